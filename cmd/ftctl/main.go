@@ -0,0 +1,537 @@
+// ftctl - утилита администратора для self-hosted установки: пользователи, миграции,
+// обновление цен, выгрузка данных, тестовые данные и проверка провайдеров. Настройки берет из того же .env
+// и файла CONFIG_FILE, что и сервер
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/database"
+	"github.com/alligatorO15/fin-tracker/internal/format"
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+)
+
+const usage = `Использование: ftctl <команда> [флаги]
+
+Команды:
+  user create -email E -password P -first-name N [-last-name N] [-currency RUB]
+  user reset-password -email E -password P
+  migrate up | down -yes | status
+  prices sync
+  prices backfill -ticker T [-exchange MOEX] | -id UUID [-years 5]
+  securities duplicates
+  securities merge -from UUID -into UUID
+  export -email E [-out файл] [-format json|csv] [-locale ru-RU]
+  seed [-file seed.json] | [-users 3] [-months 6] [-portfolios 1] [-email-prefix demo] [-email-domain D] [-random-seed 42] [-print]
+  providers
+  config check
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// без .env - переменные окружения, как у сервера
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	args := os.Args[2:]
+
+	switch os.Args[1] {
+	case "user":
+		err = runUser(ctx, cfg, args)
+	case "migrate":
+		err = runMigrate(cfg, args)
+	case "prices":
+		err = runPrices(ctx, cfg, args)
+	case "securities":
+		err = runSecurities(ctx, cfg, args)
+	case "export":
+		err = runExport(ctx, cfg, args)
+	case "seed":
+		err = runSeed(ctx, cfg, args)
+	case "providers":
+		err = runProviders(ctx, cfg)
+	case "config":
+		// ошибки конфигурации уже выведены при загрузке, сюда доходит только корректная
+		if len(args) != 1 || args[0] != "check" {
+			err = fmt.Errorf("использование: ftctl config check")
+			break
+		}
+		fmt.Println("конфигурация в порядке")
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная команда %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// connect подключается к бд; сервисы собираются так же, как в cmd/server
+func connect(cfg *config.Config) (*pgxpool.Pool, *service.Services, error) {
+	db, err := database.NewPostgresDB(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("подключение к базе данных: %w", err)
+	}
+	repos := repository.NewRepositories(db)
+	return db, service.NewServices(repos, market.NewMultiProvider(cfg), cfg), nil
+}
+
+func runUser(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("нужна подкоманда: create или reset-password")
+	}
+
+	fs := flag.NewFlagSet("user "+args[0], flag.ExitOnError)
+	email := fs.String("email", "", "email пользователя")
+	password := fs.String("password", "", "пароль (не короче 8 символов)")
+
+	switch args[0] {
+	case "create":
+		firstName := fs.String("first-name", "", "имя")
+		lastName := fs.String("last-name", "", "фамилия")
+		currency := fs.String("currency", "", "основная валюта (по умолчанию DEFAULT_CURRENCY)")
+		fs.Parse(args[1:])
+
+		input := &models.UserRegistration{
+			Email:           strings.TrimSpace(*email),
+			Password:        *password,
+			FirstName:       *firstName,
+			LastName:        *lastName,
+			DefaultCurrency: strings.ToUpper(*currency),
+		}
+		// те же правила, что у POST /auth/register
+		if err := binding.Validator.ValidateStruct(input); err != nil {
+			return err
+		}
+
+		db, services, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		user, err := services.Auth.CreateUser(ctx, input)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Создан пользователь %s (%s)\n", user.Email, user.ID)
+		return nil
+
+	case "reset-password":
+		fs.Parse(args[1:])
+		if *email == "" {
+			return fmt.Errorf("укажите -email")
+		}
+
+		db, services, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		if err := services.Auth.ResetPassword(ctx, strings.TrimSpace(*email), *password); err != nil {
+			return err
+		}
+		fmt.Printf("Пароль %s изменен, все сессии завершены\n", *email)
+		return nil
+	}
+
+	return fmt.Errorf("неизвестная подкоманда user %q", args[0])
+}
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("нужна подкоманда: up, down или status")
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	yes := fs.Bool("yes", false, "подтвердить откат (данные таблиц миграции будут удалены)")
+	fs.Parse(args[1:])
+
+	db, err := database.NewPostgresDB(cfg)
+	if err != nil {
+		return fmt.Errorf("подключение к базе данных: %w", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		return database.RunMigrations(db)
+
+	case "down":
+		if !*yes {
+			return fmt.Errorf("откат удаляет таблицы и колонки последней миграции вместе с данными; повторите с -yes")
+		}
+		version, err := database.RollbackMigration(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Миграция %d откачена. Сервер применит ее снова при следующем запуске\n", version)
+		return nil
+
+	case "status":
+		status, err := database.GetMigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ВЕРСИЯ\tПРИМЕНЕНА\tОТКАТ")
+		for _, m := range status {
+			applied := "-"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			reversible := "нет"
+			if m.Reversible {
+				reversible = "есть"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, applied, reversible)
+		}
+		return w.Flush()
+	}
+
+	return fmt.Errorf("неизвестная подкоманда migrate %q", args[0])
+}
+
+func runPrices(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("нужна подкоманда: sync или backfill")
+	}
+
+	switch args[0] {
+	case "sync":
+		db, services, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		started := time.Now()
+		refreshed, err := services.Portfolio.RefreshAll(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Обновлены цены %d портфелей за %s\n", refreshed, time.Since(started).Round(time.Second))
+		return nil
+
+	case "backfill":
+		fs := flag.NewFlagSet("prices backfill", flag.ExitOnError)
+		ticker := fs.String("ticker", "", "тикер бумаги")
+		exchange := fs.String("exchange", string(models.ExchangeMOEX), "биржа бумаги")
+		id := fs.String("id", "", "ID бумаги вместо тикера")
+		years := fs.Int("years", 5, "за сколько лет загрузить историю")
+		fs.Parse(args[1:])
+		if *ticker == "" && *id == "" {
+			return fmt.Errorf("укажите -ticker или -id")
+		}
+
+		db, services, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		securityID, err := resolveSecurityID(ctx, services, *id, strings.ToUpper(strings.TrimSpace(*ticker)), models.Exchange(strings.ToUpper(*exchange)))
+		if err != nil {
+			return err
+		}
+		result, err := services.Investment.BackfillHistory(ctx, uuid.Nil, securityID, *years)
+		if err != nil {
+			return err
+		}
+		if result.From == nil {
+			fmt.Printf("%s: провайдер не отдал историю\n", result.Ticker)
+			return nil
+		}
+		fmt.Printf("%s: %d свечей с %s по %s, пересчитано %d снимков стоимости в %d портфелях\n",
+			result.Ticker, result.Bars, result.From.Format("2006-01-02"), result.To.Format("2006-01-02"), result.Snapshots, result.Portfolios)
+		return nil
+	}
+
+	return fmt.Errorf("неизвестная подкоманда prices %q", args[0])
+}
+
+// resolveSecurityID бумага по ID или точному совпадению тикера на бирже
+func resolveSecurityID(ctx context.Context, services *service.Services, id, ticker string, exchange models.Exchange) (uuid.UUID, error) {
+	if id != "" {
+		return uuid.Parse(id)
+	}
+	securities, err := services.Investment.SearchSecurities(ctx, uuid.Nil, ticker, nil, &exchange)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	for _, security := range securities {
+		if security.Ticker == ticker && security.Exchange == exchange {
+			return security.ID, nil
+		}
+	}
+	return uuid.Nil, fmt.Errorf("бумага %s на %s не найдена", ticker, exchange)
+}
+
+func runSecurities(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("нужна подкоманда: duplicates или merge")
+	}
+
+	switch args[0] {
+	case "duplicates":
+		db, services, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		groups, err := services.Investment.FindDuplicateSecurities(ctx)
+		if err != nil {
+			return err
+		}
+		if len(groups) == 0 {
+			fmt.Println("Дублей не найдено")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ПРИЧИНА\tКЛЮЧ\tID\tБИРЖА\tТИКЕР\tНАЗВАНИЕ\tПОЗИЦИЙ\tОПЕРАЦИЙ\t")
+		for _, group := range groups {
+			for _, d := range group.Securities {
+				mark := ""
+				if d.ID == group.SuggestedTargetID {
+					mark = "основная"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+					group.Reason, group.Key, d.ID, d.Exchange, d.Ticker, d.Name, d.Holdings, d.Transactions, mark)
+			}
+		}
+		return w.Flush()
+
+	case "merge":
+		fs := flag.NewFlagSet("securities merge", flag.ExitOnError)
+		from := fs.String("from", "", "ID бумаги, которая удаляется")
+		into := fs.String("into", "", "ID бумаги, в которую переносятся позиции и операции")
+		fs.Parse(args[1:])
+
+		sourceID, err := uuid.Parse(*from)
+		if err != nil {
+			return fmt.Errorf("неверный -from: %w", err)
+		}
+		targetID, err := uuid.Parse(*into)
+		if err != nil {
+			return fmt.Errorf("неверный -into: %w", err)
+		}
+
+		db, services, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		result, err := services.Investment.MergeSecurities(ctx, &models.SecurityMergeRequest{SourceID: sourceID, TargetID: targetID})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Бумага %s объединена с %s: позиций %d, операций %d\n",
+			result.SourceID, result.TargetID, result.Holdings, result.Transactions)
+		return nil
+	}
+
+	return fmt.Errorf("неизвестная подкоманда securities %q", args[0])
+}
+
+func runExport(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	email := fs.String("email", "", "email пользователя")
+	out := fs.String("out", "", "файл для выгрузки (по умолчанию stdout)")
+	outFormat := fs.String("format", "json", "json - все данные, csv - транзакции для таблиц")
+	locale := fs.String("locale", "", "формат сумм и дат в csv (по умолчанию из профиля)")
+	fs.Parse(args)
+	if *email == "" {
+		return fmt.Errorf("укажите -email")
+	}
+	if *outFormat != "json" && *outFormat != "csv" {
+		return fmt.Errorf("неизвестный формат %q: json или csv", *outFormat)
+	}
+	if *locale != "" && !format.Supported(*locale) {
+		return fmt.Errorf("неизвестная локаль %q", *locale)
+	}
+
+	db, services, err := connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	user, err := services.User.GetByEmail(ctx, strings.TrimSpace(*email))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		// в выгрузке личные данные - файл только для владельца
+		f, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *outFormat == "csv" {
+		n, err := services.Export.TransactionsCSV(ctx, user.ID, *locale, w)
+		if err != nil {
+			return err
+		}
+		if *out != "" {
+			fmt.Fprintf(os.Stderr, "Выгружено в %s: %d транзакций\n", *out, n)
+		}
+		return nil
+	}
+
+	export, err := services.Export.Export(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return err
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "Выгружено в %s: %d транзакций, %d портфелей\n", *out, len(export.Transactions), len(export.Portfolios))
+	}
+	return nil
+}
+
+func runSeed(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	file := fs.String("file", "", "описание тестовых данных в JSON; без него данные генерируются по флагам ниже")
+	users := fs.Int("users", 3, "сколько пользователей сгенерировать")
+	months := fs.Int("months", 6, "за сколько месяцев операции")
+	portfolios := fs.Int("portfolios", 1, "портфелей у пользователя: 0, 1 или 2")
+	emailPrefix := fs.String("email-prefix", "demo", "email пользователей: <prefix>1@<domain>, <prefix>2@<domain>...")
+	emailDomain := fs.String("email-domain", "fin-tracker.local", "домен email пользователей")
+	randomSeed := fs.Int64("random-seed", 42, "одинаковый seed - одинаковые суммы и даты")
+	printOnly := fs.Bool("print", false, "только вывести описание в JSON, ничего не записывая")
+	fs.Parse(args)
+
+	var spec *models.SeedSpec
+	var err error
+	if *file != "" {
+		spec, err = service.LoadSeedSpec(*file)
+	} else {
+		spec, err = service.GenerateSeedSpec(models.SeedOptions{
+			Users:       *users,
+			Months:      *months,
+			Portfolios:  *portfolios,
+			EmailPrefix: *emailPrefix,
+			EmailDomain: *emailDomain,
+			RandomSeed:  *randomSeed,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if *printOnly {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(spec)
+	}
+
+	db, services, err := connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := services.Seed.Apply(ctx, spec)
+	if result != nil {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "EMAIL\tПАРОЛЬ\tОПЕРАЦИЙ\tПОРТФЕЛЕЙ\tСДЕЛОК")
+		for _, u := range result.Users {
+			if u.Skipped {
+				fmt.Fprintf(w, "%s\tуже есть, пропущен\t-\t-\t-\n", u.Email)
+				continue
+			}
+			password := u.Password
+			if password == "" {
+				password = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n", u.Email, password, u.Transactions, u.Portfolios, u.Trades)
+		}
+		if werr := w.Flush(); werr != nil && err == nil {
+			err = werr
+		}
+		for _, u := range result.Users {
+			for _, warning := range u.Warnings {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", u.Email, warning)
+			}
+		}
+	}
+	return err
+}
+
+func runProviders(ctx context.Context, cfg *config.Config) error {
+	db, services, err := connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ready := services.Health.Ready(ctx, nil)
+	fmt.Printf("База данных: %s\n", ready.Database)
+
+	providers := services.Health.PingProviders(ctx)
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	marketOK := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ПРОВАЙДЕР\tСОСТОЯНИЕ")
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, providers[name])
+		marketOK = marketOK || providers[name] == "ok"
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// нужна бд и хотя бы один провайдер котировок
+	if !ready.Ready || !marketOK {
+		return fmt.Errorf("не все зависимости доступны")
+	}
+	return nil
+}
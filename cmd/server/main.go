@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/api"
 	"github.com/alligatorO15/fin-tracker/internal/config"
 	"github.com/alligatorO15/fin-tracker/internal/database"
 	"github.com/alligatorO15/fin-tracker/internal/market"
 	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/alligatorO15/fin-tracker/internal/scheduler"
 	"github.com/alligatorO15/fin-tracker/internal/service"
 	"github.com/joho/godotenv"
 )
@@ -19,11 +25,14 @@ func main() {
 		log.Println("Файл .env не найден, используются переменные окружения")
 	}
 
-	// загрузка конфигурации
-	cfg := config.Load()
+	// загрузка конфигурации: переменные окружения и файл CONFIG_FILE
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Ошибка конфигурации: %v", err)
+	}
 
 	// инициализация базы данных
-	db, err := database.NewPostgresDB(cfg.DatabaseURL)
+	db, err := database.NewPostgresDB(cfg)
 	if err != nil {
 		log.Fatalf("Ошибка подключения к базе данных: %v", err)
 	}
@@ -35,6 +44,15 @@ func main() {
 	}
 
 	// инициализация репозиториев
+	repository.SetRetryPolicy(repository.RetryPolicy{Attempts: cfg.DBRetryAttempts, Backoff: 100 * time.Millisecond})
+	replica, err := database.NewReplicaDB(cfg)
+	if err != nil {
+		log.Printf("Реплика бд отключена: %v", err)
+	}
+	if replica != nil {
+		defer replica.Close()
+		repository.SetReadReplica(replica)
+	}
 	repos := repository.NewRepositories(db)
 
 	// инициализация провайдера рыночных данных
@@ -42,17 +60,303 @@ func main() {
 
 	// инициализация сервисов
 	services := service.NewServices(repos, marketProvider, cfg)
+	if cfg.DemoMode {
+		if err := services.Demo.Seed(context.Background()); err != nil {
+			log.Printf("Не удалось заполнить демо-пользователя: %v", err)
+		}
+	}
+	// тестовые данные для разработки и стендов; при повторных запусках заведенные пользователи пропускаются
+	if cfg.SeedFile != "" {
+		if err := seedFromFile(services.Seed, cfg.SeedFile); err != nil {
+			log.Printf("Не удалось загрузить тестовые данные из %s: %v", cfg.SeedFile, err)
+		}
+	}
+
+	// фоновые задачи
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// деноминации валют нужны каждому экземпляру, поэтому перечитываются в обход планировщика
+	if err := services.Currency.Load(ctx); err != nil {
+		log.Printf("Не удалось загрузить деноминации валют: %v", err)
+	}
+	go services.Currency.Watch(ctx, 10*time.Minute)
+
+	// несколько экземпляров за балансировщиком: задачи выполняет один из них
+	var elector scheduler.Elector
+	var jobStore scheduler.JobStore
+	if cfg.SchedulerLeaderElection {
+		elector = scheduler.NewPostgresElector(db, "fin-tracker-scheduler")
+		jobStore = scheduler.NewPostgresJobStore(db, "fin-tracker-scheduler")
+	}
+	jobs := scheduler.New(elector, jobStore)
+	jobs.Add(scheduler.Job{
+		Name:     "purge-deleted-users",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			purged, err := services.User.PurgeDeleted(ctx)
+			if purged > 0 {
+				log.Printf("Удалены данные %d пользователей", purged)
+			}
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "purge-login-attempts",
+		Interval: 24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := services.LoginThrottle.PurgeStale(ctx)
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "purge-telegram-updates",
+		Interval: 24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := services.Telegram.PurgeUpdates(ctx)
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "purge-csv-uploads",
+		Interval: 24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := services.CSVImport.PurgeUploads(ctx)
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "sector-backfill",
+		Interval: 24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			result, err := services.Sector.Backfill(ctx)
+			if result != nil && result.Updated > 0 {
+				log.Printf("Заполнены секторы для %d бумаг", result.Updated)
+			}
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "refresh-fundamentals",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			refreshed, err := services.Fundamentals.RefreshStale(ctx)
+			if refreshed > 0 {
+				log.Printf("Обновлены мультипликаторы %d бумаг", refreshed)
+			}
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "sync-portfolio-goals",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := services.Goal.SyncPortfolioGoals(ctx)
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "rebuild-queued-holdings",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			rebuilt, err := services.Investment.RebuildQueuedHoldings(ctx)
+			if rebuilt > 0 {
+				log.Printf("Пересчитано %d позиций из очереди", rebuilt)
+			}
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "post-planned-transactions",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			result, err := services.Planned.PostDue(ctx)
+			if result != nil && result.Posted > 0 {
+				log.Printf("Проведено %d запланированных платежей", result.Posted)
+			}
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "apply-account-rules",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			result, err := services.AccountRule.ApplyDue(ctx)
+			if result != nil && result.Applied > 0 {
+				log.Printf("Проведено %d начислений и списаний по правилам счетов", result.Applied)
+			}
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "evaluate-challenges",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			result, err := services.Challenge.Evaluate(ctx)
+			if result != nil && result.Completed+result.Failed > 0 {
+				log.Printf("Подведено периодов челленджей: выполнено %d, не выполнено %d", result.Completed, result.Failed)
+			}
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "settle-expired-derivatives",
+		Interval: 6 * time.Hour,
+		Run: func(ctx context.Context) error {
+			settled, err := services.Investment.SettleExpiredDerivatives(ctx)
+			if settled > 0 {
+				log.Printf("Закрыто %d позиций по истекшим контрактам", settled)
+			}
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "sync-bond-principal",
+		Interval: 24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			created, err := services.Investment.SyncBondPrincipal(ctx)
+			if created > 0 {
+				log.Printf("Проведено %d амортизаций и погашений облигаций", created)
+			}
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "snapshot-financial-health",
+		Interval: 6 * time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := services.Analytics.SnapshotFinancialHealth(ctx)
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "notify-anomalies",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := services.Analytics.NotifyAnomalies(ctx)
+			return err
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "check-portfolio-rebalance",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := services.Portfolio.RefreshWatched(ctx)
+			return err
+		},
+	})
+	// MAIL_POLL_MINUTES=0 - ящики опрашиваются только вручную
+	if cfg.MailPollInterval > 0 {
+		jobs.Add(scheduler.Job{
+			Name:     "poll-mailboxes",
+			Interval: cfg.MailPollInterval,
+			Run: func(ctx context.Context) error {
+				result, err := services.MailImport.PollAll(ctx)
+				if result != nil && result.Drafts > 0 {
+					log.Printf("Из почты создано %d черновиков транзакций", result.Drafts)
+				}
+				return err
+			},
+		})
+	}
+	// без SMTP_HOST отчеты не рассылаются
+	if cfg.SMTPHost != "" {
+		jobs.Add(scheduler.Job{
+			Name:     "send-report-emails",
+			Interval: 15 * time.Minute,
+			Run: func(ctx context.Context) error {
+				result, err := services.Report.SendDue(ctx)
+				if result != nil && result.Sent+result.Failed > 0 {
+					log.Printf("Отправлено отчетов: %d, с ошибкой: %d", result.Sent, result.Failed)
+				}
+				return err
+			},
+		})
+	}
+	jobs.Start(ctx)
+	go shutdownOnSignal(cancel, services)
 
 	// инициализация и запуск API сервера
 	server := api.NewServer(cfg, services)
+	reloadOnSIGHUP(ctx, cfg, server, marketProvider)
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	log.Printf("Запуск сервера FinTracker на порту %s", port)
+	scheme := "http"
+	if cfg.TLSEnabled() {
+		scheme = "https"
+	}
+	log.Printf("Запуск сервера FinTracker на порту %s (%s)", port, scheme)
 	if err := server.Run(":" + port); err != nil {
 		log.Fatalf("Ошибка запуска сервера: %v", err)
 	}
 }
+
+// seedFromFile применяет описание тестовых данных из SEED_FILE
+func seedFromFile(seed service.SeedService, path string) error {
+	spec, err := service.LoadSeedSpec(path)
+	if err != nil {
+		return err
+	}
+	result, err := seed.Apply(context.Background(), spec)
+	if result != nil {
+		for _, u := range result.Users {
+			if !u.Skipped {
+				log.Printf("Тестовые данные: %s - %d операций, %d сделок", u.Email, u.Transactions, u.Trades)
+			}
+		}
+	}
+	return err
+}
+
+// shutdownOnSignal по SIGINT/SIGTERM останавливает фоновые задачи и дожидается фоновых генераций,
+// чтобы они не остались в бд выполняющимися
+func shutdownOnSignal(cancel context.CancelFunc, services *service.Services) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+
+	log.Println("Остановка сервера")
+	cancel()
+	ctx, stop := context.WithTimeout(context.Background(), 10*time.Second)
+	defer stop()
+	services.Analytics.Shutdown(ctx)
+	os.Exit(0)
+}
+
+// reloadOnSIGHUP перечитывает конфигурацию по SIGHUP и применяет то, что меняется без перезапуска:
+// включение провайдеров, лимиты запросов, уровень логов. переменные окружения процесса при этом
+// не меняются, так что на лету правится только файл CONFIG_FILE. обработчик ставится до возврата,
+// иначе SIGHUP, пришедший раньше, завершил бы процесс
+func reloadOnSIGHUP(ctx context.Context, startup *config.Config, server *api.Server, marketProvider *market.MultiProvider) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+			}
+
+			next, err := config.Load()
+			if err != nil {
+				log.Printf("Конфигурация не перечитана, действуют прежние настройки: %v", err)
+				continue
+			}
+
+			marketProvider.SetEnabled(next.MOEXEnabled, next.ForeignEnabled)
+			server.Reload(next)
+			log.Printf("Конфигурация перечитана: MOEX %t, иностранные биржи %t, уровень логов %s",
+				next.MOEXEnabled, next.ForeignEnabled, next.LogLevel)
+			if keys := startup.RestartRequired(next); len(keys) > 0 {
+				log.Printf("Изменения вступят в силу только после перезапуска: %s", strings.Join(keys, ", "))
+			}
+		}
+	}()
+}
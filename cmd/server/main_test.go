@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/api"
+	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+)
+
+func TestReloadOnSIGHUPAppliesRateLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(ipRule string) {
+		body := "rate_limit:\n  redis_url: \"\"\n  ip: \"" + ipRule + "\"\n  auth: \"0\"\n"
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	writeConfig("1/m")
+
+	t.Setenv(config.FileEnv, path)
+	// значения из окружения перекрыли бы файл
+	for _, key := range []string{"RATE_LIMIT_ENABLED", "RATE_LIMIT_REDIS_URL", "RATE_LIMIT_IP", "RATE_LIMIT_AUTH"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	server := api.NewServer(cfg, &service.Services{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloadOnSIGHUP(ctx, cfg, server, market.NewMultiProvider(cfg))
+
+	// на пустое тело логин отвечает 400, не доходя до сервиса
+	login := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	login()
+	if code := login(); code != http.StatusTooManyRequests {
+		t.Fatalf("status before reload = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	writeConfig("0")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for login() == http.StatusTooManyRequests {
+		if time.Now().After(deadline) {
+			t.Fatal("rate limit was not reloaded after SIGHUP")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
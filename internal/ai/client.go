@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"strings"
+)
+
+// провайдеры AI, выбираются через AI_PROVIDER
+const (
+	ProviderNone   = "none"
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai" // любой OpenAI-совместимый API (OpenAI, OpenRouter, vLLM, LM Studio...)
+)
+
+// Client AI-провайдер для рекомендаций, категоризации и кратких сводок
+type Client interface {
+	// GetFinancialAdvice генерирует рекомендации на основе финансовых данных
+	GetFinancialAdvice(ctx context.Context, data FinancialSummary) (string, error)
+	// Categorize выбирает для описания операции одну из переданных категорий, "" - если не подошла ни одна
+	Categorize(ctx context.Context, description string, categories []string) (string, error)
+	// Summarize краткий пересказ текста
+	Summarize(ctx context.Context, text string) (string, error)
+	// IsAvailable проверяет доступность провайдера
+	IsAvailable(ctx context.Context) bool
+}
+
+// generator отправляет промпт модели и возвращает ответ; общие методы Client строятся поверх него
+type generator interface {
+	generate(ctx context.Context, prompt string) (string, error)
+}
+
+func financialAdvice(ctx context.Context, g generator, data FinancialSummary) (string, error) {
+	return g.generate(ctx, buildAdvicePrompt(data))
+}
+
+func categorize(ctx context.Context, g generator, description string, categories []string) (string, error) {
+	if len(categories) == 0 {
+		return "", nil
+	}
+	answer, err := g.generate(ctx, buildCategorizePrompt(description, categories))
+	if err != nil {
+		return "", err
+	}
+	return matchCategory(answer, categories), nil
+}
+
+func summarize(ctx context.Context, g generator, text string) (string, error) {
+	answer, err := g.generate(ctx, buildSummarizePrompt(text))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// matchCategory сопоставляет ответ модели со списком: модели часто добавляют кавычки, точку или пояснение
+func matchCategory(answer string, categories []string) string {
+	answer = strings.ToLower(strings.Trim(strings.TrimSpace(answer), "\"'«».!"))
+	if answer == "" {
+		return ""
+	}
+
+	for _, c := range categories {
+		if strings.ToLower(c) == answer {
+			return c
+		}
+	}
+
+	// самое длинное название, которое встречается в ответе
+	best := ""
+	for _, c := range categories {
+		if strings.Contains(answer, strings.ToLower(c)) && len(c) > len(best) {
+			best = c
+		}
+	}
+	return best
+}
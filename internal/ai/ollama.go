@@ -7,10 +7,10 @@ import (
 	"fmt"
 	"net/http"
 	"time"
-
-	"github.com/shopspring/decimal"
 )
 
+var _ Client = (*OllamaClient)(nil)
+
 type OllamaClient struct {
 	baseURL string
 	model   string
@@ -38,88 +38,16 @@ func NewOllamaClient(baseURL, model string) *OllamaClient {
 	}
 }
 
-// GetFinancialAdvice генерирует рекомендации на основе финансовых данных
 func (c *OllamaClient) GetFinancialAdvice(ctx context.Context, data FinancialSummary) (string, error) {
-	prompt := c.buildPrompt(data)
-	return c.generate(ctx, prompt)
-}
-
-type FinancialSummary struct {
-	TotalIncome   decimal.Decimal    `json:"total_income"`
-	TotalExpenses decimal.Decimal    `json:"total_expenses"`
-	Balance       decimal.Decimal    `json:"balance"`
-	TopCategories []CategorySpending `json:"top_categories"`
-	SavingsRate   decimal.Decimal    `json:"savings_rate"`
-	BudgetStatus  []BudgetStatus     `json:"budget_status"`
-	Currency      string             `json:"currency"`
-}
-
-type CategorySpending struct {
-	Name   string          `json:"name"`
-	Amount decimal.Decimal `json:"amount"`
-}
-
-type BudgetStatus struct {
-	Category string          `json:"category"`
-	Limit    decimal.Decimal `json:"limit"`
-	Spent    decimal.Decimal `json:"spent"`
-	Percent  decimal.Decimal `json:"percent"`
+	return financialAdvice(ctx, c, data)
 }
 
-func (c *OllamaClient) buildPrompt(data FinancialSummary) string {
-	return fmt.Sprintf(`Ты финансовый консультант. Проанализируй данные пользователя и дай 3-5 кратких рекомендаций на русском языке.
-
-Финансовые данные за месяц:
-- Доходы: %s %s
-- Расходы: %s %s
-- Баланс: %s %s
-- Норма сбережений: %s%%
-
-Топ категории расходов:
-%s
-
-Статус бюджетов:
-%s
-
-Дай конкретные, практичные рекомендации. Отвечай кратко, по делу.`,
-		data.TotalIncome.StringFixed(2), data.Currency,
-		data.TotalExpenses.StringFixed(2), data.Currency,
-		data.Balance.StringFixed(2), data.Currency,
-		data.SavingsRate.StringFixed(1),
-		formatCategories(data.TopCategories, data.Currency),
-		formatBudgets(data.BudgetStatus, data.Currency),
-	)
+func (c *OllamaClient) Categorize(ctx context.Context, description string, categories []string) (string, error) {
+	return categorize(ctx, c, description, categories)
 }
 
-func formatCategories(categories []CategorySpending, currency string) string {
-	if len(categories) == 0 {
-		return "Нет данных"
-	}
-	var result string
-	for _, c := range categories {
-		result += fmt.Sprintf("- %s: %s %s\n", c.Name, c.Amount.StringFixed(2), currency)
-	}
-	return result
-}
-
-func formatBudgets(budgets []BudgetStatus, currency string) string {
-	if len(budgets) == 0 {
-		return "Бюджеты не установлены"
-	}
-	hundred := decimal.NewFromInt(100)
-	eighty := decimal.NewFromInt(80)
-	var result string
-	for _, b := range budgets {
-		status := "✓"
-		if b.Percent.GreaterThan(hundred) {
-			status = "⚠️ превышен"
-		} else if b.Percent.GreaterThan(eighty) {
-			status = "⚡ близко к лимиту"
-		}
-		result += fmt.Sprintf("- %s: %s/%s %s (%s%%) %s\n",
-			b.Category, b.Spent.StringFixed(2), b.Limit.StringFixed(2), currency, b.Percent.StringFixed(0), status)
-	}
-	return result
+func (c *OllamaClient) Summarize(ctx context.Context, text string) (string, error) {
+	return summarize(ctx, c, text)
 }
 
 func (c *OllamaClient) generate(ctx context.Context, prompt string) (string, error) {
@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var _ Client = (*OpenAIClient)(nil)
+
+// OpenAIClient клиент OpenAI-совместимого API (/chat/completions)
+type OpenAIClient struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// NewOpenAIClient baseURL вместе с версией API, например https://api.openai.com/v1; apiKey может быть пустым для локальных серверов
+func NewOpenAIClient(baseURL, apiKey, model string) *OpenAIClient {
+	return &OpenAIClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client: &http.Client{
+			Timeout: 120 * time.Second,
+		},
+	}
+}
+
+func (c *OpenAIClient) GetFinancialAdvice(ctx context.Context, data FinancialSummary) (string, error) {
+	return financialAdvice(ctx, c, data)
+}
+
+func (c *OpenAIClient) Categorize(ctx context.Context, description string, categories []string) (string, error) {
+	return categorize(ctx, c, description, categories)
+}
+
+func (c *OpenAIClient) Summarize(ctx context.Context, text string) (string, error) {
+	return summarize(ctx, c, text)
+}
+
+func (c *OpenAIClient) generate(ctx context.Context, prompt string) (string, error) {
+	reqBody := chatCompletionRequest{
+		Model:    c.model,
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+		Stream:   false,
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("openai-compatible API returned status %d", resp.StatusCode)
+	}
+
+	var result chatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("openai-compatible API returned no choices")
+	}
+
+	return result.Choices[0].Message.Content, nil
+}
+
+// IsAvailable проверяет доступность API по списку моделей
+func (c *OpenAIClient) IsAvailable(ctx context.Context) bool {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/models", nil)
+	if err != nil {
+		return false
+	}
+	c.authorize(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
+
+func (c *OpenAIClient) authorize(req *http.Request) {
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
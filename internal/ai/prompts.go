@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+type FinancialSummary struct {
+	TotalIncome   decimal.Decimal    `json:"total_income"`
+	TotalExpenses decimal.Decimal    `json:"total_expenses"`
+	Balance       decimal.Decimal    `json:"balance"`
+	TopCategories []CategorySpending `json:"top_categories"`
+	SavingsRate   decimal.Decimal    `json:"savings_rate"`
+	BudgetStatus  []BudgetStatus     `json:"budget_status"`
+	Currency      string             `json:"currency"`
+}
+
+type CategorySpending struct {
+	Name   string          `json:"name"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+type BudgetStatus struct {
+	Category string          `json:"category"`
+	Limit    decimal.Decimal `json:"limit"`
+	Spent    decimal.Decimal `json:"spent"`
+	Percent  decimal.Decimal `json:"percent"`
+}
+
+func buildAdvicePrompt(data FinancialSummary) string {
+	return fmt.Sprintf(`Ты финансовый консультант. Проанализируй данные пользователя и дай 3-5 кратких рекомендаций на русском языке.
+
+%s
+Дай конкретные, практичные рекомендации. Отвечай кратко, по делу.`,
+		FormatSummary(data),
+	)
+}
+
+func buildCategorizePrompt(description string, categories []string) string {
+	return fmt.Sprintf(`Определи категорию банковской операции.
+
+Описание операции: %s
+
+Возможные категории:
+%s
+Ответь только названием одной категории из списка, без пояснений. Если ни одна не подходит, ответь "нет".`,
+		description,
+		"- "+strings.Join(categories, "\n- ")+"\n",
+	)
+}
+
+func buildSummarizePrompt(text string) string {
+	return fmt.Sprintf(`Кратко перескажи текст на русском языке в 2-3 предложениях. Без вступлений и списков.
+
+%s`, text)
+}
+
+// FormatSummary финансовые данные за месяц в виде текста для промпта
+func FormatSummary(data FinancialSummary) string {
+	return fmt.Sprintf(`Финансовые данные за месяц:
+- Доходы: %s %s
+- Расходы: %s %s
+- Баланс: %s %s
+- Норма сбережений: %s%%
+
+Топ категории расходов:
+%s
+Статус бюджетов:
+%s`,
+		data.TotalIncome.StringFixed(2), data.Currency,
+		data.TotalExpenses.StringFixed(2), data.Currency,
+		data.Balance.StringFixed(2), data.Currency,
+		data.SavingsRate.StringFixed(1),
+		formatCategories(data.TopCategories, data.Currency),
+		formatBudgets(data.BudgetStatus, data.Currency),
+	)
+}
+
+func formatCategories(categories []CategorySpending, currency string) string {
+	if len(categories) == 0 {
+		return "Нет данных\n"
+	}
+	var result string
+	for _, c := range categories {
+		result += fmt.Sprintf("- %s: %s %s\n", c.Name, c.Amount.StringFixed(2), currency)
+	}
+	return result
+}
+
+func formatBudgets(budgets []BudgetStatus, currency string) string {
+	if len(budgets) == 0 {
+		return "Бюджеты не установлены\n"
+	}
+	hundred := decimal.NewFromInt(100)
+	eighty := decimal.NewFromInt(80)
+	var result string
+	for _, b := range budgets {
+		status := "✓"
+		if b.Percent.GreaterThan(hundred) {
+			status = "⚠️ превышен"
+		} else if b.Percent.GreaterThan(eighty) {
+			status = "⚡ близко к лимиту"
+		}
+		result += fmt.Sprintf("- %s: %s/%s %s (%s%%) %s\n",
+			b.Category, b.Spent.StringFixed(2), b.Limit.StringFixed(2), currency, b.Percent.StringFixed(0), status)
+	}
+	return result
+}
@@ -23,45 +23,80 @@ func (h *AccountHandler) Create(c *gin.Context) {
 
 	var input models.AccountCreate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	account, err := h.accountService.Create(c.Request.Context(), userID, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, account)
+	respond(c, http.StatusCreated, account)
 }
 
+// List GET /accounts; ?grouped=true - вместе с заголовками групп по настройке пользователя
 func (h *AccountHandler) List(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
+	if c.Query("grouped") == "true" {
+		list, err := h.accountService.GetList(c.Request.Context(), userID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		respond(c, http.StatusOK, list)
+		return
+	}
+
 	accounts, err := h.accountService.GetByUserID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, accounts)
+}
+
+// SetOrder PATCH /accounts/order {"account_ids": [...], "group_by": "institution", "groups": {"id": "Семья"}}
+func (h *AccountHandler) SetOrder(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.AccountOrder
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	list, err := h.accountService.SetOrder(c.Request.Context(), userID, &input)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidAccountGrouping, service.ErrAccountOrderUnknown, service.ErrInvalidAccountGroup:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, accounts)
+	respond(c, http.StatusOK, list)
 }
 
 func (h *AccountHandler) GetByID(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+		respondError(c, http.StatusBadRequest, errInvalidAccountID)
 		return
 	}
 
 	account, err := h.accountService.GetByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		respondError(c, http.StatusNotFound, service.ErrAccountNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, account)
+	respond(c, http.StatusOK, account)
 }
 
 func (h *AccountHandler) GetSummary(c *gin.Context) {
@@ -69,46 +104,46 @@ func (h *AccountHandler) GetSummary(c *gin.Context) {
 
 	summary, err := h.accountService.GetSummary(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, summary)
+	respond(c, http.StatusOK, summary)
 }
 
 func (h *AccountHandler) Update(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+		respondError(c, http.StatusBadRequest, errInvalidAccountID)
 		return
 	}
 
 	var input models.AccountUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	account, err := h.accountService.Update(c.Request.Context(), id, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, account)
+	respond(c, http.StatusOK, account)
 }
 
 func (h *AccountHandler) Delete(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+		respondError(c, http.StatusBadRequest, errInvalidAccountID)
 		return
 	}
 
 	if err := h.accountService.Delete(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "account deleted"})
+	respond(c, http.StatusOK, gin.H{"message": "account deleted"})
 }
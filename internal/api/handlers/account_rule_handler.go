@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AccountRuleHandler struct {
+	ruleService service.AccountRuleService
+}
+
+func NewAccountRuleHandler(ruleService service.AccountRuleService) *AccountRuleHandler {
+	return &AccountRuleHandler{ruleService: ruleService}
+}
+
+func (h *AccountRuleHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidAccountID)
+		return
+	}
+
+	rules, err := h.ruleService.List(c.Request.Context(), userID, accountID)
+	if err != nil {
+		accountRuleError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, rules)
+}
+
+func (h *AccountRuleHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidAccountID)
+		return
+	}
+
+	var input models.AccountRuleCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	rule, err := h.ruleService.Create(c.Request.Context(), userID, accountID, &input)
+	if err != nil {
+		accountRuleError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, rule)
+}
+
+func (h *AccountRuleHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidAccountID)
+		return
+	}
+	id, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidRuleID)
+		return
+	}
+
+	var input models.AccountRuleUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	rule, err := h.ruleService.Update(c.Request.Context(), userID, accountID, id, &input)
+	if err != nil {
+		accountRuleError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, rule)
+}
+
+func (h *AccountRuleHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidAccountID)
+		return
+	}
+	id, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidRuleID)
+		return
+	}
+
+	if err := h.ruleService.Delete(c.Request.Context(), userID, accountID, id); err != nil {
+		accountRuleError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "account rule deleted"})
+}
+
+func accountRuleError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrAccountNotFound, service.ErrAccountRuleNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrCategoryNotFound, service.ErrInvalidRuleAmount, service.ErrInvalidRuleRate, service.ErrInvalidRuleMax:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AdminHandler struct {
+	sectorService     service.SectorService
+	currencyService   service.CurrencyService
+	investmentService service.InvestmentService
+}
+
+func NewAdminHandler(sectorService service.SectorService, currencyService service.CurrencyService, investmentService service.InvestmentService) *AdminHandler {
+	return &AdminHandler{sectorService: sectorService, currencyService: currencyService, investmentService: investmentService}
+}
+
+func (h *AdminHandler) ListSectorMappings(c *gin.Context) {
+	var exchange *models.Exchange
+	if e := c.Query("exchange"); e != "" {
+		ex := models.Exchange(e)
+		exchange = &ex
+	}
+
+	mappings, err := h.sectorService.ListMappings(c.Request.Context(), exchange)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, mappings)
+}
+
+func (h *AdminHandler) UpsertSectorMapping(c *gin.Context) {
+	var input models.SectorMappingUpsert
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	mapping, err := h.sectorService.UpsertMapping(c.Request.Context(), &input)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, mapping)
+}
+
+func (h *AdminHandler) DeleteSectorMapping(c *gin.Context) {
+	exchange := models.Exchange(c.Param("exchange"))
+
+	if err := h.sectorService.DeleteMapping(c.Request.Context(), c.Param("ticker"), exchange); err != nil {
+		if err == service.ErrSectorMappingNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "sector mapping deleted"})
+}
+
+func (h *AdminHandler) ListSecurityTypes(c *gin.Context) {
+	var exchange *models.Exchange
+	if e := c.Query("exchange"); e != "" {
+		ex := models.Exchange(e)
+		exchange = &ex
+	}
+
+	overrides, err := h.sectorService.ListTypeOverrides(c.Request.Context(), exchange)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, overrides)
+}
+
+// UpsertSecurityType PUT /admin/security-types {"ticker": "SBERP", "exchange": "MOEX", "type": "preferred_stock"}
+func (h *AdminHandler) UpsertSecurityType(c *gin.Context) {
+	var input models.SecurityTypeOverrideUpsert
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	override, err := h.sectorService.UpsertTypeOverride(c.Request.Context(), &input)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, override)
+}
+
+func (h *AdminHandler) DeleteSecurityType(c *gin.Context) {
+	exchange := models.Exchange(c.Param("exchange"))
+
+	if err := h.sectorService.DeleteTypeOverride(c.Request.Context(), c.Param("ticker"), exchange); err != nil {
+		if err == service.ErrSecurityTypeOverrideNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "security type override deleted"})
+}
+
+func (h *AdminHandler) BackfillSectors(c *gin.Context) {
+	result, err := h.sectorService.Backfill(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
+
+func (h *AdminHandler) ListRedenominations(c *gin.Context) {
+	list, err := h.currencyService.ListRedenominations(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, list)
+}
+
+func (h *AdminHandler) CreateRedenomination(c *gin.Context) {
+	var input models.CurrencyRedenominationCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	redenomination, err := h.currencyService.CreateRedenomination(c.Request.Context(), &input)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidRedenomination:
+			respondError(c, http.StatusBadRequest, err)
+			return
+		case service.ErrRedenominationExists:
+			respondError(c, http.StatusConflict, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, redenomination)
+}
+
+func (h *AdminHandler) DeleteRedenomination(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidRedenominationID)
+		return
+	}
+
+	if err := h.currencyService.DeleteRedenomination(c.Request.Context(), id); err != nil {
+		if err == service.ErrRedenominationNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "redenomination deleted"})
+}
+
+func (h *AdminHandler) ListSecurityDuplicates(c *gin.Context) {
+	groups, err := h.investmentService.FindDuplicateSecurities(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, groups)
+}
+
+func (h *AdminHandler) MergeSecurities(c *gin.Context) {
+	var input models.SecurityMergeRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.investmentService.MergeSecurities(c.Request.Context(), &input)
+	if err != nil {
+		switch err {
+		case service.ErrSecurityNotFound:
+			respondError(c, http.StatusNotFound, err)
+			return
+		case service.ErrSecurityMergeSame, service.ErrSecurityMergeManual, service.ErrSecurityMergeMismatch:
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
@@ -35,12 +36,17 @@ func (h *AnalyticsHandler) GetSummary(c *gin.Context) {
 		}
 	}
 
-	summary, err := h.analyticsService.GetFinancialSummary(c.Request.Context(), userID, period, startDate, endDate)
+	currency, ok := currencyParam(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.analyticsService.GetFinancialSummary(c.Request.Context(), userID, period, startDate, endDate, currency)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
-	c.JSON(http.StatusOK, summary)
+	respond(c, http.StatusOK, summary)
 }
 
 func (h *AnalyticsHandler) GetCashFlow(c *gin.Context) {
@@ -59,12 +65,22 @@ func (h *AnalyticsHandler) GetCashFlow(c *gin.Context) {
 		}
 	}
 
-	report, err := h.analyticsService.GetCashFlowReport(c.Request.Context(), userID, period, startDate, endDate)
+	currency, ok := currencyParam(c)
+	if !ok {
+		return
+	}
+
+	dimension := models.CashFlowDimension(c.Query("group_by"))
+	report, err := h.analyticsService.GetCashFlowReport(c.Request.Context(), userID, period, startDate, endDate, currency, dimension)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrInvalidCashFlowDimension {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
-	c.JSON(http.StatusOK, report)
+	respond(c, http.StatusOK, report)
 }
 
 func (h *AnalyticsHandler) GetSpendingTrends(c *gin.Context) {
@@ -79,21 +95,26 @@ func (h *AnalyticsHandler) GetSpendingTrends(c *gin.Context) {
 
 	trends, err := h.analyticsService.GetSpendingTrends(c.Request.Context(), userID, months)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
-	c.JSON(http.StatusOK, trends)
+	respond(c, http.StatusOK, trends)
 }
 
 func (h *AnalyticsHandler) GetNetWorth(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	report, err := h.analyticsService.GetNetWorthReport(c.Request.Context(), userID)
+	currency, ok := currencyParam(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.analyticsService.GetNetWorthReport(c.Request.Context(), userID, currency)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
-	c.JSON(http.StatusOK, report)
+	respond(c, http.StatusOK, report)
 }
 
 func (h *AnalyticsHandler) GetFinancialHealth(c *gin.Context) {
@@ -101,10 +122,28 @@ func (h *AnalyticsHandler) GetFinancialHealth(c *gin.Context) {
 
 	health, err := h.analyticsService.GetFinancialHealth(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
-	c.JSON(http.StatusOK, health)
+	respond(c, http.StatusOK, health)
+}
+
+func (h *AnalyticsHandler) GetFinancialHealthHistory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	months := 0
+	if m := c.Query("months"); m != "" {
+		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 {
+			months = parsed
+		}
+	}
+
+	history, err := h.analyticsService.GetFinancialHealthHistory(c.Request.Context(), userID, months)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respond(c, http.StatusOK, history)
 }
 
 func (h *AnalyticsHandler) GetRecommendations(c *gin.Context) {
@@ -112,8 +151,246 @@ func (h *AnalyticsHandler) GetRecommendations(c *gin.Context) {
 
 	recommendations, err := h.analyticsService.GetRecommendations(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respond(c, http.StatusOK, recommendations)
+}
+
+// RefreshRecommendations запускает генерацию AI-рекомендаций; результат - в GET /recommendations
+func (h *AnalyticsHandler) RefreshRecommendations(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	state, err := h.analyticsService.RefreshRecommendations(c.Request.Context(), userID)
+	if err != nil {
+		switch err {
+		case service.ErrAIRefreshTooSoon:
+			respondError(c, http.StatusTooManyRequests, err)
+		case service.ErrAIBusy:
+			respondError(c, http.StatusServiceUnavailable, err)
+		default:
+			aiError(c, err)
+		}
+		return
+	}
+	respond(c, http.StatusAccepted, state)
+}
+
+func (h *AnalyticsHandler) GetRecommendationsState(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	state, err := h.analyticsService.GetRecommendationsState(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respond(c, http.StatusOK, state)
+}
+
+func (h *AnalyticsHandler) GetForecast(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	months := 3
+	if m := c.Query("months"); m != "" {
+		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 {
+			months = parsed
+		}
+	}
+
+	forecast, err := h.analyticsService.GetCashFlowForecast(c.Request.Context(), userID, months)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respond(c, http.StatusOK, forecast)
+}
+
+// GetFire прогноз финансовой независимости; параметры сценария в query заменяют значения из данных
+func (h *AnalyticsHandler) GetFire(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	currency, ok := currencyParam(c)
+	if !ok {
+		return
+	}
+	scenario := &models.FireScenario{
+		Currency:       currency,
+		NetWorth:       decimalQuery(c, "net_worth"),
+		AnnualIncome:   decimalQuery(c, "annual_income"),
+		AnnualExpenses: decimalQuery(c, "annual_expenses"),
+		SavingsRate:    decimalQuery(c, "savings_rate"),
+		ReturnRate:     decimalQuery(c, "return_rate"),
+		WithdrawalRate: decimalQuery(c, "withdrawal_rate"),
+	}
+	if y := c.Query("years"); y != "" {
+		if parsed, err := strconv.Atoi(y); err == nil && parsed > 0 {
+			scenario.MaxYears = parsed
+		}
+	}
+
+	projection, err := h.analyticsService.GetFireProjection(c.Request.Context(), userID, scenario)
+	if err != nil {
+		if err == service.ErrInvalidFireScenario {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respond(c, http.StatusOK, projection)
+}
+
+func (h *AnalyticsHandler) GetAnomalies(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	days := 30
+	if d := c.Query("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+
+	anomalies, err := h.analyticsService.GetAnomalies(c.Request.Context(), userID, days)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respond(c, http.StatusOK, anomalies)
+}
+
+// GetPatterns траты по дням недели, числам месяца и часам; календарь - данные для тепловой карты
+func (h *AnalyticsHandler) GetPatterns(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	period := models.Period(c.DefaultQuery("period", "month"))
+
+	var startDate, endDate *time.Time
+	if s := c.Query("start_date"); s != "" {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			startDate = &t
+		}
+	}
+	if e := c.Query("end_date"); e != "" {
+		if t, err := time.Parse("2006-01-02", e); err == nil {
+			endDate = &t
+		}
+	}
+
+	currency, ok := currencyParam(c)
+	if !ok {
+		return
+	}
+
+	patterns, err := h.analyticsService.GetSpendingPatterns(c.Request.Context(), userID, period, startDate, endDate, currency)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respond(c, http.StatusOK, patterns)
+}
+
+// GetMap траты на карте: ?group_by=geohash|city, ?precision=1..9 (длина geohash, по умолчанию 5)
+func (h *AnalyticsHandler) GetMap(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	period := models.Period(c.DefaultQuery("period", "month"))
+
+	var startDate, endDate *time.Time
+	if s := c.Query("start_date"); s != "" {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			startDate = &t
+		}
+	}
+	if e := c.Query("end_date"); e != "" {
+		if t, err := time.Parse("2006-01-02", e); err == nil {
+			endDate = &t
+		}
+	}
+
+	currency, ok := currencyParam(c)
+	if !ok {
+		return
+	}
+
+	precision := 0
+	if p := c.Query("precision"); p != "" {
+		var err error
+		if precision, err = strconv.Atoi(p); err != nil {
+			respondError(c, http.StatusBadRequest, service.ErrInvalidMapPrecision)
+			return
+		}
+	}
+
+	spendingMap, err := h.analyticsService.GetSpendingMap(c.Request.Context(), userID, period, startDate, endDate, currency, models.SpendingMapGroup(c.Query("group_by")), precision)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidMapGroup, service.ErrInvalidMapPrecision:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	respond(c, http.StatusOK, spendingMap)
+}
+
+func (h *AnalyticsHandler) SuggestCategory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	description := strings.TrimSpace(c.Query("description"))
+	if description == "" {
+		respondError(c, http.StatusBadRequest, errDescriptionRequired)
+		return
+	}
+	categoryType := models.CategoryType(c.DefaultQuery("type", string(models.CategoryTypeExpense)))
+
+	category, err := h.analyticsService.SuggestCategory(c.Request.Context(), userID, description, categoryType)
+	if err != nil {
+		aiError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, recommendations)
+	respond(c, http.StatusOK, gin.H{"category": category})
+}
+
+func (h *AnalyticsHandler) GetAISummary(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	summary, err := h.analyticsService.GetAISummary(c.Request.Context(), userID)
+	if err != nil {
+		aiError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"summary": summary})
+}
+
+func aiError(c *gin.Context, err error) {
+	if quotaError(c, err) {
+		return
+	}
+	if err == service.ErrAIDisabled {
+		respondError(c, http.StatusForbidden, err)
+		return
+	}
+	if err == service.ErrAIUnavailable {
+		respondError(c, http.StatusServiceUnavailable, err)
+		return
+	}
+	respondError(c, http.StatusInternalServerError, err)
+}
+
+// currencyParam читает ?currency= (валюта отчета), при невалидном значении отвечает 400
+func currencyParam(c *gin.Context) (string, bool) {
+	currency := strings.ToUpper(strings.TrimSpace(c.Query("currency")))
+	if currency == "" {
+		return "", true
+	}
+	if len(currency) != 3 {
+		respondError(c, http.StatusBadRequest, errInvalidCurrencyCode)
+		return "", false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			respondError(c, http.StatusBadRequest, errInvalidCurrencyCode)
+			return "", false
+		}
+	}
+	return currency, true
 }
@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
 	"github.com/alligatorO15/fin-tracker/internal/config"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/service"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 const (
@@ -30,67 +34,119 @@ func NewAuthHandler(authService service.AuthService, cfg *config.Config) *AuthHa
 func (h *AuthHandler) Register(c *gin.Context) {
 	var input models.UserRegistration
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
+		return
 	}
-	response, err := h.authService.Register(c.Request.Context(), &input)
+	response, err := h.authService.Register(c.Request.Context(), &input, clientInfo(c))
 	if err != nil {
 		if err == service.ErrUserExists {
-			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			respondError(c, http.StatusConflict, err)
+			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
+		return
 	}
 
-	h.setRefreshTokenCookie(c, response.RefreshToken)
+	h.setRefreshTokenCookie(c, response.RefreshToken, response.RememberMe)
 	response.RefreshToken = "" //затираем из json ответа
 
-	c.JSON(http.StatusCreated, response)
+	respond(c, http.StatusCreated, response)
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
 	var input models.UserLogin
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
-	response, err := h.authService.Login(c.Request.Context(), &input)
+	response, err := h.authService.Login(c.Request.Context(), &input, clientInfo(c))
 	if err != nil {
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			respondError(c, http.StatusTooManyRequests, err)
+			return
+		}
 		if err == service.ErrInvalidCredentials {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			respondError(c, http.StatusUnauthorized, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.setRefreshTokenCookie(c, response.RefreshToken, response.RememberMe)
+	response.RefreshToken = ""
+
+	respond(c, http.StatusOK, response)
+}
+
+// Unlock снимает блокировку входа кодом из письма, которое уходит после серии неудачных попыток
+func (h *AuthHandler) Unlock(c *gin.Context) {
+	var input models.AccountUnlock
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.authService.UnlockLogin(c.Request.Context(), input.Token, clientInfo(c)); err != nil {
+		if err == service.ErrInvalidUnlockToken {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "login unlocked"})
+}
+
+// DemoLogin вход под демо-пользователем без пароля, только если включен DEMO_MODE
+func (h *AuthHandler) DemoLogin(c *gin.Context) {
+	response, err := h.authService.DemoLogin(c.Request.Context(), clientInfo(c))
+	if err != nil {
+		if err == service.ErrDemoDisabled {
+			respondError(c, http.StatusNotFound, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	h.setRefreshTokenCookie(c, response.RefreshToken)
+	h.setRefreshTokenCookie(c, response.RefreshToken, response.RememberMe)
 	response.RefreshToken = ""
 
-	c.JSON(http.StatusOK, response)
+	respond(c, http.StatusOK, response)
 }
 
 func (h *AuthHandler) Refresh(c *gin.Context) {
 	// берем refersh token из httpOnly cookie
 	refreshToken, err := c.Cookie(refreshTokenCookie)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token not found"})
+		respondError(c, http.StatusUnauthorized, errRefreshTokenNotFound)
 		return
 	}
 
-	response, err := h.authService.RefreshTokens(c.Request.Context(), refreshToken)
+	response, err := h.authService.RefreshTokens(c.Request.Context(), refreshToken, clientInfo(c))
 	if err != nil {
-		if err == service.ErrInvalidCredentials || err == service.ErrTokenExpired {
+		if err == service.ErrTokenReused {
 			h.clearRefreshTokenCookie(c)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+			respondError(c, http.StatusUnauthorized, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrInvalidCredentials || err == service.ErrInvalidToken || err == service.ErrTokenExpired {
+			h.clearRefreshTokenCookie(c)
+			respondError(c, http.StatusUnauthorized, errInvalidRefreshToken)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	h.setRefreshTokenCookie(c, response.RefreshToken)
+	h.setRefreshTokenCookie(c, response.RefreshToken, response.RememberMe)
 	response.RefreshToken = ""
 
-	c.JSON(http.StatusOK, response)
+	respond(c, http.StatusOK, response)
 }
 
 func (h *AuthHandler) Logout(c *gin.Context) {
@@ -101,24 +157,73 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 
 	h.clearRefreshTokenCookie(c)
 
-	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+	respond(c, http.StatusOK, gin.H{"message": "logged out successfully"})
 }
 
 func (h *AuthHandler) LogoutAll(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
 	if err := h.authService.LogoutAll(c.Request.Context(), userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "logged out from all devices"})
+}
+
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	sessions, err := h.authService.GetSessions(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "logged out from all devices"})
+	respond(c, http.StatusOK, sessions)
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidSessionID)
+		return
+	}
+
+	if err := h.authService.RevokeSession(c.Request.Context(), userID, sessionID); err != nil {
+		if err == service.ErrSessionNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+// данные клиента для списка сессий
+func clientInfo(c *gin.Context) models.ClientInfo {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	return models.ClientInfo{
+		UserAgent: userAgent,
+		IPAddress: c.ClientIP(),
+	}
 }
 
 // устанавливает refresh token в httpOnly cookie
-func (h *AuthHandler) setRefreshTokenCookie(c *gin.Context, token string) {
-	secure := h.config.Env == "production"
-	maxAge := int(h.config.RefreshTokenExpiration.Seconds())
+// без remember me кука сессионная - живет до закрытия браузера
+func (h *AuthHandler) setRefreshTokenCookie(c *gin.Context, token string, rememberMe bool) {
+	secure := h.config.Env == "production" || h.config.TLSEnabled()
+	maxAge := 0
+	if rememberMe {
+		maxAge = int(h.config.RefreshTokenExpiration.Seconds())
+	}
 
 	c.SetCookie(
 		refreshTokenCookie, // имя
@@ -133,7 +238,7 @@ func (h *AuthHandler) setRefreshTokenCookie(c *gin.Context, token string) {
 
 // удаляет refresh token cookie
 func (h *AuthHandler) clearRefreshTokenCookie(c *gin.Context) {
-	secure := h.config.Env == "production"
+	secure := h.config.Env == "production" || h.config.TLSEnabled()
 
 	c.SetCookie(
 		refreshTokenCookie,
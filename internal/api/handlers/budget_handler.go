@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
 	"github.com/alligatorO15/fin-tracker/internal/models"
@@ -23,17 +25,21 @@ func (h *BudgetHandler) Create(c *gin.Context) {
 
 	var input models.BudgetCreate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	budget, err := h.budgetService.Create(c.Request.Context(), userID, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrAccountNotFound || err == service.ErrPayeeNotFound || err == service.ErrInvalidBudgetAlertThresholds {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, budget)
+	respond(c, http.StatusCreated, budget)
 }
 
 func (h *BudgetHandler) List(c *gin.Context) {
@@ -42,27 +48,27 @@ func (h *BudgetHandler) List(c *gin.Context) {
 
 	budgets, err := h.budgetService.GetByUserID(c.Request.Context(), userID, activeOnly)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, budgets)
+	respond(c, http.StatusOK, budgets)
 }
 
 func (h *BudgetHandler) GetByID(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid budget ID"})
+		respondError(c, http.StatusBadRequest, errInvalidBudgetID)
 		return
 	}
 
 	budget, err := h.budgetService.GetByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "budget not found"})
+		respondError(c, http.StatusNotFound, service.ErrBudgetNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, budget)
+	respond(c, http.StatusOK, budget)
 }
 
 func (h *BudgetHandler) GetSummary(c *gin.Context) {
@@ -70,11 +76,11 @@ func (h *BudgetHandler) GetSummary(c *gin.Context) {
 
 	summary, err := h.budgetService.GetSummary(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, summary)
+	respond(c, http.StatusOK, summary)
 }
 
 func (h *BudgetHandler) GetAlerts(c *gin.Context) {
@@ -82,46 +88,112 @@ func (h *BudgetHandler) GetAlerts(c *gin.Context) {
 
 	alerts, err := h.budgetService.GetAlerts(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, alerts)
+	respond(c, http.StatusOK, alerts)
+}
+
+func (h *BudgetHandler) Suggest(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.BudgetSuggestRequest
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	suggestions, err := h.budgetService.Suggest(c.Request.Context(), userID, &input)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, suggestions)
+}
+
+func (h *BudgetHandler) GetHistory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidBudgetID)
+		return
+	}
+
+	history, err := h.budgetService.GetHistory(c.Request.Context(), userID, id, historyPeriods(c))
+	if err != nil {
+		if err == service.ErrBudgetNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, history)
+}
+
+func (h *BudgetHandler) GetHistoryReport(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	report, err := h.budgetService.GetHistoryReport(c.Request.Context(), userID, historyPeriods(c))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// historyPeriods ?periods= (0 - значение по умолчанию в сервисе)
+func historyPeriods(c *gin.Context) int {
+	periods, _ := strconv.Atoi(c.Query("periods"))
+	return periods
 }
 
 func (h *BudgetHandler) Update(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid budget ID"})
+		respondError(c, http.StatusBadRequest, errInvalidBudgetID)
 		return
 	}
 
 	var input models.BudgetUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	budget, err := h.budgetService.Update(c.Request.Context(), id, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrBudgetNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err == service.ErrAccountNotFound || err == service.ErrPayeeNotFound || err == service.ErrInvalidBudgetAlertThresholds {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, budget)
+	respond(c, http.StatusOK, budget)
 }
 
 func (h *BudgetHandler) Delete(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid budget ID"})
+		respondError(c, http.StatusBadRequest, errInvalidBudgetID)
 		return
 	}
 
 	if err := h.budgetService.Delete(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "budget deleted"})
+	respond(c, http.StatusOK, gin.H{"message": "budget deleted"})
 }
@@ -23,17 +23,22 @@ func (h *CategoryHandler) Create(c *gin.Context) {
 
 	var input models.CategoryCreate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	category, err := h.categoryService.Create(c.Request.Context(), userID, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch err {
+		case service.ErrInvalidCategoryIcon, service.ErrInvalidCategoryColor:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
-	c.JSON(http.StatusCreated, category)
+	respond(c, http.StatusCreated, category)
 }
 
 func (h *CategoryHandler) List(c *gin.Context) {
@@ -44,71 +49,108 @@ func (h *CategoryHandler) List(c *gin.Context) {
 	if categoryType != "" {
 		categories, err := h.categoryService.GetByType(c.Request.Context(), userID, models.CategoryType(categoryType))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondError(c, http.StatusInternalServerError, err)
 			return
 		}
-		c.JSON(http.StatusOK, categories)
+		respond(c, http.StatusOK, categories)
 		return
 	}
 
 	categories, err := h.categoryService.GetByUserID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, categories)
+	respond(c, http.StatusOK, categories)
 }
 
 func (h *CategoryHandler) GetByID(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		respondError(c, http.StatusBadRequest, errInvalidCategoryID)
 		return
 	}
 
 	category, err := h.categoryService.GetByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		respondError(c, http.StatusNotFound, service.ErrCategoryNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, category)
+	respond(c, http.StatusOK, category)
 }
 
 func (h *CategoryHandler) Update(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		respondError(c, http.StatusBadRequest, errInvalidCategoryID)
 		return
 	}
 
 	var input models.CategoryUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	category, err := h.categoryService.Update(c.Request.Context(), id, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch err {
+		case service.ErrInvalidCategoryIcon, service.ErrInvalidCategoryColor:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusOK, category)
+}
+
+// SetFixed PUT /categories/:id/fixed {"is_fixed": true}
+func (h *CategoryHandler) SetFixed(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidCategoryID)
+		return
+	}
+
+	var input models.CategoryFixed
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	category, err := h.categoryService.SetFixed(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		switch err {
+		case service.ErrCategoryNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case service.ErrCategoryNotExpense:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, category)
+	respond(c, http.StatusOK, category)
 }
 
 func (h *CategoryHandler) Delete(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		respondError(c, http.StatusBadRequest, errInvalidCategoryID)
 		return
 	}
 
 	if err := h.categoryService.Delete(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "category deleted"})
+	respond(c, http.StatusOK, gin.H{"message": "category deleted"})
 }
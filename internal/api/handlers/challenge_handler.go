@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ChallengeHandler struct {
+	challengeService service.ChallengeService
+}
+
+func NewChallengeHandler(challengeService service.ChallengeService) *ChallengeHandler {
+	return &ChallengeHandler{challengeService: challengeService}
+}
+
+func (h *ChallengeHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	challenges, err := h.challengeService.List(c.Request.Context(), userID)
+	if err != nil {
+		challengeError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, challenges)
+}
+
+func (h *ChallengeHandler) GetByID(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidChallengeID)
+		return
+	}
+
+	challenge, err := h.challengeService.Get(c.Request.Context(), userID, id)
+	if err != nil {
+		challengeError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, challenge)
+}
+
+func (h *ChallengeHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.ChallengeCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	challenge, err := h.challengeService.Create(c.Request.Context(), userID, &input)
+	if err != nil {
+		challengeError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, challenge)
+}
+
+func (h *ChallengeHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidChallengeID)
+		return
+	}
+
+	var input models.ChallengeUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	challenge, err := h.challengeService.Update(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		challengeError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, challenge)
+}
+
+func (h *ChallengeHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidChallengeID)
+		return
+	}
+
+	if err := h.challengeService.Delete(c.Request.Context(), userID, id); err != nil {
+		challengeError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "challenge deleted"})
+}
+
+func challengeError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrChallengeNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrInvalidChallengeAmount, service.ErrInvalidChallengeWeekdays, service.ErrInvalidChallengeDates,
+		service.ErrCategoryNotFound, service.ErrAccountNotFound, service.ErrPayeeNotFound:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CSVImportHandler struct {
+	csvImportService service.CSVImportService
+}
+
+func NewCSVImportHandler(csvImportService service.CSVImportService) *CSVImportHandler {
+	return &CSVImportHandler{csvImportService: csvImportService}
+}
+
+// Upload принимает выписку файлом (multipart, поле file) или телом text/csv
+func (h *CSVImportHandler) Upload(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var body io.Reader = c.Request.Body
+	filename := c.Query("filename")
+	if c.ContentType() == "multipart/form-data" {
+		file, err := c.FormFile("file")
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errFileRequired)
+			return
+		}
+		if file.Size > maxBatchCSVSize {
+			respondError(c, http.StatusRequestEntityTooLarge, errFileTooLarge)
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		defer f.Close()
+		body, filename = f, file.Filename
+	}
+
+	content, err := io.ReadAll(io.LimitReader(body, maxBatchCSVSize+1))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(content) > maxBatchCSVSize {
+		respondError(c, http.StatusRequestEntityTooLarge, errFileTooLarge)
+		return
+	}
+
+	detection, err := h.csvImportService.Upload(c.Request.Context(), userID, filename, content)
+	if err != nil {
+		csvImportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, detection)
+}
+
+func (h *CSVImportHandler) Detect(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidUploadID)
+		return
+	}
+	var profileID *uuid.UUID
+	if raw := c.Query("profile_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidProfileID)
+			return
+		}
+		profileID = &parsed
+	}
+
+	detection, err := h.csvImportService.Detect(c.Request.Context(), userID, id, profileID)
+	if err != nil {
+		csvImportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, detection)
+}
+
+func (h *CSVImportHandler) Import(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidUploadID)
+		return
+	}
+
+	var input models.CSVImportRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if c.Query("dry_run") == "true" {
+		input.DryRun = true
+	}
+
+	result, err := h.csvImportService.Import(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		csvImportError(c, err)
+		return
+	}
+
+	switch {
+	case result.Invalid > 0:
+		respond(c, http.StatusUnprocessableEntity, result)
+	case result.DryRun || result.Created == 0:
+		respond(c, http.StatusOK, result)
+	default:
+		respond(c, http.StatusCreated, result)
+	}
+}
+
+func (h *CSVImportHandler) ListProfiles(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	profiles, err := h.csvImportService.GetProfiles(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, profiles)
+}
+
+func (h *CSVImportHandler) CreateProfile(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.CSVImportProfileCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	profile, err := h.csvImportService.CreateProfile(c.Request.Context(), userID, &input)
+	if err != nil {
+		csvImportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, profile)
+}
+
+func (h *CSVImportHandler) UpdateProfile(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidProfileID)
+		return
+	}
+
+	var input models.CSVImportProfileUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	profile, err := h.csvImportService.UpdateProfile(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		csvImportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, profile)
+}
+
+func (h *CSVImportHandler) DeleteProfile(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidProfileID)
+		return
+	}
+
+	if err := h.csvImportService.DeleteProfile(c.Request.Context(), userID, id); err != nil {
+		csvImportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "CSV import profile deleted"})
+}
+
+func csvImportError(c *gin.Context, err error) {
+	if quotaError(c, err) {
+		return
+	}
+	if errors.Is(err, service.ErrInvalidCSV) {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	switch err {
+	case service.ErrCSVUploadNotFound, service.ErrCSVProfileNotFound, service.ErrAccountNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrInvalidCSVMapping, service.ErrCSVMappingRequired, service.ErrCSVAccountRequired,
+		service.ErrCategoryNotFound, service.ErrBatchTooLarge:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
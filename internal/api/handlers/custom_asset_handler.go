@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CustomAssetHandler struct {
+	assetService service.CustomAssetService
+}
+
+func NewCustomAssetHandler(assetService service.CustomAssetService) *CustomAssetHandler {
+	return &CustomAssetHandler{assetService: assetService}
+}
+
+func (h *CustomAssetHandler) GetClasses(c *gin.Context) {
+	respond(c, http.StatusOK, models.CustomAssetClasses)
+}
+
+func (h *CustomAssetHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.CustomAssetCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	asset, err := h.assetService.Create(c.Request.Context(), userID, &input)
+	if err != nil {
+		customAssetError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, asset)
+}
+
+func (h *CustomAssetHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	activeOnly := c.Query("active") == "true"
+
+	assets, err := h.assetService.GetByUserID(c.Request.Context(), userID, activeOnly)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, assets)
+}
+
+func (h *CustomAssetHandler) Get(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidAssetID)
+		return
+	}
+
+	asset, err := h.assetService.GetByID(c.Request.Context(), userID, id)
+	if err != nil {
+		customAssetError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, asset)
+}
+
+func (h *CustomAssetHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidAssetID)
+		return
+	}
+
+	var input models.CustomAssetUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	asset, err := h.assetService.Update(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		customAssetError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, asset)
+}
+
+func (h *CustomAssetHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidAssetID)
+		return
+	}
+
+	if err := h.assetService.Delete(c.Request.Context(), userID, id); err != nil {
+		customAssetError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "asset deleted"})
+}
+
+func (h *CustomAssetHandler) AddValuation(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidAssetID)
+		return
+	}
+
+	var input models.CustomAssetValuationCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	valuation, err := h.assetService.AddValuation(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		customAssetError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, valuation)
+}
+
+func (h *CustomAssetHandler) GetValuations(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidAssetID)
+		return
+	}
+
+	valuations, err := h.assetService.GetValuations(c.Request.Context(), userID, id)
+	if err != nil {
+		customAssetError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, valuations)
+}
+
+func (h *CustomAssetHandler) DeleteValuation(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidAssetID)
+		return
+	}
+	valuationID, err := uuid.Parse(c.Param("valuationId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidValuationID)
+		return
+	}
+
+	if err := h.assetService.DeleteValuation(c.Request.Context(), userID, id, valuationID); err != nil {
+		customAssetError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "valuation deleted"})
+}
+
+func customAssetError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrCustomAssetNotFound, service.ErrValuationNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrInvalidAssetClass, service.ErrInvalidAssetValue:
+		respondError(c, http.StatusBadRequest, err)
+	case service.ErrLastValuation:
+		respondError(c, http.StatusConflict, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CustomReportHandler struct {
+	reportService service.CustomReportService
+}
+
+func NewCustomReportHandler(reportService service.CustomReportService) *CustomReportHandler {
+	return &CustomReportHandler{reportService: reportService}
+}
+
+func (h *CustomReportHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.ReportDefinitionCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := h.reportService.Create(c.Request.Context(), userID, &input)
+	if err != nil {
+		customReportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, report)
+}
+
+func (h *CustomReportHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	reports, err := h.reportService.GetByUserID(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, reports)
+}
+
+func (h *CustomReportHandler) GetByID(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidReportID)
+		return
+	}
+
+	report, err := h.reportService.GetByID(c.Request.Context(), userID, id)
+	if err != nil {
+		customReportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+func (h *CustomReportHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidReportID)
+		return
+	}
+
+	var input models.ReportDefinitionUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := h.reportService.Update(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		customReportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+func (h *CustomReportHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidReportID)
+		return
+	}
+
+	if err := h.reportService.Delete(c.Request.Context(), userID, id); err != nil {
+		customReportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "report deleted"})
+}
+
+func (h *CustomReportHandler) Run(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidReportID)
+		return
+	}
+
+	result, err := h.reportService.Run(c.Request.Context(), userID, id)
+	if err != nil {
+		customReportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
+
+func (h *CustomReportHandler) Preview(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.ReportSpec
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.reportService.Preview(c.Request.Context(), userID, &input)
+	if err != nil {
+		customReportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
+
+func customReportError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrCustomReportNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrInvalidReportSpec, service.ErrInvalidReportSort, service.ErrInvalidReportPeriod:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
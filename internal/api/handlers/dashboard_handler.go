@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type DashboardHandler struct {
+	dashboardService service.DashboardService
+}
+
+func NewDashboardHandler(dashboardService service.DashboardService) *DashboardHandler {
+	return &DashboardHandler{dashboardService: dashboardService}
+}
+
+// Get данные главного экрана; ?sections=accounts,goals - только перечисленные разделы
+func (h *DashboardHandler) Get(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var sections []models.DashboardSection
+	for _, name := range strings.Split(c.Query("sections"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			sections = append(sections, models.DashboardSection(name))
+		}
+	}
+
+	dashboard, err := h.dashboardService.Get(c.Request.Context(), userID, sections)
+	if err != nil {
+		if err == service.ErrInvalidDashboardSection {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, dashboard)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DepositHandler struct {
+	depositService service.DepositService
+}
+
+func NewDepositHandler(depositService service.DepositService) *DepositHandler {
+	return &DepositHandler{depositService: depositService}
+}
+
+func (h *DepositHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.DepositCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	deposit, err := h.depositService.Create(c.Request.Context(), userID, &input)
+	if err != nil {
+		depositError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, deposit)
+}
+
+func (h *DepositHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	activeOnly := c.Query("active") == "true"
+
+	deposits, err := h.depositService.GetByUserID(c.Request.Context(), userID, activeOnly)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, deposits)
+}
+
+func (h *DepositHandler) Get(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidDepositID)
+		return
+	}
+
+	deposit, err := h.depositService.GetByID(c.Request.Context(), userID, id)
+	if err != nil {
+		depositError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, deposit)
+}
+
+func (h *DepositHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidDepositID)
+		return
+	}
+
+	var input models.DepositUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	deposit, err := h.depositService.Update(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		depositError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, deposit)
+}
+
+func (h *DepositHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidDepositID)
+		return
+	}
+
+	if err := h.depositService.Delete(c.Request.Context(), userID, id); err != nil {
+		depositError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "deposit deleted"})
+}
+
+func (h *DepositHandler) Compare(c *gin.Context) {
+	var input models.DepositCompareInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := h.depositService.Compare(c.Request.Context(), &input)
+	if err != nil {
+		depositError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, results)
+}
+
+func depositError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrDepositNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrInvalidDepositAmount, service.ErrInvalidDepositRate, service.ErrInvalidDepositTerm,
+		service.ErrInvalidCapitalization, service.ErrPayoutAccountNotFound:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DrawdownHandler struct {
+	drawdownService service.DrawdownService
+}
+
+func NewDrawdownHandler(drawdownService service.DrawdownService) *DrawdownHandler {
+	return &DrawdownHandler{drawdownService: drawdownService}
+}
+
+// Get пороги уведомлений и текущая просадка портфеля
+func (h *DrawdownHandler) Get(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	status, err := h.drawdownService.GetStatus(c.Request.Context(), userID, id)
+	if err != nil {
+		drawdownError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, status)
+}
+
+func (h *DrawdownHandler) Set(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	var input models.DrawdownAlertInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	status, err := h.drawdownService.SetSettings(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		drawdownError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, status)
+}
+
+func drawdownError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrPortfolioNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrInvalidDrawdownThreshold:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type DuplicateHandler struct {
+	duplicateService service.DuplicateService
+}
+
+func NewDuplicateHandler(duplicateService service.DuplicateService) *DuplicateHandler {
+	return &DuplicateHandler{duplicateService: duplicateService}
+}
+
+// List группы транзакций, похожих на одну операцию из разных источников
+func (h *DuplicateHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var days *int
+	if d := c.Query("days"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidDays)
+			return
+		}
+		days = &parsed
+	}
+
+	var since *time.Time
+	if s := c.Query("since"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidSinceDate)
+			return
+		}
+		since = &parsed
+	}
+
+	groups, err := h.duplicateService.Find(c.Request.Context(), userID, days, since)
+	if err != nil {
+		if err == service.ErrDuplicateWindowInvalid {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, groups)
+}
+
+// Merge оставляет одну транзакцию из группы, остальные удаляет
+func (h *DuplicateHandler) Merge(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.TransactionMerge
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := h.duplicateService.Merge(c.Request.Context(), userID, &input)
+	if err != nil {
+		switch err {
+		case service.ErrTransactionNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case service.ErrInvalidMerge:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusOK, tx)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type EnvelopeHandler struct {
+	envelopeService service.EnvelopeService
+}
+
+func NewEnvelopeHandler(envelopeService service.EnvelopeService) *EnvelopeHandler {
+	return &EnvelopeHandler{envelopeService: envelopeService}
+}
+
+func (h *EnvelopeHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.EnvelopeCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	envelope, err := h.envelopeService.Create(c.Request.Context(), userID, &input)
+	if err != nil {
+		if err == service.ErrEnvelopeCategory {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err == service.ErrEnvelopeExists {
+			respondError(c, http.StatusConflict, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, envelope)
+}
+
+// GetMonth конверты за месяц (?month=2024-05, по умолчанию текущий)
+func (h *EnvelopeHandler) GetMonth(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	month := time.Now()
+	if m := c.Query("month"); m != "" {
+		t, err := time.Parse("2006-01", m)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidMonth)
+			return
+		}
+		month = t
+	}
+
+	view, err := h.envelopeService.GetMonth(c.Request.Context(), userID, month)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, view)
+}
+
+func (h *EnvelopeHandler) Archive(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidEnvelopeID)
+		return
+	}
+
+	if err := h.envelopeService.Archive(c.Request.Context(), userID, id); err != nil {
+		if err == service.ErrEnvelopeNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "envelope archived"})
+}
+
+func (h *EnvelopeHandler) Allocate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidEnvelopeID)
+		return
+	}
+
+	var input models.EnvelopeAllocate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	view, err := h.envelopeService.Allocate(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		if err == service.ErrEnvelopeNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if isEnvelopeInputError(err) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, view)
+}
+
+func (h *EnvelopeHandler) Move(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.EnvelopeMove
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	view, err := h.envelopeService.Move(c.Request.Context(), userID, &input)
+	if err != nil {
+		if err == service.ErrEnvelopeNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if isEnvelopeInputError(err) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, view)
+}
+
+func (h *EnvelopeHandler) GetHistory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidEnvelopeID)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	entries, err := h.envelopeService.GetHistory(c.Request.Context(), userID, id, limit)
+	if err != nil {
+		if err == service.ErrEnvelopeNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, entries)
+}
+
+func isEnvelopeInputError(err error) bool {
+	return err == service.ErrInvalidEnvelopeAmount ||
+		err == service.ErrSameEnvelope ||
+		err == service.ErrInsufficientUnallocated ||
+		err == service.ErrInsufficientEnvelopeFunds
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/response"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+)
+
+// ошибки разбора запроса в хэндлерах
+var (
+	errAvatarTooLarge              = errors.New("avatar is too large")
+	errDescriptionRequired         = errors.New("description is required")
+	errFileRequired                = errors.New("file is required")
+	errFileTooLarge                = errors.New("file is too large")
+	errImageTooLarge               = errors.New("image is too large")
+	errInvalidAccountFilter        = errors.New("invalid account_id")
+	errInvalidAccountID            = errors.New("invalid account ID")
+	errInvalidAssetID              = errors.New("invalid asset ID")
+	errInvalidBirthDate            = errors.New("invalid birth_date, expected YYYY-MM-DD")
+	errInvalidBudgetID             = errors.New("invalid budget ID")
+	errInvalidCashFlowID           = errors.New("invalid cash flow ID")
+	errInvalidCategoryFilter       = errors.New("invalid category_id")
+	errInvalidCategoryID           = errors.New("invalid category ID")
+	errInvalidChallengeID          = errors.New("invalid challenge ID")
+	errInvalidConnectionID         = errors.New("invalid connection ID")
+	errInvalidContributions        = errors.New("invalid contributions")
+	errInvalidCurrencyCode         = errors.New("invalid currency code")
+	errInvalidDays                 = errors.New("invalid days")
+	errInvalidDepositID            = errors.New("invalid deposit ID")
+	errInvalidDraftID              = errors.New("invalid draft ID")
+	errInvalidEndDate              = errors.New("invalid end_date, expected YYYY-MM-DD")
+	errInvalidEnvelopeID           = errors.New("invalid envelope ID")
+	errInvalidFrom                 = errors.New("invalid from, expected YYYY-MM-DD")
+	errInvalidGoalID               = errors.New("invalid goal ID")
+	errInvalidJobID                = errors.New("invalid job ID")
+	errInvalidMonth                = errors.New("invalid month, expected YYYY-MM")
+	errInvalidNotificationID       = errors.New("invalid notification ID")
+	errInvalidPayeeID              = errors.New("invalid payee ID")
+	errInvalidPlannedTransactionID = errors.New("invalid planned transaction ID")
+	errInvalidPortfolioID          = errors.New("invalid portfolio ID")
+	errInvalidProductID            = errors.New("invalid product ID")
+	errInvalidProfileID            = errors.New("invalid profile ID")
+	errInvalidRedenominationID     = errors.New("invalid redenomination ID")
+	errInvalidRefreshToken         = errors.New("invalid or expired refresh token")
+	errInvalidReportID             = errors.New("invalid report ID")
+	errInvalidRuleID               = errors.New("invalid rule ID")
+	errInvalidSecurityID           = errors.New("invalid security ID")
+	errInvalidSessionID            = errors.New("invalid session ID")
+	errInvalidSimulations          = errors.New("invalid simulations")
+	errInvalidSinceDate            = errors.New("invalid since date, expected YYYY-MM-DD")
+	errInvalidStartDate            = errors.New("invalid start_date, expected YYYY-MM-DD")
+	errInvalidStatus               = errors.New("invalid status")
+	errInvalidSubscriptionID       = errors.New("invalid subscription ID")
+	errInvalidTo                   = errors.New("invalid to, expected YYYY-MM-DD")
+	errInvalidTransactionID        = errors.New("invalid transaction ID")
+	errInvalidUploadID             = errors.New("invalid upload ID")
+	errInvalidValuationID          = errors.New("invalid valuation ID")
+	errInvalidWebhookID            = errors.New("invalid webhook ID")
+	errInvalidYear                 = errors.New("invalid year")
+	errPayloadTooLarge             = errors.New("payload is too large")
+	errRefreshTokenNotFound        = errors.New("refresh token not found")
+	errSearchQueryRequired         = errors.New("search query required")
+)
+
+// errorCodes стабильные коды ошибок сервисов для клиентов. код не меняется вместе с текстом
+// ошибки; новые ошибки добавляются сюда, переименовывать существующие коды нельзя
+var errorCodes = map[error]string{
+	service.ErrAIBusy:                       "ai_busy",
+	service.ErrAIDisabled:                   "ai_disabled",
+	service.ErrAIQuotaExceeded:              "ai_quota_exceeded",
+	service.ErrAIRefreshTooSoon:             "ai_refresh_too_soon",
+	service.ErrAIUnavailable:                "ai_unavailable",
+	service.ErrAccountLocked:                "account_locked",
+	service.ErrAccountNotFound:              "account_not_found",
+	service.ErrAccountOrderUnknown:          "unknown_account",
+	service.ErrAccountRuleNotFound:          "account_rule_not_found",
+	service.ErrAttachmentQuotaExceeded:      "attachment_quota_exceeded",
+	service.ErrAttachmentsDisabled:          "attachments_disabled",
+	service.ErrAvatarNotFound:               "avatar_not_found",
+	service.ErrBackfillManual:               "backfill_manual_security",
+	service.ErrBackfillUnavailable:          "backfill_unavailable",
+	service.ErrBatchQuantityZero:            "batch_quantity_required",
+	service.ErrBatchTooLarge:                "batch_too_large",
+	service.ErrBenchmarkUnavailable:         "benchmark_unavailable",
+	service.ErrBrokerRefExists:              "broker_ref_exists",
+	service.ErrBudgetNotFound:               "budget_not_found",
+	service.ErrCSVAccountRequired:           "csv_account_required",
+	service.ErrCSVMappingRequired:           "csv_mapping_required",
+	service.ErrCSVProfileNotFound:           "csv_profile_not_found",
+	service.ErrCSVUploadNotFound:            "csv_upload_not_found",
+	service.ErrCategoryNotExpense:           "category_not_expense",
+	service.ErrCategoryNotFound:             "category_not_found",
+	service.ErrChallengeNotFound:            "challenge_not_found",
+	service.ErrCurrencyMismatch:             "currency_mismatch",
+	service.ErrCustomAssetNotFound:          "custom_asset_not_found",
+	service.ErrCustomReportNotFound:         "report_not_found",
+	service.ErrDemoDisabled:                 "demo_disabled",
+	service.ErrDepositNotFound:              "deposit_not_found",
+	service.ErrDraftAccountRequired:         "draft_account_required",
+	service.ErrDraftNotFound:                "draft_not_found",
+	service.ErrDraftNotPending:              "draft_not_pending",
+	service.ErrDuplicateTarget:              "duplicate_target",
+	service.ErrDuplicateWindowInvalid:       "duplicate_window_invalid",
+	service.ErrEnvelopeCategory:             "envelope_category",
+	service.ErrEnvelopeExists:               "envelope_exists",
+	service.ErrEnvelopeNotFound:             "envelope_not_found",
+	service.ErrExchangeRateRequired:         "exchange_rate_required",
+	service.ErrExchangeRateUnavailable:      "exchange_rate_unavailable",
+	service.ErrGoalNoHistory:                "goal_no_history",
+	service.ErrGoalNoTargetDate:             "goal_no_target_date",
+	service.ErrGoalNotFound:                 "goal_not_found",
+	service.ErrGoalNotPortfolio:             "goal_not_portfolio",
+	service.ErrGoalPortfolioNotFound:        "goal_portfolio_not_found",
+	service.ErrGoalTrackedByPortfolio:       "goal_tracked_by_portfolio",
+	service.ErrHoldingNotFound:              "holding_not_found",
+	service.ErrIISCashFlowNotFound:          "iis_cash_flow_not_found",
+	service.ErrIISCurrency:                  "iis_currency",
+	service.ErrIISEarlyWithdrawal:           "iis_early_withdrawal",
+	service.ErrIISLimitExceeded:             "iis_limit_exceeded",
+	service.ErrIISWithdrawalTooHigh:         "iis_withdrawal_too_high",
+	service.ErrInsufficientEnvelopeFunds:    "insufficient_envelope_funds",
+	service.ErrInsufficientShares:           "insufficient_shares",
+	service.ErrInsufficientUnallocated:      "insufficient_unallocated",
+	service.ErrInvalidAccountGroup:          "invalid_account_group",
+	service.ErrInvalidAccountGrouping:       "invalid_account_grouping",
+	service.ErrInvalidAssetClass:            "invalid_asset_class",
+	service.ErrInvalidAssetValue:            "invalid_asset_value",
+	service.ErrInvalidBackfillYears:         "invalid_backfill_years",
+	service.ErrInvalidBatchCSV:              "invalid_batch_csv",
+	service.ErrInvalidBatchRow:              "invalid_batch_row",
+	service.ErrInvalidBirthDate:             "invalid_birth_date",
+	service.ErrInvalidBudgetAlertThresholds: "invalid_budget_alert_thresholds",
+	service.ErrInvalidCSV:                   "invalid_csv",
+	service.ErrInvalidCSVMapping:            "invalid_csv_mapping",
+	service.ErrInvalidCapitalization:        "invalid_capitalization",
+	service.ErrInvalidCashFlowDimension:     "invalid_cash_flow_dimension",
+	service.ErrInvalidCategoryColor:         "invalid_category_color",
+	service.ErrInvalidCategoryIcon:          "invalid_category_icon",
+	service.ErrInvalidChallengeAmount:       "invalid_challenge_amount",
+	service.ErrInvalidChallengeDates:        "invalid_challenge_dates",
+	service.ErrInvalidChallengeWeekdays:     "invalid_challenge_weekdays",
+	service.ErrInvalidCoordinates:           "invalid_coordinates",
+	service.ErrInvalidCursor:                "invalid_cursor",
+	service.ErrInvalidCredentials:           "invalid_credentials",
+	service.ErrInvalidDashboardSection:      "invalid_dashboard_section",
+	service.ErrInvalidDateRange:             "invalid_date_range",
+	service.ErrInvalidDeletionCode:          "invalid_deletion_code",
+	service.ErrInvalidDepositAmount:         "invalid_deposit_amount",
+	service.ErrInvalidDepositRate:           "invalid_deposit_rate",
+	service.ErrInvalidDepositTerm:           "invalid_deposit_term",
+	service.ErrInvalidDrawdownThreshold:     "invalid_drawdown_threshold",
+	service.ErrInvalidEnvelopeAmount:        "invalid_envelope_amount",
+	service.ErrInvalidExchangeRate:          "invalid_exchange_rate",
+	service.ErrInvalidFireScenario:          "invalid_fire_scenario",
+	service.ErrInvalidHoldingTags:           "invalid_holding_tags",
+	service.ErrInvalidIISAmount:             "invalid_iis_amount",
+	service.ErrInvalidIISDate:               "invalid_iis_date",
+	service.ErrInvalidImage:                 "invalid_image",
+	service.ErrInvalidManualPrice:           "invalid_manual_price",
+	service.ErrInvalidMapGroup:              "invalid_map_group",
+	service.ErrInvalidMapPrecision:          "invalid_map_precision",
+	service.ErrInvalidMerge:                 "invalid_merge",
+	service.ErrInvalidPassword:              "invalid_password",
+	service.ErrInvalidPayee:                 "invalid_payee",
+	service.ErrInvalidPlannedAmount:         "invalid_planned_amount",
+	service.ErrInvalidPrincipal:             "invalid_principal",
+	service.ErrInvalidProduct:               "invalid_product",
+	service.ErrInvalidQuantity:              "invalid_quantity",
+	service.ErrInvalidReceiptQR:             "invalid_receipt_qr",
+	service.ErrInvalidRedenomination:        "invalid_redenomination",
+	service.ErrInvalidReportFrequency:       "invalid_report_frequency",
+	service.ErrInvalidReportPeriod:          "invalid_report_period",
+	service.ErrInvalidReportSchedule:        "invalid_report_schedule",
+	service.ErrInvalidReportSort:            "invalid_report_sort",
+	service.ErrInvalidReportSpec:            "invalid_report_spec",
+	service.ErrInvalidRewardInput:           "invalid_reward_input",
+	service.ErrInvalidRuleAmount:            "invalid_rule_amount",
+	service.ErrInvalidRuleMax:               "invalid_rule_max",
+	service.ErrInvalidRuleRate:              "invalid_rule_rate",
+	service.ErrInvalidSimulations:           "invalid_simulations",
+	service.ErrInvalidSortField:             "invalid_sort_field",
+	service.ErrInvalidTag:                   "invalid_tag",
+	service.ErrInvalidTagGroup:              "invalid_tag_group",
+	service.ErrInvalidTargetPrice:           "invalid_target_price",
+	service.ErrInvalidTargetWeight:          "invalid_target_weight",
+	service.ErrInvalidThreshold:             "invalid_threshold",
+	service.ErrInvalidToken:                 "invalid_token",
+	service.ErrInvalidTransactionType:       "invalid_transaction_type",
+	service.ErrInvalidTransferMatch:         "invalid_transfer_match",
+	service.ErrInvalidUnlockToken:           "invalid_unlock_token",
+	service.ErrLastValuation:                "last_valuation",
+	service.ErrLotSizeMismatch:              "lot_size_mismatch",
+	service.ErrMailConnectionNotFound:       "mail_connection_not_found",
+	service.ErrMailHostNotAllowed:           "mail_host_not_allowed",
+	service.ErrMailImportDisabled:           "mail_import_disabled",
+	service.ErrMailInvalidCredentials:       "mail_invalid_credentials",
+	service.ErrMailLoginFailed:              "mail_login_failed",
+	service.ErrManualSecurityExists:         "manual_security_exists",
+	service.ErrNotBond:                      "not_bond",
+	service.ErrNotDerivative:                "not_derivative",
+	service.ErrNotIIS:                       "not_iis",
+	service.ErrNotManualSecurity:            "not_manual_security",
+	service.ErrNothingToRestore:             "nothing_to_restore",
+	service.ErrNotificationNotFound:         "notification_not_found",
+	service.ErrPayeeNameTaken:               "payee_name_taken",
+	service.ErrPayeeNotFound:                "payee_not_found",
+	service.ErrPayoutAccountNotFound:        "payout_account_not_found",
+	service.ErrPlannedNotFound:              "planned_not_found",
+	service.ErrPlannedNotPending:            "planned_not_pending",
+	service.ErrPortfolioNotFound:            "portfolio_not_found",
+	service.ErrPortfolioQuotaExceeded:       "portfolio_quota_exceeded",
+	service.ErrProductNameTaken:             "product_name_taken",
+	service.ErrProductNotFound:              "product_not_found",
+	service.ErrQuantityPrecision:            "quantity_precision",
+	service.ErrReceiptAlreadyImported:       "receipt_already_imported",
+	service.ErrReceiptDisabled:              "receipt_disabled",
+	service.ErrReceiptNotFound:              "receipt_not_found",
+	service.ErrReceiptRateLimited:           "receipt_rate_limited",
+	service.ErrReceiptRequired:              "receipt_required",
+	service.ErrReceiptUnavailable:           "receipt_unavailable",
+	service.ErrRedenominationExists:         "redenomination_exists",
+	service.ErrRedenominationNotFound:       "redenomination_not_found",
+	service.ErrRefreshJobNotFound:           "refresh_job_not_found",
+	service.ErrReportEmailDisabled:          "report_email_disabled",
+	service.ErrReportEmpty:                  "report_empty",
+	service.ErrReportSubscriptionExists:     "report_subscription_exists",
+	service.ErrReportSubscriptionNotFound:   "report_subscription_not_found",
+	service.ErrSameEnvelope:                 "same_envelope",
+	service.ErrSectorMappingNotFound:        "sector_mapping_not_found",
+	service.ErrSecurityMergeManual:          "security_merge_manual",
+	service.ErrSecurityMergeMismatch:        "security_merge_mismatch",
+	service.ErrSecurityMergeSame:            "security_merge_same",
+	service.ErrSecurityNotFound:             "security_not_found",
+	service.ErrSecurityRequired:             "security_required",
+	service.ErrSecurityTypeOverrideNotFound: "security_type_override_not_found",
+	service.ErrSessionNotFound:              "session_not_found",
+	service.ErrTagNameTaken:                 "tag_name_taken",
+	service.ErrTagNotFound:                  "tag_not_found",
+	service.ErrTelegramDisabled:             "telegram_disabled",
+	service.ErrTelegramInvalidSecret:        "telegram_invalid_secret",
+	service.ErrTelegramNotLinked:            "telegram_not_linked",
+	service.ErrTickerNotInPortfolio:         "ticker_not_in_portfolio",
+	service.ErrTokenExpired:                 "token_expired",
+	service.ErrTokenReused:                  "token_reused",
+	service.ErrTokenRevoked:                 "token_revoked",
+	service.ErrTooManyLoginAttempts:         "too_many_login_attempts",
+	service.ErrTransactionNotFound:          "transaction_not_found",
+	service.ErrTransactionQuotaExceeded:     "transaction_quota_exceeded",
+	service.ErrTransferMissingAccount:       "transfer_missing_account",
+	service.ErrTransferWindowInvalid:        "transfer_window_invalid",
+	service.ErrUnknownBenchmark:             "unknown_benchmark",
+	service.ErrUserExists:                   "user_exists",
+	service.ErrUserNotFound:                 "user_not_found",
+	service.ErrValuationNotFound:            "valuation_not_found",
+	service.ErrWeakPassword:                 "weak_password",
+	service.ErrWebhookCurrencyMismatch:      "webhook_currency_mismatch",
+	service.ErrWebhookInactive:              "webhook_inactive",
+	service.ErrWebhookInvalidAmount:         "webhook_invalid_amount",
+	service.ErrWebhookInvalidDate:           "webhook_invalid_date",
+	service.ErrWebhookInvalidPayload:        "webhook_invalid_payload",
+	service.ErrWebhookInvalidType:           "webhook_invalid_type",
+	service.ErrWebhookNotFound:              "webhook_not_found",
+
+	// ошибки разбора запроса в хэндлерах
+	errAvatarTooLarge:              "avatar_is_too_large",
+	errDescriptionRequired:         "description_is_required",
+	errFileRequired:                "file_is_required",
+	errFileTooLarge:                "file_is_too_large",
+	errImageTooLarge:               "image_is_too_large",
+	errInvalidAccountFilter:        "invalid_account_id",
+	errInvalidAccountID:            "invalid_account_id",
+	errInvalidAssetID:              "invalid_asset_id",
+	errInvalidBirthDate:            "invalid_birth_date",
+	errInvalidBudgetID:             "invalid_budget_id",
+	errInvalidCashFlowID:           "invalid_cash_flow_id",
+	errInvalidCategoryFilter:       "invalid_category_id",
+	errInvalidCategoryID:           "invalid_category_id",
+	errInvalidChallengeID:          "invalid_challenge_id",
+	errInvalidConnectionID:         "invalid_connection_id",
+	errInvalidContributions:        "invalid_contributions",
+	errInvalidCurrencyCode:         "invalid_currency_code",
+	errInvalidDays:                 "invalid_days",
+	errInvalidDepositID:            "invalid_deposit_id",
+	errInvalidDraftID:              "invalid_draft_id",
+	errInvalidEndDate:              "invalid_end_date",
+	errInvalidEnvelopeID:           "invalid_envelope_id",
+	errInvalidFrom:                 "invalid_from",
+	errInvalidGoalID:               "invalid_goal_id",
+	errInvalidJobID:                "invalid_job_id",
+	errInvalidMonth:                "invalid_month",
+	errInvalidNotificationID:       "invalid_notification_id",
+	errInvalidPayeeID:              "invalid_payee_id",
+	errInvalidPlannedTransactionID: "invalid_planned_transaction_id",
+	errInvalidPortfolioID:          "invalid_portfolio_id",
+	errInvalidProductID:            "invalid_product_id",
+	errInvalidProfileID:            "invalid_profile_id",
+	errInvalidRedenominationID:     "invalid_redenomination_id",
+	errInvalidRefreshToken:         "invalid_or_expired_refresh_token",
+	errInvalidReportID:             "invalid_report_id",
+	errInvalidRuleID:               "invalid_rule_id",
+	errInvalidSecurityID:           "invalid_security_id",
+	errInvalidSessionID:            "invalid_session_id",
+	errInvalidSimulations:          "invalid_simulations",
+	errInvalidSinceDate:            "invalid_since_date",
+	errInvalidStartDate:            "invalid_start_date",
+	errInvalidStatus:               "invalid_status",
+	errInvalidSubscriptionID:       "invalid_subscription_id",
+	errInvalidTo:                   "invalid_to",
+	errInvalidTransactionID:        "invalid_transaction_id",
+	errInvalidUploadID:             "invalid_upload_id",
+	errInvalidValuationID:          "invalid_valuation_id",
+	errInvalidWebhookID:            "invalid_webhook_id",
+	errInvalidYear:                 "invalid_year",
+	errPayloadTooLarge:             "payload_is_too_large",
+	errRefreshTokenNotFound:        "refresh_token_not_found",
+	errSearchQueryRequired:         "search_query_required",
+}
+
+// errorCode код ошибки сервиса; для неизвестных ошибок - код по статусу ответа
+func errorCode(err error, status int) string {
+	if code, ok := errorCodes[err]; ok {
+		return code
+	}
+	for known, code := range errorCodes {
+		if errors.Is(err, known) {
+			return code
+		}
+	}
+	return response.StatusCode(status)
+}
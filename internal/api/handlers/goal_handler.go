@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 type GoalHandler struct {
@@ -23,17 +26,21 @@ func (h *GoalHandler) Create(c *gin.Context) {
 
 	var input models.GoalCreate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	goal, err := h.goalService.Create(c.Request.Context(), userID, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrGoalPortfolioNotFound {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, goal)
+	respond(c, http.StatusCreated, goal)
 }
 
 func (h *GoalHandler) List(c *gin.Context) {
@@ -47,100 +54,153 @@ func (h *GoalHandler) List(c *gin.Context) {
 
 	goals, err := h.goalService.GetByUserID(c.Request.Context(), userID, status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, goals)
+	respond(c, http.StatusOK, goals)
 }
 
 func (h *GoalHandler) GetByID(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal ID"})
+		respondError(c, http.StatusBadRequest, errInvalidGoalID)
 		return
 	}
 
 	goal, err := h.goalService.GetByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		respondError(c, http.StatusNotFound, service.ErrGoalNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, goal)
+	respond(c, http.StatusOK, goal)
 }
 
 func (h *GoalHandler) Update(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal ID"})
+		respondError(c, http.StatusBadRequest, errInvalidGoalID)
 		return
 	}
 
 	var input models.GoalUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	goal, err := h.goalService.Update(c.Request.Context(), id, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrGoalPortfolioNotFound {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, goal)
+	respond(c, http.StatusOK, goal)
 }
 
 func (h *GoalHandler) Delete(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal ID"})
+		respondError(c, http.StatusBadRequest, errInvalidGoalID)
 		return
 	}
 
 	if err := h.goalService.Delete(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "goal deleted"})
+	respond(c, http.StatusOK, gin.H{"message": "goal deleted"})
 }
 
 func (h *GoalHandler) AddContribution(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal ID"})
+		respondError(c, http.StatusBadRequest, errInvalidGoalID)
 		return
 	}
 
 	var input models.GoalContributionCreate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	goal, err := h.goalService.AddContribution(c.Request.Context(), id, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrGoalTrackedByPortfolio {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, goal)
+	respond(c, http.StatusOK, goal)
 }
 
 func (h *GoalHandler) GetContributions(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal ID"})
+		respondError(c, http.StatusBadRequest, errInvalidGoalID)
 		return
 	}
 
 	contributions, err := h.goalService.GetContributions(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, contributions)
+	respond(c, http.StatusOK, contributions)
+}
+
+// Project вероятность достичь цели по портфелю при разных взносах (?contributions=5000,10000&simulations=2000)
+func (h *GoalHandler) Project(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidGoalID)
+		return
+	}
+
+	var input models.GoalProjectionRequest
+	if raw := c.Query("contributions"); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			amount, err := decimal.NewFromString(strings.TrimSpace(part))
+			if err != nil {
+				respondError(c, http.StatusBadRequest, errInvalidContributions)
+				return
+			}
+			input.Contributions = append(input.Contributions, amount)
+		}
+	}
+	if raw := c.Query("simulations"); raw != "" {
+		input.Simulations, err = strconv.Atoi(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidSimulations)
+			return
+		}
+	}
+
+	projection, err := h.goalService.Project(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		switch err {
+		case service.ErrGoalNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case service.ErrGoalNotPortfolio, service.ErrGoalNoTargetDate, service.ErrGoalNoHistory, service.ErrInvalidSimulations:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusOK, projection)
 }
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type HoldingMetadataHandler struct {
+	metadataService service.HoldingMetadataService
+}
+
+func NewHoldingMetadataHandler(metadataService service.HoldingMetadataService) *HoldingMetadataHandler {
+	return &HoldingMetadataHandler{metadataService: metadataService}
+}
+
+func (h *HoldingMetadataHandler) Set(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	portfolioID, securityID, ok := holdingParams(c)
+	if !ok {
+		return
+	}
+
+	var input models.HoldingMetadataInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	metadata, err := h.metadataService.Set(c.Request.Context(), userID, portfolioID, securityID, &input)
+	if err != nil {
+		holdingMetadataError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, metadata)
+}
+
+func (h *HoldingMetadataHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	portfolioID, securityID, ok := holdingParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.metadataService.Delete(c.Request.Context(), userID, portfolioID, securityID); err != nil {
+		holdingMetadataError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "holding metadata deleted"})
+}
+
+// holdingParams id портфеля и бумаги из пути; при ошибке уже ответили 400
+func holdingParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return uuid.Nil, uuid.Nil, false
+	}
+	securityID, err := uuid.Parse(c.Param("securityId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidSecurityID)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return portfolioID, securityID, true
+}
+
+func holdingMetadataError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrPortfolioNotFound, service.ErrHoldingNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrInvalidHoldingTags, service.ErrInvalidTargetPrice:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
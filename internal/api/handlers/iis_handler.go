@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type IISHandler struct {
+	iisService service.IISService
+}
+
+func NewIISHandler(iisService service.IISService) *IISHandler {
+	return &IISHandler{iisService: iisService}
+}
+
+func (h *IISHandler) GetSummary(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	year := 0
+	if y := c.Query("year"); y != "" {
+		if year, err = strconv.Atoi(y); err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidYear)
+			return
+		}
+	}
+
+	summary, err := h.iisService.GetSummary(c.Request.Context(), userID, portfolioID, year)
+	if err != nil {
+		iisError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, summary)
+}
+
+func (h *IISHandler) AddCashFlow(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	var input models.IISCashFlowCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	flow, err := h.iisService.AddCashFlow(c.Request.Context(), userID, portfolioID, &input)
+	if err != nil {
+		iisError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, flow)
+}
+
+func (h *IISHandler) DeleteCashFlow(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+	id, err := uuid.Parse(c.Param("flowId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidCashFlowID)
+		return
+	}
+
+	if err := h.iisService.DeleteCashFlow(c.Request.Context(), userID, portfolioID, id); err != nil {
+		iisError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "IIS cash flow deleted"})
+}
+
+func iisError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrPortfolioNotFound, service.ErrIISCashFlowNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrNotIIS, service.ErrInvalidIISAmount, service.ErrInvalidIISDate, service.ErrIISWithdrawalTooHigh:
+		respondError(c, http.StatusBadRequest, err)
+	case service.ErrIISLimitExceeded, service.ErrIISEarlyWithdrawal:
+		respondError(c, http.StatusConflict, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -1,16 +1,24 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/api/response"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
+// CSV пакета сделок больше этого не принимаем
+const maxBatchCSVSize = 10 << 20
+
 type InvestmentHandler struct {
 	investmentService service.InvestmentService
 }
@@ -22,7 +30,7 @@ func NewInvestmentHandler(investmentService service.InvestmentService) *Investme
 func (h *InvestmentHandler) SearchSecurities(c *gin.Context) {
 	query := c.Query("q")
 	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "search query required"})
+		respondError(c, http.StatusBadRequest, errSearchQueryRequired)
 		return
 	}
 
@@ -38,29 +46,266 @@ func (h *InvestmentHandler) SearchSecurities(c *gin.Context) {
 		exchange = &ex
 	}
 
-	securities, err := h.investmentService.SearchSecurities(c.Request.Context(), query, securityType, exchange)
+	securities, err := h.investmentService.SearchSecurities(c.Request.Context(), middleware.GetUserID(c), query, securityType, exchange)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, securities)
+}
+
+func (h *InvestmentHandler) GetSecurityPreferences(c *gin.Context) {
+	prefs, err := h.investmentService.GetSecurityPreferences(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, prefs)
+}
+
+func (h *InvestmentHandler) UpdateSecurityPreferences(c *gin.Context) {
+	var input models.SecurityPreferences
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	prefs, err := h.investmentService.UpdateSecurityPreferences(c.Request.Context(), middleware.GetUserID(c), &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, securities)
+	respond(c, http.StatusOK, prefs)
 }
 
 func (h *InvestmentHandler) GetSecurity(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid security ID"})
+		respondError(c, http.StatusBadRequest, errInvalidSecurityID)
 		return
 	}
 
-	security, err := h.investmentService.GetSecurityByID(c.Request.Context(), id)
+	security, err := h.investmentService.GetSecurityByID(c.Request.Context(), userID, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "security not found"})
+		respondError(c, http.StatusNotFound, service.ErrSecurityNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, security)
+	respond(c, http.StatusOK, security)
+}
+
+// CreateManualSecurity заводит бумагу без биржевых котировок (доля в непубличной компании, опционы работодателя)
+func (h *InvestmentHandler) CreateManualSecurity(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.ManualSecurityCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	security, err := h.investmentService.CreateManualSecurity(c.Request.Context(), userID, &input)
+	if err != nil {
+		switch err {
+		case service.ErrManualSecurityExists:
+			respondError(c, http.StatusConflict, err)
+		case service.ErrInvalidManualPrice:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusCreated, security)
+}
+
+func (h *InvestmentHandler) GetManualSecurities(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	securities, err := h.investmentService.GetManualSecurities(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, securities)
+}
+
+// AddManualPrice цена бумаги, заведенной вручную, на дату; самая свежая становится текущей ценой
+func (h *InvestmentHandler) AddManualPrice(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidSecurityID)
+		return
+	}
+
+	var input models.ManualPriceCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	security, err := h.investmentService.AddManualPrice(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		switch err {
+		case service.ErrSecurityNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case service.ErrNotManualSecurity, service.ErrInvalidManualPrice:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusOK, security)
+}
+
+// Screener подбор бумаг по типу, сектору, валюте, цене, доходности и мультипликаторам среди синхронизированных с биржей
+func (h *InvestmentHandler) Screener(c *gin.Context) {
+	filter := &models.SecurityScreenerFilter{
+		Sector:    c.Query("sector"),
+		Currency:  c.Query("currency"),
+		SortBy:    c.Query("sort_by"),
+		SortOrder: c.DefaultQuery("sort_order", "desc"),
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	if t := c.Query("type"); t != "" {
+		st := models.SecurityType(t)
+		filter.Type = &st
+	}
+	if e := c.Query("exchange"); e != "" {
+		ex := models.Exchange(e)
+		filter.Exchange = &ex
+	}
+
+	// некорректные значения диапазонов пропускаются, как в фильтрах операций
+	filter.MinPrice = decimalQuery(c, "min_price")
+	filter.MaxPrice = decimalQuery(c, "max_price")
+	filter.MinYield = decimalQuery(c, "min_yield")
+	filter.MaxYield = decimalQuery(c, "max_yield")
+	filter.MinPE = decimalQuery(c, "min_pe")
+	filter.MaxPE = decimalQuery(c, "max_pe")
+	filter.MinMarketCap = decimalQuery(c, "min_market_cap")
+	filter.MaxMarketCap = decimalQuery(c, "max_market_cap")
+	if from := c.Query("maturity_from"); from != "" {
+		if t, err := time.Parse("2006-01-02", from); err == nil {
+			filter.MaturityFrom = &t
+		}
+	}
+	if to := c.Query("maturity_to"); to != "" {
+		if t, err := time.Parse("2006-01-02", to); err == nil {
+			filter.MaturityTo = &t
+		}
+	}
+
+	result, err := h.investmentService.ScreenSecurities(c.Request.Context(), middleware.GetUserID(c), filter)
+	if err != nil {
+		if err == service.ErrInvalidSortField {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.List(c, result, result.Securities, &response.Pagination{
+		Total: &result.Total,
+		Limit: result.Limit,
+	})
+}
+
+// decimalQuery число из query параметра, nil если параметра нет или он некорректен
+func decimalQuery(c *gin.Context, key string) *decimal.Decimal {
+	if v := c.Query(key); v != "" {
+		if d, err := decimal.NewFromString(v); err == nil {
+			return &d
+		}
+	}
+	return nil
+}
+
+// GetHistory дневные цены бумаги, по умолчанию за последний год
+func (h *InvestmentHandler) GetHistory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidSecurityID)
+		return
+	}
+
+	to := time.Now()
+	from := to.AddDate(-1, 0, 0)
+	if s := c.Query("from"); s != "" {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			from = t
+		}
+	}
+	if e := c.Query("to"); e != "" {
+		if t, err := time.Parse("2006-01-02", e); err == nil {
+			to = t
+		}
+	}
+
+	history, err := h.investmentService.GetSecurityHistory(c.Request.Context(), userID, id, from, to)
+	if err != nil {
+		if err == service.ErrSecurityNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err == service.ErrInvalidDateRange {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, history)
+}
+
+// BackfillHistory загружает историю котировок бумаги за несколько лет (?years=5) и пересчитывает снимки стоимости портфелей
+func (h *InvestmentHandler) BackfillHistory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidSecurityID)
+		return
+	}
+	years := 0
+	if y := c.Query("years"); y != "" {
+		if years, err = strconv.Atoi(y); err != nil {
+			respondError(c, http.StatusBadRequest, service.ErrInvalidBackfillYears)
+			return
+		}
+	}
+
+	result, err := h.investmentService.BackfillHistory(c.Request.Context(), userID, id, years)
+	if err != nil {
+		switch {
+		case err == service.ErrSecurityNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case err == service.ErrInvalidBackfillYears, err == service.ErrBackfillManual:
+			respondError(c, http.StatusBadRequest, err)
+		case errors.Is(err, service.ErrBackfillUnavailable):
+			respondError(c, http.StatusBadGateway, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusOK, result)
 }
 
 func (h *InvestmentHandler) GetQuote(c *gin.Context) {
@@ -70,103 +315,305 @@ func (h *InvestmentHandler) GetQuote(c *gin.Context) {
 
 	quote, err := h.investmentService.GetSecurityQuote(c.Request.Context(), ticker, exchange)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, quote)
+	respond(c, http.StatusOK, quote)
 }
 
 func (h *InvestmentHandler) AddTransaction(c *gin.Context) {
 	var input models.InvestmentTransactionCreate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	transaction, err := h.investmentService.AddTransaction(c.Request.Context(), &input)
 	if err != nil {
 		if err == service.ErrSecurityNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			respondError(c, http.StatusNotFound, err)
 			return
 		}
-		if err == service.ErrInsufficientShares {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err == service.ErrExchangeRateUnavailable {
+			respondError(c, http.StatusBadGateway, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrBrokerRefExists {
+			respondError(c, http.StatusConflict, err)
+			return
+		}
+		if err == service.ErrInsufficientShares || err == service.ErrInvalidRewardInput || err == service.ErrSecurityRequired || err == service.ErrNotDerivative ||
+			err == service.ErrInvalidQuantity || err == service.ErrLotSizeMismatch || err == service.ErrQuantityPrecision ||
+			err == service.ErrCurrencyMismatch || err == service.ErrExchangeRateRequired || err == service.ErrInvalidExchangeRate ||
+			err == service.ErrNotBond || err == service.ErrInvalidPrincipal {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, transaction)
+	respond(c, http.StatusCreated, transaction)
+}
+
+// ImportTransactions пакет сделок в портфель: JSON {"transactions": [...], "dry_run": bool},
+// CSV в теле (text/csv) или файлом file в multipart; для CSV dry_run передается в query
+func (h *InvestmentHandler) ImportTransactions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	var input models.InvestmentBatchImport
+	switch c.ContentType() {
+	case "text/csv", "multipart/form-data":
+		var body io.Reader = c.Request.Body
+		if c.ContentType() == "multipart/form-data" {
+			file, err := c.FormFile("file")
+			if err != nil {
+				respondError(c, http.StatusBadRequest, errFileRequired)
+				return
+			}
+			if file.Size > maxBatchCSVSize {
+				respondError(c, http.StatusRequestEntityTooLarge, errFileTooLarge)
+				return
+			}
+			f, err := file.Open()
+			if err != nil {
+				respondError(c, http.StatusBadRequest, err)
+				return
+			}
+			defer f.Close()
+			body = f
+		}
+
+		input.Transactions, err = service.ParseTransactionsCSV(io.LimitReader(body, maxBatchCSVSize))
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidBatchCSV), err == service.ErrBatchTooLarge:
+				respondError(c, http.StatusBadRequest, err)
+			default:
+				respondError(c, http.StatusInternalServerError, err)
+			}
+			return
+		}
+		input.DryRun = c.Query("dry_run") == "true"
+	default:
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if c.Query("dry_run") == "true" {
+			input.DryRun = true
+		}
+	}
+
+	result, err := h.investmentService.ImportTransactions(c.Request.Context(), userID, portfolioID, &input)
+	if err != nil {
+		switch err {
+		case service.ErrPortfolioNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case service.ErrBatchTooLarge:
+			respondError(c, http.StatusBadRequest, err)
+		case service.ErrInsufficientShares:
+			// пакет сходится, но ломает уже загруженную историю (например, покупка задним числом после продажи)
+			respondError(c, http.StatusConflict, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	switch {
+	case result.Invalid > 0:
+		respond(c, http.StatusUnprocessableEntity, result)
+	case result.DryRun || result.Created == 0:
+		respond(c, http.StatusOK, result)
+	default:
+		respond(c, http.StatusCreated, result)
+	}
 }
 
 func (h *InvestmentHandler) GetTransactions(c *gin.Context) {
 	portfolioID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
 		return
 	}
 
-	limit := 100
-	offset := 0
+	filter := &models.InvestmentTransactionFilter{
+		Limit:     100,
+		SortBy:    c.Query("sort_by"),
+		SortOrder: c.DefaultQuery("sort_order", "desc"),
+	}
 
 	if l := c.Query("limit"); l != "" {
 		if parsed, err := strconv.Atoi(l); err == nil {
-			limit = parsed
+			filter.Limit = parsed
 		}
 	}
 
 	if o := c.Query("offset"); o != "" {
 		if parsed, err := strconv.Atoi(o); err == nil {
-			offset = parsed
+			filter.Offset = parsed
 		}
 	}
+	// ?page= удобнее для бесконечной прокрутки; offset оставлен для старых клиентов
+	filter.Page, _ = strconv.Atoi(c.Query("page"))
+
+	investmentFilterFromQuery(c, filter)
 
-	transactions, err := h.investmentService.GetTransactions(c.Request.Context(), portfolioID, limit, offset)
+	result, err := h.investmentService.GetTransactions(c.Request.Context(), portfolioID, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrInvalidSortField {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, transactions)
+	response.List(c, result, result.Transactions, &response.Pagination{
+		Total:      &result.Total,
+		Page:       result.Page,
+		Limit:      result.Limit,
+		TotalPages: result.TotalPages,
+	})
+}
+
+// GetTransactionsPage операции портфеля с курсорной пагинацией (/api/v2)
+func (h *InvestmentHandler) GetTransactionsPage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	filter := &models.InvestmentTransactionFilter{}
+	investmentFilterFromQuery(c, filter)
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	page, err := h.investmentService.GetTransactionsPage(c.Request.Context(), userID, portfolioID, filter, c.Query("cursor"), limit)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidCursor:
+			respondError(c, http.StatusBadRequest, err)
+		case service.ErrPortfolioNotFound:
+			respondError(c, http.StatusNotFound, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	response.List(c, page, page.Transactions, &response.Pagination{
+		Limit:      page.Limit,
+		NextCursor: page.NextCursor,
+	})
+}
+
+// investmentFilterFromQuery условия фильтра операций из query параметров; некорректные значения пропускаются
+func investmentFilterFromQuery(c *gin.Context, filter *models.InvestmentTransactionFilter) {
+	if securityID := c.Query("security_id"); securityID != "" {
+		if id, err := uuid.Parse(securityID); err == nil {
+			filter.SecurityID = &id
+		}
+	}
+
+	if txType := c.Query("type"); txType != "" {
+		t := models.InvestmentTransactionType(txType)
+		filter.Type = &t
+	}
+
+	if dateFrom := c.Query("date_from"); dateFrom != "" {
+		if t, err := time.Parse("2006-01-02", dateFrom); err == nil {
+			filter.DateFrom = &t
+		}
+	}
+
+	if dateTo := c.Query("date_to"); dateTo != "" {
+		if t, err := time.Parse("2006-01-02", dateTo); err == nil {
+			filter.DateTo = &t
+		}
+	}
 }
 
 func (h *InvestmentHandler) DeleteTransaction(c *gin.Context) {
+	userID := middleware.GetUserID(c)
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction ID"})
+		respondError(c, http.StatusBadRequest, errInvalidTransactionID)
 		return
 	}
 
-	if err := h.investmentService.DeleteTransaction(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.investmentService.DeleteTransaction(c.Request.Context(), userID, id); err != nil {
+		if err == service.ErrTransactionNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "transaction deleted"})
+	respond(c, http.StatusOK, gin.H{"message": "transaction deleted"})
+}
+
+// RestoreTransaction возвращает удаленную операцию портфеля
+func (h *InvestmentHandler) RestoreTransaction(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidTransactionID)
+		return
+	}
+
+	tx, err := h.investmentService.RestoreTransaction(c.Request.Context(), userID, id)
+	if err != nil {
+		switch err {
+		case service.ErrTransactionNotFound, service.ErrSecurityNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case service.ErrBrokerRefExists:
+			respondError(c, http.StatusConflict, err)
+		case service.ErrInsufficientShares:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusOK, tx)
 }
 
 func (h *InvestmentHandler) GetAnalytics(c *gin.Context) {
 	portfolioID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	currency, ok := currencyParam(c)
+	if !ok {
 		return
 	}
 
-	analytics, err := h.investmentService.GetPortfolioAnalytics(c.Request.Context(), portfolioID)
+	analytics, err := h.investmentService.GetPortfolioAnalytics(c.Request.Context(), portfolioID, currency)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, analytics)
+	respond(c, http.StatusOK, analytics)
 }
 
 func (h *InvestmentHandler) GetTaxReport(c *gin.Context) {
 	portfolioID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
 		return
 	}
 
@@ -179,25 +626,177 @@ func (h *InvestmentHandler) GetTaxReport(c *gin.Context) {
 
 	report, err := h.investmentService.GetTaxReport(c.Request.Context(), portfolioID, year)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// GetBenchmark сравнение портфеля с бенчмарком (?benchmark=IMOEX|MCFTR|SBMX|BTC, по умолчанию IMOEX)
+func (h *InvestmentHandler) GetBenchmark(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	comparison, err := h.investmentService.GetBenchmarkComparison(c.Request.Context(), userID, portfolioID, c.Query("benchmark"))
+	if err != nil {
+		switch err {
+		case service.ErrPortfolioNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case service.ErrUnknownBenchmark:
+			respondError(c, http.StatusBadRequest, err)
+		case service.ErrBenchmarkUnavailable:
+			respondError(c, http.StatusBadGateway, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, report)
+	respond(c, http.StatusOK, comparison)
+}
+
+// GetAttribution вклад бумаг и секторов в доходность портфеля (?from=&to= в YYYY-MM-DD, по умолчанию последний год)
+func (h *InvestmentHandler) GetAttribution(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	to := time.Now()
+	if e := c.Query("to"); e != "" {
+		t, err := time.Parse("2006-01-02", e)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidTo)
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(-1, 0, 0)
+	if s := c.Query("from"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidFrom)
+			return
+		}
+		from = t
+	}
+
+	report, err := h.investmentService.GetPerformanceAttribution(c.Request.Context(), userID, portfolioID, from, to)
+	if err != nil {
+		switch err {
+		case service.ErrPortfolioNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case service.ErrInvalidDateRange:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusOK, report)
 }
 
 func (h *InvestmentHandler) GetDividends(c *gin.Context) {
 	portfolioID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.Query("page"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	result, err := h.investmentService.GetUpcomingDividends(c.Request.Context(), portfolioID, page, limit)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.List(c, result, result.Dividends, &response.Pagination{
+		Total:      &result.Total,
+		Page:       result.Page,
+		Limit:      result.Limit,
+		TotalPages: result.TotalPages,
+	})
+}
+
+func (h *InvestmentHandler) GetCoupons(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	coupons, err := h.investmentService.GetUpcomingCoupons(c.Request.Context(), userID, portfolioID)
+	if err != nil {
+		if err == service.ErrPortfolioNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, coupons)
+}
+
+func (h *InvestmentHandler) GetIncomeCalendar(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	currency, ok := currencyParam(c)
+	if !ok {
 		return
 	}
 
-	dividends, err := h.investmentService.GetUpcomingDividends(c.Request.Context(), portfolioID)
+	calendar, err := h.investmentService.GetIncomeCalendar(c.Request.Context(), userID, portfolioID, currency)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrPortfolioNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, calendar)
+}
+
+func (h *InvestmentHandler) GetIncomeReport(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	currency, ok := currencyParam(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.investmentService.GetIncomeReport(c.Request.Context(), userID, portfolioID, currency)
+	if err != nil {
+		if err == service.ErrPortfolioNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, dividends)
+	respond(c, http.StatusOK, report)
 }
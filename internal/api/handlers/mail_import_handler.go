@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MailImportHandler struct {
+	mailImportService service.MailImportService
+}
+
+func NewMailImportHandler(mailImportService service.MailImportService) *MailImportHandler {
+	return &MailImportHandler{mailImportService: mailImportService}
+}
+
+func (h *MailImportHandler) CreateConnection(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.MailConnectionCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	conn, err := h.mailImportService.CreateConnection(c.Request.Context(), userID, &input)
+	if err != nil {
+		mailImportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, conn)
+}
+
+func (h *MailImportHandler) ListConnections(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	conns, err := h.mailImportService.GetConnections(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, conns)
+}
+
+func (h *MailImportHandler) UpdateConnection(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidConnectionID)
+		return
+	}
+
+	var input models.MailConnectionUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	conn, err := h.mailImportService.UpdateConnection(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		mailImportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, conn)
+}
+
+func (h *MailImportHandler) DeleteConnection(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidConnectionID)
+		return
+	}
+
+	if err := h.mailImportService.DeleteConnection(c.Request.Context(), userID, id); err != nil {
+		mailImportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "mail connection deleted"})
+}
+
+// Poll опрашивает ящик сразу; ошибка почтового сервера - 502
+func (h *MailImportHandler) Poll(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidConnectionID)
+		return
+	}
+
+	result, err := h.mailImportService.PollConnection(c.Request.Context(), userID, id)
+	if err != nil {
+		if err == service.ErrMailConnectionNotFound || err == service.ErrMailImportDisabled {
+			mailImportError(c, err)
+			return
+		}
+		respondError(c, http.StatusBadGateway, err)
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
+
+func (h *MailImportHandler) ListDrafts(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var status *models.TransactionDraftStatus
+	if s := c.Query("status"); s != "" {
+		st := models.TransactionDraftStatus(s)
+		if st != models.DraftStatusPending && st != models.DraftStatusConfirmed && st != models.DraftStatusRejected {
+			respondError(c, http.StatusBadRequest, errInvalidStatus)
+			return
+		}
+		status = &st
+	}
+
+	drafts, err := h.mailImportService.GetDrafts(c.Request.Context(), userID, status)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, drafts)
+}
+
+func (h *MailImportHandler) ConfirmDraft(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidDraftID)
+		return
+	}
+
+	var input models.TransactionDraftConfirm
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := h.mailImportService.ConfirmDraft(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		mailImportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, tx)
+}
+
+func (h *MailImportHandler) RejectDraft(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidDraftID)
+		return
+	}
+
+	draft, err := h.mailImportService.RejectDraft(c.Request.Context(), userID, id)
+	if err != nil {
+		mailImportError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, draft)
+}
+
+func mailImportError(c *gin.Context, err error) {
+	if quotaError(c, err) {
+		return
+	}
+	switch err {
+	case service.ErrMailImportDisabled:
+		respondError(c, http.StatusServiceUnavailable, err)
+	case service.ErrMailConnectionNotFound, service.ErrDraftNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrMailLoginFailed, service.ErrMailHostNotAllowed, service.ErrMailInvalidCredentials, service.ErrAccountNotFound, service.ErrDraftNotPending,
+		service.ErrDraftAccountRequired, service.ErrPayeeNotFound:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type NotificationHandler struct {
+	notificationService service.NotificationService
+}
+
+func NewNotificationHandler(notificationService service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+// List входящие: ?unread=true - только непрочитанные, ?type= - один тип, limit/offset - страница
+func (h *NotificationHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	filter := &models.NotificationFilter{UnreadOnly: c.Query("unread") == "true"}
+	if t := c.Query("type"); t != "" {
+		notificationType := models.NotificationType(t)
+		filter.Type = &notificationType
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	list, err := h.notificationService.List(c.Request.Context(), userID, filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, list)
+}
+
+func (h *NotificationHandler) UnreadCount(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	counts, err := h.notificationService.UnreadCount(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"unread": counts.Unread, "unread_by_type": counts.UnreadByType})
+}
+
+// MarkRead отмечает прочитанными {"ids": [...]} или все сразу {"all": true}
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.NotificationMarkRead
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	marked, err := h.notificationService.MarkRead(c.Request.Context(), userID, &input)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"marked": marked})
+}
+
+func (h *NotificationHandler) MarkOneRead(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidNotificationID)
+		return
+	}
+
+	marked, err := h.notificationService.MarkRead(c.Request.Context(), userID, &models.NotificationMarkRead{IDs: []uuid.UUID{id}})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"marked": marked})
+}
+
+func (h *NotificationHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidNotificationID)
+		return
+	}
+
+	if err := h.notificationService.Delete(c.Request.Context(), userID, id); err != nil {
+		if err == service.ErrNotificationNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "notification deleted"})
+}
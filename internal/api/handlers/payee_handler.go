@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PayeeHandler struct {
+	payeeService service.PayeeService
+}
+
+func NewPayeeHandler(payeeService service.PayeeService) *PayeeHandler {
+	return &PayeeHandler{payeeService: payeeService}
+}
+
+func (h *PayeeHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	payees, err := h.payeeService.GetByUserID(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, payees)
+}
+
+func (h *PayeeHandler) GetStats(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPayeeID)
+		return
+	}
+
+	stats, err := h.payeeService.GetStats(c.Request.Context(), userID, id)
+	if err != nil {
+		if err == service.ErrPayeeNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, stats)
+}
+
+func (h *PayeeHandler) Rename(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPayeeID)
+		return
+	}
+
+	var input models.PayeeRename
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	payee, err := h.payeeService.Rename(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		if err == service.ErrPayeeNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err == service.ErrPayeeNameTaken {
+			respondError(c, http.StatusConflict, err)
+			return
+		}
+		if err == service.ErrInvalidPayee {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, payee)
+}
+
+func (h *PayeeHandler) Merge(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPayeeID)
+		return
+	}
+
+	var input models.PayeeMerge
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	payee, err := h.payeeService.Merge(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		if err == service.ErrPayeeNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, payee)
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PlannedTransactionHandler struct {
+	plannedService service.PlannedTransactionService
+}
+
+func NewPlannedTransactionHandler(plannedService service.PlannedTransactionService) *PlannedTransactionHandler {
+	return &PlannedTransactionHandler{plannedService: plannedService}
+}
+
+func (h *PlannedTransactionHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.PlannedTransactionCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	planned, err := h.plannedService.Create(c.Request.Context(), userID, &input)
+	if err != nil {
+		if isPlannedInputError(err) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, planned)
+}
+
+func (h *PlannedTransactionHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var status *models.PlannedTransactionStatus
+	if s := c.Query("status"); s != "" {
+		st := models.PlannedTransactionStatus(s)
+		if st != models.PlannedStatusPlanned && st != models.PlannedStatusPosted && st != models.PlannedStatusCancelled {
+			respondError(c, http.StatusBadRequest, errInvalidStatus)
+			return
+		}
+		status = &st
+	}
+
+	planned, err := h.plannedService.GetByUserID(c.Request.Context(), userID, status)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, planned)
+}
+
+func (h *PlannedTransactionHandler) GetByID(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPlannedTransactionID)
+		return
+	}
+
+	planned, err := h.plannedService.GetByID(c.Request.Context(), userID, id)
+	if err != nil {
+		if err == service.ErrPlannedNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, planned)
+}
+
+func (h *PlannedTransactionHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPlannedTransactionID)
+		return
+	}
+
+	var input models.PlannedTransactionUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	planned, err := h.plannedService.Update(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		if err == service.ErrPlannedNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err == service.ErrPlannedNotPending {
+			respondError(c, http.StatusConflict, err)
+			return
+		}
+		if isPlannedInputError(err) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, planned)
+}
+
+func (h *PlannedTransactionHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPlannedTransactionID)
+		return
+	}
+
+	if err := h.plannedService.Delete(c.Request.Context(), userID, id); err != nil {
+		if err == service.ErrPlannedNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "planned transaction deleted"})
+}
+
+func (h *PlannedTransactionHandler) Confirm(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPlannedTransactionID)
+		return
+	}
+
+	// тело необязательно - по умолчанию проводим плановые сумму и дату
+	var input models.PlannedTransactionConfirm
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := h.plannedService.Confirm(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		if quotaError(c, err) {
+			return
+		}
+		if err == service.ErrPlannedNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err == service.ErrPlannedNotPending {
+			respondError(c, http.StatusConflict, err)
+			return
+		}
+		if isPlannedInputError(err) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, tx)
+}
+
+func (h *PlannedTransactionHandler) Cancel(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPlannedTransactionID)
+		return
+	}
+
+	planned, err := h.plannedService.Cancel(c.Request.Context(), userID, id)
+	if err != nil {
+		if err == service.ErrPlannedNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err == service.ErrPlannedNotPending {
+			respondError(c, http.StatusConflict, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, planned)
+}
+
+func isPlannedInputError(err error) bool {
+	return err == service.ErrAccountNotFound ||
+		err == service.ErrInvalidPlannedAmount ||
+		err == service.ErrInvalidTransactionType ||
+		err == service.ErrTransferMissingAccount
+}
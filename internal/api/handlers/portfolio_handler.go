@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/api/response"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/service"
 	"github.com/gin-gonic/gin"
@@ -23,17 +27,24 @@ func (h *PortfolioHandler) Create(c *gin.Context) {
 
 	var input models.PortfolioCreate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	portfolio, err := h.portfolioService.Create(c.Request.Context(), userID, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if quotaError(c, err) {
+			return
+		}
+		if err == service.ErrIISCurrency {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, portfolio)
+	respond(c, http.StatusCreated, portfolio)
 }
 
 func (h *PortfolioHandler) List(c *gin.Context) {
@@ -41,100 +52,172 @@ func (h *PortfolioHandler) List(c *gin.Context) {
 
 	portfolios, err := h.portfolioService.GetByUserID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, portfolios)
+	respond(c, http.StatusOK, portfolios)
+}
+
+// GetSummary компактная сводка по всем портфелям для виджета на главной
+func (h *PortfolioHandler) GetSummary(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	summaries, err := h.portfolioService.GetSummary(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, summaries)
 }
 
 func (h *PortfolioHandler) GetByID(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
 		return
 	}
 
 	portfolio, err := h.portfolioService.GetWithHoldings(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "portfolio not found"})
+		respondError(c, http.StatusNotFound, service.ErrPortfolioNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, portfolio)
+	respond(c, http.StatusOK, portfolio)
 }
 
 func (h *PortfolioHandler) GetHoldings(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
 		return
 	}
 
-	portfolio, err := h.portfolioService.GetWithHoldings(c.Request.Context(), id)
+	// фильтр по заметкам: ?tag=core&tag=dividends (нужны все), ?q=продать, ?has_target_price=true
+	filter := &models.HoldingFilter{
+		Tags:           c.QueryArray("tag"),
+		Search:         strings.TrimSpace(c.Query("q")),
+		HasTargetPrice: c.Query("has_target_price") == "true",
+	}
+	filter.Page, _ = strconv.Atoi(c.Query("page"))
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+
+	result, err := h.portfolioService.GetHoldings(c.Request.Context(), id, filter)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "portfolio not found"})
+		respondError(c, http.StatusNotFound, service.ErrPortfolioNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, portfolio.Holdings)
+	response.List(c, result, result.Holdings, &response.Pagination{
+		Total:      &result.Total,
+		Page:       result.Page,
+		Limit:      result.Limit,
+		TotalPages: result.TotalPages,
+	})
 }
 
 func (h *PortfolioHandler) Update(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
 		return
 	}
 
 	var input models.PortfolioUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	portfolio, err := h.portfolioService.Update(c.Request.Context(), id, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrIISCurrency {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, portfolio)
+	respond(c, http.StatusOK, portfolio)
 }
 
 func (h *PortfolioHandler) Delete(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
 		return
 	}
 
 	if err := h.portfolioService.Delete(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "portfolio deleted"})
+	respond(c, http.StatusOK, gin.H{"message": "portfolio deleted"})
 }
 
+// RefreshPrices обновляет цены всех или указанных бумаг портфеля. 200 - итог по каждой бумаге,
+// 202 - большой портфель обновляется в фоне, статус по GET /portfolios/:id/refresh/:jobId
 func (h *PortfolioHandler) RefreshPrices(c *gin.Context) {
+	userID := middleware.GetUserID(c)
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	// тело необязательно: без него обновляются все позиции
+	var input models.PortfolioRefreshRequest
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := h.portfolioService.Refresh(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		switch err {
+		case service.ErrPortfolioNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case service.ErrTickerNotInPortfolio:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
-	if err := h.portfolioService.RefreshPrices(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if job.Status != models.RefreshJobDone {
+		respond(c, http.StatusAccepted, job)
 		return
 	}
 
-	// Return updated portfolio
+	// портфель с позициями по новым ценам, как раньше отдавал этот эндпоинт
 	portfolio, err := h.portfolioService.GetWithHoldings(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	job.Report.Portfolio = portfolio
+
+	respond(c, http.StatusOK, job.Report)
+}
+
+func (h *PortfolioHandler) GetRefreshJob(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	jobID, err := uuid.Parse(c.Param("jobId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidJobID)
+		return
+	}
+
+	job, err := h.portfolioService.GetRefreshJob(c.Request.Context(), userID, jobID)
+	if err != nil {
+		respondError(c, http.StatusNotFound, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, portfolio)
+	respond(c, http.StatusOK, job)
 }
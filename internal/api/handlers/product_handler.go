@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ProductHandler struct {
+	productService service.ProductService
+}
+
+func NewProductHandler(productService service.ProductService) *ProductHandler {
+	return &ProductHandler{productService: productService}
+}
+
+func (h *ProductHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	products, err := h.productService.GetByUserID(c.Request.Context(), userID, c.Query("q"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, products)
+}
+
+// GetSpending сколько потрачено на каждый товар за период
+func (h *ProductHandler) GetSpending(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	startDate, endDate := productDates(c)
+	currency, ok := currencyParam(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.productService.GetSpending(c.Request.Context(), userID, startDate, endDate, currency)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// GetPriceHistory цены товара по покупкам и продавцам
+func (h *ProductHandler) GetPriceHistory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidProductID)
+		return
+	}
+
+	startDate, endDate := productDates(c)
+	history, err := h.productService.GetPriceHistory(c.Request.Context(), userID, id, startDate, endDate)
+	if err != nil {
+		if err == service.ErrProductNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, history)
+}
+
+func (h *ProductHandler) Rename(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidProductID)
+		return
+	}
+
+	var input models.ProductRename
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	product, err := h.productService.Rename(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		if err == service.ErrProductNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err == service.ErrProductNameTaken {
+			respondError(c, http.StatusConflict, err)
+			return
+		}
+		if err == service.ErrInvalidProduct {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, product)
+}
+
+func (h *ProductHandler) Merge(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidProductID)
+		return
+	}
+
+	var input models.ProductMerge
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	product, err := h.productService.Merge(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		if err == service.ErrProductNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, product)
+}
+
+// productDates ?start_date= и ?end_date= (YYYY-MM-DD); неверная или пустая дата - по умолчанию
+func productDates(c *gin.Context) (startDate, endDate *time.Time) {
+	if s := c.Query("start_date"); s != "" {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			startDate = &t
+		}
+	}
+	if e := c.Query("end_date"); e != "" {
+		if t, err := time.Parse("2006-01-02", e); err == nil {
+			endDate = &t
+		}
+	}
+	return startDate, endDate
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RebalanceHandler struct {
+	rebalanceService service.RebalanceService
+}
+
+func NewRebalanceHandler(rebalanceService service.RebalanceService) *RebalanceHandler {
+	return &RebalanceHandler{rebalanceService: rebalanceService}
+}
+
+func (h *RebalanceHandler) GetTargets(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	targets, err := h.rebalanceService.GetTargets(c.Request.Context(), userID, id)
+	if err != nil {
+		rebalanceError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, targets)
+}
+
+func (h *RebalanceHandler) SetTargets(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	var input models.RebalanceTargetsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	targets, err := h.rebalanceService.SetTargets(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		rebalanceError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, targets)
+}
+
+// GetReport отклонение долей от целевых и сделки для ребалансировки
+func (h *RebalanceHandler) GetReport(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidPortfolioID)
+		return
+	}
+
+	report, err := h.rebalanceService.GetReport(c.Request.Context(), userID, id)
+	if err != nil {
+		rebalanceError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+func rebalanceError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrPortfolioNotFound, service.ErrSecurityNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrInvalidTargetWeight, service.ErrDuplicateTarget, service.ErrInvalidThreshold:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// фото чека больше этого не принимаем
+const maxReceiptImageSize = 10 << 20
+
+type ReceiptHandler struct {
+	receiptService service.ReceiptService
+}
+
+func NewReceiptHandler(receiptService service.ReceiptService) *ReceiptHandler {
+	return &ReceiptHandler{receiptService: receiptService}
+}
+
+// Import принимает JSON со строкой из QR-кода или multipart-форму с фото QR-кода (поле image)
+func (h *ReceiptHandler) Import(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.ReceiptImport
+	var image []byte
+	var filename string
+
+	if c.ContentType() == "multipart/form-data" {
+		var err error
+		if input.AccountID, err = uuid.Parse(c.PostForm("account_id")); err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidAccountFilter)
+			return
+		}
+		if input.CategoryID, err = uuid.Parse(c.PostForm("category_id")); err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidCategoryFilter)
+			return
+		}
+		input.QR = c.PostForm("qr")
+
+		if file, err := c.FormFile("image"); err == nil {
+			if file.Size > maxReceiptImageSize {
+				respondError(c, http.StatusRequestEntityTooLarge, errImageTooLarge)
+				return
+			}
+			f, err := file.Open()
+			if err != nil {
+				respondError(c, http.StatusBadRequest, err)
+				return
+			}
+			image, err = io.ReadAll(io.LimitReader(f, maxReceiptImageSize))
+			f.Close()
+			if err != nil {
+				respondError(c, http.StatusBadRequest, err)
+				return
+			}
+			filename = file.Filename
+		}
+	} else if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.receiptService.Import(c.Request.Context(), userID, &input, image, filename)
+	if err != nil {
+		if quotaError(c, err) {
+			return
+		}
+		switch err {
+		case service.ErrReceiptDisabled, service.ErrReceiptUnavailable:
+			respondError(c, http.StatusServiceUnavailable, err)
+		case service.ErrReceiptRequired, service.ErrInvalidReceiptQR, service.ErrAccountNotFound:
+			respondError(c, http.StatusBadRequest, err)
+		case service.ErrReceiptNotFound:
+			respondError(c, http.StatusNotFound, err)
+		case service.ErrReceiptAlreadyImported:
+			respondError(c, http.StatusConflict, err)
+		case service.ErrReceiptRateLimited:
+			respondError(c, http.StatusTooManyRequests, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusCreated, result)
+}
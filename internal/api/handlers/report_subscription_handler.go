@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ReportSubscriptionHandler struct {
+	reportService service.ReportSubscriptionService
+}
+
+func NewReportSubscriptionHandler(reportService service.ReportSubscriptionService) *ReportSubscriptionHandler {
+	return &ReportSubscriptionHandler{reportService: reportService}
+}
+
+func (h *ReportSubscriptionHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.ReportSubscriptionCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	sub, err := h.reportService.Create(c.Request.Context(), userID, &input)
+	if err != nil {
+		reportSubscriptionError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, sub)
+}
+
+func (h *ReportSubscriptionHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	subs, err := h.reportService.GetByUserID(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, subs)
+}
+
+func (h *ReportSubscriptionHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidSubscriptionID)
+		return
+	}
+
+	var input models.ReportSubscriptionUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	sub, err := h.reportService.Update(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		reportSubscriptionError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, sub)
+}
+
+func (h *ReportSubscriptionHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidSubscriptionID)
+		return
+	}
+
+	if err := h.reportService.Delete(c.Request.Context(), userID, id); err != nil {
+		reportSubscriptionError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "subscription deleted"})
+}
+
+func (h *ReportSubscriptionHandler) SendNow(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidSubscriptionID)
+		return
+	}
+
+	if err := h.reportService.SendNow(c.Request.Context(), userID, id); err != nil {
+		reportSubscriptionError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "report sent"})
+}
+
+func reportSubscriptionError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrReportEmailDisabled:
+		respondError(c, http.StatusServiceUnavailable, err)
+	case service.ErrReportSubscriptionNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrReportSubscriptionExists:
+		respondError(c, http.StatusConflict, err)
+	case service.ErrInvalidReportFrequency, service.ErrInvalidReportSchedule, service.ErrReportEmpty:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
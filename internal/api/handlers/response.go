@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// respond успешный ответ в формате, выбранном для запроса
+func respond(c *gin.Context, status int, data interface{}) {
+	response.OK(c, status, data)
+}
+
+// respondError ответ с ошибкой сервиса или разбора запроса
+func respondError(c *gin.Context, status int, err error) {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		details := make([]response.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			details = append(details, response.FieldError{Field: fe.Field(), Rule: fe.Tag()})
+		}
+		response.Fail(c, http.StatusBadRequest, "validation_failed", err.Error(), details)
+		return
+	}
+
+	response.Fail(c, status, errorCode(err, status), err.Error(), nil)
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type SystemHandler struct {
+	healthService service.HealthService
+}
+
+func NewSystemHandler(healthService service.HealthService) *SystemHandler {
+	return &SystemHandler{healthService: healthService}
+}
+
+// GetProviders состояние провайдеров котировок: помогает понять, почему цены не обновляются
+func (h *SystemHandler) GetProviders(c *gin.Context) {
+	respond(c, http.StatusOK, h.healthService.Providers())
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type TagHandler struct {
+	tagService service.TagService
+}
+
+func NewTagHandler(tagService service.TagService) *TagHandler {
+	return &TagHandler{tagService: tagService}
+}
+
+func (h *TagHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	tags, err := h.tagService.List(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, tags)
+}
+
+// Rename старое имя передается в теле: в метке может быть "/", который не пройдет в URL
+func (h *TagHandler) Rename(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.TagRename
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	change, err := h.tagService.Rename(c.Request.Context(), userID, &input)
+	if err != nil {
+		tagError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, change)
+}
+
+func (h *TagHandler) Merge(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.TagMerge
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	change, err := h.tagService.Merge(c.Request.Context(), userID, &input)
+	if err != nil {
+		tagError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, change)
+}
+
+// GetSpending ?tags=a&tags=b (без них - все метки), group_by, start_date, end_date, currency
+func (h *TagHandler) GetSpending(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var startDate, endDate *time.Time
+	if s := c.Query("start_date"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidStartDate)
+			return
+		}
+		startDate = &t
+	}
+	if e := c.Query("end_date"); e != "" {
+		t, err := time.Parse("2006-01-02", e)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidEndDate)
+			return
+		}
+		endDate = &t
+	}
+
+	currency, ok := currencyParam(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.tagService.GetSpending(c.Request.Context(), userID, c.QueryArray("tags"), c.Query("group_by"), startDate, endDate, currency)
+	if err != nil {
+		tagError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+func tagError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrTagNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrTagNameTaken:
+		respondError(c, http.StatusConflict, err)
+	case service.ErrInvalidTag, service.ErrInvalidTagGroup:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/alligatorO15/fin-tracker/internal/telegram"
+	"github.com/gin-gonic/gin"
+)
+
+// обновление от Telegram - одно сообщение, больше не читаем
+const maxTelegramUpdateSize = 1 << 20
+
+type TelegramHandler struct {
+	telegramService service.TelegramService
+}
+
+func NewTelegramHandler(telegramService service.TelegramService) *TelegramHandler {
+	return &TelegramHandler{telegramService: telegramService}
+}
+
+// CreateLink одноразовая ссылка на бота для привязки чата
+func (h *TelegramHandler) CreateLink(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.TelegramLinkRequest
+	// тело необязательное
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	code, err := h.telegramService.CreateLinkCode(c.Request.Context(), userID, &input)
+	if err != nil {
+		switch err {
+		case service.ErrTelegramDisabled:
+			respondError(c, http.StatusServiceUnavailable, err)
+		case service.ErrAccountNotFound:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	respond(c, http.StatusCreated, code)
+}
+
+func (h *TelegramHandler) GetLink(c *gin.Context) {
+	link, err := h.telegramService.GetLink(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respond(c, http.StatusOK, link)
+}
+
+func (h *TelegramHandler) Unlink(c *gin.Context) {
+	if err := h.telegramService.Unlink(c.Request.Context(), middleware.GetUserID(c)); err != nil {
+		if err == service.ErrTelegramNotLinked {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"message": "telegram unlinked"})
+}
+
+// Webhook входящие обновления бота (адрес задается в setWebhook вместе с secret_token)
+func (h *TelegramHandler) Webhook(c *gin.Context) {
+	if err := h.telegramService.VerifyWebhook(c.GetHeader("X-Telegram-Bot-Api-Secret-Token")); err != nil {
+		if err == service.ErrTelegramDisabled {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTelegramUpdateSize)
+	var update telegram.Update
+	if err := c.ShouldBindJSON(&update); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// на ошибку Telegram повторяет доставку, а повтор здесь ничего не исправит
+	if err := h.telegramService.HandleUpdate(c.Request.Context(), &update); err != nil {
+		log.Printf("Не удалось ответить в Telegram на обновление %d: %v", update.UpdateID, err)
+	}
+	c.Status(http.StatusOK)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/api/response"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/service"
 	"github.com/gin-gonic/gin"
@@ -28,26 +29,91 @@ func (h *TransactionHandler) Create(c *gin.Context) {
 
 	var input models.TransactionCreate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	transaction, err := h.transactionService.Create(c.Request.Context(), userID, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if quotaError(c, err) {
+			return
+		}
+		if err == service.ErrPayeeNotFound || err == service.ErrInvalidCoordinates {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, transaction)
+	respond(c, http.StatusCreated, transaction)
 }
 
 // получиаем список транзакций с фильрацией
 func (h *TransactionHandler) List(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
+	filter := transactionFilterFromQuery(c)
+
+	if page := c.Query("page"); page != "" {
+		if p, err := strconv.Atoi(page); err == nil {
+			filter.Page = p
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = l
+		}
+	}
+
+	filter.SortBy = c.DefaultQuery("sort_by", "date")
+	filter.SortOrder = c.DefaultQuery("sort_order", "desc")
+
+	result, err := h.transactionService.GetByFilter(c.Request.Context(), userID, filter)
+	if err != nil {
+		if err == service.ErrInvalidSortField {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.List(c, result, result.Transactions, &response.Pagination{
+		Total:      &result.Total,
+		Page:       result.Page,
+		Limit:      result.Limit,
+		TotalPages: result.TotalPages,
+	})
+}
+
+// ListPage список транзакций с курсорной пагинацией (/api/v2)
+func (h *TransactionHandler) ListPage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	filter := transactionFilterFromQuery(c)
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	page, err := h.transactionService.GetPage(c.Request.Context(), userID, filter, c.Query("cursor"), limit)
+	if err != nil {
+		if err == service.ErrInvalidCursor {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.List(c, page, page.Transactions, &response.Pagination{
+		Limit:      page.Limit,
+		NextCursor: page.NextCursor,
+	})
+}
+
+// transactionFilterFromQuery фильтр списка из query параметров; некорректные значения пропускаются
+func transactionFilterFromQuery(c *gin.Context) *models.TransactionFilter {
 	filter := &models.TransactionFilter{}
 
-	// парсим query параметры
 	if accountID := c.Query("account_id"); accountID != "" {
 		if id, err := uuid.Parse(accountID); err == nil {
 			filter.AccountID = &id
@@ -60,6 +126,12 @@ func (h *TransactionHandler) List(c *gin.Context) {
 		}
 	}
 
+	if payeeID := c.Query("payee_id"); payeeID != "" {
+		if id, err := uuid.Parse(payeeID); err == nil {
+			filter.PayeeID = &id
+		}
+	}
+
 	if txType := c.Query("type"); txType != "" {
 		t := models.TransactionType(txType)
 		filter.Type = &t
@@ -90,80 +162,65 @@ func (h *TransactionHandler) List(c *gin.Context) {
 	}
 
 	filter.Search = c.Query("search")
+	filter.Tags = c.QueryArray("tags")
+	filter.Currency = c.Query("currency")
 
-	if page := c.Query("page"); page != "" {
-		if p, err := strconv.Atoi(page); err == nil {
-			filter.Page = p
-		}
-	}
-
-	if limit := c.Query("limit"); limit != "" {
-		if l, err := strconv.Atoi(limit); err == nil {
-			filter.Limit = l
-		}
-	}
-
-	filter.SortBy = c.DefaultQuery("sort_by", "date")
-	filter.SortOrder = c.DefaultQuery("sort_order", "desc")
-
-	result, err := h.transactionService.GetByFilter(c.Request.Context(), userID, filter)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, result)
+	return filter
 }
 
 func (h *TransactionHandler) GetByID(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction ID"})
+		respondError(c, http.StatusBadRequest, errInvalidTransactionID)
 		return
 	}
 
 	transaction, err := h.transactionService.GetByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		respondError(c, http.StatusNotFound, service.ErrTransactionNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, transaction)
+	respond(c, http.StatusOK, transaction)
 }
 
 func (h *TransactionHandler) Update(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction ID"})
+		respondError(c, http.StatusBadRequest, errInvalidTransactionID)
 		return
 	}
 
 	var input models.TransactionUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	transaction, err := h.transactionService.Update(c.Request.Context(), id, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrPayeeNotFound || err == service.ErrInvalidCoordinates {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, transaction)
+	respond(c, http.StatusOK, transaction)
 }
 
 func (h *TransactionHandler) Delete(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction ID"})
+		respondError(c, http.StatusBadRequest, errInvalidTransactionID)
 		return
 	}
 
 	if err := h.transactionService.Delete(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "transaction deleted"})
+	respond(c, http.StatusOK, gin.H{"message": "transaction deleted"})
 }
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type TransferMatchHandler struct {
+	transferMatchService service.TransferMatchService
+}
+
+func NewTransferMatchHandler(transferMatchService service.TransferMatchService) *TransferMatchHandler {
+	return &TransferMatchHandler{transferMatchService: transferMatchService}
+}
+
+// List предлагает пары расход/доход, похожие на переводы между своими счетами
+func (h *TransferMatchHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var days *int
+	if d := c.Query("days"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidDays)
+			return
+		}
+		days = &parsed
+	}
+
+	var since *time.Time
+	if s := c.Query("since"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidSinceDate)
+			return
+		}
+		since = &parsed
+	}
+
+	matches, err := h.transferMatchService.Find(c.Request.Context(), userID, days, since)
+	if err != nil {
+		if err == service.ErrTransferWindowInvalid {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, matches)
+}
+
+// Confirm объединяет выбранную пару в один перевод
+func (h *TransferMatchHandler) Confirm(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.TransferMatchConfirm
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	transfer, err := h.transferMatchService.Confirm(c.Request.Context(), userID, &input)
+	if err != nil {
+		if err == service.ErrTransactionNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err == service.ErrInvalidTransferMatch {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, transfer)
+}
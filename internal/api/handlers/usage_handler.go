@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type UsageHandler struct {
+	quotaService service.QuotaService
+}
+
+func NewUsageHandler(quotaService service.QuotaService) *UsageHandler {
+	return &UsageHandler{quotaService: quotaService}
+}
+
+func (h *UsageHandler) Get(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	usage, err := h.quotaService.GetUsage(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, usage)
+}
+
+// quotaError отвечает на исчерпанную квоту; false - ошибка не про квоты
+func quotaError(c *gin.Context, err error) bool {
+	switch err {
+	case service.ErrPortfolioQuotaExceeded, service.ErrAttachmentQuotaExceeded:
+		respondError(c, http.StatusForbidden, err)
+	case service.ErrTransactionQuotaExceeded, service.ErrAIQuotaExceeded:
+		respondError(c, http.StatusTooManyRequests, err)
+	default:
+		return false
+	}
+	return true
+}
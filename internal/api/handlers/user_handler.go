@@ -1,14 +1,20 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/service"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
+// исходный файл аватара больше этого не принимаем
+const maxAvatarSize = 5 << 20
+
 type UserHandler struct {
 	userService service.UserService
 }
@@ -22,11 +28,11 @@ func (h *UserHandler) GetCurrent(c *gin.Context) {
 
 	user, err := h.userService.GetByID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		respondError(c, http.StatusNotFound, service.ErrUserNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	respond(c, http.StatusOK, user)
 }
 
 func (h *UserHandler) Update(c *gin.Context) {
@@ -34,26 +40,192 @@ func (h *UserHandler) Update(c *gin.Context) {
 
 	var input models.UserUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	user, err := h.userService.Update(c.Request.Context(), userID, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	respond(c, http.StatusOK, user)
 }
 
 func (h *UserHandler) Delete(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	if err := h.userService.Delete(c.Request.Context(), userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// тело необязательно: с почтой подтверждение придет письмом
+	var input models.UserDeleteRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	result, err := h.userService.Delete(c.Request.Context(), userID, &input, clientInfo(c))
+	if err != nil {
+		if err == service.ErrInvalidPassword {
+			respondError(c, http.StatusForbidden, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if result.Status == models.UserDeleteConfirmationSent {
+		respond(c, http.StatusAccepted, result)
+		return
+	}
+	respond(c, http.StatusOK, result)
+}
+
+// ConfirmDelete удаление по коду из письма; код сам подтверждает владельца, вход не нужен
+func (h *UserHandler) ConfirmDelete(c *gin.Context) {
+	var input models.UserDeleteConfirm
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	deletion, err := h.userService.ConfirmDelete(c.Request.Context(), input.Token, clientInfo(c))
+	if err != nil {
+		if err == service.ErrInvalidDeletionCode {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"message":     "user deleted",
+		"purge_after": deletion.PurgeAfter,
+	})
+}
+
+// Restore восстанавливает удаленный аккаунт до конца льготного периода, после этого можно войти как обычно
+func (h *UserHandler) Restore(c *gin.Context) {
+	var input models.UserRestoreRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.userService.Restore(c.Request.Context(), &input); err != nil {
+		switch err {
+		case service.ErrNothingToRestore:
+			respondError(c, http.StatusBadRequest, err)
+		case service.ErrUserExists:
+			respondError(c, http.StatusConflict, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "user restored"})
+}
+
+// UpdateProfile принимает JSON с телефоном и датой рождения или multipart-форму:
+// поля phone, birth_date (ГГГГ-ММ-ДД) и файл avatar
+func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.UserProfileUpdate
+	var avatar []byte
+
+	if c.ContentType() == "multipart/form-data" {
+		if phone, ok := c.GetPostForm("phone"); ok {
+			input.Phone = &phone
+		}
+		if value := c.PostForm("birth_date"); value != "" {
+			birthDate, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, errInvalidBirthDate)
+				return
+			}
+			input.BirthDate = &birthDate
+		}
+		if err := binding.Validator.ValidateStruct(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+
+		if file, err := c.FormFile("avatar"); err == nil {
+			if file.Size > maxAvatarSize {
+				respondError(c, http.StatusRequestEntityTooLarge, errAvatarTooLarge)
+				return
+			}
+			f, err := file.Open()
+			if err != nil {
+				respondError(c, http.StatusBadRequest, err)
+				return
+			}
+			avatar, err = io.ReadAll(io.LimitReader(f, maxAvatarSize))
+			f.Close()
+			if err != nil {
+				respondError(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(c.Request.Context(), userID, &input, avatar)
+	if err != nil {
+		if quotaError(c, err) {
+			return
+		}
+		switch err {
+		case service.ErrInvalidBirthDate, service.ErrInvalidImage:
+			respondError(c, http.StatusBadRequest, err)
+		case service.ErrAttachmentsDisabled:
+			respondError(c, http.StatusServiceUnavailable, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	respond(c, http.StatusOK, user)
+}
+
+// GetAvatar картинка аватара (JPEG)
+func (h *UserHandler) GetAvatar(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	data, err := h.userService.GetAvatar(c.Request.Context(), userID)
+	if err != nil {
+		if err == service.ErrAvatarNotFound || err == service.ErrUserNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// ссылка версионируется (?v=), так что браузер может держать картинку в кэше
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, "image/jpeg", data)
+}
+
+func (h *UserHandler) DeleteAvatar(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	user, err := h.userService.DeleteAvatar(c.Request.Context(), userID)
+	if err != nil {
+		if err == service.ErrAvatarNotFound || err == service.ErrUserNotFound {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
+	respond(c, http.StatusOK, user)
 }
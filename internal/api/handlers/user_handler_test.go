@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// profileUserService запоминает обновление профиля, остальные методы не нужны
+type profileUserService struct {
+	service.UserService
+	update *models.UserProfileUpdate
+}
+
+func (s *profileUserService) UpdateProfile(ctx context.Context, id uuid.UUID, update *models.UserProfileUpdate, avatar []byte) (*models.User, error) {
+	s.update = update
+	return &models.User{ID: id}, nil
+}
+
+func TestUpdateProfilePhone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantPhone *string
+	}{
+		{name: "пустая строка удаляет телефон", body: `{"phone": ""}`, wantCode: http.StatusOK, wantPhone: new(string)},
+		{name: "номер E.164", body: `{"phone": "+79991234567"}`, wantCode: http.StatusOK, wantPhone: stringPtr("+79991234567")},
+		{name: "без телефона", body: `{}`, wantCode: http.StatusOK},
+		{name: "не E.164", body: `{"phone": "8 999 123"}`, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &profileUserService{}
+			router := gin.New()
+			router.PUT("/user/profile", NewUserHandler(users).UpdateProfile)
+
+			req := httptest.NewRequest(http.MethodPut, "/user/profile", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			got := users.update.Phone
+			if (got == nil) != (tt.wantPhone == nil) || (got != nil && *got != *tt.wantPhone) {
+				t.Errorf("phone = %v, want %v", got, tt.wantPhone)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
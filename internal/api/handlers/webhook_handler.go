@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// уведомление об операции - небольшой JSON, больше не читаем
+const maxWebhookPayloadSize = 64 << 10
+
+type WebhookHandler struct {
+	webhookService service.WebhookService
+}
+
+func NewWebhookHandler(webhookService service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+func (h *WebhookHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var input models.WebhookEndpointCreate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	endpoint, err := h.webhookService.Create(c.Request.Context(), userID, &input)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, endpoint)
+}
+
+func (h *WebhookHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	endpoints, err := h.webhookService.GetByUserID(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(c, http.StatusOK, endpoints)
+}
+
+func (h *WebhookHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidWebhookID)
+		return
+	}
+
+	var input models.WebhookEndpointUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	endpoint, err := h.webhookService.Update(c.Request.Context(), userID, id, &input)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, endpoint)
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidWebhookID)
+		return
+	}
+
+	if err := h.webhookService.Delete(c.Request.Context(), userID, id); err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "webhook deleted"})
+}
+
+func (h *WebhookHandler) RotateToken(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidWebhookID)
+		return
+	}
+
+	endpoint, err := h.webhookService.RotateToken(c.Request.Context(), userID, id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, endpoint)
+}
+
+// Receive публичный прием уведомления: авторизация - секретный токен в адресе
+func (h *WebhookHandler) Receive(c *gin.Context) {
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookPayloadSize))
+	if err != nil {
+		respondError(c, http.StatusRequestEntityTooLarge, errPayloadTooLarge)
+		return
+	}
+
+	tx, created, err := h.webhookService.Ingest(c.Request.Context(), c.Param("token"), payload)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+	respond(c, status, tx)
+}
+
+func webhookError(c *gin.Context, err error) {
+	if quotaError(c, err) {
+		return
+	}
+	switch err {
+	case service.ErrWebhookNotFound:
+		respondError(c, http.StatusNotFound, err)
+	case service.ErrWebhookInactive:
+		respondError(c, http.StatusForbidden, err)
+	case service.ErrWebhookInvalidPayload, service.ErrWebhookInvalidAmount, service.ErrWebhookInvalidType,
+		service.ErrWebhookInvalidDate, service.ErrWebhookCurrencyMismatch, service.ErrAccountNotFound,
+		service.ErrCategoryNotFound, service.ErrPayeeNotFound:
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/alligatorO15/fin-tracker/internal/api/response"
 	"github.com/alligatorO15/fin-tracker/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -18,19 +19,19 @@ func Auth(authService service.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
+			response.Fail(c, http.StatusUnauthorized, "unauthorized", "authorization header required", nil)
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+			response.Fail(c, http.StatusUnauthorized, "unauthorized", "invalid authorization header format", nil)
 			return
 		}
 
 		claims, err := authService.ValidateToken(parts[1])
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+			response.Fail(c, http.StatusUnauthorized, "invalid_token", "invalid or expired token", nil)
 			return
 		}
 
@@ -49,3 +50,21 @@ func GetUserID(c *gin.Context) uuid.UUID {
 
 	return userID.(uuid.UUID)
 }
+
+func GetEmail(c *gin.Context) string {
+	return c.GetString(EmailKey)
+}
+
+// AdminOnly пропускает только пользователей из списка администраторов (ставится после Auth)
+func AdminOnly(adminIDs []uuid.UUID) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := GetUserID(c)
+		for _, admin := range adminIDs {
+			if userID != uuid.Nil && userID == admin {
+				c.Next()
+				return
+			}
+		}
+		response.Fail(c, http.StatusForbidden, "forbidden", "admin access required", nil)
+	}
+}
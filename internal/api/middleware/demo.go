@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/response"
+	"github.com/gin-gonic/gin"
+)
+
+// DemoSandbox ограничения демо-пользователя (ставится после Auth): только чтение и свой лимит
+// частоты limit. демо-сессию открывает кто угодно, поэтому лимит на адрес, а не на пользователя
+func DemoSandbox(demoEmail string, limit gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.EqualFold(GetEmail(c), demoEmail) {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			response.Fail(c, http.StatusForbidden, "demo_read_only", "demo account is read-only", nil)
+			return
+		}
+		limit(c)
+	}
+}
+
+// DemoForbidden закрывает эндпоинт для демо-пользователя целиком, в том числе на чтение:
+// демо-аккаунт общий, и его данные о сессиях видели бы все посетители
+func DemoForbidden(demoEmail string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.EqualFold(GetEmail(c), demoEmail) {
+			response.Fail(c, http.StatusForbidden, "demo_forbidden", "not available for the demo account", nil)
+			return
+		}
+		c.Next()
+	}
+}
@@ -2,18 +2,44 @@ package middleware
 
 import (
 	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/alligatorO15/fin-tracker/internal/api/response"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
 	"github.com/gin-gonic/gin"
 )
 
-func CORS() gin.HandlerFunc {
+// CORS отвечает браузеру заголовками CORS только для разрешенных источников; "*" - любой источник,
+// но без cookie. чужой Origin получает ответ без заголовков, и браузер не отдаст его странице
+func CORS(allowedOrigins []string) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAll = true
+			continue
+		}
+		allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*") // в проде указать на конкретный домен(фронт)
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, Authorization, X-Requested-With")
-		c.Header("Access-Control-Expose-Headers", "Content-Length")
-		c.Header("Access-Control-Allow-Credentials", "true")
+		if origin := c.GetHeader("Origin"); origin != "" {
+			c.Header("Vary", "Origin")
+			switch {
+			case allowed[strings.ToLower(origin)]:
+				c.Header("Access-Control-Allow-Origin", origin)
+				c.Header("Access-Control-Allow-Credentials", "true")
+			case allowAll:
+				c.Header("Access-Control-Allow-Origin", "*")
+			}
+			c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, Authorization, X-Requested-With, "+response.HeaderEnvelope)
+			c.Header("Access-Control-Expose-Headers", "Content-Length")
+		}
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -24,6 +50,21 @@ func CORS() gin.HandlerFunc {
 	}
 }
 
+// минимальный статус ответа, который попадает в лог запросов; меняется по SIGHUP
+var logMinStatus atomic.Int32
+
+// SetLogLevel уровень логов запросов: debug и info - все, warn - 4xx и 5xx, error - только 5xx
+func SetLogLevel(level string) {
+	switch level {
+	case "warn":
+		logMinStatus.Store(http.StatusBadRequest)
+	case "error":
+		logMinStatus.Store(http.StatusInternalServerError)
+	default:
+		logMinStatus.Store(0)
+	}
+}
+
 func RequestLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -32,6 +73,10 @@ func RequestLogger() gin.HandlerFunc {
 
 		c.Next()
 
+		if int32(c.Writer.Status()) < logMinStatus.Load() {
+			return
+		}
+
 		latency := time.Since(start)
 
 		if raw != "" {
@@ -47,3 +92,24 @@ func RequestLogger() gin.HandlerFunc {
 		)
 	}
 }
+
+// ResponseEnvelope выбирает формат ответов: enabled - значение по умолчанию для группы маршрутов,
+// клиент может переопределить его заголовком X-Response-Envelope
+func ResponseEnvelope(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		on := enabled
+		if v, err := strconv.ParseBool(c.GetHeader(response.HeaderEnvelope)); err == nil {
+			on = v
+		}
+		response.SetEnvelope(c, on)
+		c.Next()
+	}
+}
+
+// ReadReplica разрешает отдавать чтения запроса реплике бд (тяжелые отчеты и списки)
+func ReadReplica() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(repository.WithReadReplica(c.Request.Context()))
+		c.Next()
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alligatorO15/fin-tracker/internal/api/response"
+	"github.com/alligatorO15/fin-tracker/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RateLimitKey определяет, чей лимит расходует запрос
+type RateLimitKey func(c *gin.Context) string
+
+// ByIP лимит на адрес клиента
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser лимит на пользователя (ставится после Auth), без авторизации - на адрес
+func ByUser(c *gin.Context) string {
+	if userID := GetUserID(c); userID != uuid.Nil {
+		return "user:" + userID.String()
+	}
+	return ByIP(c)
+}
+
+// ByVisitor лимит на пользователя, но общий демо-аккаунт считается по адресу: его сессии - разные посетители
+func ByVisitor(demoEmail string) RateLimitKey {
+	return func(c *gin.Context) string {
+		if strings.EqualFold(GetEmail(c), demoEmail) {
+			return ByIP(c)
+		}
+		return ByUser(c)
+	}
+}
+
+// RateLimit ограничивает частоту запросов по правилу; scope разделяет корзины разных групп маршрутов.
+// правило берется на каждый запрос, чтобы его можно было поменять без перезапуска.
+// если хранилище лимитов недоступно, запрос пропускается
+func RateLimit(limiter ratelimit.Limiter, scope string, rule func() ratelimit.Rule, key RateLimitKey) gin.HandlerFunc {
+	if limiter == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		rule := rule()
+		if !rule.Enabled() {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), scope+":"+key(c), rule)
+		if err != nil {
+			log.Printf("Ошибка проверки лимита %s: %v", scope, err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rule.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			response.Fail(c, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded", nil)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,119 @@
+package response
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderEnvelope переключает формат ответа для одного запроса: "true" или "false"
+const HeaderEnvelope = "X-Response-Envelope"
+
+const envelopeKey = "response_envelope"
+
+// Envelope единый формат ответа: данные или ошибка, плюс метаданные списка
+type Envelope struct {
+	Data  interface{} `json:"data"`
+	Error *Error      `json:"error,omitempty"`
+	Meta  *Meta       `json:"meta,omitempty"`
+}
+
+// Error ошибка для клиента; Code стабилен между версиями, Message может меняться
+type Error struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+type Meta struct {
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination постраничная (total, page, total_pages) или курсорная (next_cursor) пагинация
+type Pagination struct {
+	Total      *int64 `json:"total,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	TotalPages int    `json:"total_pages,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// FieldError ошибка валидации одного поля запроса
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+// SetEnvelope выбирает формат ответа для запроса
+func SetEnvelope(c *gin.Context, on bool) {
+	c.Set(envelopeKey, on)
+}
+
+// Enveloped отвечать ли в конверте; без явного выбора - старый формат
+func Enveloped(c *gin.Context) bool {
+	return c.GetBool(envelopeKey)
+}
+
+// OK успешный ответ: в конверте - {"data": ...}, иначе данные как есть
+func OK(c *gin.Context, status int, data interface{}) {
+	if !Enveloped(c) {
+		c.JSON(status, data)
+		return
+	}
+	c.JSON(status, Envelope{Data: data})
+}
+
+// List страница списка; legacy - прежнее тело ответа, где пагинация лежит рядом с данными
+func List(c *gin.Context, legacy, items interface{}, pagination *Pagination) {
+	if !Enveloped(c) {
+		c.JSON(http.StatusOK, legacy)
+		return
+	}
+	c.JSON(http.StatusOK, Envelope{Data: items, Meta: &Meta{Pagination: pagination}})
+}
+
+// Fail прерывает обработку запроса с ошибкой. в старом формате к {"error": message}
+// добавляются только code и details, так что существующие клиенты ничего не замечают
+func Fail(c *gin.Context, status int, code, message string, details interface{}) {
+	if code == "" {
+		code = StatusCode(status)
+	}
+
+	if !Enveloped(c) {
+		body := gin.H{"error": message, "code": code}
+		if details != nil {
+			body["details"] = details
+		}
+		c.AbortWithStatusJSON(status, body)
+		return
+	}
+	c.AbortWithStatusJSON(status, Envelope{Error: &Error{Code: code, Message: message, Details: details}})
+}
+
+// StatusCode код ошибки по HTTP-статусу, когда точнее сказать нечего
+func StatusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusUnprocessableEntity:
+		return "unprocessable"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return "unavailable"
+	}
+	if status >= 500 {
+		return "internal_error"
+	}
+	return "error"
+}
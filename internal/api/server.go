@@ -1,17 +1,28 @@
 package api
 
 import (
+	"log"
+	"net/http"
+	"sync"
+
 	"github.com/alligatorO15/fin-tracker/internal/api/handlers"
 	"github.com/alligatorO15/fin-tracker/internal/api/middleware"
 	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/ratelimit"
 	"github.com/alligatorO15/fin-tracker/internal/service"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 type Server struct {
 	router   *gin.Engine
 	config   *config.Config
 	services *service.Services
+	limiter  ratelimit.Limiter
+
+	// правила лимитов по группам маршрутов; меняются по SIGHUP без перезапуска
+	rulesMu sync.RWMutex
+	rules   map[string]ratelimit.Rule
 }
 
 func NewServer(cfg *config.Config, services *service.Services) *Server {
@@ -19,66 +30,271 @@ func NewServer(cfg *config.Config, services *service.Services) *Server {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.Default()
+	// IP клиента (логи, лимиты, аудит) берем из заголовков только от своих прокси
+	router.RemoteIPHeaders = cfg.TrustedProxyHeaders
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("Некорректный TRUSTED_PROXIES, заголовкам прокси не доверяем: %v", err)
+		_ = router.SetTrustedProxies(nil)
+	}
 
 	server := &Server{
 		router:   router,
 		config:   cfg,
 		services: services,
+		limiter:  newLimiter(cfg),
 	}
+	server.applyRateLimits(cfg)
+	middleware.SetLogLevel(cfg.LogLevel)
 
 	server.setupRoutes()
 
 	return server
 }
 
+// ServeHTTP сервер как http.Handler: для тестов и встраивания за другим http.Server
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// Run запускает сервер; с настроенным TLS - сразу по https
 func (s *Server) Run(addr string) error {
+	switch {
+	case len(s.config.TLSAutocertDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(s.config.TLSAutocertDomains...),
+			Cache:      autocert.DirCache(s.config.TLSAutocertDir),
+			Email:      s.config.TLSAutocertEmail,
+		}
+		// проверка домена Let's Encrypt по http, остальные запросы уходят на https
+		go func() {
+			if err := http.ListenAndServe(":"+s.config.TLSHTTPPort, manager.HTTPHandler(nil)); err != nil {
+				log.Printf("HTTP-сервер для Let's Encrypt остановлен: %v", err)
+			}
+		}()
+		srv := &http.Server{Addr: addr, Handler: s.router, TLSConfig: manager.TLSConfig()}
+		return srv.ListenAndServeTLS("", "")
+
+	case s.config.TLSCertFile != "":
+		return s.router.RunTLS(addr, s.config.TLSCertFile, s.config.TLSKeyFile)
+	}
 	return s.router.Run(addr)
 }
 
+// newLimiter выбирает хранилище лимитов: Redis, если задан, иначе память процесса
+func newLimiter(cfg *config.Config) ratelimit.Limiter {
+	if !cfg.RateLimitEnabled {
+		return nil
+	}
+	if cfg.RateLimitRedisURL != "" {
+		limiter, err := ratelimit.NewRedisLimiter(cfg.RateLimitRedisURL)
+		if err == nil {
+			return limiter
+		}
+		log.Printf("%v, лимиты считаются в памяти процесса", err)
+	}
+	return ratelimit.NewMemoryLimiter()
+}
+
+// rateLimitSpecs правила лимитов из конфига по группам маршрутов
+func rateLimitSpecs(cfg *config.Config) map[string]string {
+	return map[string]string{
+		"ip":      cfg.RateLimitIP,
+		"auth":    cfg.RateLimitAuth,
+		"user":    cfg.RateLimitUser,
+		"market":  cfg.RateLimitMarket,
+		"webhook": cfg.RateLimitWebhook,
+		"demo":    cfg.RateLimitDemo,
+	}
+}
+
+// applyRateLimits разбирает правила лимитов; некорректное правило отключает ограничение группы
+func (s *Server) applyRateLimits(cfg *config.Config) {
+	rules := make(map[string]ratelimit.Rule)
+	for scope, spec := range rateLimitSpecs(cfg) {
+		rule, err := ratelimit.ParseRule(spec)
+		if err != nil {
+			log.Printf("Лимит %s отключен: %v", scope, err)
+		}
+		rules[scope] = rule
+	}
+
+	s.rulesMu.Lock()
+	s.rules = rules
+	s.rulesMu.Unlock()
+}
+
+// Reload применяет настройки, которые меняются без перезапуска: лимиты и уровень логов.
+// включение провайдеров рыночных данных применяется отдельно, в MultiProvider
+func (s *Server) Reload(cfg *config.Config) {
+	s.applyRateLimits(cfg)
+	middleware.SetLogLevel(cfg.LogLevel)
+}
+
+// rateLimit middleware для группы маршрутов; правило читается на каждый запрос
+func (s *Server) rateLimit(scope string, key middleware.RateLimitKey) gin.HandlerFunc {
+	return middleware.RateLimit(s.limiter, scope, func() ratelimit.Rule {
+		s.rulesMu.RLock()
+		defer s.rulesMu.RUnlock()
+		return s.rules[scope]
+	}, key)
+}
+
+// demoSandbox демо-пользователь только читает, и его запросы считаются отдельным лимитом по адресу
+func (s *Server) demoSandbox() gin.HandlerFunc {
+	return middleware.DemoSandbox(s.config.DemoEmail, s.rateLimit("demo", middleware.ByIP))
+}
+
+// userKey ключ лимитов авторизованных запросов; посетители демо-аккаунта не делят одну корзину
+func (s *Server) userKey() middleware.RateLimitKey {
+	if s.config.DemoMode {
+		return middleware.ByVisitor(s.config.DemoEmail)
+	}
+	return middleware.ByUser
+}
+
 func (s *Server) setupRoutes() {
 	//middleware
-	s.router.Use(middleware.CORS())
+	s.router.Use(middleware.CORS(s.config.CORSAllowedOrigins))
 	s.router.Use(middleware.RequestLogger())
 
-	// health check
+	// liveness: процесс отвечает; состояние провайдеров котировок только для сведения
 	s.router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
+		c.JSON(http.StatusOK, s.services.Health.Health(c.Request.Context()))
+	})
+
+	// readiness: бд и Redis (если лимиты в нем) доступны
+	var redis service.Pinger
+	if pinger, ok := s.limiter.(service.Pinger); ok {
+		redis = pinger
+	}
+	s.router.GET("/ready", func(c *gin.Context) {
+		status := s.services.Health.Ready(c.Request.Context(), redis)
+		if !status.Ready {
+			c.JSON(http.StatusServiceUnavailable, status)
+			return
+		}
+		c.JSON(http.StatusOK, status)
 	})
 
 	api := s.router.Group("/api/v1")
+	// v1 по умолчанию отвечает в прежнем формате, конверт включается настройкой или заголовком
+	api.Use(middleware.ResponseEnvelope(s.config.APIResponseEnvelope))
+	api.Use(s.rateLimit("ip", middleware.ByIP))
 
 	// подготавливаем хэндлеры
 	authHandler := handlers.NewAuthHandler(s.services.Auth, s.config)
 	userHandler := handlers.NewUserHandler(s.services.User)
 	accountHandler := handlers.NewAccountHandler(s.services.Account)
+	accountRuleHandler := handlers.NewAccountRuleHandler(s.services.AccountRule)
 	categoryHandler := handlers.NewCategoryHandler(s.services.Category)
 	transactionHandler := handlers.NewTransactionHandler(s.services.Transaction)
 	budgetHandler := handlers.NewBudgetHandler(s.services.Budget)
+	challengeHandler := handlers.NewChallengeHandler(s.services.Challenge)
+	dashboardHandler := handlers.NewDashboardHandler(s.services.Dashboard)
 	goalHandler := handlers.NewGoalHandler(s.services.Goal)
 	portfolioHandler := handlers.NewPortfolioHandler(s.services.Portfolio)
+	rebalanceHandler := handlers.NewRebalanceHandler(s.services.Rebalance)
+	drawdownHandler := handlers.NewDrawdownHandler(s.services.Drawdown)
+	holdingMetadataHandler := handlers.NewHoldingMetadataHandler(s.services.HoldingMeta)
+	iisHandler := handlers.NewIISHandler(s.services.IIS)
 	investmentHandler := handlers.NewInvestmentHandler(s.services.Investment)
 	analyticsHandler := handlers.NewAnalyticsHandler(s.services.Analytics)
+	adminHandler := handlers.NewAdminHandler(s.services.Sector, s.services.Currency, s.services.Investment)
+	systemHandler := handlers.NewSystemHandler(s.services.Health)
+	usageHandler := handlers.NewUsageHandler(s.services.Quota)
+	payeeHandler := handlers.NewPayeeHandler(s.services.Payee)
+	productHandler := handlers.NewProductHandler(s.services.Product)
+	tagHandler := handlers.NewTagHandler(s.services.Tag)
+	plannedHandler := handlers.NewPlannedTransactionHandler(s.services.Planned)
+	envelopeHandler := handlers.NewEnvelopeHandler(s.services.Envelope)
+	transferMatchHandler := handlers.NewTransferMatchHandler(s.services.TransferMatch)
+	duplicateHandler := handlers.NewDuplicateHandler(s.services.Duplicate)
+	mailImportHandler := handlers.NewMailImportHandler(s.services.MailImport)
+	receiptHandler := handlers.NewReceiptHandler(s.services.Receipt)
+	csvImportHandler := handlers.NewCSVImportHandler(s.services.CSVImport)
+	webhookHandler := handlers.NewWebhookHandler(s.services.Webhook)
+	reportHandler := handlers.NewReportSubscriptionHandler(s.services.Report)
+	customReportHandler := handlers.NewCustomReportHandler(s.services.CustomReport)
+	customAssetHandler := handlers.NewCustomAssetHandler(s.services.CustomAsset)
+	depositHandler := handlers.NewDepositHandler(s.services.Deposit)
+	notificationHandler := handlers.NewNotificationHandler(s.services.Notification)
+	telegramHandler := handlers.NewTelegramHandler(s.services.Telegram)
 
 	// эндпоинты аутентификации (публичные)
 	auth := api.Group("/auth")
+	auth.Use(s.rateLimit("auth", middleware.ByIP))
 	{
 		auth.POST("/register", authHandler.Register)
 		auth.POST("/login", authHandler.Login)
+		auth.POST("/unlock", authHandler.Unlock)
+		auth.POST("/delete/confirm", userHandler.ConfirmDelete)
+		auth.POST("/restore", userHandler.Restore)
 		auth.POST("/refresh", authHandler.Refresh)
 		auth.POST("/logout", authHandler.Logout)
+		auth.POST("/demo", authHandler.DemoLogin)
+	}
+
+	// входящие вебхуки банков и автоматизаций (публичные, доступ по секретному токену в адресе)
+	hooks := api.Group("/hooks")
+	hooks.Use(s.rateLimit("webhook", middleware.ByIP))
+	{
+		hooks.POST("/:token", webhookHandler.Receive)
 	}
 
+	// вебхук Telegram-бота (публичный, доступ по секрету в заголовке X-Telegram-Bot-Api-Secret-Token).
+	// все чаты приходят с нескольких адресов Telegram, поэтому лимит вебхуков по IP здесь не ставим
+	api.POST("/telegram/webhook", telegramHandler.Webhook)
+
 	// непублчиные эндпоинты
 	protected := api.Group("")
 	protected.Use(middleware.Auth(s.services.Auth))
+	protected.Use(s.rateLimit("user", s.userKey()))
+	if s.config.DemoMode {
+		protected.Use(s.demoSandbox())
+	}
+
+	// эндпоинты, которые ходят к внешним провайдерам котировок
+	marketLimit := s.rateLimit("market", s.userKey())
+	// сессии общего демо-аккаунта - это чужие посетители
+	noDemo := func(c *gin.Context) { c.Next() }
+	if s.config.DemoMode {
+		noDemo = middleware.DemoForbidden(s.config.DemoEmail)
+	}
+	// тяжелые отчеты и списки читают с реплики бд, если она настроена
+	readReplica := middleware.ReadReplica()
 	{
 		// auth (protected)
 		protected.POST("/auth/logout-all", authHandler.LogoutAll)
+		protected.GET("/auth/sessions", noDemo, authHandler.GetSessions)
+		protected.DELETE("/auth/sessions/:id", noDemo, authHandler.RevokeSession)
 
 		// user
 		protected.GET("/user", userHandler.GetCurrent)
 		protected.PUT("/user", userHandler.Update)
 		protected.DELETE("/user", userHandler.Delete)
+		protected.PUT("/user/profile", userHandler.UpdateProfile)
+		protected.GET("/user/avatar", userHandler.GetAvatar)
+		protected.DELETE("/user/avatar", userHandler.DeleteAvatar)
+		protected.GET("/user/usage", usageHandler.Get)
+
+		// главный экран одним запросом
+		protected.GET("/dashboard", dashboardHandler.Get)
+
+		// входящие уведомления
+		notifications := protected.Group("/notifications")
+		{
+			notifications.GET("", notificationHandler.List)
+			notifications.GET("/unread-count", notificationHandler.UnreadCount)
+			notifications.POST("/read", notificationHandler.MarkRead)
+			notifications.POST("/:id/read", notificationHandler.MarkOneRead)
+			notifications.DELETE("/:id", notificationHandler.Delete)
+		}
+
+		// telegram
+		protected.GET("/telegram/link", telegramHandler.GetLink)
+		protected.POST("/telegram/link", telegramHandler.CreateLink)
+		protected.DELETE("/telegram/link", telegramHandler.Unlink)
 
 		// accounts
 		accounts := protected.Group("/accounts")
@@ -86,9 +302,14 @@ func (s *Server) setupRoutes() {
 			accounts.POST("", accountHandler.Create)
 			accounts.GET("", accountHandler.List)
 			accounts.GET("/summary", accountHandler.GetSummary)
+			accounts.PATCH("/order", accountHandler.SetOrder)
 			accounts.GET("/:id", accountHandler.GetByID)
 			accounts.PUT("/:id", accountHandler.Update)
 			accounts.DELETE("/:id", accountHandler.Delete)
+			accounts.GET("/:id/rules", accountRuleHandler.List)
+			accounts.POST("/:id/rules", accountRuleHandler.Create)
+			accounts.PUT("/:id/rules/:ruleId", accountRuleHandler.Update)
+			accounts.DELETE("/:id/rules/:ruleId", accountRuleHandler.Delete)
 		}
 
 		// categories
@@ -98,6 +319,7 @@ func (s *Server) setupRoutes() {
 			categories.GET("", categoryHandler.List)
 			categories.GET("/:id", categoryHandler.GetByID)
 			categories.PUT("/:id", categoryHandler.Update)
+			categories.PUT("/:id/fixed", categoryHandler.SetFixed)
 			categories.DELETE("/:id", categoryHandler.Delete)
 		}
 
@@ -105,12 +327,145 @@ func (s *Server) setupRoutes() {
 		transactions := protected.Group("/transactions")
 		{
 			transactions.POST("", transactionHandler.Create)
-			transactions.GET("", transactionHandler.List)
+			transactions.GET("", readReplica, transactionHandler.List)
+			// покупка по QR-коду кассового чека (строка или фото), с позициями чека
+			transactions.POST("/from-receipt", receiptHandler.Import)
+			// пары расход/доход между своими счетами, которые на самом деле один перевод
+			transactions.GET("/transfer-matches", transferMatchHandler.List)
+			transactions.POST("/transfer-matches/confirm", transferMatchHandler.Confirm)
+			// одна операция, загруженная из нескольких источников
+			transactions.GET("/duplicates", duplicateHandler.List)
+			transactions.POST("/merge", duplicateHandler.Merge)
 			transactions.GET("/:id", transactionHandler.GetByID)
 			transactions.PUT("/:id", transactionHandler.Update)
 			transactions.DELETE("/:id", transactionHandler.Delete)
 		}
 
+		// payees
+		payees := protected.Group("/payees")
+		{
+			payees.GET("", payeeHandler.List)
+			payees.GET("/:id/stats", payeeHandler.GetStats)
+			payees.PUT("/:id", payeeHandler.Rename)
+			payees.POST("/:id/merge", payeeHandler.Merge)
+		}
+
+		// products (каталог позиций чеков)
+		products := protected.Group("/products")
+		{
+			products.GET("", productHandler.List)
+			products.GET("/spending", readReplica, productHandler.GetSpending)
+			products.GET("/:id/prices", readReplica, productHandler.GetPriceHistory)
+			products.PUT("/:id", productHandler.Rename)
+			products.POST("/:id/merge", productHandler.Merge)
+		}
+
+		// tags
+		tags := protected.Group("/tags")
+		{
+			tags.GET("", tagHandler.List)
+			tags.GET("/spending", readReplica, tagHandler.GetSpending)
+			tags.POST("/rename", tagHandler.Rename)
+			tags.POST("/merge", tagHandler.Merge)
+		}
+
+		// planned transactions
+		planned := protected.Group("/planned-transactions")
+		{
+			planned.POST("", plannedHandler.Create)
+			planned.GET("", plannedHandler.List)
+			planned.GET("/:id", plannedHandler.GetByID)
+			planned.PUT("/:id", plannedHandler.Update)
+			planned.DELETE("/:id", plannedHandler.Delete)
+			planned.POST("/:id/confirm", plannedHandler.Confirm)
+			planned.POST("/:id/cancel", plannedHandler.Cancel)
+		}
+
+		// почтовые ящики с уведомлениями банков и черновики транзакций из них
+		mail := protected.Group("/mail-connections")
+		{
+			mail.POST("", mailImportHandler.CreateConnection)
+			mail.GET("", mailImportHandler.ListConnections)
+			mail.PUT("/:id", mailImportHandler.UpdateConnection)
+			mail.DELETE("/:id", mailImportHandler.DeleteConnection)
+			mail.POST("/:id/poll", mailImportHandler.Poll)
+		}
+		drafts := protected.Group("/transaction-drafts")
+		{
+			drafts.GET("", mailImportHandler.ListDrafts)
+			drafts.POST("/:id/confirm", mailImportHandler.ConfirmDraft)
+			drafts.POST("/:id/reject", mailImportHandler.RejectDraft)
+		}
+
+		// импорт выписок банков в CSV: загрузка, разбор колонок, профили сопоставления
+		csvImport := protected.Group("/imports/csv")
+		{
+			csvImport.POST("", csvImportHandler.Upload)
+			csvImport.GET("/profiles", csvImportHandler.ListProfiles)
+			csvImport.POST("/profiles", csvImportHandler.CreateProfile)
+			csvImport.PUT("/profiles/:id", csvImportHandler.UpdateProfile)
+			csvImport.DELETE("/profiles/:id", csvImportHandler.DeleteProfile)
+			csvImport.GET("/:id", csvImportHandler.Detect)
+			csvImport.POST("/:id/import", csvImportHandler.Import)
+		}
+
+		// вебхуки для уведомлений об операциях
+		webhooks := protected.Group("/webhooks")
+		{
+			webhooks.POST("", webhookHandler.Create)
+			webhooks.GET("", webhookHandler.List)
+			webhooks.PUT("/:id", webhookHandler.Update)
+			webhooks.DELETE("/:id", webhookHandler.Delete)
+			webhooks.POST("/:id/rotate", webhookHandler.RotateToken)
+		}
+
+		// отчеты по почте
+		reports := protected.Group("/report-subscriptions")
+		{
+			reports.POST("", reportHandler.Create)
+			reports.GET("", reportHandler.List)
+			reports.PUT("/:id", reportHandler.Update)
+			reports.DELETE("/:id", reportHandler.Delete)
+			reports.POST("/:id/send", reportHandler.SendNow)
+		}
+
+		// пользовательские отчеты: сводные таблицы по категориям, счетам, меткам и месяцам
+		customReports := protected.Group("/reports")
+		{
+			customReports.POST("", customReportHandler.Create)
+			customReports.GET("", customReportHandler.List)
+			customReports.POST("/preview", readReplica, customReportHandler.Preview)
+			customReports.GET("/:id", customReportHandler.GetByID)
+			customReports.PUT("/:id", customReportHandler.Update)
+			customReports.DELETE("/:id", customReportHandler.Delete)
+			customReports.GET("/:id/run", readReplica, customReportHandler.Run)
+		}
+
+		// активы с ручной оценкой: недвижимость, транспорт, займы
+		assets := protected.Group("/assets")
+		{
+			assets.GET("/classes", customAssetHandler.GetClasses)
+			assets.POST("", customAssetHandler.Create)
+			assets.GET("", customAssetHandler.List)
+			assets.GET("/:id", customAssetHandler.Get)
+			assets.PUT("/:id", customAssetHandler.Update)
+			assets.DELETE("/:id", customAssetHandler.Delete)
+			assets.POST("/:id/valuations", customAssetHandler.AddValuation)
+			assets.GET("/:id/valuations", customAssetHandler.GetValuations)
+			assets.DELETE("/:id/valuations/:valuationId", customAssetHandler.DeleteValuation)
+		}
+
+		// банковские вклады и сравнение предложений
+		deposits := protected.Group("/deposits")
+		{
+			deposits.POST("/compare", depositHandler.Compare)
+			deposits.POST("", depositHandler.Create)
+			deposits.GET("", depositHandler.List)
+			deposits.GET("/:id", depositHandler.Get)
+			deposits.PUT("/:id", depositHandler.Update)
+			deposits.DELETE("/:id", depositHandler.Delete)
+		}
+
 		// budgets
 		budgets := protected.Group("/budgets")
 		{
@@ -118,11 +473,35 @@ func (s *Server) setupRoutes() {
 			budgets.GET("", budgetHandler.List)
 			budgets.GET("/summary", budgetHandler.GetSummary)
 			budgets.GET("/alerts", budgetHandler.GetAlerts)
+			budgets.POST("/suggest", budgetHandler.Suggest)
+			budgets.GET("/history", readReplica, budgetHandler.GetHistoryReport)
 			budgets.GET("/:id", budgetHandler.GetByID)
+			budgets.GET("/:id/history", budgetHandler.GetHistory)
 			budgets.PUT("/:id", budgetHandler.Update)
 			budgets.DELETE("/:id", budgetHandler.Delete)
 		}
 
+		// челленджи по расходам и серии
+		challenges := protected.Group("/challenges")
+		{
+			challenges.GET("", challengeHandler.List)
+			challenges.POST("", challengeHandler.Create)
+			challenges.GET("/:id", challengeHandler.GetByID)
+			challenges.PUT("/:id", challengeHandler.Update)
+			challenges.DELETE("/:id", challengeHandler.Delete)
+		}
+
+		// envelopes (бюджетирование с нуля)
+		envelopes := protected.Group("/envelopes")
+		{
+			envelopes.POST("", envelopeHandler.Create)
+			envelopes.GET("", envelopeHandler.GetMonth)
+			envelopes.POST("/move", envelopeHandler.Move)
+			envelopes.DELETE("/:id", envelopeHandler.Archive)
+			envelopes.POST("/:id/allocate", envelopeHandler.Allocate)
+			envelopes.GET("/:id/history", envelopeHandler.GetHistory)
+		}
+
 		// goals
 		goals := protected.Group("/goals")
 		{
@@ -133,6 +512,7 @@ func (s *Server) setupRoutes() {
 			goals.DELETE("/:id", goalHandler.Delete)
 			goals.POST("/:id/contributions", goalHandler.AddContribution)
 			goals.GET("/:id/contributions", goalHandler.GetContributions)
+			goals.GET("/:id/projection", marketLimit, goalHandler.Project)
 		}
 
 		// investment portfolios
@@ -140,37 +520,122 @@ func (s *Server) setupRoutes() {
 		{
 			portfolios.POST("", portfolioHandler.Create)
 			portfolios.GET("", portfolioHandler.List)
+			portfolios.GET("/summary", portfolioHandler.GetSummary)
 			portfolios.GET("/:id", portfolioHandler.GetByID)
 			portfolios.GET("/:id/holdings", portfolioHandler.GetHoldings)
+			portfolios.PUT("/:id/holdings/:securityId/metadata", holdingMetadataHandler.Set)
+			portfolios.DELETE("/:id/holdings/:securityId/metadata", holdingMetadataHandler.Delete)
 			portfolios.PUT("/:id", portfolioHandler.Update)
 			portfolios.DELETE("/:id", portfolioHandler.Delete)
-			portfolios.POST("/:id/refresh", portfolioHandler.RefreshPrices)
+			portfolios.POST("/:id/refresh", marketLimit, portfolioHandler.RefreshPrices)
+			portfolios.GET("/:id/refresh/:jobId", portfolioHandler.GetRefreshJob)
+			// целевые доли и уведомления об отклонении от них
+			portfolios.GET("/:id/targets", rebalanceHandler.GetTargets)
+			portfolios.PUT("/:id/targets", rebalanceHandler.SetTargets)
+			portfolios.GET("/:id/rebalance", marketLimit, rebalanceHandler.GetReport)
+			// уведомления о просадке от максимума за 30 дней и о падении за день
+			portfolios.GET("/:id/drawdown-alerts", marketLimit, drawdownHandler.Get)
+			portfolios.PUT("/:id/drawdown-alerts", marketLimit, drawdownHandler.Set)
+			// ИИС: взносы относительно годового лимита, ожидаемый вычет, досрочный вывод
+			portfolios.GET("/:id/iis", iisHandler.GetSummary)
+			portfolios.POST("/:id/iis/cash-flows", iisHandler.AddCashFlow)
+			portfolios.DELETE("/:id/iis/cash-flows/:flowId", iisHandler.DeleteCashFlow)
 		}
 
 		// investment operations
 		investments := protected.Group("/investments")
 		{
-			investments.GET("/securities/search", investmentHandler.SearchSecurities)
+			investments.GET("/securities/search", marketLimit, investmentHandler.SearchSecurities)
+			investments.GET("/screener", readReplica, investmentHandler.Screener)
+			investments.POST("/securities/manual", investmentHandler.CreateManualSecurity)
+			investments.GET("/securities/manual", investmentHandler.GetManualSecurities)
+			investments.GET("/securities/preferences", investmentHandler.GetSecurityPreferences)
+			investments.PUT("/securities/preferences", investmentHandler.UpdateSecurityPreferences)
 			investments.GET("/securities/:id", investmentHandler.GetSecurity)
-			investments.GET("/securities/quote/:ticker", investmentHandler.GetQuote)
+			investments.POST("/securities/:id/prices", investmentHandler.AddManualPrice)
+			investments.GET("/securities/:id/history", marketLimit, investmentHandler.GetHistory)
+			investments.POST("/securities/:id/backfill", marketLimit, investmentHandler.BackfillHistory)
+			investments.GET("/securities/quote/:ticker", marketLimit, investmentHandler.GetQuote)
 			investments.POST("/transactions", investmentHandler.AddTransaction)
-			investments.GET("/portfolios/:id/transactions", investmentHandler.GetTransactions)
+			investments.POST("/portfolios/:id/transactions/batch", investmentHandler.ImportTransactions)
+			investments.GET("/portfolios/:id/transactions", readReplica, investmentHandler.GetTransactions)
 			investments.DELETE("/transactions/:id", investmentHandler.DeleteTransaction)
-			investments.GET("/portfolios/:id/analytics", investmentHandler.GetAnalytics)
-			investments.GET("/portfolios/:id/tax-report", investmentHandler.GetTaxReport)
+			investments.POST("/transactions/:id/restore", investmentHandler.RestoreTransaction)
+			investments.GET("/portfolios/:id/analytics", readReplica, investmentHandler.GetAnalytics)
+			investments.GET("/portfolios/:id/tax-report", readReplica, investmentHandler.GetTaxReport)
+			investments.GET("/portfolios/:id/benchmark", investmentHandler.GetBenchmark)
+			investments.GET("/portfolios/:id/attribution", marketLimit, investmentHandler.GetAttribution)
 			investments.GET("/portfolios/:id/dividends", investmentHandler.GetDividends)
+			investments.GET("/portfolios/:id/coupons", investmentHandler.GetCoupons)
+			investments.GET("/portfolios/:id/income-calendar", investmentHandler.GetIncomeCalendar)
+			investments.GET("/portfolios/:id/income", readReplica, investmentHandler.GetIncomeReport)
 		}
 
+		// генерация рекомендаций пишет в бд, поэтому вне группы с чтением с реплики
+		protected.POST("/analytics/recommendations/refresh", analyticsHandler.RefreshRecommendations)
+
 		// analytics
 		analytics := protected.Group("/analytics")
+		analytics.Use(readReplica)
 		{
 			analytics.GET("/summary", analyticsHandler.GetSummary)
 			analytics.GET("/cashflow", analyticsHandler.GetCashFlow)
 			analytics.GET("/trends", analyticsHandler.GetSpendingTrends)
 			analytics.GET("/networth", analyticsHandler.GetNetWorth)
 			analytics.GET("/health", analyticsHandler.GetFinancialHealth)
+			analytics.GET("/health/history", analyticsHandler.GetFinancialHealthHistory)
 			analytics.GET("/recommendations", analyticsHandler.GetRecommendations)
+			analytics.GET("/recommendations/status", analyticsHandler.GetRecommendationsState)
+			analytics.GET("/forecast", analyticsHandler.GetForecast)
+			analytics.GET("/fire", analyticsHandler.GetFire)
+			analytics.GET("/anomalies", analyticsHandler.GetAnomalies)
+			analytics.GET("/patterns", analyticsHandler.GetPatterns)
+			analytics.GET("/map", analyticsHandler.GetMap)
+			analytics.GET("/ai-summary", analyticsHandler.GetAISummary)
+			analytics.GET("/suggest-category", analyticsHandler.SuggestCategory)
+		}
+
+		// администрирование (доступ по ADMIN_USER_IDS)
+		admin := protected.Group("/admin")
+		admin.Use(middleware.AdminOnly(s.config.AdminUserIDs))
+		{
+			admin.GET("/sectors", adminHandler.ListSectorMappings)
+			admin.PUT("/sectors", adminHandler.UpsertSectorMapping)
+			admin.DELETE("/sectors/:exchange/:ticker", adminHandler.DeleteSectorMapping)
+			admin.POST("/sectors/backfill", adminHandler.BackfillSectors)
+			admin.GET("/security-types", adminHandler.ListSecurityTypes)
+			admin.PUT("/security-types", adminHandler.UpsertSecurityType)
+			admin.DELETE("/security-types/:exchange/:ticker", adminHandler.DeleteSecurityType)
+			admin.GET("/redenominations", adminHandler.ListRedenominations)
+			admin.POST("/redenominations", adminHandler.CreateRedenomination)
+			admin.DELETE("/redenominations/:id", adminHandler.DeleteRedenomination)
+			admin.GET("/securities/duplicates", adminHandler.ListSecurityDuplicates)
+			admin.POST("/securities/merge", adminHandler.MergeSecurities)
+		}
+
+		// диагностика сервера (доступ по ADMIN_USER_IDS)
+		system := protected.Group("/system")
+		system.Use(middleware.AdminOnly(s.config.AdminUserIDs))
+		{
+			system.GET("/providers", systemHandler.GetProviders)
 		}
 
 	}
+
+	// v2: конверт ответа по умолчанию и курсорная пагинация списков; лимиты общие с v1
+	v2 := s.router.Group("/api/v2")
+	v2.Use(middleware.ResponseEnvelope(true))
+	v2.Use(s.rateLimit("ip", middleware.ByIP))
+
+	protectedV2 := v2.Group("")
+	protectedV2.Use(middleware.Auth(s.services.Auth))
+	protectedV2.Use(s.rateLimit("user", s.userKey()))
+	if s.config.DemoMode {
+		protectedV2.Use(s.demoSandbox())
+	}
+	protectedV2.Use(middleware.ReadReplica())
+	{
+		protectedV2.GET("/transactions", transactionHandler.ListPage)
+		protectedV2.GET("/investments/portfolios/:id/transactions", investmentHandler.GetTransactionsPage)
+	}
 }
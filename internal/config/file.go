@@ -0,0 +1,242 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alligatorO15/fin-tracker/internal/ratelimit"
+	"github.com/goccy/go-yaml"
+	"github.com/google/uuid"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// FileEnv переменная окружения с путем к файлу конфигурации
+const FileEnv = "CONFIG_FILE"
+
+// defaultJWTSecret секрет для разработки; в production сервер с ним не запустится
+const defaultJWTSecret = "jwtсекретлол"
+
+// reloadableKeys настройки, которые применяются по SIGHUP без перезапуска
+var reloadableKeys = map[string]bool{
+	"MOEX_ENABLED":       true,
+	"FOREIGN_ENABLED":    true,
+	"RATE_LIMIT_IP":      true,
+	"RATE_LIMIT_AUTH":    true,
+	"RATE_LIMIT_USER":    true,
+	"RATE_LIMIT_MARKET":  true,
+	"RATE_LIMIT_WEBHOOK": true,
+	"RATE_LIMIT_DEMO":    true,
+	"LOG_LEVEL":          true,
+}
+
+// ValidationError все проблемы конфигурации разом, чтобы не исправлять их по одной за запуск
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "некорректная конфигурация:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// loader читает ключи из окружения и файла и копит ошибки вместо того, чтобы молча брать значение по умолчанию.
+// ключи файла те же, что у переменных окружения, в любом регистре; вложенные секции склеиваются
+// через "_": rate_limit: {user: 300/m} - это RATE_LIMIT_USER
+type loader struct {
+	path     string
+	file     map[string]string // ключ -> значение из файла
+	names    map[string]string // ключ -> как он записан в файле, для сообщений об ошибках
+	values   map[string]string // все прочитанные ключи с итоговыми значениями
+	problems []string
+}
+
+func newLoader(path string) (*loader, error) {
+	l := &loader{
+		path:   path,
+		file:   make(map[string]string),
+		names:  make(map[string]string),
+		values: make(map[string]string),
+	}
+	if path == "" {
+		return l, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл конфигурации: %w", err)
+	}
+
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("файл конфигурации %s: поддерживаются .yaml, .yml и .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("файл конфигурации %s: %w", path, err)
+	}
+
+	l.flatten("", "", raw)
+	return l, nil
+}
+
+// flatten раскладывает секции файла в плоские ключи; списки склеиваются через запятую, как в окружении
+func (l *loader) flatten(prefix, name string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			key := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(k))
+			if prefix != "" {
+				key = prefix + "_" + key
+			}
+			itemName := k
+			if name != "" {
+				itemName = name + "." + k
+			}
+			l.flatten(key, itemName, item)
+		}
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		l.file[prefix], l.names[prefix] = strings.Join(items, ","), name
+	case nil:
+		l.file[prefix], l.names[prefix] = "", name
+	default:
+		l.file[prefix], l.names[prefix] = fmt.Sprint(v), name
+	}
+}
+
+// lookup значение ключа: окружение, затем файл; ok=false - ключ нигде не задан
+func (l *loader) lookup(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	value, ok := l.file[key]
+	return value, ok
+}
+
+// where откуда взято значение, для сообщений об ошибках
+func (l *loader) where(key string) string {
+	if _, ok := os.LookupEnv(key); ok {
+		return key
+	}
+	return fmt.Sprintf("%s (%s в %s)", key, l.names[key], l.path)
+}
+
+func (l *loader) invalid(key, expected, value string) {
+	l.problems = append(l.problems, fmt.Sprintf("%s: ожидается %s, получено %q", l.where(key), expected, value))
+}
+
+func (l *loader) str(key, defaultValue string) string {
+	value, ok := l.lookup(key)
+	if !ok {
+		value = defaultValue
+	}
+	l.values[key] = value
+	return value
+}
+
+func (l *loader) int(key string, defaultValue int) int {
+	raw, ok := l.lookup(key)
+	if !ok {
+		l.values[key] = strconv.Itoa(defaultValue)
+		return defaultValue
+	}
+	l.values[key] = raw
+	value, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		l.invalid(key, "целое число", raw)
+		return defaultValue
+	}
+	return value
+}
+
+func (l *loader) bool(key string, defaultValue bool) bool {
+	raw, ok := l.lookup(key)
+	if !ok {
+		l.values[key] = strconv.FormatBool(defaultValue)
+		return defaultValue
+	}
+	l.values[key] = raw
+	value, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		l.invalid(key, "true или false", raw)
+		return defaultValue
+	}
+	return value
+}
+
+// oneOf строка из фиксированного набора без учета регистра
+func (l *loader) oneOf(key, defaultValue string, allowed ...string) string {
+	value := strings.ToLower(l.str(key, defaultValue))
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	l.invalid(key, strings.Join(allowed, ", "), value)
+	return defaultValue
+}
+
+// uuids список идентификаторов через запятую
+func (l *loader) uuids(key string) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, item := range splitList(l.str(key, "")) {
+		id, err := uuid.Parse(item)
+		if err != nil {
+			l.invalid(key, "UUID через запятую", item)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// rule правило лимита вида "120/m"; сам разбор остается за сервером, здесь только проверка
+func (l *loader) rule(key, defaultValue string) string {
+	value := l.str(key, defaultValue)
+	if _, err := ratelimit.ParseRule(value); err != nil {
+		l.invalid(key, "правило N/период (например, 120/m) или 0", value)
+		return defaultValue
+	}
+	return value
+}
+
+// validate неизвестные ключи файла и обязательные для production настройки
+func (l *loader) validate(cfg *Config) error {
+	for key := range l.file {
+		if _, known := l.values[key]; !known {
+			l.problems = append(l.problems, fmt.Sprintf("%s: неизвестный ключ в %s", l.names[key], l.path))
+		}
+	}
+
+	if cfg.Env == "production" && (cfg.JWTSecret == "" || cfg.JWTSecret == defaultJWTSecret) {
+		l.problems = append(l.problems, "JWT_SECRET: обязателен в production")
+	}
+
+	if len(l.problems) == 0 {
+		return nil
+	}
+	sort.Strings(l.problems)
+	return &ValidationError{Problems: l.problems}
+}
+
+// RestartRequired ключи, которые в next отличаются от текущих, но применятся только после перезапуска
+func (c *Config) RestartRequired(next *Config) []string {
+	var keys []string
+	for key, value := range next.values {
+		if !reloadableKeys[key] && c.values[key] != value {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -2,44 +2,312 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// migrations выполняются по порядку при каждом запуске, поэтому все идемпотентны.
+// номер миграции (версия) - ее позиция в списке, начиная с 1; новые только дописываются в конец
+var migrations = []string{
+	migrationCreateExtensions,
+	migrationCreateUsers,
+	migrationCreateRefreshTokens,
+	migrationCreateAccounts,
+	migrationCreateCategories,
+	migrationCreateTransactions,
+	migrationCreateBudgets,
+	migrationCreateGoals,
+	migrationCreateSecurities,
+	migrationCreatePortfolios,
+	migrationCreateHoldings,
+	migrationCreateInvestmentTransactions,
+	migrationCreateIndexes,
+	migrationInsertDefaultCategories,
+	migrationRefreshTokenFamilies,
+	migrationCreateAccountDeletions,
+	migrationCreateSectorMappings,
+	migrationGoalPortfolioLink,
+	migrationCreatePayees,
+	migrationCreatePlannedTransactions,
+	migrationCreatePriceHistory,
+	migrationCreateEnvelopes,
+	migrationUserAISettings,
+	migrationSecurityDerivatives,
+	migrationCreateMailImport,
+	migrationCreateReceipts,
+	migrationCreateWebhooks,
+	migrationCreateReportSubscriptions,
+	migrationUserInvestmentIncome,
+	migrationCreateCustomAssets,
+	migrationCreatePortfolioTargets,
+	migrationCreateUserUsage,
+	migrationCreateHoldingMetadata,
+	migrationCreateDeposits,
+	migrationCursorPaginationIndexes,
+	migrationCreateNotifications,
+	migrationCategoryFixedCosts,
+	migrationPortfolioIIS,
+	migrationCreateProducts,
+	migrationBudgetScope,
+	migrationCreateHealthSnapshots,
+	migrationManualSecurities,
+	migrationBondAccruedInterest,
+	migrationUserLocale,
+	migrationUserProfile,
+	migrationSecurityPreferences,
+	migrationTransactionGeo,
+	migrationTelegramLinks,
+	migrationCurrencyRedenominations,
+	migrationPortfolioDrawdownAlerts,
+	migrationSecurityFundamentals,
+	migrationInvestmentTransactionSoftDelete,
+	migrationAccountRules,
+	migrationReportDefinitions,
+	migrationPasswordHashVersion,
+	migrationLoginAttempts,
+	migrationCSVImport,
+	migrationChallenges,
+	migrationCategoryIconRepair,
+	migrationAccountOrder,
+	migrationSecurityTypeOverrides,
+	migrationBudgetAlertThresholds,
+	migrationPlannedAutoPostIndex,
+	migrationRefreshTokenRevokedReason,
+	migrationSecuritySectorChecked,
+	migrationUserIsDemo,
+	migrationSchedulerJobs,
+	migrationAIRecommendations,
+	migrationUserAnomalyAlerts,
+	migrationAccountDeletionConfirm,
+	migrationPayeeAliases,
+	migrationTelegramUpdates,
+}
+
+// migrationRollbacks SQL отката по версии миграции. откатить можно только последние
+// миграции подряд, пока не встретится миграция без отката
+var migrationRollbacks = map[int]string{
+	34: `DROP TABLE IF EXISTS deposits;`,
+	35: `
+DROP INDEX IF EXISTS idx_transactions_user_date_id;
+DROP INDEX IF EXISTS idx_investment_transactions_portfolio_date_id;
+`,
+	36: `DROP TABLE IF EXISTS notifications;`,
+	37: `
+DROP TABLE IF EXISTS category_preferences;
+ALTER TABLE categories DROP COLUMN IF EXISTS is_fixed;
+`,
+	38: `
+DROP TABLE IF EXISTS iis_cash_flows;
+ALTER TABLE portfolios DROP COLUMN IF EXISTS iis_type, DROP COLUMN IF EXISTS iis_opened_at;
+`,
+	39: `
+ALTER TABLE transaction_items DROP COLUMN IF EXISTS product_id;
+DROP TABLE IF EXISTS products;
+`,
+	40: `ALTER TABLE budgets DROP COLUMN IF EXISTS account_id, DROP COLUMN IF EXISTS payee_id;`,
+	41: `DROP TABLE IF EXISTS financial_health_snapshots;`,
+	42: `
+DELETE FROM securities WHERE owner_id IS NOT NULL;
+DROP INDEX IF EXISTS idx_securities_owner_ticker;
+DROP INDEX IF EXISTS idx_securities_ticker_exchange;
+ALTER TABLE securities DROP CONSTRAINT IF EXISTS securities_ticker_exchange_key;
+ALTER TABLE securities ADD CONSTRAINT securities_ticker_exchange_key UNIQUE (ticker, exchange);
+ALTER TABLE securities DROP COLUMN IF EXISTS owner_id;
+`,
+	43: `
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'securities' AND column_name = 'accrued_interest') THEN
+        UPDATE securities SET last_price = last_price * 100 / face_value, price_change = price_change * 100 / face_value
+        WHERE type = 'bond' AND exchange = 'MOEX' AND face_value > 0;
+        UPDATE price_history ph
+        SET open = ph.open * 100 / s.face_value, high = ph.high * 100 / s.face_value,
+            low = ph.low * 100 / s.face_value, close = ph.close * 100 / s.face_value
+        FROM securities s
+        WHERE s.id = ph.security_id AND s.type = 'bond' AND s.exchange = 'MOEX' AND s.face_value > 0;
+        ALTER TABLE securities DROP COLUMN accrued_interest;
+    END IF;
+END $$;
+`,
+	44: `ALTER TABLE users DROP COLUMN IF EXISTS locale;`,
+	45: `
+ALTER TABLE users DROP COLUMN IF EXISTS phone;
+ALTER TABLE users DROP COLUMN IF EXISTS birth_date;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_key;
+`,
+	46: `DROP TABLE IF EXISTS security_preferences;`,
+	47: `
+DROP INDEX IF EXISTS idx_transactions_geohash;
+ALTER TABLE transactions DROP COLUMN IF EXISTS latitude;
+ALTER TABLE transactions DROP COLUMN IF EXISTS longitude;
+ALTER TABLE transactions DROP COLUMN IF EXISTS geohash;
+ALTER TABLE transactions DROP COLUMN IF EXISTS city;
+`,
+	48: `DROP TABLE IF EXISTS telegram_links;`,
+	49: `DROP TABLE IF EXISTS currency_redenominations;`,
+	50: `DROP TABLE IF EXISTS portfolio_drawdown_alerts; DROP TABLE IF EXISTS portfolio_value_snapshots;`,
+	51: `DROP TABLE IF EXISTS security_dividends; DROP TABLE IF EXISTS security_fundamentals;`,
+	52: `
+DROP TABLE IF EXISTS holding_rebuilds;
+DROP INDEX IF EXISTS idx_investment_transactions_broker_ref;
+DELETE FROM investment_transactions WHERE deleted_at IS NOT NULL;
+ALTER TABLE investment_transactions DROP COLUMN IF EXISTS deleted_at;
+`,
+	53: `DROP TABLE IF EXISTS account_rule_runs; DROP TABLE IF EXISTS account_rules;`,
+	54: `DROP TABLE IF EXISTS report_definitions;`,
+	56: `DROP TABLE IF EXISTS login_attempts;`,
+	57: `
+DROP TABLE IF EXISTS csv_uploads;
+DROP TABLE IF EXISTS csv_import_profiles;
+`,
+	58: `DROP TABLE IF EXISTS challenge_results; DROP TABLE IF EXISTS challenges;`,
+	59: `
+ALTER TABLE categories ALTER COLUMN icon TYPE VARCHAR(10) USING LEFT(icon, 10);
+ALTER TABLE accounts ALTER COLUMN icon TYPE VARCHAR(10) USING LEFT(icon, 10);
+ALTER TABLE goals ALTER COLUMN icon TYPE VARCHAR(10) USING LEFT(icon, 10);
+`,
+	60: `
+DROP TABLE IF EXISTS account_preferences;
+ALTER TABLE accounts DROP COLUMN IF EXISTS account_group;
+ALTER TABLE accounts DROP COLUMN IF EXISTS sort_order;
+`,
+	61: `DROP TABLE IF EXISTS security_type_overrides;`,
+	62: `
+DROP TABLE IF EXISTS budget_alert_state;
+ALTER TABLE budgets DROP COLUMN IF EXISTS alert_thresholds;
+`,
+	63: `DROP INDEX IF EXISTS idx_planned_transactions_auto_due;`,
+	64: `ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS revoked_reason;`,
+	65: `ALTER TABLE securities DROP COLUMN IF EXISTS sector_checked_at;`,
+	66: `ALTER TABLE users DROP COLUMN IF EXISTS is_demo;`,
+	67: `DROP TABLE IF EXISTS scheduler_jobs;`,
+	68: `DROP TABLE IF EXISTS ai_recommendations;`,
+	69: `ALTER TABLE users DROP COLUMN IF EXISTS anomaly_alerts;`,
+	70: `
+DROP TABLE IF EXISTS account_deletion_tokens;
+DROP INDEX IF EXISTS idx_users_email_active;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+`,
+	71: `DROP TABLE IF EXISTS payee_aliases;`,
+	72: `DROP TABLE IF EXISTS telegram_updates;`,
+}
+
+var ErrIrreversibleMigration = errors.New("migration cannot be rolled back")
+
+// MigrationStatus состояние одной миграции
+type MigrationStatus struct {
+	Version    int
+	AppliedAt  *time.Time
+	Reversible bool
+}
+
+const migrationSchemaVersions = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
 func RunMigrations(pool *pgxpool.Pool) error {
 	log.Println("Running database migrations...")
 
 	ctx := context.Background()
 
-	migrations := []string{
-		migrationCreateExtensions,
-		migrationCreateUsers,
-		migrationCreateRefreshTokens,
-		migrationCreateAccounts,
-		migrationCreateCategories,
-		migrationCreateTransactions,
-		migrationCreateBudgets,
-		migrationCreateGoals,
-		migrationCreateSecurities,
-		migrationCreatePortfolios,
-		migrationCreateHoldings,
-		migrationCreateInvestmentTransactions,
-		migrationCreateIndexes,
-		migrationInsertDefaultCategories,
+	if _, err := pool.Exec(ctx, migrationSchemaVersions); err != nil {
+		return fmt.Errorf("schema_migrations: %w", err)
 	}
 
 	for i, migration := range migrations {
 		if _, err := pool.Exec(ctx, migration); err != nil {
 			return fmt.Errorf("migration %d failed: %w", i+1, err)
 		}
+		if _, err := pool.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT DO NOTHING`, i+1); err != nil {
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
 	}
 
 	log.Println("Migrations completed successfully")
 	return nil
 }
 
+// RollbackMigration откатывает последнюю примененную миграцию и возвращает ее версию.
+// после отката сервер при следующем запуске применит ее снова - откат нужен перед запуском старой версии
+func RollbackMigration(pool *pgxpool.Pool) (int, error) {
+	ctx := context.Background()
+
+	if _, err := pool.Exec(ctx, migrationSchemaVersions); err != nil {
+		return 0, fmt.Errorf("schema_migrations: %w", err)
+	}
+
+	var version int
+	if err := pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	if version == 0 {
+		return 0, errors.New("no applied migrations")
+	}
+	rollback, ok := migrationRollbacks[version]
+	if !ok {
+		return version, fmt.Errorf("migration %d: %w", version, ErrIrreversibleMigration)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return version, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, rollback); err != nil {
+		return version, fmt.Errorf("rollback of migration %d failed: %w", version, err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, version); err != nil {
+		return version, err
+	}
+	return version, tx.Commit(ctx)
+}
+
+// GetMigrationStatus все миграции с датой применения (nil - не применена)
+func GetMigrationStatus(pool *pgxpool.Pool) ([]MigrationStatus, error) {
+	ctx := context.Background()
+
+	if _, err := pool.Exec(ctx, migrationSchemaVersions); err != nil {
+		return nil, fmt.Errorf("schema_migrations: %w", err)
+	}
+
+	rows, err := pool.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i := range migrations {
+		status[i] = MigrationStatus{Version: i + 1}
+		if at, ok := applied[i+1]; ok {
+			status[i].AppliedAt = &at
+		}
+		_, status[i].Reversible = migrationRollbacks[i+1]
+	}
+	return status, nil
+}
+
 const migrationCreateExtensions = `
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 CREATE EXTENSION IF NOT EXISTS "pgcrypto";
@@ -322,3 +590,1029 @@ INSERT INTO categories (id, name, type, icon, color, is_system, sort_order) VALU
     (uuid_generate_v4(), 'Перевод', 'transfer', '🔄', '#607D8B', true, 21)
 ON CONFLICT DO NOTHING;
 `
+
+// семейства refresh токенов (цепочка ротаций одной сессии) и журнал событий безопасности
+const migrationRefreshTokenFamilies = `
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id UUID;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS remember_me BOOLEAN DEFAULT true;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS user_agent VARCHAR(255);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS ip_address VARCHAR(45);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP WITH TIME ZONE;
+UPDATE refresh_tokens SET family_id = id WHERE family_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+CREATE TABLE IF NOT EXISTS security_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    family_id UUID,
+    user_agent VARCHAR(255),
+    ip_address VARCHAR(45),
+    details TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events(user_id, created_at);
+`
+
+// аудит удаления аккаунтов: без FK на users, чтобы запись пережила физическое удаление
+const migrationCreateAccountDeletions = `
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL,
+    email_hash VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reason TEXT,
+    ip_address VARCHAR(45),
+    requested_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    purge_after TIMESTAMP WITH TIME ZONE NOT NULL,
+    purged_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_status ON account_deletions(status, purge_after);
+`
+
+// справочник секторов: MOEX ISS почти не отдает сектор, поэтому храним привязки тикеров отдельно
+const migrationCreateSectorMappings = `
+CREATE TABLE IF NOT EXISTS sector_mappings (
+    ticker VARCHAR(20) NOT NULL,
+    exchange VARCHAR(20) NOT NULL,
+    sector VARCHAR(100) NOT NULL,
+    industry VARCHAR(100),
+    source VARCHAR(20) NOT NULL DEFAULT 'manual',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (ticker, exchange)
+);
+`
+
+// цели, привязанные к инвестиционному портфелю
+const migrationGoalPortfolioLink = `
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS portfolio_id UUID REFERENCES portfolios(id) ON DELETE SET NULL;
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS expected_return DECIMAL(8, 4);
+
+CREATE INDEX IF NOT EXISTS idx_goals_portfolio_id ON goals(portfolio_id);
+`
+
+// получатели платежей (нормализованные названия продавцов)
+const migrationCreatePayees = `
+CREATE TABLE IF NOT EXISTS payees (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    normalized_name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, normalized_name)
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS payee_id UUID REFERENCES payees(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_payee_id ON transactions(payee_id);
+`
+
+// разовые запланированные платежи
+const migrationCreatePlannedTransactions = `
+CREATE TABLE IF NOT EXISTS planned_transactions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    category_id UUID NOT NULL REFERENCES categories(id),
+    type VARCHAR(20) NOT NULL,
+    amount DECIMAL(18, 2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    description VARCHAR(500),
+    due_date DATE NOT NULL,
+    to_account_id UUID REFERENCES accounts(id) ON DELETE SET NULL,
+    auto_post BOOLEAN DEFAULT false,
+    status VARCHAR(20) NOT NULL DEFAULT 'planned',
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    notes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    posted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_planned_transactions_user_id ON planned_transactions(user_id, status, due_date);
+CREATE INDEX IF NOT EXISTS idx_planned_transactions_due ON planned_transactions(status, due_date);
+`
+
+// история цен бумаг: аналитика считается по сохраненным свечам, провайдер дергается только за недостающие даты
+const migrationCreatePriceHistory = `
+CREATE TABLE IF NOT EXISTS price_history (
+    security_id UUID NOT NULL REFERENCES securities(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    open DECIMAL(18, 6),
+    high DECIMAL(18, 6),
+    low DECIMAL(18, 6),
+    close DECIMAL(18, 6) NOT NULL,
+    volume BIGINT DEFAULT 0,
+    PRIMARY KEY (security_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS price_history_coverage (
+    security_id UUID PRIMARY KEY REFERENCES securities(id) ON DELETE CASCADE,
+    from_date DATE NOT NULL,
+    to_date DATE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// конверты (бюджетирование с нуля) и журнал распределений по ним
+const migrationCreateEnvelopes = `
+CREATE TABLE IF NOT EXISTS envelopes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    is_archived BOOLEAN DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, category_id)
+);
+
+CREATE TABLE IF NOT EXISTS envelope_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    envelope_id UUID NOT NULL REFERENCES envelopes(id) ON DELETE CASCADE,
+    related_envelope_id UUID REFERENCES envelopes(id) ON DELETE SET NULL,
+    type VARCHAR(20) NOT NULL,
+    amount DECIMAL(18, 2) NOT NULL,
+    month DATE NOT NULL,
+    note VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_envelope_entries_envelope_id ON envelope_entries(envelope_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_envelope_entries_user_month ON envelope_entries(user_id, month);
+`
+
+// отказ пользователя от AI-функций (данные не уходят внешнему провайдеру)
+const migrationUserAISettings = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS ai_enabled BOOLEAN NOT NULL DEFAULT true;
+`
+
+// параметры контрактов срочного рынка (фьючерсы, опционы)
+const migrationSecurityDerivatives = `
+ALTER TABLE securities ADD COLUMN IF NOT EXISTS underlying VARCHAR(50);
+ALTER TABLE securities ADD COLUMN IF NOT EXISTS expiry_date DATE;
+ALTER TABLE securities ADD COLUMN IF NOT EXISTS strike DECIMAL(18, 6);
+ALTER TABLE securities ADD COLUMN IF NOT EXISTS option_type VARCHAR(4);
+ALTER TABLE securities ADD COLUMN IF NOT EXISTS contract_multiplier DECIMAL(18, 6);
+ALTER TABLE securities ADD COLUMN IF NOT EXISTS initial_margin DECIMAL(18, 2);
+
+CREATE INDEX IF NOT EXISTS idx_securities_expiry_date ON securities(expiry_date) WHERE expiry_date IS NOT NULL;
+`
+
+// почтовые ящики для импорта уведомлений банков и черновики распознанных операций
+const migrationCreateMailImport = `
+CREATE TABLE IF NOT EXISTS mail_connections (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    host VARCHAR(255) NOT NULL,
+    port INTEGER NOT NULL DEFAULT 993,
+    username VARCHAR(255) NOT NULL,
+    password_enc TEXT NOT NULL,
+    mailbox VARCHAR(255) NOT NULL DEFAULT 'INBOX',
+    use_tls BOOLEAN NOT NULL DEFAULT true,
+    account_id UUID REFERENCES accounts(id) ON DELETE SET NULL,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    uid_validity BIGINT NOT NULL DEFAULT 0,
+    last_uid BIGINT NOT NULL DEFAULT 0,
+    last_polled_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mail_connections_user_id ON mail_connections(user_id);
+
+CREATE TABLE IF NOT EXISTS transaction_drafts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    connection_id UUID REFERENCES mail_connections(id) ON DELETE SET NULL,
+    external_id VARCHAR(255) NOT NULL,
+    parser VARCHAR(50) NOT NULL,
+    account_id UUID REFERENCES accounts(id) ON DELETE SET NULL,
+    type VARCHAR(20) NOT NULL,
+    amount DECIMAL(18, 2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    description VARCHAR(500),
+    date TIMESTAMP WITH TIME ZONE NOT NULL,
+    card_last4 VARCHAR(4),
+    subject VARCHAR(500),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, external_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_drafts_user_status ON transaction_drafts(user_id, status, date DESC);
+`
+
+// позиции чеков внутри транзакций и чеки, проверенные в ФНС
+const migrationCreateReceipts = `
+CREATE TABLE IF NOT EXISTS transaction_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(500) NOT NULL,
+    quantity DECIMAL(18, 6) NOT NULL DEFAULT 1,
+    price DECIMAL(18, 2) NOT NULL DEFAULT 0,
+    amount DECIMAL(18, 2) NOT NULL,
+    category_id UUID REFERENCES categories(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_items_transaction_id ON transaction_items(transaction_id, position);
+
+CREATE TABLE IF NOT EXISTS receipts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    fn VARCHAR(20) NOT NULL,
+    fd VARCHAR(20) NOT NULL,
+    fp VARCHAR(20) NOT NULL,
+    seller VARCHAR(255),
+    seller_inn VARCHAR(12),
+    retail_place VARCHAR(255),
+    address VARCHAR(500),
+    total DECIMAL(18, 2) NOT NULL,
+    date TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_receipts_user_fiscal ON receipts(user_id, fn, fd, fp);
+CREATE INDEX IF NOT EXISTS idx_receipts_transaction_id ON receipts(transaction_id);
+`
+
+// входящие вебхуки банков и автоматизаций и принятые по ним уведомления
+const migrationCreateWebhooks = `
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    income_category_id UUID REFERENCES categories(id) ON DELETE SET NULL,
+    template JSONB NOT NULL DEFAULT '{}',
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_endpoints_user_id ON webhook_endpoints(user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    external_id VARCHAR(255) NOT NULL,
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (endpoint_id, external_id)
+);
+`
+
+// подписки на отчеты по почте
+const migrationCreateReportSubscriptions = `
+CREATE TABLE IF NOT EXISTS report_subscriptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    frequency VARCHAR(20) NOT NULL,
+    include_summary BOOLEAN NOT NULL DEFAULT true,
+    include_budgets BOOLEAN NOT NULL DEFAULT true,
+    include_portfolio BOOLEAN NOT NULL DEFAULT true,
+    weekday SMALLINT NOT NULL DEFAULT 1,
+    day_of_month SMALLINT NOT NULL DEFAULT 1,
+    hour SMALLINT NOT NULL DEFAULT 9,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    last_sent_at TIMESTAMP WITH TIME ZONE,
+    next_send_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, frequency)
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_subscriptions_due ON report_subscriptions(next_send_at) WHERE is_active = true;
+`
+
+// учитывать дивиденды и купоны из портфелей в доходах аналитики
+const migrationUserInvestmentIncome = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS include_investment_income BOOLEAN NOT NULL DEFAULT false;
+`
+
+// активы с ручной оценкой (недвижимость, транспорт, займы, ценности) и история их оценок
+const migrationCreateCustomAssets = `
+CREATE TABLE IF NOT EXISTS custom_assets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    class VARCHAR(30) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    current_value DECIMAL(18, 2) NOT NULL,
+    valued_at DATE NOT NULL,
+    purchase_value DECIMAL(18, 2),
+    purchase_date DATE,
+    notes TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_custom_assets_user_id ON custom_assets(user_id);
+
+CREATE TABLE IF NOT EXISTS custom_asset_valuations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    asset_id UUID NOT NULL REFERENCES custom_assets(id) ON DELETE CASCADE,
+    value DECIMAL(18, 2) NOT NULL,
+    date DATE NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_custom_asset_valuations_asset_date ON custom_asset_valuations(asset_id, date);
+`
+
+// целевые доли бумаг в портфеле и порог отклонения для уведомлений о ребалансировке
+const migrationCreatePortfolioTargets = `
+ALTER TABLE portfolios ADD COLUMN IF NOT EXISTS rebalance_threshold DECIMAL(5, 2);
+
+CREATE TABLE IF NOT EXISTS portfolio_targets (
+    portfolio_id UUID NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    security_id UUID NOT NULL REFERENCES securities(id) ON DELETE CASCADE,
+    weight DECIMAL(5, 2) NOT NULL,
+    alerted_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (portfolio_id, security_id)
+);
+`
+
+// счетчики расхода квот пользователя (вызовы AI за день, объем вложений за месяц)
+const migrationCreateUserUsage = `
+CREATE TABLE IF NOT EXISTS user_usage (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    metric VARCHAR(30) NOT NULL,
+    period DATE NOT NULL,
+    amount BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, metric, period)
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_user_created ON transactions(user_id, created_at);
+`
+
+// заметки, целевая цена и теги пользователя к позициям портфеля
+const migrationCreateHoldingMetadata = `
+CREATE TABLE IF NOT EXISTS holding_metadata (
+    portfolio_id UUID NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    security_id UUID NOT NULL REFERENCES securities(id) ON DELETE CASCADE,
+    notes TEXT NOT NULL DEFAULT '',
+    target_price DECIMAL(18, 8),
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (portfolio_id, security_id)
+);
+`
+
+// банковские вклады: ставка, срок и капитализация, начисленные проценты считаются на лету
+const migrationCreateDeposits = `
+CREATE TABLE IF NOT EXISTS deposits (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    bank VARCHAR(255) NOT NULL,
+    name VARCHAR(255),
+    currency VARCHAR(3) NOT NULL,
+    principal DECIMAL(18, 2) NOT NULL,
+    rate DECIMAL(7, 4) NOT NULL,
+    capitalization VARCHAR(20) NOT NULL DEFAULT 'none',
+    opened_at DATE NOT NULL,
+    term_days INTEGER NOT NULL,
+    maturity_date DATE NOT NULL,
+    payout_account_id UUID REFERENCES accounts(id) ON DELETE SET NULL,
+    notes TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposits_user_id ON deposits(user_id);
+`
+
+// индексы под курсорную пагинацию /api/v2: (date, id) от новых к старым
+const migrationCursorPaginationIndexes = `
+CREATE INDEX IF NOT EXISTS idx_transactions_user_date_id ON transactions(user_id, date DESC, id DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_investment_transactions_portfolio_date_id ON investment_transactions(portfolio_id, date DESC, id DESC);
+`
+
+// входящие уведомления в приложении; dedup_key не дает создать повторное уведомление об одном событии
+const migrationCreateNotifications = `
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(30) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT,
+    entity_id UUID,
+    dedup_key VARCHAR(255),
+    read_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_unread ON notifications(user_id) WHERE read_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_user_dedup ON notifications(user_id, dedup_key) WHERE dedup_key IS NOT NULL;
+`
+
+// обязательные (fixed) и необязательные расходы. системные категории общие, поэтому
+// пользователь переопределяет их признак в category_preferences
+const migrationCategoryFixedCosts = `
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS is_fixed BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE categories SET is_fixed = true
+WHERE is_system = true AND type = 'expense' AND name IN ('Жилье', 'Коммунальные услуги', 'Связь', 'Подписки');
+
+CREATE TABLE IF NOT EXISTS category_preferences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    is_fixed BOOLEAN NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, category_id)
+);
+`
+
+// индивидуальные инвестиционные счета: тип вычета, дата открытия и движение денег для лимита взносов
+const migrationPortfolioIIS = `
+ALTER TABLE portfolios ADD COLUMN IF NOT EXISTS iis_type VARCHAR(1) CHECK (iis_type IN ('A', 'B'));
+ALTER TABLE portfolios ADD COLUMN IF NOT EXISTS iis_opened_at DATE;
+
+CREATE TABLE IF NOT EXISTS iis_cash_flows (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    portfolio_id UUID NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('contribution', 'withdrawal')),
+    amount DECIMAL(18, 2) NOT NULL CHECK (amount > 0),
+    date DATE NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_iis_cash_flows_portfolio_date ON iis_cash_flows(portfolio_id, date);
+`
+
+// каталог товаров из позиций чеков: одинаковые названия (без учета регистра и пробелов) - один товар.
+// уже сохраненные позиции привязываются к товарам при миграции
+const migrationCreateProducts = `
+CREATE TABLE IF NOT EXISTS products (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(500) NOT NULL,
+    normalized_name VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, normalized_name)
+);
+
+ALTER TABLE transaction_items ADD COLUMN IF NOT EXISTS product_id UUID REFERENCES products(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_transaction_items_product_id ON transaction_items(product_id);
+
+INSERT INTO products (user_id, name, normalized_name)
+SELECT DISTINCT ON (t.user_id, LOWER(BTRIM(REGEXP_REPLACE(i.name, '\s+', ' ', 'g'))))
+    t.user_id, BTRIM(REGEXP_REPLACE(i.name, '\s+', ' ', 'g')), LOWER(BTRIM(REGEXP_REPLACE(i.name, '\s+', ' ', 'g')))
+FROM transaction_items i
+JOIN transactions t ON t.id = i.transaction_id
+WHERE i.product_id IS NULL AND BTRIM(i.name) <> ''
+ON CONFLICT (user_id, normalized_name) DO NOTHING;
+
+UPDATE transaction_items i SET product_id = p.id
+FROM transactions t, products p
+WHERE t.id = i.transaction_id AND i.product_id IS NULL
+  AND p.user_id = t.user_id AND p.normalized_name = LOWER(BTRIM(REGEXP_REPLACE(i.name, '\s+', ' ', 'g')));
+`
+
+// бюджет по счету и/или получателю в дополнение к категории ("карта для ресторанов - 15 000 в месяц")
+const migrationBudgetScope = `
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS account_id UUID REFERENCES accounts(id) ON DELETE SET NULL;
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS payee_id UUID REFERENCES payees(id) ON DELETE SET NULL;
+`
+
+// оценка финансового здоровья по месяцам: один снимок на пользователя и месяц
+const migrationCreateHealthSnapshots = `
+CREATE TABLE IF NOT EXISTS financial_health_snapshots (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    month DATE NOT NULL,
+    overall_score INTEGER NOT NULL,
+    grade VARCHAR(1) NOT NULL,
+    savings_score INTEGER NOT NULL,
+    budget_score INTEGER NOT NULL,
+    debt_score INTEGER NOT NULL,
+    emergency_fund_score INTEGER NOT NULL,
+    savings_rate DECIMAL(18, 4) NOT NULL DEFAULT 0,
+    debt_to_income_ratio DECIMAL(18, 4) NOT NULL DEFAULT 0,
+    emergency_fund_months DECIMAL(18, 4) NOT NULL DEFAULT 0,
+    fixed_cost_months DECIMAL(18, 4) NOT NULL DEFAULT 0,
+    fixed_cost_ratio DECIMAL(18, 4) NOT NULL DEFAULT 0,
+    discretionary_trend DECIMAL(18, 4) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, month)
+);
+`
+
+// бумаги, заведенные пользователем вручную (опционы работодателя, непубличные доли): биржа MANUAL,
+// тикер уникален в пределах владельца, у биржевых бумаг - как раньше в пределах биржи
+const migrationManualSecurities = `
+ALTER TABLE securities ADD COLUMN IF NOT EXISTS owner_id UUID REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE securities DROP CONSTRAINT IF EXISTS securities_ticker_exchange_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_securities_ticker_exchange ON securities(ticker, exchange) WHERE owner_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_securities_owner_ticker ON securities(owner_id, ticker) WHERE owner_id IS NOT NULL;
+`
+
+// НКД облигаций; цены облигаций MOEX теперь хранятся в валюте, а не в % от номинала, как котирует биржа
+// пересчет цен выполняется только вместе с добавлением колонки, чтобы повторный запуск их не умножал
+const migrationBondAccruedInterest = `
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'securities' AND column_name = 'accrued_interest') THEN
+        ALTER TABLE securities ADD COLUMN accrued_interest DECIMAL(18, 6) NOT NULL DEFAULT 0;
+        UPDATE securities SET last_price = last_price * face_value / 100, price_change = price_change * face_value / 100
+        WHERE type = 'bond' AND exchange = 'MOEX' AND face_value > 0;
+        -- номинал на дату свечи не известен, берем текущий
+        UPDATE price_history ph
+        SET open = ph.open * s.face_value / 100, high = ph.high * s.face_value / 100,
+            low = ph.low * s.face_value / 100, close = ph.close * s.face_value / 100
+        FROM securities s
+        WHERE s.id = ph.security_id AND s.type = 'bond' AND s.exchange = 'MOEX' AND s.face_value > 0;
+    END IF;
+END $$;
+`
+
+// локаль профиля: формат сумм и дат в выгрузках и письмах
+const migrationUserLocale = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT 'ru-RU';
+`
+
+// профиль: телефон, дата рождения (возраст для пенсионных прогнозов) и аватар в хранилище вложений
+const migrationUserProfile = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS birth_date DATE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key VARCHAR(255) NOT NULL DEFAULT '';
+`
+
+// скрытые пользователем тикеры, типы бумаг и биржи: не показываются в поиске и скринере
+const migrationSecurityPreferences = `
+CREATE TABLE IF NOT EXISTS security_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    hidden_tickers TEXT[] NOT NULL DEFAULT '{}',
+    hidden_types TEXT[] NOT NULL DEFAULT '{}',
+    hidden_exchanges TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// координаты операции с мобильных клиентов; geohash считается при записи, по его префиксу строится карта трат
+const migrationTransactionGeo = `
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS geohash VARCHAR(12);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS city VARCHAR(100) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_transactions_geohash ON transactions(user_id, geohash) WHERE geohash IS NOT NULL;
+`
+
+// привязка чата Telegram-бота: сначала одноразовый код для deep link (хранится хэш), после /start - chat_id
+const migrationTelegramLinks = `
+CREATE TABLE IF NOT EXISTS telegram_links (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    chat_id BIGINT UNIQUE,
+    username VARCHAR(100) NOT NULL DEFAULT '',
+    account_id UUID REFERENCES accounts(id) ON DELETE SET NULL,
+    code_hash VARCHAR(64) UNIQUE,
+    code_expires_at TIMESTAMP WITH TIME ZONE,
+    linked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// деноминации и замены токенов: суммы в currency до effective_date показываются как сумма × factor в new_currency
+const migrationCurrencyRedenominations = `
+CREATE TABLE IF NOT EXISTS currency_redenominations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    currency VARCHAR(10) NOT NULL,
+    new_currency VARCHAR(10) NOT NULL,
+    factor DECIMAL(30, 12) NOT NULL CHECK (factor > 0),
+    effective_date DATE NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (currency, effective_date)
+);
+
+INSERT INTO currency_redenominations (currency, new_currency, factor, effective_date, note) VALUES
+    ('RUR', 'RUB', 0.001, '1998-01-01', 'деноминация рубля 1998'),
+    ('BYR', 'BYN', 0.0001, '2016-07-01', 'деноминация белорусского рубля 2016'),
+    ('MATIC', 'POL', 1, '2024-09-04', 'миграция MATIC в POL')
+ON CONFLICT (currency, effective_date) DO NOTHING;
+`
+
+// дневные снимки стоимости портфеля (последняя и максимальная за день) и пороги уведомлений о просадке
+const migrationPortfolioDrawdownAlerts = `
+CREATE TABLE IF NOT EXISTS portfolio_value_snapshots (
+    portfolio_id UUID NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    value DECIMAL(20, 4) NOT NULL,
+    high DECIMAL(20, 4) NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (portfolio_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS portfolio_drawdown_alerts (
+    portfolio_id UUID PRIMARY KEY REFERENCES portfolios(id) ON DELETE CASCADE,
+    drawdown_pct DECIMAL(5, 2),
+    daily_drop_pct DECIMAL(5, 2),
+    cooldown_hours INT NOT NULL DEFAULT 24,
+    drawdown_alerted_at TIMESTAMP WITH TIME ZONE,
+    daily_drop_alerted_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// мультипликаторы бумаг от провайдеров и история дивидендов на акцию; дивдоходность считается
+// от текущей цены при чтении, поэтому хранится только сумма за 12 месяцев
+const migrationSecurityFundamentals = `
+CREATE TABLE IF NOT EXISTS security_fundamentals (
+    security_id UUID PRIMARY KEY REFERENCES securities(id) ON DELETE CASCADE,
+    market_cap DECIMAL(24, 2),
+    pe_ratio DECIMAL(14, 4),
+    eps DECIMAL(18, 6),
+    dividend_per_share DECIMAL(18, 6),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS security_dividends (
+    security_id UUID NOT NULL REFERENCES securities(id) ON DELETE CASCADE,
+    ex_date DATE NOT NULL,
+    payment_date DATE,
+    amount DECIMAL(18, 6) NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    dividend_type VARCHAR(20) NOT NULL DEFAULT 'regular',
+    PRIMARY KEY (security_id, ex_date, dividend_type)
+);
+`
+
+// операции портфеля удаляются мягко, как и обычные, чтобы их можно было восстановить.
+// broker_ref уникален среди живых операций портфеля; уже загруженные дубли (кроме самой ранней операции)
+// помечаются удаленными, а их позиции ставятся в очередь на пересчет (дедупликация - один раз, вместе с индексом)
+const migrationInvestmentTransactionSoftDelete = `
+ALTER TABLE investment_transactions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS holding_rebuilds (
+    portfolio_id UUID NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    security_id UUID NOT NULL REFERENCES securities(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (portfolio_id, security_id)
+);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_investment_transactions_broker_ref') THEN
+        CREATE TEMP TABLE broker_ref_duplicates AS
+        SELECT id, portfolio_id, security_id
+        FROM (
+            SELECT id, portfolio_id, security_id,
+                   ROW_NUMBER() OVER (PARTITION BY portfolio_id, broker_ref ORDER BY created_at, id) AS n
+            FROM investment_transactions
+            WHERE broker_ref <> '' AND deleted_at IS NULL
+        ) d
+        WHERE d.n > 1;
+
+        INSERT INTO holding_rebuilds (portfolio_id, security_id)
+        SELECT DISTINCT portfolio_id, security_id FROM broker_ref_duplicates
+        ON CONFLICT DO NOTHING;
+
+        UPDATE investment_transactions SET deleted_at = NOW()
+        WHERE id IN (SELECT id FROM broker_ref_duplicates);
+
+        DROP TABLE broker_ref_duplicates;
+
+        CREATE UNIQUE INDEX idx_investment_transactions_broker_ref ON investment_transactions(portfolio_id, broker_ref)
+        WHERE broker_ref <> '' AND deleted_at IS NULL;
+    END IF;
+END $$;
+`
+
+// правила счетов (комиссия, кэшбэк, проценты на остаток) и их срабатывания по месяцам
+const migrationAccountRules = `
+CREATE TABLE IF NOT EXISTS account_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    day_of_month INT NOT NULL,
+    amount DECIMAL(18, 2),
+    rate DECIMAL(8, 4),
+    source_category_id UUID REFERENCES categories(id) ON DELETE SET NULL,
+    max_amount DECIMAL(18, 2),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_rules_account_id ON account_rules(account_id);
+CREATE INDEX IF NOT EXISTS idx_account_rules_user_id ON account_rules(user_id);
+
+CREATE TABLE IF NOT EXISTS account_rule_runs (
+    rule_id UUID NOT NULL REFERENCES account_rules(id) ON DELETE CASCADE,
+    period DATE NOT NULL,
+    amount DECIMAL(18, 2) NOT NULL,
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (rule_id, period)
+);
+`
+
+// пользовательские отчеты: описание разбивок, показателей и фильтров
+const migrationReportDefinitions = `
+CREATE TABLE IF NOT EXISTS report_definitions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    spec JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_definitions_user_id ON report_definitions(user_id);
+`
+
+// версия алгоритма хэша пароля: 1 - bcrypt (все существующие), 2 - argon2id.
+// без отката: старый код не проверит argon2id-хэши, пересчитанные при входе
+const migrationPasswordHashVersion = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash_version SMALLINT NOT NULL DEFAULT 1;
+`
+
+// счетчики неудачных входов по email и адресу: общие для всех экземпляров сервера
+const migrationLoginAttempts = `
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    unlock_token_hash VARCHAR(64),
+    last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_attempts_unlock_token ON login_attempts(unlock_token_hash) WHERE unlock_token_hash IS NOT NULL;
+`
+
+// импорт произвольных банковских выгрузок CSV: профили сопоставления колонок и загруженные файлы
+const migrationCSVImport = `
+CREATE TABLE IF NOT EXISTS csv_import_profiles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    account_id UUID REFERENCES accounts(id) ON DELETE SET NULL,
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    income_category_id UUID REFERENCES categories(id) ON DELETE SET NULL,
+    mapping JSONB NOT NULL DEFAULT '{}',
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_csv_import_profiles_user_id ON csv_import_profiles(user_id);
+
+CREATE TABLE IF NOT EXISTS csv_uploads (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL DEFAULT '',
+    content BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_csv_uploads_created_at ON csv_uploads(created_at);
+`
+
+// челленджи по расходам и итоги их периодов; серия хранится в челлендже, чтобы не пересчитывать историю
+const migrationChallenges = `
+CREATE TABLE IF NOT EXISTS challenges (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    period VARCHAR(10) NOT NULL,
+    amount DECIMAL(18, 2),
+    weekdays INT[] NOT NULL DEFAULT '{}',
+    category_id UUID REFERENCES categories(id) ON DELETE CASCADE,
+    account_id UUID REFERENCES accounts(id) ON DELETE CASCADE,
+    payee_id UUID REFERENCES payees(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    end_date DATE,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    current_streak INT NOT NULL DEFAULT 0,
+    best_streak INT NOT NULL DEFAULT 0,
+    last_period DATE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenges_user_id ON challenges(user_id);
+
+CREATE TABLE IF NOT EXISTS challenge_results (
+    challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    spent DECIMAL(18, 2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (challenge_id, period_start)
+);
+`
+
+// иконки хранятся как есть в UTF-8: VARCHAR(10) мал для emoji из нескольких кодовых точек
+// (флаги, ZWJ-последовательности), а системные категории, вставленные при неверной кодировке
+// клиента, превратились в кракозябры. эталонные значения записаны escape-последовательностями
+// U&'...', чтобы миграция не зависела от client_encoding; сопоставление по sort_order, потому что
+// испорчены могли быть и названия
+const migrationCategoryIconRepair = `
+ALTER TABLE categories ALTER COLUMN icon TYPE VARCHAR(32);
+ALTER TABLE accounts ALTER COLUMN icon TYPE VARCHAR(32);
+ALTER TABLE goals ALTER COLUMN icon TYPE VARCHAR(32);
+
+UPDATE categories c SET name = d.name, icon = d.icon, color = d.color, updated_at = CURRENT_TIMESTAMP
+FROM (VALUES
+    (1, 'income', U&'\0417\0430\0440\043F\043B\0430\0442\0430', U&'\+01F4B0', '#4CAF50'), -- 💰 Зарплата
+    (2, 'income', U&'\0424\0440\0438\043B\0430\043D\0441', U&'\+01F4BB', '#8BC34A'), -- 💻 Фриланс
+    (3, 'income', U&'\0418\043D\0432\0435\0441\0442\0438\0446\0438\0438', U&'\+01F4C8', '#009688'), -- 📈 Инвестиции
+    (4, 'income', U&'\0414\0438\0432\0438\0434\0435\043D\0434\044B', U&'\+01F4B5', '#00BCD4'), -- 💵 Дивиденды
+    (5, 'income', U&'\041F\043E\0434\0430\0440\043A\0438', U&'\+01F381', '#03A9F4'), -- 🎁 Подарки
+    (6, 'income', U&'\0414\0440\0443\0433\043E\0439 \0434\043E\0445\043E\0434', U&'\+01F4B8', '#2196F3'), -- 💸 Другой доход
+    (7, 'expense', U&'\041F\0440\043E\0434\0443\043A\0442\044B', U&'\+01F6D2', '#FF5722'), -- 🛒 Продукты
+    (8, 'expense', U&'\0420\0435\0441\0442\043E\0440\0430\043D\044B', U&'\+01F37D\FE0F', '#FF9800'), -- 🍽️ Рестораны
+    (9, 'expense', U&'\0422\0440\0430\043D\0441\043F\043E\0440\0442', U&'\+01F697', '#FFC107'), -- 🚗 Транспорт
+    (10, 'expense', U&'\0416\0438\043B\044C\0435', U&'\+01F3E0', '#795548'), -- 🏠 Жилье
+    (11, 'expense', U&'\041A\043E\043C\043C\0443\043D\0430\043B\044C\043D\044B\0435 \0443\0441\043B\0443\0433\0438', U&'\+01F4A1', '#607D8B'), -- 💡 Коммунальные услуги
+    (12, 'expense', U&'\0417\0434\043E\0440\043E\0432\044C\0435', U&'\+01F3E5', '#E91E63'), -- 🏥 Здоровье
+    (13, 'expense', U&'\0420\0430\0437\0432\043B\0435\0447\0435\043D\0438\044F', U&'\+01F3AC', '#9C27B0'), -- 🎬 Развлечения
+    (14, 'expense', U&'\041F\043E\043A\0443\043F\043A\0438', U&'\+01F6CD\FE0F', '#673AB7'), -- 🛍️ Покупки
+    (15, 'expense', U&'\041E\0431\0440\0430\0437\043E\0432\0430\043D\0438\0435', U&'\+01F4DA', '#3F51B5'), -- 📚 Образование
+    (16, 'expense', U&'\041F\0443\0442\0435\0448\0435\0441\0442\0432\0438\044F', U&'\2708\FE0F', '#2196F3'), -- ✈️ Путешествия
+    (17, 'expense', U&'\041F\043E\0434\043F\0438\0441\043A\0438', U&'\+01F4F1', '#00BCD4'), -- 📱 Подписки
+    (18, 'expense', U&'\0421\0432\044F\0437\044C', U&'\+01F4DE', '#009688'), -- 📞 Связь
+    (19, 'expense', U&'\0414\043E\043C\0430\0448\043D\0438\0435 \0436\0438\0432\043E\0442\043D\044B\0435', U&'\+01F415', '#4CAF50'), -- 🐕 Домашние животные
+    (20, 'expense', U&'\0414\0440\0443\0433\0438\0435 \0440\0430\0441\0445\043E\0434\044B', U&'\+01F4CB', '#9E9E9E'), -- 📋 Другие расходы
+    (21, 'transfer', U&'\041F\0435\0440\0435\0432\043E\0434', U&'\+01F504', '#607D8B') -- 🔄 Перевод
+) AS d(sort_order, type, name, icon, color)
+WHERE c.is_system = true AND c.user_id IS NULL AND c.sort_order = d.sort_order AND c.type = d.type
+  AND (c.name, c.icon, c.color) IS DISTINCT FROM (d.name, d.icon, d.color);
+`
+
+// порядок и группировка счетов: порядок и своя группа хранятся в счете, способ группировки - у пользователя.
+// существующие счета нумеруются в порядке создания, чтобы список не перемешался
+const migrationAccountOrder = `
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS sort_order INTEGER;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS account_group VARCHAR(50);
+
+UPDATE accounts a SET sort_order = o.position
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at) AS position FROM accounts) o
+WHERE a.id = o.id AND a.sort_order IS NULL;
+
+ALTER TABLE accounts ALTER COLUMN sort_order SET DEFAULT 0;
+ALTER TABLE accounts ALTER COLUMN sort_order SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS account_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    group_by VARCHAR(20) NOT NULL DEFAULT 'none',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// тип бумаги, заданный администратором: провайдеры путают привилегированные акции и расписки с обычными акциями
+const migrationSecurityTypeOverrides = `
+CREATE TABLE IF NOT EXISTS security_type_overrides (
+    ticker VARCHAR(20) NOT NULL,
+    exchange VARCHAR(20) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (ticker, exchange)
+);
+`
+
+// несколько порогов оповещения у бюджета и отметки сработавших порогов по периодам
+const migrationBudgetAlertThresholds = `
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS alert_thresholds INTEGER[];
+
+-- раньше было одно предупреждение на alert_percent и уведомление о превышении на 100%
+UPDATE budgets
+SET alert_thresholds = CASE
+    WHEN COALESCE(alert_percent, 80) < 100 THEN ARRAY[COALESCE(alert_percent, 80), 100]
+    ELSE ARRAY[alert_percent]
+END
+WHERE alert_thresholds IS NULL;
+
+ALTER TABLE budgets ALTER COLUMN alert_thresholds SET DEFAULT '{80,100}';
+ALTER TABLE budgets ALTER COLUMN alert_thresholds SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS budget_alert_state (
+    budget_id UUID NOT NULL REFERENCES budgets(id) ON DELETE CASCADE,
+    period_start DATE NOT NULL,
+    threshold INTEGER NOT NULL,
+    fired_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (budget_id, period_start, threshold)
+);
+
+-- уже отправленные уведомления старого формата (budget:<id>:<период>:warning|exceeded) считаем сработавшими порогами,
+-- чтобы после обновления они не пришли повторно
+INSERT INTO budget_alert_state (budget_id, period_start, threshold, fired_at)
+SELECT b.id, split_part(n.dedup_key, ':', 3)::date, t.threshold, n.created_at
+FROM notifications n
+JOIN budgets b ON b.id::text = split_part(n.dedup_key, ':', 2)
+CROSS JOIN LATERAL unnest(b.alert_thresholds) AS t(threshold)
+WHERE n.type = 'budget_alert'
+  AND split_part(n.dedup_key, ':', 4) IN ('warning', 'exceeded')
+  AND t.threshold <= CASE split_part(n.dedup_key, ':', 4) WHEN 'exceeded' THEN 100 ELSE b.alert_percent END
+ON CONFLICT DO NOTHING;
+`
+
+// наступившие платежи с автопроведением для фоновой задачи
+const migrationPlannedAutoPostIndex = `
+CREATE INDEX IF NOT EXISTS idx_planned_transactions_auto_due ON planned_transactions(due_date)
+WHERE status = 'planned' AND auto_post = true;
+`
+
+// причина отзыва refresh токена: кражей считается только повторное предъявление ротированного
+const migrationRefreshTokenRevokedReason = `
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS revoked_reason VARCHAR(20);
+UPDATE refresh_tokens SET revoked_reason = 'rotated' WHERE revoked_at IS NOT NULL AND last_used_at IS NOT NULL AND revoked_reason IS NULL;
+UPDATE refresh_tokens SET revoked_reason = 'revoked' WHERE revoked_at IS NOT NULL AND revoked_reason IS NULL;
+`
+
+// когда последний раз пытались определить сектор бумаги: дозаполнение идет по кругу, а не по первым тикерам
+const migrationSecuritySectorChecked = `
+ALTER TABLE securities ADD COLUMN IF NOT EXISTS sector_checked_at TIMESTAMP WITH TIME ZONE;
+`
+
+// демо-аккаунт помечается явно: пользователь, сам зарегистрировавший DEMO_EMAIL, демо не становится
+const migrationUserIsDemo = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_demo BOOLEAN NOT NULL DEFAULT false;
+`
+
+// время последнего запуска фоновых задач, общее для всех экземпляров сервера
+const migrationSchedulerJobs = `
+CREATE TABLE IF NOT EXISTS scheduler_jobs (
+    name VARCHAR(100) PRIMARY KEY,
+    last_run_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+`
+
+// AI-рекомендации в бд: все экземпляры отдают одну генерацию и не списывают квоту повторно
+const migrationAIRecommendations = `
+CREATE TABLE IF NOT EXISTS ai_recommendations (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    items JSONB NOT NULL DEFAULT '[]',
+    generated_at TIMESTAMP WITH TIME ZONE,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    running BOOLEAN NOT NULL DEFAULT false,
+    error TEXT NOT NULL DEFAULT ''
+);
+`
+
+// уведомления о подозрительных тратах включает сам пользователь
+const migrationUserAnomalyAlerts = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anomaly_alerts BOOLEAN NOT NULL DEFAULT false;
+`
+
+// удаление аккаунта подтверждается кодом из письма. email уникален только среди неудаленных пользователей:
+// пока удаленный аккаунт ждет очистки, с тем же email можно зарегистрироваться заново
+const migrationAccountDeletionConfirm = `
+CREATE TABLE IF NOT EXISTS account_deletion_tokens (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    reason TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users(email) WHERE deleted_at IS NULL;
+`
+
+// прежние названия объединенных и переименованных получателей: описание со старым названием
+// находит получателя точным поиском, а не создает его заново
+const migrationPayeeAliases = `
+CREATE TABLE IF NOT EXISTS payee_aliases (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    normalized_name VARCHAR(255) NOT NULL,
+    payee_id UUID NOT NULL REFERENCES payees(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, normalized_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payee_aliases_payee_id ON payee_aliases(payee_id);
+`
+
+// обработанные обновления Telegram-бота: повторная доставка того же update_id не записывает операцию второй раз
+const migrationTelegramUpdates = `
+CREATE TABLE IF NOT EXISTS telegram_updates (
+    update_id BIGINT PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
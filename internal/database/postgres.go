@@ -3,24 +3,90 @@ package database
 import (
 	"context"
 	"fmt"
+	"log"
+	"strconv"
 	"time"
 
+	"github.com/alligatorO15/fin-tracker/internal/config"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewPostgresDB(databaseURL string) (*pgxpool.Pool, error) {
+func NewPostgresDB(cfg *config.Config) (*pgxpool.Pool, error) {
+	config, err := poolConfig(cfg, cfg.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// бд может подниматься дольше приложения (docker compose), поэтому пробуем несколько раз
+	attempts := cfg.DBRetryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		pool, err := connect(config)
+		if err == nil {
+			return pool, nil
+		}
+		if attempt >= attempts {
+			return nil, err
+		}
+		log.Printf("Не удалось подключиться к базе данных (попытка %d/%d): %v", attempt, attempts, err)
+		time.Sleep(time.Duration(attempt) * 2 * time.Second)
+	}
+}
+
+// NewReplicaDB пул реплики только для чтения; nil без DATABASE_REPLICA_URL. недоступная при старте
+// реплика не мешает запуску: пул подключится к ней позже, а до тех пор чтения идут в основную бд
+func NewReplicaDB(cfg *config.Config) (*pgxpool.Pool, error) {
+	if cfg.DatabaseReplicaURL == "" {
+		return nil, nil
+	}
+
+	config, err := poolConfig(cfg, cfg.DatabaseReplicaURL)
+	if err != nil {
+		return nil, err
+	}
+	// защита от записи, если по ошибке указан адрес основной бд
+	config.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replica pool: %w", err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	if err := pool.Ping(ctx); err != nil {
+		log.Printf("Реплика бд пока недоступна: %v", err)
+	}
+	return pool, nil
+}
 
-	config, err := pgxpool.ParseConfig(databaseURL)
+func poolConfig(cfg *config.Config, url string) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse database url: %w", err)
 	}
 
-	config.MaxConns = 25
-	config.MinConns = 5
+	config.MaxConns = cfg.DBMaxConns
+	config.MinConns = cfg.DBMinConns
+	if config.MinConns > config.MaxConns {
+		config.MinConns = config.MaxConns
+	}
 	config.MaxConnIdleTime = 1 * time.Minute
 	config.MaxConnLifetime = 5 * time.Minute
+	if cfg.DBHealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = cfg.DBHealthCheckPeriod
+	}
+	if cfg.DBStatementTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.DBStatementTimeout.Milliseconds(), 10)
+	}
+	return config, nil
+}
+
+func connect(config *pgxpool.Config) (*pgxpool.Pool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
@@ -29,9 +95,9 @@ func NewPostgresDB(databaseURL string) (*pgxpool.Pool, error) {
 
 	//протестим пул
 	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	return pool, nil
-
 }
@@ -0,0 +1,124 @@
+// Package format - суммы, проценты и даты для людей: выгрузки и письма с отчетами.
+// правила берутся из локали пользователя: разделители разрядов и дробной части,
+// положение символа валюты, разделитель колонок CSV
+package format
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultLocale локаль профиля по умолчанию
+const DefaultLocale = "ru-RU"
+
+// Locale правила форматирования для одной локали
+type Locale struct {
+	Tag          string
+	Decimal      string // разделитель дробной части
+	Group        string // разделитель разрядов
+	SymbolBefore bool   // "$1,234.56" вместо "1 234,56 ₽"
+	DateLayout   string
+	CSVSeparator rune // при десятичной запятой Excel ждет ';'
+}
+
+// неразрывный пробел: сумма не переносится посередине
+const nbsp = "\u00a0"
+
+var locales = map[string]Locale{
+	"ru-RU": {Tag: "ru-RU", Decimal: ",", Group: nbsp, DateLayout: "02.01.2006", CSVSeparator: ';'},
+	"en-US": {Tag: "en-US", Decimal: ".", Group: ",", SymbolBefore: true, DateLayout: "01/02/2006", CSVSeparator: ','},
+	"en-GB": {Tag: "en-GB", Decimal: ".", Group: ",", SymbolBefore: true, DateLayout: "02/01/2006", CSVSeparator: ','},
+	"de-DE": {Tag: "de-DE", Decimal: ",", Group: ".", DateLayout: "02.01.2006", CSVSeparator: ';'},
+	"fr-FR": {Tag: "fr-FR", Decimal: ",", Group: nbsp, DateLayout: "02/01/2006", CSVSeparator: ';'},
+}
+
+var symbols = map[string]string{
+	"RUB": "₽",
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"CNY": "¥",
+	"JPY": "¥",
+	"KZT": "₸",
+	"BYN": "Br",
+	"TRY": "₺",
+	"INR": "₹",
+}
+
+// Get правила локали; неизвестная или пустая - правила DefaultLocale
+func Get(tag string) Locale {
+	if l, ok := locales[tag]; ok {
+		return l
+	}
+	return locales[DefaultLocale]
+}
+
+// Supported поддерживается ли локаль
+func Supported(tag string) bool {
+	_, ok := locales[tag]
+	return ok
+}
+
+// Symbol символ валюты; для валют без символа - сам код
+func Symbol(currency string) string {
+	if s, ok := symbols[strings.ToUpper(currency)]; ok {
+		return s
+	}
+	return strings.ToUpper(currency)
+}
+
+// Number число с places знаками после запятой и разделителями разрядов
+func (l Locale) Number(d decimal.Decimal, places int32) string {
+	s := d.Abs().StringFixed(places)
+	intPart, frac, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if d.Round(places).IsNegative() {
+		b.WriteByte('-')
+	}
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteString(l.Decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// Money сумма с символом валюты: "1 234,56 ₽", "$1,234.56", "-€10.00"
+func (l Locale) Money(d decimal.Decimal, currency string) string {
+	symbol := Symbol(currency)
+	number := l.Number(d, 2)
+	if !l.SymbolBefore {
+		return number + nbsp + symbol
+	}
+
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	// буквенный код отделяем пробелом: "CHF 10.00"
+	if len(symbol) == 3 && symbol == strings.ToUpper(currency) {
+		symbol += nbsp
+	}
+	return sign + symbol + number
+}
+
+// Percent процент: "12,5 %" или "12.5%"
+func (l Locale) Percent(d decimal.Decimal, places int32) string {
+	if l.Decimal == "," {
+		return l.Number(d, places) + nbsp + "%"
+	}
+	return l.Number(d, places) + "%"
+}
+
+// Date дата в привычном для локали виде
+func (l Locale) Date(t time.Time) string {
+	return t.Format(l.DateLayout)
+}
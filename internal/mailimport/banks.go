@@ -0,0 +1,143 @@
+package mailimport
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// bankParser разбирает типичные уведомления об операциях по карте: тип операции по ключевым
+// словам, сумма с валютой, продавец сразу после суммы и последние цифры карты
+type bankParser struct {
+	name    string
+	senders []string // домены отправителей уведомлений
+}
+
+func bankParsers() []Parser {
+	return []Parser{
+		&bankParser{name: "sber", senders: []string{"sberbank.ru", "sber.ru"}},
+		&bankParser{name: "tbank", senders: []string{"tinkoff.ru", "tbank.ru"}},
+		&bankParser{name: "alfa", senders: []string{"alfabank.ru"}},
+		&bankParser{name: "vtb", senders: []string{"vtb.ru"}},
+	}
+}
+
+// ключевые слова операций; сравниваются с текстом в нижнем регистре
+var (
+	expenseWords = []string{"покупка", "оплата", "списание", "снятие", "перевод на", "перевод с карты", "платеж", "платёж"}
+	incomeWords  = []string{"зачисление", "поступление", "пополнение", "перевод от", "возврат", "дивиденд", "купон", "заработная плата"}
+)
+
+var (
+	amountRe   = regexp.MustCompile(`(\d[\d \x{00a0}]*(?:[.,]\d{1,2})?)\s?(₽|руб\.?|р\.|RUB|RUR|USD|EUR|\$|€)`)
+	cardRe     = regexp.MustCompile(`(?i)(?:карт[аыe]?|сч[её]т[а]?|card)\s*(?:[*•]+|№\s*)?\s*(\d{4})\b|[*•]{1,4}(\d{4})\b|\b(?:MIR|VISA|MC|ECMC)[- ]?(\d{4})\b`)
+	merchantRe = regexp.MustCompile(`^[.,]?\s*([^\n]+?)(?:\.\s|\.$|\n|$)`)
+	// после суммы часто идет остаток, а не продавец
+	balanceWords = []string{"баланс", "доступно", "остаток", "комиссия"}
+)
+
+func (p *bankParser) Name() string {
+	return p.name
+}
+
+func (p *bankParser) Match(msg *Message) bool {
+	for _, sender := range p.senders {
+		if strings.HasSuffix(msg.From, "@"+sender) || strings.HasSuffix(msg.From, "."+sender) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *bankParser) Parse(msg *Message) (*Operation, error) {
+	text := msg.Subject + "\n" + msg.Text
+	lower := strings.ToLower(text)
+
+	txType, keywordAt := detectOperationType(lower)
+	if txType == "" {
+		return nil, ErrNotParsed
+	}
+
+	// сумма ищется после ключевого слова: выше бывают остатки и лимиты
+	loc := amountRe.FindStringSubmatchIndex(text[keywordAt:])
+	if loc == nil {
+		return nil, ErrNotParsed
+	}
+	amountText := text[keywordAt+loc[2] : keywordAt+loc[3]]
+	currencyText := text[keywordAt+loc[4] : keywordAt+loc[5]]
+
+	amount, err := parseAmount(amountText)
+	if err != nil || !amount.IsPositive() {
+		return nil, ErrNotParsed
+	}
+
+	op := &Operation{
+		Type:        txType,
+		Amount:      amount,
+		Currency:    normalizeCurrency(currencyText),
+		Description: merchantAfter(text[keywordAt+loc[1]:]),
+		Date:        msg.Date,
+	}
+	if m := cardRe.FindStringSubmatch(text); m != nil {
+		op.CardLast4 = m[1] + m[2] + m[3]
+	}
+	if op.Description == "" {
+		op.Description = strings.TrimSpace(msg.Subject)
+	}
+	return op, nil
+}
+
+// detectOperationType тип по первому встреченному ключевому слову и его позиция в тексте
+func detectOperationType(lower string) (models.TransactionType, int) {
+	var txType models.TransactionType
+	first := -1
+	check := func(words []string, t models.TransactionType) {
+		for _, word := range words {
+			if i := strings.Index(lower, word); i >= 0 && (first < 0 || i < first) {
+				first, txType = i, t
+			}
+		}
+	}
+	check(expenseWords, models.TransactionTypeExpense)
+	check(incomeWords, models.TransactionTypeIncome)
+	if first < 0 {
+		return "", 0
+	}
+	return txType, first
+}
+
+func parseAmount(s string) (decimal.Decimal, error) {
+	s = strings.NewReplacer(" ", "", " ", "", ",", ".").Replace(s)
+	return decimal.NewFromString(s)
+}
+
+func normalizeCurrency(s string) string {
+	switch strings.TrimSuffix(strings.ToUpper(s), ".") {
+	case "$", "USD":
+		return "USD"
+	case "€", "EUR":
+		return "EUR"
+	}
+	return "RUB"
+}
+
+// merchantAfter продавец - фрагмент сразу после суммы до конца предложения
+func merchantAfter(rest string) string {
+	m := merchantRe.FindStringSubmatch(rest)
+	if m == nil {
+		return ""
+	}
+	merchant := strings.TrimSpace(m[1])
+	lower := strings.ToLower(merchant)
+	for _, word := range balanceWords {
+		if strings.HasPrefix(lower, word) {
+			return ""
+		}
+	}
+	if len([]rune(merchant)) > 100 {
+		merchant = string([]rune(merchant)[:100])
+	}
+	return merchant
+}
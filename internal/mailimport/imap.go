@@ -0,0 +1,347 @@
+package mailimport
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrIMAPGreeting = errors.New("сервер IMAP не прислал приветствие")
+	ErrIMAPCommand  = errors.New("сервер IMAP отклонил команду")
+	ErrIMAPResponse = errors.New("не удалось разобрать ответ сервера IMAP")
+	ErrIMAPNoTLS    = errors.New("сервер IMAP не поддерживает STARTTLS, пароль открытым текстом не отправляем")
+	// ErrIMAPUnsafeString перевод строки или NUL в логине, пароле или папке дописал бы к команде свои
+	ErrIMAPUnsafeString = errors.New("недопустимые символы в логине, пароле или имени папки")
+	// ErrIMAPAddressNotAllowed адрес сервера внутри сети или порт не IMAP: подключаемся с сервера приложения
+	ErrIMAPAddressNotAllowed = errors.New("адрес сервера IMAP не разрешен")
+)
+
+const (
+	imapTimeout      = 30 * time.Second
+	imapMaxLiteral   = 10 << 20 // письма больше 10 МБ - не уведомления банка
+	defaultFetchSize = 50       // писем за один опрос ящика
+)
+
+// allowedPorts порты IMAP: 993 - сразу TLS, 143 - STARTTLS
+var allowedPorts = map[int]bool{993: true, 143: true}
+
+// sharedAddressSpace 100.64.0.0/10 (CGNAT): не входит в IsPrivate, но снаружи тоже недоступен
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Credentials параметры подключения к почтовому ящику
+type Credentials struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Mailbox  string
+	UseTLS   bool
+}
+
+// RawMessage письмо как есть (RFC 822) с его UID в ящике
+type RawMessage struct {
+	UID uint32
+	Raw []byte
+}
+
+// FetchResult новые письма и состояние ящика, которое нужно сохранить до следующего опроса
+type FetchResult struct {
+	UIDValidity uint32
+	LastUID     uint32
+	Messages    []RawMessage
+}
+
+// Check проверяет, что с этими параметрами удается войти и открыть папку
+func Check(ctx context.Context, creds Credentials) error {
+	client, err := dialIMAP(ctx, creds)
+	if err != nil {
+		return err
+	}
+	defer client.logout()
+
+	if err := client.login(creds.Username, creds.Password); err != nil {
+		return err
+	}
+	_, err = client.selectMailbox(creds.Mailbox)
+	return err
+}
+
+// FetchNew забирает письма с UID больше lastUID, но не старше since. Если UIDVALIDITY ящика
+// сменилась, нумерация UID начинается заново. Письма не помечаются прочитанными
+func FetchNew(ctx context.Context, creds Credentials, uidValidity, lastUID uint32, since time.Time) (*FetchResult, error) {
+	client, err := dialIMAP(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	defer client.logout()
+
+	if err := client.login(creds.Username, creds.Password); err != nil {
+		return nil, err
+	}
+	validity, err := client.selectMailbox(creds.Mailbox)
+	if err != nil {
+		return nil, err
+	}
+	if validity != uidValidity {
+		lastUID = 0
+	}
+
+	uids, err := client.searchUIDs(lastUID+1, since)
+	if err != nil {
+		return nil, err
+	}
+	if len(uids) > defaultFetchSize {
+		uids = uids[:defaultFetchSize]
+	}
+
+	result := &FetchResult{UIDValidity: validity, LastUID: lastUID}
+	for _, uid := range uids {
+		raw, err := client.fetchRaw(uid)
+		if err != nil {
+			return nil, err
+		}
+		result.Messages = append(result.Messages, RawMessage{UID: uid, Raw: raw})
+		if uid > result.LastUID {
+			result.LastUID = uid
+		}
+	}
+	return result, nil
+}
+
+// imapClient минимальный клиент IMAP4rev1: вход, выбор папки, поиск и чтение писем
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// imapResponse строка ответа сервера; литералы ({N}) вынесены отдельно
+type imapResponse struct {
+	text     string
+	literals [][]byte
+}
+
+func dialIMAP(ctx context.Context, creds Credentials) (*imapClient, error) {
+	if !allowedPorts[creds.Port] {
+		return nil, ErrIMAPAddressNotAllowed
+	}
+	addr := net.JoinHostPort(creds.Host, strconv.Itoa(creds.Port))
+	// адрес проверяется после разрешения имени, на каждом подключении: DNS может вернуть внутренний IP
+	dialer := &net.Dialer{Timeout: imapTimeout, Control: checkPublicAddress}
+	tlsConfig := &tls.Config{ServerName: creds.Host}
+
+	var conn net.Conn
+	var err error
+	if creds.UseTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		if errors.Is(err, ErrIMAPAddressNotAllowed) {
+			return nil, ErrIMAPAddressNotAllowed
+		}
+		return nil, fmt.Errorf("подключение к %s: %w", addr, err)
+	}
+
+	deadline := time.Now().Add(imapTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	client := &imapClient{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := client.readResponse()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting.text, "* OK") && !strings.HasPrefix(greeting.text, "* PREAUTH") {
+		conn.Close()
+		return nil, ErrIMAPGreeting
+	}
+
+	// без TLS с порта 143 переходим на шифрование до LOGIN
+	if !creds.UseTLS {
+		if err := client.startTLS(tlsConfig, deadline); err != nil {
+			client.conn.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// checkPublicAddress отказывает в подключении к локальным, частным и служебным адресам
+func checkPublicAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip) {
+		return ErrIMAPAddressNotAllowed
+	}
+	return nil
+}
+
+func (c *imapClient) startTLS(config *tls.Config, deadline time.Time) error {
+	if _, err := c.command("STARTTLS"); err != nil {
+		return ErrIMAPNoTLS
+	}
+	conn := tls.Client(c.conn, config)
+	conn.SetDeadline(deadline)
+	if err := conn.Handshake(); err != nil {
+		return fmt.Errorf("STARTTLS: %w", err)
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	return nil
+}
+
+func (c *imapClient) login(username, password string) error {
+	user, err := imapQuote(username)
+	if err != nil {
+		return err
+	}
+	pass, err := imapQuote(password)
+	if err != nil {
+		return err
+	}
+	_, err = c.command("LOGIN " + user + " " + pass)
+	return err
+}
+
+var uidValidityRe = regexp.MustCompile(`\[UIDVALIDITY (\d+)\]`)
+
+// selectMailbox открывает папку только на чтение (EXAMINE) и возвращает ее UIDVALIDITY
+func (c *imapClient) selectMailbox(mailbox string) (uint32, error) {
+	name, err := imapQuote(mailbox)
+	if err != nil {
+		return 0, err
+	}
+	responses, err := c.command("EXAMINE " + name)
+	if err != nil {
+		return 0, err
+	}
+	for _, resp := range responses {
+		if m := uidValidityRe.FindStringSubmatch(resp.text); m != nil {
+			v, _ := strconv.ParseUint(m[1], 10, 32)
+			return uint32(v), nil
+		}
+	}
+	return 0, nil
+}
+
+func (c *imapClient) searchUIDs(fromUID uint32, since time.Time) ([]uint32, error) {
+	responses, err := c.command(fmt.Sprintf("UID SEARCH UID %d:* SINCE %s", fromUID, since.Format("02-Jan-2006")))
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []uint32
+	for _, resp := range responses {
+		if !strings.HasPrefix(resp.text, "* SEARCH") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.TrimPrefix(resp.text, "* SEARCH")) {
+			uid, err := strconv.ParseUint(field, 10, 32)
+			// "n:*" всегда включает последнее письмо, даже если его UID меньше n
+			if err == nil && uint32(uid) >= fromUID {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	return uids, nil
+}
+
+func (c *imapClient) fetchRaw(uid uint32) ([]byte, error) {
+	responses, err := c.command(fmt.Sprintf("UID FETCH %d BODY.PEEK[]", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, resp := range responses {
+		if strings.Contains(resp.text, "FETCH") && len(resp.literals) > 0 {
+			return resp.literals[0], nil
+		}
+	}
+	return nil, ErrIMAPResponse
+}
+
+func (c *imapClient) logout() {
+	c.command("LOGOUT")
+	c.conn.Close()
+}
+
+// command отправляет команду и читает ответы до строки со своим тегом
+func (c *imapClient) command(cmd string) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("a%03d", c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+cmd+"\r\n"); err != nil {
+		return nil, err
+	}
+
+	var responses []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if status, ok := strings.CutPrefix(resp.text, tag+" "); ok {
+			if !strings.HasPrefix(status, "OK") {
+				// текст команды не выводим: в LOGIN там пароль
+				return nil, fmt.Errorf("%w: %s", ErrIMAPCommand, status)
+			}
+			return responses, nil
+		}
+		responses = append(responses, *resp)
+	}
+}
+
+var literalRe = regexp.MustCompile(`\{(\d+)\}$`)
+
+// readResponse читает строку ответа вместе со всеми литералами внутри нее
+func (c *imapClient) readResponse() (*imapResponse, error) {
+	resp := &imapResponse{}
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		resp.text += line
+
+		m := literalRe.FindStringSubmatch(line)
+		if m == nil {
+			return resp, nil
+		}
+		size, err := strconv.Atoi(m[1])
+		if err != nil || size > imapMaxLiteral {
+			return nil, ErrIMAPResponse
+		}
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return nil, err
+		}
+		resp.literals = append(resp.literals, literal)
+	}
+}
+
+// imapQuote строка в кавычках по правилам IMAP; CR, LF и NUL в quoted string недопустимы
+func imapQuote(s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n\x00") {
+		return "", ErrIMAPUnsafeString
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`, nil
+}
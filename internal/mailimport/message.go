@@ -0,0 +1,161 @@
+package mailimport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// Message письмо, разобранное до того, что нужно парсерам
+type Message struct {
+	ID      string // Message-ID (или хеш письма), ключ для дедупликации черновиков
+	From    string // адрес отправителя в нижнем регистре
+	Subject string
+	Date    time.Time
+	Text    string // text/plain, а если его нет - html без разметки
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// ParseMessage разбирает письмо RFC 822: заголовки в MIME-кодировке, multipart,
+// base64/quoted-printable и кодировки вроде windows-1251 и koi8-r
+func ParseMessage(raw []byte) (*Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("письмо не разобрано: %w", err)
+	}
+
+	result := &Message{
+		ID:      strings.Trim(msg.Header.Get("Message-Id"), "<> "),
+		Subject: decodeHeader(msg.Header.Get("Subject")),
+	}
+	if result.ID == "" {
+		sum := sha256.Sum256(raw)
+		result.ID = hex.EncodeToString(sum[:])
+	}
+
+	parser := &mail.AddressParser{WordDecoder: wordDecoder}
+	if from, err := parser.Parse(msg.Header.Get("From")); err == nil {
+		result.From = strings.ToLower(from.Address)
+	}
+
+	if date, err := msg.Header.Date(); err == nil {
+		result.Date = date
+	} else {
+		result.Date = time.Now()
+	}
+
+	plain, htmlText := extractText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if plain != "" {
+		result.Text = plain
+	} else {
+		result.Text = stripHTML(htmlText)
+	}
+	return result, nil
+}
+
+func decodeHeader(value string) string {
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// extractText возвращает первые найденные text/plain и text/html части письма
+func extractText(contentType, encoding string, body io.Reader) (plain, htmlText string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			p, h := extractText(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if plain == "" {
+				plain = p
+			}
+			if htmlText == "" {
+				htmlText = h
+			}
+		}
+		return plain, htmlText
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", ""
+	}
+
+	text := decodeBody(encoding, params["charset"], body)
+	if mediaType == "text/html" {
+		return "", text
+	}
+	return text, ""
+}
+
+func decodeBody(encoding, charset string, body io.Reader) string {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	if charset != "" {
+		if r, err := charsetReader(charset, body); err == nil {
+			body = r
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, imapMaxLiteral))
+	if err != nil && len(data) == 0 {
+		return ""
+	}
+	return string(data)
+}
+
+// charsetReader перекодирует в UTF-8; неизвестные кодировки - ошибка
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+var (
+	htmlSkipRe  = regexp.MustCompile(`(?is)<(style|script|head)[^>]*>.*?</(style|script|head)>`)
+	htmlBreakRe = regexp.MustCompile(`(?i)<(br|/p|/div|/tr|/li|/h\d)[^>]*>`)
+	htmlTagRe   = regexp.MustCompile(`<[^>]+>`)
+	spacesRe    = regexp.MustCompile(`[ \t\x{00a0}]+`)
+	newlinesRe  = regexp.MustCompile(`\s*\n\s*`)
+)
+
+// stripHTML текст письма без разметки: блоки - отдельными строками, пробелы схлопнуты
+func stripHTML(s string) string {
+	s = htmlSkipRe.ReplaceAllString(s, "")
+	s = htmlBreakRe.ReplaceAllString(s, "\n")
+	s = htmlTagRe.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	s = spacesRe.ReplaceAllString(s, " ")
+	s = newlinesRe.ReplaceAllString(s, "\n")
+	return strings.TrimSpace(s)
+}
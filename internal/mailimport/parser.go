@@ -0,0 +1,65 @@
+package mailimport
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrNoParser  = errors.New("письмо не похоже на уведомление известного банка")
+	ErrNotParsed = errors.New("не удалось распознать операцию в письме")
+)
+
+// Operation операция, распознанная в уведомлении банка или брокера
+type Operation struct {
+	Type        models.TransactionType // income или expense
+	Amount      decimal.Decimal
+	Currency    string
+	Description string // продавец или назначение платежа
+	Date        time.Time
+	CardLast4   string // последние цифры карты/счета, по ним подбирается счет
+}
+
+// Parser разбирает уведомления одного банка
+type Parser interface {
+	Name() string
+	// Match - письмо от этого банка (обычно по домену отправителя)
+	Match(msg *Message) bool
+	Parse(msg *Message) (*Operation, error)
+}
+
+// Registry парсеры, которые пробуются по порядку
+type Registry struct {
+	parsers []Parser
+}
+
+func NewRegistry(parsers ...Parser) *Registry {
+	return &Registry{parsers: parsers}
+}
+
+// DefaultRegistry парсеры поддерживаемых банков
+func DefaultRegistry() *Registry {
+	return NewRegistry(bankParsers()...)
+}
+
+func (r *Registry) Register(parser Parser) {
+	r.parsers = append(r.parsers, parser)
+}
+
+// Parse находит парсер по отправителю и разбирает письмо; возвращает и имя парсера
+func (r *Registry) Parse(msg *Message) (*Operation, string, error) {
+	for _, parser := range r.parsers {
+		if !parser.Match(msg) {
+			continue
+		}
+		op, err := parser.Parse(msg)
+		if err != nil {
+			return nil, parser.Name(), err
+		}
+		return op, parser.Name(), nil
+	}
+	return nil, "", ErrNoParser
+}
@@ -0,0 +1,157 @@
+package market
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// сколько живет составленный кросс-курс: оба плеча - отдельные запросы к провайдерам
+const crossRateTTL = 10 * time.Minute
+
+// опорные валюты кросс-курсов
+const (
+	pivotRUB = "RUB"
+	pivotUSD = "USD"
+)
+
+// rateLookup курс пары у провайдеров без кросс-курсов
+type rateLookup func(ctx context.Context, from, to string) (decimal.Decimal, error)
+
+// isCryptoCurrency валюта - известная монета CoinGecko
+func isCryptoCurrency(currency string) bool {
+	_, ok := cryptoIDMap[currency]
+	return ok
+}
+
+// crossPivots опорные валюты в порядке предпочтения: для фиата RUB (валютный рынок MOEX),
+// если в паре есть монета - USD (к нему CoinGecko котирует все монеты)
+func crossPivots(from, to string) []string {
+	if isCryptoCurrency(from) || isCryptoCurrency(to) {
+		return []string{pivotUSD, pivotRUB}
+	}
+	return []string{pivotRUB, pivotUSD}
+}
+
+// rateProviders провайдеры курсов в порядке опроса: для монет сначала CoinGecko, для пар с рублем - MOEX,
+// дальше остальные. каждый провайдер один раз, хотя зарегистрирован на несколько бирж
+func (mp *MultiProvider) rateProviders(from, to string) []MarketProvider {
+	order := []models.Exchange{models.ExchangeMOEX, models.ExchangeNYSE, models.ExchangeCRYPTO}
+	if isCryptoCurrency(from) || isCryptoCurrency(to) {
+		order = []models.Exchange{models.ExchangeCRYPTO, models.ExchangeMOEX, models.ExchangeNYSE}
+	}
+
+	registered := mp.current()
+	providers := make([]MarketProvider, 0, len(order))
+	for _, exchange := range order {
+		if provider, ok := registered[exchange]; ok {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
+
+// directRate текущий курс пары у первого провайдера, который его знает
+func (mp *MultiProvider) directRate(ctx context.Context, from, to string) (decimal.Decimal, error) {
+	for _, provider := range mp.rateProviders(from, to) {
+		if rate, err := provider.GetCurrencyRate(ctx, from, to); err == nil && rate.IsPositive() {
+			return rate, nil
+		}
+	}
+	return decimal.Zero, fmt.Errorf("не удалось получить курс для %s/%s", from, to)
+}
+
+// historicalRate курс пары на дату у первого провайдера с историей курсов
+func (mp *MultiProvider) historicalRate(ctx context.Context, from, to string, date time.Time) (decimal.Decimal, error) {
+	for _, provider := range mp.rateProviders(from, to) {
+		if lookup, ok := provider.(HistoricalRateLookup); ok {
+			if rate, err := lookup.GetHistoricalCurrencyRate(ctx, from, to, date); err == nil && rate.IsPositive() {
+				return rate, nil
+			}
+		}
+	}
+	return decimal.Zero, fmt.Errorf("нет курса %s/%s на %s", from, to, date.Format("2006-01-02"))
+}
+
+// pairRate прямой курс, а если провайдеры знают только обратную пару - обратный к ней
+func (mp *MultiProvider) pairRate(ctx context.Context, from, to string, lookup rateLookup) (decimal.Decimal, error) {
+	rate, err := lookup(ctx, from, to)
+	if err == nil {
+		return rate, nil
+	}
+	if inverse, ierr := lookup(ctx, to, from); ierr == nil {
+		return decimal.NewFromInt(1).Div(inverse), nil
+	}
+	return decimal.Zero, err
+}
+
+// crossRate курс через первую опорную валюту, для которой нашлись оба плеча
+func (mp *MultiProvider) crossRate(ctx context.Context, from, to string, lookup rateLookup) (decimal.Decimal, error) {
+	for _, pivot := range crossPivots(from, to) {
+		if pivot == from || pivot == to {
+			continue
+		}
+		first, err := mp.pairRate(ctx, from, pivot, lookup)
+		if err != nil {
+			continue
+		}
+		second, err := mp.pairRate(ctx, pivot, to, lookup)
+		if err != nil {
+			continue
+		}
+		return first.Mul(second), nil
+	}
+	return decimal.Zero, fmt.Errorf("нет кросс-курса для %s/%s", from, to)
+}
+
+// rateCache составленные кросс-курсы; курс на дату (date не нулевая) не меняется и живет так же,
+// чтобы кэш не рос без ограничений
+type rateCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	rates map[string]cachedRate
+}
+
+type cachedRate struct {
+	rate    decimal.Decimal
+	expires time.Time
+}
+
+func newRateCache(ttl time.Duration) *rateCache {
+	return &rateCache{ttl: ttl, rates: make(map[string]cachedRate)}
+}
+
+func rateCacheKey(from, to string, date time.Time) string {
+	key := from + "/" + to
+	if !date.IsZero() {
+		key += "@" + date.Format("2006-01-02")
+	}
+	return key
+}
+
+func (c *rateCache) get(from, to string, date time.Time) (decimal.Decimal, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.rates[rateCacheKey(from, to, date)]
+	if !ok || time.Now().After(cached.expires) {
+		return decimal.Zero, false
+	}
+	return cached.rate, true
+}
+
+func (c *rateCache) set(from, to string, date time.Time, rate decimal.Decimal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	// истекшие записи вычищаем при записи, отдельная горутина для этого не нужна
+	for key, cached := range c.rates {
+		if now.After(cached.expires) {
+			delete(c.rates, key)
+		}
+	}
+	c.rates[rateCacheKey(from, to, date)] = cachedRate{rate: rate, expires: now.Add(c.ttl)}
+}
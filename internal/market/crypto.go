@@ -19,6 +19,7 @@ import (
 type CryptoProvider struct {
 	baseURL    string
 	httpClient *http.Client
+	tracker    *callTracker
 }
 
 // NewCryptoProvider создаёт новый экземпляр крипто-провайдера
@@ -28,6 +29,7 @@ func NewCryptoProvider() *CryptoProvider {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		tracker: newCallTracker(),
 	}
 }
 
@@ -58,9 +60,10 @@ type CGCoinMarket struct {
 }
 
 type CGCoinDetail struct {
-	ID          string `json:"id"`
-	Symbol      string `json:"symbol"`
-	Name        string `json:"name"`
+	ID          string   `json:"id"`
+	Symbol      string   `json:"symbol"`
+	Name        string   `json:"name"`
+	Categories  []string `json:"categories"`
 	Description struct {
 		En string `json:"en"`
 	} `json:"description"`
@@ -244,6 +247,28 @@ func (p *CryptoProvider) GetSecurityInfo(ctx context.Context, ticker string, exc
 	return security, nil
 }
 
+// GetSectorInfo берет отрасль из категорий CoinGecko (Layer 1, Stablecoins и т.п.)
+func (p *CryptoProvider) GetSectorInfo(ctx context.Context, ticker string, exchange models.Exchange) (*SectorInfo, error) {
+	coinID := p.tickerToCoinID(ticker)
+
+	url := fmt.Sprintf("%s/coins/%s?localization=false&tickers=false&market_data=false&community_data=false&developer_data=false",
+		p.baseURL, coinID)
+
+	var coin CGCoinDetail
+	if err := p.makeRequest(ctx, url, &coin); err != nil {
+		return nil, fmt.Errorf("криптовалюта не найдена: %s", ticker)
+	}
+
+	info := &SectorInfo{Sector: SectorCrypto}
+	for _, category := range coin.Categories {
+		if category != "" {
+			info.Industry = category
+			break
+		}
+	}
+	return info, nil
+}
+
 func (p *CryptoProvider) GetPriceHistory(ctx context.Context, ticker string, exchange models.Exchange, from, to time.Time) ([]PriceBar, error) {
 	coinID := p.tickerToCoinID(ticker)
 
@@ -352,23 +377,50 @@ func (p *CryptoProvider) GetCurrencyRate(ctx context.Context, from, to string) (
 
 // вспомогательные методы
 // метод запроса
+// Ping проверяет доступность CoinGecko
+func (p *CryptoProvider) Ping(ctx context.Context) error {
+	var result map[string]interface{}
+	return p.makeRequest(ctx, p.baseURL+"/ping", &result)
+}
+
 func (p *CryptoProvider) makeRequest(ctx context.Context, url string, result interface{}) error {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
 
+	if err := p.tracker.allow(); err != nil {
+		return err
+	}
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
+		p.tracker.record(ctx, err)
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("ошибка CoinGecko API: статус %d", resp.StatusCode)
+		statusErr := fmt.Errorf("ошибка CoinGecko API: статус %d", resp.StatusCode)
+		// бесплатный тариф CoinGecko часто отвечает 429
+		if resp.StatusCode == http.StatusTooManyRequests {
+			p.tracker.rateLimited(resp)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			p.tracker.record(ctx, statusErr)
+		} else {
+			p.tracker.record(ctx, nil)
+		}
+		return statusErr
 	}
 
-	return json.NewDecoder(resp.Body).Decode(result)
+	err = json.NewDecoder(resp.Body).Decode(result)
+	p.tracker.record(ctx, err)
+	return err
+}
+
+// Status статистика запросов к CoinGecko
+func (p *CryptoProvider) Status() ProviderStatus {
+	return p.tracker.status(p.GetName(), p.GetSupportedExchanges())
 }
 
 // getFloat безопасно извлекает float из map[string]float64
@@ -387,3 +439,17 @@ func (p *CryptoProvider) tickerToCoinID(ticker string) string {
 	}
 	return strings.ToLower(ticker)
 }
+
+// GetFundamentals капитализация монеты в долларах; мультипликаторов у криптовалют нет
+func (p *CryptoProvider) GetFundamentals(ctx context.Context, ticker string, exchange models.Exchange) (*Fundamentals, error) {
+	url := fmt.Sprintf("%s/coins/markets?vs_currency=usd&ids=%s", p.baseURL, p.tickerToCoinID(ticker))
+
+	var markets []CGCoinMarket
+	if err := p.makeRequest(ctx, url, &markets); err != nil {
+		return nil, err
+	}
+	if len(markets) == 0 {
+		return nil, fmt.Errorf("криптовалюта не найдена: %s", ticker)
+	}
+	return &Fundamentals{MarketCap: positiveDecimal(markets[0].MarketCap)}, nil
+}
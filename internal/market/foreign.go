@@ -0,0 +1,805 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/errgroup"
+)
+
+var ErrForeignNoData = errors.New("провайдер не вернул данных по бумаге")
+
+// ForeignProvider реализует MarketProvider для иностранных бирж (NYSE, NASDAQ, LSE, FRA, HKEX).
+// основной источник - Yahoo Finance (без ключа), при его ошибке запрос повторяется в Twelve Data,
+// если задан TWELVE_DATA_API_KEY
+type ForeignProvider struct {
+	yahooURL    string
+	twelveURL   string
+	twelveKey   string
+	httpClient  *http.Client
+	tracker     *callTracker // запросы к Yahoo
+	twelveCalls *callTracker // запросы к Twelve Data
+}
+
+// NewForeignProvider создаёт провайдер иностранных бирж; пустой twelveKey - без запасного источника
+func NewForeignProvider(yahooURL, twelveURL, twelveKey string) *ForeignProvider {
+	return &ForeignProvider{
+		yahooURL:  strings.TrimSuffix(yahooURL, "/"),
+		twelveURL: strings.TrimSuffix(twelveURL, "/"),
+		twelveKey: twelveKey,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		tracker:     newCallTracker(),
+		twelveCalls: newCallTracker(),
+	}
+}
+
+func (p *ForeignProvider) GetName() string {
+	return "Foreign"
+}
+
+func (p *ForeignProvider) GetSupportedExchanges() []models.Exchange {
+	return []models.Exchange{models.ExchangeNYSE, models.ExchangeNASDAQ, models.ExchangeLSE, models.ExchangeFRA, models.ExchangeHKEX}
+}
+
+func (p *ForeignProvider) IsEnabled() bool {
+	return true
+}
+
+// суффикс тикера в Yahoo и MIC-код биржи для Twelve Data
+var foreignExchanges = map[models.Exchange]struct {
+	yahooSuffix string
+	mic         string
+}{
+	models.ExchangeNYSE:   {"", "XNYS"},
+	models.ExchangeNASDAQ: {"", "XNAS"},
+	models.ExchangeLSE:    {".L", "XLON"},
+	models.ExchangeFRA:    {".F", "XFRA"},
+	models.ExchangeHKEX:   {".HK", "XHKG"},
+}
+
+// коды бирж в поиске Yahoo
+var yahooExchangeCodes = map[string]models.Exchange{
+	"NYQ": models.ExchangeNYSE,
+	"NMS": models.ExchangeNASDAQ,
+	"NGM": models.ExchangeNASDAQ,
+	"NCM": models.ExchangeNASDAQ,
+	"NAS": models.ExchangeNASDAQ,
+	"LSE": models.ExchangeLSE,
+	"FRA": models.ExchangeFRA,
+	"HKG": models.ExchangeHKEX,
+}
+
+// Структуры ответов Yahoo Finance
+type yahooChartResponse struct {
+	Chart struct {
+		Result []yahooChartResult `json:"result"`
+		Error  *struct {
+			Code        string `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	} `json:"chart"`
+}
+
+type yahooChartResult struct {
+	Meta struct {
+		Currency           string  `json:"currency"`
+		Symbol             string  `json:"symbol"`
+		LongName           string  `json:"longName"`
+		ShortName          string  `json:"shortName"`
+		InstrumentType     string  `json:"instrumentType"`
+		RegularMarketPrice float64 `json:"regularMarketPrice"`
+		ChartPreviousClose float64 `json:"chartPreviousClose"`
+		RegularMarketHigh  float64 `json:"regularMarketDayHigh"`
+		RegularMarketLow   float64 `json:"regularMarketDayLow"`
+		RegularMarketVol   int64   `json:"regularMarketVolume"`
+	} `json:"meta"`
+	Timestamp  []int64 `json:"timestamp"`
+	Indicators struct {
+		Quote []struct {
+			Open   []*float64 `json:"open"`
+			High   []*float64 `json:"high"`
+			Low    []*float64 `json:"low"`
+			Close  []*float64 `json:"close"`
+			Volume []*int64   `json:"volume"`
+		} `json:"quote"`
+	} `json:"indicators"`
+	Events struct {
+		Dividends map[string]struct {
+			Amount float64 `json:"amount"`
+			Date   int64   `json:"date"`
+		} `json:"dividends"`
+	} `json:"events"`
+}
+
+type yahooSearchResponse struct {
+	Quotes []struct {
+		Symbol    string `json:"symbol"`
+		ShortName string `json:"shortname"`
+		LongName  string `json:"longname"`
+		QuoteType string `json:"quoteType"`
+		Exchange  string `json:"exchange"`
+	} `json:"quotes"`
+}
+
+// Структуры ответов Twelve Data; числа приходят строками
+type twelveQuote struct {
+	Symbol        string `json:"symbol"`
+	Name          string `json:"name"`
+	Currency      string `json:"currency"`
+	Open          string `json:"open"`
+	High          string `json:"high"`
+	Low           string `json:"low"`
+	Close         string `json:"close"`
+	Volume        string `json:"volume"`
+	PreviousClose string `json:"previous_close"`
+	Change        string `json:"change"`
+	PercentChange string `json:"percent_change"`
+}
+
+type twelveTimeSeries struct {
+	Values []struct {
+		Datetime string `json:"datetime"`
+		Open     string `json:"open"`
+		High     string `json:"high"`
+		Low      string `json:"low"`
+		Close    string `json:"close"`
+		Volume   string `json:"volume"`
+	} `json:"values"`
+}
+
+type twelveSearch struct {
+	Data []struct {
+		Symbol         string `json:"symbol"`
+		InstrumentName string `json:"instrument_name"`
+		MICCode        string `json:"mic_code"`
+		InstrumentType string `json:"instrument_type"`
+		Country        string `json:"country"`
+		Currency       string `json:"currency"`
+	} `json:"data"`
+}
+
+type twelveDividends struct {
+	Dividends []struct {
+		ExDate string  `json:"ex_date"`
+		Amount float64 `json:"amount"`
+	} `json:"dividends"`
+}
+
+type yahooQuoteResponse struct {
+	QuoteResponse struct {
+		Result []struct {
+			MarketCap  float64 `json:"marketCap"`
+			TrailingPE float64 `json:"trailingPE"`
+			EPS        float64 `json:"epsTrailingTwelveMonths"`
+		} `json:"result"`
+	} `json:"quoteResponse"`
+}
+
+type twelveStatistics struct {
+	Statistics struct {
+		ValuationsMetrics struct {
+			MarketCapitalization float64 `json:"market_capitalization"`
+			TrailingPE           float64 `json:"trailing_pe"`
+		} `json:"valuations_metrics"`
+		Financials struct {
+			IncomeStatement struct {
+				DilutedEPS float64 `json:"diluted_eps_ttm"`
+			} `json:"income_statement"`
+		} `json:"financials"`
+	} `json:"statistics"`
+}
+
+// twelveStatus Twelve Data сообщает об ошибке в теле ответа со статусом 200
+type twelveStatus struct {
+	Status  string `json:"status"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (p *ForeignProvider) GetQuote(ctx context.Context, ticker string, exchange models.Exchange) (*models.MarketQuote, error) {
+	quote, err := p.yahooQuote(ctx, ticker, exchange)
+	if err == nil || !p.hasFallback() {
+		return quote, err
+	}
+	return p.twelveQuote(ctx, ticker, exchange)
+}
+
+// GetQuotes пакетного запроса у Yahoo нет, поэтому котировки бумаг запрашиваем параллельно
+func (p *ForeignProvider) GetQuotes(ctx context.Context, tickers []string, exchange models.Exchange) (map[string]*models.MarketQuote, error) {
+	result := make(map[string]*models.MarketQuote)
+
+	var (
+		mu      sync.Mutex
+		lastErr error
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentQuoteRequests)
+	for _, ticker := range tickers {
+		g.Go(func() error {
+			quote, err := p.GetQuote(gctx, ticker, exchange)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+				return nil
+			}
+			result[strings.ToUpper(ticker)] = quote
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	if len(result) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return result, nil
+}
+
+func (p *ForeignProvider) SearchSecurities(ctx context.Context, query string, securityType *models.SecurityType, exchange models.Exchange) ([]models.Security, error) {
+	securities, err := p.yahooSearch(ctx, query, exchange)
+	if err != nil && p.hasFallback() {
+		securities, err = p.twelveSearch(ctx, query, exchange)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if securityType == nil {
+		return securities, nil
+	}
+	filtered := securities[:0]
+	for _, s := range securities {
+		if s.Type == *securityType {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered, nil
+}
+
+func (p *ForeignProvider) GetSecurityInfo(ctx context.Context, ticker string, exchange models.Exchange) (*models.Security, error) {
+	quote, err := p.GetQuote(ctx, ticker, exchange)
+	if err != nil {
+		return nil, fmt.Errorf("бумага не найдена: %s: %w", ticker, err)
+	}
+
+	security := &models.Security{
+		ID:                 uuid.New(),
+		Ticker:             strings.ToUpper(ticker),
+		Name:               strings.ToUpper(ticker),
+		ShortName:          strings.ToUpper(ticker),
+		Type:               models.SecurityTypeStock,
+		Exchange:           exchange,
+		Currency:           exchange.QuoteCurrency(),
+		IsActive:           true,
+		LotSize:            1,
+		MinPriceIncrement:  decimal.NewFromFloat(0.01),
+		LastPrice:          quote.LastPrice,
+		PriceChange:        quote.Change,
+		PriceChangePercent: quote.ChangePercent,
+		Volume:             quote.Volume,
+	}
+
+	// название и тип берем из поиска, если бумага там находится
+	if found, err := p.SearchSecurities(ctx, ticker, nil, exchange); err == nil {
+		for _, s := range found {
+			if strings.EqualFold(s.Ticker, ticker) {
+				security.Name = s.Name
+				security.ShortName = s.ShortName
+				security.Type = s.Type
+				break
+			}
+		}
+	}
+	return security, nil
+}
+
+func (p *ForeignProvider) GetPriceHistory(ctx context.Context, ticker string, exchange models.Exchange, from, to time.Time) ([]PriceBar, error) {
+	bars, err := p.yahooHistory(ctx, ticker, exchange, from, to)
+	if err == nil || !p.hasFallback() {
+		return bars, err
+	}
+	return p.twelveHistory(ctx, ticker, exchange, from, to)
+}
+
+func (p *ForeignProvider) GetDividends(ctx context.Context, ticker string, exchange models.Exchange) ([]models.Dividend, error) {
+	dividends, err := p.yahooDividends(ctx, ticker, exchange)
+	if err == nil || !p.hasFallback() {
+		return dividends, err
+	}
+	return p.twelveDividends(ctx, ticker, exchange)
+}
+
+func (p *ForeignProvider) GetFundamentals(ctx context.Context, ticker string, exchange models.Exchange) (*Fundamentals, error) {
+	fundamentals, err := p.yahooFundamentals(ctx, ticker, exchange)
+	if err == nil || !p.hasFallback() {
+		return fundamentals, err
+	}
+	return p.twelveFundamentals(ctx, ticker, exchange)
+}
+
+func (p *ForeignProvider) GetCurrencyRate(ctx context.Context, from, to string) (decimal.Decimal, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+
+	// валютная пара в Yahoo - тикер вида EURUSD=X
+	var chart yahooChartResponse
+	err := p.yahooRequest(ctx, p.yahooChartURL(from+to+"=X", url.Values{"range": {"1d"}, "interval": {"1d"}}), &chart)
+	if err == nil && len(chart.Chart.Result) > 0 && chart.Chart.Result[0].Meta.RegularMarketPrice > 0 {
+		return decimal.NewFromFloat(chart.Chart.Result[0].Meta.RegularMarketPrice), nil
+	}
+	if !p.hasFallback() {
+		return decimal.Zero, fmt.Errorf("не удалось получить курс для %s/%s", from, to)
+	}
+
+	var rate struct {
+		Rate float64 `json:"rate"`
+	}
+	if err := p.twelveRequest(ctx, "/exchange_rate", url.Values{"symbol": {from + "/" + to}}, &rate); err != nil {
+		return decimal.Zero, err
+	}
+	if rate.Rate <= 0 {
+		return decimal.Zero, fmt.Errorf("не удалось получить курс для %s/%s", from, to)
+	}
+	return decimal.NewFromFloat(rate.Rate), nil
+}
+
+// Ping проверяет доступность Yahoo Finance (при недоступности - Twelve Data)
+func (p *ForeignProvider) Ping(ctx context.Context) error {
+	_, err := p.yahooQuote(ctx, "AAPL", models.ExchangeNASDAQ)
+	if err == nil || !p.hasFallback() {
+		return err
+	}
+	var usage map[string]interface{}
+	return p.twelveRequest(ctx, "/api_usage", url.Values{}, &usage)
+}
+
+// Status статистика запросов к основному источнику
+func (p *ForeignProvider) Status() ProviderStatus {
+	return p.tracker.status(p.GetName(), p.GetSupportedExchanges())
+}
+
+func (p *ForeignProvider) hasFallback() bool {
+	return p.twelveKey != ""
+}
+
+// ---- Yahoo Finance ----
+
+func (p *ForeignProvider) yahooSymbol(ticker string, exchange models.Exchange) string {
+	return strings.ToUpper(ticker) + foreignExchanges[exchange].yahooSuffix
+}
+
+func (p *ForeignProvider) yahooChartURL(symbol string, params url.Values) string {
+	return fmt.Sprintf("%s/v8/finance/chart/%s?%s", p.yahooURL, url.PathEscape(symbol), params.Encode())
+}
+
+func (p *ForeignProvider) yahooChart(ctx context.Context, ticker string, exchange models.Exchange, params url.Values) (*yahooChartResult, error) {
+	var chart yahooChartResponse
+	if err := p.yahooRequest(ctx, p.yahooChartURL(p.yahooSymbol(ticker, exchange), params), &chart); err != nil {
+		return nil, err
+	}
+	if chart.Chart.Error != nil {
+		return nil, fmt.Errorf("ошибка Yahoo Finance: %s", chart.Chart.Error.Description)
+	}
+	if len(chart.Chart.Result) == 0 {
+		return nil, ErrForeignNoData
+	}
+	return &chart.Chart.Result[0], nil
+}
+
+func (p *ForeignProvider) yahooQuote(ctx context.Context, ticker string, exchange models.Exchange) (*models.MarketQuote, error) {
+	result, err := p.yahooChart(ctx, ticker, exchange, url.Values{"range": {"1d"}, "interval": {"1d"}})
+	if err != nil {
+		return nil, err
+	}
+	meta := result.Meta
+	if meta.RegularMarketPrice <= 0 {
+		return nil, ErrForeignNoData
+	}
+
+	// акции LSE котируются в пенсах (GBp)
+	scale := decimal.NewFromInt(1)
+	if meta.Currency == "GBp" || meta.Currency == "GBX" {
+		scale = decimal.NewFromFloat(0.01)
+	}
+
+	quote := &models.MarketQuote{
+		Ticker:    strings.ToUpper(ticker),
+		Exchange:  exchange,
+		LastPrice: decimal.NewFromFloat(meta.RegularMarketPrice).Mul(scale),
+		High:      decimal.NewFromFloat(meta.RegularMarketHigh).Mul(scale),
+		Low:       decimal.NewFromFloat(meta.RegularMarketLow).Mul(scale),
+		Close:     decimal.NewFromFloat(meta.ChartPreviousClose).Mul(scale),
+		Volume:    meta.RegularMarketVol,
+		Timestamp: time.Now(),
+	}
+	if q := result.Indicators.Quote; len(q) > 0 && len(q[0].Open) > 0 && q[0].Open[0] != nil {
+		quote.Open = decimal.NewFromFloat(*q[0].Open[0]).Mul(scale)
+	}
+	if quote.Close.IsPositive() {
+		quote.Change = quote.LastPrice.Sub(quote.Close)
+		quote.ChangePercent = quote.Change.Div(quote.Close).Mul(decimal.NewFromInt(100)).Round(4)
+	}
+	return quote, nil
+}
+
+func (p *ForeignProvider) yahooHistory(ctx context.Context, ticker string, exchange models.Exchange, from, to time.Time) ([]PriceBar, error) {
+	result, err := p.yahooChart(ctx, ticker, exchange, url.Values{
+		"period1":  {strconv.FormatInt(from.Unix(), 10)},
+		"period2":  {strconv.FormatInt(to.Unix(), 10)},
+		"interval": {"1d"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Indicators.Quote) == 0 {
+		return nil, nil
+	}
+
+	scale := decimal.NewFromInt(1)
+	if result.Meta.Currency == "GBp" || result.Meta.Currency == "GBX" {
+		scale = decimal.NewFromFloat(0.01)
+	}
+
+	q := result.Indicators.Quote[0]
+	var bars []PriceBar
+	for i, ts := range result.Timestamp {
+		// в дни без торгов Yahoo присылает null
+		if i >= len(q.Close) || q.Close[i] == nil {
+			continue
+		}
+		bar := PriceBar{
+			Date:  truncateUTCDay(time.Unix(ts, 0)),
+			Close: decimal.NewFromFloat(*q.Close[i]).Mul(scale),
+		}
+		bar.Open, bar.High, bar.Low = bar.Close, bar.Close, bar.Close
+		if i < len(q.Open) && q.Open[i] != nil {
+			bar.Open = decimal.NewFromFloat(*q.Open[i]).Mul(scale)
+		}
+		if i < len(q.High) && q.High[i] != nil {
+			bar.High = decimal.NewFromFloat(*q.High[i]).Mul(scale)
+		}
+		if i < len(q.Low) && q.Low[i] != nil {
+			bar.Low = decimal.NewFromFloat(*q.Low[i]).Mul(scale)
+		}
+		if i < len(q.Volume) && q.Volume[i] != nil {
+			bar.Volume = *q.Volume[i]
+		}
+		bars = append(bars, bar)
+	}
+	return bars, nil
+}
+
+func (p *ForeignProvider) yahooDividends(ctx context.Context, ticker string, exchange models.Exchange) ([]models.Dividend, error) {
+	result, err := p.yahooChart(ctx, ticker, exchange, url.Values{"range": {"5y"}, "interval": {"1mo"}, "events": {"div"}})
+	if err != nil {
+		return nil, err
+	}
+
+	currency := exchange.QuoteCurrency()
+	var dividends []models.Dividend
+	for _, d := range result.Events.Dividends {
+		date := truncateUTCDay(time.Unix(d.Date, 0))
+		dividends = append(dividends, models.Dividend{
+			ID:           uuid.New(),
+			ExDate:       date,
+			RecordDate:   date,
+			Amount:       decimal.NewFromFloat(d.Amount),
+			Currency:     currency,
+			DividendType: "regular",
+		})
+	}
+	sort.Slice(dividends, func(i, j int) bool { return dividends[i].ExDate.Before(dividends[j].ExDate) })
+	return dividends, nil
+}
+
+func (p *ForeignProvider) yahooFundamentals(ctx context.Context, ticker string, exchange models.Exchange) (*Fundamentals, error) {
+	params := url.Values{"symbols": {p.yahooSymbol(ticker, exchange)}}
+	var resp yahooQuoteResponse
+	if err := p.yahooRequest(ctx, p.yahooURL+"/v7/finance/quote?"+params.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.QuoteResponse.Result) == 0 {
+		return nil, ErrForeignNoData
+	}
+	q := resp.QuoteResponse.Result[0]
+	return &Fundamentals{
+		MarketCap: positiveDecimal(q.MarketCap),
+		PERatio:   optionalDecimal(q.TrailingPE),
+		EPS:       optionalDecimal(q.EPS),
+	}, nil
+}
+
+func (p *ForeignProvider) yahooSearch(ctx context.Context, query string, exchange models.Exchange) ([]models.Security, error) {
+	params := url.Values{"q": {query}, "quotesCount": {"20"}, "newsCount": {"0"}}
+	var search yahooSearchResponse
+	if err := p.yahooRequest(ctx, p.yahooURL+"/v1/finance/search?"+params.Encode(), &search); err != nil {
+		return nil, err
+	}
+
+	var securities []models.Security
+	for _, q := range search.Quotes {
+		found, ok := yahooExchangeCodes[q.Exchange]
+		if !ok || (exchange != "" && found != exchange) {
+			continue
+		}
+		securityType, ok := yahooSecurityType(q.QuoteType)
+		if !ok {
+			continue
+		}
+
+		name := q.LongName
+		if name == "" {
+			name = q.ShortName
+		}
+		securities = append(securities, models.Security{
+			ID:        uuid.New(),
+			Ticker:    strings.TrimSuffix(q.Symbol, foreignExchanges[found].yahooSuffix),
+			Name:      name,
+			ShortName: q.ShortName,
+			Type:      securityType,
+			Exchange:  found,
+			Currency:  found.QuoteCurrency(),
+			IsActive:  true,
+			LotSize:   1,
+		})
+	}
+	return securities, nil
+}
+
+func yahooSecurityType(quoteType string) (models.SecurityType, bool) {
+	switch quoteType {
+	case "EQUITY":
+		return models.SecurityTypeStock, true
+	case "ETF":
+		return models.SecurityTypeETF, true
+	case "MUTUALFUND":
+		return models.SecurityTypeMutualFund, true
+	}
+	return "", false
+}
+
+func (p *ForeignProvider) yahooRequest(ctx context.Context, url string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	// без User-Agent Yahoo отвечает 429
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; FinTracker)")
+	return doTrackedRequest(ctx, p.httpClient, p.tracker, req, "Yahoo Finance", result)
+}
+
+// ---- Twelve Data ----
+
+func (p *ForeignProvider) twelveParams(ticker string, exchange models.Exchange) url.Values {
+	return url.Values{"symbol": {strings.ToUpper(ticker)}, "mic_code": {foreignExchanges[exchange].mic}}
+}
+
+func (p *ForeignProvider) twelveQuote(ctx context.Context, ticker string, exchange models.Exchange) (*models.MarketQuote, error) {
+	var q twelveQuote
+	if err := p.twelveRequest(ctx, "/quote", p.twelveParams(ticker, exchange), &q); err != nil {
+		return nil, err
+	}
+
+	quote := &models.MarketQuote{
+		Ticker:        strings.ToUpper(ticker),
+		Exchange:      exchange,
+		LastPrice:     parseDecimal(q.Close),
+		Open:          parseDecimal(q.Open),
+		High:          parseDecimal(q.High),
+		Low:           parseDecimal(q.Low),
+		Close:         parseDecimal(q.PreviousClose),
+		Change:        parseDecimal(q.Change),
+		ChangePercent: parseDecimal(q.PercentChange),
+		Timestamp:     time.Now(),
+	}
+	quote.Volume, _ = strconv.ParseInt(q.Volume, 10, 64)
+	if !quote.LastPrice.IsPositive() {
+		return nil, ErrForeignNoData
+	}
+	return quote, nil
+}
+
+func (p *ForeignProvider) twelveHistory(ctx context.Context, ticker string, exchange models.Exchange, from, to time.Time) ([]PriceBar, error) {
+	params := p.twelveParams(ticker, exchange)
+	params.Set("interval", "1day")
+	params.Set("start_date", from.Format("2006-01-02"))
+	params.Set("end_date", to.Format("2006-01-02"))
+	params.Set("outputsize", "5000")
+
+	var series twelveTimeSeries
+	if err := p.twelveRequest(ctx, "/time_series", params, &series); err != nil {
+		return nil, err
+	}
+
+	bars := make([]PriceBar, 0, len(series.Values))
+	for _, v := range series.Values {
+		date, err := time.Parse("2006-01-02", v.Datetime)
+		if err != nil {
+			continue
+		}
+		volume, _ := strconv.ParseInt(v.Volume, 10, 64)
+		bars = append(bars, PriceBar{
+			Date:   date,
+			Open:   parseDecimal(v.Open),
+			High:   parseDecimal(v.High),
+			Low:    parseDecimal(v.Low),
+			Close:  parseDecimal(v.Close),
+			Volume: volume,
+		})
+	}
+	// Twelve Data отдает от новых к старым
+	sort.Slice(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+	return bars, nil
+}
+
+func (p *ForeignProvider) twelveDividends(ctx context.Context, ticker string, exchange models.Exchange) ([]models.Dividend, error) {
+	params := p.twelveParams(ticker, exchange)
+	params.Set("range", "5y")
+
+	var result twelveDividends
+	if err := p.twelveRequest(ctx, "/dividends", params, &result); err != nil {
+		return nil, err
+	}
+
+	currency := exchange.QuoteCurrency()
+	var dividends []models.Dividend
+	for _, d := range result.Dividends {
+		date, err := time.Parse("2006-01-02", d.ExDate)
+		if err != nil {
+			continue
+		}
+		dividends = append(dividends, models.Dividend{
+			ID:           uuid.New(),
+			ExDate:       date,
+			RecordDate:   date,
+			Amount:       decimal.NewFromFloat(d.Amount),
+			Currency:     currency,
+			DividendType: "regular",
+		})
+	}
+	sort.Slice(dividends, func(i, j int) bool { return dividends[i].ExDate.Before(dividends[j].ExDate) })
+	return dividends, nil
+}
+
+func (p *ForeignProvider) twelveFundamentals(ctx context.Context, ticker string, exchange models.Exchange) (*Fundamentals, error) {
+	var result twelveStatistics
+	if err := p.twelveRequest(ctx, "/statistics", p.twelveParams(ticker, exchange), &result); err != nil {
+		return nil, err
+	}
+	stats := result.Statistics
+	return &Fundamentals{
+		MarketCap: positiveDecimal(stats.ValuationsMetrics.MarketCapitalization),
+		PERatio:   optionalDecimal(stats.ValuationsMetrics.TrailingPE),
+		EPS:       optionalDecimal(stats.Financials.IncomeStatement.DilutedEPS),
+	}, nil
+}
+
+func (p *ForeignProvider) twelveSearch(ctx context.Context, query string, exchange models.Exchange) ([]models.Security, error) {
+	var search twelveSearch
+	if err := p.twelveRequest(ctx, "/symbol_search", url.Values{"symbol": {query}, "outputsize": {"30"}}, &search); err != nil {
+		return nil, err
+	}
+
+	var securities []models.Security
+	for _, d := range search.Data {
+		found, ok := exchangeByMIC(d.MICCode)
+		if !ok || (exchange != "" && found != exchange) {
+			continue
+		}
+		securityType := models.SecurityTypeStock
+		switch d.InstrumentType {
+		case "ETF":
+			securityType = models.SecurityTypeETF
+		case "Mutual Fund":
+			securityType = models.SecurityTypeMutualFund
+		}
+		currency := d.Currency
+		if currency == "" || currency == "GBp" {
+			currency = found.QuoteCurrency()
+		}
+		securities = append(securities, models.Security{
+			ID:        uuid.New(),
+			Ticker:    d.Symbol,
+			Name:      d.InstrumentName,
+			ShortName: d.Symbol,
+			Type:      securityType,
+			Exchange:  found,
+			Currency:  currency,
+			IsActive:  true,
+			LotSize:   1,
+		})
+	}
+	return securities, nil
+}
+
+// exchangeByMIC биржа по MIC-коду; у NASDAQ их несколько по сегментам рынка
+func exchangeByMIC(mic string) (models.Exchange, bool) {
+	switch mic {
+	case "XNGS", "XNMS", "XNCM":
+		return models.ExchangeNASDAQ, true
+	}
+	for exchange, codes := range foreignExchanges {
+		if codes.mic == mic {
+			return exchange, true
+		}
+	}
+	return "", false
+}
+
+func (p *ForeignProvider) twelveRequest(ctx context.Context, path string, params url.Values, result interface{}) error {
+	params.Set("apikey", p.twelveKey)
+	req, err := http.NewRequestWithContext(ctx, "GET", p.twelveURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	var raw json.RawMessage
+	if err := doTrackedRequest(ctx, p.httpClient, p.twelveCalls, req, "Twelve Data", &raw); err != nil {
+		return err
+	}
+	var status twelveStatus
+	if err := json.Unmarshal(raw, &status); err == nil && status.Status == "error" {
+		return fmt.Errorf("ошибка Twelve Data: %d %s", status.Code, status.Message)
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// doTrackedRequest выполняет GET и учитывает исход в трекере провайдера, как makeRequest у CoinGecko
+func doTrackedRequest(ctx context.Context, client *http.Client, tracker *callTracker, req *http.Request, source string, result interface{}) error {
+	if err := tracker.allow(); err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		tracker.record(ctx, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		statusErr := fmt.Errorf("ошибка %s: статус %d", source, resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			tracker.rateLimited(resp)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			tracker.record(ctx, statusErr)
+		} else {
+			tracker.record(ctx, nil)
+		}
+		return statusErr
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(result)
+	tracker.record(ctx, err)
+	return err
+}
+
+func parseDecimal(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
+
+func truncateUTCDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
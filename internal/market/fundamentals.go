@@ -0,0 +1,52 @@
+package market
+
+import (
+	"context"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Fundamentals мультипликаторы эмитента в валюте бумаги; nil - провайдер поле не отдает
+type Fundamentals struct {
+	MarketCap *decimal.Decimal
+	PERatio   *decimal.Decimal
+	EPS       *decimal.Decimal
+}
+
+// FundamentalsLookup - необязательная возможность провайдера: капитализация, P/E и EPS.
+// MOEX ISS отдает только капитализацию, CoinGecko - капитализацию монеты
+type FundamentalsLookup interface {
+	GetFundamentals(ctx context.Context, ticker string, exchange models.Exchange) (*Fundamentals, error)
+}
+
+// GetFundamentals запрашивает мультипликаторы у провайдера биржи; nil без ошибки - провайдер их не отдает
+func (mp *MultiProvider) GetFundamentals(ctx context.Context, ticker string, exchange models.Exchange) (*Fundamentals, error) {
+	provider, err := mp.GetProvider(exchange)
+	if err != nil {
+		return nil, err
+	}
+	lookup, ok := provider.(FundamentalsLookup)
+	if !ok {
+		return nil, nil
+	}
+	return lookup.GetFundamentals(ctx, ticker, exchange)
+}
+
+// positiveDecimal nil для нуля и отрицательных значений: провайдеры отдают 0 вместо "нет данных"
+func positiveDecimal(v float64) *decimal.Decimal {
+	if v <= 0 {
+		return nil
+	}
+	d := decimal.NewFromFloat(v)
+	return &d
+}
+
+// optionalDecimal nil только для нуля: EPS и P/E бывают отрицательными у убыточных компаний
+func optionalDecimal(v float64) *decimal.Decimal {
+	if v == 0 {
+		return nil
+	}
+	d := decimal.NewFromFloat(v)
+	return &d
+}
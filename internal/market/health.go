@@ -0,0 +1,187 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+)
+
+var (
+	ErrCircuitOpen = errors.New("провайдер временно отключен после серии ошибок")
+	ErrRateLimited = errors.New("провайдер ограничил частоту запросов")
+)
+
+const (
+	healthWindowSize       = 100              // по скольким последним запросам считаем долю ошибок
+	circuitFailureLimit    = 5                // подряд неудачных запросов до размыкания
+	circuitCooldown        = time.Minute      // сколько провайдер отдыхает после размыкания
+	defaultRateLimitPause  = time.Minute      // пауза после 429 без Retry-After
+	maxRateLimitPause      = 10 * time.Minute // верхняя граница для Retry-After
+	circuitStateClosed     = "closed"
+	circuitStateOpen       = "open"
+	circuitStateHalfOpen   = "half_open"
+	providerStatusDisabled = "disabled"
+)
+
+// ProviderStatus состояние провайдера рыночных данных для диагностики
+type ProviderStatus struct {
+	Name             string            `json:"name"`
+	Enabled          bool              `json:"enabled"`
+	Exchanges        []models.Exchange `json:"exchanges"`
+	Requests         int               `json:"requests"` // в окне последних запросов
+	Errors           int               `json:"errors"`
+	ErrorRate        float64           `json:"error_rate"` // доля ошибок в окне, 0..1
+	LastSuccessAt    *time.Time        `json:"last_success_at"`
+	LastErrorAt      *time.Time        `json:"last_error_at"`
+	LastError        string            `json:"last_error,omitempty"`
+	RateLimited      bool              `json:"rate_limited"`
+	RateLimitedUntil *time.Time        `json:"rate_limited_until,omitempty"`
+	Circuit          string            `json:"circuit"` // closed, open, half_open, disabled
+	CircuitOpenUntil *time.Time        `json:"circuit_open_until,omitempty"`
+}
+
+// StatusReporter - необязательная возможность провайдера: статистика его запросов
+type StatusReporter interface {
+	Status() ProviderStatus
+}
+
+// callTracker считает исходы HTTP-запросов провайдера и работает как автомат-предохранитель:
+// после circuitFailureLimit ошибок подряд запросы не отправляются circuitCooldown,
+// затем пропускается один пробный запрос
+type callTracker struct {
+	mu sync.Mutex
+
+	window []bool // true - ошибка; кольцевой буфер
+	next   int
+
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+
+	failures         int // подряд
+	openUntil        time.Time
+	probing          bool // в полуоткрытом состоянии уже идет пробный запрос
+	rateLimitedUntil time.Time
+}
+
+func newCallTracker() *callTracker {
+	return &callTracker{window: make([]bool, 0, healthWindowSize)}
+}
+
+// allow решает, можно ли сейчас отправить запрос
+func (t *callTracker) allow() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(t.rateLimitedUntil) {
+		return ErrRateLimited
+	}
+	if t.failures < circuitFailureLimit {
+		return nil
+	}
+	if now.Before(t.openUntil) || t.probing {
+		return ErrCircuitOpen
+	}
+	t.probing = true
+	return nil
+}
+
+// record запоминает исход запроса. отмена запроса вызывающим - не ошибка провайдера
+func (t *callTracker) record(ctx context.Context, err error) {
+	if err != nil && ctx.Err() != nil {
+		t.mu.Lock()
+		t.probing = false
+		t.mu.Unlock()
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	failed := err != nil
+	if len(t.window) < healthWindowSize {
+		t.window = append(t.window, failed)
+	} else {
+		t.window[t.next] = failed
+	}
+	t.next = (t.next + 1) % healthWindowSize
+	t.probing = false
+
+	now := time.Now()
+	if !failed {
+		t.lastSuccess = now
+		t.failures = 0
+		return
+	}
+
+	t.lastFailure = now
+	t.lastError = err.Error()
+	t.failures++
+	if t.failures >= circuitFailureLimit {
+		t.openUntil = now.Add(circuitCooldown)
+	}
+}
+
+// rateLimited ставит паузу по ответу 429 (Retry-After в секундах)
+func (t *callTracker) rateLimited(resp *http.Response) {
+	pause := defaultRateLimitPause
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		pause = min(time.Duration(seconds)*time.Second, maxRateLimitPause)
+	}
+
+	t.mu.Lock()
+	t.rateLimitedUntil = time.Now().Add(pause)
+	t.mu.Unlock()
+}
+
+func (t *callTracker) status(name string, exchanges []models.Exchange) ProviderStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := ProviderStatus{
+		Name:      name,
+		Enabled:   true,
+		Exchanges: exchanges,
+		Requests:  len(t.window),
+		LastError: t.lastError,
+		Circuit:   circuitStateClosed,
+	}
+	for _, failed := range t.window {
+		if failed {
+			status.Errors++
+		}
+	}
+	if status.Requests > 0 {
+		status.ErrorRate = float64(status.Errors) / float64(status.Requests)
+	}
+	if !t.lastSuccess.IsZero() {
+		lastSuccess := t.lastSuccess
+		status.LastSuccessAt = &lastSuccess
+	}
+	if !t.lastFailure.IsZero() {
+		lastFailure := t.lastFailure
+		status.LastErrorAt = &lastFailure
+	}
+
+	now := time.Now()
+	if now.Before(t.rateLimitedUntil) {
+		until := t.rateLimitedUntil
+		status.RateLimited = true
+		status.RateLimitedUntil = &until
+	}
+	if t.failures >= circuitFailureLimit {
+		status.Circuit = circuitStateHalfOpen
+		if now.Before(t.openUntil) {
+			until := t.openUntil
+			status.Circuit = circuitStateOpen
+			status.CircuitOpenUntil = &until
+		}
+	}
+	return status
+}
@@ -0,0 +1,174 @@
+package market
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ошибки разбора ответов MOEX ISS (проверяются через errors.Is)
+var (
+	ErrISSStatus         = errors.New("ISS ответил ошибкой")
+	ErrISSDecode         = errors.New("не удалось разобрать ответ ISS")
+	ErrISSBlockMissing   = errors.New("в ответе ISS нет ожидаемого блока")
+	ErrISSBlockEmpty     = errors.New("ISS вернул пустой блок")
+	ErrISSColumnsMissing = errors.New("в блоке ISS нет обязательных колонок")
+	ErrISSMalformedRow   = errors.New("строка блока ISS не совпадает с колонками")
+)
+
+// ISSError ошибка ответа ISS с адресом запроса и блоком, на котором споткнулись
+type ISSError struct {
+	URL        string
+	Block      string
+	StatusCode int
+	Columns    []string // отсутствующие колонки
+	Err        error
+}
+
+func (e *ISSError) Error() string {
+	var b strings.Builder
+	b.WriteString("MOEX ISS: ")
+	b.WriteString(e.Err.Error())
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, " (HTTP %d)", e.StatusCode)
+	}
+	if e.Block != "" {
+		fmt.Fprintf(&b, ", блок %q", e.Block)
+	}
+	if len(e.Columns) > 0 {
+		fmt.Fprintf(&b, ", колонки %s", strings.Join(e.Columns, ", "))
+	}
+	b.WriteString(", запрос ")
+	b.WriteString(e.URL)
+	return b.String()
+}
+
+func (e *ISSError) Unwrap() error {
+	return e.Err
+}
+
+// ISSBlock табличный блок ответа ISS: названия колонок и строки значений
+type ISSBlock struct {
+	Columns []string        `json:"columns"`
+	Data    [][]interface{} `json:"data"`
+
+	present bool // блок был в ответе (отличаем отсутствующий блок от пустого)
+}
+
+func (b *ISSBlock) UnmarshalJSON(raw []byte) error {
+	if string(raw) == "null" {
+		return nil
+	}
+
+	var block struct {
+		Columns []string        `json:"columns"`
+		Data    [][]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return err
+	}
+
+	b.Columns = block.Columns
+	b.Data = block.Data
+	b.present = true
+	return nil
+}
+
+// issExpect что должно быть в ответе: блок, обязательные колонки и можно ли ему быть пустым
+type issExpect struct {
+	block      string
+	columns    []string
+	allowEmpty bool
+}
+
+// block блок ответа по имени в ISS
+func (r *MOEXResponse) block(name string) *ISSBlock {
+	switch name {
+	case "securities":
+		return &r.Securities
+	case "marketdata":
+		return &r.Marketdata
+	case "history":
+		return &r.History
+	case "dividends":
+		return &r.Dividends
+	case "coupons":
+		return &r.Coupons
+	case "amortizations":
+		return &r.Amortizations
+	case "description":
+		return &r.Description
+	}
+	return nil
+}
+
+// validate проверяет ответ по ожиданиям вызывающего метода
+func (r *MOEXResponse) validate(url string, expects []issExpect) error {
+	for _, expect := range expects {
+		block := r.block(expect.block)
+		if block == nil || !block.present {
+			return &ISSError{URL: url, Block: expect.block, Err: ErrISSBlockMissing}
+		}
+
+		if !expect.allowEmpty && len(block.Data) == 0 {
+			return &ISSError{URL: url, Block: expect.block, Err: ErrISSBlockEmpty}
+		}
+
+		// колонки проверяем только когда есть данные: пустые блоки ISS иногда отдает без колонок
+		if len(block.Data) == 0 {
+			continue
+		}
+
+		cols := makeColumnIndex(block.Columns)
+		var missing []string
+		for _, col := range expect.columns {
+			if _, ok := cols[col]; !ok {
+				missing = append(missing, col)
+			}
+		}
+		if len(missing) > 0 {
+			return &ISSError{URL: url, Block: expect.block, Columns: missing, Err: ErrISSColumnsMissing}
+		}
+
+		for _, row := range block.Data {
+			if len(row) != len(block.Columns) {
+				return &ISSError{URL: url, Block: expect.block, Err: ErrISSMalformedRow}
+			}
+		}
+	}
+
+	return nil
+}
+
+// descriptionRow разворачивает блок description (строки name/value) в одну строку с колонками
+// в нижнем регистре; числовые значения приводятся к float64, как в остальных блоках
+func descriptionRow(block ISSBlock) ([]interface{}, map[string]int) {
+	cols := makeColumnIndex(block.Columns)
+	nameIdx, valueIdx := cols["name"], cols["value"]
+	typeIdx, hasType := cols["type"]
+
+	row := make([]interface{}, 0, len(block.Data))
+	index := make(map[string]int, len(block.Data))
+	for _, data := range block.Data {
+		name, _ := data[nameIdx].(string)
+		if name == "" {
+			continue
+		}
+
+		value := data[valueIdx]
+		if s, ok := value.(string); ok && hasType {
+			if kind, _ := data[typeIdx].(string); kind == "number" {
+				if f, err := strconv.ParseFloat(s, 64); err == nil {
+					value = f
+				}
+			}
+		}
+
+		index[strings.ToLower(name)] = len(row)
+		row = append(row, value)
+	}
+
+	return row, index
+}
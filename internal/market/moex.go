@@ -6,17 +6,21 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
+	"golang.org/x/sync/errgroup"
 )
 
 type MOEXProvider struct {
 	baseURL    string
 	httpClient *http.Client
+	tracker    *callTracker
 }
 
 func NewMOEXProvider(baseURL string) *MOEXProvider {
@@ -29,6 +33,7 @@ func NewMOEXProvider(baseURL string) *MOEXProvider {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		tracker: newCallTracker(),
 	}
 }
 
@@ -46,43 +51,26 @@ func (p *MOEXProvider) IsEnabled() bool {
 
 // MOEXResponse представляет стандартную структуру ответа MOEX ISS API
 type MOEXResponse struct {
-	Securities struct {
-		Columns []string        `json:"columns"`
-		Data    [][]interface{} `json:"data"`
-	} `json:"secutiries"`
-	Marketdata struct {
-		Columns []string        `json:"columns"`
-		Data    [][]interface{} `json:"data"`
-	} `json:"marketdata"`
-	History struct {
-		Columns []string        `json:"columns"`
-		Data    [][]interface{} `json:"data"`
-	} `json:"history"`
-	Dividends struct {
-		Columns []string        `json:"columns"`
-		Data    [][]interface{} `json:"data"`
-	} `json:"dividends"`
-	Coupons struct {
-		Columns []string        `json:"columns"`
-		Data    [][]interface{} `json:"data"`
-	} `json:"coupons"`
+	Securities    ISSBlock `json:"securities"`
+	Marketdata    ISSBlock `json:"marketdata"`
+	History       ISSBlock `json:"history"`
+	Dividends     ISSBlock `json:"dividends"`
+	Coupons       ISSBlock `json:"coupons"`
+	Amortizations ISSBlock `json:"amortizations"`
+	Description   ISSBlock `json:"description"`
 }
 
 func (p *MOEXProvider) GetQuote(ctx context.Context, ticker string, exchange models.Exchange) (*models.MarketQuote, error) {
 	// определяем торговую систему, рынок и редим торгов для url
 	engine, market, board := p.detectMarket(ticker)
 
-	url := fmt.Sprintf("%s/engines/%s/markets/%s/boards/%s/securities/%s.json&iss.meta=off", p.baseURL, engine, market, board, ticker)
+	url := fmt.Sprintf("%s/engines/%s/markets/%s/boards/%s/securities/%s.json?iss.meta=off", p.baseURL, engine, market, board, ticker)
 
-	resp, err := p.makeRequest(ctx, url)
+	resp, err := p.makeRequest(ctx, url, issExpect{block: "marketdata"})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(resp.Marketdata.Data) == 0 {
-		return nil, fmt.Errorf("нет рыночных данных для %s", ticker)
-	}
-
 	mdCols := makeColumnIndex(resp.Marketdata.Columns)
 	data := resp.Marketdata.Data[0] // для одной бумаги один срез данных
 
@@ -109,6 +97,10 @@ func (p *MOEXProvider) GetQuote(ctx context.Context, ticker string, exchange mod
 		}
 	}
 
+	if market == "bonds" && len(resp.Securities.Data) > 0 {
+		p.applyBondPricing(quote, resp.Securities.Data[0], makeColumnIndex(resp.Securities.Columns))
+	}
+
 	return quote, nil
 }
 
@@ -128,90 +120,149 @@ func (p *MOEXProvider) GetQuotes(ctx context.Context, tickers []string, exchange
 		grouped[key] = append(grouped[key], ticker)
 	}
 
-	// делаем отдельный запрос для каждой группы
-	var lastErr error
+	// запросы по группам независимы, поэтому делаем их параллельно
+	var (
+		mu      sync.Mutex
+		lastErr error
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentQuoteRequests)
 	for key, groupTickers := range grouped {
-		tickerList := strings.Join(groupTickers, ",")
+		g.Go(func() error {
+			quotes, err := p.getGroupQuotes(gctx, key.engine, key.market, groupTickers, exchange)
 
-		url := fmt.Sprintf("%s/engines/%s/markets/%s/securities.json?iss.meta=off&securities=%s",
-			p.baseURL, key.engine, key.market, tickerList)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				// Запоминаем последнюю ошибку, продолжаем с другими группами
+				lastErr = fmt.Errorf("ошибка получения котировок %s/%s: %w", key.engine, key.market, err)
+				return nil
+			}
+			for ticker, quote := range quotes {
+				result[ticker] = quote
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
 
-		resp, err := p.makeRequest(ctx, url)
-		if err != nil {
-			// Запоминаем последнюю ошибку, продолжаем с другими группами
-			lastErr = fmt.Errorf("ошибка получения котировок %s/%s: %w", key.engine, key.market, err)
-			continue
-		}
+	// если ничего не получили и была ошибка — возвращаем её
+	if len(result) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
 
-		mdCols := makeColumnIndex(resp.Marketdata.Columns)
-		secCols := makeColumnIndex(resp.Securities.Columns)
+	return result, nil
+}
 
-		for _, data := range resp.Marketdata.Data {
-			var ticker string
-			if secIdx, ok := mdCols["SECID"]; ok && secIdx < len(data) {
-				ticker, _ = data[secIdx].(string)
-			}
-			if ticker == "" {
-				continue // пропускаем записи без тикера(защита от битых данных)
-			}
+// getGroupQuotes котировки бумаг одного рынка (engine/market) одним запросом
+func (p *MOEXProvider) getGroupQuotes(ctx context.Context, engine, market string, tickers []string, exchange models.Exchange) (map[string]*models.MarketQuote, error) {
+	result := make(map[string]*models.MarketQuote)
+	tickerList := strings.Join(tickers, ",")
 
-			quote := &models.MarketQuote{
-				Ticker:    ticker,
-				Exchange:  exchange,
-				Timestamp: time.Now(),
-			}
+	url := fmt.Sprintf("%s/engines/%s/markets/%s/securities.json?iss.meta=off&securities=%s",
+		p.baseURL, engine, market, tickerList)
 
-			quote.LastPrice = p.getDecimal(data, mdCols, "LAST", "CURRENTVALUE")
-			quote.Change = p.getDecimal(data, mdCols, "CHANGE")
-			quote.ChangePercent = p.getDecimal(data, mdCols, "LASTTOPREVPRICE")
-			quote.Open = p.getDecimal(data, mdCols, "OPEN", "OPENPERIODPRICE")
-			quote.High = p.getDecimal(data, mdCols, "HIGH")
-			quote.Low = p.getDecimal(data, mdCols, "LOW")
-			quote.Close = p.getDecimal(data, mdCols, "CLOSE", "CLOSEPRICE", "LCLOSEPRICE")
-			quote.Bid = p.getDecimal(data, mdCols, "BID")
-			quote.Ask = p.getDecimal(data, mdCols, "OFFER")
-
-			if v, ok := mdCols["VOLTODAY"]; ok && v < len(data) {
-				if vol, ok := data[v].(float64); ok {
-					quote.Volume = int64(vol)
-				}
-			}
+	resp, err := p.makeRequest(ctx, url,
+		issExpect{block: "marketdata", columns: []string{"SECID"}},
+		issExpect{block: "securities", columns: []string{"SECID"}, allowEmpty: true},
+	)
+	if err != nil {
+		return nil, err
+	}
 
-			result[ticker] = quote
+	mdCols := makeColumnIndex(resp.Marketdata.Columns)
+	secCols := makeColumnIndex(resp.Securities.Columns)
+
+	for _, data := range resp.Marketdata.Data {
+		var ticker string
+		if secIdx, ok := mdCols["SECID"]; ok && secIdx < len(data) {
+			ticker, _ = data[secIdx].(string)
+		}
+		if ticker == "" {
+			continue // пропускаем записи без тикера(защита от битых данных)
 		}
 
-		// дополняем информацией из данных о ценных бумагах
-		for _, data := range resp.Securities.Data {
-			var ticker string
-			if secIdx, ok := secCols["SECID"]; ok && secIdx < len(data) {
-				ticker, _ = data[secIdx].(string)
-			}
-			if ticker == "" {
-				continue // пропускаем записи без тикера(защита от битых данных)
-			}
+		quote := &models.MarketQuote{
+			Ticker:    ticker,
+			Exchange:  exchange,
+			Timestamp: time.Now(),
+		}
 
-			if quote, exists := result[ticker]; exists {
-				if quote.LastPrice.IsZero() {
-					quote.LastPrice = p.getDecimal(data, secCols, "PREVPRICE", "PREVADMITTEDQUOTE") // фоллбэк: используем цену закрытия если тек котирвоки нет
-				}
+		quote.LastPrice = p.getDecimal(data, mdCols, "LAST", "CURRENTVALUE")
+		quote.Change = p.getDecimal(data, mdCols, "CHANGE")
+		quote.ChangePercent = p.getDecimal(data, mdCols, "LASTTOPREVPRICE")
+		quote.Open = p.getDecimal(data, mdCols, "OPEN", "OPENPERIODPRICE")
+		quote.High = p.getDecimal(data, mdCols, "HIGH")
+		quote.Low = p.getDecimal(data, mdCols, "LOW")
+		quote.Close = p.getDecimal(data, mdCols, "CLOSE", "CLOSEPRICE", "LCLOSEPRICE")
+		quote.Bid = p.getDecimal(data, mdCols, "BID")
+		quote.Ask = p.getDecimal(data, mdCols, "OFFER")
+
+		if v, ok := mdCols["VOLTODAY"]; ok && v < len(data) {
+			if vol, ok := data[v].(float64); ok {
+				quote.Volume = int64(vol)
 			}
 		}
+
+		result[ticker] = quote
 	}
 
-	// если ничего не получили и была ошибка — возвращаем её
-	if len(result) == 0 && lastErr != nil {
-		return nil, lastErr
+	// дополняем информацией из данных о ценных бумагах
+	for _, data := range resp.Securities.Data {
+		var ticker string
+		if secIdx, ok := secCols["SECID"]; ok && secIdx < len(data) {
+			ticker, _ = data[secIdx].(string)
+		}
+		if ticker == "" {
+			continue // пропускаем записи без тикера(защита от битых данных)
+		}
+
+		if quote, exists := result[ticker]; exists {
+			if quote.LastPrice.IsZero() {
+				quote.LastPrice = p.getDecimal(data, secCols, "PREVPRICE", "PREVADMITTEDQUOTE") // фоллбэк: используем цену закрытия если тек котирвоки нет
+			}
+			if market == "bonds" {
+				p.applyBondPricing(quote, data, secCols)
+			}
+		}
 	}
 
 	return result, nil
 }
 
+// applyBondPricing переводит цены облигации из % от номинала в валюту номинала и добавляет НКД
+// на одну бумагу. без номинала котировка остается как есть
+func (p *MOEXProvider) applyBondPricing(quote *models.MarketQuote, data []interface{}, cols map[string]int) {
+	face := p.getDecimal(data, cols, "FACEVALUE")
+	if !face.IsPositive() {
+		return
+	}
+
+	percent := quote.LastPrice
+	quote.PricePercent = &percent
+	quote.FaceValue = &face
+	quote.AccruedInterest = p.getDecimal(data, cols, "ACCRUEDINT")
+
+	toMoney := func(d decimal.Decimal) decimal.Decimal {
+		return d.Mul(face).Div(decimal.NewFromInt(100)).Round(6)
+	}
+	quote.LastPrice = toMoney(quote.LastPrice)
+	quote.Open = toMoney(quote.Open)
+	quote.High = toMoney(quote.High)
+	quote.Low = toMoney(quote.Low)
+	quote.Close = toMoney(quote.Close)
+	quote.Bid = toMoney(quote.Bid)
+	quote.Ask = toMoney(quote.Ask)
+	quote.Change = toMoney(quote.Change)
+}
+
 func (p *MOEXProvider) SearchSecurities(ctx context.Context, query string, securityType *models.SecurityType, exchange models.Exchange) ([]models.Security, error) {
 	encodedQuery := url.QueryEscape(query)
 
 	url := fmt.Sprintf("%s/securities.json?iss.meta=off&q=%s&limit=50", p.baseURL, encodedQuery)
 
-	resp, err := p.makeRequest(ctx, url)
+	// пустой результат поиска - это нормально
+	resp, err := p.makeRequest(ctx, url, issExpect{block: "securities", columns: []string{"secid"}, allowEmpty: true})
 	if err != nil {
 		return nil, err
 	}
@@ -238,7 +289,7 @@ func (p *MOEXProvider) SearchSecurities(ctx context.Context, query string, secur
 		security.ISIN = p.getString(data, cols, "isin")
 
 		group := p.getString(data, cols, "group")
-		security.Type = p.mapSecurityType(group)
+		security.Type = p.mapSecurityType(group, p.getString(data, cols, "type"))
 
 		// если не соответствует указанному фильтру пропускаем
 		if securityType != nil && security.Type != *securityType {
@@ -270,22 +321,28 @@ func (p *MOEXProvider) SearchSecurities(ctx context.Context, query string, secur
 }
 
 func (p *MOEXProvider) GetSecurityInfo(ctx context.Context, ticker string, exchange models.Exchange) (*models.Security, error) {
+	// контракты срочного рынка описаны не в карточке бумаги, а в блоке securities секции FORTS
+	if engine, market, _ := p.detectMarket(ticker); engine == "futures" {
+		return p.getDerivativeInfo(ctx, ticker, market, exchange)
+	}
+
 	url := fmt.Sprintf("%s/securities/%s.json?iss.meta=off", p.baseURL, ticker)
 
-	resp, err := p.makeRequest(ctx, url)
+	// карточка бумаги приходит блоком description (строки "параметр - значение")
+	resp, err := p.makeRequest(ctx, url, issExpect{block: "description", columns: []string{"name", "value"}, allowEmpty: true})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(resp.Securities.Data) == 0 {
+	if len(resp.Description.Data) == 0 {
 		return nil, fmt.Errorf("ценная бумага не найдена: %s", ticker)
 	}
 
-	cols := makeColumnIndex(resp.Securities.Columns)
-	data := resp.Securities.Data[0]
+	data, cols := descriptionRow(resp.Description)
 
-	currency := p.getString(data, cols, "CURRENCYID", "CURRENCY", "currencyid", "currency")
-	if currency == "" {
+	// валюта номинала (SUR - старое обозначение рубля)
+	currency := p.getString(data, cols, "currencyid", "faceunit")
+	if currency == "" || currency == "SUR" {
 		currency = "RUB"
 	}
 
@@ -304,7 +361,7 @@ func (p *MOEXProvider) GetSecurityInfo(ctx context.Context, ticker string, excha
 	security.ISIN = p.getString(data, cols, "isin")
 
 	group := p.getString(data, cols, "group")
-	security.Type = p.mapSecurityType(group)
+	security.Type = p.mapSecurityType(group, p.getString(data, cols, "type"))
 
 	// сектор и индустрия
 	security.Sector = p.getString(data, cols, "sector")
@@ -357,6 +414,71 @@ func (p *MOEXProvider) GetSecurityInfo(ctx context.Context, ticker string, excha
 	return security, nil
 }
 
+// getDerivativeInfo параметры фьючерса или опциона: базовый актив, экспирация, страйк, стоимость пункта, ГО
+func (p *MOEXProvider) getDerivativeInfo(ctx context.Context, ticker, market string, exchange models.Exchange) (*models.Security, error) {
+	url := fmt.Sprintf("%s/engines/futures/markets/%s/securities/%s.json?iss.meta=off&iss.only=securities", p.baseURL, market, ticker)
+
+	resp, err := p.makeRequest(ctx, url, issExpect{block: "securities", columns: []string{"SECID", "LASTTRADEDATE"}, allowEmpty: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Securities.Data) == 0 {
+		return nil, fmt.Errorf("контракт не найден: %s", ticker)
+	}
+
+	cols := makeColumnIndex(resp.Securities.Columns)
+	data := resp.Securities.Data[0]
+
+	security := &models.Security{
+		ID:       uuid.New(),
+		Ticker:   p.getString(data, cols, "SECID"),
+		Type:     models.SecurityTypeDerivative,
+		Exchange: exchange,
+		IsActive: true,
+		Country:  "RU",
+		Currency: "RUB",
+		LotSize:  1,
+	}
+	security.ShortName = p.getString(data, cols, "SHORTNAME")
+	security.Name = p.getString(data, cols, "SECNAME")
+	if security.Name == "" {
+		security.Name = security.ShortName
+	}
+	security.Underlying = p.getString(data, cols, "ASSETCODE", "UNDERLYINGASSET")
+
+	if t, err := time.Parse("2006-01-02", p.getString(data, cols, "LASTTRADEDATE")); err == nil {
+		security.ExpiryDate = &t
+	}
+
+	minStep := p.getDecimal(data, cols, "MINSTEP")
+	security.MinPriceIncrement = minStep
+
+	// стоимость пункта = стоимость шага цены / шаг цены
+	if stepPrice := p.getDecimal(data, cols, "STEPPRICE"); stepPrice.IsPositive() && minStep.IsPositive() {
+		multiplier := stepPrice.Div(minStep)
+		security.ContractMultiplier = &multiplier
+	}
+
+	if margin := p.getDecimal(data, cols, "INITIALMARGIN"); margin.IsPositive() {
+		security.InitialMargin = &margin
+	}
+
+	if strike := p.getDecimal(data, cols, "STRIKE"); strike.IsPositive() {
+		security.Strike = &strike
+	}
+	switch strings.ToUpper(p.getString(data, cols, "OPTIONTYPE")) {
+	case "C":
+		security.OptionType = models.OptionTypeCall
+	case "P":
+		security.OptionType = models.OptionTypePut
+	}
+
+	// расчетная цена последнего клиринга - ориентир цены до первой котировки
+	security.LastPrice = p.getDecimal(data, cols, "PREVSETTLEPRICE")
+
+	return security, nil
+}
+
 func (p *MOEXProvider) GetPriceHistory(ctx context.Context, ticker string, exchange models.Exchange, from, to time.Time) ([]PriceBar, error) {
 	engine, market, board := p.detectMarket(ticker)
 
@@ -369,7 +491,7 @@ func (p *MOEXProvider) GetPriceHistory(ctx context.Context, ticker string, excha
 		url := fmt.Sprintf("%s/history/engines/%s/markets/%s/boards/%s/securities/%s.json?iss.meta=off&from=%s&till=%s&start=%d",
 			p.baseURL, engine, market, board, ticker, startDate, endDate, start)
 
-		resp, err := p.makeRequest(ctx, url)
+		resp, err := p.makeRequest(ctx, url, issExpect{block: "history", columns: []string{"TRADEDATE"}, allowEmpty: true})
 		if err != nil {
 			return nil, err
 		}
@@ -396,6 +518,15 @@ func (p *MOEXProvider) GetPriceHistory(ctx context.Context, ticker string, excha
 				Volume: int64(p.getFloat(data, cols, "VOLUME")),
 			}
 
+			// облигации торгуются в % от номинала - переводим в валюту, как и текущие котировки
+			if face := p.getDecimal(data, cols, "FACEVALUE"); market == "bonds" && face.IsPositive() {
+				hundred := decimal.NewFromInt(100)
+				bar.Open = bar.Open.Mul(face).Div(hundred)
+				bar.High = bar.High.Mul(face).Div(hundred)
+				bar.Low = bar.Low.Mul(face).Div(hundred)
+				bar.Close = bar.Close.Mul(face).Div(hundred)
+			}
+
 			bars = append(bars, bar)
 		}
 
@@ -411,7 +542,7 @@ func (p *MOEXProvider) GetPriceHistory(ctx context.Context, ticker string, excha
 func (p *MOEXProvider) GetDividends(ctx context.Context, ticker string, exchange models.Exchange) ([]models.Dividend, error) {
 	url := fmt.Sprintf("%s/securities/%s/dividends.json?iss.meta=off", p.baseURL, ticker)
 
-	resp, err := p.makeRequest(ctx, url)
+	resp, err := p.makeRequest(ctx, url, issExpect{block: "dividends", allowEmpty: true})
 	if err != nil {
 		return nil, err
 	}
@@ -471,42 +602,109 @@ func (p *MOEXProvider) GetDividends(ctx context.Context, ticker string, exchange
 	return dividends, nil
 }
 
-func (p *MOEXProvider) GetCurrencyRate(ctx context.Context, from, to string) (decimal.Decimal, error) {
-	// обрабатываем пары с рублём через валютный рынок MOEX (тоже упрощенно)
-	var ticker string
-	var invert bool // moex api предоставляем currency к рублю, обратно прилется самому
+// GetCoupons получает график купонов облигации (прошлые и будущие выплаты)
+func (p *MOEXProvider) GetCoupons(ctx context.Context, ticker string, exchange models.Exchange) ([]models.Coupon, error) {
+	url := fmt.Sprintf("%s/securities/%s/bondization.json?iss.meta=off&iss.only=coupons&limit=unlimited", p.baseURL, ticker)
 
-	switch {
-	case from == "USD" && to == "RUB":
-		ticker = "USD000UTSTOM"
-	case from == "RUB" && to == "USD":
-		ticker = "USD000UTSTOM"
-		invert = true
-	case from == "EUR" && to == "RUB":
-		ticker = "EUR_RUB__TOM"
-	case from == "RUB" && to == "EUR":
-		ticker = "EUR_RUB__TOM"
-		invert = true
-	case from == "CNY" && to == "RUB":
-		ticker = "CNYRUB_TOM"
-	case from == "RUB" && to == "CNY":
-		ticker = "CNYRUB_TOM"
-		invert = true
-	default:
+	resp, err := p.makeRequest(ctx, url, issExpect{block: "coupons", columns: []string{"coupondate"}, allowEmpty: true})
+	if err != nil {
+		return nil, err
+	}
+
+	cols := makeColumnIndex(resp.Coupons.Columns)
+
+	var coupons []models.Coupon
+	for _, data := range resp.Coupons.Data {
+		date, err := time.Parse("2006-01-02", p.getString(data, cols, "coupondate"))
+		if err != nil {
+			continue // без даты выплаты запись бесполезна
+		}
+
+		coupon := models.Coupon{
+			Date:     date,
+			Amount:   decimal.NewFromFloat(p.getFloat(data, cols, "value_rub", "value")),
+			Currency: "RUB",
+		}
+
+		if t, err := time.Parse("2006-01-02", p.getString(data, cols, "recorddate")); err == nil {
+			coupon.RecordDate = &t
+		}
+		if rate := p.getFloat(data, cols, "valueprc"); rate > 0 {
+			r := decimal.NewFromFloat(rate)
+			coupon.Rate = &r
+		}
+		// value_rub уже в рублях, иначе берем валюту номинала (SUR - старое обозначение рубля)
+		if _, ok := cols["value_rub"]; !ok {
+			if unit := p.getString(data, cols, "faceunit"); unit != "" && unit != "SUR" {
+				coupon.Currency = unit
+			}
+		}
+
+		coupons = append(coupons, coupon)
+	}
+
+	return coupons, nil
+}
+
+// GetAmortizations получает график погашения номинала облигации: амортизации и погашение выпуска
+func (p *MOEXProvider) GetAmortizations(ctx context.Context, ticker string, exchange models.Exchange) ([]models.Amortization, error) {
+	url := fmt.Sprintf("%s/securities/%s/bondization.json?iss.meta=off&iss.only=amortizations&limit=unlimited", p.baseURL, ticker)
+
+	resp, err := p.makeRequest(ctx, url, issExpect{block: "amortizations", columns: []string{"amortdate"}, allowEmpty: true})
+	if err != nil {
+		return nil, err
+	}
+
+	cols := makeColumnIndex(resp.Amortizations.Columns)
+
+	var amortizations []models.Amortization
+	for _, data := range resp.Amortizations.Data {
+		date, err := time.Parse("2006-01-02", p.getString(data, cols, "amortdate"))
+		if err != nil {
+			continue
+		}
+
+		amortization := models.Amortization{
+			Date:     date,
+			Amount:   decimal.NewFromFloat(p.getFloat(data, cols, "value_rub", "value")),
+			Currency: "RUB",
+			// data_source: amortization - частичное погашение, maturity - погашение в конце срока
+			IsRedemption: p.getString(data, cols, "data_source") == "maturity",
+		}
+
+		if prc := p.getFloat(data, cols, "valueprc"); prc > 0 {
+			v := decimal.NewFromFloat(prc)
+			amortization.Percent = &v
+		}
+		if face := p.getFloat(data, cols, "facevalue"); face > 0 {
+			v := decimal.NewFromFloat(face)
+			amortization.FaceValue = &v
+		}
+		if _, ok := cols["value_rub"]; !ok {
+			if unit := p.getString(data, cols, "faceunit"); unit != "" && unit != "SUR" {
+				amortization.Currency = unit
+			}
+		}
+
+		amortizations = append(amortizations, amortization)
+	}
+
+	return amortizations, nil
+}
+
+func (p *MOEXProvider) GetCurrencyRate(ctx context.Context, from, to string) (decimal.Decimal, error) {
+	ticker, invert, ok := moexCurrencyTicker(from, to)
+	if !ok {
 		return decimal.Zero, fmt.Errorf("неподдерживаемая валютная пара: %s/%s", from, to)
 	}
 
 	url := fmt.Sprintf("%s/engines/currency/markets/selt/boards/CETS/securities/%s.json?iss.meta=off", p.baseURL, ticker)
 
-	resp, err := p.makeRequest(ctx, url)
+	resp, err := p.makeRequest(ctx, url, issExpect{block: "marketdata"})
 	if err != nil {
 		return decimal.Zero, err
 	}
 
-	if len(resp.Marketdata.Data) == 0 {
-		return decimal.Zero, fmt.Errorf("нет данных по курсу для %s/%s", from, to)
-	}
-
 	cols := makeColumnIndex(resp.Marketdata.Columns)
 	data := resp.Marketdata.Data[0]
 
@@ -522,37 +720,152 @@ func (p *MOEXProvider) GetCurrencyRate(ctx context.Context, from, to string) (de
 	return rate, nil
 }
 
+// GetHistoricalCurrencyRate средневзвешенный курс торгов на дату; в выходные и праздники - курс последних торгов до нее
+func (p *MOEXProvider) GetHistoricalCurrencyRate(ctx context.Context, from, to string, date time.Time) (decimal.Decimal, error) {
+	ticker, invert, ok := moexCurrencyTicker(from, to)
+	if !ok {
+		return decimal.Zero, fmt.Errorf("неподдерживаемая валютная пара: %s/%s", from, to)
+	}
+
+	// длинные праздники - до 10 дней без торгов
+	url := fmt.Sprintf("%s/history/engines/currency/markets/selt/boards/CETS/securities/%s.json?iss.meta=off&from=%s&till=%s",
+		p.baseURL, ticker, date.AddDate(0, 0, -10).Format("2006-01-02"), date.Format("2006-01-02"))
+
+	resp, err := p.makeRequest(ctx, url, issExpect{block: "history", columns: []string{"TRADEDATE"}, allowEmpty: true})
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	cols := makeColumnIndex(resp.History.Columns)
+	var rate decimal.Decimal
+	// строки по возрастанию даты - последняя ненулевая ближе всего к дате сделки
+	for _, data := range resp.History.Data {
+		if r := p.getDecimal(data, cols, "WAPRICE", "CLOSE"); r.IsPositive() {
+			rate = r
+		}
+	}
+	if rate.IsZero() {
+		return decimal.Zero, fmt.Errorf("нет курса %s/%s на %s", from, to, date.Format("2006-01-02"))
+	}
+
+	if invert {
+		rate = decimal.NewFromInt(1).Div(rate)
+	}
+	return rate, nil
+}
+
+// moexCurrencyTicker инструмент валютного рынка MOEX для пары с рублем (тоже упрощенно).
+// moex api предоставляет currency к рублю, обратный курс считаем сами (invert)
+func moexCurrencyTicker(from, to string) (ticker string, invert bool, ok bool) {
+	switch {
+	case from == "USD" && to == "RUB":
+		return "USD000UTSTOM", false, true
+	case from == "RUB" && to == "USD":
+		return "USD000UTSTOM", true, true
+	case from == "EUR" && to == "RUB":
+		return "EUR_RUB__TOM", false, true
+	case from == "RUB" && to == "EUR":
+		return "EUR_RUB__TOM", true, true
+	case from == "CNY" && to == "RUB":
+		return "CNYRUB_TOM", false, true
+	case from == "RUB" && to == "CNY":
+		return "CNYRUB_TOM", true, true
+	}
+	return "", false, false
+}
+
 // вспомогаттельные методы
 
 // метод запроса
-func (p *MOEXProvider) makeRequest(ctx context.Context, url string) (*MOEXResponse, error) {
+// Status статистика запросов к ISS
+func (p *MOEXProvider) Status() ProviderStatus {
+	return p.tracker.status(p.GetName(), p.GetSupportedExchanges())
+}
+
+// Ping проверяет доступность ISS по легкому справочнику торговых систем
+func (p *MOEXProvider) Ping(ctx context.Context) error {
+	_, err := p.makeRequest(ctx, p.baseURL+"/engines.json?iss.meta=off")
+	return err
+}
+
+// makeRequest выполняет запрос к ISS и проверяет, что в ответе есть ожидаемые блоки и колонки.
+// несоответствие возвращается как *ISSError, а не как частично пустые данные
+func (p *MOEXProvider) makeRequest(ctx context.Context, url string, expects ...issExpect) (*MOEXResponse, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := p.tracker.allow(); err != nil {
+		return nil, err
+	}
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
+		p.tracker.record(ctx, err)
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ошибка MOEX API: %d", resp.StatusCode)
+		issErr := &ISSError{URL: url, StatusCode: resp.StatusCode, Err: ErrISSStatus}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			p.tracker.rateLimited(resp)
+		}
+		// ошибкой провайдера считаем только перегрузку и сбои сервера, 4xx - проблема запроса
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			p.tracker.record(ctx, issErr)
+		} else {
+			p.tracker.record(ctx, nil)
+		}
+		return nil, issErr
 	}
 
 	var result MOEXResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		decodeErr := &ISSError{URL: url, Err: fmt.Errorf("%w: %v", ErrISSDecode, err)}
+		p.tracker.record(ctx, decodeErr)
+		return nil, decodeErr
+	}
+	p.tracker.record(ctx, nil)
+
+	if err := result.validate(url, expects); err != nil {
 		return nil, err
 	}
 
 	return &result, nil
 }
 
+// коды контрактов FORTS: базовый актив + месяц (F..Z) + последняя цифра года;
+// у опционов между ними страйк и тип расчетов (A - американский, B - маржируемый)
+// индексы биржи и фонды, режим которых не угадывается по тикеру (бенчмарки для сравнения портфеля)
+var moexBoards = map[string][3]string{
+	"IMOEX": {"stock", "index", "SNDX"},
+	"MCFTR": {"stock", "index", "SNDX"},
+	"RGBI":  {"stock", "index", "SNDX"},
+	"SBMX":  {"stock", "shares", "TQTF"},
+}
+
+var (
+	fortsFutureCode = regexp.MustCompile(`^[A-Z][A-Z0-9][FGHJKMNQUVXZ][0-9]$`)
+	fortsOptionCode = regexp.MustCompile(`^[A-Z][A-Z0-9][0-9]+(\.[0-9]+)?[AB][A-X][0-9][A-Z]?$`)
+)
+
 // упрощенно определяем параметры для url ISS API по тикеру
 func (p *MOEXProvider) detectMarket(ticker string) (engine, market, board string) {
 	upperTicker := strings.ToUpper(ticker)
 
+	if b, ok := moexBoards[upperTicker]; ok {
+		return b[0], b[1], b[2]
+	}
+
+	// срочный рынок: короткие коды фьючерсов (SiH5) и коды опционов (Si80000BC5)
+	if fortsFutureCode.MatchString(upperTicker) {
+		return "futures", "forts", "RFUD"
+	}
+	if fortsOptionCode.MatchString(upperTicker) {
+		return "futures", "options", "ROPD"
+	}
+
 	// облигации
 	if strings.HasPrefix(upperTicker, "SU") || strings.HasPrefix(upperTicker, "RU") {
 		return "stock", "bonds", "TQOB"
@@ -572,18 +885,38 @@ func (p *MOEXProvider) detectMarket(ticker string) (engine, market, board string
 	return "stock", "shares", "TQBR"
 }
 
-// приводит строку из iss moex к типам models.SecurityType
-func (p *MOEXProvider) mapSecurityType(group string) models.SecurityType {
-	group = strings.ToLower(group)
+// приводит группу (group) и вид (type) бумаги из iss moex к models.SecurityType. вид точнее группы:
+// в stock_shares лежат и обыкновенные, и привилегированные акции, а в stock_ppif - и биржевые, и обычные ПИФы
+func (p *MOEXProvider) mapSecurityType(group, kind string) models.SecurityType {
+	group, kind = strings.ToLower(group), strings.ToLower(kind)
+
+	switch kind {
+	case "common_share":
+		return models.SecurityTypeStock
+	case "preferred_share":
+		return models.SecurityTypePreferred
+	case "depositary_receipt":
+		return models.SecurityTypeDR
+	case "exchange_ppif", "etf_ppif":
+		return models.SecurityTypeETF
+	case "public_ppif", "interval_ppif", "private_ppif", "stock_mortgage":
+		return models.SecurityTypeMutualFund
+	}
 
 	switch {
-	case strings.Contains(group, "bond"):
+	case strings.Contains(group, "bond") || strings.Contains(kind, "bond"):
 		return models.SecurityTypeBond
+	case group == "stock_dr":
+		return models.SecurityTypeDR
+	case group == "stock_mortgage":
+		return models.SecurityTypeMutualFund
 	case strings.Contains(group, "etf") || strings.Contains(group, "ppif"):
+		// без вида ПИФ на бирже почти всегда БПИФ
 		return models.SecurityTypeETF
 	case strings.Contains(group, "currency"):
 		return models.SecurityTypeCurrency
-	case strings.Contains(group, "futures") || strings.Contains(group, "option"):
+	case strings.Contains(group, "futures") || strings.Contains(group, "option") ||
+		strings.Contains(kind, "futures") || strings.Contains(kind, "option"):
 		return models.SecurityTypeDerivative
 	default:
 		return models.SecurityTypeStock
@@ -628,3 +961,22 @@ func makeColumnIndex(columns []string) map[string]int {
 	}
 	return idx
 }
+
+// GetFundamentals капитализация акции из рыночных данных ISS; P/E и EPS биржа не публикует
+func (p *MOEXProvider) GetFundamentals(ctx context.Context, ticker string, exchange models.Exchange) (*Fundamentals, error) {
+	engine, market, board := p.detectMarket(ticker)
+	if market != "shares" {
+		return nil, nil
+	}
+
+	url := fmt.Sprintf("%s/engines/%s/markets/%s/boards/%s/securities/%s.json?iss.meta=off&iss.only=marketdata", p.baseURL, engine, market, board, ticker)
+	resp, err := p.makeRequest(ctx, url, issExpect{block: "marketdata"})
+	if err != nil {
+		return nil, err
+	}
+
+	cols := makeColumnIndex(resp.Marketdata.Columns)
+	return &Fundamentals{
+		MarketCap: positiveDecimal(p.getFloat(resp.Marketdata.Data[0], cols, "ISSUECAPITALIZATION")),
+	}, nil
+}
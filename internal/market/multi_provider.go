@@ -3,44 +3,92 @@ package market
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/config"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/shopspring/decimal"
+	"golang.org/x/sync/errgroup"
 )
 
 // MultiProvider агрегирует несколько провайдеров рыночных данных
 type MultiProvider struct {
-	providers map[models.Exchange]MarketProvider
-	config    *config.Config
+	config     *config.Config
+	crossRates *rateCache
+
+	// карта бирж заменяется целиком при включении и выключении провайдеров (SIGHUP), поэтому
+	// читатели берут ее снимок под блокировкой и дальше работают без нее
+	mu             sync.RWMutex
+	providers      map[models.Exchange]MarketProvider
+	moexEnabled    bool
+	foreignEnabled bool
+
+	// созданные провайдеры переживают выключение, чтобы не терять кэши и состояние circuit breaker
+	moex    MarketProvider
+	foreign MarketProvider
+	crypto  MarketProvider
 }
 
 // NewMultiProvider создаёт новый экземпляр мульти-провайдера
 func NewMultiProvider(cfg *config.Config) *MultiProvider {
 	mp := &MultiProvider{
-		providers: make(map[models.Exchange]MarketProvider),
-		config:    cfg,
+		config:     cfg,
+		crossRates: newRateCache(crossRateTTL),
+		// крипто-провайдер доступен всегда
+		crypto: NewCryptoProvider(),
 	}
+	mp.SetEnabled(cfg.MOEXEnabled, cfg.ForeignEnabled)
+	return mp
+}
+
+// SetEnabled включает и выключает MOEX и иностранные биржи без перезапуска; адреса и ключи API
+// берутся из конфигурации, с которой провайдер создан
+func (mp *MultiProvider) SetEnabled(moex, foreign bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	providers := make(map[models.Exchange]MarketProvider)
 
 	// Регистрация провайдера MOEX (российский рынок — основной)
-	if cfg.MOEXEnabled {
-		moexProvider := NewMOEXProvider(cfg.MOEXApiURL)
-		for _, exchange := range moexProvider.GetSupportedExchanges() {
-			mp.providers[exchange] = moexProvider
+	if moex {
+		if mp.moex == nil {
+			mp.moex = NewMOEXProvider(mp.config.MOEXApiURL)
+		}
+		for _, exchange := range mp.moex.GetSupportedExchanges() {
+			providers[exchange] = mp.moex
 		}
 	}
 
-	// Регистрация крипто-провайдера (всегда доступен)
-	cryptoProvider := NewCryptoProvider()
-	mp.providers[models.ExchangeCRYPTO] = cryptoProvider
+	// Регистрация провайдера иностранных бирж
+	if foreign {
+		if mp.foreign == nil {
+			mp.foreign = NewForeignProvider(mp.config.YahooFinanceURL, mp.config.TwelveDataAPIURL, mp.config.TwelveDataAPIKey)
+		}
+		for _, exchange := range mp.foreign.GetSupportedExchanges() {
+			providers[exchange] = mp.foreign
+		}
+	}
 
-	return mp
+	providers[models.ExchangeCRYPTO] = mp.crypto
+
+	mp.providers = providers
+	mp.moexEnabled = moex
+	mp.foreignEnabled = foreign
+}
+
+// current снимок карты бирж; карта после публикации не меняется
+func (mp *MultiProvider) current() map[models.Exchange]MarketProvider {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return mp.providers
 }
 
 // GetProvider возвращает подходящий провайдер для биржи
 func (mp *MultiProvider) GetProvider(exchange models.Exchange) (MarketProvider, error) {
-	provider, exists := mp.providers[exchange]
+	provider, exists := mp.current()[exchange]
 	if !exists {
 		return nil, fmt.Errorf("нет провайдера для биржи %s", exchange)
 	}
@@ -65,6 +113,42 @@ func (mp *MultiProvider) GetQuotes(ctx context.Context, tickers []string, exchan
 	return provider.GetQuotes(ctx, tickers, exchange)
 }
 
+// сколько запросов котировок к провайдерам выполняем одновременно
+const maxConcurrentQuoteRequests = 4
+
+// GetQuotesByExchange получает котировки сразу по нескольким биржам, опрашивая провайдеров параллельно.
+// ошибка отдельной биржи не мешает остальным: ее просто не будет в результате
+func (mp *MultiProvider) GetQuotesByExchange(ctx context.Context, tickers map[models.Exchange][]string) (map[models.Exchange]map[string]*models.MarketQuote, error) {
+	result := make(map[models.Exchange]map[string]*models.MarketQuote)
+
+	var (
+		mu      sync.Mutex
+		lastErr error
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentQuoteRequests)
+	for exchange, exchangeTickers := range tickers {
+		g.Go(func() error {
+			quotes, err := mp.GetQuotes(gctx, exchangeTickers, exchange)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = fmt.Errorf("ошибка получения котировок %s: %w", exchange, err)
+				return nil
+			}
+			result[exchange] = quotes
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	if len(result) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return result, nil
+}
+
 // SearchSecurities ищет ценные бумаги по всем включённым провайдерам
 func (mp *MultiProvider) SearchSecurities(ctx context.Context, query string, securityType *models.SecurityType, exchange *models.Exchange) ([]models.Security, error) {
 	var results []models.Security
@@ -78,15 +162,19 @@ func (mp *MultiProvider) SearchSecurities(ctx context.Context, query string, sec
 		return provider.SearchSecurities(ctx, query, securityType, *exchange)
 	}
 
-	// Поиск по всем провайдерам
+	// Поиск по всем провайдерам. провайдеру нескольких бирж передаем пустую биржу - он ищет по всем своим
 	seen := make(map[string]bool)
-	for _, provider := range mp.providers {
+	for _, provider := range mp.current() {
 		if seen[provider.GetName()] {
 			continue
 		}
 		seen[provider.GetName()] = true
 
-		securities, err := provider.SearchSecurities(ctx, query, securityType, *exchange)
+		var searchExchange models.Exchange
+		if exchanges := provider.GetSupportedExchanges(); len(exchanges) == 1 {
+			searchExchange = exchanges[0]
+		}
+		securities, err := provider.SearchSecurities(ctx, query, securityType, searchExchange)
 		if err != nil {
 			continue // Пропускаем провайдеры с ошибками
 		}
@@ -123,33 +211,68 @@ func (mp *MultiProvider) GetDividends(ctx context.Context, ticker string, exchan
 	return provider.GetDividends(ctx, ticker, exchange)
 }
 
-// GetCurrencyRate получает курс обмена валют
+// GetCurrencyRate курс обмена валют. порядок:
+//  1. прямой курс у провайдеров (rateProviders), затем обратный к нему;
+//  2. кросс-курс через опорную валюту (crossPivots): MOEX знает только пары с рублем,
+//     CoinGecko - монеты к фиату, поэтому USD/EUR считается через RUB, а BTC/ETH - через USD.
+//
+// кросс-курсы кэшируются на crossRateTTL, прямые не кэшируются (их кэширует вызывающий)
 func (mp *MultiProvider) GetCurrencyRate(ctx context.Context, from, to string) (decimal.Decimal, error) {
-	// Сначала пробуем MOEX для пар с рублём
-	if from == "RUB" || to == "RUB" {
-		if provider, exists := mp.providers[models.ExchangeMOEX]; exists {
-			rate, err := provider.GetCurrencyRate(ctx, from, to)
-			if err == nil {
-				return rate, nil
-			}
-		}
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+	if rate, ok := mp.crossRates.get(from, to, time.Time{}); ok {
+		return rate, nil
 	}
 
-	// Пробуем другие провайдеры для остальных валют
-	for _, provider := range mp.providers {
-		rate, err := provider.GetCurrencyRate(ctx, from, to)
-		if err == nil {
-			return rate, nil
-		}
+	rate, err := mp.pairRate(ctx, from, to, mp.directRate)
+	if err == nil {
+		return rate, nil
+	}
+	if rate, err := mp.crossRate(ctx, from, to, mp.directRate); err == nil {
+		mp.crossRates.set(from, to, time.Time{}, rate)
+		return rate, nil
 	}
 
 	return decimal.Zero, fmt.Errorf("не удалось получить курс для %s/%s", from, to)
 }
 
+// GetHistoricalCurrencyRate курс валюты на дату сделки; на сегодня и у провайдеров без истории - текущий курс.
+// порядок тот же, что у GetCurrencyRate
+func (mp *MultiProvider) GetHistoricalCurrencyRate(ctx context.Context, from, to string, date time.Time) (decimal.Decimal, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+	now := time.Now()
+	if !date.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, date.Location())) {
+		return mp.GetCurrencyRate(ctx, from, to)
+	}
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	if rate, ok := mp.crossRates.get(from, to, day); ok {
+		return rate, nil
+	}
+
+	lookup := func(ctx context.Context, from, to string) (decimal.Decimal, error) {
+		return mp.historicalRate(ctx, from, to, date)
+	}
+	if rate, err := mp.pairRate(ctx, from, to, lookup); err == nil {
+		return rate, nil
+	}
+	if rate, err := mp.crossRate(ctx, from, to, lookup); err == nil {
+		mp.crossRates.set(from, to, day, rate)
+		return rate, nil
+	}
+
+	return decimal.Zero, fmt.Errorf("не удалось получить курс %s/%s на %s", from, to, date.Format("2006-01-02"))
+}
+
 // GetSupportedExchanges возвращает все поддерживаемые биржи
 func (mp *MultiProvider) GetSupportedExchanges() []models.Exchange {
-	exchanges := make([]models.Exchange, 0, len(mp.providers))
-	for exchange := range mp.providers {
+	providers := mp.current()
+	exchanges := make([]models.Exchange, 0, len(providers))
+	for exchange := range providers {
 		exchanges = append(exchanges, exchange)
 	}
 	return exchanges
@@ -157,6 +280,115 @@ func (mp *MultiProvider) GetSupportedExchanges() []models.Exchange {
 
 // IsExchangeSupported проверяет, поддерживается ли биржа
 func (mp *MultiProvider) IsExchangeSupported(exchange models.Exchange) bool {
-	_, exists := mp.providers[exchange]
+	_, exists := mp.current()[exchange]
 	return exists
 }
+
+// Ping проверяет, что доступен хотя бы один провайдер
+func (mp *MultiProvider) Ping(ctx context.Context) error {
+	var lastErr error
+	seen := make(map[string]bool)
+	for _, provider := range mp.current() {
+		if seen[provider.GetName()] || !provider.IsEnabled() {
+			continue
+		}
+		seen[provider.GetName()] = true
+
+		pinger, ok := provider.(Pinger)
+		if !ok {
+			continue
+		}
+		if err := pinger.Ping(ctx); err != nil {
+			lastErr = fmt.Errorf("%s: %w", provider.GetName(), err)
+			continue
+		}
+		return nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("нет доступных провайдеров рыночных данных")
+	}
+	return lastErr
+}
+
+// PingEach проверяет каждый включенный провайдер отдельно: имя -> ошибка (nil - отвечает).
+// провайдеры без проверки доступности не попадают в результат
+func (mp *MultiProvider) PingEach(ctx context.Context) map[string]error {
+	result := make(map[string]error)
+	for _, provider := range mp.current() {
+		if _, ok := result[provider.GetName()]; ok || !provider.IsEnabled() {
+			continue
+		}
+		if pinger, ok := provider.(Pinger); ok {
+			result[provider.GetName()] = pinger.Ping(ctx)
+		}
+	}
+	return result
+}
+
+// Status состояние всех провайдеров; выключенные в конфиге MOEX и иностранные биржи тоже попадают в список
+func (mp *MultiProvider) Status() []ProviderStatus {
+	var statuses []ProviderStatus
+	seen := make(map[string]bool)
+	for _, provider := range mp.current() {
+		if seen[provider.GetName()] {
+			continue
+		}
+		seen[provider.GetName()] = true
+
+		status := ProviderStatus{Name: provider.GetName(), Enabled: provider.IsEnabled(), Exchanges: provider.GetSupportedExchanges(), Circuit: circuitStateClosed}
+		if reporter, ok := provider.(StatusReporter); ok {
+			status = reporter.Status()
+			status.Enabled = provider.IsEnabled()
+		}
+		statuses = append(statuses, status)
+	}
+
+	mp.mu.RLock()
+	moexEnabled, foreignEnabled := mp.moexEnabled, mp.foreignEnabled
+	mp.mu.RUnlock()
+
+	if !moexEnabled {
+		statuses = append(statuses, ProviderStatus{
+			Name:      "MOEX",
+			Exchanges: []models.Exchange{models.ExchangeMOEX},
+			Circuit:   providerStatusDisabled,
+		})
+	}
+	if !foreignEnabled {
+		statuses = append(statuses, ProviderStatus{
+			Name:      "Foreign",
+			Exchanges: []models.Exchange{models.ExchangeNYSE, models.ExchangeNASDAQ, models.ExchangeLSE, models.ExchangeFRA, models.ExchangeHKEX},
+			Circuit:   providerStatusDisabled,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// GetCoupons получает график купонов облигации, если провайдер биржи это умеет
+func (mp *MultiProvider) GetCoupons(ctx context.Context, ticker string, exchange models.Exchange) ([]models.Coupon, error) {
+	provider, err := mp.GetProvider(exchange)
+	if err != nil {
+		return nil, err
+	}
+	lookup, ok := provider.(CouponLookup)
+	if !ok {
+		return nil, fmt.Errorf("провайдер %s не поддерживает график купонов", provider.GetName())
+	}
+	return lookup.GetCoupons(ctx, ticker, exchange)
+}
+
+// GetAmortizations получает график погашения номинала облигации, если провайдер биржи это умеет
+func (mp *MultiProvider) GetAmortizations(ctx context.Context, ticker string, exchange models.Exchange) ([]models.Amortization, error) {
+	provider, err := mp.GetProvider(exchange)
+	if err != nil {
+		return nil, err
+	}
+	lookup, ok := provider.(AmortizationLookup)
+	if !ok {
+		return nil, fmt.Errorf("провайдер %s не поддерживает график погашения", provider.GetName())
+	}
+	return lookup.GetAmortizations(ctx, ticker, exchange)
+}
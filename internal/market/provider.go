@@ -44,6 +44,26 @@ type MarketProvider interface {
 	GetCurrencyRate(ctx context.Context, from, to string) (decimal.Decimal, error)
 }
 
+// Pinger - необязательная возможность провайдера: дешевая проверка доступности API (для readiness)
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HistoricalRateLookup - необязательная возможность провайдера: курс валюты на прошедшую дату
+type HistoricalRateLookup interface {
+	GetHistoricalCurrencyRate(ctx context.Context, from, to string, date time.Time) (decimal.Decimal, error)
+}
+
+// CouponLookup - необязательная возможность провайдера: график купонов облигации
+type CouponLookup interface {
+	GetCoupons(ctx context.Context, ticker string, exchange models.Exchange) ([]models.Coupon, error)
+}
+
+// AmortizationLookup - необязательная возможность провайдера: график погашения номинала облигации
+type AmortizationLookup interface {
+	GetAmortizations(ctx context.Context, ticker string, exchange models.Exchange) ([]models.Amortization, error)
+}
+
 // PriceBar представляет данные свечи OHLCV (цена открытия, максимум, минимум, закрытия, объем)
 type PriceBar struct {
 	Date   time.Time       `json:"date"`
@@ -0,0 +1,163 @@
+package market
+
+import (
+	"context"
+	"strings"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+)
+
+// SectorInfo сектор и отрасль эмитента
+type SectorInfo struct {
+	Sector   string `json:"sector"`
+	Industry string `json:"industry"`
+}
+
+// SectorLookup - необязательная возможность провайдера: определить сектор бумаги.
+// MOEX ISS почти никогда не отдает сектор, поэтому реализуют не все провайдеры
+type SectorLookup interface {
+	GetSectorInfo(ctx context.Context, ticker string, exchange models.Exchange) (*SectorInfo, error)
+}
+
+// названия секторов (используются в AllocationBySector)
+const (
+	SectorFinance     = "Финансы"
+	SectorOilGas      = "Нефть и газ"
+	SectorMetals      = "Металлы и добыча"
+	SectorUtilities   = "Электроэнергетика"
+	SectorTelecom     = "Телекоммуникации"
+	SectorIT          = "IT"
+	SectorConsumer    = "Потребительский сектор"
+	SectorChemicals   = "Химия"
+	SectorTransport   = "Транспорт"
+	SectorRealEstate  = "Недвижимость"
+	SectorHealthcare  = "Здравоохранение"
+	SectorCrypto      = "Криптовалюты"
+	SectorGovernment  = "Государственные облигации"
+	SectorDiversified = "Диверсифицированные фонды"
+)
+
+// справочник секторов для популярных бумаг MOEX (позже можно дополнить через админку)
+var moexSectorMap = map[string]SectorInfo{
+	// финансы
+	"SBER":  {SectorFinance, "Банки"},
+	"SBERP": {SectorFinance, "Банки"},
+	"VTBR":  {SectorFinance, "Банки"},
+	"T":     {SectorFinance, "Банки"},
+	"TCSG":  {SectorFinance, "Банки"},
+	"CBOM":  {SectorFinance, "Банки"},
+	"BSPB":  {SectorFinance, "Банки"},
+	"SVCB":  {SectorFinance, "Банки"},
+	"MOEX":  {SectorFinance, "Биржи"},
+	"SPBE":  {SectorFinance, "Биржи"},
+	"RENI":  {SectorFinance, "Страхование"},
+	"AFKS":  {SectorFinance, "Холдинги"},
+	"SFIN":  {SectorFinance, "Холдинги"},
+	// нефть и газ
+	"GAZP":  {SectorOilGas, "Газ"},
+	"NVTK":  {SectorOilGas, "Газ"},
+	"LKOH":  {SectorOilGas, "Нефть"},
+	"ROSN":  {SectorOilGas, "Нефть"},
+	"SNGS":  {SectorOilGas, "Нефть"},
+	"SNGSP": {SectorOilGas, "Нефть"},
+	"TATN":  {SectorOilGas, "Нефть"},
+	"TATNP": {SectorOilGas, "Нефть"},
+	"BANE":  {SectorOilGas, "Нефть"},
+	"BANEP": {SectorOilGas, "Нефть"},
+	"TRNFP": {SectorOilGas, "Транспортировка нефти"},
+	// металлы и добыча
+	"GMKN": {SectorMetals, "Цветные металлы"},
+	"RUAL": {SectorMetals, "Алюминий"},
+	"NLMK": {SectorMetals, "Черная металлургия"},
+	"CHMF": {SectorMetals, "Черная металлургия"},
+	"MAGN": {SectorMetals, "Черная металлургия"},
+	"PLZL": {SectorMetals, "Золото"},
+	"UGLD": {SectorMetals, "Золото"},
+	"SELG": {SectorMetals, "Золото"},
+	"ALRS": {SectorMetals, "Алмазы"},
+	"MTLR": {SectorMetals, "Уголь"},
+	"RASP": {SectorMetals, "Уголь"},
+	"ENPG": {SectorMetals, "Цветные металлы"},
+	// электроэнергетика
+	"IRAO": {SectorUtilities, "Генерация"},
+	"HYDR": {SectorUtilities, "Генерация"},
+	"UPRO": {SectorUtilities, "Генерация"},
+	"OGKB": {SectorUtilities, "Генерация"},
+	"TGKA": {SectorUtilities, "Генерация"},
+	"MSNG": {SectorUtilities, "Генерация"},
+	"FEES": {SectorUtilities, "Электросети"},
+	"MRKP": {SectorUtilities, "Электросети"},
+	// телеком
+	"MTSS":  {SectorTelecom, "Мобильная связь"},
+	"RTKM":  {SectorTelecom, "Связь"},
+	"RTKMP": {SectorTelecom, "Связь"},
+	// IT
+	"YDEX": {SectorIT, "Интернет"},
+	"VKCO": {SectorIT, "Интернет"},
+	"HEAD": {SectorIT, "Интернет"},
+	"OZON": {SectorIT, "Электронная коммерция"},
+	"POSI": {SectorIT, "Кибербезопасность"},
+	"ASTR": {SectorIT, "Программное обеспечение"},
+	"SOFL": {SectorIT, "Программное обеспечение"},
+	"DIAS": {SectorIT, "Программное обеспечение"},
+	// потребительский сектор
+	"MGNT": {SectorConsumer, "Ритейл"},
+	"X5":   {SectorConsumer, "Ритейл"},
+	"LENT": {SectorConsumer, "Ритейл"},
+	"FIXP": {SectorConsumer, "Ритейл"},
+	"MVID": {SectorConsumer, "Ритейл"},
+	"BELU": {SectorConsumer, "Напитки"},
+	"ABRD": {SectorConsumer, "Напитки"},
+	"AQUA": {SectorConsumer, "Продукты питания"},
+	"GCHE": {SectorConsumer, "Продукты питания"},
+	// химия
+	"PHOR": {SectorChemicals, "Удобрения"},
+	"AKRN": {SectorChemicals, "Удобрения"},
+	"NKNC": {SectorChemicals, "Нефтехимия"},
+	// транспорт
+	"AFLT": {SectorTransport, "Авиаперевозки"},
+	"FLOT": {SectorTransport, "Морские перевозки"},
+	"NMTP": {SectorTransport, "Порты"},
+	"FESH": {SectorTransport, "Морские перевозки"},
+	// недвижимость
+	"PIKK": {SectorRealEstate, "Девелопмент"},
+	"SMLT": {SectorRealEstate, "Девелопмент"},
+	"LSRG": {SectorRealEstate, "Девелопмент"},
+	"ETLN": {SectorRealEstate, "Девелопмент"},
+	// здравоохранение
+	"MDMG": {SectorHealthcare, "Медицинские услуги"},
+	"GEMC": {SectorHealthcare, "Медицинские услуги"},
+	"APTK": {SectorHealthcare, "Аптеки"},
+}
+
+// BundledSector ищет сектор бумаги во встроенном справочнике
+func BundledSector(security *models.Security) (SectorInfo, bool) {
+	if security.Exchange == models.ExchangeMOEX {
+		if info, ok := moexSectorMap[strings.ToUpper(security.Ticker)]; ok {
+			return info, true
+		}
+		// для облигаций и фондов сектор определяем по типу
+		switch security.Type {
+		case models.SecurityTypeBond:
+			if strings.HasPrefix(strings.ToUpper(security.Ticker), "SU") {
+				return SectorInfo{SectorGovernment, "ОФЗ"}, true
+			}
+		case models.SecurityTypeETF, models.SecurityTypeMutualFund:
+			return SectorInfo{SectorDiversified, "Фонды"}, true
+		}
+	}
+	return SectorInfo{}, false
+}
+
+// GetSectorInfo запрашивает сектор у провайдера биржи, если он это умеет
+func (mp *MultiProvider) GetSectorInfo(ctx context.Context, ticker string, exchange models.Exchange) (*SectorInfo, error) {
+	provider, err := mp.GetProvider(exchange)
+	if err != nil {
+		return nil, err
+	}
+	lookup, ok := provider.(SectorLookup)
+	if !ok {
+		return nil, nil
+	}
+	return lookup.GetSectorInfo(ctx, ticker, exchange)
+}
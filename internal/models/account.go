@@ -32,6 +32,8 @@ type Account struct {
 	Institution    string          `json:"institution" db:"institution"`
 	AccountNumber  string          `json:"account_number" db:"account_number"`
 	Notes          string          `json:"notes" db:"notes"`
+	SortOrder      int             `json:"sort_order" db:"sort_order"`
+	Group          string          `json:"group,omitempty" db:"account_group"` // своя группа для группировки custom
 	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
 	DeletedAt      *time.Time      `json:"-" db:"deleted_at"`
@@ -66,3 +68,44 @@ type AccountSummary struct {
 	AccountsByType    map[AccountType]int        `json:"accounts_by_type"`
 	Accounts          []Account                  `json:"accounts"`
 }
+
+// AccountGrouping как группировать счета в списке
+type AccountGrouping string
+
+const (
+	AccountGroupingNone        AccountGrouping = "none"
+	AccountGroupingType        AccountGrouping = "type"
+	AccountGroupingInstitution AccountGrouping = "institution"
+	AccountGroupingCustom      AccountGrouping = "custom"
+)
+
+func (g AccountGrouping) Valid() bool {
+	switch g {
+	case AccountGroupingNone, AccountGroupingType, AccountGroupingInstitution, AccountGroupingCustom:
+		return true
+	}
+	return false
+}
+
+// AccountOrder PATCH /accounts/order; все поля необязательны
+type AccountOrder struct {
+	AccountIDs []uuid.UUID          `json:"account_ids"` // новый порядок; не указанные счета идут следом в прежнем порядке
+	GroupBy    *AccountGrouping     `json:"group_by"`
+	Groups     map[uuid.UUID]string `json:"groups"` // своя группа счета для group_by=custom; "" - убрать из группы
+}
+
+// AccountGroup заголовок группы в списке счетов. группы идут в порядке первого счета группы,
+// Key "" - счета без учреждения или без своей группы
+type AccountGroup struct {
+	Key               string                     `json:"key"`
+	Name              string                     `json:"name"`
+	AccountIDs        []uuid.UUID                `json:"account_ids"`
+	BalanceByCurrency map[string]decimal.Decimal `json:"balance_by_currency"` // только активные счета
+}
+
+// AccountList счета в сохраненном порядке с заголовками групп (GET /accounts?grouped=true)
+type AccountList struct {
+	GroupBy  AccountGrouping `json:"group_by"`
+	Groups   []AccountGroup  `json:"groups"`
+	Accounts []Account       `json:"accounts"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type AccountRuleKind string
+
+const (
+	AccountRuleFee      AccountRuleKind = "fee"      // фиксированное списание: обслуживание, смс-информирование
+	AccountRuleCashback AccountRuleKind = "cashback" // % от расходов по счету за прошлый месяц
+	AccountRuleInterest AccountRuleKind = "interest" // % годовых на остаток раз в месяц; на минусовой остаток - списание
+)
+
+// AccountRule правило счета, которое фоновая задача раз в месяц проводит обычной транзакцией
+type AccountRule struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	UserID      uuid.UUID       `json:"user_id" db:"user_id"`
+	AccountID   uuid.UUID       `json:"account_id" db:"account_id"`
+	Kind        AccountRuleKind `json:"kind" db:"kind"`
+	Description string          `json:"description" db:"description"`
+	CategoryID  uuid.UUID       `json:"category_id" db:"category_id"`   // категория создаваемой транзакции
+	DayOfMonth  int             `json:"day_of_month" db:"day_of_month"` // 31 в коротком месяце - последний день
+
+	Amount           *decimal.Decimal `json:"amount,omitempty" db:"amount"`                         // fee
+	Rate             *decimal.Decimal `json:"rate,omitempty" db:"rate"`                             // cashback, interest: %
+	SourceCategoryID *uuid.UUID       `json:"source_category_id,omitempty" db:"source_category_id"` // cashback только по категории (с подкатегориями)
+	MaxAmount        *decimal.Decimal `json:"max_amount,omitempty" db:"max_amount"`                 // лимит кэшбэка за месяц
+
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	LastPeriod *time.Time `json:"last_period,omitempty" db:"-"` // последний обработанный месяц
+	NextDate   *time.Time `json:"next_date,omitempty" db:"-"`   // когда правило сработает в следующий раз
+}
+
+type AccountRuleCreate struct {
+	Kind             AccountRuleKind  `json:"kind" binding:"required,oneof=fee cashback interest"`
+	Description      string           `json:"description" binding:"max=255"`
+	CategoryID       uuid.UUID        `json:"category_id" binding:"required"`
+	DayOfMonth       int              `json:"day_of_month" binding:"required,min=1,max=31"`
+	Amount           *decimal.Decimal `json:"amount"`
+	Rate             *decimal.Decimal `json:"rate"`
+	SourceCategoryID *uuid.UUID       `json:"source_category_id"`
+	MaxAmount        *decimal.Decimal `json:"max_amount"`
+}
+
+type AccountRuleUpdate struct {
+	Description      *string          `json:"description" binding:"omitempty,max=255"`
+	CategoryID       *uuid.UUID       `json:"category_id"`
+	DayOfMonth       *int             `json:"day_of_month" binding:"omitempty,min=1,max=31"`
+	Amount           *decimal.Decimal `json:"amount"`
+	Rate             *decimal.Decimal `json:"rate"`
+	SourceCategoryID *uuid.UUID       `json:"source_category_id"`
+	MaxAmount        *decimal.Decimal `json:"max_amount"`
+	IsActive         *bool            `json:"is_active"`
+}
+
+// AccountRuleRun срабатывание правила за месяц; одно на месяц, поэтому повторный запуск задачи ничего не задвоит
+type AccountRuleRun struct {
+	RuleID        uuid.UUID       `json:"rule_id" db:"rule_id"`
+	Period        time.Time       `json:"period" db:"period"` // первое число месяца, за который начисление
+	Amount        decimal.Decimal `json:"amount" db:"amount"`
+	TransactionID *uuid.UUID      `json:"transaction_id,omitempty" db:"transaction_id"` // nil - начислять было нечего
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AccountRuleApplyResult итог фонового проведения правил
+type AccountRuleApplyResult struct {
+	Applied int `json:"applied"`
+	Empty   int `json:"empty"` // нулевая сумма: не было трат или остатка
+	Failed  int `json:"failed"`
+}
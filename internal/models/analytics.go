@@ -21,14 +21,15 @@ const (
 
 // предоставляет общий финансовый обзор пользователя
 type FinancialSummary struct {
-	Period        Period          `json:"period"`         // какой период анализируем
-	StartDate     time.Time       `json:"start_date"`     // начальная дата периода
-	EndDate       time.Time       `json:"end_date"`       // конечная дата периода
-	Currency      string          `json:"currency"`       // валюта отчета (может отличаться от валют транзакций)
-	TotalIncome   decimal.Decimal `json:"total_income"`   // общая сумма доходов за период
-	TotalExpenses decimal.Decimal `json:"total_expenses"` // общая сумма расходов за период
-	NetSavings    decimal.Decimal `json:"net_savings"`    // чистые сбережения = TotalIncome - TotalExpenses
-	SavingsRate   decimal.Decimal `json:"savings_rate"`   // норма сбережений в % = (NetSavings / TotalIncome) × 100
+	Period           Period          `json:"period"`            // какой период анализируем
+	StartDate        time.Time       `json:"start_date"`        // начальная дата периода
+	EndDate          time.Time       `json:"end_date"`          // конечная дата периода
+	Currency         string          `json:"currency"`          // валюта отчета (может отличаться от валют транзакций)
+	TotalIncome      decimal.Decimal `json:"total_income"`      // общая сумма доходов за период
+	TotalExpenses    decimal.Decimal `json:"total_expenses"`    // общая сумма расходов за период
+	InvestmentIncome decimal.Decimal `json:"investment_income"` // дивиденды и купоны портфелей (уже в TotalIncome), если включен include_investment_income
+	NetSavings       decimal.Decimal `json:"net_savings"`       // чистые сбережения = TotalIncome - TotalExpenses
+	SavingsRate      decimal.Decimal `json:"savings_rate"`      // норма сбережений в % = (NetSavings / TotalIncome) × 100
 	// Финансовый индикатор: >20% хорошо, <10% плохо
 	TotalBalance     decimal.Decimal  `json:"total_balance"`      // общий баланс по всем счетам на конец периода
 	IncomeChange     decimal.Decimal  `json:"income_change"`      // абсолютное изменение доходов: текущий - предыдущий период
@@ -41,6 +42,13 @@ type FinancialSummary struct {
 	ExpenseByCategory []CategoryAmount `json:"expense_by_category"`
 	// Список категорий расходов с суммами
 	// Пример: Продукты (30%), Аренда (25%), Транспорт (15%)
+
+	// обязательные расходы - категории с is_fixed (аренда, коммуналка, связь), остальные - необязательные
+	FixedExpenses          decimal.Decimal `json:"fixed_expenses"`
+	DiscretionaryExpenses  decimal.Decimal `json:"discretionary_expenses"`
+	FixedCostRatio         decimal.Decimal `json:"fixed_cost_ratio"`         // доля дохода на обязательные расходы в % = (FixedExpenses / TotalIncome) × 100
+	DiscretionaryChange    decimal.Decimal `json:"discretionary_change"`     // изменение необязательных расходов к предыдущему периоду
+	DiscretionaryChangePct decimal.Decimal `json:"discretionary_change_pct"` // то же в %
 }
 
 // представляет сумму по категории
@@ -52,6 +60,7 @@ type CategoryAmount struct {
 	Amount       decimal.Decimal `json:"amount"`        // cумма по этой категории
 	Percentage   decimal.Decimal `json:"percentage"`    // доля в общем объеме в % = (Amount / Total) × 100
 	Count        int             `json:"count"`         // rоличество транзакций в этой категории
+	IsFixed      bool            `json:"is_fixed"`      // обязательный расход (только для расходов)
 }
 
 // представляет данные о движении денежных средств
@@ -63,6 +72,35 @@ type CashFlow struct {
 	// Положительный = деньги остаются, Отрицательный = убыток
 }
 
+// CashFlowDimension дополнительная разбивка отчета о денежных потоках помимо времени
+type CashFlowDimension string
+
+const (
+	CashFlowByCategory CashFlowDimension = "category"
+	CashFlowByAccount  CashFlowDimension = "account"
+	CashFlowByTag      CashFlowDimension = "tag" // операция с несколькими метками попадает в каждую из них
+)
+
+// CashFlowCell суммы за период по одному значению разбивки в валюте операций (строка из SQL)
+type CashFlowCell struct {
+	Period   string
+	Currency string
+	Key      string
+	Name     string
+	Income   decimal.Decimal
+	Expenses decimal.Decimal
+}
+
+// CashFlowGroup строка матрицы для stacked-bar графика: суммы по периодам из data, в том же порядке
+type CashFlowGroup struct {
+	Key           string            `json:"key"` // id категории или счета, текст метки; "" - без метки
+	Name          string            `json:"name"`
+	Income        []decimal.Decimal `json:"income"`
+	Expenses      []decimal.Decimal `json:"expenses"`
+	TotalIncome   decimal.Decimal   `json:"total_income"`
+	TotalExpenses decimal.Decimal   `json:"total_expenses"`
+}
+
 // представляет полный отчет о денежных потоках
 type CashFlowReport struct {
 	Period   Period          `json:"period"`    // Базовый период (month, quarter, year)
@@ -71,6 +109,9 @@ type CashFlowReport struct {
 	TotalIn  decimal.Decimal `json:"total_in"`  // Общий приток за весь период
 	TotalOut decimal.Decimal `json:"total_out"` // Общий отток за весь период
 	NetFlow  decimal.Decimal `json:"net_flow"`  // Общий чистый поток = TotalIn - TotalOut
+
+	GroupBy CashFlowDimension `json:"group_by,omitempty"`
+	Groups  []CashFlowGroup   `json:"groups,omitempty"` // при group_by: ряды матрицы периоды x значения разбивки
 }
 
 // показывает динамику расходов по времени
@@ -114,6 +155,35 @@ type Recommendation struct {
 	Impact string `json:"impact"` // Насколько сильно это повлияет на финансы
 }
 
+// AIRecommendationsStatus состояние AI-рекомендаций пользователя
+type AIRecommendationsStatus string
+
+const (
+	AIRecommendationsNone    AIRecommendationsStatus = "none"    // еще не генерировались или устарели
+	AIRecommendationsRunning AIRecommendationsStatus = "running" // генерируются в фоне
+	AIRecommendationsReady   AIRecommendationsStatus = "ready"   // готовы, GET /recommendations отдает их
+	AIRecommendationsFailed  AIRecommendationsStatus = "failed"  // последняя генерация не удалась
+)
+
+// AIRecommendationsState AI-рекомендации генерируются в фоне и кэшируются; пока их нет - отдаются базовые
+type AIRecommendationsState struct {
+	Status        AIRecommendationsStatus `json:"status"`
+	GeneratedAt   *time.Time              `json:"generated_at,omitempty"`
+	ExpiresAt     *time.Time              `json:"expires_at,omitempty"`
+	NextRefreshAt *time.Time              `json:"next_refresh_at,omitempty"` // раньше POST /refresh ответит 429
+	Error         string                  `json:"error,omitempty"`
+}
+
+// AIRecommendationsRun последняя генерация AI-рекомендаций пользователя, общая для всех экземпляров сервера
+type AIRecommendationsRun struct {
+	UserID      uuid.UUID
+	Items       []Recommendation
+	GeneratedAt *time.Time // nil - готовых рекомендаций еще не было
+	StartedAt   time.Time  // последняя попытка генерации, от нее считается cooldown
+	Running     bool
+	Error       string
+}
+
 // FinancialHealth предоставляет общую оценку финансового здоровья
 type FinancialHealth struct {
 	OverallScore        int              `json:"overall_score"`         // Итоговый балл финансового здоровья
@@ -125,5 +195,242 @@ type FinancialHealth struct {
 	SavingsRate         decimal.Decimal  `json:"savings_rate"`          // норма сбережений в %
 	DebtToIncomeRatio   decimal.Decimal  `json:"debt_to_income_ratio"`  // Коэффициент долговой нагрузки = (Ежемесячные платежи по долгам / Ежемесячный доход) × 100
 	EmergencyFundMonths decimal.Decimal  `json:"emergency_fund_months"` // На сколько месяцев хватит резервного фонда = (Резервный фонд / Среднемесячные расходы)
+	FixedCostMonths     decimal.Decimal  `json:"fixed_cost_months"`     // на сколько месяцев резерва хватит только на обязательные расходы
+	FixedCostRatio      decimal.Decimal  `json:"fixed_cost_ratio"`      // доля дохода на обязательные расходы в %
+	DiscretionaryTrend  decimal.Decimal  `json:"discretionary_trend"`   // изменение необязательных расходов к прошлому периоду в %
 	TopRecommendations  []Recommendation `json:"top_recommendations"`
 }
+
+// FinancialHealthSnapshot оценка финансового здоровья за месяц: баллы и показатели, из которых они посчитаны.
+// пока месяц идет, снимок обновляется, после - остается как итог месяца
+type FinancialHealthSnapshot struct {
+	ID                  uuid.UUID       `json:"id" db:"id"`
+	UserID              uuid.UUID       `json:"user_id" db:"user_id"`
+	Month               time.Time       `json:"month" db:"month"` // первое число месяца
+	OverallScore        int             `json:"overall_score" db:"overall_score"`
+	Grade               string          `json:"grade" db:"grade"`
+	SavingsScore        int             `json:"savings_score" db:"savings_score"`
+	BudgetScore         int             `json:"budget_score" db:"budget_score"`
+	DebtScore           int             `json:"debt_score" db:"debt_score"`
+	EmergencyFundScore  int             `json:"emergency_fund_score" db:"emergency_fund_score"`
+	SavingsRate         decimal.Decimal `json:"savings_rate" db:"savings_rate"`
+	DebtToIncomeRatio   decimal.Decimal `json:"debt_to_income_ratio" db:"debt_to_income_ratio"`
+	EmergencyFundMonths decimal.Decimal `json:"emergency_fund_months" db:"emergency_fund_months"`
+	FixedCostMonths     decimal.Decimal `json:"fixed_cost_months" db:"fixed_cost_months"`
+	FixedCostRatio      decimal.Decimal `json:"fixed_cost_ratio" db:"fixed_cost_ratio"`
+	DiscretionaryTrend  decimal.Decimal `json:"discretionary_trend" db:"discretionary_trend"`
+	CreatedAt           time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at" db:"updated_at"`
+
+	Delta *FinancialHealthDelta `json:"delta,omitempty" db:"-"` // изменение к предыдущему снимку
+}
+
+// FinancialHealthDelta изменение баллов между двумя снимками
+type FinancialHealthDelta struct {
+	OverallScore       int `json:"overall_score"`
+	SavingsScore       int `json:"savings_score"`
+	BudgetScore        int `json:"budget_score"`
+	DebtScore          int `json:"debt_score"`
+	EmergencyFundScore int `json:"emergency_fund_score"`
+}
+
+// FinancialHealthHistory снимки по месяцам от старых к новым
+type FinancialHealthHistory struct {
+	Months    int                       `json:"months"`
+	Snapshots []FinancialHealthSnapshot `json:"snapshots"`
+	Change    *FinancialHealthDelta     `json:"change,omitempty"` // от первого снимка периода к последнему
+}
+
+// прогноз денежного потока на несколько месяцев вперед
+type CashFlowForecast struct {
+	Currency        string          `json:"currency"`
+	Months          int             `json:"months"`           // горизонт прогноза в месяцах
+	StartingBalance decimal.Decimal `json:"starting_balance"` // текущий остаток на ликвидных счетах (наличные + банк)
+	EndingBalance   decimal.Decimal `json:"ending_balance"`   // прогнозный остаток на конец горизонта
+	LowestBalance   decimal.Decimal `json:"lowest_balance"`   // минимальный прогнозный остаток (если < 0 - будет кассовый разрыв)
+	Points          []ForecastPoint `json:"points"`
+	Items           []ForecastItem  `json:"items"` // регулярные позиции на которых построен прогноз
+}
+
+// прогноз на один месяц
+type ForecastPoint struct {
+	Period            string          `json:"period"`             // "2024-05"
+	RecurringIncome   decimal.Decimal `json:"recurring_income"`   // повторяющиеся доходы (зарплата и т.п.)
+	RecurringExpenses decimal.Decimal `json:"recurring_expenses"` // повторяющиеся расходы (подписки, аренда)
+	LoanPayments      decimal.Decimal `json:"loan_payments"`      // регулярные переводы на кредитные/долговые счета
+	GoalContributions decimal.Decimal `json:"goal_contributions"` // автопополнения целей
+	ExpectedIncome    decimal.Decimal `json:"expected_income"`    // средний нерегулярный доход за прошлые месяцы
+	ExpectedSpending  decimal.Decimal `json:"expected_spending"`  // средние нерегулярные расходы по категориям
+	InvestmentIncome  decimal.Decimal `json:"investment_income"`  // ожидаемые дивиденды и купоны
+	DepositMaturities decimal.Decimal `json:"deposit_maturities"` // вклады, которые закончатся в этом месяце (сумма с процентами)
+	PlannedIncome     decimal.Decimal `json:"planned_income"`     // запланированные разовые поступления
+	PlannedExpenses   decimal.Decimal `json:"planned_expenses"`   // запланированные разовые платежи
+	AccountFees       decimal.Decimal `json:"account_fees"`       // комиссии по правилам счетов и проценты на минусовой остаток
+	AccountCredits    decimal.Decimal `json:"account_credits"`    // кэшбэк и проценты на остаток по правилам счетов
+	NetFlow           decimal.Decimal `json:"net_flow"`
+	ProjectedBalance  decimal.Decimal `json:"projected_balance"` // остаток на конец месяца
+}
+
+// регулярная позиция прогноза
+type ForecastItem struct {
+	Source      string          `json:"source"` // recurring_income, recurring_expense, loan, goal, dividend, coupon, deposit, planned_income, planned_expense, account_fee, account_cashback, account_interest
+	ReferenceID uuid.UUID       `json:"reference_id"`
+	Description string          `json:"description"`
+	Amount      decimal.Decimal `json:"amount"` // сумма за весь горизонт прогноза
+}
+
+// типы аномалий
+const (
+	AnomalyTypeCategoryOutlier = "category_outlier" // сумма сильно выше обычной для категории
+	AnomalyTypeMerchantOutlier = "merchant_outlier" // сумма сильно выше обычной для этого получателя
+	AnomalyTypeDuplicate       = "duplicate"        // повторное списание той же суммы за несколько минут
+	AnomalyTypeNewMerchant     = "new_merchant"     // крупная трата у нового получателя
+)
+
+// подозрительная транзакция
+type Anomaly struct {
+	Type                 string           `json:"type"`
+	Severity             string           `json:"severity"` // low, medium, high
+	TransactionID        uuid.UUID        `json:"transaction_id"`
+	RelatedTransactionID *uuid.UUID       `json:"related_transaction_id,omitempty"` // для дублей - первая транзакция пары
+	Date                 time.Time        `json:"date"`
+	Amount               decimal.Decimal  `json:"amount"`
+	Description          string           `json:"description"`
+	CategoryID           uuid.UUID        `json:"category_id"`
+	Expected             *decimal.Decimal `json:"expected,omitempty"` // обычная сумма (среднее по истории)
+	ZScore               float64          `json:"z_score,omitempty"`  // на сколько стандартных отклонений выше среднего
+	Message              string           `json:"message"`
+}
+
+// траты, разложенные по дням недели, числам месяца и времени суток (данные для календаря-тепловой карты)
+type SpendingPatterns struct {
+	Period            Period               `json:"period"`
+	Currency          string               `json:"currency"`
+	StartDate         time.Time            `json:"start_date"`
+	EndDate           time.Time            `json:"end_date"`
+	TotalSpent        decimal.Decimal      `json:"total_spent"`
+	TransactionCount  int                  `json:"transaction_count"`
+	Days              int                  `json:"days"`                // дней в периоде
+	AverageDailySpend decimal.Decimal      `json:"average_daily_spend"` // TotalSpent / Days, дни без трат тоже считаются
+	Calendar          []DailySpending      `json:"calendar"`            // каждый день периода, в том числе без трат
+	ByWeekday         []WeekdaySpending    `json:"by_weekday"`
+	ByDayOfMonth      []DayOfMonthSpending `json:"by_day_of_month"`
+	ByHour            []HourSpending       `json:"by_hour"` // по времени внесения операции в часовом поясе пользователя
+	MostExpensiveDay  *DailySpending       `json:"most_expensive_day,omitempty"`
+	Insights          []string             `json:"insights"`
+}
+
+// траты за один день
+type DailySpending struct {
+	Date  string          `json:"date"` // "2024-05-17"
+	Total decimal.Decimal `json:"total"`
+	Count int             `json:"count"`
+}
+
+// траты по дню недели
+type WeekdaySpending struct {
+	Weekday int             `json:"weekday"` // 1 - понедельник, 7 - воскресенье
+	Total   decimal.Decimal `json:"total"`
+	Count   int             `json:"count"`
+	Average decimal.Decimal `json:"average"` // в среднем за один такой день периода
+}
+
+// траты по числу месяца
+type DayOfMonthSpending struct {
+	Day   int             `json:"day"`
+	Total decimal.Decimal `json:"total"`
+	Count int             `json:"count"`
+}
+
+// траты по часу суток
+type HourSpending struct {
+	Hour  int             `json:"hour"`
+	Total decimal.Decimal `json:"total"`
+	Count int             `json:"count"`
+}
+
+// SpendingMapGroup как группировать траты на карте
+type SpendingMapGroup string
+
+const (
+	SpendingMapByGeohash SpendingMapGroup = "geohash" // ячейки сетки geohash заданной точности
+	SpendingMapByCity    SpendingMapGroup = "city"
+)
+
+// SpendingMapCell траты одной ячейки карты в валюте операций (строка из SQL)
+type SpendingMapCell struct {
+	Key       string
+	City      string
+	Currency  string
+	Count     int
+	Total     decimal.Decimal
+	Latitude  *float64
+	Longitude *float64
+}
+
+// SpendingMapPoint точка карты трат: центр операций ячейки и их сумма
+type SpendingMapPoint struct {
+	Key       string          `json:"key"` // префикс geohash или название города
+	City      string          `json:"city,omitempty"`
+	Latitude  *float64        `json:"latitude,omitempty"` // средние координаты операций; у города без координат нет
+	Longitude *float64        `json:"longitude,omitempty"`
+	Total     decimal.Decimal `json:"total"`
+	Count     int             `json:"count"`
+}
+
+// SpendingMap траты за период с привязкой к месту, для карты
+type SpendingMap struct {
+	Period    Period             `json:"period"`
+	Currency  string             `json:"currency"`
+	StartDate time.Time          `json:"start_date"`
+	EndDate   time.Time          `json:"end_date"`
+	GroupBy   SpendingMapGroup   `json:"group_by"`
+	Precision int                `json:"precision,omitempty"` // длина префикса geohash
+	Points    []SpendingMapPoint `json:"points"`              // от больших сумм к меньшим
+	Total     decimal.Decimal    `json:"total"`
+	Count     int                `json:"count"`
+}
+
+// FireScenario параметры расчета финансовой независимости; nil - значение из данных пользователя
+type FireScenario struct {
+	Currency       string
+	NetWorth       *decimal.Decimal // стартовый капитал, по умолчанию чистый капитал
+	AnnualIncome   *decimal.Decimal // по умолчанию доходы за последние 12 месяцев
+	AnnualExpenses *decimal.Decimal // по умолчанию расходы за последние 12 месяцев
+	SavingsRate    *decimal.Decimal // %, задает расходы как долю дохода
+	ReturnRate     *decimal.Decimal // ожидаемая реальная доходность портфеля, % годовых
+	WithdrawalRate *decimal.Decimal // безопасная ставка изъятия, % в год
+	MaxYears       int              // горизонт расчета
+}
+
+// FireProjection сколько лет до финансовой независимости (FIRE): капитал растет на доходность
+// и ежегодные сбережения, пока не достигнет расходов / ставка изъятия
+type FireProjection struct {
+	Currency       string          `json:"currency"`
+	NetWorth       decimal.Decimal `json:"net_worth"`
+	AnnualIncome   decimal.Decimal `json:"annual_income"`
+	AnnualExpenses decimal.Decimal `json:"annual_expenses"`
+	AnnualSavings  decimal.Decimal `json:"annual_savings"`
+	SavingsRate    decimal.Decimal `json:"savings_rate"`    // % дохода
+	ReturnRate     decimal.Decimal `json:"return_rate"`     // % годовых
+	WithdrawalRate decimal.Decimal `json:"withdrawal_rate"` // % в год
+	FireNumber     decimal.Decimal `json:"fire_number"`     // капитал, с которого можно жить на изъятия
+	Progress       decimal.Decimal `json:"progress"`        // % от fire_number уже накоплено
+	Reached        bool            `json:"reached"`
+	// nil - при этих параметрах цель не достигается за горизонт расчета
+	YearsToFire *decimal.Decimal `json:"years_to_fire,omitempty"`
+	FireDate    *time.Time       `json:"fire_date,omitempty"`
+	CurrentAge  *int             `json:"current_age,omitempty"` // по дате рождения из профиля
+	FireAge     *int             `json:"fire_age,omitempty"`
+	Series      []FirePoint      `json:"series"` // по годам до достижения цели или конца горизонта
+}
+
+// капитал на конец года прогноза
+type FirePoint struct {
+	Year          int             `json:"year"` // 0 - сейчас
+	Age           *int            `json:"age,omitempty"`
+	NetWorth      decimal.Decimal `json:"net_worth"`
+	Contributions decimal.Decimal `json:"contributions"` // накопленные сбережения с начала прогноза
+	Growth        decimal.Decimal `json:"growth"`        // накопленный доход от доходности
+	FireNumber    decimal.Decimal `json:"fire_number"`
+}
@@ -17,10 +17,15 @@ const (
 	BudgetPeriodCustom    BudgetPeriod = "custom" //кастомно как разница между StartDate и EndDate
 )
 
+// DefaultBudgetAlertThresholds пороги оповещений нового бюджета (% от лимита), если не заданы ни пороги, ни alert_percent
+var DefaultBudgetAlertThresholds = []int{50, 80, 100, 120}
+
 type Budget struct {
 	ID           uuid.UUID       `json:"id" db:"id"`
 	UserID       uuid.UUID       `json:"user_id" db:"user_id"`
 	CategoryID   *uuid.UUID      `json:"category_id" db:"category_id"`
+	AccountID    *uuid.UUID      `json:"account_id" db:"account_id"` // только расходы с этого счета
+	PayeeID      *uuid.UUID      `json:"payee_id" db:"payee_id"`     // только расходы у этого получателя
 	Name         string          `json:"name" db:"name"`
 	Amount       decimal.Decimal `json:"amount" db:"amount"`
 	Currency     string          `json:"currency" db:"currency"`
@@ -28,32 +33,50 @@ type Budget struct {
 	StartDate    time.Time       `json:"start_date" db:"start_date"`
 	EndDate      *time.Time      `json:"end_date" db:"end_date"`
 	IsActive     bool            `json:"is_active" db:"is_active"`
-	AlertPercent int             `json:"alert_percent" db:"alert_percent"` // уведомляеь если достигло
-	Notes        string          `json:"notes" db:"notes"`
-	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time       `json:"updated_at" db:"updated_at"`
+	AlertPercent int             `json:"alert_percent" db:"alert_percent"` // уведомляеь если достигло; младший из alert_thresholds
+	// AlertThresholds пороги оповещений по возрастанию; каждый срабатывает один раз за период
+	AlertThresholds []int     `json:"alert_thresholds" db:"alert_thresholds"`
+	Notes           string    `json:"notes" db:"notes"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
 
 	// Вычисляются на лету
 	Spent        decimal.Decimal `json:"spent" db:"-"`
 	Remaining    decimal.Decimal `json:"remaining" db:"-"`
 	SpentPercent float64         `json:"spent_percent" db:"-"`
+	Scope        string          `json:"scope" db:"-"` // на что распространяется бюджет: категория, счет, получатель
 	Category     *Category       `json:"category,omitempty"`
 }
 
+// BudgetSpendPeriod период бюджета с его фильтрами: расходы многих бюджетов и периодов считаются одним запросом
+type BudgetSpendPeriod struct {
+	Start      time.Time
+	End        time.Time
+	CategoryID *uuid.UUID
+	AccountID  *uuid.UUID
+	PayeeID    *uuid.UUID
+}
+
 type BudgetCreate struct {
 	CategoryID   *uuid.UUID      `json:"category_id"`
+	AccountID    *uuid.UUID      `json:"account_id"`
+	PayeeID      *uuid.UUID      `json:"payee_id"`
 	Name         string          `json:"name" binding:"required"`
 	Amount       decimal.Decimal `json:"amount" binding:"required"`
 	Currency     string          `json:"currency" binding:"required"`
 	Period       BudgetPeriod    `json:"period" binding:"required"`
 	StartDate    time.Time       `json:"start_date" binding:"required"`
 	EndDate      *time.Time      `json:"end_date"`
-	AlertPercent int             `json:"alert_percent"`
-	Notes        string          `json:"notes"`
+	AlertPercent int             `json:"alert_percent"` // один порог + 100%, если не заданы alert_thresholds
+	// AlertThresholds пороги в % от лимита (например, 50, 80, 100, 120)
+	AlertThresholds []int  `json:"alert_thresholds"`
+	Notes           string `json:"notes"`
 }
 
 type BudgetUpdate struct {
 	CategoryID   *uuid.UUID       `json:"category_id"`
+	AccountID    *uuid.UUID       `json:"account_id"`
+	PayeeID      *uuid.UUID       `json:"payee_id"`
 	Name         *string          `json:"name"`
 	Amount       *decimal.Decimal `json:"amount"`
 	Period       *BudgetPeriod    `json:"period"`
@@ -61,7 +84,9 @@ type BudgetUpdate struct {
 	EndDate      *time.Time       `json:"end_date"`
 	IsActive     *bool            `json:"is_active"`
 	AlertPercent *int             `json:"alert_percent"`
-	Notes        *string          `json:"notes"`
+	// AlertThresholds заменяет пороги целиком; nil - не менять
+	AlertThresholds []int   `json:"alert_thresholds"`
+	Notes           *string `json:"notes"`
 }
 
 type BudgetSummary struct {
@@ -75,8 +100,70 @@ type BudgetSummary struct {
 type BudgetAlert struct {
 	BudgetID   uuid.UUID       `json:"budget_id"`
 	BudgetName string          `json:"budget_name"`
+	Scope      string          `json:"scope"`
 	Amount     decimal.Decimal `json:"amount"`
 	Spent      decimal.Decimal `json:"spent"`
 	Percent    float64         `json:"percent"`
 	AlertType  string          `json:"alert_type"`
+	Threshold  int             `json:"threshold"`          // старший достигнутый порог
+	FiredAt    *time.Time      `json:"fired_at,omitempty"` // когда порог сработал (ушло уведомление); nil - еще не срабатывал
+
+	PeriodStart time.Time `json:"period_start"` // начало текущего периода бюджета
+}
+
+// запрос на автоподбор бюджетов по истории расходов
+type BudgetSuggestRequest struct {
+	Months        int    `json:"months"`         // сколько последних полных месяцев анализируем (по умолчанию 3)
+	BufferPercent int    `json:"buffer_percent"` // запас сверху медианы в процентах (по умолчанию 10)
+	Currency      string `json:"currency"`       // валюта бюджетов, по умолчанию основная валюта пользователя; расходы в других валютах не учитываются
+	Create        bool   `json:"create"`         // сразу создать бюджеты по предложенным суммам
+}
+
+type BudgetSuggestion struct {
+	CategoryID      uuid.UUID       `json:"category_id"`
+	CategoryName    string          `json:"category_name"`
+	Median          decimal.Decimal `json:"median"`
+	Average         decimal.Decimal `json:"average"`
+	SuggestedAmount decimal.Decimal `json:"suggested_amount"` // медиана + запас, округлено до 100
+	ActiveMonths    int             `json:"active_months"`    // в скольких месяцах были расходы
+	HasBudget       bool            `json:"has_budget"`       // на категорию уже есть активный бюджет
+	Budget          *Budget         `json:"budget,omitempty"` // созданный бюджет если create=true
+}
+
+// факт против бюджета за один период
+type BudgetPeriodResult struct {
+	PeriodStart  time.Time       `json:"period_start"`
+	PeriodEnd    time.Time       `json:"period_end"`
+	Budgeted     decimal.Decimal `json:"budgeted"`
+	Spent        decimal.Decimal `json:"spent"`
+	Variance     decimal.Decimal `json:"variance"` // budgeted - spent, отрицательное = перерасход
+	SpentPercent float64         `json:"spent_percent"`
+	IsOver       bool            `json:"is_over"`
+	IsCurrent    bool            `json:"is_current"` // период еще идет
+}
+
+// история бюджета за последние N периодов (от старых к новым)
+type BudgetHistory struct {
+	BudgetID         uuid.UUID            `json:"budget_id"`
+	BudgetName       string               `json:"budget_name"`
+	CategoryID       *uuid.UUID           `json:"category_id"`
+	AccountID        *uuid.UUID           `json:"account_id"`
+	PayeeID          *uuid.UUID           `json:"payee_id"`
+	Period           BudgetPeriod         `json:"period"`
+	Currency         string               `json:"currency"`
+	Periods          []BudgetPeriodResult `json:"periods"`
+	AverageSpent     decimal.Decimal      `json:"average_spent"`
+	AveragePercent   float64              `json:"average_percent"`
+	OverCount        int                  `json:"over_count"`        // сколько завершенных периодов с перерасходом
+	ConsistentlyOver bool                 `json:"consistently_over"` // перерасход в половине и более завершенных периодов
+}
+
+// сводный отчет факт/бюджет по всем активным бюджетам
+type BudgetHistoryReport struct {
+	Periods          int             `json:"periods"`
+	TotalBudgeted    decimal.Decimal `json:"total_budgeted"`
+	TotalSpent       decimal.Decimal `json:"total_spent"`
+	TotalVariance    decimal.Decimal `json:"total_variance"`
+	ConsistentlyOver int             `json:"consistently_over"` // сколько бюджетов систематически превышается
+	Budgets          []BudgetHistory `json:"budgets"`
 }
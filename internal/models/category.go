@@ -24,6 +24,7 @@ type Category struct {
 	ParentID  *uuid.UUID   `json:"parent_id" db:"parent_id"`
 	IsSystem  bool         `json:"is_system" db:"is_system"`
 	SortOrder int          `json:"sort_order" db:"sort_order"`
+	IsFixed   bool         `json:"is_fixed" db:"is_fixed"` // обязательный расход (аренда, коммуналка); с учетом настройки пользователя
 	CreatedAt time.Time    `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
 
@@ -36,6 +37,7 @@ type CategoryCreate struct {
 	Icon     string       `json:"icon"`
 	Color    string       `json:"color"`
 	ParentID *uuid.UUID   `json:"parent_id"`
+	IsFixed  bool         `json:"is_fixed"`
 }
 
 type CategoryUpdate struct {
@@ -46,14 +48,19 @@ type CategoryUpdate struct {
 	SortOrder *int       `json:"sort_order"`
 }
 
-// дефолтные системные категориии
+// CategoryFixed отмечает категорию расходов обязательной или необязательной для пользователя
+type CategoryFixed struct {
+	IsFixed *bool `json:"is_fixed" binding:"required"`
+}
+
+// дефолтные системные категориии; должны совпадать с migrationInsertDefaultCategories
 var DefaultCategories = []Category{
-	{Name: "Зарплата", Type: CategoryTypeIncome, Icon: "💵", Color: "#4CAF50", IsSystem: true},
+	{Name: "Зарплата", Type: CategoryTypeIncome, Icon: "💰", Color: "#4CAF50", IsSystem: true},
 	{Name: "Фриланс", Type: CategoryTypeIncome, Icon: "💻", Color: "#8BC34A", IsSystem: true},
 	{Name: "Инвестиции", Type: CategoryTypeIncome, Icon: "📈", Color: "#009688", IsSystem: true},
-	{Name: "Дивиденды", Type: CategoryTypeIncome, Icon: "💸", Color: "#00BCD4", IsSystem: true},
+	{Name: "Дивиденды", Type: CategoryTypeIncome, Icon: "💵", Color: "#00BCD4", IsSystem: true},
 	{Name: "Подарки", Type: CategoryTypeIncome, Icon: "🎁", Color: "#03A9F4", IsSystem: true},
-	{Name: "Другой доход", Type: CategoryTypeIncome, Icon: "💰", Color: "#2196F3", IsSystem: true},
+	{Name: "Другой доход", Type: CategoryTypeIncome, Icon: "💸", Color: "#2196F3", IsSystem: true},
 	{Name: "Продукты", Type: CategoryTypeExpense, Icon: "🛒", Color: "#FF5722", IsSystem: true},
 	{Name: "Рестораны", Type: CategoryTypeExpense, Icon: "🍽️", Color: "#FF9800", IsSystem: true},
 	{Name: "Транспорт", Type: CategoryTypeExpense, Icon: "🚗", Color: "#FFC107", IsSystem: true},
@@ -68,5 +75,5 @@ var DefaultCategories = []Category{
 	{Name: "Связь", Type: CategoryTypeExpense, Icon: "📞", Color: "#009688", IsSystem: true},
 	{Name: "Домашние животные", Type: CategoryTypeExpense, Icon: "🐕", Color: "#4CAF50", IsSystem: true},
 	{Name: "Другие расходы", Type: CategoryTypeExpense, Icon: "📋", Color: "#9E9E9E", IsSystem: true},
-	{Name: "Перевод", Type: CategoryTypeTransfer, Icon: "💳", Color: "#607D8B", IsSystem: true},
+	{Name: "Перевод", Type: CategoryTypeTransfer, Icon: "🔄", Color: "#607D8B", IsSystem: true},
 }
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type ChallengeKind string
+
+const (
+	ChallengeNoSpend    ChallengeKind = "no_spend"    // ни одного расхода в отмеченные дни периода
+	ChallengeSpendLimit ChallengeKind = "spend_limit" // расходы за период не больше amount
+)
+
+type ChallengePeriod string
+
+const (
+	ChallengePeriodDaily   ChallengePeriod = "daily"
+	ChallengePeriodWeekly  ChallengePeriod = "weekly" // с понедельника по воскресенье
+	ChallengePeriodMonthly ChallengePeriod = "monthly"
+)
+
+type ChallengeStatus string
+
+const (
+	ChallengeInProgress ChallengeStatus = "in_progress"
+	ChallengeCompleted  ChallengeStatus = "completed"
+	ChallengeFailed     ChallengeStatus = "failed"
+)
+
+// Challenge челлендж по расходам: условие проверяется за каждый период, серия - сколько периодов подряд оно выполнено.
+// фильтры (категория, счет, получатель) работают как у бюджетов
+type Challenge struct {
+	ID         uuid.UUID        `json:"id" db:"id"`
+	UserID     uuid.UUID        `json:"user_id" db:"user_id"`
+	Name       string           `json:"name" db:"name"`
+	Kind       ChallengeKind    `json:"kind" db:"kind"`
+	Period     ChallengePeriod  `json:"period" db:"period"`
+	Amount     *decimal.Decimal `json:"amount,omitempty" db:"amount"` // лимит для spend_limit
+	Weekdays   []int            `json:"weekdays" db:"weekdays"`       // дни no_spend: 1 - пн ... 7 - вс; пусто - все дни
+	CategoryID *uuid.UUID       `json:"category_id" db:"category_id"`
+	AccountID  *uuid.UUID       `json:"account_id" db:"account_id"`
+	PayeeID    *uuid.UUID       `json:"payee_id" db:"payee_id"`
+	StartDate  time.Time        `json:"start_date" db:"start_date"`
+	EndDate    *time.Time       `json:"end_date" db:"end_date"` // nil - бессрочный
+	IsActive   bool             `json:"is_active" db:"is_active"`
+
+	CurrentStreak int        `json:"current_streak" db:"current_streak"` // выполнено периодов подряд, включая последний подведенный
+	BestStreak    int        `json:"best_streak" db:"best_streak"`
+	LastPeriod    *time.Time `json:"last_period" db:"last_period"` // начало последнего подведенного периода
+	Completed     int        `json:"completed" db:"-"`
+	Failed        int        `json:"failed" db:"-"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+
+	Progress *ChallengeProgress `json:"progress,omitempty" db:"-"` // текущий период; nil - челлендж еще не начался или закончился
+	History  []ChallengeResult  `json:"history,omitempty" db:"-"`
+}
+
+// ChallengeProgress состояние текущего периода: failed - условие уже нарушено до конца периода
+type ChallengeProgress struct {
+	PeriodStart time.Time        `json:"period_start"`
+	PeriodEnd   time.Time        `json:"period_end"`
+	Status      ChallengeStatus  `json:"status"`
+	Spent       decimal.Decimal  `json:"spent"`
+	Remaining   *decimal.Decimal `json:"remaining,omitempty"` // spend_limit
+	Percent     float64          `json:"percent,omitempty"`   // spend_limit: потрачено от лимита
+	Days        int              `json:"days,omitempty"`      // no_spend: отмеченных дней в периоде
+	CleanDays   int              `json:"clean_days"`          // прошедших отмеченных дней без расходов
+	DaysLeft    int              `json:"days_left"`
+}
+
+// ChallengeResult итог завершившегося периода
+type ChallengeResult struct {
+	ChallengeID uuid.UUID       `json:"challenge_id" db:"challenge_id"`
+	PeriodStart time.Time       `json:"period_start" db:"period_start"`
+	PeriodEnd   time.Time       `json:"period_end" db:"period_end"`
+	Status      ChallengeStatus `json:"status" db:"status"`
+	Spent       decimal.Decimal `json:"spent" db:"spent"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+type ChallengeCreate struct {
+	Name       string           `json:"name" binding:"required,max=100"`
+	Kind       ChallengeKind    `json:"kind" binding:"required,oneof=no_spend spend_limit"`
+	Period     ChallengePeriod  `json:"period" binding:"required,oneof=daily weekly monthly"`
+	Amount     *decimal.Decimal `json:"amount"`
+	Weekdays   []int            `json:"weekdays" binding:"omitempty,max=7,dive,min=1,max=7"`
+	CategoryID *uuid.UUID       `json:"category_id"`
+	AccountID  *uuid.UUID       `json:"account_id"`
+	PayeeID    *uuid.UUID       `json:"payee_id"`
+	StartDate  *time.Time       `json:"start_date"` // по умолчанию сегодня
+	EndDate    *time.Time       `json:"end_date"`
+}
+
+// ChallengeUpdate условия и фильтры не меняются, чтобы не переписывать прошлые итоги
+type ChallengeUpdate struct {
+	Name     *string          `json:"name" binding:"omitempty,max=100"`
+	Amount   *decimal.Decimal `json:"amount"`
+	EndDate  *time.Time       `json:"end_date"`
+	IsActive *bool            `json:"is_active"`
+}
+
+// ChallengeEvaluateResult итог фонового подведения периодов
+type ChallengeEvaluateResult struct {
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Errors    int `json:"errors"`
+}
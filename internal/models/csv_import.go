@@ -0,0 +1,138 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// CSVAmountSign как в выгрузке банка отличить расход от поступления
+type CSVAmountSign string
+
+const (
+	CSVAmountNegativeExpense CSVAmountSign = "negative_expense" // одна колонка, расход со знаком минус
+	CSVAmountPositiveExpense CSVAmountSign = "positive_expense" // одна колонка, поступление со знаком минус (выписки кредиток)
+	CSVAmountDebitCredit     CSVAmountSign = "debit_credit"     // расход и поступление в разных колонках
+)
+
+// CSVMapping какая колонка выгрузки что значит и как читать значения. колонки - номера с 0
+type CSVMapping struct {
+	Encoding          string        `json:"encoding" binding:"omitempty,oneof=utf-8 windows-1251"` // пусто - определяется по файлу
+	Delimiter         string        `json:"delimiter" binding:"omitempty,len=1"`                   // пусто - определяется по файлу
+	SkipRows          int           `json:"skip_rows" binding:"min=0,max=50"`                      // строк перед заголовком (шапка выписки)
+	HasHeader         bool          `json:"has_header"`
+	DateColumn        int           `json:"date_column" binding:"min=0"`
+	DateFormat        string        `json:"date_format"` // "DD.MM.YYYY", "YYYY-MM-DD HH:mm" или раскладка Go; пусто - определяется по значениям
+	AmountSign        CSVAmountSign `json:"amount_sign" binding:"required,oneof=negative_expense positive_expense debit_credit"`
+	AmountColumn      *int          `json:"amount_column" binding:"omitempty,min=0"`
+	DebitColumn       *int          `json:"debit_column" binding:"omitempty,min=0"`          // расход для debit_credit
+	CreditColumn      *int          `json:"credit_column" binding:"omitempty,min=0"`         // поступление для debit_credit
+	DecimalSeparator  string        `json:"decimal_separator" binding:"omitempty,oneof=. ,"` // по умолчанию точка
+	DescriptionColumn *int          `json:"description_column" binding:"omitempty,min=0"`
+	CategoryColumn    *int          `json:"category_column" binding:"omitempty,min=0"` // сопоставляется с категориями пользователя по названию
+	// Header названия колонок выгрузки, для которой настроен профиль: по ним профиль узнается при следующей загрузке
+	Header []string `json:"header,omitempty"`
+}
+
+// CSVImportProfile сохраненное сопоставление колонок для выгрузок одного банка
+type CSVImportProfile struct {
+	ID               uuid.UUID  `json:"id" db:"id"`
+	UserID           uuid.UUID  `json:"user_id" db:"user_id"`
+	Name             string     `json:"name" db:"name"`
+	AccountID        *uuid.UUID `json:"account_id,omitempty" db:"account_id"` // счет по умолчанию
+	CategoryID       uuid.UUID  `json:"category_id" db:"category_id"`         // для расходов без сопоставленной категории
+	IncomeCategoryID *uuid.UUID `json:"income_category_id,omitempty" db:"income_category_id"`
+	Mapping          CSVMapping `json:"mapping" db:"mapping"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CSVImportProfileCreate struct {
+	Name             string     `json:"name" binding:"required,max=100"`
+	AccountID        *uuid.UUID `json:"account_id"`
+	CategoryID       uuid.UUID  `json:"category_id" binding:"required"`
+	IncomeCategoryID *uuid.UUID `json:"income_category_id"`
+	Mapping          CSVMapping `json:"mapping"`
+}
+
+type CSVImportProfileUpdate struct {
+	Name             *string     `json:"name" binding:"omitempty,max=100"`
+	AccountID        *uuid.UUID  `json:"account_id"`
+	CategoryID       *uuid.UUID  `json:"category_id"`
+	IncomeCategoryID *uuid.UUID  `json:"income_category_id"`
+	Mapping          *CSVMapping `json:"mapping"`
+}
+
+// CSVUpload загруженная выгрузка; хранится сутки, пока пользователь настраивает сопоставление
+type CSVUpload struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Filename  string    `json:"filename" db:"filename"`
+	Content   []byte    `json:"-" db:"content"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CSVColumn колонка выгрузки с примерами значений и догадкой о содержимом
+type CSVColumn struct {
+	Index   int      `json:"index"`
+	Name    string   `json:"name"` // из заголовка или "Колонка N"
+	Kind    string   `json:"kind"` // date, amount или text
+	Samples []string `json:"samples"`
+}
+
+// CSVDetection что удалось понять о выгрузке: колонки и предлагаемое сопоставление
+type CSVDetection struct {
+	UploadID  uuid.UUID   `json:"upload_id"`
+	Filename  string      `json:"filename"`
+	Rows      int         `json:"rows"` // строк с данными
+	Columns   []CSVColumn `json:"columns"`
+	Suggested CSVMapping  `json:"suggested"`
+	// ProfileID сохраненный профиль, которому подходит выгрузка (те же колонки в заголовке)
+	ProfileID *uuid.UUID `json:"profile_id,omitempty"`
+}
+
+// CSVImportRequest импорт загруженной выгрузки по сохраненному профилю или разовому сопоставлению
+type CSVImportRequest struct {
+	ProfileID        *uuid.UUID  `json:"profile_id"`
+	Mapping          *CSVMapping `json:"mapping"`     // вместо профиля
+	AccountID        *uuid.UUID  `json:"account_id"`  // по умолчанию счет профиля
+	CategoryID       *uuid.UUID  `json:"category_id"` // по умолчанию категории профиля
+	IncomeCategoryID *uuid.UUID  `json:"income_category_id"`
+	DryRun           bool        `json:"dry_run"`
+	// SaveAs сохранить разовое сопоставление профилем с этим названием
+	SaveAs string `json:"save_as" binding:"max=100"`
+}
+
+type CSVImportRowStatus string
+
+const (
+	CSVImportRowCreated   CSVImportRowStatus = "created"
+	CSVImportRowReady     CSVImportRowStatus = "ready" // dry_run: строка прошла проверку
+	CSVImportRowDuplicate CSVImportRowStatus = "duplicate"
+	CSVImportRowInvalid   CSVImportRowStatus = "invalid"
+)
+
+// CSVImportRow итог по одной строке выгрузки; row - номер строки файла с 1
+type CSVImportRow struct {
+	Row         int                `json:"row"`
+	Status      CSVImportRowStatus `json:"status"`
+	Error       string             `json:"error,omitempty"`
+	Date        *time.Time         `json:"date,omitempty"`
+	Type        TransactionType    `json:"type,omitempty"`
+	Amount      *decimal.Decimal   `json:"amount,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Transaction *Transaction       `json:"transaction,omitempty"`
+}
+
+// CSVImportResult итог импорта. при ошибке хотя бы в одной строке не записывается ничего
+type CSVImportResult struct {
+	DryRun     bool              `json:"dry_run"`
+	Total      int               `json:"total"`
+	Created    int               `json:"created"`
+	Duplicates int               `json:"duplicates"`
+	Invalid    int               `json:"invalid"`
+	Rows       []CSVImportRow    `json:"rows"`
+	Profile    *CSVImportProfile `json:"profile,omitempty"` // сохраненный по save_as
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// CurrencyRedenomination деноминация валюты или замена токена. суммы в Currency до EffectiveDate
+// показываются как сумма × Factor в NewCurrency; при смене кода пересчитываются все суммы в старом коде
+type CurrencyRedenomination struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	Currency      string          `json:"currency" db:"currency"`
+	NewCurrency   string          `json:"new_currency" db:"new_currency"`
+	Factor        decimal.Decimal `json:"factor" db:"factor"`
+	EffectiveDate time.Time       `json:"effective_date" db:"effective_date"`
+	Note          string          `json:"note" db:"note"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// CurrencyRedenominationCreate новая деноминация; пустой new_currency - код не меняется (BYR 2000 → BYR)
+type CurrencyRedenominationCreate struct {
+	Currency      string          `json:"currency" binding:"required,min=2,max=10"`
+	NewCurrency   string          `json:"new_currency" binding:"omitempty,min=2,max=10"`
+	Factor        decimal.Decimal `json:"factor" binding:"required"`
+	EffectiveDate time.Time       `json:"effective_date" binding:"required"`
+	Note          string          `json:"note" binding:"max=255"`
+}
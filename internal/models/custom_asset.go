@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// CustomAssetClass класс актива, которого нет на бирже
+type CustomAssetClass string
+
+const (
+	AssetClassRealEstate  CustomAssetClass = "real_estate"  // квартира, дом, участок
+	AssetClassVehicle     CustomAssetClass = "vehicle"      // автомобиль, мотоцикл, лодка
+	AssetClassPrivateLoan CustomAssetClass = "private_loan" // деньги, выданные в долг частному лицу
+	AssetClassValuables   CustomAssetClass = "valuables"    // драгоценности, коллекции, искусство
+	AssetClassBusiness    CustomAssetClass = "business"     // доля в бизнесе
+	AssetClassOther       CustomAssetClass = "other"
+)
+
+// CustomAssetClassInfo элемент справочника классов активов
+type CustomAssetClassInfo struct {
+	Class CustomAssetClass `json:"class"`
+	Name  string           `json:"name"`
+}
+
+// CustomAssetClasses справочник классов для интерфейса
+var CustomAssetClasses = []CustomAssetClassInfo{
+	{AssetClassRealEstate, "Недвижимость"},
+	{AssetClassVehicle, "Транспорт"},
+	{AssetClassPrivateLoan, "Частные займы"},
+	{AssetClassValuables, "Ценности"},
+	{AssetClassBusiness, "Доля в бизнесе"},
+	{AssetClassOther, "Другое"},
+}
+
+// CustomAsset актив с ручной оценкой; стоимость - последняя по дате оценка
+type CustomAsset struct {
+	ID            uuid.UUID        `json:"id" db:"id"`
+	UserID        uuid.UUID        `json:"user_id" db:"user_id"`
+	Name          string           `json:"name" db:"name"`
+	Class         CustomAssetClass `json:"class" db:"class"`
+	Currency      string           `json:"currency" db:"currency"`
+	CurrentValue  decimal.Decimal  `json:"current_value" db:"current_value"`
+	ValuedAt      time.Time        `json:"valued_at" db:"valued_at"` // дата последней оценки
+	PurchaseValue *decimal.Decimal `json:"purchase_value,omitempty" db:"purchase_value"`
+	PurchaseDate  *time.Time       `json:"purchase_date,omitempty" db:"purchase_date"`
+	Notes         string           `json:"notes" db:"notes"`
+	IsActive      bool             `json:"is_active" db:"is_active"` // false - продан/списан, в капитал не входит
+	CreatedAt     time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at" db:"updated_at"`
+
+	// вычисляются на лету, если известна цена покупки
+	Gain        *decimal.Decimal `json:"gain,omitempty" db:"-"`
+	GainPercent *decimal.Decimal `json:"gain_percent,omitempty" db:"-"`
+}
+
+type CustomAssetCreate struct {
+	Name          string           `json:"name" binding:"required"`
+	Class         CustomAssetClass `json:"class" binding:"required"`
+	Currency      string           `json:"currency" binding:"required"`
+	Value         decimal.Decimal  `json:"value" binding:"required"` // первая оценка
+	ValuedAt      *time.Time       `json:"valued_at"`                // по умолчанию сегодня
+	PurchaseValue *decimal.Decimal `json:"purchase_value"`
+	PurchaseDate  *time.Time       `json:"purchase_date"`
+	Notes         string           `json:"notes"`
+}
+
+type CustomAssetUpdate struct {
+	Name          *string           `json:"name"`
+	Class         *CustomAssetClass `json:"class"`
+	PurchaseValue *decimal.Decimal  `json:"purchase_value"`
+	PurchaseDate  *time.Time        `json:"purchase_date"`
+	Notes         *string           `json:"notes"`
+	IsActive      *bool             `json:"is_active"`
+}
+
+// CustomAssetValuation оценка актива на дату
+type CustomAssetValuation struct {
+	ID        uuid.UUID       `json:"id" db:"id"`
+	AssetID   uuid.UUID       `json:"asset_id" db:"asset_id"`
+	Value     decimal.Decimal `json:"value" db:"value"`
+	Date      time.Time       `json:"date" db:"date"`
+	Notes     string          `json:"notes" db:"notes"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+type CustomAssetValuationCreate struct {
+	Value decimal.Decimal `json:"value" binding:"required"`
+	Date  *time.Time      `json:"date"` // по умолчанию сегодня
+	Notes string          `json:"notes"`
+}
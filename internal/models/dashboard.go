@@ -0,0 +1,37 @@
+package models
+
+type DashboardSection string
+
+const (
+	DashboardAccounts     DashboardSection = "accounts"     // сводка по счетам
+	DashboardMonth        DashboardSection = "month"        // доходы и расходы текущего месяца
+	DashboardBudgets      DashboardSection = "budgets"      // превышения и предупреждения бюджетов
+	DashboardGoals        DashboardSection = "goals"        // активные цели с прогрессом
+	DashboardPortfolios   DashboardSection = "portfolios"   // итоги портфелей
+	DashboardTransactions DashboardSection = "transactions" // последние транзакции
+)
+
+var DashboardSections = []DashboardSection{
+	DashboardAccounts, DashboardMonth, DashboardBudgets, DashboardGoals, DashboardPortfolios, DashboardTransactions,
+}
+
+func (s DashboardSection) Valid() bool {
+	for _, section := range DashboardSections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// Dashboard данные главного экрана одним запросом. незапрошенные разделы - null;
+// раздел, который не удалось собрать, тоже null, а причина - в Errors
+type Dashboard struct {
+	Accounts           *AccountSummary             `json:"accounts"`
+	Month              *FinancialSummary           `json:"month"`
+	BudgetAlerts       []BudgetAlert               `json:"budget_alerts"`
+	Goals              []Goal                      `json:"goals"`
+	Portfolios         []PortfolioSummary          `json:"portfolios"`
+	RecentTransactions []Transaction               `json:"recent_transactions"`
+	Errors             map[DashboardSection]string `json:"errors,omitempty"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// DepositCapitalization как часто проценты по вкладу присоединяются к сумме
+type DepositCapitalization string
+
+const (
+	CapitalizationNone      DepositCapitalization = "none" // проценты выплачиваются в конце срока
+	CapitalizationMonthly   DepositCapitalization = "monthly"
+	CapitalizationQuarterly DepositCapitalization = "quarterly"
+	CapitalizationYearly    DepositCapitalization = "yearly"
+)
+
+// Deposit банковский вклад; проценты начисляются ежедневно (фактические дни / 365)
+type Deposit struct {
+	ID              uuid.UUID             `json:"id" db:"id"`
+	UserID          uuid.UUID             `json:"user_id" db:"user_id"`
+	Bank            string                `json:"bank" db:"bank"`
+	Name            string                `json:"name" db:"name"`
+	Currency        string                `json:"currency" db:"currency"`
+	Principal       decimal.Decimal       `json:"principal" db:"principal"`
+	Rate            decimal.Decimal       `json:"rate" db:"rate"` // годовая ставка, %
+	Capitalization  DepositCapitalization `json:"capitalization" db:"capitalization"`
+	OpenedAt        time.Time             `json:"opened_at" db:"opened_at"`
+	TermDays        int                   `json:"term_days" db:"term_days"`
+	MaturityDate    time.Time             `json:"maturity_date" db:"maturity_date"`
+	PayoutAccountID *uuid.UUID            `json:"payout_account_id,omitempty" db:"payout_account_id"` // куда вернутся деньги в конце срока
+	Notes           string                `json:"notes" db:"notes"`
+	IsActive        bool                  `json:"is_active" db:"is_active"` // false - вклад закрыт, в капитал и прогноз не входит
+	CreatedAt       time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at" db:"updated_at"`
+
+	// вычисляются на лету
+	AccruedInterest    decimal.Decimal `json:"accrued_interest" db:"-"`     // начислено на сегодня (после окончания срока - за весь срок)
+	CurrentValue       decimal.Decimal `json:"current_value" db:"-"`        // сумма вклада с начисленными процентами
+	InterestAtMaturity decimal.Decimal `json:"interest_at_maturity" db:"-"` // доход за весь срок
+	AmountAtMaturity   decimal.Decimal `json:"amount_at_maturity" db:"-"`
+	EffectiveYield     decimal.Decimal `json:"effective_yield" db:"-"` // доходность за срок в пересчете на год с учетом капитализации, %
+	DaysLeft           int             `json:"days_left" db:"-"`
+}
+
+type DepositCreate struct {
+	Bank            string                `json:"bank" binding:"required"`
+	Name            string                `json:"name"`
+	Currency        string                `json:"currency" binding:"required"`
+	Principal       decimal.Decimal       `json:"principal" binding:"required"`
+	Rate            decimal.Decimal       `json:"rate" binding:"required"`
+	Capitalization  DepositCapitalization `json:"capitalization"` // по умолчанию none
+	OpenedAt        *time.Time            `json:"opened_at"`      // по умолчанию сегодня
+	TermDays        int                   `json:"term_days" binding:"required"`
+	PayoutAccountID *uuid.UUID            `json:"payout_account_id"`
+	Notes           string                `json:"notes"`
+}
+
+type DepositUpdate struct {
+	Bank            *string    `json:"bank"`
+	Name            *string    `json:"name"`
+	PayoutAccountID *uuid.UUID `json:"payout_account_id"`
+	Notes           *string    `json:"notes"`
+	IsActive        *bool      `json:"is_active"`
+}
+
+// DepositOffer предложение банка для сравнения
+type DepositOffer struct {
+	Bank           string                `json:"bank" binding:"required"`
+	Rate           decimal.Decimal       `json:"rate" binding:"required"`
+	TermDays       int                   `json:"term_days" binding:"required"`
+	Capitalization DepositCapitalization `json:"capitalization"`
+}
+
+type DepositCompareInput struct {
+	Amount decimal.Decimal `json:"amount" binding:"required"`
+	Offers []DepositOffer  `json:"offers" binding:"required,min=1,dive"`
+}
+
+// DepositOfferResult доход по предложению на заданную сумму
+type DepositOfferResult struct {
+	DepositOffer
+	Interest         decimal.Decimal `json:"interest"`
+	AmountAtMaturity decimal.Decimal `json:"amount_at_maturity"`
+	EffectiveYield   decimal.Decimal `json:"effective_yield"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// DrawdownAlertKind вид уведомления о просадке портфеля
+type DrawdownAlertKind string
+
+const (
+	DrawdownFromHigh  DrawdownAlertKind = "drawdown"   // от максимума за 30 дней
+	DrawdownDailyDrop DrawdownAlertKind = "daily_drop" // от закрытия прошлого дня
+)
+
+// DrawdownAlertSettings пороги уведомлений о просадке портфеля, %
+type DrawdownAlertSettings struct {
+	PortfolioID   uuid.UUID        `json:"portfolio_id" db:"portfolio_id"`
+	DrawdownPct   *decimal.Decimal `json:"drawdown_pct" db:"drawdown_pct"`     // nil - выключено
+	DailyDropPct  *decimal.Decimal `json:"daily_drop_pct" db:"daily_drop_pct"` // nil - выключено
+	CooldownHours int              `json:"cooldown_hours" db:"cooldown_hours"` // не чаще одного уведомления каждого вида за это время
+	// когда последний раз уведомили
+	DrawdownAlertedAt  *time.Time `json:"drawdown_alerted_at,omitempty" db:"drawdown_alerted_at"`
+	DailyDropAlertedAt *time.Time `json:"daily_drop_alerted_at,omitempty" db:"daily_drop_alerted_at"`
+}
+
+// DrawdownAlertInput пороги целиком заменяют прежние; null выключает уведомление
+type DrawdownAlertInput struct {
+	DrawdownPct   *decimal.Decimal `json:"drawdown_pct"`
+	DailyDropPct  *decimal.Decimal `json:"daily_drop_pct"`
+	CooldownHours *int             `json:"cooldown_hours" binding:"omitempty,min=1,max=720"`
+}
+
+// PortfolioValueSnapshot стоимость портфеля за день: последняя и максимальная из обновлений цен
+type PortfolioValueSnapshot struct {
+	PortfolioID uuid.UUID       `json:"-" db:"portfolio_id"`
+	Date        time.Time       `json:"date" db:"date"`
+	Value       decimal.Decimal `json:"value" db:"value"`
+	High        decimal.Decimal `json:"high" db:"high"`
+	Currency    string          `json:"currency" db:"currency"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// DrawdownStatus пороги и текущая просадка портфеля по последним сохраненным ценам
+type DrawdownStatus struct {
+	Settings      DrawdownAlertSettings    `json:"settings"`
+	Currency      string                   `json:"currency"`
+	Value         decimal.Decimal          `json:"value"`
+	High30d       decimal.Decimal          `json:"high_30d"`
+	Drawdown      decimal.Decimal          `json:"drawdown"`                 // % от максимума за 30 дней
+	PreviousClose *decimal.Decimal         `json:"previous_close,omitempty"` // nil - вчерашнего снимка нет
+	DailyChange   *decimal.Decimal         `json:"daily_change,omitempty"`   // %, меньше нуля - падение
+	Snapshots     []PortfolioValueSnapshot `json:"snapshots"`
+}
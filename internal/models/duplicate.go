@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// DuplicateGroup транзакции одного счета с одинаковой суммой, близкими датами и похожим описанием -
+// скорее всего одна операция, загруженная из нескольких источников
+type DuplicateGroup struct {
+	AccountID    uuid.UUID       `json:"account_id"`
+	Type         TransactionType `json:"type"`
+	Amount       decimal.Decimal `json:"amount"`
+	Currency     string          `json:"currency"`
+	Transactions []Transaction   `json:"transactions"`
+	KeepID       uuid.UUID       `json:"keep_id"` // какую транзакцию предлагаем оставить
+}
+
+// TransactionMerge объединение дублей: keep_id остается, duplicate_ids удаляются,
+// недостающие у оставшейся заметки, место, получатель, теги и позиции чека берутся из них
+type TransactionMerge struct {
+	KeepID       uuid.UUID   `json:"keep_id" binding:"required"`
+	DuplicateIDs []uuid.UUID `json:"duplicate_ids" binding:"required,min=1"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type EnvelopeEntryType string
+
+const (
+	EnvelopeEntryAllocate EnvelopeEntryType = "allocate" // из нераспределенного в конверт (отрицательная сумма - обратно)
+	EnvelopeEntryMoveIn   EnvelopeEntryType = "move_in"  // перевод из другого конверта
+	EnvelopeEntryMoveOut  EnvelopeEntryType = "move_out" // перевод в другой конверт
+)
+
+// Envelope конверт для бюджетирования с нуля: весь доход раскладывается по конвертам-категориям,
+// расходы по категории тратят деньги конверта, остаток переносится на следующий месяц
+type Envelope struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+	CategoryID uuid.UUID `json:"category_id" db:"category_id"`
+	Name       string    `json:"name" db:"name"`
+	IsArchived bool      `json:"is_archived" db:"is_archived"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+
+	// вычисляются на лету за выбранный месяц
+	Allocated decimal.Decimal `json:"allocated" db:"-"` // положено в этом месяце (с учетом переводов)
+	Spent     decimal.Decimal `json:"spent" db:"-"`     // потрачено в этом месяце
+	Available decimal.Decimal `json:"available" db:"-"` // остаток с учетом прошлых месяцев
+	Category  *Category       `json:"category,omitempty"`
+}
+
+type EnvelopeCreate struct {
+	CategoryID uuid.UUID `json:"category_id" binding:"required"`
+	Name       string    `json:"name"` // по умолчанию - название категории
+}
+
+// EnvelopeAllocate положить деньги в конверт из нераспределенных (отрицательная сумма - вернуть)
+type EnvelopeAllocate struct {
+	Amount decimal.Decimal `json:"amount" binding:"required"`
+	Month  *time.Time      `json:"month"` // по умолчанию - текущий
+	Note   string          `json:"note"`
+}
+
+type EnvelopeMove struct {
+	FromEnvelopeID uuid.UUID       `json:"from_envelope_id" binding:"required"`
+	ToEnvelopeID   uuid.UUID       `json:"to_envelope_id" binding:"required"`
+	Amount         decimal.Decimal `json:"amount" binding:"required"`
+	Month          *time.Time      `json:"month"`
+	Note           string          `json:"note"`
+}
+
+// EnvelopeEntry запись журнала конверта
+type EnvelopeEntry struct {
+	ID                uuid.UUID         `json:"id" db:"id"`
+	UserID            uuid.UUID         `json:"user_id" db:"user_id"`
+	EnvelopeID        uuid.UUID         `json:"envelope_id" db:"envelope_id"`
+	RelatedEnvelopeID *uuid.UUID        `json:"related_envelope_id,omitempty" db:"related_envelope_id"` // второй конверт при переводе
+	Type              EnvelopeEntryType `json:"type" db:"type"`
+	Amount            decimal.Decimal   `json:"amount" db:"amount"` // со знаком: плюс - в конверт, минус - из конверта
+	Month             time.Time         `json:"month" db:"month"`
+	Note              string            `json:"note" db:"note"`
+	CreatedAt         time.Time         `json:"created_at" db:"created_at"`
+}
+
+// EnvelopeMonth состояние конвертов за месяц
+type EnvelopeMonth struct {
+	Month       time.Time       `json:"month"`
+	Currency    string          `json:"currency"`    // конверты ведутся в основной валюте пользователя, операции в других валютах не учитываются
+	Income      decimal.Decimal `json:"income"`      // доход за месяц
+	Allocated   decimal.Decimal `json:"allocated"`   // распределено за месяц
+	Unallocated decimal.Decimal `json:"unallocated"` // весь доход минус все распределения на конец месяца, цель - 0
+	Envelopes   []Envelope      `json:"envelopes"`
+}
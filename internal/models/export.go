@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// UserExport все данные пользователя одним документом (выгрузка для самого пользователя или переезда)
+type UserExport struct {
+	ExportedAt             time.Time               `json:"exported_at"`
+	User                   User                    `json:"user"`
+	Accounts               []Account               `json:"accounts"`
+	Categories             []Category              `json:"categories"` // только свои, без системных
+	Transactions           []Transaction           `json:"transactions"`
+	PlannedTransactions    []PlannedTransaction    `json:"planned_transactions"`
+	Budgets                []Budget                `json:"budgets"`
+	Goals                  []Goal                  `json:"goals"`
+	Payees                 []Payee                 `json:"payees"`
+	Portfolios             []Portfolio             `json:"portfolios"` // вместе с позициями
+	InvestmentTransactions []InvestmentTransaction `json:"investment_transactions"`
+	CustomAssets           []CustomAsset           `json:"custom_assets"`
+	Deposits               []Deposit               `json:"deposits"`
+}
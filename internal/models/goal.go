@@ -37,10 +37,15 @@ type Goal struct {
 	UpdatedAt        time.Time       `json:"updated_at" db:"updated_at"`
 	CompletedAt      *time.Time      `json:"completed_at" db:"completed_at"`
 
+	// цель по портфелю: current_amount = рыночная стоимость портфеля (обновляется фоновой задачей)
+	PortfolioID    *uuid.UUID       `json:"portfolio_id" db:"portfolio_id"`
+	ExpectedReturn *decimal.Decimal `json:"expected_return" db:"expected_return"` // ожидаемая годовая доходность в %
+
 	// Вычисляются на лету
 	Progress        float64         `json:"progress" db:"-"`
 	DaysRemaining   int             `json:"days_remaining" db:"-"`
 	RequiredMonthly decimal.Decimal `json:"required_monthly" db:"-"`
+	ProjectedAmount decimal.Decimal `json:"projected_amount" db:"-"` // ожидаемая сумма к целевой дате с учетом доходности и автовзносов
 	Account         *Account        `json:"account,omitempty"`
 }
 
@@ -58,6 +63,9 @@ type GoalCreate struct {
 	AutoContribute   bool            `json:"auto_contribute"`
 	ContributeAmount decimal.Decimal `json:"contribute_amount"`
 	ContributeFreq   string          `json:"contribute_freq"`
+
+	PortfolioID    *uuid.UUID       `json:"portfolio_id"`
+	ExpectedReturn *decimal.Decimal `json:"expected_return"`
 }
 
 type GoalUpdate struct {
@@ -74,6 +82,9 @@ type GoalUpdate struct {
 	AutoContribute   *bool            `json:"auto_contribute"`
 	ContributeAmount *decimal.Decimal `json:"contribute_amount"`
 	ContributeFreq   *string          `json:"contribute_freq"`
+	PortfolioID      *uuid.UUID       `json:"portfolio_id"`
+	UnlinkPortfolio  bool             `json:"unlink_portfolio"` // отвязать цель от портфеля
+	ExpectedReturn   *decimal.Decimal `json:"expected_return"`
 }
 
 type GoalContribution struct {
@@ -90,3 +101,33 @@ type GoalContributionCreate struct {
 	Date   time.Time       `json:"date"`
 	Notes  string          `json:"notes"`
 }
+
+// GoalProjectionRequest параметры моделирования цели по портфелю
+type GoalProjectionRequest struct {
+	Contributions []decimal.Decimal // сценарии ежемесячного взноса; пусто - без взносов, текущий автовзнос и необходимый
+	Simulations   int               // число траекторий, по умолчанию 2000
+}
+
+// GoalProjection результат моделирования Монте-Карло для цели по портфелю
+type GoalProjection struct {
+	GoalID         uuid.UUID                `json:"goal_id"`
+	Currency       string                   `json:"currency"`
+	CurrentAmount  decimal.Decimal          `json:"current_amount"`
+	TargetAmount   decimal.Decimal          `json:"target_amount"`
+	TargetDate     time.Time                `json:"target_date"`
+	Months         int                      `json:"months"`
+	Simulations    int                      `json:"simulations"`
+	ExpectedReturn decimal.Decimal          `json:"expected_return"` // историческая годовая доходность бумаг портфеля в %
+	Volatility     decimal.Decimal          `json:"volatility"`      // историческая годовая волатильность в %
+	HistoryDays    int                      `json:"history_days"`    // сколько торговых дней истории использовано
+	Scenarios      []GoalProjectionScenario `json:"scenarios"`
+}
+
+// GoalProjectionScenario исход одного сценария взносов
+type GoalProjectionScenario struct {
+	MonthlyContribution decimal.Decimal `json:"monthly_contribution"`
+	Probability         float64         `json:"probability"` // доля траекторий, достигших цели к сроку, в %
+	P10                 decimal.Decimal `json:"p10"`         // пессимистичный исход: 90% траекторий не хуже
+	P50                 decimal.Decimal `json:"p50"`         // медиана
+	P90                 decimal.Decimal `json:"p90"`         // оптимистичный исход
+}
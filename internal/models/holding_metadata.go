@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// HoldingMetadata заметки пользователя к позиции. Привязаны к бумаге в портфеле, а не к строке holdings,
+// поэтому переживают полную продажу и повторную покупку
+type HoldingMetadata struct {
+	PortfolioID uuid.UUID        `json:"portfolio_id" db:"portfolio_id"`
+	SecurityID  uuid.UUID        `json:"security_id" db:"security_id"`
+	Notes       string           `json:"notes" db:"notes"`
+	TargetPrice *decimal.Decimal `json:"target_price" db:"target_price"` // в валюте бумаги
+	Tags        []string         `json:"tags" db:"tags"`
+	UpdatedAt   time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// HoldingMetadataInput полностью заменяет заметки к позиции
+type HoldingMetadataInput struct {
+	Notes       string           `json:"notes"`
+	TargetPrice *decimal.Decimal `json:"target_price"`
+	Tags        []string         `json:"tags"`
+}
+
+// HoldingFilter фильтр списка позиций по заметкам пользователя
+type HoldingFilter struct {
+	Tags           []string // позиция должна иметь все теги
+	Search         string   // подстрока в заметке
+	HasTargetPrice bool
+	Page           int // пагинация: номер страницы с 1
+	Limit          int
+}
+
+// Match подходит ли позиция под фильтр; теги и текст сравниваются без учета регистра
+func (f *HoldingFilter) Match(h *Holding) bool {
+	if f == nil {
+		return true
+	}
+	m := h.Metadata
+	if m == nil {
+		return len(f.Tags) == 0 && f.Search == "" && !f.HasTargetPrice
+	}
+	if f.HasTargetPrice && m.TargetPrice == nil {
+		return false
+	}
+	if f.Search != "" && !strings.Contains(strings.ToLower(m.Notes), strings.ToLower(f.Search)) {
+		return false
+	}
+	for _, want := range f.Tags {
+		found := false
+		for _, tag := range m.Tags {
+			if strings.EqualFold(tag, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
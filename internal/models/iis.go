@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// IISType тип вычета индивидуального инвестиционного счета
+type IISType string
+
+const (
+	IISTypeA IISType = "A" // вычет на взносы: 13% от внесенного за год
+	IISTypeB IISType = "B" // освобождение от НДФЛ прибыли от операций при закрытии
+)
+
+type IISCashFlowType string
+
+const (
+	IISContribution IISCashFlowType = "contribution" // пополнение счета
+	IISWithdrawal   IISCashFlowType = "withdrawal"   // вывод денег (до 3 лет закрывает ИИС)
+)
+
+// IISCashFlow пополнение или вывод денег с ИИС, суммы в рублях
+type IISCashFlow struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	PortfolioID uuid.UUID       `json:"portfolio_id" db:"portfolio_id"`
+	Type        IISCashFlowType `json:"type" db:"type"`
+	Amount      decimal.Decimal `json:"amount" db:"amount"`
+	Date        time.Time       `json:"date" db:"date"`
+	Notes       string          `json:"notes" db:"notes"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+
+	Warning string `json:"warning,omitempty" db:"-"` // последствия досрочного вывода
+}
+
+type IISCashFlowCreate struct {
+	Type   IISCashFlowType `json:"type" binding:"required,oneof=contribution withdrawal"`
+	Amount decimal.Decimal `json:"amount" binding:"required"`
+	Date   *time.Time      `json:"date"` // по умолчанию сегодня
+	Notes  string          `json:"notes"`
+	// вывод раньше 3 лет со дня открытия записывается только с подтверждением
+	Confirm bool `json:"confirm"`
+}
+
+// IISSummary взносы за год относительно лимита и ожидаемый вычет
+type IISSummary struct {
+	PortfolioID       uuid.UUID       `json:"portfolio_id"`
+	Type              IISType         `json:"type"`
+	OpenedAt          time.Time       `json:"opened_at"`
+	LockedUntil       time.Time       `json:"locked_until"` // до этой даты вывод денег закрывает ИИС
+	CanWithdraw       bool            `json:"can_withdraw"` // 3 года прошли, вывод не лишает льгот
+	Year              int             `json:"year"`
+	Contributed       decimal.Decimal `json:"contributed"`        // внесено за год
+	AnnualLimit       decimal.Decimal `json:"annual_limit"`       // 1 000 000 ₽ в год
+	RemainingLimit    decimal.Decimal `json:"remaining_limit"`    // сколько еще можно внести в этом году
+	ExpectedDeduction decimal.Decimal `json:"expected_deduction"` // тип А: 13% от взносов, не больше 400 000 ₽ в год
+	TotalContributed  decimal.Decimal `json:"total_contributed"`  // за все время
+	TotalWithdrawn    decimal.Decimal `json:"total_withdrawn"`
+	CashFlows         []IISCashFlow   `json:"cash_flows"` // операции за год
+}
+
+// IISTaxInfo раздел налогового отчета по ИИС
+type IISTaxInfo struct {
+	Type              IISType         `json:"type"`
+	LockedUntil       time.Time       `json:"locked_until"`
+	Contributions     decimal.Decimal `json:"contributions"`      // взносы за год
+	DeductionBase     decimal.Decimal `json:"deduction_base"`     // тип А: взносы в пределах 400 000 ₽
+	ExpectedDeduction decimal.Decimal `json:"expected_deduction"` // тип А: 13% от DeductionBase
+	ExemptGain        decimal.Decimal `json:"exempt_gain"`        // тип Б: прибыль от продаж, которая не облагается при закрытии после LockedUntil
+	EarlyWithdrawal   bool            `json:"early_withdrawal"`   // был вывод до LockedUntil - льготы ИИС потеряны
+}
@@ -14,13 +14,44 @@ const (
 	//российские
 	ExchangeMOEX   Exchange = "MOEX"
 	ExchangeCRYPTO Exchange = "CRYPTO"
+
+	// иностранные
+	ExchangeNYSE   Exchange = "NYSE"
+	ExchangeNASDAQ Exchange = "NASDAQ"
+	ExchangeLSE    Exchange = "LSE"  // Лондонская биржа
+	ExchangeFRA    Exchange = "FRA"  // Франкфуртская биржа
+	ExchangeHKEX   Exchange = "HKEX" // Гонконгская биржа
+
+	// бумаги, заведенные пользователем: котировок нет, цены вводятся вручную
+	ExchangeManual Exchange = "MANUAL"
 )
 
+// QuoteCurrency валюта котировок биржи по умолчанию, если у бумаги валюта не указана
+func (e Exchange) QuoteCurrency() string {
+	switch e {
+	case ExchangeMOEX:
+		return "RUB"
+	case ExchangeCRYPTO:
+		return "USD" // CoinGecko отдает цены в долларах
+	case ExchangeNYSE, ExchangeNASDAQ:
+		return "USD"
+	case ExchangeLSE:
+		return "GBP"
+	case ExchangeFRA:
+		return "EUR"
+	case ExchangeHKEX:
+		return "HKD"
+	}
+	return ""
+}
+
 // типы ценных бумаг
 type SecurityType string
 
 const (
 	SecurityTypeStock      SecurityType = "stock"
+	SecurityTypePreferred  SecurityType = "preferred_stock"    // привилегированные акции
+	SecurityTypeDR         SecurityType = "depositary_receipt" // депозитарные расписки (ADR, GDR, РДР)
 	SecurityTypeBond       SecurityType = "bond"
 	SecurityTypeETF        SecurityType = "etf"
 	SecurityTypeMutualFund SecurityType = "mutual_fund" //пифы
@@ -29,6 +60,13 @@ const (
 	SecurityTypeDerivative SecurityType = "derivative" //производные бумаги(фьючерсы, опционы)
 )
 
+type OptionType string
+
+const (
+	OptionTypeCall OptionType = "call"
+	OptionTypePut  OptionType = "put"
+)
+
 type Security struct {
 	ID                uuid.UUID       `json:"id" db:"id"`
 	Ticker            string          `json:"ticker" db:"ticker"` // биржевой тикер(уникален в рамках биржи): "GAZP" (Газпром), "SBER" (Сбербанк)
@@ -51,13 +89,181 @@ type Security struct {
 	CouponFreq   *int             `json:"coupon_freq" db:"coupon_freq"`     //частота выплата купонов в год
 	// для etf
 	ExpenseRatio *decimal.Decimal `json:"expense_ration" db:"expense_ration"` //комиссия фонда в %
+	// для деривативов (фьючерсы и опционы срочного рынка MOEX)
+	Underlying         string           `json:"underlying,omitempty" db:"underlying"`         // базовый актив: код (Si, BR) или тикер (SBER)
+	ExpiryDate         *time.Time       `json:"expiry_date" db:"expiry_date"`                 // последний день торгов, после него позиция закрывается по расчетной цене
+	Strike             *decimal.Decimal `json:"strike" db:"strike"`                           // цена исполнения опциона
+	OptionType         OptionType       `json:"option_type,omitempty" db:"option_type"`       // call / put
+	ContractMultiplier *decimal.Decimal `json:"contract_multiplier" db:"contract_multiplier"` // стоимость одного пункта цены в валюте (шаг цены / стоимость шага)
+	InitialMargin      *decimal.Decimal `json:"initial_margin" db:"initial_margin"`           // гарантийное обеспечение на один контракт
 	//Рыночные данные
 	LastPrice          decimal.Decimal `json:"last_price" db:"last_price"`                     //последняя цена сделки
 	PriceChange        decimal.Decimal `json:"price_change" db:"price_change"`                 //изменение цены с пред закрытия
 	PriceChangePercent decimal.Decimal `json:"price_change_percent" db:"price_change_percent"` // изменение в %
 	Volume             int64           `json:"volume" db:"volume"`
+	AccruedInterest    decimal.Decimal `json:"accrued_interest" db:"accrued_interest"` // НКД на одну облигацию, в цену не входит
 	CreatedAt          time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt          time.Time       `json:"updated_at" db:"updated_at"`
+
+	// владелец бумаги, заведенной вручную (биржа MANUAL); у биржевых бумаг nil
+	OwnerID *uuid.UUID `json:"owner_id,omitempty" db:"owner_id"`
+
+	// мультипликаторы и дивиденды; заполняются в карточке бумаги и скринере
+	Fundamentals *SecurityFundamentals `json:"fundamentals,omitempty" db:"-"`
+}
+
+// SecurityFundamentals мультипликаторы и дивиденды бумаги от провайдера; nil - провайдер поле не отдает
+type SecurityFundamentals struct {
+	MarketCap        *decimal.Decimal `json:"market_cap"` // в валюте котировки
+	PERatio          *decimal.Decimal `json:"pe_ratio"`
+	EPS              *decimal.Decimal `json:"eps"`
+	DividendPerShare *decimal.Decimal `json:"dividend_per_share"` // сумма дивидендов за последние 12 месяцев
+	DividendYield    *decimal.Decimal `json:"dividend_yield"`     // % к текущей цене
+	UpdatedAt        time.Time        `json:"updated_at"`
+	Dividends        []Dividend       `json:"dividends,omitempty"` // история выплат на акцию, от новых к старым
+}
+
+// IsManual бумага заведена пользователем и не котируется у провайдеров
+func (s *Security) IsManual() bool {
+	return s.Exchange == ExchangeManual
+}
+
+// ManualSecurityCreate бумага без биржевых котировок: доля в непубличной компании, опционы работодателя
+type ManualSecurityCreate struct {
+	Ticker   string           `json:"ticker" binding:"required,max=20"`
+	Name     string           `json:"name" binding:"required,max=200"`
+	Type     SecurityType     `json:"type" binding:"omitempty,oneof=stock preferred_stock depositary_receipt bond etf mutual_fund crypto currency derivative"`
+	Currency string           `json:"currency" binding:"required,len=3"`
+	Sector   string           `json:"sector" binding:"max=100"`
+	Price    *decimal.Decimal `json:"price"` // начальная цена на сегодня
+}
+
+// ManualPriceCreate цена бумаги, заведенной вручную, на дату
+type ManualPriceCreate struct {
+	Date  time.Time       `json:"date" binding:"required"`
+	Price decimal.Decimal `json:"price" binding:"required"`
+}
+
+// ContractSize во сколько раз стоимость позиции больше цены: для деривативов - стоимость пункта, для остальных 1
+func (s *Security) ContractSize() decimal.Decimal {
+	if s.ContractMultiplier != nil && s.ContractMultiplier.IsPositive() {
+		return *s.ContractMultiplier
+	}
+	return decimal.NewFromInt(1)
+}
+
+// IsExpired дериватив прошел дату экспирации
+func (s *Security) IsExpired(now time.Time) bool {
+	return s.Type == SecurityTypeDerivative && s.ExpiryDate != nil && s.ExpiryDate.Before(now)
+}
+
+// SecurityDuplicate бумага из группы подозрительных дублей: reason - isin (общий ISIN)
+// или ticker (тикер в одной валюте на разных биржах), key - значение, по которому они совпали
+type SecurityDuplicate struct {
+	Reason       string       `json:"-"`
+	Key          string       `json:"-"`
+	ID           uuid.UUID    `json:"id"`
+	Ticker       string       `json:"ticker"`
+	ISIN         string       `json:"isin"`
+	Name         string       `json:"name"`
+	Type         SecurityType `json:"type"`
+	Exchange     Exchange     `json:"exchange"`
+	Currency     string       `json:"currency"`
+	IsActive     bool         `json:"is_active"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	Holdings     int64        `json:"holdings"`     // позиций в портфелях
+	Transactions int64        `json:"transactions"` // операций по бумаге
+}
+
+type SecurityDuplicateGroup struct {
+	Reason     string              `json:"reason"`
+	Key        string              `json:"key"`
+	Securities []SecurityDuplicate `json:"securities"`
+	// предлагаемая основная бумага: с наибольшим числом операций и позиций, при равенстве - активная и раньше заведенная
+	SuggestedTargetID uuid.UUID `json:"suggested_target_id"`
+}
+
+type SecurityMergeRequest struct {
+	SourceID uuid.UUID `json:"source_id" binding:"required"` // удаляется после переноса
+	TargetID uuid.UUID `json:"target_id" binding:"required"`
+}
+
+type SecurityMergeResult struct {
+	SourceID     uuid.UUID `json:"source_id"`
+	TargetID     uuid.UUID `json:"target_id"`
+	Holdings     int64     `json:"holdings"` // перенесенных или сложенных позиций
+	Transactions int64     `json:"transactions"`
+}
+
+// SecurityScreenerFilter условия подбора бумаг среди уже синхронизированных с биржей
+type SecurityScreenerFilter struct {
+	Type         *SecurityType
+	Exchange     *Exchange
+	Sector       string
+	Currency     string
+	MinPrice     *decimal.Decimal
+	MaxPrice     *decimal.Decimal
+	MinYield     *decimal.Decimal // доходность в % годовых; бумаги без доходности при этом фильтре не попадают
+	MaxYield     *decimal.Decimal
+	MaturityFrom *time.Time // только облигации
+	MaturityTo   *time.Time
+	MinPE        *decimal.Decimal // бумаги без P/E при фильтрах по мультипликаторам не попадают
+	MaxPE        *decimal.Decimal
+	MinMarketCap *decimal.Decimal
+	MaxMarketCap *decimal.Decimal
+	SortBy       string // ticker, price, yield, change, volume, maturity, pe, market_cap
+	SortOrder    string
+	Limit        int
+	Offset       int
+	Hidden       *SecurityPreferences // скрытые пользователем бумаги в выдачу не попадают
+}
+
+// SecurityPreferences бумаги, которые пользователь скрыл из поиска и скринера:
+// отдельные тикеры (на любой бирже), целые типы (например, derivative) и биржи
+type SecurityPreferences struct {
+	HiddenTickers   []string       `json:"hidden_tickers" binding:"max=500,dive,min=1,max=20"`
+	HiddenTypes     []SecurityType `json:"hidden_types" binding:"dive,oneof=stock preferred_stock depositary_receipt bond etf mutual_fund crypto currency derivative"`
+	HiddenExchanges []Exchange     `json:"hidden_exchanges" binding:"dive,oneof=MOEX CRYPTO NYSE NASDAQ LSE FRA HKEX"`
+	UpdatedAt       *time.Time     `json:"updated_at,omitempty"`
+}
+
+// Hides скрыта ли бумага
+func (p *SecurityPreferences) Hides(s *Security) bool {
+	if p == nil {
+		return false
+	}
+	for _, t := range p.HiddenTickers {
+		if t == s.Ticker {
+			return true
+		}
+	}
+	for _, t := range p.HiddenTypes {
+		if t == s.Type {
+			return true
+		}
+	}
+	for _, e := range p.HiddenExchanges {
+		if e == s.Exchange {
+			return true
+		}
+	}
+	return false
+}
+
+// ScreenerSecurity бумага в выдаче скринера
+type ScreenerSecurity struct {
+	Security
+	// текущая доходность в % годовых: для облигаций купон к цене, для акций и фондов -
+	// дивиденды за 12 месяцев к цене. nil - данных нет
+	Yield *decimal.Decimal `json:"yield"`
+}
+
+// SecurityScreenerResult страница выдачи скринера
+type SecurityScreenerResult struct {
+	Securities []ScreenerSecurity `json:"securities"`
+	Total      int64              `json:"total"`
+	Limit      int                `json:"limit"`
+	Offset     int                `json:"offset"`
 }
 
 // Portfolio представляет инвестиционный портфель пользователя
@@ -72,6 +278,8 @@ type Portfolio struct {
 	BrokerName    string     `json:"broker_name" db:"broker_name"`       //брокер
 	BrokerAccount string     `json:"broker_account" db:"broker_account"` //счет у брокера
 	IsActive      bool       `json:"is_active" db:"is_active"`
+	IISType       *IISType   `json:"iis_type,omitempty" db:"iis_type"`           // nil - обычный брокерский счет
+	IISOpenedAt   *time.Time `json:"iis_opened_at,omitempty" db:"iis_opened_at"` // от нее отсчитываются 3 года
 	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
 	//вычисляются на лету
@@ -82,6 +290,31 @@ type Portfolio struct {
 	Holdings      []Holding       `json:"holdings,omitempty" db:"-"` //позиции портфеля(заполняется при join)
 }
 
+// PortfolioSummary сводка по портфелю для виджета на главной: без позиций, суммы в валюте портфеля
+type PortfolioSummary struct {
+	PortfolioID      uuid.UUID       `json:"portfolio_id"`
+	Name             string          `json:"name"`
+	Currency         string          `json:"currency"`
+	IsActive         bool            `json:"is_active"`
+	HoldingsCount    int             `json:"holdings_count"`
+	TotalValue       decimal.Decimal `json:"total_value"`
+	TotalInvested    decimal.Decimal `json:"total_invested"`
+	TotalProfit      decimal.Decimal `json:"total_profit"`
+	ProfitPercent    decimal.Decimal `json:"profit_percent"`
+	DayChange        decimal.Decimal `json:"day_change"`         // изменение стоимости с пред закрытия
+	DayChangePercent decimal.Decimal `json:"day_change_percent"` // от стоимости на пред закрытии
+	TopMover         *PortfolioMover `json:"top_mover"`          // nil - позиций нет или цены не менялись
+}
+
+// PortfolioMover бумага портфеля с наибольшим за день изменением цены (по модулю, в %)
+type PortfolioMover struct {
+	SecurityID    uuid.UUID       `json:"security_id"`
+	Ticker        string          `json:"ticker"`
+	Name          string          `json:"name"`
+	ChangePercent decimal.Decimal `json:"change_percent"`
+	DayChange     decimal.Decimal `json:"day_change"` // изменение стоимости позиции в валюте портфеля
+}
+
 type PortfolioCreate struct {
 	AccountID     *uuid.UUID `json:"account_id"`
 	Name          string     `json:"name" binding:"required"`
@@ -89,14 +322,75 @@ type PortfolioCreate struct {
 	Currency      string     `json:"currency" binding:"required"` //обязательное(для конвертаации)
 	BrokerName    string     `json:"broker_name"`
 	BrokerAccount string     `json:"broker_account"`
+	IISType       *IISType   `json:"iis_type" binding:"omitempty,oneof=A B"`
+	IISOpenedAt   *time.Time `json:"iis_opened_at"` // по умолчанию сегодня
 }
 
 type PortfolioUpdate struct {
-	Name          *string `json:"name"`
-	Description   *string `json:"description"`
-	BrokerName    *string `json:"broker_name"`
-	BrokerAccount *string `json:"broker_account"`
-	IsActive      *bool   `json:"is_active"`
+	Name          *string    `json:"name"`
+	Description   *string    `json:"description"`
+	BrokerName    *string    `json:"broker_name"`
+	BrokerAccount *string    `json:"broker_account"`
+	IsActive      *bool      `json:"is_active"`
+	IISType       *IISType   `json:"iis_type" binding:"omitempty,oneof=A B"`
+	IISOpenedAt   *time.Time `json:"iis_opened_at"`
+}
+
+// PortfolioRefreshRequest обновление цен портфеля: все позиции или только указанные тикеры.
+// Async nil - в фоне, только если позиций много
+type PortfolioRefreshRequest struct {
+	Tickers []string `json:"tickers" binding:"omitempty,max=200,dive,required,max=20"`
+	Async   *bool    `json:"async"`
+}
+
+type PriceRefreshStatus string
+
+const (
+	PriceRefreshUpdated PriceRefreshStatus = "updated" // котировка получена, цена обновлена
+	PriceRefreshStale   PriceRefreshStatus = "stale"   // котировки нет, в портфеле остается прежняя цена
+	PriceRefreshFailed  PriceRefreshStatus = "failed"  // котировки нет и прежней цены тоже
+	PriceRefreshSkipped PriceRefreshStatus = "skipped" // бумага заведена вручную, у провайдеров ее нет
+)
+
+// PriceRefreshResult итог обновления цены одной бумаги
+type PriceRefreshResult struct {
+	SecurityID     uuid.UUID          `json:"security_id"`
+	Ticker         string             `json:"ticker"`
+	Exchange       Exchange           `json:"exchange"`
+	Status         PriceRefreshStatus `json:"status"`
+	Price          decimal.Decimal    `json:"price"`
+	PriceUpdatedAt *time.Time         `json:"price_updated_at,omitempty"` // когда получена прежняя цена (для stale)
+	Error          string             `json:"error,omitempty"`
+}
+
+// PortfolioRefreshReport итог обновления цен портфеля по бумагам
+type PortfolioRefreshReport struct {
+	PortfolioID uuid.UUID            `json:"portfolio_id"`
+	Results     []PriceRefreshResult `json:"results"`
+	Updated     int                  `json:"updated"`
+	Stale       int                  `json:"stale"`
+	Failed      int                  `json:"failed"`
+	Skipped     int                  `json:"skipped"`
+	RefreshedAt time.Time            `json:"refreshed_at"`
+	Portfolio   *Portfolio           `json:"portfolio,omitempty"` // портфель с позициями по новым ценам
+}
+
+type RefreshJobStatus string
+
+const (
+	RefreshJobPending RefreshJobStatus = "pending"
+	RefreshJobRunning RefreshJobStatus = "running"
+	RefreshJobDone    RefreshJobStatus = "done"
+)
+
+// PortfolioRefreshJob фоновое обновление цен большого портфеля
+type PortfolioRefreshJob struct {
+	ID          uuid.UUID               `json:"id"`
+	PortfolioID uuid.UUID               `json:"portfolio_id"`
+	Status      RefreshJobStatus        `json:"status"`
+	Report      *PortfolioRefreshReport `json:"report,omitempty"`
+	CreatedAt   time.Time               `json:"created_at"`
+	FinishedAt  *time.Time              `json:"finished_at,omitempty"`
 }
 
 // представляет позицию в портфеле
@@ -112,17 +406,57 @@ type Holding struct {
 
 	// Вычисляемые поля
 	CurrentPrice  decimal.Decimal `json:"current_price" db:"-"`  // подгружается из Security.LastPrice
-	CurrentValue  decimal.Decimal `json:"current_value" db:"-"`  // = Quantity × CurrentPrice
+	CurrentValue  decimal.Decimal `json:"current_value" db:"-"`  // = Quantity × CurrentPrice + AccruedInterest
 	Profit        decimal.Decimal `json:"profit" db:"-"`         // = CurrentValue - TotalCost
 	ProfitPercent decimal.Decimal `json:"profit_percent" db:"-"` // в % = (Profit / TotalCost) × 100
-	Weight        decimal.Decimal `json:"weight" db:"-"`         // доля в портфеле (CurrentValue / PortfolioTotalValue) × 100
+	Weight        decimal.Decimal `json:"weight" db:"-"`         // доля в портфеле (ConvertedValue / PortfolioTotalValue) × 100
 	Security      *Security       `json:"security,omitempty"`    // полные данные по каждой бумаге
+	// НКД по всей позиции (для облигаций): при продаже его доплачивает покупатель, поэтому он входит в стоимость
+	AccruedInterest decimal.Decimal `json:"accrued_interest" db:"-"`
+
+	// CurrentPrice, CurrentValue, TotalCost и Profit - в валюте бумаги; ниже они же в валюте портфеля
+	Currency          string          `json:"currency" db:"-"`           // валюта бумаги
+	PortfolioCurrency string          `json:"portfolio_currency" db:"-"` // валюта портфеля
+	ExchangeRate      decimal.Decimal `json:"exchange_rate" db:"-"`      // курс валюты бумаги к валюте портфеля
+	ConvertedValue    decimal.Decimal `json:"converted_value" db:"-"`
+	ConvertedCost     decimal.Decimal `json:"converted_cost" db:"-"`
+	ConvertedProfit   decimal.Decimal `json:"converted_profit" db:"-"`
+
+	Metadata *HoldingMetadata `json:"metadata,omitempty" db:"-"` // заметки, целевая цена и теги пользователя
+	Aging    *HoldingAging    `json:"aging,omitempty" db:"-"`    // срок владения по лотам покупок
+}
+
+// HoldingAging срок владения позицией, посчитанный по лотам покупок (FIFO).
+// ЛДВ - льгота долгосрочного владения: бумаги, которыми владеют больше 3 лет, продаются без НДФЛ с прибыли
+type HoldingAging struct {
+	AveragePurchaseDate  time.Time       `json:"average_purchase_date"` // взвешенная по количеству дата покупки
+	AverageHoldingDays   int             `json:"average_holding_days"`
+	LDVApplicable        bool            `json:"ldv_applicable"`      // false - крипта, деривативы, валюта: ЛДВ не применяется
+	LongTermQuantity     decimal.Decimal `json:"long_term_quantity"`  // куплено больше 3 лет назад
+	LongTermShare        decimal.Decimal `json:"long_term_share"`     // доля позиции, которую можно продать без налога, %
+	NextLongTermDate     *time.Time      `json:"next_long_term_date"` // когда ближайший лот станет долгосрочным
+	NextLongTermQuantity decimal.Decimal `json:"next_long_term_quantity"`
+}
+
+// ValueCurrency валюта, в которой считается стоимость позиции: валюта бумаги,
+// иначе валюта котировок биржи, иначе fallback (обычно валюта портфеля)
+func (h *Holding) ValueCurrency(fallback string) string {
+	if h.Security != nil {
+		if h.Security.Currency != "" {
+			return h.Security.Currency
+		}
+		if c := h.Security.Exchange.QuoteCurrency(); c != "" {
+			return c
+		}
+	}
+	return fallback
 }
 
 func (h *Holding) CalculateValues() {
 	if h.Security != nil {
 		h.CurrentPrice = h.Security.LastPrice
-		h.CurrentValue = h.Quantity.Mul(h.CurrentPrice)
+		h.AccruedInterest = h.Quantity.Mul(h.Security.AccruedInterest)
+		h.CurrentValue = h.Quantity.Mul(h.CurrentPrice).Mul(h.Security.ContractSize()).Add(h.AccruedInterest)
 		h.Profit = h.CurrentValue.Sub(h.TotalCost)
 
 		if h.TotalCost.GreaterThan(decimal.Zero) {
@@ -144,8 +478,34 @@ const (
 	InvestmentTransactionTypeTransferOut InvestmentTransactionType = "transfer_out" // вывод бумаг на счет другого брокера
 	InvestmentTransactionTypeFee         InvestmentTransactionType = "fee"          // комиссия брокера/биржи
 	InvestmentTransactionTypeTax         InvestmentTransactionType = "tax"          // удержание налога (например, налог на дивиденды)
+	// крипто-доходы: увеличивают позицию, себестоимость = справедливая цена на дату получения (или 0)
+	InvestmentTransactionTypeStakingReward InvestmentTransactionType = "staking_reward" // награда за стейкинг
+	InvestmentTransactionTypeAirdrop       InvestmentTransactionType = "airdrop"        // раздача токенов
+	// закрытие позиции по деривативу в день экспирации по расчетной цене (как продажа)
+	InvestmentTransactionTypeExpiration InvestmentTransactionType = "expiration"
+	// возврат номинала облигации: price - сумма на одну бумагу
+	InvestmentTransactionTypeAmortization InvestmentTransactionType = "amortization" // частичное погашение, количество не меняется
+	InvestmentTransactionTypeRedemption   InvestmentTransactionType = "redemption"   // погашение выпуска, позиция закрывается (как продажа)
 )
 
+// DividendIncomeTypes доход от владения бумагой: дивиденды и крипто-награды. по нему считаются
+// дивидендная доходность портфеля и облагаемый доход в налоговом отчете
+var DividendIncomeTypes = []InvestmentTransactionType{
+	InvestmentTransactionTypeDividend,
+	InvestmentTransactionTypeStakingReward,
+	InvestmentTransactionTypeAirdrop,
+}
+
+// IsDividendIncome операция входит в DividendIncomeTypes
+func (t InvestmentTransactionType) IsDividendIncome() bool {
+	for _, income := range DividendIncomeTypes {
+		if t == income {
+			return true
+		}
+	}
+	return false
+}
+
 // представляет биржевую сделку
 type InvestmentTransaction struct {
 	ID           uuid.UUID                 `json:"id" db:"id"`
@@ -167,7 +527,7 @@ type InvestmentTransaction struct {
 
 type InvestmentTransactionCreate struct {
 	PortfolioID  uuid.UUID                 `json:"portfolio_id" binding:"required"`
-	SecurityID   uuid.UUID                 `json:"security_id" binding:"required"`
+	SecurityID   uuid.UUID                 `json:"security_id"` // либо security_id, либо ticker+exchange
 	Type         InvestmentTransactionType `json:"type" binding:"required"`
 	Date         time.Time                 `json:"date" binding:"required"`
 	Quantity     decimal.Decimal           `json:"quantity" binding:"required"`
@@ -176,6 +536,68 @@ type InvestmentTransactionCreate struct {
 	Currency     string                    `json:"currency"`
 	ExchangeRate decimal.Decimal           `json:"exchange_rate"`
 	Notes        string                    `json:"notes"`
+	BrokerRef    string                    `json:"broker_ref" binding:"max=100"` // номер сделки у брокера: повторный импорт ее пропустит
+
+	// внебиржевая сделка или брокер с дробными акциями: не проверять кратность лоту
+	AllowFractional bool `json:"allow_fractional"`
+	// сделка в валюте, отличной от валюты бумаги (например, расчеты в юанях по рублевой бумаге)
+	AllowCurrencyMismatch bool `json:"allow_currency_mismatch"`
+	// курс не указан, а валюта сделки отличается от валюты портфеля: взять курс биржи на дату сделки
+	FetchExchangeRate bool `json:"fetch_exchange_rate"`
+
+	// бумага по тикеру: если ее еще нет в бд, подтягивается у рыночного провайдера
+	Ticker   string   `json:"ticker"`
+	Exchange Exchange `json:"exchange"`
+}
+
+// InvestmentBatchImport пакет сделок в один портфель; portfolio_id в строках не нужен - берется из пути
+type InvestmentBatchImport struct {
+	Transactions []InvestmentTransactionCreate `json:"transactions" binding:"required,min=1"`
+	// проверить и показать результат без записи
+	DryRun bool `json:"dry_run"`
+}
+
+type InvestmentBatchRowStatus string
+
+const (
+	InvestmentBatchRowCreated   InvestmentBatchRowStatus = "created"
+	InvestmentBatchRowReady     InvestmentBatchRowStatus = "ready" // dry_run: строка прошла проверку
+	InvestmentBatchRowDuplicate InvestmentBatchRowStatus = "duplicate"
+	InvestmentBatchRowInvalid   InvestmentBatchRowStatus = "invalid"
+)
+
+// InvestmentBatchRow итог по одной строке пакета; row - номер строки с 1
+type InvestmentBatchRow struct {
+	Row         int                      `json:"row"`
+	Status      InvestmentBatchRowStatus `json:"status"`
+	Error       string                   `json:"error,omitempty"`
+	BrokerRef   string                   `json:"broker_ref,omitempty"`
+	Transaction *InvestmentTransaction   `json:"transaction,omitempty"`
+}
+
+// InvestmentBatchResult итог импорта. при ошибке хотя бы в одной строке не записывается ничего;
+// holdings - позиции по затронутым бумагам после пересчета (при dry_run - какими они стали бы)
+type InvestmentBatchResult struct {
+	DryRun     bool                 `json:"dry_run"`
+	Total      int                  `json:"total"`
+	Created    int                  `json:"created"`
+	Duplicates int                  `json:"duplicates"`
+	Invalid    int                  `json:"invalid"`
+	Rows       []InvestmentBatchRow `json:"rows"`
+	Holdings   []Holding            `json:"holdings"`
+}
+
+// фильтр операций портфеля
+type InvestmentTransactionFilter struct {
+	SecurityID *uuid.UUID
+	Type       *InvestmentTransactionType
+	DateFrom   *time.Time
+	DateTo     *time.Time
+	SortBy     string // date, amount, quantity, price, type
+	SortOrder  string
+	Limit      int
+	Offset     int
+	Page       int // номер страницы с 1; если задан, Offset не учитывается
 }
 
 // Dividend представляет информацию о дивидендной выплате по бумаге (из API, не хранится в БД)
@@ -193,10 +615,113 @@ type Dividend struct {
 	Security *Security `json:"security,omitempty"`
 }
 
+// Coupon купонная выплата по облигации из графика биржи (на одну бумагу, не хранится в БД)
+type Coupon struct {
+	SecurityID uuid.UUID        `json:"security_id"`
+	Date       time.Time        `json:"date"`        // дата выплаты
+	RecordDate *time.Time       `json:"record_date"` // дата фиксации
+	Amount     decimal.Decimal  `json:"amount"`      // сумма на одну облигацию, 0 если купон еще не объявлен (флоатеры)
+	Rate       *decimal.Decimal `json:"rate"`        // ставка купона, % годовых
+	Currency   string           `json:"currency"`
+}
+
+// Amortization погашение номинала облигации из графика биржи (на одну бумагу, не хранится в БД)
+type Amortization struct {
+	Date         time.Time        `json:"date"`
+	Amount       decimal.Decimal  `json:"amount"`     // возвращаемая часть номинала
+	Percent      *decimal.Decimal `json:"percent"`    // доля от первоначального номинала, %
+	FaceValue    *decimal.Decimal `json:"face_value"` // номинал до погашения
+	Currency     string           `json:"currency"`
+	IsRedemption bool             `json:"is_redemption"` // погашение в дату погашения выпуска, а не амортизация
+}
+
+// CouponPayment ожидаемая выплата купона по позиции в портфеле
+type CouponPayment struct {
+	SecurityID    uuid.UUID       `json:"security_id"`
+	Date          time.Time       `json:"date"`
+	AmountPerBond decimal.Decimal `json:"amount_per_bond"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	TotalAmount   decimal.Decimal `json:"total_amount"` // AmountPerBond × Quantity
+	Currency      string          `json:"currency"`
+	IsEstimated   bool            `json:"is_estimated"` // сумма/дата посчитаны по ставке купона, а не взяты из графика
+
+	Security *Security `json:"security,omitempty"`
+}
+
+type IncomeEventType string
+
+const (
+	IncomeEventDividend IncomeEventType = "dividend"
+	IncomeEventCoupon   IncomeEventType = "coupon"
+)
+
+// IncomeEvent событие в календаре выплат портфеля
+type IncomeEvent struct {
+	Type          IncomeEventType `json:"type"`
+	Date          time.Time       `json:"date"`
+	SecurityID    uuid.UUID       `json:"security_id"`
+	Ticker        string          `json:"ticker"`
+	AmountPerUnit decimal.Decimal `json:"amount_per_unit"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	TotalAmount   decimal.Decimal `json:"total_amount"`
+	Currency      string          `json:"currency"`
+	IsEstimated   bool            `json:"is_estimated"`
+}
+
+// IncomeCalendar предстоящие дивиденды и купоны портфеля
+type IncomeCalendar struct {
+	PortfolioID                uuid.UUID       `json:"portfolio_id"`
+	Currency                   string          `json:"currency"` // валюта итогов
+	Events                     []IncomeEvent   `json:"events"`
+	UpcomingTotal              decimal.Decimal `json:"upcoming_total"`                // сумма всех событий календаря
+	ProjectedAnnualFixedIncome decimal.Decimal `json:"projected_annual_fixed_income"` // купоны облигаций на ближайшие 12 месяцев
+}
+
+// PortfolioIncomeReport полученные и ожидаемые дивиденды и купоны портфеля, все суммы в валюте отчета
+type PortfolioIncomeReport struct {
+	PortfolioID     uuid.UUID       `json:"portfolio_id"`
+	Currency        string          `json:"currency"`
+	TotalReceived   decimal.Decimal `json:"total_received"`   // за все время
+	TotalPrincipal  decimal.Decimal `json:"total_principal"`  // возвращенный номинал облигаций за все время, доходом не считается
+	TTMIncome       decimal.Decimal `json:"ttm_income"`       // за последние 12 месяцев
+	ProjectedIncome decimal.Decimal `json:"projected_income"` // объявленные дивиденды и купоны на 12 месяцев вперед
+	TotalCost       decimal.Decimal `json:"total_cost"`       // вложения в текущие позиции
+	YieldOnCost     decimal.Decimal `json:"yield_on_cost"`    // TTMIncome / TotalCost, %
+	ForwardYield    decimal.Decimal `json:"forward_yield"`    // ProjectedIncome / TotalCost, %
+
+	ByMonth  []IncomePeriod  `json:"by_month"` // "2024-03"
+	ByYear   []IncomePeriod  `json:"by_year"`  // "2024"
+	Holdings []HoldingIncome `json:"holdings"`
+}
+
+// IncomePeriod полученный доход за месяц или год
+type IncomePeriod struct {
+	Period    string          `json:"period"`
+	Dividends decimal.Decimal `json:"dividends"`
+	Coupons   decimal.Decimal `json:"coupons"`
+	Total     decimal.Decimal `json:"total"`
+	Principal decimal.Decimal `json:"principal"` // возврат номинала облигаций (амортизация и погашение), в total не входит
+}
+
+// HoldingIncome доход по одной бумаге; проданные бумаги остаются в отчете с нулевыми вложениями
+type HoldingIncome struct {
+	SecurityID      uuid.UUID       `json:"security_id"`
+	Ticker          string          `json:"ticker"`
+	Type            SecurityType    `json:"type"`
+	Quantity        decimal.Decimal `json:"quantity"`
+	TotalCost       decimal.Decimal `json:"total_cost"`
+	TotalReceived   decimal.Decimal `json:"total_received"`
+	TTMIncome       decimal.Decimal `json:"ttm_income"`
+	ProjectedIncome decimal.Decimal `json:"projected_income"`
+	YieldOnCost     decimal.Decimal `json:"yield_on_cost"` // TTMIncome / TotalCost, %
+	ForwardYield    decimal.Decimal `json:"forward_yield"` // ProjectedIncome / TotalCost, %
+}
+
 // PortfolioAnalytics содержит аналитику по портфелю
 // рассчитывается на основе данных портфеля и рыночной информации. Это не аналитика личных финансов поэтому здесь оставил.
 type PortfolioAnalytics struct {
 	PortfolioID    uuid.UUID       `json:"portfolio_id"`
+	Currency       string          `json:"currency"`             // валюта, в которой посчитаны суммы
 	TotalReturn    decimal.Decimal `json:"total_return"`         // совокупная доходность за все время
 	TotalReturnPct decimal.Decimal `json:"total_return_percent"` // совокупная доходность в %
 	// доходности = (доходность за сегодня - доходность_n_период_назад)/ доходность_n_период_назад
@@ -220,9 +745,15 @@ type PortfolioAnalytics struct {
 	AllocationBySector   map[string]decimal.Decimal       `json:"allocation_by_sector"`   // распределение по секторам: 30% IT, 25% Финансы, 20% Энергетика
 	AllocationByCurrency map[string]decimal.Decimal       `json:"allocation_by_currency"` // валютная диверсификация: 70% RUB, 20% USD, 10% EUR
 
+	// --- Деривативы ---
+	NotionalExposure  decimal.Decimal            `json:"notional_exposure"`      // номинальная стоимость позиций по фьючерсам и опционам
+	NotionalByAsset   map[string]decimal.Decimal `json:"notional_by_underlying"` // номинал по базовым активам
+	MarginRequirement decimal.Decimal            `json:"margin_requirement"`     // гарантийное обеспечение по открытым контрактам
+	NotionalToValue   decimal.Decimal            `json:"notional_to_value"`      // номинал деривативов к стоимости портфеля, %
+
 	// --- Доходность ---
 	DividendYield decimal.Decimal `json:"dividend_yield"` // дивидендная доходность портфеля в %
-	//DividendYield = (Сумма всех дивидендов за год) / (Текущая стоимость портфеля) × 100%
+	//DividendYield = (Дивиденды и крипто-награды за прошлый год, models.DividendIncomeTypes) / (Текущая стоимость портфеля) × 100%
 	ExpectedDividends []Dividend `json:"expected_dividends"` // ожидаемые дивидендные выплаты
 
 	// --- История стоимости ---
@@ -237,6 +768,79 @@ type PortfolioValuePoint struct {
 	Value decimal.Decimal `json:"value"`
 }
 
+// BenchmarkComparison "что если бы я купил индекс": те же покупки и продажи портфеля, но в бенчмарк.
+// прибыль = стоимость + выведенные деньги - вложенные; у портфеля в выведенное входят дивиденды и купоны
+type BenchmarkComparison struct {
+	PortfolioID uuid.UUID `json:"portfolio_id"`
+	Benchmark   string    `json:"benchmark"`
+	Currency    string    `json:"currency"` // валюта портфеля, в ней все суммы
+	From        time.Time `json:"from"`     // дата первой сделки
+
+	Invested decimal.Decimal `json:"invested"` // всего вложено покупками
+
+	PortfolioValue     decimal.Decimal `json:"portfolio_value"`
+	PortfolioWithdrawn decimal.Decimal `json:"portfolio_withdrawn"` // продажи + дивиденды и купоны
+	PortfolioProfit    decimal.Decimal `json:"portfolio_profit"`
+	PortfolioReturnPct decimal.Decimal `json:"portfolio_return_percent"`
+
+	BenchmarkValue     decimal.Decimal `json:"benchmark_value"`
+	BenchmarkWithdrawn decimal.Decimal `json:"benchmark_withdrawn"` // продажи паев бенчмарка на суммы продаж портфеля
+	BenchmarkProfit    decimal.Decimal `json:"benchmark_profit"`
+	BenchmarkReturnPct decimal.Decimal `json:"benchmark_return_percent"`
+
+	// Outperformance portfolio_return_percent - benchmark_return_percent: >0 - портфель обыграл индекс
+	Outperformance decimal.Decimal `json:"outperformance"`
+
+	Points []BenchmarkPoint `json:"points"`
+}
+
+// BenchmarkPoint стоимость портфеля и гипотетического бенчмарка на дату (для периодов больше года - по неделям)
+type BenchmarkPoint struct {
+	Date           time.Time       `json:"date"`
+	Invested       decimal.Decimal `json:"invested"`
+	PortfolioValue decimal.Decimal `json:"portfolio_value"`
+	BenchmarkValue decimal.Decimal `json:"benchmark_value"`
+}
+
+// PerformanceAttribution из чего сложилась доходность портфеля за период. доходность считается по
+// Modified Dietz: прибыль делится на средний вложенный капитал, где деньги, внесенные посреди периода,
+// учитываются с весом оставшейся доли периода. вклад позиции = вес × доходность = ее прибыль / капитал
+// портфеля, поэтому вклады бумаг (и секторов) в сумме дают доходность портфеля
+type PerformanceAttribution struct {
+	PortfolioID uuid.UUID `json:"portfolio_id"`
+	Currency    string    `json:"currency"` // валюта портфеля, в ней все суммы
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+
+	StartValue decimal.Decimal `json:"start_value"` // по ценам закрытия перед from
+	EndValue   decimal.Decimal `json:"end_value"`   // по ценам закрытия на to
+	NetFlows   decimal.Decimal `json:"net_flows"`   // покупки минус продажи за период
+	Income     decimal.Decimal `json:"income"`      // дивиденды, купоны и амортизация за вычетом налогов и комиссий
+	Profit     decimal.Decimal `json:"profit"`
+	ReturnPct  decimal.Decimal `json:"return_percent"`
+
+	Securities []AttributionItem `json:"securities"` // по убыванию вклада
+	Sectors    []AttributionItem `json:"sectors"`
+}
+
+// AttributionItem вклад бумаги или сектора в доходность портфеля
+type AttributionItem struct {
+	SecurityID *uuid.UUID `json:"security_id,omitempty"`
+	Ticker     string     `json:"ticker,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	Sector     string     `json:"sector"` // "" - сектор неизвестен
+
+	StartValue decimal.Decimal `json:"start_value"`
+	EndValue   decimal.Decimal `json:"end_value"`
+	NetFlow    decimal.Decimal `json:"net_flow"`
+	Income     decimal.Decimal `json:"income"`
+	Profit     decimal.Decimal `json:"profit"`
+
+	WeightPct       decimal.Decimal `json:"weight_percent"`       // доля среднего капитала портфеля
+	ReturnPct       decimal.Decimal `json:"return_percent"`       // доходность позиции
+	ContributionPct decimal.Decimal `json:"contribution_percent"` // вклад в доходность портфеля, п.п.
+}
+
 // представляет налоговый отчет
 // важно для декларации 3-НДФЛ в России
 type TaxReport struct {
@@ -244,11 +848,17 @@ type TaxReport struct {
 	PortfolioID    uuid.UUID       `json:"portfolio_id"`
 	TotalDividends decimal.Decimal `json:"total_dividends"` // cумма всех полученных дивидендов
 	TotalCoupons   decimal.Decimal `json:"total_coupons"`   // cумма всех полученных купонов по облигациям
+	TotalStaking   decimal.Decimal `json:"total_staking"`   // награды за стейкинг по справедливой цене на дату получения
+	TotalAirdrops  decimal.Decimal `json:"total_airdrops"`  // полученные airdrop-ы по справедливой цене
 	RealizedGains  decimal.Decimal `json:"realized_gains"`  // реализованная прибыль (от продажи бумаг)
 	RealizedLosses decimal.Decimal `json:"realized_losses"` // реализованные убытки (от продажи бумаг)
 	NetGain        decimal.Decimal `json:"net_gain"`        // чистый финансовый результат = RealizedGains - RealizedLosses
 	TaxableAmount  decimal.Decimal `json:"taxable_amount"`  // налогооблагаемая сумма. В РФ: дивиденды + купоны + прибыль от продаж (TaxableAmount = TotalDividends + TotalCoupons + NetGain)
 	EstimatedTax   decimal.Decimal `json:"estimated_tax"`   // это уже рассчитанная сумма налога к уплате.
+	IIS            *IISTaxInfo     `json:"iis,omitempty"`   // только для портфелей-ИИС
+
+	// амортизация облигаций - возврат вложений, не доход: уменьшает себестоимость, результат считается при погашении
+	TotalAmortization decimal.Decimal `json:"total_amortization"`
 
 	//Доп детали
 	Transactions     []InvestmentTransaction `json:"transactions"`      // сделки за год (для проверки)
@@ -272,4 +882,59 @@ type MarketQuote struct {
 	Ask           decimal.Decimal `json:"ask"`            // лучшая цена продажи (сколько продавцы просят(мин))
 	// Spread = Ask - Bid (спред)
 	Timestamp time.Time `json:"timestamp"` // время получения котировки
+
+	// облигации MOEX: цены выше уже в валюте (биржа котирует в % от номинала), НКД - на одну бумагу
+	PricePercent    *decimal.Decimal `json:"price_percent,omitempty"` // цена в % от номинала, как на бирже
+	FaceValue       *decimal.Decimal `json:"face_value,omitempty"`
+	AccruedInterest decimal.Decimal  `json:"accrued_interest"`
+}
+
+// источник привязки тикера к сектору
+type SectorSource string
+
+const (
+	SectorSourceBundled  SectorSource = "bundled"  // встроенный справочник
+	SectorSourceProvider SectorSource = "provider" // ответ рыночного провайдера
+	SectorSourceManual   SectorSource = "manual"   // задано администратором, не перезаписывается автоматически
+)
+
+// SectorMapping привязка тикера к сектору/отрасли
+type SectorMapping struct {
+	Ticker    string       `json:"ticker" db:"ticker"`
+	Exchange  Exchange     `json:"exchange" db:"exchange"`
+	Sector    string       `json:"sector" db:"sector"`
+	Industry  string       `json:"industry" db:"industry"`
+	Source    SectorSource `json:"source" db:"source"`
+	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+type SectorMappingUpsert struct {
+	Ticker   string   `json:"ticker" binding:"required"`
+	Exchange Exchange `json:"exchange" binding:"required"`
+	Sector   string   `json:"sector" binding:"required"`
+	Industry string   `json:"industry"`
+}
+
+// SecurityTypeOverride тип бумаги, заданный администратором поверх данных провайдера
+type SecurityTypeOverride struct {
+	Ticker    string       `json:"ticker" db:"ticker"`
+	Exchange  Exchange     `json:"exchange" db:"exchange"`
+	Type      SecurityType `json:"type" db:"type"`
+	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// SecurityTypeOverrideUpsert тип и, если указан, сектор бумаги (сектор сохраняется ручной привязкой)
+type SecurityTypeOverrideUpsert struct {
+	Ticker   string       `json:"ticker" binding:"required,max=20"`
+	Exchange Exchange     `json:"exchange" binding:"required"`
+	Type     SecurityType `json:"type" binding:"required,oneof=stock preferred_stock depositary_receipt bond etf mutual_fund crypto currency derivative"`
+	Sector   string       `json:"sector" binding:"max=100"`
+	Industry string       `json:"industry" binding:"max=100"`
+}
+
+// результат прохода по бумагам без сектора
+type SectorBackfillResult struct {
+	Processed  int      `json:"processed"`
+	Updated    int      `json:"updated"`
+	Unresolved []string `json:"unresolved"` // тикеры, для которых сектор найти не удалось
 }
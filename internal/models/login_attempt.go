@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// LoginAttempt счетчик неудачных входов по email или адресу клиента
+type LoginAttempt struct {
+	Key          string     `json:"key" db:"key"` // "email:<адрес>" или "ip:<адрес>"
+	Failures     int        `json:"failures" db:"failures"`
+	LockedUntil  *time.Time `json:"locked_until" db:"locked_until"`
+	LastFailedAt time.Time  `json:"last_failed_at" db:"last_failed_at"`
+}
+
+// AccountUnlock снятие блокировки входа кодом из письма
+type AccountUnlock struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// MailConnection почтовый ящик, из которого читаются уведомления банков и брокеров
+type MailConnection struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	Host         string     `json:"host" db:"host"`
+	Port         int        `json:"port" db:"port"`
+	Username     string     `json:"username" db:"username"`
+	PasswordEnc  string     `json:"-" db:"password_enc"` // пароль (пароль приложения) в зашифрованном виде
+	Mailbox      string     `json:"mailbox" db:"mailbox"`
+	UseTLS       bool       `json:"use_tls" db:"use_tls"`
+	AccountID    *uuid.UUID `json:"account_id,omitempty" db:"account_id"` // счет для черновиков, если карта в письме не опознана
+	IsActive     bool       `json:"is_active" db:"is_active"`
+	UIDValidity  uint32     `json:"-" db:"uid_validity"` // состояние ящика: с какого письма продолжать
+	LastUID      uint32     `json:"-" db:"last_uid"`
+	LastPolledAt *time.Time `json:"last_polled_at,omitempty" db:"last_polled_at"`
+	LastError    string     `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type MailConnectionCreate struct {
+	Host      string     `json:"host" binding:"required"`
+	Port      int        `json:"port"` // по умолчанию 993
+	Username  string     `json:"username" binding:"required"`
+	Password  string     `json:"password" binding:"required"`
+	Mailbox   string     `json:"mailbox"` // по умолчанию INBOX
+	UseTLS    *bool      `json:"use_tls"` // по умолчанию true
+	AccountID *uuid.UUID `json:"account_id"`
+}
+
+type MailConnectionUpdate struct {
+	Password  *string    `json:"password"`
+	Mailbox   *string    `json:"mailbox"`
+	AccountID *uuid.UUID `json:"account_id"`
+	IsActive  *bool      `json:"is_active"`
+}
+
+type TransactionDraftStatus string
+
+const (
+	DraftStatusPending   TransactionDraftStatus = "pending"   // ждет проверки пользователем
+	DraftStatusConfirmed TransactionDraftStatus = "confirmed" // проведен как транзакция
+	DraftStatusRejected  TransactionDraftStatus = "rejected"  // отклонен
+)
+
+// TransactionDraft операция, распознанная в письме; транзакцией становится после подтверждения
+type TransactionDraft struct {
+	ID            uuid.UUID              `json:"id" db:"id"`
+	UserID        uuid.UUID              `json:"user_id" db:"user_id"`
+	ConnectionID  *uuid.UUID             `json:"connection_id,omitempty" db:"connection_id"`
+	ExternalID    string                 `json:"-" db:"external_id"` // Message-ID письма, чтобы не создавать черновик дважды
+	Parser        string                 `json:"parser" db:"parser"` // какой банк распознал письмо
+	AccountID     *uuid.UUID             `json:"account_id,omitempty" db:"account_id"`
+	Type          TransactionType        `json:"type" db:"type"`
+	Amount        decimal.Decimal        `json:"amount" db:"amount"`
+	Currency      string                 `json:"currency" db:"currency"`
+	Description   string                 `json:"description" db:"description"`
+	Date          time.Time              `json:"date" db:"date"`
+	CardLast4     string                 `json:"card_last4,omitempty" db:"card_last4"`
+	Subject       string                 `json:"subject" db:"subject"` // тема письма, чтобы пользователь понял, откуда черновик
+	Status        TransactionDraftStatus `json:"status" db:"status"`
+	TransactionID *uuid.UUID             `json:"transaction_id,omitempty" db:"transaction_id"`
+	CreatedAt     time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+// TransactionDraftConfirm категория обязательна; остальное уточняет распознанное
+type TransactionDraftConfirm struct {
+	CategoryID  uuid.UUID        `json:"category_id" binding:"required"`
+	AccountID   *uuid.UUID       `json:"account_id"`
+	Amount      *decimal.Decimal `json:"amount"`
+	Description *string          `json:"description"`
+	Date        *time.Time       `json:"date"`
+}
+
+// MailPollResult итог опроса почтовых ящиков
+type MailPollResult struct {
+	Connections int `json:"connections"`
+	Fetched     int `json:"fetched"` // писем прочитано
+	Drafts      int `json:"drafts"`  // черновиков создано
+	Skipped     int `json:"skipped"` // не уведомления банка или уже разобраны
+	Failed      int `json:"failed"`  // ящиков с ошибкой
+}
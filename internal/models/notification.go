@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotificationType string
+
+const (
+	NotificationBudgetAlert   NotificationType = "budget_alert"
+	NotificationGoalCompleted NotificationType = "goal_completed"
+	NotificationChallenge     NotificationType = "challenge" // период челленджа выполнен
+	NotificationImportResult  NotificationType = "import_result"
+	NotificationPriceAlert    NotificationType = "price_alert"
+	NotificationSecurity      NotificationType = "security" // блокировка входа и другие события безопасности
+	NotificationPlanned       NotificationType = "planned"  // запланированный платеж проведен автоматически или не провелся
+	NotificationAnomaly       NotificationType = "anomaly"  // подозрительная трата (если пользователь включил anomaly_alerts)
+)
+
+// Notification уведомление во входящих пользователя
+type Notification struct {
+	ID       uuid.UUID        `json:"id" db:"id"`
+	UserID   uuid.UUID        `json:"user_id" db:"user_id"`
+	Type     NotificationType `json:"type" db:"type"`
+	Title    string           `json:"title" db:"title"`
+	Body     string           `json:"body" db:"body"`
+	EntityID *uuid.UUID       `json:"entity_id,omitempty" db:"entity_id"` // бюджет, цель, портфель или почтовый ящик
+	// DedupKey одно событие - одно уведомление (например, превышение бюджета за период)
+	DedupKey  string     `json:"-" db:"dedup_key"`
+	ReadAt    *time.Time `json:"read_at" db:"read_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type NotificationFilter struct {
+	UnreadOnly bool
+	Type       *NotificationType
+	Limit      int
+	Offset     int
+}
+
+// NotificationList входящие с количеством непрочитанных (всего и по типам)
+type NotificationList struct {
+	Notifications []Notification           `json:"notifications"`
+	Unread        int                      `json:"unread"`
+	UnreadByType  map[NotificationType]int `json:"unread_by_type"`
+}
+
+// NotificationMarkRead отметить прочитанными перечисленные уведомления или все сразу
+type NotificationMarkRead struct {
+	IDs []uuid.UUID `json:"ids"`
+	All bool        `json:"all"`
+}
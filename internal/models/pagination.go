@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PageCursor позиция в списке, отсортированном по (date, id) от новых к старым
+type PageCursor struct {
+	Date time.Time
+	ID   uuid.UUID
+}
+
+// CursorPage страница по курсору (keyset): записи строго после After; nil - с начала списка
+type CursorPage struct {
+	After *PageCursor
+	Limit int
+}
+
+// TransactionPage страница транзакций для /api/v2; пустой NextCursor - страниц больше нет
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+	Limit        int           `json:"limit"`
+	Currency     string        `json:"currency,omitempty"` // валюта converted_amount, если запрошена
+}
+
+type InvestmentTransactionPage struct {
+	Transactions []InvestmentTransaction `json:"transactions"`
+	NextCursor   string                  `json:"next_cursor,omitempty"`
+	Limit        int                     `json:"limit"`
+}
+
+// InvestmentTransactionList страница операций портфеля по номеру, как TransactionList
+type InvestmentTransactionList struct {
+	Transactions []InvestmentTransaction `json:"transactions"`
+	Total        int64                   `json:"total"`
+	Page         int                     `json:"page"`
+	Limit        int                     `json:"limit"`
+	TotalPages   int                     `json:"total_pages"`
+}
+
+type DividendList struct {
+	Dividends  []Dividend `json:"dividends"`
+	Total      int64      `json:"total"`
+	Page       int        `json:"page"`
+	Limit      int        `json:"limit"`
+	TotalPages int        `json:"total_pages"`
+}
+
+type HoldingList struct {
+	Holdings   []Holding `json:"holdings"`
+	Total      int64     `json:"total"`
+	Page       int       `json:"page"`
+	Limit      int       `json:"limit"`
+	TotalPages int       `json:"total_pages"`
+}
+
+// TotalPages сколько страниц по limit записей
+func TotalPages(total int64, limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	pages := int(total) / limit
+	if int(total)%limit > 0 {
+		pages++
+	}
+	return pages
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Payee получатель/продавец, к которому привязываются транзакции
+type Payee struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	Name           string    `json:"name" db:"name"`
+	NormalizedName string    `json:"-" db:"normalized_name"` // ключ для сопоставления описаний транзакций
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	//вычисляемые
+	TransactionCount int64 `json:"transaction_count"`
+}
+
+type PayeeRename struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// PayeeMerge переносит транзакции получателей SourceIDs на получателя из URL и удаляет их
+type PayeeMerge struct {
+	SourceIDs []uuid.UUID `json:"source_ids" binding:"required,min=1"`
+}
+
+type PayeeStats struct {
+	PayeeID          uuid.UUID           `json:"payee_id"`
+	Name             string              `json:"name"`
+	TotalSpent       decimal.Decimal     `json:"total_spent"`
+	TransactionCount int64               `json:"transaction_count"`
+	AverageCheck     decimal.Decimal     `json:"average_check"`
+	FirstDate        *time.Time          `json:"first_date"`
+	LastDate         *time.Time          `json:"last_date"`
+	MonthlyTrend     []PayeeMonthlyStats `json:"monthly_trend"`
+}
+
+type PayeeMonthlyStats struct {
+	Month            string          `json:"month"` // YYYY-MM
+	Amount           decimal.Decimal `json:"amount"`
+	TransactionCount int64           `json:"transaction_count"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type PlannedTransactionStatus string
+
+const (
+	PlannedStatusPlanned   PlannedTransactionStatus = "planned"   // ждет даты/подтверждения
+	PlannedStatusPosted    PlannedTransactionStatus = "posted"    // проведена как обычная транзакция
+	PlannedStatusCancelled PlannedTransactionStatus = "cancelled" // отменена пользователем
+)
+
+// PlannedTransaction разовый запланированный платеж (аренда 5-го числа, счет к оплате).
+// в отличие от повторяющихся транзакций не влияет на баланс, пока не проведен
+type PlannedTransaction struct {
+	ID            uuid.UUID                `json:"id" db:"id"`
+	UserID        uuid.UUID                `json:"user_id" db:"user_id"`
+	AccountID     uuid.UUID                `json:"account_id" db:"account_id"`
+	CategoryID    uuid.UUID                `json:"category_id" db:"category_id"`
+	Type          TransactionType          `json:"type" db:"type"`
+	Amount        decimal.Decimal          `json:"amount" db:"amount"`
+	Currency      string                   `json:"currency" db:"currency"`
+	Description   string                   `json:"description" db:"description"`
+	DueDate       time.Time                `json:"due_date" db:"due_date"`
+	ToAccountID   *uuid.UUID               `json:"to_account_id,omitempty" db:"to_account_id"`
+	AutoPost      bool                     `json:"auto_post" db:"auto_post"` // провести автоматически в дату платежа
+	Status        PlannedTransactionStatus `json:"status" db:"status"`
+	TransactionID *uuid.UUID               `json:"transaction_id,omitempty" db:"transaction_id"` // созданная транзакция после проведения
+	Notes         string                   `json:"notes" db:"notes"`
+	CreatedAt     time.Time                `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time                `json:"updated_at" db:"updated_at"`
+	PostedAt      *time.Time               `json:"posted_at,omitempty" db:"posted_at"`
+
+	// вычисляется на лету
+	IsOverdue bool `json:"is_overdue" db:"-"`
+}
+
+type PlannedTransactionCreate struct {
+	AccountID   uuid.UUID       `json:"account_id" binding:"required"`
+	CategoryID  uuid.UUID       `json:"category_id" binding:"required"`
+	Type        TransactionType `json:"type" binding:"required"`
+	Amount      decimal.Decimal `json:"amount" binding:"required"`
+	Description string          `json:"description"`
+	DueDate     time.Time       `json:"due_date" binding:"required"`
+	ToAccountID *uuid.UUID      `json:"to_account_id"`
+	AutoPost    bool            `json:"auto_post"`
+	Notes       string          `json:"notes"`
+}
+
+type PlannedTransactionUpdate struct {
+	AccountID   *uuid.UUID       `json:"account_id"`
+	CategoryID  *uuid.UUID       `json:"category_id"`
+	Amount      *decimal.Decimal `json:"amount"`
+	Description *string          `json:"description"`
+	DueDate     *time.Time       `json:"due_date"`
+	ToAccountID *uuid.UUID       `json:"to_account_id"`
+	AutoPost    *bool            `json:"auto_post"`
+	Notes       *string          `json:"notes"`
+}
+
+// PlannedTransactionConfirm фактические сумма и дата при подтверждении (по умолчанию - плановые)
+type PlannedTransactionConfirm struct {
+	Amount *decimal.Decimal `json:"amount"`
+	Date   *time.Time       `json:"date"`
+}
+
+// PlannedPostResult итог фонового проведения запланированных платежей
+type PlannedPostResult struct {
+	Posted  int `json:"posted"`
+	Overdue int `json:"overdue"` // без автопроведения, ждут подтверждения
+	Failed  int `json:"failed"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PriceHistory дневная свеча бумаги, сохраненная из провайдера котировок
+type PriceHistory struct {
+	SecurityID uuid.UUID       `json:"security_id" db:"security_id"`
+	Date       time.Time       `json:"date" db:"date"`
+	Open       decimal.Decimal `json:"open" db:"open"`
+	High       decimal.Decimal `json:"high" db:"high"`
+	Low        decimal.Decimal `json:"low" db:"low"`
+	Close      decimal.Decimal `json:"close" db:"close"`
+	Volume     int64           `json:"volume" db:"volume"`
+}
+
+// PriceHistoryCoverage непрерывный диапазон дат, который уже загружен из провайдера.
+// дни без торгов внутри диапазона тоже считаются загруженными
+type PriceHistoryCoverage struct {
+	SecurityID uuid.UUID `json:"security_id" db:"security_id"`
+	FromDate   time.Time `json:"from_date" db:"from_date"`
+	ToDate     time.Time `json:"to_date" db:"to_date"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PriceBackfillResult итог загрузки истории котировок бумаги за несколько лет
+type PriceBackfillResult struct {
+	SecurityID uuid.UUID  `json:"security_id"`
+	Ticker     string     `json:"ticker"`
+	From       *time.Time `json:"from,omitempty"` // первая загруженная свеча; nil - провайдер истории не отдал
+	To         *time.Time `json:"to,omitempty"`
+	Bars       int        `json:"bars"`
+	// Portfolios портфели с этой бумагой, у которых пересчитаны дневные снимки стоимости
+	Portfolios int `json:"portfolios"`
+	Snapshots  int `json:"snapshots"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Product товар из позиций чеков; позиции с одинаковым названием (без учета регистра и пробелов) - один товар
+type Product struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	Name           string    `json:"name" db:"name"`
+	NormalizedName string    `json:"-" db:"normalized_name"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	//вычисляемые
+	PurchaseCount    int64            `json:"purchase_count"`
+	LastPurchaseDate *time.Time       `json:"last_purchase_date,omitempty"`
+	LastPrice        *decimal.Decimal `json:"last_price,omitempty"`
+	LastCurrency     string           `json:"last_currency,omitempty"`
+}
+
+type ProductRename struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// ProductMerge переносит позиции товаров SourceIDs на товар из URL и удаляет их
+// ("Молоко 2,5% 930мл" из двух магазинов под разными названиями)
+type ProductMerge struct {
+	SourceIDs []uuid.UUID `json:"source_ids" binding:"required,min=1"`
+}
+
+// сколько потрачено на товары за период
+type ProductSpendingReport struct {
+	Currency   string            `json:"currency"`
+	StartDate  time.Time         `json:"start_date"`
+	EndDate    time.Time         `json:"end_date"`
+	TotalSpent decimal.Decimal   `json:"total_spent"` // по всем позициям, привязанным к товарам
+	Products   []ProductSpending `json:"products"`    // по убыванию суммы
+}
+
+type ProductSpending struct {
+	ProductID     uuid.UUID       `json:"product_id"`
+	Name          string          `json:"name"`
+	PurchaseCount int64           `json:"purchase_count"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	TotalSpent    decimal.Decimal `json:"total_spent"`
+	AveragePrice  decimal.Decimal `json:"average_price"` // за единицу
+	Share         decimal.Decimal `json:"share"`         // доля в TotalSpent отчета, %
+}
+
+// история цены товара по покупкам; цены в валюте покупки, без пересчета
+type ProductPriceHistory struct {
+	ProductID     uuid.UUID           `json:"product_id"`
+	Name          string              `json:"name"`
+	Currency      string              `json:"currency"` // валюта последней покупки, в ней считаются min/max/изменение
+	MinPrice      decimal.Decimal     `json:"min_price"`
+	MaxPrice      decimal.Decimal     `json:"max_price"`
+	FirstPrice    decimal.Decimal     `json:"first_price"`
+	LastPrice     decimal.Decimal     `json:"last_price"`
+	ChangePercent decimal.Decimal     `json:"change_percent"` // от первой покупки к последней
+	Points        []ProductPricePoint `json:"points"`         // по возрастанию даты
+	Merchants     []MerchantPrice     `json:"merchants"`      // от самой низкой средней цены
+}
+
+// одна покупка товара
+type ProductPricePoint struct {
+	Date          time.Time       `json:"date"`
+	TransactionID uuid.UUID       `json:"transaction_id"`
+	PayeeID       *uuid.UUID      `json:"payee_id,omitempty"`
+	Merchant      string          `json:"merchant"` // получатель или описание транзакции
+	Quantity      decimal.Decimal `json:"quantity"`
+	Price         decimal.Decimal `json:"price"`
+	Amount        decimal.Decimal `json:"amount"`
+	Currency      string          `json:"currency"`
+}
+
+// цены товара у одного продавца
+type MerchantPrice struct {
+	PayeeID       *uuid.UUID      `json:"payee_id,omitempty"`
+	Merchant      string          `json:"merchant"`
+	Currency      string          `json:"currency"`
+	PurchaseCount int             `json:"purchase_count"`
+	MinPrice      decimal.Decimal `json:"min_price"`
+	AveragePrice  decimal.Decimal `json:"average_price"`
+	LastPrice     decimal.Decimal `json:"last_price"`
+	LastDate      time.Time       `json:"last_date"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PortfolioTarget целевая доля бумаги в портфеле, %
+type PortfolioTarget struct {
+	PortfolioID uuid.UUID       `json:"portfolio_id" db:"portfolio_id"`
+	SecurityID  uuid.UUID       `json:"security_id" db:"security_id"`
+	Weight      decimal.Decimal `json:"weight" db:"weight"`
+	AlertedAt   *time.Time      `json:"alerted_at,omitempty" db:"alerted_at"` // когда отправили уведомление об отклонении; сбрасывается, когда доля вернулась в порог
+}
+
+// RebalanceTargets целевые доли портфеля и порог отклонения
+type RebalanceTargets struct {
+	PortfolioID uuid.UUID         `json:"portfolio_id"`
+	Threshold   *decimal.Decimal  `json:"threshold"` // п.п.; nil - уведомления выключены
+	Targets     []PortfolioTarget `json:"targets"`
+}
+
+type TargetWeightInput struct {
+	SecurityID uuid.UUID       `json:"security_id" binding:"required"`
+	Weight     decimal.Decimal `json:"weight" binding:"required"`
+}
+
+// RebalanceTargetsInput полностью заменяет целевые доли портфеля
+type RebalanceTargetsInput struct {
+	Threshold *decimal.Decimal    `json:"threshold"`
+	Targets   []TargetWeightInput `json:"targets"`
+}
+
+// RebalanceItem отклонение бумаги от целевой доли и сделка, которая ее вернет
+type RebalanceItem struct {
+	SecurityID        uuid.UUID       `json:"security_id"`
+	Ticker            string          `json:"ticker"`
+	Name              string          `json:"name"`
+	TargetWeight      decimal.Decimal `json:"target_weight"`
+	CurrentWeight     decimal.Decimal `json:"current_weight"`
+	Drift             decimal.Decimal `json:"drift"` // текущая доля минус целевая, п.п.
+	CurrentValue      decimal.Decimal `json:"current_value"`
+	Price             decimal.Decimal `json:"price"`              // в валюте портфеля
+	SuggestedAmount   decimal.Decimal `json:"suggested_amount"`   // > 0 - докупить, < 0 - продать
+	SuggestedQuantity decimal.Decimal `json:"suggested_quantity"` // целое число бумаг (контрактов)
+	OverThreshold     bool            `json:"over_threshold"`
+}
+
+// RebalanceReport текущие доли портфеля против целевых
+type RebalanceReport struct {
+	PortfolioID    uuid.UUID        `json:"portfolio_id"`
+	Currency       string           `json:"currency"`
+	TotalValue     decimal.Decimal  `json:"total_value"`
+	Threshold      *decimal.Decimal `json:"threshold"`
+	NeedsRebalance bool             `json:"needs_rebalance"`
+	Items          []RebalanceItem  `json:"items"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Receipt кассовый чек, из которого создана транзакция (проверен в ФНС)
+type Receipt struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	UserID        uuid.UUID       `json:"user_id" db:"user_id"`
+	TransactionID uuid.UUID       `json:"transaction_id" db:"transaction_id"`
+	FN            string          `json:"fn" db:"fn"` // фискальный накопитель, документ и признак однозначно задают чек
+	FD            string          `json:"fd" db:"fd"`
+	FP            string          `json:"fp" db:"fp"`
+	Seller        string          `json:"seller" db:"seller"`
+	SellerINN     string          `json:"seller_inn" db:"seller_inn"`
+	RetailPlace   string          `json:"retail_place" db:"retail_place"`
+	Address       string          `json:"address" db:"address"`
+	Total         decimal.Decimal `json:"total" db:"total"`
+	Date          time.Time       `json:"date" db:"date"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// ReceiptImport строка из QR-кода чека (или фото QR-кода отдельным файлом) и куда записать покупку
+type ReceiptImport struct {
+	QR         string    `json:"qr"`
+	AccountID  uuid.UUID `json:"account_id" binding:"required"`
+	CategoryID uuid.UUID `json:"category_id" binding:"required"`
+}
+
+type ReceiptImportResult struct {
+	Transaction *Transaction `json:"transaction"`
+	Receipt     *Receipt     `json:"receipt"`
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ReportDimension разбивка пользовательского отчета (строки сводной таблицы)
+type ReportDimension string
+
+const (
+	ReportByCategory ReportDimension = "category"
+	ReportByAccount  ReportDimension = "account"
+	ReportByTag      ReportDimension = "tag" // операция с несколькими метками попадает в каждую из них
+	ReportByMonth    ReportDimension = "month"
+)
+
+// ReportMeasure показатель пользовательского отчета (колонки сводной таблицы)
+type ReportMeasure string
+
+const (
+	ReportSum   ReportMeasure = "sum"
+	ReportCount ReportMeasure = "count"
+	ReportAvg   ReportMeasure = "avg" // средняя сумма операции
+)
+
+// ReportSpec что считать: разбивки, показатели и фильтры. из него собирается один SQL-запрос,
+// в текст которого попадают только выражения из белого списка, значения фильтров - параметрами
+type ReportSpec struct {
+	Dimensions []ReportDimension `json:"dimensions" binding:"required,min=1,max=3,dive,oneof=category account tag month"`
+	Measures   []ReportMeasure   `json:"measures" binding:"omitempty,max=3,dive,oneof=sum count avg"` // по умолчанию sum
+	Filters    ReportFilters     `json:"filters"`
+	Currency   string            `json:"currency" binding:"omitempty,len=3"` // пусто - валюта пользователя
+	SortBy     string            `json:"sort_by"`                            // показатель или разбивка; по умолчанию первый показатель
+	SortOrder  string            `json:"sort_order" binding:"omitempty,oneof=asc desc"`
+	Limit      int               `json:"limit" binding:"omitempty,min=1,max=1000"` // сколько строк вернуть
+}
+
+// ReportFilters какие операции попадают в отчет
+type ReportFilters struct {
+	Type        TransactionType  `json:"type" binding:"omitempty,oneof=income expense transfer"` // по умолчанию expense
+	DateFrom    *time.Time       `json:"date_from"`
+	DateTo      *time.Time       `json:"date_to"`
+	LastMonths  int              `json:"last_months" binding:"omitempty,min=1,max=120"` // вместо дат: последние N месяцев, включая текущий
+	AccountIDs  []uuid.UUID      `json:"account_ids"`
+	CategoryIDs []uuid.UUID      `json:"category_ids"` // вместе с подкатегориями
+	Tags        []string         `json:"tags"`
+	AmountMin   *decimal.Decimal `json:"amount_min"`
+	AmountMax   *decimal.Decimal `json:"amount_max"`
+}
+
+// ReportDefinition сохраненный пользовательский отчет
+type ReportDefinition struct {
+	ID     uuid.UUID `json:"id" db:"id"`
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	Name   string    `json:"name" db:"name"`
+	ReportSpec
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type ReportDefinitionCreate struct {
+	Name string `json:"name" binding:"required,max=100"`
+	ReportSpec
+}
+
+// ReportDefinitionUpdate описание отчета заменяется целиком, если передано
+type ReportDefinitionUpdate struct {
+	Name *string     `json:"name" binding:"omitempty,max=100"`
+	Spec *ReportSpec `json:"spec"`
+}
+
+// ReportCell сумма и число операций по одному сочетанию разбивок за месяц в валюте операций (строка из SQL)
+type ReportCell struct {
+	Month    string
+	Currency string
+	Keys     []string
+	Names    []string
+	Sum      decimal.Decimal
+	Count    int64
+}
+
+// ReportResult сводная таблица отчета
+type ReportResult struct {
+	Name       string            `json:"name,omitempty"`
+	Currency   string            `json:"currency"`
+	DateFrom   time.Time         `json:"date_from"`
+	DateTo     time.Time         `json:"date_to"`
+	Dimensions []ReportDimension `json:"dimensions"`
+	Measures   []ReportMeasure   `json:"measures"`
+	Rows       []ReportRow       `json:"rows"`
+	Totals     ReportValues      `json:"totals"`
+	Truncated  bool              `json:"truncated"` // строк больше limit
+}
+
+// ReportRow строка отчета: значения разбивок в порядке dimensions
+type ReportRow struct {
+	Keys   []ReportKey  `json:"keys"`
+	Values ReportValues `json:"values"`
+}
+
+type ReportKey struct {
+	Key  string `json:"key"` // id категории или счета, текст метки, месяц "2024-05"; "" - без категории/метки
+	Name string `json:"name"`
+}
+
+// ReportValues значения только запрошенных показателей
+type ReportValues struct {
+	Sum   *decimal.Decimal `json:"sum,omitempty"`
+	Count *int64           `json:"count,omitempty"`
+	Avg   *decimal.Decimal `json:"avg,omitempty"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReportFrequency string
+
+const (
+	ReportWeekly  ReportFrequency = "weekly"  // за прошлую неделю (пн-вс)
+	ReportMonthly ReportFrequency = "monthly" // за прошлый календарный месяц
+)
+
+// ReportSubscription подписка на отчет по почте; время отправки - в часовом поясе пользователя
+type ReportSubscription struct {
+	ID               uuid.UUID       `json:"id" db:"id"`
+	UserID           uuid.UUID       `json:"user_id" db:"user_id"`
+	Frequency        ReportFrequency `json:"frequency" db:"frequency"`
+	IncludeSummary   bool            `json:"include_summary" db:"include_summary"`     // доходы, расходы, сбережения и топ категорий
+	IncludeBudgets   bool            `json:"include_budgets" db:"include_budgets"`     // исполнение бюджетов
+	IncludePortfolio bool            `json:"include_portfolio" db:"include_portfolio"` // стоимость и доходность портфелей
+	Weekday          int             `json:"weekday" db:"weekday"`                     // для weekly: 1 - понедельник ... 7 - воскресенье
+	DayOfMonth       int             `json:"day_of_month" db:"day_of_month"`           // для monthly: 1-28
+	Hour             int             `json:"hour" db:"hour"`                           // час отправки 0-23
+	IsActive         bool            `json:"is_active" db:"is_active"`
+	LastSentAt       *time.Time      `json:"last_sent_at,omitempty" db:"last_sent_at"`
+	NextSendAt       time.Time       `json:"next_send_at" db:"next_send_at"`
+	LastError        string          `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+type ReportSubscriptionCreate struct {
+	Frequency        ReportFrequency `json:"frequency" binding:"required"`
+	IncludeSummary   *bool           `json:"include_summary"`   // по умолчанию true
+	IncludeBudgets   *bool           `json:"include_budgets"`   // по умолчанию true
+	IncludePortfolio *bool           `json:"include_portfolio"` // по умолчанию true
+	Weekday          int             `json:"weekday"`           // по умолчанию 1 (понедельник)
+	DayOfMonth       int             `json:"day_of_month"`      // по умолчанию 1
+	Hour             *int            `json:"hour"`              // по умолчанию 9
+}
+
+type ReportSubscriptionUpdate struct {
+	IncludeSummary   *bool `json:"include_summary"`
+	IncludeBudgets   *bool `json:"include_budgets"`
+	IncludePortfolio *bool `json:"include_portfolio"`
+	Weekday          *int  `json:"weekday"`
+	DayOfMonth       *int  `json:"day_of_month"`
+	Hour             *int  `json:"hour"`
+	IsActive         *bool `json:"is_active"`
+}
+
+// ReportSendResult итог фоновой рассылки
+type ReportSendResult struct {
+	Sent   int `json:"sent"`
+	Failed int `json:"failed"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// SeedSpec описание тестовых данных: пользователи со счетами, операциями за последние месяцы и портфелями.
+// применяется через сервисы приложения, а не SQL, поэтому данные проходят те же проверки, что и из API
+type SeedSpec struct {
+	Months     int        `json:"months"`      // за сколько месяцев генерировать операции, включая текущий
+	RandomSeed int64      `json:"random_seed"` // одинаковый seed - одинаковые суммы и даты при каждом запуске
+	Users      []SeedUser `json:"users"`
+}
+
+type SeedUser struct {
+	Email     string `json:"email"`
+	Password  string `json:"password"` // пусто - случайный, вход только через /auth/demo или сброс пароля
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Currency  string `json:"currency"`
+
+	Accounts   []SeedAccount   `json:"accounts"`
+	Monthly    []SeedMonthly   `json:"monthly"`
+	Daily      []SeedDaily     `json:"daily"`
+	Portfolios []SeedPortfolio `json:"portfolios"`
+}
+
+// SeedAccount счет пользователя; операции ссылаются на него по имени
+type SeedAccount struct {
+	Name        string          `json:"name"`
+	Type        AccountType     `json:"type"`
+	Currency    string          `json:"currency"` // пусто - валюта пользователя
+	Balance     decimal.Decimal `json:"balance"`
+	Institution string          `json:"institution"`
+}
+
+// SeedMonthly операция раз в месяц в указанный день: зарплата, аренда, подписки
+type SeedMonthly struct {
+	Category    string          `json:"category"` // имя системной категории
+	Description string          `json:"description"`
+	Type        TransactionType `json:"type"` // по умолчанию expense
+	Day         int             `json:"day"`
+	Amount      decimal.Decimal `json:"amount"`
+	Account     string          `json:"account"` // пусто - первый счет
+}
+
+// SeedDaily повседневные расходы: per_month раз в месяц в случайные дни, сумма от min до max.
+// счета из accounts чередуются по очереди
+type SeedDaily struct {
+	Category    string          `json:"category"`
+	Description string          `json:"description"`
+	PerMonth    int             `json:"per_month"`
+	Min         decimal.Decimal `json:"min"`
+	Max         decimal.Decimal `json:"max"`
+	Accounts    []string        `json:"accounts"`
+}
+
+type SeedPortfolio struct {
+	Name     string      `json:"name"`
+	Currency string      `json:"currency"` // пусто - валюта пользователя
+	Broker   string      `json:"broker"`
+	Trades   []SeedTrade `json:"trades"`
+}
+
+// SeedTrade покупка бумаги; цена условная, котировки подтянутся у провайдера
+type SeedTrade struct {
+	Ticker     string          `json:"ticker"`
+	Exchange   Exchange        `json:"exchange"` // по умолчанию MOEX
+	MonthsAgo  int             `json:"months_ago"`
+	Quantity   decimal.Decimal `json:"quantity"`
+	Price      decimal.Decimal `json:"price"`
+	Commission decimal.Decimal `json:"commission"`
+}
+
+// SeedOptions параметры генератора демо-данных
+type SeedOptions struct {
+	Users       int    // сколько пользователей
+	Months      int    // за сколько месяцев операции
+	Portfolios  int    // портфелей у каждого пользователя: 0, 1 или 2
+	EmailPrefix string // demo -> demo1@..., demo2@...
+	EmailDomain string
+	RandomSeed  int64
+}
+
+// SeedResult что создано; уже существующие пользователи пропускаются целиком
+type SeedResult struct {
+	Users []SeedUserResult `json:"users"`
+}
+
+type SeedUserResult struct {
+	ID           uuid.UUID `json:"id"`
+	Email        string    `json:"email"`
+	Password     string    `json:"password,omitempty"` // только если задан в описании
+	Skipped      bool      `json:"skipped"`            // пользователь уже был
+	Transactions int       `json:"transactions"`
+	Portfolios   int       `json:"portfolios"`
+	Trades       int       `json:"trades"`
+	Warnings     []string  `json:"warnings,omitempty"` // например, бумага не нашлась без связи с биржей
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// типы событий безопасности
+const (
+	SecurityEventTokenReuse     = "refresh_token_reuse" // повторно использован уже отозванный refresh токен
+	SecurityEventSessionRevoked = "session_revoked"     // сессия завершена пользователем
+	SecurityEventAccountLocked  = "account_locked"      // вход заблокирован после серии неудачных попыток
+	SecurityEventAccountUnlock  = "account_unlocked"    // блокировка снята кодом из письма
+)
+
+// данные клиента, с которого пришел запрос (для списка сессий)
+type ClientInfo struct {
+	UserAgent string `json:"user_agent"`
+	IPAddress string `json:"ip_address"`
+}
+
+// активная сессия = семейство refresh токенов (цепочка ротаций от одного входа)
+type Session struct {
+	ID         uuid.UUID  `json:"id"` // family_id
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	RememberMe bool       `json:"remember_me"`
+	CreatedAt  time.Time  `json:"created_at"` // когда выдан текущий токен сессии
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+type SecurityEvent struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Type      string     `json:"type" db:"type"`
+	FamilyID  *uuid.UUID `json:"family_id" db:"family_id"`
+	UserAgent string     `json:"user_agent" db:"user_agent"`
+	IPAddress string     `json:"ip_address" db:"ip_address"`
+	Details   string     `json:"details" db:"details"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type SessionsResponse struct {
+	Sessions []Session       `json:"sessions"`
+	Events   []SecurityEvent `json:"events"` // последние подозрительные события
+}
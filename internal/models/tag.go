@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TagUsage метка транзакций и как часто она используется
+type TagUsage struct {
+	Tag              string     `json:"tag"`
+	TransactionCount int64      `json:"transaction_count"`
+	LastUsed         *time.Time `json:"last_used"`
+}
+
+// TagRename переименовывает метку Tag во всех транзакциях пользователя
+type TagRename struct {
+	Tag  string `json:"tag" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
+
+// TagMerge заменяет метки Sources на Target во всех транзакциях пользователя
+type TagMerge struct {
+	Sources []string `json:"sources" binding:"required,min=1"`
+	Target  string   `json:"target" binding:"required"`
+}
+
+// TagChange итог переименования или слияния
+type TagChange struct {
+	Tag                 string `json:"tag"`
+	UpdatedTransactions int64  `json:"updated_transactions"`
+}
+
+// TagSpendingReport расходы по меткам за период в валюте отчета
+type TagSpendingReport struct {
+	Currency  string        `json:"currency"`
+	GroupBy   string        `json:"group_by"`
+	StartDate time.Time     `json:"start_date"`
+	EndDate   time.Time     `json:"end_date"`
+	Tags      []TagSpending `json:"tags"`
+}
+
+type TagSpending struct {
+	Tag              string            `json:"tag"`
+	Total            decimal.Decimal   `json:"total"`
+	TransactionCount int64             `json:"transaction_count"`
+	Periods          []TagPeriodAmount `json:"periods"`
+}
+
+type TagPeriodAmount struct {
+	Period           string          `json:"period"`
+	Amount           decimal.Decimal `json:"amount"`
+	TransactionCount int64           `json:"transaction_count"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TelegramLink привязка аккаунта к чату Telegram-бота
+type TelegramLink struct {
+	UserID    uuid.UUID  `json:"-" db:"user_id"`
+	Linked    bool       `json:"linked"`
+	ChatID    *int64     `json:"chat_id,omitempty" db:"chat_id"`
+	Username  string     `json:"username,omitempty" db:"username"`
+	AccountID *uuid.UUID `json:"account_id,omitempty" db:"account_id"` // счет для операций из бота; nil - подбирается сам
+	LinkedAt  *time.Time `json:"linked_at,omitempty" db:"linked_at"`
+}
+
+// TelegramLinkRequest запрос ссылки для привязки
+type TelegramLinkRequest struct {
+	AccountID *uuid.UUID `json:"account_id"`
+}
+
+// TelegramLinkCode одноразовая ссылка на бота: открыть в Telegram и нажать «Старт»
+type TelegramLinkCode struct {
+	Code      string    `json:"code"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
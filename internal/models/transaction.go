@@ -33,8 +33,19 @@ type Transaction struct {
 	//метаданные для сортировки и деталей (теги, и т.д.)
 	Tags        []string `json:"tags" db:"-"` //теги для категоризации
 	Location    string   `json:"location" db:"location"`
+	Latitude    *float64 `json:"latitude,omitempty" db:"latitude"` // координаты задаются парой
+	Longitude   *float64 `json:"longitude,omitempty" db:"longitude"`
+	Geohash     string   `json:"-" db:"geohash"`
+	City        string   `json:"city,omitempty" db:"city"`
 	Notes       string   `json:"notes" db:"notes"`
 	Attachments []string `json:"attachments" db:"-"` //ссылки на прикрепленные файлы(отчётности и т.п.)
+
+	PayeeID *uuid.UUID `json:"payee_id,omitempty" db:"payee_id"` // получатель, определяется по описанию
+	// сумма в валюте отображения (?currency= в списках) по курсу на дату операции; nil - валюта не запрошена или курса нет
+	ConvertedAmount *decimal.Decimal `json:"converted_amount,omitempty" db:"-"`
+	ConversionRate  *decimal.Decimal `json:"conversion_rate,omitempty" db:"-"`
+	// позиции чека (разбивка суммы по товарам)
+	Items []TransactionItem `json:"items,omitempty" db:"-"`
 	//время аудит
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
@@ -58,7 +69,25 @@ type TransactionCreate struct {
 	RecurrenceRule string           `json:"recurrence_rule"`
 	Tags           []string         `json:"tags"`
 	Location       string           `json:"location"`
+	Latitude       *float64         `json:"latitude"`
+	Longitude      *float64         `json:"longitude"`
+	City           string           `json:"city" binding:"max=100"`
 	Notes          string           `json:"notes"`
+
+	PayeeID *uuid.UUID        `json:"payee_id"` // если не указан - подбирается по описанию
+	Items   []TransactionItem `json:"items"`
+}
+
+// TransactionItem позиция чека внутри транзакции
+type TransactionItem struct {
+	ID         uuid.UUID       `json:"id" db:"id"`
+	Position   int             `json:"position" db:"position"`
+	Name       string          `json:"name" db:"name"`
+	Quantity   decimal.Decimal `json:"quantity" db:"quantity"`
+	Price      decimal.Decimal `json:"price" db:"price"`
+	Amount     decimal.Decimal `json:"amount" db:"amount"`                     // сумма позиции с учетом скидок
+	CategoryID *uuid.UUID      `json:"category_id,omitempty" db:"category_id"` // своя категория позиции, если отличается от транзакции
+	ProductID  *uuid.UUID      `json:"product_id,omitempty" db:"product_id"`   // товар в каталоге, подбирается по названию
 }
 
 type TransactionUpdate struct {
@@ -71,12 +100,20 @@ type TransactionUpdate struct {
 	ToAmount    *decimal.Decimal `json:"to_amount"`
 	Tags        []string         `json:"tags"`
 	Location    *string          `json:"location"`
+	Latitude    *float64         `json:"latitude"`
+	Longitude   *float64         `json:"longitude"`
+	City        *string          `json:"city" binding:"omitempty,max=100"`
 	Notes       *string          `json:"notes"`
+
+	Geohash *string `json:"-"` // считается сервисом по координатам
+
+	PayeeID *uuid.UUID `json:"payee_id"`
 }
 
 type TransactionFilter struct {
 	AccountID  *uuid.UUID       `form:"account_id"`
 	CategoryID *uuid.UUID       `form:"category_id"`
+	PayeeID    *uuid.UUID       `form:"payee_id"`
 	Type       *TransactionType `form:"type"`
 	DateFrom   *time.Time       `form:"date_from"`  //транзакции с этой даты
 	DateTo     *time.Time       `form:"date_to"`    // по эту дату
@@ -88,6 +125,7 @@ type TransactionFilter struct {
 	Limit      int              `form:"limit"`      //пагинация кол-во на стр
 	SortBy     string           `form:"sort_by"`    //?sort_by=date
 	SortOrder  string           `form:"sort_order"` //?sort_order=desc
+	Currency   string           `form:"currency"`   // не фильтр: валюта отображения для converted_amount
 }
 
 // структура пагинированного ответа
@@ -96,5 +134,6 @@ type TransactionList struct {
 	Total        int64         `json:"total"` //всего тарнзакций
 	Page         int           `json:"page"`
 	Limit        int           `json:"limit"`
-	TotalPages   int           `json:"total_pages"` //всего страниц
+	TotalPages   int           `json:"total_pages"`        //всего страниц
+	Currency     string        `json:"currency,omitempty"` // валюта converted_amount, если запрошена
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// TransferMatch пара "расход с одного счета - доход на другой", похожая на один перевод
+// (например, одна и та же операция в выписках двух банков)
+type TransferMatch struct {
+	Outgoing  Transaction      `json:"outgoing"`
+	Incoming  Transaction      `json:"incoming"`
+	DaysApart int              `json:"days_apart"`     // разница дат списания и зачисления
+	Rate      *decimal.Decimal `json:"rate,omitempty"` // курс зачисления к списанию, если валюты счетов разные
+}
+
+// TransferMatchConfirm подтверждение пары: расход превращается в перевод, доход удаляется
+type TransferMatchConfirm struct {
+	OutgoingID uuid.UUID `json:"outgoing_id" binding:"required"`
+	IncomingID uuid.UUID `json:"incoming_id" binding:"required"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// QuotaUsage расход одной квоты; Limit 0 - без ограничения
+type QuotaUsage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+// UserUsage расход квот пользователя; месячные квоты сбрасываются 1-го числа, дневные - в полночь (UTC)
+type UserUsage struct {
+	Portfolios            QuotaUsage `json:"portfolios"`
+	TransactionsThisMonth QuotaUsage `json:"transactions_this_month"` // созданные в этом месяце, включая удаленные
+	AttachmentBytesMonth  QuotaUsage `json:"attachment_bytes_this_month"`
+	AICallsToday          QuotaUsage `json:"ai_calls_today"`
+	MonthResetsAt         time.Time  `json:"month_resets_at"`
+	DayResetsAt           time.Time  `json:"day_resets_at"`
+}
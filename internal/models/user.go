@@ -7,16 +7,38 @@ import (
 )
 
 type User struct {
-	ID              uuid.UUID  `json:"id" db:"id"`
-	Email           string     `json:"email" db:"email"`
-	PasswordHash    string     `json:"-" db:"password_hash"`
-	FirstName       string     `json:"first_name" db:"first_name"`
-	LastName        string     `json:"last_name" db:"last_name"`
-	DefaultCurrency string     `json:"default_currency" db:"default_currency"`
-	Timezone        string     `json:"timezone" db:"timezone"`
-	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
-	DeletedAt       *time.Time `json:"-" db:"deleted_at"`
+	ID                      uuid.UUID  `json:"id" db:"id"`
+	Email                   string     `json:"email" db:"email"`
+	PasswordHash            string     `json:"-" db:"password_hash"`
+	PasswordHashVersion     int16      `json:"-" db:"password_hash_version"` // алгоритм хэша, см. password.Version
+	FirstName               string     `json:"first_name" db:"first_name"`
+	LastName                string     `json:"last_name" db:"last_name"`
+	DefaultCurrency         string     `json:"default_currency" db:"default_currency"`
+	Timezone                string     `json:"timezone" db:"timezone"`
+	Locale                  string     `json:"locale" db:"locale"`                                       // формат сумм и дат в выгрузках и письмах
+	AIEnabled               bool       `json:"ai_enabled" db:"ai_enabled"`                               // false - пользователь отказался от AI-функций
+	IncludeInvestmentIncome bool       `json:"include_investment_income" db:"include_investment_income"` // дивиденды и купоны портфелей входят в доходы аналитики
+	AnomalyAlerts           bool       `json:"anomaly_alerts" db:"anomaly_alerts"`                       // уведомлять о подозрительных тратах
+	Phone                   string     `json:"phone" db:"phone"`
+	BirthDate               *time.Time `json:"birth_date,omitempty" db:"birth_date"` // для прогнозов, завязанных на возраст
+	AvatarKey               string     `json:"-" db:"avatar_key"`                    // ключ файла в хранилище вложений
+	AvatarURL               string     `json:"avatar_url,omitempty" db:"-"`
+	IsDemo                  bool       `json:"-" db:"is_demo"` // общий демо-аккаунт, заведен самим сервером
+	CreatedAt               time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt               *time.Time `json:"-" db:"deleted_at"`
+}
+
+// Age полных лет на дату at; nil - дата рождения не указана
+func (u *User) Age(at time.Time) *int {
+	if u.BirthDate == nil {
+		return nil
+	}
+	age := at.Year() - u.BirthDate.Year()
+	if at.Month() < u.BirthDate.Month() || (at.Month() == u.BirthDate.Month() && at.Day() < u.BirthDate.Day()) {
+		age--
+	}
+	return &age
 }
 
 type UserRegistration struct {
@@ -28,8 +50,9 @@ type UserRegistration struct {
 }
 
 type UserLogin struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	RememberMe *bool  `json:"remember_me"` // nil = true; false - короткая сессия (до закрытия браузера)
 }
 
 type UserUpdate struct {
@@ -37,15 +60,77 @@ type UserUpdate struct {
 	LastName        *string `json:"last_name"`
 	DefaultCurrency *string `json:"defaul_currency"`
 	Timezone        *string `json:"timezone"`
+	Locale          *string `json:"locale" binding:"omitempty,oneof=ru-RU en-US en-GB de-DE fr-FR"`
+	AIEnabled       *bool   `json:"ai_enabled"`
+
+	IncludeInvestmentIncome *bool `json:"include_investment_income"`
+	AnomalyAlerts           *bool `json:"anomaly_alerts"`
+}
+
+// UserProfileUpdate поля профиля для PUT /user/profile; аватар приходит отдельным файлом
+type UserProfileUpdate struct {
+	Phone     *string    `json:"phone" binding:"omitempty,e164|len=0"` // "" - удалить
+	BirthDate *time.Time `json:"birth_date"`
 }
 
 type AuthResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	ExpiresAt    int64  `json:"expires_at"`
+	RememberMe   bool   `json:"remember_me"`
 	User         User   `json:"json"`
 }
 
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
+
+// статусы запроса на удаление аккаунта
+const (
+	AccountDeletionPending  = "pending"  // аккаунт скрыт, ждем окончания льготного периода
+	AccountDeletionPurged   = "purged"   // данные физически удалены
+	AccountDeletionRestored = "restored" // пользователь восстановил аккаунт в льготный период
+)
+
+// запрос на удаление аккаунта: с настроенной почтой подтверждается кодом из письма,
+// без нее - повторным вводом пароля
+type UserDeleteRequest struct {
+	Password string `json:"password"`
+	Reason   string `json:"reason"`
+}
+
+// UserDeleteResult ответ на запрос удаления: письмо с кодом отправлено или удаление уже запланировано
+type UserDeleteResult struct {
+	Status     string     `json:"status"` // confirmation_sent, scheduled
+	ConfirmBy  *time.Time `json:"confirm_by,omitempty"`
+	PurgeAfter *time.Time `json:"purge_after,omitempty"`
+}
+
+const (
+	UserDeleteConfirmationSent = "confirmation_sent"
+	UserDeleteScheduled        = "scheduled"
+)
+
+// UserDeleteConfirm код подтверждения удаления из письма
+type UserDeleteConfirm struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// UserRestoreRequest восстановление удаленного аккаунта до конца льготного периода
+type UserRestoreRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// запись аудита удаления аккаунта (переживает удаление пользователя, email хранится только в виде хеша)
+type AccountDeletion struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	EmailHash   string     `json:"-" db:"email_hash"`
+	Status      string     `json:"status" db:"status"`
+	Reason      string     `json:"reason" db:"reason"`
+	IPAddress   string     `json:"-" db:"ip_address"`
+	RequestedAt time.Time  `json:"requested_at" db:"requested_at"`
+	PurgeAfter  time.Time  `json:"purge_after" db:"purge_after"`
+	PurgedAt    *time.Time `json:"purged_at" db:"purged_at"`
+}
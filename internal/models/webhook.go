@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookEndpoint входящий вебхук пользователя: банк или автоматизация (Tasker, Команды)
+// присылает на секретный адрес JSON об операции, из него сразу создается транзакция
+type WebhookEndpoint struct {
+	ID               uuid.UUID       `json:"id" db:"id"`
+	UserID           uuid.UUID       `json:"user_id" db:"user_id"`
+	Name             string          `json:"name" db:"name"`
+	TokenHash        string          `json:"-" db:"token_hash"`          // sha256 токена из адреса, сам токен не храним
+	AccountID        uuid.UUID       `json:"account_id" db:"account_id"` // счет, если карта из уведомления не опознана
+	CategoryID       uuid.UUID       `json:"category_id" db:"category_id"`
+	IncomeCategoryID *uuid.UUID      `json:"income_category_id,omitempty" db:"income_category_id"` // для поступлений; по умолчанию category_id
+	Template         WebhookTemplate `json:"template" db:"template"`
+	IsActive         bool            `json:"is_active" db:"is_active"`
+	LastUsedAt       *time.Time      `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookTemplate где в JSON уведомления лежат поля операции. путь - ключи через точку,
+// для массивов индекс: "data.operation.amount", "items.0.sum". пустой путь - поле стандартной схемы
+type WebhookTemplate struct {
+	Amount      string            `json:"amount,omitempty"`      // по умолчанию "amount"; число или строка "1 250,50"
+	Type        string            `json:"type,omitempty"`        // по умолчанию "type"; если значения нет - по знаку суммы
+	TypeValues  map[string]string `json:"type_values,omitempty"` // значение отправителя -> income/expense, например {"debit": "expense"}
+	Description string            `json:"description,omitempty"` // по умолчанию "description"
+	Date        string            `json:"date,omitempty"`        // по умолчанию "date": RFC 3339, YYYY-MM-DD или unix-время; нет - время получения
+	Currency    string            `json:"currency,omitempty"`    // по умолчанию "currency"; должна совпадать с валютой счета
+	Card        string            `json:"card,omitempty"`        // по умолчанию "card"; последние цифры карты для выбора счета
+	ExternalID  string            `json:"external_id,omitempty"` // по умолчанию "id"; повтор с тем же id не создает вторую транзакцию
+}
+
+type WebhookEndpointCreate struct {
+	Name             string           `json:"name" binding:"required"`
+	AccountID        uuid.UUID        `json:"account_id" binding:"required"`
+	CategoryID       uuid.UUID        `json:"category_id" binding:"required"`
+	IncomeCategoryID *uuid.UUID       `json:"income_category_id"`
+	Template         *WebhookTemplate `json:"template"`
+}
+
+type WebhookEndpointUpdate struct {
+	Name             *string          `json:"name"`
+	AccountID        *uuid.UUID       `json:"account_id"`
+	CategoryID       *uuid.UUID       `json:"category_id"`
+	IncomeCategoryID *uuid.UUID       `json:"income_category_id"`
+	Template         *WebhookTemplate `json:"template"`
+	IsActive         *bool            `json:"is_active"`
+}
+
+// WebhookEndpointSecret вебхук вместе с секретным адресом; адрес показывается только при создании и смене токена
+type WebhookEndpointSecret struct {
+	WebhookEndpoint
+	Token string `json:"token"`
+	URL   string `json:"url"` // путь относительно адреса сервера
+}
+
+// WebhookDelivery принятое уведомление; по external_id отсекаются повторные доставки
+type WebhookDelivery struct {
+	EndpointID    uuid.UUID `json:"endpoint_id" db:"endpoint_id"`
+	ExternalID    string    `json:"external_id" db:"external_id"`
+	TransactionID uuid.UUID `json:"transaction_id" db:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
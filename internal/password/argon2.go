@@ -0,0 +1,103 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// параметры по умолчанию - второй рекомендованный набор RFC 9106: 64 МиБ, 3 прохода, 4 потока
+const (
+	DefaultArgon2Memory      = 64 * 1024
+	DefaultArgon2Iterations  = 3
+	DefaultArgon2Parallelism = 4
+)
+
+type argon2Scheme struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// NewArgon2id нулевые параметры заменяются значениями по умолчанию
+func NewArgon2id(memory, iterations uint32, parallelism uint8) Scheme {
+	if memory == 0 {
+		memory = DefaultArgon2Memory
+	}
+	if iterations == 0 {
+		iterations = DefaultArgon2Iterations
+	}
+	if parallelism == 0 {
+		parallelism = DefaultArgon2Parallelism
+	}
+	return &argon2Scheme{memory: memory, iterations: iterations, parallelism: parallelism}
+}
+
+func (s *argon2Scheme) Version() Version {
+	return VersionArgon2id
+}
+
+// Hash в формате PHC: $argon2id$v=19$m=65536,t=3,p=4$<соль>$<ключ>, параметры хранятся в самом хэше
+func (s *argon2Scheme) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, s.iterations, s.memory, s.parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, s.memory, s.iterations, s.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (s *argon2Scheme) Verify(hash, password string) bool {
+	params, salt, key, ok := decodeArgon2(hash)
+	if !ok {
+		return false
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1
+}
+
+func (s *argon2Scheme) NeedsRehash(hash string) bool {
+	params, _, key, ok := decodeArgon2(hash)
+	return !ok || *params != *s || len(key) != argon2KeyLength
+}
+
+// decodeArgon2 разбирает хэш в формате PHC
+func decodeArgon2(hash string) (*argon2Scheme, []byte, []byte, bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, false
+	}
+	var params argon2Scheme
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, nil, nil, false
+	}
+	if params.memory == 0 || params.iterations == 0 || params.parallelism == 0 {
+		return nil, nil, nil, false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, false
+	}
+	return &params, salt, key, true
+}
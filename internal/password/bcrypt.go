@@ -0,0 +1,33 @@
+package password
+
+import "golang.org/x/crypto/bcrypt"
+
+type bcryptScheme struct {
+	cost int
+}
+
+// NewBcrypt cost вне допустимых границ заменяется на bcrypt.DefaultCost
+func NewBcrypt(cost int) Scheme {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &bcryptScheme{cost: cost}
+}
+
+func (s *bcryptScheme) Version() Version {
+	return VersionBcrypt
+}
+
+func (s *bcryptScheme) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
+	return string(hash), err
+}
+
+func (s *bcryptScheme) Verify(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (s *bcryptScheme) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != s.cost
+}
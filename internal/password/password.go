@@ -0,0 +1,86 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Version алгоритм, которым посчитан хэш пароля; хранится рядом с хэшем в users.password_hash_version.
+// новый алгоритм - новая версия: старые хэши продолжают проверяться и пересчитываются при входе
+type Version int16
+
+const (
+	VersionBcrypt   Version = 1
+	VersionArgon2id Version = 2
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var ErrUnknownAlgorithm = errors.New("неизвестный алгоритм хэширования паролей")
+
+// Scheme один алгоритм хэширования со своими параметрами
+type Scheme interface {
+	Version() Version
+	Hash(password string) (string, error)
+	Verify(hash, password string) bool
+	// NeedsRehash хэш посчитан с другими параметрами, чем заданы сейчас
+	NeedsRehash(hash string) bool
+}
+
+// Params настройки хэширования из конфига
+type Params struct {
+	Algorithm string // argon2id или bcrypt - чем хэшировать новые пароли
+
+	BcryptCost int
+
+	Argon2Memory      uint32 // КиБ
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// Hasher хэширует новые пароли текущей схемой и проверяет хэши всех известных версий
+type Hasher struct {
+	current Scheme
+	schemes map[Version]Scheme
+}
+
+func NewHasher(params Params) (*Hasher, error) {
+	bcryptScheme := NewBcrypt(params.BcryptCost)
+	argon2Scheme := NewArgon2id(params.Argon2Memory, params.Argon2Iterations, params.Argon2Parallelism)
+
+	h := &Hasher{schemes: map[Version]Scheme{
+		bcryptScheme.Version(): bcryptScheme,
+		argon2Scheme.Version(): argon2Scheme,
+	}}
+	switch params.Algorithm {
+	case AlgorithmArgon2id, "":
+		h.current = argon2Scheme
+	case AlgorithmBcrypt:
+		h.current = bcryptScheme
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, params.Algorithm)
+	}
+	return h, nil
+}
+
+// Hash хэш нового пароля и версия, которую нужно сохранить вместе с ним
+func (h *Hasher) Hash(password string) (string, Version, error) {
+	hash, err := h.current.Hash(password)
+	if err != nil {
+		return "", 0, err
+	}
+	return hash, h.current.Version(), nil
+}
+
+// Verify проверяет пароль; rehash - пароль верный, но хэш устарел (другой алгоритм или параметры)
+// и его стоит пересчитать через Hash, пока пароль на руках
+func (h *Hasher) Verify(hash string, version Version, password string) (ok, rehash bool) {
+	scheme, known := h.schemes[version]
+	if !known || !scheme.Verify(hash, password) {
+		return false, false
+	}
+	return true, version != h.current.Version() || scheme.NeedsRehash(hash)
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// как часто вычищаем давно не использованные корзины
+const memorySweepInterval = 5 * time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// MemoryLimiter корзины в памяти процесса - подходит для одного инстанса
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	if !rule.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	now := time.Now()
+	rate := rule.ratePerMs()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Limit), updated: now}
+		l.buckets[key] = b
+	}
+	b.period = rule.Period
+
+	elapsed := float64(now.Sub(b.updated).Milliseconds())
+	b.tokens = math.Min(float64(rule.Limit), b.tokens+elapsed*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := time.Duration(math.Ceil((1-b.tokens)/rate)) * time.Millisecond
+		return Result{Allowed: false, Remaining: 0, RetryAfter: wait}, nil
+	}
+
+	b.tokens--
+	return Result{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+// sweep удаляет корзины, которые успели полностью восстановиться
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < memorySweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > b.period {
+			delete(l.buckets, key)
+		}
+	}
+}
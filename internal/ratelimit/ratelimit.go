@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule лимит вида "N запросов за период" (token bucket: емкость N, пополнение N/период)
+type Rule struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Enabled нулевое правило означает "без ограничений"
+func (r Rule) Enabled() bool {
+	return r.Limit > 0 && r.Period > 0
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%d/%s", r.Limit, r.Period)
+}
+
+// ratePerMs скорость пополнения корзины
+func (r Rule) ratePerMs() float64 {
+	return float64(r.Limit) / float64(r.Period.Milliseconds())
+}
+
+// Result результат проверки лимита
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // через сколько появится следующий токен (если отказано)
+}
+
+// Limiter хранилище корзин (в памяти процесса или общее в Redis)
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// ParseRule разбирает правило вида "120/m", "10/s", "1000/h", "5/30s".
+// пустая строка и "0" - без ограничений
+func ParseRule(spec string) (Rule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "0" {
+		return Rule{}, nil
+	}
+
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) != 2 {
+		return Rule{}, fmt.Errorf("некорректное правило %q, ожидается N/период", spec)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || limit < 0 {
+		return Rule{}, fmt.Errorf("некорректный лимит в правиле %q", spec)
+	}
+
+	period, err := parsePeriod(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return Rule{}, fmt.Errorf("некорректный период в правиле %q: %w", spec, err)
+	}
+
+	return Rule{Limit: limit, Period: period}, nil
+}
+
+func parsePeriod(value string) (time.Duration, error) {
+	switch value {
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	case "d":
+		return 24 * time.Hour, nil
+	}
+
+	period, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if period < time.Millisecond {
+		return 0, fmt.Errorf("период меньше миллисекунды")
+	}
+	return period, nil
+}
@@ -0,0 +1,319 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// префикс ключей, чтобы не пересекаться с другими данными в Redis
+const redisKeyPrefix = "fintracker:ratelimit:"
+
+const (
+	redisDialTimeout    = 2 * time.Second
+	redisCommandTimeout = 2 * time.Second // на команду, даже если у ctx дедлайна нет или он дальше
+	redisMaxConns       = 32              // одновременных команд; остальные ждут свободного соединения
+	redisMaxIdle        = 8               // сколько соединений держим открытыми между запросами
+)
+
+var errRedisClosed = errors.New("redis limiter is closed")
+
+// tokenBucketScript атомарно пополняет корзину и списывает токен.
+// возвращает {allowed, remaining, retry_after_ms}
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+return {allowed, math.floor(tokens), retry}
+`
+
+// RedisLimiter корзины в Redis - общие лимиты для нескольких инстансов.
+// говорит с Redis напрямую по RESP через пул соединений: запросы не ждут друг друга за одним соединением
+type RedisLimiter struct {
+	addr     string
+	password string
+	db       int
+
+	slots chan struct{}   // занятые соединения, не больше redisMaxConns
+	idle  chan *redisConn // свободные соединения
+
+	mu     sync.Mutex
+	closed bool
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisLimiter принимает адрес вида redis://[:password@]host:port[/db]
+func NewRedisLimiter(rawURL string) (*RedisLimiter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("некорректный адрес Redis %q", rawURL)
+	}
+
+	l := &RedisLimiter{
+		addr:  u.Host,
+		slots: make(chan struct{}, redisMaxConns),
+		idle:  make(chan *redisConn, redisMaxIdle),
+	}
+	if u.User != nil {
+		l.password, _ = u.User.Password()
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		db, err := strconv.Atoi(path)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный номер базы Redis %q", path)
+		}
+		l.db = db
+	}
+
+	return l, nil
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	if !rule.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	reply, err := l.do(ctx,
+		"EVAL", tokenBucketScript, "1", redisKeyPrefix+key,
+		strconv.Itoa(rule.Limit),
+		strconv.FormatFloat(rule.ratePerMs(), 'f', -1, 64),
+		strconv.FormatInt(time.Now().UnixMilli(), 10),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 3 {
+		return Result{}, fmt.Errorf("неожиданный ответ Redis: %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	retry, _ := values[2].(int64)
+
+	return Result{
+		Allowed:    allowed == 1,
+		Remaining:  int(remaining),
+		RetryAfter: time.Duration(retry) * time.Millisecond,
+	}, nil
+}
+
+// Ping проверяет доступность Redis
+func (l *RedisLimiter) Ping(ctx context.Context) error {
+	_, err := l.do(ctx, "PING")
+	return err
+}
+
+// Close закрывает свободные соединения; занятые закроются, когда их вернут
+func (l *RedisLimiter) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+
+	var firstErr error
+	for {
+		select {
+		case c := <-l.idle:
+			if err := c.conn.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		default:
+			return firstErr
+		}
+	}
+}
+
+// do берет соединение из пула, отправляет команду и читает ответ; после сетевой ошибки соединение закрывается
+func (l *RedisLimiter) do(ctx context.Context, args ...string) (interface{}, error) {
+	c, err := l.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.roundTrip(ctx, args)
+	if err != nil {
+		var redisErr redisError
+		if !errors.As(err, &redisErr) {
+			l.put(c, true)
+			return nil, err
+		}
+	}
+	l.put(c, false)
+	return reply, err
+}
+
+// get ждет свободное место в пуле (не дольше ctx) и отдает открытое соединение или новое
+func (l *RedisLimiter) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	l.mu.Lock()
+	closed := l.closed
+	l.mu.Unlock()
+	if closed {
+		<-l.slots
+		return nil, errRedisClosed
+	}
+
+	select {
+	case c := <-l.idle:
+		return c, nil
+	default:
+	}
+
+	c, err := l.connect(ctx)
+	if err != nil {
+		<-l.slots
+		return nil, err
+	}
+	return c, nil
+}
+
+// put возвращает соединение в пул; broken - соединение в неизвестном состоянии, его закрываем
+func (l *RedisLimiter) put(c *redisConn, broken bool) {
+	defer func() { <-l.slots }()
+
+	l.mu.Lock()
+	closed := l.closed
+	l.mu.Unlock()
+	if !broken && !closed {
+		select {
+		case l.idle <- c:
+			return
+		default:
+		}
+	}
+	c.conn.Close()
+}
+
+func (l *RedisLimiter) connect(ctx context.Context) (*redisConn, error) {
+	dialer := net.Dialer{Timeout: redisDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", l.addr)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к Redis: %w", err)
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	if l.password != "" {
+		if _, err := c.roundTrip(ctx, []string{"AUTH", l.password}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if l.db != 0 {
+		if _, err := c.roundTrip(ctx, []string{"SELECT", strconv.Itoa(l.db)}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// roundTrip выполняет одну команду; дедлайн - ближайший из ctx и redisCommandTimeout
+func (c *redisConn) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	deadline := time.Now().Add(redisCommandTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+
+	return readReply(c.reader)
+}
+
+// redisError ошибка, которую вернул сам Redis (соединение при этом исправно)
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readReply разбирает ответ RESP2
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("пустой ответ Redis")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, count)
+		for i := range values {
+			if values[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("неизвестный тип ответа Redis %q", line[0])
+}
@@ -0,0 +1,204 @@
+package receipt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrReceiptNotFound = errors.New("чек не найден или еще не поступил в ФНС")
+	ErrRateLimited     = errors.New("превышен лимит запросов к сервису проверки чеков")
+	ErrProvider        = errors.New("сервис проверки чеков вернул ошибку")
+)
+
+// Receipt чек с позициями, полученный из ФНС
+type Receipt struct {
+	FN            string
+	FD            string
+	FP            string
+	Date          time.Time
+	Total         decimal.Decimal
+	OperationType int
+	Seller        string // юрлицо продавца
+	SellerINN     string
+	RetailPlace   string // название точки продаж, обычно понятнее юрлица
+	Address       string
+	Items         []Item
+}
+
+type Item struct {
+	Name     string
+	Quantity decimal.Decimal
+	Price    decimal.Decimal
+	Sum      decimal.Decimal
+}
+
+// Provider получает чек по данным QR-кода или по фото QR-кода
+type Provider interface {
+	FetchByQR(ctx context.Context, qr *QR) (*Receipt, error)
+	FetchByImage(ctx context.Context, image []byte, filename string) (*Receipt, error)
+}
+
+var _ Provider = (*ProverkachekaClient)(nil)
+
+// ProverkachekaClient клиент proverkacheka.com: сервис сам запрашивает чек в ФНС
+// и умеет распознавать QR-код на фото
+type ProverkachekaClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func NewProverkachekaClient(baseURL, token string) *ProverkachekaClient {
+	return &ProverkachekaClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+type checkResponse struct {
+	Code int             `json:"code"`
+	Data json.RawMessage `json:"data"`
+}
+
+type checkData struct {
+	JSON struct {
+		User                 string `json:"user"`
+		UserINN              string `json:"userInn"`
+		RetailPlace          string `json:"retailPlace"`
+		RetailPlaceAddress   string `json:"retailPlaceAddress"`
+		DateTime             string `json:"dateTime"`
+		TotalSum             int64  `json:"totalSum"` // в копейках
+		OperationType        int    `json:"operationType"`
+		FiscalDriveNumber    string `json:"fiscalDriveNumber"`
+		FiscalDocumentNumber int64  `json:"fiscalDocumentNumber"`
+		FiscalSign           int64  `json:"fiscalSign"`
+		Items                []struct {
+			Name     string          `json:"name"`
+			Price    int64           `json:"price"` // в копейках
+			Quantity decimal.Decimal `json:"quantity"`
+			Sum      int64           `json:"sum"` // в копейках
+		} `json:"items"`
+	} `json:"json"`
+}
+
+func (c *ProverkachekaClient) FetchByQR(ctx context.Context, qr *QR) (*Receipt, error) {
+	return c.fetch(ctx, func(w *multipart.Writer) error {
+		return w.WriteField("qrraw", qr.Raw)
+	})
+}
+
+func (c *ProverkachekaClient) FetchByImage(ctx context.Context, image []byte, filename string) (*Receipt, error) {
+	return c.fetch(ctx, func(w *multipart.Writer) error {
+		part, err := w.CreateFormFile("qrfile", filename)
+		if err != nil {
+			return err
+		}
+		_, err = part.Write(image)
+		return err
+	})
+}
+
+func (c *ProverkachekaClient) fetch(ctx context.Context, writeQR func(w *multipart.Writer) error) (*Receipt, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.WriteField("token", c.token); err != nil {
+		return nil, err
+	}
+	if err := writeQR(w); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/check/get", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("запрос к сервису проверки чеков: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("%w: HTTP %d", ErrProvider, resp.StatusCode)
+	}
+
+	var result checkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+
+	// 1 - чек получен; 0 - неверные данные; 2 - чек еще не в ФНС; 3, 4 - лимиты; 5 - прочее
+	switch result.Code {
+	case 1:
+	case 0, 2:
+		return nil, ErrReceiptNotFound
+	case 3, 4:
+		return nil, ErrRateLimited
+	default:
+		return nil, fmt.Errorf("%w: код %d", ErrProvider, result.Code)
+	}
+
+	var data checkData
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	return data.receipt()
+}
+
+func (d *checkData) receipt() (*Receipt, error) {
+	check := d.JSON
+	date, err := time.ParseInLocation("2006-01-02T15:04:05", check.DateTime, moscow)
+	if err != nil {
+		date, err = time.ParseInLocation("2006-01-02T15:04", check.DateTime, moscow)
+		if err != nil {
+			return nil, fmt.Errorf("%w: дата чека %q", ErrProvider, check.DateTime)
+		}
+	}
+
+	receipt := &Receipt{
+		FN:            check.FiscalDriveNumber,
+		FD:            strconv.FormatInt(check.FiscalDocumentNumber, 10),
+		FP:            strconv.FormatInt(check.FiscalSign, 10),
+		Date:          date,
+		Total:         kopecks(check.TotalSum),
+		OperationType: check.OperationType,
+		Seller:        strings.TrimSpace(check.User),
+		SellerINN:     strings.TrimSpace(check.UserINN),
+		RetailPlace:   strings.TrimSpace(check.RetailPlace),
+		Address:       strings.TrimSpace(check.RetailPlaceAddress),
+	}
+	for _, item := range check.Items {
+		receipt.Items = append(receipt.Items, Item{
+			Name:     strings.TrimSpace(item.Name),
+			Quantity: item.Quantity,
+			Price:    kopecks(item.Price),
+			Sum:      kopecks(item.Sum),
+		})
+	}
+	return receipt, nil
+}
+
+func kopecks(v int64) decimal.Decimal {
+	return decimal.New(v, -2)
+}
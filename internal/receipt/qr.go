@@ -0,0 +1,77 @@
+package receipt
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidQR = errors.New("строка не похожа на QR-код кассового чека")
+
+// признак расчета (n) в QR-коде чека
+const (
+	OperationIncome        = 1 // приход: покупка
+	OperationIncomeReturn  = 2 // возврат прихода: возврат покупки
+	OperationOutcome       = 3 // расход: продавец платит покупателю (например, скупка)
+	OperationOutcomeReturn = 4 // возврат расхода
+)
+
+// QR данные из QR-кода чека по формату ФНС: t=20240115T1530&s=1250.50&fn=...&i=...&fp=...&n=1
+type QR struct {
+	Raw           string
+	Time          time.Time
+	Sum           decimal.Decimal
+	FN            string // номер фискального накопителя
+	FD            string // номер фискального документа (i)
+	FP            string // фискальный признак
+	OperationType int
+}
+
+// ParseQR разбирает строку из QR-кода чека; обязательны t, s, fn, i и fp
+func ParseQR(raw string) (*QR, error) {
+	raw = strings.TrimSpace(raw)
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, ErrInvalidQR
+	}
+
+	qr := &QR{
+		Raw:           raw,
+		FN:            values.Get("fn"),
+		FD:            values.Get("i"),
+		FP:            values.Get("fp"),
+		OperationType: OperationIncome,
+	}
+	if qr.FN == "" || qr.FD == "" || qr.FP == "" {
+		return nil, ErrInvalidQR
+	}
+
+	if qr.Sum, err = decimal.NewFromString(values.Get("s")); err != nil || !qr.Sum.IsPositive() {
+		return nil, ErrInvalidQR
+	}
+
+	// секунды в QR бывают не всегда
+	t := values.Get("t")
+	for _, layout := range []string{"20060102T150405", "20060102T1504"} {
+		if qr.Time, err = time.ParseInLocation(layout, t, moscow); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, ErrInvalidQR
+	}
+
+	if n := values.Get("n"); n != "" {
+		if qr.OperationType, err = strconv.Atoi(n); err != nil || qr.OperationType < OperationIncome || qr.OperationType > OperationOutcomeReturn {
+			return nil, ErrInvalidQR
+		}
+	}
+	return qr, nil
+}
+
+// время в чеках - местное время кассы; без часового пояса считаем московским
+var moscow = time.FixedZone("MSK", 3*60*60)
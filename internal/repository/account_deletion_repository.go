@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AccountDeletionRepository interface {
+	Create(ctx context.Context, deletion *models.AccountDeletion) error
+	GetDue(ctx context.Context, now time.Time, limit int) ([]models.AccountDeletion, error)
+	// GetPending действующий запрос на удаление пользователя
+	GetPending(ctx context.Context, userID uuid.UUID) (*models.AccountDeletion, error)
+	// MarkPurged и MarkRestored меняют только ожидающий запрос; false - его уже восстановили или удалили
+	MarkPurged(ctx context.Context, id uuid.UUID) (bool, error)
+	MarkRestored(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// CreateToken сохраняет код подтверждения удаления из письма; новый код заменяет прежний
+	CreateToken(ctx context.Context, userID uuid.UUID, token, reason string, expiresAt time.Time) error
+	// UseToken гасит действующий код и возвращает пользователя и причину удаления
+	UseToken(ctx context.Context, token string, at time.Time) (uuid.UUID, string, error)
+	// PurgeUserData физически удаляет все данные пользователя
+	PurgeUserData(ctx context.Context, userID uuid.UUID) error
+}
+
+type accountDeletionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAccountDeletionRepository(pool *pgxpool.Pool) AccountDeletionRepository {
+	return &accountDeletionRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *accountDeletionRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *accountDeletionRepository) Create(ctx context.Context, deletion *models.AccountDeletion) error {
+	query := `
+		INSERT INTO account_deletions (id, user_id, email_hash, status, reason, ip_address, requested_at, purge_after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	if deletion.ID == uuid.Nil {
+		deletion.ID = uuid.New()
+	}
+	if deletion.Status == "" {
+		deletion.Status = models.AccountDeletionPending
+	}
+	deletion.RequestedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		deletion.ID, deletion.UserID, deletion.EmailHash, deletion.Status,
+		deletion.Reason, deletion.IPAddress, deletion.RequestedAt, deletion.PurgeAfter,
+	)
+	return err
+}
+
+func (r *accountDeletionRepository) GetDue(ctx context.Context, now time.Time, limit int) ([]models.AccountDeletion, error) {
+	query := `
+		SELECT id, user_id, email_hash, status, COALESCE(reason, ''), COALESCE(ip_address, ''), requested_at, purge_after, purged_at
+		FROM account_deletions
+		WHERE status = $1 AND purge_after <= $2
+		ORDER BY purge_after
+		LIMIT $3
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, models.AccountDeletionPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deletions []models.AccountDeletion
+	for rows.Next() {
+		var d models.AccountDeletion
+		err := rows.Scan(
+			&d.ID, &d.UserID, &d.EmailHash, &d.Status,
+			&d.Reason, &d.IPAddress, &d.RequestedAt, &d.PurgeAfter, &d.PurgedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, d)
+	}
+	return deletions, rows.Err()
+}
+
+func (r *accountDeletionRepository) GetPending(ctx context.Context, userID uuid.UUID) (*models.AccountDeletion, error) {
+	query := `
+		SELECT id, user_id, email_hash, status, COALESCE(reason, ''), COALESCE(ip_address, ''), requested_at, purge_after, purged_at
+		FROM account_deletions
+		WHERE user_id = $1 AND status = $2
+		ORDER BY requested_at DESC
+		LIMIT 1
+	`
+
+	var d models.AccountDeletion
+	err := r.db(ctx).QueryRow(ctx, query, userID, models.AccountDeletionPending).Scan(
+		&d.ID, &d.UserID, &d.EmailHash, &d.Status,
+		&d.Reason, &d.IPAddress, &d.RequestedAt, &d.PurgeAfter, &d.PurgedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *accountDeletionRepository) MarkPurged(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE account_deletions SET status = $2, purged_at = $3 WHERE id = $1 AND status = $4`
+	tag, err := r.db(ctx).Exec(ctx, query, id, models.AccountDeletionPurged, time.Now(), models.AccountDeletionPending)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *accountDeletionRepository) MarkRestored(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE account_deletions SET status = $2 WHERE id = $1 AND status = $3`
+	tag, err := r.db(ctx).Exec(ctx, query, id, models.AccountDeletionRestored, models.AccountDeletionPending)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *accountDeletionRepository) CreateToken(ctx context.Context, userID uuid.UUID, token, reason string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO account_deletion_tokens (user_id, token_hash, reason, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, reason = EXCLUDED.reason,
+			expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP
+	`
+	_, err := r.db(ctx).Exec(ctx, query, userID, hashToken(token), reason, expiresAt)
+	return err
+}
+
+func (r *accountDeletionRepository) UseToken(ctx context.Context, token string, at time.Time) (uuid.UUID, string, error) {
+	var userID uuid.UUID
+	var reason string
+	err := r.db(ctx).QueryRow(ctx, `
+		DELETE FROM account_deletion_tokens WHERE token_hash = $1 AND expires_at > $2
+		RETURNING user_id, COALESCE(reason, '')
+	`, hashToken(token), at).Scan(&userID, &reason)
+	return userID, reason, err
+}
+
+func (r *accountDeletionRepository) PurgeUserData(ctx context.Context, userID uuid.UUID) error {
+	// транзакции (и запланированные) удаляем явно: они ссылаются на категории без каскада.
+	// остальное (счета, бюджеты, цели, портфели, токены и т.д.) уходит каскадом от users
+	queries := []string{
+		`DELETE FROM planned_transactions WHERE user_id = $1`,
+		`DELETE FROM transactions WHERE user_id = $1`,
+		`DELETE FROM users WHERE id = $1`,
+	}
+
+	for _, query := range queries {
+		if _, err := r.db(ctx).Exec(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
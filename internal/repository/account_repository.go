@@ -2,10 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/shopspring/decimal"
 )
@@ -18,6 +20,14 @@ type AccountRepository interface {
 	UpdateBalance(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	GetSummary(ctx context.Context, userID uuid.UUID) (*models.AccountSummary, error)
+
+	// SetOrder нумерует счета пользователя в порядке ids; чужие id пропускаются
+	SetOrder(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) error
+	// SetGroups задает своим счетам пользователя группы; "" убирает счет из группы
+	SetGroups(ctx context.Context, userID uuid.UUID, groups map[uuid.UUID]string) error
+	// GetGrouping способ группировки списка; без настройки - none
+	GetGrouping(ctx context.Context, userID uuid.UUID) (models.AccountGrouping, error)
+	SetGrouping(ctx context.Context, userID uuid.UUID, groupBy models.AccountGrouping) error
 }
 
 type accountRepository struct {
@@ -35,8 +45,11 @@ func (r *accountRepository) db(ctx context.Context) DBTX {
 
 func (r *accountRepository) Create(ctx context.Context, account *models.Account) error {
 	query := `
-		INSERT INTO accounts (id, user_id, name, type, currency, balance, initial_balance, icon, color, is_active, institution, account_number, notes, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		INSERT INTO accounts (id, user_id, name, type, currency, balance, initial_balance, icon, color, is_active, institution, account_number, notes, created_at, updated_at,
+			sort_order)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15,
+			(SELECT COALESCE(MAX(sort_order), 0) + 1 FROM accounts WHERE user_id = $2))
+		RETURNING sort_order
 	`
 
 	if account.ID == uuid.Nil {
@@ -48,19 +61,20 @@ func (r *accountRepository) Create(ctx context.Context, account *models.Account)
 	account.Balance = account.InitialBalance
 	account.IsActive = true
 
-	_, err := r.db(ctx).Exec(ctx, query,
+	// новый счет встает в конец сохраненного порядка
+	return r.db(ctx).QueryRow(ctx, query,
 		account.ID, account.UserID, account.Name, account.Type,
 		account.Currency, account.Balance, account.InitialBalance,
 		account.Icon, account.Color, account.IsActive,
 		account.Institution, account.AccountNumber, account.Notes,
 		account.CreatedAt, account.UpdatedAt,
-	)
-	return err
+	).Scan(&account.SortOrder)
 }
 
 func (r *accountRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Account, error) {
 	query := `
-		SELECT id, user_id, name, type, currency, balance, initial_balance, icon, color, is_active, institution, account_number, notes, created_at, updated_at
+		SELECT id, user_id, name, type, currency, balance, initial_balance, icon, color, is_active, institution, account_number, notes,
+			sort_order, COALESCE(account_group, ''), created_at, updated_at
 		FROM accounts
 		WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -71,6 +85,7 @@ func (r *accountRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.
 		&account.Currency, &account.Balance, &account.InitialBalance,
 		&account.Icon, &account.Color, &account.IsActive,
 		&account.Institution, &account.AccountNumber, &account.Notes,
+		&account.SortOrder, &account.Group,
 		&account.CreatedAt, &account.UpdatedAt,
 	)
 	if err != nil {
@@ -81,10 +96,11 @@ func (r *accountRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.
 
 func (r *accountRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Account, error) {
 	query := `
-		SELECT id, user_id, name, type, currency, balance, initial_balance, icon, color, is_active, institution, account_number, notes, created_at, updated_at
+		SELECT id, user_id, name, type, currency, balance, initial_balance, icon, color, is_active, institution, account_number, notes,
+			sort_order, COALESCE(account_group, ''), created_at, updated_at
 		FROM accounts
 		WHERE user_id = $1 AND deleted_at IS NULL
-		ORDER BY created_at
+		ORDER BY sort_order, created_at
 	`
 
 	rows, err := r.db(ctx).Query(ctx, query, userID)
@@ -101,6 +117,7 @@ func (r *accountRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (
 			&account.Currency, &account.Balance, &account.InitialBalance,
 			&account.Icon, &account.Color, &account.IsActive,
 			&account.Institution, &account.AccountNumber, &account.Notes,
+			&account.SortOrder, &account.Group,
 			&account.CreatedAt, &account.UpdatedAt,
 		)
 		if err != nil {
@@ -174,3 +191,49 @@ func (r *accountRepository) GetSummary(ctx context.Context, userID uuid.UUID) (*
 
 	return summary, nil
 }
+
+func (r *accountRepository) SetOrder(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) error {
+	query := `
+		UPDATE accounts a SET sort_order = o.position, updated_at = $3
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS o(id, position)
+		WHERE a.id = o.id AND a.user_id = $1
+	`
+	_, err := r.db(ctx).Exec(ctx, query, userID, ids, time.Now())
+	return err
+}
+
+func (r *accountRepository) SetGroups(ctx context.Context, userID uuid.UUID, groups map[uuid.UUID]string) error {
+	ids := make([]uuid.UUID, 0, len(groups))
+	names := make([]string, 0, len(groups))
+	for id, name := range groups {
+		ids = append(ids, id)
+		names = append(names, name)
+	}
+
+	query := `
+		UPDATE accounts a SET account_group = NULLIF(g.name, ''), updated_at = $4
+		FROM unnest($2::uuid[], $3::text[]) AS g(id, name)
+		WHERE a.id = g.id AND a.user_id = $1
+	`
+	_, err := r.db(ctx).Exec(ctx, query, userID, ids, names, time.Now())
+	return err
+}
+
+func (r *accountRepository) GetGrouping(ctx context.Context, userID uuid.UUID) (models.AccountGrouping, error) {
+	var groupBy models.AccountGrouping
+	err := r.db(ctx).QueryRow(ctx, `SELECT group_by FROM account_preferences WHERE user_id = $1`, userID).Scan(&groupBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.AccountGroupingNone, nil
+	}
+	return groupBy, err
+}
+
+func (r *accountRepository) SetGrouping(ctx context.Context, userID uuid.UUID, groupBy models.AccountGrouping) error {
+	query := `
+		INSERT INTO account_preferences (user_id, group_by, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET group_by = EXCLUDED.group_by, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db(ctx).Exec(ctx, query, userID, groupBy, time.Now())
+	return err
+}
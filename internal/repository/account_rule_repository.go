@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+type AccountRuleRepository interface {
+	Create(ctx context.Context, rule *models.AccountRule) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.AccountRule, error)
+	GetByAccountID(ctx context.Context, accountID uuid.UUID) ([]models.AccountRule, error)
+	// GetActiveByUserID включенные правила живых счетов пользователя (для прогноза)
+	GetActiveByUserID(ctx context.Context, userID uuid.UUID) ([]models.AccountRule, error)
+	// GetActive включенные правила живых счетов всех пользователей (для фоновой задачи)
+	GetActive(ctx context.Context) ([]models.AccountRule, error)
+	Update(ctx context.Context, id uuid.UUID, update *models.AccountRuleUpdate) error
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// CreateRun занимает месяц за правилом; false - за этот месяц правило уже сработало
+	CreateRun(ctx context.Context, run *models.AccountRuleRun) (bool, error)
+	SetRunTransaction(ctx context.Context, ruleID uuid.UUID, period time.Time, transactionID uuid.UUID) error
+	// GetExpenseTotal расходы по счету за период; categoryID - только категория и ее подкатегории
+	GetExpenseTotal(ctx context.Context, accountID uuid.UUID, categoryID *uuid.UUID, from, to time.Time) (decimal.Decimal, error)
+}
+
+type accountRuleRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAccountRuleRepository(pool *pgxpool.Pool) AccountRuleRepository {
+	return &accountRuleRepository{pool: pool}
+}
+
+func (r *accountRuleRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const accountRuleColumns = `ar.id, ar.user_id, ar.account_id, ar.kind, ar.description, ar.category_id, ar.day_of_month,
+	ar.amount, ar.rate, ar.source_category_id, ar.max_amount, ar.is_active, ar.created_at, ar.updated_at,
+	(SELECT MAX(period) FROM account_rule_runs WHERE rule_id = ar.id)`
+
+func scanAccountRule(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.AccountRule, error) {
+	var rule models.AccountRule
+	err := row.Scan(
+		&rule.ID, &rule.UserID, &rule.AccountID, &rule.Kind, &rule.Description, &rule.CategoryID, &rule.DayOfMonth,
+		&rule.Amount, &rule.Rate, &rule.SourceCategoryID, &rule.MaxAmount, &rule.IsActive, &rule.CreatedAt, &rule.UpdatedAt,
+		&rule.LastPeriod,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *accountRuleRepository) Create(ctx context.Context, rule *models.AccountRule) error {
+	query := `
+		INSERT INTO account_rules (id, user_id, account_id, kind, description, category_id, day_of_month,
+			amount, rate, source_category_id, max_amount, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`
+
+	if rule.ID == uuid.Nil {
+		rule.ID = uuid.New()
+	}
+	now := time.Now()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		rule.ID, rule.UserID, rule.AccountID, rule.Kind, rule.Description, rule.CategoryID, rule.DayOfMonth,
+		rule.Amount, rule.Rate, rule.SourceCategoryID, rule.MaxAmount, rule.IsActive, rule.CreatedAt, rule.UpdatedAt,
+	)
+	return err
+}
+
+func (r *accountRuleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.AccountRule, error) {
+	query := `SELECT ` + accountRuleColumns + ` FROM account_rules ar WHERE ar.id = $1`
+	return scanAccountRule(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *accountRuleRepository) GetByAccountID(ctx context.Context, accountID uuid.UUID) ([]models.AccountRule, error) {
+	query := `SELECT ` + accountRuleColumns + ` FROM account_rules ar WHERE ar.account_id = $1 ORDER BY ar.created_at`
+	return r.list(ctx, query, accountID)
+}
+
+func (r *accountRuleRepository) GetActiveByUserID(ctx context.Context, userID uuid.UUID) ([]models.AccountRule, error) {
+	query := `
+		SELECT ` + accountRuleColumns + `
+		FROM account_rules ar
+		JOIN accounts a ON a.id = ar.account_id AND a.deleted_at IS NULL AND a.is_active
+		WHERE ar.user_id = $1 AND ar.is_active
+		ORDER BY ar.created_at
+	`
+	return r.list(ctx, query, userID)
+}
+
+func (r *accountRuleRepository) GetActive(ctx context.Context) ([]models.AccountRule, error) {
+	query := `
+		SELECT ` + accountRuleColumns + `
+		FROM account_rules ar
+		JOIN accounts a ON a.id = ar.account_id AND a.deleted_at IS NULL AND a.is_active
+		WHERE ar.is_active
+		ORDER BY ar.created_at
+	`
+	return r.list(ctx, query)
+}
+
+func (r *accountRuleRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.AccountRule, error) {
+	rows, err := r.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.AccountRule
+	for rows.Next() {
+		rule, err := scanAccountRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *accountRuleRepository) Update(ctx context.Context, id uuid.UUID, update *models.AccountRuleUpdate) error {
+	query := `
+		UPDATE account_rules SET
+			description = COALESCE($2, description),
+			category_id = COALESCE($3, category_id),
+			day_of_month = COALESCE($4, day_of_month),
+			amount = COALESCE($5, amount),
+			rate = COALESCE($6, rate),
+			source_category_id = COALESCE($7, source_category_id),
+			max_amount = COALESCE($8, max_amount),
+			is_active = COALESCE($9, is_active),
+			updated_at = $10
+		WHERE id = $1
+	`
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		id, update.Description, update.CategoryID, update.DayOfMonth, update.Amount, update.Rate,
+		update.SourceCategoryID, update.MaxAmount, update.IsActive, time.Now(),
+	)
+	return err
+}
+
+func (r *accountRuleRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db(ctx).Exec(ctx, `DELETE FROM account_rules WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *accountRuleRepository) CreateRun(ctx context.Context, run *models.AccountRuleRun) (bool, error) {
+	query := `
+		INSERT INTO account_rule_runs (rule_id, period, amount, transaction_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (rule_id, period) DO NOTHING
+	`
+
+	run.CreatedAt = time.Now()
+	result, err := r.db(ctx).Exec(ctx, query, run.RuleID, run.Period, run.Amount, run.TransactionID, run.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *accountRuleRepository) SetRunTransaction(ctx context.Context, ruleID uuid.UUID, period time.Time, transactionID uuid.UUID) error {
+	query := `UPDATE account_rule_runs SET transaction_id = $3 WHERE rule_id = $1 AND period = $2`
+	_, err := r.db(ctx).Exec(ctx, query, ruleID, period, transactionID)
+	return err
+}
+
+func (r *accountRuleRepository) GetExpenseTotal(ctx context.Context, accountID uuid.UUID, categoryID *uuid.UUID, from, to time.Time) (decimal.Decimal, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM transactions
+		WHERE account_id = $1 AND type = 'expense' AND date >= $2 AND date <= $3 AND deleted_at IS NULL
+		  AND ($4::uuid IS NULL OR category_id = $4 OR category_id IN (SELECT id FROM categories WHERE parent_id = $4))
+	`
+
+	var total decimal.Decimal
+	err := r.db(ctx).QueryRow(ctx, query, accountID, from, to, categoryID).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AIRecommendationRepository interface {
+	Get(ctx context.Context, userID uuid.UUID) (*models.AIRecommendationsRun, error)
+	// Start занимает генерацию, если прошлая попытка началась не позже since; false - занять не удалось
+	Start(ctx context.Context, userID uuid.UUID, startedAt, since time.Time) (bool, error)
+	// Cancel отменяет занятую генерацию (например, при исчерпанной квоте), возвращая прошлое время попытки
+	Cancel(ctx context.Context, userID uuid.UUID, startedAt, previous time.Time) error
+	// Finish сохраняет результат генерации; errMsg не пустой - генерация не удалась, прошлые рекомендации остаются
+	Finish(ctx context.Context, userID uuid.UUID, startedAt time.Time, items []models.Recommendation, errMsg string) error
+}
+
+type aiRecommendationRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAIRecommendationRepository(pool *pgxpool.Pool) AIRecommendationRepository {
+	return &aiRecommendationRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *aiRecommendationRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *aiRecommendationRepository) Get(ctx context.Context, userID uuid.UUID) (*models.AIRecommendationsRun, error) {
+	query := `
+		SELECT user_id, items, generated_at, started_at, running, error
+		FROM ai_recommendations
+		WHERE user_id = $1
+	`
+
+	run := &models.AIRecommendationsRun{}
+	var items []byte
+	err := r.db(ctx).QueryRow(ctx, query, userID).Scan(
+		&run.UserID, &items, &run.GeneratedAt, &run.StartedAt, &run.Running, &run.Error,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(items, &run.Items); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (r *aiRecommendationRepository) Start(ctx context.Context, userID uuid.UUID, startedAt, since time.Time) (bool, error) {
+	// условие в самом запросе: из нескольких экземпляров генерацию займет только один
+	query := `
+		INSERT INTO ai_recommendations (user_id, started_at, running)
+		VALUES ($1, $2, true)
+		ON CONFLICT (user_id) DO UPDATE SET started_at = EXCLUDED.started_at, running = true, error = ''
+		WHERE ai_recommendations.started_at <= $3
+	`
+
+	tag, err := r.db(ctx).Exec(ctx, query, userID, startedAt, since)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *aiRecommendationRepository) Cancel(ctx context.Context, userID uuid.UUID, startedAt, previous time.Time) error {
+	_, err := r.db(ctx).Exec(ctx, `
+		UPDATE ai_recommendations SET running = false, started_at = $3
+		WHERE user_id = $1 AND started_at = $2
+	`, userID, startedAt, previous)
+	return err
+}
+
+func (r *aiRecommendationRepository) Finish(ctx context.Context, userID uuid.UUID, startedAt time.Time, items []models.Recommendation, errMsg string) error {
+	// started_at в условии: результат устаревшей попытки не перетирает более новую
+	if errMsg != "" {
+		_, err := r.db(ctx).Exec(ctx, `
+			UPDATE ai_recommendations SET running = false, error = $3
+			WHERE user_id = $1 AND started_at = $2
+		`, userID, startedAt, errMsg)
+		return err
+	}
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	_, err = r.db(ctx).Exec(ctx, `
+		UPDATE ai_recommendations SET running = false, error = '', items = $3, generated_at = $4
+		WHERE user_id = $1 AND started_at = $2
+	`, userID, startedAt, data, time.Now())
+	return err
+}
@@ -16,6 +16,13 @@ type BudgetRepository interface {
 	GetByCategory(ctx context.Context, userID uuid.UUID, categoryID uuid.UUID) ([]models.Budget, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.BudgetUpdate) error
 	Delete(ctx context.Context, id uuid.UUID) error
+
+	// GetFiredAlerts сработавшие пороги бюджетов в заданных периодах: budgetIDs[i] - в периоде с periodStarts[i]
+	GetFiredAlerts(ctx context.Context, budgetIDs []uuid.UUID, periodStarts []time.Time) (map[uuid.UUID]map[int]time.Time, error)
+	// MarkAlertFired отмечает порог сработавшим; false - он уже срабатывал в этом периоде
+	MarkAlertFired(ctx context.Context, budgetID uuid.UUID, periodStart time.Time, threshold int, firedAt time.Time) (bool, error)
+	// ResetAlerts снимает отметки порогов выше percent, чтобы они могли сработать снова
+	ResetAlerts(ctx context.Context, budgetID uuid.UUID, periodStart time.Time, percent float64) error
 }
 
 type budgetRepository struct {
@@ -26,10 +33,15 @@ func NewBudgetRepository(pool *pgxpool.Pool) BudgetRepository {
 	return &budgetRepository{pool: pool}
 }
 
+// db возвращает транзакцию из контекста или pool
+func (r *budgetRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
 func (r *budgetRepository) Create(ctx context.Context, budget *models.Budget) error {
 	query := `
-		INSERT INTO budgets (id, user_id, category_id, name, amount, currency, period, start_date, end_date, is_active, alert_percent, notes, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		INSERT INTO budgets (id, user_id, category_id, account_id, payee_id, name, amount, currency, period, start_date, end_date, is_active, alert_percent, alert_thresholds, notes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 	`
 
 	if budget.ID == uuid.Nil {
@@ -43,12 +55,15 @@ func (r *budgetRepository) Create(ctx context.Context, budget *models.Budget) er
 	if budget.AlertPercent == 0 {
 		budget.AlertPercent = 80
 	}
+	if len(budget.AlertThresholds) == 0 {
+		budget.AlertThresholds = []int{budget.AlertPercent, 100}
+	}
 
-	_, err := r.pool.Exec(ctx, query,
-		budget.ID, budget.UserID, budget.CategoryID, budget.Name,
+	_, err := r.db(ctx).Exec(ctx, query,
+		budget.ID, budget.UserID, budget.CategoryID, budget.AccountID, budget.PayeeID, budget.Name,
 		budget.Amount, budget.Currency, budget.Period,
 		budget.StartDate, budget.EndDate, budget.IsActive,
-		budget.AlertPercent, budget.Notes,
+		budget.AlertPercent, budget.AlertThresholds, budget.Notes,
 		budget.CreatedAt, budget.UpdatedAt,
 	)
 	return err
@@ -56,17 +71,17 @@ func (r *budgetRepository) Create(ctx context.Context, budget *models.Budget) er
 
 func (r *budgetRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Budget, error) {
 	query := `
-		SELECT id, user_id, category_id, name, amount, currency, period, start_date, end_date, is_active, alert_percent, notes, created_at, updated_at
+		SELECT id, user_id, category_id, account_id, payee_id, name, amount, currency, period, start_date, end_date, is_active, alert_percent, alert_thresholds, notes, created_at, updated_at
 		FROM budgets
 		WHERE id = $1
 	`
 
 	var budget models.Budget
-	err := r.pool.QueryRow(ctx, query, id).Scan(
-		&budget.ID, &budget.UserID, &budget.CategoryID, &budget.Name,
+	err := r.db(ctx).QueryRow(ctx, query, id).Scan(
+		&budget.ID, &budget.UserID, &budget.CategoryID, &budget.AccountID, &budget.PayeeID, &budget.Name,
 		&budget.Amount, &budget.Currency, &budget.Period,
 		&budget.StartDate, &budget.EndDate, &budget.IsActive,
-		&budget.AlertPercent, &budget.Notes,
+		&budget.AlertPercent, &budget.AlertThresholds, &budget.Notes,
 		&budget.CreatedAt, &budget.UpdatedAt,
 	)
 	if err != nil {
@@ -77,7 +92,7 @@ func (r *budgetRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.B
 
 func (r *budgetRepository) GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.Budget, error) {
 	query := `
-		SELECT id, user_id, category_id, name, amount, currency, period, start_date, end_date, is_active, alert_percent, notes, created_at, updated_at
+		SELECT id, user_id, category_id, account_id, payee_id, name, amount, currency, period, start_date, end_date, is_active, alert_percent, alert_thresholds, notes, created_at, updated_at
 		FROM budgets
 		WHERE user_id = $1
 	`
@@ -87,7 +102,7 @@ func (r *budgetRepository) GetByUserID(ctx context.Context, userID uuid.UUID, ac
 	}
 	query += " ORDER BY created_at DESC"
 
-	rows, err := r.pool.Query(ctx, query, userID)
+	rows, err := r.db(ctx).Query(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -97,10 +112,10 @@ func (r *budgetRepository) GetByUserID(ctx context.Context, userID uuid.UUID, ac
 	for rows.Next() {
 		var budget models.Budget
 		err := rows.Scan(
-			&budget.ID, &budget.UserID, &budget.CategoryID, &budget.Name,
+			&budget.ID, &budget.UserID, &budget.CategoryID, &budget.AccountID, &budget.PayeeID, &budget.Name,
 			&budget.Amount, &budget.Currency, &budget.Period,
 			&budget.StartDate, &budget.EndDate, &budget.IsActive,
-			&budget.AlertPercent, &budget.Notes,
+			&budget.AlertPercent, &budget.AlertThresholds, &budget.Notes,
 			&budget.CreatedAt, &budget.UpdatedAt,
 		)
 		if err != nil {
@@ -113,13 +128,13 @@ func (r *budgetRepository) GetByUserID(ctx context.Context, userID uuid.UUID, ac
 
 func (r *budgetRepository) GetByCategory(ctx context.Context, userID uuid.UUID, categoryID uuid.UUID) ([]models.Budget, error) {
 	query := `
-		SELECT id, user_id, category_id, name, amount, currency, period, start_date, end_date, is_active, alert_percent, notes, created_at, updated_at
+		SELECT id, user_id, category_id, account_id, payee_id, name, amount, currency, period, start_date, end_date, is_active, alert_percent, alert_thresholds, notes, created_at, updated_at
 		FROM budgets
 		WHERE user_id = $1 AND category_id = $2
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.pool.Query(ctx, query, userID, categoryID)
+	rows, err := r.db(ctx).Query(ctx, query, userID, categoryID)
 	if err != nil {
 		return nil, err
 	}
@@ -129,10 +144,10 @@ func (r *budgetRepository) GetByCategory(ctx context.Context, userID uuid.UUID,
 	for rows.Next() {
 		var budget models.Budget
 		err := rows.Scan(
-			&budget.ID, &budget.UserID, &budget.CategoryID, &budget.Name,
+			&budget.ID, &budget.UserID, &budget.CategoryID, &budget.AccountID, &budget.PayeeID, &budget.Name,
 			&budget.Amount, &budget.Currency, &budget.Period,
 			&budget.StartDate, &budget.EndDate, &budget.IsActive,
-			&budget.AlertPercent, &budget.Notes,
+			&budget.AlertPercent, &budget.AlertThresholds, &budget.Notes,
 			&budget.CreatedAt, &budget.UpdatedAt,
 		)
 		if err != nil {
@@ -155,21 +170,81 @@ func (r *budgetRepository) Update(ctx context.Context, id uuid.UUID, update *mod
 			is_active = COALESCE($8, is_active),
 			alert_percent = COALESCE($9, alert_percent),
 			notes = COALESCE($10, notes),
-			updated_at = $11
+			account_id = COALESCE($11, account_id),
+			payee_id = COALESCE($12, payee_id),
+			updated_at = $13,
+			alert_thresholds = COALESCE($14::int[], alert_thresholds)
 		WHERE id = $1
 	`
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err := r.db(ctx).Exec(ctx, query,
 		id, update.CategoryID, update.Name, update.Amount,
 		update.Period, update.StartDate, update.EndDate,
 		update.IsActive, update.AlertPercent, update.Notes,
-		time.Now(),
+		update.AccountID, update.PayeeID,
+		time.Now(), update.AlertThresholds,
 	)
 	return err
 }
 
 func (r *budgetRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM budgets WHERE id = $1`
-	_, err := r.pool.Exec(ctx, query, id)
+	_, err := r.db(ctx).Exec(ctx, query, id)
+	return err
+}
+
+func (r *budgetRepository) GetFiredAlerts(ctx context.Context, budgetIDs []uuid.UUID, periodStarts []time.Time) (map[uuid.UUID]map[int]time.Time, error) {
+	fired := make(map[uuid.UUID]map[int]time.Time)
+	if len(budgetIDs) == 0 {
+		return fired, nil
+	}
+
+	query := `
+		SELECT s.budget_id, s.threshold, s.fired_at
+		FROM budget_alert_state s
+		JOIN unnest($1::uuid[], $2::date[]) AS p(budget_id, period_start)
+		  ON p.budget_id = s.budget_id AND p.period_start = s.period_start
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, budgetIDs, periodStarts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			budgetID  uuid.UUID
+			threshold int
+			firedAt   time.Time
+		)
+		if err := rows.Scan(&budgetID, &threshold, &firedAt); err != nil {
+			return nil, err
+		}
+		if fired[budgetID] == nil {
+			fired[budgetID] = make(map[int]time.Time)
+		}
+		fired[budgetID][threshold] = firedAt
+	}
+	return fired, rows.Err()
+}
+
+func (r *budgetRepository) MarkAlertFired(ctx context.Context, budgetID uuid.UUID, periodStart time.Time, threshold int, firedAt time.Time) (bool, error) {
+	// вставка с ON CONFLICT: из параллельных проверок порог достанется только одной
+	query := `
+		INSERT INTO budget_alert_state (budget_id, period_start, threshold, fired_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (budget_id, period_start, threshold) DO NOTHING
+	`
+	tag, err := r.db(ctx).Exec(ctx, query, budgetID, periodStart, threshold, firedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *budgetRepository) ResetAlerts(ctx context.Context, budgetID uuid.UUID, periodStart time.Time, percent float64) error {
+	query := `DELETE FROM budget_alert_state WHERE budget_id = $1 AND period_start = $2 AND threshold > $3`
+	_, err := r.db(ctx).Exec(ctx, query, budgetID, periodStart, percent)
 	return err
 }
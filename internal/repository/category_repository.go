@@ -17,6 +17,8 @@ type CategoryRepository interface {
 	GetSystemCategories(ctx context.Context) ([]models.Category, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.CategoryUpdate) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	// SetFixed сохраняет признак обязательного расхода для пользователя (и для системных категорий)
+	SetFixed(ctx context.Context, userID, categoryID uuid.UUID, isFixed bool) error
 }
 
 type categoryRepository struct {
@@ -27,10 +29,15 @@ func NewCategoryRepository(pool *pgxpool.Pool) CategoryRepository {
 	return &categoryRepository{pool: pool}
 }
 
+// db возвращает транзакцию из контекста или pool
+func (r *categoryRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
 func (r *categoryRepository) Create(ctx context.Context, category *models.Category) error {
 	query := `
-		INSERT INTO categories (id, user_id, name, type, icon, color, parent_id, is_system, sort_order, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO categories (id, user_id, name, type, icon, color, parent_id, is_system, sort_order, is_fixed, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	`
 
 	if category.ID == uuid.Nil {
@@ -40,10 +47,10 @@ func (r *categoryRepository) Create(ctx context.Context, category *models.Catego
 	category.CreatedAt = now
 	category.UpdatedAt = now
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err := r.db(ctx).Exec(ctx, query,
 		category.ID, category.UserID, category.Name, category.Type,
 		category.Icon, category.Color, category.ParentID,
-		category.IsSystem, category.SortOrder,
+		category.IsSystem, category.SortOrder, category.IsFixed,
 		category.CreatedAt, category.UpdatedAt,
 	)
 	return err
@@ -51,16 +58,16 @@ func (r *categoryRepository) Create(ctx context.Context, category *models.Catego
 
 func (r *categoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Category, error) {
 	query := `
-		SELECT id, user_id, name, type, icon, color, parent_id, is_system, sort_order, created_at, updated_at
+		SELECT id, user_id, name, type, icon, color, parent_id, is_system, sort_order, is_fixed, created_at, updated_at
 		FROM categories
 		WHERE id = $1
 	`
 
 	var category models.Category
-	err := r.pool.QueryRow(ctx, query, id).Scan(
+	err := r.db(ctx).QueryRow(ctx, query, id).Scan(
 		&category.ID, &category.UserID, &category.Name, &category.Type,
 		&category.Icon, &category.Color, &category.ParentID,
-		&category.IsSystem, &category.SortOrder,
+		&category.IsSystem, &category.SortOrder, &category.IsFixed,
 		&category.CreatedAt, &category.UpdatedAt,
 	)
 	if err != nil {
@@ -71,20 +78,24 @@ func (r *categoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*models
 
 func (r *categoryRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Category, error) {
 	query := `
-		SELECT id, user_id, name, type, icon, color, parent_id, is_system, sort_order, created_at, updated_at
-		FROM categories
-		WHERE (user_id = $1 OR is_system = true)
-		ORDER BY sort_order,name 
+		SELECT c.id, c.user_id, c.name, c.type, c.icon, c.color, c.parent_id, c.is_system, c.sort_order,
+			COALESCE(cp.is_fixed, c.is_fixed), c.created_at, c.updated_at
+		FROM categories c
+		LEFT JOIN category_preferences cp ON cp.category_id = c.id AND cp.user_id = $1
+		WHERE (c.user_id = $1 OR c.is_system = true)
+		ORDER BY c.sort_order, c.name
 	`
 	return r.queryCategories(ctx, query, userID)
 }
 
 func (r *categoryRepository) GetByType(ctx context.Context, userID uuid.UUID, categoryType models.CategoryType) ([]models.Category, error) {
 	query := `
-		SELECT id, user_id, name, type, icon, color, parent_id, is_system, sort_order, created_at, updated_at
-		FROM categories
-		WHERE (user_id = $1 OR is_system = true) AND type = $2
-		ORDER BY sort_order, name
+		SELECT c.id, c.user_id, c.name, c.type, c.icon, c.color, c.parent_id, c.is_system, c.sort_order,
+			COALESCE(cp.is_fixed, c.is_fixed), c.created_at, c.updated_at
+		FROM categories c
+		LEFT JOIN category_preferences cp ON cp.category_id = c.id AND cp.user_id = $1
+		WHERE (c.user_id = $1 OR c.is_system = true) AND c.type = $2
+		ORDER BY c.sort_order, c.name
 	`
 
 	return r.queryCategories(ctx, query, userID, categoryType)
@@ -92,7 +103,7 @@ func (r *categoryRepository) GetByType(ctx context.Context, userID uuid.UUID, ca
 
 func (r *categoryRepository) GetSystemCategories(ctx context.Context) ([]models.Category, error) {
 	query := `
-		SELECT id, user_id, name, type, icon, color, parent_id, is_system, sort_order, created_at, updated_at
+		SELECT id, user_id, name, type, icon, color, parent_id, is_system, sort_order, is_fixed, created_at, updated_at
 		FROM categories
 		WHERE is_system = true
 		ORDER BY sort_order, name
@@ -101,7 +112,7 @@ func (r *categoryRepository) GetSystemCategories(ctx context.Context) ([]models.
 	return r.queryCategories(ctx, query)
 }
 func (r *categoryRepository) queryCategories(ctx context.Context, query string, args ...interface{}) ([]models.Category, error) {
-	rows, err := r.pool.Query(ctx, query, args...)
+	rows, err := r.db(ctx).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -113,7 +124,7 @@ func (r *categoryRepository) queryCategories(ctx context.Context, query string,
 		err := rows.Scan(
 			&category.ID, &category.UserID, &category.Name, &category.Type,
 			&category.Icon, &category.Color, &category.ParentID,
-			&category.IsSystem, &category.SortOrder,
+			&category.IsSystem, &category.SortOrder, &category.IsFixed,
 			&category.CreatedAt, &category.UpdatedAt,
 		)
 		if err != nil {
@@ -136,7 +147,7 @@ func (r *categoryRepository) Update(ctx context.Context, id uuid.UUID, update *m
 		WHERE id = $1 AND is_system = false
 	`
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err := r.db(ctx).Exec(ctx, query,
 		id, update.Name, update.Icon, update.Color,
 		update.ParentID, update.SortOrder, time.Now(),
 	)
@@ -145,6 +156,16 @@ func (r *categoryRepository) Update(ctx context.Context, id uuid.UUID, update *m
 
 func (r *categoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM categories WHERE id = $1 AND is_system = false`
-	_, err := r.pool.Exec(ctx, query, id)
+	_, err := r.db(ctx).Exec(ctx, query, id)
+	return err
+}
+
+func (r *categoryRepository) SetFixed(ctx context.Context, userID, categoryID uuid.UUID, isFixed bool) error {
+	query := `
+		INSERT INTO category_preferences (user_id, category_id, is_fixed, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, category_id) DO UPDATE SET is_fixed = EXCLUDED.is_fixed, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db(ctx).Exec(ctx, query, userID, categoryID, isFixed, time.Now())
 	return err
 }
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ChallengeRepository interface {
+	Create(ctx context.Context, challenge *models.Challenge) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Challenge, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Challenge, error)
+	// GetActive включенные челленджи всех пользователей (для фоновой задачи)
+	GetActive(ctx context.Context) ([]models.Challenge, error)
+	Update(ctx context.Context, id uuid.UUID, update *models.ChallengeUpdate) error
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// CreateResult записывает итог периода; false - период уже подведен параллельным проходом
+	CreateResult(ctx context.Context, result *models.ChallengeResult) (bool, error)
+	// SetStreak сохраняет серию после подведения периода lastPeriod
+	SetStreak(ctx context.Context, id uuid.UUID, lastPeriod time.Time, current, best int) error
+	// GetResults итоги последних периодов, новые первыми
+	GetResults(ctx context.Context, challengeID uuid.UUID, limit int) ([]models.ChallengeResult, error)
+}
+
+type challengeRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewChallengeRepository(pool *pgxpool.Pool) ChallengeRepository {
+	return &challengeRepository{pool: pool}
+}
+
+func (r *challengeRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const challengeColumns = `c.id, c.user_id, c.name, c.kind, c.period, c.amount, c.weekdays, c.category_id, c.account_id, c.payee_id,
+	c.start_date, c.end_date, c.is_active, c.current_streak, c.best_streak, c.last_period, c.created_at, c.updated_at,
+	(SELECT COUNT(*) FROM challenge_results WHERE challenge_id = c.id AND status = 'completed'),
+	(SELECT COUNT(*) FROM challenge_results WHERE challenge_id = c.id AND status = 'failed')`
+
+func scanChallenge(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.Challenge, error) {
+	var c models.Challenge
+	err := row.Scan(
+		&c.ID, &c.UserID, &c.Name, &c.Kind, &c.Period, &c.Amount, &c.Weekdays, &c.CategoryID, &c.AccountID, &c.PayeeID,
+		&c.StartDate, &c.EndDate, &c.IsActive, &c.CurrentStreak, &c.BestStreak, &c.LastPeriod, &c.CreatedAt, &c.UpdatedAt,
+		&c.Completed, &c.Failed,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *challengeRepository) Create(ctx context.Context, challenge *models.Challenge) error {
+	query := `
+		INSERT INTO challenges (id, user_id, name, kind, period, amount, weekdays, category_id, account_id, payee_id,
+			start_date, end_date, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`
+
+	if challenge.ID == uuid.Nil {
+		challenge.ID = uuid.New()
+	}
+	if challenge.Weekdays == nil {
+		challenge.Weekdays = []int{}
+	}
+	now := time.Now()
+	challenge.CreatedAt = now
+	challenge.UpdatedAt = now
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		challenge.ID, challenge.UserID, challenge.Name, challenge.Kind, challenge.Period, challenge.Amount, challenge.Weekdays,
+		challenge.CategoryID, challenge.AccountID, challenge.PayeeID,
+		challenge.StartDate, challenge.EndDate, challenge.IsActive, challenge.CreatedAt, challenge.UpdatedAt,
+	)
+	return err
+}
+
+func (r *challengeRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Challenge, error) {
+	query := `SELECT ` + challengeColumns + ` FROM challenges c WHERE c.id = $1`
+	return scanChallenge(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *challengeRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Challenge, error) {
+	query := `SELECT ` + challengeColumns + ` FROM challenges c WHERE c.user_id = $1 ORDER BY c.is_active DESC, c.created_at DESC`
+	return r.list(ctx, query, userID)
+}
+
+func (r *challengeRepository) GetActive(ctx context.Context) ([]models.Challenge, error) {
+	query := `SELECT ` + challengeColumns + ` FROM challenges c WHERE c.is_active ORDER BY c.user_id, c.created_at`
+	return r.list(ctx, query)
+}
+
+func (r *challengeRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.Challenge, error) {
+	rows, err := r.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var challenges []models.Challenge
+	for rows.Next() {
+		challenge, err := scanChallenge(rows)
+		if err != nil {
+			return nil, err
+		}
+		challenges = append(challenges, *challenge)
+	}
+	return challenges, rows.Err()
+}
+
+func (r *challengeRepository) Update(ctx context.Context, id uuid.UUID, update *models.ChallengeUpdate) error {
+	query := `
+		UPDATE challenges SET
+			name = COALESCE($2, name),
+			amount = COALESCE($3, amount),
+			end_date = COALESCE($4, end_date),
+			is_active = COALESCE($5, is_active),
+			updated_at = $6
+		WHERE id = $1
+	`
+
+	_, err := r.db(ctx).Exec(ctx, query, id, update.Name, update.Amount, update.EndDate, update.IsActive, time.Now())
+	return err
+}
+
+func (r *challengeRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db(ctx).Exec(ctx, `DELETE FROM challenges WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *challengeRepository) CreateResult(ctx context.Context, result *models.ChallengeResult) (bool, error) {
+	query := `
+		INSERT INTO challenge_results (challenge_id, period_start, period_end, status, spent, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (challenge_id, period_start) DO NOTHING
+	`
+
+	result.CreatedAt = time.Now()
+	tag, err := r.db(ctx).Exec(ctx, query,
+		result.ChallengeID, result.PeriodStart, result.PeriodEnd, result.Status, result.Spent, result.CreatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *challengeRepository) SetStreak(ctx context.Context, id uuid.UUID, lastPeriod time.Time, current, best int) error {
+	query := `UPDATE challenges SET last_period = $2, current_streak = $3, best_streak = $4 WHERE id = $1`
+	_, err := r.db(ctx).Exec(ctx, query, id, lastPeriod, current, best)
+	return err
+}
+
+func (r *challengeRepository) GetResults(ctx context.Context, challengeID uuid.UUID, limit int) ([]models.ChallengeResult, error) {
+	query := `
+		SELECT challenge_id, period_start, period_end, status, spent, created_at
+		FROM challenge_results
+		WHERE challenge_id = $1
+		ORDER BY period_start DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, challengeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.ChallengeResult
+	for rows.Next() {
+		var res models.ChallengeResult
+		if err := rows.Scan(&res.ChallengeID, &res.PeriodStart, &res.PeriodEnd, &res.Status, &res.Spent, &res.CreatedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CSVImportRepository interface {
+	CreateProfile(ctx context.Context, profile *models.CSVImportProfile) error
+	GetProfileByID(ctx context.Context, id uuid.UUID) (*models.CSVImportProfile, error)
+	GetProfilesByUserID(ctx context.Context, userID uuid.UUID) ([]models.CSVImportProfile, error)
+	UpdateProfile(ctx context.Context, profile *models.CSVImportProfile) error
+	MarkProfileUsed(ctx context.Context, id uuid.UUID) error
+	DeleteProfile(ctx context.Context, id uuid.UUID) (bool, error)
+
+	CreateUpload(ctx context.Context, upload *models.CSVUpload) error
+	GetUploadByID(ctx context.Context, id uuid.UUID) (*models.CSVUpload, error)
+	// DeleteUploadsBefore удаляет выгрузки, загруженные раньше before
+	DeleteUploadsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type csvImportRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewCSVImportRepository(pool *pgxpool.Pool) CSVImportRepository {
+	return &csvImportRepository{pool: pool}
+}
+
+func (r *csvImportRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const csvProfileColumns = `id, user_id, name, account_id, category_id, income_category_id, mapping, last_used_at, created_at, updated_at`
+
+func scanCSVProfile(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.CSVImportProfile, error) {
+	var p models.CSVImportProfile
+	var mapping []byte
+	err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.AccountID, &p.CategoryID, &p.IncomeCategoryID,
+		&mapping, &p.LastUsedAt, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(mapping, &p.Mapping); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *csvImportRepository) CreateProfile(ctx context.Context, profile *models.CSVImportProfile) error {
+	query := `
+		INSERT INTO csv_import_profiles (id, user_id, name, account_id, category_id, income_category_id, mapping, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	mapping, err := json.Marshal(profile.Mapping)
+	if err != nil {
+		return err
+	}
+
+	if profile.ID == uuid.Nil {
+		profile.ID = uuid.New()
+	}
+	now := time.Now()
+	profile.CreatedAt = now
+	profile.UpdatedAt = now
+
+	_, err = r.db(ctx).Exec(ctx, query,
+		profile.ID, profile.UserID, profile.Name, profile.AccountID, profile.CategoryID, profile.IncomeCategoryID,
+		mapping, profile.CreatedAt, profile.UpdatedAt,
+	)
+	return err
+}
+
+func (r *csvImportRepository) GetProfileByID(ctx context.Context, id uuid.UUID) (*models.CSVImportProfile, error) {
+	query := `SELECT ` + csvProfileColumns + ` FROM csv_import_profiles WHERE id = $1`
+	return scanCSVProfile(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *csvImportRepository) GetProfilesByUserID(ctx context.Context, userID uuid.UUID) ([]models.CSVImportProfile, error) {
+	query := `SELECT ` + csvProfileColumns + ` FROM csv_import_profiles WHERE user_id = $1 ORDER BY last_used_at DESC NULLS LAST, name`
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []models.CSVImportProfile
+	for rows.Next() {
+		p, err := scanCSVProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *p)
+	}
+	return profiles, rows.Err()
+}
+
+func (r *csvImportRepository) UpdateProfile(ctx context.Context, profile *models.CSVImportProfile) error {
+	query := `
+		UPDATE csv_import_profiles SET
+			name = $2, account_id = $3, category_id = $4, income_category_id = $5, mapping = $6, updated_at = $7
+		WHERE id = $1
+	`
+
+	mapping, err := json.Marshal(profile.Mapping)
+	if err != nil {
+		return err
+	}
+	profile.UpdatedAt = time.Now()
+
+	_, err = r.db(ctx).Exec(ctx, query,
+		profile.ID, profile.Name, profile.AccountID, profile.CategoryID, profile.IncomeCategoryID,
+		mapping, profile.UpdatedAt,
+	)
+	return err
+}
+
+func (r *csvImportRepository) MarkProfileUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `UPDATE csv_import_profiles SET last_used_at = $2 WHERE id = $1`, id, time.Now())
+	return err
+}
+
+func (r *csvImportRepository) DeleteProfile(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db(ctx).Exec(ctx, `DELETE FROM csv_import_profiles WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *csvImportRepository) CreateUpload(ctx context.Context, upload *models.CSVUpload) error {
+	if upload.ID == uuid.Nil {
+		upload.ID = uuid.New()
+	}
+	upload.CreatedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, `
+		INSERT INTO csv_uploads (id, user_id, filename, content, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, upload.ID, upload.UserID, upload.Filename, upload.Content, upload.CreatedAt)
+	return err
+}
+
+func (r *csvImportRepository) GetUploadByID(ctx context.Context, id uuid.UUID) (*models.CSVUpload, error) {
+	var u models.CSVUpload
+	err := r.db(ctx).QueryRow(ctx, `SELECT id, user_id, filename, content, created_at FROM csv_uploads WHERE id = $1`, id).
+		Scan(&u.ID, &u.UserID, &u.Filename, &u.Content, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *csvImportRepository) DeleteUploadsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db(ctx).Exec(ctx, `DELETE FROM csv_uploads WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CurrencyRedenominationRepository interface {
+	List(ctx context.Context) ([]models.CurrencyRedenomination, error)
+	Create(ctx context.Context, r *models.CurrencyRedenomination) error
+	// Delete false - записи не было
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+type currencyRedenominationRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewCurrencyRedenominationRepository(pool *pgxpool.Pool) CurrencyRedenominationRepository {
+	return &currencyRedenominationRepository{pool: pool}
+}
+
+func (r *currencyRedenominationRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *currencyRedenominationRepository) List(ctx context.Context) ([]models.CurrencyRedenomination, error) {
+	query := `
+		SELECT id, currency, new_currency, factor, effective_date, note, created_at
+		FROM currency_redenominations
+		ORDER BY effective_date, currency
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.CurrencyRedenomination
+	for rows.Next() {
+		var d models.CurrencyRedenomination
+		if err := rows.Scan(&d.ID, &d.Currency, &d.NewCurrency, &d.Factor, &d.EffectiveDate, &d.Note, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+func (r *currencyRedenominationRepository) Create(ctx context.Context, d *models.CurrencyRedenomination) error {
+	query := `
+		INSERT INTO currency_redenominations (id, currency, new_currency, factor, effective_date, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	d.ID = uuid.New()
+	d.CreatedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		d.ID, d.Currency, d.NewCurrency, d.Factor, d.EffectiveDate, d.Note, d.CreatedAt,
+	)
+	return err
+}
+
+func (r *currencyRedenominationRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `DELETE FROM currency_redenominations WHERE id = $1`
+	tag, err := r.db(ctx).Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
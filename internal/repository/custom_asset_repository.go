@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+type CustomAssetRepository interface {
+	Create(ctx context.Context, asset *models.CustomAsset) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.CustomAsset, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.CustomAsset, error)
+	Update(ctx context.Context, asset *models.CustomAsset) error
+	// SetValue обновляет текущую стоимость по последней оценке
+	SetValue(ctx context.Context, id uuid.UUID, value decimal.Decimal, valuedAt time.Time) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	AddValuation(ctx context.Context, valuation *models.CustomAssetValuation) error
+	GetValuation(ctx context.Context, id uuid.UUID) (*models.CustomAssetValuation, error)
+	// GetValuations история оценок по возрастанию даты
+	GetValuations(ctx context.Context, assetID uuid.UUID) ([]models.CustomAssetValuation, error)
+	DeleteValuation(ctx context.Context, id uuid.UUID) error
+}
+
+type customAssetRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewCustomAssetRepository(pool *pgxpool.Pool) CustomAssetRepository {
+	return &customAssetRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *customAssetRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const customAssetColumns = `id, user_id, name, class, currency, current_value, valued_at, purchase_value, purchase_date, COALESCE(notes, ''), is_active, created_at, updated_at`
+
+func scanCustomAsset(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.CustomAsset, error) {
+	var a models.CustomAsset
+	err := row.Scan(
+		&a.ID, &a.UserID, &a.Name, &a.Class, &a.Currency, &a.CurrentValue, &a.ValuedAt,
+		&a.PurchaseValue, &a.PurchaseDate, &a.Notes, &a.IsActive, &a.CreatedAt, &a.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *customAssetRepository) Create(ctx context.Context, asset *models.CustomAsset) error {
+	query := `
+		INSERT INTO custom_assets (id, user_id, name, class, currency, current_value, valued_at, purchase_value, purchase_date, notes, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	if asset.ID == uuid.Nil {
+		asset.ID = uuid.New()
+	}
+	now := time.Now()
+	asset.CreatedAt = now
+	asset.UpdatedAt = now
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		asset.ID, asset.UserID, asset.Name, asset.Class, asset.Currency, asset.CurrentValue, asset.ValuedAt,
+		asset.PurchaseValue, asset.PurchaseDate, asset.Notes, asset.IsActive, asset.CreatedAt, asset.UpdatedAt,
+	)
+	return err
+}
+
+func (r *customAssetRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.CustomAsset, error) {
+	query := `SELECT ` + customAssetColumns + ` FROM custom_assets WHERE id = $1`
+	return scanCustomAsset(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *customAssetRepository) GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.CustomAsset, error) {
+	query := `SELECT ` + customAssetColumns + ` FROM custom_assets WHERE user_id = $1`
+	if activeOnly {
+		query += ` AND is_active = true`
+	}
+	query += ` ORDER BY class, name`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assets []models.CustomAsset
+	for rows.Next() {
+		a, err := scanCustomAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, *a)
+	}
+	return assets, rows.Err()
+}
+
+func (r *customAssetRepository) Update(ctx context.Context, asset *models.CustomAsset) error {
+	query := `
+		UPDATE custom_assets SET
+			name = $2, class = $3, purchase_value = $4, purchase_date = $5, notes = $6, is_active = $7, updated_at = $8
+		WHERE id = $1
+	`
+
+	asset.UpdatedAt = time.Now()
+	_, err := r.db(ctx).Exec(ctx, query,
+		asset.ID, asset.Name, asset.Class, asset.PurchaseValue, asset.PurchaseDate, asset.Notes, asset.IsActive, asset.UpdatedAt,
+	)
+	return err
+}
+
+func (r *customAssetRepository) SetValue(ctx context.Context, id uuid.UUID, value decimal.Decimal, valuedAt time.Time) error {
+	query := `UPDATE custom_assets SET current_value = $2, valued_at = $3, updated_at = $4 WHERE id = $1`
+	_, err := r.db(ctx).Exec(ctx, query, id, value, valuedAt, time.Now())
+	return err
+}
+
+func (r *customAssetRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM custom_assets WHERE id = $1`, id)
+	return err
+}
+
+func (r *customAssetRepository) AddValuation(ctx context.Context, valuation *models.CustomAssetValuation) error {
+	query := `
+		INSERT INTO custom_asset_valuations (id, asset_id, value, date, notes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	if valuation.ID == uuid.Nil {
+		valuation.ID = uuid.New()
+	}
+	valuation.CreatedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		valuation.ID, valuation.AssetID, valuation.Value, valuation.Date, valuation.Notes, valuation.CreatedAt,
+	)
+	return err
+}
+
+const customAssetValuationColumns = `id, asset_id, value, date, COALESCE(notes, ''), created_at`
+
+func scanCustomAssetValuation(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.CustomAssetValuation, error) {
+	var v models.CustomAssetValuation
+	if err := row.Scan(&v.ID, &v.AssetID, &v.Value, &v.Date, &v.Notes, &v.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *customAssetRepository) GetValuation(ctx context.Context, id uuid.UUID) (*models.CustomAssetValuation, error) {
+	query := `SELECT ` + customAssetValuationColumns + ` FROM custom_asset_valuations WHERE id = $1`
+	return scanCustomAssetValuation(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *customAssetRepository) GetValuations(ctx context.Context, assetID uuid.UUID) ([]models.CustomAssetValuation, error) {
+	query := `SELECT ` + customAssetValuationColumns + ` FROM custom_asset_valuations WHERE asset_id = $1 ORDER BY date, created_at`
+
+	rows, err := r.db(ctx).Query(ctx, query, assetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var valuations []models.CustomAssetValuation
+	for rows.Next() {
+		v, err := scanCustomAssetValuation(rows)
+		if err != nil {
+			return nil, err
+		}
+		valuations = append(valuations, *v)
+	}
+	return valuations, rows.Err()
+}
+
+func (r *customAssetRepository) DeleteValuation(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM custom_asset_valuations WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DepositRepository interface {
+	Create(ctx context.Context, deposit *models.Deposit) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Deposit, error)
+	// GetByUserID вклады по дате окончания
+	GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.Deposit, error)
+	Update(ctx context.Context, deposit *models.Deposit) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type depositRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewDepositRepository(pool *pgxpool.Pool) DepositRepository {
+	return &depositRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *depositRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const depositColumns = `id, user_id, bank, COALESCE(name, ''), currency, principal, rate, capitalization, opened_at, term_days, maturity_date, payout_account_id, COALESCE(notes, ''), is_active, created_at, updated_at`
+
+func scanDeposit(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.Deposit, error) {
+	var d models.Deposit
+	err := row.Scan(
+		&d.ID, &d.UserID, &d.Bank, &d.Name, &d.Currency, &d.Principal, &d.Rate, &d.Capitalization, &d.OpenedAt,
+		&d.TermDays, &d.MaturityDate, &d.PayoutAccountID, &d.Notes, &d.IsActive, &d.CreatedAt, &d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *depositRepository) Create(ctx context.Context, deposit *models.Deposit) error {
+	query := `
+		INSERT INTO deposits (id, user_id, bank, name, currency, principal, rate, capitalization, opened_at, term_days,
+			maturity_date, payout_account_id, notes, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`
+
+	if deposit.ID == uuid.Nil {
+		deposit.ID = uuid.New()
+	}
+	now := time.Now()
+	deposit.CreatedAt = now
+	deposit.UpdatedAt = now
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		deposit.ID, deposit.UserID, deposit.Bank, deposit.Name, deposit.Currency, deposit.Principal, deposit.Rate,
+		deposit.Capitalization, deposit.OpenedAt, deposit.TermDays, deposit.MaturityDate, deposit.PayoutAccountID,
+		deposit.Notes, deposit.IsActive, deposit.CreatedAt, deposit.UpdatedAt,
+	)
+	return err
+}
+
+func (r *depositRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Deposit, error) {
+	query := `SELECT ` + depositColumns + ` FROM deposits WHERE id = $1`
+	return scanDeposit(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *depositRepository) GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.Deposit, error) {
+	query := `SELECT ` + depositColumns + ` FROM deposits WHERE user_id = $1`
+	if activeOnly {
+		query += ` AND is_active = true`
+	}
+	query += ` ORDER BY maturity_date, bank`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deposits []models.Deposit
+	for rows.Next() {
+		d, err := scanDeposit(rows)
+		if err != nil {
+			return nil, err
+		}
+		deposits = append(deposits, *d)
+	}
+	return deposits, rows.Err()
+}
+
+func (r *depositRepository) Update(ctx context.Context, deposit *models.Deposit) error {
+	query := `
+		UPDATE deposits SET
+			bank = $2, name = $3, payout_account_id = $4, notes = $5, is_active = $6, updated_at = $7
+		WHERE id = $1
+	`
+
+	deposit.UpdatedAt = time.Now()
+	_, err := r.db(ctx).Exec(ctx, query,
+		deposit.ID, deposit.Bank, deposit.Name, deposit.PayoutAccountID, deposit.Notes, deposit.IsActive, deposit.UpdatedAt,
+	)
+	return err
+}
+
+func (r *depositRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM deposits WHERE id = $1`, id)
+	return err
+}
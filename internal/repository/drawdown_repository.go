@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+type DrawdownRepository interface {
+	// GetSettings nil без ошибки - пороги не заданы
+	GetSettings(ctx context.Context, portfolioID uuid.UUID) (*models.DrawdownAlertSettings, error)
+	// UpsertSettings сохраняет пороги, отметки об отправленных уведомлениях не трогает
+	UpsertSettings(ctx context.Context, settings *models.DrawdownAlertSettings) error
+	SetAlerted(ctx context.Context, portfolioID uuid.UUID, kind models.DrawdownAlertKind, at time.Time) error
+	// GetWatchedPortfolioIDs активные портфели, у которых включен хотя бы один порог
+	GetWatchedPortfolioIDs(ctx context.Context) ([]uuid.UUID, error)
+
+	// UpsertSnapshot записывает стоимость портфеля за день; максимум дня только растет
+	UpsertSnapshot(ctx context.Context, snapshot *models.PortfolioValueSnapshot) error
+	// GetSnapshots снимки с даты from по возрастанию даты
+	GetSnapshots(ctx context.Context, portfolioID uuid.UUID, from time.Time) ([]models.PortfolioValueSnapshot, error)
+	// ReplaceSnapshots перезаписывает снимки портфеля по пересчитанной истории: максимум дня равен стоимости
+	ReplaceSnapshots(ctx context.Context, portfolioID uuid.UUID, snapshots []models.PortfolioValueSnapshot) error
+}
+
+type drawdownRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewDrawdownRepository(pool *pgxpool.Pool) DrawdownRepository {
+	return &drawdownRepository{pool: pool}
+}
+
+func (r *drawdownRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *drawdownRepository) GetSettings(ctx context.Context, portfolioID uuid.UUID) (*models.DrawdownAlertSettings, error) {
+	query := `
+		SELECT portfolio_id, drawdown_pct, daily_drop_pct, cooldown_hours, drawdown_alerted_at, daily_drop_alerted_at
+		FROM portfolio_drawdown_alerts
+		WHERE portfolio_id = $1
+	`
+
+	var s models.DrawdownAlertSettings
+	err := r.db(ctx).QueryRow(ctx, query, portfolioID).Scan(
+		&s.PortfolioID, &s.DrawdownPct, &s.DailyDropPct, &s.CooldownHours, &s.DrawdownAlertedAt, &s.DailyDropAlertedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *drawdownRepository) UpsertSettings(ctx context.Context, settings *models.DrawdownAlertSettings) error {
+	query := `
+		INSERT INTO portfolio_drawdown_alerts (portfolio_id, drawdown_pct, daily_drop_pct, cooldown_hours, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (portfolio_id) DO UPDATE SET
+			drawdown_pct = EXCLUDED.drawdown_pct,
+			daily_drop_pct = EXCLUDED.daily_drop_pct,
+			cooldown_hours = EXCLUDED.cooldown_hours,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		settings.PortfolioID, settings.DrawdownPct, settings.DailyDropPct, settings.CooldownHours, time.Now(),
+	)
+	return err
+}
+
+func (r *drawdownRepository) SetAlerted(ctx context.Context, portfolioID uuid.UUID, kind models.DrawdownAlertKind, at time.Time) error {
+	query := `UPDATE portfolio_drawdown_alerts SET drawdown_alerted_at = $2 WHERE portfolio_id = $1`
+	if kind == models.DrawdownDailyDrop {
+		query = `UPDATE portfolio_drawdown_alerts SET daily_drop_alerted_at = $2 WHERE portfolio_id = $1`
+	}
+	_, err := r.db(ctx).Exec(ctx, query, portfolioID, at)
+	return err
+}
+
+func (r *drawdownRepository) GetWatchedPortfolioIDs(ctx context.Context) ([]uuid.UUID, error) {
+	query := `
+		SELECT p.id
+		FROM portfolios p
+		JOIN portfolio_drawdown_alerts a ON a.portfolio_id = p.id
+		WHERE p.is_active = true AND (a.drawdown_pct IS NOT NULL OR a.daily_drop_pct IS NOT NULL)
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *drawdownRepository) UpsertSnapshot(ctx context.Context, snapshot *models.PortfolioValueSnapshot) error {
+	query := `
+		INSERT INTO portfolio_value_snapshots (portfolio_id, date, value, high, currency, updated_at)
+		VALUES ($1, $2, $3, $3, $4, $5)
+		ON CONFLICT (portfolio_id, date) DO UPDATE SET
+			value = EXCLUDED.value,
+			high = CASE WHEN portfolio_value_snapshots.currency = EXCLUDED.currency
+				THEN GREATEST(portfolio_value_snapshots.high, EXCLUDED.value)
+				ELSE EXCLUDED.value END,
+			currency = EXCLUDED.currency,
+			updated_at = EXCLUDED.updated_at
+		RETURNING high
+	`
+
+	snapshot.UpdatedAt = time.Now()
+
+	var high decimal.Decimal
+	err := r.db(ctx).QueryRow(ctx, query,
+		snapshot.PortfolioID, snapshot.Date, snapshot.Value, snapshot.Currency, snapshot.UpdatedAt,
+	).Scan(&high)
+	if err != nil {
+		return err
+	}
+	snapshot.High = high
+	return nil
+}
+
+func (r *drawdownRepository) GetSnapshots(ctx context.Context, portfolioID uuid.UUID, from time.Time) ([]models.PortfolioValueSnapshot, error) {
+	query := `
+		SELECT portfolio_id, date, value, high, currency, updated_at
+		FROM portfolio_value_snapshots
+		WHERE portfolio_id = $1 AND date >= $2
+		ORDER BY date
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, portfolioID, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []models.PortfolioValueSnapshot
+	for rows.Next() {
+		var s models.PortfolioValueSnapshot
+		if err := rows.Scan(&s.PortfolioID, &s.Date, &s.Value, &s.High, &s.Currency, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+func (r *drawdownRepository) ReplaceSnapshots(ctx context.Context, portfolioID uuid.UUID, snapshots []models.PortfolioValueSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO portfolio_value_snapshots (portfolio_id, date, value, high, currency, updated_at)
+		SELECT $1, s.date, s.value, s.value, s.currency, $5
+		FROM unnest($2::date[], $3::numeric[], $4::varchar[]) AS s(date, value, currency)
+		ON CONFLICT (portfolio_id, date) DO UPDATE SET
+			value = EXCLUDED.value,
+			high = EXCLUDED.high,
+			currency = EXCLUDED.currency,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	dates := make([]time.Time, len(snapshots))
+	values := make([]string, len(snapshots))
+	currencies := make([]string, len(snapshots))
+	for i, snapshot := range snapshots {
+		dates[i], values[i], currencies[i] = snapshot.Date, snapshot.Value.String(), snapshot.Currency
+	}
+	_, err := r.db(ctx).Exec(ctx, query, portfolioID, dates, values, currencies, time.Now())
+	return err
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+type EnvelopeRepository interface {
+	Create(ctx context.Context, envelope *models.Envelope) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Envelope, error)
+	// GetByUserID вместе с архивными - их распределения тоже участвуют в расчете нераспределенного
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Envelope, error)
+	GetByCategory(ctx context.Context, userID, categoryID uuid.UUID) (*models.Envelope, error)
+	SetArchived(ctx context.Context, id uuid.UUID, archived bool) error
+
+	AddEntry(ctx context.Context, entry *models.EnvelopeEntry) error
+	GetEntries(ctx context.Context, envelopeID uuid.UUID, limit int) ([]models.EnvelopeEntry, error)
+	// GetAllocatedSums сумма записей журнала по конвертам за месяцы [from, to]
+	GetAllocatedSums(ctx context.Context, userID uuid.UUID, from, to time.Time) (map[uuid.UUID]decimal.Decimal, error)
+	// GetLatestMonth самый поздний месяц в журнале пользователя; нулевое время - записей нет
+	GetLatestMonth(ctx context.Context, userID uuid.UUID) (time.Time, error)
+	// LockUser блокирует конверты пользователя до конца транзакции: проверка остатка и запись идут по очереди
+	LockUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type envelopeRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewEnvelopeRepository(pool *pgxpool.Pool) EnvelopeRepository {
+	return &envelopeRepository{pool: pool}
+}
+
+func (r *envelopeRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *envelopeRepository) Create(ctx context.Context, envelope *models.Envelope) error {
+	query := `
+		INSERT INTO envelopes (id, user_id, category_id, name, is_archived, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	envelope.ID = uuid.New()
+	now := time.Now()
+	envelope.CreatedAt = now
+	envelope.UpdatedAt = now
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		envelope.ID, envelope.UserID, envelope.CategoryID, envelope.Name, envelope.IsArchived,
+		envelope.CreatedAt, envelope.UpdatedAt,
+	)
+	return err
+}
+
+func (r *envelopeRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Envelope, error) {
+	query := `
+		SELECT id, user_id, category_id, name, is_archived, created_at, updated_at
+		FROM envelopes
+		WHERE id = $1
+	`
+
+	var e models.Envelope
+	err := r.db(ctx).QueryRow(ctx, query, id).Scan(
+		&e.ID, &e.UserID, &e.CategoryID, &e.Name, &e.IsArchived, &e.CreatedAt, &e.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *envelopeRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Envelope, error) {
+	query := `
+		SELECT id, user_id, category_id, name, is_archived, created_at, updated_at
+		FROM envelopes
+		WHERE user_id = $1
+		ORDER BY name
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var envelopes []models.Envelope
+	for rows.Next() {
+		var e models.Envelope
+		if err := rows.Scan(&e.ID, &e.UserID, &e.CategoryID, &e.Name, &e.IsArchived, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, err
+		}
+		envelopes = append(envelopes, e)
+	}
+	return envelopes, rows.Err()
+}
+
+func (r *envelopeRepository) GetByCategory(ctx context.Context, userID, categoryID uuid.UUID) (*models.Envelope, error) {
+	query := `
+		SELECT id, user_id, category_id, name, is_archived, created_at, updated_at
+		FROM envelopes
+		WHERE user_id = $1 AND category_id = $2
+	`
+
+	var e models.Envelope
+	err := r.db(ctx).QueryRow(ctx, query, userID, categoryID).Scan(
+		&e.ID, &e.UserID, &e.CategoryID, &e.Name, &e.IsArchived, &e.CreatedAt, &e.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *envelopeRepository) SetArchived(ctx context.Context, id uuid.UUID, archived bool) error {
+	query := `UPDATE envelopes SET is_archived = $2, updated_at = NOW() WHERE id = $1`
+	_, err := r.db(ctx).Exec(ctx, query, id, archived)
+	return err
+}
+
+func (r *envelopeRepository) AddEntry(ctx context.Context, entry *models.EnvelopeEntry) error {
+	query := `
+		INSERT INTO envelope_entries (id, user_id, envelope_id, related_envelope_id, type, amount, month, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	entry.ID = uuid.New()
+	entry.CreatedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		entry.ID, entry.UserID, entry.EnvelopeID, entry.RelatedEnvelopeID, entry.Type,
+		entry.Amount, entry.Month, entry.Note, entry.CreatedAt,
+	)
+	return err
+}
+
+func (r *envelopeRepository) GetEntries(ctx context.Context, envelopeID uuid.UUID, limit int) ([]models.EnvelopeEntry, error) {
+	query := `
+		SELECT id, user_id, envelope_id, related_envelope_id, type, amount, month, COALESCE(note, ''), created_at
+		FROM envelope_entries
+		WHERE envelope_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, envelopeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.EnvelopeEntry
+	for rows.Next() {
+		var e models.EnvelopeEntry
+		if err := rows.Scan(
+			&e.ID, &e.UserID, &e.EnvelopeID, &e.RelatedEnvelopeID, &e.Type, &e.Amount, &e.Month, &e.Note, &e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (r *envelopeRepository) GetAllocatedSums(ctx context.Context, userID uuid.UUID, from, to time.Time) (map[uuid.UUID]decimal.Decimal, error) {
+	query := `
+		SELECT envelope_id, SUM(amount)
+		FROM envelope_entries
+		WHERE user_id = $1 AND month >= $2 AND month <= $3
+		GROUP BY envelope_id
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sums := make(map[uuid.UUID]decimal.Decimal)
+	for rows.Next() {
+		var id uuid.UUID
+		var sum decimal.Decimal
+		if err := rows.Scan(&id, &sum); err != nil {
+			return nil, err
+		}
+		sums[id] = sum
+	}
+	return sums, rows.Err()
+}
+
+func (r *envelopeRepository) GetLatestMonth(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	var latest *time.Time
+	err := r.db(ctx).QueryRow(ctx, `SELECT MAX(month) FROM envelope_entries WHERE user_id = $1`, userID).Scan(&latest)
+	if err != nil || latest == nil {
+		return time.Time{}, err
+	}
+	return *latest, nil
+}
+
+func (r *envelopeRepository) LockUser(ctx context.Context, userID uuid.UUID) error {
+	rows, err := r.db(ctx).Query(ctx, `SELECT id FROM envelopes WHERE user_id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return err
+	}
+	rows.Close()
+	return rows.Err()
+}
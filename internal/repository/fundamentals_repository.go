@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type FundamentalsRepository interface {
+	// Get nil без ошибки - мультипликаторы еще не запрашивались
+	Get(ctx context.Context, securityID uuid.UUID) (*models.SecurityFundamentals, error)
+	Upsert(ctx context.Context, securityID uuid.UUID, f *models.SecurityFundamentals) error
+	// ReplaceDividends заменяет историю дивидендов бумаги целиком
+	ReplaceDividends(ctx context.Context, securityID uuid.UUID, dividends []models.Dividend) error
+	// GetDividends последние выплаты, от новых к старым
+	GetDividends(ctx context.Context, securityID uuid.UUID, limit int) ([]models.Dividend, error)
+	// GetStale биржевые акции, фонды и криптовалюты без мультипликаторов или с обновленными раньше before:
+	// сначала бумаги из портфелей, затем самые торгуемые
+	GetStale(ctx context.Context, before time.Time, limit int) ([]models.Security, error)
+}
+
+type fundamentalsRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewFundamentalsRepository(pool *pgxpool.Pool) FundamentalsRepository {
+	return &fundamentalsRepository{pool: pool}
+}
+
+func (r *fundamentalsRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *fundamentalsRepository) Get(ctx context.Context, securityID uuid.UUID) (*models.SecurityFundamentals, error) {
+	query := `
+		SELECT market_cap, pe_ratio, eps, dividend_per_share, updated_at
+		FROM security_fundamentals
+		WHERE security_id = $1
+	`
+
+	var f models.SecurityFundamentals
+	err := r.db(ctx).QueryRow(ctx, query, securityID).Scan(&f.MarketCap, &f.PERatio, &f.EPS, &f.DividendPerShare, &f.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *fundamentalsRepository) Upsert(ctx context.Context, securityID uuid.UUID, f *models.SecurityFundamentals) error {
+	query := `
+		INSERT INTO security_fundamentals (security_id, market_cap, pe_ratio, eps, dividend_per_share, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (security_id) DO UPDATE SET
+			market_cap = EXCLUDED.market_cap,
+			pe_ratio = EXCLUDED.pe_ratio,
+			eps = EXCLUDED.eps,
+			dividend_per_share = EXCLUDED.dividend_per_share,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	f.UpdatedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, query, securityID, f.MarketCap, f.PERatio, f.EPS, f.DividendPerShare, f.UpdatedAt)
+	return err
+}
+
+func (r *fundamentalsRepository) ReplaceDividends(ctx context.Context, securityID uuid.UUID, dividends []models.Dividend) error {
+	if _, err := r.db(ctx).Exec(ctx, `DELETE FROM security_dividends WHERE security_id = $1`, securityID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO security_dividends (security_id, ex_date, payment_date, amount, currency, dividend_type)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (security_id, ex_date, dividend_type) DO UPDATE SET amount = EXCLUDED.amount
+	`
+	for _, d := range dividends {
+		var paymentDate *time.Time
+		if !d.PaymentDate.IsZero() {
+			paymentDate = &d.PaymentDate
+		}
+		dividendType := d.DividendType
+		if dividendType == "" {
+			dividendType = "regular"
+		}
+		if _, err := r.db(ctx).Exec(ctx, query, securityID, d.ExDate, paymentDate, d.Amount, d.Currency, dividendType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fundamentalsRepository) GetDividends(ctx context.Context, securityID uuid.UUID, limit int) ([]models.Dividend, error) {
+	query := `
+		SELECT ex_date, payment_date, amount, currency, dividend_type
+		FROM security_dividends
+		WHERE security_id = $1
+		ORDER BY ex_date DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, securityID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dividends []models.Dividend
+	for rows.Next() {
+		d := models.Dividend{SecurityID: securityID}
+		var paymentDate *time.Time
+		if err := rows.Scan(&d.ExDate, &paymentDate, &d.Amount, &d.Currency, &d.DividendType); err != nil {
+			return nil, err
+		}
+		d.RecordDate = d.ExDate
+		if paymentDate != nil {
+			d.PaymentDate = *paymentDate
+		}
+		dividends = append(dividends, d)
+	}
+	return dividends, rows.Err()
+}
+
+func (r *fundamentalsRepository) GetStale(ctx context.Context, before time.Time, limit int) ([]models.Security, error) {
+	query := `
+		SELECT s.id, s.ticker, s.type, s.exchange, s.currency, s.last_price
+		FROM securities s
+		LEFT JOIN security_fundamentals f ON f.security_id = s.id
+		WHERE s.is_active = true AND s.owner_id IS NULL AND s.type IN ('stock', 'etf', 'crypto')
+		  AND (f.updated_at IS NULL OR f.updated_at < $1)
+		ORDER BY EXISTS (SELECT 1 FROM holdings h WHERE h.security_id = s.id) DESC, s.volume DESC, s.ticker
+		LIMIT $2
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var securities []models.Security
+	for rows.Next() {
+		var s models.Security
+		if err := rows.Scan(&s.ID, &s.Ticker, &s.Type, &s.Exchange, &s.Currency, &s.LastPrice); err != nil {
+			return nil, err
+		}
+		securities = append(securities, s)
+	}
+	return securities, rows.Err()
+}
@@ -16,6 +16,10 @@ type GoalRepository interface {
 	GetByUserID(ctx context.Context, userID uuid.UUID, status *models.GoalStatus) ([]models.Goal, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.GoalUpdate) error
 	UpdateAmount(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error
+	// SetAmount выставляет текущую сумму (для целей, привязанных к портфелю)
+	SetAmount(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error
+	// GetPortfolioLinked возвращает активные цели, привязанные к портфелям
+	GetPortfolioLinked(ctx context.Context) ([]models.Goal, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	AddContribution(ctx context.Context, goalID uuid.UUID, contribution *models.GoalContribution) error
 	GetContributions(ctx context.Context, goalID uuid.UUID) ([]models.GoalContribution, error)
@@ -29,10 +33,15 @@ func NewGoalRepository(pool *pgxpool.Pool) GoalRepository {
 	return &goalRepository{pool: pool}
 }
 
+// db возвращает транзакцию из контекста или pool
+func (r *goalRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
 func (r *goalRepository) Create(ctx context.Context, goal *models.Goal) error {
 	query := `
-		INSERT INTO goals (id, user_id, account_id, name, description, target_amount, current_amount, currency, target_date, icon, color, status, priority, auto_contribute, contribute_amount, contribute_freq, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		INSERT INTO goals (id, user_id, account_id, name, description, target_amount, current_amount, currency, target_date, icon, color, status, priority, auto_contribute, contribute_amount, contribute_freq, portfolio_id, expected_return, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 	`
 
 	if goal.ID == uuid.Nil {
@@ -43,11 +52,12 @@ func (r *goalRepository) Create(ctx context.Context, goal *models.Goal) error {
 	goal.UpdatedAt = now
 	goal.Status = models.GoalStatusActive
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err := r.db(ctx).Exec(ctx, query,
 		goal.ID, goal.UserID, goal.AccountID, goal.Name, goal.Description,
 		goal.TargetAmount, goal.CurrentAmount, goal.Currency, goal.TargetDate,
 		goal.Icon, goal.Color, goal.Status, goal.Priority,
 		goal.AutoContribute, goal.ContributeAmount, goal.ContributeFreq,
+		goal.PortfolioID, goal.ExpectedReturn,
 		goal.CreatedAt, goal.UpdatedAt,
 	)
 	return err
@@ -55,17 +65,18 @@ func (r *goalRepository) Create(ctx context.Context, goal *models.Goal) error {
 
 func (r *goalRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Goal, error) {
 	query := `
-		SELECT id, user_id, account_id, name, description, target_amount, current_amount, currency, target_date, icon, color, status, priority, auto_contribute, contribute_amount, contribute_freq, created_at, updated_at, completed_at
+		SELECT id, user_id, account_id, name, description, target_amount, current_amount, currency, target_date, icon, color, status, priority, auto_contribute, contribute_amount, contribute_freq, portfolio_id, expected_return, created_at, updated_at, completed_at
 		FROM goals
 		WHERE id = $1
 	`
 
 	var goal models.Goal
-	err := r.pool.QueryRow(ctx, query, id).Scan(
+	err := r.db(ctx).QueryRow(ctx, query, id).Scan(
 		&goal.ID, &goal.UserID, &goal.AccountID, &goal.Name, &goal.Description,
 		&goal.TargetAmount, &goal.CurrentAmount, &goal.Currency, &goal.TargetDate,
 		&goal.Icon, &goal.Color, &goal.Status, &goal.Priority,
 		&goal.AutoContribute, &goal.ContributeAmount, &goal.ContributeFreq,
+		&goal.PortfolioID, &goal.ExpectedReturn,
 		&goal.CreatedAt, &goal.UpdatedAt, &goal.CompletedAt,
 	)
 	if err != nil {
@@ -96,7 +107,7 @@ func (r *goalRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Goa
 
 func (r *goalRepository) GetByUserID(ctx context.Context, userID uuid.UUID, status *models.GoalStatus) ([]models.Goal, error) {
 	query := `
-		SELECT id, user_id, account_id, name, description, target_amount, current_amount, currency, target_date, icon, color, status, priority, auto_contribute, contribute_amount, contribute_freq, created_at, updated_at, completed_at
+		SELECT id, user_id, account_id, name, description, target_amount, current_amount, currency, target_date, icon, color, status, priority, auto_contribute, contribute_amount, contribute_freq, portfolio_id, expected_return, created_at, updated_at, completed_at
 		FROM goals
 		WHERE user_id = $1
 	`
@@ -108,7 +119,7 @@ func (r *goalRepository) GetByUserID(ctx context.Context, userID uuid.UUID, stat
 	}
 	query += " ORDER BY priority DESC, created_at DESC"
 
-	rows, err := r.pool.Query(ctx, query, args...)
+	rows, err := r.db(ctx).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -122,6 +133,7 @@ func (r *goalRepository) GetByUserID(ctx context.Context, userID uuid.UUID, stat
 			&goal.TargetAmount, &goal.CurrentAmount, &goal.Currency, &goal.TargetDate,
 			&goal.Icon, &goal.Color, &goal.Status, &goal.Priority,
 			&goal.AutoContribute, &goal.ContributeAmount, &goal.ContributeFreq,
+			&goal.PortfolioID, &goal.ExpectedReturn,
 			&goal.CreatedAt, &goal.UpdatedAt, &goal.CompletedAt,
 		)
 		if err != nil {
@@ -153,15 +165,18 @@ func (r *goalRepository) Update(ctx context.Context, id uuid.UUID, update *model
 			auto_contribute = COALESCE($12, auto_contribute),
 			contribute_amount = COALESCE($13, contribute_amount),
 			contribute_freq = COALESCE($14, contribute_freq),
-			updated_at = $15
+			portfolio_id = CASE WHEN $16 THEN NULL ELSE COALESCE($15, portfolio_id) END,
+			expected_return = COALESCE($17, expected_return),
+			updated_at = $18
 		WHERE id = $1
 	`
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err := r.db(ctx).Exec(ctx, query,
 		id, update.AccountID, update.Name, update.Description,
 		update.TargetAmount, update.CurrentAmount, update.TargetDate,
 		update.Icon, update.Color, update.Status, update.Priority,
 		update.AutoContribute, update.ContributeAmount, update.ContributeFreq,
+		update.PortfolioID, update.UnlinkPortfolio, update.ExpectedReturn,
 		time.Now(),
 	)
 	return err
@@ -174,7 +189,7 @@ func (r *goalRepository) UpdateAmount(ctx context.Context, id uuid.UUID, amount
 			updated_at = $3
 		WHERE id = $1
 	`
-	_, err := r.pool.Exec(ctx, query, id, amount, time.Now())
+	_, err := r.db(ctx).Exec(ctx, query, id, amount, time.Now())
 	if err != nil {
 		return err
 	}
@@ -185,13 +200,67 @@ func (r *goalRepository) UpdateAmount(ctx context.Context, id uuid.UUID, amount
 			completed_at = $2 
 		WHERE id = $1 AND current_amount >= target_amount AND status = 'active'
 	`
-	_, err = r.pool.Exec(ctx, checkQuery, id, time.Now())
+	_, err = r.db(ctx).Exec(ctx, checkQuery, id, time.Now())
 	return err
 }
 
+func (r *goalRepository) SetAmount(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	query := `
+		UPDATE goals SET
+			current_amount = $2,
+			updated_at = $3
+		WHERE id = $1
+	`
+	_, err := r.db(ctx).Exec(ctx, query, id, amount, time.Now())
+	if err != nil {
+		return err
+	}
+
+	checkQuery := `
+		UPDATE goals SET
+			status = 'completed',
+			completed_at = $2
+		WHERE id = $1 AND current_amount >= target_amount AND status = 'active'
+	`
+	_, err = r.db(ctx).Exec(ctx, checkQuery, id, time.Now())
+	return err
+}
+
+func (r *goalRepository) GetPortfolioLinked(ctx context.Context) ([]models.Goal, error) {
+	query := `
+		SELECT id, user_id, account_id, name, description, target_amount, current_amount, currency, target_date, icon, color, status, priority, auto_contribute, contribute_amount, contribute_freq, portfolio_id, expected_return, created_at, updated_at, completed_at
+		FROM goals
+		WHERE portfolio_id IS NOT NULL AND status = 'active'
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var goals []models.Goal
+	for rows.Next() {
+		var goal models.Goal
+		err := rows.Scan(
+			&goal.ID, &goal.UserID, &goal.AccountID, &goal.Name, &goal.Description,
+			&goal.TargetAmount, &goal.CurrentAmount, &goal.Currency, &goal.TargetDate,
+			&goal.Icon, &goal.Color, &goal.Status, &goal.Priority,
+			&goal.AutoContribute, &goal.ContributeAmount, &goal.ContributeFreq,
+			&goal.PortfolioID, &goal.ExpectedReturn,
+			&goal.CreatedAt, &goal.UpdatedAt, &goal.CompletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, goal)
+	}
+	return goals, rows.Err()
+}
+
 func (r *goalRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM goals WHERE id = $1`
-	_, err := r.pool.Exec(ctx, query, id)
+	_, err := r.db(ctx).Exec(ctx, query, id)
 	return err
 }
 
@@ -210,7 +279,7 @@ func (r *goalRepository) AddContribution(ctx context.Context, goalID uuid.UUID,
 		contribution.Date = time.Now()
 	}
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err := r.db(ctx).Exec(ctx, query,
 		contribution.ID, contribution.GoalID, contribution.Amount,
 		contribution.Date, contribution.Notes, contribution.CreatedAt,
 	)
@@ -229,7 +298,7 @@ func (r *goalRepository) GetContributions(ctx context.Context, goalID uuid.UUID)
 		ORDER BY date DESC
 	`
 
-	rows, err := r.pool.Query(ctx, query, goalID)
+	rows, err := r.db(ctx).Query(ctx, query, goalID)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type HealthSnapshotRepository interface {
+	// Upsert сохраняет снимок месяца, заменяя прежний снимок того же месяца
+	Upsert(ctx context.Context, snapshot *models.FinancialHealthSnapshot) error
+	// GetByUserID снимки начиная с месяца from, от старых к новым
+	GetByUserID(ctx context.Context, userID uuid.UUID, from time.Time) ([]models.FinancialHealthSnapshot, error)
+	// GetStaleUserIDs пользователи, у которых снимка за месяц нет или он обновлялся раньше staleBefore
+	GetStaleUserIDs(ctx context.Context, month, staleBefore time.Time) ([]uuid.UUID, error)
+}
+
+type healthSnapshotRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewHealthSnapshotRepository(pool *pgxpool.Pool) HealthSnapshotRepository {
+	return &healthSnapshotRepository{pool: pool}
+}
+
+func (r *healthSnapshotRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const healthSnapshotColumns = `id, user_id, month, overall_score, grade, savings_score, budget_score, debt_score, emergency_fund_score,
+	savings_rate, debt_to_income_ratio, emergency_fund_months, fixed_cost_months, fixed_cost_ratio, discretionary_trend, created_at, updated_at`
+
+func (r *healthSnapshotRepository) Upsert(ctx context.Context, snapshot *models.FinancialHealthSnapshot) error {
+	query := `
+		INSERT INTO financial_health_snapshots (` + healthSnapshotColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		ON CONFLICT (user_id, month) DO UPDATE SET
+			overall_score = EXCLUDED.overall_score,
+			grade = EXCLUDED.grade,
+			savings_score = EXCLUDED.savings_score,
+			budget_score = EXCLUDED.budget_score,
+			debt_score = EXCLUDED.debt_score,
+			emergency_fund_score = EXCLUDED.emergency_fund_score,
+			savings_rate = EXCLUDED.savings_rate,
+			debt_to_income_ratio = EXCLUDED.debt_to_income_ratio,
+			emergency_fund_months = EXCLUDED.emergency_fund_months,
+			fixed_cost_months = EXCLUDED.fixed_cost_months,
+			fixed_cost_ratio = EXCLUDED.fixed_cost_ratio,
+			discretionary_trend = EXCLUDED.discretionary_trend,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	if snapshot.ID == uuid.Nil {
+		snapshot.ID = uuid.New()
+	}
+	now := time.Now()
+	snapshot.CreatedAt = now
+	snapshot.UpdatedAt = now
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		snapshot.ID, snapshot.UserID, snapshot.Month,
+		snapshot.OverallScore, snapshot.Grade,
+		snapshot.SavingsScore, snapshot.BudgetScore, snapshot.DebtScore, snapshot.EmergencyFundScore,
+		snapshot.SavingsRate, snapshot.DebtToIncomeRatio, snapshot.EmergencyFundMonths,
+		snapshot.FixedCostMonths, snapshot.FixedCostRatio, snapshot.DiscretionaryTrend,
+		snapshot.CreatedAt, snapshot.UpdatedAt,
+	)
+	return err
+}
+
+func (r *healthSnapshotRepository) GetByUserID(ctx context.Context, userID uuid.UUID, from time.Time) ([]models.FinancialHealthSnapshot, error) {
+	query := `SELECT ` + healthSnapshotColumns + ` FROM financial_health_snapshots WHERE user_id = $1 AND month >= $2 ORDER BY month`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []models.FinancialHealthSnapshot
+	for rows.Next() {
+		var h models.FinancialHealthSnapshot
+		err := rows.Scan(
+			&h.ID, &h.UserID, &h.Month, &h.OverallScore, &h.Grade,
+			&h.SavingsScore, &h.BudgetScore, &h.DebtScore, &h.EmergencyFundScore,
+			&h.SavingsRate, &h.DebtToIncomeRatio, &h.EmergencyFundMonths,
+			&h.FixedCostMonths, &h.FixedCostRatio, &h.DiscretionaryTrend,
+			&h.CreatedAt, &h.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, h)
+	}
+	return snapshots, rows.Err()
+}
+
+func (r *healthSnapshotRepository) GetStaleUserIDs(ctx context.Context, month, staleBefore time.Time) ([]uuid.UUID, error) {
+	query := `
+		SELECT u.id
+		FROM users u
+		LEFT JOIN financial_health_snapshots h ON h.user_id = u.id AND h.month = $1
+		WHERE u.deleted_at IS NULL AND (h.id IS NULL OR h.updated_at < $2)
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, month, staleBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
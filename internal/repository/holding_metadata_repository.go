@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type HoldingMetadataRepository interface {
+	Get(ctx context.Context, portfolioID, securityID uuid.UUID) (*models.HoldingMetadata, error)
+	GetByPortfolioID(ctx context.Context, portfolioID uuid.UUID) ([]models.HoldingMetadata, error)
+	Upsert(ctx context.Context, metadata *models.HoldingMetadata) error
+	Delete(ctx context.Context, portfolioID, securityID uuid.UUID) error
+}
+
+type holdingMetadataRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewHoldingMetadataRepository(pool *pgxpool.Pool) HoldingMetadataRepository {
+	return &holdingMetadataRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *holdingMetadataRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const holdingMetadataColumns = `portfolio_id, security_id, notes, target_price, tags, updated_at`
+
+func scanHoldingMetadata(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.HoldingMetadata, error) {
+	m := &models.HoldingMetadata{}
+	err := row.Scan(&m.PortfolioID, &m.SecurityID, &m.Notes, &m.TargetPrice, &m.Tags, &m.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *holdingMetadataRepository) Get(ctx context.Context, portfolioID, securityID uuid.UUID) (*models.HoldingMetadata, error) {
+	query := `SELECT ` + holdingMetadataColumns + ` FROM holding_metadata WHERE portfolio_id = $1 AND security_id = $2`
+	return scanHoldingMetadata(r.db(ctx).QueryRow(ctx, query, portfolioID, securityID))
+}
+
+func (r *holdingMetadataRepository) GetByPortfolioID(ctx context.Context, portfolioID uuid.UUID) ([]models.HoldingMetadata, error) {
+	query := `SELECT ` + holdingMetadataColumns + ` FROM holding_metadata WHERE portfolio_id = $1`
+
+	rows, err := r.db(ctx).Query(ctx, query, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.HoldingMetadata
+	for rows.Next() {
+		m, err := scanHoldingMetadata(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *m)
+	}
+	return items, rows.Err()
+}
+
+func (r *holdingMetadataRepository) Upsert(ctx context.Context, m *models.HoldingMetadata) error {
+	query := `
+		INSERT INTO holding_metadata (portfolio_id, security_id, notes, target_price, tags, updated_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		ON CONFLICT (portfolio_id, security_id) DO UPDATE
+		SET notes = EXCLUDED.notes, target_price = EXCLUDED.target_price, tags = EXCLUDED.tags, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`
+	return r.db(ctx).QueryRow(ctx, query, m.PortfolioID, m.SecurityID, m.Notes, m.TargetPrice, m.Tags).Scan(&m.UpdatedAt)
+}
+
+func (r *holdingMetadataRepository) Delete(ctx context.Context, portfolioID, securityID uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM holding_metadata WHERE portfolio_id = $1 AND security_id = $2`, portfolioID, securityID)
+	return err
+}
@@ -14,10 +14,16 @@ type HoldingRepository interface {
 	Create(ctx context.Context, holding *models.Holding) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Holding, error)
 	GetByPortfolioID(ctx context.Context, portfolioID uuid.UUID) ([]models.Holding, error)
+	// GetByUserID позиции всех портфелей пользователя одним запросом
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Holding, error)
 	GetByPortfolioAndSecurity(ctx context.Context, portfolioID, securityID uuid.UUID) (*models.Holding, error)
 	Update(ctx context.Context, id uuid.UUID, quantity, avgPrice, totalCost decimal.Decimal) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	DeleteIfZero(ctx context.Context, portfolioID, securityID uuid.UUID) error
+	// GetExpiredDerivatives открытые позиции всех портфелей по контрактам, истекшим до указанной даты
+	GetExpiredDerivatives(ctx context.Context, before time.Time) ([]models.Holding, error)
+	// GetBonds открытые позиции всех портфелей по облигациям
+	GetBonds(ctx context.Context) ([]models.Holding, error)
 }
 
 type holdingRepository struct {
@@ -58,45 +64,101 @@ func (r *holdingRepository) Create(ctx context.Context, holding *models.Holding)
 	return err
 }
 
-func (r *holdingRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Holding, error) {
-	query := `
-		SELECT h.id, h.portfolio_id, h.security_id, h.quantity, h.average_price, h.total_cost, h.created_at, h.updated_at,
-		       s.ticker, s.name, s.type, s.exchange, s.currency, s.last_price
-		FROM holdings h
-		JOIN securities s ON h.security_id = s.id
-		WHERE h.id = $1
-	`
+// позиция вместе с данными бумаги, нужными для оценки (цена, параметры контракта) и прогноза купонов
+const holdingWithSecurityColumns = `h.id, h.portfolio_id, h.security_id, h.quantity, h.average_price, h.total_cost, h.created_at, h.updated_at,
+		       s.ticker, s.name, s.type, s.exchange, s.currency, s.last_price, s.price_change, s.price_change_percent,
+		       COALESCE(s.underlying, ''), s.expiry_date, s.strike, s.contract_multiplier, s.initial_margin, s.updated_at,
+		       s.accrued_interest, s.face_value, s.coupon_rate, s.coupon_freq, s.maturity_date`
 
+func scanHoldingWithSecurity(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.Holding, error) {
 	var h models.Holding
 	var security models.Security
-	err := r.db(ctx).QueryRow(ctx, query, id).Scan(
+	err := row.Scan(
 		&h.ID, &h.PortfolioID, &h.SecurityID,
 		&h.Quantity, &h.AveragePrice, &h.TotalCost,
 		&h.CreatedAt, &h.UpdatedAt,
 		&security.Ticker, &security.Name, &security.Type,
 		&security.Exchange, &security.Currency, &security.LastPrice,
+		&security.PriceChange, &security.PriceChangePercent,
+		&security.Underlying, &security.ExpiryDate, &security.Strike,
+		&security.ContractMultiplier, &security.InitialMargin, &security.UpdatedAt,
+		&security.AccruedInterest, &security.FaceValue, &security.CouponRate,
+		&security.CouponFreq, &security.MaturityDate,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	security.ID = h.SecurityID
 	h.Security = &security
 	h.CalculateValues()
-
 	return &h, nil
 }
 
+func (r *holdingRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Holding, error) {
+	query := `
+		SELECT ` + holdingWithSecurityColumns + `
+		FROM holdings h
+		JOIN securities s ON h.security_id = s.id
+		WHERE h.id = $1
+	`
+
+	return scanHoldingWithSecurity(r.db(ctx).QueryRow(ctx, query, id))
+}
+
 func (r *holdingRepository) GetByPortfolioID(ctx context.Context, portfolioID uuid.UUID) ([]models.Holding, error) {
 	query := `
-		SELECT h.id, h.portfolio_id, h.security_id, h.quantity, h.average_price, h.total_cost, h.created_at, h.updated_at,
-		       s.ticker, s.name, s.type, s.exchange, s.currency, s.last_price
+		SELECT ` + holdingWithSecurityColumns + `
 		FROM holdings h
 		JOIN securities s ON h.security_id = s.id
 		WHERE h.portfolio_id = $1
 		ORDER BY h.total_cost DESC
 	`
 
-	rows, err := r.db(ctx).Query(ctx, query, portfolioID)
+	return r.queryHoldings(ctx, query, portfolioID)
+}
+
+func (r *holdingRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Holding, error) {
+	query := `
+		SELECT ` + holdingWithSecurityColumns + `
+		FROM holdings h
+		JOIN securities s ON h.security_id = s.id
+		JOIN portfolios p ON h.portfolio_id = p.id
+		WHERE p.user_id = $1
+		ORDER BY h.portfolio_id, h.total_cost DESC
+	`
+
+	return r.queryHoldings(ctx, query, userID)
+}
+
+func (r *holdingRepository) GetExpiredDerivatives(ctx context.Context, before time.Time) ([]models.Holding, error) {
+	query := `
+		SELECT ` + holdingWithSecurityColumns + `
+		FROM holdings h
+		JOIN securities s ON h.security_id = s.id
+		WHERE s.type = 'derivative' AND s.expiry_date < $1 AND h.quantity > 0
+		ORDER BY s.expiry_date
+	`
+
+	return r.queryHoldings(ctx, query, before)
+}
+
+func (r *holdingRepository) GetBonds(ctx context.Context) ([]models.Holding, error) {
+	query := `
+		SELECT ` + holdingWithSecurityColumns + `
+		FROM holdings h
+		JOIN securities s ON h.security_id = s.id
+		WHERE s.type = 'bond' AND h.quantity > 0
+		ORDER BY s.id, h.portfolio_id
+	`
+
+	return r.queryHoldings(ctx, query)
+}
+
+func (r *holdingRepository) queryHoldings(ctx context.Context, query string, args ...interface{}) ([]models.Holding, error) {
+	rows, err := r.db(ctx).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -104,21 +166,11 @@ func (r *holdingRepository) GetByPortfolioID(ctx context.Context, portfolioID uu
 
 	var holdings []models.Holding
 	for rows.Next() {
-		var h models.Holding
-		var security models.Security
-		err := rows.Scan(
-			&h.ID, &h.PortfolioID, &h.SecurityID,
-			&h.Quantity, &h.AveragePrice, &h.TotalCost,
-			&h.CreatedAt, &h.UpdatedAt,
-			&security.Ticker, &security.Name, &security.Type,
-			&security.Exchange, &security.Currency, &security.LastPrice,
-		)
+		h, err := scanHoldingWithSecurity(rows)
 		if err != nil {
 			return nil, err
 		}
-		h.Security = &security
-		h.CalculateValues()
-		holdings = append(holdings, h)
+		holdings = append(holdings, *h)
 	}
 	return holdings, rows.Err()
 }
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IISRepository interface {
+	Create(ctx context.Context, flow *models.IISCashFlow) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.IISCashFlow, error)
+	// GetByPortfolioID все пополнения и выводы ИИС по дате
+	GetByPortfolioID(ctx context.Context, portfolioID uuid.UUID) ([]models.IISCashFlow, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type iisRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewIISRepository(pool *pgxpool.Pool) IISRepository {
+	return &iisRepository{pool: pool}
+}
+
+func (r *iisRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const iisCashFlowColumns = `id, portfolio_id, type, amount, date, COALESCE(notes, ''), created_at`
+
+func scanIISCashFlow(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.IISCashFlow, error) {
+	var f models.IISCashFlow
+	if err := row.Scan(&f.ID, &f.PortfolioID, &f.Type, &f.Amount, &f.Date, &f.Notes, &f.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *iisRepository) Create(ctx context.Context, flow *models.IISCashFlow) error {
+	query := `
+		INSERT INTO iis_cash_flows (id, portfolio_id, type, amount, date, notes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	if flow.ID == uuid.Nil {
+		flow.ID = uuid.New()
+	}
+	flow.CreatedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		flow.ID, flow.PortfolioID, flow.Type, flow.Amount, flow.Date, flow.Notes, flow.CreatedAt,
+	)
+	return err
+}
+
+func (r *iisRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.IISCashFlow, error) {
+	query := `SELECT ` + iisCashFlowColumns + ` FROM iis_cash_flows WHERE id = $1`
+	return scanIISCashFlow(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *iisRepository) GetByPortfolioID(ctx context.Context, portfolioID uuid.UUID) ([]models.IISCashFlow, error) {
+	query := `SELECT ` + iisCashFlowColumns + ` FROM iis_cash_flows WHERE portfolio_id = $1 ORDER BY date, created_at`
+
+	rows, err := r.db(ctx).Query(ctx, query, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flows []models.IISCashFlow
+	for rows.Next() {
+		f, err := scanIISCashFlow(rows)
+		if err != nil {
+			return nil, err
+		}
+		flows = append(flows, *f)
+	}
+	return flows, rows.Err()
+}
+
+func (r *iisRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM iis_cash_flows WHERE id = $1`, id)
+	return err
+}
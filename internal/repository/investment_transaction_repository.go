@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/models"
@@ -13,12 +14,40 @@ import (
 type InvestmentTransactionRepository interface {
 	Create(ctx context.Context, tx *models.InvestmentTransaction) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.InvestmentTransaction, error)
-	GetByPortfolioID(ctx context.Context, portfolioID uuid.UUID, limit, offset int) ([]models.InvestmentTransaction, error)
+	GetByFilter(ctx context.Context, portfolioID uuid.UUID, filter *models.InvestmentTransactionFilter) (*models.InvestmentTransactionList, error)
+	// GetPage страница по курсору в порядке (date, id) от новых к старым; сортировка, limit и offset из filter не учитываются
+	GetPage(ctx context.Context, portfolioID uuid.UUID, filter *models.InvestmentTransactionFilter, page models.CursorPage) ([]models.InvestmentTransaction, error)
 	GetBySecurityID(ctx context.Context, portfolioID, securityID uuid.UUID) ([]models.InvestmentTransaction, error)
 	GetByDateRange(ctx context.Context, portfolioID uuid.UUID, startDate, endDate time.Time) ([]models.InvestmentTransaction, error)
-	Delete(ctx context.Context, id uuid.UUID) error
+	// GetDeletedByID удаленная операция, которую можно восстановить
+	GetDeletedByID(ctx context.Context, id uuid.UUID) (*models.InvestmentTransaction, error)
+	// Delete помечает операцию удаленной; false - операции нет или она уже удалена
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+	// Restore снимает пометку об удалении; false - операция не удалена
+	Restore(ctx context.Context, id uuid.UUID) (bool, error)
+	// GetTotalDividends - дивидендоподобный доход за год (models.DividendIncomeTypes)
 	GetTotalDividends(ctx context.Context, portfolioID uuid.UUID, year int) (decimal.Decimal, error)
 	GetTotalCommissions(ctx context.Context, portfolioID uuid.UUID, year int) (decimal.Decimal, error)
+	// GetIncomeByCurrency дивиденды и купоны по всем портфелям пользователя за период, по валютам выплат
+	GetIncomeByCurrency(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) (map[string]decimal.Decimal, error)
+	// GetIncome все полученные дивиденды, купоны и возвраты номинала облигаций портфеля от старых к новым
+	GetIncome(ctx context.Context, portfolioID uuid.UUID) ([]models.InvestmentTransaction, error)
+	// GetExistingBrokerRefs какие из референсов брокера уже есть среди неудаленных операций портфеля
+	GetExistingBrokerRefs(ctx context.Context, portfolioID uuid.UUID, refs []string) (map[string]bool, error)
+	// GetPortfolioIDsBySecurity портфели, в которых есть неудаленные операции с бумагой
+	GetPortfolioIDsBySecurity(ctx context.Context, securityID uuid.UUID) ([]uuid.UUID, error)
+	// GetQueuedRebuilds позиции, поставленные в очередь на пересчет (например, миграцией после удаления дублей)
+	GetQueuedRebuilds(ctx context.Context, limit int) ([]HoldingRebuild, error)
+	DeleteQueuedRebuild(ctx context.Context, portfolioID, securityID uuid.UUID) error
+}
+
+// ErrDuplicateBrokerRef операция с таким broker_ref уже есть среди неудаленных операций портфеля
+var ErrDuplicateBrokerRef = errors.New("broker ref already exists in portfolio")
+
+// HoldingRebuild позиция, которую нужно провести заново по истории операций
+type HoldingRebuild struct {
+	PortfolioID uuid.UUID
+	SecurityID  uuid.UUID
 }
 
 type investmentTransactionRepository struct {
@@ -53,21 +82,32 @@ func (r *investmentTransactionRepository) Create(ctx context.Context, tx *models
 		tx.Quantity, tx.Price, tx.Amount, tx.Commission, tx.Currency,
 		tx.ExchangeRate, tx.Notes, tx.BrokerRef, tx.CreatedAt,
 	)
+	if isUniqueViolation(err, "idx_investment_transactions_broker_ref") {
+		return ErrDuplicateBrokerRef
+	}
 	return err
 }
 
 func (r *investmentTransactionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.InvestmentTransaction, error) {
+	return r.getByID(ctx, id, false)
+}
+
+func (r *investmentTransactionRepository) GetDeletedByID(ctx context.Context, id uuid.UUID) (*models.InvestmentTransaction, error) {
+	return r.getByID(ctx, id, true)
+}
+
+func (r *investmentTransactionRepository) getByID(ctx context.Context, id uuid.UUID, deleted bool) (*models.InvestmentTransaction, error) {
 	query := `
 		SELECT it.id, it.portfolio_id, it.security_id, it.type, it.date, it.quantity, it.price, it.amount, it.commission, it.currency, it.exchange_rate, it.notes, it.broker_ref, it.created_at,
 		       s.ticker, s.name, s.type as security_type
 		FROM investment_transactions it
 		JOIN securities s ON it.security_id = s.id
-		WHERE it.id = $1
+		WHERE it.id = $1 AND (it.deleted_at IS NOT NULL) = $2
 	`
 
 	var tx models.InvestmentTransaction
 	var security models.Security
-	err := r.db(ctx).QueryRow(ctx, query, id).Scan(
+	err := r.db(ctx).QueryRow(ctx, query, id, deleted).Scan(
 		&tx.ID, &tx.PortfolioID, &tx.SecurityID, &tx.Type, &tx.Date,
 		&tx.Quantity, &tx.Price, &tx.Amount, &tx.Commission, &tx.Currency,
 		&tx.ExchangeRate, &tx.Notes, &tx.BrokerRef, &tx.CreatedAt,
@@ -82,22 +122,87 @@ func (r *investmentTransactionRepository) GetByID(ctx context.Context, id uuid.U
 	return &tx, nil
 }
 
-func (r *investmentTransactionRepository) GetByPortfolioID(ctx context.Context, portfolioID uuid.UUID, limit, offset int) ([]models.InvestmentTransaction, error) {
+// поля, по которым можно сортировать операции портфеля
+var investmentTransactionSortColumns = sortColumns{
+	"date":     "it.date",
+	"amount":   "it.amount",
+	"quantity": "it.quantity",
+	"price":    "it.price",
+	"type":     "it.type",
+}
+
+func (r *investmentTransactionRepository) GetByFilter(ctx context.Context, portfolioID uuid.UUID, filter *models.InvestmentTransactionFilter) (*models.InvestmentTransactionList, error) {
 	query := `
 		SELECT it.id, it.portfolio_id, it.security_id, it.type, it.date, it.quantity, it.price, it.amount, it.commission, it.currency, it.exchange_rate, it.notes, it.broker_ref, it.created_at,
 		       s.ticker, s.name, s.type as security_type
 		FROM investment_transactions it
 		JOIN securities s ON it.security_id = s.id
-		WHERE it.portfolio_id = $1
-		ORDER BY it.date DESC, it.created_at DESC
-		LIMIT $2 OFFSET $3
+		WHERE it.portfolio_id = $1 AND it.deleted_at IS NULL
 	`
+	countQuery := `SELECT COUNT(*) FROM investment_transactions it WHERE it.portfolio_id = $1 AND it.deleted_at IS NULL`
+
+	qb := investmentFilterConditions(portfolioID, filter)
+
+	orderBy, err := investmentTransactionSortColumns.orderBy(filter.SortBy, filter.SortOrder, "date", "it.created_at DESC")
+	if err != nil {
+		return nil, err
+	}
 
+	var total int64
+	if err := r.db(ctx).QueryRow(ctx, countQuery+qb.and(), qb.params()...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
 	if limit <= 0 {
 		limit = 50
 	}
+	offset := filter.Offset
+	if filter.Page > 0 {
+		offset = (filter.Page - 1) * limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query+qb.and()+orderBy+qb.page(limit, offset), qb.params()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions, err := r.scanTransactions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if transactions == nil {
+		transactions = []models.InvestmentTransaction{}
+	}
+
+	return &models.InvestmentTransactionList{
+		Transactions: transactions,
+		Total:        total,
+		Page:         offset/limit + 1,
+		Limit:        limit,
+		TotalPages:   models.TotalPages(total, limit),
+	}, nil
+}
+
+func (r *investmentTransactionRepository) GetPage(ctx context.Context, portfolioID uuid.UUID, filter *models.InvestmentTransactionFilter, page models.CursorPage) ([]models.InvestmentTransaction, error) {
+	query := `
+		SELECT it.id, it.portfolio_id, it.security_id, it.type, it.date, it.quantity, it.price, it.amount, it.commission, it.currency, it.exchange_rate, it.notes, it.broker_ref, it.created_at,
+		       s.ticker, s.name, s.type as security_type
+		FROM investment_transactions it
+		JOIN securities s ON it.security_id = s.id
+		WHERE it.portfolio_id = $1 AND it.deleted_at IS NULL
+	`
+
+	qb := investmentFilterConditions(portfolioID, filter)
+	if page.After != nil {
+		qb.where("(it.date, it.id) < (?, ?)", page.After.Date, page.After.ID)
+	}
 
-	rows, err := r.db(ctx).Query(ctx, query, portfolioID, limit, offset)
+	rows, err := r.db(ctx).Query(ctx, query+qb.and()+" ORDER BY it.date DESC, it.id DESC LIMIT "+qb.arg(page.Limit), qb.params()...)
 	if err != nil {
 		return nil, err
 	}
@@ -106,13 +211,22 @@ func (r *investmentTransactionRepository) GetByPortfolioID(ctx context.Context,
 	return r.scanTransactions(rows)
 }
 
+// investmentFilterConditions условия фильтра операций портфеля; $1 - портфель
+func investmentFilterConditions(portfolioID uuid.UUID, filter *models.InvestmentTransactionFilter) *queryBuilder {
+	return newQueryBuilder(portfolioID).
+		whereIf(filter.SecurityID != nil, "it.security_id = ?", filter.SecurityID).
+		whereIf(filter.Type != nil, "it.type = ?", filter.Type).
+		whereIf(filter.DateFrom != nil, "it.date >= ?", filter.DateFrom).
+		whereIf(filter.DateTo != nil, "it.date <= ?", filter.DateTo)
+}
+
 func (r *investmentTransactionRepository) GetBySecurityID(ctx context.Context, portfolioID, securityID uuid.UUID) ([]models.InvestmentTransaction, error) {
 	query := `
 		SELECT it.id, it.portfolio_id, it.security_id, it.type, it.date, it.quantity, it.price, it.amount, it.commission, it.currency, it.exchange_rate, it.notes, it.broker_ref, it.created_at,
 		       s.ticker, s.name, s.type as security_type
 		FROM investment_transactions it
 		JOIN securities s ON it.security_id = s.id
-		WHERE it.portfolio_id = $1 AND it.security_id = $2
+		WHERE it.portfolio_id = $1 AND it.security_id = $2 AND it.deleted_at IS NULL
 		ORDER BY it.date DESC
 	`
 
@@ -131,7 +245,7 @@ func (r *investmentTransactionRepository) GetByDateRange(ctx context.Context, po
 		       s.ticker, s.name, s.type as security_type
 		FROM investment_transactions it
 		JOIN securities s ON it.security_id = s.id
-		WHERE it.portfolio_id = $1 AND it.date >= $2 AND it.date <= $3
+		WHERE it.portfolio_id = $1 AND it.date >= $2 AND it.date <= $3 AND it.deleted_at IS NULL
 		ORDER BY it.date DESC
 	`
 
@@ -144,6 +258,53 @@ func (r *investmentTransactionRepository) GetByDateRange(ctx context.Context, po
 	return r.scanTransactions(rows)
 }
 
+func (r *investmentTransactionRepository) GetIncome(ctx context.Context, portfolioID uuid.UUID) ([]models.InvestmentTransaction, error) {
+	query := `
+		SELECT it.id, it.portfolio_id, it.security_id, it.type, it.date, it.quantity, it.price, it.amount, it.commission, it.currency, it.exchange_rate, it.notes, it.broker_ref, it.created_at,
+		       s.ticker, s.name, s.type as security_type
+		FROM investment_transactions it
+		JOIN securities s ON it.security_id = s.id
+		WHERE it.portfolio_id = $1 AND it.type IN ('dividend', 'coupon', 'amortization', 'redemption') AND it.deleted_at IS NULL
+		ORDER BY it.date
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanTransactions(rows)
+}
+
+func (r *investmentTransactionRepository) GetExistingBrokerRefs(ctx context.Context, portfolioID uuid.UUID, refs []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(refs) == 0 {
+		return existing, nil
+	}
+
+	query := `
+		SELECT DISTINCT broker_ref
+		FROM investment_transactions
+		WHERE portfolio_id = $1 AND broker_ref = ANY($2) AND deleted_at IS NULL
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, portfolioID, refs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, err
+		}
+		existing[ref] = true
+	}
+	return existing, rows.Err()
+}
+
 func (r *investmentTransactionRepository) scanTransactions(rows interface {
 	Next() bool
 	Scan(...interface{}) error
@@ -167,21 +328,41 @@ func (r *investmentTransactionRepository) scanTransactions(rows interface {
 	return transactions, nil
 }
 
-func (r *investmentTransactionRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM investment_transactions WHERE id = $1`
-	_, err := r.db(ctx).Exec(ctx, query, id)
-	return err
+func (r *investmentTransactionRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE investment_transactions SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL`
+	result, err := r.db(ctx).Exec(ctx, query, id, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *investmentTransactionRepository) Restore(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE investment_transactions SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
+	result, err := r.db(ctx).Exec(ctx, query, id)
+	if isUniqueViolation(err, "idx_investment_transactions_broker_ref") {
+		return false, ErrDuplicateBrokerRef
+	}
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
 }
 
 func (r *investmentTransactionRepository) GetTotalDividends(ctx context.Context, portfolioID uuid.UUID, year int) (decimal.Decimal, error) {
 	query := `
 		SELECT COALESCE(SUM(amount), 0)
 		FROM investment_transactions
-		WHERE portfolio_id = $1 AND type = 'dividend' AND EXTRACT(YEAR FROM date) = $2
+		WHERE portfolio_id = $1 AND type = ANY($3) AND EXTRACT(YEAR FROM date) = $2 AND deleted_at IS NULL
 	`
 
+	types := make([]string, len(models.DividendIncomeTypes))
+	for i, t := range models.DividendIncomeTypes {
+		types[i] = string(t)
+	}
+
 	var total decimal.Decimal
-	err := r.db(ctx).QueryRow(ctx, query, portfolioID, year).Scan(&total)
+	err := r.db(ctx).QueryRow(ctx, query, portfolioID, year, types).Scan(&total)
 	return total, err
 }
 
@@ -189,10 +370,86 @@ func (r *investmentTransactionRepository) GetTotalCommissions(ctx context.Contex
 	query := `
 		SELECT COALESCE(SUM(commission), 0)
 		FROM investment_transactions
-		WHERE portfolio_id = $1 AND EXTRACT(YEAR FROM date) = $2
+		WHERE portfolio_id = $1 AND EXTRACT(YEAR FROM date) = $2 AND deleted_at IS NULL
 	`
 
 	var total decimal.Decimal
 	err := r.db(ctx).QueryRow(ctx, query, portfolioID, year).Scan(&total)
 	return total, err
 }
+
+func (r *investmentTransactionRepository) GetIncomeByCurrency(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) (map[string]decimal.Decimal, error) {
+	query := `
+		SELECT it.currency, SUM(it.amount)
+		FROM investment_transactions it
+		JOIN portfolios p ON p.id = it.portfolio_id
+		WHERE p.user_id = $1 AND it.type IN ('dividend', 'coupon') AND it.date >= $2 AND it.date <= $3 AND it.deleted_at IS NULL
+		GROUP BY it.currency
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]decimal.Decimal)
+	for rows.Next() {
+		var currency string
+		var sum decimal.Decimal
+		if err := rows.Scan(&currency, &sum); err != nil {
+			return nil, err
+		}
+		result[currency] = sum
+	}
+	return result, rows.Err()
+}
+
+func (r *investmentTransactionRepository) GetPortfolioIDsBySecurity(ctx context.Context, securityID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		SELECT DISTINCT portfolio_id
+		FROM investment_transactions
+		WHERE security_id = $1 AND deleted_at IS NULL
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, securityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *investmentTransactionRepository) GetQueuedRebuilds(ctx context.Context, limit int) ([]HoldingRebuild, error) {
+	query := `SELECT portfolio_id, security_id FROM holding_rebuilds ORDER BY created_at LIMIT $1`
+
+	rows, err := r.db(ctx).Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []HoldingRebuild
+	for rows.Next() {
+		var item HoldingRebuild
+		if err := rows.Scan(&item.PortfolioID, &item.SecurityID); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *investmentTransactionRepository) DeleteQueuedRebuild(ctx context.Context, portfolioID, securityID uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM holding_rebuilds WHERE portfolio_id = $1 AND security_id = $2`, portfolioID, securityID)
+	return err
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LoginAttemptRepository interface {
+	// GetLocked счетчики из keys, заблокированные на момент at
+	GetLocked(ctx context.Context, keys []string, at time.Time) ([]models.LoginAttempt, error)
+	// RegisterFailure +1 неудача; счетчик начинается заново, если прошлая неудача старше window и блокировки нет
+	RegisterFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*models.LoginAttempt, error)
+	// Lock блокирует вход до until; unlockToken пустой - снять блокировку раньше можно только сбросом
+	Lock(ctx context.Context, key string, until time.Time, unlockToken string) error
+	Reset(ctx context.Context, key string) error
+	// Unlock снимает блокировку по коду из письма и возвращает ее ключ; pgx.ErrNoRows - код неверный или блокировка уже истекла
+	Unlock(ctx context.Context, unlockToken string, at time.Time) (string, error)
+	// DeleteStale удаляет счетчики без блокировки и без неудач после before
+	DeleteStale(ctx context.Context, before time.Time) (int64, error)
+}
+
+type loginAttemptRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewLoginAttemptRepository(pool *pgxpool.Pool) LoginAttemptRepository {
+	return &loginAttemptRepository{pool: pool}
+}
+
+func (r *loginAttemptRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *loginAttemptRepository) GetLocked(ctx context.Context, keys []string, at time.Time) ([]models.LoginAttempt, error) {
+	query := `
+		SELECT key, failures, locked_until, last_failed_at
+		FROM login_attempts
+		WHERE key = ANY($1) AND locked_until > $2
+	`
+	rows, err := r.db(ctx).Query(ctx, query, keys, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []models.LoginAttempt
+	for rows.Next() {
+		var a models.LoginAttempt
+		if err := rows.Scan(&a.Key, &a.Failures, &a.LockedUntil, &a.LastFailedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+func (r *loginAttemptRepository) RegisterFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	// одним upsert, чтобы параллельные попытки с разных экземпляров не теряли инкременты
+	query := `
+		INSERT INTO login_attempts (key, failures, last_failed_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failed_at < $3 AND (login_attempts.locked_until IS NULL OR login_attempts.locked_until <= $2) THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failed_at = $2
+		RETURNING key, failures, locked_until, last_failed_at
+	`
+	var a models.LoginAttempt
+	err := r.db(ctx).QueryRow(ctx, query, key, at, at.Add(-window)).Scan(&a.Key, &a.Failures, &a.LockedUntil, &a.LastFailedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, key string, until time.Time, unlockToken string) error {
+	var tokenHash *string
+	if unlockToken != "" {
+		hash := hashToken(unlockToken)
+		tokenHash = &hash
+	}
+	_, err := r.db(ctx).Exec(ctx, `UPDATE login_attempts SET locked_until = $2, unlock_token_hash = $3 WHERE key = $1`,
+		key, until, tokenHash)
+	return err
+}
+
+func (r *loginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
+
+func (r *loginAttemptRepository) Unlock(ctx context.Context, unlockToken string, at time.Time) (string, error) {
+	var key string
+	err := r.db(ctx).QueryRow(ctx, `DELETE FROM login_attempts WHERE unlock_token_hash = $1 AND locked_until > $2 RETURNING key`,
+		hashToken(unlockToken), at).Scan(&key)
+	return key, err
+}
+
+func (r *loginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db(ctx).Exec(ctx, `
+		DELETE FROM login_attempts
+		WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < $1)
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type MailConnectionRepository interface {
+	Create(ctx context.Context, conn *models.MailConnection) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.MailConnection, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.MailConnection, error)
+	// GetActive - включенные ящики всех пользователей (для фонового опроса)
+	GetActive(ctx context.Context) ([]models.MailConnection, error)
+	Update(ctx context.Context, id uuid.UUID, update *models.MailConnectionUpdate, passwordEnc *string) error
+	// SetPollState сохраняет, до какого письма ящик прочитан, и ошибку последнего опроса
+	SetPollState(ctx context.Context, id uuid.UUID, uidValidity, lastUID uint32, lastError string) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type mailConnectionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewMailConnectionRepository(pool *pgxpool.Pool) MailConnectionRepository {
+	return &mailConnectionRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *mailConnectionRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const mailConnectionColumns = `id, user_id, host, port, username, password_enc, mailbox, use_tls, account_id, is_active, uid_validity, last_uid, last_polled_at, COALESCE(last_error, ''), created_at, updated_at`
+
+func scanMailConnection(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.MailConnection, error) {
+	var c models.MailConnection
+	var uidValidity, lastUID int64
+	err := row.Scan(
+		&c.ID, &c.UserID, &c.Host, &c.Port, &c.Username, &c.PasswordEnc, &c.Mailbox, &c.UseTLS,
+		&c.AccountID, &c.IsActive, &uidValidity, &lastUID, &c.LastPolledAt, &c.LastError,
+		&c.CreatedAt, &c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.UIDValidity, c.LastUID = uint32(uidValidity), uint32(lastUID)
+	return &c, nil
+}
+
+func (r *mailConnectionRepository) Create(ctx context.Context, conn *models.MailConnection) error {
+	query := `
+		INSERT INTO mail_connections (id, user_id, host, port, username, password_enc, mailbox, use_tls, account_id, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	if conn.ID == uuid.Nil {
+		conn.ID = uuid.New()
+	}
+	now := time.Now()
+	conn.CreatedAt = now
+	conn.UpdatedAt = now
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		conn.ID, conn.UserID, conn.Host, conn.Port, conn.Username, conn.PasswordEnc, conn.Mailbox,
+		conn.UseTLS, conn.AccountID, conn.IsActive, conn.CreatedAt, conn.UpdatedAt,
+	)
+	return err
+}
+
+func (r *mailConnectionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.MailConnection, error) {
+	query := `SELECT ` + mailConnectionColumns + ` FROM mail_connections WHERE id = $1`
+	return scanMailConnection(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *mailConnectionRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.MailConnection, error) {
+	query := `SELECT ` + mailConnectionColumns + ` FROM mail_connections WHERE user_id = $1 ORDER BY created_at`
+	return r.list(ctx, query, userID)
+}
+
+func (r *mailConnectionRepository) GetActive(ctx context.Context) ([]models.MailConnection, error) {
+	query := `SELECT ` + mailConnectionColumns + ` FROM mail_connections WHERE is_active = true ORDER BY last_polled_at NULLS FIRST`
+	return r.list(ctx, query)
+}
+
+func (r *mailConnectionRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.MailConnection, error) {
+	rows, err := r.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conns []models.MailConnection
+	for rows.Next() {
+		c, err := scanMailConnection(rows)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, *c)
+	}
+	return conns, rows.Err()
+}
+
+func (r *mailConnectionRepository) Update(ctx context.Context, id uuid.UUID, update *models.MailConnectionUpdate, passwordEnc *string) error {
+	query := `
+		UPDATE mail_connections SET
+			password_enc = COALESCE($2, password_enc),
+			mailbox = COALESCE($3, mailbox),
+			account_id = COALESCE($4, account_id),
+			is_active = COALESCE($5, is_active),
+			updated_at = $6
+		WHERE id = $1
+	`
+	_, err := r.db(ctx).Exec(ctx, query, id, passwordEnc, update.Mailbox, update.AccountID, update.IsActive, time.Now())
+	return err
+}
+
+func (r *mailConnectionRepository) SetPollState(ctx context.Context, id uuid.UUID, uidValidity, lastUID uint32, lastError string) error {
+	query := `
+		UPDATE mail_connections SET
+			uid_validity = $2, last_uid = $3, last_error = NULLIF($4, ''), last_polled_at = $5, updated_at = $5
+		WHERE id = $1
+	`
+	_, err := r.db(ctx).Exec(ctx, query, id, int64(uidValidity), int64(lastUID), lastError, time.Now())
+	return err
+}
+
+func (r *mailConnectionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM mail_connections WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type NotificationRepository interface {
+	// Create сохраняет уведомление; false - уведомление с тем же dedup_key уже было
+	Create(ctx context.Context, n *models.Notification) (bool, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, filter *models.NotificationFilter) ([]models.Notification, error)
+	CountUnread(ctx context.Context, userID uuid.UUID) (map[models.NotificationType]int, error)
+	// MarkRead отмечает уведомления прочитанными; пустой ids - все непрочитанные пользователя
+	MarkRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int64, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) (bool, error)
+}
+
+type notificationRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewNotificationRepository(pool *pgxpool.Pool) NotificationRepository {
+	return &notificationRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *notificationRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const notificationColumns = `id, user_id, type, title, COALESCE(body, ''), entity_id, COALESCE(dedup_key, ''), read_at, created_at`
+
+func scanNotification(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.Notification, error) {
+	n := &models.Notification{}
+	err := row.Scan(&n.ID, &n.UserID, &n.Type, &n.Title, &n.Body, &n.EntityID, &n.DedupKey, &n.ReadAt, &n.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (r *notificationRepository) Create(ctx context.Context, n *models.Notification) (bool, error) {
+	query := `
+		INSERT INTO notifications (id, user_id, type, title, body, entity_id, dedup_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id, dedup_key) WHERE dedup_key IS NOT NULL DO NOTHING
+		RETURNING created_at
+	`
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+
+	err := r.db(ctx).QueryRow(ctx, query, n.ID, n.UserID, n.Type, n.Title, n.Body, n.EntityID, n.DedupKey).Scan(&n.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *notificationRepository) GetByUserID(ctx context.Context, userID uuid.UUID, filter *models.NotificationFilter) ([]models.Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE user_id = $1`
+
+	qb := newQueryBuilder(userID).
+		whereIf(filter.UnreadOnly, "read_at IS NULL").
+		whereIf(filter.Type != nil, "type = ?", filter.Type)
+
+	rows, err := r.db(ctx).Query(ctx, query+qb.and()+" ORDER BY created_at DESC, id"+qb.page(filter.Limit, filter.Offset), qb.params()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *n)
+	}
+	return items, rows.Err()
+}
+
+func (r *notificationRepository) CountUnread(ctx context.Context, userID uuid.UUID) (map[models.NotificationType]int, error) {
+	query := `SELECT type, COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL GROUP BY type`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[models.NotificationType]int)
+	for rows.Next() {
+		var t models.NotificationType
+		var count int
+		if err := rows.Scan(&t, &count); err != nil {
+			return nil, err
+		}
+		counts[t] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r *notificationRepository) MarkRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	query := `UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND read_at IS NULL`
+	qb := newQueryBuilder(userID).whereIf(len(ids) > 0, "id = ANY(?)", ids)
+
+	tag, err := r.db(ctx).Exec(ctx, query+qb.and(), qb.params()...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *notificationRepository) Delete(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	tag, err := r.db(ctx).Exec(ctx, `DELETE FROM notifications WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PayeeRepository interface {
+	Create(ctx context.Context, payee *models.Payee) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Payee, error)
+	GetByNormalizedName(ctx context.Context, userID uuid.UUID, normalizedName string) (*models.Payee, error)
+	// FindByName ищет получателя по нормализованному названию или по его прежнему названию
+	FindByName(ctx context.Context, userID uuid.UUID, normalizedName string) (*models.Payee, error)
+	// GetMatchCandidates получатели, которые могут оказаться похожими: длина названия в [minLen, maxLen]
+	// или одно название - начало другого. без количества транзакций
+	GetMatchCandidates(ctx context.Context, userID uuid.UUID, normalizedName string, minLen, maxLen int) ([]models.Payee, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Payee, error)
+	// Rename меняет название; прежнее остается псевдонимом получателя
+	Rename(ctx context.Context, id uuid.UUID, name, normalizedName string) error
+	// Merge переносит транзакции sourceIDs на targetID и удаляет исходных получателей, их названия становятся псевдонимами targetID
+	Merge(ctx context.Context, targetID uuid.UUID, sourceIDs []uuid.UUID) error
+	GetStats(ctx context.Context, id uuid.UUID) (*models.PayeeStats, error)
+}
+
+type payeeRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPayeeRepository(pool *pgxpool.Pool) PayeeRepository {
+	return &payeeRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *payeeRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *payeeRepository) Create(ctx context.Context, payee *models.Payee) error {
+	// при гонке двух транзакций с одинаковым описанием возвращаем уже существующего
+	query := `
+		INSERT INTO payees (id, user_id, name, normalized_name, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, normalized_name) DO UPDATE SET updated_at = payees.updated_at
+		RETURNING id, name, created_at, updated_at
+	`
+
+	if payee.ID == uuid.Nil {
+		payee.ID = uuid.New()
+	}
+	now := time.Now()
+
+	return r.db(ctx).QueryRow(ctx, query,
+		payee.ID, payee.UserID, payee.Name, payee.NormalizedName, now, now,
+	).Scan(&payee.ID, &payee.Name, &payee.CreatedAt, &payee.UpdatedAt)
+}
+
+func (r *payeeRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Payee, error) {
+	query := `
+		SELECT id, user_id, name, normalized_name, created_at, updated_at
+		FROM payees
+		WHERE id = $1
+	`
+
+	var p models.Payee
+	err := r.db(ctx).QueryRow(ctx, query, id).Scan(
+		&p.ID, &p.UserID, &p.Name, &p.NormalizedName, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *payeeRepository) GetByNormalizedName(ctx context.Context, userID uuid.UUID, normalizedName string) (*models.Payee, error) {
+	query := `
+		SELECT id, user_id, name, normalized_name, created_at, updated_at
+		FROM payees
+		WHERE user_id = $1 AND normalized_name = $2
+	`
+
+	var p models.Payee
+	err := r.db(ctx).QueryRow(ctx, query, userID, normalizedName).Scan(
+		&p.ID, &p.UserID, &p.Name, &p.NormalizedName, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *payeeRepository) FindByName(ctx context.Context, userID uuid.UUID, normalizedName string) (*models.Payee, error) {
+	// сначала текущее название, затем псевдоним
+	query := `
+		SELECT id, user_id, name, normalized_name, created_at, updated_at
+		FROM (
+			SELECT p.*, 0 AS priority FROM payees p
+			WHERE p.user_id = $1 AND p.normalized_name = $2
+			UNION ALL
+			SELECT p.*, 1 AS priority FROM payee_aliases a
+			JOIN payees p ON p.id = a.payee_id
+			WHERE a.user_id = $1 AND a.normalized_name = $2
+		) found
+		ORDER BY priority
+		LIMIT 1
+	`
+
+	var p models.Payee
+	err := r.db(ctx).QueryRow(ctx, query, userID, normalizedName).Scan(
+		&p.ID, &p.UserID, &p.Name, &p.NormalizedName, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *payeeRepository) GetMatchCandidates(ctx context.Context, userID uuid.UUID, normalizedName string, minLen, maxLen int) ([]models.Payee, error) {
+	// нормализованные названия состоят из букв, цифр и пробелов, поэтому в LIKE их можно подставлять как есть
+	query := `
+		SELECT id, user_id, name, normalized_name, created_at, updated_at
+		FROM payees
+		WHERE user_id = $1
+			AND (char_length(normalized_name) BETWEEN $3 AND $4
+				OR normalized_name LIKE $2 || ' %'
+				OR $2 LIKE normalized_name || ' %')
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID, normalizedName, minLen, maxLen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payees []models.Payee
+	for rows.Next() {
+		var p models.Payee
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.NormalizedName, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		payees = append(payees, p)
+	}
+	return payees, rows.Err()
+}
+
+func (r *payeeRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Payee, error) {
+	query := `
+		SELECT p.id, p.user_id, p.name, p.normalized_name, p.created_at, p.updated_at, COALESCE(c.count, 0)
+		FROM payees p
+		LEFT JOIN (
+			SELECT payee_id, COUNT(*) AS count
+			FROM transactions
+			WHERE user_id = $1 AND payee_id IS NOT NULL AND deleted_at IS NULL
+			GROUP BY payee_id
+		) c ON c.payee_id = p.id
+		WHERE p.user_id = $1
+		ORDER BY p.name
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payees []models.Payee
+	for rows.Next() {
+		var p models.Payee
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.NormalizedName, &p.CreatedAt, &p.UpdatedAt, &p.TransactionCount); err != nil {
+			return nil, err
+		}
+		payees = append(payees, p)
+	}
+	return payees, rows.Err()
+}
+
+func (r *payeeRepository) Rename(ctx context.Context, id uuid.UUID, name, normalizedName string) error {
+	// прежнее название запоминаем псевдонимом, а псевдоним, ставший названием, удаляем
+	aliasQuery := `
+		INSERT INTO payee_aliases (user_id, normalized_name, payee_id)
+		SELECT user_id, normalized_name, id FROM payees WHERE id = $1 AND normalized_name <> $2
+		ON CONFLICT (user_id, normalized_name) DO UPDATE SET payee_id = EXCLUDED.payee_id
+	`
+	if _, err := r.db(ctx).Exec(ctx, aliasQuery, id, normalizedName); err != nil {
+		return err
+	}
+	if _, err := r.db(ctx).Exec(ctx, `
+		DELETE FROM payee_aliases
+		WHERE user_id = (SELECT user_id FROM payees WHERE id = $1) AND normalized_name = $2
+	`, id, normalizedName); err != nil {
+		return err
+	}
+
+	query := `UPDATE payees SET name = $2, normalized_name = $3, updated_at = $4 WHERE id = $1`
+	_, err := r.db(ctx).Exec(ctx, query, id, name, normalizedName, time.Now())
+	return err
+}
+
+func (r *payeeRepository) Merge(ctx context.Context, targetID uuid.UUID, sourceIDs []uuid.UUID) error {
+	if _, err := r.db(ctx).Exec(ctx, `UPDATE transactions SET payee_id = $1 WHERE payee_id = ANY($2)`, targetID, sourceIDs); err != nil {
+		return err
+	}
+	// бюджеты по объединяемым получателям продолжают считаться по итоговому
+	if _, err := r.db(ctx).Exec(ctx, `UPDATE budgets SET payee_id = $1 WHERE payee_id = ANY($2)`, targetID, sourceIDs); err != nil {
+		return err
+	}
+	// названия и псевдонимы исходных получателей переходят к итоговому до удаления (иначе удалятся каскадом)
+	if _, err := r.db(ctx).Exec(ctx, `UPDATE payee_aliases SET payee_id = $1 WHERE payee_id = ANY($2)`, targetID, sourceIDs); err != nil {
+		return err
+	}
+	aliasQuery := `
+		INSERT INTO payee_aliases (user_id, normalized_name, payee_id)
+		SELECT user_id, normalized_name, $1 FROM payees WHERE id = ANY($2) AND id <> $1
+		ON CONFLICT (user_id, normalized_name) DO UPDATE SET payee_id = EXCLUDED.payee_id
+	`
+	if _, err := r.db(ctx).Exec(ctx, aliasQuery, targetID, sourceIDs); err != nil {
+		return err
+	}
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM payees WHERE id = ANY($1) AND id <> $2`, sourceIDs, targetID)
+	return err
+}
+
+// GetStats считает расходы у получателя: итог, средний чек и помесячную динамику
+func (r *payeeRepository) GetStats(ctx context.Context, id uuid.UUID) (*models.PayeeStats, error) {
+	stats := &models.PayeeStats{PayeeID: id, MonthlyTrend: []models.PayeeMonthlyStats{}}
+
+	totalsQuery := `
+		SELECT COALESCE(SUM(amount), 0), COUNT(*), MIN(date), MAX(date)
+		FROM transactions
+		WHERE payee_id = $1 AND type = 'expense' AND deleted_at IS NULL
+	`
+	err := r.db(ctx).QueryRow(ctx, totalsQuery, id).Scan(
+		&stats.TotalSpent, &stats.TransactionCount, &stats.FirstDate, &stats.LastDate,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	monthlyQuery := `
+		SELECT TO_CHAR(date, 'YYYY-MM') as month, SUM(amount), COUNT(*)
+		FROM transactions
+		WHERE payee_id = $1 AND type = 'expense' AND deleted_at IS NULL
+		GROUP BY month
+		ORDER BY month
+	`
+	rows, err := r.db(ctx).Query(ctx, monthlyQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.PayeeMonthlyStats
+		if err := rows.Scan(&m.Month, &m.Amount, &m.TransactionCount); err != nil {
+			return nil, err
+		}
+		stats.MonthlyTrend = append(stats.MonthlyTrend, m)
+	}
+	return stats, rows.Err()
+}
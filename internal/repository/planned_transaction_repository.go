@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PlannedTransactionRepository interface {
+	Create(ctx context.Context, planned *models.PlannedTransaction) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.PlannedTransaction, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, status *models.PlannedTransactionStatus) ([]models.PlannedTransaction, error)
+	// GetPlannedBefore - еще не проведенные платежи пользователя с датой до указанной (для прогноза)
+	GetPlannedBefore(ctx context.Context, userID uuid.UUID, before time.Time) ([]models.PlannedTransaction, error)
+	// GetDue - не проведенные платежи всех пользователей с наступившей датой, с автопроведением или без
+	GetDue(ctx context.Context, date time.Time, autoPost bool, limit int) ([]models.PlannedTransaction, error)
+	Update(ctx context.Context, id uuid.UUID, update *models.PlannedTransactionUpdate) error
+	// SetStatus переводит еще не проведенный платеж в status; false - платеж уже проведен или отменен
+	// (проверка и смена статуса одним запросом, поэтому параллельные подтверждения не проведут платеж дважды)
+	SetStatus(ctx context.Context, id uuid.UUID, status models.PlannedTransactionStatus) (bool, error)
+	SetTransaction(ctx context.Context, id, transactionID uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type plannedTransactionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPlannedTransactionRepository(pool *pgxpool.Pool) PlannedTransactionRepository {
+	return &plannedTransactionRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *plannedTransactionRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const plannedTransactionColumns = `id, user_id, account_id, category_id, type, amount, currency, COALESCE(description, ''), due_date, to_account_id, auto_post, status, transaction_id, COALESCE(notes, ''), created_at, updated_at, posted_at`
+
+func scanPlannedTransaction(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.PlannedTransaction, error) {
+	var p models.PlannedTransaction
+	err := row.Scan(
+		&p.ID, &p.UserID, &p.AccountID, &p.CategoryID, &p.Type, &p.Amount, &p.Currency,
+		&p.Description, &p.DueDate, &p.ToAccountID, &p.AutoPost, &p.Status, &p.TransactionID,
+		&p.Notes, &p.CreatedAt, &p.UpdatedAt, &p.PostedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *plannedTransactionRepository) Create(ctx context.Context, planned *models.PlannedTransaction) error {
+	query := `
+		INSERT INTO planned_transactions (id, user_id, account_id, category_id, type, amount, currency, description, due_date, to_account_id, auto_post, status, notes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`
+
+	if planned.ID == uuid.Nil {
+		planned.ID = uuid.New()
+	}
+	if planned.Status == "" {
+		planned.Status = models.PlannedStatusPlanned
+	}
+	now := time.Now()
+	planned.CreatedAt = now
+	planned.UpdatedAt = now
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		planned.ID, planned.UserID, planned.AccountID, planned.CategoryID, planned.Type,
+		planned.Amount, planned.Currency, planned.Description, planned.DueDate,
+		planned.ToAccountID, planned.AutoPost, planned.Status, planned.Notes,
+		planned.CreatedAt, planned.UpdatedAt,
+	)
+	return err
+}
+
+func (r *plannedTransactionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.PlannedTransaction, error) {
+	query := `SELECT ` + plannedTransactionColumns + ` FROM planned_transactions WHERE id = $1`
+	return scanPlannedTransaction(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *plannedTransactionRepository) GetByUserID(ctx context.Context, userID uuid.UUID, status *models.PlannedTransactionStatus) ([]models.PlannedTransaction, error) {
+	query := `
+		SELECT ` + plannedTransactionColumns + `
+		FROM planned_transactions
+		WHERE user_id = $1 AND ($2::varchar IS NULL OR status = $2)
+		ORDER BY due_date, created_at
+	`
+	return r.list(ctx, query, userID, status)
+}
+
+func (r *plannedTransactionRepository) GetPlannedBefore(ctx context.Context, userID uuid.UUID, before time.Time) ([]models.PlannedTransaction, error) {
+	query := `
+		SELECT ` + plannedTransactionColumns + `
+		FROM planned_transactions
+		WHERE user_id = $1 AND status = $2 AND due_date < $3
+		ORDER BY due_date
+	`
+	return r.list(ctx, query, userID, models.PlannedStatusPlanned, before)
+}
+
+func (r *plannedTransactionRepository) GetDue(ctx context.Context, date time.Time, autoPost bool, limit int) ([]models.PlannedTransaction, error) {
+	// фильтр auto_post в запросе: иначе просроченные ручные платежи займут всю страницу
+	query := `
+		SELECT ` + plannedTransactionColumns + `
+		FROM planned_transactions
+		WHERE status = $1 AND due_date <= $2 AND COALESCE(auto_post, false) = $3
+		ORDER BY due_date
+		LIMIT $4
+	`
+	return r.list(ctx, query, models.PlannedStatusPlanned, date, autoPost, limit)
+}
+
+func (r *plannedTransactionRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.PlannedTransaction, error) {
+	rows, err := r.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var planned []models.PlannedTransaction
+	for rows.Next() {
+		p, err := scanPlannedTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		planned = append(planned, *p)
+	}
+	return planned, rows.Err()
+}
+
+func (r *plannedTransactionRepository) Update(ctx context.Context, id uuid.UUID, update *models.PlannedTransactionUpdate) error {
+	query := `
+		UPDATE planned_transactions SET
+			account_id = COALESCE($2, account_id),
+			category_id = COALESCE($3, category_id),
+			amount = COALESCE($4, amount),
+			description = COALESCE($5, description),
+			due_date = COALESCE($6, due_date),
+			to_account_id = COALESCE($7, to_account_id),
+			auto_post = COALESCE($8, auto_post),
+			notes = COALESCE($9, notes),
+			updated_at = $10
+		WHERE id = $1
+	`
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		id, update.AccountID, update.CategoryID, update.Amount, update.Description,
+		update.DueDate, update.ToAccountID, update.AutoPost, update.Notes, time.Now(),
+	)
+	return err
+}
+
+func (r *plannedTransactionRepository) SetStatus(ctx context.Context, id uuid.UUID, status models.PlannedTransactionStatus) (bool, error) {
+	query := `
+		UPDATE planned_transactions
+		SET status = $2, updated_at = $4, posted_at = CASE WHEN $2 = $5 THEN $4 ELSE posted_at END
+		WHERE id = $1 AND status = $3
+	`
+	tag, err := r.db(ctx).Exec(ctx, query, id, status, models.PlannedStatusPlanned, time.Now(), models.PlannedStatusPosted)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *plannedTransactionRepository) SetTransaction(ctx context.Context, id, transactionID uuid.UUID) error {
+	query := `UPDATE planned_transactions SET transaction_id = $2 WHERE id = $1`
+	_, err := r.db(ctx).Exec(ctx, query, id, transactionID)
+	return err
+}
+
+func (r *plannedTransactionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM planned_transactions WHERE id = $1`, id)
+	return err
+}
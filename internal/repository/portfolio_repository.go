@@ -15,6 +15,8 @@ type PortfolioRepository interface {
 	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Portfolio, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.PortfolioUpdate) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	// GetActiveIDs активные портфели всех пользователей, у которых есть позиции
+	GetActiveIDs(ctx context.Context) ([]uuid.UUID, error)
 }
 
 type portfolioRepository struct {
@@ -31,8 +33,8 @@ func (r *portfolioRepository) db(ctx context.Context) DBTX {
 
 func (r *portfolioRepository) Create(ctx context.Context, portfolio *models.Portfolio) error {
 	query := `
-		INSERT INTO portfolios (id, user_id, account_id, name, description, currency, broker_name, broker_account, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO portfolios (id, user_id, account_id, name, description, currency, broker_name, broker_account, is_active, iis_type, iis_opened_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 
 	if portfolio.ID == uuid.Nil {
@@ -46,7 +48,7 @@ func (r *portfolioRepository) Create(ctx context.Context, portfolio *models.Port
 	_, err := r.db(ctx).Exec(ctx, query,
 		portfolio.ID, portfolio.UserID, portfolio.AccountID, portfolio.Name,
 		portfolio.Description, portfolio.Currency, portfolio.BrokerName,
-		portfolio.BrokerAccount, portfolio.IsActive,
+		portfolio.BrokerAccount, portfolio.IsActive, portfolio.IISType, portfolio.IISOpenedAt,
 		portfolio.CreatedAt, portfolio.UpdatedAt,
 	)
 	return err
@@ -54,7 +56,7 @@ func (r *portfolioRepository) Create(ctx context.Context, portfolio *models.Port
 
 func (r *portfolioRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Portfolio, error) {
 	query := `
-		SELECT id, user_id, account_id, name, description, currency, broker_name, broker_account, is_active, created_at, updated_at
+		SELECT id, user_id, account_id, name, description, currency, broker_name, broker_account, is_active, iis_type, iis_opened_at, created_at, updated_at
 		FROM portfolios
 		WHERE id = $1
 	`
@@ -63,7 +65,7 @@ func (r *portfolioRepository) GetByID(ctx context.Context, id uuid.UUID) (*model
 	err := r.db(ctx).QueryRow(ctx, query, id).Scan(
 		&portfolio.ID, &portfolio.UserID, &portfolio.AccountID, &portfolio.Name,
 		&portfolio.Description, &portfolio.Currency, &portfolio.BrokerName,
-		&portfolio.BrokerAccount, &portfolio.IsActive,
+		&portfolio.BrokerAccount, &portfolio.IsActive, &portfolio.IISType, &portfolio.IISOpenedAt,
 		&portfolio.CreatedAt, &portfolio.UpdatedAt,
 	)
 	if err != nil {
@@ -74,7 +76,7 @@ func (r *portfolioRepository) GetByID(ctx context.Context, id uuid.UUID) (*model
 
 func (r *portfolioRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Portfolio, error) {
 	query := `
-		SELECT id, user_id, account_id, name, description, currency, broker_name, broker_account, is_active, created_at, updated_at
+		SELECT id, user_id, account_id, name, description, currency, broker_name, broker_account, is_active, iis_type, iis_opened_at, created_at, updated_at
 		FROM portfolios
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -92,7 +94,7 @@ func (r *portfolioRepository) GetByUserID(ctx context.Context, userID uuid.UUID)
 		err := rows.Scan(
 			&portfolio.ID, &portfolio.UserID, &portfolio.AccountID, &portfolio.Name,
 			&portfolio.Description, &portfolio.Currency, &portfolio.BrokerName,
-			&portfolio.BrokerAccount, &portfolio.IsActive,
+			&portfolio.BrokerAccount, &portfolio.IsActive, &portfolio.IISType, &portfolio.IISOpenedAt,
 			&portfolio.CreatedAt, &portfolio.UpdatedAt,
 		)
 		if err != nil {
@@ -111,13 +113,15 @@ func (r *portfolioRepository) Update(ctx context.Context, id uuid.UUID, update *
 			broker_name = COALESCE($4, broker_name),
 			broker_account = COALESCE($5, broker_account),
 			is_active = COALESCE($6, is_active),
-			updated_at = $7
+			iis_type = COALESCE($7, iis_type),
+			iis_opened_at = COALESCE($8, iis_opened_at),
+			updated_at = $9
 		WHERE id = $1
 	`
 
 	_, err := r.db(ctx).Exec(ctx, query,
 		id, update.Name, update.Description, update.BrokerName,
-		update.BrokerAccount, update.IsActive, time.Now(),
+		update.BrokerAccount, update.IsActive, update.IISType, update.IISOpenedAt, time.Now(),
 	)
 	return err
 }
@@ -127,3 +131,28 @@ func (r *portfolioRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	_, err := r.db(ctx).Exec(ctx, query, id)
 	return err
 }
+
+func (r *portfolioRepository) GetActiveIDs(ctx context.Context) ([]uuid.UUID, error) {
+	query := `
+		SELECT p.id
+		FROM portfolios p
+		JOIN users u ON u.id = p.user_id AND u.deleted_at IS NULL
+		WHERE p.is_active = true AND EXISTS (SELECT 1 FROM holdings h WHERE h.portfolio_id = p.id)
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// сколько свечей вставляем одним запросом (7 параметров на свечу, лимит postgres - 65535)
+const priceHistoryInsertChunk = 1000
+
+type PriceHistoryRepository interface {
+	GetRange(ctx context.Context, securityID uuid.UUID, from, to time.Time) ([]models.PriceHistory, error)
+	// Upsert сохраняет свечи, существующие даты перезаписываются
+	Upsert(ctx context.Context, bars []models.PriceHistory) error
+	GetCoverage(ctx context.Context, securityID uuid.UUID) (*models.PriceHistoryCoverage, error)
+	SetCoverage(ctx context.Context, coverage *models.PriceHistoryCoverage) error
+}
+
+type priceHistoryRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPriceHistoryRepository(pool *pgxpool.Pool) PriceHistoryRepository {
+	return &priceHistoryRepository{pool: pool}
+}
+
+func (r *priceHistoryRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *priceHistoryRepository) GetRange(ctx context.Context, securityID uuid.UUID, from, to time.Time) ([]models.PriceHistory, error) {
+	query := `
+		SELECT security_id, date, COALESCE(open, 0), COALESCE(high, 0), COALESCE(low, 0), close, COALESCE(volume, 0)
+		FROM price_history
+		WHERE security_id = $1 AND date >= $2 AND date <= $3
+		ORDER BY date
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, securityID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bars []models.PriceHistory
+	for rows.Next() {
+		var b models.PriceHistory
+		if err := rows.Scan(&b.SecurityID, &b.Date, &b.Open, &b.High, &b.Low, &b.Close, &b.Volume); err != nil {
+			return nil, err
+		}
+		bars = append(bars, b)
+	}
+	return bars, rows.Err()
+}
+
+func (r *priceHistoryRepository) Upsert(ctx context.Context, bars []models.PriceHistory) error {
+	for start := 0; start < len(bars); start += priceHistoryInsertChunk {
+		end := start + priceHistoryInsertChunk
+		if end > len(bars) {
+			end = len(bars)
+		}
+		if err := r.upsertChunk(ctx, bars[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *priceHistoryRepository) upsertChunk(ctx context.Context, bars []models.PriceHistory) error {
+	values := make([]string, 0, len(bars))
+	args := make([]interface{}, 0, len(bars)*7)
+	for i, b := range bars {
+		n := i * 7
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7))
+		args = append(args, b.SecurityID, b.Date, b.Open, b.High, b.Low, b.Close, b.Volume)
+	}
+
+	query := `
+		INSERT INTO price_history (security_id, date, open, high, low, close, volume)
+		VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (security_id, date) DO UPDATE SET
+			open = EXCLUDED.open,
+			high = EXCLUDED.high,
+			low = EXCLUDED.low,
+			close = EXCLUDED.close,
+			volume = EXCLUDED.volume
+	`
+
+	_, err := r.db(ctx).Exec(ctx, query, args...)
+	return err
+}
+
+func (r *priceHistoryRepository) GetCoverage(ctx context.Context, securityID uuid.UUID) (*models.PriceHistoryCoverage, error) {
+	query := `
+		SELECT security_id, from_date, to_date, updated_at
+		FROM price_history_coverage
+		WHERE security_id = $1
+	`
+
+	var c models.PriceHistoryCoverage
+	err := r.db(ctx).QueryRow(ctx, query, securityID).Scan(&c.SecurityID, &c.FromDate, &c.ToDate, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *priceHistoryRepository) SetCoverage(ctx context.Context, coverage *models.PriceHistoryCoverage) error {
+	query := `
+		INSERT INTO price_history_coverage (security_id, from_date, to_date, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (security_id) DO UPDATE SET
+			from_date = EXCLUDED.from_date,
+			to_date = EXCLUDED.to_date,
+			updated_at = NOW()
+	`
+
+	_, err := r.db(ctx).Exec(ctx, query, coverage.SecurityID, coverage.FromDate, coverage.ToDate)
+	return err
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+type ProductRepository interface {
+	Create(ctx context.Context, product *models.Product) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Product, error)
+	GetByNormalizedName(ctx context.Context, userID uuid.UUID, normalizedName string) (*models.Product, error)
+	// GetByUserID каталог с последней покупкой; query - подстрока названия, пустая - все товары
+	GetByUserID(ctx context.Context, userID uuid.UUID, query string) ([]models.Product, error)
+	Rename(ctx context.Context, id uuid.UUID, name, normalizedName string) error
+	// Merge переносит позиции товаров sourceIDs на targetID и удаляет исходные товары
+	Merge(ctx context.Context, targetID uuid.UUID, sourceIDs []uuid.UUID) error
+	// GetSpending суммы расходных позиций за период по товарам и валютам транзакций
+	GetSpending(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) ([]ProductSpendingRow, error)
+	// GetPurchases покупки товара за период по возрастанию даты
+	GetPurchases(ctx context.Context, productID uuid.UUID, startDate, endDate time.Time) ([]models.ProductPricePoint, error)
+}
+
+// ProductSpendingRow расходы на товар в одной валюте
+type ProductSpendingRow struct {
+	ProductID     uuid.UUID
+	Name          string
+	Currency      string
+	PurchaseCount int64
+	Quantity      decimal.Decimal
+	Amount        decimal.Decimal
+}
+
+type productRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewProductRepository(pool *pgxpool.Pool) ProductRepository {
+	return &productRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *productRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *productRepository) Create(ctx context.Context, product *models.Product) error {
+	// одинаковые позиции в одном чеке и параллельные чеки - возвращаем уже созданный товар
+	query := `
+		INSERT INTO products (id, user_id, name, normalized_name, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, normalized_name) DO UPDATE SET updated_at = products.updated_at
+		RETURNING id, name, created_at, updated_at
+	`
+
+	if product.ID == uuid.Nil {
+		product.ID = uuid.New()
+	}
+	now := time.Now()
+
+	return r.db(ctx).QueryRow(ctx, query,
+		product.ID, product.UserID, product.Name, product.NormalizedName, now, now,
+	).Scan(&product.ID, &product.Name, &product.CreatedAt, &product.UpdatedAt)
+}
+
+func (r *productRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Product, error) {
+	query := `
+		SELECT id, user_id, name, normalized_name, created_at, updated_at
+		FROM products
+		WHERE id = $1
+	`
+
+	var p models.Product
+	err := r.db(ctx).QueryRow(ctx, query, id).Scan(
+		&p.ID, &p.UserID, &p.Name, &p.NormalizedName, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *productRepository) GetByNormalizedName(ctx context.Context, userID uuid.UUID, normalizedName string) (*models.Product, error) {
+	query := `
+		SELECT id, user_id, name, normalized_name, created_at, updated_at
+		FROM products
+		WHERE user_id = $1 AND normalized_name = $2
+	`
+
+	var p models.Product
+	err := r.db(ctx).QueryRow(ctx, query, userID, normalizedName).Scan(
+		&p.ID, &p.UserID, &p.Name, &p.NormalizedName, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *productRepository) GetByUserID(ctx context.Context, userID uuid.UUID, query string) ([]models.Product, error) {
+	sql := `
+		SELECT p.id, p.user_id, p.name, p.normalized_name, p.created_at, p.updated_at,
+			COALESCE(s.cnt, 0), l.date, l.price, l.currency
+		FROM products p
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) AS cnt
+			FROM transaction_items i
+			JOIN transactions t ON t.id = i.transaction_id
+			WHERE i.product_id = p.id AND t.deleted_at IS NULL
+		) s ON true
+		LEFT JOIN LATERAL (
+			SELECT t.date, i.price, t.currency
+			FROM transaction_items i
+			JOIN transactions t ON t.id = i.transaction_id
+			WHERE i.product_id = p.id AND t.deleted_at IS NULL
+			ORDER BY t.date DESC, t.created_at DESC
+			LIMIT 1
+		) l ON true
+		WHERE p.user_id = $1 AND ($2 = '' OR p.name ILIKE '%' || $2 || '%')
+		ORDER BY p.name
+	`
+
+	rows, err := r.db(ctx).Query(ctx, sql, userID, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var p models.Product
+		var lastCurrency *string
+		if err := rows.Scan(
+			&p.ID, &p.UserID, &p.Name, &p.NormalizedName, &p.CreatedAt, &p.UpdatedAt,
+			&p.PurchaseCount, &p.LastPurchaseDate, &p.LastPrice, &lastCurrency,
+		); err != nil {
+			return nil, err
+		}
+		if lastCurrency != nil {
+			p.LastCurrency = *lastCurrency
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+func (r *productRepository) Rename(ctx context.Context, id uuid.UUID, name, normalizedName string) error {
+	query := `UPDATE products SET name = $2, normalized_name = $3, updated_at = $4 WHERE id = $1`
+	_, err := r.db(ctx).Exec(ctx, query, id, name, normalizedName, time.Now())
+	return err
+}
+
+func (r *productRepository) Merge(ctx context.Context, targetID uuid.UUID, sourceIDs []uuid.UUID) error {
+	if _, err := r.db(ctx).Exec(ctx, `UPDATE transaction_items SET product_id = $1 WHERE product_id = ANY($2)`, targetID, sourceIDs); err != nil {
+		return err
+	}
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM products WHERE id = ANY($1) AND id <> $2`, sourceIDs, targetID)
+	return err
+}
+
+func (r *productRepository) GetSpending(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) ([]ProductSpendingRow, error) {
+	query := `
+		SELECT p.id, p.name, t.currency, COUNT(*), SUM(i.quantity), SUM(i.amount)
+		FROM transaction_items i
+		JOIN transactions t ON t.id = i.transaction_id
+		JOIN products p ON p.id = i.product_id
+		WHERE t.user_id = $1 AND t.type = 'expense' AND t.date >= $2 AND t.date <= $3 AND t.deleted_at IS NULL
+		GROUP BY p.id, p.name, t.currency
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []ProductSpendingRow
+	for rows.Next() {
+		var row ProductSpendingRow
+		if err := rows.Scan(&row.ProductID, &row.Name, &row.Currency, &row.PurchaseCount, &row.Quantity, &row.Amount); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func (r *productRepository) GetPurchases(ctx context.Context, productID uuid.UUID, startDate, endDate time.Time) ([]models.ProductPricePoint, error) {
+	query := `
+		SELECT t.date, t.id, t.payee_id, COALESCE(py.name, t.description, ''), i.quantity, i.price, i.amount, t.currency
+		FROM transaction_items i
+		JOIN transactions t ON t.id = i.transaction_id
+		LEFT JOIN payees py ON py.id = t.payee_id
+		WHERE i.product_id = $1 AND t.type = 'expense' AND t.date >= $2 AND t.date <= $3 AND t.deleted_at IS NULL
+		ORDER BY t.date, t.created_at, i.position
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, productID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []models.ProductPricePoint
+	for rows.Next() {
+		var p models.ProductPricePoint
+		if err := rows.Scan(&p.Date, &p.TransactionID, &p.PayeeID, &p.Merchant, &p.Quantity, &p.Price, &p.Amount, &p.Currency); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
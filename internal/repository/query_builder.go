@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidSortField = errors.New("invalid sort field")
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 1000
+)
+
+// queryBuilder собирает условия WHERE, сортировку и пагинацию для динамических запросов.
+// значения всегда уходят параметрами ($N), в текст запроса попадают только строки из кода
+type queryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// newQueryBuilder args - параметры, уже занятые в базовом запросе ($1, $2, ...)
+func newQueryBuilder(args ...interface{}) *queryBuilder {
+	return &queryBuilder{args: args}
+}
+
+// where добавляет условие; каждый "?" в нем заменяется на очередной параметр
+func (b *queryBuilder) where(condition string, args ...interface{}) *queryBuilder {
+	var sb strings.Builder
+	next := 0
+	for _, r := range condition {
+		if r == '?' && next < len(args) {
+			sb.WriteString(b.arg(args[next]))
+			next++
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	b.conditions = append(b.conditions, sb.String())
+	return b
+}
+
+// whereIf добавляет условие только если фильтр задан
+func (b *queryBuilder) whereIf(ok bool, condition string, args ...interface{}) *queryBuilder {
+	if ok {
+		b.where(condition, args...)
+	}
+	return b
+}
+
+// arg регистрирует параметр и возвращает его плейсхолдер
+func (b *queryBuilder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// and условия для дописывания к базовому запросу, в котором уже есть WHERE
+func (b *queryBuilder) and() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " AND " + strings.Join(b.conditions, " AND ")
+}
+
+// page LIMIT/OFFSET параметрами; limit без значения - defaultPageLimit, не больше maxPageLimit, offset не меньше нуля
+func (b *queryBuilder) page(limit, offset int) string {
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return " LIMIT " + b.arg(limit) + " OFFSET " + b.arg(offset)
+}
+
+func (b *queryBuilder) params() []interface{} {
+	return b.args
+}
+
+// sortColumns белый список сортировки: имя поля из API -> выражение SQL
+type sortColumns map[string]string
+
+// orderBy собирает ORDER BY; пустое поле - сортировка по умолчанию, неизвестное - ErrInvalidSortField.
+// tiebreak добавляется последним, чтобы порядок страниц был стабильным
+func (s sortColumns) orderBy(field, order, defaultField, tiebreak string) (string, error) {
+	if field == "" {
+		field = defaultField
+	}
+	column, ok := s[field]
+	if !ok {
+		return "", ErrInvalidSortField
+	}
+
+	direction := "DESC"
+	if strings.EqualFold(order, "asc") {
+		direction = "ASC"
+	}
+
+	clause := " ORDER BY " + column + " " + direction
+	if tiebreak != "" {
+		clause += ", " + tiebreak
+	}
+	return clause, nil
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestQueryBuilderWhere(t *testing.T) {
+	tests := []struct {
+		name      string
+		build     func() *queryBuilder
+		wantAnd   string
+		wantParam []interface{}
+	}{
+		{
+			name:      "без условий",
+			build:     func() *queryBuilder { return newQueryBuilder("user") },
+			wantAnd:   "",
+			wantParam: []interface{}{"user"},
+		},
+		{
+			name: "нумерация продолжает параметры базового запроса",
+			build: func() *queryBuilder {
+				return newQueryBuilder("user").where("type = ?", "expense")
+			},
+			wantAnd:   " AND type = $2",
+			wantParam: []interface{}{"user", "expense"},
+		},
+		{
+			name: "несколько плейсхолдеров в одном условии",
+			build: func() *queryBuilder {
+				return newQueryBuilder("user").where("date BETWEEN ? AND ?", 1, 2).where("amount > ?", 3)
+			},
+			wantAnd:   " AND date BETWEEN $2 AND $3 AND amount > $4",
+			wantParam: []interface{}{"user", 1, 2, 3},
+		},
+		{
+			name: "условие без параметров",
+			build: func() *queryBuilder {
+				return newQueryBuilder().where("deleted_at IS NULL").where("type = ?", "income")
+			},
+			wantAnd:   " AND deleted_at IS NULL AND type = $1",
+			wantParam: []interface{}{"income"},
+		},
+		{
+			name: "лишний ? без параметра остается в тексте",
+			build: func() *queryBuilder {
+				return newQueryBuilder().where("a = ? OR b = ?", 1)
+			},
+			wantAnd:   " AND a = $1 OR b = ?",
+			wantParam: []interface{}{1},
+		},
+		{
+			name: "whereIf пропускает незаданный фильтр и не занимает номер",
+			build: func() *queryBuilder {
+				return newQueryBuilder("user").
+					whereIf(false, "type = ?", "expense").
+					whereIf(true, "account_id = ?", "acc").
+					whereIf(false, "category_id = ?", "cat").
+					whereIf(true, "amount < ?", 10)
+			},
+			wantAnd:   " AND account_id = $2 AND amount < $3",
+			wantParam: []interface{}{"user", "acc", 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := tt.build()
+			if got := qb.and(); got != tt.wantAnd {
+				t.Errorf("and() = %q, want %q", got, tt.wantAnd)
+			}
+			if got := qb.params(); !reflect.DeepEqual(got, tt.wantParam) {
+				t.Errorf("params() = %v, want %v", got, tt.wantParam)
+			}
+		})
+	}
+}
+
+func TestSortColumnsOrderBy(t *testing.T) {
+	columns := sortColumns{"date": "t.date", "amount": "t.amount"}
+
+	tests := []struct {
+		name     string
+		field    string
+		order    string
+		tiebreak string
+		want     string
+		wantErr  error
+	}{
+		{name: "поле по умолчанию", field: "", order: "", tiebreak: "t.id DESC", want: " ORDER BY t.date DESC, t.id DESC"},
+		{name: "по возрастанию", field: "amount", order: "ASC", want: " ORDER BY t.amount ASC"},
+		{name: "неизвестное направление - по убыванию", field: "amount", order: "sideways", want: " ORDER BY t.amount DESC"},
+		{name: "поле не из списка", field: "description", wantErr: ErrInvalidSortField},
+		{name: "внедрение SQL", field: "date; DROP TABLE users", wantErr: ErrInvalidSortField},
+		{name: "SQL-выражение вместо имени поля", field: "t.date", wantErr: ErrInvalidSortField},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := columns.orderBy(tt.field, tt.order, "date", tt.tiebreak)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("orderBy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderPage(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		offset     int
+		wantLimit  int
+		wantOffset int
+	}{
+		{name: "как есть", limit: 20, offset: 40, wantLimit: 20, wantOffset: 40},
+		{name: "нулевой лимит", limit: 0, offset: 0, wantLimit: defaultPageLimit, wantOffset: 0},
+		{name: "отрицательный лимит", limit: -5, offset: 10, wantLimit: defaultPageLimit, wantOffset: 10},
+		{name: "лимит больше максимума", limit: maxPageLimit + 1, offset: 0, wantLimit: maxPageLimit, wantOffset: 0},
+		{name: "отрицательное смещение", limit: 10, offset: -30, wantLimit: 10, wantOffset: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := newQueryBuilder("user").where("type = ?", "expense")
+			if got, want := qb.page(tt.limit, tt.offset), " LIMIT $3 OFFSET $4"; got != want {
+				t.Errorf("page() = %q, want %q", got, want)
+			}
+			want := []interface{}{"user", "expense", tt.wantLimit, tt.wantOffset}
+			if got := qb.params(); !reflect.DeepEqual(got, want) {
+				t.Errorf("params() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+type RebalanceRepository interface {
+	GetTargets(ctx context.Context, portfolioID uuid.UUID) ([]models.PortfolioTarget, error)
+	// ReplaceTargets заменяет все целевые доли портфеля; отметки об уведомлениях сбрасываются
+	ReplaceTargets(ctx context.Context, portfolioID uuid.UUID, targets []models.PortfolioTarget) error
+	SetAlerted(ctx context.Context, portfolioID, securityID uuid.UUID, alertedAt *time.Time) error
+	GetThreshold(ctx context.Context, portfolioID uuid.UUID) (*decimal.Decimal, error)
+	SetThreshold(ctx context.Context, portfolioID uuid.UUID, threshold *decimal.Decimal) error
+	// GetWatchedPortfolioIDs активные портфели с порогом и целевыми долями
+	GetWatchedPortfolioIDs(ctx context.Context) ([]uuid.UUID, error)
+}
+
+type rebalanceRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRebalanceRepository(pool *pgxpool.Pool) RebalanceRepository {
+	return &rebalanceRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *rebalanceRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *rebalanceRepository) GetTargets(ctx context.Context, portfolioID uuid.UUID) ([]models.PortfolioTarget, error) {
+	query := `
+		SELECT portfolio_id, security_id, weight, alerted_at
+		FROM portfolio_targets
+		WHERE portfolio_id = $1
+		ORDER BY weight DESC
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []models.PortfolioTarget
+	for rows.Next() {
+		var t models.PortfolioTarget
+		if err := rows.Scan(&t.PortfolioID, &t.SecurityID, &t.Weight, &t.AlertedAt); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+func (r *rebalanceRepository) ReplaceTargets(ctx context.Context, portfolioID uuid.UUID, targets []models.PortfolioTarget) error {
+	if _, err := r.db(ctx).Exec(ctx, `DELETE FROM portfolio_targets WHERE portfolio_id = $1`, portfolioID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO portfolio_targets (portfolio_id, security_id, weight) VALUES ($1, $2, $3)`
+	for _, t := range targets {
+		if _, err := r.db(ctx).Exec(ctx, query, portfolioID, t.SecurityID, t.Weight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *rebalanceRepository) SetAlerted(ctx context.Context, portfolioID, securityID uuid.UUID, alertedAt *time.Time) error {
+	query := `UPDATE portfolio_targets SET alerted_at = $3 WHERE portfolio_id = $1 AND security_id = $2`
+	_, err := r.db(ctx).Exec(ctx, query, portfolioID, securityID, alertedAt)
+	return err
+}
+
+func (r *rebalanceRepository) GetThreshold(ctx context.Context, portfolioID uuid.UUID) (*decimal.Decimal, error) {
+	var threshold *decimal.Decimal
+	err := r.db(ctx).QueryRow(ctx, `SELECT rebalance_threshold FROM portfolios WHERE id = $1`, portfolioID).Scan(&threshold)
+	return threshold, err
+}
+
+func (r *rebalanceRepository) SetThreshold(ctx context.Context, portfolioID uuid.UUID, threshold *decimal.Decimal) error {
+	query := `UPDATE portfolios SET rebalance_threshold = $2, updated_at = $3 WHERE id = $1`
+	_, err := r.db(ctx).Exec(ctx, query, portfolioID, threshold, time.Now())
+	return err
+}
+
+func (r *rebalanceRepository) GetWatchedPortfolioIDs(ctx context.Context) ([]uuid.UUID, error) {
+	query := `
+		SELECT p.id
+		FROM portfolios p
+		WHERE p.rebalance_threshold IS NOT NULL AND p.is_active = true
+		  AND EXISTS (SELECT 1 FROM portfolio_targets t WHERE t.portfolio_id = p.id)
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
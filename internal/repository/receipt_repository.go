@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReceiptRepository interface {
+	Create(ctx context.Context, receipt *models.Receipt) error
+	// Exists - чек уже импортирован пользователем (транзакция не удалена)
+	Exists(ctx context.Context, userID uuid.UUID, fn, fd, fp string) (bool, error)
+}
+
+type receiptRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewReceiptRepository(pool *pgxpool.Pool) ReceiptRepository {
+	return &receiptRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *receiptRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *receiptRepository) Create(ctx context.Context, receipt *models.Receipt) error {
+	query := `
+		INSERT INTO receipts (id, user_id, transaction_id, fn, fd, fp, seller, seller_inn, retail_place, address, total, date, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	if receipt.ID == uuid.Nil {
+		receipt.ID = uuid.New()
+	}
+	receipt.CreatedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		receipt.ID, receipt.UserID, receipt.TransactionID, receipt.FN, receipt.FD, receipt.FP,
+		receipt.Seller, receipt.SellerINN, receipt.RetailPlace, receipt.Address,
+		receipt.Total, receipt.Date, receipt.CreatedAt,
+	)
+	return err
+}
+
+func (r *receiptRepository) Exists(ctx context.Context, userID uuid.UUID, fn, fd, fp string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM receipts rc
+			JOIN transactions t ON t.id = rc.transaction_id
+			WHERE rc.user_id = $1 AND rc.fn = $2 AND rc.fd = $3 AND rc.fp = $4 AND t.deleted_at IS NULL
+		)
+	`
+	var exists bool
+	err := r.db(ctx).QueryRow(ctx, query, userID, fn, fd, fp).Scan(&exists)
+	return exists, err
+}
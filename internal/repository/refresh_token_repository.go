@@ -6,25 +6,47 @@ import (
 	"encoding/hex"
 	"time"
 
+	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type RefreshToken struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    uuid.UUID `json:"user_id"`
-	TokenHash string    `json:"-"`
-	ExpiresAt time.Time `json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
-	RevokedAt time.Time `json:"revoked_at"`
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	FamilyID   uuid.UUID  `json:"family_id"` // общий для всей цепочки ротаций одного входа
+	TokenHash  string     `json:"-"`
+	RememberMe bool       `json:"remember_me"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	// RevokedReason почему отозван: повторное предъявление ротированного токена - кража, отозванного иначе - нет
+	RevokedReason string `json:"revoked_reason,omitempty"`
 }
 
+const (
+	RevokeReasonRotated = "rotated" // обменян на новую пару
+	RevokeReasonRevoked = "revoked" // выход, отзыв сессии или всей цепочки
+)
+
 type RefreshTokenRepository interface {
-	Create(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time) error
+	Create(ctx context.Context, rt *RefreshToken, token string) error
+	// GetByToken возвращает токен в любом состоянии (в т.ч. отозванный) - для детекта повторного использования
 	GetByToken(ctx context.Context, token string) (*RefreshToken, error)
+	// Rotate атомарно отзывает действующий токен как ротированный и возвращает его; pgx.ErrNoRows - токена нет или он уже отозван
+	Rotate(ctx context.Context, token string) (*RefreshToken, error)
 	Revoke(cxt context.Context, token string) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
 	RevokeAllForUser(cxt context.Context, userID uuid.UUID) error
+	GetActiveSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
 	DeleteExpired(ctx context.Context) error
+
+	CreateSecurityEvent(ctx context.Context, event *models.SecurityEvent) error
+	GetSecurityEvents(ctx context.Context, userID uuid.UUID, limit int) ([]models.SecurityEvent, error)
 }
 
 type refreshTokenRepository struct {
@@ -35,38 +57,54 @@ func NewRefreshTokenRepository(pool *pgxpool.Pool) RefreshTokenRepository {
 	return &refreshTokenRepository{pool: pool}
 }
 
+// db возвращает транзакцию из контекста или pool
+func (r *refreshTokenRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
 func hashToken(token string) string {
 	hash := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(hash[:])
 }
 
-func (r *refreshTokenRepository) Create(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time) error {
+func (r *refreshTokenRepository) Create(ctx context.Context, rt *RefreshToken, token string) error {
 	query := `
-		INSERT INTO refresh_tokens (id, user_id, token_hash, expires_at, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, remember_me, user_agent, ip_address, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 
-	_, err := r.pool.Exec(ctx, query,
-		uuid.New(),
-		userID,
-		hashToken(token),
-		expiresAt,
-		time.Now(),
+	if rt.ID == uuid.Nil {
+		rt.ID = uuid.New()
+	}
+	// новый вход - новое семейство
+	if rt.FamilyID == uuid.Nil {
+		rt.FamilyID = rt.ID
+	}
+	rt.TokenHash = hashToken(token)
+	rt.CreatedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		rt.ID,
+		rt.UserID,
+		rt.FamilyID,
+		rt.TokenHash,
+		rt.RememberMe,
+		rt.UserAgent,
+		rt.IPAddress,
+		rt.ExpiresAt,
+		rt.CreatedAt,
 	)
 	return err
 }
 
-func (r *refreshTokenRepository) GetByToken(ctx context.Context, token string) (*RefreshToken, error) {
-	query := `
-		SELECT id, user_id, token_hash, expires_at, created_at, revoked_at
-		FROM refresh_tokens
-		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
-	`
+const refreshTokenColumns = `id, user_id, COALESCE(family_id, id), token_hash, COALESCE(remember_me, true), COALESCE(user_agent, ''), COALESCE(ip_address, ''), expires_at, created_at, last_used_at, revoked_at, COALESCE(revoked_reason, '')`
 
+func scanRefreshToken(row pgx.Row) (*RefreshToken, error) {
 	var rt RefreshToken
-	err := r.pool.QueryRow(ctx, query, hashToken(token)).Scan(
-		&rt.ID, &rt.UserID, &rt.TokenHash,
-		&rt.ExpiresAt, &rt.CreatedAt, &rt.RevokedAt,
+	err := row.Scan(
+		&rt.ID, &rt.UserID, &rt.FamilyID, &rt.TokenHash,
+		&rt.RememberMe, &rt.UserAgent, &rt.IPAddress,
+		&rt.ExpiresAt, &rt.CreatedAt, &rt.LastUsedAt, &rt.RevokedAt, &rt.RevokedReason,
 	)
 	if err != nil {
 		return nil, err
@@ -74,20 +112,111 @@ func (r *refreshTokenRepository) GetByToken(ctx context.Context, token string) (
 	return &rt, nil
 }
 
+func (r *refreshTokenRepository) GetByToken(ctx context.Context, token string) (*RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1`
+	return scanRefreshToken(r.db(ctx).QueryRow(ctx, query, hashToken(token)))
+}
+
+func (r *refreshTokenRepository) Rotate(ctx context.Context, token string) (*RefreshToken, error) {
+	// условие revoked_at IS NULL под блокировкой строки: из двух параллельных обменов проходит один
+	query := `
+		UPDATE refresh_tokens SET revoked_at = NOW(), last_used_at = NOW(), revoked_reason = $2
+		WHERE token_hash = $1 AND revoked_at IS NULL
+		RETURNING ` + refreshTokenColumns
+	return scanRefreshToken(r.db(ctx).QueryRow(ctx, query, hashToken(token), RevokeReasonRotated))
+}
+
 func (r refreshTokenRepository) Revoke(ctx context.Context, token string) error {
-	query := `UPDATE refresh_tokens SET revoked_at = NOW() where token_hash = $1 AND revoked_at IS NULL`
-	_, err := r.pool.Exec(ctx, query, hashToken(token))
+	query := `UPDATE refresh_tokens SET revoked_at = NOW(), last_used_at = NOW(), revoked_reason = $2 where token_hash = $1 AND revoked_at IS NULL`
+	_, err := r.db(ctx).Exec(ctx, query, hashToken(token), RevokeReasonRevoked)
+	return err
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW(), revoked_reason = $2 where family_id = $1 AND revoked_at IS NULL`
+	_, err := r.db(ctx).Exec(ctx, query, familyID, RevokeReasonRevoked)
 	return err
 }
 
 func (r *refreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
-	query := `UPDATE refresh_tokens SET revoked_at = NOW() where _id = $1 AND revoked_at IS NULL`
-	_, err := r.pool.Exec(ctx, query, userID)
+	query := `UPDATE refresh_tokens SET revoked_at = NOW(), revoked_reason = $2 where user_id = $1 AND revoked_at IS NULL`
+	_, err := r.db(ctx).Exec(ctx, query, userID, RevokeReasonRevoked)
 	return err
 }
 
+// GetActiveSessions возвращает по одному действующему токену на семейство
+func (r *refreshTokenRepository) GetActiveSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	query := `
+		SELECT COALESCE(family_id, id), COALESCE(user_agent, ''), COALESCE(ip_address, ''), COALESCE(remember_me, true), created_at, last_used_at, expires_at
+		FROM refresh_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.IPAddress, &s.RememberMe, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
 func (r *refreshTokenRepository) DeleteExpired(cxt context.Context) error {
-	query := `DELETE FROM refresh_tokens WHERE expires_at < NOW() OR revoked_at IS NOT NULL`
-	_, err := r.pool.Exec(cxt, query)
+	// отозванные храним еще неделю, чтобы ловить повторное использование украденных токенов
+	query := `DELETE FROM refresh_tokens WHERE expires_at < NOW() OR revoked_at < NOW() - INTERVAL '7 days'`
+	_, err := r.db(cxt).Exec(cxt, query)
 	return err
 }
+
+func (r *refreshTokenRepository) CreateSecurityEvent(ctx context.Context, event *models.SecurityEvent) error {
+	query := `
+		INSERT INTO security_events (id, user_id, type, family_id, user_agent, ip_address, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	event.CreatedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		event.ID, event.UserID, event.Type, event.FamilyID,
+		event.UserAgent, event.IPAddress, event.Details, event.CreatedAt,
+	)
+	return err
+}
+
+func (r *refreshTokenRepository) GetSecurityEvents(ctx context.Context, userID uuid.UUID, limit int) ([]models.SecurityEvent, error) {
+	query := `
+		SELECT id, user_id, type, family_id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), COALESCE(details, ''), created_at
+		FROM security_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.SecurityEvent
+	for rows.Next() {
+		var e models.SecurityEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Type, &e.FamilyID, &e.UserAgent, &e.IPAddress, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
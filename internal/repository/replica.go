@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// сколько не отправлять запросы на реплику после ошибки соединения с ней
+const replicaRetryAfter = 30 * time.Second
+
+// readReplica пул реплики только для чтения, задается при старте через SetReadReplica.
+// nil - реплики нет, все запросы идут в основную бд
+var readReplica *pgxpool.Pool
+
+// replicaDownUntil до какого момента (unix nano) реплика считается недоступной
+var replicaDownUntil atomic.Int64
+
+func SetReadReplica(pool *pgxpool.Pool) {
+	readReplica = pool
+}
+
+type replicaKey struct{}
+
+// WithReadReplica разрешает отдавать чтения в этом контексте реплике: тяжелые отчеты и списки,
+// где небольшое отставание реплики не страшно. записи и транзакции все равно идут в основную бд
+func WithReadReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaKey{}, true)
+}
+
+// replicaFor пул реплики для запроса или nil, если читать нужно из основной бд
+func replicaFor(ctx context.Context) *pgxpool.Pool {
+	if readReplica == nil {
+		return nil
+	}
+	if on, _ := ctx.Value(replicaKey{}).(bool); !on {
+		return nil
+	}
+	if time.Now().UnixNano() < replicaDownUntil.Load() {
+		return nil
+	}
+	return readReplica
+}
+
+// replicaDB читает с реплики, при ее недоступности - из основной бд; Exec всегда идет в основную
+type replicaDB struct {
+	replica *pgxpool.Pool
+	primary *retryDB
+}
+
+func (db *replicaDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return db.primary.Exec(ctx, sql, args...)
+}
+
+func (db *replicaDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := db.replica.Query(ctx, sql, args...)
+	if err != nil && fallbackToPrimary(err) {
+		return db.primary.Query(ctx, sql, args...)
+	}
+	return rows, err
+}
+
+func (db *replicaDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return &replicaRow{db: db, ctx: ctx, sql: sql, args: args}
+}
+
+type replicaRow struct {
+	db   *replicaDB
+	ctx  context.Context
+	sql  string
+	args []interface{}
+}
+
+func (r *replicaRow) Scan(dest ...interface{}) error {
+	err := r.db.replica.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	if err != nil && fallbackToPrimary(err) {
+		return r.db.primary.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	}
+	return err
+}
+
+// fallbackToPrimary нужно ли повторить запрос в основной бд. при потере соединения реплика
+// на время выключается; запись через QueryRow (INSERT ... RETURNING) просто уходит в основную бд
+func fallbackToPrimary(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// read_only_sql_transaction или конфликт с применением WAL на реплике (40001)
+		if pgErr.Code == "25006" || pgErr.Code == "40001" {
+			return true
+		}
+	}
+
+	var connectErr *pgconn.ConnectError
+	if !errors.As(err, &connectErr) && !isTransientError(err) {
+		return false
+	}
+	if replicaDownUntil.Swap(time.Now().Add(replicaRetryAfter).UnixNano()) < time.Now().UnixNano() {
+		log.Printf("Реплика бд недоступна, чтения идут в основную бд: %v", err)
+	}
+	return true
+}
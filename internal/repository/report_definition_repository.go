@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReportDefinitionRepository interface {
+	Create(ctx context.Context, report *models.ReportDefinition) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.ReportDefinition, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ReportDefinition, error)
+	Update(ctx context.Context, report *models.ReportDefinition) error
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+	// Aggregate сумма и число операций по сочетаниям разбивок, месяцам и валютам
+	Aggregate(ctx context.Context, userID uuid.UUID, spec *models.ReportSpec, from, to time.Time) ([]models.ReportCell, error)
+}
+
+type reportDefinitionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewReportDefinitionRepository(pool *pgxpool.Pool) ReportDefinitionRepository {
+	return &reportDefinitionRepository{pool: pool}
+}
+
+func (r *reportDefinitionRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const reportDefinitionColumns = `id, user_id, name, spec, created_at, updated_at`
+
+func scanReportDefinition(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.ReportDefinition, error) {
+	var report models.ReportDefinition
+	var spec []byte
+	if err := row.Scan(&report.ID, &report.UserID, &report.Name, &spec, &report.CreatedAt, &report.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(spec, &report.ReportSpec); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r *reportDefinitionRepository) Create(ctx context.Context, report *models.ReportDefinition) error {
+	query := `
+		INSERT INTO report_definitions (id, user_id, name, spec, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	spec, err := json.Marshal(report.ReportSpec)
+	if err != nil {
+		return err
+	}
+
+	if report.ID == uuid.Nil {
+		report.ID = uuid.New()
+	}
+	now := time.Now()
+	report.CreatedAt = now
+	report.UpdatedAt = now
+
+	_, err = r.db(ctx).Exec(ctx, query, report.ID, report.UserID, report.Name, spec, report.CreatedAt, report.UpdatedAt)
+	return err
+}
+
+func (r *reportDefinitionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.ReportDefinition, error) {
+	query := `SELECT ` + reportDefinitionColumns + ` FROM report_definitions WHERE id = $1`
+	return scanReportDefinition(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *reportDefinitionRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ReportDefinition, error) {
+	query := `SELECT ` + reportDefinitionColumns + ` FROM report_definitions WHERE user_id = $1 ORDER BY name, created_at`
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []models.ReportDefinition
+	for rows.Next() {
+		report, err := scanReportDefinition(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, rows.Err()
+}
+
+func (r *reportDefinitionRepository) Update(ctx context.Context, report *models.ReportDefinition) error {
+	spec, err := json.Marshal(report.ReportSpec)
+	if err != nil {
+		return err
+	}
+	report.UpdatedAt = time.Now()
+
+	_, err = r.db(ctx).Exec(ctx, `UPDATE report_definitions SET name = $2, spec = $3, updated_at = $4 WHERE id = $1`,
+		report.ID, report.Name, spec, report.UpdatedAt)
+	return err
+}
+
+func (r *reportDefinitionRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db(ctx).Exec(ctx, `DELETE FROM report_definitions WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// reportDimensions ключ, название и join для разбивки пользовательского отчета
+var reportDimensions = map[models.ReportDimension]struct{ key, name, join string }{
+	models.ReportByCategory: {"COALESCE(t.category_id::text, '')", "COALESCE(c.name, '')", "LEFT JOIN categories c ON c.id = t.category_id"},
+	models.ReportByAccount:  {"t.account_id::text", "COALESCE(a.name, '')", "LEFT JOIN accounts a ON a.id = t.account_id"},
+	models.ReportByTag:      {"COALESCE(tt.tag, '')", "COALESCE(tt.tag, '')", "LEFT JOIN transaction_tags tt ON tt.transaction_id = t.id"},
+	models.ReportByMonth:    {"TO_CHAR(t.date, 'YYYY-MM')", "TO_CHAR(t.date, 'YYYY-MM')", ""},
+}
+
+func (r *reportDefinitionRepository) Aggregate(ctx context.Context, userID uuid.UUID, spec *models.ReportSpec, from, to time.Time) ([]models.ReportCell, error) {
+	// разбивки только из белого списка; месяц и валюта нужны всегда - для пересчета по курсу
+	columns := []string{"TO_CHAR(t.date, 'YYYY-MM')", "t.currency"}
+	var joins []string
+	for _, dimension := range spec.Dimensions {
+		dim, ok := reportDimensions[dimension]
+		if !ok {
+			return nil, fmt.Errorf("unknown report dimension %q", dimension)
+		}
+		columns = append(columns, dim.key, dim.name)
+		if dim.join != "" {
+			joins = append(joins, dim.join)
+		}
+	}
+
+	f := spec.Filters
+	qb := newQueryBuilder(userID, from, to, f.Type).
+		whereIf(len(f.AccountIDs) > 0, "t.account_id = ANY(?)", f.AccountIDs).
+		whereIf(len(f.CategoryIDs) > 0, "(t.category_id = ANY(?) OR t.category_id IN (SELECT id FROM categories WHERE parent_id = ANY(?)))", f.CategoryIDs, f.CategoryIDs).
+		whereIf(len(f.Tags) > 0, "t.id IN (SELECT transaction_id FROM transaction_tags WHERE tag = ANY(?))", f.Tags).
+		whereIf(f.AmountMin != nil, "t.amount >= ?", f.AmountMin).
+		whereIf(f.AmountMax != nil, "t.amount <= ?", f.AmountMax)
+
+	groupBy := make([]string, len(columns))
+	for i := range columns {
+		groupBy[i] = fmt.Sprint(i + 1)
+	}
+	query := `
+		SELECT ` + strings.Join(columns, ", ") + `, SUM(t.amount), COUNT(*)
+		FROM transactions t
+		` + strings.Join(joins, "\n\t\t") + `
+		WHERE t.user_id = $1 AND t.date >= $2 AND t.date <= $3 AND t.type = $4 AND t.deleted_at IS NULL` + qb.and() + `
+		GROUP BY ` + strings.Join(groupBy, ", ")
+
+	rows, err := r.db(ctx).Query(ctx, query, qb.params()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cells []models.ReportCell
+	for rows.Next() {
+		cell := models.ReportCell{
+			Keys:  make([]string, len(spec.Dimensions)),
+			Names: make([]string, len(spec.Dimensions)),
+		}
+		dest := []interface{}{&cell.Month, &cell.Currency}
+		for i := range spec.Dimensions {
+			dest = append(dest, &cell.Keys[i], &cell.Names[i])
+		}
+		dest = append(dest, &cell.Sum, &cell.Count)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		cells = append(cells, cell)
+	}
+	return cells, rows.Err()
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReportSubscriptionRepository interface {
+	Create(ctx context.Context, sub *models.ReportSubscription) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.ReportSubscription, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ReportSubscription, error)
+	// GetDue включенные подписки, время отправки которых наступило
+	GetDue(ctx context.Context, now time.Time) ([]models.ReportSubscription, error)
+	Update(ctx context.Context, sub *models.ReportSubscription) error
+	// SetSent сдвигает расписание на следующую отправку; lastError пустой - письмо ушло
+	SetSent(ctx context.Context, id uuid.UUID, sentAt, nextSendAt time.Time, lastError string) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type reportSubscriptionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewReportSubscriptionRepository(pool *pgxpool.Pool) ReportSubscriptionRepository {
+	return &reportSubscriptionRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *reportSubscriptionRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const reportSubscriptionColumns = `id, user_id, frequency, include_summary, include_budgets, include_portfolio, weekday, day_of_month, hour, is_active, last_sent_at, next_send_at, COALESCE(last_error, ''), created_at, updated_at`
+
+func scanReportSubscription(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.ReportSubscription, error) {
+	var s models.ReportSubscription
+	err := row.Scan(
+		&s.ID, &s.UserID, &s.Frequency, &s.IncludeSummary, &s.IncludeBudgets, &s.IncludePortfolio,
+		&s.Weekday, &s.DayOfMonth, &s.Hour, &s.IsActive, &s.LastSentAt, &s.NextSendAt, &s.LastError,
+		&s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *reportSubscriptionRepository) Create(ctx context.Context, sub *models.ReportSubscription) error {
+	query := `
+		INSERT INTO report_subscriptions (id, user_id, frequency, include_summary, include_budgets, include_portfolio, weekday, day_of_month, hour, is_active, next_send_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	if sub.ID == uuid.Nil {
+		sub.ID = uuid.New()
+	}
+	now := time.Now()
+	sub.CreatedAt = now
+	sub.UpdatedAt = now
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		sub.ID, sub.UserID, sub.Frequency, sub.IncludeSummary, sub.IncludeBudgets, sub.IncludePortfolio,
+		sub.Weekday, sub.DayOfMonth, sub.Hour, sub.IsActive, sub.NextSendAt, sub.CreatedAt, sub.UpdatedAt,
+	)
+	return err
+}
+
+func (r *reportSubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.ReportSubscription, error) {
+	query := `SELECT ` + reportSubscriptionColumns + ` FROM report_subscriptions WHERE id = $1`
+	return scanReportSubscription(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *reportSubscriptionRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ReportSubscription, error) {
+	query := `SELECT ` + reportSubscriptionColumns + ` FROM report_subscriptions WHERE user_id = $1 ORDER BY frequency`
+	return r.list(ctx, query, userID)
+}
+
+func (r *reportSubscriptionRepository) GetDue(ctx context.Context, now time.Time) ([]models.ReportSubscription, error) {
+	query := `
+		SELECT ` + reportSubscriptionColumns + ` FROM report_subscriptions
+		WHERE is_active = true AND next_send_at <= $1
+		ORDER BY next_send_at
+	`
+	return r.list(ctx, query, now)
+}
+
+func (r *reportSubscriptionRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.ReportSubscription, error) {
+	rows, err := r.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []models.ReportSubscription
+	for rows.Next() {
+		s, err := scanReportSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *s)
+	}
+	return subs, rows.Err()
+}
+
+func (r *reportSubscriptionRepository) Update(ctx context.Context, sub *models.ReportSubscription) error {
+	query := `
+		UPDATE report_subscriptions SET
+			include_summary = $2, include_budgets = $3, include_portfolio = $4, weekday = $5, day_of_month = $6,
+			hour = $7, is_active = $8, next_send_at = $9, updated_at = $10
+		WHERE id = $1
+	`
+
+	sub.UpdatedAt = time.Now()
+	_, err := r.db(ctx).Exec(ctx, query,
+		sub.ID, sub.IncludeSummary, sub.IncludeBudgets, sub.IncludePortfolio, sub.Weekday, sub.DayOfMonth,
+		sub.Hour, sub.IsActive, sub.NextSendAt, sub.UpdatedAt,
+	)
+	return err
+}
+
+func (r *reportSubscriptionRepository) SetSent(ctx context.Context, id uuid.UUID, sentAt, nextSendAt time.Time, lastError string) error {
+	query := `
+		UPDATE report_subscriptions SET
+			last_sent_at = CASE WHEN $4 = '' THEN $2 ELSE last_sent_at END,
+			next_send_at = $3, last_error = NULLIF($4, ''), updated_at = $2
+		WHERE id = $1
+	`
+	_, err := r.db(ctx).Exec(ctx, query, id, sentAt, nextSendAt, lastError)
+	return err
+}
+
+func (r *reportSubscriptionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM report_subscriptions WHERE id = $1`, id)
+	return err
+}
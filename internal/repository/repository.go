@@ -1,10 +1,22 @@
 package repository
 
 import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// isUniqueViolation нарушение уникального индекса или ограничения с этим именем
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+}
+
 type Repositories struct {
+	pool *pgxpool.Pool
+
 	TxManager    TxManager
 	User         UserRepository
 	RefreshToken RefreshTokenRepository
@@ -17,10 +29,44 @@ type Repositories struct {
 	Security     SecurityRepository
 	Holding      HoldingRepository
 	Investment   InvestmentTransactionRepository
+	Deletion     AccountDeletionRepository
+	Sector       SectorMappingRepository
+	SecurityType SecurityTypeOverrideRepository
+	Payee        PayeeRepository
+	Planned      PlannedTransactionRepository
+	PriceHistory PriceHistoryRepository
+	Envelope     EnvelopeRepository
+
+	MailConnection   MailConnectionRepository
+	TransactionDraft TransactionDraftRepository
+	Receipt          ReceiptRepository
+	Webhook          WebhookRepository
+	ReportSub        ReportSubscriptionRepository
+	CustomAsset      CustomAssetRepository
+	Rebalance        RebalanceRepository
+	Usage            UsageRepository
+	HoldingMetadata  HoldingMetadataRepository
+	Deposit          DepositRepository
+	Notification     NotificationRepository
+	Tag              TagRepository
+	IIS              IISRepository
+	Product          ProductRepository
+	HealthSnapshot   HealthSnapshotRepository
+	Telegram         TelegramRepository
+	Redenomination   CurrencyRedenominationRepository
+	Drawdown         DrawdownRepository
+	Fundamentals     FundamentalsRepository
+	AccountRule      AccountRuleRepository
+	ReportDefinition ReportDefinitionRepository
+	LoginAttempt     LoginAttemptRepository
+	CSVImport        CSVImportRepository
+	Challenge        ChallengeRepository
+	AIRecommendation AIRecommendationRepository
 }
 
 func NewRepositories(pool *pgxpool.Pool) *Repositories {
 	return &Repositories{
+		pool:         pool,
 		TxManager:    NewTxManager(pool),
 		User:         NewUserRepository(pool),
 		RefreshToken: NewRefreshTokenRepository(pool),
@@ -33,5 +79,43 @@ func NewRepositories(pool *pgxpool.Pool) *Repositories {
 		Security:     NewSecurityRepository(pool),
 		Holding:      NewHoldingRepository(pool),
 		Investment:   NewInvestmentTransactionRepository(pool),
+		Deletion:     NewAccountDeletionRepository(pool),
+		Sector:       NewSectorMappingRepository(pool),
+		SecurityType: NewSecurityTypeOverrideRepository(pool),
+		Payee:        NewPayeeRepository(pool),
+		Planned:      NewPlannedTransactionRepository(pool),
+		PriceHistory: NewPriceHistoryRepository(pool),
+		Envelope:     NewEnvelopeRepository(pool),
+
+		MailConnection:   NewMailConnectionRepository(pool),
+		TransactionDraft: NewTransactionDraftRepository(pool),
+		Receipt:          NewReceiptRepository(pool),
+		Webhook:          NewWebhookRepository(pool),
+		ReportSub:        NewReportSubscriptionRepository(pool),
+		CustomAsset:      NewCustomAssetRepository(pool),
+		Rebalance:        NewRebalanceRepository(pool),
+		Usage:            NewUsageRepository(pool),
+		HoldingMetadata:  NewHoldingMetadataRepository(pool),
+		Deposit:          NewDepositRepository(pool),
+		Notification:     NewNotificationRepository(pool),
+		Tag:              NewTagRepository(pool),
+		IIS:              NewIISRepository(pool),
+		Product:          NewProductRepository(pool),
+		HealthSnapshot:   NewHealthSnapshotRepository(pool),
+		Telegram:         NewTelegramRepository(pool),
+		Redenomination:   NewCurrencyRedenominationRepository(pool),
+		Drawdown:         NewDrawdownRepository(pool),
+		Fundamentals:     NewFundamentalsRepository(pool),
+		AccountRule:      NewAccountRuleRepository(pool),
+		ReportDefinition: NewReportDefinitionRepository(pool),
+		LoginAttempt:     NewLoginAttemptRepository(pool),
+		CSVImport:        NewCSVImportRepository(pool),
+		Challenge:        NewChallengeRepository(pool),
+		AIRecommendation: NewAIRecommendationRepository(pool),
 	}
 }
+
+// Ping проверяет соединение с бд (для readiness)
+func (r *Repositories) Ping(ctx context.Context) error {
+	return r.pool.Ping(ctx)
+}
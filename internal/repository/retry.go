@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RetryPolicy сколько раз повторять запрос при временной ошибке соединения и пауза между попытками
+type RetryPolicy struct {
+	Attempts int
+	Backoff  time.Duration
+}
+
+// retryPolicy общая для всех репозиториев, задается при старте через SetRetryPolicy
+var retryPolicy = RetryPolicy{Attempts: 3, Backoff: 100 * time.Millisecond}
+
+func SetRetryPolicy(policy RetryPolicy) {
+	if policy.Attempts < 1 {
+		policy.Attempts = 1
+	}
+	retryPolicy = policy
+}
+
+// retryDB обертка над pool, повторяющая запросы при временных ошибках.
+// внутри транзакции не используется: там повтор одного запроса небезопасен
+type retryDB struct {
+	pool *pgxpool.Pool
+}
+
+func (db *retryDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	var tag pgconn.CommandTag
+	err := withRetry(ctx, func() error {
+		var err error
+		tag, err = db.pool.Exec(ctx, sql, args...)
+		return err
+	})
+	return tag, err
+}
+
+func (db *retryDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	var rows pgx.Rows
+	err := withRetry(ctx, func() error {
+		var err error
+		rows, err = db.pool.Query(ctx, sql, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (db *retryDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return &retryRow{pool: db.pool, ctx: ctx, sql: sql, args: args}
+}
+
+// retryRow откладывает запрос до Scan, т.к. pgx.Row отдает ошибку только там
+type retryRow struct {
+	pool *pgxpool.Pool
+	ctx  context.Context
+	sql  string
+	args []interface{}
+}
+
+func (r *retryRow) Scan(dest ...interface{}) error {
+	return withRetry(r.ctx, func() error {
+		return r.pool.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	})
+}
+
+func withRetry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= retryPolicy.Attempts || !isTransientError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryPolicy.Backoff * time.Duration(attempt)):
+		}
+	}
+}
+
+// isTransientError - ошибки, после которых запрос точно не был применен и его можно повторить
+func isTransientError(err error) bool {
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"57P03": // cannot_connect_now (бд стартует)
+			return true
+		}
+		// класс 08 - ошибки соединения
+		return len(pgErr.Code) == 5 && pgErr.Code[:2] == "08"
+	}
+	return false
+}
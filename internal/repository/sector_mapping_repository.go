@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SectorMappingRepository interface {
+	Get(ctx context.Context, ticker string, exchange models.Exchange) (*models.SectorMapping, error)
+	List(ctx context.Context, exchange *models.Exchange) ([]models.SectorMapping, error)
+	// Upsert не перезаписывает ручные привязки автоматическими
+	Upsert(ctx context.Context, mapping *models.SectorMapping) error
+	Delete(ctx context.Context, ticker string, exchange models.Exchange) error
+}
+
+type sectorMappingRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSectorMappingRepository(pool *pgxpool.Pool) SectorMappingRepository {
+	return &sectorMappingRepository{pool: pool}
+}
+
+func (r *sectorMappingRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *sectorMappingRepository) Get(ctx context.Context, ticker string, exchange models.Exchange) (*models.SectorMapping, error) {
+	query := `
+		SELECT ticker, exchange, sector, COALESCE(industry, ''), source, updated_at
+		FROM sector_mappings
+		WHERE ticker = $1 AND exchange = $2
+	`
+
+	var m models.SectorMapping
+	err := r.db(ctx).QueryRow(ctx, query, ticker, exchange).Scan(
+		&m.Ticker, &m.Exchange, &m.Sector, &m.Industry, &m.Source, &m.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *sectorMappingRepository) List(ctx context.Context, exchange *models.Exchange) ([]models.SectorMapping, error) {
+	query := `
+		SELECT ticker, exchange, sector, COALESCE(industry, ''), source, updated_at
+		FROM sector_mappings
+		WHERE ($1::varchar IS NULL OR exchange = $1)
+		ORDER BY exchange, ticker
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, exchange)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mappings []models.SectorMapping
+	for rows.Next() {
+		var m models.SectorMapping
+		if err := rows.Scan(&m.Ticker, &m.Exchange, &m.Sector, &m.Industry, &m.Source, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+func (r *sectorMappingRepository) Upsert(ctx context.Context, mapping *models.SectorMapping) error {
+	query := `
+		INSERT INTO sector_mappings (ticker, exchange, sector, industry, source, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (ticker, exchange) DO UPDATE SET
+			sector = EXCLUDED.sector,
+			industry = EXCLUDED.industry,
+			source = EXCLUDED.source,
+			updated_at = EXCLUDED.updated_at
+		WHERE sector_mappings.source <> 'manual' OR EXCLUDED.source = 'manual'
+	`
+
+	mapping.UpdatedAt = time.Now()
+
+	_, err := r.db(ctx).Exec(ctx, query,
+		mapping.Ticker, mapping.Exchange, mapping.Sector, mapping.Industry, mapping.Source, mapping.UpdatedAt,
+	)
+	return err
+}
+
+func (r *sectorMappingRepository) Delete(ctx context.Context, ticker string, exchange models.Exchange) error {
+	query := `DELETE FROM sector_mappings WHERE ticker = $1 AND exchange = $2`
+	_, err := r.db(ctx).Exec(ctx, query, ticker, exchange)
+	return err
+}
@@ -2,12 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/shopspring/decimal"
 )
 
 type SecurityRepository interface {
@@ -15,10 +17,34 @@ type SecurityRepository interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Security, error)
 	GetByTicker(ctx context.Context, ticker string, exchange models.Exchange) (*models.Security, error)
 	GetByExchange(ctx context.Context, exchange models.Exchange) ([]models.Security, error)
-	Search(ctx context.Context, query string, limit int) ([]models.Security, error)
+	// GetByISIN биржевая бумага с таким ISIN на бирже (самая ранняя, если их несколько); nil - такой нет
+	GetByISIN(ctx context.Context, isin string, exchange models.Exchange) (*models.Security, error)
+	// FindDuplicates биржевые бумаги, похожие на одну и ту же: общий ISIN или тикер в одной валюте на разных биржах
+	FindDuplicates(ctx context.Context) ([]models.SecurityDuplicate, error)
+	// Merge переносит позиции, операции, цели, заметки, историю цен и дивиденды бумаги sourceID на targetID
+	// и удаляет sourceID. позиции одного портфеля складываются. вызывать в транзакции
+	Merge(ctx context.Context, sourceID, targetID uuid.UUID) (*models.SecurityMergeResult, error)
+	// Search поиск по тикеру, названию и ISIN; hidden - скрытые пользователем бумаги (nil - без исключений)
+	Search(ctx context.Context, query string, securityType *models.SecurityType, exchange *models.Exchange, hidden *models.SecurityPreferences, limit int) ([]models.Security, error)
+	// GetByOwner бумаги, заведенные пользователем вручную
+	GetByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.Security, error)
+	// Screen подбор бумаг по фильтру; второе значение - сколько всего бумаг подходит без учета страницы
+	Screen(ctx context.Context, filter *models.SecurityScreenerFilter) ([]models.ScreenerSecurity, int64, error)
 	Update(ctx context.Context, id uuid.UUID, security *models.Security) error
-	UpdatePrice(ctx context.Context, id uuid.UUID, price decimal.Decimal, change decimal.Decimal, changePercent decimal.Decimal, volume int64) error
+	// UpdatePrice сохраняет котировку как текущую цену бумаги (для облигаций - вместе с НКД)
+	UpdatePrice(ctx context.Context, id uuid.UUID, quote *models.MarketQuote) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	// GetWithoutSector возвращает активные бумаги с незаполненным сектором, сначала те, что дольше не проверялись
+	GetWithoutSector(ctx context.Context, limit int) ([]models.Security, error)
+	// MarkSectorChecked запоминает попытку определить сектор, чтобы следующий проход начал с других бумаг
+	MarkSectorChecked(ctx context.Context, ids []uuid.UUID) error
+	UpdateSector(ctx context.Context, ticker string, exchange models.Exchange, sector, industry string) (int64, error)
+	// UpdateType меняет тип всех сохраненных бумаг с тикером на бирже
+	UpdateType(ctx context.Context, ticker string, exchange models.Exchange, securityType models.SecurityType) (int64, error)
+
+	// GetPreferences скрытые пользователем бумаги; если настроек нет - пустые списки
+	GetPreferences(ctx context.Context, userID uuid.UUID) (*models.SecurityPreferences, error)
+	SetPreferences(ctx context.Context, userID uuid.UUID, prefs *models.SecurityPreferences) error
 }
 
 type securityRepository struct {
@@ -35,15 +61,22 @@ func (r *securityRepository) db(ctx context.Context) DBTX {
 
 func (r *securityRepository) Create(ctx context.Context, security *models.Security) error {
 	query := `
-		INSERT INTO securities (id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, last_price, price_change, price_change_percent, volume, updated_at, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)
-		ON CONFLICT (ticker, exchange) DO UPDATE SET
+		INSERT INTO securities (id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, underlying, expiry_date, strike, option_type, contract_multiplier, initial_margin, last_price, price_change, price_change_percent, volume, updated_at, created_at, owner_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32)
+		ON CONFLICT (ticker, exchange) WHERE owner_id IS NULL DO UPDATE SET
 			name = EXCLUDED.name,
 			short_name = EXCLUDED.short_name,
-			sector = EXCLUDED.sector,
-			industry = EXCLUDED.industry,
+			sector = COALESCE(NULLIF(EXCLUDED.sector, ''), securities.sector),
+			industry = COALESCE(NULLIF(EXCLUDED.industry, ''), securities.industry),
 			is_active = EXCLUDED.is_active,
+			underlying = COALESCE(NULLIF(EXCLUDED.underlying, ''), securities.underlying),
+			expiry_date = COALESCE(EXCLUDED.expiry_date, securities.expiry_date),
+			strike = COALESCE(EXCLUDED.strike, securities.strike),
+			option_type = COALESCE(NULLIF(EXCLUDED.option_type, ''), securities.option_type),
+			contract_multiplier = COALESCE(EXCLUDED.contract_multiplier, securities.contract_multiplier),
+			initial_margin = COALESCE(EXCLUDED.initial_margin, securities.initial_margin),
 			updated_at = EXCLUDED.updated_at
+		RETURNING id
 	`
 
 	if security.ID == uuid.Nil {
@@ -57,20 +90,23 @@ func (r *securityRepository) Create(ctx context.Context, security *models.Securi
 		security.LotSize = 1
 	}
 
-	_, err := r.db(ctx).Exec(ctx, query,
+	// при конфликте возвращается id уже существующей бумаги
+	return r.db(ctx).QueryRow(ctx, query,
 		security.ID, security.Ticker, security.ISIN, security.Name, security.ShortName,
 		security.Type, security.Exchange, security.Currency, security.Country,
 		security.Sector, security.Industry, security.LotSize, security.MinPriceIncrement,
 		security.IsActive, security.FaceValue, security.CouponRate, security.MaturityDate,
-		security.CouponFreq, security.ExpenseRatio, security.LastPrice, security.PriceChange,
+		security.CouponFreq, security.ExpenseRatio, security.Underlying, security.ExpiryDate,
+		security.Strike, security.OptionType, security.ContractMultiplier, security.InitialMargin,
+		security.LastPrice, security.PriceChange,
 		security.PriceChangePercent, security.Volume, security.UpdatedAt, security.CreatedAt,
-	)
-	return err
+		security.OwnerID,
+	).Scan(&security.ID)
 }
 
 func (r *securityRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Security, error) {
 	query := `
-		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, last_price, price_change, price_change_percent, volume, updated_at, created_at
+		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, COALESCE(underlying, ''), expiry_date, strike, COALESCE(option_type, ''), contract_multiplier, initial_margin, last_price, price_change, price_change_percent, volume, updated_at, created_at, owner_id
 		FROM securities
 		WHERE id = $1
 	`
@@ -81,8 +117,11 @@ func (r *securityRepository) GetByID(ctx context.Context, id uuid.UUID) (*models
 		&s.Type, &s.Exchange, &s.Currency, &s.Country,
 		&s.Sector, &s.Industry, &s.LotSize, &s.MinPriceIncrement,
 		&s.IsActive, &s.FaceValue, &s.CouponRate, &s.MaturityDate,
-		&s.CouponFreq, &s.ExpenseRatio, &s.LastPrice, &s.PriceChange,
+		&s.CouponFreq, &s.ExpenseRatio, &s.Underlying, &s.ExpiryDate,
+		&s.Strike, &s.OptionType, &s.ContractMultiplier, &s.InitialMargin,
+		&s.LastPrice, &s.PriceChange,
 		&s.PriceChangePercent, &s.Volume, &s.UpdatedAt, &s.CreatedAt,
+		&s.OwnerID,
 	)
 	if err != nil {
 		return nil, err
@@ -92,9 +131,9 @@ func (r *securityRepository) GetByID(ctx context.Context, id uuid.UUID) (*models
 
 func (r *securityRepository) GetByTicker(ctx context.Context, ticker string, exchange models.Exchange) (*models.Security, error) {
 	query := `
-		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, last_price, price_change, price_change_percent, volume, updated_at, created_at
+		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, COALESCE(underlying, ''), expiry_date, strike, COALESCE(option_type, ''), contract_multiplier, initial_margin, last_price, price_change, price_change_percent, volume, updated_at, created_at
 		FROM securities
-		WHERE ticker = $1 AND exchange = $2
+		WHERE ticker = $1 AND exchange = $2 AND owner_id IS NULL
 	`
 
 	var s models.Security
@@ -103,20 +142,157 @@ func (r *securityRepository) GetByTicker(ctx context.Context, ticker string, exc
 		&s.Type, &s.Exchange, &s.Currency, &s.Country,
 		&s.Sector, &s.Industry, &s.LotSize, &s.MinPriceIncrement,
 		&s.IsActive, &s.FaceValue, &s.CouponRate, &s.MaturityDate,
-		&s.CouponFreq, &s.ExpenseRatio, &s.LastPrice, &s.PriceChange,
+		&s.CouponFreq, &s.ExpenseRatio, &s.Underlying, &s.ExpiryDate,
+		&s.Strike, &s.OptionType, &s.ContractMultiplier, &s.InitialMargin,
+		&s.LastPrice, &s.PriceChange,
+		&s.PriceChangePercent, &s.Volume, &s.UpdatedAt, &s.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *securityRepository) GetByISIN(ctx context.Context, isin string, exchange models.Exchange) (*models.Security, error) {
+	query := `
+		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, COALESCE(underlying, ''), expiry_date, strike, COALESCE(option_type, ''), contract_multiplier, initial_margin, last_price, price_change, price_change_percent, volume, updated_at, created_at
+		FROM securities
+		WHERE isin = $1 AND exchange = $2 AND owner_id IS NULL
+		ORDER BY created_at
+		LIMIT 1
+	`
+
+	var s models.Security
+	err := r.db(ctx).QueryRow(ctx, query, isin, exchange).Scan(
+		&s.ID, &s.Ticker, &s.ISIN, &s.Name, &s.ShortName,
+		&s.Type, &s.Exchange, &s.Currency, &s.Country,
+		&s.Sector, &s.Industry, &s.LotSize, &s.MinPriceIncrement,
+		&s.IsActive, &s.FaceValue, &s.CouponRate, &s.MaturityDate,
+		&s.CouponFreq, &s.ExpenseRatio, &s.Underlying, &s.ExpiryDate,
+		&s.Strike, &s.OptionType, &s.ContractMultiplier, &s.InitialMargin,
+		&s.LastPrice, &s.PriceChange,
 		&s.PriceChangePercent, &s.Volume, &s.UpdatedAt, &s.CreatedAt,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &s, nil
 }
 
+func (r *securityRepository) FindDuplicates(ctx context.Context) ([]models.SecurityDuplicate, error) {
+	// группа по тикеру - только если хотя бы у одной бумаги нет ISIN, иначе она уже попала в группу по ISIN
+	// или это разные бумаги с одинаковым тикером
+	query := `
+		WITH groups AS (
+			SELECT 'isin' AS reason, isin AS key
+			FROM securities
+			WHERE owner_id IS NULL AND COALESCE(isin, '') <> ''
+			GROUP BY isin
+			HAVING COUNT(*) > 1
+			UNION ALL
+			SELECT 'ticker', UPPER(ticker) || ':' || currency
+			FROM securities
+			WHERE owner_id IS NULL
+			GROUP BY UPPER(ticker), currency
+			HAVING COUNT(*) > 1 AND bool_or(COALESCE(isin, '') = '') AND COUNT(DISTINCT NULLIF(isin, '')) <= 1
+		)
+		SELECT g.reason, g.key, s.id, s.ticker, COALESCE(s.isin, ''), s.name, s.type, s.exchange, s.currency, s.is_active, s.updated_at,
+		       (SELECT COUNT(*) FROM holdings h WHERE h.security_id = s.id),
+		       (SELECT COUNT(*) FROM investment_transactions t WHERE t.security_id = s.id)
+		FROM groups g
+		JOIN securities s ON s.owner_id IS NULL AND (
+			(g.reason = 'isin' AND s.isin = g.key) OR
+			(g.reason = 'ticker' AND UPPER(s.ticker) || ':' || s.currency = g.key))
+		ORDER BY g.reason, g.key, s.created_at
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var duplicates []models.SecurityDuplicate
+	for rows.Next() {
+		var d models.SecurityDuplicate
+		if err := rows.Scan(
+			&d.Reason, &d.Key, &d.ID, &d.Ticker, &d.ISIN, &d.Name, &d.Type, &d.Exchange, &d.Currency,
+			&d.IsActive, &d.UpdatedAt, &d.Holdings, &d.Transactions,
+		); err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, d)
+	}
+	return duplicates, rows.Err()
+}
+
+func (r *securityRepository) Merge(ctx context.Context, sourceID, targetID uuid.UUID) (*models.SecurityMergeResult, error) {
+	db := r.db(ctx)
+	result := &models.SecurityMergeResult{SourceID: sourceID, TargetID: targetID}
+
+	// позиции портфелей, где есть обе бумаги, складываем в позицию целевой
+	tag, err := db.Exec(ctx, `
+		UPDATE holdings t SET
+			quantity = t.quantity + s.quantity,
+			total_cost = t.total_cost + s.total_cost,
+			average_price = CASE WHEN t.quantity + s.quantity > 0
+				THEN (t.total_cost + s.total_cost) / (t.quantity + s.quantity) ELSE t.average_price END,
+			updated_at = NOW()
+		FROM holdings s
+		WHERE s.security_id = $1 AND t.security_id = $2 AND t.portfolio_id = s.portfolio_id
+	`, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	result.Holdings = tag.RowsAffected()
+
+	if _, err := db.Exec(ctx, `
+		DELETE FROM holdings h
+		WHERE h.security_id = $1 AND EXISTS (SELECT 1 FROM holdings t WHERE t.security_id = $2 AND t.portfolio_id = h.portfolio_id)
+	`, sourceID, targetID); err != nil {
+		return nil, err
+	}
+	if tag, err = db.Exec(ctx, `UPDATE holdings SET security_id = $2, updated_at = NOW() WHERE security_id = $1`, sourceID, targetID); err != nil {
+		return nil, err
+	}
+	result.Holdings += tag.RowsAffected()
+
+	if tag, err = db.Exec(ctx, `UPDATE investment_transactions SET security_id = $2 WHERE security_id = $1`, sourceID, targetID); err != nil {
+		return nil, err
+	}
+	result.Transactions = tag.RowsAffected()
+
+	// строки с тем же ключом у целевой бумаги важнее; оставшиеся у исходной удалятся каскадом вместе с ней
+	moves := []string{
+		`UPDATE portfolio_targets x SET security_id = $2 WHERE security_id = $1
+			AND NOT EXISTS (SELECT 1 FROM portfolio_targets y WHERE y.security_id = $2 AND y.portfolio_id = x.portfolio_id)`,
+		`UPDATE holding_metadata x SET security_id = $2 WHERE security_id = $1
+			AND NOT EXISTS (SELECT 1 FROM holding_metadata y WHERE y.security_id = $2 AND y.portfolio_id = x.portfolio_id)`,
+		`UPDATE price_history x SET security_id = $2 WHERE security_id = $1
+			AND NOT EXISTS (SELECT 1 FROM price_history y WHERE y.security_id = $2 AND y.date = x.date)`,
+		`UPDATE security_dividends x SET security_id = $2 WHERE security_id = $1
+			AND NOT EXISTS (SELECT 1 FROM security_dividends y WHERE y.security_id = $2 AND y.ex_date = x.ex_date AND y.dividend_type = x.dividend_type)`,
+	}
+	for _, query := range moves {
+		if _, err := db.Exec(ctx, query, sourceID, targetID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := db.Exec(ctx, `DELETE FROM securities WHERE id = $1`, sourceID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (r *securityRepository) GetByExchange(ctx context.Context, exchange models.Exchange) ([]models.Security, error) {
 	query := `
-		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, last_price, price_change, price_change_percent, volume, updated_at, created_at
+		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, COALESCE(underlying, ''), expiry_date, strike, COALESCE(option_type, ''), contract_multiplier, initial_margin, last_price, price_change, price_change_percent, volume, updated_at, created_at
 		FROM securities
-		WHERE exchange = $1 AND is_active = true
+		WHERE exchange = $1 AND is_active = true AND owner_id IS NULL
 		ORDER BY ticker
 	`
 
@@ -134,7 +310,9 @@ func (r *securityRepository) GetByExchange(ctx context.Context, exchange models.
 			&s.Type, &s.Exchange, &s.Currency, &s.Country,
 			&s.Sector, &s.Industry, &s.LotSize, &s.MinPriceIncrement,
 			&s.IsActive, &s.FaceValue, &s.CouponRate, &s.MaturityDate,
-			&s.CouponFreq, &s.ExpenseRatio, &s.LastPrice, &s.PriceChange,
+			&s.CouponFreq, &s.ExpenseRatio, &s.Underlying, &s.ExpiryDate,
+			&s.Strike, &s.OptionType, &s.ContractMultiplier, &s.InitialMargin,
+			&s.LastPrice, &s.PriceChange,
 			&s.PriceChangePercent, &s.Volume, &s.UpdatedAt, &s.CreatedAt,
 		)
 		if err != nil {
@@ -145,20 +323,62 @@ func (r *securityRepository) GetByExchange(ctx context.Context, exchange models.
 	return securities, rows.Err()
 }
 
-func (r *securityRepository) Search(ctx context.Context, query string, limit int) ([]models.Security, error) {
-	sqlQuery := `
-		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, last_price, price_change, price_change_percent, volume, updated_at, created_at
+func (r *securityRepository) GetByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.Security, error) {
+	query := `
+		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, COALESCE(sector, ''), COALESCE(industry, ''), lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, COALESCE(underlying, ''), expiry_date, strike, COALESCE(option_type, ''), contract_multiplier, initial_margin, last_price, price_change, price_change_percent, volume, updated_at, created_at, owner_id
 		FROM securities
-		WHERE (ticker ILIKE $1 OR name ILIKE $1 OR short_name ILIKE $1 OR isin ILIKE $1) AND is_active = true
+		WHERE owner_id = $1 AND is_active = true
 		ORDER BY ticker
-		LIMIT $2
 	`
 
+	rows, err := r.db(ctx).Query(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var securities []models.Security
+	for rows.Next() {
+		var s models.Security
+		err := rows.Scan(
+			&s.ID, &s.Ticker, &s.ISIN, &s.Name, &s.ShortName,
+			&s.Type, &s.Exchange, &s.Currency, &s.Country,
+			&s.Sector, &s.Industry, &s.LotSize, &s.MinPriceIncrement,
+			&s.IsActive, &s.FaceValue, &s.CouponRate, &s.MaturityDate,
+			&s.CouponFreq, &s.ExpenseRatio, &s.Underlying, &s.ExpiryDate,
+			&s.Strike, &s.OptionType, &s.ContractMultiplier, &s.InitialMargin,
+			&s.LastPrice, &s.PriceChange,
+			&s.PriceChangePercent, &s.Volume, &s.UpdatedAt, &s.CreatedAt,
+			&s.OwnerID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		securities = append(securities, s)
+	}
+	return securities, rows.Err()
+}
+
+func (r *securityRepository) Search(ctx context.Context, query string, securityType *models.SecurityType, exchange *models.Exchange, hidden *models.SecurityPreferences, limit int) ([]models.Security, error) {
 	if limit <= 0 {
 		limit = 20
 	}
 
-	rows, err := r.pool.Query(ctx, sqlQuery, "%"+query+"%", limit)
+	pattern := "%" + query + "%"
+	qb := newQueryBuilder().
+		where("(ticker ILIKE ? OR name ILIKE ? OR short_name ILIKE ? OR isin ILIKE ?)", pattern, pattern, pattern, pattern).
+		whereIf(securityType != nil, "type = ?", securityType).
+		whereIf(exchange != nil, "exchange = ?", exchange)
+	excludeHidden(qb, hidden)
+
+	sqlQuery := `
+		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, sector, industry, lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, COALESCE(underlying, ''), expiry_date, strike, COALESCE(option_type, ''), contract_multiplier, initial_margin, last_price, price_change, price_change_percent, volume, updated_at, created_at
+		FROM securities
+		WHERE is_active = true AND owner_id IS NULL` + qb.and() + `
+		ORDER BY ticker
+		LIMIT ` + qb.arg(limit)
+
+	rows, err := r.db(ctx).Query(ctx, sqlQuery, qb.params()...)
 	if err != nil {
 		return nil, err
 	}
@@ -172,7 +392,9 @@ func (r *securityRepository) Search(ctx context.Context, query string, limit int
 			&s.Type, &s.Exchange, &s.Currency, &s.Country,
 			&s.Sector, &s.Industry, &s.LotSize, &s.MinPriceIncrement,
 			&s.IsActive, &s.FaceValue, &s.CouponRate, &s.MaturityDate,
-			&s.CouponFreq, &s.ExpenseRatio, &s.LastPrice, &s.PriceChange,
+			&s.CouponFreq, &s.ExpenseRatio, &s.Underlying, &s.ExpiryDate,
+			&s.Strike, &s.OptionType, &s.ContractMultiplier, &s.InitialMargin,
+			&s.LastPrice, &s.PriceChange,
 			&s.PriceChangePercent, &s.Volume, &s.UpdatedAt, &s.CreatedAt,
 		)
 		if err != nil {
@@ -183,6 +405,110 @@ func (r *securityRepository) Search(ctx context.Context, query string, limit int
 	return securities, rows.Err()
 }
 
+// screenerYield текущая доходность: у облигаций годовой купон к цене (цена и номинал в валюте),
+// у остальных - дивиденды за 12 месяцев к цене
+const screenerYield = `CASE
+	WHEN type = 'bond' THEN CASE WHEN coupon_rate IS NOT NULL AND face_value > 0 AND last_price > 0 THEN ROUND(coupon_rate * face_value / last_price, 2) END
+	WHEN f.dividend_per_share > 0 AND last_price > 0 THEN ROUND(f.dividend_per_share * 100 / last_price, 2)
+END`
+
+// поля, по которым можно сортировать выдачу скринера
+var screenerSortColumns = sortColumns{
+	"ticker":     "ticker",
+	"price":      "last_price",
+	"yield":      "yield",
+	"change":     "price_change_percent",
+	"volume":     "volume",
+	"maturity":   "maturity_date",
+	"pe":         "f.pe_ratio",
+	"market_cap": "f.market_cap",
+}
+
+func (r *securityRepository) Screen(ctx context.Context, filter *models.SecurityScreenerFilter) ([]models.ScreenerSecurity, int64, error) {
+	qb := newQueryBuilder().
+		whereIf(filter.Type != nil, "type = ?", filter.Type).
+		whereIf(filter.Exchange != nil, "exchange = ?", filter.Exchange).
+		whereIf(filter.Sector != "", "LOWER(sector) = LOWER(?)", filter.Sector).
+		whereIf(filter.Currency != "", "currency = ?", filter.Currency).
+		whereIf(filter.MinPrice != nil, "last_price >= ?", filter.MinPrice).
+		whereIf(filter.MaxPrice != nil, "last_price <= ?", filter.MaxPrice).
+		whereIf(filter.MinYield != nil, screenerYield+" >= ?", filter.MinYield).
+		whereIf(filter.MaxYield != nil, screenerYield+" <= ?", filter.MaxYield).
+		whereIf(filter.MaturityFrom != nil, "maturity_date >= ?", filter.MaturityFrom).
+		whereIf(filter.MaturityTo != nil, "maturity_date <= ?", filter.MaturityTo).
+		whereIf(filter.MinPE != nil, "f.pe_ratio >= ?", filter.MinPE).
+		whereIf(filter.MaxPE != nil, "f.pe_ratio <= ?", filter.MaxPE).
+		whereIf(filter.MinMarketCap != nil, "f.market_cap >= ?", filter.MinMarketCap).
+		whereIf(filter.MaxMarketCap != nil, "f.market_cap <= ?", filter.MaxMarketCap)
+	excludeHidden(qb, filter.Hidden)
+
+	// по умолчанию сначала самые торгуемые
+	orderBy, err := screenerSortColumns.orderBy(filter.SortBy, filter.SortOrder, "volume", "ticker")
+	if err != nil {
+		return nil, 0, err
+	}
+	// бумаги без доходности, даты погашения или мультипликаторов - в конце при любом направлении
+	orderBy = strings.Replace(orderBy, "ASC", "ASC NULLS LAST", 1)
+	orderBy = strings.Replace(orderBy, "DESC", "DESC NULLS LAST", 1)
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := `
+		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, COALESCE(sector, ''), COALESCE(industry, ''), lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, COALESCE(underlying, ''), expiry_date, strike, COALESCE(option_type, ''), contract_multiplier, initial_margin, last_price, price_change, price_change_percent, volume, securities.updated_at, created_at,
+		       ` + screenerYield + ` AS yield, f.market_cap, f.pe_ratio, f.eps, f.dividend_per_share, f.updated_at, COUNT(*) OVER()
+		FROM securities
+		LEFT JOIN security_fundamentals f ON f.security_id = securities.id
+		WHERE is_active = true AND owner_id IS NULL` + qb.and() + orderBy + qb.page(limit, offset)
+
+	rows, err := r.db(ctx).Query(ctx, query, qb.params()...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var (
+		securities []models.ScreenerSecurity
+		total      int64
+	)
+	for rows.Next() {
+		var (
+			s                   models.ScreenerSecurity
+			f                   models.SecurityFundamentals
+			fundamentalsUpdated *time.Time
+		)
+		err := rows.Scan(
+			&s.ID, &s.Ticker, &s.ISIN, &s.Name, &s.ShortName,
+			&s.Type, &s.Exchange, &s.Currency, &s.Country,
+			&s.Sector, &s.Industry, &s.LotSize, &s.MinPriceIncrement,
+			&s.IsActive, &s.FaceValue, &s.CouponRate, &s.MaturityDate,
+			&s.CouponFreq, &s.ExpenseRatio, &s.Underlying, &s.ExpiryDate,
+			&s.Strike, &s.OptionType, &s.ContractMultiplier, &s.InitialMargin,
+			&s.LastPrice, &s.PriceChange,
+			&s.PriceChangePercent, &s.Volume, &s.UpdatedAt, &s.CreatedAt,
+			&s.Yield, &f.MarketCap, &f.PERatio, &f.EPS, &f.DividendPerShare, &fundamentalsUpdated, &total,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		if fundamentalsUpdated != nil {
+			f.UpdatedAt = *fundamentalsUpdated
+			if s.Type != models.SecurityTypeBond {
+				f.DividendYield = s.Yield
+			}
+			s.Fundamentals = &f
+		}
+		securities = append(securities, s)
+	}
+	return securities, total, rows.Err()
+}
+
 func (r *securityRepository) Update(ctx context.Context, id uuid.UUID, security *models.Security) error {
 	query := `
 		UPDATE securities SET
@@ -196,29 +522,38 @@ func (r *securityRepository) Update(ctx context.Context, id uuid.UUID, security
 			maturity_date = $9,
 			coupon_freq = $10,
 			expense_ratio = $11,
-			updated_at = $12
+			underlying = $12,
+			expiry_date = $13,
+			strike = $14,
+			option_type = $15,
+			contract_multiplier = $16,
+			initial_margin = $17,
+			updated_at = $18
 		WHERE id = $1
 	`
 
 	_, err := r.db(ctx).Exec(ctx, query,
 		id, security.Name, security.ShortName, security.Sector, security.Industry,
 		security.IsActive, security.FaceValue, security.CouponRate, security.MaturityDate,
-		security.CouponFreq, security.ExpenseRatio, time.Now(),
+		security.CouponFreq, security.ExpenseRatio, security.Underlying, security.ExpiryDate,
+		security.Strike, security.OptionType, security.ContractMultiplier, security.InitialMargin,
+		time.Now(),
 	)
 	return err
 }
 
-func (r *securityRepository) UpdatePrice(ctx context.Context, id uuid.UUID, price decimal.Decimal, change decimal.Decimal, changePercent decimal.Decimal, volume int64) error {
+func (r *securityRepository) UpdatePrice(ctx context.Context, id uuid.UUID, quote *models.MarketQuote) error {
 	query := `
 		UPDATE securities SET
 			last_price = $2,
 			price_change = $3,
 			price_change_percent = $4,
 			volume = $5,
-			updated_at = $6
+			accrued_interest = $6,
+			updated_at = $7
 		WHERE id = $1
 	`
-	_, err := r.db(ctx).Exec(ctx, query, id, price, change, changePercent, volume, time.Now())
+	_, err := r.db(ctx).Exec(ctx, query, id, quote.LastPrice, quote.Change, quote.ChangePercent, quote.Volume, quote.AccruedInterest, time.Now())
 	return err
 }
 
@@ -227,3 +562,139 @@ func (r *securityRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	_, err := r.db(ctx).Exec(ctx, query, id)
 	return err
 }
+
+func (r *securityRepository) GetWithoutSector(ctx context.Context, limit int) ([]models.Security, error) {
+	query := `
+		SELECT id, ticker, isin, name, short_name, type, exchange, currency, country, COALESCE(sector, ''), COALESCE(industry, ''), lot_size, min_price_increment, is_active, face_value, coupon_rate, maturity_date, coupon_freq, expense_ratio, COALESCE(underlying, ''), expiry_date, strike, COALESCE(option_type, ''), contract_multiplier, initial_margin, last_price, price_change, price_change_percent, volume, updated_at, created_at
+		FROM securities
+		WHERE (sector IS NULL OR sector = '') AND is_active = true AND owner_id IS NULL
+		ORDER BY sector_checked_at NULLS FIRST, ticker
+		LIMIT $1
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var securities []models.Security
+	for rows.Next() {
+		var s models.Security
+		err := rows.Scan(
+			&s.ID, &s.Ticker, &s.ISIN, &s.Name, &s.ShortName,
+			&s.Type, &s.Exchange, &s.Currency, &s.Country,
+			&s.Sector, &s.Industry, &s.LotSize, &s.MinPriceIncrement,
+			&s.IsActive, &s.FaceValue, &s.CouponRate, &s.MaturityDate,
+			&s.CouponFreq, &s.ExpenseRatio, &s.Underlying, &s.ExpiryDate,
+			&s.Strike, &s.OptionType, &s.ContractMultiplier, &s.InitialMargin,
+			&s.LastPrice, &s.PriceChange,
+			&s.PriceChangePercent, &s.Volume, &s.UpdatedAt, &s.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		securities = append(securities, s)
+	}
+	return securities, rows.Err()
+}
+
+func (r *securityRepository) MarkSectorChecked(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.db(ctx).Exec(ctx, `UPDATE securities SET sector_checked_at = NOW() WHERE id = ANY($1)`, ids)
+	return err
+}
+
+func (r *securityRepository) UpdateSector(ctx context.Context, ticker string, exchange models.Exchange, sector, industry string) (int64, error) {
+	query := `UPDATE securities SET sector = $3, industry = $4, updated_at = $5 WHERE ticker = $1 AND exchange = $2`
+	tag, err := r.db(ctx).Exec(ctx, query, ticker, exchange, sector, industry, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *securityRepository) UpdateType(ctx context.Context, ticker string, exchange models.Exchange, securityType models.SecurityType) (int64, error) {
+	query := `UPDATE securities SET type = $3, updated_at = $4 WHERE ticker = $1 AND exchange = $2`
+	tag, err := r.db(ctx).Exec(ctx, query, ticker, exchange, securityType, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// excludeHidden убирает из выборки скрытые пользователем тикеры, типы и биржи
+func excludeHidden(qb *queryBuilder, hidden *models.SecurityPreferences) {
+	if hidden == nil {
+		return
+	}
+	types := make([]string, len(hidden.HiddenTypes))
+	for i, t := range hidden.HiddenTypes {
+		types[i] = string(t)
+	}
+	exchanges := make([]string, len(hidden.HiddenExchanges))
+	for i, e := range hidden.HiddenExchanges {
+		exchanges[i] = string(e)
+	}
+	qb.whereIf(len(hidden.HiddenTickers) > 0, "ticker <> ALL(?)", hidden.HiddenTickers).
+		whereIf(len(types) > 0, "type <> ALL(?)", types).
+		whereIf(len(exchanges) > 0, "exchange <> ALL(?)", exchanges)
+}
+
+func (r *securityRepository) GetPreferences(ctx context.Context, userID uuid.UUID) (*models.SecurityPreferences, error) {
+	query := `
+		SELECT hidden_tickers, hidden_types, hidden_exchanges, updated_at
+		FROM security_preferences
+		WHERE user_id = $1
+	`
+
+	var (
+		prefs            models.SecurityPreferences
+		types, exchanges []string
+		updatedAt        time.Time
+	)
+	err := r.db(ctx).QueryRow(ctx, query, userID).Scan(&prefs.HiddenTickers, &types, &exchanges, &updatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.SecurityPreferences{HiddenTickers: []string{}, HiddenTypes: []models.SecurityType{}, HiddenExchanges: []models.Exchange{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefs.HiddenTypes = make([]models.SecurityType, len(types))
+	for i, t := range types {
+		prefs.HiddenTypes[i] = models.SecurityType(t)
+	}
+	prefs.HiddenExchanges = make([]models.Exchange, len(exchanges))
+	for i, e := range exchanges {
+		prefs.HiddenExchanges[i] = models.Exchange(e)
+	}
+	prefs.UpdatedAt = &updatedAt
+	return &prefs, nil
+}
+
+func (r *securityRepository) SetPreferences(ctx context.Context, userID uuid.UUID, prefs *models.SecurityPreferences) error {
+	query := `
+		INSERT INTO security_preferences (user_id, hidden_tickers, hidden_types, hidden_exchanges, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE SET
+			hidden_tickers = EXCLUDED.hidden_tickers, hidden_types = EXCLUDED.hidden_types,
+			hidden_exchanges = EXCLUDED.hidden_exchanges, updated_at = EXCLUDED.updated_at
+	`
+
+	types := make([]string, len(prefs.HiddenTypes))
+	for i, t := range prefs.HiddenTypes {
+		types[i] = string(t)
+	}
+	exchanges := make([]string, len(prefs.HiddenExchanges))
+	for i, e := range prefs.HiddenExchanges {
+		exchanges[i] = string(e)
+	}
+
+	now := time.Now()
+	prefs.UpdatedAt = &now
+	_, err := r.db(ctx).Exec(ctx, query, userID, prefs.HiddenTickers, types, exchanges, now)
+	return err
+}
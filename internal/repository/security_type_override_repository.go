@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SecurityTypeOverrideRepository interface {
+	Get(ctx context.Context, ticker string, exchange models.Exchange) (*models.SecurityTypeOverride, error)
+	List(ctx context.Context, exchange *models.Exchange) ([]models.SecurityTypeOverride, error)
+	Upsert(ctx context.Context, override *models.SecurityTypeOverride) error
+	Delete(ctx context.Context, ticker string, exchange models.Exchange) (bool, error)
+}
+
+type securityTypeOverrideRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSecurityTypeOverrideRepository(pool *pgxpool.Pool) SecurityTypeOverrideRepository {
+	return &securityTypeOverrideRepository{pool: pool}
+}
+
+func (r *securityTypeOverrideRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *securityTypeOverrideRepository) Get(ctx context.Context, ticker string, exchange models.Exchange) (*models.SecurityTypeOverride, error) {
+	query := `
+		SELECT ticker, exchange, type, updated_at
+		FROM security_type_overrides
+		WHERE ticker = $1 AND exchange = $2
+	`
+
+	var o models.SecurityTypeOverride
+	err := r.db(ctx).QueryRow(ctx, query, ticker, exchange).Scan(&o.Ticker, &o.Exchange, &o.Type, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *securityTypeOverrideRepository) List(ctx context.Context, exchange *models.Exchange) ([]models.SecurityTypeOverride, error) {
+	query := `
+		SELECT ticker, exchange, type, updated_at
+		FROM security_type_overrides
+		WHERE ($1::varchar IS NULL OR exchange = $1)
+		ORDER BY exchange, ticker
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, exchange)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []models.SecurityTypeOverride
+	for rows.Next() {
+		var o models.SecurityTypeOverride
+		if err := rows.Scan(&o.Ticker, &o.Exchange, &o.Type, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+func (r *securityTypeOverrideRepository) Upsert(ctx context.Context, override *models.SecurityTypeOverride) error {
+	query := `
+		INSERT INTO security_type_overrides (ticker, exchange, type, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (ticker, exchange) DO UPDATE SET
+			type = EXCLUDED.type,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	override.UpdatedAt = time.Now()
+	_, err := r.db(ctx).Exec(ctx, query, override.Ticker, override.Exchange, override.Type, override.UpdatedAt)
+	return err
+}
+
+func (r *securityTypeOverrideRepository) Delete(ctx context.Context, ticker string, exchange models.Exchange) (bool, error) {
+	tag, err := r.db(ctx).Exec(ctx, `DELETE FROM security_type_overrides WHERE ticker = $1 AND exchange = $2`, ticker, exchange)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TagRepository метки транзакций пользователя (transaction_tags) целиком, а не по одной транзакции
+type TagRepository interface {
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.TagUsage, error)
+	// Exists есть ли метка хотя бы у одной транзакции пользователя, включая удаленные
+	Exists(ctx context.Context, userID uuid.UUID, tag string) (bool, error)
+	// Replace ставит метку target вместо sources во всех транзакциях пользователя.
+	// возвращает число затронутых транзакций
+	Replace(ctx context.Context, userID uuid.UUID, sources []string, target string) (int64, error)
+	// GetExpensesByPeriodCurrency расходы по меткам: метка -> валюта -> суммы по периодам
+	GetExpensesByPeriodCurrency(ctx context.Context, userID uuid.UUID, tags []string, startDate, endDate time.Time, groupBy string) (map[string]map[string][]models.TagPeriodAmount, error)
+}
+
+type tagRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTagRepository(pool *pgxpool.Pool) TagRepository {
+	return &tagRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *tagRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *tagRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.TagUsage, error) {
+	query := `
+		SELECT tt.tag, COUNT(*), MAX(t.date)
+		FROM transaction_tags tt
+		JOIN transactions t ON t.id = tt.transaction_id
+		WHERE t.user_id = $1 AND t.deleted_at IS NULL
+		GROUP BY tt.tag
+		ORDER BY COUNT(*) DESC, tt.tag
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.TagUsage{}
+	for rows.Next() {
+		var t models.TagUsage
+		if err := rows.Scan(&t.Tag, &t.TransactionCount, &t.LastUsed); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+func (r *tagRepository) Exists(ctx context.Context, userID uuid.UUID, tag string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM transaction_tags tt
+			JOIN transactions t ON t.id = tt.transaction_id
+			WHERE t.user_id = $1 AND tt.tag = $2
+		)
+	`
+
+	var exists bool
+	err := r.db(ctx).QueryRow(ctx, query, userID, tag).Scan(&exists)
+	return exists, err
+}
+
+func (r *tagRepository) Replace(ctx context.Context, userID uuid.UUID, sources []string, target string) (int64, error) {
+	// у транзакции уже может быть target - тогда новая строка не нужна, старые просто удаляются
+	insertQuery := `
+		INSERT INTO transaction_tags (transaction_id, tag)
+		SELECT DISTINCT tt.transaction_id, $3
+		FROM transaction_tags tt
+		JOIN transactions t ON t.id = tt.transaction_id
+		WHERE t.user_id = $1 AND tt.tag = ANY($2)
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := r.db(ctx).Exec(ctx, insertQuery, userID, sources, target); err != nil {
+		return 0, err
+	}
+
+	deleteQuery := `
+		WITH removed AS (
+			DELETE FROM transaction_tags tt
+			USING transactions t
+			WHERE t.id = tt.transaction_id AND t.user_id = $1 AND tt.tag = ANY($2) AND tt.tag <> $3
+			RETURNING tt.transaction_id
+		)
+		SELECT COUNT(DISTINCT transaction_id) FROM removed
+	`
+	var updated int64
+	err := r.db(ctx).QueryRow(ctx, deleteQuery, userID, sources, target).Scan(&updated)
+	return updated, err
+}
+
+func (r *tagRepository) GetExpensesByPeriodCurrency(ctx context.Context, userID uuid.UUID, tags []string, startDate, endDate time.Time, groupBy string) (map[string]map[string][]models.TagPeriodAmount, error) {
+	b := newQueryBuilder(userID, startDate, endDate).
+		whereIf(len(tags) > 0, "tt.tag = ANY(?)", tags)
+
+	query := fmt.Sprintf(`
+		SELECT tt.tag, TO_CHAR(t.date, '%s') as period, t.currency, SUM(t.amount), COUNT(*)
+		FROM transaction_tags tt
+		JOIN transactions t ON t.id = tt.transaction_id
+		WHERE t.user_id = $1 AND t.date >= $2 AND t.date <= $3 AND t.type = 'expense' AND t.deleted_at IS NULL%s
+		GROUP BY tt.tag, period, t.currency
+		ORDER BY tt.tag, period
+	`, periodDateFormat(groupBy), b.and())
+
+	rows, err := r.db(ctx).Query(ctx, query, b.params()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]map[string][]models.TagPeriodAmount)
+	for rows.Next() {
+		var tag, currency string
+		var p models.TagPeriodAmount
+		if err := rows.Scan(&tag, &p.Period, &currency, &p.Amount, &p.TransactionCount); err != nil {
+			return nil, err
+		}
+		if result[tag] == nil {
+			result[tag] = make(map[string][]models.TagPeriodAmount)
+		}
+		result[tag][currency] = append(result[tag][currency], p)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TelegramRepository interface {
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*models.TelegramLink, error)
+	GetByChatID(ctx context.Context, chatID int64) (*models.TelegramLink, error)
+	// SetCode выдает новый код привязки; прежний код и привязанный чат остаются до /start с новым кодом
+	SetCode(ctx context.Context, userID uuid.UUID, codeHash string, expiresAt time.Time, accountID *uuid.UUID) error
+	// ReleaseChat отвязывает чат от аккаунта, к которому он привязан (перед привязкой к другому)
+	ReleaseChat(ctx context.Context, chatID int64) error
+	// LinkByCode привязывает чат по действующему коду и гасит код; pgx.ErrNoRows - код неверный или истек
+	LinkByCode(ctx context.Context, codeHash string, chatID int64, username string) (*models.TelegramLink, error)
+	Delete(ctx context.Context, userID uuid.UUID) (bool, error)
+	// MarkUpdate запоминает обновление бота; false - оно уже обрабатывалось (повторная доставка)
+	MarkUpdate(ctx context.Context, updateID int64) (bool, error)
+	// DeleteUpdatesBefore забывает обновления, полученные раньше before
+	DeleteUpdatesBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type telegramRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTelegramRepository(pool *pgxpool.Pool) TelegramRepository {
+	return &telegramRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *telegramRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const telegramLinkColumns = `user_id, chat_id, username, account_id, linked_at`
+
+func scanTelegramLink(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.TelegramLink, error) {
+	var l models.TelegramLink
+	if err := row.Scan(&l.UserID, &l.ChatID, &l.Username, &l.AccountID, &l.LinkedAt); err != nil {
+		return nil, err
+	}
+	l.Linked = l.ChatID != nil
+	return &l, nil
+}
+
+func (r *telegramRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*models.TelegramLink, error) {
+	query := `SELECT ` + telegramLinkColumns + ` FROM telegram_links WHERE user_id = $1`
+	return scanTelegramLink(r.db(ctx).QueryRow(ctx, query, userID))
+}
+
+func (r *telegramRepository) GetByChatID(ctx context.Context, chatID int64) (*models.TelegramLink, error) {
+	query := `SELECT ` + telegramLinkColumns + ` FROM telegram_links WHERE chat_id = $1`
+	return scanTelegramLink(r.db(ctx).QueryRow(ctx, query, chatID))
+}
+
+func (r *telegramRepository) SetCode(ctx context.Context, userID uuid.UUID, codeHash string, expiresAt time.Time, accountID *uuid.UUID) error {
+	query := `
+		INSERT INTO telegram_links (user_id, code_hash, code_expires_at, account_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			code_hash = EXCLUDED.code_hash,
+			code_expires_at = EXCLUDED.code_expires_at,
+			account_id = COALESCE(EXCLUDED.account_id, telegram_links.account_id)
+	`
+	_, err := r.db(ctx).Exec(ctx, query, userID, codeHash, expiresAt, accountID)
+	return err
+}
+
+func (r *telegramRepository) ReleaseChat(ctx context.Context, chatID int64) error {
+	_, err := r.db(ctx).Exec(ctx, `UPDATE telegram_links SET chat_id = NULL, linked_at = NULL WHERE chat_id = $1`, chatID)
+	return err
+}
+
+func (r *telegramRepository) LinkByCode(ctx context.Context, codeHash string, chatID int64, username string) (*models.TelegramLink, error) {
+	query := `
+		UPDATE telegram_links
+		SET chat_id = $2, username = $3, linked_at = $4, code_hash = NULL, code_expires_at = NULL
+		WHERE code_hash = $1 AND code_expires_at > $4
+		RETURNING ` + telegramLinkColumns
+	return scanTelegramLink(r.db(ctx).QueryRow(ctx, query, codeHash, chatID, username, time.Now()))
+}
+
+func (r *telegramRepository) Delete(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tag, err := r.db(ctx).Exec(ctx, `DELETE FROM telegram_links WHERE user_id = $1`, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *telegramRepository) MarkUpdate(ctx context.Context, updateID int64) (bool, error) {
+	tag, err := r.db(ctx).Exec(ctx, `INSERT INTO telegram_updates (update_id) VALUES ($1) ON CONFLICT DO NOTHING`, updateID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *telegramRepository) DeleteUpdatesBefore(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db(ctx).Exec(ctx, `DELETE FROM telegram_updates WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TransactionDraftRepository interface {
+	// Create сохраняет черновик; false - черновик по этому письму уже есть
+	Create(ctx context.Context, draft *models.TransactionDraft) (bool, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.TransactionDraft, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, status *models.TransactionDraftStatus) ([]models.TransactionDraft, error)
+	MarkConfirmed(ctx context.Context, id, transactionID uuid.UUID) error
+	SetStatus(ctx context.Context, id uuid.UUID, status models.TransactionDraftStatus) error
+}
+
+type transactionDraftRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTransactionDraftRepository(pool *pgxpool.Pool) TransactionDraftRepository {
+	return &transactionDraftRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *transactionDraftRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const transactionDraftColumns = `id, user_id, connection_id, external_id, parser, account_id, type, amount, currency, COALESCE(description, ''), date, COALESCE(card_last4, ''), COALESCE(subject, ''), status, transaction_id, created_at, updated_at`
+
+func scanTransactionDraft(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.TransactionDraft, error) {
+	var d models.TransactionDraft
+	err := row.Scan(
+		&d.ID, &d.UserID, &d.ConnectionID, &d.ExternalID, &d.Parser, &d.AccountID, &d.Type,
+		&d.Amount, &d.Currency, &d.Description, &d.Date, &d.CardLast4, &d.Subject, &d.Status,
+		&d.TransactionID, &d.CreatedAt, &d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *transactionDraftRepository) Create(ctx context.Context, draft *models.TransactionDraft) (bool, error) {
+	query := `
+		INSERT INTO transaction_drafts (id, user_id, connection_id, external_id, parser, account_id, type, amount, currency, description, date, card_last4, subject, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT (user_id, external_id) DO NOTHING
+	`
+
+	if draft.ID == uuid.Nil {
+		draft.ID = uuid.New()
+	}
+	if draft.Status == "" {
+		draft.Status = models.DraftStatusPending
+	}
+	now := time.Now()
+	draft.CreatedAt = now
+	draft.UpdatedAt = now
+
+	tag, err := r.db(ctx).Exec(ctx, query,
+		draft.ID, draft.UserID, draft.ConnectionID, draft.ExternalID, draft.Parser, draft.AccountID,
+		draft.Type, draft.Amount, draft.Currency, draft.Description, draft.Date, draft.CardLast4,
+		draft.Subject, draft.Status, draft.CreatedAt, draft.UpdatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *transactionDraftRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.TransactionDraft, error) {
+	query := `SELECT ` + transactionDraftColumns + ` FROM transaction_drafts WHERE id = $1`
+	return scanTransactionDraft(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *transactionDraftRepository) GetByUserID(ctx context.Context, userID uuid.UUID, status *models.TransactionDraftStatus) ([]models.TransactionDraft, error) {
+	query := `
+		SELECT ` + transactionDraftColumns + `
+		FROM transaction_drafts
+		WHERE user_id = $1 AND ($2::varchar IS NULL OR status = $2)
+		ORDER BY date DESC, created_at DESC
+	`
+	rows, err := r.db(ctx).Query(ctx, query, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drafts []models.TransactionDraft
+	for rows.Next() {
+		d, err := scanTransactionDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, *d)
+	}
+	return drafts, rows.Err()
+}
+
+func (r *transactionDraftRepository) MarkConfirmed(ctx context.Context, id, transactionID uuid.UUID) error {
+	query := `UPDATE transaction_drafts SET status = $2, transaction_id = $3, updated_at = $4 WHERE id = $1`
+	_, err := r.db(ctx).Exec(ctx, query, id, models.DraftStatusConfirmed, transactionID, time.Now())
+	return err
+}
+
+func (r *transactionDraftRepository) SetStatus(ctx context.Context, id uuid.UUID, status models.TransactionDraftStatus) error {
+	query := `UPDATE transaction_drafts SET status = $2, updated_at = $3 WHERE id = $1`
+	_, err := r.db(ctx).Exec(ctx, query, id, status, time.Now())
+	return err
+}
@@ -3,7 +3,6 @@ package repository
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/models"
@@ -16,12 +15,29 @@ type TransactionRepository interface {
 	Create(ctx context.Context, tx *models.Transaction) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Transaction, error)
 	GetByFilter(ctx context.Context, userID uuid.UUID, filter *models.TransactionFilter) (*models.TransactionList, error)
+	// GetPage страница по курсору в порядке (date, id) от новых к старым; сортировка и номер страницы из filter не учитываются
+	GetPage(ctx context.Context, userID uuid.UUID, filter *models.TransactionFilter, page models.CursorPage) ([]models.Transaction, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.TransactionUpdate) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	// ConvertToTransfer превращает расход в перевод на счет toAccountID (балансы счетов не трогает);
+	// false - неудаленного расхода с таким id нет
+	ConvertToTransfer(ctx context.Context, id, toAccountID uuid.UUID, toAmount decimal.Decimal) (bool, error)
 	GetTags(ctx context.Context, transactionID uuid.UUID) ([]string, error)
 	SetTags(ctx context.Context, transactionID uuid.UUID, tags []string) error
+	GetItems(ctx context.Context, transactionID uuid.UUID) ([]models.TransactionItem, error)
+	SetItems(ctx context.Context, transactionID uuid.UUID, items []models.TransactionItem) error
 	GetSumByCategory(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, txType models.TransactionType) (map[uuid.UUID]decimal.Decimal, error)
+	// GetExpenseSums расходы за несколько периодов одним запросом; nil-фильтры периода по категории, счету
+	// и получателю не ограничивают выборку. суммы в порядке periods
+	GetExpenseSums(ctx context.Context, userID uuid.UUID, periods []models.BudgetSpendPeriod) ([]decimal.Decimal, error)
 	GetSumByPeriod(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, groupBy string) ([]models.CashFlow, error)
+	GetSumByCategoryCurrency(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, txType models.TransactionType) (map[string]map[uuid.UUID]decimal.Decimal, error)
+	GetSumByPeriodCurrency(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, groupBy string) (map[string][]models.CashFlow, error)
+	GetSumByPeriodDimension(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, groupBy string, dimension models.CashFlowDimension) ([]models.CashFlowCell, error)
+	// GetSpendingByLocation расходы с привязкой к месту по ячейкам geohash длины precision или по городам, с разбивкой по валютам
+	GetSpendingByLocation(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, groupBy models.SpendingMapGroup, precision int) ([]models.SpendingMapCell, error)
+	GetRecurring(ctx context.Context, userID uuid.UUID) ([]models.Transaction, error)
+	GetByDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, txType *models.TransactionType) ([]models.Transaction, error)
 }
 
 type transactionRepository struct {
@@ -39,8 +55,8 @@ func (r *transactionRepository) db(ctx context.Context) DBTX {
 
 func (r *transactionRepository) Create(ctx context.Context, tx *models.Transaction) error {
 	query := `
-		INSERT INTO transactions (id, user_id, account_id, category_id, type, amount, currency, description, date, to_account_id, to_amount, is_recurring, recurrence_rule, parent_transaction_id, location, notes, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		INSERT INTO transactions (id, user_id, account_id, category_id, type, amount, currency, description, date, to_account_id, to_amount, is_recurring, recurrence_rule, parent_transaction_id, location, notes, created_at, updated_at, payee_id, latitude, longitude, geohash, city)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, NULLIF($22, ''), $23)
 	`
 
 	if tx.ID == uuid.Nil {
@@ -55,7 +71,8 @@ func (r *transactionRepository) Create(ctx context.Context, tx *models.Transacti
 		tx.Amount, tx.Currency, tx.Description, tx.Date,
 		tx.ToAccountID, tx.ToAmount, tx.IsRecurring, tx.RecurrenceRule,
 		tx.ParentTransactionID, tx.Location, tx.Notes,
-		tx.CreatedAt, tx.UpdatedAt,
+		tx.CreatedAt, tx.UpdatedAt, tx.PayeeID,
+		tx.Latitude, tx.Longitude, tx.Geohash, tx.City,
 	)
 
 	if err != nil {
@@ -63,7 +80,13 @@ func (r *transactionRepository) Create(ctx context.Context, tx *models.Transacti
 	}
 
 	if len(tx.Tags) > 0 {
-		return r.SetTags(ctx, tx.ID, tx.Tags)
+		if err := r.SetTags(ctx, tx.ID, tx.Tags); err != nil {
+			return err
+		}
+	}
+
+	if len(tx.Items) > 0 {
+		return r.SetItems(ctx, tx.ID, tx.Items)
 	}
 
 	return nil
@@ -71,7 +94,7 @@ func (r *transactionRepository) Create(ctx context.Context, tx *models.Transacti
 
 func (r *transactionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Transaction, error) {
 	query := `
-		SELECT t.id, t.user_id, t.account_id, t.category_id, t.type, t.amount, t.currency, t.description, t.date, t.to_account_id, t.to_amount, t.is_recurring, t.recurrence_rule, t.parent_transaction_id, t.location, t.notes, t.created_at, t.updated_at
+		SELECT t.id, t.user_id, t.account_id, t.category_id, t.type, t.amount, t.currency, t.description, t.date, t.to_account_id, t.to_amount, t.is_recurring, t.recurrence_rule, t.parent_transaction_id, t.location, t.latitude, t.longitude, t.city, t.notes, t.created_at, t.updated_at, t.payee_id
 		FROM transactions t
 		WHERE t.id = $1 AND t.deleted_at IS NULL
 	`
@@ -81,85 +104,44 @@ func (r *transactionRepository) GetByID(ctx context.Context, id uuid.UUID) (*mod
 		&tx.ID, &tx.UserID, &tx.AccountID, &tx.CategoryID, &tx.Type,
 		&tx.Amount, &tx.Currency, &tx.Description, &tx.Date,
 		&tx.ToAccountID, &tx.ToAmount, &tx.IsRecurring, &tx.RecurrenceRule,
-		&tx.ParentTransactionID, &tx.Location, &tx.Notes,
-		&tx.CreatedAt, &tx.UpdatedAt,
+		&tx.ParentTransactionID, &tx.Location, &tx.Latitude, &tx.Longitude, &tx.City, &tx.Notes,
+		&tx.CreatedAt, &tx.UpdatedAt, &tx.PayeeID,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	tx.Tags, _ = r.GetTags(ctx, id)
+	tx.Items, _ = r.GetItems(ctx, id)
 
 	return &tx, nil
 }
 
+// поля, по которым можно сортировать список транзакций (?sort_by=)
+var transactionSortColumns = sortColumns{
+	"date":       "t.date",
+	"amount":     "t.amount",
+	"created_at": "t.created_at",
+	"type":       "t.type",
+}
+
 func (r *transactionRepository) GetByFilter(ctx context.Context, userID uuid.UUID, filter *models.TransactionFilter) (*models.TransactionList, error) {
 	baseQuery := `
-		SELECT t.id, t.user_id, t.account_id, t.category_id, t.type, t.amount, t.currency, t.description, t.date, t.to_account_id, t.to_amount, t.is_recurring, t.recurrence_rule, t.parent_transaction_id, t.location, t.notes, t.created_at, t.updated_at
+		SELECT t.id, t.user_id, t.account_id, t.category_id, t.type, t.amount, t.currency, t.description, t.date, t.to_account_id, t.to_amount, t.is_recurring, t.recurrence_rule, t.parent_transaction_id, t.location, t.latitude, t.longitude, t.city, t.notes, t.created_at, t.updated_at, t.payee_id
 		FROM transactions t
 		WHERE t.user_id = $1 AND t.deleted_at IS NULL
 	`
 	countQuery := `SELECT COUNT(*) FROM transactions t WHERE t.user_id = $1 AND t.deleted_at IS NULL`
 
-	var conditions []string
-	args := []interface{}{userID}
-	argIndex := 2
-
-	if filter.AccountID != nil {
-		conditions = append(conditions, fmt.Sprintf("t.account_id = $%d", argIndex))
-		args = append(args, *filter.AccountID)
-		argIndex++
-	}
-
-	if filter.CategoryID != nil {
-		conditions = append(conditions, fmt.Sprintf("t.category_id = $%d", argIndex))
-		args = append(args, *filter.CategoryID)
-		argIndex++
-	}
-
-	if filter.Type != nil {
-		conditions = append(conditions, fmt.Sprintf("t.type = $%d", argIndex))
-		args = append(args, *filter.Type)
-		argIndex++
-	}
-
-	if filter.DateFrom != nil {
-		conditions = append(conditions, fmt.Sprintf("t.date >= $%d", argIndex))
-		args = append(args, *filter.DateFrom)
-		argIndex++
-	}
-
-	if filter.DateTo != nil {
-		conditions = append(conditions, fmt.Sprintf("t.date <= $%d", argIndex))
-		args = append(args, *filter.DateTo)
-		argIndex++
-	}
+	qb := transactionFilterConditions(userID, filter)
 
-	if filter.AmountMin != nil {
-		conditions = append(conditions, fmt.Sprintf("t.amount >= $%d", argIndex))
-		args = append(args, *filter.AmountMin)
-		argIndex++
-	}
-
-	if filter.AmountMax != nil {
-		conditions = append(conditions, fmt.Sprintf("t.amount <= $%d", argIndex))
-		args = append(args, *filter.AmountMax)
-		argIndex++
-	}
-
-	if filter.Search != "" {
-		conditions = append(conditions, fmt.Sprintf("(t.description ILIKE $%d OR t.notes ILIKE $%d)", argIndex, argIndex))
-		args = append(args, "%"+filter.Search+"%")
-		argIndex++
-	}
-
-	whereClause := ""
-	if len(conditions) > 0 {
-		whereClause = " AND " + strings.Join(conditions, " AND ")
+	orderBy, err := transactionSortColumns.orderBy(filter.SortBy, filter.SortOrder, "date", "t.id")
+	if err != nil {
+		return nil, err
 	}
 
 	var total int64
-	err := r.db(ctx).QueryRow(ctx, countQuery+whereClause, args...).Scan(&total)
+	err = r.db(ctx).QueryRow(ctx, countQuery+qb.and(), qb.params()...).Scan(&total)
 	if err != nil {
 		return nil, err
 	}
@@ -172,19 +154,56 @@ func (r *transactionRepository) GetByFilter(ctx context.Context, userID uuid.UUI
 	}
 	offset := (filter.Page - 1) * filter.Limit
 
-	sortBy := "date"
-	if filter.SortBy != "" {
-		sortBy = filter.SortBy
+	finalQuery := baseQuery + qb.and() + orderBy + qb.page(filter.Limit, offset)
+	args := qb.params()
+
+	transactions, err := r.queryList(ctx, finalQuery, args...)
+	if err != nil {
+		return nil, err
 	}
-	sortOrder := "DESC"
-	if filter.SortOrder == "asc" {
-		sortOrder = "ASC"
+
+	return &models.TransactionList{
+		Transactions: transactions,
+		Total:        total,
+		Page:         filter.Page,
+		Limit:        filter.Limit,
+		TotalPages:   models.TotalPages(total, filter.Limit),
+	}, nil
+}
+
+func (r *transactionRepository) GetPage(ctx context.Context, userID uuid.UUID, filter *models.TransactionFilter, page models.CursorPage) ([]models.Transaction, error) {
+	query := `
+		SELECT t.id, t.user_id, t.account_id, t.category_id, t.type, t.amount, t.currency, t.description, t.date, t.to_account_id, t.to_amount, t.is_recurring, t.recurrence_rule, t.parent_transaction_id, t.location, t.latitude, t.longitude, t.city, t.notes, t.created_at, t.updated_at, t.payee_id
+		FROM transactions t
+		WHERE t.user_id = $1 AND t.deleted_at IS NULL
+	`
+
+	qb := transactionFilterConditions(userID, filter)
+	if page.After != nil {
+		qb.where("(t.date, t.id) < (?, ?)", page.After.Date, page.After.ID)
 	}
 
-	finalQuery := baseQuery + whereClause + fmt.Sprintf(" ORDER BY t.%s %s LIMIT $%d OFFSET $%d", sortBy, sortOrder, argIndex, argIndex+1)
-	args = append(args, filter.Limit, offset)
+	return r.queryList(ctx, query+qb.and()+" ORDER BY t.date DESC, t.id DESC LIMIT "+qb.arg(page.Limit), qb.params()...)
+}
+
+// transactionFilterConditions условия фильтра списка транзакций; $1 - пользователь
+func transactionFilterConditions(userID uuid.UUID, filter *models.TransactionFilter) *queryBuilder {
+	return newQueryBuilder(userID).
+		whereIf(filter.AccountID != nil, "t.account_id = ?", filter.AccountID).
+		whereIf(filter.CategoryID != nil, "t.category_id = ?", filter.CategoryID).
+		whereIf(filter.PayeeID != nil, "t.payee_id = ?", filter.PayeeID).
+		whereIf(filter.Type != nil, "t.type = ?", filter.Type).
+		whereIf(filter.DateFrom != nil, "t.date >= ?", filter.DateFrom).
+		whereIf(filter.DateTo != nil, "t.date <= ?", filter.DateTo).
+		whereIf(filter.AmountMin != nil, "t.amount >= ?", filter.AmountMin).
+		whereIf(filter.AmountMax != nil, "t.amount <= ?", filter.AmountMax).
+		whereIf(filter.Search != "", "(t.description ILIKE ? OR t.notes ILIKE ?)", "%"+filter.Search+"%", "%"+filter.Search+"%").
+		whereIf(len(filter.Tags) > 0, "t.id IN (SELECT transaction_id FROM transaction_tags WHERE tag = ANY(?))", filter.Tags)
+}
 
-	rows, err := r.db(ctx).Query(ctx, finalQuery, args...)
+// queryList читает транзакции списка (без тегов и позиций чека)
+func (r *transactionRepository) queryList(ctx context.Context, query string, args ...interface{}) ([]models.Transaction, error) {
+	rows, err := r.db(ctx).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -197,27 +216,15 @@ func (r *transactionRepository) GetByFilter(ctx context.Context, userID uuid.UUI
 			&tx.ID, &tx.UserID, &tx.AccountID, &tx.CategoryID, &tx.Type,
 			&tx.Amount, &tx.Currency, &tx.Description, &tx.Date,
 			&tx.ToAccountID, &tx.ToAmount, &tx.IsRecurring, &tx.RecurrenceRule,
-			&tx.ParentTransactionID, &tx.Location, &tx.Notes,
-			&tx.CreatedAt, &tx.UpdatedAt,
+			&tx.ParentTransactionID, &tx.Location, &tx.Latitude, &tx.Longitude, &tx.City, &tx.Notes,
+			&tx.CreatedAt, &tx.UpdatedAt, &tx.PayeeID,
 		)
 		if err != nil {
 			return nil, err
 		}
 		transactions = append(transactions, tx)
 	}
-
-	totalPages := int(total) / filter.Limit
-	if int(total)%filter.Limit > 0 {
-		totalPages++
-	}
-
-	return &models.TransactionList{
-		Transactions: transactions,
-		Total:        total,
-		Page:         filter.Page,
-		Limit:        filter.Limit,
-		TotalPages:   totalPages,
-	}, nil
+	return transactions, rows.Err()
 }
 
 func (r *transactionRepository) Update(ctx context.Context, id uuid.UUID, update *models.TransactionUpdate) error {
@@ -232,14 +239,20 @@ func (r *transactionRepository) Update(ctx context.Context, id uuid.UUID, update
 			to_amount = COALESCE($8, to_amount),
 			location = COALESCE($9, location),
 			notes = COALESCE($10, notes),
-			updated_at = $11
+			updated_at = $11,
+			payee_id = COALESCE($12, payee_id),
+			latitude = COALESCE($13, latitude),
+			longitude = COALESCE($14, longitude),
+			geohash = COALESCE($15, geohash),
+			city = COALESCE($16, city)
 		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	_, err := r.db(ctx).Exec(ctx, query,
 		id, update.AccountID, update.CategoryID, update.Amount,
 		update.Description, update.Date, update.ToAccountID, update.ToAmount,
-		update.Location, update.Notes, time.Now(),
+		update.Location, update.Notes, time.Now(), update.PayeeID,
+		update.Latitude, update.Longitude, update.Geohash, update.City,
 	)
 
 	if err != nil {
@@ -259,6 +272,23 @@ func (r *transactionRepository) Delete(ctx context.Context, id uuid.UUID) error
 	return err
 }
 
+func (r *transactionRepository) ConvertToTransfer(ctx context.Context, id, toAccountID uuid.UUID, toAmount decimal.Decimal) (bool, error) {
+	query := `
+		UPDATE transactions SET
+			type = $2,
+			to_account_id = $3,
+			to_amount = $4,
+			payee_id = NULL,
+			updated_at = $5
+		WHERE id = $1 AND type = $6 AND deleted_at IS NULL
+	`
+	tag, err := r.db(ctx).Exec(ctx, query, id, models.TransactionTypeTransfer, toAccountID, toAmount, time.Now(), models.TransactionTypeExpense)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
 func (r *transactionRepository) GetTags(ctx context.Context, transactionID uuid.UUID) ([]string, error) {
 	query := `SELECT tag FROM transaction_tags WHERE transaction_id = $1`
 
@@ -294,6 +324,57 @@ func (r *transactionRepository) SetTags(ctx context.Context, transactionID uuid.
 	return nil
 }
 
+func (r *transactionRepository) GetItems(ctx context.Context, transactionID uuid.UUID) ([]models.TransactionItem, error) {
+	query := `
+		SELECT id, position, name, quantity, price, amount, category_id, product_id
+		FROM transaction_items
+		WHERE transaction_id = $1
+		ORDER BY position
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.TransactionItem
+	for rows.Next() {
+		var item models.TransactionItem
+		if err := rows.Scan(&item.ID, &item.Position, &item.Name, &item.Quantity, &item.Price, &item.Amount, &item.CategoryID, &item.ProductID); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// SetItems заменяет позиции транзакции; порядок позиций сохраняется
+func (r *transactionRepository) SetItems(ctx context.Context, transactionID uuid.UUID, items []models.TransactionItem) error {
+	if _, err := r.db(ctx).Exec(ctx, `DELETE FROM transaction_items WHERE transaction_id = $1`, transactionID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO transaction_items (id, transaction_id, position, name, quantity, price, amount, category_id, product_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	for i := range items {
+		item := &items[i]
+		if item.ID == uuid.Nil {
+			item.ID = uuid.New()
+		}
+		item.Position = i + 1
+		_, err := r.db(ctx).Exec(ctx, query,
+			item.ID, transactionID, item.Position, item.Name, item.Quantity, item.Price, item.Amount, item.CategoryID, item.ProductID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *transactionRepository) GetSumByCategory(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, txType models.TransactionType) (map[uuid.UUID]decimal.Decimal, error) {
 	query := `
 		SELECT category_id, SUM(amount) 
@@ -320,20 +401,66 @@ func (r *transactionRepository) GetSumByCategory(ctx context.Context, userID uui
 	return result, rows.Err()
 }
 
-func (r *transactionRepository) GetSumByPeriod(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, groupBy string) ([]models.CashFlow, error) {
-	var dateFormat string
-	switch groupBy {
-	case "day":
-		dateFormat = "YYYY-MM-DD"
-	case "week":
-		dateFormat = "IYYY-IW"
-	case "month":
-		dateFormat = "YYYY-MM"
-	case "year":
-		dateFormat = "YYYY"
-	default:
-		dateFormat = "YYYY-MM"
+func (r *transactionRepository) GetExpenseSums(ctx context.Context, userID uuid.UUID, periods []models.BudgetSpendPeriod) ([]decimal.Decimal, error) {
+	sums := make([]decimal.Decimal, len(periods))
+	if len(periods) == 0 {
+		return sums, nil
+	}
+
+	// периоды уходят массивами-колонками; пустая строка - фильтр не задан
+	starts := make([]time.Time, len(periods))
+	ends := make([]time.Time, len(periods))
+	categories := make([]string, len(periods))
+	accounts := make([]string, len(periods))
+	payees := make([]string, len(periods))
+	optional := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+	for i, p := range periods {
+		starts[i], ends[i] = p.Start, p.End
+		categories[i], accounts[i], payees[i] = optional(p.CategoryID), optional(p.AccountID), optional(p.PayeeID)
+	}
+
+	query := `
+		SELECT p.idx, COALESCE(SUM(t.amount), 0)
+		FROM (
+			SELECT idx, start_date, end_date,
+				NULLIF(category_id, '')::uuid AS category_id,
+				NULLIF(account_id, '')::uuid AS account_id,
+				NULLIF(payee_id, '')::uuid AS payee_id
+			FROM unnest($2::date[], $3::date[], $4::text[], $5::text[], $6::text[])
+				WITH ORDINALITY AS u(start_date, end_date, category_id, account_id, payee_id, idx)
+		) p
+		LEFT JOIN transactions t ON t.user_id = $1 AND t.type = 'expense' AND t.deleted_at IS NULL
+			AND t.date >= p.start_date AND t.date <= p.end_date
+			AND (p.category_id IS NULL OR t.category_id = p.category_id)
+			AND (p.account_id IS NULL OR t.account_id = p.account_id)
+			AND (p.payee_id IS NULL OR t.payee_id = p.payee_id)
+		GROUP BY p.idx
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID, starts, ends, categories, accounts, payees)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var idx int
+		var sum decimal.Decimal
+		if err := rows.Scan(&idx, &sum); err != nil {
+			return nil, err
+		}
+		sums[idx-1] = sum
 	}
+	return sums, rows.Err()
+}
+
+func (r *transactionRepository) GetSumByPeriod(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, groupBy string) ([]models.CashFlow, error) {
+	dateFormat := periodDateFormat(groupBy)
 
 	query := fmt.Sprintf(`
 		SELECT 
@@ -363,3 +490,239 @@ func (r *transactionRepository) GetSumByPeriod(ctx context.Context, userID uuid.
 	}
 	return result, rows.Err()
 }
+
+// GetSumByPeriodCurrency - то же что GetSumByPeriod, но с разбивкой по валютам транзакций
+func (r *transactionRepository) GetSumByPeriodCurrency(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, groupBy string) (map[string][]models.CashFlow, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			TO_CHAR(date, '%s') as period,
+			currency,
+			SUM(CASE WHEN type = 'income' THEN amount ELSE 0 END) as income,
+			SUM(CASE WHEN type = 'expense' THEN amount ELSE 0 END) as expenses
+		FROM transactions
+		WHERE user_id = $1 AND date >= $2 AND date <= $3 AND deleted_at IS NULL
+		GROUP BY period, currency
+		ORDER BY period
+	`, periodDateFormat(groupBy))
+
+	rows, err := r.db(ctx).Query(ctx, query, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string][]models.CashFlow)
+	for rows.Next() {
+		var cf models.CashFlow
+		var currency string
+		if err := rows.Scan(&cf.Period, &currency, &cf.Income, &cf.Expenses); err != nil {
+			return nil, err
+		}
+		cf.Net = cf.Income.Sub(cf.Expenses)
+		result[currency] = append(result[currency], cf)
+	}
+	return result, rows.Err()
+}
+
+// cashFlowDimensions ключ, название и join для разбивки денежного потока
+var cashFlowDimensions = map[models.CashFlowDimension]struct{ key, name, join string }{
+	models.CashFlowByCategory: {"COALESCE(t.category_id::text, '')", "COALESCE(c.name, '')", "LEFT JOIN categories c ON c.id = t.category_id"},
+	models.CashFlowByAccount:  {"t.account_id::text", "COALESCE(a.name, '')", "LEFT JOIN accounts a ON a.id = t.account_id"},
+	models.CashFlowByTag:      {"COALESCE(tt.tag, '')", "COALESCE(tt.tag, '')", "LEFT JOIN transaction_tags tt ON tt.transaction_id = t.id"},
+}
+
+// GetSumByPeriodDimension - доходы и расходы по периодам и значениям разбивки (категория, счет, метка)
+// с разбивкой по валютам; переводы не учитываются
+func (r *transactionRepository) GetSumByPeriodDimension(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, groupBy string, dimension models.CashFlowDimension) ([]models.CashFlowCell, error) {
+	dim, ok := cashFlowDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unknown cash flow dimension %q", dimension)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			TO_CHAR(t.date, '%s') AS period,
+			t.currency,
+			%s AS key,
+			%s AS name,
+			SUM(CASE WHEN t.type = 'income' THEN t.amount ELSE 0 END) AS income,
+			SUM(CASE WHEN t.type = 'expense' THEN t.amount ELSE 0 END) AS expenses
+		FROM transactions t
+		%s
+		WHERE t.user_id = $1 AND t.date >= $2 AND t.date <= $3 AND t.deleted_at IS NULL
+			AND t.type IN ('income', 'expense')
+		GROUP BY 1, 2, 3, 4
+		ORDER BY 1, 3
+	`, periodDateFormat(groupBy), dim.key, dim.name, dim.join)
+
+	rows, err := r.db(ctx).Query(ctx, query, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cells []models.CashFlowCell
+	for rows.Next() {
+		var cell models.CashFlowCell
+		if err := rows.Scan(&cell.Period, &cell.Currency, &cell.Key, &cell.Name, &cell.Income, &cell.Expenses); err != nil {
+			return nil, err
+		}
+		cells = append(cells, cell)
+	}
+	return cells, rows.Err()
+}
+
+func (r *transactionRepository) GetSpendingByLocation(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, groupBy models.SpendingMapGroup, precision int) ([]models.SpendingMapCell, error) {
+	// для ячейки geohash город - самый частый среди ее операций
+	query := `
+		SELECT LEFT(t.geohash, $4), COALESCE(MODE() WITHIN GROUP (ORDER BY NULLIF(t.city, '')), ''), t.currency,
+			COUNT(*), SUM(t.amount), AVG(t.latitude), AVG(t.longitude)
+		FROM transactions t
+		WHERE t.user_id = $1 AND t.date >= $2 AND t.date <= $3 AND t.deleted_at IS NULL
+			AND t.type = 'expense' AND t.geohash IS NOT NULL
+		GROUP BY 1, 3
+	`
+	args := []interface{}{userID, startDate, endDate, precision}
+	if groupBy == models.SpendingMapByCity {
+		query = `
+			SELECT t.city, t.city, t.currency, COUNT(*), SUM(t.amount), AVG(t.latitude), AVG(t.longitude)
+			FROM transactions t
+			WHERE t.user_id = $1 AND t.date >= $2 AND t.date <= $3 AND t.deleted_at IS NULL
+				AND t.type = 'expense' AND t.city <> ''
+			GROUP BY 1, 3
+		`
+		args = args[:3]
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cells []models.SpendingMapCell
+	for rows.Next() {
+		var cell models.SpendingMapCell
+		if err := rows.Scan(&cell.Key, &cell.City, &cell.Currency, &cell.Count, &cell.Total, &cell.Latitude, &cell.Longitude); err != nil {
+			return nil, err
+		}
+		cells = append(cells, cell)
+	}
+	return cells, rows.Err()
+}
+
+// GetSumByCategoryCurrency - суммы по категориям с разбивкой по валютам: валюта -> категория -> сумма
+func (r *transactionRepository) GetSumByCategoryCurrency(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, txType models.TransactionType) (map[string]map[uuid.UUID]decimal.Decimal, error) {
+	query := `
+		SELECT currency, category_id, SUM(amount)
+		FROM transactions
+		WHERE user_id = $1 AND date >= $2 AND date <= $3 AND type = $4 AND deleted_at IS NULL
+		GROUP BY currency, category_id
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID, startDate, endDate, txType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]map[uuid.UUID]decimal.Decimal)
+	for rows.Next() {
+		var currency string
+		var categoryID uuid.UUID
+		var sum decimal.Decimal
+		if err := rows.Scan(&currency, &categoryID, &sum); err != nil {
+			return nil, err
+		}
+		if result[currency] == nil {
+			result[currency] = make(map[uuid.UUID]decimal.Decimal)
+		}
+		result[currency][categoryID] = sum
+	}
+	return result, rows.Err()
+}
+
+// periodDateFormat формат TO_CHAR для группировки по периоду
+func periodDateFormat(groupBy string) string {
+	switch groupBy {
+	case "day":
+		return "YYYY-MM-DD"
+	case "week":
+		return "IYYY-IW"
+	case "year":
+		return "YYYY"
+	default:
+		return "YYYY-MM"
+	}
+}
+
+// GetRecurring возвращает исходные (родительские) повторяющиеся транзакции пользователя
+func (r *transactionRepository) GetRecurring(ctx context.Context, userID uuid.UUID) ([]models.Transaction, error) {
+	query := `
+		SELECT t.id, t.user_id, t.account_id, t.category_id, t.type, t.amount, t.currency, t.description, t.date, t.to_account_id, t.to_amount, t.is_recurring, t.recurrence_rule, t.parent_transaction_id, t.location, t.latitude, t.longitude, t.city, t.notes, t.created_at, t.updated_at, t.payee_id
+		FROM transactions t
+		WHERE t.user_id = $1 AND t.is_recurring = true AND t.parent_transaction_id IS NULL AND t.deleted_at IS NULL
+		ORDER BY t.date
+	`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var tx models.Transaction
+		err := rows.Scan(
+			&tx.ID, &tx.UserID, &tx.AccountID, &tx.CategoryID, &tx.Type,
+			&tx.Amount, &tx.Currency, &tx.Description, &tx.Date,
+			&tx.ToAccountID, &tx.ToAmount, &tx.IsRecurring, &tx.RecurrenceRule,
+			&tx.ParentTransactionID, &tx.Location, &tx.Latitude, &tx.Longitude, &tx.City, &tx.Notes,
+			&tx.CreatedAt, &tx.UpdatedAt, &tx.PayeeID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, rows.Err()
+}
+
+// GetByDateRange возвращает все транзакции за период без пагинации (для аналитики)
+func (r *transactionRepository) GetByDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, txType *models.TransactionType) ([]models.Transaction, error) {
+	query := `
+		SELECT t.id, t.user_id, t.account_id, t.category_id, t.type, t.amount, t.currency, t.description, t.date, t.to_account_id, t.to_amount, t.is_recurring, t.recurrence_rule, t.parent_transaction_id, t.location, t.latitude, t.longitude, t.city, t.notes, t.created_at, t.updated_at, t.payee_id
+		FROM transactions t
+		WHERE t.user_id = $1 AND t.date >= $2 AND t.date <= $3 AND t.deleted_at IS NULL
+	`
+	args := []interface{}{userID, startDate, endDate}
+	if txType != nil {
+		query += " AND t.type = $4"
+		args = append(args, *txType)
+	}
+	query += " ORDER BY t.date, t.created_at"
+
+	rows, err := r.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var tx models.Transaction
+		err := rows.Scan(
+			&tx.ID, &tx.UserID, &tx.AccountID, &tx.CategoryID, &tx.Type,
+			&tx.Amount, &tx.Currency, &tx.Description, &tx.Date,
+			&tx.ToAccountID, &tx.ToAmount, &tx.IsRecurring, &tx.RecurrenceRule,
+			&tx.ParentTransactionID, &tx.Location, &tx.Latitude, &tx.Longitude, &tx.City, &tx.Notes,
+			&tx.CreatedAt, &tx.UpdatedAt, &tx.PayeeID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, rows.Err()
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -13,9 +14,17 @@ type TxManager interface {
 	// WithTx оборачивает репу-метод и выполняет функцию внутри транзакции
 	// Если функци возвращает ошибку - транзакция откатывается
 	// Если функция завершается успешно - транзакция коммитится
+	// Вложенный вызов (в ctx уже есть транзакция) присоединяется к внешней
 	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// WithSavepoint как WithTx, но внутри внешней транзакции fn выполняется в SAVEPOINT: ошибка fn
+	// откатывает только ее изменения, и внешняя транзакция может продолжаться. если откатиться
+	// к savepoint не удалось, ошибка содержит ErrSavepointRollback и внешнюю транзакцию надо прервать
+	WithSavepoint(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
+// ErrSavepointRollback откат к savepoint не удался, внешняя транзакция непригодна
+var ErrSavepointRollback = errors.New("rollback to savepoint failed")
+
 // DBTX(database and transaction execut) единый интерфейс для работы с бд (его реализует и pool, и tx)
 type DBTX interface {
 	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
@@ -38,11 +47,16 @@ type txKey struct{}
 func (m *txManager) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
 	// Проверяем есть ли транзакция в ctx.Value
 	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
-		return fn(ctx) // Если внутри контекста уже есть транзакция просто выполняем репо-методы
+		return fn(ctx) // Если внутри контекста уже есть транзакция - используем ее
 	}
 
-	// Если нет, то начинаем новую транзакцию
-	tx, err := m.pool.Begin(ctx)
+	// Если нет, то начинаем новую транзакцию (начало транзакции можно безопасно повторить)
+	var tx pgx.Tx
+	err := withRetry(ctx, func() error {
+		var err error
+		tx, err = m.pool.Begin(ctx)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -61,10 +75,39 @@ func (m *txManager) WithTx(ctx context.Context, fn func(ctx context.Context) err
 	return tx.Commit(ctx)
 }
 
-// GetTxOrPool возвращает либо pool, либо tx из контекста
+// WithSavepoint выполняет fn во вложенной транзакции внутри внешней, без внешней - в обычной.
+// pgx делает вложенную транзакцию через SAVEPOINT: Rollback - это ROLLBACK TO SAVEPOINT, Commit - RELEASE SAVEPOINT.
+// повторять нечего: внутри транзакции временная ошибка все равно прервет внешнюю
+func (m *txManager) WithSavepoint(ctx context.Context, fn func(ctx context.Context) error) error {
+	outer, ok := ctx.Value(txKey{}).(pgx.Tx)
+	if !ok {
+		return m.WithTx(ctx, fn)
+	}
+
+	sp, err := outer.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, sp)); err != nil {
+		// не откатились к savepoint - внешняя транзакция в состоянии ошибки, продолжать ее нельзя
+		if rbErr := sp.Rollback(ctx); rbErr != nil {
+			return errors.Join(err, ErrSavepointRollback, rbErr)
+		}
+		return err
+	}
+
+	return sp.Commit(ctx)
+}
+
+// GetTxOrPool возвращает либо pool (с повтором при временных ошибках), либо tx из контекста.
+// если контекст разрешает читать с реплики, чтения идут на нее
 func GetTxOrPool(ctx context.Context, pool *pgxpool.Pool) DBTX {
 	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
 		return tx
 	}
-	return pool
+	if replica := replicaFor(ctx); replica != nil {
+		return &replicaDB{replica: replica, primary: &retryDB{pool: pool}}
+	}
+	return &retryDB{pool: pool}
 }
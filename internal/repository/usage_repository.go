@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// счетчики в user_usage
+const (
+	UsageMetricAICalls         = "ai_calls"
+	UsageMetricAttachmentBytes = "attachment_bytes"
+)
+
+type UsageRepository interface {
+	CountPortfolios(ctx context.Context, userID uuid.UUID) (int64, error)
+	// CountTransactionsSince транзакции, созданные начиная с since, включая удаленные
+	CountTransactionsSince(ctx context.Context, userID uuid.UUID, since time.Time) (int64, error)
+	Get(ctx context.Context, userID uuid.UUID, metric string, period time.Time) (int64, error)
+	// Add увеличивает счетчик, если он не выйдет за limit (0 - без ограничения); false - квота исчерпана
+	Add(ctx context.Context, userID uuid.UUID, metric string, period time.Time, amount, limit int64) (bool, error)
+}
+
+type usageRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewUsageRepository(pool *pgxpool.Pool) UsageRepository {
+	return &usageRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *usageRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+func (r *usageRepository) CountPortfolios(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM portfolios WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *usageRepository) CountTransactionsSince(ctx context.Context, userID uuid.UUID, since time.Time) (int64, error) {
+	var count int64
+	err := r.db(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM transactions WHERE user_id = $1 AND created_at >= $2`, userID, since).Scan(&count)
+	return count, err
+}
+
+func (r *usageRepository) Get(ctx context.Context, userID uuid.UUID, metric string, period time.Time) (int64, error) {
+	query := `SELECT amount FROM user_usage WHERE user_id = $1 AND metric = $2 AND period = $3`
+
+	var amount int64
+	err := r.db(ctx).QueryRow(ctx, query, userID, metric, period).Scan(&amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return amount, err
+}
+
+func (r *usageRepository) Add(ctx context.Context, userID uuid.UUID, metric string, period time.Time, amount, limit int64) (bool, error) {
+	// проверка и увеличение одним запросом, чтобы параллельные запросы не проскочили квоту
+	query := `
+		INSERT INTO user_usage (user_id, metric, period, amount)
+		SELECT $1, $2, $3, $4
+		WHERE $5 = 0 OR $4 <= $5
+		ON CONFLICT (user_id, metric, period) DO UPDATE
+		SET amount = user_usage.amount + EXCLUDED.amount
+		WHERE $5 = 0 OR user_usage.amount + EXCLUDED.amount <= $5
+		RETURNING amount
+	`
+
+	var total int64
+	err := r.db(ctx).QueryRow(ctx, query, userID, metric, period, amount, limit).Scan(&total)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -15,8 +15,19 @@ type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// GetDeletedByEmail последний удаленный (soft delete) пользователь с этим email
+	GetDeletedByEmail(ctx context.Context, email string) (*models.User, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.UserUpdate) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string, version int16) error
+	UpdateProfile(ctx context.Context, id uuid.UUID, update *models.UserProfileUpdate) error
+	SetAvatarKey(ctx context.Context, id uuid.UUID, key string) error
+	// MarkDemo помечает пользователя демо-аккаунтом: в него входят без пароля через /auth/demo
+	MarkDemo(ctx context.Context, id uuid.UUID) error
+	// GetWithAnomalyAlerts пользователи, включившие уведомления о подозрительных тратах
+	GetWithAnomalyAlerts(ctx context.Context) ([]uuid.UUID, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	// Restore отменяет soft delete; ErrDuplicateEmail - email уже занял другой пользователь
+	Restore(ctx context.Context, id uuid.UUID) error
 }
 
 // userRepository - ПРИВАТНАЯ структура, реализующая интерфейс UserRepository
@@ -31,10 +42,35 @@ func NewUserRepository(pool *pgxpool.Pool) UserRepository {
 	return &userRepository{pool: pool}
 }
 
+// db возвращает транзакцию из контекста или pool
+func (r *userRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+// ErrDuplicateEmail email уже занят другим неудаленным пользователем
+var ErrDuplicateEmail = errors.New("email already exists")
+
+const userColumns = `id, email, password_hash, password_hash_version, first_name, last_name, default_currency, timezone, locale, ai_enabled, include_investment_income, anomaly_alerts, phone, birth_date, avatar_key, is_demo, created_at, updated_at`
+
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.PasswordHashVersion,
+		&user.FirstName, &user.LastName,
+		&user.DefaultCurrency, &user.Timezone, &user.Locale, &user.AIEnabled, &user.IncludeInvestmentIncome, &user.AnomalyAlerts,
+		&user.Phone, &user.BirthDate, &user.AvatarKey, &user.IsDemo,
+		&user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (id, email, password_hash, first_name, last_name, default_currency, timezone, created_at, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		INSERT INTO users (id, email, password_hash, password_hash_version, first_name, last_name, default_currency, timezone, locale, ai_enabled, include_investment_income, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
 	`
 
 	if user.ID == uuid.Nil {
@@ -43,65 +79,35 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 
 	now := time.Now()
 	user.CreatedAt = now
-	user.CreatedAt = now
+	user.UpdatedAt = now
 
-	_, err := r.pool.Exec(ctx, query,
-		user.ID, user.Email, user.PasswordHash,
+	_, err := r.db(ctx).Exec(ctx, query,
+		user.ID, user.Email, user.PasswordHash, user.PasswordHashVersion,
 		user.FirstName, user.LastName,
-		user.DefaultCurrency, user.Timezone,
+		user.DefaultCurrency, user.Timezone, user.Locale, user.AIEnabled, user.IncludeInvestmentIncome,
 		user.CreatedAt, user.UpdatedAt,
 	)
+	// email уникален только среди неудаленных пользователей, занять его могли параллельно
+	if isUniqueViolation(err, "idx_users_email_active") {
+		return ErrDuplicateEmail
+	}
 	return err
 }
 
 func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
-	query := `
-		SELECT id, email, password_hash, first_name, last_name, default_currency, timezone, created_at, updated_at
-		FROM users
-		WHERE id = $1 AND deleted_at IS NULL
-	`
-	var user models.User
-	err := r.pool.QueryRow(ctx, query, id).Scan(
-		&user.ID, &user.Email, &user.PasswordHash,
-		&user.FirstName, &user.LastName,
-		&user.DefaultCurrency, &user.Timezone,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
-
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			// TODO:разделил так как потом можно добавить логирование для ноуроус
-			return nil, err
-		}
-		return nil, err
-	}
-	return &user, nil
-
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL`
+	return scanUser(r.db(ctx).QueryRow(ctx, query, id))
 }
 
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
-	query := `
-		SELECT id, email, password_hash, first_name, last_name, default_currency, timezone, created_at, updated_at
-		FROM users
-		WHERE email = $1 AND deleted_at IS NULL
-	`
-	var user models.User
-	err := r.pool.QueryRow(ctx, query, email).Scan(
-		&user.ID, &user.Email, &user.PasswordHash,
-		&user.FirstName, &user.LastName,
-		&user.DefaultCurrency, &user.Timezone,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
-
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			// TODO:разделил так как потом можно добавить логирование для ноуроус
-			return nil, err
-		}
-		return nil, err
-	}
-	return &user, nil
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1 AND deleted_at IS NULL`
+	return scanUser(r.db(ctx).QueryRow(ctx, query, email))
+}
 
+func (r *userRepository) GetDeletedByEmail(ctx context.Context, email string) (*models.User, error) {
+	// после удаления email могли занять заново, и удаленных с ним может быть несколько - берем последний
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC LIMIT 1`
+	return scanUser(r.db(ctx).QueryRow(ctx, query, email))
 }
 
 func (r *userRepository) Update(ctx context.Context, id uuid.UUID, update *models.UserUpdate) error {
@@ -111,18 +117,78 @@ func (r *userRepository) Update(ctx context.Context, id uuid.UUID, update *model
 			last_name = COALESCE($3, last_name),
 			default_currency = COALESCE($4, default_currency),
 			timezone = COALESCE($5, timezone),
-			updated_at = $6
-		WHERE id = $1 and deleted_at IS NOT NULL
+			ai_enabled = COALESCE($6, ai_enabled),
+			include_investment_income = COALESCE($7, include_investment_income),
+			locale = COALESCE($8, locale),
+			anomaly_alerts = COALESCE($10, anomaly_alerts),
+			updated_at = $9
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	_, err := r.pool.Exec(ctx, query, id, update.FirstName, update.LastName, update.DefaultCurrency,
-		update.Timezone, time.Now(),
+	_, err := r.db(ctx).Exec(ctx, query, id, update.FirstName, update.LastName, update.DefaultCurrency,
+		update.Timezone, update.AIEnabled, update.IncludeInvestmentIncome, update.Locale, time.Now(), update.AnomalyAlerts,
 	)
 	return err
 }
 
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string, version int16) error {
+	query := `UPDATE users SET password_hash = $2, password_hash_version = $3, updated_at = $4 WHERE id = $1 AND deleted_at IS NULL`
+	_, err := r.db(ctx).Exec(ctx, query, id, passwordHash, version, time.Now())
+	return err
+}
+
+func (r *userRepository) UpdateProfile(ctx context.Context, id uuid.UUID, update *models.UserProfileUpdate) error {
+	query := `
+		UPDATE users SET
+			phone = COALESCE($2, phone),
+			birth_date = COALESCE($3, birth_date),
+			updated_at = $4
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	_, err := r.db(ctx).Exec(ctx, query, id, update.Phone, update.BirthDate, time.Now())
+	return err
+}
+
+func (r *userRepository) SetAvatarKey(ctx context.Context, id uuid.UUID, key string) error {
+	query := `UPDATE users SET avatar_key = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL`
+	_, err := r.db(ctx).Exec(ctx, query, id, key, time.Now())
+	return err
+}
+
+func (r *userRepository) MarkDemo(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `UPDATE users SET is_demo = true WHERE id = $1`, id)
+	return err
+}
+
 func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `UPDATE users SET deleted_at = $2 WHERE id = $1`
-	_, err := r.pool.Exec(ctx, query, id, time.Now())
+	_, err := r.db(ctx).Exec(ctx, query, id, time.Now())
+	return err
+}
+
+func (r *userRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET deleted_at = NULL, updated_at = $2 WHERE id = $1 AND deleted_at IS NOT NULL`
+	_, err := r.db(ctx).Exec(ctx, query, id, time.Now())
+	if isUniqueViolation(err, "idx_users_email_active") {
+		return ErrDuplicateEmail
+	}
 	return err
 }
+
+func (r *userRepository) GetWithAnomalyAlerts(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := r.db(ctx).Query(ctx, `SELECT id FROM users WHERE anomaly_alerts AND deleted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
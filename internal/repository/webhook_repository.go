@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type WebhookRepository interface {
+	Create(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error)
+	// GetByTokenHash вебхук по хэшу токена из адреса
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.WebhookEndpoint, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.WebhookEndpoint, error)
+	Update(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	SetTokenHash(ctx context.Context, id uuid.UUID, tokenHash string) error
+	MarkUsed(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// CreateDelivery запоминает принятое уведомление; false - с таким external_id уже было
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (bool, error)
+	GetDelivery(ctx context.Context, endpointID uuid.UUID, externalID string) (*models.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookRepository(pool *pgxpool.Pool) WebhookRepository {
+	return &webhookRepository{pool: pool}
+}
+
+// db возвращает транзакцию из контекста или pool
+func (r *webhookRepository) db(ctx context.Context) DBTX {
+	return GetTxOrPool(ctx, r.pool)
+}
+
+const webhookColumns = `id, user_id, name, token_hash, account_id, category_id, income_category_id, template, is_active, last_used_at, created_at, updated_at`
+
+func scanWebhook(row interface {
+	Scan(dest ...interface{}) error
+}) (*models.WebhookEndpoint, error) {
+	var e models.WebhookEndpoint
+	var template []byte
+	err := row.Scan(
+		&e.ID, &e.UserID, &e.Name, &e.TokenHash, &e.AccountID, &e.CategoryID, &e.IncomeCategoryID,
+		&template, &e.IsActive, &e.LastUsedAt, &e.CreatedAt, &e.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(template, &e.Template); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *webhookRepository) Create(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	query := `
+		INSERT INTO webhook_endpoints (id, user_id, name, token_hash, account_id, category_id, income_category_id, template, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	template, err := json.Marshal(endpoint.Template)
+	if err != nil {
+		return err
+	}
+
+	if endpoint.ID == uuid.Nil {
+		endpoint.ID = uuid.New()
+	}
+	now := time.Now()
+	endpoint.CreatedAt = now
+	endpoint.UpdatedAt = now
+
+	_, err = r.db(ctx).Exec(ctx, query,
+		endpoint.ID, endpoint.UserID, endpoint.Name, endpoint.TokenHash, endpoint.AccountID, endpoint.CategoryID,
+		endpoint.IncomeCategoryID, template, endpoint.IsActive, endpoint.CreatedAt, endpoint.UpdatedAt,
+	)
+	return err
+}
+
+func (r *webhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhook_endpoints WHERE id = $1`
+	return scanWebhook(r.db(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *webhookRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.WebhookEndpoint, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhook_endpoints WHERE token_hash = $1`
+	return scanWebhook(r.db(ctx).QueryRow(ctx, query, tokenHash))
+}
+
+func (r *webhookRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.WebhookEndpoint, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhook_endpoints WHERE user_id = $1 ORDER BY created_at`
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []models.WebhookEndpoint
+	for rows.Next() {
+		e, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, *e)
+	}
+	return endpoints, rows.Err()
+}
+
+func (r *webhookRepository) Update(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	query := `
+		UPDATE webhook_endpoints SET
+			name = $2, account_id = $3, category_id = $4, income_category_id = $5, template = $6, is_active = $7, updated_at = $8
+		WHERE id = $1
+	`
+
+	template, err := json.Marshal(endpoint.Template)
+	if err != nil {
+		return err
+	}
+	endpoint.UpdatedAt = time.Now()
+
+	_, err = r.db(ctx).Exec(ctx, query,
+		endpoint.ID, endpoint.Name, endpoint.AccountID, endpoint.CategoryID, endpoint.IncomeCategoryID,
+		template, endpoint.IsActive, endpoint.UpdatedAt,
+	)
+	return err
+}
+
+func (r *webhookRepository) SetTokenHash(ctx context.Context, id uuid.UUID, tokenHash string) error {
+	_, err := r.db(ctx).Exec(ctx, `UPDATE webhook_endpoints SET token_hash = $2, updated_at = $3 WHERE id = $1`, id, tokenHash, time.Now())
+	return err
+}
+
+func (r *webhookRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `UPDATE webhook_endpoints SET last_used_at = $2 WHERE id = $1`, id, time.Now())
+	return err
+}
+
+func (r *webhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM webhook_endpoints WHERE id = $1`, id)
+	return err
+}
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (bool, error) {
+	query := `
+		INSERT INTO webhook_deliveries (endpoint_id, external_id, transaction_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (endpoint_id, external_id) DO NOTHING
+	`
+
+	delivery.CreatedAt = time.Now()
+	tag, err := r.db(ctx).Exec(ctx, query, delivery.EndpointID, delivery.ExternalID, delivery.TransactionID, delivery.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *webhookRepository) GetDelivery(ctx context.Context, endpointID uuid.UUID, externalID string) (*models.WebhookDelivery, error) {
+	query := `
+		SELECT endpoint_id, external_id, transaction_id, created_at
+		FROM webhook_deliveries WHERE endpoint_id = $1 AND external_id = $2
+	`
+
+	var d models.WebhookDelivery
+	err := r.db(ctx).QueryRow(ctx, query, endpointID, externalID).Scan(&d.EndpointID, &d.ExternalID, &d.TransactionID, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
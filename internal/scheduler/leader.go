@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Elector выбирает экземпляр сервера, который выполняет фоновые задачи, когда их запущено несколько
+type Elector interface {
+	// Campaign пытается стать ведущим или проверяет, что лидерство не потеряно; true - этот экземпляр ведущий
+	Campaign(ctx context.Context) bool
+	// Resign отдает лидерство при остановке, чтобы другой экземпляр подхватил задачи сразу
+	Resign()
+}
+
+// PostgresElector лидерство через advisory lock: блокировку держит отдельное соединение из пула,
+// при падении экземпляра Postgres снимает ее вместе с сессией
+type PostgresElector struct {
+	pool *pgxpool.Pool
+	key  int64
+	conn *pgxpool.Conn // не nil, пока этот экземпляр ведущий
+}
+
+// NewPostgresElector name - имя блокировки; экземпляры с одним именем и одной бд выбирают одного ведущего
+func NewPostgresElector(pool *pgxpool.Pool, name string) *PostgresElector {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return &PostgresElector{pool: pool, key: int64(h.Sum64())}
+}
+
+func (e *PostgresElector) Campaign(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// уже ведущий - проверяем, что сессия с блокировкой жива
+	if e.conn != nil {
+		if err := e.conn.Ping(ctx); err == nil {
+			return true
+		}
+		log.Printf("Соединение с блокировкой фоновых задач потеряно, экземпляр больше не ведущий")
+		e.conn.Release()
+		e.conn = nil
+	}
+
+	conn, err := e.pool.Acquire(ctx)
+	if err != nil {
+		log.Printf("Выбор ведущего для фоновых задач: %v", err)
+		return false
+	}
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, e.key).Scan(&locked); err != nil || !locked {
+		if err != nil {
+			log.Printf("Выбор ведущего для фоновых задач: %v", err)
+		}
+		conn.Release()
+		return false
+	}
+
+	log.Printf("Экземпляр стал ведущим, фоновые задачи выполняются здесь")
+	e.conn = conn
+	return true
+}
+
+func (e *PostgresElector) Resign() {
+	if e.conn == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, e.key)
+	e.conn.Release()
+	e.conn = nil
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// как часто экземпляр пытается стать ведущим (или проверяет, что еще ведущий)
+const electionInterval = 15 * time.Second
+
+// как часто с журналом запусков проверяется, не пора ли выполнить задачу: после смены ведущего
+// просроченные задачи выполняются в пределах этого времени, а не на следующем тике нового экземпляра
+const dueCheckInterval = time.Minute
+
+// Job - периодическая фоновая задача
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler запускает фоновые задачи с заданным интервалом. с Elector задачи выполняет только
+// ведущий экземпляр, остальные пропускают запуски, пока не станут ведущими сами. с JobStore
+// интервал отсчитывается от последнего запуска на любом экземпляре
+type Scheduler struct {
+	jobs    []Job
+	wg      sync.WaitGroup
+	elector Elector  // nil - один экземпляр, задачи выполняются всегда
+	store   JobStore // nil - интервал считается в памяти экземпляра
+	leader  atomic.Bool
+}
+
+func New(elector Elector, store JobStore) *Scheduler {
+	return &Scheduler{elector: elector, store: store}
+}
+
+// Add регистрирует задачу, вызывать до Start
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start запускает каждую задачу в своей горутине; задачи останавливаются при отмене ctx
+func (s *Scheduler) Start(ctx context.Context) {
+	if s.elector == nil {
+		s.leader.Store(true)
+	} else {
+		// до первого прогона задач уже известно, ведущий ли этот экземпляр
+		s.leader.Store(s.elector.Campaign(ctx))
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.elect(ctx)
+		}()
+	}
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+}
+
+// Wait ждет завершения всех задач после отмены контекста
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	tick := job.Interval
+	if s.store != nil && tick > dueCheckInterval {
+		tick = dueCheckInterval
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	// первый прогон сразу после старта (с журналом - если задача просрочена)
+	s.runOnce(ctx, job)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce(ctx, job)
+		}
+	}
+}
+
+// elect периодически переизбирает ведущего; при остановке отдает лидерство
+func (s *Scheduler) elect(ctx context.Context) {
+	ticker := time.NewTicker(electionInterval)
+	defer ticker.Stop()
+	defer s.elector.Resign()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.leader.Store(s.elector.Campaign(ctx))
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	if !s.leader.Load() {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Задача %s упала: %v", job.Name, r)
+		}
+	}()
+
+	if s.store == nil {
+		s.run(ctx, job)
+		return
+	}
+
+	// пока задача выполняется, ее не возьмет другой экземпляр, даже если лидерство уже перешло
+	release, ok := s.store.Lock(ctx, job.Name)
+	if !ok {
+		return
+	}
+	defer release()
+
+	last, err := s.store.LastRun(ctx, job.Name)
+	if err != nil {
+		log.Printf("Задача %s: не удалось прочитать время последнего запуска: %v", job.Name, err)
+		return
+	}
+	started := time.Now()
+	if started.Sub(last) < job.Interval {
+		return
+	}
+
+	s.run(ctx, job)
+	if err := s.store.MarkRun(ctx, job.Name, started); err != nil {
+		log.Printf("Задача %s: не удалось записать время запуска: %v", job.Name, err)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	if err := job.Run(ctx); err != nil {
+		log.Printf("Задача %s завершилась с ошибкой: %v", job.Name, err)
+	}
+}
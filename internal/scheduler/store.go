@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobStore общий для всех экземпляров журнал запусков задач. по нему новый ведущий понимает,
+// какие задачи просрочены, а блокировка задачи не дает выполнить ее дважды, пока лидерство переходит
+type JobStore interface {
+	// Lock занимает задачу; false - ее выполняет другой экземпляр. release снимает блокировку
+	Lock(ctx context.Context, name string) (release func(), ok bool)
+	// LastRun время последнего запуска задачи; нулевое - задача еще не запускалась
+	LastRun(ctx context.Context, name string) (time.Time, error)
+	MarkRun(ctx context.Context, name string, at time.Time) error
+}
+
+// PostgresJobStore журнал в таблице scheduler_jobs, блокировка задачи - advisory lock на время выполнения
+type PostgresJobStore struct {
+	pool   *pgxpool.Pool
+	prefix string
+}
+
+// NewPostgresJobStore prefix отделяет блокировки задач от блокировки ведущего и других приложений в той же бд
+func NewPostgresJobStore(pool *pgxpool.Pool, prefix string) *PostgresJobStore {
+	return &PostgresJobStore{pool: pool, prefix: prefix}
+}
+
+func (s *PostgresJobStore) Lock(ctx context.Context, name string) (func(), bool) {
+	h := fnv.New64a()
+	h.Write([]byte(s.prefix + ":" + name))
+	key := int64(h.Sum64())
+
+	// блокировка сессии: держим свое соединение, пока задача выполняется
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return nil, false
+	}
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&locked); err != nil || !locked {
+		conn.Release()
+		return nil, false
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, key)
+		conn.Release()
+	}, true
+}
+
+func (s *PostgresJobStore) LastRun(ctx context.Context, name string) (time.Time, error) {
+	var last time.Time
+	err := s.pool.QueryRow(ctx, `SELECT last_run_at FROM scheduler_jobs WHERE name = $1`, name).Scan(&last)
+	if err == pgx.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return last, err
+}
+
+func (s *PostgresJobStore) MarkRun(ctx context.Context, name string, at time.Time) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO scheduler_jobs (name, last_run_at) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET last_run_at = EXCLUDED.last_run_at
+	`, name, at)
+	return err
+}
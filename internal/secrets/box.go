@@ -0,0 +1,62 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+var (
+	ErrNoKey     = errors.New("ключ шифрования не задан")
+	ErrMalformed = errors.New("зашифрованное значение повреждено или зашифровано другим ключом")
+)
+
+// Box шифрует секреты пользователей (пароли почтовых ящиков, токены) для хранения в бд.
+// AES-256-GCM, ключ выводится из строки конфига через SHA-256
+type Box struct {
+	aead cipher.AEAD
+}
+
+func NewBox(key string) (*Box, error) {
+	if key == "" {
+		return nil, ErrNoKey
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal шифрует строку; результат - base64(nonce + шифротекст)
+func (b *Box) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *Box) Open(ciphertext string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	nonce, sealed := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrMalformed
+	}
+	return string(plaintext), nil
+}
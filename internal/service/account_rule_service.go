@@ -0,0 +1,380 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrAccountRuleNotFound = errors.New("account rule not found")
+	ErrInvalidRuleAmount   = errors.New("fee amount must be positive")
+	ErrInvalidRuleRate     = errors.New("rule rate must be greater than 0 and at most 100")
+	ErrInvalidRuleMax      = errors.New("cashback limit must be positive")
+)
+
+// за сколько прошлых месяцев догоняем пропущенные начисления (сервер лежал, правило выключали)
+const accountRuleCatchUpMonths = 3
+
+var monthsInYear = decimal.NewFromInt(12)
+
+// описания транзакций по умолчанию
+var accountRuleDescriptions = map[models.AccountRuleKind]string{
+	models.AccountRuleFee:      "Обслуживание счета",
+	models.AccountRuleCashback: "Кэшбэк",
+	models.AccountRuleInterest: "Проценты на остаток",
+}
+
+type AccountRuleService interface {
+	List(ctx context.Context, userID, accountID uuid.UUID) ([]models.AccountRule, error)
+	Create(ctx context.Context, userID, accountID uuid.UUID, input *models.AccountRuleCreate) (*models.AccountRule, error)
+	Update(ctx context.Context, userID, accountID, id uuid.UUID, update *models.AccountRuleUpdate) (*models.AccountRule, error)
+	// Delete удаляет правило; уже проведенные транзакции остаются
+	Delete(ctx context.Context, userID, accountID, id uuid.UUID) error
+	// ApplyDue проводит наступившие начисления и списания всех пользователей
+	ApplyDue(ctx context.Context) (*models.AccountRuleApplyResult, error)
+}
+
+type accountRuleService struct {
+	txManager          repository.TxManager
+	ruleRepo           repository.AccountRuleRepository
+	accountRepo        repository.AccountRepository
+	categoryRepo       repository.CategoryRepository
+	transactionService TransactionService
+}
+
+func NewAccountRuleService(txManager repository.TxManager, ruleRepo repository.AccountRuleRepository, accountRepo repository.AccountRepository, categoryRepo repository.CategoryRepository, transactionService TransactionService) AccountRuleService {
+	return &accountRuleService{
+		txManager:          txManager,
+		ruleRepo:           ruleRepo,
+		accountRepo:        accountRepo,
+		categoryRepo:       categoryRepo,
+		transactionService: transactionService,
+	}
+}
+
+func (s *accountRuleService) List(ctx context.Context, userID, accountID uuid.UUID) ([]models.AccountRule, error) {
+	if err := s.checkAccount(ctx, userID, accountID); err != nil {
+		return nil, err
+	}
+	rules, err := s.ruleRepo.GetByAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	today := truncateDay(time.Now())
+	for i := range rules {
+		enrichAccountRule(&rules[i], today)
+	}
+	return rules, nil
+}
+
+func (s *accountRuleService) Create(ctx context.Context, userID, accountID uuid.UUID, input *models.AccountRuleCreate) (*models.AccountRule, error) {
+	if err := s.checkAccount(ctx, userID, accountID); err != nil {
+		return nil, err
+	}
+
+	rule := &models.AccountRule{
+		UserID:           userID,
+		AccountID:        accountID,
+		Kind:             input.Kind,
+		Description:      input.Description,
+		CategoryID:       input.CategoryID,
+		DayOfMonth:       input.DayOfMonth,
+		Amount:           input.Amount,
+		Rate:             input.Rate,
+		SourceCategoryID: input.SourceCategoryID,
+		MaxAmount:        input.MaxAmount,
+		IsActive:         true,
+	}
+	if rule.Description == "" {
+		rule.Description = accountRuleDescriptions[rule.Kind]
+	}
+	if err := s.validate(ctx, userID, rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+	enrichAccountRule(rule, truncateDay(time.Now()))
+	return rule, nil
+}
+
+func (s *accountRuleService) Update(ctx context.Context, userID, accountID, id uuid.UUID, update *models.AccountRuleUpdate) (*models.AccountRule, error) {
+	rule, err := s.get(ctx, userID, accountID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// проверяем правило целиком, как оно будет после изменения
+	if update.CategoryID != nil {
+		rule.CategoryID = *update.CategoryID
+	}
+	if update.Amount != nil {
+		rule.Amount = update.Amount
+	}
+	if update.Rate != nil {
+		rule.Rate = update.Rate
+	}
+	if update.SourceCategoryID != nil {
+		rule.SourceCategoryID = update.SourceCategoryID
+	}
+	if update.MaxAmount != nil {
+		rule.MaxAmount = update.MaxAmount
+	}
+	if err := s.validate(ctx, userID, rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.ruleRepo.Update(ctx, id, update); err != nil {
+		return nil, err
+	}
+	return s.get(ctx, userID, accountID, id)
+}
+
+func (s *accountRuleService) Delete(ctx context.Context, userID, accountID, id uuid.UUID) error {
+	if _, err := s.get(ctx, userID, accountID, id); err != nil {
+		return err
+	}
+	deleted, err := s.ruleRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAccountRuleNotFound
+	}
+	return nil
+}
+
+func (s *accountRuleService) ApplyDue(ctx context.Context) (*models.AccountRuleApplyResult, error) {
+	rules, err := s.ruleRepo.GetActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	today := truncateDay(time.Now())
+	result := &models.AccountRuleApplyResult{}
+	for i := range rules {
+		rule := &rules[i]
+		for _, period := range accountRuleDuePeriods(rule, today) {
+			applied, err := s.apply(ctx, rule, period)
+			if err != nil {
+				result.Failed++
+				log.Printf("Не удалось применить правило счета %s за %s: %v", rule.ID, period.Format("2006-01"), err)
+				// следующие месяцы не трогаем, чтобы не было дыр: попробуем в следующий проход
+				break
+			}
+			if applied {
+				result.Applied++
+			} else {
+				result.Empty++
+			}
+		}
+	}
+	return result, nil
+}
+
+// apply проводит правило за месяц; false - сумма нулевая, месяц отмечен без транзакции
+func (s *accountRuleService) apply(ctx context.Context, rule *models.AccountRule, period time.Time) (bool, error) {
+	applied := false
+	err := s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		txType, amount, err := s.ruleAmount(txCtx, rule, period)
+		if err != nil {
+			return err
+		}
+
+		run := &models.AccountRuleRun{RuleID: rule.ID, Period: period, Amount: amount}
+		claimed, err := s.ruleRepo.CreateRun(txCtx, run)
+		if err != nil {
+			return err
+		}
+		// месяц уже обработан параллельным проходом
+		if !claimed || amount.IsZero() {
+			return nil
+		}
+
+		tx, err := s.transactionService.Create(txCtx, rule.UserID, &models.TransactionCreate{
+			AccountID:   rule.AccountID,
+			CategoryID:  rule.CategoryID,
+			Type:        txType,
+			Amount:      amount,
+			Description: rule.Description,
+			Date:        accountRuleChargeDate(rule, period),
+		})
+		if err != nil {
+			return err
+		}
+		applied = true
+		return s.ruleRepo.SetRunTransaction(txCtx, rule.ID, period, tx.ID)
+	})
+	return applied, err
+}
+
+// ruleAmount сумма и тип транзакции правила за месяц
+func (s *accountRuleService) ruleAmount(ctx context.Context, rule *models.AccountRule, period time.Time) (models.TransactionType, decimal.Decimal, error) {
+	switch rule.Kind {
+	case models.AccountRuleFee:
+		return models.TransactionTypeExpense, *rule.Amount, nil
+
+	case models.AccountRuleCashback:
+		// траты до создания правила не считаем
+		from := period
+		if created := truncateDay(rule.CreatedAt); created.After(from) {
+			from = created
+		}
+		to := period.AddDate(0, 1, 0).Add(-time.Nanosecond)
+		spent, err := s.ruleRepo.GetExpenseTotal(ctx, rule.AccountID, rule.SourceCategoryID, from, to)
+		if err != nil {
+			return "", decimal.Zero, err
+		}
+		return models.TransactionTypeIncome, cashbackAmount(rule, spent), nil
+
+	case models.AccountRuleInterest:
+		account, err := s.accountRepo.GetByID(ctx, rule.AccountID)
+		if err != nil {
+			return "", decimal.Zero, err
+		}
+		txType, amount := interestAmount(rule, account.Balance)
+		return txType, amount, nil
+	}
+	return "", decimal.Zero, ErrAccountRuleNotFound
+}
+
+func (s *accountRuleService) get(ctx context.Context, userID, accountID, id uuid.UUID) (*models.AccountRule, error) {
+	rule, err := s.ruleRepo.GetByID(ctx, id)
+	if err != nil || rule.UserID != userID || rule.AccountID != accountID {
+		return nil, ErrAccountRuleNotFound
+	}
+	enrichAccountRule(rule, truncateDay(time.Now()))
+	return rule, nil
+}
+
+func (s *accountRuleService) checkAccount(ctx context.Context, userID, accountID uuid.UUID) error {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil || account.UserID != userID {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+func (s *accountRuleService) checkCategory(ctx context.Context, userID, categoryID uuid.UUID) error {
+	category, err := s.categoryRepo.GetByID(ctx, categoryID)
+	if err != nil || (!category.IsSystem && (category.UserID == nil || *category.UserID != userID)) {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// validate проверяет поля, обязательные для вида правила
+func (s *accountRuleService) validate(ctx context.Context, userID uuid.UUID, rule *models.AccountRule) error {
+	switch rule.Kind {
+	case models.AccountRuleFee:
+		if rule.Amount == nil || !rule.Amount.IsPositive() {
+			return ErrInvalidRuleAmount
+		}
+	case models.AccountRuleCashback, models.AccountRuleInterest:
+		if rule.Rate == nil || !rule.Rate.IsPositive() || rule.Rate.GreaterThan(decimal.NewFromInt(100)) {
+			return ErrInvalidRuleRate
+		}
+	}
+	if rule.MaxAmount != nil && !rule.MaxAmount.IsPositive() {
+		return ErrInvalidRuleMax
+	}
+
+	if err := s.checkCategory(ctx, userID, rule.CategoryID); err != nil {
+		return err
+	}
+	if rule.Kind == models.AccountRuleCashback && rule.SourceCategoryID != nil {
+		return s.checkCategory(ctx, userID, *rule.SourceCategoryID)
+	}
+	return nil
+}
+
+// cashbackAmount кэшбэк с трат за месяц с учетом лимита
+func cashbackAmount(rule *models.AccountRule, spent decimal.Decimal) decimal.Decimal {
+	amount := spent.Mul(*rule.Rate).Div(decimal.NewFromInt(100)).Round(2)
+	if rule.MaxAmount != nil && amount.GreaterThan(*rule.MaxAmount) {
+		amount = *rule.MaxAmount
+	}
+	return amount
+}
+
+// interestAmount проценты за месяц: на положительный остаток - доход, на минусовой - списание
+func interestAmount(rule *models.AccountRule, balance decimal.Decimal) (models.TransactionType, decimal.Decimal) {
+	amount := balance.Abs().Mul(*rule.Rate).Div(decimal.NewFromInt(100)).Div(monthsInYear).Round(2)
+	if balance.IsNegative() {
+		return models.TransactionTypeExpense, amount
+	}
+	return models.TransactionTypeIncome, amount
+}
+
+// accountRuleChargeDate дата проведения правила за месяц period: комиссия и проценты - в том же месяце,
+// кэшбэк за траты месяца - в следующем. день больше длины месяца - последний день
+func accountRuleChargeDate(rule *models.AccountRule, period time.Time) time.Time {
+	month := period
+	if rule.Kind == models.AccountRuleCashback {
+		month = period.AddDate(0, 1, 0)
+	}
+	lastDay := month.AddDate(0, 1, -1).Day()
+	return month.AddDate(0, 0, min(rule.DayOfMonth, lastDay)-1)
+}
+
+// accountRuleDuePeriods месяцы, за которые правило пора провести к дню today
+func accountRuleDuePeriods(rule *models.AccountRule, today time.Time) []time.Time {
+	created := truncateDay(rule.CreatedAt)
+	start := monthStart(created)
+	if earliest := monthStart(today).AddDate(0, -accountRuleCatchUpMonths, 0); start.Before(earliest) {
+		start = earliest
+	}
+	if rule.LastPeriod != nil && !monthStart(*rule.LastPeriod).Before(start) {
+		start = monthStart(*rule.LastPeriod).AddDate(0, 1, 0)
+	}
+
+	var periods []time.Time
+	for period := start; ; period = period.AddDate(0, 1, 0) {
+		date := accountRuleChargeDate(rule, period)
+		if date.After(today) {
+			break
+		}
+		// дата списания в месяце создания уже прошла - начинаем со следующего
+		if date.Before(created) {
+			continue
+		}
+		periods = append(periods, period)
+	}
+	return periods
+}
+
+// enrichAccountRule заполняет дату следующего срабатывания
+func enrichAccountRule(rule *models.AccountRule, today time.Time) {
+	rule.NextDate = nil
+	if !rule.IsActive {
+		return
+	}
+	created := truncateDay(rule.CreatedAt)
+	start := monthStart(created)
+	if rule.LastPeriod != nil {
+		start = monthStart(*rule.LastPeriod).AddDate(0, 1, 0)
+	}
+	for period := start; ; period = period.AddDate(0, 1, 0) {
+		date := accountRuleChargeDate(rule, period)
+		if date.Before(created) {
+			continue
+		}
+		// непроведенный прошлый месяц проведет ближайший проход задачи
+		if date.Before(today) && period.Before(monthStart(today).AddDate(0, -accountRuleCatchUpMonths, 0)) {
+			continue
+		}
+		if date.Before(today) {
+			date = today
+		}
+		rule.NextDate = &date
+		return
+	}
+}
@@ -2,6 +2,9 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/alligatorO15/fin-tracker/internal/market"
 	"github.com/alligatorO15/fin-tracker/internal/models"
@@ -10,6 +13,14 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+var (
+	ErrInvalidAccountGrouping = errors.New("group_by must be one of: none, type, institution, custom")
+	ErrAccountOrderUnknown    = errors.New("account_ids and groups must contain only your accounts")
+	ErrInvalidAccountGroup    = errors.New("account group name must be up to 50 characters")
+)
+
+const maxAccountGroupLength = 50 // accounts.account_group VARCHAR(50)
+
 type AccountService interface {
 	Create(ctx context.Context, userID uuid.UUID, input *models.AccountCreate) (*models.Account, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Account, error)
@@ -18,16 +29,23 @@ type AccountService interface {
 	Update(ctx context.Context, id uuid.UUID, update *models.AccountUpdate) (*models.Account, error)
 	UpdateBalance(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error
 	Delete(ctx context.Context, id uuid.UUID) error
+
+	// GetList счета в сохраненном порядке с заголовками групп по настройке пользователя
+	GetList(ctx context.Context, userID uuid.UUID) (*models.AccountList, error)
+	// SetOrder сохраняет порядок, способ группировки и свои группы счетов; возвращает новый список
+	SetOrder(ctx context.Context, userID uuid.UUID, input *models.AccountOrder) (*models.AccountList, error)
 }
 
 type accountService struct {
+	txManager      repository.TxManager
 	accountRepo    repository.AccountRepository
 	userRepo       repository.UserRepository
 	marketProvider *market.MultiProvider
 }
 
-func NewAccountService(accountRepo repository.AccountRepository, userRepo repository.UserRepository, marketProvider *market.MultiProvider) AccountService {
+func NewAccountService(txManager repository.TxManager, accountRepo repository.AccountRepository, userRepo repository.UserRepository, marketProvider *market.MultiProvider) AccountService {
 	return &accountService{
+		txManager:      txManager,
 		accountRepo:    accountRepo,
 		userRepo:       userRepo,
 		marketProvider: marketProvider,
@@ -113,3 +131,140 @@ func (s *accountService) UpdateBalance(ctx context.Context, id uuid.UUID, amount
 func (s *accountService) Delete(ctx context.Context, id uuid.UUID) error {
 	return s.accountRepo.Delete(ctx, id)
 }
+
+func (s *accountService) GetList(ctx context.Context, userID uuid.UUID) (*models.AccountList, error) {
+	accounts, err := s.accountRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	groupBy, err := s.accountRepo.GetGrouping(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if accounts == nil {
+		accounts = []models.Account{}
+	}
+
+	return &models.AccountList{
+		GroupBy:  groupBy,
+		Groups:   groupAccounts(accounts, groupBy),
+		Accounts: accounts,
+	}, nil
+}
+
+func (s *accountService) SetOrder(ctx context.Context, userID uuid.UUID, input *models.AccountOrder) (*models.AccountList, error) {
+	if input.GroupBy != nil && !input.GroupBy.Valid() {
+		return nil, ErrInvalidAccountGrouping
+	}
+	for _, name := range input.Groups {
+		if utf8.RuneCountInString(strings.TrimSpace(name)) > maxAccountGroupLength {
+			return nil, ErrInvalidAccountGroup
+		}
+	}
+
+	accounts, err := s.accountRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	own := make(map[uuid.UUID]bool, len(accounts))
+	for _, account := range accounts {
+		own[account.ID] = true
+	}
+	for _, id := range input.AccountIDs {
+		if !own[id] {
+			return nil, ErrAccountOrderUnknown
+		}
+	}
+	for id := range input.Groups {
+		if !own[id] {
+			return nil, ErrAccountOrderUnknown
+		}
+	}
+
+	// переданные счета встают первыми, остальные - следом в прежнем порядке,
+	// поэтому нумерация всегда сплошная и клиент может прислать только видимую часть списка
+	var order []uuid.UUID
+	if len(input.AccountIDs) > 0 {
+		placed := make(map[uuid.UUID]bool, len(accounts))
+		order = make([]uuid.UUID, 0, len(accounts))
+		for _, id := range input.AccountIDs {
+			if !placed[id] {
+				placed[id] = true
+				order = append(order, id)
+			}
+		}
+		for _, account := range accounts {
+			if !placed[account.ID] {
+				order = append(order, account.ID)
+			}
+		}
+	}
+
+	groups := make(map[uuid.UUID]string, len(input.Groups))
+	for id, name := range input.Groups {
+		groups[id] = strings.TrimSpace(name)
+	}
+
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		if len(order) > 0 {
+			if err := s.accountRepo.SetOrder(txCtx, userID, order); err != nil {
+				return err
+			}
+		}
+		if len(groups) > 0 {
+			if err := s.accountRepo.SetGroups(txCtx, userID, groups); err != nil {
+				return err
+			}
+		}
+		if input.GroupBy != nil {
+			return s.accountRepo.SetGrouping(txCtx, userID, *input.GroupBy)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetList(ctx, userID)
+}
+
+// groupAccounts заголовки групп для уже упорядоченных счетов; группа встает на место своего первого счета
+func groupAccounts(accounts []models.Account, groupBy models.AccountGrouping) []models.AccountGroup {
+	groups := []models.AccountGroup{}
+	if groupBy == models.AccountGroupingNone || groupBy == "" {
+		return groups
+	}
+
+	index := make(map[string]int)
+	for _, account := range accounts {
+		var name string
+		switch groupBy {
+		case models.AccountGroupingType:
+			name = string(account.Type)
+		case models.AccountGroupingInstitution:
+			name = strings.TrimSpace(account.Institution)
+		case models.AccountGroupingCustom:
+			name = account.Group
+		}
+		// "Сбер" и "сбер" - одно учреждение; заголовок берем у первого счета
+		key := strings.ToLower(name)
+
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, models.AccountGroup{
+				Key:               key,
+				Name:              name,
+				AccountIDs:        []uuid.UUID{},
+				BalanceByCurrency: make(map[string]decimal.Decimal),
+			})
+		}
+		group := &groups[i]
+		group.AccountIDs = append(group.AccountIDs, account.ID)
+		if account.IsActive {
+			group.BalanceByCurrency[account.Currency] = group.BalanceByCurrency[account.Currency].Add(account.Balance)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrAIRefreshTooSoon = errors.New("AI recommendations were refreshed recently, try again later")
+	ErrAIBusy           = errors.New("too many AI recommendations are being generated, try again later")
+)
+
+const (
+	aiRecommendationsTTL     = 12 * time.Hour   // сколько отдаются сгенерированные рекомендации
+	aiRecommendationsTimeout = 3 * time.Minute  // сколько ждем модель в фоне (холодный старт Ollama долгий)
+	aiRefreshCooldown        = 10 * time.Minute // не чаще одной генерации за это время на пользователя
+	aiMaxGenerations         = 4                // одновременных генераций на экземпляр, остальные ждут следующего запроса
+)
+
+// aiRunning генерация идет; начатая раньше таймаута считается прерванной (например, экземпляр остановили)
+func aiRunning(run *models.AIRecommendationsRun, now time.Time) bool {
+	return run.Running && now.Sub(run.StartedAt) < aiRecommendationsTimeout
+}
+
+// aiFresh рекомендации есть и TTL не истек
+func aiFresh(run *models.AIRecommendationsRun, now time.Time) bool {
+	return len(run.Items) > 0 && run.GeneratedAt != nil && now.Sub(*run.GeneratedAt) < aiRecommendationsTTL
+}
+
+func aiRecommendationsState(run *models.AIRecommendationsRun, now time.Time) *models.AIRecommendationsState {
+	state := &models.AIRecommendationsState{Status: models.AIRecommendationsNone, Error: run.Error}
+	switch {
+	case aiRunning(run, now):
+		state.Status = models.AIRecommendationsRunning
+	case aiFresh(run, now):
+		state.Status = models.AIRecommendationsReady
+	case run.Error != "":
+		state.Status = models.AIRecommendationsFailed
+	}
+	if aiFresh(run, now) {
+		generatedAt, expiresAt := *run.GeneratedAt, run.GeneratedAt.Add(aiRecommendationsTTL)
+		state.GeneratedAt, state.ExpiresAt = &generatedAt, &expiresAt
+	}
+	if next := run.StartedAt.Add(aiRefreshCooldown); next.After(now) {
+		state.NextRefreshAt = &next
+	}
+	return state
+}
+
+// aiRun последняя генерация пользователя; nil - генераций еще не было
+func (s *analyticsService) aiRun(ctx context.Context, userID uuid.UUID) (*models.AIRecommendationsRun, error) {
+	run, err := s.repos.AIRecommendation.Get(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return run, err
+}
+
+// cachedAIRecommendations готовые рекомендации пользователя; если их нет или они устарели,
+// запускает генерацию в фоне (не чаще aiRefreshCooldown) и возвращает nil
+func (s *analyticsService) cachedAIRecommendations(ctx context.Context, userID uuid.UUID) []models.Recommendation {
+	run, err := s.aiRun(ctx, userID)
+	if err != nil {
+		log.Printf("Не удалось прочитать AI-рекомендации пользователя %s: %v", userID, err)
+		return nil
+	}
+
+	now := time.Now()
+	if run != nil && aiFresh(run, now) {
+		return run.Items
+	}
+	if run != nil && aiRunning(run, now) {
+		return nil
+	}
+
+	// нет свободного места - пользователь пока видит базовые рекомендации, генерация запустится при следующем запросе
+	if !s.acquireAISlot() {
+		return nil
+	}
+	startedAt := now.Truncate(time.Microsecond)
+	started, err := s.repos.AIRecommendation.Start(ctx, userID, startedAt, startedAt.Add(-aiRefreshCooldown))
+	if err != nil || !started {
+		s.releaseAISlot()
+		return nil
+	}
+	s.runAIRecommendations(userID, startedAt, false)
+	return nil
+}
+
+func (s *analyticsService) RefreshRecommendations(ctx context.Context, userID uuid.UUID) (*models.AIRecommendationsState, error) {
+	user, err := s.repos.User.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.AIEnabled {
+		return nil, ErrAIDisabled
+	}
+	if s.ai == nil || !s.ai.IsAvailable(ctx) {
+		return nil, ErrAIUnavailable
+	}
+
+	run, err := s.aiRun(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Truncate(time.Microsecond)
+	if run == nil {
+		run = &models.AIRecommendationsRun{UserID: userID}
+	}
+	if aiRunning(run, now) {
+		return aiRecommendationsState(run, now), nil
+	}
+
+	if !s.acquireAISlot() {
+		return nil, ErrAIBusy
+	}
+	// генерацию занимаем до списания квоты, чтобы параллельный refresh (в том числе на другом экземпляре)
+	// не списал ее второй раз
+	started, err := s.repos.AIRecommendation.Start(ctx, userID, now, now.Add(-aiRefreshCooldown))
+	if err != nil || !started {
+		s.releaseAISlot()
+		if err == nil {
+			err = ErrAIRefreshTooSoon
+		}
+		return nil, err
+	}
+
+	// квота списывается сразу, чтобы исчерпанный лимит был виден в ответе, а не в статусе
+	if err := s.quota.UseAICall(ctx, userID); err != nil {
+		s.releaseAISlot()
+		if cerr := s.repos.AIRecommendation.Cancel(ctx, userID, now, run.StartedAt); cerr != nil {
+			log.Printf("Не удалось отменить генерацию AI-рекомендаций пользователя %s: %v", userID, cerr)
+		}
+		return nil, err
+	}
+	s.runAIRecommendations(userID, now, true)
+
+	run.Running, run.StartedAt, run.Error = true, now, ""
+	return aiRecommendationsState(run, now), nil
+}
+
+func (s *analyticsService) GetRecommendationsState(ctx context.Context, userID uuid.UUID) (*models.AIRecommendationsState, error) {
+	run, err := s.aiRun(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return &models.AIRecommendationsState{Status: models.AIRecommendationsNone}, nil
+	}
+	return aiRecommendationsState(run, time.Now()), nil
+}
+
+// acquireAISlot занимает место под генерацию без ожидания; false - все места заняты
+func (s *analyticsService) acquireAISlot() bool {
+	select {
+	case s.aiSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *analyticsService) releaseAISlot() {
+	<-s.aiSlots
+}
+
+// runAIRecommendations генерирует рекомендации в фоне и сохраняет результат; место под генерацию
+// уже занято и освобождается по ее окончании. charged - квота уже списана
+func (s *analyticsService) runAIRecommendations(userID uuid.UUID, startedAt time.Time, charged bool) {
+	s.aiWG.Add(1)
+	go func() {
+		defer s.aiWG.Done()
+		defer s.releaseAISlot()
+
+		// запрос завершится раньше генерации, поэтому контекст свой; при остановке сервера он отменяется
+		ctx, cancel := context.WithTimeout(s.aiCtx, aiRecommendationsTimeout)
+		defer cancel()
+
+		items, err := s.generateAIRecommendations(ctx, userID, charged)
+		var errMsg string
+		if err != nil {
+			log.Printf("Не удалось сгенерировать AI-рекомендации пользователя %s: %v", userID, err)
+			errMsg = err.Error()
+		}
+
+		// результат пишем и после отмены, иначе генерация останется в бд выполняющейся
+		saveCtx, cancelSave := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelSave()
+		if err := s.repos.AIRecommendation.Finish(saveCtx, userID, startedAt, items, errMsg); err != nil {
+			log.Printf("Не удалось сохранить AI-рекомендации пользователя %s: %v", userID, err)
+		}
+	}()
+}
+
+// Shutdown прерывает фоновые генерации и ждет, пока они сохранят результат (не дольше ctx)
+func (s *analyticsService) Shutdown(ctx context.Context) {
+	s.aiCancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.aiWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func (s *analyticsService) generateAIRecommendations(ctx context.Context, userID uuid.UUID, charged bool) ([]models.Recommendation, error) {
+	if !charged {
+		if !s.ai.IsAvailable(ctx) {
+			return nil, ErrAIUnavailable
+		}
+		// при исчерпанном лимите пользователь просто видит базовые рекомендации
+		if err := s.quota.UseAICall(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	summary, _ := s.GetFinancialSummary(ctx, userID, models.PeriodMonth, nil, nil, "")
+	budgets, _ := s.repos.Budget.GetByUserID(ctx, userID, true)
+	currency := s.config.DefaultCurrency
+	if summary != nil {
+		currency = summary.Currency
+	}
+
+	advice, err := s.ai.GetFinancialAdvice(ctx, s.buildAISummary(summary, budgets, currency))
+	if err != nil {
+		return nil, err
+	}
+	if advice == "" {
+		return nil, ErrAIUnavailable
+	}
+	return []models.Recommendation{{
+		ID:          uuid.New(),
+		Type:        "ai",
+		Priority:    5,
+		Title:       "Персональные рекомендации",
+		Description: advice,
+		Impact:      "high",
+	}}, nil
+}
@@ -2,58 +2,135 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/ai"
 	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/market"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/repository"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
+var (
+	ErrAIDisabled          = errors.New("AI features are disabled for this user")
+	ErrAIUnavailable       = errors.New("AI provider is unavailable")
+	ErrInvalidFireScenario = errors.New("withdrawal_rate must be in (0, 20], return_rate in [-20, 30], savings_rate in [0, 100]")
+
+	ErrInvalidCashFlowDimension = errors.New("group_by must be category, account or tag")
+)
+
+// параметры FIRE по умолчанию: реальная доходность, правило 4%, горизонт в годах
+const (
+	fireDefaultReturn     = 5
+	fireDefaultWithdrawal = 4
+	fireDefaultYears      = 50
+	fireMaxYears          = 100
+)
+
+// название виртуальной категории для дивидендов и купонов в сводке
+const investmentIncomeCategory = "Инвестиционный доход"
+
 type AnalyticsService interface {
-	GetFinancialSummary(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time) (*models.FinancialSummary, error)
-	GetCashFlowReport(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time) (*models.CashFlowReport, error)
+	// currency - валюта отчета, пустая строка = валюта пользователя
+	GetFinancialSummary(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time, currency string) (*models.FinancialSummary, error)
+	// dimension - дополнительная разбивка (категория, счет, метка), пустая - только по времени
+	GetCashFlowReport(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time, currency string, dimension models.CashFlowDimension) (*models.CashFlowReport, error)
 	GetSpendingTrends(ctx context.Context, userID uuid.UUID, months int) ([]models.SpendingTrend, error)
-	GetNetWorthReport(ctx context.Context, userID uuid.UUID) (*models.NetWorthReport, error)
+	GetNetWorthReport(ctx context.Context, userID uuid.UUID, currency string) (*models.NetWorthReport, error)
 	GetFinancialHealth(ctx context.Context, userID uuid.UUID) (*models.FinancialHealth, error)
+	// GetFinancialHealthHistory снимки оценки по месяцам за последние months месяцев с изменениями баллов
+	GetFinancialHealthHistory(ctx context.Context, userID uuid.UUID, months int) (*models.FinancialHealthHistory, error)
+	// SnapshotFinancialHealth обновляет снимок текущего месяца у пользователей, чей снимок устарел
+	SnapshotFinancialHealth(ctx context.Context) (int, error)
+	// GetRecommendations отвечает сразу: AI-рекомендации из кэша, а если их нет или они устарели -
+	// базовые, пока AI-рекомендации генерируются в фоне
 	GetRecommendations(ctx context.Context, userID uuid.UUID) ([]models.Recommendation, error)
+	// RefreshRecommendations запускает генерацию AI-рекомендаций вне очереди: списывает AI-квоту,
+	// не чаще раза в 10 минут на пользователя
+	RefreshRecommendations(ctx context.Context, userID uuid.UUID) (*models.AIRecommendationsState, error)
+	GetRecommendationsState(ctx context.Context, userID uuid.UUID) (*models.AIRecommendationsState, error)
+	GetCashFlowForecast(ctx context.Context, userID uuid.UUID, months int) (*models.CashFlowForecast, error)
+	// GetFireProjection годы до финансовой независимости при заданном сценарии
+	GetFireProjection(ctx context.Context, userID uuid.UUID, scenario *models.FireScenario) (*models.FireProjection, error)
+	GetAnomalies(ctx context.Context, userID uuid.UUID, days int) ([]models.Anomaly, error)
+	// NotifyAnomalies уведомляет о свежих подозрительных тратах тех, кто включил anomaly_alerts
+	NotifyAnomalies(ctx context.Context) (int, error)
+	// GetSpendingMap траты с координатами или городом, сгруппированные по ячейкам geohash длины precision или по городам
+	GetSpendingMap(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time, currency string, groupBy models.SpendingMapGroup, precision int) (*models.SpendingMap, error)
+	// GetSpendingPatterns траты по дням недели, числам месяца и часам, календарь для тепловой карты
+	GetSpendingPatterns(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time, currency string) (*models.SpendingPatterns, error)
+	// SuggestCategory подбирает категорию по описанию операции через AI; nil - ни одна не подошла
+	SuggestCategory(ctx context.Context, userID uuid.UUID, description string, categoryType models.CategoryType) (*models.Category, error)
+	// GetAISummary краткая сводка финансов за месяц своими словами
+	GetAISummary(ctx context.Context, userID uuid.UUID) (string, error)
+	// Shutdown прерывает фоновые генерации AI-рекомендаций и ждет их завершения
+	Shutdown(ctx context.Context)
 }
 
 type analyticsService struct {
-	repos  *repository.Repositories
-	config *config.Config
-	ai     *ai.OllamaClient
+	repos          *repository.Repositories
+	marketProvider *market.MultiProvider
+	config         *config.Config
+	ai             ai.Client
+	quota          QuotaService
+	notifications  NotificationService
+
+	// фоновые генерации AI-рекомендаций: места под них, ожидание при остановке и их общий контекст
+	aiSlots  chan struct{}
+	aiWG     sync.WaitGroup
+	aiCtx    context.Context
+	aiCancel context.CancelFunc
 }
 
-func NewAnalyticsService(repos *repository.Repositories, cfg *config.Config, aiClient *ai.OllamaClient) AnalyticsService {
+func NewAnalyticsService(repos *repository.Repositories, marketProvider *market.MultiProvider, cfg *config.Config, aiClient ai.Client, quota QuotaService, notifications NotificationService) AnalyticsService {
+	aiCtx, aiCancel := context.WithCancel(context.Background())
 	return &analyticsService{
-		repos:  repos,
-		config: cfg,
-		ai:     aiClient,
+		repos:          repos,
+		marketProvider: marketProvider,
+		config:         cfg,
+		ai:             aiClient,
+		quota:          quota,
+		notifications:  notifications,
+		aiSlots:        make(chan struct{}, aiMaxGenerations),
+		aiCtx:          aiCtx,
+		aiCancel:       aiCancel,
 	}
 }
 
-func (s *analyticsService) GetFinancialSummary(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time) (*models.FinancialSummary, error) {
-	start, end := s.calculatePeriodDates(period, startDate, endDate)
-
+func (s *analyticsService) GetFinancialSummary(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time, currency string) (*models.FinancialSummary, error) {
 	user, err := s.repos.User.GetByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	start, end := s.calculatePeriodDates(period, startDate, endDate, localNow(user))
 
 	summary := &models.FinancialSummary{
 		Period:    period,
 		StartDate: start,
 		EndDate:   end,
-		Currency:  user.DefaultCurrency,
+		Currency:  reportCurrency(currency, user.DefaultCurrency, s.config.DefaultCurrency),
 	}
+	conv := newCurrencyConverter(s.marketProvider, summary.Currency)
 
 	// get income/expenses by category
-	incomeByCategory, _ := s.repos.Transaction.GetSumByCategory(ctx, userID, start, end, models.TransactionTypeIncome)
-	expensesByCategory, _ := s.repos.Transaction.GetSumByCategory(ctx, userID, start, end, models.TransactionTypeExpense)
+	incomeByCategory, err := s.sumByCategory(ctx, conv, userID, start, end, models.TransactionTypeIncome)
+	if err != nil {
+		return nil, err
+	}
+	expensesByCategory, err := s.sumByCategory(ctx, conv, userID, start, end, models.TransactionTypeExpense)
+	if err != nil {
+		return nil, err
+	}
 
 	categories, _ := s.repos.Category.GetByUserID(ctx, userID)
 	categoryMap := make(map[uuid.UUID]models.Category)
@@ -72,14 +149,36 @@ func (s *analyticsService) GetFinancialSummary(ctx context.Context, userID uuid.
 		})
 	}
 
+	if user.IncludeInvestmentIncome {
+		summary.InvestmentIncome, err = s.investmentIncome(ctx, conv, userID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if summary.InvestmentIncome.GreaterThan(decimal.Zero) {
+			summary.TotalIncome = summary.TotalIncome.Add(summary.InvestmentIncome)
+			// виртуальная категория: у выплат из портфеля категории нет
+			summary.IncomeByCategory = append(summary.IncomeByCategory, models.CategoryAmount{
+				CategoryName: investmentIncomeCategory,
+				CategoryIcon: "📈",
+				Amount:       summary.InvestmentIncome,
+			})
+		}
+	}
+
 	for categoryID, amount := range expensesByCategory {
 		summary.TotalExpenses = summary.TotalExpenses.Add(amount)
 		cat := categoryMap[categoryID]
+		if cat.IsFixed {
+			summary.FixedExpenses = summary.FixedExpenses.Add(amount)
+		} else {
+			summary.DiscretionaryExpenses = summary.DiscretionaryExpenses.Add(amount)
+		}
 		summary.ExpenseByCategory = append(summary.ExpenseByCategory, models.CategoryAmount{
 			CategoryID:   categoryID,
 			CategoryName: cat.Name,
 			CategoryIcon: cat.Icon,
 			Amount:       amount,
+			IsFixed:      cat.IsFixed,
 		})
 	}
 
@@ -106,24 +205,48 @@ func (s *analyticsService) GetFinancialSummary(ctx context.Context, userID uuid.
 	summary.NetSavings = summary.TotalIncome.Sub(summary.TotalExpenses)
 	if summary.TotalIncome.GreaterThan(decimal.Zero) {
 		summary.SavingsRate = summary.NetSavings.Div(summary.TotalIncome).Mul(decimal.NewFromInt(100))
+		summary.FixedCostRatio = summary.FixedExpenses.Div(summary.TotalIncome).Mul(decimal.NewFromInt(100))
 	}
 
 	accountSummary, _ := s.repos.Account.GetSummary(ctx, userID)
 	if accountSummary != nil {
-		summary.TotalBalance = accountSummary.TotalBalance
+		for cur, balance := range accountSummary.BalanceByCurrency {
+			converted, err := conv.convert(ctx, balance, cur)
+			if err != nil {
+				return nil, err
+			}
+			summary.TotalBalance = summary.TotalBalance.Add(converted)
+		}
 	}
 
 	// сравннение с предыд периодом
 	prevStart, prevEnd := s.calculatePreviousPeriod(start, end)
-	prevIncome, _ := s.repos.Transaction.GetSumByCategory(ctx, userID, prevStart, prevEnd, models.TransactionTypeIncome)
-	prevExpenses, _ := s.repos.Transaction.GetSumByCategory(ctx, userID, prevStart, prevEnd, models.TransactionTypeExpense)
+	prevIncome, err := s.sumByCategory(ctx, conv, userID, prevStart, prevEnd, models.TransactionTypeIncome)
+	if err != nil {
+		return nil, err
+	}
+	prevExpenses, err := s.sumByCategory(ctx, conv, userID, prevStart, prevEnd, models.TransactionTypeExpense)
+	if err != nil {
+		return nil, err
+	}
 
 	var prevTotalIncome, prevTotalExpenses decimal.Decimal
 	for _, amount := range prevIncome {
 		prevTotalIncome = prevTotalIncome.Add(amount)
 	}
-	for _, amount := range prevExpenses {
+	if user.IncludeInvestmentIncome {
+		prevInvestment, err := s.investmentIncome(ctx, conv, userID, prevStart, prevEnd)
+		if err != nil {
+			return nil, err
+		}
+		prevTotalIncome = prevTotalIncome.Add(prevInvestment)
+	}
+	var prevDiscretionary decimal.Decimal
+	for categoryID, amount := range prevExpenses {
 		prevTotalExpenses = prevTotalExpenses.Add(amount)
+		if !categoryMap[categoryID].IsFixed {
+			prevDiscretionary = prevDiscretionary.Add(amount)
+		}
 	}
 
 	summary.IncomeChange = summary.TotalIncome.Sub(prevTotalIncome)
@@ -135,11 +258,29 @@ func (s *analyticsService) GetFinancialSummary(ctx context.Context, userID uuid.
 		summary.ExpenseChangePct = summary.ExpenseChange.Div(prevTotalExpenses).Mul(decimal.NewFromInt(100))
 	}
 
+	summary.DiscretionaryChange = summary.DiscretionaryExpenses.Sub(prevDiscretionary)
+	if prevDiscretionary.GreaterThan(decimal.Zero) {
+		summary.DiscretionaryChangePct = summary.DiscretionaryChange.Div(prevDiscretionary).Mul(decimal.NewFromInt(100))
+	}
+
 	return summary, nil
 }
 
-func (s *analyticsService) GetCashFlowReport(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time) (*models.CashFlowReport, error) {
-	start, end := s.calculatePeriodDates(period, startDate, endDate)
+func (s *analyticsService) GetCashFlowReport(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time, currency string, dimension models.CashFlowDimension) (*models.CashFlowReport, error) {
+	switch dimension {
+	case "", models.CashFlowByCategory, models.CashFlowByAccount, models.CashFlowByTag:
+	default:
+		return nil, ErrInvalidCashFlowDimension
+	}
+	// границы периода - в часовом поясе пользователя, иначе операции утекают в соседний месяц
+	user, _ := s.repos.User.GetByID(ctx, userID)
+	now := time.Now().UTC()
+	userCurrency := ""
+	if user != nil {
+		now = localNow(user)
+		userCurrency = user.DefaultCurrency
+	}
+	start, end := s.calculatePeriodDates(period, startDate, endDate, now)
 
 	groupBy := "month"
 	switch period {
@@ -149,16 +290,44 @@ func (s *analyticsService) GetCashFlowReport(ctx context.Context, userID uuid.UU
 		groupBy = "week"
 	}
 
-	data, err := s.repos.Transaction.GetSumByPeriod(ctx, userID, start, end, groupBy)
+	byCurrency, err := s.repos.Transaction.GetSumByPeriodCurrency(ctx, userID, start, end, groupBy)
 	if err != nil {
 		return nil, err
 	}
 
-	user, _ := s.repos.User.GetByID(ctx, userID)
-	currency := s.config.DefaultCurrency
-	if user != nil {
-		currency = user.DefaultCurrency
+	currency = reportCurrency(currency, userCurrency, s.config.DefaultCurrency)
+	conv := newCurrencyConverter(s.marketProvider, currency)
+
+	// сводим валюты в одну строку на период
+	merged := make(map[string]*models.CashFlow)
+	for cur, flows := range byCurrency {
+		for _, cf := range flows {
+			date := periodStart(cf.Period, groupBy)
+			income, err := conv.convertAt(ctx, cf.Income, cur, date)
+			if err != nil {
+				return nil, err
+			}
+			expenses, err := conv.convertAt(ctx, cf.Expenses, cur, date)
+			if err != nil {
+				return nil, err
+			}
+
+			row, ok := merged[cf.Period]
+			if !ok {
+				row = &models.CashFlow{Period: cf.Period}
+				merged[cf.Period] = row
+			}
+			row.Income = row.Income.Add(income)
+			row.Expenses = row.Expenses.Add(expenses)
+		}
+	}
+
+	var data []models.CashFlow
+	for _, row := range merged {
+		row.Net = row.Income.Sub(row.Expenses)
+		data = append(data, *row)
 	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Period < data[j].Period })
 
 	report := &models.CashFlowReport{
 		Period:   period,
@@ -172,9 +341,77 @@ func (s *analyticsService) GetCashFlowReport(ctx context.Context, userID uuid.UU
 	}
 	report.NetFlow = report.TotalIn.Sub(report.TotalOut)
 
+	if dimension != "" {
+		report.GroupBy = dimension
+		if report.Groups, err = s.cashFlowGroups(ctx, conv, userID, start, end, groupBy, dimension, data); err != nil {
+			return nil, err
+		}
+	}
+
 	return report, nil
 }
 
+// cashFlowGroups матрица разбивки: суммы считает SQL, здесь только перевод в валюту отчета
+// и раскладка по колонкам-периодам отчета
+func (s *analyticsService) cashFlowGroups(ctx context.Context, conv *currencyConverter, userID uuid.UUID, start, end time.Time, groupBy string, dimension models.CashFlowDimension, data []models.CashFlow) ([]models.CashFlowGroup, error) {
+	cells, err := s.repos.Transaction.GetSumByPeriodDimension(ctx, userID, start, end, groupBy, dimension)
+	if err != nil {
+		return nil, err
+	}
+
+	column := make(map[string]int, len(data))
+	for i, cf := range data {
+		column[cf.Period] = i
+	}
+
+	groups := make(map[string]*models.CashFlowGroup)
+	var order []string
+	for _, cell := range cells {
+		i, ok := column[cell.Period]
+		if !ok {
+			continue
+		}
+		date := periodStart(cell.Period, groupBy)
+		income, err := conv.convertAt(ctx, cell.Income, cell.Currency, date)
+		if err != nil {
+			return nil, err
+		}
+		expenses, err := conv.convertAt(ctx, cell.Expenses, cell.Currency, date)
+		if err != nil {
+			return nil, err
+		}
+
+		group, ok := groups[cell.Key]
+		if !ok {
+			group = &models.CashFlowGroup{
+				Key:      cell.Key,
+				Name:     cell.Name,
+				Income:   make([]decimal.Decimal, len(data)),
+				Expenses: make([]decimal.Decimal, len(data)),
+			}
+			groups[cell.Key] = group
+			order = append(order, cell.Key)
+		}
+		group.Income[i] = group.Income[i].Add(income)
+		group.Expenses[i] = group.Expenses[i].Add(expenses)
+		group.TotalIncome = group.TotalIncome.Add(income)
+		group.TotalExpenses = group.TotalExpenses.Add(expenses)
+	}
+
+	result := make([]models.CashFlowGroup, 0, len(order))
+	for _, key := range order {
+		result = append(result, *groups[key])
+	}
+	// крупные расходы первыми, при равных - крупные доходы
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].TotalExpenses.Equal(result[j].TotalExpenses) {
+			return result[i].TotalExpenses.GreaterThan(result[j].TotalExpenses)
+		}
+		return result[i].TotalIncome.GreaterThan(result[j].TotalIncome)
+	})
+	return result, nil
+}
+
 func (s *analyticsService) GetSpendingTrends(ctx context.Context, userID uuid.UUID, months int) ([]models.SpendingTrend, error) {
 	if months <= 0 {
 		months = 6
@@ -256,7 +493,7 @@ func (s *analyticsService) GetSpendingTrends(ctx context.Context, userID uuid.UU
 	return trends, nil
 }
 
-func (s *analyticsService) GetNetWorthReport(ctx context.Context, userID uuid.UUID) (*models.NetWorthReport, error) {
+func (s *analyticsService) GetNetWorthReport(ctx context.Context, userID uuid.UUID, currency string) (*models.NetWorthReport, error) {
 	user, err := s.repos.User.GetByID(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -264,22 +501,27 @@ func (s *analyticsService) GetNetWorthReport(ctx context.Context, userID uuid.UU
 
 	report := &models.NetWorthReport{
 		Date:              time.Now(),
-		Currency:          user.DefaultCurrency,
+		Currency:          reportCurrency(currency, user.DefaultCurrency, s.config.DefaultCurrency),
 		AssetsByType:      make(map[string]decimal.Decimal),
 		LiabilitiesByType: make(map[string]decimal.Decimal),
 	}
+	conv := newCurrencyConverter(s.marketProvider, report.Currency)
 
 	accounts, _ := s.repos.Account.GetByUserID(ctx, userID)
 	for _, acc := range accounts {
 		if !acc.IsActive {
 			continue
 		}
+		balance, err := conv.convert(ctx, acc.Balance, acc.Currency)
+		if err != nil {
+			return nil, err
+		}
 		if acc.Type == models.AccountTypeDebt || acc.Type == models.AccountTypeCredit {
-			report.TotalLiabilities = report.TotalLiabilities.Add(acc.Balance.Abs())
-			report.LiabilitiesByType[string(acc.Type)] = report.LiabilitiesByType[string(acc.Type)].Add(acc.Balance.Abs())
+			report.TotalLiabilities = report.TotalLiabilities.Add(balance.Abs())
+			report.LiabilitiesByType[string(acc.Type)] = report.LiabilitiesByType[string(acc.Type)].Add(balance.Abs())
 		} else {
-			report.TotalAssets = report.TotalAssets.Add(acc.Balance)
-			report.AssetsByType[string(acc.Type)] = report.AssetsByType[string(acc.Type)].Add(acc.Balance)
+			report.TotalAssets = report.TotalAssets.Add(balance)
+			report.AssetsByType[string(acc.Type)] = report.AssetsByType[string(acc.Type)].Add(balance)
 		}
 	}
 
@@ -287,19 +529,61 @@ func (s *analyticsService) GetNetWorthReport(ctx context.Context, userID uuid.UU
 	for _, p := range portfolios {
 		holdings, _ := s.repos.Holding.GetByPortfolioID(ctx, p.ID)
 		for _, h := range holdings {
-			report.TotalAssets = report.TotalAssets.Add(h.CurrentValue)
-			report.AssetsByType["investment"] = report.AssetsByType["investment"].Add(h.CurrentValue)
+			value, err := conv.convert(ctx, h.CurrentValue, h.ValueCurrency(p.Currency))
+			if err != nil {
+				return nil, err
+			}
+			report.TotalAssets = report.TotalAssets.Add(value)
+			report.AssetsByType["investment"] = report.AssetsByType["investment"].Add(value)
 		}
 	}
 
+	// активы с ручной оценкой попадают в распределение под своим классом
+	customAssets, _ := s.repos.CustomAsset.GetByUserID(ctx, userID, true)
+	for _, a := range customAssets {
+		value, err := conv.convert(ctx, a.CurrentValue, a.Currency)
+		if err != nil {
+			return nil, err
+		}
+		report.TotalAssets = report.TotalAssets.Add(value)
+		report.AssetsByType[string(a.Class)] = report.AssetsByType[string(a.Class)].Add(value)
+	}
+
+	// вклады - тело вместе с начисленными на сегодня процентами
+	deposits, _ := s.repos.Deposit.GetByUserID(ctx, userID, true)
+	for i := range deposits {
+		withDepositInterest(&deposits[i], report.Date)
+		value, err := conv.convert(ctx, deposits[i].CurrentValue, deposits[i].Currency)
+		if err != nil {
+			return nil, err
+		}
+		report.TotalAssets = report.TotalAssets.Add(value)
+		report.AssetsByType["deposit"] = report.AssetsByType["deposit"].Add(value)
+	}
+
 	report.NetWorth = report.TotalAssets.Sub(report.TotalLiabilities)
 	return report, nil
 }
 
 func (s *analyticsService) GetFinancialHealth(ctx context.Context, userID uuid.UUID) (*models.FinancialHealth, error) {
+	health := s.computeFinancialHealth(ctx, userID)
+	if _, err := s.saveHealthSnapshot(ctx, userID, health); err != nil {
+		log.Printf("Не удалось сохранить оценку финансового здоровья %s: %v", userID, err)
+	}
+
+	health.TopRecommendations, _ = s.GetRecommendations(ctx, userID)
+	if len(health.TopRecommendations) > 3 {
+		health.TopRecommendations = health.TopRecommendations[:3]
+	}
+
+	return health, nil
+}
+
+// computeFinancialHealth баллы и показатели за текущий месяц, без рекомендаций
+func (s *analyticsService) computeFinancialHealth(ctx context.Context, userID uuid.UUID) *models.FinancialHealth {
 	health := &models.FinancialHealth{}
 
-	summary, _ := s.GetFinancialSummary(ctx, userID, models.PeriodMonth, nil, nil)
+	summary, _ := s.GetFinancialSummary(ctx, userID, models.PeriodMonth, nil, nil, "")
 
 	// выставляем баллы по сбережениям
 	if summary != nil && summary.TotalIncome.GreaterThan(decimal.Zero) {
@@ -334,7 +618,7 @@ func (s *analyticsService) GetFinancialHealth(ctx context.Context, userID uuid.U
 	}
 
 	// по обязательствам
-	netWorth, _ := s.GetNetWorthReport(ctx, userID)
+	netWorth, _ := s.GetNetWorthReport(ctx, userID, "")
 	if netWorth != nil && summary != nil && summary.TotalIncome.GreaterThan(decimal.Zero) {
 		monthlyDebt := netWorth.TotalLiabilities.Div(decimal.NewFromInt(12))
 		debtToIncome := monthlyDebt.Div(summary.TotalIncome).InexactFloat64()
@@ -376,6 +660,15 @@ func (s *analyticsService) GetFinancialHealth(ctx context.Context, userID uuid.U
 		}
 	}
 
+	// обязательные расходы: их не урезать, поэтому резерв меряем и ими отдельно
+	if summary != nil {
+		health.FixedCostRatio = summary.FixedCostRatio
+		health.DiscretionaryTrend = summary.DiscretionaryChangePct
+		if summary.FixedExpenses.GreaterThan(decimal.Zero) {
+			health.FixedCostMonths = liquidAssets.Div(summary.FixedExpenses).Round(2)
+		}
+	}
+
 	// общая оценка (буквенная)
 	health.OverallScore = (health.SavingsScore + health.BudgetScore + health.DebtScore + health.EmergencyFundScore) / 4
 
@@ -392,142 +685,1512 @@ func (s *analyticsService) GetFinancialHealth(ctx context.Context, userID uuid.U
 		health.Grade = "F"
 	}
 
-	health.TopRecommendations, _ = s.GetRecommendations(ctx, userID)
-	if len(health.TopRecommendations) > 3 {
-		health.TopRecommendations = health.TopRecommendations[:3]
-	}
+	return health
+}
 
-	return health, nil
+// сколько месяцев истории оценки отдаем по умолчанию и максимум
+const (
+	defaultHealthHistoryMonths = 12
+	maxHealthHistoryMonths     = 60
+)
+
+// healthSnapshotTTL снимок текущего месяца старше этого пересчитывается фоновой задачей
+const healthSnapshotTTL = 20 * time.Hour
+
+// healthMonth первое число месяца, к которому относится снимок
+func healthMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
 }
 
-func (s *analyticsService) GetRecommendations(ctx context.Context, userID uuid.UUID) ([]models.Recommendation, error) {
-	summary, _ := s.GetFinancialSummary(ctx, userID, models.PeriodMonth, nil, nil)
-	budgets, _ := s.repos.Budget.GetByUserID(ctx, userID, true)
-	user, _ := s.repos.User.GetByID(ctx, userID)
+func (s *analyticsService) saveHealthSnapshot(ctx context.Context, userID uuid.UUID, health *models.FinancialHealth) (*models.FinancialHealthSnapshot, error) {
+	snapshot := &models.FinancialHealthSnapshot{
+		UserID:              userID,
+		Month:               healthMonth(time.Now()),
+		OverallScore:        health.OverallScore,
+		Grade:               health.Grade,
+		SavingsScore:        health.SavingsScore,
+		BudgetScore:         health.BudgetScore,
+		DebtScore:           health.DebtScore,
+		EmergencyFundScore:  health.EmergencyFundScore,
+		SavingsRate:         health.SavingsRate.Round(4),
+		DebtToIncomeRatio:   health.DebtToIncomeRatio.Round(4),
+		EmergencyFundMonths: health.EmergencyFundMonths.Round(4),
+		FixedCostMonths:     health.FixedCostMonths.Round(4),
+		FixedCostRatio:      health.FixedCostRatio.Round(4),
+		DiscretionaryTrend:  health.DiscretionaryTrend.Round(4),
+	}
+	if err := s.repos.HealthSnapshot.Upsert(ctx, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
 
-	currency := s.config.DefaultCurrency
-	if user != nil {
-		currency = user.DefaultCurrency
+func (s *analyticsService) GetFinancialHealthHistory(ctx context.Context, userID uuid.UUID, months int) (*models.FinancialHealthHistory, error) {
+	if months <= 0 {
+		months = defaultHealthHistoryMonths
+	}
+	if months > maxHealthHistoryMonths {
+		months = maxHealthHistoryMonths
 	}
 
-	// пробуем получить ai рекомендации
-	if s.ai != nil && s.ai.IsAvailable(ctx) {
-		aiSummary := s.buildAISummary(summary, budgets, currency)
-		if advice, err := s.ai.GetFinancialAdvice(ctx, aiSummary); err == nil && advice != "" {
-			return []models.Recommendation{{
-				ID:          uuid.New(),
-				Type:        "ai",
-				Priority:    5,
-				Title:       "Персональные рекомендации",
-				Description: advice,
-				Impact:      "high",
-			}}, nil
-		}
+	// текущий месяц всегда актуален: пересчитываем перед выдачей истории
+	current, err := s.saveHealthSnapshot(ctx, userID, s.computeFinancialHealth(ctx, userID))
+	if err != nil {
+		return nil, err
 	}
 
-	// fallback: простые правила если ai недоступен (если вернет пустой срез фронт покажет что нибуль типо круто)
-	return s.getBasicRecommendations(summary, budgets)
+	from := current.Month.AddDate(0, -(months - 1), 0)
+	snapshots, err := s.repos.HealthSnapshot.GetByUserID(ctx, userID, from)
+	if err != nil {
+		return nil, err
+	}
+	// история может читаться с реплики, где свежего снимка еще нет
+	if n := len(snapshots); n > 0 && snapshots[n-1].Month.Equal(current.Month) {
+		snapshots[n-1] = *current
+	} else {
+		snapshots = append(snapshots, *current)
+	}
+
+	history := &models.FinancialHealthHistory{Months: months, Snapshots: snapshots}
+	for i := 1; i < len(snapshots); i++ {
+		snapshots[i].Delta = healthDelta(&snapshots[i-1], &snapshots[i])
+	}
+	if n := len(snapshots); n > 1 {
+		history.Change = healthDelta(&snapshots[0], &snapshots[n-1])
+	}
+
+	return history, nil
 }
 
-func (s *analyticsService) buildAISummary(summary *models.FinancialSummary, budgets []models.Budget, currency string) ai.FinancialSummary {
-	aiSummary := ai.FinancialSummary{Currency: currency}
+// healthDelta изменение баллов от снимка from к снимку to
+func healthDelta(from, to *models.FinancialHealthSnapshot) *models.FinancialHealthDelta {
+	return &models.FinancialHealthDelta{
+		OverallScore:       to.OverallScore - from.OverallScore,
+		SavingsScore:       to.SavingsScore - from.SavingsScore,
+		BudgetScore:        to.BudgetScore - from.BudgetScore,
+		DebtScore:          to.DebtScore - from.DebtScore,
+		EmergencyFundScore: to.EmergencyFundScore - from.EmergencyFundScore,
+	}
+}
 
-	if summary != nil {
-		aiSummary.TotalIncome = summary.TotalIncome
-		aiSummary.TotalExpenses = summary.TotalExpenses
-		aiSummary.Balance = summary.TotalBalance
-		aiSummary.SavingsRate = summary.SavingsRate
+func (s *analyticsService) SnapshotFinancialHealth(ctx context.Context) (int, error) {
+	now := time.Now()
+	userIDs, err := s.repos.HealthSnapshot.GetStaleUserIDs(ctx, healthMonth(now), now.Add(-healthSnapshotTTL))
+	if err != nil {
+		return 0, err
+	}
 
-		for _, cat := range summary.ExpenseByCategory {
-			aiSummary.TopCategories = append(aiSummary.TopCategories, ai.CategorySpending{
-				Name:   cat.CategoryName,
-				Amount: cat.Amount,
-			})
+	saved := 0
+	for _, userID := range userIDs {
+		if _, err := s.saveHealthSnapshot(ctx, userID, s.computeFinancialHealth(ctx, userID)); err != nil {
+			return saved, err
 		}
+		saved++
 	}
+	return saved, nil
+}
 
-	for _, b := range budgets {
-		aiSummary.BudgetStatus = append(aiSummary.BudgetStatus, ai.BudgetStatus{
-			Category: b.Name,
-			Limit:    b.Amount,
-			Spent:    b.Spent,
-			Percent:  decimal.NewFromFloat(b.SpentPercent),
-		})
+func (s *analyticsService) GetRecommendations(ctx context.Context, userID uuid.UUID) ([]models.Recommendation, error) {
+	user, _ := s.repos.User.GetByID(ctx, userID)
+
+	// ai рекомендации (если пользователь от них не отказался); модель не ждем - генерация идет в фоне
+	if s.ai != nil && (user == nil || user.AIEnabled) {
+		if items := s.cachedAIRecommendations(ctx, userID); items != nil {
+			return items, nil
+		}
 	}
 
-	return aiSummary
+	// fallback: простые правила пока ai рекомендаций нет (если вернет пустой срез фронт покажет что нибуль типо круто)
+	summary, _ := s.GetFinancialSummary(ctx, userID, models.PeriodMonth, nil, nil, "")
+	budgets, _ := s.repos.Budget.GetByUserID(ctx, userID, true)
+	return s.getBasicRecommendations(summary, budgets)
 }
 
-func (s *analyticsService) getBasicRecommendations(summary *models.FinancialSummary, budgets []models.Budget) ([]models.Recommendation, error) {
-	var recs []models.Recommendation
+// GetFireProjection по умолчанию берет чистый капитал, доходы и расходы за последние 12 месяцев;
+// любой параметр можно заменить в сценарии. капитал считается помесячно со сложным процентом
+func (s *analyticsService) GetFireProjection(ctx context.Context, userID uuid.UUID, scenario *models.FireScenario) (*models.FireProjection, error) {
+	returnRate := decimal.NewFromInt(fireDefaultReturn)
+	if scenario.ReturnRate != nil {
+		returnRate = *scenario.ReturnRate
+	}
+	withdrawalRate := decimal.NewFromInt(fireDefaultWithdrawal)
+	if scenario.WithdrawalRate != nil {
+		withdrawalRate = *scenario.WithdrawalRate
+	}
+	if !withdrawalRate.IsPositive() || withdrawalRate.GreaterThan(decimal.NewFromInt(20)) ||
+		returnRate.LessThan(decimal.NewFromInt(-20)) || returnRate.GreaterThan(decimal.NewFromInt(30)) ||
+		(scenario.SavingsRate != nil && (scenario.SavingsRate.IsNegative() || scenario.SavingsRate.GreaterThan(decimal.NewFromInt(100)))) {
+		return nil, ErrInvalidFireScenario
+	}
+	years := scenario.MaxYears
+	if years <= 0 {
+		years = fireDefaultYears
+	}
+	years = min(years, fireMaxYears)
 
-	// проверка нормы сбережений
-	if summary != nil && summary.TotalIncome.GreaterThan(decimal.Zero) {
-		if summary.SavingsRate.LessThan(decimal.NewFromInt(10)) {
-			recs = append(recs, models.Recommendation{
-				ID:          uuid.New(),
-				Type:        "savings",
-				Priority:    5,
-				Title:       "Увеличьте норму сбережений",
-				Description: "Рекомендуется откладывать минимум 10% дохода.",
-				Impact:      "high",
-			})
+	user, err := s.repos.User.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	projection := &models.FireProjection{
+		Currency:       reportCurrency(scenario.Currency, user.DefaultCurrency, s.config.DefaultCurrency),
+		ReturnRate:     returnRate,
+		WithdrawalRate: withdrawalRate,
+	}
+
+	if scenario.NetWorth != nil {
+		projection.NetWorth = *scenario.NetWorth
+	} else {
+		report, err := s.GetNetWorthReport(ctx, userID, projection.Currency)
+		if err != nil {
+			return nil, err
 		}
+		projection.NetWorth = report.NetWorth
 	}
 
-	// проверка бюджетов
-	for _, b := range budgets {
-		if b.SpentPercent >= 90 {
-			recs = append(recs, models.Recommendation{
-				ID:          uuid.New(),
-				Type:        "budget",
-				Priority:    4,
-				Title:       "Бюджет «" + b.Name + "» близок к лимиту",
-				Description: "Израсходовано более 90%.",
-				Impact:      "medium",
-			})
+	if scenario.AnnualIncome == nil || (scenario.AnnualExpenses == nil && scenario.SavingsRate == nil) {
+		end := time.Now()
+		start := end.AddDate(-1, 0, 0)
+		summary, err := s.GetFinancialSummary(ctx, userID, models.PeriodYear, &start, &end, projection.Currency)
+		if err != nil {
+			return nil, err
 		}
+		projection.AnnualIncome = summary.TotalIncome
+		projection.AnnualExpenses = summary.TotalExpenses
+	}
+	if scenario.AnnualIncome != nil {
+		projection.AnnualIncome = *scenario.AnnualIncome
+	}
+	if scenario.AnnualExpenses != nil {
+		projection.AnnualExpenses = *scenario.AnnualExpenses
+	}
+	// норма сбережений задает расходы как долю дохода
+	if scenario.SavingsRate != nil {
+		projection.AnnualExpenses = projection.AnnualIncome.Mul(decimal.NewFromInt(100).Sub(*scenario.SavingsRate)).Div(decimal.NewFromInt(100))
+	}
+	projection.AnnualExpenses = projection.AnnualExpenses.Round(2)
+	projection.AnnualSavings = projection.AnnualIncome.Sub(projection.AnnualExpenses)
+	if projection.AnnualIncome.IsPositive() {
+		projection.SavingsRate = projection.AnnualSavings.Div(projection.AnnualIncome).Mul(decimal.NewFromInt(100)).Round(2)
 	}
 
-	return recs, nil
-}
+	projection.FireNumber = projection.AnnualExpenses.Mul(decimal.NewFromInt(100)).Div(withdrawalRate).Round(2)
+	if projection.FireNumber.IsPositive() {
+		projection.Progress = projection.NetWorth.Div(projection.FireNumber).Mul(decimal.NewFromInt(100)).Round(2)
+	}
 
-func (s *analyticsService) calculatePeriodDates(period models.Period, startDate, endDate *time.Time) (time.Time, time.Time) {
 	now := time.Now()
+	projection.CurrentAge = user.Age(now)
+	point := func(year int, netWorth, contributions, growth float64) models.FirePoint {
+		return models.FirePoint{
+			Year:          year,
+			Age:           user.Age(now.AddDate(year, 0, 0)),
+			NetWorth:      decimal.NewFromFloat(netWorth).Round(2),
+			Contributions: decimal.NewFromFloat(contributions).Round(2),
+			Growth:        decimal.NewFromFloat(growth).Round(2),
+			FireNumber:    projection.FireNumber,
+		}
+	}
 
-	if startDate != nil && endDate != nil {
-		return *startDate, *endDate
+	netWorth := projection.NetWorth.InexactFloat64()
+	fireNumber := projection.FireNumber.InexactFloat64()
+	projection.Series = []models.FirePoint{point(0, netWorth, 0, 0)}
+	// без расходов цель не определена: показываем только текущее состояние
+	if fireNumber <= 0 {
+		return projection, nil
 	}
 
-	switch period {
-	case models.PeriodDay:
-		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		return start, now
-	case models.PeriodWeek:
-		weekday := int(now.Weekday())
-		if weekday == 0 {
-			weekday = 7
+	monthlyReturn := math.Pow(1+returnRate.InexactFloat64()/100, 1.0/12) - 1
+	monthlySavings := projection.AnnualSavings.InexactFloat64() / 12
+	reachedMonth := -1
+	if netWorth >= fireNumber {
+		reachedMonth = 0
+	}
+
+	var contributions, growth float64
+	for month := 1; month <= years*12 && reachedMonth != 0; month++ {
+		// доходность только на положительный капитал: долги считаем без процентов
+		monthGrowth := 0.0
+		if netWorth > 0 {
+			monthGrowth = netWorth * monthlyReturn
 		}
-		start := now.AddDate(0, 0, -weekday+1)
-		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
-		return start, now
-	case models.PeriodMonth:
-		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), now
-	case models.PeriodQuarter:
-		quarter := (int(now.Month()) - 1) / 3
-		return time.Date(now.Year(), time.Month(quarter*3+1), 1, 0, 0, 0, 0, now.Location()), now
-	case models.PeriodYear:
-		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location()), now
-	case models.PeriodAll:
-		return time.Date(2000, 1, 1, 0, 0, 0, 0, now.Location()), now
-	default:
-		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), now
+		netWorth += monthGrowth + monthlySavings
+		growth += monthGrowth
+		contributions += monthlySavings
+
+		if reachedMonth < 0 && netWorth >= fireNumber {
+			reachedMonth = month
+		}
+		if month%12 == 0 {
+			projection.Series = append(projection.Series, point(month/12, netWorth, contributions, growth))
+			// ряд доводим до конца года, в котором цель достигнута
+			if reachedMonth > 0 {
+				break
+			}
+		}
+	}
+
+	if reachedMonth >= 0 {
+		fireDate := now.AddDate(0, reachedMonth, 0)
+		yearsToFire := decimal.NewFromInt(int64(reachedMonth)).Div(decimal.NewFromInt(12)).Round(1)
+		projection.Reached = reachedMonth == 0
+		projection.YearsToFire = &yearsToFire
+		projection.FireDate = &fireDate
+		projection.FireAge = user.Age(fireDate)
 	}
+
+	return projection, nil
 }
 
-func (s *analyticsService) calculatePreviousPeriod(currentStart, currentEnd time.Time) (time.Time, time.Time) {
-	duration := currentEnd.Sub(currentStart)
-	prevEnd := currentStart.Add(-time.Second)
-	prevStart := prevEnd.Add(-duration)
-	return prevStart, prevEnd
+// GetCashFlowForecast прогнозирует остаток на ликвидных счетах на 1-6 месяцев вперед
+// учитываются: повторяющиеся транзакции, платежи по кредитам, автопополнения целей,
+// запланированные разовые платежи, средние нерегулярные доходы/расходы за последние 3 месяца
+// и ожидаемые дивиденды/купоны
+func (s *analyticsService) GetCashFlowForecast(ctx context.Context, userID uuid.UUID, months int) (*models.CashFlowForecast, error) {
+	if months <= 0 {
+		months = 3
+	}
+	if months > 6 {
+		months = 6
+	}
+
+	user, err := s.repos.User.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := localNow(user)
+	firstMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	horizonEnd := firstMonth.AddDate(0, months, 0)
+
+	forecast := &models.CashFlowForecast{
+		Currency: user.DefaultCurrency,
+		Months:   months,
+		Points:   make([]models.ForecastPoint, months),
+	}
+	for i := range forecast.Points {
+		forecast.Points[i].Period = firstMonth.AddDate(0, i, 0).Format("2006-01")
+	}
+
+	// индекс месяца прогноза для даты (-1 если вне горизонта или уже прошла)
+	monthIndex := func(date time.Time) int {
+		if !date.After(now) || !date.Before(horizonEnd) {
+			return -1
+		}
+		return (date.Year()-firstMonth.Year())*12 + int(date.Month()) - int(firstMonth.Month())
+	}
+
+	// ликвидные счета: с них и на них идут деньги
+	accounts, err := s.repos.Account.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	accountTypes := make(map[uuid.UUID]models.AccountType)
+	for _, acc := range accounts {
+		accountTypes[acc.ID] = acc.Type
+		if acc.IsActive && isLiquidAccount(acc.Type) {
+			forecast.StartingBalance = forecast.StartingBalance.Add(acc.Balance)
+		}
+	}
+
+	// повторяющиеся транзакции разворачиваем в конкретные даты внутри горизонта
+	recurringByCategory := make(map[models.TransactionType]map[uuid.UUID]decimal.Decimal)
+	recurringByCategory[models.TransactionTypeIncome] = make(map[uuid.UUID]decimal.Decimal)
+	recurringByCategory[models.TransactionTypeExpense] = make(map[uuid.UUID]decimal.Decimal)
+
+	recurring, err := s.repos.Transaction.GetRecurring(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range recurring {
+		years, monthsStep, days, ok := parseRecurrenceRule(tx.RecurrenceRule)
+		if !ok {
+			continue
+		}
+
+		source := ""
+		switch tx.Type {
+		case models.TransactionTypeIncome:
+			source = "recurring_income"
+		case models.TransactionTypeExpense:
+			source = "recurring_expense"
+		case models.TransactionTypeTransfer:
+			// перевод между ликвидными счетами на остаток не влияет
+			if tx.ToAccountID == nil || isLiquidAccount(accountTypes[*tx.ToAccountID]) {
+				continue
+			}
+			toType := accountTypes[*tx.ToAccountID]
+			if toType == models.AccountTypeCredit || toType == models.AccountTypeDebt {
+				source = "loan"
+			} else {
+				source = "recurring_expense"
+			}
+		}
+		if source == "" {
+			continue
+		}
+
+		var total decimal.Decimal
+		occurrences := 0
+		// каждую дату считаем от исходной: цепочка AddDate с 31-го числа уползает на начало месяцев
+		for n := 0; occurrences < 1000; n++ {
+			date := tx.Date.AddDate(years*n, monthsStep*n, days*n)
+			if !date.Before(horizonEnd) {
+				break
+			}
+			idx := monthIndex(date)
+			if idx < 0 {
+				continue
+			}
+			occurrences++
+			point := &forecast.Points[idx]
+			switch source {
+			case "recurring_income":
+				point.RecurringIncome = point.RecurringIncome.Add(tx.Amount)
+			case "recurring_expense":
+				point.RecurringExpenses = point.RecurringExpenses.Add(tx.Amount)
+			case "loan":
+				point.LoanPayments = point.LoanPayments.Add(tx.Amount)
+			}
+			total = total.Add(tx.Amount)
+		}
+
+		if total.IsZero() {
+			continue
+		}
+
+		// чтобы не учесть дважды в средних по категориям - запоминаем месячную сумму
+		if tx.Type != models.TransactionTypeTransfer {
+			perMonth := total.Div(decimal.NewFromInt(int64(months)))
+			recurringByCategory[tx.Type][tx.CategoryID] = recurringByCategory[tx.Type][tx.CategoryID].Add(perMonth)
+		}
+
+		forecast.Items = append(forecast.Items, models.ForecastItem{
+			Source:      source,
+			ReferenceID: tx.ID,
+			Description: tx.Description,
+			Amount:      total,
+		})
+	}
+
+	// комиссии, кэшбэк и проценты по правилам счетов
+	ruleItems, err := s.forecastAccountRules(ctx, userID, forecast.Currency, now, accounts, forecast.Points, monthIndex, recurringByCategory)
+	if err != nil {
+		return nil, err
+	}
+	forecast.Items = append(forecast.Items, ruleItems...)
+
+	// средние нерегулярные доходы и расходы за последние 3 полных месяца
+	const lookbackMonths = 3
+	lookbackStart := firstMonth.AddDate(0, -lookbackMonths, 0)
+	lookbackEnd := firstMonth.Add(-time.Nanosecond)
+	averages := make(map[models.TransactionType]decimal.Decimal)
+	for _, txType := range []models.TransactionType{models.TransactionTypeIncome, models.TransactionTypeExpense} {
+		sums, err := s.repos.Transaction.GetSumByCategory(ctx, userID, lookbackStart, lookbackEnd, txType)
+		if err != nil {
+			return nil, err
+		}
+		for categoryID, sum := range sums {
+			avg := sum.Div(decimal.NewFromInt(lookbackMonths)).Sub(recurringByCategory[txType][categoryID])
+			if avg.GreaterThan(decimal.Zero) {
+				averages[txType] = averages[txType].Add(avg)
+			}
+		}
+	}
+
+	// автопополнения целей
+	activeStatus := models.GoalStatusActive
+	goals, _ := s.repos.Goal.GetByUserID(ctx, userID, &activeStatus)
+	for _, g := range goals {
+		if !g.AutoContribute || g.ContributeAmount.LessThanOrEqual(decimal.Zero) {
+			continue
+		}
+
+		perMonth := g.ContributeAmount
+		switch g.ContributeFreq {
+		case "daily":
+			perMonth = g.ContributeAmount.Mul(decimal.NewFromInt(30))
+		case "weekly":
+			perMonth = g.ContributeAmount.Mul(decimal.NewFromInt(52)).Div(decimal.NewFromInt(12))
+		}
+
+		var total decimal.Decimal
+		remaining := g.TargetAmount.Sub(g.CurrentAmount)
+		for i := range forecast.Points {
+			// цель не пополняется сверх целевой суммы
+			amount := decimal.Min(perMonth, remaining)
+			if amount.LessThanOrEqual(decimal.Zero) {
+				break
+			}
+			forecast.Points[i].GoalContributions = forecast.Points[i].GoalContributions.Add(amount)
+			remaining = remaining.Sub(amount)
+			total = total.Add(amount)
+		}
+
+		if total.IsPositive() {
+			forecast.Items = append(forecast.Items, models.ForecastItem{
+				Source:      "goal",
+				ReferenceID: g.ID,
+				Description: g.Name,
+				Amount:      total,
+			})
+		}
+	}
+
+	// дивиденды и купоны по позициям
+	forecast.Items = append(forecast.Items, s.forecastInvestmentIncome(ctx, userID, forecast.Points, monthIndex)...)
+
+	// вклады, которые закончатся в горизонте прогноза
+	depositItems, err := s.forecastDeposits(ctx, userID, forecast.Currency, accountTypes, forecast.Points, monthIndex)
+	if err != nil {
+		return nil, err
+	}
+	forecast.Items = append(forecast.Items, depositItems...)
+
+	// разовые запланированные платежи (просроченные, но не проведенные - ожидаем в текущем месяце)
+	planned, err := s.repos.Planned.GetPlannedBefore(ctx, userID, horizonEnd)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range planned {
+		idx := monthIndex(p.DueDate)
+		if p.DueDate.Before(now) {
+			idx = 0
+		}
+		if idx < 0 {
+			continue
+		}
+
+		source := ""
+		switch p.Type {
+		case models.TransactionTypeIncome:
+			source = "planned_income"
+			forecast.Points[idx].PlannedIncome = forecast.Points[idx].PlannedIncome.Add(p.Amount)
+		case models.TransactionTypeExpense:
+			source = "planned_expense"
+			forecast.Points[idx].PlannedExpenses = forecast.Points[idx].PlannedExpenses.Add(p.Amount)
+		case models.TransactionTypeTransfer:
+			// перевод между ликвидными счетами на остаток не влияет
+			if p.ToAccountID == nil || isLiquidAccount(accountTypes[*p.ToAccountID]) {
+				continue
+			}
+			toType := accountTypes[*p.ToAccountID]
+			if toType == models.AccountTypeCredit || toType == models.AccountTypeDebt {
+				source = "loan"
+				forecast.Points[idx].LoanPayments = forecast.Points[idx].LoanPayments.Add(p.Amount)
+			} else {
+				source = "planned_expense"
+				forecast.Points[idx].PlannedExpenses = forecast.Points[idx].PlannedExpenses.Add(p.Amount)
+			}
+		}
+		if source == "" {
+			continue
+		}
+
+		forecast.Items = append(forecast.Items, models.ForecastItem{
+			Source:      source,
+			ReferenceID: p.ID,
+			Description: p.Description,
+			Amount:      p.Amount,
+		})
+	}
+
+	// собираем остатки по месяцам
+	balance := forecast.StartingBalance
+	forecast.LowestBalance = balance
+	for i := range forecast.Points {
+		point := &forecast.Points[i]
+
+		// в текущем месяце ожидаем только оставшуюся долю средних доходов/расходов
+		share := decimal.NewFromInt(1)
+		if i == 0 {
+			daysInMonth := firstMonth.AddDate(0, 1, -1).Day()
+			share = decimal.NewFromInt(int64(daysInMonth - now.Day() + 1)).Div(decimal.NewFromInt(int64(daysInMonth)))
+		}
+		point.ExpectedIncome = averages[models.TransactionTypeIncome].Mul(share).Round(2)
+		point.ExpectedSpending = averages[models.TransactionTypeExpense].Mul(share).Round(2)
+
+		point.NetFlow = point.RecurringIncome.
+			Add(point.ExpectedIncome).
+			Add(point.InvestmentIncome).
+			Add(point.DepositMaturities).
+			Add(point.PlannedIncome).
+			Add(point.AccountCredits).
+			Sub(point.RecurringExpenses).
+			Sub(point.AccountFees).
+			Sub(point.PlannedExpenses).
+			Sub(point.LoanPayments).
+			Sub(point.GoalContributions).
+			Sub(point.ExpectedSpending)
+
+		balance = balance.Add(point.NetFlow)
+		point.ProjectedBalance = balance
+		if balance.LessThan(forecast.LowestBalance) {
+			forecast.LowestBalance = balance
+		}
+	}
+	forecast.EndingBalance = balance
+
+	return forecast, nil
+}
+
+// forecastInvestmentIncome раскладывает ожидаемые дивиденды и купоны по месяцам прогноза
+func (s *analyticsService) forecastInvestmentIncome(ctx context.Context, userID uuid.UUID, points []models.ForecastPoint, monthIndex func(time.Time) int) []models.ForecastItem {
+	var items []models.ForecastItem
+
+	portfolios, _ := s.repos.Portfolio.GetByUserID(ctx, userID)
+	for _, p := range portfolios {
+		if !p.IsActive {
+			continue
+		}
+		holdings, _ := s.repos.Holding.GetByPortfolioID(ctx, p.ID)
+		for _, h := range holdings {
+			if h.Security == nil || h.Quantity.LessThanOrEqual(decimal.Zero) {
+				continue
+			}
+
+			var total decimal.Decimal
+			source := "dividend"
+
+			if h.Security.Type == models.SecurityTypeBond {
+				// купоны: номинал × ставка / частота, даты считаем назад от погашения
+				source = "coupon"
+				sec := h.Security
+				if sec.FaceValue == nil || sec.CouponRate == nil || sec.CouponFreq == nil || *sec.CouponFreq <= 0 || sec.MaturityDate == nil {
+					continue
+				}
+				coupon := sec.FaceValue.Mul(*sec.CouponRate).Div(decimal.NewFromInt(100)).Div(decimal.NewFromInt(int64(*sec.CouponFreq)))
+				step := 12 / *sec.CouponFreq
+				if step <= 0 {
+					step = 1
+				}
+				now := time.Now()
+				for n := 0; ; n++ {
+					date := sec.MaturityDate.AddDate(0, -step*n, 0)
+					if !date.After(now) {
+						break
+					}
+					if idx := monthIndex(date); idx >= 0 {
+						amount := coupon.Mul(h.Quantity)
+						points[idx].InvestmentIncome = points[idx].InvestmentIncome.Add(amount)
+						total = total.Add(amount)
+					}
+				}
+			} else if s.marketProvider != nil {
+				divs, err := s.marketProvider.GetDividends(ctx, h.Security.Ticker, h.Security.Exchange)
+				if err != nil {
+					continue
+				}
+				for _, d := range divs {
+					date := d.PaymentDate
+					if date.IsZero() {
+						date = d.ExDate
+					}
+					if idx := monthIndex(date); idx >= 0 {
+						amount := d.Amount.Mul(h.Quantity)
+						points[idx].InvestmentIncome = points[idx].InvestmentIncome.Add(amount)
+						total = total.Add(amount)
+					}
+				}
+			}
+
+			if total.IsPositive() {
+				items = append(items, models.ForecastItem{
+					Source:      source,
+					ReferenceID: h.SecurityID,
+					Description: h.Security.Ticker,
+					Amount:      total,
+				})
+			}
+		}
+	}
+
+	return items
+}
+
+// forecastAccountRules раскладывает срабатывания правил ликвидных счетов по месяцам прогноза.
+// кэшбэк оцениваем по средним тратам счета за 3 прошлых месяца, проценты - по текущему остатку.
+// в средние по категориям эти суммы уже попали, поэтому добавляем их в recurringByCategory
+func (s *analyticsService) forecastAccountRules(ctx context.Context, userID uuid.UUID, currency string, now time.Time, accounts []models.Account, points []models.ForecastPoint, monthIndex func(time.Time) int, recurringByCategory map[models.TransactionType]map[uuid.UUID]decimal.Decimal) ([]models.ForecastItem, error) {
+	rules, err := s.repos.AccountRule.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accountsByID := make(map[uuid.UUID]*models.Account, len(accounts))
+	for i := range accounts {
+		accountsByID[accounts[i].ID] = &accounts[i]
+	}
+
+	conv := newCurrencyConverter(s.marketProvider, currency)
+	firstMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	var items []models.ForecastItem
+	for i := range rules {
+		rule := &rules[i]
+		account := accountsByID[rule.AccountID]
+		if account == nil || !isLiquidAccount(account.Type) {
+			continue
+		}
+
+		// сумма за один месяц в валюте счета
+		txType := models.TransactionTypeExpense
+		var amount decimal.Decimal
+		switch rule.Kind {
+		case models.AccountRuleFee:
+			amount = *rule.Amount
+		case models.AccountRuleCashback:
+			spent, err := s.repos.AccountRule.GetExpenseTotal(ctx, rule.AccountID, rule.SourceCategoryID, firstMonth.AddDate(0, -3, 0), firstMonth.Add(-time.Nanosecond))
+			if err != nil {
+				return nil, err
+			}
+			txType, amount = models.TransactionTypeIncome, cashbackAmount(rule, spent.Div(decimal.NewFromInt(3)))
+		case models.AccountRuleInterest:
+			txType, amount = interestAmount(rule, account.Balance)
+		}
+		amount, err = conv.convert(ctx, amount, account.Currency)
+		if err != nil {
+			return nil, err
+		}
+		if amount.IsZero() {
+			continue
+		}
+
+		var total decimal.Decimal
+		for idx := range points {
+			// кэшбэк за траты прошлого месяца приходит в этом
+			period := firstMonth.AddDate(0, idx, 0)
+			if rule.Kind == models.AccountRuleCashback {
+				period = period.AddDate(0, -1, 0)
+			}
+			if rule.LastPeriod != nil && !monthStart(period).After(monthStart(*rule.LastPeriod)) {
+				continue
+			}
+			if monthIndex(accountRuleChargeDate(rule, period)) != idx {
+				continue
+			}
+
+			if txType == models.TransactionTypeIncome {
+				points[idx].AccountCredits = points[idx].AccountCredits.Add(amount)
+			} else {
+				points[idx].AccountFees = points[idx].AccountFees.Add(amount)
+			}
+			total = total.Add(amount)
+		}
+		if total.IsZero() {
+			continue
+		}
+
+		recurringByCategory[txType][rule.CategoryID] = recurringByCategory[txType][rule.CategoryID].Add(amount)
+
+		source := "account_fee"
+		switch {
+		case rule.Kind == models.AccountRuleCashback:
+			source = "account_cashback"
+		case rule.Kind == models.AccountRuleInterest && txType == models.TransactionTypeIncome:
+			source = "account_interest"
+		}
+		items = append(items, models.ForecastItem{
+			Source:      source,
+			ReferenceID: rule.ID,
+			Description: rule.Description,
+			Amount:      total,
+		})
+	}
+	return items, nil
+}
+
+// forecastDeposits раскладывает возврат вкладов с процентами по месяцам окончания. Вклад, который
+// закрывается на неликвидный счет (например, пополняет другой вклад), на остаток не влияет
+func (s *analyticsService) forecastDeposits(ctx context.Context, userID uuid.UUID, currency string, accountTypes map[uuid.UUID]models.AccountType, points []models.ForecastPoint, monthIndex func(time.Time) int) ([]models.ForecastItem, error) {
+	deposits, err := s.repos.Deposit.GetByUserID(ctx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	conv := newCurrencyConverter(s.marketProvider, currency)
+	now := time.Now()
+	var items []models.ForecastItem
+	for i := range deposits {
+		d := &deposits[i]
+		idx := monthIndex(d.MaturityDate)
+		if idx < 0 {
+			continue
+		}
+		if d.PayoutAccountID != nil && !isLiquidAccount(accountTypes[*d.PayoutAccountID]) {
+			continue
+		}
+
+		withDepositInterest(d, now)
+		amount, err := conv.convert(ctx, d.AmountAtMaturity, d.Currency)
+		if err != nil {
+			return nil, err
+		}
+		points[idx].DepositMaturities = points[idx].DepositMaturities.Add(amount)
+
+		description := d.Bank
+		if d.Name != "" {
+			description += " - " + d.Name
+		}
+		items = append(items, models.ForecastItem{
+			Source:      "deposit",
+			ReferenceID: d.ID,
+			Description: description,
+			Amount:      amount,
+		})
+	}
+	return items, nil
+}
+
+// пороги детектора аномалий
+const (
+	anomalyHistoryDays     = 180              // сколько дней истории берем для расчета нормы
+	anomalyMinSamples      = 5                // минимум транзакций в группе для z-оценки
+	anomalyZScoreThreshold = 3.0              // с какого z-score трата считается выбросом
+	anomalyRelativeFloor   = 0.1              // минимальное отклонение - доля среднего: у одинаковых трат дисперсия нулевая
+	anomalyNotifyDays      = 2                // за сколько дней проверяются траты для уведомлений
+	anomalyDuplicateWindow = 10 * time.Minute // окно поиска повторных списаний
+)
+
+// GetAnomalies ищет подозрительные расходы за последние days дней
+// сравнивая их с историей пользователя за предыдущие полгода
+func (s *analyticsService) GetAnomalies(ctx context.Context, userID uuid.UUID, days int) ([]models.Anomaly, error) {
+	if days <= 0 {
+		days = 30
+	}
+
+	now := time.Now()
+	periodStart := now.AddDate(0, 0, -days)
+	historyStart := periodStart.AddDate(0, 0, -anomalyHistoryDays)
+	expense := models.TransactionTypeExpense
+
+	history, err := s.repos.Transaction.GetByDateRange(ctx, userID, historyStart, periodStart, &expense)
+	if err != nil {
+		return nil, err
+	}
+	recent, err := s.repos.Transaction.GetByDateRange(ctx, userID, periodStart, now, &expense)
+	if err != nil {
+		return nil, err
+	}
+
+	// статистика по категориям и получателям
+	byCategory := make(map[uuid.UUID]*amountStats)
+	byMerchant := make(map[string]*amountStats)
+	var allAmounts []float64
+	for _, tx := range history {
+		amount := tx.Amount.InexactFloat64()
+		allAmounts = append(allAmounts, amount)
+
+		if byCategory[tx.CategoryID] == nil {
+			byCategory[tx.CategoryID] = &amountStats{}
+		}
+		byCategory[tx.CategoryID].add(amount)
+
+		if merchant := normalizeMerchant(tx.Description); merchant != "" {
+			if byMerchant[merchant] == nil {
+				byMerchant[merchant] = &amountStats{}
+			}
+			byMerchant[merchant].add(amount)
+		}
+	}
+
+	// порог "крупной" траты - 90-й перцентиль истории
+	var largeThreshold float64
+	if len(allAmounts) >= 10 {
+		sort.Float64s(allAmounts)
+		largeThreshold = allAmounts[len(allAmounts)*9/10]
+	}
+
+	var anomalies []models.Anomaly
+	for i, tx := range recent {
+		amount := tx.Amount.InexactFloat64()
+		merchant := normalizeMerchant(tx.Description)
+
+		// выбросы: сначала по получателю (точнее), потом по категории
+		if stats := byMerchant[merchant]; merchant != "" && stats != nil && stats.count >= anomalyMinSamples {
+			if z := stats.zScore(amount); z >= anomalyZScoreThreshold {
+				anomalies = append(anomalies, newOutlierAnomaly(models.AnomalyTypeMerchantOutlier, tx, stats.mean(), z))
+			}
+		} else if stats := byCategory[tx.CategoryID]; stats != nil && stats.count >= anomalyMinSamples {
+			if z := stats.zScore(amount); z >= anomalyZScoreThreshold {
+				anomalies = append(anomalies, newOutlierAnomaly(models.AnomalyTypeCategoryOutlier, tx, stats.mean(), z))
+			}
+		}
+
+		// новый получатель с крупной суммой
+		if merchant != "" && byMerchant[merchant] == nil && largeThreshold > 0 && amount >= largeThreshold {
+			anomalies = append(anomalies, models.Anomaly{
+				Type:          models.AnomalyTypeNewMerchant,
+				Severity:      "low",
+				TransactionID: tx.ID,
+				Date:          tx.Date,
+				Amount:        tx.Amount,
+				Description:   tx.Description,
+				CategoryID:    tx.CategoryID,
+				Message:       fmt.Sprintf("Крупная трата у нового получателя «%s»", tx.Description),
+			})
+		}
+
+		// повторное списание: тот же счет, сумма и получатель в пределах нескольких минут
+		for j := i - 1; j >= 0; j-- {
+			prev := recent[j]
+			// транзакции отсортированы по дате, дальше искать смысла нет
+			if tx.Date.Sub(prev.Date) >= 24*time.Hour {
+				break
+			}
+			if prev.AccountID != tx.AccountID || !prev.Amount.Equal(tx.Amount) || normalizeMerchant(prev.Description) != merchant {
+				continue
+			}
+			// дата часто без времени, поэтому смотрим еще и на момент создания записи
+			if absDuration(tx.Date.Sub(prev.Date)) > anomalyDuplicateWindow && absDuration(tx.CreatedAt.Sub(prev.CreatedAt)) > anomalyDuplicateWindow {
+				continue
+			}
+
+			prevID := prev.ID
+			anomalies = append(anomalies, models.Anomaly{
+				Type:                 models.AnomalyTypeDuplicate,
+				Severity:             "high",
+				TransactionID:        tx.ID,
+				RelatedTransactionID: &prevID,
+				Date:                 tx.Date,
+				Amount:               tx.Amount,
+				Description:          tx.Description,
+				CategoryID:           tx.CategoryID,
+				Message:              "Возможное повторное списание той же суммы",
+			})
+			break
+		}
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].Date.After(anomalies[j].Date)
+	})
+
+	return anomalies, nil
+}
+
+// NotifyAnomalies уведомляет о подозрительных тратах за последние дни пользователей, включивших anomaly_alerts;
+// о каждой трате - один раз (повтор отсекает DedupKey). возвращает, сколько подозрительных трат найдено
+func (s *analyticsService) NotifyAnomalies(ctx context.Context) (int, error) {
+	userIDs, err := s.repos.User.GetWithAnomalyAlerts(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	found := 0
+	for _, userID := range userIDs {
+		anomalies, err := s.GetAnomalies(ctx, userID, anomalyNotifyDays)
+		if err != nil {
+			log.Printf("Не удалось проверить траты пользователя %s: %v", userID, err)
+			continue
+		}
+		for _, a := range anomalies {
+			txID := a.TransactionID
+			s.notifications.Notify(ctx, &models.Notification{
+				UserID:   userID,
+				Type:     models.NotificationAnomaly,
+				Title:    a.Message,
+				Body:     fmt.Sprintf("%s, %s: %s", a.Date.Format("02.01.2006"), a.Description, a.Amount.StringFixed(2)),
+				EntityID: &txID,
+				DedupKey: fmt.Sprintf("anomaly:%s:%s", a.Type, a.TransactionID),
+			})
+			found++
+		}
+	}
+	return found, nil
+}
+
+func newOutlierAnomaly(anomalyType string, tx models.Transaction, mean, z float64) models.Anomaly {
+	severity := "medium"
+	if z >= 2*anomalyZScoreThreshold {
+		severity = "high"
+	}
+
+	expected := decimal.NewFromFloat(mean).Round(2)
+	return models.Anomaly{
+		Type:          anomalyType,
+		Severity:      severity,
+		TransactionID: tx.ID,
+		Date:          tx.Date,
+		Amount:        tx.Amount,
+		Description:   tx.Description,
+		CategoryID:    tx.CategoryID,
+		Expected:      &expected,
+		ZScore:        math.Round(z*100) / 100,
+		Message:       fmt.Sprintf("Сумма %s заметно выше обычной (%s)", tx.Amount.StringFixed(2), expected.StringFixed(2)),
+	}
+}
+
+// amountStats - инкрементальные среднее и дисперсия сумм
+type amountStats struct {
+	count int
+	sum   float64
+	sumSq float64
+}
+
+func (a *amountStats) add(v float64) {
+	a.count++
+	a.sum += v
+	a.sumSq += v * v
+}
+
+func (a *amountStats) mean() float64 {
+	if a.count == 0 {
+		return 0
+	}
+	return a.sum / float64(a.count)
+}
+
+// zScore отклонение от среднего в стандартных отклонениях; отклонение не меньше anomalyRelativeFloor
+// от среднего, иначе при одинаковых тратах любая другая сумма была бы бесконечно далеко (или не замечалась)
+func (a *amountStats) zScore(v float64) float64 {
+	mean := a.mean()
+	stddev := math.Sqrt(max(a.sumSq/float64(a.count)-mean*mean, 0))
+	stddev = max(stddev, math.Abs(mean)*anomalyRelativeFloor)
+	if stddev == 0 {
+		return 0
+	}
+	return (v - mean) / stddev
+}
+
+// normalizeMerchant приводит описание к ключу получателя
+func normalizeMerchant(description string) string {
+	return strings.Join(strings.Fields(strings.ToLower(description)), " ")
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func (s *analyticsService) GetSpendingPatterns(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time, currency string) (*models.SpendingPatterns, error) {
+	user, err := s.repos.User.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	start, end := s.calculatePeriodDates(period, startDate, endDate, localNow(user))
+
+	expense := models.TransactionTypeExpense
+	transactions, err := s.repos.Transaction.GetByDateRange(ctx, userID, start, end, &expense)
+	if err != nil {
+		return nil, err
+	}
+
+	currency = reportCurrency(currency, user.DefaultCurrency, s.config.DefaultCurrency)
+	conv := newCurrencyConverter(s.marketProvider, currency)
+	loc := userLocation(user)
+
+	// за все время календарь начинается с первой траты, а не с 2000 года
+	if period == models.PeriodAll && startDate == nil && len(transactions) > 0 {
+		start = transactions[0].Date
+	}
+	firstDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	lastDay := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+
+	patterns := &models.SpendingPatterns{
+		Period:       period,
+		Currency:     currency,
+		StartDate:    firstDay,
+		EndDate:      lastDay,
+		ByWeekday:    make([]models.WeekdaySpending, 7),
+		ByDayOfMonth: make([]models.DayOfMonthSpending, 31),
+		ByHour:       make([]models.HourSpending, 24),
+		Insights:     []string{},
+	}
+	for i := range patterns.ByWeekday {
+		patterns.ByWeekday[i].Weekday = i + 1
+	}
+	for i := range patterns.ByDayOfMonth {
+		patterns.ByDayOfMonth[i].Day = i + 1
+	}
+	for i := range patterns.ByHour {
+		patterns.ByHour[i].Hour = i
+	}
+
+	// сколько раз каждый день недели встречается в периоде - для среднего за день
+	dayIndex := make(map[string]int)
+	weekdayDays := make([]int, 7)
+	for d := firstDay; !d.After(lastDay); d = d.AddDate(0, 0, 1) {
+		dayIndex[d.Format("2006-01-02")] = len(patterns.Calendar)
+		patterns.Calendar = append(patterns.Calendar, models.DailySpending{Date: d.Format("2006-01-02")})
+		weekdayDays[isoWeekday(d)-1]++
+	}
+	patterns.Days = len(patterns.Calendar)
+
+	for _, tx := range transactions {
+		amount, err := conv.convertAt(ctx, tx.Amount, tx.Currency, tx.Date)
+		if err != nil {
+			return nil, err
+		}
+		patterns.TotalSpent = patterns.TotalSpent.Add(amount)
+		patterns.TransactionCount++
+
+		if i, ok := dayIndex[tx.Date.Format("2006-01-02")]; ok {
+			patterns.Calendar[i].Total = patterns.Calendar[i].Total.Add(amount)
+			patterns.Calendar[i].Count++
+		}
+
+		weekday := &patterns.ByWeekday[isoWeekday(tx.Date)-1]
+		weekday.Total = weekday.Total.Add(amount)
+		weekday.Count++
+
+		day := &patterns.ByDayOfMonth[tx.Date.Day()-1]
+		day.Total = day.Total.Add(amount)
+		day.Count++
+
+		// в date только дата, время берем из момента внесения операции
+		hour := &patterns.ByHour[tx.CreatedAt.In(loc).Hour()]
+		hour.Total = hour.Total.Add(amount)
+		hour.Count++
+	}
+
+	if patterns.Days > 0 {
+		patterns.AverageDailySpend = patterns.TotalSpent.Div(decimal.NewFromInt(int64(patterns.Days))).Round(2)
+	}
+	for i := range patterns.ByWeekday {
+		if weekdayDays[i] > 0 {
+			patterns.ByWeekday[i].Average = patterns.ByWeekday[i].Total.Div(decimal.NewFromInt(int64(weekdayDays[i]))).Round(2)
+		}
+	}
+	for i, day := range patterns.Calendar {
+		if day.Count > 0 && (patterns.MostExpensiveDay == nil || day.Total.GreaterThan(patterns.MostExpensiveDay.Total)) {
+			patterns.MostExpensiveDay = &patterns.Calendar[i]
+		}
+	}
+
+	patterns.Insights = spendingPatternInsights(patterns)
+	return patterns, nil
+}
+
+var weekdayNames = [7]string{"понедельник", "вторник", "среда", "четверг", "пятница", "суббота", "воскресенье"}
+
+// spendingPatternInsights короткие выводы по раскладке трат
+func spendingPatternInsights(p *models.SpendingPatterns) []string {
+	insights := []string{}
+	if p.TransactionCount == 0 {
+		return insights
+	}
+
+	if p.MostExpensiveDay != nil {
+		insights = append(insights, fmt.Sprintf("Самый дорогой день - %s: %s %s (%d операций)",
+			p.MostExpensiveDay.Date, p.MostExpensiveDay.Total.StringFixed(2), p.Currency, p.MostExpensiveDay.Count))
+	}
+
+	top := p.ByWeekday[0]
+	for _, w := range p.ByWeekday[1:] {
+		if w.Average.GreaterThan(top.Average) {
+			top = w
+		}
+	}
+	if top.Average.IsPositive() && p.AverageDailySpend.IsPositive() {
+		ratio := top.Average.Div(p.AverageDailySpend)
+		insights = append(insights, fmt.Sprintf("Больше всего тратите по дням недели «%s»: в среднем %s %s, в %s раза больше обычного дня",
+			weekdayNames[top.Weekday-1], top.Average.StringFixed(2), p.Currency, ratio.StringFixed(1)))
+	}
+
+	// траты в выходные против будних
+	var weekend, weekdays decimal.Decimal
+	for _, w := range p.ByWeekday {
+		if w.Weekday >= 6 {
+			weekend = weekend.Add(w.Average)
+		} else {
+			weekdays = weekdays.Add(w.Average)
+		}
+	}
+	weekend = weekend.Div(decimal.NewFromInt(2))
+	weekdays = weekdays.Div(decimal.NewFromInt(5))
+	if weekdays.IsPositive() && weekend.GreaterThan(weekdays.Mul(decimal.NewFromFloat(1.5))) {
+		insights = append(insights, fmt.Sprintf("В выходные тратите в %s раза больше, чем в будни", weekend.Div(weekdays).StringFixed(1)))
+	}
+
+	// первая неделя месяца (обычно после зарплаты)
+	var firstWeek decimal.Decimal
+	for _, d := range p.ByDayOfMonth[:7] {
+		firstWeek = firstWeek.Add(d.Total)
+	}
+	if share := firstWeek.Div(p.TotalSpent).Mul(decimal.NewFromInt(100)); p.Days >= 28 && share.GreaterThanOrEqual(decimal.NewFromInt(40)) {
+		insights = append(insights, fmt.Sprintf("%s%% трат приходится на первые 7 дней месяца", share.StringFixed(0)))
+	}
+
+	return insights
+}
+
+// periodStart начало периода по его строке из GetSumByPeriodCurrency (формат зависит от groupBy);
+// нулевое время - строку не разобрали
+func periodStart(period, groupBy string) time.Time {
+	layout := "2006-01"
+	switch groupBy {
+	case "day":
+		layout = "2006-01-02"
+	case "year":
+		layout = "2006"
+	case "week":
+		var year, week int
+		if _, err := fmt.Sscanf(period, "%d-%d", &year, &week); err != nil {
+			return time.Time{}
+		}
+		// 4 января всегда попадает в первую ISO-неделю
+		jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)
+		return jan4.AddDate(0, 0, (week-1)*7-(isoWeekday(jan4)-1))
+	}
+	t, _ := time.Parse(layout, period)
+	return t
+}
+
+// isoWeekday день недели от 1 (понедельник) до 7 (воскресенье)
+func isoWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+func (s *analyticsService) GetSpendingMap(ctx context.Context, userID uuid.UUID, period models.Period, startDate, endDate *time.Time, currency string, groupBy models.SpendingMapGroup, precision int) (*models.SpendingMap, error) {
+	groupBy, precision, err := spendingMapGroup(groupBy, precision)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repos.User.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	start, end := s.calculatePeriodDates(period, startDate, endDate, localNow(user))
+	currency = reportCurrency(currency, user.DefaultCurrency, s.config.DefaultCurrency)
+	conv := newCurrencyConverter(s.marketProvider, currency)
+
+	cells, err := s.repos.Transaction.GetSpendingByLocation(ctx, userID, start, end, groupBy, precision)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.SpendingMap{
+		Period:    period,
+		Currency:  currency,
+		StartDate: start,
+		EndDate:   end,
+		GroupBy:   groupBy,
+		Precision: precision,
+		Points:    []models.SpendingMapPoint{},
+	}
+
+	// одна ячейка приходит строкой на каждую валюту: суммы сводим, координаты усредняем с весом по числу операций
+	type located struct {
+		lat, lon float64
+		weight   int
+	}
+	index := make(map[string]int)
+	coords := make(map[string]*located)
+	for _, cell := range cells {
+		amount, err := conv.convert(ctx, cell.Total, cell.Currency)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := index[cell.Key]
+		if !ok {
+			i = len(result.Points)
+			index[cell.Key] = i
+			result.Points = append(result.Points, models.SpendingMapPoint{Key: cell.Key, City: cell.City})
+		}
+		point := &result.Points[i]
+		point.Total = point.Total.Add(amount)
+		point.Count += cell.Count
+		if point.City == "" {
+			point.City = cell.City
+		}
+		if cell.Latitude != nil && cell.Longitude != nil {
+			c := coords[cell.Key]
+			if c == nil {
+				c = &located{}
+				coords[cell.Key] = c
+			}
+			c.lat += *cell.Latitude * float64(cell.Count)
+			c.lon += *cell.Longitude * float64(cell.Count)
+			c.weight += cell.Count
+		}
+
+		result.Total = result.Total.Add(amount)
+		result.Count += cell.Count
+	}
+
+	for i := range result.Points {
+		point := &result.Points[i]
+		point.Total = point.Total.Round(2)
+		if c := coords[point.Key]; c != nil && c.weight > 0 {
+			lat, lon := c.lat/float64(c.weight), c.lon/float64(c.weight)
+			point.Latitude, point.Longitude = &lat, &lon
+		}
+	}
+	sort.SliceStable(result.Points, func(i, j int) bool {
+		return result.Points[i].Total.GreaterThan(result.Points[j].Total)
+	})
+	result.Total = result.Total.Round(2)
+	return result, nil
+}
+
+func (s *analyticsService) SuggestCategory(ctx context.Context, userID uuid.UUID, description string, categoryType models.CategoryType) (*models.Category, error) {
+	if err := s.checkAI(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	categories, err := s.repos.Category.GetByType(ctx, userID, categoryType)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(categories))
+	for _, c := range categories {
+		names = append(names, c.Name)
+	}
+
+	name, err := s.ai.Categorize(ctx, description, names)
+	if err != nil {
+		return nil, ErrAIUnavailable
+	}
+
+	for i := range categories {
+		if categories[i].Name == name {
+			return &categories[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *analyticsService) GetAISummary(ctx context.Context, userID uuid.UUID) (string, error) {
+	if err := s.checkAI(ctx, userID); err != nil {
+		return "", err
+	}
+
+	summary, err := s.GetFinancialSummary(ctx, userID, models.PeriodMonth, nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	budgets, _ := s.repos.Budget.GetByUserID(ctx, userID, true)
+
+	text, err := s.ai.Summarize(ctx, ai.FormatSummary(s.buildAISummary(summary, budgets, summary.Currency)))
+	if err != nil {
+		return "", ErrAIUnavailable
+	}
+	return text, nil
+}
+
+// checkAI AI настроен, доступен, пользователь от него не отказался; вызов списывается с дневной квоты
+func (s *analyticsService) checkAI(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repos.User.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.AIEnabled {
+		return ErrAIDisabled
+	}
+	if s.ai == nil || !s.ai.IsAvailable(ctx) {
+		return ErrAIUnavailable
+	}
+	return s.quota.UseAICall(ctx, userID)
+}
+
+func (s *analyticsService) buildAISummary(summary *models.FinancialSummary, budgets []models.Budget, currency string) ai.FinancialSummary {
+	aiSummary := ai.FinancialSummary{Currency: currency}
+
+	if summary != nil {
+		aiSummary.TotalIncome = summary.TotalIncome
+		aiSummary.TotalExpenses = summary.TotalExpenses
+		aiSummary.Balance = summary.TotalBalance
+		aiSummary.SavingsRate = summary.SavingsRate
+
+		for _, cat := range summary.ExpenseByCategory {
+			aiSummary.TopCategories = append(aiSummary.TopCategories, ai.CategorySpending{
+				Name:   cat.CategoryName,
+				Amount: cat.Amount,
+			})
+		}
+	}
+
+	for _, b := range budgets {
+		aiSummary.BudgetStatus = append(aiSummary.BudgetStatus, ai.BudgetStatus{
+			Category: b.Name,
+			Limit:    b.Amount,
+			Spent:    b.Spent,
+			Percent:  decimal.NewFromFloat(b.SpentPercent),
+		})
+	}
+
+	return aiSummary
+}
+
+func (s *analyticsService) getBasicRecommendations(summary *models.FinancialSummary, budgets []models.Budget) ([]models.Recommendation, error) {
+	var recs []models.Recommendation
+
+	// проверка нормы сбережений
+	if summary != nil && summary.TotalIncome.GreaterThan(decimal.Zero) {
+		if summary.SavingsRate.LessThan(decimal.NewFromInt(10)) {
+			recs = append(recs, models.Recommendation{
+				ID:          uuid.New(),
+				Type:        "savings",
+				Priority:    5,
+				Title:       "Увеличьте норму сбережений",
+				Description: "Рекомендуется откладывать минимум 10% дохода.",
+				Impact:      "high",
+			})
+		}
+	}
+
+	// обязательные расходы съедают больше половины дохода - урезать почти нечего
+	if summary != nil && summary.FixedCostRatio.GreaterThan(decimal.NewFromInt(50)) {
+		recs = append(recs, models.Recommendation{
+			ID:           uuid.New(),
+			Type:         "fixed_costs",
+			Priority:     4,
+			Title:        "Обязательные расходы больше половины дохода",
+			Description:  "Аренда, коммунальные платежи и подписки занимают большую часть дохода. Проверьте, что из них можно пересмотреть.",
+			CurrentValue: summary.FixedCostRatio.Round(1),
+			TargetValue:  decimal.NewFromInt(50),
+			Impact:       "high",
+		})
+	}
+
+	// проверка бюджетов
+	for _, b := range budgets {
+		if b.SpentPercent >= 90 {
+			recs = append(recs, models.Recommendation{
+				ID:          uuid.New(),
+				Type:        "budget",
+				Priority:    4,
+				Title:       "Бюджет «" + b.Name + "» близок к лимиту",
+				Description: "Израсходовано более 90%.",
+				Impact:      "medium",
+			})
+		}
+	}
+
+	return recs, nil
+}
+
+// sumByCategory суммирует транзакции по категориям в валюте конвертера
+func (s *analyticsService) sumByCategory(ctx context.Context, conv *currencyConverter, userID uuid.UUID, start, end time.Time, txType models.TransactionType) (map[uuid.UUID]decimal.Decimal, error) {
+	byCurrency, err := s.repos.Transaction.GetSumByCategoryCurrency(ctx, userID, start, end, txType)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[uuid.UUID]decimal.Decimal)
+	for cur, sums := range byCurrency {
+		for categoryID, amount := range sums {
+			converted, err := conv.convert(ctx, amount, cur)
+			if err != nil {
+				return nil, err
+			}
+			result[categoryID] = result[categoryID].Add(converted)
+		}
+	}
+	return result, nil
+}
+
+// investmentIncome дивиденды и купоны по всем портфелям за период в валюте отчета
+func (s *analyticsService) investmentIncome(ctx context.Context, conv *currencyConverter, userID uuid.UUID, start, end time.Time) (decimal.Decimal, error) {
+	byCurrency, err := s.repos.Investment.GetIncomeByCurrency(ctx, userID, start, end)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	var total decimal.Decimal
+	for cur, amount := range byCurrency {
+		converted, err := conv.convert(ctx, amount, cur)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Add(converted)
+	}
+	return total, nil
+}
+
+// calculatePeriodDates границы периода; now - текущее время в часовом поясе пользователя,
+// чтобы начало дня, недели и месяца совпадало с его календарем, а не с часовым поясом сервера
+func (s *analyticsService) calculatePeriodDates(period models.Period, startDate, endDate *time.Time, now time.Time) (time.Time, time.Time) {
+	if startDate != nil && endDate != nil {
+		return *startDate, *endDate
+	}
+
+	switch period {
+	case models.PeriodDay:
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		return start, now
+	case models.PeriodWeek:
+		weekday := int(now.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		start := now.AddDate(0, 0, -weekday+1)
+		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+		return start, now
+	case models.PeriodMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), now
+	case models.PeriodQuarter:
+		quarter := (int(now.Month()) - 1) / 3
+		return time.Date(now.Year(), time.Month(quarter*3+1), 1, 0, 0, 0, 0, now.Location()), now
+	case models.PeriodYear:
+		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location()), now
+	case models.PeriodAll:
+		return time.Date(2000, 1, 1, 0, 0, 0, 0, now.Location()), now
+	default:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), now
+	}
+}
+
+func (s *analyticsService) calculatePreviousPeriod(currentStart, currentEnd time.Time) (time.Time, time.Time) {
+	duration := currentEnd.Sub(currentStart)
+	prevEnd := currentStart.Add(-time.Second)
+	prevStart := prevEnd.Add(-duration)
+	return prevStart, prevEnd
+}
+
+// ликвидные счета - те с которых реально тратятся деньги
+func isLiquidAccount(accountType models.AccountType) bool {
+	return accountType == models.AccountTypeCash || accountType == models.AccountTypeBank
+}
+
+// parseRecurrenceRule разбирает правило повтора в шаг (годы, месяцы, дни)
+// поддерживаются daily/weekly/biweekly/monthly/quarterly/yearly и RRULE вида FREQ=MONTHLY;INTERVAL=2
+func parseRecurrenceRule(rule string) (years, months, days int, ok bool) {
+	rule = strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(rule, "RRULE:")))
+	if rule == "" {
+		return 0, 0, 0, false
+	}
+
+	freq := rule
+	interval := 1
+	if strings.Contains(rule, "=") {
+		freq = ""
+		for _, part := range strings.Split(rule, ";") {
+			kv := strings.SplitN(part, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case "FREQ":
+				freq = kv[1]
+			case "INTERVAL":
+				if n, err := strconv.Atoi(kv[1]); err == nil && n > 0 {
+					interval = n
+				}
+			}
+		}
+	}
+
+	switch freq {
+	case "DAILY":
+		return 0, 0, interval, true
+	case "WEEKLY":
+		return 0, 0, 7 * interval, true
+	case "BIWEEKLY":
+		return 0, 0, 14 * interval, true
+	case "MONTHLY":
+		return 0, interval, 0, true
+	case "QUARTERLY":
+		return 0, 3 * interval, 0, true
+	case "YEARLY", "ANNUALLY":
+		return interval, 0, 0, true
+	}
+	return 0, 0, 0, false
 }
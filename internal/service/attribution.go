@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// attributionPosition одна бумага портфеля за период атрибуции; суммы в валюте портфеля
+type attributionPosition struct {
+	security *models.Security
+	currency string
+	closes   []models.PriceHistory // по возрастанию даты
+	last     decimal.Decimal       // цена последней сделки, если в истории нет свечи
+	quantity decimal.Decimal
+
+	startValue decimal.Decimal
+	endValue   decimal.Decimal
+	netFlow    decimal.Decimal
+	income     decimal.Decimal
+	capital    decimal.Decimal // средний вложенный капитал по Modified Dietz
+	active     bool            // позиция была в портфеле или двигалась в периоде
+}
+
+// priceOn цена закрытия на date или раньше, без свечей - цена последней сделки
+func (p *attributionPosition) priceOn(date time.Time) decimal.Decimal {
+	i := sort.Search(len(p.closes), func(i int) bool { return p.closes[i].Date.After(date) })
+	if i > 0 {
+		return p.closes[i-1].Close
+	}
+	return p.last
+}
+
+// GetPerformanceAttribution раскладывает доходность портфеля за [from, to] по бумагам и секторам.
+// позиции на начало оцениваются по закрытию дня перед from, на конец - по закрытию to;
+// курс валюты бумаги - на те же дни, курс сделок - из самих сделок
+func (s *investmentService) GetPerformanceAttribution(ctx context.Context, userID, portfolioID uuid.UUID, from, to time.Time) (*models.PerformanceAttribution, error) {
+	from, to = truncateDay(from), truncateDay(to)
+	if today := truncateDay(time.Now()); to.After(today) {
+		to = today
+	}
+	if to.Before(from) {
+		return nil, ErrInvalidDateRange
+	}
+
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+
+	result := &models.PerformanceAttribution{
+		PortfolioID: portfolioID,
+		Currency:    portfolio.Currency,
+		From:        from,
+		To:          to,
+		Securities:  []models.AttributionItem{},
+		Sectors:     []models.AttributionItem{},
+	}
+
+	transactions, err := s.investmentRepo.GetByDateRange(ctx, portfolioID, time.Time{}, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 {
+		return result, nil
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if !transactions[i].Date.Equal(transactions[j].Date) {
+			return transactions[i].Date.Before(transactions[j].Date)
+		}
+		return transactions[i].CreatedAt.Before(transactions[j].CreatedAt)
+	})
+
+	// свеча на начало может быть за несколько дней до from (выходные, праздники)
+	historyFrom := from.AddDate(0, 0, -14)
+	positions := make(map[uuid.UUID]*attributionPosition)
+	var order []uuid.UUID
+	for _, tx := range transactions {
+		if _, ok := positions[tx.SecurityID]; ok {
+			continue
+		}
+		security, err := s.securityRepo.GetByID(ctx, tx.SecurityID)
+		if err != nil {
+			return nil, err
+		}
+		pos := &attributionPosition{security: security, currency: securityCurrency(security, portfolio.Currency)}
+		if history, err := s.priceHistory.GetHistory(ctx, security.ID, historyFrom, to); err == nil {
+			for _, bar := range history {
+				if bar.Close.IsPositive() {
+					pos.closes = append(pos.closes, bar)
+				}
+			}
+		}
+		positions[tx.SecurityID] = pos
+		order = append(order, tx.SecurityID)
+	}
+
+	conv := newCurrencyConverter(s.marketProvider, portfolio.Currency)
+	value := func(pos *attributionPosition, date time.Time) (decimal.Decimal, error) {
+		if !pos.quantity.IsPositive() {
+			return decimal.Zero, nil
+		}
+		rate, err := conv.rateOn(ctx, pos.currency, date)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		return pos.quantity.Mul(pos.priceOn(date)).Mul(pos.security.ContractSize()).Mul(rate), nil
+	}
+
+	// деньги, внесенные в день t, работали (to - t) из (to - from) периода
+	days := decimal.NewFromFloat(to.Sub(from).Hours()/24 + 1)
+	weight := func(date time.Time) decimal.Decimal {
+		return decimal.NewFromFloat(to.Sub(truncateDay(date)).Hours()/24 + 1).Div(days)
+	}
+
+	startDay := from.AddDate(0, 0, -1)
+	started := false
+	for i := 0; i <= len(transactions); i++ {
+		// позиции на начало периода - после всех сделок до from
+		if !started && (i == len(transactions) || !truncateDay(transactions[i].Date).Before(from)) {
+			started = true
+			for _, pos := range positions {
+				v, err := value(pos, startDay)
+				if err != nil {
+					return nil, err
+				}
+				pos.startValue = v
+				pos.capital = v
+				pos.active = pos.quantity.IsPositive()
+			}
+		}
+		if i == len(transactions) {
+			break
+		}
+
+		tx := &transactions[i]
+		pos := positions[tx.SecurityID]
+		if tx.Price.IsPositive() {
+			pos.last = tx.Price
+		}
+		rate := tx.ExchangeRate
+		if !rate.IsPositive() {
+			rate = decimal.NewFromInt(1)
+		}
+		gross := tx.Quantity.Mul(tx.Price).Mul(pos.security.ContractSize()).Mul(rate)
+		commission := tx.Commission.Mul(rate)
+
+		var flow, income decimal.Decimal
+		switch tx.Type {
+		case models.InvestmentTransactionTypeBuy, models.InvestmentTransactionTypeTransferIn:
+			pos.quantity = pos.quantity.Add(tx.Quantity)
+			flow = gross.Add(commission)
+		case models.InvestmentTransactionTypeSell, models.InvestmentTransactionTypeTransferOut,
+			models.InvestmentTransactionTypeExpiration, models.InvestmentTransactionTypeRedemption:
+			pos.quantity = pos.quantity.Sub(tx.Quantity)
+			flow = gross.Sub(commission).Neg()
+		case models.InvestmentTransactionTypeDividend, models.InvestmentTransactionTypeCoupon, models.InvestmentTransactionTypeAmortization:
+			income = gross.Sub(commission)
+		case models.InvestmentTransactionTypeTax, models.InvestmentTransactionTypeFee:
+			income = tx.Amount.Mul(rate).Neg()
+		case models.InvestmentTransactionTypeSplit:
+			if tx.Quantity.IsPositive() {
+				pos.quantity = pos.quantity.Mul(tx.Quantity)
+				pos.last = pos.last.Div(tx.Quantity)
+			}
+		case models.InvestmentTransactionTypeStakingReward, models.InvestmentTransactionTypeAirdrop:
+			pos.quantity = pos.quantity.Add(tx.Quantity)
+		}
+
+		if !started {
+			continue
+		}
+		pos.active = true
+		pos.netFlow = pos.netFlow.Add(flow)
+		pos.income = pos.income.Add(income)
+		pos.capital = pos.capital.Add(flow.Mul(weight(tx.Date)))
+	}
+
+	var capital decimal.Decimal
+	for _, id := range order {
+		pos := positions[id]
+		if !pos.active {
+			continue
+		}
+		v, err := value(pos, to)
+		if err != nil {
+			return nil, err
+		}
+		pos.endValue = v
+		capital = capital.Add(pos.capital)
+	}
+
+	sectors := make(map[string]*models.AttributionItem)
+	sectorCapital := make(map[string]decimal.Decimal)
+	var sectorOrder []string
+	for _, id := range order {
+		pos := positions[id]
+		if !pos.active {
+			continue
+		}
+		securityID := id
+		item := models.AttributionItem{
+			SecurityID: &securityID,
+			Ticker:     pos.security.Ticker,
+			Name:       pos.security.Name,
+			Sector:     pos.security.Sector,
+			StartValue: pos.startValue,
+			EndValue:   pos.endValue,
+			NetFlow:    pos.netFlow,
+			Income:     pos.income,
+			Profit:     pos.endValue.Sub(pos.startValue).Sub(pos.netFlow).Add(pos.income),
+		}
+		fillAttribution(&item, pos.capital, capital)
+		result.Securities = append(result.Securities, item)
+
+		sector, ok := sectors[item.Sector]
+		if !ok {
+			sector = &models.AttributionItem{Sector: item.Sector}
+			sectors[item.Sector] = sector
+			sectorOrder = append(sectorOrder, item.Sector)
+		}
+		sector.StartValue = sector.StartValue.Add(item.StartValue)
+		sector.EndValue = sector.EndValue.Add(item.EndValue)
+		sector.NetFlow = sector.NetFlow.Add(item.NetFlow)
+		sector.Income = sector.Income.Add(item.Income)
+		sector.Profit = sector.Profit.Add(item.Profit)
+		sectorCapital[item.Sector] = sectorCapital[item.Sector].Add(pos.capital)
+
+		result.StartValue = result.StartValue.Add(item.StartValue)
+		result.EndValue = result.EndValue.Add(item.EndValue)
+		result.NetFlows = result.NetFlows.Add(item.NetFlow)
+		result.Income = result.Income.Add(item.Income)
+		result.Profit = result.Profit.Add(item.Profit)
+	}
+	for _, name := range sectorOrder {
+		sector := sectors[name]
+		fillAttribution(sector, sectorCapital[name], capital)
+		result.Sectors = append(result.Sectors, *sector)
+	}
+
+	if capital.IsPositive() {
+		result.ReturnPct = result.Profit.Div(capital).Mul(decimal.NewFromInt(100)).Round(2)
+	}
+	result.StartValue = result.StartValue.Round(2)
+	result.EndValue = result.EndValue.Round(2)
+	result.NetFlows = result.NetFlows.Round(2)
+	result.Income = result.Income.Round(2)
+	result.Profit = result.Profit.Round(2)
+
+	byContribution := func(items []models.AttributionItem) {
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].ContributionPct.GreaterThan(items[j].ContributionPct)
+		})
+	}
+	byContribution(result.Securities)
+	byContribution(result.Sectors)
+	return result, nil
+}
+
+// fillAttribution вес, доходность и вклад позиции с капиталом capital в портфеле с капиталом total; суммы округляет
+func fillAttribution(item *models.AttributionItem, capital, total decimal.Decimal) {
+	hundred := decimal.NewFromInt(100)
+	if total.IsPositive() {
+		item.WeightPct = capital.Div(total).Mul(hundred).Round(2)
+		item.ContributionPct = item.Profit.Div(total).Mul(hundred).Round(2)
+	}
+	if capital.IsPositive() {
+		item.ReturnPct = item.Profit.Div(capital).Mul(hundred).Round(2)
+	}
+	item.StartValue = item.StartValue.Round(2)
+	item.EndValue = item.EndValue.Round(2)
+	item.NetFlow = item.NetFlow.Round(2)
+	item.Income = item.Income.Round(2)
+	item.Profit = item.Profit.Round(2)
+}
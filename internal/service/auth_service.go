@@ -5,14 +5,17 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"log"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/format"
 	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/password"
 	"github.com/alligatorO15/fin-tracker/internal/repository"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jackc/pgx/v5"
 )
 
 // кастомные ошибки
@@ -22,14 +25,32 @@ var (
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrTokenExpired       = errors.New("token expired")
 	ErrTokenRevoked       = errors.New("token revoked")
+	ErrTokenReused        = errors.New("refresh token reuse detected, session revoked")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrWeakPassword       = errors.New("password must be at least 8 characters")
+	ErrDemoDisabled       = errors.New("demo mode is disabled")
 )
 
+// как binding:"min=8" у UserRegistration
+const minPasswordLength = 8
+
 type AuthService interface {
-	Register(ctx context.Context, input *models.UserRegistration) (*models.AuthResponse, error)
-	Login(ctx context.Context, input *models.UserLogin) (*models.AuthResponse, error)
-	RefreshTokens(ctx context.Context, refreshToken string) (*models.AuthResponse, error)
+	Register(ctx context.Context, input *models.UserRegistration, client models.ClientInfo) (*models.AuthResponse, error)
+	// CreateUser заводит пользователя без входа (для администратора, ftctl)
+	CreateUser(ctx context.Context, input *models.UserRegistration) (*models.User, error)
+	// ResetPassword задает новый пароль без старого и завершает все сессии пользователя
+	ResetPassword(ctx context.Context, email, password string) error
+	Login(ctx context.Context, input *models.UserLogin, client models.ClientInfo) (*models.AuthResponse, error)
+	// UnlockLogin снимает блокировку входа кодом из письма
+	UnlockLogin(ctx context.Context, token string, client models.ClientInfo) error
+	// DemoLogin вход в песочницу демо-режима без пароля; короткая сессия без "запомнить меня"
+	DemoLogin(ctx context.Context, client models.ClientInfo) (*models.AuthResponse, error)
+	RefreshTokens(ctx context.Context, refreshToken string, client models.ClientInfo) (*models.AuthResponse, error)
 	Logout(ctx context.Context, refreshToken string) error
 	LogoutAll(ctx context.Context, userID uuid.UUID) error
+	GetSessions(ctx context.Context, userID uuid.UUID) (*models.SessionsResponse, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
 	ValidateToken(tokenString string) (*Claims, error)
 }
 
@@ -40,28 +61,48 @@ type Claims struct {
 }
 
 type authService struct {
+	txManager        repository.TxManager
 	userRepo         repository.UserRepository
 	refreshTokenRepo repository.RefreshTokenRepository
+	hasher           *password.Hasher
+	throttle         LoginThrottleService
 	config           *config.Config
 }
 
-func NewAuthService(userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository, cfg *config.Config) AuthService {
+func NewAuthService(txManager repository.TxManager, userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository, hasher *password.Hasher, throttle LoginThrottleService, cfg *config.Config) AuthService {
 	return &authService{
+		txManager:        txManager,
 		userRepo:         userRepo,
 		refreshTokenRepo: refreshTokenRepo,
+		hasher:           hasher,
+		throttle:         throttle,
 		config:           cfg,
 	}
 }
 
-func (s *authService) Register(ctx context.Context, input *models.UserRegistration) (*models.AuthResponse, error) {
+func (s *authService) Register(ctx context.Context, input *models.UserRegistration, client models.ClientInfo) (*models.AuthResponse, error) {
+	user, err := s.CreateUser(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// генерируем access и refresh токена на сессию
+	return s.generateAuthResponse(ctx, user, &repository.RefreshToken{
+		RememberMe: true,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+	})
+}
+
+func (s *authService) CreateUser(ctx context.Context, input *models.UserRegistration) (*models.User, error) {
 	// смотрим существует ли юзер
 	existing, _ := s.userRepo.GetByEmail(ctx, input.Email)
 	if existing != nil {
 		return nil, ErrUserExists
 	}
 
-	// хэшируем пароль,  bcrypt.DefaultCost = 10 компромисс между безопасностью и скоростью
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	// хэшируем пароль алгоритмом из конфига, версию храним рядом с хэшем
+	hashedPassword, hashVersion, err := s.hasher.Hash(input.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -73,64 +114,186 @@ func (s *authService) Register(ctx context.Context, input *models.UserRegistrati
 	}
 
 	user := &models.User{
-		ID:              uuid.New(),
-		Email:           input.Email,
-		PasswordHash:    string(hashedPassword),
-		FirstName:       input.FirstName,
-		LastName:        input.LastName,
-		DefaultCurrency: defaultCurrency,
-		Timezone:        "Europe/Moscow",
+		ID:                  uuid.New(),
+		Email:               input.Email,
+		PasswordHash:        hashedPassword,
+		PasswordHashVersion: int16(hashVersion),
+		FirstName:           input.FirstName,
+		LastName:            input.LastName,
+		DefaultCurrency:     defaultCurrency,
+		Timezone:            "Europe/Moscow",
+		Locale:              format.DefaultLocale,
+		AIEnabled:           true,
 	}
 
-	if err := s.userRepo.Create(ctx, user); err != nil {
+	err = s.userRepo.Create(ctx, user)
+	if errors.Is(err, repository.ErrDuplicateEmail) {
+		return nil, ErrUserExists
+	}
+	if err != nil {
 		return nil, err
 	}
+	return user, nil
+}
 
-	// генерируем access и refresh токена на сессию
-	return s.generateAuthResponse(ctx, user)
+func (s *authService) ResetPassword(ctx context.Context, email, password string) error {
+	if len(password) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	hashedPassword, hashVersion, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword, int16(hashVersion)); err != nil {
+		return err
+	}
+	// новый пароль снимает и блокировку входа
+	if err := s.throttle.Reset(ctx, email); err != nil {
+		return err
+	}
+	// старый пароль мог утечь - выкидываем все его сессии
+	return s.refreshTokenRepo.RevokeAllForUser(ctx, user.ID)
 }
 
-func (s *authService) Login(ctx context.Context, input *models.UserLogin) (*models.AuthResponse, error) {
+func (s *authService) Login(ctx context.Context, input *models.UserLogin, client models.ClientInfo) (*models.AuthResponse, error) {
+	// пока email или адрес заблокирован, пароль даже не проверяем
+	if err := s.throttle.Check(ctx, input.Email, client); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetByEmail(ctx, input.Email)
 	if err != nil {
+		s.throttle.Failure(ctx, input.Email, nil, client)
 		return nil, ErrInvalidCredentials
 	}
 
 	// Сравниваем пароль с его хэшем
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
+	ok, rehash := s.hasher.Verify(user.PasswordHash, password.Version(user.PasswordHashVersion), input.Password)
+	if !ok {
+		s.throttle.Failure(ctx, input.Email, user, client)
 		return nil, ErrInvalidCredentials
 	}
+	s.throttle.Success(ctx, input.Email)
+	// хэш старым алгоритмом или с прежними параметрами - пересчитываем, пока пароль на руках.
+	// ошибка не мешает входу: попробуем при следующем
+	if rehash {
+		if err := s.rehashPassword(ctx, user.ID, input.Password); err != nil {
+			log.Printf("Не удалось обновить хэш пароля пользователя %s: %v", user.ID, err)
+		}
+	}
+
+	// без "запомнить меня" сессия живет недолго
+	rememberMe := input.RememberMe == nil || *input.RememberMe
 
-	return s.generateAuthResponse(ctx, user)
+	return s.generateAuthResponse(ctx, user, &repository.RefreshToken{
+		RememberMe: rememberMe,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+	})
 }
 
-func (s *authService) RefreshTokens(ctx context.Context, refreshToken string) (*models.AuthResponse, error) {
-	// ищем рефреш токен в бд
-	storedToken, err := s.refreshTokenRepo.GetByToken(ctx, refreshToken)
+func (s *authService) UnlockLogin(ctx context.Context, token string, client models.ClientInfo) error {
+	return s.throttle.Unlock(ctx, token, client)
+}
+
+func (s *authService) rehashPassword(ctx context.Context, userID uuid.UUID, plain string) error {
+	hashedPassword, hashVersion, err := s.hasher.Hash(plain)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if storedToken == nil {
-		return nil, ErrInvalidToken
+	return s.userRepo.UpdatePassword(ctx, userID, hashedPassword, int16(hashVersion))
+}
+
+func (s *authService) DemoLogin(ctx context.Context, client models.ClientInfo) (*models.AuthResponse, error) {
+	if !s.config.DemoMode {
+		return nil, ErrDemoDisabled
+	}
+	// без пароля пускаем только в аккаунт, который сервер сам завел как демо
+	user, err := s.userRepo.GetByEmail(ctx, s.config.DemoEmail)
+	if err != nil || !user.IsDemo {
+		return nil, ErrDemoDisabled
 	}
 
-	// берем юзера
-	user, err := s.userRepo.GetByID(ctx, storedToken.UserID)
+	// аккаунт общий, поэтому адрес и браузер посетителя не сохраняем: их увидели бы все остальные
+	return s.generateAuthResponse(ctx, user, &repository.RefreshToken{
+		RememberMe: false,
+	})
+}
+
+func (s *authService) RefreshTokens(ctx context.Context, refreshToken string, client models.ClientInfo) (*models.AuthResponse, error) {
+	// отзыв старого и выдача нового токена в одной транзакции
+	var response *models.AuthResponse
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		storedToken, err := s.refreshTokenRepo.Rotate(ctx, refreshToken)
+		if err != nil {
+			return err
+		}
+		if storedToken.ExpiresAt.Before(time.Now()) {
+			return ErrTokenExpired
+		}
+
+		user, err := s.userRepo.GetByID(ctx, storedToken.UserID)
+		if err != nil {
+			return err
+		}
+		if user.IsDemo {
+			client = models.ClientInfo{}
+		}
+
+		// создаем новую пару в том же семействе
+		response, err = s.generateAuthResponse(ctx, user, &repository.RefreshToken{
+			FamilyID:   storedToken.FamilyID,
+			RememberMe: storedToken.RememberMe,
+			UserAgent:  client.UserAgent,
+			IPAddress:  client.IPAddress,
+		})
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, s.checkTokenReuse(ctx, refreshToken, client)
+	}
 	if err != nil {
 		return nil, err
 	}
+	return response, nil
+}
 
-	// отзываем и удаляем старый рефреш токен
-	if err := s.refreshTokenRepo.Revoke(ctx, refreshToken); err != nil {
-		return nil, err
+// checkTokenReuse разбирает недействующий refresh токен. ротированный токен предъявляют повторно -
+// значит им пользуется кто-то еще (кража): отзываем всю цепочку, чтобы выкинуть и злоумышленника,
+// и владельца, и пишем событие. токен после выхода или отзыва сессии - просто недействителен
+func (s *authService) checkTokenReuse(ctx context.Context, refreshToken string, client models.ClientInfo) error {
+	storedToken, err := s.refreshTokenRepo.GetByToken(ctx, refreshToken)
+	if err != nil || storedToken.RevokedReason != repository.RevokeReasonRotated {
+		return ErrInvalidToken
 	}
 
-	// создаем новую пару
-	return s.generateAuthResponse(ctx, user)
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, storedToken.FamilyID); err != nil {
+		return err
+	}
+	familyID := storedToken.FamilyID
+	_ = s.refreshTokenRepo.CreateSecurityEvent(ctx, &models.SecurityEvent{
+		UserID:    storedToken.UserID,
+		Type:      models.SecurityEventTokenReuse,
+		FamilyID:  &familyID,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		Details:   "повторное использование refresh токена, сессия завершена",
+	})
+	return ErrTokenReused
 }
 
+// Logout завершает сессию целиком (все токены семейства)
 func (s *authService) Logout(ctx context.Context, refreshToken string) error {
-	return s.refreshTokenRepo.Revoke(ctx, refreshToken)
+	storedToken, err := s.refreshTokenRepo.GetByToken(ctx, refreshToken)
+	if err != nil {
+		return s.refreshTokenRepo.Revoke(ctx, refreshToken)
+	}
+	return s.refreshTokenRepo.RevokeFamily(ctx, storedToken.FamilyID)
 }
 
 // отзываем все рефреш токены пользователя (выход из всех устройств)
@@ -138,6 +301,49 @@ func (s *authService) LogoutAll(ctx context.Context, userID uuid.UUID) error {
 	return s.refreshTokenRepo.RevokeAllForUser(ctx, userID)
 }
 
+// GetSessions возвращает активные сессии и последние события безопасности
+func (s *authService) GetSessions(ctx context.Context, userID uuid.UUID) (*models.SessionsResponse, error) {
+	sessions, err := s.refreshTokenRepo.GetActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.refreshTokenRepo.GetSecurityEvents(ctx, userID, 20)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SessionsResponse{
+		Sessions: sessions,
+		Events:   events,
+	}, nil
+}
+
+func (s *authService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	sessions, err := s.refreshTokenRepo.GetActiveSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == sessionID {
+			if err := s.refreshTokenRepo.RevokeFamily(ctx, sessionID); err != nil {
+				return err
+			}
+			familyID := sessionID
+			return s.refreshTokenRepo.CreateSecurityEvent(ctx, &models.SecurityEvent{
+				UserID:    userID,
+				Type:      models.SecurityEventSessionRevoked,
+				FamilyID:  &familyID,
+				UserAgent: session.UserAgent,
+				IPAddress: session.IPAddress,
+			})
+		}
+	}
+
+	return ErrSessionNotFound
+}
+
 // валидация jwt-токена
 func (s *authService) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
@@ -156,13 +362,15 @@ func (s *authService) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-func (s *authService) generateAuthResponse(ctx context.Context, user *models.User) (*models.AuthResponse, error) {
+// session - шаблон для нового refresh токена (семейство, remember me, данные клиента)
+func (s *authService) generateAuthResponse(ctx context.Context, user *models.User, session *repository.RefreshToken) (*models.AuthResponse, error) {
 	accessToken, expiresAt, err := s.generateAccessToken(user)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := s.generateRefreshToken(ctx, user.ID)
+	session.UserID = user.ID
+	refreshToken, err := s.generateRefreshToken(ctx, session)
 	if err != nil {
 		return nil, err
 	}
@@ -171,7 +379,8 @@ func (s *authService) generateAuthResponse(ctx context.Context, user *models.Use
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		ExpiresAt:    expiresAt.Unix(),
-		User:         *user,
+		RememberMe:   session.RememberMe,
+		User:         *withAvatarURL(user),
 	}, nil
 }
 
@@ -197,15 +406,20 @@ func (s *authService) generateAccessToken(user *models.User) (string, time.Time,
 	return tokenString, expiresAt, nil
 }
 
-func (s *authService) generateRefreshToken(ctx context.Context, userID uuid.UUID) (string, error) {
+func (s *authService) generateRefreshToken(ctx context.Context, session *repository.RefreshToken) (string, error) {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
 		return "", err
 	}
 	token := base64.URLEncoding.EncodeToString(bytes)
 
-	expiresAt := time.Now().Add(s.config.RefreshTokenExpiration)
-	if err := s.refreshTokenRepo.Create(ctx, userID, token, expiresAt); err != nil {
+	lifetime := s.config.RefreshTokenExpiration
+	if !session.RememberMe {
+		lifetime = s.config.RefreshTokenShortExpiration
+	}
+	session.ExpiresAt = time.Now().Add(lifetime)
+
+	if err := s.refreshTokenRepo.Create(ctx, session, token); err != nil {
 		return "", err
 	}
 
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUnknownBenchmark     = errors.New("unknown benchmark, use IMOEX, MCFTR, SBMX or BTC")
+	ErrBenchmarkUnavailable = errors.New("benchmark price history is unavailable")
+)
+
+// бенчмарки для сравнения: индекс Мосбиржи, он же полной доходности (с дивидендами), фонд на индекс и биткоин
+var benchmarks = map[string]models.Exchange{
+	"IMOEX": models.ExchangeMOEX,
+	"MCFTR": models.ExchangeMOEX,
+	"SBMX":  models.ExchangeMOEX,
+	"BTC":   models.ExchangeCRYPTO,
+}
+
+// DefaultBenchmark бенчмарк, если не указан
+const DefaultBenchmark = "IMOEX"
+
+// benchmarkPosition бумага портфеля при воспроизведении истории
+type benchmarkPosition struct {
+	security *models.Security
+	rate     decimal.Decimal // курс валюты бумаги к валюте портфеля
+	closes   map[time.Time]decimal.Decimal
+	last     decimal.Decimal
+	quantity decimal.Decimal
+}
+
+func (p *benchmarkPosition) value() decimal.Decimal {
+	return p.quantity.Mul(p.last).Mul(p.security.ContractSize()).Mul(p.rate)
+}
+
+// GetBenchmarkComparison проводит реальные денежные потоки портфеля через бенчмарк: на сумму каждой покупки
+// (или ввода бумаг) покупаются паи бенчмарка по цене того дня, на сумму продажи - продаются.
+// стоимость бумаг и бенчмарка переводится в валюту портфеля по текущему курсу, как в графике стоимости
+func (s *investmentService) GetBenchmarkComparison(ctx context.Context, userID, portfolioID uuid.UUID, benchmark string) (*models.BenchmarkComparison, error) {
+	benchmark = strings.ToUpper(strings.TrimSpace(benchmark))
+	if benchmark == "" {
+		benchmark = DefaultBenchmark
+	}
+	exchange, ok := benchmarks[benchmark]
+	if !ok {
+		return nil, ErrUnknownBenchmark
+	}
+
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+
+	result := &models.BenchmarkComparison{
+		PortfolioID: portfolioID,
+		Benchmark:   benchmark,
+		Currency:    portfolio.Currency,
+		Points:      []models.BenchmarkPoint{},
+	}
+
+	to := truncateDay(time.Now())
+	transactions, err := s.investmentRepo.GetByDateRange(ctx, portfolioID, time.Time{}, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 {
+		return result, nil
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if !transactions[i].Date.Equal(transactions[j].Date) {
+			return transactions[i].Date.Before(transactions[j].Date)
+		}
+		return transactions[i].CreatedAt.Before(transactions[j].CreatedAt)
+	})
+	from := truncateDay(transactions[0].Date)
+	result.From = from
+
+	bench, err := s.resolveSecurity(ctx, &models.InvestmentTransactionCreate{Ticker: benchmark, Exchange: exchange})
+	if err != nil {
+		return nil, ErrBenchmarkUnavailable
+	}
+	benchHistory, err := s.priceHistory.GetHistory(ctx, bench.ID, from, to)
+	if err != nil || len(benchHistory) == 0 {
+		return nil, ErrBenchmarkUnavailable
+	}
+
+	conv := newCurrencyConverter(s.marketProvider, portfolio.Currency)
+	benchRate, err := conv.rate(ctx, securityCurrency(bench, portfolio.Currency))
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make(map[uuid.UUID]*benchmarkPosition)
+	for _, tx := range transactions {
+		if _, ok := positions[tx.SecurityID]; ok {
+			continue
+		}
+		security, err := s.securityRepo.GetByID(ctx, tx.SecurityID)
+		if err != nil {
+			return nil, err
+		}
+		rate, err := conv.rate(ctx, securityCurrency(security, portfolio.Currency))
+		if err != nil {
+			return nil, err
+		}
+		pos := &benchmarkPosition{security: security, rate: rate, closes: make(map[time.Time]decimal.Decimal)}
+		// без истории бумага оценивается по цене последней сделки
+		if history, err := s.priceHistory.GetHistory(ctx, security.ID, from, to); err == nil {
+			for _, bar := range history {
+				if bar.Close.IsPositive() {
+					pos.closes[bar.Date] = bar.Close
+				}
+			}
+		}
+		positions[tx.SecurityID] = pos
+	}
+
+	var units, benchPrice decimal.Decimal
+	next := 0
+	// apply проводит сделки по дату date включительно
+	apply := func(date time.Time) {
+		for ; next < len(transactions) && !truncateDay(transactions[next].Date).After(date); next++ {
+			tx := &transactions[next]
+			pos := positions[tx.SecurityID]
+			rate := tx.ExchangeRate
+			if !rate.IsPositive() {
+				rate = decimal.NewFromInt(1)
+			}
+			gross := tx.Quantity.Mul(tx.Price).Mul(pos.security.ContractSize()).Mul(rate)
+			commission := tx.Commission.Mul(rate)
+			if pos.last.IsZero() && tx.Price.IsPositive() {
+				pos.last = tx.Price
+			}
+
+			switch tx.Type {
+			case models.InvestmentTransactionTypeBuy, models.InvestmentTransactionTypeTransferIn:
+				pos.quantity = pos.quantity.Add(tx.Quantity)
+				cash := gross.Add(commission)
+				result.Invested = result.Invested.Add(cash)
+				units = units.Add(cash.Div(benchPrice.Mul(benchRate)))
+			case models.InvestmentTransactionTypeSell, models.InvestmentTransactionTypeTransferOut,
+				models.InvestmentTransactionTypeExpiration, models.InvestmentTransactionTypeRedemption:
+				pos.quantity = pos.quantity.Sub(tx.Quantity)
+				cash := gross.Sub(commission)
+				result.PortfolioWithdrawn = result.PortfolioWithdrawn.Add(cash)
+				sold := decimal.Min(units, cash.Div(benchPrice.Mul(benchRate)))
+				units = units.Sub(sold)
+				result.BenchmarkWithdrawn = result.BenchmarkWithdrawn.Add(sold.Mul(benchPrice).Mul(benchRate))
+			case models.InvestmentTransactionTypeDividend, models.InvestmentTransactionTypeCoupon, models.InvestmentTransactionTypeAmortization:
+				result.PortfolioWithdrawn = result.PortfolioWithdrawn.Add(gross.Sub(commission))
+			case models.InvestmentTransactionTypeTax:
+				result.PortfolioWithdrawn = result.PortfolioWithdrawn.Sub(tx.Amount.Mul(rate))
+			case models.InvestmentTransactionTypeFee:
+				result.Invested = result.Invested.Add(tx.Amount.Mul(rate))
+			case models.InvestmentTransactionTypeSplit:
+				if tx.Quantity.IsPositive() {
+					pos.quantity = pos.quantity.Mul(tx.Quantity)
+					pos.last = pos.last.Div(tx.Quantity)
+				}
+			case models.InvestmentTransactionTypeStakingReward, models.InvestmentTransactionTypeAirdrop:
+				pos.quantity = pos.quantity.Add(tx.Quantity)
+			}
+		}
+	}
+	portfolioValue := func() decimal.Decimal {
+		var value decimal.Decimal
+		for _, pos := range positions {
+			value = value.Add(pos.value())
+		}
+		return value
+	}
+
+	for _, bar := range benchHistory {
+		if !bar.Close.IsPositive() {
+			continue
+		}
+		benchPrice = bar.Close
+		for _, pos := range positions {
+			if c, ok := pos.closes[bar.Date]; ok {
+				pos.last = c
+			}
+		}
+		apply(bar.Date)
+
+		result.Points = append(result.Points, models.BenchmarkPoint{
+			Date:           bar.Date,
+			Invested:       result.Invested.Round(2),
+			PortfolioValue: portfolioValue().Round(2),
+			BenchmarkValue: units.Mul(benchPrice).Mul(benchRate).Round(2),
+		})
+	}
+	if benchPrice.IsZero() {
+		return nil, ErrBenchmarkUnavailable
+	}
+	// сделки после последней свечи бенчмарка (сегодня торгов еще не было) - по последней цене
+	apply(to)
+
+	result.PortfolioValue = portfolioValue().Round(2)
+	result.BenchmarkValue = units.Mul(benchPrice).Mul(benchRate).Round(2)
+	result.Invested = result.Invested.Round(2)
+	result.PortfolioWithdrawn = result.PortfolioWithdrawn.Round(2)
+	result.BenchmarkWithdrawn = result.BenchmarkWithdrawn.Round(2)
+	result.PortfolioProfit = result.PortfolioValue.Add(result.PortfolioWithdrawn).Sub(result.Invested)
+	result.BenchmarkProfit = result.BenchmarkValue.Add(result.BenchmarkWithdrawn).Sub(result.Invested)
+	if result.Invested.IsPositive() {
+		hundred := decimal.NewFromInt(100)
+		result.PortfolioReturnPct = result.PortfolioProfit.Div(result.Invested).Mul(hundred).Round(2)
+		result.BenchmarkReturnPct = result.BenchmarkProfit.Div(result.Invested).Mul(hundred).Round(2)
+		result.Outperformance = result.PortfolioReturnPct.Sub(result.BenchmarkReturnPct)
+	}
+
+	// за несколько лет дневных точек слишком много для графика - оставляем последнюю точку каждой недели
+	if to.Sub(from) > 366*24*time.Hour {
+		result.Points = weeklyBenchmarkPoints(result.Points)
+	}
+	return result, nil
+}
+
+func weeklyBenchmarkPoints(points []models.BenchmarkPoint) []models.BenchmarkPoint {
+	weekly := make([]models.BenchmarkPoint, 0, len(points)/5+1)
+	for i, p := range points {
+		if i == len(points)-1 {
+			weekly = append(weekly, p)
+			break
+		}
+		y1, w1 := p.Date.ISOWeek()
+		y2, w2 := points[i+1].Date.ISOWeek()
+		if y1 != y2 || w1 != w2 {
+			weekly = append(weekly, p)
+		}
+	}
+	return weekly
+}
+
+// securityCurrency валюта цены бумаги: своя, биржи или fallback
+func securityCurrency(security *models.Security, fallback string) string {
+	if security.Currency != "" {
+		return security.Currency
+	}
+	if c := security.Exchange.QuoteCurrency(); c != "" {
+		return c
+	}
+	return fallback
+}
@@ -2,6 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/models"
@@ -10,12 +16,35 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+var (
+	ErrBudgetNotFound               = errors.New("budget not found")
+	ErrInvalidBudgetAlertThresholds = errors.New("alert_thresholds must contain 1-8 values between 1 and 1000")
+)
+
+// сколько периодов истории бюджета отдаем по умолчанию и максимум
+const (
+	defaultBudgetHistoryPeriods = 6
+	maxBudgetHistoryPeriods     = 24
+
+	maxBudgetAlertThresholds = 8
+	maxBudgetAlertPercent    = 1000
+)
+
 type BudgetService interface {
 	Create(ctx context.Context, userID uuid.UUID, input *models.BudgetCreate) (*models.Budget, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Budget, error)
 	GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.Budget, error)
 	GetSummary(ctx context.Context, userID uuid.UUID) (*models.BudgetSummary, error)
+	// GetAlerts бюджеты, дошедшие до порога в текущем периоде, со старшим достигнутым порогом; ничего не отмечает
 	GetAlerts(ctx context.Context, userID uuid.UUID) ([]models.BudgetAlert, error)
+	// EvaluateAlerts отмечает достигнутые пороги сработавшими и возвращает бюджеты, у которых сработал
+	// порог выше прежних в этом периоде; повторная проверка без новых трат ничего не вернет
+	EvaluateAlerts(ctx context.Context, userID uuid.UUID) ([]models.BudgetAlert, error)
+	Suggest(ctx context.Context, userID uuid.UUID, input *models.BudgetSuggestRequest) ([]models.BudgetSuggestion, error)
+	// GetHistory факт против бюджета за последние periods периодов бюджета (текущий включительно)
+	GetHistory(ctx context.Context, userID, budgetID uuid.UUID, periods int) (*models.BudgetHistory, error)
+	// GetHistoryReport то же по всем активным бюджетам с итогами
+	GetHistoryReport(ctx context.Context, userID uuid.UUID, periods int) (*models.BudgetHistoryReport, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.BudgetUpdate) (*models.Budget, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
@@ -24,32 +53,47 @@ type budgetService struct {
 	budgetRepo      repository.BudgetRepository
 	transactionRepo repository.TransactionRepository
 	categoryRepo    repository.CategoryRepository
+	accountRepo     repository.AccountRepository
+	payeeRepo       repository.PayeeRepository
+	userRepo        repository.UserRepository
+	txManager       repository.TxManager
 }
 
-func NewBudgetService(budgetRepo repository.BudgetRepository, transactionRepo repository.TransactionRepository, categoryRepo repository.CategoryRepository) BudgetService {
+func NewBudgetService(budgetRepo repository.BudgetRepository, transactionRepo repository.TransactionRepository, categoryRepo repository.CategoryRepository, accountRepo repository.AccountRepository, payeeRepo repository.PayeeRepository, userRepo repository.UserRepository, txManager repository.TxManager) BudgetService {
 	return &budgetService{
 		budgetRepo:      budgetRepo,
 		transactionRepo: transactionRepo,
 		categoryRepo:    categoryRepo,
+		accountRepo:     accountRepo,
+		payeeRepo:       payeeRepo,
+		userRepo:        userRepo,
+		txManager:       txManager,
 	}
 }
 
 func (s *budgetService) Create(ctx context.Context, userID uuid.UUID, input *models.BudgetCreate) (*models.Budget, error) {
-	budget := &models.Budget{
-		UserID:       userID,
-		CategoryID:   input.CategoryID,
-		Name:         input.Name,
-		Amount:       input.Amount,
-		Currency:     input.Currency,
-		Period:       input.Period,
-		StartDate:    input.StartDate,
-		EndDate:      input.EndDate,
-		AlertPercent: input.AlertPercent,
-		Notes:        input.Notes,
+	if err := s.validateScope(ctx, userID, input.AccountID, input.PayeeID); err != nil {
+		return nil, err
+	}
+	thresholds, err := budgetAlertThresholds(input.AlertThresholds, input.AlertPercent)
+	if err != nil {
+		return nil, err
 	}
 
-	if budget.AlertPercent == 0 {
-		budget.AlertPercent = 80
+	budget := &models.Budget{
+		UserID:          userID,
+		CategoryID:      input.CategoryID,
+		AccountID:       input.AccountID,
+		PayeeID:         input.PayeeID,
+		Name:            input.Name,
+		Amount:          input.Amount,
+		Currency:        input.Currency,
+		Period:          input.Period,
+		StartDate:       input.StartDate,
+		EndDate:         input.EndDate,
+		AlertPercent:    thresholds[0],
+		AlertThresholds: thresholds,
+		Notes:           input.Notes,
 	}
 
 	if err := s.budgetRepo.Create(ctx, budget); err != nil {
@@ -57,7 +101,32 @@ func (s *budgetService) Create(ctx context.Context, userID uuid.UUID, input *mod
 	}
 
 	// вычисляем поля
-	return s.calculateBudgetSpent(ctx, budget)
+	return s.calculateBudgetSpent(ctx, budget, s.now(ctx, userID))
+}
+
+// budgetAlertThresholds пороги по возрастанию без повторов. без порогов: alert_percent и 100%
+// (как было до нескольких порогов), а если нет и его - пороги по умолчанию
+func budgetAlertThresholds(thresholds []int, alertPercent int) ([]int, error) {
+	if len(thresholds) == 0 {
+		if alertPercent == 0 {
+			return slices.Clone(models.DefaultBudgetAlertThresholds), nil
+		}
+		thresholds = []int{alertPercent, 100}
+		if alertPercent > 100 {
+			thresholds = []int{alertPercent}
+		}
+	}
+	if len(thresholds) > maxBudgetAlertThresholds {
+		return nil, ErrInvalidBudgetAlertThresholds
+	}
+	for _, t := range thresholds {
+		if t < 1 || t > maxBudgetAlertPercent {
+			return nil, ErrInvalidBudgetAlertThresholds
+		}
+	}
+	sorted := slices.Clone(thresholds)
+	slices.Sort(sorted)
+	return slices.Compact(sorted), nil
 }
 
 func (s *budgetService) GetByID(ctx context.Context, id uuid.UUID) (*models.Budget, error) {
@@ -65,7 +134,7 @@ func (s *budgetService) GetByID(ctx context.Context, id uuid.UUID) (*models.Budg
 	if err != nil {
 		return nil, err
 	}
-	return s.calculateBudgetSpent(ctx, budget)
+	return s.calculateBudgetSpent(ctx, budget, s.now(ctx, budget.UserID))
 }
 
 func (s *budgetService) GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.Budget, error) {
@@ -74,13 +143,9 @@ func (s *budgetService) GetByUserID(ctx context.Context, userID uuid.UUID, activ
 		return nil, err
 	}
 
-	for i := range budgets {
-		updated, _ := s.calculateBudgetSpent(ctx, &budgets[i])
-		if updated != nil {
-			budgets[i] = *updated
-		}
+	if err := s.calculateBudgetsSpent(ctx, userID, budgets, s.now(ctx, userID)); err != nil {
+		return nil, err
 	}
-
 	return budgets, nil
 }
 
@@ -109,88 +174,618 @@ func (s *budgetService) GetSummary(ctx context.Context, userID uuid.UUID) (*mode
 }
 
 func (s *budgetService) GetAlerts(ctx context.Context, userID uuid.UUID) ([]models.BudgetAlert, error) {
+	return s.alerts(ctx, userID, false)
+}
+
+func (s *budgetService) EvaluateAlerts(ctx context.Context, userID uuid.UUID) ([]models.BudgetAlert, error) {
+	return s.alerts(ctx, userID, true)
+}
+
+// alerts бюджеты, дошедшие до порога в текущем периоде. fire - отметить достигнутые пороги сработавшими
+// и оставить только бюджеты, где сработал порог выше уже сработавших (после скачка трат с 40% до 130%
+// сработают 50, 80, 100 и 120, но оповещение будет одно - о 120%)
+func (s *budgetService) alerts(ctx context.Context, userID uuid.UUID, fire bool) ([]models.BudgetAlert, error) {
 	budgets, err := s.GetByUserID(ctx, userID, true)
 	if err != nil {
 		return nil, err
 	}
 
+	now := s.now(ctx, userID)
+	ids := make([]uuid.UUID, len(budgets))
+	periodStarts := make([]time.Time, len(budgets))
+	for i := range budgets {
+		ids[i] = budgets[i].ID
+		periodStarts[i], _ = s.getBudgetPeriodDates(&budgets[i], now)
+	}
+	fired, err := s.budgetRepo.GetFiredAlerts(ctx, ids, periodStarts)
+	if err != nil {
+		return nil, err
+	}
+
 	var alerts []models.BudgetAlert
-	for _, budget := range budgets {
-		if budget.SpentPercent >= float64(budget.AlertPercent) {
-			alertType := "warning"
-			if budget.SpentPercent >= 100 {
-				alertType = "exceeded"
+	for i, budget := range budgets {
+		threshold := reachedThreshold(budget.AlertThresholds, budget.SpentPercent)
+		if threshold == 0 {
+			continue
+		}
+
+		alertType := "warning"
+		if budget.SpentPercent >= 100 {
+			alertType = "exceeded"
+		}
+		alert := models.BudgetAlert{
+			BudgetID:    budget.ID,
+			BudgetName:  budget.Name,
+			Scope:       budget.Scope,
+			Amount:      budget.Amount,
+			Spent:       budget.Spent,
+			Percent:     budget.SpentPercent,
+			AlertType:   alertType,
+			Threshold:   threshold,
+			PeriodStart: periodStarts[i],
+		}
+		if firedAt, ok := fired[budget.ID][threshold]; ok {
+			alert.FiredAt = &firedAt
+		}
+
+		if fire {
+			raised, err := s.fireThresholds(ctx, &budget, periodStarts[i], fired[budget.ID])
+			if err != nil {
+				return nil, err
+			}
+			if raised == nil {
+				continue
+			}
+			alert.FiredAt = raised
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, nil
+}
+
+// fireThresholds отмечает достигнутые, но еще не сработавшие пороги бюджета; возвращает время срабатывания,
+// если среди них есть порог выше всех сработавших раньше, иначе nil. вызывается в одной транзакции
+// с записью уведомления (см. notificationService.CheckBudgets)
+func (s *budgetService) fireThresholds(ctx context.Context, budget *models.Budget, periodStart time.Time, fired map[int]time.Time) (*time.Time, error) {
+	highest := 0
+	for t := range fired {
+		highest = max(highest, t)
+	}
+
+	now := time.Now()
+	raised := false
+	for _, t := range budget.AlertThresholds {
+		if float64(t) > budget.SpentPercent {
+			break
+		}
+		if _, ok := fired[t]; ok {
+			continue
+		}
+		// порог мог отметить параллельный запрос - тогда оповещение за ним
+		marked, err := s.budgetRepo.MarkAlertFired(ctx, budget.ID, periodStart, t, now)
+		if err != nil {
+			return nil, err
+		}
+		if marked && t > highest {
+			raised = true
+		}
+	}
+	if !raised {
+		return nil, nil
+	}
+	return &now, nil
+}
+
+// reachedThreshold старший порог (пороги по возрастанию), до которого дошли траты; 0 - ни одного
+func reachedThreshold(thresholds []int, percent float64) int {
+	reached := 0
+	for _, t := range thresholds {
+		if float64(t) > percent {
+			break
+		}
+		reached = t
+	}
+	return reached
+}
+
+// Suggest предлагает месячные бюджеты по категориям: медиана расходов за последние N полных месяцев + запас
+func (s *budgetService) Suggest(ctx context.Context, userID uuid.UUID, input *models.BudgetSuggestRequest) ([]models.BudgetSuggestion, error) {
+	months := input.Months
+	if months <= 0 {
+		months = 3
+	}
+	if months > 24 {
+		months = 24
+	}
+	buffer := input.BufferPercent
+	if buffer <= 0 {
+		buffer = 10
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	currency := strings.ToUpper(strings.TrimSpace(input.Currency))
+	if currency == "" {
+		currency = user.DefaultCurrency
+	}
+
+	// суммы по категориям за каждый месяц (текущий неполный месяц не берем).
+	// учитываются только расходы в валюте бюджетов: рубли и доллары не складываем
+	now := localNow(user)
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	monthly := make(map[uuid.UUID][]decimal.Decimal)
+	for i := months; i >= 1; i-- {
+		start := currentMonth.AddDate(0, -i, 0)
+		end := start.AddDate(0, 1, 0).Add(-time.Nanosecond)
+
+		byCurrency, err := s.transactionRepo.GetSumByCategoryCurrency(ctx, userID, start, end, models.TransactionTypeExpense)
+		if err != nil {
+			return nil, err
+		}
+		for categoryID, sum := range byCurrency[currency] {
+			monthly[categoryID] = append(monthly[categoryID], sum)
+		}
+	}
+
+	// категории на которые уже есть бюджет
+	existing, err := s.budgetRepo.GetByUserID(ctx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	budgeted := make(map[uuid.UUID]bool)
+	for _, b := range existing {
+		// бюджет по счету или получателю покрывает только часть расходов категории
+		if b.CategoryID != nil && b.AccountID == nil && b.PayeeID == nil {
+			budgeted[*b.CategoryID] = true
+		}
+	}
+
+	// названия категорий одним запросом
+	categories, err := s.categoryRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	categoryNames := make(map[uuid.UUID]string, len(categories))
+	for _, category := range categories {
+		categoryNames[category.ID] = category.Name
+	}
+
+	multiplier := decimal.NewFromInt(int64(100 + buffer)).Div(decimal.NewFromInt(100))
+	hundred := decimal.NewFromInt(100)
+
+	var suggestions []models.BudgetSuggestion
+	for categoryID, sums := range monthly {
+		// месяцы без расходов считаем нулями, чтобы разовые траты не раздували бюджет
+		values := make([]decimal.Decimal, months)
+		copy(values, sums)
+
+		median := medianDecimal(values)
+		if median.IsZero() {
+			continue
+		}
+
+		total := decimal.Zero
+		for _, v := range sums {
+			total = total.Add(v)
+		}
+
+		suggestion := models.BudgetSuggestion{
+			CategoryID:      categoryID,
+			Median:          median.Round(2),
+			Average:         total.Div(decimal.NewFromInt(int64(months))).Round(2),
+			SuggestedAmount: median.Mul(multiplier).Div(hundred).Ceil().Mul(hundred),
+			ActiveMonths:    len(sums),
+			HasBudget:       budgeted[categoryID],
+			CategoryName:    categoryNames[categoryID],
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].SuggestedAmount.GreaterThan(suggestions[j].SuggestedAmount)
+	})
+
+	if !input.Create {
+		return suggestions, nil
+	}
+
+	// бюджеты создаются все или ни одного
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		for i := range suggestions {
+			if suggestions[i].HasBudget {
+				continue
 			}
 
-			alerts = append(alerts, models.BudgetAlert{
-				BudgetID:   budget.ID,
-				BudgetName: budget.Name,
-				Amount:     budget.Amount,
-				Spent:      budget.Spent,
-				Percent:    budget.SpentPercent,
-				AlertType:  alertType,
+			categoryID := suggestions[i].CategoryID
+			name := suggestions[i].CategoryName
+			if name == "" {
+				name = "Бюджет"
+			}
+
+			budget, err := s.Create(txCtx, userID, &models.BudgetCreate{
+				CategoryID: &categoryID,
+				Name:       name,
+				Amount:     suggestions[i].SuggestedAmount,
+				Currency:   currency,
+				Period:     models.BudgetPeriodMonthly,
+				StartDate:  currentMonth,
+				Notes:      "создан автоматически по истории расходов",
 			})
+			if err != nil {
+				return err
+			}
+			suggestions[i].Budget = budget
+			suggestions[i].HasBudget = true
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return alerts, nil
+	return suggestions, nil
+}
+
+func (s *budgetService) GetHistory(ctx context.Context, userID, budgetID uuid.UUID, periods int) (*models.BudgetHistory, error) {
+	budget, err := s.budgetRepo.GetByID(ctx, budgetID)
+	if err != nil || budget.UserID != userID {
+		return nil, ErrBudgetNotFound
+	}
+	now := s.now(ctx, userID)
+	spendPeriods := s.historyPeriods(budget, periods, now)
+	spent, err := s.transactionRepo.GetExpenseSums(ctx, userID, spendPeriods)
+	if err != nil {
+		return nil, err
+	}
+	return buildBudgetHistory(budget, spendPeriods, spent), nil
+}
+
+func (s *budgetService) GetHistoryReport(ctx context.Context, userID uuid.UUID, periods int) (*models.BudgetHistoryReport, error) {
+	budgets, err := s.budgetRepo.GetByUserID(ctx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// периоды всех бюджетов считаем одним запросом, потом раскладываем по бюджетам
+	now := s.now(ctx, userID)
+	var spendPeriods []models.BudgetSpendPeriod
+	offsets := make([]int, len(budgets)+1)
+	for i := range budgets {
+		spendPeriods = append(spendPeriods, s.historyPeriods(&budgets[i], periods, now)...)
+		offsets[i+1] = len(spendPeriods)
+	}
+	spent, err := s.transactionRepo.GetExpenseSums(ctx, userID, spendPeriods)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.BudgetHistoryReport{Periods: clampHistoryPeriods(periods)}
+	for i := range budgets {
+		from, to := offsets[i], offsets[i+1]
+		history := buildBudgetHistory(&budgets[i], spendPeriods[from:to], spent[from:to])
+
+		for _, p := range history.Periods {
+			report.TotalBudgeted = report.TotalBudgeted.Add(p.Budgeted)
+			report.TotalSpent = report.TotalSpent.Add(p.Spent)
+		}
+		if history.ConsistentlyOver {
+			report.ConsistentlyOver++
+		}
+		report.Budgets = append(report.Budgets, *history)
+	}
+	report.TotalVariance = report.TotalBudgeted.Sub(report.TotalSpent)
+
+	// сначала бюджеты, которые чаще всего превышаются
+	sort.SliceStable(report.Budgets, func(i, j int) bool {
+		if report.Budgets[i].OverCount != report.Budgets[j].OverCount {
+			return report.Budgets[i].OverCount > report.Budgets[j].OverCount
+		}
+		return report.Budgets[i].AveragePercent > report.Budgets[j].AveragePercent
+	})
+
+	return report, nil
+}
+
+// historyPeriods последние periods периодов бюджета от старых к новым, текущий - последним.
+// у кастомного бюджета период один
+func (s *budgetService) historyPeriods(budget *models.Budget, periods int, now time.Time) []models.BudgetSpendPeriod {
+	periods = clampHistoryPeriods(periods)
+
+	var result []models.BudgetSpendPeriod
+	for i := periods - 1; i >= 0; i-- {
+		start, end, ok := s.budgetPeriodBack(budget, i, now)
+		if !ok {
+			continue
+		}
+		result = append(result, budgetSpendPeriod(budget, start, end))
+	}
+	return result
+}
+
+// buildBudgetHistory факт против бюджета по периодам из historyPeriods и расходам за них
+func buildBudgetHistory(budget *models.Budget, periods []models.BudgetSpendPeriod, spentByPeriod []decimal.Decimal) *models.BudgetHistory {
+	history := &models.BudgetHistory{
+		BudgetID:   budget.ID,
+		BudgetName: budget.Name,
+		CategoryID: budget.CategoryID,
+		AccountID:  budget.AccountID,
+		PayeeID:    budget.PayeeID,
+		Period:     budget.Period,
+		Currency:   budget.Currency,
+	}
+
+	hundred := decimal.NewFromInt(100)
+	totalSpent := decimal.Zero
+	totalPercent := 0.0
+	completed := 0
+
+	for i, period := range periods {
+		spent := spentByPeriod[i]
+
+		// исторических лимитов не храним - сравниваем с текущей суммой бюджета
+		result := models.BudgetPeriodResult{
+			PeriodStart: period.Start,
+			PeriodEnd:   period.End,
+			Budgeted:    budget.Amount,
+			Spent:       spent,
+			Variance:    budget.Amount.Sub(spent),
+			IsOver:      spent.GreaterThan(budget.Amount),
+			IsCurrent:   i == len(periods)-1,
+		}
+		if budget.Amount.GreaterThan(decimal.Zero) {
+			result.SpentPercent = spent.Div(budget.Amount).Mul(hundred).Round(2).InexactFloat64()
+		}
+
+		totalSpent = totalSpent.Add(spent)
+		totalPercent += result.SpentPercent
+
+		// текущий период еще не закончился, в "систематический перерасход" его не считаем
+		if !result.IsCurrent {
+			completed++
+			if result.IsOver {
+				history.OverCount++
+			}
+		}
+
+		history.Periods = append(history.Periods, result)
+	}
+
+	if n := len(history.Periods); n > 0 {
+		history.AverageSpent = totalSpent.Div(decimal.NewFromInt(int64(n))).Round(2)
+		history.AveragePercent = math.Round(totalPercent/float64(n)*100) / 100
+	}
+	history.ConsistentlyOver = completed > 0 && history.OverCount*2 >= completed
+
+	return history
+}
+
+func clampHistoryPeriods(periods int) int {
+	if periods <= 0 {
+		return defaultBudgetHistoryPeriods
+	}
+	if periods > maxBudgetHistoryPeriods {
+		return maxBudgetHistoryPeriods
+	}
+	return periods
 }
 
 func (s *budgetService) Update(ctx context.Context, id uuid.UUID, update *models.BudgetUpdate) (*models.Budget, error) {
+	budget, err := s.budgetRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrBudgetNotFound
+	}
+	if update.AccountID != nil || update.PayeeID != nil {
+		if err := s.validateScope(ctx, budget.UserID, update.AccountID, update.PayeeID); err != nil {
+			return nil, err
+		}
+	}
+	if update.AlertThresholds != nil || update.AlertPercent != nil {
+		alertPercent := 0
+		if update.AlertPercent != nil {
+			alertPercent = *update.AlertPercent
+		}
+		thresholds, err := budgetAlertThresholds(update.AlertThresholds, alertPercent)
+		if err != nil {
+			return nil, err
+		}
+		update.AlertThresholds, update.AlertPercent = thresholds, &thresholds[0]
+	}
+
 	if err := s.budgetRepo.Update(ctx, id, update); err != nil {
 		return nil, err
 	}
-	return s.GetByID(ctx, id)
+	updated, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// после смены лимита, периода или порогов пороги выше текущих трат снова могут сработать в этом периоде
+	if update.Amount != nil || update.Period != nil || update.StartDate != nil || update.AlertThresholds != nil {
+		periodStart, _ := s.getBudgetPeriodDates(updated, s.now(ctx, updated.UserID))
+		if err := s.budgetRepo.ResetAlerts(ctx, id, periodStart, updated.SpentPercent); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
 }
 
 func (s *budgetService) Delete(ctx context.Context, id uuid.UUID) error {
 	return s.budgetRepo.Delete(ctx, id)
 }
 
-func (s *budgetService) calculateBudgetSpent(ctx context.Context, budget *models.Budget) (*models.Budget, error) {
-	// вычисляем начало и конец бюджетирования
-	startDate, endDate := s.getBudgetPeriodDates(budget)
+func (s *budgetService) calculateBudgetSpent(ctx context.Context, budget *models.Budget, now time.Time) (*models.Budget, error) {
+	budgets := []models.Budget{*budget}
+	if err := s.calculateBudgetsSpent(ctx, budget.UserID, budgets, now); err != nil {
+		return nil, err
+	}
+	return &budgets[0], nil
+}
 
-	// расходы
-	var spent decimal.Decimal
+// calculateBudgetsSpent заполняет расходы бюджетов пользователя за их текущие периоды.
+// расходы всех бюджетов - один запрос, категории, счета и получатели - по запросу на все бюджеты
+func (s *budgetService) calculateBudgetsSpent(ctx context.Context, userID uuid.UUID, budgets []models.Budget, now time.Time) error {
+	if len(budgets) == 0 {
+		return nil
+	}
 
-	if budget.CategoryID != nil {
-		sums, err := s.transactionRepo.GetSumByCategory(ctx, budget.UserID, startDate, endDate, models.TransactionTypeExpense)
-		if err == nil {
-			if sum, ok := sums[*budget.CategoryID]; ok {
-				spent = sum
+	periods := make([]models.BudgetSpendPeriod, len(budgets))
+	for i := range budgets {
+		// вычисляем начало и конец бюджетирования
+		start, end := s.getBudgetPeriodDates(&budgets[i], now)
+		periods[i] = budgetSpendPeriod(&budgets[i], start, end)
+	}
+	spent, err := s.transactionRepo.GetExpenseSums(ctx, userID, periods)
+	if err != nil {
+		return err
+	}
+
+	categories := make(map[uuid.UUID]*models.Category)
+	if userCategories, err := s.categoryRepo.GetByUserID(ctx, userID); err == nil {
+		for i := range userCategories {
+			categories[userCategories[i].ID] = &userCategories[i]
+		}
+	}
+
+	accountNames, payeeNames := s.scopeNames(ctx, userID, budgets)
+
+	for i := range budgets {
+		budget := &budgets[i]
+		budget.Spent = spent[i]
+		budget.Remaining = budget.Amount.Sub(spent[i])
+		if budget.Amount.GreaterThan(decimal.Zero) {
+			budget.SpentPercent = spent[i].Div(budget.Amount).Mul(decimal.NewFromInt(100)).InexactFloat64()
+		}
+
+		// достаем инфу о категории и добавляем в поле
+		if budget.CategoryID != nil {
+			budget.Category = categories[*budget.CategoryID]
+		}
+		budget.Scope = describeScope(budget, accountNames, payeeNames)
+	}
+	return nil
+}
+
+// validateScope счет и получатель бюджета должны принадлежать пользователю
+func (s *budgetService) validateScope(ctx context.Context, userID uuid.UUID, accountID, payeeID *uuid.UUID) error {
+	if accountID != nil {
+		account, err := s.accountRepo.GetByID(ctx, *accountID)
+		if err != nil || account.UserID != userID {
+			return ErrAccountNotFound
+		}
+	}
+	if payeeID != nil {
+		payee, err := s.payeeRepo.GetByID(ctx, *payeeID)
+		if err != nil || payee.UserID != userID {
+			return ErrPayeeNotFound
+		}
+	}
+	return nil
+}
+
+// scopeNames названия счетов и получателей, на которые ограничены бюджеты; загружаются, только если такие бюджеты есть
+func (s *budgetService) scopeNames(ctx context.Context, userID uuid.UUID, budgets []models.Budget) (map[uuid.UUID]string, map[uuid.UUID]string) {
+	accountNames := make(map[uuid.UUID]string)
+	payeeNames := make(map[uuid.UUID]string)
+
+	needAccounts, needPayees := false, false
+	for i := range budgets {
+		needAccounts = needAccounts || budgets[i].AccountID != nil
+		needPayees = needPayees || budgets[i].PayeeID != nil
+	}
+
+	if needAccounts {
+		if accounts, err := s.accountRepo.GetByUserID(ctx, userID); err == nil {
+			for _, account := range accounts {
+				accountNames[account.ID] = account.Name
 			}
 		}
-	} else {
-		// все категории
-		sums, err := s.transactionRepo.GetSumByCategory(ctx, budget.UserID, startDate, endDate, models.TransactionTypeExpense)
-		if err == nil {
-			for _, sum := range sums {
-				spent = spent.Add(sum)
+	}
+	if needPayees {
+		if payees, err := s.payeeRepo.GetByUserID(ctx, userID); err == nil {
+			for _, payee := range payees {
+				payeeNames[payee.ID] = payee.Name
 			}
 		}
 	}
+	return accountNames, payeeNames
+}
+
+// describeScope на какие расходы распространяется бюджет: "категория «Кафе», счет «Tinkoff Black»"
+func describeScope(budget *models.Budget, accountNames, payeeNames map[uuid.UUID]string) string {
+	var parts []string
+	if budget.Category != nil {
+		parts = append(parts, fmt.Sprintf("категория «%s»", budget.Category.Name))
+	}
+	if budget.AccountID != nil {
+		if name, ok := accountNames[*budget.AccountID]; ok {
+			parts = append(parts, fmt.Sprintf("счет «%s»", name))
+		}
+	}
+	if budget.PayeeID != nil {
+		if name, ok := payeeNames[*budget.PayeeID]; ok {
+			parts = append(parts, fmt.Sprintf("получатель «%s»", name))
+		}
+	}
 
-	budget.Spent = spent
-	budget.Remaining = budget.Amount.Sub(spent)
+	if len(parts) == 0 {
+		return "все расходы"
+	}
+	return strings.Join(parts, ", ")
+}
 
-	if budget.Amount.GreaterThan(decimal.Zero) {
-		budget.SpentPercent = spent.Div(budget.Amount).Mul(decimal.NewFromInt(100)).InexactFloat64()
+// budgetSpendPeriod период бюджета со всеми его фильтрами: категорией, счетом и получателем
+func budgetSpendPeriod(budget *models.Budget, start, end time.Time) models.BudgetSpendPeriod {
+	return models.BudgetSpendPeriod{
+		Start:      start,
+		End:        end,
+		CategoryID: budget.CategoryID,
+		AccountID:  budget.AccountID,
+		PayeeID:    budget.PayeeID,
 	}
+}
 
-	// достаем инфу о категории и добавляем в поле
-	if budget.CategoryID != nil {
-		category, err := s.categoryRepo.GetByID(ctx, *budget.CategoryID)
-		if err == nil {
-			budget.Category = category
-		}
+// budgetPeriodBack границы периода бюджета, отстоящего на n периодов назад от текущего.
+// у кастомного бюджета период один, для n > 0 возвращается false
+func (s *budgetService) budgetPeriodBack(budget *models.Budget, n int, now time.Time) (time.Time, time.Time, bool) {
+	start, end := s.getBudgetPeriodDates(budget, now)
+	if n == 0 {
+		return start, end, true
 	}
 
-	return budget, nil
+	switch budget.Period {
+	case models.BudgetPeriodWeekly:
+		return start.AddDate(0, 0, -7*n), end.AddDate(0, 0, -7*n), true
+
+	case models.BudgetPeriodMonthly:
+		start = start.AddDate(0, -n, 0)
+		return start, start.AddDate(0, 1, -1), true
+
+	case models.BudgetPeriodQuarterly:
+		start = start.AddDate(0, -3*n, 0)
+		return start, start.AddDate(0, 3, -1), true
+
+	case models.BudgetPeriodYearly:
+		start = start.AddDate(-n, 0, 0)
+		return start, time.Date(start.Year(), 12, 31, 23, 59, 59, 0, start.Location()), true
+	}
+
+	return time.Time{}, time.Time{}, false
 }
 
-func (s *budgetService) getBudgetPeriodDates(budget *models.Budget) (time.Time, time.Time) {
-	now := time.Now()
+// now текущее время в часовом поясе пользователя: по нему считаются границы периодов бюджета
+func (s *budgetService) now(ctx context.Context, userID uuid.UUID) time.Time {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return time.Now().UTC()
+	}
+	return localNow(user)
+}
+
+// getBudgetPeriodDates границы текущего периода бюджета; now - в часовом поясе пользователя
+func (s *budgetService) getBudgetPeriodDates(budget *models.Budget, now time.Time) (time.Time, time.Time) {
 	// логика такая: если указываем период не кастом то отсчитывается начало и конец от тек времени(budget.StartDate, *budget.EndDate игнорируюся ), если кастом то берется budget.StartDate, *budget.EndDate или now
 	switch budget.Period {
 	case models.BudgetPeriodWeekly:
@@ -230,3 +825,21 @@ func (s *budgetService) getBudgetPeriodDates(budget *models.Budget) (time.Time,
 		return budget.StartDate, now
 	}
 }
+
+func medianDecimal(values []decimal.Decimal) decimal.Decimal {
+	if len(values) == 0 {
+		return decimal.Zero
+	}
+
+	sorted := make([]decimal.Decimal, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].LessThan(sorted[j])
+	})
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return sorted[mid-1].Add(sorted[mid]).Div(decimal.NewFromInt(2))
+	}
+	return sorted[mid]
+}
@@ -2,12 +2,26 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/repository"
 	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
 )
 
+var (
+	ErrCategoryNotFound     = errors.New("category not found")
+	ErrCategoryNotExpense   = errors.New("only expense categories can be fixed")
+	ErrInvalidCategoryIcon  = errors.New("icon must be an emoji or short symbol of up to 32 characters")
+	ErrInvalidCategoryColor = errors.New("color must be a hex color like #4CAF50")
+)
+
+const maxCategoryIconLength = 32 // categories.icon VARCHAR(32)
+
 type CategoryService interface {
 	Create(ctx context.Context, userID uuid.UUID, input *models.CategoryCreate) (*models.Category, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Category, error)
@@ -15,6 +29,9 @@ type CategoryService interface {
 	GetByType(ctx context.Context, userID uuid.UUID, categoryType models.CategoryType) ([]models.Category, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.CategoryUpdate) (*models.Category, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	// SetFixed отмечает категорию расходов обязательной (аренда, коммуналка) или необязательной.
+	// работает и для системных категорий - настройка хранится у пользователя
+	SetFixed(ctx context.Context, userID, id uuid.UUID, input *models.CategoryFixed) (*models.Category, error)
 }
 
 type categoryService struct {
@@ -26,6 +43,15 @@ func NewCategoryService(categoryRepo repository.CategoryRepository) CategoryServ
 }
 
 func (s *categoryService) Create(ctx context.Context, userID uuid.UUID, input *models.CategoryCreate) (*models.Category, error) {
+	icon, err := normalizeIcon(input.Icon)
+	if err != nil {
+		return nil, err
+	}
+	color, err := normalizeColor(input.Color)
+	if err != nil {
+		return nil, err
+	}
+
 	existingCategories, err := s.categoryRepo.GetByUserID(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -43,11 +69,12 @@ func (s *categoryService) Create(ctx context.Context, userID uuid.UUID, input *m
 		UserID:    &userID,
 		Name:      input.Name,
 		Type:      input.Type,
-		Icon:      input.Icon,
-		Color:     input.Color,
+		Icon:      icon,
+		Color:     color,
 		ParentID:  input.ParentID,
 		IsSystem:  false,
 		SortOrder: maxSortOrder + 1, // следующий порядковый номер
+		IsFixed:   input.IsFixed && input.Type == models.CategoryTypeExpense,
 	}
 
 	if err := s.categoryRepo.Create(ctx, category); err != nil {
@@ -76,6 +103,21 @@ func (s *categoryService) GetByType(ctx context.Context, userID uuid.UUID, categ
 }
 
 func (s *categoryService) Update(ctx context.Context, id uuid.UUID, update *models.CategoryUpdate) (*models.Category, error) {
+	if update.Icon != nil {
+		icon, err := normalizeIcon(*update.Icon)
+		if err != nil {
+			return nil, err
+		}
+		update.Icon = &icon
+	}
+	if update.Color != nil {
+		color, err := normalizeColor(*update.Color)
+		if err != nil {
+			return nil, err
+		}
+		update.Color = &color
+	}
+
 	if err := s.categoryRepo.Update(ctx, id, update); err != nil {
 		return nil, err
 	}
@@ -86,6 +128,22 @@ func (s *categoryService) Delete(ctx context.Context, id uuid.UUID) error {
 	return s.categoryRepo.Delete(ctx, id)
 }
 
+func (s *categoryService) SetFixed(ctx context.Context, userID, id uuid.UUID, input *models.CategoryFixed) (*models.Category, error) {
+	category, err := s.categoryRepo.GetByID(ctx, id)
+	if err != nil || (!category.IsSystem && (category.UserID == nil || *category.UserID != userID)) {
+		return nil, ErrCategoryNotFound
+	}
+	if category.Type != models.CategoryTypeExpense {
+		return nil, ErrCategoryNotExpense
+	}
+
+	if err := s.categoryRepo.SetFixed(ctx, userID, id, *input.IsFixed); err != nil {
+		return nil, err
+	}
+	category.IsFixed = *input.IsFixed
+	return category, nil
+}
+
 func (s *categoryService) buildCategoryTree(categories []models.Category) []models.Category {
 	// создаем хэш-таблицу (мапу) чтобы время поиска О(1)
 	categoryMap := make(map[uuid.UUID]*models.Category)
@@ -107,3 +165,50 @@ func (s *categoryService) buildCategoryTree(categories []models.Category) []mode
 
 	return rootCategories
 }
+
+// normalizeIcon приводит иконку к NFC, чтобы одно и то же emoji не хранилось в разных формах.
+// пустая строка - без иконки. U+FFFD появляется, когда клиент прислал не UTF-8, - такую иконку не сохраняем
+func normalizeIcon(icon string) (string, error) {
+	icon = strings.TrimSpace(icon)
+	if icon == "" {
+		return "", nil
+	}
+	if !utf8.ValidString(icon) {
+		return "", ErrInvalidCategoryIcon
+	}
+	icon = norm.NFC.String(icon)
+	if utf8.RuneCountInString(icon) > maxCategoryIconLength {
+		return "", ErrInvalidCategoryIcon
+	}
+	for _, r := range icon {
+		if r == utf8.RuneError || unicode.IsControl(r) || unicode.IsSpace(r) {
+			return "", ErrInvalidCategoryIcon
+		}
+	}
+	return icon, nil
+}
+
+// normalizeColor принимает #RGB и #RRGGBB, хранит всегда #RRGGBB в верхнем регистре; пустая строка - без цвета
+func normalizeColor(color string) (string, error) {
+	color = strings.TrimSpace(color)
+	if color == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(color, "#") {
+		return "", ErrInvalidCategoryColor
+	}
+	hex := color[1:]
+	for _, r := range hex {
+		if !unicode.Is(unicode.ASCII_Hex_Digit, r) {
+			return "", ErrInvalidCategoryColor
+		}
+	}
+	switch len(hex) {
+	case 3:
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	case 6:
+	default:
+		return "", ErrInvalidCategoryColor
+	}
+	return "#" + strings.ToUpper(hex), nil
+}
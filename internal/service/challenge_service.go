@@ -0,0 +1,515 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrChallengeNotFound        = errors.New("challenge not found")
+	ErrInvalidChallengeAmount   = errors.New("spend_limit challenge requires a positive amount")
+	ErrInvalidChallengeWeekdays = errors.New("weekdays are only allowed for no_spend challenges")
+	ErrInvalidChallengeDates    = errors.New("challenge end_date must not be before start_date")
+)
+
+const (
+	// сколько прошедших периодов подводим за один проход задачи, остальные - в следующих
+	challengeCatchUpPeriods = 60
+	// сколько последних итогов показываем в карточке челленджа
+	challengeHistoryLimit = 12
+)
+
+type ChallengeService interface {
+	// List челленджи пользователя с прогрессом текущего периода
+	List(ctx context.Context, userID uuid.UUID) ([]models.Challenge, error)
+	// Get челлендж с прогрессом и итогами последних периодов
+	Get(ctx context.Context, userID, id uuid.UUID) (*models.Challenge, error)
+	Create(ctx context.Context, userID uuid.UUID, input *models.ChallengeCreate) (*models.Challenge, error)
+	Update(ctx context.Context, userID, id uuid.UUID, update *models.ChallengeUpdate) (*models.Challenge, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	// Evaluate подводит завершившиеся периоды всех активных челленджей, обновляет серии и уведомляет о выполнении
+	Evaluate(ctx context.Context) (*models.ChallengeEvaluateResult, error)
+}
+
+type challengeService struct {
+	txManager       repository.TxManager
+	challengeRepo   repository.ChallengeRepository
+	transactionRepo repository.TransactionRepository
+	accountRepo     repository.AccountRepository
+	categoryRepo    repository.CategoryRepository
+	payeeRepo       repository.PayeeRepository
+	userRepo        repository.UserRepository
+	notifications   NotificationService
+}
+
+func NewChallengeService(
+	txManager repository.TxManager,
+	challengeRepo repository.ChallengeRepository,
+	transactionRepo repository.TransactionRepository,
+	accountRepo repository.AccountRepository,
+	categoryRepo repository.CategoryRepository,
+	payeeRepo repository.PayeeRepository,
+	userRepo repository.UserRepository,
+	notifications NotificationService,
+) ChallengeService {
+	return &challengeService{
+		txManager:       txManager,
+		challengeRepo:   challengeRepo,
+		transactionRepo: transactionRepo,
+		accountRepo:     accountRepo,
+		categoryRepo:    categoryRepo,
+		payeeRepo:       payeeRepo,
+		userRepo:        userRepo,
+		notifications:   notifications,
+	}
+}
+
+func (s *challengeService) List(ctx context.Context, userID uuid.UUID) ([]models.Challenge, error) {
+	challenges, err := s.challengeRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if challenges == nil {
+		return []models.Challenge{}, nil
+	}
+
+	today := s.today(ctx, userID)
+	for i := range challenges {
+		if err := s.attachProgress(ctx, &challenges[i], today); err != nil {
+			return nil, err
+		}
+	}
+	return challenges, nil
+}
+
+func (s *challengeService) Get(ctx context.Context, userID, id uuid.UUID) (*models.Challenge, error) {
+	challenge, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachProgress(ctx, challenge, s.today(ctx, userID)); err != nil {
+		return nil, err
+	}
+	if challenge.History, err = s.challengeRepo.GetResults(ctx, id, challengeHistoryLimit); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (s *challengeService) Create(ctx context.Context, userID uuid.UUID, input *models.ChallengeCreate) (*models.Challenge, error) {
+	today := s.today(ctx, userID)
+	challenge := &models.Challenge{
+		UserID:     userID,
+		Name:       input.Name,
+		Kind:       input.Kind,
+		Period:     input.Period,
+		Amount:     input.Amount,
+		Weekdays:   normalizeWeekdays(input.Weekdays),
+		CategoryID: input.CategoryID,
+		AccountID:  input.AccountID,
+		PayeeID:    input.PayeeID,
+		StartDate:  today,
+		IsActive:   true,
+	}
+	if input.StartDate != nil {
+		challenge.StartDate = truncateDay(*input.StartDate)
+	}
+	if input.EndDate != nil {
+		end := truncateDay(*input.EndDate)
+		challenge.EndDate = &end
+	}
+	if err := s.validate(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	if err := s.challengeRepo.Create(ctx, challenge); err != nil {
+		return nil, err
+	}
+	if err := s.attachProgress(ctx, challenge, today); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (s *challengeService) Update(ctx context.Context, userID, id uuid.UUID, update *models.ChallengeUpdate) (*models.Challenge, error) {
+	challenge, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// проверяем челлендж целиком, как он будет после изменения
+	if update.Amount != nil {
+		challenge.Amount = update.Amount
+	}
+	if update.EndDate != nil {
+		end := truncateDay(*update.EndDate)
+		update.EndDate = &end
+		challenge.EndDate = &end
+	}
+	if err := s.validate(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	if err := s.challengeRepo.Update(ctx, id, update); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID, id)
+}
+
+func (s *challengeService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.get(ctx, userID, id); err != nil {
+		return err
+	}
+	deleted, err := s.challengeRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrChallengeNotFound
+	}
+	return nil
+}
+
+func (s *challengeService) Evaluate(ctx context.Context) (*models.ChallengeEvaluateResult, error) {
+	challenges, err := s.challengeRepo.GetActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ChallengeEvaluateResult{}
+	todays := make(map[uuid.UUID]time.Time)
+	for i := range challenges {
+		challenge := &challenges[i]
+		today, ok := todays[challenge.UserID]
+		if !ok {
+			today = s.today(ctx, challenge.UserID)
+			todays[challenge.UserID] = today
+		}
+		if err := s.evaluateChallenge(ctx, challenge, today, result); err != nil {
+			result.Errors++
+			log.Printf("Не удалось подвести челлендж %s: %v", challenge.ID, err)
+		}
+	}
+	return result, nil
+}
+
+// evaluateChallenge подводит по порядку все завершившиеся, но еще не подведенные периоды челленджа
+func (s *challengeService) evaluateChallenge(ctx context.Context, challenge *models.Challenge, today time.Time, result *models.ChallengeEvaluateResult) error {
+	start := challengePeriodStart(challenge.Period, challenge.StartDate)
+	if challenge.LastPeriod != nil {
+		start = nextChallengePeriod(challenge.Period, *challenge.LastPeriod)
+	}
+
+	for n := 0; n < challengeCatchUpPeriods; n++ {
+		if challenge.EndDate != nil && start.After(*challenge.EndDate) {
+			// все периоды подведены - челлендж завершен
+			inactive := false
+			return s.challengeRepo.Update(ctx, challenge.ID, &models.ChallengeUpdate{IsActive: &inactive})
+		}
+		end := challengePeriodEnd(challenge.Period, start)
+		if challenge.EndDate != nil && end.After(*challenge.EndDate) {
+			end = *challenge.EndDate
+		}
+		if !end.Before(today) {
+			return nil
+		}
+
+		progress, err := s.periodProgress(ctx, challenge, start, today)
+		if err != nil {
+			return err
+		}
+		if progress != nil {
+			claimed, err := s.recordPeriod(ctx, challenge, progress)
+			if err != nil {
+				return err
+			}
+			// период подвел параллельный проход, серию он же и обновил
+			if !claimed {
+				return nil
+			}
+			if progress.Status == models.ChallengeCompleted {
+				result.Completed++
+				s.notifyCompleted(ctx, challenge, progress)
+			} else {
+				result.Failed++
+			}
+		}
+		start = nextChallengePeriod(challenge.Period, start)
+	}
+	return nil
+}
+
+// recordPeriod сохраняет итог периода и серию; false - период уже подведен
+func (s *challengeService) recordPeriod(ctx context.Context, challenge *models.Challenge, progress *models.ChallengeProgress) (bool, error) {
+	current, best := challenge.CurrentStreak, challenge.BestStreak
+	if progress.Status == models.ChallengeCompleted {
+		current++
+		best = max(best, current)
+	} else {
+		current = 0
+	}
+
+	claimed := false
+	err := s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		var err error
+		claimed, err = s.challengeRepo.CreateResult(txCtx, &models.ChallengeResult{
+			ChallengeID: challenge.ID,
+			PeriodStart: progress.PeriodStart,
+			PeriodEnd:   progress.PeriodEnd,
+			Status:      progress.Status,
+			Spent:       progress.Spent,
+		})
+		if err != nil || !claimed {
+			return err
+		}
+		return s.challengeRepo.SetStreak(txCtx, challenge.ID, challengePeriodStart(challenge.Period, progress.PeriodStart), current, best)
+	})
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	challenge.CurrentStreak, challenge.BestStreak = current, best
+	return true, nil
+}
+
+func (s *challengeService) notifyCompleted(ctx context.Context, challenge *models.Challenge, progress *models.ChallengeProgress) {
+	body := fmt.Sprintf("Период %s - %s пройден. Серия: %d, лучшая: %d",
+		progress.PeriodStart.Format("02.01.2006"), progress.PeriodEnd.Format("02.01.2006"),
+		challenge.CurrentStreak, challenge.BestStreak)
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID:   challenge.UserID,
+		Type:     models.NotificationChallenge,
+		Title:    "Челлендж «" + challenge.Name + "» выполнен",
+		Body:     body,
+		EntityID: &challenge.ID,
+		DedupKey: "challenge:" + challenge.ID.String() + ":" + progress.PeriodStart.Format("2006-01-02"),
+	})
+}
+
+// attachProgress заполняет прогресс текущего периода; у не начавшегося или завершенного челленджа его нет
+func (s *challengeService) attachProgress(ctx context.Context, challenge *models.Challenge, today time.Time) error {
+	challenge.Progress = nil
+	if today.Before(challenge.StartDate) || (challenge.EndDate != nil && today.After(*challenge.EndDate)) {
+		return nil
+	}
+	progress, err := s.periodProgress(ctx, challenge, challengePeriodStart(challenge.Period, today), today)
+	if err != nil {
+		return err
+	}
+	challenge.Progress = progress
+	return nil
+}
+
+// periodProgress считает расходы периода, начинающегося в start, к дню today.
+// nil - в периоде нет дней, которые учитывает челлендж (например, выходные до его начала)
+func (s *challengeService) periodProgress(ctx context.Context, challenge *models.Challenge, start, today time.Time) (*models.ChallengeProgress, error) {
+	from, to := start, challengePeriodEnd(challenge.Period, start)
+	if from.Before(challenge.StartDate) {
+		from = challenge.StartDate
+	}
+	if challenge.EndDate != nil && to.After(*challenge.EndDate) {
+		to = *challenge.EndDate
+	}
+	if to.Before(from) {
+		return nil, nil
+	}
+
+	progress := &models.ChallengeProgress{PeriodStart: from, PeriodEnd: to, Status: models.ChallengeInProgress}
+	if !to.Before(today) {
+		progress.DaysLeft = int(to.Sub(today).Hours()/24) + 1
+	}
+
+	switch challenge.Kind {
+	case models.ChallengeSpendLimit:
+		// до сегодняшнего дня включительно: будущие операции (запланированные) в прогресс не попадают
+		spent, err := s.transactionRepo.GetExpenseSums(ctx, challenge.UserID, []models.BudgetSpendPeriod{
+			challengeSpendPeriod(challenge, from, minTime(to, today)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		progress.Spent = spent[0]
+		limit := decimal.Zero
+		if challenge.Amount != nil {
+			limit = *challenge.Amount
+		}
+		remaining := limit.Sub(progress.Spent)
+		progress.Remaining = &remaining
+		if limit.IsPositive() {
+			progress.Percent = progress.Spent.Div(limit).Mul(decimal.NewFromInt(100)).Round(2).InexactFloat64()
+		}
+		if progress.Spent.GreaterThan(limit) {
+			progress.Status = models.ChallengeFailed
+		}
+
+	case models.ChallengeNoSpend:
+		var days []time.Time
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			if challengeCountsDay(challenge, day) {
+				days = append(days, day)
+			}
+		}
+		if len(days) == 0 {
+			return nil, nil
+		}
+		progress.Days = len(days)
+
+		var periods []models.BudgetSpendPeriod
+		for _, day := range days {
+			if day.After(today) {
+				break
+			}
+			periods = append(periods, challengeSpendPeriod(challenge, day, day))
+		}
+		spent, err := s.transactionRepo.GetExpenseSums(ctx, challenge.UserID, periods)
+		if err != nil {
+			return nil, err
+		}
+		for i, sum := range spent {
+			progress.Spent = progress.Spent.Add(sum)
+			if sum.IsPositive() {
+				progress.Status = models.ChallengeFailed
+			} else if days[i].Before(today) {
+				progress.CleanDays++
+			}
+		}
+	}
+
+	if progress.Status == models.ChallengeInProgress && to.Before(today) {
+		progress.Status = models.ChallengeCompleted
+	}
+	return progress, nil
+}
+
+func (s *challengeService) get(ctx context.Context, userID, id uuid.UUID) (*models.Challenge, error) {
+	challenge, err := s.challengeRepo.GetByID(ctx, id)
+	if err != nil || challenge.UserID != userID {
+		return nil, ErrChallengeNotFound
+	}
+	return challenge, nil
+}
+
+// today текущий день в часовом поясе пользователя (как дата в UTC, как и даты операций)
+func (s *challengeService) today(ctx context.Context, userID uuid.UUID) time.Time {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return truncateDay(time.Now().UTC())
+	}
+	return truncateDay(localNow(user))
+}
+
+// validate проверяет условие челленджа и принадлежность фильтров пользователю
+func (s *challengeService) validate(ctx context.Context, challenge *models.Challenge) error {
+	switch challenge.Kind {
+	case models.ChallengeSpendLimit:
+		if challenge.Amount == nil || !challenge.Amount.IsPositive() {
+			return ErrInvalidChallengeAmount
+		}
+		if len(challenge.Weekdays) > 0 {
+			return ErrInvalidChallengeWeekdays
+		}
+	case models.ChallengeNoSpend:
+		// у no_spend лимита нет
+		challenge.Amount = nil
+	}
+	if challenge.EndDate != nil && challenge.EndDate.Before(challenge.StartDate) {
+		return ErrInvalidChallengeDates
+	}
+
+	if challenge.CategoryID != nil {
+		category, err := s.categoryRepo.GetByID(ctx, *challenge.CategoryID)
+		if err != nil || (!category.IsSystem && (category.UserID == nil || *category.UserID != challenge.UserID)) {
+			return ErrCategoryNotFound
+		}
+	}
+	if challenge.AccountID != nil {
+		account, err := s.accountRepo.GetByID(ctx, *challenge.AccountID)
+		if err != nil || account.UserID != challenge.UserID {
+			return ErrAccountNotFound
+		}
+	}
+	if challenge.PayeeID != nil {
+		payee, err := s.payeeRepo.GetByID(ctx, *challenge.PayeeID)
+		if err != nil || payee.UserID != challenge.UserID {
+			return ErrPayeeNotFound
+		}
+	}
+	return nil
+}
+
+// challengeSpendPeriod расходы с фильтрами челленджа за дни from..to
+func challengeSpendPeriod(challenge *models.Challenge, from, to time.Time) models.BudgetSpendPeriod {
+	return models.BudgetSpendPeriod{
+		Start:      from,
+		End:        to,
+		CategoryID: challenge.CategoryID,
+		AccountID:  challenge.AccountID,
+		PayeeID:    challenge.PayeeID,
+	}
+}
+
+// challengeCountsDay учитывается ли день в no_spend: без списка дней недели - каждый день
+func challengeCountsDay(challenge *models.Challenge, day time.Time) bool {
+	if len(challenge.Weekdays) == 0 {
+		return true
+	}
+	weekday := int(day.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	return slices.Contains(challenge.Weekdays, weekday)
+}
+
+// challengePeriodStart начало периода, в который попадает день
+func challengePeriodStart(period models.ChallengePeriod, day time.Time) time.Time {
+	day = truncateDay(day)
+	switch period {
+	case models.ChallengePeriodWeekly:
+		weekday := int(day.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		return day.AddDate(0, 0, -weekday+1)
+	case models.ChallengePeriodMonthly:
+		return monthStart(day)
+	}
+	return day
+}
+
+// challengePeriodEnd последний день периода, начинающегося в start
+func challengePeriodEnd(period models.ChallengePeriod, start time.Time) time.Time {
+	return nextChallengePeriod(period, start).AddDate(0, 0, -1)
+}
+
+func nextChallengePeriod(period models.ChallengePeriod, start time.Time) time.Time {
+	switch period {
+	case models.ChallengePeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case models.ChallengePeriodMonthly:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// normalizeWeekdays дни недели без повторов по порядку
+func normalizeWeekdays(weekdays []int) []int {
+	result := slices.Clone(weekdays)
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,467 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCSV         = errors.New("invalid CSV file")
+	ErrInvalidCSVMapping  = errors.New("invalid column mapping: amount columns do not match amount_sign or date_format is invalid")
+	ErrCSVMappingRequired = errors.New("profile_id or mapping is required")
+	ErrCSVAccountRequired = errors.New("account_id is required when the profile has no default account")
+	ErrCSVUploadNotFound  = errors.New("CSV upload not found")
+	ErrCSVProfileNotFound = errors.New("CSV import profile not found")
+)
+
+// загруженная выгрузка живет сутки: за это время пользователь настраивает сопоставление
+const csvUploadTTL = 24 * time.Hour
+
+// CSVImportService импорт выписок произвольных банков: загрузка, разбор колонок, профили сопоставления
+type CSVImportService interface {
+	// Upload сохраняет выгрузку и возвращает угаданные колонки
+	Upload(ctx context.Context, userID uuid.UUID, filename string, content []byte) (*models.CSVDetection, error)
+	// Detect колонки загруженной выгрузки; с профилем файл читается по его настройкам
+	Detect(ctx context.Context, userID, uploadID uuid.UUID, profileID *uuid.UUID) (*models.CSVDetection, error)
+	Import(ctx context.Context, userID, uploadID uuid.UUID, input *models.CSVImportRequest) (*models.CSVImportResult, error)
+
+	CreateProfile(ctx context.Context, userID uuid.UUID, input *models.CSVImportProfileCreate) (*models.CSVImportProfile, error)
+	GetProfiles(ctx context.Context, userID uuid.UUID) ([]models.CSVImportProfile, error)
+	UpdateProfile(ctx context.Context, userID, id uuid.UUID, update *models.CSVImportProfileUpdate) (*models.CSVImportProfile, error)
+	DeleteProfile(ctx context.Context, userID, id uuid.UUID) error
+
+	// PurgeUploads удаляет выгрузки старше суток
+	PurgeUploads(ctx context.Context) (int64, error)
+}
+
+type csvImportService struct {
+	txManager          repository.TxManager
+	csvRepo            repository.CSVImportRepository
+	accountRepo        repository.AccountRepository
+	categoryRepo       repository.CategoryRepository
+	transactionRepo    repository.TransactionRepository
+	transactionService TransactionService
+}
+
+func NewCSVImportService(txManager repository.TxManager, csvRepo repository.CSVImportRepository, accountRepo repository.AccountRepository, categoryRepo repository.CategoryRepository, transactionRepo repository.TransactionRepository, transactionService TransactionService) CSVImportService {
+	return &csvImportService{
+		txManager:          txManager,
+		csvRepo:            csvRepo,
+		accountRepo:        accountRepo,
+		categoryRepo:       categoryRepo,
+		transactionRepo:    transactionRepo,
+		transactionService: transactionService,
+	}
+}
+
+func (s *csvImportService) Upload(ctx context.Context, userID uuid.UUID, filename string, content []byte) (*models.CSVDetection, error) {
+	// сразу проверяем, что файл читается, чтобы не хранить мусор
+	table, err := parseCSVTable(content, &models.CSVMapping{})
+	if err != nil {
+		return nil, err
+	}
+	if len(table.records)-1 > maxBatchTransactions {
+		return nil, ErrBatchTooLarge
+	}
+
+	if name := []rune(filename); len(name) > 255 {
+		filename = string(name[:255])
+	}
+	upload := &models.CSVUpload{UserID: userID, Filename: filename, Content: content}
+	if err := s.csvRepo.CreateUpload(ctx, upload); err != nil {
+		return nil, err
+	}
+	return s.detect(ctx, upload, table, nil)
+}
+
+func (s *csvImportService) Detect(ctx context.Context, userID, uploadID uuid.UUID, profileID *uuid.UUID) (*models.CSVDetection, error) {
+	upload, err := s.getUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	mapping := &models.CSVMapping{}
+	var profile *models.CSVImportProfile
+	if profileID != nil {
+		if profile, err = s.getProfile(ctx, userID, *profileID); err != nil {
+			return nil, err
+		}
+		mapping = &profile.Mapping
+	}
+
+	table, err := parseCSVTable(upload.Content, mapping)
+	if err != nil {
+		return nil, err
+	}
+	return s.detect(ctx, upload, table, profile)
+}
+
+// detect колонки и сопоставление: профиль, если задан или подходит по заголовку, иначе догадка
+func (s *csvImportService) detect(ctx context.Context, upload *models.CSVUpload, table *csvTable, profile *models.CSVImportProfile) (*models.CSVDetection, error) {
+	columns, suggested := detectCSV(table)
+
+	if profile == nil && suggested.HasHeader {
+		profiles, err := s.csvRepo.GetProfilesByUserID(ctx, upload.UserID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range profiles {
+			if slices.Equal(profiles[i].Mapping.Header, suggested.Header) {
+				profile = &profiles[i]
+				break
+			}
+		}
+	}
+
+	detection := &models.CSVDetection{
+		UploadID:  upload.ID,
+		Filename:  upload.Filename,
+		Columns:   columns,
+		Suggested: suggested,
+	}
+	if profile != nil {
+		detection.Suggested, detection.ProfileID = profile.Mapping, &profile.ID
+	}
+	detection.Rows = len(table.records) - min(detection.Suggested.SkipRows, len(table.records))
+	if detection.Suggested.HasHeader && detection.Rows > 0 {
+		detection.Rows--
+	}
+	return detection, nil
+}
+
+func (s *csvImportService) Import(ctx context.Context, userID, uploadID uuid.UUID, input *models.CSVImportRequest) (*models.CSVImportResult, error) {
+	upload, err := s.getUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	// настройки профиля, поверх - явно переданные в запросе
+	var profile *models.CSVImportProfile
+	var mapping *models.CSVMapping
+	var accountID, categoryID, incomeCategoryID *uuid.UUID
+	if input.ProfileID != nil {
+		if profile, err = s.getProfile(ctx, userID, *input.ProfileID); err != nil {
+			return nil, err
+		}
+		mapping, accountID, categoryID, incomeCategoryID = &profile.Mapping, profile.AccountID, &profile.CategoryID, profile.IncomeCategoryID
+	}
+	if input.Mapping != nil {
+		mapping = input.Mapping
+	}
+	if input.AccountID != nil {
+		accountID = input.AccountID
+	}
+	if input.CategoryID != nil {
+		categoryID = input.CategoryID
+	}
+	if input.IncomeCategoryID != nil {
+		incomeCategoryID = input.IncomeCategoryID
+	}
+	if mapping == nil || categoryID == nil {
+		return nil, ErrCSVMappingRequired
+	}
+	if accountID == nil {
+		return nil, ErrCSVAccountRequired
+	}
+	if incomeCategoryID == nil {
+		incomeCategoryID = categoryID
+	}
+
+	if err := validateCSVMapping(mapping); err != nil {
+		return nil, err
+	}
+	if err := s.checkAccount(ctx, userID, *accountID); err != nil {
+		return nil, err
+	}
+	for _, id := range []uuid.UUID{*categoryID, *incomeCategoryID} {
+		if err := s.checkCategory(ctx, userID, id); err != nil {
+			return nil, err
+		}
+	}
+
+	table, err := parseCSVTable(upload.Content, mapping)
+	if err != nil {
+		return nil, err
+	}
+	records := applyCSVMapping(table, mapping)
+	if len(records) > maxBatchTransactions {
+		return nil, ErrBatchTooLarge
+	}
+
+	categories, err := s.categoriesByName(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	seen, err := s.existingKeys(ctx, userID, *accountID, records)
+	if err != nil {
+		return nil, err
+	}
+
+	// сначала проверяем все строки: при ошибках и в dry_run ничего не создается
+	result := &models.CSVImportResult{DryRun: input.DryRun, Total: len(records), Rows: make([]models.CSVImportRow, 0, len(records))}
+	inputs := make(map[int]*models.TransactionCreate)
+	for i, record := range records {
+		row := models.CSVImportRow{Row: record.line, Description: record.description}
+		if record.err != nil {
+			row.Status, row.Error = models.CSVImportRowInvalid, record.err.Error()
+			result.Invalid++
+			result.Rows = append(result.Rows, row)
+			continue
+		}
+		date, amount := record.date, record.amount
+		row.Date, row.Type, row.Amount = &date, record.txType, &amount
+
+		key := csvDuplicateKey(record.date, record.txType, record.amount, record.description)
+		if seen[key] > 0 {
+			seen[key]--
+			row.Status = models.CSVImportRowDuplicate
+			result.Duplicates++
+			result.Rows = append(result.Rows, row)
+			continue
+		}
+
+		tx := &models.TransactionCreate{
+			AccountID:   *accountID,
+			CategoryID:  *categoryID,
+			Type:        record.txType,
+			Amount:      record.amount,
+			Description: record.description,
+			Date:        record.date,
+		}
+		if record.txType == models.TransactionTypeIncome {
+			tx.CategoryID = *incomeCategoryID
+		}
+		if id, ok := categories[csvCategoryKey(record.category, record.txType)]; ok {
+			tx.CategoryID = id
+		}
+		inputs[i] = tx
+		row.Status = models.CSVImportRowReady
+		result.Rows = append(result.Rows, row)
+	}
+
+	if !input.DryRun && result.Invalid == 0 && len(inputs) > 0 {
+		err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+			for i := range result.Rows {
+				tx, ok := inputs[i]
+				if !ok {
+					continue
+				}
+				created, err := s.transactionService.Create(txCtx, userID, tx)
+				if err != nil {
+					return err
+				}
+				result.Rows[i].Status, result.Rows[i].Transaction = models.CSVImportRowCreated, created
+				result.Created++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if input.DryRun || result.Invalid > 0 {
+		return result, nil
+	}
+
+	if profile != nil {
+		_ = s.csvRepo.MarkProfileUsed(ctx, profile.ID)
+	}
+	if name := strings.TrimSpace(input.SaveAs); name != "" {
+		saved := *mapping
+		if saved.HasHeader {
+			skip := min(saved.SkipRows, len(table.records)-1)
+			saved.Header = normalizeCSVHeader(table.records[skip])
+		}
+		result.Profile = &models.CSVImportProfile{
+			UserID:           userID,
+			Name:             name,
+			AccountID:        accountID,
+			CategoryID:       *categoryID,
+			IncomeCategoryID: incomeCategoryID,
+			Mapping:          saved,
+		}
+		if err := s.csvRepo.CreateProfile(ctx, result.Profile); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// existingKeys сколько раз каждая операция уже есть на счете за даты выгрузки
+func (s *csvImportService) existingKeys(ctx context.Context, userID, accountID uuid.UUID, records []csvRecord) (map[string]int, error) {
+	keys := make(map[string]int)
+	from, to, ok := csvDateRange(records)
+	if !ok {
+		return keys, nil
+	}
+	existing, err := s.transactionRepo.GetByDateRange(ctx, userID, from, to, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range existing {
+		if tx.AccountID == accountID {
+			keys[csvDuplicateKey(tx.Date, tx.Type, tx.Amount, tx.Description)]++
+		}
+	}
+	return keys, nil
+}
+
+func csvCategoryKey(name string, txType models.TransactionType) string {
+	return string(txType) + "|" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// categoriesByName категории пользователя для колонки категории выгрузки
+func (s *csvImportService) categoriesByName(ctx context.Context, userID uuid.UUID) (map[string]uuid.UUID, error) {
+	categories, err := s.categoryRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]uuid.UUID, len(categories))
+	for _, category := range categories {
+		var txType models.TransactionType
+		switch category.Type {
+		case models.CategoryTypeExpense:
+			txType = models.TransactionTypeExpense
+		case models.CategoryTypeIncome:
+			txType = models.TransactionTypeIncome
+		default:
+			continue
+		}
+		// своя категория важнее системной с тем же названием
+		key := csvCategoryKey(category.Name, txType)
+		if _, ok := byName[key]; !ok || !category.IsSystem {
+			byName[key] = category.ID
+		}
+	}
+	return byName, nil
+}
+
+func (s *csvImportService) CreateProfile(ctx context.Context, userID uuid.UUID, input *models.CSVImportProfileCreate) (*models.CSVImportProfile, error) {
+	profile := &models.CSVImportProfile{
+		UserID:           userID,
+		Name:             strings.TrimSpace(input.Name),
+		AccountID:        input.AccountID,
+		CategoryID:       input.CategoryID,
+		IncomeCategoryID: input.IncomeCategoryID,
+		Mapping:          input.Mapping,
+	}
+	if err := s.validateProfile(ctx, userID, profile); err != nil {
+		return nil, err
+	}
+	if err := s.csvRepo.CreateProfile(ctx, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (s *csvImportService) GetProfiles(ctx context.Context, userID uuid.UUID) ([]models.CSVImportProfile, error) {
+	return s.csvRepo.GetProfilesByUserID(ctx, userID)
+}
+
+func (s *csvImportService) UpdateProfile(ctx context.Context, userID, id uuid.UUID, update *models.CSVImportProfileUpdate) (*models.CSVImportProfile, error) {
+	profile, err := s.getProfile(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if update.Name != nil {
+		profile.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.AccountID != nil {
+		profile.AccountID = update.AccountID
+	}
+	if update.CategoryID != nil {
+		profile.CategoryID = *update.CategoryID
+	}
+	if update.IncomeCategoryID != nil {
+		profile.IncomeCategoryID = update.IncomeCategoryID
+	}
+	if update.Mapping != nil {
+		// заголовок, по которому узнается выгрузка, сохраняем, если в новом сопоставлении его нет
+		header := profile.Mapping.Header
+		profile.Mapping = *update.Mapping
+		if profile.Mapping.Header == nil {
+			profile.Mapping.Header = header
+		}
+	}
+	if err := s.validateProfile(ctx, userID, profile); err != nil {
+		return nil, err
+	}
+	if err := s.csvRepo.UpdateProfile(ctx, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (s *csvImportService) DeleteProfile(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.getProfile(ctx, userID, id); err != nil {
+		return err
+	}
+	deleted, err := s.csvRepo.DeleteProfile(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCSVProfileNotFound
+	}
+	return nil
+}
+
+func (s *csvImportService) PurgeUploads(ctx context.Context) (int64, error) {
+	return s.csvRepo.DeleteUploadsBefore(ctx, time.Now().Add(-csvUploadTTL))
+}
+
+func (s *csvImportService) validateProfile(ctx context.Context, userID uuid.UUID, profile *models.CSVImportProfile) error {
+	if err := validateCSVMapping(&profile.Mapping); err != nil {
+		return err
+	}
+	if profile.AccountID != nil {
+		if err := s.checkAccount(ctx, userID, *profile.AccountID); err != nil {
+			return err
+		}
+	}
+	if err := s.checkCategory(ctx, userID, profile.CategoryID); err != nil {
+		return err
+	}
+	if profile.IncomeCategoryID != nil {
+		return s.checkCategory(ctx, userID, *profile.IncomeCategoryID)
+	}
+	return nil
+}
+
+func (s *csvImportService) getUpload(ctx context.Context, userID, id uuid.UUID) (*models.CSVUpload, error) {
+	upload, err := s.csvRepo.GetUploadByID(ctx, id)
+	if err != nil || upload.UserID != userID {
+		return nil, ErrCSVUploadNotFound
+	}
+	return upload, nil
+}
+
+func (s *csvImportService) getProfile(ctx context.Context, userID, id uuid.UUID) (*models.CSVImportProfile, error) {
+	profile, err := s.csvRepo.GetProfileByID(ctx, id)
+	if err != nil || profile.UserID != userID {
+		return nil, ErrCSVProfileNotFound
+	}
+	return profile, nil
+}
+
+func (s *csvImportService) checkAccount(ctx context.Context, userID, accountID uuid.UUID) error {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil || account.UserID != userID {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+func (s *csvImportService) checkCategory(ctx context.Context, userID, categoryID uuid.UUID) error {
+	category, err := s.categoryRepo.GetByID(ctx, categoryID)
+	if err != nil || (!category.IsSystem && (category.UserID == nil || *category.UserID != userID)) {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,558 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	csvEncodingUTF8    = "utf-8"
+	csvEncodingCP1251  = "windows-1251"
+	csvDetectRows      = 20 // по скольким строкам угадываются разделитель и колонки
+	csvSampleValues    = 5
+	csvColumnDate      = "date"
+	csvColumnAmount    = "amount"
+	csvColumnText      = "text"
+	defaultCSVDecimals = "."
+)
+
+// csvDelimiters в порядке предпочтения при равенстве
+var csvDelimiters = []rune{';', ',', '\t', '|'}
+
+// csvDateFormats форматы дат, которые пробуются при угадывании
+var csvDateFormats = []string{
+	"DD.MM.YYYY", "DD.MM.YYYY HH:mm:ss", "DD.MM.YYYY HH:mm", "DD.MM.YY",
+	"YYYY-MM-DD", "YYYY-MM-DD HH:mm:ss", "YYYY-MM-DDTHH:mm:ss", "YYYY-MM-DD HH:mm",
+	"DD/MM/YYYY", "MM/DD/YYYY", "DD-MM-YYYY", "YYYY.MM.DD",
+}
+
+var csvDateTokens = strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02", "HH", "15", "mm", "04", "ss", "05")
+
+// ключевые слова в заголовках банковских выгрузок
+var (
+	csvDebitHeaders       = []string{"расход", "списан", "дебет", "debit", "withdraw", "outflow"}
+	csvCreditHeaders      = []string{"приход", "поступ", "зачисл", "кредит", "credit", "deposit", "inflow"}
+	csvAmountHeaders      = []string{"сумма операции", "сумма платежа", "сумма", "amount", "sum"}
+	csvDescriptionHeaders = []string{"описание", "назначение", "контрагент", "получатель", "description", "details", "memo", "payee"}
+	csvCategoryHeaders    = []string{"категория", "category"}
+)
+
+// csvTable разобранная выгрузка: записи и номера их строк в файле
+type csvTable struct {
+	encoding  string
+	delimiter rune
+	records   [][]string
+	lines     []int
+}
+
+// csvRecord строка выгрузки после применения сопоставления
+type csvRecord struct {
+	line        int
+	date        time.Time
+	txType      models.TransactionType
+	amount      decimal.Decimal
+	description string
+	category    string
+	err         error
+}
+
+// decodeCSV текст выгрузки в UTF-8; без явной кодировки невалидный UTF-8 считается windows-1251
+func decodeCSV(content []byte, encoding string) (string, string, error) {
+	content = []byte(strings.TrimPrefix(string(content), "\ufeff"))
+	if encoding == "" {
+		encoding = csvEncodingUTF8
+		if !utf8.Valid(content) {
+			encoding = csvEncodingCP1251
+		}
+	}
+	if encoding == csvEncodingCP1251 {
+		decoded, err := charmap.Windows1251.NewDecoder().Bytes(content)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+		}
+		return string(decoded), encoding, nil
+	}
+	return string(content), encoding, nil
+}
+
+// readCSV разбирает текст с разделителем; пустые строки пропускаются
+func readCSV(text string, delimiter rune, limit int) ([][]string, []int, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	var records [][]string
+	var lines []int
+	for limit <= 0 || len(records) < limit {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	return records, lines, nil
+}
+
+// csvWidth самое частое число колонок и индекс первой строки такой ширины
+func csvWidth(records [][]string) (int, int) {
+	counts := make(map[int]int)
+	for _, record := range records {
+		counts[len(record)]++
+	}
+	width := 0
+	for w, n := range counts {
+		if n > counts[width] || (n == counts[width] && w > width) {
+			width = w
+		}
+	}
+	for i, record := range records {
+		if len(record) == width {
+			return width, i
+		}
+	}
+	return width, 0
+}
+
+// detectDelimiter разделитель, при котором больше всего строк одинаковой ширины больше одной колонки
+func detectDelimiter(text string) rune {
+	best, bestScore := csvDelimiters[0], -1
+	for _, delimiter := range csvDelimiters {
+		records, _, err := readCSV(text, delimiter, csvDetectRows)
+		if err != nil {
+			continue
+		}
+		width, _ := csvWidth(records)
+		if width < 2 {
+			continue
+		}
+		score := 0
+		for _, record := range records {
+			if len(record) == width {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = delimiter, score
+		}
+	}
+	return best
+}
+
+// parseCSVTable декодирует выгрузку и режет на записи: явные настройки сопоставления или угаданные
+func parseCSVTable(content []byte, mapping *models.CSVMapping) (*csvTable, error) {
+	text, encoding, err := decodeCSV(content, mapping.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	delimiter := detectDelimiter(text)
+	if mapping.Delimiter != "" {
+		delimiter, _ = utf8.DecodeRuneInString(mapping.Delimiter)
+	}
+
+	records, lines, err := readCSV(text, delimiter, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidCSV)
+	}
+	return &csvTable{encoding: encoding, delimiter: delimiter, records: records, lines: lines}, nil
+}
+
+func csvDateLayout(format string) string {
+	if strings.Contains(format, "2006") {
+		return format
+	}
+	return csvDateTokens.Replace(format)
+}
+
+// parseCSVDate дата операции без времени: время в выгрузках местное, а в бд хранится только день
+func parseCSVDate(value, format string) (time.Time, error) {
+	t, err := time.Parse(csvDateLayout(format), strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// detectDateFormat первый формат, которым читаются все значения
+func detectDateFormat(values []string) string {
+	for _, format := range csvDateFormats {
+		ok := len(values) > 0
+		for _, v := range values {
+			if _, err := parseCSVDate(v, format); err != nil {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return format
+		}
+	}
+	return ""
+}
+
+// isCSVNumber значение похоже на сумму: цифры, знаки, разделители, скобки и валюта в конце
+func isCSVNumber(value string) bool {
+	digits := 0
+	for _, r := range strings.TrimSpace(value) {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+		case strings.ContainsRune(" \u00a0\u202f.,+-\u2212()'\u20bd$\u20ac", r):
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// parseCSVAmount сумма вида "-1 250,50", "(1,250.50)" или "1250.50 ₽"; пустая строка - ноль
+func parseCSVAmount(value, decimalSeparator string) (decimal.Decimal, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '-' || r == '\u2212':
+			negative = !negative
+		case r == ',' && decimalSeparator == ",", r == '.' && decimalSeparator != ",":
+			b.WriteRune('.')
+		}
+	}
+	if b.Len() == 0 {
+		return decimal.Zero, nil
+	}
+
+	amount, err := decimal.NewFromString(b.String())
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		amount = amount.Neg()
+	}
+	return amount, nil
+}
+
+// detectDecimalSeparator запятая, если в суммах копейки чаще отделены ею
+func detectDecimalSeparator(values []string) string {
+	comma, dot := 0, 0
+	for _, v := range values {
+		v = strings.TrimRight(strings.TrimSpace(v), " \u20bd$\u20ac)")
+		if i := strings.LastIndexAny(v, ".,"); i >= 0 && len(v)-i-1 <= 2 && len(v)-i-1 > 0 {
+			if v[i] == ',' {
+				comma++
+			} else {
+				dot++
+			}
+		}
+	}
+	if comma > dot {
+		return ","
+	}
+	return defaultCSVDecimals
+}
+
+// columnValues непустые значения колонки в записях
+func columnValues(records [][]string, index int) []string {
+	var values []string
+	for _, record := range records {
+		if index < len(record) {
+			if v := strings.TrimSpace(record[index]); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// columnKind date, amount или text по значениям колонки
+func columnKind(values []string) string {
+	if len(values) == 0 {
+		return csvColumnText
+	}
+	if detectDateFormat(values) != "" {
+		return csvColumnDate
+	}
+	for _, v := range values {
+		if !isCSVNumber(v) {
+			return csvColumnText
+		}
+	}
+	return csvColumnAmount
+}
+
+// looksLikeHeader в первой строке нет ни дат, ни сумм, а во второй есть
+func looksLikeHeader(records [][]string) bool {
+	if len(records) < 2 {
+		return false
+	}
+	typed := func(record []string) bool {
+		for _, v := range record {
+			if v = strings.TrimSpace(v); v != "" && columnKind([]string{v}) != csvColumnText {
+				return true
+			}
+		}
+		return false
+	}
+	return !typed(records[0]) && typed(records[1])
+}
+
+func matchHeader(name string, keywords []string) bool {
+	name = strings.ToLower(name)
+	for _, keyword := range keywords {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeCSVHeader названия колонок для сравнения выгрузки с профилем
+func normalizeCSVHeader(record []string) []string {
+	header := make([]string, len(record))
+	for i, name := range record {
+		header[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+	return header
+}
+
+// detectCSV колонки выгрузки и предлагаемое сопоставление
+func detectCSV(table *csvTable) ([]models.CSVColumn, models.CSVMapping) {
+	width, skip := csvWidth(table.records)
+	records := table.records[skip:]
+
+	mapping := models.CSVMapping{
+		Encoding:         table.encoding,
+		Delimiter:        string(table.delimiter),
+		SkipRows:         skip,
+		HasHeader:        looksLikeHeader(records),
+		AmountSign:       models.CSVAmountNegativeExpense,
+		DecimalSeparator: defaultCSVDecimals,
+	}
+	var header []string
+	if mapping.HasHeader {
+		header = normalizeCSVHeader(records[0])
+		mapping.Header = header
+		records = records[1:]
+	}
+
+	columns := make([]models.CSVColumn, width)
+	var amountValues []string
+	dateFound := false
+	var amounts, texts []int
+	for i := range columns {
+		values := columnValues(records, i)
+		column := models.CSVColumn{Index: i, Name: fmt.Sprintf("Колонка %d", i+1), Kind: columnKind(values), Samples: []string{}}
+		if i < len(header) && header[i] != "" {
+			column.Name = strings.TrimSpace(table.records[skip][i])
+		}
+		column.Samples = append(column.Samples, values[:min(len(values), csvSampleValues)]...)
+		columns[i] = column
+
+		switch column.Kind {
+		case csvColumnDate:
+			if !dateFound {
+				mapping.DateColumn, mapping.DateFormat, dateFound = i, detectDateFormat(values), true
+			}
+		case csvColumnAmount:
+			amounts = append(amounts, i)
+			amountValues = append(amountValues, values...)
+		case csvColumnText:
+			texts = append(texts, i)
+		}
+	}
+	mapping.DecimalSeparator = detectDecimalSeparator(amountValues)
+
+	// суммы: отдельные колонки расхода и прихода или одна колонка со знаком
+	var debit, credit, amount *int
+	for _, i := range amounts {
+		index := i
+		switch {
+		case debit == nil && matchHeader(columns[i].Name, csvDebitHeaders):
+			debit = &index
+		case credit == nil && matchHeader(columns[i].Name, csvCreditHeaders):
+			credit = &index
+		case amount == nil && matchHeader(columns[i].Name, csvAmountHeaders):
+			amount = &index
+		}
+	}
+	switch {
+	case debit != nil && credit != nil:
+		mapping.AmountSign, mapping.DebitColumn, mapping.CreditColumn = models.CSVAmountDebitCredit, debit, credit
+	case amount != nil:
+		mapping.AmountColumn = amount
+	case len(amounts) > 0:
+		mapping.AmountColumn = &amounts[0]
+	}
+
+	// описание: по заголовку, иначе текстовая колонка с самыми длинными значениями
+	bestLength := 0
+	for _, i := range texts {
+		index := i
+		if matchHeader(columns[i].Name, csvCategoryHeaders) {
+			if mapping.CategoryColumn == nil {
+				mapping.CategoryColumn = &index
+			}
+			continue
+		}
+		if matchHeader(columns[i].Name, csvDescriptionHeaders) {
+			mapping.DescriptionColumn, bestLength = &index, int(^uint(0)>>1)
+			continue
+		}
+		length := 0
+		for _, v := range columns[i].Samples {
+			length += utf8.RuneCountInString(v)
+		}
+		if length > bestLength {
+			mapping.DescriptionColumn, bestLength = &index, length
+		}
+	}
+	return columns, mapping
+}
+
+// validateCSVMapping колонки сумм заданы под выбранный способ
+func validateCSVMapping(mapping *models.CSVMapping) error {
+	switch mapping.AmountSign {
+	case models.CSVAmountDebitCredit:
+		if mapping.DebitColumn == nil || mapping.CreditColumn == nil {
+			return ErrInvalidCSVMapping
+		}
+	case models.CSVAmountNegativeExpense, models.CSVAmountPositiveExpense:
+		if mapping.AmountColumn == nil {
+			return ErrInvalidCSVMapping
+		}
+	default:
+		return ErrInvalidCSVMapping
+	}
+	if mapping.DateFormat != "" {
+		if _, err := time.Parse(csvDateLayout(mapping.DateFormat), time.Now().Format(csvDateLayout(mapping.DateFormat))); err != nil {
+			return ErrInvalidCSVMapping
+		}
+	}
+	return nil
+}
+
+// applyCSVMapping записи выгрузки по сопоставлению; ошибка строки - в err записи
+func applyCSVMapping(table *csvTable, mapping *models.CSVMapping) []csvRecord {
+	records, lines := table.records, table.lines
+	skip := min(mapping.SkipRows, len(records))
+	records, lines = records[skip:], lines[skip:]
+	if mapping.HasHeader && len(records) > 0 {
+		records, lines = records[1:], lines[1:]
+	}
+
+	dateFormat := mapping.DateFormat
+	if dateFormat == "" {
+		dateFormat = detectDateFormat(columnValues(records[:min(len(records), csvDetectRows)], mapping.DateColumn))
+	}
+
+	result := make([]csvRecord, 0, len(records))
+	for n, record := range records {
+		row := csvRecord{line: lines[n]}
+		field := func(index *int) string {
+			if index != nil && *index < len(record) {
+				return strings.TrimSpace(record[*index])
+			}
+			return ""
+		}
+		row.description = field(mapping.DescriptionColumn)
+		row.category = field(mapping.CategoryColumn)
+
+		dateColumn := mapping.DateColumn
+		date, err := parseCSVDate(field(&dateColumn), dateFormat)
+		if err != nil {
+			row.err = fmt.Errorf("invalid date %q", field(&dateColumn))
+			result = append(result, row)
+			continue
+		}
+		row.date = date
+
+		row.amount, row.txType, row.err = csvRecordAmount(mapping, field)
+		result = append(result, row)
+	}
+	return result
+}
+
+// csvRecordAmount сумма и тип операции строки по способу записи знака
+func csvRecordAmount(mapping *models.CSVMapping, field func(*int) string) (decimal.Decimal, models.TransactionType, error) {
+	if mapping.AmountSign == models.CSVAmountDebitCredit {
+		debit, err := parseCSVAmount(field(mapping.DebitColumn), mapping.DecimalSeparator)
+		if err != nil {
+			return decimal.Zero, "", err
+		}
+		credit, err := parseCSVAmount(field(mapping.CreditColumn), mapping.DecimalSeparator)
+		if err != nil {
+			return decimal.Zero, "", err
+		}
+		switch {
+		case !debit.IsZero() && !credit.IsZero():
+			return decimal.Zero, "", fmt.Errorf("both debit and credit are set")
+		case !debit.IsZero():
+			return debit.Abs(), models.TransactionTypeExpense, nil
+		case !credit.IsZero():
+			return credit.Abs(), models.TransactionTypeIncome, nil
+		}
+		return decimal.Zero, "", fmt.Errorf("amount is empty")
+	}
+
+	amount, err := parseCSVAmount(field(mapping.AmountColumn), mapping.DecimalSeparator)
+	if err != nil {
+		return decimal.Zero, "", err
+	}
+	if amount.IsZero() {
+		return decimal.Zero, "", fmt.Errorf("amount is empty")
+	}
+	expense := amount.IsNegative()
+	if mapping.AmountSign == models.CSVAmountPositiveExpense {
+		expense = !expense
+	}
+	if expense {
+		return amount.Abs(), models.TransactionTypeExpense, nil
+	}
+	return amount.Abs(), models.TransactionTypeIncome, nil
+}
+
+// csvDuplicateKey операция с той же датой, типом, суммой и описанием считается уже загруженной
+func csvDuplicateKey(date time.Time, txType models.TransactionType, amount decimal.Decimal, description string) string {
+	return date.Format("2006-01-02") + "|" + string(txType) + "|" + amount.StringFixed(2) + "|" + strings.ToLower(strings.TrimSpace(description))
+}
+
+// csvDateRange границы дат строк для поиска уже загруженных операций
+func csvDateRange(rows []csvRecord) (from, to time.Time, ok bool) {
+	for _, row := range rows {
+		if row.err != nil {
+			continue
+		}
+		if !ok || row.date.Before(from) {
+			from = row.date
+		}
+		if !ok || row.date.After(to) {
+			to = row.date
+		}
+		ok = true
+	}
+	return from, to, ok
+}
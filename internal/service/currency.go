@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// currencyConverter переводит суммы в валюту отчета, курсы кешируются на время одного запроса
+type currencyConverter struct {
+	provider   *market.MultiProvider
+	target     string
+	rates      map[string]decimal.Decimal
+	historical map[string]decimal.Decimal // "валюта|день" -> курс на этот день
+}
+
+func newCurrencyConverter(provider *market.MultiProvider, target string) *currencyConverter {
+	return &currencyConverter{
+		provider:   provider,
+		target:     strings.ToUpper(target),
+		rates:      make(map[string]decimal.Decimal),
+		historical: make(map[string]decimal.Decimal),
+	}
+}
+
+func (c *currencyConverter) convert(ctx context.Context, amount decimal.Decimal, from string) (decimal.Decimal, error) {
+	return c.convertAt(ctx, amount, from, time.Time{})
+}
+
+// convertAt как convert, но для суммы от даты date: учитывает деноминации, прошедшие после нее
+func (c *currencyConverter) convertAt(ctx context.Context, amount decimal.Decimal, from string, date time.Time) (decimal.Decimal, error) {
+	amount, from = redenominate(amount, strings.ToUpper(from), date)
+	if from == "" || from == c.target || amount.IsZero() {
+		return amount, nil
+	}
+
+	rate, ok := c.rates[from]
+	if !ok {
+		var err error
+		rate, err = c.provider.GetCurrencyRate(ctx, from, c.target)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("no exchange rate for %s/%s: %w", from, c.target, err)
+		}
+		c.rates[from] = rate
+	}
+	return amount.Mul(rate), nil
+}
+
+// rateOn курс валюты from к валюте converter'а на день date, а не на сегодня; деноминации после
+// этой даты входят в курс, так что сумма умножается на него как есть
+func (c *currencyConverter) rateOn(ctx context.Context, from string, date time.Time) (decimal.Decimal, error) {
+	factor, current := redenominate(decimal.NewFromInt(1), strings.ToUpper(from), date)
+	if current == "" || current == c.target {
+		return factor, nil
+	}
+
+	key := current + "|" + date.Format("2006-01-02")
+	rate, ok := c.historical[key]
+	if !ok {
+		var err error
+		rate, err = c.provider.GetHistoricalCurrencyRate(ctx, current, c.target, date)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("no exchange rate for %s/%s on %s: %w", current, c.target, date.Format("2006-01-02"), err)
+		}
+		c.historical[key] = rate
+	}
+	return factor.Mul(rate), nil
+}
+
+// деноминации и замены токенов по возрастанию даты, общие для всех конвертеров процесса;
+// заполняются CurrencyService.Load
+var redenominations atomic.Pointer[[]models.CurrencyRedenomination]
+
+func setRedenominations(list []models.CurrencyRedenomination) {
+	sorted := slices.Clone(list)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].EffectiveDate.Before(sorted[j].EffectiveDate)
+	})
+	redenominations.Store(&sorted)
+}
+
+// redenominate приводит сумму к актуальной валюте. смена кода (RUR → RUB) применяется всегда:
+// суммы в старом коде записаны в старых единицах. деноминация без смены кода - только к суммам
+// с датой раньше effective_date; нулевая date - дата неизвестна, такие деноминации пропускаются
+func redenominate(amount decimal.Decimal, currency string, date time.Time) (decimal.Decimal, string) {
+	list := redenominations.Load()
+	if list == nil {
+		return amount, currency
+	}
+	// по порядку дат, чтобы цепочки BYB → BYR → BYN применялись целиком
+	for _, d := range *list {
+		if d.Currency != currency {
+			continue
+		}
+		if d.NewCurrency == d.Currency && (date.IsZero() || !date.Before(d.EffectiveDate)) {
+			continue
+		}
+		amount = amount.Mul(d.Factor)
+		currency = d.NewCurrency
+	}
+	return amount, currency
+}
+
+// rate курс валюты from к валюте converter'а
+func (c *currencyConverter) rate(ctx context.Context, from string) (decimal.Decimal, error) {
+	return c.convert(ctx, decimal.NewFromInt(1), from)
+}
+
+// convertHoldings заполняет у позиций стоимость, затраты и прибыль в валюте портфеля и доли в нем.
+// возвращает итоговую стоимость и затраты портфеля в его валюте
+func convertHoldings(ctx context.Context, conv *currencyConverter, holdings []models.Holding) (totalValue, totalCost decimal.Decimal, err error) {
+	for i := range holdings {
+		h := &holdings[i]
+		h.Currency = h.ValueCurrency(conv.target)
+		h.PortfolioCurrency = conv.target
+		if h.ExchangeRate, err = conv.rate(ctx, h.Currency); err != nil {
+			return decimal.Zero, decimal.Zero, err
+		}
+		h.ConvertedValue = h.CurrentValue.Mul(h.ExchangeRate)
+		h.ConvertedCost = h.TotalCost.Mul(h.ExchangeRate)
+		h.ConvertedProfit = h.ConvertedValue.Sub(h.ConvertedCost)
+
+		totalValue = totalValue.Add(h.ConvertedValue)
+		totalCost = totalCost.Add(h.ConvertedCost)
+	}
+
+	// Weight = (ConvertedValue / TotalPortfolioValue) × 100
+	if totalValue.GreaterThan(decimal.Zero) {
+		for i := range holdings {
+			holdings[i].Weight = holdings[i].ConvertedValue.Div(totalValue).Mul(decimal.NewFromInt(100))
+		}
+	}
+	return totalValue, totalCost, nil
+}
+
+// reportCurrency выбирает валюту отчета: явно запрошенная, иначе валюта пользователя, иначе из конфига
+func reportCurrency(requested, userCurrency, fallback string) string {
+	if requested != "" {
+		return strings.ToUpper(requested)
+	}
+	if userCurrency != "" {
+		return userCurrency
+	}
+	return fallback
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrRedenominationNotFound = errors.New("redenomination not found")
+	ErrRedenominationExists   = errors.New("redenomination for this currency and date already exists")
+	ErrInvalidRedenomination  = errors.New("redenomination must have a positive factor and change the currency code or scale")
+)
+
+// CurrencyService справочник деноминаций валют и замен токенов
+type CurrencyService interface {
+	ListRedenominations(ctx context.Context) ([]models.CurrencyRedenomination, error)
+	CreateRedenomination(ctx context.Context, input *models.CurrencyRedenominationCreate) (*models.CurrencyRedenomination, error)
+	DeleteRedenomination(ctx context.Context, id uuid.UUID) error
+	// Load перечитывает деноминации в память, ими пользуются все пересчеты валют процесса
+	Load(ctx context.Context) error
+	// Watch перечитывает деноминации с интервалом, пока не отменен ctx: изменения с других экземпляров
+	Watch(ctx context.Context, interval time.Duration)
+}
+
+type currencyService struct {
+	redenominationRepo repository.CurrencyRedenominationRepository
+}
+
+func NewCurrencyService(redenominationRepo repository.CurrencyRedenominationRepository) CurrencyService {
+	return &currencyService{redenominationRepo: redenominationRepo}
+}
+
+func (s *currencyService) ListRedenominations(ctx context.Context) ([]models.CurrencyRedenomination, error) {
+	list, err := s.redenominationRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []models.CurrencyRedenomination{}
+	}
+	return list, nil
+}
+
+func (s *currencyService) CreateRedenomination(ctx context.Context, input *models.CurrencyRedenominationCreate) (*models.CurrencyRedenomination, error) {
+	d := &models.CurrencyRedenomination{
+		Currency:      strings.ToUpper(strings.TrimSpace(input.Currency)),
+		NewCurrency:   strings.ToUpper(strings.TrimSpace(input.NewCurrency)),
+		Factor:        input.Factor,
+		EffectiveDate: truncateDay(input.EffectiveDate),
+		Note:          strings.TrimSpace(input.Note),
+	}
+	if d.NewCurrency == "" {
+		d.NewCurrency = d.Currency
+	}
+	// событие без смены кода и масштаба ничего не меняет
+	if !d.Factor.IsPositive() || (d.NewCurrency == d.Currency && d.Factor.Equal(decimal.NewFromInt(1))) {
+		return nil, ErrInvalidRedenomination
+	}
+
+	existing, err := s.redenominationRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range existing {
+		if e.Currency == d.Currency && e.EffectiveDate.Equal(d.EffectiveDate) {
+			return nil, ErrRedenominationExists
+		}
+	}
+
+	if err := s.redenominationRepo.Create(ctx, d); err != nil {
+		return nil, err
+	}
+	s.reload(ctx)
+	return d, nil
+}
+
+func (s *currencyService) DeleteRedenomination(ctx context.Context, id uuid.UUID) error {
+	deleted, err := s.redenominationRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRedenominationNotFound
+	}
+	s.reload(ctx)
+	return nil
+}
+
+func (s *currencyService) Load(ctx context.Context) error {
+	list, err := s.redenominationRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+	setRedenominations(list)
+	return nil
+}
+
+func (s *currencyService) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reload(ctx)
+		}
+	}
+}
+
+// reload как Load, но ошибку только логирует: в памяти остается прежний список
+func (s *currencyService) reload(ctx context.Context) {
+	if err := s.Load(ctx); err != nil {
+		log.Printf("Не удалось перечитать деноминации валют: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	defaultCursorLimit = 50
+	maxCursorLimit     = 200
+
+	// списки, которые собираются в памяти (позиции, дивиденды), режутся на страницы после сборки
+	defaultListLimit = 100
+)
+
+// encodeCursor непрозрачный для клиента курсор: "дата|id" в base64url
+func encodeCursor(date time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(date.Format("2006-01-02") + "|" + id.String()))
+}
+
+func decodeCursor(cursor string) (*models.PageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	datePart, idPart, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	date, err := time.Parse("2006-01-02", datePart)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &models.PageCursor{Date: date, ID: id}, nil
+}
+
+// cursorPage разбирает курсор и лимит запроса. из бд читается на одну запись больше,
+// чтобы без COUNT понять, есть ли следующая страница
+func cursorPage(cursor string, limit int) (models.CursorPage, int, error) {
+	if limit <= 0 {
+		limit = defaultCursorLimit
+	}
+	if limit > maxCursorLimit {
+		limit = maxCursorLimit
+	}
+
+	page := models.CursorPage{Limit: limit + 1}
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return page, limit, err
+		}
+		page.After = after
+	}
+	return page, limit, nil
+}
+
+// listPage номер страницы и лимит с умолчаниями и границы страницы в списке из total записей
+func listPage(total, page, limit int) (int, int, int, int) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if page <= 0 {
+		page = 1
+	}
+	start := (page - 1) * limit
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+	return page, limit, start, end
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrCustomAssetNotFound = errors.New("asset not found")
+	ErrInvalidAssetClass   = errors.New("unknown asset class")
+	ErrInvalidAssetValue   = errors.New("asset value must not be negative")
+	ErrValuationNotFound   = errors.New("valuation not found")
+	ErrLastValuation       = errors.New("cannot delete the only valuation of an asset")
+)
+
+type CustomAssetService interface {
+	// Create создает актив вместе с первой оценкой
+	Create(ctx context.Context, userID uuid.UUID, input *models.CustomAssetCreate) (*models.CustomAsset, error)
+	GetByID(ctx context.Context, userID, id uuid.UUID) (*models.CustomAsset, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.CustomAsset, error)
+	Update(ctx context.Context, userID, id uuid.UUID, update *models.CustomAssetUpdate) (*models.CustomAsset, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+
+	// AddValuation добавляет переоценку; текущая стоимость - оценка с самой поздней датой
+	AddValuation(ctx context.Context, userID, assetID uuid.UUID, input *models.CustomAssetValuationCreate) (*models.CustomAssetValuation, error)
+	// GetValuations история стоимости актива по возрастанию даты
+	GetValuations(ctx context.Context, userID, assetID uuid.UUID) ([]models.CustomAssetValuation, error)
+	DeleteValuation(ctx context.Context, userID, assetID, valuationID uuid.UUID) error
+}
+
+type customAssetService struct {
+	assetRepo repository.CustomAssetRepository
+	txManager repository.TxManager
+}
+
+func NewCustomAssetService(assetRepo repository.CustomAssetRepository, txManager repository.TxManager) CustomAssetService {
+	return &customAssetService{
+		assetRepo: assetRepo,
+		txManager: txManager,
+	}
+}
+
+func (s *customAssetService) Create(ctx context.Context, userID uuid.UUID, input *models.CustomAssetCreate) (*models.CustomAsset, error) {
+	if !validAssetClass(input.Class) {
+		return nil, ErrInvalidAssetClass
+	}
+	if input.Value.IsNegative() {
+		return nil, ErrInvalidAssetValue
+	}
+
+	valuedAt := truncateDay(time.Now())
+	if input.ValuedAt != nil {
+		valuedAt = truncateDay(*input.ValuedAt)
+	}
+
+	asset := &models.CustomAsset{
+		UserID:        userID,
+		Name:          input.Name,
+		Class:         input.Class,
+		Currency:      strings.ToUpper(input.Currency),
+		CurrentValue:  input.Value,
+		ValuedAt:      valuedAt,
+		PurchaseValue: input.PurchaseValue,
+		PurchaseDate:  input.PurchaseDate,
+		Notes:         input.Notes,
+		IsActive:      true,
+	}
+
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.assetRepo.Create(ctx, asset); err != nil {
+			return err
+		}
+		return s.assetRepo.AddValuation(ctx, &models.CustomAssetValuation{
+			AssetID: asset.ID,
+			Value:   input.Value,
+			Date:    valuedAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	withGain(asset)
+	return asset, nil
+}
+
+func (s *customAssetService) GetByID(ctx context.Context, userID, id uuid.UUID) (*models.CustomAsset, error) {
+	asset, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	withGain(asset)
+	return asset, nil
+}
+
+func (s *customAssetService) GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.CustomAsset, error) {
+	assets, err := s.assetRepo.GetByUserID(ctx, userID, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	for i := range assets {
+		withGain(&assets[i])
+	}
+	return assets, nil
+}
+
+func (s *customAssetService) Update(ctx context.Context, userID, id uuid.UUID, update *models.CustomAssetUpdate) (*models.CustomAsset, error) {
+	asset, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		asset.Name = *update.Name
+	}
+	if update.Class != nil {
+		if !validAssetClass(*update.Class) {
+			return nil, ErrInvalidAssetClass
+		}
+		asset.Class = *update.Class
+	}
+	if update.PurchaseValue != nil {
+		asset.PurchaseValue = update.PurchaseValue
+	}
+	if update.PurchaseDate != nil {
+		asset.PurchaseDate = update.PurchaseDate
+	}
+	if update.Notes != nil {
+		asset.Notes = *update.Notes
+	}
+	if update.IsActive != nil {
+		asset.IsActive = *update.IsActive
+	}
+
+	if err := s.assetRepo.Update(ctx, asset); err != nil {
+		return nil, err
+	}
+
+	withGain(asset)
+	return asset, nil
+}
+
+func (s *customAssetService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.get(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.assetRepo.Delete(ctx, id)
+}
+
+func (s *customAssetService) AddValuation(ctx context.Context, userID, assetID uuid.UUID, input *models.CustomAssetValuationCreate) (*models.CustomAssetValuation, error) {
+	asset, err := s.get(ctx, userID, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Value.IsNegative() {
+		return nil, ErrInvalidAssetValue
+	}
+
+	date := truncateDay(time.Now())
+	if input.Date != nil {
+		date = truncateDay(*input.Date)
+	}
+
+	valuation := &models.CustomAssetValuation{
+		AssetID: asset.ID,
+		Value:   input.Value,
+		Date:    date,
+		Notes:   input.Notes,
+	}
+
+	err = s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.assetRepo.AddValuation(ctx, valuation); err != nil {
+			return err
+		}
+		// задним числом можно дописать историю, текущую стоимость это не меняет
+		if date.Before(asset.ValuedAt) {
+			return nil
+		}
+		return s.assetRepo.SetValue(ctx, asset.ID, valuation.Value, date)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return valuation, nil
+}
+
+func (s *customAssetService) GetValuations(ctx context.Context, userID, assetID uuid.UUID) ([]models.CustomAssetValuation, error) {
+	if _, err := s.get(ctx, userID, assetID); err != nil {
+		return nil, err
+	}
+	return s.assetRepo.GetValuations(ctx, assetID)
+}
+
+func (s *customAssetService) DeleteValuation(ctx context.Context, userID, assetID, valuationID uuid.UUID) error {
+	if _, err := s.get(ctx, userID, assetID); err != nil {
+		return err
+	}
+
+	valuation, err := s.assetRepo.GetValuation(ctx, valuationID)
+	if err != nil || valuation.AssetID != assetID {
+		return ErrValuationNotFound
+	}
+
+	return s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.assetRepo.DeleteValuation(ctx, valuationID); err != nil {
+			return err
+		}
+		remaining, err := s.assetRepo.GetValuations(ctx, assetID)
+		if err != nil {
+			return err
+		}
+		if len(remaining) == 0 {
+			return ErrLastValuation
+		}
+		// история отсортирована по дате - последняя оценка становится текущей стоимостью
+		latest := remaining[len(remaining)-1]
+		return s.assetRepo.SetValue(ctx, assetID, latest.Value, latest.Date)
+	})
+}
+
+// get актив пользователя; чужой актив не отличается от несуществующего
+func (s *customAssetService) get(ctx context.Context, userID, id uuid.UUID) (*models.CustomAsset, error) {
+	asset, err := s.assetRepo.GetByID(ctx, id)
+	if err != nil || asset.UserID != userID {
+		return nil, ErrCustomAssetNotFound
+	}
+	return asset, nil
+}
+
+func validAssetClass(class models.CustomAssetClass) bool {
+	for _, info := range models.CustomAssetClasses {
+		if info.Class == class {
+			return true
+		}
+	}
+	return false
+}
+
+// withGain считает прирост стоимости относительно цены покупки
+func withGain(asset *models.CustomAsset) {
+	if asset.PurchaseValue == nil {
+		return
+	}
+	gain := asset.CurrentValue.Sub(*asset.PurchaseValue)
+	asset.Gain = &gain
+	if asset.PurchaseValue.IsPositive() {
+		percent := gain.Div(*asset.PurchaseValue).Mul(decimal.NewFromInt(100)).Round(2)
+		asset.GainPercent = &percent
+	}
+}
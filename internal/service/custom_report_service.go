@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrCustomReportNotFound = errors.New("report not found")
+	ErrInvalidReportSpec    = errors.New("report dimensions and measures must not repeat")
+	ErrInvalidReportSort    = errors.New("sort_by must be one of the report dimensions or measures")
+	ErrInvalidReportPeriod  = errors.New("date_from must be before date_to")
+)
+
+// период отчета, если в фильтрах не задан
+const defaultReportMonths = 12
+
+type CustomReportService interface {
+	Create(ctx context.Context, userID uuid.UUID, input *models.ReportDefinitionCreate) (*models.ReportDefinition, error)
+	GetByID(ctx context.Context, userID, id uuid.UUID) (*models.ReportDefinition, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ReportDefinition, error)
+	Update(ctx context.Context, userID, id uuid.UUID, update *models.ReportDefinitionUpdate) (*models.ReportDefinition, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	// Run строит сохраненный отчет на текущих данных
+	Run(ctx context.Context, userID, id uuid.UUID) (*models.ReportResult, error)
+	// Preview строит отчет по описанию без сохранения (конструктор на фронте)
+	Preview(ctx context.Context, userID uuid.UUID, spec *models.ReportSpec) (*models.ReportResult, error)
+}
+
+type customReportService struct {
+	reportRepo     repository.ReportDefinitionRepository
+	userRepo       repository.UserRepository
+	marketProvider *market.MultiProvider
+}
+
+func NewCustomReportService(reportRepo repository.ReportDefinitionRepository, userRepo repository.UserRepository, marketProvider *market.MultiProvider) CustomReportService {
+	return &customReportService{
+		reportRepo:     reportRepo,
+		userRepo:       userRepo,
+		marketProvider: marketProvider,
+	}
+}
+
+func (s *customReportService) Create(ctx context.Context, userID uuid.UUID, input *models.ReportDefinitionCreate) (*models.ReportDefinition, error) {
+	spec := input.ReportSpec
+	if err := normalizeReportSpec(&spec); err != nil {
+		return nil, err
+	}
+
+	report := &models.ReportDefinition{
+		UserID:     userID,
+		Name:       strings.TrimSpace(input.Name),
+		ReportSpec: spec,
+	}
+	if err := s.reportRepo.Create(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *customReportService) GetByID(ctx context.Context, userID, id uuid.UUID) (*models.ReportDefinition, error) {
+	report, err := s.reportRepo.GetByID(ctx, id)
+	if err != nil || report.UserID != userID {
+		return nil, ErrCustomReportNotFound
+	}
+	return report, nil
+}
+
+func (s *customReportService) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ReportDefinition, error) {
+	return s.reportRepo.GetByUserID(ctx, userID)
+}
+
+func (s *customReportService) Update(ctx context.Context, userID, id uuid.UUID, update *models.ReportDefinitionUpdate) (*models.ReportDefinition, error) {
+	report, err := s.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		report.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Spec != nil {
+		spec := *update.Spec
+		if err := normalizeReportSpec(&spec); err != nil {
+			return nil, err
+		}
+		report.ReportSpec = spec
+	}
+
+	if err := s.reportRepo.Update(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *customReportService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.GetByID(ctx, userID, id); err != nil {
+		return err
+	}
+	deleted, err := s.reportRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCustomReportNotFound
+	}
+	return nil
+}
+
+func (s *customReportService) Run(ctx context.Context, userID, id uuid.UUID) (*models.ReportResult, error) {
+	report, err := s.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	// описание могло сохраниться до появления новых правил - проверяем заново
+	if err := normalizeReportSpec(&report.ReportSpec); err != nil {
+		return nil, err
+	}
+
+	result, err := s.run(ctx, userID, &report.ReportSpec)
+	if err != nil {
+		return nil, err
+	}
+	result.Name = report.Name
+	return result, nil
+}
+
+func (s *customReportService) Preview(ctx context.Context, userID uuid.UUID, spec *models.ReportSpec) (*models.ReportResult, error) {
+	if err := normalizeReportSpec(spec); err != nil {
+		return nil, err
+	}
+	return s.run(ctx, userID, spec)
+}
+
+// run суммы считает SQL, здесь только перевод в валюту отчета, свертка по месяцам и валютам и сортировка
+func (s *customReportService) run(ctx context.Context, userID uuid.UUID, spec *models.ReportSpec) (*models.ReportResult, error) {
+	currency := spec.Currency
+	if currency == "" {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		currency = user.DefaultCurrency
+	}
+	from, to := customReportPeriod(&spec.Filters, time.Now())
+
+	cells, err := s.reportRepo.Aggregate(ctx, userID, spec, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	type accumulator struct {
+		keys  []models.ReportKey
+		sum   decimal.Decimal
+		count int64
+	}
+	conv := newCurrencyConverter(s.marketProvider, currency)
+	groups := make(map[string]*accumulator)
+	var order []string
+	var totalSum decimal.Decimal
+	var totalCount int64
+	for _, cell := range cells {
+		month, _ := time.Parse("2006-01", cell.Month)
+		sum, err := conv.convertAt(ctx, cell.Sum, cell.Currency, month)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.Join(cell.Keys, "\x00")
+		group, ok := groups[id]
+		if !ok {
+			group = &accumulator{keys: make([]models.ReportKey, len(cell.Keys))}
+			for i := range cell.Keys {
+				group.keys[i] = models.ReportKey{Key: cell.Keys[i], Name: cell.Names[i]}
+			}
+			groups[id] = group
+			order = append(order, id)
+		}
+		group.sum = group.sum.Add(sum)
+		group.count += cell.Count
+		totalSum = totalSum.Add(sum)
+		totalCount += cell.Count
+	}
+
+	// операция с несколькими метками попадает в строку каждой метки, поэтому итог по строкам завышен:
+	// считаем его отдельным запросом без разбивок
+	if slices.Contains(spec.Dimensions, models.ReportByTag) {
+		totalsSpec := *spec
+		totalsSpec.Dimensions = nil
+		totals, err := s.reportRepo.Aggregate(ctx, userID, &totalsSpec, from, to)
+		if err != nil {
+			return nil, err
+		}
+		totalSum, totalCount = decimal.Zero, 0
+		for _, cell := range totals {
+			month, _ := time.Parse("2006-01", cell.Month)
+			sum, err := conv.convertAt(ctx, cell.Sum, cell.Currency, month)
+			if err != nil {
+				return nil, err
+			}
+			totalSum = totalSum.Add(sum)
+			totalCount += cell.Count
+		}
+	}
+
+	result := &models.ReportResult{
+		Currency:   strings.ToUpper(currency),
+		DateFrom:   from,
+		DateTo:     to,
+		Dimensions: spec.Dimensions,
+		Measures:   spec.Measures,
+		Rows:       make([]models.ReportRow, 0, len(order)),
+		Totals:     reportValues(spec.Measures, totalSum.Round(2), totalCount),
+	}
+	for _, id := range order {
+		group := groups[id]
+		result.Rows = append(result.Rows, models.ReportRow{
+			Keys:   group.keys,
+			Values: reportValues(spec.Measures, group.sum.Round(2), group.count),
+		})
+	}
+
+	sortReportRows(result.Rows, spec)
+	if spec.Limit > 0 && len(result.Rows) > spec.Limit {
+		result.Rows = result.Rows[:spec.Limit]
+		result.Truncated = true
+	}
+	return result, nil
+}
+
+// normalizeReportSpec проверяет описание и заполняет умолчания
+func normalizeReportSpec(spec *models.ReportSpec) error {
+	if len(spec.Measures) == 0 {
+		spec.Measures = []models.ReportMeasure{models.ReportSum}
+	}
+	if spec.Filters.Type == "" {
+		spec.Filters.Type = models.TransactionTypeExpense
+	}
+	spec.Currency = strings.ToUpper(spec.Currency)
+
+	seen := make(map[string]bool)
+	for _, dimension := range spec.Dimensions {
+		if seen[string(dimension)] {
+			return ErrInvalidReportSpec
+		}
+		seen[string(dimension)] = true
+	}
+	for _, measure := range spec.Measures {
+		if seen[string(measure)] {
+			return ErrInvalidReportSpec
+		}
+		seen[string(measure)] = true
+	}
+	if spec.SortBy != "" && !seen[spec.SortBy] {
+		return ErrInvalidReportSort
+	}
+
+	f := &spec.Filters
+	if f.DateFrom != nil && f.DateTo != nil && f.DateFrom.After(*f.DateTo) {
+		return ErrInvalidReportPeriod
+	}
+	return nil
+}
+
+// customReportPeriod границы отчета: last_months, иначе даты фильтра, иначе последние 12 месяцев
+func customReportPeriod(f *models.ReportFilters, now time.Time) (time.Time, time.Time) {
+	firstMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if f.LastMonths > 0 {
+		return firstMonth.AddDate(0, -(f.LastMonths - 1), 0), now
+	}
+
+	from := firstMonth.AddDate(0, -(defaultReportMonths - 1), 0)
+	to := now
+	if f.DateFrom != nil {
+		from = *f.DateFrom
+	}
+	if f.DateTo != nil {
+		to = *f.DateTo
+	}
+	return from, to
+}
+
+// reportValues значения запрошенных показателей
+func reportValues(measures []models.ReportMeasure, sum decimal.Decimal, count int64) models.ReportValues {
+	var values models.ReportValues
+	for _, measure := range measures {
+		switch measure {
+		case models.ReportSum:
+			values.Sum = &sum
+		case models.ReportCount:
+			values.Count = &count
+		case models.ReportAvg:
+			avg := decimal.Zero
+			if count > 0 {
+				avg = sum.Div(decimal.NewFromInt(count)).Round(2)
+			}
+			values.Avg = &avg
+		}
+	}
+	return values
+}
+
+// sortReportRows по показателю - крупные первыми, по разбивке - по алфавиту (месяцы по порядку)
+func sortReportRows(rows []models.ReportRow, spec *models.ReportSpec) {
+	sortBy := spec.SortBy
+	if sortBy == "" {
+		sortBy = string(spec.Measures[0])
+	}
+
+	dimension := -1
+	for i, d := range spec.Dimensions {
+		if string(d) == sortBy {
+			dimension = i
+		}
+	}
+	desc := spec.SortOrder == "desc" || (spec.SortOrder == "" && dimension < 0)
+
+	measure := func(v models.ReportValues) decimal.Decimal {
+		switch models.ReportMeasure(sortBy) {
+		case models.ReportCount:
+			return decimal.NewFromInt(*v.Count)
+		case models.ReportAvg:
+			return *v.Avg
+		}
+		return *v.Sum
+	}
+	less := func(i, j int) bool {
+		if dimension >= 0 {
+			return rows[i].Keys[dimension].Name < rows[j].Keys[dimension].Name
+		}
+		return measure(rows[i].Values).LessThan(measure(rows[j].Values))
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if desc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// tagReportRepo одна операция на 100 RUB с двумя метками: в разбивке по меткам она встречается дважды
+type tagReportRepo struct {
+	repository.ReportDefinitionRepository
+}
+
+func (r *tagReportRepo) Aggregate(ctx context.Context, userID uuid.UUID, spec *models.ReportSpec, from, to time.Time) ([]models.ReportCell, error) {
+	month := time.Now().Format("2006-01")
+	if len(spec.Dimensions) == 0 {
+		return []models.ReportCell{{Month: month, Currency: "RUB", Sum: decimal.NewFromInt(100), Count: 1}}, nil
+	}
+	return []models.ReportCell{
+		{Month: month, Currency: "RUB", Keys: []string{"отпуск"}, Names: []string{"отпуск"}, Sum: decimal.NewFromInt(100), Count: 1},
+		{Month: month, Currency: "RUB", Keys: []string{"семья"}, Names: []string{"семья"}, Sum: decimal.NewFromInt(100), Count: 1},
+	}, nil
+}
+
+func TestCustomReportTagTotalsCountTransactionOnce(t *testing.T) {
+	svc := &customReportService{reportRepo: &tagReportRepo{}}
+	spec := &models.ReportSpec{
+		Dimensions: []models.ReportDimension{models.ReportByTag},
+		Measures:   []models.ReportMeasure{models.ReportSum, models.ReportCount},
+		Currency:   "RUB",
+	}
+
+	result, err := svc.Preview(context.Background(), uuid.New(), spec)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if len(result.Rows) != 2 {
+		t.Fatalf("rows = %d, want 2", len(result.Rows))
+	}
+	if !result.Totals.Sum.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("totals sum = %s, want 100", result.Totals.Sum)
+	}
+	if *result.Totals.Count != 1 {
+		t.Errorf("totals count = %d, want 1", *result.Totals.Count)
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidDashboardSection = errors.New("invalid dashboard section")
+
+type DashboardService interface {
+	// Get собирает разделы главного экрана параллельно; пустой sections - разделы из конфига.
+	// ошибка одного раздела не ломает ответ: раздел остается пустым, причина - в Errors
+	Get(ctx context.Context, userID uuid.UUID, sections []models.DashboardSection) (*models.Dashboard, error)
+}
+
+type dashboardService struct {
+	accountService     AccountService
+	analyticsService   AnalyticsService
+	budgetService      BudgetService
+	goalService        GoalService
+	portfolioService   PortfolioService
+	transactionService TransactionService
+
+	defaultSections []models.DashboardSection
+	recentLimit     int
+	sectionTimeout  time.Duration
+}
+
+func NewDashboardService(
+	accountService AccountService,
+	analyticsService AnalyticsService,
+	budgetService BudgetService,
+	goalService GoalService,
+	portfolioService PortfolioService,
+	transactionService TransactionService,
+	cfg *config.Config,
+) DashboardService {
+	// неизвестные разделы в конфиге пропускаем, чтобы опечатка не ломала главный экран
+	var sections []models.DashboardSection
+	for _, name := range cfg.DashboardSections {
+		section := models.DashboardSection(name)
+		if !section.Valid() {
+			log.Printf("неизвестный раздел главного экрана в DASHBOARD_SECTIONS: %s", name)
+			continue
+		}
+		sections = append(sections, section)
+	}
+	if len(sections) == 0 {
+		sections = models.DashboardSections
+	}
+
+	recentLimit := cfg.DashboardRecentTransactions
+	if recentLimit <= 0 {
+		recentLimit = 10
+	}
+
+	return &dashboardService{
+		accountService:     accountService,
+		analyticsService:   analyticsService,
+		budgetService:      budgetService,
+		goalService:        goalService,
+		portfolioService:   portfolioService,
+		transactionService: transactionService,
+		defaultSections:    sections,
+		recentLimit:        recentLimit,
+		sectionTimeout:     cfg.DashboardSectionTimeout,
+	}
+}
+
+func (s *dashboardService) Get(ctx context.Context, userID uuid.UUID, sections []models.DashboardSection) (*models.Dashboard, error) {
+	for _, section := range sections {
+		if !section.Valid() {
+			return nil, ErrInvalidDashboardSection
+		}
+	}
+	if len(sections) == 0 {
+		sections = s.defaultSections
+	}
+
+	dashboard := &models.Dashboard{}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	seen := make(map[models.DashboardSection]bool, len(sections))
+	for _, section := range sections {
+		if seen[section] {
+			continue
+		}
+		seen[section] = true
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.loadSection(ctx, userID, section, dashboard); err != nil {
+				log.Printf("не удалось собрать раздел %s главного экрана пользователя %s: %v", section, userID, err)
+				mu.Lock()
+				if dashboard.Errors == nil {
+					dashboard.Errors = make(map[models.DashboardSection]string)
+				}
+				dashboard.Errors[section] = sectionErrorMessage(err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return dashboard, nil
+}
+
+// loadSection заполняет одно поле dashboard; каждое поле пишет только своя горутина
+func (s *dashboardService) loadSection(ctx context.Context, userID uuid.UUID, section models.DashboardSection, dashboard *models.Dashboard) (err error) {
+	// паника в горутине уронила бы весь сервер, поэтому превращаем ее в ошибку раздела
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if s.sectionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.sectionTimeout)
+		defer cancel()
+	}
+
+	switch section {
+	case models.DashboardAccounts:
+		summary, err := s.accountService.GetSummary(ctx, userID)
+		if err != nil {
+			return err
+		}
+		dashboard.Accounts = summary
+
+	case models.DashboardMonth:
+		summary, err := s.analyticsService.GetFinancialSummary(ctx, userID, models.PeriodMonth, nil, nil, "")
+		if err != nil {
+			return err
+		}
+		dashboard.Month = summary
+
+	case models.DashboardBudgets:
+		alerts, err := s.budgetService.GetAlerts(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if alerts == nil {
+			alerts = []models.BudgetAlert{}
+		}
+		dashboard.BudgetAlerts = alerts
+
+	case models.DashboardGoals:
+		active := models.GoalStatusActive
+		goals, err := s.goalService.GetByUserID(ctx, userID, &active)
+		if err != nil {
+			return err
+		}
+		if goals == nil {
+			goals = []models.Goal{}
+		}
+		dashboard.Goals = goals
+
+	case models.DashboardPortfolios:
+		portfolios, err := s.portfolioService.GetSummary(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if portfolios == nil {
+			portfolios = []models.PortfolioSummary{}
+		}
+		dashboard.Portfolios = portfolios
+
+	case models.DashboardTransactions:
+		list, err := s.transactionService.GetByFilter(ctx, userID, &models.TransactionFilter{
+			Page:      1,
+			Limit:     s.recentLimit,
+			SortBy:    "date",
+			SortOrder: "desc",
+		})
+		if err != nil {
+			return err
+		}
+		transactions := list.Transactions
+		if transactions == nil {
+			transactions = []models.Transaction{}
+		}
+		dashboard.RecentTransactions = transactions
+	}
+	return nil
+}
+
+// sectionErrorMessage причина для клиента без подробностей из бд и провайдеров
+func sectionErrorMessage(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "unavailable"
+}
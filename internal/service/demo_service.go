@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/shopspring/decimal"
+)
+
+// DemoService песочница демо-режима: пользователь с правдоподобными данными, которые можно показывать всем
+type DemoService interface {
+	// Seed заводит демо-пользователя и наполняет его данными, если его еще нет. без DEMO_MODE ничего не делает
+	Seed(ctx context.Context) error
+}
+
+type demoService struct {
+	seedService SeedService
+	userRepo    repository.UserRepository
+	config      *config.Config
+}
+
+func NewDemoService(seedService SeedService, userRepo repository.UserRepository, cfg *config.Config) DemoService {
+	return &demoService{
+		seedService: seedService,
+		userRepo:    userRepo,
+		config:      cfg,
+	}
+}
+
+// demoMonths за сколько месяцев генерируются операции
+const demoMonths = 6
+
+func (s *demoService) Seed(ctx context.Context) error {
+	if !s.config.DemoMode {
+		return nil
+	}
+
+	// типовой бюджет без масштабирования, один портфель; пароля нет - вход только через /auth/demo
+	spec := &models.SeedSpec{
+		Months:     demoMonths,
+		RandomSeed: 42,
+		Users:      []models.SeedUser{seedUser(s.config.DemoEmail, "Демо", "Пользователь", decimal.NewFromInt(1), 1)},
+	}
+	result, err := s.seedService.Apply(ctx, spec)
+	if err != nil {
+		return err
+	}
+	for _, u := range result.Users {
+		if u.Skipped {
+			// адрес уже занят: демо - только аккаунт, который завел сам сервер
+			if existing, err := s.userRepo.GetByID(ctx, u.ID); err == nil && !existing.IsDemo {
+				log.Printf("DEMO_EMAIL %s занят обычным пользователем, демо-вход не работает; задайте другой DEMO_EMAIL", u.Email)
+			}
+			continue
+		}
+		if err := s.userRepo.MarkDemo(ctx, u.ID); err != nil {
+			return err
+		}
+		// без связи с биржей демо обходится без части портфеля
+		for _, w := range u.Warnings {
+			log.Printf("Демо-портфель: %s", w)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrDepositNotFound       = errors.New("deposit not found")
+	ErrInvalidDepositAmount  = errors.New("deposit amount must be positive")
+	ErrInvalidDepositRate    = errors.New("deposit rate must be between 0 and 100")
+	ErrInvalidDepositTerm    = errors.New("deposit term must be between 1 and 3660 days")
+	ErrInvalidCapitalization = errors.New("unknown capitalization, expected none, monthly, quarterly or yearly")
+	ErrPayoutAccountNotFound = errors.New("payout account not found")
+)
+
+// самый длинный срок вклада, который принимаем (10 лет)
+const maxDepositTermDays = 3660
+
+var daysInYear = decimal.NewFromInt(365)
+
+type DepositService interface {
+	Create(ctx context.Context, userID uuid.UUID, input *models.DepositCreate) (*models.Deposit, error)
+	GetByID(ctx context.Context, userID, id uuid.UUID) (*models.Deposit, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.Deposit, error)
+	Update(ctx context.Context, userID, id uuid.UUID, update *models.DepositUpdate) (*models.Deposit, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	// Compare доход по предложениям банков на одну сумму; лучшие по эффективной доходности первыми
+	Compare(ctx context.Context, input *models.DepositCompareInput) ([]models.DepositOfferResult, error)
+}
+
+type depositService struct {
+	depositRepo repository.DepositRepository
+	accountRepo repository.AccountRepository
+}
+
+func NewDepositService(depositRepo repository.DepositRepository, accountRepo repository.AccountRepository) DepositService {
+	return &depositService{
+		depositRepo: depositRepo,
+		accountRepo: accountRepo,
+	}
+}
+
+func (s *depositService) Create(ctx context.Context, userID uuid.UUID, input *models.DepositCreate) (*models.Deposit, error) {
+	if !input.Principal.IsPositive() {
+		return nil, ErrInvalidDepositAmount
+	}
+	capitalization, err := validateDepositTerms(input.Rate, input.TermDays, input.Capitalization)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPayoutAccount(ctx, userID, input.PayoutAccountID); err != nil {
+		return nil, err
+	}
+
+	openedAt := truncateDay(time.Now())
+	if input.OpenedAt != nil {
+		openedAt = truncateDay(*input.OpenedAt)
+	}
+
+	deposit := &models.Deposit{
+		UserID:          userID,
+		Bank:            input.Bank,
+		Name:            input.Name,
+		Currency:        strings.ToUpper(input.Currency),
+		Principal:       input.Principal,
+		Rate:            input.Rate,
+		Capitalization:  capitalization,
+		OpenedAt:        openedAt,
+		TermDays:        input.TermDays,
+		MaturityDate:    openedAt.AddDate(0, 0, input.TermDays),
+		PayoutAccountID: input.PayoutAccountID,
+		Notes:           input.Notes,
+		IsActive:        true,
+	}
+	if err := s.depositRepo.Create(ctx, deposit); err != nil {
+		return nil, err
+	}
+
+	withDepositInterest(deposit, time.Now())
+	return deposit, nil
+}
+
+func (s *depositService) GetByID(ctx context.Context, userID, id uuid.UUID) (*models.Deposit, error) {
+	deposit, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	withDepositInterest(deposit, time.Now())
+	return deposit, nil
+}
+
+func (s *depositService) GetByUserID(ctx context.Context, userID uuid.UUID, activeOnly bool) ([]models.Deposit, error) {
+	deposits, err := s.depositRepo.GetByUserID(ctx, userID, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range deposits {
+		withDepositInterest(&deposits[i], now)
+	}
+	return deposits, nil
+}
+
+func (s *depositService) Update(ctx context.Context, userID, id uuid.UUID, update *models.DepositUpdate) (*models.Deposit, error) {
+	deposit, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Bank != nil {
+		deposit.Bank = *update.Bank
+	}
+	if update.Name != nil {
+		deposit.Name = *update.Name
+	}
+	if update.PayoutAccountID != nil {
+		if err := s.checkPayoutAccount(ctx, userID, update.PayoutAccountID); err != nil {
+			return nil, err
+		}
+		deposit.PayoutAccountID = update.PayoutAccountID
+	}
+	if update.Notes != nil {
+		deposit.Notes = *update.Notes
+	}
+	if update.IsActive != nil {
+		deposit.IsActive = *update.IsActive
+	}
+
+	if err := s.depositRepo.Update(ctx, deposit); err != nil {
+		return nil, err
+	}
+
+	withDepositInterest(deposit, time.Now())
+	return deposit, nil
+}
+
+func (s *depositService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.get(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.depositRepo.Delete(ctx, id)
+}
+
+func (s *depositService) Compare(ctx context.Context, input *models.DepositCompareInput) ([]models.DepositOfferResult, error) {
+	if !input.Amount.IsPositive() {
+		return nil, ErrInvalidDepositAmount
+	}
+
+	today := truncateDay(time.Now())
+	results := make([]models.DepositOfferResult, 0, len(input.Offers))
+	for _, offer := range input.Offers {
+		capitalization, err := validateDepositTerms(offer.Rate, offer.TermDays, offer.Capitalization)
+		if err != nil {
+			return nil, err
+		}
+		offer.Capitalization = capitalization
+
+		maturity := today.AddDate(0, 0, offer.TermDays)
+		amount := depositValue(input.Amount, offer.Rate, capitalization, today, maturity)
+		results = append(results, models.DepositOfferResult{
+			DepositOffer:     offer,
+			Interest:         amount.Sub(input.Amount),
+			AmountAtMaturity: amount,
+			EffectiveYield:   effectiveYield(input.Amount, amount, offer.TermDays),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].EffectiveYield.GreaterThan(results[j].EffectiveYield)
+	})
+	return results, nil
+}
+
+func (s *depositService) get(ctx context.Context, userID, id uuid.UUID) (*models.Deposit, error) {
+	deposit, err := s.depositRepo.GetByID(ctx, id)
+	if err != nil || deposit.UserID != userID {
+		return nil, ErrDepositNotFound
+	}
+	return deposit, nil
+}
+
+func (s *depositService) checkPayoutAccount(ctx context.Context, userID uuid.UUID, accountID *uuid.UUID) error {
+	if accountID == nil {
+		return nil
+	}
+	account, err := s.accountRepo.GetByID(ctx, *accountID)
+	if err != nil || account.UserID != userID {
+		return ErrPayoutAccountNotFound
+	}
+	return nil
+}
+
+// validateDepositTerms проверяет ставку и срок; пустая капитализация - выплата процентов в конце срока
+func validateDepositTerms(rate decimal.Decimal, termDays int, capitalization models.DepositCapitalization) (models.DepositCapitalization, error) {
+	if !rate.IsPositive() || rate.GreaterThan(hundred) {
+		return "", ErrInvalidDepositRate
+	}
+	if termDays <= 0 || termDays > maxDepositTermDays {
+		return "", ErrInvalidDepositTerm
+	}
+	if capitalization == "" {
+		capitalization = models.CapitalizationNone
+	}
+	if _, ok := capitalizationMonths(capitalization); !ok {
+		return "", ErrInvalidCapitalization
+	}
+	return capitalization, nil
+}
+
+// capitalizationMonths период капитализации в месяцах; 0 - без капитализации
+func capitalizationMonths(c models.DepositCapitalization) (int, bool) {
+	switch c {
+	case models.CapitalizationNone:
+		return 0, true
+	case models.CapitalizationMonthly:
+		return 1, true
+	case models.CapitalizationQuarterly:
+		return 3, true
+	case models.CapitalizationYearly:
+		return 12, true
+	}
+	return 0, false
+}
+
+// depositValue сумма вклада с процентами на дату (не позже даты окончания). Проценты начисляются
+// ежедневно по фактическим дням из 365; в даты капитализации присоединяются к сумме с округлением до копеек
+func depositValue(principal, rate decimal.Decimal, capitalization models.DepositCapitalization, openedAt, asOf time.Time) decimal.Decimal {
+	daily := rate.Div(hundred).Div(daysInYear)
+	value := principal
+	periodStart := openedAt
+
+	if step, _ := capitalizationMonths(capitalization); step > 0 {
+		// даты капитализации считаем от даты открытия, чтобы вклад от 31-го не "съезжал" на 28-е
+		for k := 1; ; k++ {
+			next := openedAt.AddDate(0, step*k, 0)
+			if next.After(asOf) {
+				break
+			}
+			value = value.Add(value.Mul(daily).Mul(decimal.NewFromInt(daysBetween(periodStart, next))).Round(2))
+			periodStart = next
+		}
+	}
+
+	if asOf.After(periodStart) {
+		value = value.Add(value.Mul(daily).Mul(decimal.NewFromInt(daysBetween(periodStart, asOf))))
+	}
+	return value.Round(2)
+}
+
+// effectiveYield доход за срок в пересчете на год, %
+func effectiveYield(principal, amount decimal.Decimal, termDays int) decimal.Decimal {
+	if !principal.IsPositive() || termDays <= 0 {
+		return decimal.Zero
+	}
+	return amount.Div(principal).Sub(decimal.NewFromInt(1)).
+		Mul(daysInYear).Div(decimal.NewFromInt(int64(termDays))).
+		Mul(hundred).Round(2)
+}
+
+// withDepositInterest заполняет начисленные проценты на дату now и итог на конец срока
+func withDepositInterest(d *models.Deposit, now time.Time) {
+	today := truncateDay(now)
+	asOf := today
+	if asOf.After(d.MaturityDate) {
+		asOf = d.MaturityDate
+	}
+
+	d.AmountAtMaturity = depositValue(d.Principal, d.Rate, d.Capitalization, d.OpenedAt, d.MaturityDate)
+	d.InterestAtMaturity = d.AmountAtMaturity.Sub(d.Principal)
+	d.CurrentValue = depositValue(d.Principal, d.Rate, d.Capitalization, d.OpenedAt, asOf)
+	d.AccruedInterest = d.CurrentValue.Sub(d.Principal)
+	d.EffectiveYield = effectiveYield(d.Principal, d.AmountAtMaturity, d.TermDays)
+
+	d.DaysLeft = 0
+	if d.MaturityDate.After(today) {
+		d.DaysLeft = int(daysBetween(today, d.MaturityDate))
+	}
+}
+
+func daysBetween(from, to time.Time) int64 {
+	return int64(to.Sub(from).Hours() / 24)
+}
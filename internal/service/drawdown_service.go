@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidDrawdownThreshold = errors.New("drawdown threshold must be between 0 and 100")
+
+const (
+	drawdownWindowDays      = 30 // за сколько дней ищем максимум стоимости
+	defaultDrawdownCooldown = 24 // часов между уведомлениями одного вида
+)
+
+type DrawdownService interface {
+	// GetStatus пороги и текущая просадка портфеля по последним сохраненным ценам
+	GetStatus(ctx context.Context, userID, portfolioID uuid.UUID) (*models.DrawdownStatus, error)
+	SetSettings(ctx context.Context, userID, portfolioID uuid.UUID, input *models.DrawdownAlertInput) (*models.DrawdownStatus, error)
+
+	// Check вызывается после обновления цен: сохраняет снимок стоимости за день и уведомляет,
+	// если просадка от максимума за 30 дней или падение за день превысили порог (не чаще раза за cooldown)
+	Check(ctx context.Context, portfolioID uuid.UUID) error
+	// GetWatchedPortfolios портфели с включенными уведомлениями о просадке
+	GetWatchedPortfolios(ctx context.Context) ([]uuid.UUID, error)
+}
+
+type drawdownService struct {
+	drawdownRepo   repository.DrawdownRepository
+	portfolioRepo  repository.PortfolioRepository
+	holdingRepo    repository.HoldingRepository
+	marketProvider *market.MultiProvider
+	notifications  NotificationService
+}
+
+func NewDrawdownService(
+	drawdownRepo repository.DrawdownRepository,
+	portfolioRepo repository.PortfolioRepository,
+	holdingRepo repository.HoldingRepository,
+	marketProvider *market.MultiProvider,
+	notifications NotificationService,
+) DrawdownService {
+	return &drawdownService{
+		drawdownRepo:   drawdownRepo,
+		portfolioRepo:  portfolioRepo,
+		holdingRepo:    holdingRepo,
+		marketProvider: marketProvider,
+		notifications:  notifications,
+	}
+}
+
+func (s *drawdownService) GetStatus(ctx context.Context, userID, portfolioID uuid.UUID) (*models.DrawdownStatus, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+	value, err := s.portfolioValue(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.settings(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	return s.status(ctx, portfolio, settings, value)
+}
+
+func (s *drawdownService) SetSettings(ctx context.Context, userID, portfolioID uuid.UUID, input *models.DrawdownAlertInput) (*models.DrawdownStatus, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+	for _, pct := range []*decimal.Decimal{input.DrawdownPct, input.DailyDropPct} {
+		if pct != nil && (!pct.IsPositive() || pct.GreaterThanOrEqual(hundred)) {
+			return nil, ErrInvalidDrawdownThreshold
+		}
+	}
+
+	settings := &models.DrawdownAlertSettings{
+		PortfolioID:   portfolioID,
+		DrawdownPct:   input.DrawdownPct,
+		DailyDropPct:  input.DailyDropPct,
+		CooldownHours: defaultDrawdownCooldown,
+	}
+	if input.CooldownHours != nil {
+		settings.CooldownHours = *input.CooldownHours
+	}
+	if err := s.drawdownRepo.UpsertSettings(ctx, settings); err != nil {
+		return nil, err
+	}
+	return s.GetStatus(ctx, userID, portfolioID)
+}
+
+func (s *drawdownService) Check(ctx context.Context, portfolioID uuid.UUID) error {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil {
+		return err
+	}
+	value, err := s.portfolioValue(ctx, portfolio)
+	if err != nil {
+		return err
+	}
+	// пустой портфель не снимаем: иначе продажа всех бумаг выглядела бы как падение на 100%
+	if !value.IsPositive() {
+		return nil
+	}
+
+	snapshot := &models.PortfolioValueSnapshot{
+		PortfolioID: portfolioID,
+		Date:        truncateDay(time.Now()),
+		Value:       value,
+		Currency:    portfolio.Currency,
+	}
+	if err := s.drawdownRepo.UpsertSnapshot(ctx, snapshot); err != nil {
+		return err
+	}
+
+	settings, err := s.settings(ctx, portfolioID)
+	if err != nil || (settings.DrawdownPct == nil && settings.DailyDropPct == nil) {
+		return err
+	}
+	status, err := s.status(ctx, portfolio, settings, value)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	cooldown := time.Duration(settings.CooldownHours) * time.Hour
+	ready := func(alertedAt *time.Time) bool {
+		return alertedAt == nil || now.Sub(*alertedAt) >= cooldown
+	}
+
+	if settings.DrawdownPct != nil && status.Drawdown.GreaterThanOrEqual(*settings.DrawdownPct) && ready(settings.DrawdownAlertedAt) {
+		s.alert(ctx, portfolio, models.DrawdownFromHigh, now,
+			fmt.Sprintf("Портфель «%s» просел на %s%% от максимума", portfolio.Name, status.Drawdown.StringFixed(1)),
+			fmt.Sprintf("Стоимость %s %s, максимум за %d дней %s %s", value.StringFixed(2), portfolio.Currency, drawdownWindowDays, status.High30d.StringFixed(2), portfolio.Currency),
+		)
+	}
+	if settings.DailyDropPct != nil && status.DailyChange != nil && status.DailyChange.Neg().GreaterThanOrEqual(*settings.DailyDropPct) && ready(settings.DailyDropAlertedAt) {
+		s.alert(ctx, portfolio, models.DrawdownDailyDrop, now,
+			fmt.Sprintf("Портфель «%s» упал на %s%% за день", portfolio.Name, status.DailyChange.Neg().StringFixed(1)),
+			fmt.Sprintf("Стоимость %s %s, на закрытии прошлого дня %s %s", value.StringFixed(2), portfolio.Currency, status.PreviousClose.StringFixed(2), portfolio.Currency),
+		)
+	}
+	return nil
+}
+
+func (s *drawdownService) GetWatchedPortfolios(ctx context.Context) ([]uuid.UUID, error) {
+	return s.drawdownRepo.GetWatchedPortfolioIDs(ctx)
+}
+
+// alert отмечает отправку до уведомления: при ошибке отметки лучше пропустить уведомление, чем слать его каждый час
+func (s *drawdownService) alert(ctx context.Context, portfolio *models.Portfolio, kind models.DrawdownAlertKind, now time.Time, title, body string) {
+	if err := s.drawdownRepo.SetAlerted(ctx, portfolio.ID, kind, now); err != nil {
+		log.Printf("не удалось отметить уведомление о просадке портфеля %s: %v", portfolio.ID, err)
+		return
+	}
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID:   portfolio.UserID,
+		Type:     models.NotificationPriceAlert,
+		Title:    title,
+		Body:     body,
+		EntityID: &portfolio.ID,
+	})
+}
+
+// settings пороги портфеля; если их не задавали - выключенные с cooldown по умолчанию
+func (s *drawdownService) settings(ctx context.Context, portfolioID uuid.UUID) (*models.DrawdownAlertSettings, error) {
+	settings, err := s.drawdownRepo.GetSettings(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &models.DrawdownAlertSettings{PortfolioID: portfolioID, CooldownHours: defaultDrawdownCooldown}
+	}
+	return settings, nil
+}
+
+// portfolioValue стоимость позиций по сохраненным ценам в валюте портфеля
+func (s *drawdownService) portfolioValue(ctx context.Context, portfolio *models.Portfolio) (decimal.Decimal, error) {
+	holdings, err := s.holdingRepo.GetByPortfolioID(ctx, portfolio.ID)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	value, _, err := convertHoldings(ctx, newCurrencyConverter(s.marketProvider, portfolio.Currency), holdings)
+	return value, err
+}
+
+// status считает просадку текущей стоимости value по снимкам за 30 дней. снимки в другой валюте
+// (валюту портфеля меняли) не учитываются
+func (s *drawdownService) status(ctx context.Context, portfolio *models.Portfolio, settings *models.DrawdownAlertSettings, value decimal.Decimal) (*models.DrawdownStatus, error) {
+	today := truncateDay(time.Now())
+	snapshots, err := s.drawdownRepo.GetSnapshots(ctx, portfolio.ID, today.AddDate(0, 0, -drawdownWindowDays))
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.DrawdownStatus{
+		Settings:  *settings,
+		Currency:  portfolio.Currency,
+		Value:     value,
+		High30d:   value,
+		Snapshots: []models.PortfolioValueSnapshot{},
+	}
+	for _, snap := range snapshots {
+		if snap.Currency != portfolio.Currency {
+			continue
+		}
+		status.Snapshots = append(status.Snapshots, snap)
+		if snap.High.GreaterThan(status.High30d) {
+			status.High30d = snap.High
+		}
+		if snap.Date.Before(today) {
+			prev := snap.Value
+			status.PreviousClose = &prev
+		}
+	}
+
+	if status.High30d.IsPositive() {
+		status.Drawdown = status.High30d.Sub(value).Div(status.High30d).Mul(hundred)
+	}
+	if status.PreviousClose != nil && status.PreviousClose.IsPositive() {
+		change := value.Sub(*status.PreviousClose).Div(*status.PreviousClose).Mul(hundred)
+		status.DailyChange = &change
+	}
+	return status, nil
+}
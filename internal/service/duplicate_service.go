@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDuplicateWindowInvalid = errors.New("days must be between 0 and 3")
+	ErrInvalidMerge           = errors.New("merged transactions must be different, on the same account and of the same type")
+)
+
+const (
+	defaultDuplicateDays     = 1  // выписка банка и ручной ввод часто расходятся на день
+	maxDuplicateDays         = 3  // дальше это уже разные операции
+	defaultDuplicateLookback = 90 // за сколько дней ищем дубли, если since не задан
+
+	// описания из разных источников пишутся по-разному ("PYATEROCHKA 1234" и "Pyaterochka"),
+	// поэтому порог ниже, чем при подборе получателя
+	duplicateSimilarityThreshold = 0.6
+)
+
+type DuplicateService interface {
+	// Find группирует вероятные дубли: тот же счет, тип и сумма, даты отличаются не больше чем на maxDays
+	// (nil - defaultDuplicateDays), описания похожи (пустое описание совпадает с любым)
+	Find(ctx context.Context, userID uuid.UUID, maxDays *int, since *time.Time) ([]models.DuplicateGroup, error)
+	// Merge оставляет одну транзакцию, остальные удаляет с откатом их влияния на баланс счета
+	Merge(ctx context.Context, userID uuid.UUID, input *models.TransactionMerge) (*models.Transaction, error)
+}
+
+type duplicateService struct {
+	transactionRepo    repository.TransactionRepository
+	transactionService TransactionService
+	txManager          repository.TxManager
+}
+
+func NewDuplicateService(transactionRepo repository.TransactionRepository, transactionService TransactionService, txManager repository.TxManager) DuplicateService {
+	return &duplicateService{
+		transactionRepo:    transactionRepo,
+		transactionService: transactionService,
+		txManager:          txManager,
+	}
+}
+
+func (s *duplicateService) Find(ctx context.Context, userID uuid.UUID, maxDays *int, since *time.Time) ([]models.DuplicateGroup, error) {
+	days := defaultDuplicateDays
+	if maxDays != nil {
+		days = *maxDays
+	}
+	if days < 0 || days > maxDuplicateDays {
+		return nil, ErrDuplicateWindowInvalid
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, -defaultDuplicateLookback)
+	if since != nil {
+		from = *since
+	}
+
+	transactions, err := s.transactionRepo.GetByDateRange(ctx, userID, from, now, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// кандидаты в дубли - только транзакции с одинаковыми счетом, типом и суммой
+	buckets := make(map[string][]models.Transaction)
+	for _, tx := range transactions {
+		buckets[duplicateKey(tx)] = append(buckets[duplicateKey(tx)], tx)
+	}
+
+	groups := make([]models.DuplicateGroup, 0)
+	for _, bucket := range buckets {
+		if len(bucket) < 2 {
+			continue
+		}
+
+		// union-find: A~B и B~C попадают в одну группу
+		parent := make([]int, len(bucket))
+		for i := range parent {
+			parent[i] = i
+		}
+		var find func(int) int
+		find = func(i int) int {
+			if parent[i] != i {
+				parent[i] = find(parent[i])
+			}
+			return parent[i]
+		}
+
+		names := make([]string, len(bucket))
+		for i, tx := range bucket {
+			names[i] = normalizePayeeName(tx.Description)
+		}
+
+		// bucket отсортирован по дате - дальше окна можно не смотреть
+		for i := range bucket {
+			for j := i + 1; j < len(bucket); j++ {
+				if transferDaysApart(bucket[i].Date, bucket[j].Date) > days {
+					break
+				}
+				if similarDescriptions(names[i], names[j]) {
+					parent[find(j)] = find(i)
+				}
+			}
+		}
+
+		clusters := make(map[int][]models.Transaction)
+		for i, tx := range bucket {
+			root := find(i)
+			clusters[root] = append(clusters[root], tx)
+		}
+		for _, cluster := range clusters {
+			if len(cluster) < 2 {
+				continue
+			}
+			groups = append(groups, newDuplicateGroup(cluster))
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Transactions[0].Date.After(groups[j].Transactions[0].Date)
+	})
+	return groups, nil
+}
+
+func (s *duplicateService) Merge(ctx context.Context, userID uuid.UUID, input *models.TransactionMerge) (*models.Transaction, error) {
+	keep, err := s.transactionRepo.GetByID(ctx, input.KeepID)
+	if err != nil || keep.UserID != userID {
+		return nil, ErrTransactionNotFound
+	}
+
+	seen := map[uuid.UUID]bool{keep.ID: true}
+	duplicates := make([]*models.Transaction, 0, len(input.DuplicateIDs))
+	for _, id := range input.DuplicateIDs {
+		if seen[id] {
+			return nil, ErrInvalidMerge
+		}
+		seen[id] = true
+
+		dup, err := s.transactionRepo.GetByID(ctx, id)
+		if err != nil || dup.UserID != userID {
+			return nil, ErrTransactionNotFound
+		}
+		if dup.AccountID != keep.AccountID || dup.Type != keep.Type {
+			return nil, ErrInvalidMerge
+		}
+		duplicates = append(duplicates, dup)
+	}
+
+	// переносим то, чего нет у оставляемой транзакции; сумма и дата остаются ее
+	update := &models.TransactionUpdate{}
+	tags := make(map[string]bool, len(keep.Tags))
+	for _, tag := range keep.Tags {
+		tags[tag] = true
+	}
+	mergedTags := append([]string(nil), keep.Tags...)
+	var items []models.TransactionItem
+
+	for _, dup := range duplicates {
+		if keep.Notes == "" && update.Notes == nil && dup.Notes != "" {
+			update.Notes = &dup.Notes
+		}
+		if keep.Location == "" && update.Location == nil && dup.Location != "" {
+			update.Location = &dup.Location
+		}
+		if keep.PayeeID == nil && update.PayeeID == nil && dup.PayeeID != nil {
+			update.PayeeID = dup.PayeeID
+		}
+		if len(keep.Items) == 0 && items == nil && len(dup.Items) > 0 {
+			items = dup.Items
+		}
+		for _, tag := range dup.Tags {
+			if !tags[tag] {
+				tags[tag] = true
+				mergedTags = append(mergedTags, tag)
+			}
+		}
+	}
+	if len(mergedTags) > len(keep.Tags) {
+		update.Tags = mergedTags
+	}
+
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		if err := s.transactionRepo.Update(txCtx, keep.ID, update); err != nil {
+			return err
+		}
+		if items != nil {
+			if err := s.transactionRepo.SetItems(txCtx, keep.ID, items); err != nil {
+				return err
+			}
+		}
+		for _, dup := range duplicates {
+			if err := s.transactionService.Delete(txCtx, dup.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transactionRepo.GetByID(ctx, keep.ID)
+}
+
+// duplicateKey счет, тип и сумма; у переводов еще и счет получателя
+func duplicateKey(tx models.Transaction) string {
+	key := []string{tx.AccountID.String(), string(tx.Type), tx.Currency, tx.Amount.String()}
+	if tx.ToAccountID != nil {
+		key = append(key, tx.ToAccountID.String())
+	}
+	return strings.Join(key, "|")
+}
+
+// similarDescriptions сравнивает нормализованные описания; пустое описание ничего не говорит
+func similarDescriptions(a, b string) bool {
+	if a == "" || b == "" {
+		return true
+	}
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return true
+	}
+	return payeeSimilarity(a, b) >= duplicateSimilarityThreshold
+}
+
+// newDuplicateGroup оставить предлагаем самую раннюю запись - на нее уже могут ссылаться черновики и чеки
+func newDuplicateGroup(cluster []models.Transaction) models.DuplicateGroup {
+	sort.Slice(cluster, func(i, j int) bool {
+		if !cluster[i].Date.Equal(cluster[j].Date) {
+			return cluster[i].Date.Before(cluster[j].Date)
+		}
+		return cluster[i].CreatedAt.Before(cluster[j].CreatedAt)
+	})
+
+	keep := cluster[0]
+	for _, tx := range cluster[1:] {
+		if tx.CreatedAt.Before(keep.CreatedAt) {
+			keep = tx
+		}
+	}
+
+	return models.DuplicateGroup{
+		AccountID:    keep.AccountID,
+		Type:         keep.Type,
+		Amount:       keep.Amount,
+		Currency:     keep.Currency,
+		Transactions: cluster,
+		KeepID:       keep.ID,
+	}
+}
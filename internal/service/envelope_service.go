@@ -0,0 +1,405 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrEnvelopeNotFound          = errors.New("envelope not found")
+	ErrEnvelopeExists            = errors.New("envelope for this category already exists")
+	ErrEnvelopeCategory          = errors.New("category not found or is not an expense category")
+	ErrInvalidEnvelopeAmount     = errors.New("amount must not be zero")
+	ErrSameEnvelope              = errors.New("source and target envelopes must differ")
+	ErrInsufficientUnallocated   = errors.New("not enough unallocated income")
+	ErrInsufficientEnvelopeFunds = errors.New("not enough money in envelope")
+)
+
+// сколько записей журнала отдаем по умолчанию
+const envelopeHistoryLimit = 100
+
+type EnvelopeService interface {
+	Create(ctx context.Context, userID uuid.UUID, input *models.EnvelopeCreate) (*models.Envelope, error)
+	// Archive скрывает конверт, его остаток возвращается в нераспределенные
+	Archive(ctx context.Context, userID, id uuid.UUID) error
+	// GetMonth состояние конвертов на месяц; month - любой день месяца
+	GetMonth(ctx context.Context, userID uuid.UUID, month time.Time) (*models.EnvelopeMonth, error)
+	Allocate(ctx context.Context, userID, id uuid.UUID, input *models.EnvelopeAllocate) (*models.EnvelopeMonth, error)
+	Move(ctx context.Context, userID uuid.UUID, input *models.EnvelopeMove) (*models.EnvelopeMonth, error)
+	GetHistory(ctx context.Context, userID, id uuid.UUID, limit int) ([]models.EnvelopeEntry, error)
+}
+
+type envelopeService struct {
+	envelopeRepo    repository.EnvelopeRepository
+	categoryRepo    repository.CategoryRepository
+	transactionRepo repository.TransactionRepository
+	userRepo        repository.UserRepository
+	txManager       repository.TxManager
+}
+
+func NewEnvelopeService(envelopeRepo repository.EnvelopeRepository, categoryRepo repository.CategoryRepository, transactionRepo repository.TransactionRepository, userRepo repository.UserRepository, txManager repository.TxManager) EnvelopeService {
+	return &envelopeService{
+		envelopeRepo:    envelopeRepo,
+		categoryRepo:    categoryRepo,
+		transactionRepo: transactionRepo,
+		userRepo:        userRepo,
+		txManager:       txManager,
+	}
+}
+
+func (s *envelopeService) Create(ctx context.Context, userID uuid.UUID, input *models.EnvelopeCreate) (*models.Envelope, error) {
+	category, err := s.categoryRepo.GetByID(ctx, input.CategoryID)
+	if err != nil || (category.UserID != nil && *category.UserID != userID) || category.Type != models.CategoryTypeExpense {
+		return nil, ErrEnvelopeCategory
+	}
+
+	// архивный конверт той же категории просто возвращаем
+	if existing, err := s.envelopeRepo.GetByCategory(ctx, userID, input.CategoryID); err == nil {
+		if !existing.IsArchived {
+			return nil, ErrEnvelopeExists
+		}
+		if err := s.envelopeRepo.SetArchived(ctx, existing.ID, false); err != nil {
+			return nil, err
+		}
+		existing.IsArchived = false
+		existing.Category = category
+		return existing, nil
+	}
+
+	envelope := &models.Envelope{
+		UserID:     userID,
+		CategoryID: input.CategoryID,
+		Name:       input.Name,
+	}
+	if envelope.Name == "" {
+		envelope.Name = category.Name
+	}
+
+	if err := s.envelopeRepo.Create(ctx, envelope); err != nil {
+		return nil, err
+	}
+	envelope.Category = category
+
+	return envelope, nil
+}
+
+func (s *envelopeService) Archive(ctx context.Context, userID, id uuid.UUID) error {
+	envelope, err := s.getOwned(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if envelope.IsArchived {
+		return nil
+	}
+
+	month := monthStart(time.Now())
+	return s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.envelopeRepo.LockUser(ctx, userID); err != nil {
+			return err
+		}
+		view, err := s.GetMonth(ctx, userID, month)
+		if err != nil {
+			return err
+		}
+		available := envelopeAvailable(view, id)
+
+		// положительный остаток возвращаем в нераспределенные, перерасход остается на конверте
+		if available.IsPositive() {
+			if err := s.envelopeRepo.AddEntry(ctx, &models.EnvelopeEntry{
+				UserID:     userID,
+				EnvelopeID: id,
+				Type:       models.EnvelopeEntryAllocate,
+				Amount:     available.Neg(),
+				Month:      month,
+				Note:       "archived",
+			}); err != nil {
+				return err
+			}
+		}
+		return s.envelopeRepo.SetArchived(ctx, id, true)
+	})
+}
+
+func (s *envelopeService) GetMonth(ctx context.Context, userID uuid.UUID, month time.Time) (*models.EnvelopeMonth, error) {
+	month = monthStart(month)
+	monthEnd := month.AddDate(0, 1, 0).Add(-time.Nanosecond)
+
+	envelopes, err := s.envelopeRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	view := &models.EnvelopeMonth{Month: month, Currency: user.DefaultCurrency, Envelopes: []models.Envelope{}}
+
+	// учет ведется с месяца, когда появился первый конверт
+	start := month
+	for _, e := range envelopes {
+		if created := monthStart(e.CreatedAt); created.Before(start) {
+			start = created
+		}
+	}
+
+	totalIncome, err := s.sumIncome(ctx, userID, view.Currency, start, monthEnd)
+	if err != nil {
+		return nil, err
+	}
+	if view.Income, err = s.sumIncome(ctx, userID, view.Currency, month, monthEnd); err != nil {
+		return nil, err
+	}
+
+	allocatedTotal, err := s.envelopeRepo.GetAllocatedSums(ctx, userID, start, month)
+	if err != nil {
+		return nil, err
+	}
+	allocatedMonth, err := s.envelopeRepo.GetAllocatedSums(ctx, userID, month, month)
+	if err != nil {
+		return nil, err
+	}
+
+	// переводы между конвертами в сумме дают ноль, поэтому нераспределенное = доход - все записи журнала
+	view.Unallocated = totalIncome
+	for _, sum := range allocatedTotal {
+		view.Unallocated = view.Unallocated.Sub(sum)
+	}
+
+	spentMonth, err := s.sumByCategory(ctx, userID, view.Currency, month, monthEnd, models.TransactionTypeExpense)
+	if err != nil {
+		return nil, err
+	}
+
+	// накопленные расходы считаем с месяца создания конкретного конверта
+	spentSince := make(map[time.Time]map[uuid.UUID]decimal.Decimal)
+	for _, e := range envelopes {
+		if e.IsArchived {
+			continue
+		}
+
+		created := monthStart(e.CreatedAt)
+		if created.After(month) {
+			continue // конверта в этом месяце еще не было
+		}
+		if _, ok := spentSince[created]; !ok {
+			sums, err := s.sumByCategory(ctx, userID, view.Currency, created, monthEnd, models.TransactionTypeExpense)
+			if err != nil {
+				return nil, err
+			}
+			spentSince[created] = sums
+		}
+
+		e.Allocated = allocatedMonth[e.ID]
+		e.Spent = spentMonth[e.CategoryID]
+		e.Available = allocatedTotal[e.ID].Sub(spentSince[created][e.CategoryID])
+		if category, err := s.categoryRepo.GetByID(ctx, e.CategoryID); err == nil {
+			e.Category = category
+		}
+
+		view.Allocated = view.Allocated.Add(e.Allocated)
+		view.Envelopes = append(view.Envelopes, e)
+	}
+
+	return view, nil
+}
+
+func (s *envelopeService) Allocate(ctx context.Context, userID, id uuid.UUID, input *models.EnvelopeAllocate) (*models.EnvelopeMonth, error) {
+	if input.Amount.IsZero() {
+		return nil, ErrInvalidEnvelopeAmount
+	}
+
+	envelope, err := s.getOwned(ctx, userID, id)
+	if err != nil || envelope.IsArchived {
+		return nil, ErrEnvelopeNotFound
+	}
+
+	month := entryMonth(input.Month)
+	err = s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		// параллельные распределения того же пользователя ждут здесь, иначе обе проверки увидят один остаток
+		if err := s.envelopeRepo.LockUser(ctx, userID); err != nil {
+			return err
+		}
+		view, err := s.GetMonth(ctx, userID, month)
+		if err != nil {
+			return err
+		}
+
+		// распределить можно только то, что есть; вернуть - только то, что осталось в конверте
+		if input.Amount.IsPositive() {
+			unallocated, err := s.unallocatedNow(ctx, userID, month, view)
+			if err != nil {
+				return err
+			}
+			if input.Amount.GreaterThan(unallocated) {
+				return ErrInsufficientUnallocated
+			}
+		}
+		if input.Amount.IsNegative() && input.Amount.Neg().GreaterThan(envelopeAvailable(view, id)) {
+			return ErrInsufficientEnvelopeFunds
+		}
+
+		return s.envelopeRepo.AddEntry(ctx, &models.EnvelopeEntry{
+			UserID:     userID,
+			EnvelopeID: id,
+			Type:       models.EnvelopeEntryAllocate,
+			Amount:     input.Amount,
+			Month:      month,
+			Note:       input.Note,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetMonth(ctx, userID, month)
+}
+
+// unallocatedNow сколько можно распределить в month: не больше нераспределенного на этот месяц и
+// не больше общего остатка с учетом распределений следующих месяцев - иначе прошлый месяц раздал бы
+// деньги, которые позже уже разложены
+func (s *envelopeService) unallocatedNow(ctx context.Context, userID uuid.UUID, month time.Time, view *models.EnvelopeMonth) (decimal.Decimal, error) {
+	latest, err := s.envelopeRepo.GetLatestMonth(ctx, userID)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if current := monthStart(time.Now()); latest.Before(current) {
+		latest = current
+	}
+	if !latest.After(month) {
+		return view.Unallocated, nil
+	}
+
+	overall, err := s.GetMonth(ctx, userID, latest)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return decimal.Min(view.Unallocated, overall.Unallocated), nil
+}
+
+func (s *envelopeService) Move(ctx context.Context, userID uuid.UUID, input *models.EnvelopeMove) (*models.EnvelopeMonth, error) {
+	if !input.Amount.IsPositive() {
+		return nil, ErrInvalidEnvelopeAmount
+	}
+	if input.FromEnvelopeID == input.ToEnvelopeID {
+		return nil, ErrSameEnvelope
+	}
+
+	for _, id := range []uuid.UUID{input.FromEnvelopeID, input.ToEnvelopeID} {
+		envelope, err := s.getOwned(ctx, userID, id)
+		if err != nil || envelope.IsArchived {
+			return nil, ErrEnvelopeNotFound
+		}
+	}
+
+	month := entryMonth(input.Month)
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.envelopeRepo.LockUser(ctx, userID); err != nil {
+			return err
+		}
+		view, err := s.GetMonth(ctx, userID, month)
+		if err != nil {
+			return err
+		}
+		if input.Amount.GreaterThan(envelopeAvailable(view, input.FromEnvelopeID)) {
+			return ErrInsufficientEnvelopeFunds
+		}
+
+		if err := s.envelopeRepo.AddEntry(ctx, &models.EnvelopeEntry{
+			UserID:            userID,
+			EnvelopeID:        input.FromEnvelopeID,
+			RelatedEnvelopeID: &input.ToEnvelopeID,
+			Type:              models.EnvelopeEntryMoveOut,
+			Amount:            input.Amount.Neg(),
+			Month:             month,
+			Note:              input.Note,
+		}); err != nil {
+			return err
+		}
+		return s.envelopeRepo.AddEntry(ctx, &models.EnvelopeEntry{
+			UserID:            userID,
+			EnvelopeID:        input.ToEnvelopeID,
+			RelatedEnvelopeID: &input.FromEnvelopeID,
+			Type:              models.EnvelopeEntryMoveIn,
+			Amount:            input.Amount,
+			Month:             month,
+			Note:              input.Note,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetMonth(ctx, userID, month)
+}
+
+func (s *envelopeService) GetHistory(ctx context.Context, userID, id uuid.UUID, limit int) ([]models.EnvelopeEntry, error) {
+	if _, err := s.getOwned(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > envelopeHistoryLimit {
+		limit = envelopeHistoryLimit
+	}
+	return s.envelopeRepo.GetEntries(ctx, id, limit)
+}
+
+func (s *envelopeService) getOwned(ctx context.Context, userID, id uuid.UUID) (*models.Envelope, error) {
+	envelope, err := s.envelopeRepo.GetByID(ctx, id)
+	if err != nil || envelope.UserID != userID {
+		return nil, ErrEnvelopeNotFound
+	}
+	return envelope, nil
+}
+
+func (s *envelopeService) sumIncome(ctx context.Context, userID uuid.UUID, currency string, from, to time.Time) (decimal.Decimal, error) {
+	sums, err := s.sumByCategory(ctx, userID, currency, from, to, models.TransactionTypeIncome)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	var total decimal.Decimal
+	for _, sum := range sums {
+		total = total.Add(sum)
+	}
+	return total, nil
+}
+
+// sumByCategory суммы по категориям только в валюте конвертов: рубли и доллары не складываем
+func (s *envelopeService) sumByCategory(ctx context.Context, userID uuid.UUID, currency string, from, to time.Time, txType models.TransactionType) (map[uuid.UUID]decimal.Decimal, error) {
+	byCurrency, err := s.transactionRepo.GetSumByCategoryCurrency(ctx, userID, from, to, txType)
+	if err != nil {
+		return nil, err
+	}
+	if sums, ok := byCurrency[currency]; ok {
+		return sums, nil
+	}
+	return map[uuid.UUID]decimal.Decimal{}, nil
+}
+
+// envelopeAvailable остаток конверта из рассчитанного месяца
+func envelopeAvailable(view *models.EnvelopeMonth, id uuid.UUID) decimal.Decimal {
+	for _, e := range view.Envelopes {
+		if e.ID == id {
+			return e.Available
+		}
+	}
+	return decimal.Zero
+}
+
+// entryMonth месяц записи журнала, по умолчанию текущий
+func entryMonth(month *time.Time) time.Time {
+	if month == nil {
+		return monthStart(time.Now())
+	}
+	return monthStart(*month)
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
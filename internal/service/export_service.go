@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/format"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+)
+
+type ExportService interface {
+	// Export собирает все данные пользователя; удаленные транзакции не попадают
+	Export(ctx context.Context, userID uuid.UUID) (*models.UserExport, error)
+	// TransactionsCSV пишет транзакции в CSV: суммы, даты и разделитель колонок по локали
+	// (пустая - локаль из профиля). возвращает число строк
+	TransactionsCSV(ctx context.Context, userID uuid.UUID, locale string, w io.Writer) (int, error)
+}
+
+type exportService struct {
+	repos *repository.Repositories
+}
+
+func NewExportService(repos *repository.Repositories) ExportService {
+	return &exportService{repos: repos}
+}
+
+func (s *exportService) Export(ctx context.Context, userID uuid.UUID) (*models.UserExport, error) {
+	user, err := s.repos.User.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	export := &models.UserExport{ExportedAt: time.Now(), User: *user}
+
+	if export.Accounts, err = s.repos.Account.GetByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	categories, err := s.repos.Category.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		if !c.IsSystem {
+			export.Categories = append(export.Categories, c)
+		}
+	}
+
+	// все время: от нулевой даты до далекого будущего (запланированные на будущее тоже)
+	if export.Transactions, err = s.repos.Transaction.GetByDateRange(ctx, userID, time.Time{}, time.Now().AddDate(100, 0, 0), nil); err != nil {
+		return nil, err
+	}
+	for i := range export.Transactions {
+		if export.Transactions[i].Tags, err = s.repos.Transaction.GetTags(ctx, export.Transactions[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if export.PlannedTransactions, err = s.repos.Planned.GetByUserID(ctx, userID, nil); err != nil {
+		return nil, err
+	}
+	if export.Budgets, err = s.repos.Budget.GetByUserID(ctx, userID, false); err != nil {
+		return nil, err
+	}
+	if export.Goals, err = s.repos.Goal.GetByUserID(ctx, userID, nil); err != nil {
+		return nil, err
+	}
+	if export.Payees, err = s.repos.Payee.GetByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if export.Portfolios, err = s.repos.Portfolio.GetByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+	for i := range export.Portfolios {
+		p := &export.Portfolios[i]
+		if p.Holdings, err = s.repos.Holding.GetByPortfolioID(ctx, p.ID); err != nil {
+			return nil, err
+		}
+		txs, err := s.repos.Investment.GetByDateRange(ctx, p.ID, time.Time{}, time.Now().AddDate(100, 0, 0))
+		if err != nil {
+			return nil, err
+		}
+		export.InvestmentTransactions = append(export.InvestmentTransactions, txs...)
+	}
+
+	if export.CustomAssets, err = s.repos.CustomAsset.GetByUserID(ctx, userID, false); err != nil {
+		return nil, err
+	}
+	if export.Deposits, err = s.repos.Deposit.GetByUserID(ctx, userID, false); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func (s *exportService) TransactionsCSV(ctx context.Context, userID uuid.UUID, locale string, w io.Writer) (int, error) {
+	user, err := s.repos.User.GetByID(ctx, userID)
+	if err != nil {
+		return 0, ErrUserNotFound
+	}
+	if locale == "" {
+		locale = user.Locale
+	}
+	lf := format.Get(locale)
+
+	accounts, err := s.repos.Account.GetByUserID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	accountNames := make(map[uuid.UUID]string, len(accounts))
+	for _, a := range accounts {
+		accountNames[a.ID] = a.Name
+	}
+	categories, err := s.repos.Category.GetByUserID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	categoryNames := make(map[uuid.UUID]string, len(categories))
+	for _, c := range categories {
+		categoryNames[c.ID] = c.Name
+	}
+
+	txs, err := s.repos.Transaction.GetByDateRange(ctx, userID, time.Time{}, time.Now().AddDate(100, 0, 0), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = lf.CSVSeparator
+	if err := cw.Write([]string{"Дата", "Тип", "Счет", "Категория", "Описание", "Сумма"}); err != nil {
+		return 0, err
+	}
+	for _, tx := range txs {
+		amount := tx.Amount
+		if tx.Type == models.TransactionTypeExpense {
+			amount = amount.Neg()
+		}
+		row := []string{
+			lf.Date(tx.Date), string(tx.Type), accountNames[tx.AccountID], categoryNames[tx.CategoryID],
+			tx.Description, lf.Money(amount, tx.Currency),
+		}
+		if err := cw.Write(row); err != nil {
+			return 0, err
+		}
+	}
+	cw.Flush()
+	return len(txs), cw.Error()
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	fundamentalsBatch     = 50             // сколько бумаг обновляем за один проход
+	fundamentalsStaleTime = 24 * time.Hour // через сколько мультипликаторы запрашиваются заново
+	securityDividendLimit = 40             // сколько последних выплат показываем в карточке бумаги
+)
+
+type FundamentalsService interface {
+	// Attach добавляет к бумаге сохраненные мультипликаторы и историю дивидендов. если их еще
+	// не запрашивали, спрашивает провайдера; ошибка провайдера только логируется
+	Attach(ctx context.Context, security *models.Security) error
+	// Refresh запрашивает мультипликаторы и дивиденды у провайдера и сохраняет их
+	Refresh(ctx context.Context, security *models.Security) (*models.SecurityFundamentals, error)
+	// RefreshStale обновляет устаревшие мультипликаторы, начиная с бумаг из портфелей (фоновая задача)
+	RefreshStale(ctx context.Context) (int, error)
+}
+
+type fundamentalsService struct {
+	fundamentalsRepo repository.FundamentalsRepository
+	txManager        repository.TxManager
+	marketProvider   *market.MultiProvider
+}
+
+func NewFundamentalsService(fundamentalsRepo repository.FundamentalsRepository, txManager repository.TxManager, marketProvider *market.MultiProvider) FundamentalsService {
+	return &fundamentalsService{
+		fundamentalsRepo: fundamentalsRepo,
+		txManager:        txManager,
+		marketProvider:   marketProvider,
+	}
+}
+
+func (s *fundamentalsService) Attach(ctx context.Context, security *models.Security) error {
+	f, err := s.fundamentalsRepo.Get(ctx, security.ID)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		if !hasFundamentals(security) {
+			return nil
+		}
+		if f, err = s.Refresh(ctx, security); err != nil {
+			log.Printf("не удалось получить мультипликаторы %s: %v", security.Ticker, err)
+			return nil
+		}
+	}
+
+	if f.Dividends, err = s.fundamentalsRepo.GetDividends(ctx, security.ID, securityDividendLimit); err != nil {
+		return err
+	}
+	f.DividendYield = dividendYield(f.DividendPerShare, security.LastPrice)
+	security.Fundamentals = f
+	return nil
+}
+
+func (s *fundamentalsService) Refresh(ctx context.Context, security *models.Security) (*models.SecurityFundamentals, error) {
+	fetched, err := s.marketProvider.GetFundamentals(ctx, security.Ticker, security.Exchange)
+	if err != nil {
+		return nil, err
+	}
+	f := &models.SecurityFundamentals{}
+	if fetched != nil {
+		f.MarketCap, f.PERatio, f.EPS = fetched.MarketCap, fetched.PERatio, fetched.EPS
+	}
+
+	// дивиденды не у всех провайдеров: без них мультипликаторы все равно сохраняем
+	var dividends []models.Dividend
+	if security.Type != models.SecurityTypeCrypto {
+		dividends, err = s.marketProvider.GetDividends(ctx, security.Ticker, security.Exchange)
+		if err != nil {
+			log.Printf("не удалось получить дивиденды %s: %v", security.Ticker, err)
+			dividends = nil
+		}
+	}
+	f.DividendPerShare = trailingDividends(dividends, time.Now())
+
+	err = s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.fundamentalsRepo.Upsert(ctx, security.ID, f); err != nil {
+			return err
+		}
+		// пустой ответ не стирает уже сохраненную историю
+		if len(dividends) == 0 {
+			return nil
+		}
+		return s.fundamentalsRepo.ReplaceDividends(ctx, security.ID, dividends)
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.DividendYield = dividendYield(f.DividendPerShare, security.LastPrice)
+	return f, nil
+}
+
+func (s *fundamentalsService) RefreshStale(ctx context.Context) (int, error) {
+	securities, err := s.fundamentalsRepo.GetStale(ctx, time.Now().Add(-fundamentalsStaleTime), fundamentalsBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	refreshed := 0
+	for i := range securities {
+		if _, err := s.Refresh(ctx, &securities[i]); err != nil {
+			log.Printf("не удалось обновить мультипликаторы %s: %v", securities[i].Ticker, err)
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// hasFundamentals у биржевых акций, фондов и криптовалют бывают мультипликаторы или дивиденды
+func hasFundamentals(security *models.Security) bool {
+	if security.IsManual() {
+		return false
+	}
+	switch security.Type {
+	case models.SecurityTypeStock, models.SecurityTypePreferred, models.SecurityTypeDR, models.SecurityTypeETF, models.SecurityTypeCrypto:
+		return true
+	}
+	return false
+}
+
+// trailingDividends сумма дивидендов на акцию с экс-датой за последние 12 месяцев; nil - выплат не было
+func trailingDividends(dividends []models.Dividend, now time.Time) *decimal.Decimal {
+	from := now.AddDate(-1, 0, 0)
+	var total decimal.Decimal
+	for _, d := range dividends {
+		if d.ExDate.After(from) && !d.ExDate.After(now) {
+			total = total.Add(d.Amount)
+		}
+	}
+	if !total.IsPositive() {
+		return nil
+	}
+	return &total
+}
+
+// dividendYield дивиденды за 12 месяцев к текущей цене, %
+func dividendYield(perShare *decimal.Decimal, price decimal.Decimal) *decimal.Decimal {
+	if perShare == nil || !price.IsPositive() {
+		return nil
+	}
+	yield := perShare.Mul(hundred).Div(price).Round(2)
+	return &yield
+}
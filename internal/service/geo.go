@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+)
+
+var (
+	ErrInvalidCoordinates  = errors.New("latitude and longitude must be set together, latitude in [-90, 90], longitude in [-180, 180]")
+	ErrInvalidMapGroup     = errors.New("group_by must be geohash or city")
+	ErrInvalidMapPrecision = errors.New("precision must be between 1 and 9")
+)
+
+const (
+	geohashAlphabet         = "0123456789bcdefghjkmnpqrstuvwxyz"
+	geohashStoredPrecision  = 9 // ~5 м, точнее GPS телефона все равно не бывает
+	defaultGeohashPrecision = 5 // ~5 км: район города
+)
+
+// encodeGeohash geohash точки длины precision: биты долготы и широты по очереди, по 5 бит на символ
+func encodeGeohash(lat, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	var hash strings.Builder
+	bit, ch, even := 0, 0, true
+	for hash.Len() < precision {
+		rng, value := &latRange, lat
+		if even {
+			rng, value = &lonRange, lon
+		}
+		mid := (rng[0] + rng[1]) / 2
+		ch <<= 1
+		if value >= mid {
+			ch |= 1
+			rng[0] = mid
+		} else {
+			rng[1] = mid
+		}
+		even = !even
+
+		if bit++; bit == 5 {
+			hash.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+// validCoordinates координаты заданы парой и лежат в допустимых пределах; обе пустые - тоже валидно
+func validCoordinates(lat, lon *float64) bool {
+	if lat == nil || lon == nil {
+		return lat == nil && lon == nil
+	}
+	return *lat >= -90 && *lat <= 90 && *lon >= -180 && *lon <= 180
+}
+
+// transactionGeohash geohash для сохранения вместе с координатами операции; "" - координат нет
+func transactionGeohash(lat, lon *float64) string {
+	if lat == nil || lon == nil {
+		return ""
+	}
+	return encodeGeohash(*lat, *lon, geohashStoredPrecision)
+}
+
+// spendingMapGroup группировка карты трат: пустая - geohash
+func spendingMapGroup(groupBy models.SpendingMapGroup, precision int) (models.SpendingMapGroup, int, error) {
+	switch groupBy {
+	case "", models.SpendingMapByGeohash:
+		if precision == 0 {
+			precision = defaultGeohashPrecision
+		}
+		if precision < 1 || precision > geohashStoredPrecision {
+			return "", 0, ErrInvalidMapPrecision
+		}
+		return models.SpendingMapByGeohash, precision, nil
+	case models.SpendingMapByCity:
+		return models.SpendingMapByCity, 0, nil
+	}
+	return "", 0, ErrInvalidMapGroup
+}
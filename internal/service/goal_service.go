@@ -2,14 +2,30 @@ package service
 
 import (
 	"context"
+	"errors"
+	"log"
+	"math"
+	"math/rand/v2"
+	"sort"
 	"time"
 
+	"github.com/alligatorO15/fin-tracker/internal/market"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/repository"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
+var (
+	ErrGoalPortfolioNotFound  = errors.New("portfolio not found")
+	ErrGoalTrackedByPortfolio = errors.New("goal amount is tracked by the linked portfolio")
+	ErrGoalNotFound           = errors.New("goal not found")
+	ErrGoalNotPortfolio       = errors.New("projection is available only for goals linked to a portfolio")
+	ErrGoalNoTargetDate       = errors.New("goal has no target date in the future")
+	ErrGoalNoHistory          = errors.New("not enough price history of the portfolio holdings to simulate")
+	ErrInvalidSimulations     = errors.New("simulations must be between 100 and 10000")
+)
+
 type GoalService interface {
 	Create(ctx context.Context, userID uuid.UUID, input *models.GoalCreate) (*models.Goal, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Goal, error)
@@ -18,14 +34,41 @@ type GoalService interface {
 	AddContribution(ctx context.Context, goalID uuid.UUID, input *models.GoalContributionCreate) (*models.Goal, error)
 	GetContributions(ctx context.Context, goalID uuid.UUID) ([]models.GoalContribution, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	// SyncPortfolioGoals пересчитывает текущую сумму целей по стоимости привязанных портфелей
+	SyncPortfolioGoals(ctx context.Context) (int, error)
+	// Project моделирует стоимость портфеля цели методом Монте-Карло по исторической доходности
+	// и волатильности бумаг и считает вероятность достичь цели к сроку при разных ежемесячных взносах
+	Project(ctx context.Context, userID, id uuid.UUID, input *models.GoalProjectionRequest) (*models.GoalProjection, error)
 }
 
 type goalService struct {
-	goalRepo repository.GoalRepository
+	goalRepo         repository.GoalRepository
+	portfolioRepo    repository.PortfolioRepository
+	holdingRepo      repository.HoldingRepository
+	portfolioService PortfolioService
+	investment       InvestmentService
+	marketProvider   *market.MultiProvider
+	notifications    NotificationService
 }
 
-func NewGoalService(goalRepo repository.GoalRepository) GoalService {
-	return &goalService{goalRepo: goalRepo}
+func NewGoalService(
+	goalRepo repository.GoalRepository,
+	portfolioRepo repository.PortfolioRepository,
+	holdingRepo repository.HoldingRepository,
+	portfolioService PortfolioService,
+	investment InvestmentService,
+	marketProvider *market.MultiProvider,
+	notifications NotificationService,
+) GoalService {
+	return &goalService{
+		goalRepo:         goalRepo,
+		portfolioRepo:    portfolioRepo,
+		holdingRepo:      holdingRepo,
+		portfolioService: portfolioService,
+		investment:       investment,
+		marketProvider:   marketProvider,
+		notifications:    notifications,
+	}
 }
 
 func (s *goalService) Create(ctx context.Context, userID uuid.UUID, input *models.GoalCreate) (*models.Goal, error) {
@@ -44,12 +87,24 @@ func (s *goalService) Create(ctx context.Context, userID uuid.UUID, input *model
 		AutoContribute:   input.AutoContribute,
 		ContributeAmount: input.ContributeAmount,
 		ContributeFreq:   input.ContributeFreq,
+		PortfolioID:      input.PortfolioID,
+		ExpectedReturn:   input.ExpectedReturn,
+	}
+
+	if goal.PortfolioID != nil {
+		if err := s.checkPortfolio(ctx, userID, *goal.PortfolioID); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := s.goalRepo.Create(ctx, goal); err != nil {
 		return nil, err
 	}
 
+	if goal.PortfolioID != nil {
+		s.syncGoal(ctx, goal)
+	}
+
 	goal, err := s.goalRepo.GetByID(ctx, goal.ID)
 	if err != nil {
 		return nil, err
@@ -108,9 +163,59 @@ func (s *goalService) enrichGoal(goal *models.Goal) {
 			}
 		}
 	}
+
+	// с ожидаемой доходностью часть пути проходит за счет роста капитала
+	if goal.TargetDate != nil && goal.ExpectedReturn != nil && goal.ExpectedReturn.IsPositive() {
+		months := time.Until(*goal.TargetDate).Hours() / 24 / 30.44
+		if months <= 0 {
+			return
+		}
+
+		// годовая доходность -> эквивалентная месячная
+		monthlyRate := math.Pow(1+goal.ExpectedReturn.InexactFloat64()/100, 1.0/12) - 1
+		growth := math.Pow(1+monthlyRate, months)
+		annuity := (growth - 1) / monthlyRate // во что превратится 1 в месяц к целевой дате
+
+		current := goal.CurrentAmount.InexactFloat64()
+		target := goal.TargetAmount.InexactFloat64()
+
+		required := (target - current*growth) / annuity
+		if required < 0 {
+			required = 0
+		}
+		goal.RequiredMonthly = decimal.NewFromFloat(required).Round(2)
+
+		projected := current * growth
+		if goal.AutoContribute {
+			projected += monthlyContribution(goal).InexactFloat64() * annuity
+		}
+		goal.ProjectedAmount = decimal.NewFromFloat(projected).Round(2)
+	}
+}
+
+// monthlyContribution приводит автовзнос к месячному
+func monthlyContribution(goal *models.Goal) decimal.Decimal {
+	switch goal.ContributeFreq {
+	case "daily":
+		return goal.ContributeAmount.Mul(decimal.NewFromFloat(30.44))
+	case "weekly":
+		return goal.ContributeAmount.Mul(decimal.NewFromFloat(30.44 / 7))
+	default:
+		return goal.ContributeAmount
+	}
 }
 
 func (s *goalService) Update(ctx context.Context, id uuid.UUID, update *models.GoalUpdate) (*models.Goal, error) {
+	if update.PortfolioID != nil && !update.UnlinkPortfolio {
+		existing, err := s.goalRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkPortfolio(ctx, existing.UserID, *update.PortfolioID); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := s.goalRepo.Update(ctx, id, update); err != nil {
 		return nil, err
 	}
@@ -118,11 +223,23 @@ func (s *goalService) Update(ctx context.Context, id uuid.UUID, update *models.G
 	if err != nil {
 		return nil, err
 	}
+	if update.PortfolioID != nil && goal.PortfolioID != nil {
+		s.syncGoal(ctx, goal)
+	}
 	s.enrichGoal(goal)
 	return goal, nil
 }
 
 func (s *goalService) AddContribution(ctx context.Context, goalID uuid.UUID, input *models.GoalContributionCreate) (*models.Goal, error) {
+	existing, err := s.goalRepo.GetByID(ctx, goalID)
+	if err != nil {
+		return nil, err
+	}
+	// сумма такой цели - стоимость портфеля, взносы делаются покупками в портфеле
+	if existing.PortfolioID != nil {
+		return nil, ErrGoalTrackedByPortfolio
+	}
+
 	contribution := &models.GoalContribution{
 		Amount: input.Amount,
 		Date:   input.Date,
@@ -137,6 +254,9 @@ func (s *goalService) AddContribution(ctx context.Context, goalID uuid.UUID, inp
 	if err != nil {
 		return nil, err
 	}
+	if existing.Status != models.GoalStatusCompleted && goal.Status == models.GoalStatusCompleted {
+		s.notifyCompleted(ctx, goal)
+	}
 	s.enrichGoal(goal)
 	return goal, nil
 }
@@ -148,3 +268,249 @@ func (s *goalService) GetContributions(ctx context.Context, goalID uuid.UUID) ([
 func (s *goalService) Delete(ctx context.Context, id uuid.UUID) error {
 	return s.goalRepo.Delete(ctx, id)
 }
+
+func (s *goalService) SyncPortfolioGoals(ctx context.Context) (int, error) {
+	goals, err := s.goalRepo.GetPortfolioLinked(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// сначала обновляем котировки, каждый портфель один раз
+	refreshed := make(map[uuid.UUID]bool)
+	for _, g := range goals {
+		if !refreshed[*g.PortfolioID] {
+			refreshed[*g.PortfolioID] = true
+			if err := s.portfolioService.RefreshPrices(ctx, *g.PortfolioID); err != nil {
+				log.Printf("не удалось обновить цены портфеля %s: %v", g.PortfolioID, err)
+			}
+		}
+	}
+
+	synced := 0
+	for i := range goals {
+		if s.syncGoal(ctx, &goals[i]) {
+			synced++
+		}
+	}
+	return synced, nil
+}
+
+// syncGoal выставляет текущую сумму цели равной стоимости портфеля в валюте цели
+func (s *goalService) syncGoal(ctx context.Context, goal *models.Goal) bool {
+	holdings, err := s.holdingRepo.GetByPortfolioID(ctx, *goal.PortfolioID)
+	if err != nil {
+		log.Printf("не удалось получить позиции портфеля %s: %v", goal.PortfolioID, err)
+		return false
+	}
+
+	rates := make(map[string]decimal.Decimal)
+	var total decimal.Decimal
+	for _, h := range holdings {
+		value := h.CurrentValue
+		currency := h.ValueCurrency(goal.Currency)
+
+		if currency != goal.Currency {
+			rate, ok := rates[currency]
+			if !ok {
+				rate, err = s.marketProvider.GetCurrencyRate(ctx, currency, goal.Currency)
+				if err != nil {
+					// без курса сумма будет неверной - лучше оставить прошлое значение
+					log.Printf("нет курса %s/%s для цели %s: %v", currency, goal.Currency, goal.ID, err)
+					return false
+				}
+				rates[currency] = rate
+			}
+			value = value.Mul(rate)
+		}
+		total = total.Add(value)
+	}
+
+	total = total.Round(2)
+	if err := s.goalRepo.SetAmount(ctx, goal.ID, total); err != nil {
+		log.Printf("не удалось обновить цель %s: %v", goal.ID, err)
+		return false
+	}
+	// бд отмечает цель выполненной тем же условием
+	if goal.Status == models.GoalStatusActive && total.GreaterThanOrEqual(goal.TargetAmount) {
+		goal.Status = models.GoalStatusCompleted
+		s.notifyCompleted(ctx, goal)
+	}
+	goal.CurrentAmount = total
+	return true
+}
+
+func (s *goalService) notifyCompleted(ctx context.Context, goal *models.Goal) {
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID:   goal.UserID,
+		Type:     models.NotificationGoalCompleted,
+		Title:    "Цель «" + goal.Name + "» достигнута",
+		Body:     "Накоплено " + goal.TargetAmount.StringFixed(2) + " " + goal.Currency,
+		EntityID: &goal.ID,
+		DedupKey: "goal_completed:" + goal.ID.String(),
+	})
+}
+
+func (s *goalService) checkPortfolio(ctx context.Context, userID, portfolioID uuid.UUID) error {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return ErrGoalPortfolioNotFound
+	}
+	return nil
+}
+
+const (
+	defaultSimulations = 2000
+	minSimulations     = 100
+	maxSimulations     = 10000
+	maxProjectionYears = 50
+	minHistoryDays     = 20 // меньше - доходность и волатильность ничего не говорят
+)
+
+func (s *goalService) Project(ctx context.Context, userID, id uuid.UUID, input *models.GoalProjectionRequest) (*models.GoalProjection, error) {
+	goal, err := s.goalRepo.GetByID(ctx, id)
+	if err != nil || goal.UserID != userID {
+		return nil, ErrGoalNotFound
+	}
+	if goal.PortfolioID == nil {
+		return nil, ErrGoalNotPortfolio
+	}
+	if goal.TargetDate == nil || !goal.TargetDate.After(time.Now()) {
+		return nil, ErrGoalNoTargetDate
+	}
+
+	simulations := input.Simulations
+	if simulations == 0 {
+		simulations = defaultSimulations
+	}
+	if simulations < minSimulations || simulations > maxSimulations {
+		return nil, ErrInvalidSimulations
+	}
+
+	months := int(math.Ceil(time.Until(*goal.TargetDate).Hours() / 24 / 30.44))
+	if months > maxProjectionYears*12 {
+		months = maxProjectionYears * 12
+	}
+
+	// дневные стоимости портфеля за год при текущем составе - в валюте цели
+	analytics, err := s.investment.GetPortfolioAnalytics(ctx, *goal.PortfolioID, goal.Currency)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]float64, 0, len(analytics.ValueHistory))
+	for _, point := range analytics.ValueHistory {
+		values = append(values, point.Value.InexactFloat64())
+	}
+	mean, stdev, n := logReturnStats(values)
+	if n < minHistoryDays {
+		return nil, ErrGoalNoHistory
+	}
+
+	// дневные лог-доходности -> месячные
+	monthlyMean := mean * tradingDaysPerYear / 12
+	monthlyStdev := stdev * math.Sqrt(tradingDaysPerYear/12)
+
+	projection := &models.GoalProjection{
+		GoalID:         goal.ID,
+		Currency:       goal.Currency,
+		CurrentAmount:  goal.CurrentAmount,
+		TargetAmount:   goal.TargetAmount,
+		TargetDate:     *goal.TargetDate,
+		Months:         months,
+		Simulations:    simulations,
+		ExpectedReturn: decimal.NewFromFloat((math.Exp(mean*tradingDaysPerYear) - 1) * 100).Round(2),
+		Volatility:     decimal.NewFromFloat(stdev * math.Sqrt(tradingDaysPerYear) * 100).Round(2),
+		HistoryDays:    n,
+	}
+
+	for _, contribution := range s.projectionScenarios(goal, input.Contributions) {
+		projection.Scenarios = append(projection.Scenarios, simulateGoal(
+			goal.CurrentAmount.InexactFloat64(), goal.TargetAmount.InexactFloat64(), contribution,
+			months, simulations, monthlyMean, monthlyStdev,
+		))
+	}
+	return projection, nil
+}
+
+// projectionScenarios взносы для сравнения: заданные пользователем или без взносов, текущий автовзнос и необходимый
+func (s *goalService) projectionScenarios(goal *models.Goal, requested []decimal.Decimal) []decimal.Decimal {
+	candidates := requested
+	if len(candidates) == 0 {
+		s.enrichGoal(goal)
+		candidates = []decimal.Decimal{decimal.Zero}
+		if goal.AutoContribute {
+			candidates = append(candidates, monthlyContribution(goal).Round(2))
+		}
+		candidates = append(candidates, goal.RequiredMonthly.Round(2))
+	}
+
+	var scenarios []decimal.Decimal
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if c.IsNegative() || seen[c.String()] {
+			continue
+		}
+		seen[c.String()] = true
+		scenarios = append(scenarios, c)
+	}
+	return scenarios
+}
+
+// simulateGoal прогоняет траектории стоимости: каждый месяц портфель растет на случайную
+// лог-доходность из нормального распределения, затем добавляется взнос. генератор с постоянным
+// зерном - все сценарии идут по одним и тем же траекториям рынка и сравнимы между собой
+func simulateGoal(current, target float64, contribution decimal.Decimal, months, simulations int, mean, stdev float64) models.GoalProjectionScenario {
+	rng := rand.New(rand.NewPCG(1, 2))
+	monthly := contribution.InexactFloat64()
+
+	finals := make([]float64, simulations)
+	reached := 0
+	for i := range finals {
+		value := current
+		for m := 0; m < months; m++ {
+			value = value*math.Exp(mean+stdev*rng.NormFloat64()) + monthly
+		}
+		finals[i] = value
+		if value >= target {
+			reached++
+		}
+	}
+	sort.Float64s(finals)
+
+	percentile := func(p float64) decimal.Decimal {
+		return decimal.NewFromFloat(finals[int(p*float64(len(finals)-1))]).Round(2)
+	}
+	return models.GoalProjectionScenario{
+		MonthlyContribution: contribution,
+		Probability:         math.Round(float64(reached)/float64(simulations)*1000) / 10,
+		P10:                 percentile(0.1),
+		P50:                 percentile(0.5),
+		P90:                 percentile(0.9),
+	}
+}
+
+// logReturnStats среднее и стандартное отклонение дневных лог-доходностей и их количество
+func logReturnStats(values []float64) (float64, float64, int) {
+	var returns []float64
+	for i := 1; i < len(values); i++ {
+		if values[i-1] > 0 && values[i] > 0 {
+			returns = append(returns, math.Log(values[i]/values[i-1]))
+		}
+	}
+	if len(returns) < 2 {
+		return 0, 0, len(returns)
+	}
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+
+	return mean, math.Sqrt(variance), len(returns)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+)
+
+const (
+	readinessTimeout = 5 * time.Second // сколько ждем ответа от каждой зависимости при проверке
+	marketCheckTTL   = time.Minute     // как часто /health на самом деле опрашивает провайдеров котировок
+)
+
+// Pinger зависимость, доступность которой проверяется при readiness (например, Redis для лимитов)
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type ReadinessStatus struct {
+	Ready    bool   `json:"ready"`
+	Database string `json:"database"`
+	Redis    string `json:"redis,omitempty"`
+}
+
+// HealthStatus liveness и последнее известное состояние провайдеров котировок (на ответ не влияет)
+type HealthStatus struct {
+	Status          string    `json:"status"`
+	Market          string    `json:"market"`
+	MarketCheckedAt time.Time `json:"market_checked_at"`
+}
+
+type HealthService interface {
+	// Ready проверяет только собственные зависимости: бд и Redis (nil - Redis не используется).
+	// внешние провайдеры сюда не входят, иначе их сбой выводит экземпляры из балансировки
+	Ready(ctx context.Context, redis Pinger) *ReadinessStatus
+	// Health состояние процесса и провайдеров котировок; провайдеры опрашиваются не чаще marketCheckTTL
+	Health(ctx context.Context) *HealthStatus
+	// Providers статистика запросов, лимиты и состояние предохранителя каждого провайдера котировок
+	Providers() []market.ProviderStatus
+	// PingProviders проверяет каждый провайдер котировок: имя -> "ok" или текст ошибки
+	PingProviders(ctx context.Context) map[string]string
+}
+
+type healthService struct {
+	repos          *repository.Repositories
+	marketProvider *market.MultiProvider
+
+	mu              sync.Mutex
+	market          string
+	marketCheckedAt time.Time
+}
+
+func NewHealthService(repos *repository.Repositories, marketProvider *market.MultiProvider) HealthService {
+	return &healthService{
+		repos:          repos,
+		marketProvider: marketProvider,
+	}
+}
+
+func (s *healthService) Ready(ctx context.Context, redis Pinger) *ReadinessStatus {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	status := &ReadinessStatus{Ready: true, Database: "ok"}
+
+	if err := s.repos.Ping(ctx); err != nil {
+		status.Ready = false
+		status.Database = err.Error()
+	}
+	if redis != nil {
+		status.Redis = "ok"
+		if err := redis.Ping(ctx); err != nil {
+			status.Ready = false
+			status.Redis = err.Error()
+		}
+	}
+
+	return status
+}
+
+func (s *healthService) Health(ctx context.Context) *HealthStatus {
+	// под мьютексом: частые пробы ждут одну проверку, а не опрашивают провайдеров параллельно
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.marketCheckedAt) >= marketCheckTTL {
+		pingCtx, cancel := context.WithTimeout(ctx, readinessTimeout)
+		s.market = "ok"
+		if err := s.marketProvider.Ping(pingCtx); err != nil {
+			s.market = err.Error()
+		}
+		cancel()
+		s.marketCheckedAt = time.Now()
+	}
+
+	return &HealthStatus{Status: "ok", Market: s.market, MarketCheckedAt: s.marketCheckedAt}
+}
+
+func (s *healthService) Providers() []market.ProviderStatus {
+	return s.marketProvider.Status()
+}
+
+func (s *healthService) PingProviders(ctx context.Context) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	result := make(map[string]string)
+	for name, err := range s.marketProvider.PingEach(ctx) {
+		result[name] = "ok"
+		if err != nil {
+			result[name] = err.Error()
+		}
+	}
+	return result
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrHoldingNotFound    = errors.New("holding not found")
+	ErrInvalidHoldingTags = errors.New("at most 20 tags of up to 50 characters are allowed")
+	ErrInvalidTargetPrice = errors.New("target price must be positive")
+)
+
+const (
+	maxHoldingTags   = 20
+	maxHoldingTagLen = 50
+)
+
+type HoldingMetadataService interface {
+	// Set заменяет заметки, целевую цену и теги позиции
+	Set(ctx context.Context, userID, portfolioID, securityID uuid.UUID, input *models.HoldingMetadataInput) (*models.HoldingMetadata, error)
+	Delete(ctx context.Context, userID, portfolioID, securityID uuid.UUID) error
+}
+
+type holdingMetadataService struct {
+	metadataRepo  repository.HoldingMetadataRepository
+	portfolioRepo repository.PortfolioRepository
+	holdingRepo   repository.HoldingRepository
+}
+
+func NewHoldingMetadataService(metadataRepo repository.HoldingMetadataRepository, portfolioRepo repository.PortfolioRepository, holdingRepo repository.HoldingRepository) HoldingMetadataService {
+	return &holdingMetadataService{
+		metadataRepo:  metadataRepo,
+		portfolioRepo: portfolioRepo,
+		holdingRepo:   holdingRepo,
+	}
+}
+
+func (s *holdingMetadataService) Set(ctx context.Context, userID, portfolioID, securityID uuid.UUID, input *models.HoldingMetadataInput) (*models.HoldingMetadata, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+	if _, err := s.holdingRepo.GetByPortfolioAndSecurity(ctx, portfolioID, securityID); err != nil {
+		return nil, ErrHoldingNotFound
+	}
+	if input.TargetPrice != nil && !input.TargetPrice.IsPositive() {
+		return nil, ErrInvalidTargetPrice
+	}
+	tags, err := normalizeHoldingTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &models.HoldingMetadata{
+		PortfolioID: portfolioID,
+		SecurityID:  securityID,
+		Notes:       strings.TrimSpace(input.Notes),
+		TargetPrice: input.TargetPrice,
+		Tags:        tags,
+	}
+	if err := s.metadataRepo.Upsert(ctx, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+func (s *holdingMetadataService) Delete(ctx context.Context, userID, portfolioID, securityID uuid.UUID) error {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return ErrPortfolioNotFound
+	}
+	return s.metadataRepo.Delete(ctx, portfolioID, securityID)
+}
+
+// normalizeHoldingTags обрезает пробелы, убирает пустые и повторы (без учета регистра), порядок сохраняется
+func normalizeHoldingTags(tags []string) ([]string, error) {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxHoldingTagLen {
+			return nil, ErrInvalidHoldingTags
+		}
+		key := strings.ToLower(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, tag)
+	}
+	if len(result) > maxHoldingTags {
+		return nil, ErrInvalidHoldingTags
+	}
+	return result, nil
+}
+
+// attachHoldingMetadata подставляет заметки пользователя в позиции портфеля
+func attachHoldingMetadata(ctx context.Context, metadataRepo repository.HoldingMetadataRepository, portfolioID uuid.UUID, holdings []models.Holding) error {
+	if len(holdings) == 0 {
+		return nil
+	}
+	items, err := metadataRepo.GetByPortfolioID(ctx, portfolioID)
+	if err != nil {
+		return err
+	}
+
+	bySecurity := make(map[uuid.UUID]*models.HoldingMetadata, len(items))
+	for i := range items {
+		bySecurity[items[i].SecurityID] = &items[i]
+	}
+	for i := range holdings {
+		holdings[i].Metadata = bySecurity[holdings[i].SecurityID]
+	}
+	return nil
+}
+
+// filterHoldings позиции, подходящие под фильтр по заметкам
+func filterHoldings(holdings []models.Holding, filter *models.HoldingFilter) []models.Holding {
+	if filter == nil {
+		return holdings
+	}
+	result := make([]models.Holding, 0, len(holdings))
+	for i := range holdings {
+		if filter.Match(&holdings[i]) {
+			result = append(result, holdings[i])
+		}
+	}
+	return result
+}
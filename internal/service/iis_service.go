@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrNotIIS               = errors.New("portfolio is not an IIS")
+	ErrIISCurrency          = errors.New("IIS portfolio must be in RUB")
+	ErrIISCashFlowNotFound  = errors.New("IIS cash flow not found")
+	ErrInvalidIISAmount     = errors.New("IIS cash flow amount must be positive")
+	ErrInvalidIISDate       = errors.New("IIS cash flow date is before the account was opened")
+	ErrIISLimitExceeded     = errors.New("annual IIS contribution limit of 1 000 000 RUB exceeded")
+	ErrIISEarlyWithdrawal   = errors.New("withdrawal within 3 years closes the IIS and voids its tax benefits, resend with confirm=true")
+	ErrIISWithdrawalTooHigh = errors.New("withdrawal exceeds IIS balance")
+)
+
+var (
+	iisAnnualLimit   = decimal.NewFromInt(1_000_000)
+	iisDeductionBase = decimal.NewFromInt(400_000) // взносы сверх этой суммы вычет типа А не увеличивают
+	iisDeductionRate = decimal.NewFromFloat(0.13)
+)
+
+// сколько лет ИИС должен быть открыт, чтобы сохранить льготы
+const iisMinYears = 3
+
+const iisEarlyWithdrawalWarning = "ИИС открыт меньше 3 лет: вывод денег закрывает счет, вычет типа А придется вернуть, освобождение типа Б не применяется"
+
+type IISService interface {
+	// GetSummary взносы за год (0 - текущий) относительно лимита и ожидаемый вычет
+	GetSummary(ctx context.Context, userID, portfolioID uuid.UUID, year int) (*models.IISSummary, error)
+	// AddCashFlow записывает пополнение или вывод; досрочный вывод только с input.Confirm
+	AddCashFlow(ctx context.Context, userID, portfolioID uuid.UUID, input *models.IISCashFlowCreate) (*models.IISCashFlow, error)
+	DeleteCashFlow(ctx context.Context, userID, portfolioID, id uuid.UUID) error
+}
+
+type iisService struct {
+	iisRepo       repository.IISRepository
+	portfolioRepo repository.PortfolioRepository
+}
+
+func NewIISService(iisRepo repository.IISRepository, portfolioRepo repository.PortfolioRepository) IISService {
+	return &iisService{
+		iisRepo:       iisRepo,
+		portfolioRepo: portfolioRepo,
+	}
+}
+
+// getIIS портфель пользователя, который ведется как ИИС
+func (s *iisService) getIIS(ctx context.Context, userID, portfolioID uuid.UUID) (*models.Portfolio, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+	if portfolio.IISType == nil {
+		return nil, ErrNotIIS
+	}
+	return portfolio, nil
+}
+
+func (s *iisService) GetSummary(ctx context.Context, userID, portfolioID uuid.UUID, year int) (*models.IISSummary, error) {
+	portfolio, err := s.getIIS(ctx, userID, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	flows, err := s.iisRepo.GetByPortfolioID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	if year == 0 {
+		year = time.Now().Year()
+	}
+	lockedUntil := iisLockedUntil(portfolio)
+	summary := &models.IISSummary{
+		PortfolioID: portfolioID,
+		Type:        *portfolio.IISType,
+		OpenedAt:    iisOpenedAt(portfolio),
+		LockedUntil: lockedUntil,
+		CanWithdraw: !time.Now().Before(lockedUntil),
+		Year:        year,
+		AnnualLimit: iisAnnualLimit,
+		CashFlows:   []models.IISCashFlow{},
+	}
+
+	for _, f := range flows {
+		if f.Type == models.IISContribution {
+			summary.TotalContributed = summary.TotalContributed.Add(f.Amount)
+		} else {
+			summary.TotalWithdrawn = summary.TotalWithdrawn.Add(f.Amount)
+		}
+		if f.Date.Year() == year {
+			summary.CashFlows = append(summary.CashFlows, f)
+		}
+	}
+
+	summary.Contributed = iisContributions(flows, year)
+	summary.RemainingLimit = decimal.Max(iisAnnualLimit.Sub(summary.Contributed), decimal.Zero)
+	if *portfolio.IISType == models.IISTypeA {
+		summary.ExpectedDeduction = iisDeduction(summary.Contributed)
+	}
+	return summary, nil
+}
+
+func (s *iisService) AddCashFlow(ctx context.Context, userID, portfolioID uuid.UUID, input *models.IISCashFlowCreate) (*models.IISCashFlow, error) {
+	portfolio, err := s.getIIS(ctx, userID, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	if !input.Amount.IsPositive() {
+		return nil, ErrInvalidIISAmount
+	}
+
+	date := truncateDay(time.Now())
+	if input.Date != nil {
+		date = truncateDay(*input.Date)
+	}
+	if date.Before(iisOpenedAt(portfolio)) {
+		return nil, ErrInvalidIISDate
+	}
+
+	flows, err := s.iisRepo.GetByPortfolioID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	flow := &models.IISCashFlow{
+		PortfolioID: portfolioID,
+		Type:        input.Type,
+		Amount:      input.Amount.Round(2),
+		Date:        date,
+		Notes:       input.Notes,
+	}
+
+	switch input.Type {
+	case models.IISContribution:
+		if iisContributions(flows, date.Year()).Add(flow.Amount).GreaterThan(iisAnnualLimit) {
+			return nil, ErrIISLimitExceeded
+		}
+	case models.IISWithdrawal:
+		var balance decimal.Decimal
+		for _, f := range flows {
+			if f.Type == models.IISContribution {
+				balance = balance.Add(f.Amount)
+			} else {
+				balance = balance.Sub(f.Amount)
+			}
+		}
+		if flow.Amount.GreaterThan(balance) {
+			return nil, ErrIISWithdrawalTooHigh
+		}
+		if date.Before(iisLockedUntil(portfolio)) {
+			if !input.Confirm {
+				return nil, ErrIISEarlyWithdrawal
+			}
+			flow.Warning = iisEarlyWithdrawalWarning
+		}
+	}
+
+	if err := s.iisRepo.Create(ctx, flow); err != nil {
+		return nil, err
+	}
+	return flow, nil
+}
+
+func (s *iisService) DeleteCashFlow(ctx context.Context, userID, portfolioID, id uuid.UUID) error {
+	if _, err := s.getIIS(ctx, userID, portfolioID); err != nil {
+		return err
+	}
+	flow, err := s.iisRepo.GetByID(ctx, id)
+	if err != nil || flow.PortfolioID != portfolioID {
+		return ErrIISCashFlowNotFound
+	}
+	return s.iisRepo.Delete(ctx, id)
+}
+
+// iisTaxInfo раздел ИИС налогового отчета за год; netGain - прибыль от продаж за этот год
+func iisTaxInfo(portfolio *models.Portfolio, flows []models.IISCashFlow, year int, netGain decimal.Decimal) *models.IISTaxInfo {
+	lockedUntil := iisLockedUntil(portfolio)
+	info := &models.IISTaxInfo{
+		Type:          *portfolio.IISType,
+		LockedUntil:   lockedUntil,
+		Contributions: iisContributions(flows, year),
+	}
+	for _, f := range flows {
+		if f.Type == models.IISWithdrawal && f.Date.Before(lockedUntil) {
+			info.EarlyWithdrawal = true
+		}
+	}
+	if info.EarlyWithdrawal {
+		return info
+	}
+
+	switch *portfolio.IISType {
+	case models.IISTypeA:
+		info.DeductionBase = decimal.Min(info.Contributions, iisDeductionBase)
+		info.ExpectedDeduction = iisDeduction(info.Contributions)
+	case models.IISTypeB:
+		info.ExemptGain = netGain
+	}
+	return info
+}
+
+func iisContributions(flows []models.IISCashFlow, year int) decimal.Decimal {
+	var total decimal.Decimal
+	for _, f := range flows {
+		if f.Type == models.IISContribution && f.Date.Year() == year {
+			total = total.Add(f.Amount)
+		}
+	}
+	return total
+}
+
+// iisDeduction вычет типа А: 13% от взносов за год, но не больше чем с 400 000 ₽
+func iisDeduction(contributed decimal.Decimal) decimal.Decimal {
+	return decimal.Min(contributed, iisDeductionBase).Mul(iisDeductionRate).Round(2)
+}
+
+func iisOpenedAt(portfolio *models.Portfolio) time.Time {
+	if portfolio.IISOpenedAt != nil {
+		return truncateDay(*portfolio.IISOpenedAt)
+	}
+	return truncateDay(portfolio.CreatedAt)
+}
+
+func iisLockedUntil(portfolio *models.Portfolio) time.Time {
+	return iisOpenedAt(portfolio).AddDate(iisMinYears, 0, 0)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// couponHoldingRepo отдает заранее заданные позиции портфеля, остальные методы не нужны
+type couponHoldingRepo struct {
+	repository.HoldingRepository
+	holdings []models.Holding
+}
+
+func (r *couponHoldingRepo) GetByPortfolioID(ctx context.Context, portfolioID uuid.UUID) ([]models.Holding, error) {
+	return r.holdings, nil
+}
+
+// couponPortfolioRepo отдает один портфель, остальные методы не нужны
+type couponPortfolioRepo struct {
+	repository.PortfolioRepository
+	portfolio *models.Portfolio
+}
+
+func (r *couponPortfolioRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Portfolio, error) {
+	if id != r.portfolio.ID {
+		return nil, pgx.ErrNoRows
+	}
+	return r.portfolio, nil
+}
+
+func TestGetUpcomingCouponsEstimatesBondByRate(t *testing.T) {
+	now := time.Now()
+	maturity := time.Date(now.Year()+2, now.Month(), 15, 0, 0, 0, 0, time.UTC)
+	face := decimal.NewFromInt(1000)
+	rate := decimal.NewFromInt(12)
+	freq := 2
+
+	bond := &models.Security{
+		ID:           uuid.New(),
+		Ticker:       "SU26238RMFS4",
+		Type:         models.SecurityTypeBond,
+		Exchange:     models.ExchangeMOEX,
+		Currency:     "RUB",
+		FaceValue:    &face,
+		CouponRate:   &rate,
+		CouponFreq:   &freq,
+		MaturityDate: &maturity,
+	}
+	stock := &models.Security{ID: uuid.New(), Ticker: "SBER", Type: models.SecurityTypeStock, Exchange: models.ExchangeMOEX}
+
+	portfolio := &models.Portfolio{ID: uuid.New(), UserID: uuid.New(), Currency: "RUB"}
+
+	// без провайдеров биржи графика купонов нет - остается оценка по ставке и дате погашения
+	svc := &investmentService{
+		portfolioRepo: &couponPortfolioRepo{portfolio: portfolio},
+		holdingRepo: &couponHoldingRepo{holdings: []models.Holding{
+			{SecurityID: bond.ID, Quantity: decimal.NewFromInt(10), Security: bond},
+			{SecurityID: stock.ID, Quantity: decimal.NewFromInt(100), Security: stock},
+		}},
+		marketProvider: market.NewMultiProvider(&config.Config{}),
+	}
+
+	payments, err := svc.GetUpcomingCoupons(context.Background(), portfolio.UserID, portfolio.ID)
+	if err != nil {
+		t.Fatalf("GetUpcomingCoupons: %v", err)
+	}
+
+	// купон раз в полгода до погашения через два года: 4 или 5 выплат в зависимости от дня месяца
+	if len(payments) < 4 || len(payments) > 5 {
+		t.Fatalf("payments = %d, want 4-5", len(payments))
+	}
+	for i, p := range payments {
+		if p.SecurityID != bond.ID {
+			t.Errorf("payment %d: security %s, want bond", i, p.SecurityID)
+		}
+		if !p.IsEstimated {
+			t.Errorf("payment %d: not marked as estimated", i)
+		}
+		// 1000 × 12% / 2 = 60 на облигацию, 600 на 10 штук
+		if !p.AmountPerBond.Equal(decimal.NewFromInt(60)) || !p.TotalAmount.Equal(decimal.NewFromInt(600)) {
+			t.Errorf("payment %d: amount %s / total %s, want 60 / 600", i, p.AmountPerBond, p.TotalAmount)
+		}
+		if p.Date.Day() != 15 {
+			t.Errorf("payment %d: date %s drifted from the 15th", i, p.Date.Format("2006-01-02"))
+		}
+		if i > 0 && p.Date.Before(payments[i-1].Date) {
+			t.Errorf("payments are not sorted by date")
+		}
+	}
+	if last := payments[len(payments)-1].Date; !last.Equal(maturity) {
+		t.Errorf("last coupon = %s, want maturity %s", last.Format("2006-01-02"), maturity.Format("2006-01-02"))
+	}
+}
+
+func TestGetUpcomingCouponsRejectsOtherUsersPortfolio(t *testing.T) {
+	portfolio := &models.Portfolio{ID: uuid.New(), UserID: uuid.New(), Currency: "RUB"}
+	svc := &investmentService{
+		portfolioRepo:  &couponPortfolioRepo{portfolio: portfolio},
+		holdingRepo:    &couponHoldingRepo{},
+		marketProvider: market.NewMultiProvider(&config.Config{}),
+	}
+
+	if _, err := svc.GetUpcomingCoupons(context.Background(), uuid.New(), portfolio.ID); err != ErrPortfolioNotFound {
+		t.Errorf("GetUpcomingCoupons for another user: err = %v, want ErrPortfolioNotFound", err)
+	}
+	if _, err := svc.GetIncomeCalendar(context.Background(), uuid.New(), portfolio.ID, ""); err != ErrPortfolioNotFound {
+		t.Errorf("GetIncomeCalendar for another user: err = %v, want ErrPortfolioNotFound", err)
+	}
+}
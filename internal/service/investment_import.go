@@ -0,0 +1,412 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidBatchCSV   = errors.New("invalid transactions CSV")
+	ErrBatchTooLarge     = errors.New("too many transactions in one batch")
+	ErrInvalidBatchRow   = errors.New("type and date are required, broker_ref is limited to 100 characters")
+	ErrBatchQuantityZero = errors.New("quantity must be set explicitly for expiration, amortization and redemption in a batch")
+)
+
+// maxBatchTransactions строк в одном пакете
+const maxBatchTransactions = 5000
+
+// errBatchRollback откатывает транзакцию импорта: dry_run или ошибки в строках
+var errBatchRollback = errors.New("batch rolled back")
+
+// ошибки, относящиеся к одной строке пакета: строка помечается invalid, остальные проверяются дальше
+var batchRowErrors = []error{
+	ErrInvalidBatchRow, ErrBatchQuantityZero,
+	ErrSecurityRequired, ErrSecurityNotFound, ErrInvalidRewardInput, ErrInvalidExchangeRate,
+	ErrInvalidQuantity, ErrLotSizeMismatch, ErrQuantityPrecision, ErrCurrencyMismatch,
+	ErrExchangeRateRequired, ErrExchangeRateUnavailable, ErrNotDerivative, ErrNotBond,
+	ErrInvalidPrincipal, ErrInsufficientShares,
+}
+
+func isBatchRowError(err error) bool {
+	for _, e := range batchRowErrors {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+var investmentTransactionTypes = map[models.InvestmentTransactionType]bool{
+	models.InvestmentTransactionTypeBuy:           true,
+	models.InvestmentTransactionTypeSell:          true,
+	models.InvestmentTransactionTypeDividend:      true,
+	models.InvestmentTransactionTypeCoupon:        true,
+	models.InvestmentTransactionTypeSplit:         true,
+	models.InvestmentTransactionTypeTransferIn:    true,
+	models.InvestmentTransactionTypeTransferOut:   true,
+	models.InvestmentTransactionTypeFee:           true,
+	models.InvestmentTransactionTypeTax:           true,
+	models.InvestmentTransactionTypeStakingReward: true,
+	models.InvestmentTransactionTypeAirdrop:       true,
+	models.InvestmentTransactionTypeExpiration:    true,
+	models.InvestmentTransactionTypeAmortization:  true,
+	models.InvestmentTransactionTypeRedemption:    true,
+}
+
+// validateBatchRow то, что у одиночной сделки проверяет binding
+func validateBatchRow(input *models.InvestmentTransactionCreate) error {
+	if !investmentTransactionTypes[input.Type] || input.Date.IsZero() || len(input.BrokerRef) > 100 {
+		return ErrInvalidBatchRow
+	}
+	// без количества эти операции берут всю позицию, а позиции пакета пересчитываются только в конце
+	switch input.Type {
+	case models.InvestmentTransactionTypeExpiration, models.InvestmentTransactionTypeAmortization, models.InvestmentTransactionTypeRedemption:
+		if !input.Quantity.IsPositive() {
+			return ErrBatchQuantityZero
+		}
+	}
+	return nil
+}
+
+// ImportTransactions пакетный импорт сделок в портфель пользователя. все строки проверяются и пишутся
+// в одной транзакции; строки с broker_ref, который уже есть в портфеле (или раньше в пакете), пропускаются.
+// после записи позиции по затронутым бумагам пересчитываются заново по всей истории операций.
+// если хоть одна строка не прошла проверку - не пишется ничего, в ответе ошибки по строкам
+func (s *investmentService) ImportTransactions(ctx context.Context, userID, portfolioID uuid.UUID, input *models.InvestmentBatchImport) (*models.InvestmentBatchResult, error) {
+	if len(input.Transactions) > maxBatchTransactions {
+		return nil, ErrBatchTooLarge
+	}
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+
+	refs := make([]string, 0, len(input.Transactions))
+	for i := range input.Transactions {
+		if ref := strings.TrimSpace(input.Transactions[i].BrokerRef); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	existing, err := s.investmentRepo.GetExistingBrokerRefs(ctx, portfolioID, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	// бумаги ищутся до транзакции: провайдер отвечает медленно, а транзакция пакета держит блокировки
+	found, err := s.findBatchSecurities(ctx, input.Transactions, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.InvestmentBatchResult{
+		DryRun:   input.DryRun,
+		Total:    len(input.Transactions),
+		Rows:     make([]models.InvestmentBatchRow, 0, len(input.Transactions)),
+		Holdings: []models.Holding{},
+	}
+
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		securities := make(map[uuid.UUID]*models.Security)
+		rowByTx := make(map[uuid.UUID]int)
+
+		for i := range input.Transactions {
+			in := &input.Transactions[i]
+			in.PortfolioID = portfolioID
+			row := models.InvestmentBatchRow{Row: i + 1, BrokerRef: strings.TrimSpace(in.BrokerRef)}
+
+			if row.BrokerRef != "" && existing[row.BrokerRef] {
+				row.Status = models.InvestmentBatchRowDuplicate
+				result.Duplicates++
+				result.Rows = append(result.Rows, row)
+				continue
+			}
+
+			// строка пишется в своем savepoint: ошибка строки откатывает только ее изменения
+			// (например, заведенную для нее бумагу), остальной пакет продолжает проверяться
+			tx := newInvestmentTransaction(in)
+			var security *models.Security
+			err := s.txManager.WithSavepoint(txCtx, func(rowCtx context.Context) error {
+				var err error
+				if security, err = s.prepareBatchRow(rowCtx, tx, portfolio, in, found); err != nil {
+					return err
+				}
+				return s.investmentRepo.Create(rowCtx, tx)
+			})
+			if err != nil {
+				// после неудачного отката к savepoint транзакция пакета уже прервана
+				if errors.Is(err, repository.ErrSavepointRollback) || !isBatchRowError(err) {
+					return err
+				}
+				row.Status, row.Error = models.InvestmentBatchRowInvalid, err.Error()
+				result.Invalid++
+				result.Rows = append(result.Rows, row)
+				continue
+			}
+			if row.BrokerRef != "" {
+				existing[row.BrokerRef] = true
+			}
+
+			tx.Security = security
+			securities[security.ID] = security
+			rowByTx[tx.ID] = len(result.Rows)
+			row.Status, row.Transaction = models.InvestmentBatchRowCreated, tx
+			result.Rows = append(result.Rows, row)
+		}
+		if result.Invalid > 0 {
+			return errBatchRollback
+		}
+
+		// позиции пересчитываются один раз по всей истории: сделки пакета могут быть задним числом
+		failed, err := s.rebuildHoldings(txCtx, portfolioID, securities)
+		if err != nil {
+			if idx, ok := rowByTx[failed]; ok && isBatchRowError(err) {
+				result.Rows[idx].Status, result.Rows[idx].Error = models.InvestmentBatchRowInvalid, err.Error()
+				result.Rows[idx].Transaction = nil
+				result.Invalid++
+				return errBatchRollback
+			}
+			return err
+		}
+
+		for id := range securities {
+			if holding, err := s.holdingRepo.GetByPortfolioAndSecurity(txCtx, portfolioID, id); err == nil {
+				result.Holdings = append(result.Holdings, *holding)
+			}
+		}
+		if input.DryRun {
+			return errBatchRollback
+		}
+		return nil
+	})
+	if err != nil && err != errBatchRollback {
+		return nil, err
+	}
+
+	for i := range result.Rows {
+		row := &result.Rows[i]
+		switch {
+		case row.Status != models.InvestmentBatchRowCreated:
+		case result.Invalid > 0:
+			// запись откатилась, строка корректна, но не сохранена
+			row.Status, row.Transaction = models.InvestmentBatchRowReady, nil
+		case input.DryRun:
+			row.Status = models.InvestmentBatchRowReady
+		default:
+			result.Created++
+		}
+	}
+	if result.Invalid > 0 {
+		result.Holdings = []models.Holding{}
+	}
+	return result, nil
+}
+
+// batchSecurity бумага строки пакета или ошибка ее поиска, которая относится к строке
+type batchSecurity struct {
+	security *models.Security
+	err      error
+}
+
+// batchSecurityKey бумага строки: по id или по тикеру и бирже
+func batchSecurityKey(input *models.InvestmentTransactionCreate) string {
+	if input.SecurityID != uuid.Nil {
+		return input.SecurityID.String()
+	}
+	return strings.ToUpper(strings.TrimSpace(input.Ticker)) + "@" + string(input.Exchange)
+}
+
+// findBatchSecurities ищет каждую бумагу пакета один раз; строки, которые не пройдут проверку
+// или будут пропущены как дубли, не ищутся
+func (s *investmentService) findBatchSecurities(ctx context.Context, rows []models.InvestmentTransactionCreate, existing map[string]bool) (map[string]batchSecurity, error) {
+	found := make(map[string]batchSecurity)
+	for i := range rows {
+		in := &rows[i]
+		if ref := strings.TrimSpace(in.BrokerRef); ref != "" && existing[ref] {
+			continue
+		}
+		if validateBatchRow(in) != nil || checkTransactionInput(newInvestmentTransaction(in), in) != nil {
+			continue
+		}
+		key := batchSecurityKey(in)
+		if _, ok := found[key]; ok {
+			continue
+		}
+		security, err := s.findSecurity(ctx, in)
+		if err != nil && !isBatchRowError(err) {
+			return nil, err
+		}
+		found[key] = batchSecurity{security: security, err: err}
+	}
+	return found, nil
+}
+
+func (s *investmentService) prepareBatchRow(ctx context.Context, tx *models.InvestmentTransaction, portfolio *models.Portfolio, input *models.InvestmentTransactionCreate, found map[string]batchSecurity) (*models.Security, error) {
+	if err := validateBatchRow(input); err != nil {
+		return nil, err
+	}
+	if err := checkTransactionInput(tx, input); err != nil {
+		return nil, err
+	}
+	security, ok := found[batchSecurityKey(input)]
+	if !ok {
+		return nil, ErrSecurityNotFound
+	}
+	if security.err != nil {
+		return nil, security.err
+	}
+	return s.prepareTransaction(ctx, tx, portfolio, input, security.security)
+}
+
+// rebuildHoldings удаляет позиции по бумагам и проводит заново все их операции от старых к новым.
+// при ошибке возвращает id операции, на которой история не сошлась (например, продажа больше остатка)
+func (s *investmentService) rebuildHoldings(ctx context.Context, portfolioID uuid.UUID, securities map[uuid.UUID]*models.Security) (uuid.UUID, error) {
+	for id, security := range securities {
+		if holding, err := s.holdingRepo.GetByPortfolioAndSecurity(ctx, portfolioID, id); err == nil {
+			if err := s.holdingRepo.Delete(ctx, holding.ID); err != nil {
+				return uuid.Nil, err
+			}
+		}
+
+		transactions, err := s.investmentRepo.GetBySecurityID(ctx, portfolioID, id)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		sort.SliceStable(transactions, func(i, j int) bool {
+			if !transactions[i].Date.Equal(transactions[j].Date) {
+				return transactions[i].Date.Before(transactions[j].Date)
+			}
+			return transactions[i].CreatedAt.Before(transactions[j].CreatedAt)
+		})
+
+		for i := range transactions {
+			tx := &transactions[i]
+			err := s.applyToHolding(ctx, tx, security)
+			// сплит до первой покупки позицию не меняет
+			if err != nil && tx.Type == models.InvestmentTransactionTypeSplit {
+				if _, herr := s.holdingRepo.GetByPortfolioAndSecurity(ctx, portfolioID, id); herr != nil {
+					err = nil
+				}
+			}
+			if err != nil {
+				return tx.ID, err
+			}
+		}
+	}
+	return uuid.Nil, nil
+}
+
+// ParseTransactionsCSV разбирает CSV сделок с заголовком: date, type, quantity, price обязательны,
+// ticker, exchange, security_id, commission, currency, exchange_rate, broker_ref, notes - по необходимости.
+// разделитель - запятая или точка с запятой
+// (тогда в числах допускается десятичная запятая); даты - 2006-01-02, 02.01.2006 или RFC 3339
+func ParseTransactionsCSV(r io.Reader) ([]models.InvestmentTransactionCreate, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	header, _, _ := strings.Cut(text, "\n")
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if strings.Count(header, ";") > strings.Count(header, ",") {
+		reader.Comma = ';'
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBatchCSV, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%w: header and at least one row are required", ErrInvalidBatchCSV)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"date", "type", "quantity", "price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: column %q is required", ErrInvalidBatchCSV, name)
+		}
+	}
+	if len(records)-1 > maxBatchTransactions {
+		return nil, ErrBatchTooLarge
+	}
+
+	rows := make([]models.InvestmentTransactionCreate, 0, len(records)-1)
+	for n, record := range records[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		number := func(name string) (decimal.Decimal, error) {
+			v := strings.ReplaceAll(field(name), " ", "")
+			if v == "" {
+				return decimal.Zero, nil
+			}
+			if reader.Comma == ';' {
+				v = strings.ReplaceAll(v, ",", ".")
+			}
+			d, err := decimal.NewFromString(v)
+			if err != nil {
+				return d, fmt.Errorf("%w: row %d: invalid %s", ErrInvalidBatchCSV, n+1, name)
+			}
+			return d, nil
+		}
+
+		row := models.InvestmentTransactionCreate{
+			Type:      models.InvestmentTransactionType(strings.ToLower(field("type"))),
+			Ticker:    field("ticker"),
+			Exchange:  models.Exchange(strings.ToUpper(field("exchange"))),
+			Currency:  field("currency"),
+			BrokerRef: field("broker_ref"),
+			Notes:     field("notes"),
+		}
+		if row.Date, err = parseBatchDate(field("date")); err != nil {
+			return nil, fmt.Errorf("%w: row %d: invalid date", ErrInvalidBatchCSV, n+1)
+		}
+		if id := field("security_id"); id != "" {
+			if row.SecurityID, err = uuid.Parse(id); err != nil {
+				return nil, fmt.Errorf("%w: row %d: invalid security_id", ErrInvalidBatchCSV, n+1)
+			}
+		}
+		if row.Quantity, err = number("quantity"); err != nil {
+			return nil, err
+		}
+		if row.Price, err = number("price"); err != nil {
+			return nil, err
+		}
+		if row.Commission, err = number("commission"); err != nil {
+			return nil, err
+		}
+		if row.ExchangeRate, err = number("exchange_rate"); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseBatchDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "02.01.2006", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ownedSecurityRepo отдает одну бумагу, остальные методы не нужны
+type ownedSecurityRepo struct {
+	repository.SecurityRepository
+	security *models.Security
+}
+
+func (r *ownedSecurityRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Security, error) {
+	if id != r.security.ID {
+		return nil, pgx.ErrNoRows
+	}
+	return r.security, nil
+}
+
+func TestManualSecurityHiddenFromOtherUsers(t *testing.T) {
+	owner := uuid.New()
+	security := &models.Security{ID: uuid.New(), Ticker: "PRIVATE", OwnerID: &owner}
+	svc := &investmentService{securityRepo: &ownedSecurityRepo{security: security}}
+
+	if _, err := svc.GetSecurityByID(context.Background(), uuid.New(), security.ID); err != ErrSecurityNotFound {
+		t.Errorf("GetSecurityByID for another user: err = %v, want ErrSecurityNotFound", err)
+	}
+	to := time.Now()
+	if _, err := svc.GetSecurityHistory(context.Background(), uuid.New(), security.ID, to.AddDate(-1, 0, 0), to); err != ErrSecurityNotFound {
+		t.Errorf("GetSecurityHistory for another user: err = %v, want ErrSecurityNotFound", err)
+	}
+}
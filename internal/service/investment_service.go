@@ -3,42 +3,116 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/market"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/repository"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/shopspring/decimal"
 )
 
 var (
 	ErrSecurityNotFound   = errors.New("security not found")
 	ErrInsufficientShares = errors.New("insufficient shares for sale")
+	ErrInvalidRewardInput = errors.New("reward quantity must be positive and price must not be negative")
+	ErrSecurityRequired   = errors.New("security_id or ticker with exchange is required")
+	ErrNotDerivative      = errors.New("expiration is only allowed for futures and options")
+	ErrInvalidQuantity    = errors.New("quantity must be positive")
+	ErrLotSizeMismatch    = errors.New("quantity must be a multiple of the security lot size (set allow_fractional for OTC or fractional trades)")
+	ErrQuantityPrecision  = errors.New("quantity has more decimal places than allowed for this security type")
+	ErrNotBond            = errors.New("amortization and redemption are only allowed for bonds")
+	ErrInvalidPrincipal   = errors.New("principal repaid per bond must be positive")
+	ErrBrokerRefExists    = errors.New("portfolio already has a transaction with this broker_ref")
+
+	ErrManualSecurityExists = errors.New("manual security with this ticker already exists")
+	ErrNotManualSecurity    = errors.New("prices can only be entered for manual securities")
+	ErrInvalidManualPrice   = errors.New("price must be positive and date must not be in the future")
+
+	ErrCurrencyMismatch        = errors.New("transaction currency differs from security currency (set allow_currency_mismatch if intended)")
+	ErrExchangeRateRequired    = errors.New("exchange_rate is required when transaction currency differs from portfolio currency (or set fetch_exchange_rate)")
+	ErrExchangeRateUnavailable = errors.New("exchange rate for the transaction date is unavailable, pass exchange_rate")
+	ErrInvalidExchangeRate     = errors.New("exchange_rate must be positive")
+)
+
+const (
+	riskHistoryDays     = 365 // за какой период считаем риск-метрики портфеля
+	tradingDaysPerYear  = 252 // для приведения дневной волатильности к годовой
+	holdingRebuildBatch = 100 // позиций из очереди на пересчет за один запуск
 )
 
 type InvestmentService interface {
 	// ценные ьумаги
-	SearchSecurities(ctx context.Context, query string, securityType *models.SecurityType, exchange *models.Exchange) ([]models.Security, error)
-	GetSecurityByID(ctx context.Context, id uuid.UUID) (*models.Security, error)
+	// SearchSecurities поиск бумаг без скрытых пользователем тикеров, типов и бирж
+	SearchSecurities(ctx context.Context, userID uuid.UUID, query string, securityType *models.SecurityType, exchange *models.Exchange) ([]models.Security, error)
+	GetSecurityByID(ctx context.Context, userID, id uuid.UUID) (*models.Security, error)
+	// бумаги без биржевых котировок, заведенные пользователем; цены вводятся вручную
+	CreateManualSecurity(ctx context.Context, userID uuid.UUID, input *models.ManualSecurityCreate) (*models.Security, error)
+	GetManualSecurities(ctx context.Context, userID uuid.UUID) ([]models.Security, error)
+	AddManualPrice(ctx context.Context, userID, securityID uuid.UUID, input *models.ManualPriceCreate) (*models.Security, error)
+	// ScreenSecurities подбор бумаг по фильтру среди синхронизированных, без запросов к бирже
+	ScreenSecurities(ctx context.Context, userID uuid.UUID, filter *models.SecurityScreenerFilter) (*models.SecurityScreenerResult, error)
+	// FindDuplicateSecurities группы подозрительных дублей бумаг для администратора
+	FindDuplicateSecurities(ctx context.Context) ([]models.SecurityDuplicateGroup, error)
+	// MergeSecurities переносит все ссылки с одной бумаги на другую и удаляет первую
+	MergeSecurities(ctx context.Context, input *models.SecurityMergeRequest) (*models.SecurityMergeResult, error)
+	// настройки скрытия бумаг из поиска и скринера
+	GetSecurityPreferences(ctx context.Context, userID uuid.UUID) (*models.SecurityPreferences, error)
+	UpdateSecurityPreferences(ctx context.Context, userID uuid.UUID, input *models.SecurityPreferences) (*models.SecurityPreferences, error)
 	GetSecurityQuote(ctx context.Context, ticker string, exchange models.Exchange) (*models.MarketQuote, error)
+	// дневная история цен (из бд, недостающее догружается у провайдера)
+	GetSecurityHistory(ctx context.Context, userID, securityID uuid.UUID, from, to time.Time) ([]models.PriceHistory, error)
+	// BackfillHistory загружает у провайдера историю котировок за years лет (0 - пять лет)
+	// и задним числом пересчитывает дневные снимки стоимости портфелей с этой бумагой
+	BackfillHistory(ctx context.Context, userID, securityID uuid.UUID, years int) (*models.PriceBackfillResult, error)
 
 	// транзакции
 	AddTransaction(ctx context.Context, input *models.InvestmentTransactionCreate) (*models.InvestmentTransaction, error)
-	GetTransactions(ctx context.Context, portfolioID uuid.UUID, limit, offset int) ([]models.InvestmentTransaction, error)
+	// ImportTransactions пакет сделок в портфель пользователя: все или ничего, с пропуском уже загруженных по broker_ref
+	ImportTransactions(ctx context.Context, userID, portfolioID uuid.UUID, input *models.InvestmentBatchImport) (*models.InvestmentBatchResult, error)
+	GetTransactions(ctx context.Context, portfolioID uuid.UUID, filter *models.InvestmentTransactionFilter) (*models.InvestmentTransactionList, error)
+	// GetTransactionsPage страница операций портфеля пользователя по курсору для /api/v2
+	GetTransactionsPage(ctx context.Context, userID, portfolioID uuid.UUID, filter *models.InvestmentTransactionFilter, cursor string, limit int) (*models.InvestmentTransactionPage, error)
 	GetTransactionsByDateRange(ctx context.Context, portfolioID uuid.UUID, start, end time.Time) ([]models.InvestmentTransaction, error)
-	DeleteTransaction(ctx context.Context, id uuid.UUID) error
+	// DeleteTransaction помечает операцию удаленной и откатывает ее влияние на позицию
+	DeleteTransaction(ctx context.Context, userID, id uuid.UUID) error
+	// RestoreTransaction возвращает удаленную операцию и пересчитывает позицию по бумаге
+	RestoreTransaction(ctx context.Context, userID, id uuid.UUID) (*models.InvestmentTransaction, error)
+	// RebuildQueuedHoldings пересчитывает позиции из очереди на пересчет; возвращает число пересчитанных
+	RebuildQueuedHoldings(ctx context.Context) (int, error)
+	// SettleExpiredDerivatives закрывает позиции по истекшим фьючерсам и опционам операцией expiration
+	SettleExpiredDerivatives(ctx context.Context) (int, error)
+	// SyncBondPrincipal проводит амортизации и погашения облигаций по графику биржи
+	SyncBondPrincipal(ctx context.Context) (int, error)
 
 	// позиции(holdings)
 	GetHoldings(ctx context.Context, portfolioID uuid.UUID) ([]models.Holding, error)
 	GetHolding(ctx context.Context, portfolioID, securityID uuid.UUID) (*models.Holding, error)
 
 	// получение аналитики
-	GetPortfolioAnalytics(ctx context.Context, portfolioID uuid.UUID) (*models.PortfolioAnalytics, error)
+	// currency - валюта отчета, пустая строка = валюта портфеля
+	GetPortfolioAnalytics(ctx context.Context, portfolioID uuid.UUID, currency string) (*models.PortfolioAnalytics, error)
 	GetTaxReport(ctx context.Context, portfolioID uuid.UUID, year int) (*models.TaxReport, error)
+	// GetBenchmarkComparison что было бы, если вкладывать те же деньги в те же дни в бенчмарк (IMOEX, MCFTR, SBMX, BTC)
+	GetBenchmarkComparison(ctx context.Context, userID, portfolioID uuid.UUID, benchmark string) (*models.BenchmarkComparison, error)
+	// GetPerformanceAttribution вклад бумаг и секторов (вес × доходность) в доходность портфеля за период
+	GetPerformanceAttribution(ctx context.Context, userID, portfolioID uuid.UUID, from, to time.Time) (*models.PerformanceAttribution, error)
 
 	// дивидендные выплаты по портфелю
-	GetUpcomingDividends(ctx context.Context, portfolioID uuid.UUID) ([]models.Dividend, error)
+	// GetUpcomingDividends дивиденды по бумагам портфеля от провайдера; страница собирается после опроса всех бумаг
+	GetUpcomingDividends(ctx context.Context, portfolioID uuid.UUID, page, limit int) (*models.DividendList, error)
+	// будущие купоны по облигациям портфеля
+	GetUpcomingCoupons(ctx context.Context, userID, portfolioID uuid.UUID) ([]models.CouponPayment, error)
+	// календарь дивидендов и купонов; currency - валюта итогов, пустая строка = валюта портфеля
+	GetIncomeCalendar(ctx context.Context, userID, portfolioID uuid.UUID, currency string) (*models.IncomeCalendar, error)
+	// доход по месяцам и годам, доходность на вложения и прогноз на 12 месяцев; currency - как в GetIncomeCalendar
+	GetIncomeReport(ctx context.Context, userID, portfolioID uuid.UUID, currency string) (*models.PortfolioIncomeReport, error)
 }
 
 type investmentService struct {
@@ -47,7 +121,12 @@ type investmentService struct {
 	securityRepo   repository.SecurityRepository
 	investmentRepo repository.InvestmentTransactionRepository
 	marketProvider *market.MultiProvider
+	sectorService  SectorService
+	fundamentals   FundamentalsService
+	priceHistory   PriceHistoryService
 	txManager      repository.TxManager
+	iisRepo        repository.IISRepository
+	drawdownRepo   repository.DrawdownRepository
 }
 
 func NewInvestmentService(
@@ -56,7 +135,12 @@ func NewInvestmentService(
 	securityRepo repository.SecurityRepository,
 	investmentRepo repository.InvestmentTransactionRepository,
 	marketProvider *market.MultiProvider,
+	sectorService SectorService,
+	fundamentals FundamentalsService,
+	priceHistory PriceHistoryService,
 	txManager repository.TxManager,
+	iisRepo repository.IISRepository,
+	drawdownRepo repository.DrawdownRepository,
 ) InvestmentService {
 	return &investmentService{
 		portfolioRepo:  portfolioRepo,
@@ -65,26 +149,24 @@ func NewInvestmentService(
 		investmentRepo: investmentRepo,
 		txManager:      txManager,
 		marketProvider: marketProvider,
+		sectorService:  sectorService,
+		fundamentals:   fundamentals,
+		priceHistory:   priceHistory,
+		iisRepo:        iisRepo,
+		drawdownRepo:   drawdownRepo,
 	}
 }
 
-func (s *investmentService) SearchSecurities(ctx context.Context, query string, securityType *models.SecurityType, exchange *models.Exchange) ([]models.Security, error) {
+func (s *investmentService) SearchSecurities(ctx context.Context, userID uuid.UUID, query string, securityType *models.SecurityType, exchange *models.Exchange) ([]models.Security, error) {
+	hidden, err := s.securityRepo.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	// сначала ищем в бд
-	dbResults, err := s.securityRepo.Search(ctx, query, 20)
+	dbResults, err := s.securityRepo.Search(ctx, query, securityType, exchange, hidden, 20)
 	if err == nil && len(dbResults) > 0 {
-		var filtered []models.Security
-		for _, sec := range dbResults {
-			if securityType != nil && sec.Type != *securityType {
-				continue
-			}
-			if exchange != nil && sec.Exchange != *exchange {
-				continue
-			}
-			filtered = append(filtered, sec)
-		}
-		if len(filtered) > 0 {
-			return filtered, nil
-		}
+		return dbResults, nil
 	}
 
 	// если нет в бд, то делаем запрос к рын. провайдеру
@@ -93,94 +175,517 @@ func (s *investmentService) SearchSecurities(ctx context.Context, query string,
 		return nil, err
 	}
 
-	// сохраняем полученные бумаги в бд
+	// сохраняем полученные бумаги в бд без повторов, заодно проставляем сектор; скрытые сохраняются, но не показываются
+	results = dedupSecurities(results)
+	visible := make([]models.Security, 0, len(results))
 	for i := range results {
-		s.securityRepo.Create(ctx, &results[i])
+		s.sectorService.Enrich(ctx, &results[i])
+		s.saveSearchResult(ctx, &results[i])
+		if !hidden.Hides(&results[i]) {
+			visible = append(visible, results[i])
+		}
+	}
+
+	return visible, nil
+}
+
+func (s *investmentService) ScreenSecurities(ctx context.Context, userID uuid.UUID, filter *models.SecurityScreenerFilter) (*models.SecurityScreenerResult, error) {
+	hidden, err := s.securityRepo.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	filter.Hidden = hidden
+
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	filter.Currency = strings.ToUpper(filter.Currency)
+
+	securities, total, err := s.securityRepo.Screen(ctx, filter)
+	if errors.Is(err, repository.ErrInvalidSortField) {
+		return nil, ErrInvalidSortField
+	}
+	if err != nil {
+		return nil, err
+	}
+	if securities == nil {
+		securities = []models.ScreenerSecurity{}
+	}
+
+	return &models.SecurityScreenerResult{
+		Securities: securities,
+		Total:      total,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+	}, nil
+}
+
+func (s *investmentService) GetSecurityPreferences(ctx context.Context, userID uuid.UUID) (*models.SecurityPreferences, error) {
+	return s.securityRepo.GetPreferences(ctx, userID)
+}
+
+// UpdateSecurityPreferences заменяет списки скрытого целиком; тикеры приводятся к верхнему регистру, повторы убираются
+func (s *investmentService) UpdateSecurityPreferences(ctx context.Context, userID uuid.UUID, input *models.SecurityPreferences) (*models.SecurityPreferences, error) {
+	prefs := &models.SecurityPreferences{
+		HiddenTickers:   make([]string, 0, len(input.HiddenTickers)),
+		HiddenTypes:     make([]models.SecurityType, 0, len(input.HiddenTypes)),
+		HiddenExchanges: make([]models.Exchange, 0, len(input.HiddenExchanges)),
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range input.HiddenTickers {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			prefs.HiddenTickers = append(prefs.HiddenTickers, t)
+		}
+	}
+	for _, t := range input.HiddenTypes {
+		if !seen["type:"+string(t)] {
+			seen["type:"+string(t)] = true
+			prefs.HiddenTypes = append(prefs.HiddenTypes, t)
+		}
+	}
+	for _, e := range input.HiddenExchanges {
+		if !seen["exchange:"+string(e)] {
+			seen["exchange:"+string(e)] = true
+			prefs.HiddenExchanges = append(prefs.HiddenExchanges, e)
+		}
+	}
+
+	if err := s.securityRepo.SetPreferences(ctx, userID, prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+func (s *investmentService) CreateManualSecurity(ctx context.Context, userID uuid.UUID, input *models.ManualSecurityCreate) (*models.Security, error) {
+	ticker := strings.ToUpper(strings.TrimSpace(input.Ticker))
+	existing, err := s.securityRepo.GetByOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sec := range existing {
+		if sec.Ticker == ticker {
+			return nil, ErrManualSecurityExists
+		}
+	}
+
+	if input.Price != nil && !input.Price.IsPositive() {
+		return nil, ErrInvalidManualPrice
+	}
+
+	security := &models.Security{
+		Ticker:    ticker,
+		Name:      strings.TrimSpace(input.Name),
+		ShortName: truncateRunes(strings.TrimSpace(input.Name), 50),
+		Type:      input.Type,
+		Exchange:  models.ExchangeManual,
+		Currency:  strings.ToUpper(input.Currency),
+		Sector:    strings.TrimSpace(input.Sector),
+		LotSize:   1,
+		IsActive:  true,
+		OwnerID:   &userID,
+	}
+	if security.Type == "" {
+		security.Type = models.SecurityTypeStock
+	}
+
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		if err := s.securityRepo.Create(txCtx, security); err != nil {
+			return err
+		}
+		if input.Price == nil {
+			return nil
+		}
+		security.LastPrice = *input.Price
+		return s.priceHistory.RecordPrice(txCtx, security, time.Now(), *input.Price)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return security, nil
+}
+
+func (s *investmentService) GetManualSecurities(ctx context.Context, userID uuid.UUID) ([]models.Security, error) {
+	securities, err := s.securityRepo.GetByOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if securities == nil {
+		securities = []models.Security{}
+	}
+	return securities, nil
+}
+
+func (s *investmentService) AddManualPrice(ctx context.Context, userID, securityID uuid.UUID, input *models.ManualPriceCreate) (*models.Security, error) {
+	security, err := s.securityRepo.GetByID(ctx, securityID)
+	if err != nil || (security.OwnerID != nil && *security.OwnerID != userID) {
+		return nil, ErrSecurityNotFound
+	}
+	if !security.IsManual() {
+		return nil, ErrNotManualSecurity
+	}
+	if !input.Price.IsPositive() || truncateDay(input.Date).After(truncateDay(time.Now())) {
+		return nil, ErrInvalidManualPrice
 	}
 
-	return results, nil
+	if err := s.priceHistory.RecordPrice(ctx, security, input.Date, input.Price); err != nil {
+		return nil, err
+	}
+	return s.securityRepo.GetByID(ctx, securityID)
 }
 
-func (s *investmentService) GetSecurityByID(ctx context.Context, id uuid.UUID) (*models.Security, error) {
-	return s.securityRepo.GetByID(ctx, id)
+func (s *investmentService) GetSecurityByID(ctx context.Context, userID, id uuid.UUID) (*models.Security, error) {
+	security, err := s.securityRepo.GetByID(ctx, id)
+	// чужие бумаги, заведенные вручную, не видны
+	if err != nil || (security.OwnerID != nil && *security.OwnerID != userID) {
+		return nil, ErrSecurityNotFound
+	}
+	if err := s.fundamentals.Attach(ctx, security); err != nil {
+		return nil, err
+	}
+	return security, nil
 }
 
 func (s *investmentService) GetSecurityQuote(ctx context.Context, ticker string, exchange models.Exchange) (*models.MarketQuote, error) {
 	return s.marketProvider.GetQuote(ctx, ticker, exchange)
 }
 
-func (s *investmentService) AddTransaction(ctx context.Context, input *models.InvestmentTransactionCreate) (*models.InvestmentTransaction, error) {
-	security, err := s.securityRepo.GetByID(ctx, input.SecurityID)
-	if err != nil {
+func (s *investmentService) GetSecurityHistory(ctx context.Context, userID, securityID uuid.UUID, from, to time.Time) ([]models.PriceHistory, error) {
+	security, err := s.securityRepo.GetByID(ctx, securityID)
+	if err != nil || (security.OwnerID != nil && *security.OwnerID != userID) {
 		return nil, ErrSecurityNotFound
 	}
+	return s.priceHistory.GetHistory(ctx, securityID, from, to)
+}
+
+func (s *investmentService) AddTransaction(ctx context.Context, input *models.InvestmentTransactionCreate) (*models.InvestmentTransaction, error) {
+	if input.SecurityID == uuid.Nil && (input.Ticker == "" || input.Exchange == "") {
+		return nil, ErrSecurityRequired
+	}
 
 	portfolio, err := s.portfolioRepo.GetByID(ctx, input.PortfolioID)
 	if err != nil {
 		return nil, err
 	}
 
-	// создаем транзакцию
-	tx := &models.InvestmentTransaction{
+	tx := newInvestmentTransaction(input)
+	if tx.BrokerRef != "" {
+		existing, err := s.investmentRepo.GetExistingBrokerRefs(ctx, tx.PortfolioID, []string{tx.BrokerRef})
+		if err != nil {
+			return nil, err
+		}
+		if existing[tx.BrokerRef] {
+			return nil, ErrBrokerRefExists
+		}
+	}
+	// бумагу ищем до транзакции: запрос к провайдеру не должен держать соединение и блокировки
+	if err := checkTransactionInput(tx, input); err != nil {
+		return nil, err
+	}
+	found, err := s.findSecurity(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	var security *models.Security
+
+	// атомарная операция: (создание бумаги) + создание транзакции + обновление холдинга
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		var err error
+		security, err = s.prepareTransaction(txCtx, tx, portfolio, input, found)
+		if err != nil {
+			return err
+		}
+
+		// Создаем транзакцию
+		if err := s.investmentRepo.Create(txCtx, tx); err != nil {
+			return err
+		}
+
+		// обновляем холдинги
+		return s.applyToHolding(txCtx, tx, security)
+	})
+
+	// тот же broker_ref мог появиться параллельно, после проверки выше
+	if errors.Is(err, repository.ErrDuplicateBrokerRef) {
+		return nil, ErrBrokerRefExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tx.Security = security
+	return tx, nil
+}
+
+// newInvestmentTransaction транзакция из входных данных, до проверок и пересчета суммы
+func newInvestmentTransaction(input *models.InvestmentTransactionCreate) *models.InvestmentTransaction {
+	return &models.InvestmentTransaction{
 		PortfolioID:  input.PortfolioID,
 		SecurityID:   input.SecurityID,
 		Type:         input.Type,
 		Date:         input.Date,
 		Quantity:     input.Quantity,
 		Price:        input.Price,
-		Amount:       input.Quantity.Mul(input.Price).Add(input.Commission),
 		Commission:   input.Commission,
 		Currency:     input.Currency,
 		ExchangeRate: input.ExchangeRate,
 		Notes:        input.Notes,
+		BrokerRef:    strings.TrimSpace(input.BrokerRef),
 	}
+}
 
-	if tx.Currency == "" {
-		tx.Currency = portfolio.Currency
+// prepareTransaction проверяет сделку по найденной бумаге (см. findSecurity), заводит бумагу, если ее еще нет в бд,
+// проставляет валюту, курс и сумму. вызывать внутри транзакции
+func (s *investmentService) prepareTransaction(ctx context.Context, tx *models.InvestmentTransaction, portfolio *models.Portfolio, input *models.InvestmentTransactionCreate, found *models.Security) (*models.Security, error) {
+	if err := checkTransactionInput(tx, input); err != nil {
+		return nil, err
 	}
-	if tx.ExchangeRate.IsZero() {
-		tx.ExchangeRate = decimal.NewFromInt(1)
+
+	security, err := s.storeSecurity(ctx, found)
+	if err != nil {
+		return nil, err
 	}
+	// чужие бумаги, заведенные вручную, не видны
+	if security.OwnerID != nil && *security.OwnerID != portfolio.UserID {
+		return nil, ErrSecurityNotFound
+	}
+	input.SecurityID = security.ID
+	tx.SecurityID = security.ID
 
-	// атомарная операция: создание транзакции + обновление холдинга
-	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
-		// Создаем транзакцию
-		if err := s.investmentRepo.Create(txCtx, tx); err != nil {
-			return err
-		}
+	if err := validateQuantity(security, input); err != nil {
+		return nil, err
+	}
+	if err := s.prepareCurrency(ctx, tx, security, portfolio.Currency, input); err != nil {
+		return nil, err
+	}
 
-		// обновляем холдинги
-		switch input.Type {
-		case models.InvestmentTransactionTypeBuy:
-			return s.updateHoldingOnBuy(txCtx, input.PortfolioID, input.SecurityID, input.Quantity, input.Price, input.Commission)
-		case models.InvestmentTransactionTypeSell:
-			return s.updateHoldingOnSell(txCtx, input.PortfolioID, input.SecurityID, input.Quantity)
-		case models.InvestmentTransactionTypeDividend, models.InvestmentTransactionTypeCoupon:
-			// при получении дивидендов/купонов холдинги не меняются
-			return nil
-		case models.InvestmentTransactionTypeSplit:
-			return s.updateHoldingOnSplit(txCtx, input.PortfolioID, input.SecurityID, input.Quantity)
+	switch input.Type {
+	case models.InvestmentTransactionTypeExpiration:
+		if err := s.prepareExpiration(ctx, tx, security); err != nil {
+			return nil, err
+		}
+	case models.InvestmentTransactionTypeAmortization, models.InvestmentTransactionTypeRedemption:
+		if err := s.prepareBondPrincipal(ctx, tx, security); err != nil {
+			return nil, err
 		}
+	}
+
+	// у деривативов цена в пунктах, сумма сделки = пункты × стоимость пункта
+	tx.Amount = tx.Quantity.Mul(tx.Price).Mul(security.ContractSize()).Add(tx.Commission)
+	return security, nil
+}
+
+// applyToHolding проводит сохраненную транзакцию по позиции портфеля
+func (s *investmentService) applyToHolding(ctx context.Context, tx *models.InvestmentTransaction, security *models.Security) error {
+	switch tx.Type {
+	case models.InvestmentTransactionTypeBuy:
+		return s.updateHoldingOnBuy(ctx, tx, security)
+	case models.InvestmentTransactionTypeSell, models.InvestmentTransactionTypeExpiration, models.InvestmentTransactionTypeRedemption:
+		return s.updateHoldingOnSell(ctx, tx.PortfolioID, tx.SecurityID, tx.Quantity)
+	case models.InvestmentTransactionTypeAmortization:
+		return s.updateHoldingOnAmortization(ctx, tx)
+	case models.InvestmentTransactionTypeDividend, models.InvestmentTransactionTypeCoupon:
+		// при получении дивидендов/купонов холдинги не меняются
 		return nil
-	})
+	case models.InvestmentTransactionTypeSplit:
+		return s.updateHoldingOnSplit(ctx, tx.PortfolioID, tx.SecurityID, tx.Quantity)
+	case models.InvestmentTransactionTypeStakingReward, models.InvestmentTransactionTypeAirdrop:
+		// монеты приходят как покупка по справедливой цене (price=0 - нулевая себестоимость)
+		return s.updateHoldingOnBuy(ctx, tx, security)
+	}
+	return nil
+}
 
+// checkTransactionInput проверки сделки, которым не нужна бумага
+func checkTransactionInput(tx *models.InvestmentTransaction, input *models.InvestmentTransactionCreate) error {
+	if input.SecurityID == uuid.Nil && (input.Ticker == "" || input.Exchange == "") {
+		return ErrSecurityRequired
+	}
+	if isRewardTransaction(input.Type) && (!input.Quantity.IsPositive() || input.Price.IsNegative()) {
+		return ErrInvalidRewardInput
+	}
+	if tx.ExchangeRate.IsNegative() {
+		return ErrInvalidExchangeRate
+	}
+	return nil
+}
+
+// resolveSecurity находит бумагу и заводит ее, если ее еще нет в бд
+func (s *investmentService) resolveSecurity(ctx context.Context, input *models.InvestmentTransactionCreate) (*models.Security, error) {
+	found, err := s.findSecurity(ctx, input)
 	if err != nil {
 		return nil, err
 	}
+	return s.storeSecurity(ctx, found)
+}
 
-	tx.Security = security
-	return tx, nil
+// storeSecurity записывает бумагу от провайдера, бумагу из бд возвращает как есть. found не меняется,
+// поэтому одну найденную бумагу можно записывать в нескольких savepoint
+func (s *investmentService) storeSecurity(ctx context.Context, found *models.Security) (*models.Security, error) {
+	if found.ID != uuid.Nil {
+		return found, nil
+	}
+	security := *found
+	if err := s.securityRepo.Create(ctx, &security); err != nil {
+		return nil, err
+	}
+	return &security, nil
+}
+
+// findSecurity бумага из бд, а если ее там нет - от провайдера, без id (записывает storeSecurity).
+// ходит во внешние API, поэтому вызывается до транзакции
+func (s *investmentService) findSecurity(ctx context.Context, input *models.InvestmentTransactionCreate) (*models.Security, error) {
+	if input.SecurityID != uuid.Nil {
+		security, err := s.securityRepo.GetByID(ctx, input.SecurityID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSecurityNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return security, nil
+	}
+
+	// к провайдеру идем, только если бумаги точно нет в бд; ошибку бд наружу, а не "не найдена"
+	ticker := strings.ToUpper(strings.TrimSpace(input.Ticker))
+	security, err := s.securityRepo.GetByTicker(ctx, ticker, input.Exchange)
+	if err == nil {
+		return security, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	security, err = s.marketProvider.GetSecurityInfo(ctx, ticker, input.Exchange)
+	if err != nil || security == nil {
+		return nil, ErrSecurityNotFound
+	}
+
+	s.sectorService.Enrich(ctx, security)
+	security.ID = uuid.Nil
+	return security, nil
+}
+
+// prepareCurrency валюта и курс сделки: пустая валюта берется из бумаги, сделка с бумагой в чужой валюте
+// отклоняется без allow_currency_mismatch, а для валюты не портфеля нужен курс - переданный или курс на дату сделки
+func (s *investmentService) prepareCurrency(ctx context.Context, tx *models.InvestmentTransaction, security *models.Security, portfolioCurrency string, input *models.InvestmentTransactionCreate) error {
+	securityCurrency := security.Currency
+	if securityCurrency == "" {
+		securityCurrency = security.Exchange.QuoteCurrency()
+	}
+
+	tx.Currency = strings.ToUpper(strings.TrimSpace(tx.Currency))
+	switch {
+	case tx.Currency == "" && securityCurrency != "":
+		tx.Currency = securityCurrency
+	case tx.Currency == "":
+		tx.Currency = portfolioCurrency
+	case securityCurrency != "" && tx.Currency != securityCurrency && !input.AllowCurrencyMismatch && isTradeTransaction(tx.Type):
+		// дивиденды и купоны бывают в другой валюте (выплата после конвертации), сделки - нет
+		return ErrCurrencyMismatch
+	}
+
+	if tx.Currency == portfolioCurrency {
+		tx.ExchangeRate = decimal.NewFromInt(1)
+		return nil
+	}
+	if tx.ExchangeRate.IsPositive() {
+		return nil
+	}
+	if !input.FetchExchangeRate {
+		return ErrExchangeRateRequired
+	}
+
+	rate, err := s.marketProvider.GetHistoricalCurrencyRate(ctx, tx.Currency, portfolioCurrency, tx.Date)
+	if err != nil || !rate.IsPositive() {
+		return ErrExchangeRateUnavailable
+	}
+	tx.ExchangeRate = rate
+	return nil
+}
+
+// isTradeTransaction сделки, в которых валюта определяется бумагой
+func isTradeTransaction(t models.InvestmentTransactionType) bool {
+	switch t {
+	case models.InvestmentTransactionTypeBuy, models.InvestmentTransactionTypeSell,
+		models.InvestmentTransactionTypeTransferIn, models.InvestmentTransactionTypeTransferOut,
+		models.InvestmentTransactionTypeExpiration:
+		return true
+	}
+	return false
+}
+
+// prepareExpiration закрытие дериватива по расчетной цене: без количества закрывается вся позиция,
+// без цены берется последняя известная цена контракта
+func (s *investmentService) prepareExpiration(ctx context.Context, tx *models.InvestmentTransaction, security *models.Security) error {
+	if security.Type != models.SecurityTypeDerivative {
+		return ErrNotDerivative
+	}
+
+	if tx.Quantity.IsZero() {
+		holding, err := s.holdingRepo.GetByPortfolioAndSecurity(ctx, tx.PortfolioID, security.ID)
+		if err != nil {
+			return ErrInsufficientShares
+		}
+		tx.Quantity = holding.Quantity
+	}
+	if tx.Price.IsZero() {
+		tx.Price = security.LastPrice
+	}
+	if tx.Notes == "" {
+		tx.Notes = "экспирация контракта"
+	}
+	return nil
 }
 
-func (s *investmentService) updateHoldingOnBuy(ctx context.Context, portfolioID, securityID uuid.UUID, quantity, price, commission decimal.Decimal) error {
-	totalCost := quantity.Mul(price).Add(commission)
+// prepareBondPrincipal возврат номинала облигации: без количества берется вся позиция,
+// погашение без цены - по текущему номиналу бумаги
+func (s *investmentService) prepareBondPrincipal(ctx context.Context, tx *models.InvestmentTransaction, security *models.Security) error {
+	if security.Type != models.SecurityTypeBond {
+		return ErrNotBond
+	}
+
+	if tx.Quantity.IsZero() {
+		holding, err := s.holdingRepo.GetByPortfolioAndSecurity(ctx, tx.PortfolioID, security.ID)
+		if err != nil {
+			return ErrInsufficientShares
+		}
+		tx.Quantity = holding.Quantity
+	}
+	if !tx.Quantity.IsPositive() {
+		return ErrInvalidQuantity
+	}
+
+	if tx.Price.IsZero() && tx.Type == models.InvestmentTransactionTypeRedemption && security.FaceValue != nil {
+		tx.Price = *security.FaceValue
+	}
+	if !tx.Price.IsPositive() {
+		return ErrInvalidPrincipal
+	}
+
+	if tx.Notes == "" {
+		if tx.Type == models.InvestmentTransactionTypeRedemption {
+			tx.Notes = "погашение облигации"
+		} else {
+			tx.Notes = "амортизация облигации"
+		}
+	}
+	return nil
+}
 
+// updateHoldingOnBuy добавляет к позиции купленное количество; себестоимость - сумма сделки с комиссией
+func (s *investmentService) updateHoldingOnBuy(ctx context.Context, tx *models.InvestmentTransaction, security *models.Security) error {
 	holding := &models.Holding{
-		PortfolioID:  portfolioID,
-		SecurityID:   securityID,
-		Quantity:     quantity,
-		AveragePrice: price,
-		TotalCost:    totalCost,
+		PortfolioID:  tx.PortfolioID,
+		SecurityID:   tx.SecurityID,
+		Quantity:     tx.Quantity,
+		AveragePrice: tx.Price.Mul(security.ContractSize()), // для деривативов - в валюте за контракт
+		TotalCost:    tx.Amount,
 	}
 
 	// это либо перезапишит либо создаст (on conflict)
@@ -200,7 +705,7 @@ func (s *investmentService) updateHoldingOnSell(ctx context.Context, portfolioID
 	newQuantity := holding.Quantity.Sub(quantity)
 
 	if newQuantity.IsZero() || newQuantity.LessThan(decimal.Zero) { // вообще отриц не должно быть прост на всякий
-		return s.holdingRepo.DeleteIfZero(ctx, portfolioID, securityID)
+		return s.holdingRepo.Delete(ctx, holding.ID)
 	}
 
 	costReduction := quantity.Div(holding.Quantity).Mul(holding.TotalCost)
@@ -210,6 +715,26 @@ func (s *investmentService) updateHoldingOnSell(ctx context.Context, portfolioID
 	return s.holdingRepo.Update(ctx, holding.ID, newQuantity, newAvgPrice, newTotalCost)
 }
 
+// updateHoldingOnAmortization возврат части номинала: количество не меняется,
+// себестоимость уменьшается на полученную сумму (но не ниже нуля)
+func (s *investmentService) updateHoldingOnAmortization(ctx context.Context, tx *models.InvestmentTransaction) error {
+	holding, err := s.holdingRepo.GetByPortfolioAndSecurity(ctx, tx.PortfolioID, tx.SecurityID)
+	if err != nil {
+		return ErrInsufficientShares
+	}
+	if holding.Quantity.LessThan(tx.Quantity) {
+		return ErrInsufficientShares
+	}
+
+	newTotalCost := holding.TotalCost.Sub(tx.Quantity.Mul(tx.Price))
+	if newTotalCost.IsNegative() {
+		newTotalCost = decimal.Zero
+	}
+	newAvgPrice := newTotalCost.Div(holding.Quantity)
+
+	return s.holdingRepo.Update(ctx, holding.ID, holding.Quantity, newAvgPrice, newTotalCost)
+}
+
 func (s *investmentService) updateHoldingOnSplit(ctx context.Context, portfolioID, securityID uuid.UUID, ratio decimal.Decimal) error {
 	holding, err := s.holdingRepo.GetByPortfolioAndSecurity(ctx, portfolioID, securityID)
 	if err != nil {
@@ -236,10 +761,14 @@ func (s *investmentService) revertBuyTransaction(ctx context.Context, tx *models
 	costReduction := tx.Amount // Amount включает цену + комиссию
 	newTotalCost := holding.TotalCost.Sub(costReduction)
 
-	if newQuantity.LessThanOrEqual(decimal.Zero) || newTotalCost.LessThanOrEqual(decimal.Zero) {
+	if newQuantity.LessThanOrEqual(decimal.Zero) {
 		// Если количество стало 0 или отрицательным - удаляем холдинг
 		return s.holdingRepo.DeleteIfZero(ctx, tx.PortfolioID, tx.SecurityID)
 	}
+	// себестоимость может быть нулевой (награды за стейкинг), но не отрицательной
+	if newTotalCost.IsNegative() {
+		newTotalCost = decimal.Zero
+	}
 
 	// пересчитываем среднюю цену
 	newAvgPrice := newTotalCost.Div(newQuantity)
@@ -249,9 +778,27 @@ func (s *investmentService) revertBuyTransaction(ctx context.Context, tx *models
 
 // revertSellTransaction откатывает продажу (увеличивает холдинг)
 func (s *investmentService) revertSellTransaction(ctx context.Context, tx *models.InvestmentTransaction) error {
+	security, err := s.securityRepo.GetByID(ctx, tx.SecurityID)
+	if err != nil {
+		return ErrSecurityNotFound
+	}
+
 	// обратная операция для продажи = добавить акции обратно
 	// используем цену и комиссию из исходной транзакции
-	return s.updateHoldingOnBuy(ctx, tx.PortfolioID, tx.SecurityID, tx.Quantity, tx.Price, tx.Commission)
+	return s.updateHoldingOnBuy(ctx, tx, security)
+}
+
+// revertAmortizationTransaction возвращает в себестоимость погашенную часть номинала
+func (s *investmentService) revertAmortizationTransaction(ctx context.Context, tx *models.InvestmentTransaction) error {
+	holding, err := s.holdingRepo.GetByPortfolioAndSecurity(ctx, tx.PortfolioID, tx.SecurityID)
+	if err != nil {
+		return nil // позиция уже закрыта
+	}
+
+	newTotalCost := holding.TotalCost.Add(tx.Quantity.Mul(tx.Price))
+	newAvgPrice := newTotalCost.Div(holding.Quantity)
+
+	return s.holdingRepo.Update(ctx, holding.ID, holding.Quantity, newAvgPrice, newTotalCost)
 }
 
 // revertSplitTransaction откатывает сплит
@@ -269,36 +816,80 @@ func (s *investmentService) revertSplitTransaction(ctx context.Context, tx *mode
 	return s.holdingRepo.Update(ctx, holding.ID, newQuantity, newAvgPrice, holding.TotalCost)
 }
 
-func (s *investmentService) GetTransactions(ctx context.Context, portfolioID uuid.UUID, limit, offset int) ([]models.InvestmentTransaction, error) {
-	return s.investmentRepo.GetByPortfolioID(ctx, portfolioID, limit, offset)
+func (s *investmentService) GetTransactions(ctx context.Context, portfolioID uuid.UUID, filter *models.InvestmentTransactionFilter) (*models.InvestmentTransactionList, error) {
+	result, err := s.investmentRepo.GetByFilter(ctx, portfolioID, filter)
+	if errors.Is(err, repository.ErrInvalidSortField) {
+		return nil, ErrInvalidSortField
+	}
+	return result, err
 }
 
-func (s *investmentService) GetTransactionsByDateRange(ctx context.Context, portfolioID uuid.UUID, start, end time.Time) ([]models.InvestmentTransaction, error) {
-	return s.investmentRepo.GetByDateRange(ctx, portfolioID, start, end)
-}
+func (s *investmentService) GetTransactionsPage(ctx context.Context, userID, portfolioID uuid.UUID, filter *models.InvestmentTransactionFilter, cursor string, limit int) (*models.InvestmentTransactionPage, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
 
-func (s *investmentService) DeleteTransaction(ctx context.Context, id uuid.UUID) error {
-	// получаем транзакцию перед удалением для отката холдинга
-	tx, err := s.investmentRepo.GetByID(ctx, id)
+	page, limit, err := cursorPage(cursor, limit)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// атомарная операция: удаление транзакции + откат холдинга
-	return s.txManager.WithTx(ctx, func(txCtx context.Context) error {
-		// удаляем транзакцию
-		if err := s.investmentRepo.Delete(txCtx, id); err != nil {
-			return err
-		}
+	transactions, err := s.investmentRepo.GetPage(ctx, portfolioID, filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.InvestmentTransactionPage{Transactions: transactions, Limit: limit}
+	if len(transactions) > limit {
+		result.Transactions = transactions[:limit]
+		last := result.Transactions[limit-1]
+		result.NextCursor = encodeCursor(last.Date, last.ID)
+	}
+	if result.Transactions == nil {
+		result.Transactions = []models.InvestmentTransaction{}
+	}
+	return result, nil
+}
+
+func (s *investmentService) GetTransactionsByDateRange(ctx context.Context, portfolioID uuid.UUID, start, end time.Time) ([]models.InvestmentTransaction, error) {
+	return s.investmentRepo.GetByDateRange(ctx, portfolioID, start, end)
+}
+
+func (s *investmentService) DeleteTransaction(ctx context.Context, userID, id uuid.UUID) error {
+	// получаем транзакцию перед удалением для отката холдинга
+	tx, err := s.investmentRepo.GetByID(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTransactionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.checkTransactionOwner(ctx, userID, tx); err != nil {
+		return err
+	}
+
+	// атомарная операция: удаление транзакции + откат холдинга
+	return s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		// помечаем удаленной; если ее успели удалить параллельно - позицию второй раз не откатываем
+		deleted, err := s.investmentRepo.Delete(txCtx, id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrTransactionNotFound
+		}
 
 		// Откатываем изменения в холдинге в зависимости от типа транзакции
 		switch tx.Type {
-		case models.InvestmentTransactionTypeBuy:
+		case models.InvestmentTransactionTypeBuy, models.InvestmentTransactionTypeStakingReward, models.InvestmentTransactionTypeAirdrop:
 			// обратная операция для покупки = продажа
 			return s.revertBuyTransaction(txCtx, tx)
-		case models.InvestmentTransactionTypeSell:
+		case models.InvestmentTransactionTypeSell, models.InvestmentTransactionTypeExpiration, models.InvestmentTransactionTypeRedemption:
 			// обратная операция для продажи = покупка
 			return s.revertSellTransaction(txCtx, tx)
+		case models.InvestmentTransactionTypeAmortization:
+			return s.revertAmortizationTransaction(txCtx, tx)
 		case models.InvestmentTransactionTypeSplit:
 			// обратная операция для сплита = обратный сплит
 			return s.revertSplitTransaction(txCtx, tx)
@@ -310,14 +901,226 @@ func (s *investmentService) DeleteTransaction(ctx context.Context, id uuid.UUID)
 	})
 }
 
+func (s *investmentService) RestoreTransaction(ctx context.Context, userID, id uuid.UUID) (*models.InvestmentTransaction, error) {
+	tx, err := s.investmentRepo.GetDeletedByID(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTransactionOwner(ctx, userID, tx); err != nil {
+		return nil, err
+	}
+
+	security, err := s.securityRepo.GetByID(ctx, tx.SecurityID)
+	if err != nil {
+		return nil, ErrSecurityNotFound
+	}
+
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		// пока операция была удалена, тот же номер сделки могли загрузить заново
+		if tx.BrokerRef != "" {
+			existing, err := s.investmentRepo.GetExistingBrokerRefs(txCtx, tx.PortfolioID, []string{tx.BrokerRef})
+			if err != nil {
+				return err
+			}
+			if existing[tx.BrokerRef] {
+				return ErrBrokerRefExists
+			}
+		}
+
+		restored, err := s.investmentRepo.Restore(txCtx, id)
+		if err != nil {
+			return err
+		}
+		if !restored {
+			return ErrTransactionNotFound
+		}
+
+		// операция может быть задним числом, поэтому позиция проводится заново по всей истории
+		_, err = s.rebuildHoldings(txCtx, tx.PortfolioID, map[uuid.UUID]*models.Security{security.ID: security})
+		return err
+	})
+	if errors.Is(err, repository.ErrDuplicateBrokerRef) {
+		return nil, ErrBrokerRefExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// checkTransactionOwner операция из портфеля пользователя; чужая выглядит как несуществующая
+func (s *investmentService) checkTransactionOwner(ctx context.Context, userID uuid.UUID, tx *models.InvestmentTransaction) error {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, tx.PortfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return ErrTransactionNotFound
+	}
+	return nil
+}
+
+func (s *investmentService) RebuildQueuedHoldings(ctx context.Context) (int, error) {
+	queued, err := s.investmentRepo.GetQueuedRebuilds(ctx, holdingRebuildBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	rebuilt := 0
+	for _, item := range queued {
+		security, err := s.securityRepo.GetByID(ctx, item.SecurityID)
+		if err != nil {
+			return rebuilt, err
+		}
+		err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+			if failedID, err := s.rebuildHoldings(txCtx, item.PortfolioID, map[uuid.UUID]*models.Security{security.ID: security}); err != nil {
+				return fmt.Errorf("операция %s: %w", failedID, err)
+			}
+			return s.investmentRepo.DeleteQueuedRebuild(txCtx, item.PortfolioID, item.SecurityID)
+		})
+		if err != nil {
+			// история не сходится - позиция остается в очереди, остальные пересчитываем
+			log.Printf("Не удалось пересчитать позицию %s в портфеле %s: %v", security.Ticker, item.PortfolioID, err)
+			continue
+		}
+		rebuilt++
+	}
+	return rebuilt, nil
+}
+
+func (s *investmentService) SettleExpiredDerivatives(ctx context.Context) (int, error) {
+	holdings, err := s.holdingRepo.GetExpiredDerivatives(ctx, truncateDay(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+
+	// расчетная цена после экспирации приходит в карточке контракта, запрашиваем один раз на бумагу
+	settlePrices := make(map[uuid.UUID]decimal.Decimal)
+	settled := 0
+	for _, h := range holdings {
+		price, ok := settlePrices[h.SecurityID]
+		if !ok {
+			price = h.Security.LastPrice
+			if info, err := s.marketProvider.GetSecurityInfo(ctx, h.Security.Ticker, h.Security.Exchange); err == nil && info.LastPrice.IsPositive() {
+				price = info.LastPrice
+			}
+			settlePrices[h.SecurityID] = price
+		}
+
+		_, err := s.AddTransaction(ctx, &models.InvestmentTransactionCreate{
+			PortfolioID: h.PortfolioID,
+			SecurityID:  h.SecurityID,
+			Type:        models.InvestmentTransactionTypeExpiration,
+			Date:        *h.Security.ExpiryDate,
+			Quantity:    h.Quantity,
+			Price:       price,
+			// контракт в валюте не портфеля - курс на дату экспирации
+			FetchExchangeRate: true,
+		})
+		if err != nil {
+			return settled, err
+		}
+		settled++
+	}
+
+	return settled, nil
+}
+
+func (s *investmentService) SyncBondPrincipal(ctx context.Context) (int, error) {
+	holdings, err := s.holdingRepo.GetBonds(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	today := truncateDay(time.Now())
+	schedules := make(map[uuid.UUID][]models.Amortization)
+	created := 0
+	for _, h := range holdings {
+		schedule, ok := schedules[h.SecurityID]
+		if !ok {
+			// провайдер без графика или биржа недоступна - попробуем при следующем запуске
+			schedule, _ = s.marketProvider.GetAmortizations(ctx, h.Security.Ticker, h.Security.Exchange)
+			sort.Slice(schedule, func(i, j int) bool { return schedule[i].Date.Before(schedule[j].Date) })
+			schedules[h.SecurityID] = schedule
+		}
+		if len(schedule) == 0 {
+			continue
+		}
+
+		transactions, err := s.investmentRepo.GetBySecurityID(ctx, h.PortfolioID, h.SecurityID)
+		if err != nil {
+			return created, err
+		}
+
+		remaining := h.Quantity
+		for _, a := range schedule {
+			if a.Date.After(today) || !a.Amount.IsPositive() || !remaining.IsPositive() {
+				continue
+			}
+			txType := models.InvestmentTransactionTypeAmortization
+			if a.IsRedemption {
+				txType = models.InvestmentTransactionTypeRedemption
+			}
+			if hasTransactionOn(transactions, txType, a.Date) {
+				continue
+			}
+
+			// выплата идет тем, кто держал бумаги до даты погашения; позиция, заведенная без сделок, не трогается
+			quantity := heldQuantity(transactions, h.SecurityID, a.Date)
+			if quantity.GreaterThan(remaining) {
+				quantity = remaining
+			}
+			if !quantity.IsPositive() {
+				continue
+			}
+
+			tx, err := s.AddTransaction(ctx, &models.InvestmentTransactionCreate{
+				PortfolioID:       h.PortfolioID,
+				SecurityID:        h.SecurityID,
+				Type:              txType,
+				Date:              a.Date,
+				Quantity:          quantity,
+				Price:             a.Amount,
+				Currency:          a.Currency,
+				FetchExchangeRate: true,
+			})
+			if err != nil {
+				return created, err
+			}
+			transactions = append(transactions, *tx)
+			if a.IsRedemption {
+				remaining = remaining.Sub(quantity)
+			}
+			created++
+		}
+	}
+
+	return created, nil
+}
+
+// hasTransactionOn есть ли уже операция этого типа в этот день
+func hasTransactionOn(transactions []models.InvestmentTransaction, txType models.InvestmentTransactionType, date time.Time) bool {
+	for _, tx := range transactions {
+		if tx.Type == txType && truncateDay(tx.Date).Equal(truncateDay(date)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *investmentService) GetHoldings(ctx context.Context, portfolioID uuid.UUID) ([]models.Holding, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
 	holdings, err := s.holdingRepo.GetByPortfolioID(ctx, portfolioID)
 	if err != nil {
 		return nil, err
 	}
 
 	// обогащаем холдинги текущими котировками
-	if err := s.enrichHoldings(ctx, holdings); err != nil {
+	if err := s.enrichHoldings(ctx, holdings, portfolio.Currency); err != nil {
 		return holdings, nil // возвращаем без обогащения при ошибке
 	}
 
@@ -325,6 +1128,11 @@ func (s *investmentService) GetHoldings(ctx context.Context, portfolioID uuid.UU
 }
 
 func (s *investmentService) GetHolding(ctx context.Context, portfolioID, securityID uuid.UUID) (*models.Holding, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
 	holding, err := s.holdingRepo.GetByPortfolioAndSecurity(ctx, portfolioID, securityID)
 	if err != nil {
 		return nil, err
@@ -332,7 +1140,7 @@ func (s *investmentService) GetHolding(ctx context.Context, portfolioID, securit
 
 	// обогащаем холдинг текущей котировкой
 	holdings := []models.Holding{*holding}
-	if err := s.enrichHoldings(ctx, holdings); err != nil {
+	if err := s.enrichHoldings(ctx, holdings, portfolio.Currency); err != nil {
 		return holding, nil // возвращаем без обогащения при ошибке
 	}
 
@@ -340,27 +1148,45 @@ func (s *investmentService) GetHolding(ctx context.Context, portfolioID, securit
 	return &enriched, nil
 }
 
-func (s *investmentService) GetPortfolioAnalytics(ctx context.Context, portfolioID uuid.UUID) (*models.PortfolioAnalytics, error) {
+func (s *investmentService) GetPortfolioAnalytics(ctx context.Context, portfolioID uuid.UUID, currency string) (*models.PortfolioAnalytics, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
 	holdings, err := s.holdingRepo.GetByPortfolioID(ctx, portfolioID)
 	if err != nil {
 		return nil, err
 	}
 
 	// обогащаем холдинги текущими котировками для расчета аналитики
-	if err := s.enrichHoldings(ctx, holdings); err != nil {
+	if err := s.enrichHoldings(ctx, holdings, portfolio.Currency); err != nil {
 		return nil, err
 	}
 
 	analytics := &models.PortfolioAnalytics{
 		PortfolioID:          portfolioID,
+		Currency:             reportCurrency(currency, portfolio.Currency, "RUB"),
 		AllocationByType:     make(map[models.SecurityType]decimal.Decimal),
 		AllocationBySector:   make(map[string]decimal.Decimal),
 		AllocationByCurrency: make(map[string]decimal.Decimal),
+		NotionalByAsset:      make(map[string]decimal.Decimal),
 	}
+	conv := newCurrencyConverter(s.marketProvider, analytics.Currency)
 
 	var totalValue, totalInvested decimal.Decimal
 
-	for _, h := range holdings {
+	for i := range holdings {
+		// переводим стоимость позиции в валюту отчета, чтобы доли и доходность считались в одной валюте
+		h := &holdings[i]
+		holdingCurrency := h.ValueCurrency(portfolio.Currency)
+		if h.CurrentValue, err = conv.convert(ctx, h.CurrentValue, holdingCurrency); err != nil {
+			return nil, err
+		}
+		if h.TotalCost, err = conv.convert(ctx, h.TotalCost, holdingCurrency); err != nil {
+			return nil, err
+		}
+
 		totalValue = totalValue.Add(h.CurrentValue)
 		totalInvested = totalInvested.Add(h.TotalCost)
 
@@ -375,6 +1201,12 @@ func (s *investmentService) GetPortfolioAnalytics(ctx context.Context, portfolio
 
 			// Currency allocation
 			analytics.AllocationByCurrency[h.Security.Currency] = analytics.AllocationByCurrency[h.Security.Currency].Add(h.CurrentValue)
+
+			if h.Security.Type == models.SecurityTypeDerivative {
+				if err := s.addDerivativeExposure(ctx, analytics, h, conv, holdingCurrency); err != nil {
+					return nil, err
+				}
+			}
 		}
 	}
 
@@ -396,16 +1228,194 @@ func (s *investmentService) GetPortfolioAnalytics(ctx context.Context, portfolio
 		}
 	}
 
+	if totalValue.GreaterThan(decimal.Zero) {
+		analytics.NotionalToValue = analytics.NotionalExposure.Div(totalValue).Mul(decimal.NewFromInt(100)).Round(2)
+	}
+
 	// получаем дивиденды за прошлый год
 	lastYear := time.Now().Year() - 1
 	totalDividends, _ := s.investmentRepo.GetTotalDividends(ctx, portfolioID, lastYear)
+	if totalDividends, err = conv.convert(ctx, totalDividends, portfolio.Currency); err != nil {
+		return nil, err
+	}
 	if totalValue.GreaterThan(decimal.Zero) {
 		analytics.DividendYield = totalDividends.Div(totalValue).Mul(decimal.NewFromInt(100))
 	}
 
+	// график стоимости и риск-метрики по сохраненной истории цен
+	if err := s.fillRiskMetrics(ctx, analytics, holdings, conv, portfolio.Currency); err != nil {
+		return nil, err
+	}
+
 	return analytics, nil
 }
 
+// addDerivativeExposure номинал и гарантийное обеспечение позиции по фьючерсу/опциону.
+// номинал опциона считается по страйку, фьючерса - по текущей цене
+func (s *investmentService) addDerivativeExposure(ctx context.Context, analytics *models.PortfolioAnalytics, h *models.Holding, conv *currencyConverter, currency string) error {
+	security := h.Security
+	quantity := h.Quantity.Abs()
+
+	price := h.CurrentPrice
+	if security.Strike != nil {
+		price = *security.Strike
+	}
+
+	notional, err := conv.convert(ctx, quantity.Mul(price).Mul(security.ContractSize()), currency)
+	if err != nil {
+		return err
+	}
+	analytics.NotionalExposure = analytics.NotionalExposure.Add(notional)
+
+	asset := security.Underlying
+	if asset == "" {
+		asset = security.Ticker
+	}
+	analytics.NotionalByAsset[asset] = analytics.NotionalByAsset[asset].Add(notional)
+
+	if security.InitialMargin != nil {
+		margin, err := conv.convert(ctx, quantity.Mul(*security.InitialMargin), currency)
+		if err != nil {
+			return err
+		}
+		analytics.MarginRequirement = analytics.MarginRequirement.Add(margin)
+	}
+	return nil
+}
+
+// fillRiskMetrics считает график стоимости, годовую волатильность и максимальную просадку
+// по дневным ценам за последний год. состав портфеля берется текущий
+func (s *investmentService) fillRiskMetrics(ctx context.Context, analytics *models.PortfolioAnalytics, holdings []models.Holding, conv *currencyConverter, portfolioCurrency string) error {
+	to := time.Now()
+	from := to.AddDate(0, 0, -riskHistoryDays)
+
+	type priceSeries struct {
+		quantity decimal.Decimal
+		rate     decimal.Decimal // курс валюты бумаги к валюте отчета
+		closes   map[time.Time]decimal.Decimal
+		first    time.Time
+	}
+
+	var series []priceSeries
+	dates := make(map[time.Time]bool)
+	for _, h := range holdings {
+		if !h.Quantity.IsPositive() {
+			continue
+		}
+
+		history, err := s.priceHistory.GetHistory(ctx, h.SecurityID, from, to)
+		if err != nil || len(history) == 0 {
+			continue // без истории бумага в метриках не участвует
+		}
+
+		rate, err := conv.rate(ctx, h.ValueCurrency(portfolioCurrency))
+		if err != nil {
+			return err
+		}
+
+		quantity := h.Quantity
+		if h.Security != nil {
+			quantity = quantity.Mul(h.Security.ContractSize())
+		}
+
+		ps := priceSeries{quantity: quantity, rate: rate, closes: make(map[time.Time]decimal.Decimal)}
+		for _, bar := range history {
+			if !bar.Close.IsPositive() {
+				continue
+			}
+			if ps.first.IsZero() {
+				ps.first = bar.Date
+			}
+			ps.closes[bar.Date] = bar.Close
+			dates[bar.Date] = true
+		}
+		if !ps.first.IsZero() {
+			series = append(series, ps)
+		}
+	}
+	if len(series) == 0 {
+		return nil
+	}
+
+	// ряд начинаем, когда есть цены по всем бумагам, иначе стоимость скачет при появлении новой бумаги
+	var start time.Time
+	for _, ps := range series {
+		if ps.first.After(start) {
+			start = ps.first
+		}
+	}
+
+	var days []time.Time
+	for date := range dates {
+		if !date.Before(start) {
+			days = append(days, date)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	// в дни без торгов по бумаге берем ее последнюю цену закрытия
+	lastClose := make([]decimal.Decimal, len(series))
+	values := make([]float64, 0, len(days))
+	for _, date := range days {
+		var value decimal.Decimal
+		for i, ps := range series {
+			if c, ok := ps.closes[date]; ok {
+				lastClose[i] = c
+			}
+			value = value.Add(lastClose[i].Mul(ps.quantity).Mul(ps.rate))
+		}
+		analytics.ValueHistory = append(analytics.ValueHistory, models.PortfolioValuePoint{Date: date, Value: value.Round(2)})
+		values = append(values, value.InexactFloat64())
+	}
+
+	analytics.Volatility = decimal.NewFromFloat(annualVolatility(values) * 100).Round(2)
+	analytics.MaxDrawdown = decimal.NewFromFloat(maxDrawdown(values) * 100).Round(2)
+	return nil
+}
+
+// annualVolatility стандартное отклонение дневных доходностей, приведенное к году
+func annualVolatility(values []float64) float64 {
+	var returns []float64
+	for i := 1; i < len(values); i++ {
+		if values[i-1] > 0 {
+			returns = append(returns, values[i]/values[i-1]-1)
+		}
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+
+	return math.Sqrt(variance) * math.Sqrt(tradingDaysPerYear)
+}
+
+// maxDrawdown наибольшее падение от пика до дна (отрицательная доля, 0 - просадок не было)
+func maxDrawdown(values []float64) float64 {
+	var peak, worst float64
+	for _, v := range values {
+		if v > peak {
+			peak = v
+		}
+		if peak > 0 {
+			if dd := v/peak - 1; dd < worst {
+				worst = dd
+			}
+		}
+	}
+	return worst
+}
+
 func (s *investmentService) GetTaxReport(ctx context.Context, portfolioID uuid.UUID, year int) (*models.TaxReport, error) {
 	startDate := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
 	endDate := time.Date(year, 12, 31, 23, 59, 59, 0, time.UTC)
@@ -431,16 +1441,30 @@ func (s *investmentService) GetTaxReport(ctx context.Context, portfolioID uuid.U
 		holdingMap[holdings[i].SecurityID] = &holdings[i]
 	}
 
+	dividendIncome := decimal.Zero
 	for _, tx := range transactions {
+		if tx.Type.IsDividendIncome() {
+			dividendIncome = dividendIncome.Add(tx.Amount)
+		}
 		switch tx.Type {
 		case models.InvestmentTransactionTypeDividend:
 			report.TotalDividends = report.TotalDividends.Add(tx.Amount)
 		case models.InvestmentTransactionTypeCoupon:
 			report.TotalCoupons = report.TotalCoupons.Add(tx.Amount)
-		case models.InvestmentTransactionTypeSell:
+		case models.InvestmentTransactionTypeStakingReward:
+			report.TotalStaking = report.TotalStaking.Add(tx.Amount)
+		case models.InvestmentTransactionTypeAirdrop:
+			report.TotalAirdrops = report.TotalAirdrops.Add(tx.Amount)
+		case models.InvestmentTransactionTypeAmortization:
+			report.TotalAmortization = report.TotalAmortization.Add(tx.Amount)
+		case models.InvestmentTransactionTypeSell, models.InvestmentTransactionTypeExpiration, models.InvestmentTransactionTypeRedemption:
 			// рассчитываем реализованную прибыль/убыток
-			// выручка = Quantity × Price - Commission
-			proceeds := tx.Quantity.Mul(tx.Price).Sub(tx.Commission)
+			// выручка = Quantity × Price - Commission (у деривативов цена умножается на стоимость пункта)
+			contractSize := decimal.NewFromInt(1)
+			if security, err := s.securityRepo.GetByID(ctx, tx.SecurityID); err == nil {
+				contractSize = security.ContractSize()
+			}
+			proceeds := tx.Quantity.Mul(tx.Price).Mul(contractSize).Sub(tx.Commission)
 
 			// себестоимость = Quantity × AveragePrice (на момент продажи)
 			// используем текущий AveragePrice из холдинга как приближение
@@ -450,7 +1474,7 @@ func (s *investmentService) GetTaxReport(ctx context.Context, portfolioID uuid.U
 			} else {
 				// если холдинга нет (продали всё), используем цену транзакции
 				// это приближение, в реальности нужно хранить историю покупок и FIFO принцип
-				costBasis = tx.Quantity.Mul(tx.Price)
+				costBasis = tx.Quantity.Mul(tx.Price).Mul(contractSize)
 			}
 
 			// Прибыль/Убыток = Выручка - Себестоимость
@@ -466,7 +1490,8 @@ func (s *investmentService) GetTaxReport(ctx context.Context, portfolioID uuid.U
 
 	report.Transactions = transactions
 
-	taxableIncome := report.TotalDividends.Add(report.TotalCoupons)
+	// крипто-награды облагаются как доход в момент получения, вместе с дивидендами
+	taxableIncome := dividendIncome.Add(report.TotalCoupons)
 	if report.RealizedGains.GreaterThan(report.RealizedLosses) {
 		report.NetGain = report.RealizedGains.Sub(report.RealizedLosses)
 		taxableIncome = taxableIncome.Add(report.NetGain)
@@ -475,10 +1500,19 @@ func (s *investmentService) GetTaxReport(ctx context.Context, portfolioID uuid.U
 	report.TaxableAmount = taxableIncome
 	report.EstimatedTax = taxableIncome.Mul(decimal.NewFromFloat(0.13))
 
+	// для ИИС - ожидаемый вычет (тип А) или освобождаемая прибыль (тип Б)
+	if portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID); err == nil && portfolio.IISType != nil {
+		flows, err := s.iisRepo.GetByPortfolioID(ctx, portfolioID)
+		if err != nil {
+			return nil, err
+		}
+		report.IIS = iisTaxInfo(portfolio, flows, year, report.NetGain)
+	}
+
 	return report, nil
 }
 
-func (s *investmentService) GetUpcomingDividends(ctx context.Context, portfolioID uuid.UUID) ([]models.Dividend, error) {
+func (s *investmentService) GetUpcomingDividends(ctx context.Context, portfolioID uuid.UUID, page, limit int) (*models.DividendList, error) {
 	// получаем все активы портфеля
 	holdings, err := s.holdingRepo.GetByPortfolioID(ctx, portfolioID)
 	if err != nil {
@@ -509,83 +1543,531 @@ func (s *investmentService) GetUpcomingDividends(ctx context.Context, portfolioI
 		allDividends = append(allDividends, divs...)
 	}
 
-	return allDividends, nil
+	page, limit, start, end := listPage(len(allDividends), page, limit)
+	total := int64(len(allDividends))
+	return &models.DividendList{
+		Dividends:  append([]models.Dividend{}, allDividends[start:end]...),
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: models.TotalPages(total, limit),
+	}, nil
 }
 
-// enrichHoldings обогащает холдинги текущими рыночными котировками
-func (s *investmentService) enrichHoldings(ctx context.Context, holdings []models.Holding) error {
-	if len(holdings) == 0 {
-		return nil
+// на сколько лет вперед достраиваем график купонов по ставке, если биржа его не отдала
+const couponEstimateYears = 10
+
+func (s *investmentService) GetUpcomingCoupons(ctx context.Context, userID, portfolioID uuid.UUID) ([]models.CouponPayment, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
 	}
+	return s.upcomingCoupons(ctx, portfolioID)
+}
 
-	// группируем тикеры по биржам
-	type exchangeGroup struct {
-		exchange models.Exchange
-		tickers  []string
-		indexes  []int // индексы холдингов
+// upcomingCoupons купоны портфеля, владелец которого уже проверен
+func (s *investmentService) upcomingCoupons(ctx context.Context, portfolioID uuid.UUID) ([]models.CouponPayment, error) {
+	holdings, err := s.holdingRepo.GetByPortfolioID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
 	}
 
-	exchangeGroups := make(map[models.Exchange]*exchangeGroup)
+	now := time.Now()
+	payments := []models.CouponPayment{}
 
 	for i := range holdings {
-		if holdings[i].Security == nil {
+		h := &holdings[i]
+		if h.Security == nil || h.Security.Type != models.SecurityTypeBond || !h.Quantity.IsPositive() {
 			continue
 		}
 
-		exchange := holdings[i].Security.Exchange
-		if group, exists := exchangeGroups[exchange]; exists {
-			group.tickers = append(group.tickers, holdings[i].Security.Ticker)
-			group.indexes = append(group.indexes, i)
-		} else {
-			exchangeGroups[exchange] = &exchangeGroup{
-				exchange: exchange,
-				tickers:  []string{holdings[i].Security.Ticker},
-				indexes:  []int{i},
+		estimated := false
+		coupons, err := s.marketProvider.GetCoupons(ctx, h.Security.Ticker, h.Security.Exchange)
+		if err != nil || len(coupons) == 0 {
+			// графика нет - строим по ставке купона и дате погашения
+			coupons = estimateCoupons(h.Security, now)
+			estimated = true
+		}
+
+		for _, c := range coupons {
+			if c.Date.Before(now) {
+				continue
 			}
+
+			payment := models.CouponPayment{
+				SecurityID:    h.Security.ID,
+				Date:          c.Date,
+				AmountPerBond: c.Amount,
+				Quantity:      h.Quantity,
+				Currency:      c.Currency,
+				IsEstimated:   estimated,
+				Security:      h.Security,
+			}
+			if payment.Currency == "" {
+				payment.Currency = h.Security.Currency
+			}
+
+			// будущие купоны флоатеров еще не объявлены - оцениваем по последней известной ставке
+			if payment.AmountPerBond.IsZero() {
+				rate := c.Rate
+				if rate == nil {
+					rate = h.Security.CouponRate
+				}
+				if amount, ok := couponAmountByRate(h.Security, rate); ok {
+					payment.AmountPerBond = amount
+					payment.IsEstimated = true
+				}
+			}
+
+			payment.TotalAmount = payment.AmountPerBond.Mul(h.Quantity).Round(2)
+			payments = append(payments, payment)
 		}
 	}
 
-	// получаем котировки для каждой биржи
-	allQuotes := make(map[string]*models.MarketQuote)
-	for _, group := range exchangeGroups {
-		quotes, err := s.marketProvider.GetQuotes(ctx, group.tickers, group.exchange)
+	sort.Slice(payments, func(i, j int) bool { return payments[i].Date.Before(payments[j].Date) })
+	return payments, nil
+}
+
+func (s *investmentService) GetIncomeCalendar(ctx context.Context, userID, portfolioID uuid.UUID, currency string) (*models.IncomeCalendar, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+
+	calendar := &models.IncomeCalendar{
+		PortfolioID: portfolioID,
+		Currency:    reportCurrency(currency, portfolio.Currency, "RUB"),
+		Events:      []models.IncomeEvent{},
+	}
+	conv := newCurrencyConverter(s.marketProvider, calendar.Currency)
+
+	now := time.Now()
+	yearAhead := now.AddDate(1, 0, 0)
+
+	coupons, err := s.upcomingCoupons(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range coupons {
+		event := models.IncomeEvent{
+			Type:          models.IncomeEventCoupon,
+			Date:          c.Date,
+			SecurityID:    c.SecurityID,
+			AmountPerUnit: c.AmountPerBond,
+			Quantity:      c.Quantity,
+			TotalAmount:   c.TotalAmount,
+			Currency:      c.Currency,
+			IsEstimated:   c.IsEstimated,
+		}
+		if c.Security != nil {
+			event.Ticker = c.Security.Ticker
+		}
+
+		converted, err := conv.convert(ctx, c.TotalAmount, c.Currency)
+		if err != nil {
+			return nil, err
+		}
+		calendar.UpcomingTotal = calendar.UpcomingTotal.Add(converted)
+		if !c.Date.After(yearAhead) {
+			calendar.ProjectedAnnualFixedIncome = calendar.ProjectedAnnualFixedIncome.Add(converted)
+		}
+		calendar.Events = append(calendar.Events, event)
+	}
+
+	// дивиденды: берем только еще не выплаченные
+	holdings, err := s.holdingRepo.GetByPortfolioID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range holdings {
+		h := &holdings[i]
+		if h.Security == nil || h.Security.Type == models.SecurityTypeBond || !h.Quantity.IsPositive() {
+			continue
+		}
+
+		dividends, err := s.marketProvider.GetDividends(ctx, h.Security.Ticker, h.Security.Exchange)
+		if err != nil {
+			continue
+		}
+		for _, d := range dividends {
+			date := d.PaymentDate
+			if date.IsZero() {
+				date = d.RecordDate
+			}
+			if date.Before(now) {
+				continue
+			}
+
+			total := d.Amount.Mul(h.Quantity).Round(2)
+			converted, err := conv.convert(ctx, total, d.Currency)
+			if err != nil {
+				return nil, err
+			}
+			calendar.UpcomingTotal = calendar.UpcomingTotal.Add(converted)
+			calendar.Events = append(calendar.Events, models.IncomeEvent{
+				Type:          models.IncomeEventDividend,
+				Date:          date,
+				SecurityID:    h.Security.ID,
+				Ticker:        h.Security.Ticker,
+				AmountPerUnit: d.Amount,
+				Quantity:      h.Quantity,
+				TotalAmount:   total,
+				Currency:      d.Currency,
+			})
+		}
+	}
+
+	sort.Slice(calendar.Events, func(i, j int) bool { return calendar.Events[i].Date.Before(calendar.Events[j].Date) })
+	calendar.UpcomingTotal = calendar.UpcomingTotal.Round(2)
+	calendar.ProjectedAnnualFixedIncome = calendar.ProjectedAnnualFixedIncome.Round(2)
+
+	return calendar, nil
+}
+
+func (s *investmentService) GetIncomeReport(ctx context.Context, userID, portfolioID uuid.UUID, currency string) (*models.PortfolioIncomeReport, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+
+	report := &models.PortfolioIncomeReport{
+		PortfolioID: portfolioID,
+		Currency:    reportCurrency(currency, portfolio.Currency, "RUB"),
+		ByMonth:     []models.IncomePeriod{},
+		ByYear:      []models.IncomePeriod{},
+		Holdings:    []models.HoldingIncome{},
+	}
+	conv := newCurrencyConverter(s.marketProvider, report.Currency)
+
+	now := time.Now()
+	yearAgo := now.AddDate(-1, 0, 0)
+	yearAhead := now.AddDate(1, 0, 0)
+
+	// позиции по бумагам: текущие холдинги плюс бумаги, по которым когда-то был доход
+	byID := make(map[uuid.UUID]*models.HoldingIncome)
+	var order []uuid.UUID
+	position := func(securityID uuid.UUID, security *models.Security) *models.HoldingIncome {
+		if hi, ok := byID[securityID]; ok {
+			return hi
+		}
+		hi := &models.HoldingIncome{SecurityID: securityID}
+		if security != nil {
+			hi.Ticker = security.Ticker
+			hi.Type = security.Type
+		}
+		byID[securityID] = hi
+		order = append(order, securityID)
+		return hi
+	}
+
+	holdings, err := s.holdingRepo.GetByPortfolioID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range holdings {
+		h := &holdings[i]
+		if !h.Quantity.IsPositive() {
+			continue
+		}
+		hi := position(h.SecurityID, h.Security)
+		hi.Quantity = h.Quantity
+		cost, err := conv.convert(ctx, h.TotalCost, h.ValueCurrency(portfolio.Currency))
+		if err != nil {
+			return nil, err
+		}
+		hi.TotalCost = cost
+		report.TotalCost = report.TotalCost.Add(cost)
+	}
+
+	// полученные выплаты
+	income, err := s.investmentRepo.GetIncome(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	months := make(map[string]*models.IncomePeriod)
+	years := make(map[string]*models.IncomePeriod)
+	for _, tx := range income {
+		amount, err := conv.convertAt(ctx, tx.Amount, tx.Currency, tx.Date)
 		if err != nil {
-			// Пропускаем ошибки для конкретной биржи, продолжаем с остальными
+			return nil, err
+		}
+
+		periods := []*models.IncomePeriod{
+			incomePeriod(months, tx.Date.Format("2006-01")),
+			incomePeriod(years, tx.Date.Format("2006")),
+		}
+
+		// возврат номинала - это вложения, а не доход: в доходность не попадает
+		if tx.Type == models.InvestmentTransactionTypeAmortization || tx.Type == models.InvestmentTransactionTypeRedemption {
+			for _, p := range periods {
+				p.Principal = p.Principal.Add(amount)
+			}
+			report.TotalPrincipal = report.TotalPrincipal.Add(amount)
 			continue
 		}
-		for ticker, quote := range quotes {
-			allQuotes[ticker] = quote
+
+		for _, p := range periods {
+			if tx.Type == models.InvestmentTransactionTypeCoupon {
+				p.Coupons = p.Coupons.Add(amount)
+			} else {
+				p.Dividends = p.Dividends.Add(amount)
+			}
+			p.Total = p.Total.Add(amount)
+		}
+
+		hi := position(tx.SecurityID, tx.Security)
+		hi.TotalReceived = hi.TotalReceived.Add(amount)
+		report.TotalReceived = report.TotalReceived.Add(amount)
+		if tx.Date.After(yearAgo) {
+			hi.TTMIncome = hi.TTMIncome.Add(amount)
+			report.TTMIncome = report.TTMIncome.Add(amount)
 		}
 	}
 
-	// рассчитываем общую стоимость портфеля для Weight
-	var totalPortfolioValue decimal.Decimal
+	// прогноз: купоны по графику и объявленные дивиденды на год вперед
+	coupons, err := s.upcomingCoupons(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range coupons {
+		if c.Date.After(yearAhead) {
+			continue
+		}
+		amount, err := conv.convert(ctx, c.TotalAmount, c.Currency)
+		if err != nil {
+			return nil, err
+		}
+		hi := position(c.SecurityID, c.Security)
+		hi.ProjectedIncome = hi.ProjectedIncome.Add(amount)
+		report.ProjectedIncome = report.ProjectedIncome.Add(amount)
+	}
 	for i := range holdings {
-		if holdings[i].Security != nil {
-			if quote, ok := allQuotes[holdings[i].Security.Ticker]; ok {
-				currentValue := holdings[i].Quantity.Mul(quote.LastPrice)
-				totalPortfolioValue = totalPortfolioValue.Add(currentValue)
+		h := &holdings[i]
+		if h.Security == nil || h.Security.Type == models.SecurityTypeBond || !h.Quantity.IsPositive() {
+			continue
+		}
+
+		dividends, err := s.marketProvider.GetDividends(ctx, h.Security.Ticker, h.Security.Exchange)
+		if err != nil {
+			continue
+		}
+		for _, d := range dividends {
+			date := d.PaymentDate
+			if date.IsZero() {
+				date = d.RecordDate
 			}
+			if date.Before(now) || date.After(yearAhead) {
+				continue
+			}
+			amount, err := conv.convert(ctx, d.Amount.Mul(h.Quantity), d.Currency)
+			if err != nil {
+				return nil, err
+			}
+			hi := position(h.SecurityID, h.Security)
+			hi.ProjectedIncome = hi.ProjectedIncome.Add(amount)
+			report.ProjectedIncome = report.ProjectedIncome.Add(amount)
 		}
 	}
 
-	// заполняем вычисляемые поля для каждого холдинга
+	for _, id := range order {
+		hi := byID[id]
+		hi.TotalCost = hi.TotalCost.Round(2)
+		hi.TotalReceived = hi.TotalReceived.Round(2)
+		hi.TTMIncome = hi.TTMIncome.Round(2)
+		hi.ProjectedIncome = hi.ProjectedIncome.Round(2)
+		hi.YieldOnCost = yieldPercent(hi.TTMIncome, hi.TotalCost)
+		hi.ForwardYield = yieldPercent(hi.ProjectedIncome, hi.TotalCost)
+		report.Holdings = append(report.Holdings, *hi)
+	}
+	sort.SliceStable(report.Holdings, func(i, j int) bool {
+		return report.Holdings[i].TTMIncome.GreaterThan(report.Holdings[j].TTMIncome)
+	})
+
+	report.ByMonth = sortedIncomePeriods(months)
+	report.ByYear = sortedIncomePeriods(years)
+	report.TotalReceived = report.TotalReceived.Round(2)
+	report.TotalPrincipal = report.TotalPrincipal.Round(2)
+	report.TTMIncome = report.TTMIncome.Round(2)
+	report.ProjectedIncome = report.ProjectedIncome.Round(2)
+	report.TotalCost = report.TotalCost.Round(2)
+	report.YieldOnCost = yieldPercent(report.TTMIncome, report.TotalCost)
+	report.ForwardYield = yieldPercent(report.ProjectedIncome, report.TotalCost)
+
+	return report, nil
+}
+
+func incomePeriod(periods map[string]*models.IncomePeriod, key string) *models.IncomePeriod {
+	p, ok := periods[key]
+	if !ok {
+		p = &models.IncomePeriod{Period: key}
+		periods[key] = p
+	}
+	return p
+}
+
+// yieldPercent доход в % от вложений; без вложений (бумага продана) - 0
+func yieldPercent(income, cost decimal.Decimal) decimal.Decimal {
+	if !cost.IsPositive() {
+		return decimal.Zero
+	}
+	return income.Div(cost).Mul(decimal.NewFromInt(100)).Round(2)
+}
+
+// sortedIncomePeriods периоды по возрастанию, суммы округлены
+func sortedIncomePeriods(periods map[string]*models.IncomePeriod) []models.IncomePeriod {
+	result := make([]models.IncomePeriod, 0, len(periods))
+	for _, p := range periods {
+		result = append(result, models.IncomePeriod{
+			Period:    p.Period,
+			Dividends: p.Dividends.Round(2),
+			Coupons:   p.Coupons.Round(2),
+			Total:     p.Total.Round(2),
+			Principal: p.Principal.Round(2),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Period < result[j].Period })
+	return result
+}
+
+// estimateCoupons строит график купонов от даты погашения назад с шагом 12/частота месяцев
+func estimateCoupons(security *models.Security, from time.Time) []models.Coupon {
+	if security.MaturityDate == nil || security.CouponFreq == nil || *security.CouponFreq <= 0 || *security.CouponFreq > 12 {
+		return nil
+	}
+	amount, ok := couponAmountByRate(security, security.CouponRate)
+	if !ok {
+		return nil
+	}
+
+	stepMonths := 12 / *security.CouponFreq
+	limit := from.AddDate(couponEstimateYears, 0, 0)
+
+	// даты отсчитываем от погашения, а не от предыдущего купона: иначе они уползают в конце месяца
+	var coupons []models.Coupon
+	for n := 0; ; n++ {
+		date := security.MaturityDate.AddDate(0, -stepMonths*n, 0)
+		if date.Before(from) {
+			break
+		}
+		if date.After(limit) {
+			continue
+		}
+		coupons = append(coupons, models.Coupon{
+			Date:     date,
+			Amount:   amount,
+			Rate:     security.CouponRate,
+			Currency: security.Currency,
+		})
+	}
+	return coupons
+}
+
+// couponAmountByRate размер одного купона: номинал × ставка / частота
+func couponAmountByRate(security *models.Security, rate *decimal.Decimal) (decimal.Decimal, bool) {
+	if rate == nil || security.FaceValue == nil || security.CouponFreq == nil || *security.CouponFreq <= 0 {
+		return decimal.Zero, false
+	}
+	return security.FaceValue.Mul(*rate).Div(decimal.NewFromInt(100)).Div(decimal.NewFromInt(int64(*security.CouponFreq))).Round(2), true
+}
+
+// isRewardTransaction - операции, по которым монеты приходят без покупки
+func isRewardTransaction(t models.InvestmentTransactionType) bool {
+	return t == models.InvestmentTransactionTypeStakingReward || t == models.InvestmentTransactionTypeAirdrop
+}
+
+// знаков после запятой в количестве по типу бумаги; чего нет в таблице - только целые
+var quantityDecimals = map[models.SecurityType]int32{
+	models.SecurityTypeMutualFund: 5, // паи ПИФов учитываются дробно
+	models.SecurityTypeCurrency:   2,
+	models.SecurityTypeCrypto:     8,
+}
+
+// то же для сделок с allow_fractional: дробные акции и фонды у зарубежных/внебиржевых брокеров
+var fractionalQuantityDecimals = map[models.SecurityType]int32{
+	models.SecurityTypeStock:     6,
+	models.SecurityTypePreferred: 6,
+	models.SecurityTypeDR:        6,
+	models.SecurityTypeETF:       6,
+}
+
+// validateQuantity проверяет количество в сделках с бумагами: точность по типу бумаги и кратность лоту.
+// лотность проверяется только для биржевых покупок и продаж, allow_fractional ее отключает
+func validateQuantity(security *models.Security, input *models.InvestmentTransactionCreate) error {
+	switch input.Type {
+	case models.InvestmentTransactionTypeBuy, models.InvestmentTransactionTypeSell,
+		models.InvestmentTransactionTypeTransferIn, models.InvestmentTransactionTypeTransferOut:
+	default:
+		return nil
+	}
+
+	if !input.Quantity.IsPositive() {
+		return ErrInvalidQuantity
+	}
+
+	decimals := quantityDecimals[security.Type]
+	if input.AllowFractional {
+		if d, ok := fractionalQuantityDecimals[security.Type]; ok {
+			decimals = d
+		}
+	}
+	if !input.Quantity.Equal(input.Quantity.Truncate(decimals)) {
+		return ErrQuantityPrecision
+	}
+
+	if input.AllowFractional || security.LotSize <= 1 || security.Exchange != models.ExchangeMOEX {
+		return nil
+	}
+	if input.Type != models.InvestmentTransactionTypeBuy && input.Type != models.InvestmentTransactionTypeSell {
+		return nil
+	}
+	if !input.Quantity.Mod(decimal.NewFromInt(int64(security.LotSize))).IsZero() {
+		return ErrLotSizeMismatch
+	}
+	return nil
+}
+
+// enrichHoldings обогащает холдинги текущими рыночными котировками и переводит их стоимость в валюту портфеля
+func (s *investmentService) enrichHoldings(ctx context.Context, holdings []models.Holding, portfolioCurrency string) error {
+	if len(holdings) == 0 {
+		return nil
+	}
+
+	// группируем тикеры по биржам
+	tickersByExchange := make(map[models.Exchange][]string)
 	for i := range holdings {
-		if holdings[i].Security == nil {
+		// у бумаг, заведенных вручную, котировок нет - цена та, что ввел пользователь
+		if holdings[i].Security == nil || holdings[i].Security.IsManual() {
 			continue
 		}
+		exchange := holdings[i].Security.Exchange
+		tickersByExchange[exchange] = append(tickersByExchange[exchange], holdings[i].Security.Ticker)
+	}
 
-		ticker := holdings[i].Security.Ticker
-		quote, ok := allQuotes[ticker]
-		if !ok {
+	// получаем котировки сразу по всем биржам (параллельно), ошибки отдельных бирж пропускаем
+	quotesByExchange, _ := s.marketProvider.GetQuotesByExchange(ctx, tickersByExchange)
+
+	// заполняем вычисляемые поля для каждого холдинга (в валюте бумаги)
+	for i := range holdings {
+		if holdings[i].Security == nil {
 			continue
 		}
 
-		// CurrentPrice - текущая рыночная цена
-		holdings[i].CurrentPrice = quote.LastPrice
+		price, accrued := holdings[i].Security.LastPrice, holdings[i].Security.AccruedInterest
+		if !holdings[i].Security.IsManual() {
+			quote, ok := quotesByExchange[holdings[i].Security.Exchange][holdings[i].Security.Ticker]
+			if !ok {
+				continue
+			}
+			price, accrued = quote.LastPrice, quote.AccruedInterest
+		}
+
+		// CurrentPrice - текущая рыночная цена (у облигаций - чистая, без НКД)
+		holdings[i].CurrentPrice = price
+		holdings[i].AccruedInterest = holdings[i].Quantity.Mul(accrued)
 
-		// CurrentValue = Quantity × CurrentPrice
-		holdings[i].CurrentValue = holdings[i].Quantity.Mul(quote.LastPrice)
+		// CurrentValue = Quantity × CurrentPrice (× стоимость пункта для деривативов) + НКД облигаций:
+		// для облигации MOEX это (цена в % × номинал + НКД) × количество
+		holdings[i].CurrentValue = holdings[i].Quantity.Mul(price).Mul(holdings[i].Security.ContractSize()).Add(holdings[i].AccruedInterest)
 
 		// Profit = CurrentValue - TotalCost
 		holdings[i].Profit = holdings[i].CurrentValue.Sub(holdings[i].TotalCost)
@@ -594,12 +2076,9 @@ func (s *investmentService) enrichHoldings(ctx context.Context, holdings []model
 		if holdings[i].TotalCost.GreaterThan(decimal.Zero) {
 			holdings[i].ProfitPercent = holdings[i].Profit.Div(holdings[i].TotalCost).Mul(decimal.NewFromInt(100))
 		}
-
-		// Weight = (CurrentValue / TotalPortfolioValue) × 100
-		if totalPortfolioValue.GreaterThan(decimal.Zero) {
-			holdings[i].Weight = holdings[i].CurrentValue.Div(totalPortfolioValue).Mul(decimal.NewFromInt(100))
-		}
 	}
 
-	return nil
+	// доли считаем в валюте портфеля: крипта в USD и акции в RUB складываются только после конвертации
+	_, _, err := convertHoldings(ctx, newCurrencyConverter(s.marketProvider, portfolioCurrency), holdings)
+	return err
 }
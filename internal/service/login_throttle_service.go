@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/notify"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts, try again later")
+	ErrAccountLocked        = errors.New("login is temporarily locked after too many failed attempts")
+	ErrInvalidUnlockToken   = errors.New("invalid or expired unlock token")
+)
+
+// первая задержка после бесплатных попыток, дальше удваивается
+const loginBackoffBase = time.Second
+
+// LoginThrottledError отказ во входе до истечения задержки или блокировки; errors.Is сравнивает с Err
+type LoginThrottledError struct {
+	Err        error // ErrTooManyLoginAttempts или ErrAccountLocked
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// LoginThrottleService защита входа от перебора паролей. счетчики в бд, поэтому общие для всех экземпляров
+type LoginThrottleService interface {
+	// Check отказывает *LoginThrottledError, пока email или адрес клиента заблокирован
+	Check(ctx context.Context, email string, client models.ClientInfo) error
+	// Failure учитывает неудачный вход; user nil - пользователя с таким email нет
+	Failure(ctx context.Context, email string, user *models.User, client models.ClientInfo)
+	// Success сбрасывает счетчик email после успешного входа (счетчик адреса не трогаем)
+	Success(ctx context.Context, email string)
+	// Unlock снимает блокировку кодом из письма
+	Unlock(ctx context.Context, token string, client models.ClientInfo) error
+	// Reset снимает блокировку email без кода (сброс пароля администратором)
+	Reset(ctx context.Context, email string) error
+	// PurgeStale удаляет счетчики, по которым давно не было неудач
+	PurgeStale(ctx context.Context) (int64, error)
+}
+
+type loginThrottleService struct {
+	attemptRepo         repository.LoginAttemptRepository
+	userRepo            repository.UserRepository
+	refreshTokenRepo    repository.RefreshTokenRepository
+	notificationService NotificationService
+	mailer              notify.Mailer // nil - код разблокировки не отправить, остается ждать
+	config              *config.Config
+}
+
+func NewLoginThrottleService(
+	attemptRepo repository.LoginAttemptRepository,
+	userRepo repository.UserRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	notificationService NotificationService,
+	mailer notify.Mailer,
+	cfg *config.Config,
+) LoginThrottleService {
+	return &loginThrottleService{
+		attemptRepo:         attemptRepo,
+		userRepo:            userRepo,
+		refreshTokenRepo:    refreshTokenRepo,
+		notificationService: notificationService,
+		mailer:              mailer,
+		config:              cfg,
+	}
+}
+
+func loginEmailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (s *loginThrottleService) Check(ctx context.Context, email string, client models.ClientInfo) error {
+	if !s.config.LoginThrottleEnabled {
+		return nil
+	}
+
+	keys := []string{loginEmailKey(email)}
+	if client.IPAddress != "" {
+		keys = append(keys, loginIPKey(client.IPAddress))
+	}
+	now := time.Now()
+	locked, err := s.attemptRepo.GetLocked(ctx, keys, now)
+	if err != nil {
+		return err
+	}
+
+	var denied *LoginThrottledError
+	for _, attempt := range locked {
+		retryAfter := attempt.LockedUntil.Sub(now)
+		reason := ErrTooManyLoginAttempts
+		if s.isLockout(&attempt) {
+			reason = ErrAccountLocked
+		}
+		if denied == nil || retryAfter > denied.RetryAfter {
+			denied = &LoginThrottledError{Err: reason, RetryAfter: retryAfter}
+		}
+	}
+	if denied != nil {
+		return denied
+	}
+	return nil
+}
+
+func (s *loginThrottleService) Failure(ctx context.Context, email string, user *models.User, client models.ClientInfo) {
+	if !s.config.LoginThrottleEnabled {
+		return
+	}
+	now := time.Now()
+
+	if client.IPAddress != "" {
+		key := loginIPKey(client.IPAddress)
+		attempt, err := s.attemptRepo.RegisterFailure(ctx, key, now, s.config.LoginAttemptWindow)
+		if err != nil {
+			log.Printf("Не удалось учесть неудачный вход с адреса %s: %v", client.IPAddress, err)
+		} else if delay := s.backoff(attempt.Failures, s.config.LoginIPFreeAttempts); delay > 0 {
+			if err := s.attemptRepo.Lock(ctx, key, now.Add(delay), ""); err != nil {
+				log.Printf("Не удалось задержать вход с адреса %s: %v", client.IPAddress, err)
+			}
+		}
+	}
+
+	// неизвестный email считаем так же, иначе по ответам можно понять, какие адреса зарегистрированы
+	key := loginEmailKey(email)
+	attempt, err := s.attemptRepo.RegisterFailure(ctx, key, now, s.config.LoginAttemptWindow)
+	if err != nil {
+		log.Printf("Не удалось учесть неудачный вход для %s: %v", email, err)
+		return
+	}
+	if s.isLockout(attempt) {
+		s.lockout(ctx, key, attempt, user, client, now)
+		return
+	}
+	if delay := s.backoff(attempt.Failures, s.config.LoginFreeAttempts); delay > 0 {
+		if err := s.attemptRepo.Lock(ctx, key, now.Add(delay), ""); err != nil {
+			log.Printf("Не удалось задержать вход для %s: %v", email, err)
+		}
+	}
+}
+
+// isLockout счетчик email дошел до полной блокировки (а не очередной задержки)
+func (s *loginThrottleService) isLockout(attempt *models.LoginAttempt) bool {
+	threshold := s.config.LoginLockoutThreshold
+	return threshold > 0 && strings.HasPrefix(attempt.Key, "email:") && attempt.Failures >= threshold
+}
+
+// backoff задержка после failures неудач: первые free бесплатно, дальше 1с, 2с, 4с... до LoginBackoffMax
+func (s *loginThrottleService) backoff(failures, free int) time.Duration {
+	over := failures - free
+	if over <= 0 {
+		return 0
+	}
+	delay := loginBackoffBase
+	for i := 1; i < over && delay < s.config.LoginBackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, s.config.LoginBackoffMax)
+}
+
+// lockout блокирует вход по email и отправляет владельцу код разблокировки
+func (s *loginThrottleService) lockout(ctx context.Context, key string, attempt *models.LoginAttempt, user *models.User, client models.ClientInfo, now time.Time) {
+	until := now.Add(s.config.LoginLockoutDuration)
+
+	// без пользователя код отправить некому - блокировка просто истечет
+	token := ""
+	if user != nil && s.mailer != nil {
+		raw := make([]byte, 24)
+		if _, err := rand.Read(raw); err == nil {
+			token = base64.RawURLEncoding.EncodeToString(raw)
+		}
+	}
+	if err := s.attemptRepo.Lock(ctx, key, until, token); err != nil {
+		log.Printf("Не удалось заблокировать вход для %s: %v", key, err)
+		return
+	}
+	if user == nil {
+		return
+	}
+
+	log.Printf("Вход пользователя %s заблокирован до %s после %d неудачных попыток", user.ID, until.Format(time.RFC3339), attempt.Failures)
+	_ = s.refreshTokenRepo.CreateSecurityEvent(ctx, &models.SecurityEvent{
+		UserID:    user.ID,
+		Type:      models.SecurityEventAccountLocked,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		Details:   fmt.Sprintf("%d неудачных попыток входа, блокировка до %s", attempt.Failures, until.Format(time.RFC3339)),
+	})
+
+	untilLocal := until.In(userLocation(user)).Format("02.01.2006 15:04")
+	s.notificationService.Notify(ctx, &models.Notification{
+		UserID: user.ID,
+		Type:   models.NotificationSecurity,
+		Title:  "Вход в аккаунт временно заблокирован",
+		Body:   fmt.Sprintf("%d неудачных попыток входа подряд, последняя с адреса %s. Вход заблокирован до %s", attempt.Failures, client.IPAddress, untilLocal),
+	})
+
+	if token == "" {
+		return
+	}
+	err := s.mailer.Send(ctx, notify.Email{
+		To:      user.Email,
+		Subject: "Вход в FinTracker временно заблокирован",
+		Text: fmt.Sprintf("Было %d неудачных попыток войти в ваш аккаунт, последняя с адреса %s.\n"+
+			"Вход заблокирован до %s.\n\n"+
+			"Если это были вы, снимите блокировку кодом: %s\n"+
+			"Если нет - после разблокировки смените пароль и проверьте активные сессии.\n",
+			attempt.Failures, client.IPAddress, untilLocal, token),
+	})
+	if err != nil {
+		log.Printf("Не удалось отправить код разблокировки пользователю %s: %v", user.ID, err)
+	}
+}
+
+func (s *loginThrottleService) Success(ctx context.Context, email string) {
+	if !s.config.LoginThrottleEnabled {
+		return
+	}
+	if err := s.attemptRepo.Reset(ctx, loginEmailKey(email)); err != nil {
+		log.Printf("Не удалось сбросить счетчик входов для %s: %v", email, err)
+	}
+}
+
+func (s *loginThrottleService) Unlock(ctx context.Context, token string, client models.ClientInfo) error {
+	key, err := s.attemptRepo.Unlock(ctx, strings.TrimSpace(token), time.Now())
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalidUnlockToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if user, err := s.userRepo.GetByEmail(ctx, strings.TrimPrefix(key, "email:")); err == nil {
+		_ = s.refreshTokenRepo.CreateSecurityEvent(ctx, &models.SecurityEvent{
+			UserID:    user.ID,
+			Type:      models.SecurityEventAccountUnlock,
+			UserAgent: client.UserAgent,
+			IPAddress: client.IPAddress,
+		})
+	}
+	return nil
+}
+
+func (s *loginThrottleService) Reset(ctx context.Context, email string) error {
+	return s.attemptRepo.Reset(ctx, loginEmailKey(email))
+}
+
+func (s *loginThrottleService) PurgeStale(ctx context.Context) (int64, error) {
+	return s.attemptRepo.DeleteStale(ctx, time.Now().Add(-max(s.config.LoginAttemptWindow, 24*time.Hour)))
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// сколько лет нужно владеть бумагой для льготы долгосрочного владения (ЛДВ)
+const ldvMinYears = 3
+
+// purchaseLot непроданный остаток одной покупки
+type purchaseLot struct {
+	date     time.Time
+	quantity decimal.Decimal
+}
+
+// trackLots восстанавливает открытые лоты по сделкам портфеля: продажи списывают самые старые
+// покупки (FIFO), сплит меняет количество во всех лотах. бумаги, введенные от другого брокера,
+// считаются купленными в день ввода
+func trackLots(transactions []models.InvestmentTransaction) map[uuid.UUID][]purchaseLot {
+	sorted := make([]models.InvestmentTransaction, len(transactions))
+	copy(sorted, transactions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Date.Equal(sorted[j].Date) {
+			return sorted[i].Date.Before(sorted[j].Date)
+		}
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	lots := make(map[uuid.UUID][]purchaseLot)
+	for _, tx := range sorted {
+		switch tx.Type {
+		case models.InvestmentTransactionTypeBuy, models.InvestmentTransactionTypeTransferIn,
+			models.InvestmentTransactionTypeStakingReward, models.InvestmentTransactionTypeAirdrop:
+			if tx.Quantity.IsPositive() {
+				lots[tx.SecurityID] = append(lots[tx.SecurityID], purchaseLot{date: tx.Date, quantity: tx.Quantity})
+			}
+
+		case models.InvestmentTransactionTypeSell, models.InvestmentTransactionTypeTransferOut,
+			models.InvestmentTransactionTypeExpiration, models.InvestmentTransactionTypeRedemption:
+			remaining := tx.Quantity
+			open := lots[tx.SecurityID]
+			for len(open) > 0 && remaining.IsPositive() {
+				if open[0].quantity.GreaterThan(remaining) {
+					open[0].quantity = open[0].quantity.Sub(remaining)
+					remaining = decimal.Zero
+					break
+				}
+				remaining = remaining.Sub(open[0].quantity)
+				open = open[1:]
+			}
+			lots[tx.SecurityID] = open
+
+		case models.InvestmentTransactionTypeSplit:
+			// Quantity сплита - коэффициент
+			if tx.Quantity.IsPositive() {
+				for i := range lots[tx.SecurityID] {
+					lots[tx.SecurityID][i].quantity = lots[tx.SecurityID][i].quantity.Mul(tx.Quantity)
+				}
+			}
+		}
+	}
+	return lots
+}
+
+// heldQuantity сколько бумаг было в портфеле к началу дня date по сделкам
+func heldQuantity(transactions []models.InvestmentTransaction, securityID uuid.UUID, date time.Time) decimal.Decimal {
+	var before []models.InvestmentTransaction
+	for _, tx := range transactions {
+		if tx.Date.Before(date) {
+			before = append(before, tx)
+		}
+	}
+
+	total := decimal.Zero
+	for _, lot := range trackLots(before)[securityID] {
+		total = total.Add(lot.quantity)
+	}
+	return total
+}
+
+// holdingAging срок владения по открытым лотам на дату now; nil - лотов нет (позиция заведена без сделок)
+func holdingAging(lots []purchaseLot, security *models.Security, now time.Time) *models.HoldingAging {
+	var total, weightedDays decimal.Decimal
+	for _, lot := range lots {
+		total = total.Add(lot.quantity)
+		weightedDays = weightedDays.Add(lot.quantity.Mul(decimal.NewFromFloat(now.Sub(lot.date).Hours() / 24)))
+	}
+	if !total.IsPositive() {
+		return nil
+	}
+
+	avgDays := int(weightedDays.Div(total).IntPart())
+	aging := &models.HoldingAging{
+		AveragePurchaseDate: truncateDay(now.AddDate(0, 0, -avgDays)),
+		AverageHoldingDays:  avgDays,
+		LDVApplicable:       ldvApplicable(security),
+	}
+	if !aging.LDVApplicable {
+		return aging
+	}
+
+	for _, lot := range lots {
+		eligibleAt := lot.date.AddDate(ldvMinYears, 0, 0)
+		if !eligibleAt.After(now) {
+			aging.LongTermQuantity = aging.LongTermQuantity.Add(lot.quantity)
+			continue
+		}
+		// лоты идут по возрастанию даты: первый не ставший долгосрочным - ближайший
+		if aging.NextLongTermDate == nil {
+			date := truncateDay(eligibleAt)
+			aging.NextLongTermDate = &date
+		}
+		if truncateDay(eligibleAt).Equal(*aging.NextLongTermDate) {
+			aging.NextLongTermQuantity = aging.NextLongTermQuantity.Add(lot.quantity)
+		}
+	}
+	aging.LongTermShare = aging.LongTermQuantity.Div(total).Mul(decimal.NewFromInt(100)).Round(2)
+	return aging
+}
+
+// ldvApplicable ЛДВ распространяется на ценные бумаги, но не на крипту, валюту и срочные контракты
+func ldvApplicable(security *models.Security) bool {
+	if security == nil {
+		return true
+	}
+	switch security.Type {
+	case models.SecurityTypeCrypto, models.SecurityTypeCurrency, models.SecurityTypeDerivative:
+		return false
+	}
+	return true
+}
+
+// attachHoldingAging подставляет в позиции срок владения по сделкам портфеля
+func attachHoldingAging(ctx context.Context, investmentRepo repository.InvestmentTransactionRepository, portfolioID uuid.UUID, holdings []models.Holding) error {
+	if len(holdings) == 0 {
+		return nil
+	}
+	transactions, err := investmentRepo.GetByDateRange(ctx, portfolioID, time.Time{}, time.Now())
+	if err != nil {
+		return err
+	}
+
+	lots := trackLots(transactions)
+	now := time.Now()
+	for i := range holdings {
+		holdings[i].Aging = holdingAging(lots[holdings[i].SecurityID], holdings[i].Security, now)
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/mailimport"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/alligatorO15/fin-tracker/internal/secrets"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMailImportDisabled     = errors.New("mail import is disabled: ENCRYPTION_KEY is not configured")
+	ErrMailConnectionNotFound = errors.New("mail connection not found")
+	ErrMailLoginFailed        = errors.New("could not log in to the mailbox with these credentials")
+	ErrMailHostNotAllowed     = errors.New("mail server must be a public IMAP host on port 993 or 143")
+	ErrMailInvalidCredentials = errors.New("username, password and mailbox must not contain line breaks")
+	ErrDraftNotFound          = errors.New("draft not found")
+	ErrDraftNotPending        = errors.New("draft is already confirmed or rejected")
+	ErrDraftAccountRequired   = errors.New("account_id is required: the card from the email did not match any account")
+)
+
+// письма старше этого при первом опросе ящика не разбираем
+const mailImportLookback = 30 * 24 * time.Hour
+
+type MailImportService interface {
+	// CreateConnection проверяет вход в ящик и сохраняет его с зашифрованным паролем
+	CreateConnection(ctx context.Context, userID uuid.UUID, input *models.MailConnectionCreate) (*models.MailConnection, error)
+	GetConnections(ctx context.Context, userID uuid.UUID) ([]models.MailConnection, error)
+	UpdateConnection(ctx context.Context, userID, id uuid.UUID, update *models.MailConnectionUpdate) (*models.MailConnection, error)
+	DeleteConnection(ctx context.Context, userID, id uuid.UUID) error
+	// PollConnection опрашивает один ящик сразу, не дожидаясь фоновой задачи
+	PollConnection(ctx context.Context, userID, id uuid.UUID) (*models.MailPollResult, error)
+	// PollAll опрашивает все включенные ящики и создает черновики транзакций
+	PollAll(ctx context.Context) (*models.MailPollResult, error)
+
+	GetDrafts(ctx context.Context, userID uuid.UUID, status *models.TransactionDraftStatus) ([]models.TransactionDraft, error)
+	// ConfirmDraft создает по черновику транзакцию (категория обязательна, остальное можно уточнить)
+	ConfirmDraft(ctx context.Context, userID, id uuid.UUID, input *models.TransactionDraftConfirm) (*models.Transaction, error)
+	RejectDraft(ctx context.Context, userID, id uuid.UUID) (*models.TransactionDraft, error)
+}
+
+type mailImportService struct {
+	connRepo           repository.MailConnectionRepository
+	draftRepo          repository.TransactionDraftRepository
+	accountRepo        repository.AccountRepository
+	transactionService TransactionService
+	txManager          repository.TxManager
+	registry           *mailimport.Registry
+	box                *secrets.Box // nil - импорт выключен
+	notifications      NotificationService
+}
+
+func NewMailImportService(connRepo repository.MailConnectionRepository, draftRepo repository.TransactionDraftRepository, accountRepo repository.AccountRepository, transactionService TransactionService, txManager repository.TxManager, registry *mailimport.Registry, box *secrets.Box, notifications NotificationService) MailImportService {
+	return &mailImportService{
+		connRepo:           connRepo,
+		draftRepo:          draftRepo,
+		accountRepo:        accountRepo,
+		transactionService: transactionService,
+		txManager:          txManager,
+		registry:           registry,
+		box:                box,
+		notifications:      notifications,
+	}
+}
+
+func (s *mailImportService) CreateConnection(ctx context.Context, userID uuid.UUID, input *models.MailConnectionCreate) (*models.MailConnection, error) {
+	if s.box == nil {
+		return nil, ErrMailImportDisabled
+	}
+	if input.AccountID != nil {
+		if err := s.checkAccount(ctx, userID, *input.AccountID); err != nil {
+			return nil, err
+		}
+	}
+
+	conn := &models.MailConnection{
+		UserID:    userID,
+		Host:      strings.TrimSpace(input.Host),
+		Port:      input.Port,
+		Username:  strings.TrimSpace(input.Username),
+		Mailbox:   input.Mailbox,
+		UseTLS:    input.UseTLS == nil || *input.UseTLS,
+		AccountID: input.AccountID,
+		IsActive:  true,
+	}
+	if conn.Port == 0 {
+		conn.Port = 993
+	}
+	if conn.Mailbox == "" {
+		conn.Mailbox = "INBOX"
+	}
+
+	if err := checkMailbox(ctx, credentials(conn, input.Password)); err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.box.Seal(input.Password)
+	if err != nil {
+		return nil, err
+	}
+	conn.PasswordEnc = sealed
+
+	if err := s.connRepo.Create(ctx, conn); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (s *mailImportService) GetConnections(ctx context.Context, userID uuid.UUID) ([]models.MailConnection, error) {
+	return s.connRepo.GetByUserID(ctx, userID)
+}
+
+func (s *mailImportService) getConnection(ctx context.Context, userID, id uuid.UUID) (*models.MailConnection, error) {
+	conn, err := s.connRepo.GetByID(ctx, id)
+	if err != nil || conn.UserID != userID {
+		return nil, ErrMailConnectionNotFound
+	}
+	return conn, nil
+}
+
+func (s *mailImportService) UpdateConnection(ctx context.Context, userID, id uuid.UUID, update *models.MailConnectionUpdate) (*models.MailConnection, error) {
+	if s.box == nil {
+		return nil, ErrMailImportDisabled
+	}
+	conn, err := s.getConnection(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if update.AccountID != nil {
+		if err := s.checkAccount(ctx, userID, *update.AccountID); err != nil {
+			return nil, err
+		}
+	}
+
+	if update.Mailbox != nil && strings.ContainsAny(*update.Mailbox, "\r\n\x00") {
+		return nil, ErrMailInvalidCredentials
+	}
+
+	var passwordEnc *string
+	if update.Password != nil {
+		if update.Mailbox != nil {
+			conn.Mailbox = *update.Mailbox
+		}
+		if err := checkMailbox(ctx, credentials(conn, *update.Password)); err != nil {
+			return nil, err
+		}
+		sealed, err := s.box.Seal(*update.Password)
+		if err != nil {
+			return nil, err
+		}
+		passwordEnc = &sealed
+	}
+
+	if err := s.connRepo.Update(ctx, id, update, passwordEnc); err != nil {
+		return nil, err
+	}
+	return s.connRepo.GetByID(ctx, id)
+}
+
+func (s *mailImportService) DeleteConnection(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.getConnection(ctx, userID, id); err != nil {
+		return err
+	}
+	// черновики остаются, у них обнуляется ссылка на ящик
+	return s.connRepo.Delete(ctx, id)
+}
+
+func (s *mailImportService) PollConnection(ctx context.Context, userID, id uuid.UUID) (*models.MailPollResult, error) {
+	if s.box == nil {
+		return nil, ErrMailImportDisabled
+	}
+	conn, err := s.getConnection(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.MailPollResult{Connections: 1}
+	if err := s.poll(ctx, conn, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *mailImportService) PollAll(ctx context.Context) (*models.MailPollResult, error) {
+	if s.box == nil {
+		return &models.MailPollResult{}, nil
+	}
+
+	conns, err := s.connRepo.GetActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.MailPollResult{Connections: len(conns)}
+	for i := range conns {
+		if err := s.poll(ctx, &conns[i], result); err != nil {
+			result.Failed++
+			log.Printf("Не удалось опросить почтовый ящик %s: %v", conns[i].ID, err)
+		}
+	}
+	return result, nil
+}
+
+// poll забирает новые письма ящика и создает черновики; ошибка сохраняется в ящике,
+// чтобы пользователь видел, почему импорт не идет
+func (s *mailImportService) poll(ctx context.Context, conn *models.MailConnection, result *models.MailPollResult) error {
+	password, err := s.box.Open(conn.PasswordEnc)
+	if err != nil {
+		s.savePollError(ctx, conn, err)
+		return err
+	}
+
+	fetched, err := mailimport.FetchNew(ctx, credentials(conn, password), conn.UIDValidity, conn.LastUID, conn.CreatedAt.Add(-mailImportLookback))
+	if err != nil {
+		s.savePollError(ctx, conn, err)
+		return err
+	}
+
+	accounts, err := s.accountRepo.GetByUserID(ctx, conn.UserID)
+	if err != nil {
+		return err
+	}
+
+	drafts := 0
+	for _, raw := range fetched.Messages {
+		result.Fetched++
+
+		msg, err := mailimport.ParseMessage(raw.Raw)
+		if err != nil {
+			result.Skipped++
+			continue
+		}
+		op, parser, err := s.registry.Parse(msg)
+		if err != nil {
+			if parser != "" {
+				log.Printf("Письмо %q (%s) не разобрано парсером %s: %v", msg.Subject, msg.From, parser, err)
+			}
+			result.Skipped++
+			continue
+		}
+
+		draft := &models.TransactionDraft{
+			UserID:       conn.UserID,
+			ConnectionID: &conn.ID,
+			ExternalID:   msg.ID,
+			Parser:       parser,
+			AccountID:    matchDraftAccount(accounts, op.CardLast4, conn.AccountID),
+			Type:         op.Type,
+			Amount:       op.Amount,
+			Currency:     op.Currency,
+			Description:  op.Description,
+			Date:         op.Date,
+			CardLast4:    op.CardLast4,
+			Subject:      msg.Subject,
+		}
+		created, err := s.draftRepo.Create(ctx, draft)
+		if err != nil {
+			return err
+		}
+		if created {
+			result.Drafts++
+			drafts++
+		} else {
+			result.Skipped++
+		}
+	}
+
+	if err := s.connRepo.SetPollState(ctx, conn.ID, fetched.UIDValidity, fetched.LastUID, ""); err != nil {
+		return err
+	}
+	if drafts > 0 {
+		s.notifications.Notify(ctx, &models.Notification{
+			UserID:   conn.UserID,
+			Type:     models.NotificationImportResult,
+			Title:    fmt.Sprintf("Из почты %s загружено операций: %d", conn.Username, drafts),
+			Body:     "Проверьте черновики и подтвердите их",
+			EntityID: &conn.ID,
+		})
+	}
+	return nil
+}
+
+func (s *mailImportService) savePollError(ctx context.Context, conn *models.MailConnection, pollErr error) {
+	if err := s.connRepo.SetPollState(ctx, conn.ID, conn.UIDValidity, conn.LastUID, pollErr.Error()); err != nil {
+		log.Printf("Не удалось сохранить ошибку опроса ящика %s: %v", conn.ID, err)
+	}
+	// о той же ошибке при каждом опросе не напоминаем
+	if conn.LastError != pollErr.Error() {
+		s.notifications.Notify(ctx, &models.Notification{
+			UserID:   conn.UserID,
+			Type:     models.NotificationImportResult,
+			Title:    "Не удалось проверить почту " + conn.Username,
+			Body:     pollErr.Error(),
+			EntityID: &conn.ID,
+		})
+	}
+}
+
+func (s *mailImportService) GetDrafts(ctx context.Context, userID uuid.UUID, status *models.TransactionDraftStatus) ([]models.TransactionDraft, error) {
+	return s.draftRepo.GetByUserID(ctx, userID, status)
+}
+
+func (s *mailImportService) getPendingDraft(ctx context.Context, userID, id uuid.UUID) (*models.TransactionDraft, error) {
+	draft, err := s.draftRepo.GetByID(ctx, id)
+	if err != nil || draft.UserID != userID {
+		return nil, ErrDraftNotFound
+	}
+	if draft.Status != models.DraftStatusPending {
+		return nil, ErrDraftNotPending
+	}
+	return draft, nil
+}
+
+func (s *mailImportService) ConfirmDraft(ctx context.Context, userID, id uuid.UUID, input *models.TransactionDraftConfirm) (*models.Transaction, error) {
+	draft, err := s.getPendingDraft(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	accountID := draft.AccountID
+	if input.AccountID != nil {
+		accountID = input.AccountID
+	}
+	if accountID == nil {
+		return nil, ErrDraftAccountRequired
+	}
+	if err := s.checkAccount(ctx, userID, *accountID); err != nil {
+		return nil, err
+	}
+
+	create := &models.TransactionCreate{
+		AccountID:   *accountID,
+		CategoryID:  input.CategoryID,
+		Type:        draft.Type,
+		Amount:      draft.Amount,
+		Description: draft.Description,
+		Date:        draft.Date,
+		Notes:       "Из письма: " + draft.Subject,
+	}
+	if input.Amount != nil {
+		create.Amount = *input.Amount
+	}
+	if input.Description != nil {
+		create.Description = *input.Description
+	}
+	if input.Date != nil {
+		create.Date = *input.Date
+	}
+
+	var tx *models.Transaction
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		var err error
+		tx, err = s.transactionService.Create(txCtx, userID, create)
+		if err != nil {
+			return err
+		}
+		return s.draftRepo.MarkConfirmed(txCtx, draft.ID, tx.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (s *mailImportService) RejectDraft(ctx context.Context, userID, id uuid.UUID) (*models.TransactionDraft, error) {
+	draft, err := s.getPendingDraft(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.draftRepo.SetStatus(ctx, id, models.DraftStatusRejected); err != nil {
+		return nil, err
+	}
+	draft.Status = models.DraftStatusRejected
+	return draft, nil
+}
+
+func (s *mailImportService) checkAccount(ctx context.Context, userID, accountID uuid.UUID) error {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil || account.UserID != userID {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+// checkMailbox пробует войти в ящик; причины отказа, которые пользователь может исправить сам, - отдельными ошибками
+func checkMailbox(ctx context.Context, creds mailimport.Credentials) error {
+	err := mailimport.Check(ctx, creds)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mailimport.ErrIMAPAddressNotAllowed):
+		return ErrMailHostNotAllowed
+	case errors.Is(err, mailimport.ErrIMAPUnsafeString):
+		return ErrMailInvalidCredentials
+	}
+	log.Printf("Проверка почтового ящика %s@%s не прошла: %v", creds.Username, creds.Host, err)
+	return ErrMailLoginFailed
+}
+
+func credentials(conn *models.MailConnection, password string) mailimport.Credentials {
+	return mailimport.Credentials{
+		Host:     conn.Host,
+		Port:     conn.Port,
+		Username: conn.Username,
+		Password: password,
+		Mailbox:  conn.Mailbox,
+		UseTLS:   conn.UseTLS,
+	}
+}
+
+// matchDraftAccount счет по последним цифрам карты из письма, иначе счет ящика по умолчанию
+func matchDraftAccount(accounts []models.Account, cardLast4 string, fallback *uuid.UUID) *uuid.UUID {
+	if cardLast4 != "" {
+		for i := range accounts {
+			number := strings.ReplaceAll(accounts[i].AccountNumber, " ", "")
+			if accounts[i].IsActive && strings.HasSuffix(number, cardLast4) {
+				return &accounts[i].ID
+			}
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/alligatorO15/fin-tracker/internal/telegram"
+	"github.com/google/uuid"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+
+	telegramPushTimeout = 15 * time.Second
+)
+
+type NotificationService interface {
+	List(ctx context.Context, userID uuid.UUID, filter *models.NotificationFilter) (*models.NotificationList, error)
+	UnreadCount(ctx context.Context, userID uuid.UUID) (*models.NotificationList, error)
+	// MarkRead отмечает уведомления прочитанными и возвращает, сколько отмечено
+	MarkRead(ctx context.Context, userID uuid.UUID, input *models.NotificationMarkRead) (int64, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+
+	// Notify кладет уведомление во входящие и дублирует в Telegram, если чат привязан; повтор с тем же DedupKey молча пропускается.
+	// ошибка только логируется: уведомление не должно ломать операцию, которая его вызвала
+	Notify(ctx context.Context, n *models.Notification)
+	// CheckBudgets уведомляет о бюджетах, дошедших до нового порога в текущем периоде (каждый порог - один раз)
+	CheckBudgets(ctx context.Context, userID uuid.UUID)
+}
+
+type notificationService struct {
+	txManager        repository.TxManager
+	notificationRepo repository.NotificationRepository
+	budgetService    BudgetService
+	telegramRepo     repository.TelegramRepository
+	bot              telegram.Bot // nil - бот не настроен
+}
+
+func NewNotificationService(txManager repository.TxManager, notificationRepo repository.NotificationRepository, budgetService BudgetService, telegramRepo repository.TelegramRepository, bot telegram.Bot) NotificationService {
+	return &notificationService{
+		txManager:        txManager,
+		notificationRepo: notificationRepo,
+		budgetService:    budgetService,
+		telegramRepo:     telegramRepo,
+		bot:              bot,
+	}
+}
+
+func (s *notificationService) List(ctx context.Context, userID uuid.UUID, filter *models.NotificationFilter) (*models.NotificationList, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultNotificationLimit
+	}
+	if filter.Limit > maxNotificationLimit {
+		filter.Limit = maxNotificationLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	list, err := s.UnreadCount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	list.Notifications, err = s.notificationRepo.GetByUserID(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	if list.Notifications == nil {
+		list.Notifications = []models.Notification{}
+	}
+	return list, nil
+}
+
+func (s *notificationService) UnreadCount(ctx context.Context, userID uuid.UUID) (*models.NotificationList, error) {
+	counts, err := s.notificationRepo.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &models.NotificationList{UnreadByType: counts}
+	for _, count := range counts {
+		list.Unread += count
+	}
+	return list, nil
+}
+
+func (s *notificationService) MarkRead(ctx context.Context, userID uuid.UUID, input *models.NotificationMarkRead) (int64, error) {
+	// пустой список без all ничего не отмечает, иначе он значил бы "все"
+	if len(input.IDs) == 0 && !input.All {
+		return 0, nil
+	}
+	ids := input.IDs
+	if input.All {
+		ids = nil
+	}
+	return s.notificationRepo.MarkRead(ctx, userID, ids)
+}
+
+func (s *notificationService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	deleted, err := s.notificationRepo.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+func (s *notificationService) Notify(ctx context.Context, n *models.Notification) {
+	created, err := s.notificationRepo.Create(ctx, n)
+	if err != nil {
+		log.Printf("Не удалось сохранить уведомление %s для %s: %v", n.Type, n.UserID, err)
+		return
+	}
+	if created && s.bot != nil {
+		s.pushTelegram(n)
+	}
+}
+
+// pushTelegram отправляет уведомление в привязанный чат в фоне: запрос не ждет Telegram
+func (s *notificationService) pushTelegram(n *models.Notification) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), telegramPushTimeout)
+		defer cancel()
+
+		link, err := s.telegramRepo.GetByUserID(ctx, n.UserID)
+		if err != nil || !link.Linked {
+			return
+		}
+		text := n.Title
+		if n.Body != "" {
+			text += "\n" + n.Body
+		}
+		if err := s.bot.SendMessage(ctx, *link.ChatID, text); err != nil {
+			log.Printf("Не удалось отправить уведомление %s в Telegram: %v", n.Type, err)
+		}
+	}()
+}
+
+func (s *notificationService) CheckBudgets(ctx context.Context, userID uuid.UUID) {
+	// отметка порога и уведомление пишутся вместе: если уведомление не сохранилось, порог сработает в следующий раз
+	var created []*models.Notification
+	err := s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		created = nil
+		alerts, err := s.budgetService.EvaluateAlerts(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		for _, alert := range alerts {
+			title := fmt.Sprintf("Бюджет «%s»: потрачено %.0f%%", alert.BudgetName, alert.Percent)
+			if alert.AlertType == "exceeded" {
+				title = fmt.Sprintf("Бюджет «%s» превышен: потрачено %.0f%%", alert.BudgetName, alert.Percent)
+			}
+			budgetID := alert.BudgetID
+			n := &models.Notification{
+				UserID:   userID,
+				Type:     models.NotificationBudgetAlert,
+				Title:    title,
+				Body:     fmt.Sprintf("Потрачено %s из %s (%s)", alert.Spent.StringFixed(2), alert.Amount.StringFixed(2), alert.Scope),
+				EntityID: &budgetID,
+				// каждый порог срабатывает один раз за период; ключ страхует от повтора при параллельной проверке
+				DedupKey: fmt.Sprintf("budget:%s:%s:%d", alert.BudgetID, alert.PeriodStart.Format("2006-01-02"), alert.Threshold),
+			}
+			ok, err := s.notificationRepo.Create(txCtx, n)
+			if err != nil {
+				return err
+			}
+			if ok {
+				created = append(created, n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Не удалось проверить бюджеты пользователя %s: %v", userID, err)
+		return
+	}
+
+	// в Telegram - только после записи, чтобы не отправить уведомление, которое откатилось
+	if s.bot != nil {
+		for _, n := range created {
+			s.pushTelegram(n)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrPayeeNotFound  = errors.New("payee not found")
+	ErrPayeeNameTaken = errors.New("payee with this name already exists, merge them instead")
+	ErrInvalidPayee   = errors.New("payee name is empty")
+)
+
+// минимальная похожесть названий (0..1), при которой описание считаем тем же получателем
+const payeeMatchThreshold = 0.85
+
+// служебные слова из банковских выписок, которые не относятся к названию продавца
+var payeeStopWords = map[string]bool{
+	"оплата": true, "покупка": true, "перевод": true, "платеж": true, "платёж": true,
+	"pos": true, "retail": true, "payment": true, "purchase": true, "card": true,
+	"ooo": true, "ооо": true, "ип": true, "ао": true, "пао": true,
+}
+
+type PayeeService interface {
+	// Resolve находит получателя по описанию транзакции (с нечетким сравнением) или создает нового.
+	// для пустого описания возвращает nil
+	Resolve(ctx context.Context, userID uuid.UUID, description string) (*uuid.UUID, error)
+	GetByID(ctx context.Context, userID, id uuid.UUID) (*models.Payee, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Payee, error)
+	GetStats(ctx context.Context, userID, id uuid.UUID) (*models.PayeeStats, error)
+	Rename(ctx context.Context, userID, id uuid.UUID, input *models.PayeeRename) (*models.Payee, error)
+	Merge(ctx context.Context, userID, targetID uuid.UUID, input *models.PayeeMerge) (*models.Payee, error)
+}
+
+type payeeService struct {
+	payeeRepo repository.PayeeRepository
+	txManager repository.TxManager
+}
+
+func NewPayeeService(payeeRepo repository.PayeeRepository, txManager repository.TxManager) PayeeService {
+	return &payeeService{
+		payeeRepo: payeeRepo,
+		txManager: txManager,
+	}
+}
+
+func (s *payeeService) Resolve(ctx context.Context, userID uuid.UUID, description string) (*uuid.UUID, error) {
+	normalized := normalizePayeeName(description)
+	if normalized == "" {
+		return nil, nil
+	}
+
+	payee, err := s.payeeRepo.FindByName(ctx, userID, normalized)
+	if err == nil {
+		return &payee.ID, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	// точного совпадения нет - ищем похожего ("PYATEROCHKA 1234 MOSKVA" и "Pyaterochka Moskva").
+	// сравниваем только с теми, кто может пройти порог
+	minLen, maxLen := payeeCandidateLengths(normalized)
+	payees, err := s.payeeRepo.GetMatchCandidates(ctx, userID, normalized, minLen, maxLen)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *models.Payee
+	bestScore := 0.0
+	for i := range payees {
+		if score := payeeSimilarity(normalized, payees[i].NormalizedName); score >= payeeMatchThreshold && score > bestScore {
+			best = &payees[i]
+			bestScore = score
+		}
+	}
+	if best != nil {
+		return &best.ID, nil
+	}
+
+	payee = &models.Payee{
+		UserID:         userID,
+		Name:           payeeDisplayName(description),
+		NormalizedName: normalized,
+	}
+	if err := s.payeeRepo.Create(ctx, payee); err != nil {
+		return nil, err
+	}
+	return &payee.ID, nil
+}
+
+func (s *payeeService) GetByID(ctx context.Context, userID, id uuid.UUID) (*models.Payee, error) {
+	payee, err := s.payeeRepo.GetByID(ctx, id)
+	if err != nil || payee.UserID != userID {
+		return nil, ErrPayeeNotFound
+	}
+	return payee, nil
+}
+
+func (s *payeeService) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Payee, error) {
+	return s.payeeRepo.GetByUserID(ctx, userID)
+}
+
+func (s *payeeService) GetStats(ctx context.Context, userID, id uuid.UUID) (*models.PayeeStats, error) {
+	payee, err := s.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.payeeRepo.GetStats(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	stats.Name = payee.Name
+	if stats.TransactionCount > 0 {
+		stats.AverageCheck = stats.TotalSpent.Div(decimal.NewFromInt(stats.TransactionCount)).Round(2)
+	}
+	return stats, nil
+}
+
+func (s *payeeService) Rename(ctx context.Context, userID, id uuid.UUID, input *models.PayeeRename) (*models.Payee, error) {
+	payee, err := s.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	name := payeeDisplayName(input.Name)
+	normalized := normalizePayeeName(name)
+	if normalized == "" {
+		return nil, ErrInvalidPayee
+	}
+	if existing, err := s.payeeRepo.GetByNormalizedName(ctx, userID, normalized); err == nil && existing.ID != id {
+		return nil, ErrPayeeNameTaken
+	}
+
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		return s.payeeRepo.Rename(txCtx, id, name, normalized)
+	})
+	if err != nil {
+		return nil, err
+	}
+	payee.Name = name
+	payee.NormalizedName = normalized
+	return payee, nil
+}
+
+func (s *payeeService) Merge(ctx context.Context, userID, targetID uuid.UUID, input *models.PayeeMerge) (*models.Payee, error) {
+	target, err := s.GetByID(ctx, userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceIDs []uuid.UUID
+	for _, id := range input.SourceIDs {
+		if id == targetID {
+			continue
+		}
+		if _, err := s.GetByID(ctx, userID, id); err != nil {
+			return nil, err
+		}
+		sourceIDs = append(sourceIDs, id)
+	}
+	if len(sourceIDs) == 0 {
+		return target, nil
+	}
+
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		return s.payeeRepo.Merge(txCtx, targetID, sourceIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// normalizePayeeName приводит описание к ключу получателя: нижний регистр,
+// без пунктуации, номеров карт/чеков и служебных слов выписки
+func normalizePayeeName(description string) string {
+	words := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var kept []string
+	for _, w := range words {
+		if payeeStopWords[w] || strings.IndexFunc(w, unicode.IsLetter) < 0 {
+			continue
+		}
+		kept = append(kept, w)
+	}
+
+	return truncateRunes(strings.Join(kept, " "), 255)
+}
+
+// payeeDisplayName название получателя для показа пользователю
+func payeeDisplayName(description string) string {
+	return truncateRunes(strings.Join(strings.Fields(description), " "), 255)
+}
+
+// payeeCandidateLengths границы длины названий, похожесть которых по Левенштейну может достичь порога:
+// расстояние не меньше разницы длин и не больше (1 - порог) от длины большего названия
+func payeeCandidateLengths(normalized string) (int, int) {
+	n := float64(len([]rune(normalized)))
+	return int(math.Ceil(n*payeeMatchThreshold - 1e-9)), int(math.Floor(n/payeeMatchThreshold + 1e-9))
+}
+
+// payeeSimilarity похожесть двух нормализованных названий от 0 до 1
+func payeeSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	// "yandex go" и "yandex go moskva": одно название - начало другого
+	short, long := a, b
+	if len([]rune(short)) > len([]rune(long)) {
+		short, long = long, short
+	}
+	if len([]rune(short)) >= 5 && strings.HasPrefix(long, short+" ") {
+		return payeeMatchThreshold
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	maxLen := len(ra)
+	if len(rb) > maxLen {
+		maxLen = len(rb)
+	}
+	if maxLen == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(maxLen)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
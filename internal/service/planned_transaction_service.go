@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPlannedNotFound   = errors.New("planned transaction not found")
+	ErrPlannedNotPending = errors.New("planned transaction is already posted or cancelled")
+	ErrAccountNotFound   = errors.New("account not found")
+
+	ErrInvalidPlannedAmount = errors.New("planned transaction amount must be positive")
+)
+
+// сколько платежей проводим за один проход фоновой задачи
+const plannedPostBatch = 200
+
+type PlannedTransactionService interface {
+	Create(ctx context.Context, userID uuid.UUID, input *models.PlannedTransactionCreate) (*models.PlannedTransaction, error)
+	GetByID(ctx context.Context, userID, id uuid.UUID) (*models.PlannedTransaction, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, status *models.PlannedTransactionStatus) ([]models.PlannedTransaction, error)
+	Update(ctx context.Context, userID, id uuid.UUID, update *models.PlannedTransactionUpdate) (*models.PlannedTransaction, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	// Confirm проводит платеж как обычную транзакцию (сумму и дату можно уточнить)
+	Confirm(ctx context.Context, userID, id uuid.UUID, input *models.PlannedTransactionConfirm) (*models.Transaction, error)
+	Cancel(ctx context.Context, userID, id uuid.UUID) (*models.PlannedTransaction, error)
+	// PostDue проводит наступившие платежи с auto_post, об остальных напоминает
+	PostDue(ctx context.Context) (*models.PlannedPostResult, error)
+}
+
+type plannedTransactionService struct {
+	txManager          repository.TxManager
+	plannedRepo        repository.PlannedTransactionRepository
+	accountRepo        repository.AccountRepository
+	transactionService TransactionService
+	notifications      NotificationService
+}
+
+func NewPlannedTransactionService(txManager repository.TxManager, plannedRepo repository.PlannedTransactionRepository, accountRepo repository.AccountRepository, transactionService TransactionService, notifications NotificationService) PlannedTransactionService {
+	return &plannedTransactionService{
+		txManager:          txManager,
+		plannedRepo:        plannedRepo,
+		accountRepo:        accountRepo,
+		transactionService: transactionService,
+		notifications:      notifications,
+	}
+}
+
+func (s *plannedTransactionService) Create(ctx context.Context, userID uuid.UUID, input *models.PlannedTransactionCreate) (*models.PlannedTransaction, error) {
+	switch input.Type {
+	case models.TransactionTypeIncome, models.TransactionTypeExpense:
+	case models.TransactionTypeTransfer:
+		if input.ToAccountID == nil {
+			return nil, ErrTransferMissingAccount
+		}
+	default:
+		return nil, ErrInvalidTransactionType
+	}
+	if !input.Amount.IsPositive() {
+		return nil, ErrInvalidPlannedAmount
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, input.AccountID)
+	if err != nil || account.UserID != userID {
+		return nil, ErrAccountNotFound
+	}
+	if err := s.checkAccount(ctx, userID, input.ToAccountID); err != nil {
+		return nil, err
+	}
+
+	planned := &models.PlannedTransaction{
+		UserID:      userID,
+		AccountID:   input.AccountID,
+		CategoryID:  input.CategoryID,
+		Type:        input.Type,
+		Amount:      input.Amount,
+		Currency:    account.Currency,
+		Description: input.Description,
+		DueDate:     input.DueDate,
+		ToAccountID: input.ToAccountID,
+		AutoPost:    input.AutoPost,
+		Notes:       input.Notes,
+	}
+
+	if err := s.plannedRepo.Create(ctx, planned); err != nil {
+		return nil, err
+	}
+	enrichPlanned(planned)
+	return planned, nil
+}
+
+func (s *plannedTransactionService) GetByID(ctx context.Context, userID, id uuid.UUID) (*models.PlannedTransaction, error) {
+	planned, err := s.plannedRepo.GetByID(ctx, id)
+	if err != nil || planned.UserID != userID {
+		return nil, ErrPlannedNotFound
+	}
+	enrichPlanned(planned)
+	return planned, nil
+}
+
+func (s *plannedTransactionService) GetByUserID(ctx context.Context, userID uuid.UUID, status *models.PlannedTransactionStatus) ([]models.PlannedTransaction, error) {
+	planned, err := s.plannedRepo.GetByUserID(ctx, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	for i := range planned {
+		enrichPlanned(&planned[i])
+	}
+	return planned, nil
+}
+
+func (s *plannedTransactionService) Update(ctx context.Context, userID, id uuid.UUID, update *models.PlannedTransactionUpdate) (*models.PlannedTransaction, error) {
+	planned, err := s.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if planned.Status != models.PlannedStatusPlanned {
+		return nil, ErrPlannedNotPending
+	}
+
+	if update.Amount != nil && !update.Amount.IsPositive() {
+		return nil, ErrInvalidPlannedAmount
+	}
+	if err := s.checkAccount(ctx, userID, update.AccountID); err != nil {
+		return nil, err
+	}
+	if err := s.checkAccount(ctx, userID, update.ToAccountID); err != nil {
+		return nil, err
+	}
+
+	if err := s.plannedRepo.Update(ctx, id, update); err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, userID, id)
+}
+
+func (s *plannedTransactionService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.GetByID(ctx, userID, id); err != nil {
+		return err
+	}
+	// проведенная транзакция остается, удаляется только план
+	return s.plannedRepo.Delete(ctx, id)
+}
+
+func (s *plannedTransactionService) Confirm(ctx context.Context, userID, id uuid.UUID, input *models.PlannedTransactionConfirm) (*models.Transaction, error) {
+	planned, err := s.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if planned.Status != models.PlannedStatusPlanned {
+		return nil, ErrPlannedNotPending
+	}
+
+	return s.post(ctx, planned, input)
+}
+
+func (s *plannedTransactionService) Cancel(ctx context.Context, userID, id uuid.UUID) (*models.PlannedTransaction, error) {
+	planned, err := s.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if planned.Status != models.PlannedStatusPlanned {
+		return nil, ErrPlannedNotPending
+	}
+
+	cancelled, err := s.plannedRepo.SetStatus(ctx, id, models.PlannedStatusCancelled)
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		return nil, ErrPlannedNotPending
+	}
+	planned.Status = models.PlannedStatusCancelled
+	planned.IsOverdue = false
+	return planned, nil
+}
+
+func (s *plannedTransactionService) PostDue(ctx context.Context) (*models.PlannedPostResult, error) {
+	now := time.Now()
+	due, err := s.plannedRepo.GetDue(ctx, now, true, plannedPostBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PlannedPostResult{}
+	for i := range due {
+		planned := &due[i]
+		plannedID := planned.ID
+
+		tx, err := s.post(ctx, planned, nil)
+		if err != nil {
+			if err == ErrPlannedNotPending {
+				// пользователь успел подтвердить или отменить платеж сам
+				continue
+			}
+			result.Failed++
+			log.Printf("Не удалось провести запланированный платеж %s: %v", planned.ID, err)
+			// платеж остается запланированным и пробуется снова; уведомление об ошибке - одно на платеж
+			s.notifications.Notify(ctx, &models.Notification{
+				UserID:   planned.UserID,
+				Type:     models.NotificationPlanned,
+				Title:    "Не удалось провести платеж «" + plannedTitle(planned) + "»",
+				Body:     err.Error(),
+				EntityID: &plannedID,
+				DedupKey: "planned:" + planned.ID.String() + ":failed",
+			})
+			continue
+		}
+		result.Posted++
+		log.Printf("Проведен запланированный платеж %s -> транзакция %s", planned.ID, tx.ID)
+		s.notifications.Notify(ctx, &models.Notification{
+			UserID:   planned.UserID,
+			Type:     models.NotificationPlanned,
+			Title:    "Проведен платеж «" + plannedTitle(planned) + "»",
+			Body:     tx.Amount.StringFixed(2) + " " + planned.Currency + ", " + tx.Date.Format("02.01.2006"),
+			EntityID: &plannedID,
+			DedupKey: "planned:" + planned.ID.String() + ":posted",
+		})
+	}
+
+	// без автопроведения только напоминаем: пользователь подтверждает сам
+	manual, err := s.plannedRepo.GetDue(ctx, now, false, plannedPostBatch)
+	if err != nil {
+		return nil, err
+	}
+	for _, planned := range manual {
+		result.Overdue++
+		log.Printf("Запланированный платеж %s (%s, %s %s) ожидает подтверждения с %s",
+			planned.ID, planned.Description, planned.Amount.String(), planned.Currency, planned.DueDate.Format("2006-01-02"))
+	}
+
+	return result, nil
+}
+
+// plannedTitle название платежа для уведомлений
+func plannedTitle(planned *models.PlannedTransaction) string {
+	if planned.Description != "" {
+		return planned.Description
+	}
+	return planned.Amount.StringFixed(2) + " " + planned.Currency
+}
+
+// checkAccount счет (если задан) принадлежит пользователю
+func (s *plannedTransactionService) checkAccount(ctx context.Context, userID uuid.UUID, accountID *uuid.UUID) error {
+	if accountID == nil {
+		return nil
+	}
+	account, err := s.accountRepo.GetByID(ctx, *accountID)
+	if err != nil || account.UserID != userID {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+// post помечает план проведенным и создает по нему транзакцию в одной транзакции бд. план занимается
+// первым условным UPDATE: при гонке ручного подтверждения с автопроведением (или двух подтверждений)
+// транзакцию создаст только один, второй получит ErrPlannedNotPending
+func (s *plannedTransactionService) post(ctx context.Context, planned *models.PlannedTransaction, input *models.PlannedTransactionConfirm) (*models.Transaction, error) {
+	create := &models.TransactionCreate{
+		AccountID:   planned.AccountID,
+		CategoryID:  planned.CategoryID,
+		Type:        planned.Type,
+		Amount:      planned.Amount,
+		Description: planned.Description,
+		Date:        planned.DueDate,
+		ToAccountID: planned.ToAccountID,
+		Notes:       planned.Notes,
+	}
+	if input != nil && input.Amount != nil {
+		create.Amount = *input.Amount
+	}
+	if input != nil && input.Date != nil {
+		create.Date = *input.Date
+	}
+
+	var tx *models.Transaction
+	err := s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		claimed, err := s.plannedRepo.SetStatus(txCtx, planned.ID, models.PlannedStatusPosted)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return ErrPlannedNotPending
+		}
+		tx, err = s.transactionService.Create(txCtx, planned.UserID, create)
+		if err != nil {
+			return err
+		}
+		return s.plannedRepo.SetTransaction(txCtx, planned.ID, tx.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// enrichPlanned заполняет вычисляемые поля
+func enrichPlanned(planned *models.PlannedTransaction) {
+	today := time.Now().Truncate(24 * time.Hour)
+	planned.IsOverdue = planned.Status == models.PlannedStatusPlanned && planned.DueDate.Before(today)
+}
@@ -2,6 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/market"
 	"github.com/alligatorO15/fin-tracker/internal/models"
@@ -10,14 +16,38 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+var (
+	ErrTickerNotInPortfolio = errors.New("ticker is not held in the portfolio")
+	ErrRefreshJobNotFound   = errors.New("refresh job not found")
+)
+
+const (
+	asyncRefreshHoldings = 30              // с какого числа бумаг обновление по умолчанию уходит в фон
+	refreshJobTimeout    = 5 * time.Minute // сколько ждем провайдеров в фоновом обновлении
+	refreshJobTTL        = 1 * time.Hour   // сколько хранится результат фонового обновления
+)
+
 type PortfolioService interface {
 	Create(ctx context.Context, userID uuid.UUID, input *models.PortfolioCreate) (*models.Portfolio, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Portfolio, error)
 	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Portfolio, error)
 	GetWithHoldings(ctx context.Context, id uuid.UUID) (*models.Portfolio, error)
+	// GetSummary сводка по всем портфелям пользователя: два запроса к БД, курсы общие для портфелей в одной валюте
+	GetSummary(ctx context.Context, userID uuid.UUID) ([]models.PortfolioSummary, error)
+	// GetHoldings позиции портфеля, отфильтрованные по заметкам пользователя; доли считаются от всего портфеля
+	GetHoldings(ctx context.Context, id uuid.UUID, filter *models.HoldingFilter) (*models.HoldingList, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.PortfolioUpdate) (*models.Portfolio, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	// RefreshPrices обновляет цены бумаг портфеля и проверяет отклонение от целевых долей и просадку
 	RefreshPrices(ctx context.Context, portfolioID uuid.UUID) error
+	// Refresh обновление цен по запросу пользователя с итогом по каждой бумаге. большой портфель
+	// (или async: true) обновляется в фоне: возвращается задача в статусе pending, итог - в GetRefreshJob
+	Refresh(ctx context.Context, userID, portfolioID uuid.UUID, input *models.PortfolioRefreshRequest) (*models.PortfolioRefreshJob, error)
+	GetRefreshJob(ctx context.Context, userID, jobID uuid.UUID) (*models.PortfolioRefreshJob, error)
+	// RefreshWatched обновляет цены портфелей с включенными уведомлениями о ребалансировке или просадке (фоновая задача)
+	RefreshWatched(ctx context.Context) (int, error)
+	// RefreshAll обновляет цены всех активных портфелей с позициями
+	RefreshAll(ctx context.Context) (int, error)
 }
 
 type portfolioService struct {
@@ -25,6 +55,22 @@ type portfolioService struct {
 	holdingRepo    repository.HoldingRepository
 	securityRepo   repository.SecurityRepository
 	marketProvider *market.MultiProvider
+	rebalance      RebalanceService
+	drawdown       DrawdownService
+	quota          QuotaService
+	metadataRepo   repository.HoldingMetadataRepository
+	notifications  NotificationService
+	investmentRepo repository.InvestmentTransactionRepository
+
+	// фоновые обновления цен живут в памяти процесса: после рестарта статус задачи теряется
+	jobsMu sync.Mutex
+	jobs   map[uuid.UUID]*refreshJob
+}
+
+// refreshJob задача фонового обновления и владелец портфеля, которому можно ее показывать
+type refreshJob struct {
+	job    models.PortfolioRefreshJob
+	userID uuid.UUID
 }
 
 func NewPortfolioService(
@@ -32,16 +78,36 @@ func NewPortfolioService(
 	holdingRepo repository.HoldingRepository,
 	securityRepo repository.SecurityRepository,
 	marketProvider *market.MultiProvider,
+	rebalance RebalanceService,
+	drawdown DrawdownService,
+	quota QuotaService,
+	metadataRepo repository.HoldingMetadataRepository,
+	notifications NotificationService,
+	investmentRepo repository.InvestmentTransactionRepository,
 ) PortfolioService {
 	return &portfolioService{
 		portfolioRepo:  portfolioRepo,
 		holdingRepo:    holdingRepo,
 		securityRepo:   securityRepo,
 		marketProvider: marketProvider,
+		rebalance:      rebalance,
+		drawdown:       drawdown,
+		quota:          quota,
+		metadataRepo:   metadataRepo,
+		notifications:  notifications,
+		investmentRepo: investmentRepo,
+		jobs:           make(map[uuid.UUID]*refreshJob),
 	}
 }
 
 func (s *portfolioService) Create(ctx context.Context, userID uuid.UUID, input *models.PortfolioCreate) (*models.Portfolio, error) {
+	if err := s.quota.CheckPortfolio(ctx, userID); err != nil {
+		return nil, err
+	}
+	if input.IISType != nil && input.Currency != "RUB" {
+		return nil, ErrIISCurrency
+	}
+
 	portfolio := &models.Portfolio{
 		UserID:        userID,
 		AccountID:     input.AccountID,
@@ -50,6 +116,14 @@ func (s *portfolioService) Create(ctx context.Context, userID uuid.UUID, input *
 		Currency:      input.Currency,
 		BrokerName:    input.BrokerName,
 		BrokerAccount: input.BrokerAccount,
+		IISType:       input.IISType,
+	}
+	if input.IISType != nil {
+		openedAt := truncateDay(time.Now())
+		if input.IISOpenedAt != nil {
+			openedAt = truncateDay(*input.IISOpenedAt)
+		}
+		portfolio.IISOpenedAt = &openedAt
 	}
 
 	if err := s.portfolioRepo.Create(ctx, portfolio); err != nil {
@@ -75,10 +149,11 @@ func (s *portfolioService) GetByUserID(ctx context.Context, userID uuid.UUID) ([
 			continue
 		}
 
-		var totalValue, totalInvested decimal.Decimal
-		for _, h := range holdings {
-			totalValue = totalValue.Add(h.CurrentValue)
-			totalInvested = totalInvested.Add(h.TotalCost)
+		// позиции в разных валютах складываем в валюте портфеля
+		totalValue, totalInvested, err := convertHoldings(ctx, newCurrencyConverter(s.marketProvider, portfolios[i].Currency), holdings)
+		if err != nil {
+			log.Printf("не удалось пересчитать портфель %s в %s: %v", portfolios[i].ID, portfolios[i].Currency, err)
+			continue
 		}
 
 		portfolios[i].TotalValue = totalValue
@@ -93,6 +168,89 @@ func (s *portfolioService) GetByUserID(ctx context.Context, userID uuid.UUID) ([
 	return portfolios, nil
 }
 
+func (s *portfolioService) GetSummary(ctx context.Context, userID uuid.UUID) ([]models.PortfolioSummary, error) {
+	portfolios, err := s.portfolioRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	holdings, err := s.holdingRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byPortfolio := make(map[uuid.UUID][]models.Holding, len(portfolios))
+	for _, h := range holdings {
+		byPortfolio[h.PortfolioID] = append(byPortfolio[h.PortfolioID], h)
+	}
+	converters := make(map[string]*currencyConverter)
+
+	summaries := make([]models.PortfolioSummary, 0, len(portfolios))
+	for _, p := range portfolios {
+		summary := models.PortfolioSummary{
+			PortfolioID:   p.ID,
+			Name:          p.Name,
+			Currency:      p.Currency,
+			IsActive:      p.IsActive,
+			HoldingsCount: len(byPortfolio[p.ID]),
+		}
+
+		conv, ok := converters[p.Currency]
+		if !ok {
+			conv = newCurrencyConverter(s.marketProvider, p.Currency)
+			converters[p.Currency] = conv
+		}
+		if err := summarizePortfolio(ctx, conv, byPortfolio[p.ID], &summary); err != nil {
+			log.Printf("не удалось пересчитать портфель %s в %s: %v", p.ID, p.Currency, err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+// summarizePortfolio заполняет стоимость, прибыль и изменение за день по позициям портфеля
+func summarizePortfolio(ctx context.Context, conv *currencyConverter, holdings []models.Holding, summary *models.PortfolioSummary) error {
+	totalValue, totalInvested, err := convertHoldings(ctx, conv, holdings)
+	if err != nil {
+		return err
+	}
+	summary.TotalValue = totalValue
+	summary.TotalInvested = totalInvested
+	summary.TotalProfit = totalValue.Sub(totalInvested)
+	if totalInvested.GreaterThan(decimal.Zero) {
+		summary.ProfitPercent = summary.TotalProfit.Div(totalInvested).Mul(decimal.NewFromInt(100))
+	}
+
+	for _, h := range holdings {
+		if h.Security == nil || h.Quantity.IsZero() {
+			continue
+		}
+		// изменение цены с пред закрытия × количество × стоимость пункта, в валюте портфеля
+		change := h.Quantity.Mul(h.Security.PriceChange).Mul(h.Security.ContractSize()).Mul(h.ExchangeRate)
+		summary.DayChange = summary.DayChange.Add(change)
+
+		percent := h.Security.PriceChangePercent
+		if percent.IsZero() {
+			continue
+		}
+		if summary.TopMover == nil || percent.Abs().GreaterThan(summary.TopMover.ChangePercent.Abs()) {
+			summary.TopMover = &models.PortfolioMover{
+				SecurityID:    h.SecurityID,
+				Ticker:        h.Security.Ticker,
+				Name:          h.Security.Name,
+				ChangePercent: percent,
+				DayChange:     change,
+			}
+		}
+	}
+
+	// процент от стоимости на пред закрытии
+	if previous := totalValue.Sub(summary.DayChange); previous.GreaterThan(decimal.Zero) {
+		summary.DayChangePercent = summary.DayChange.Div(previous).Mul(decimal.NewFromInt(100))
+	}
+	return nil
+}
+
 func (s *portfolioService) GetWithHoldings(ctx context.Context, id uuid.UUID) (*models.Portfolio, error) {
 	portfolio, err := s.portfolioRepo.GetByID(ctx, id)
 	if err != nil {
@@ -104,13 +262,17 @@ func (s *portfolioService) GetWithHoldings(ctx context.Context, id uuid.UUID) (*
 		return nil, err
 	}
 
-	portfolio.Holdings = holdings
-
-	var totalValue, totalInvested decimal.Decimal
-	for _, h := range holdings {
-		totalValue = totalValue.Add(h.CurrentValue)
-		totalInvested = totalInvested.Add(h.TotalCost)
+	totalValue, totalInvested, err := convertHoldings(ctx, newCurrencyConverter(s.marketProvider, portfolio.Currency), holdings)
+	if err != nil {
+		return nil, err
+	}
+	if err := attachHoldingMetadata(ctx, s.metadataRepo, id, holdings); err != nil {
+		return nil, err
 	}
+	if err := attachHoldingAging(ctx, s.investmentRepo, id, holdings); err != nil {
+		return nil, err
+	}
+	portfolio.Holdings = holdings
 
 	portfolio.TotalValue = totalValue
 	portfolio.TotalInvested = totalInvested
@@ -123,7 +285,39 @@ func (s *portfolioService) GetWithHoldings(ctx context.Context, id uuid.UUID) (*
 	return portfolio, nil
 }
 
+func (s *portfolioService) GetHoldings(ctx context.Context, id uuid.UUID, filter *models.HoldingFilter) (*models.HoldingList, error) {
+	portfolio, err := s.GetWithHoldings(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := filterHoldings(portfolio.Holdings, filter)
+	page, limit, start, end := listPage(len(holdings), filter.Page, filter.Limit)
+	total := int64(len(holdings))
+	return &models.HoldingList{
+		Holdings:   append([]models.Holding{}, holdings[start:end]...),
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: models.TotalPages(total, limit),
+	}, nil
+}
+
 func (s *portfolioService) Update(ctx context.Context, id uuid.UUID, update *models.PortfolioUpdate) (*models.Portfolio, error) {
+	if update.IISType != nil {
+		portfolio, err := s.portfolioRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if portfolio.Currency != "RUB" {
+			return nil, ErrIISCurrency
+		}
+		// портфель становится ИИС: без явной даты считаем, что счет открыт сегодня
+		if portfolio.IISOpenedAt == nil && update.IISOpenedAt == nil {
+			openedAt := truncateDay(time.Now())
+			update.IISOpenedAt = &openedAt
+		}
+	}
 	if err := s.portfolioRepo.Update(ctx, id, update); err != nil {
 		return nil, err
 	}
@@ -139,38 +333,281 @@ func (s *portfolioService) RefreshPrices(ctx context.Context, portfolioID uuid.U
 	if err != nil {
 		return err
 	}
+	s.refresh(ctx, portfolioID, holdings)
+	return nil
+}
+
+func (s *portfolioService) Refresh(ctx context.Context, userID, portfolioID uuid.UUID, input *models.PortfolioRefreshRequest) (*models.PortfolioRefreshJob, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+
+	holdings, err := s.holdingRepo.GetByPortfolioID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	holdings, err = selectHoldings(holdings, input.Tickers)
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.PortfolioRefreshJob{
+		ID:          uuid.New(),
+		PortfolioID: portfolioID,
+		Status:      models.RefreshJobPending,
+		CreatedAt:   time.Now(),
+	}
+
+	async := len(holdings) >= asyncRefreshHoldings
+	if input.Async != nil {
+		async = *input.Async
+	}
+	if !async {
+		job.Report = s.refresh(ctx, portfolioID, holdings)
+		job.Status = models.RefreshJobDone
+		job.FinishedAt = &job.Report.RefreshedAt
+		return &job, nil
+	}
 
+	s.jobsMu.Lock()
+	s.purgeJobs()
+	s.jobs[job.ID] = &refreshJob{job: job, userID: userID}
+	s.jobsMu.Unlock()
+
+	// запрос завершится раньше обновления, поэтому контекст свой
+	go func() {
+		jobCtx, cancel := context.WithTimeout(context.Background(), refreshJobTimeout)
+		defer cancel()
+
+		s.setJob(job.ID, func(j *models.PortfolioRefreshJob) { j.Status = models.RefreshJobRunning })
+		report := s.refresh(jobCtx, portfolioID, holdings)
+		s.setJob(job.ID, func(j *models.PortfolioRefreshJob) {
+			j.Status = models.RefreshJobDone
+			j.Report = report
+			j.FinishedAt = &report.RefreshedAt
+		})
+	}()
+
+	return &job, nil
+}
+
+func (s *portfolioService) GetRefreshJob(ctx context.Context, userID, jobID uuid.UUID) (*models.PortfolioRefreshJob, error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	j, ok := s.jobs[jobID]
+	if !ok || j.userID != userID {
+		return nil, ErrRefreshJobNotFound
+	}
+	job := j.job
+	return &job, nil
+}
+
+func (s *portfolioService) setJob(id uuid.UUID, update func(*models.PortfolioRefreshJob)) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	if j, ok := s.jobs[id]; ok {
+		update(&j.job)
+	}
+}
+
+// purgeJobs удаляет завершенные задачи старше refreshJobTTL; вызывается под jobsMu
+func (s *portfolioService) purgeJobs() {
+	cutoff := time.Now().Add(-refreshJobTTL)
+	for id, j := range s.jobs {
+		if j.job.FinishedAt != nil && j.job.FinishedAt.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// selectHoldings позиции с указанными тикерами; пустой список - все позиции
+func selectHoldings(holdings []models.Holding, tickers []string) ([]models.Holding, error) {
+	if len(tickers) == 0 {
+		return holdings, nil
+	}
+
+	wanted := make(map[string]bool, len(tickers))
+	for _, t := range tickers {
+		wanted[strings.ToUpper(strings.TrimSpace(t))] = true
+	}
+
+	var selected []models.Holding
+	for _, h := range holdings {
+		if h.Security != nil && wanted[h.Security.Ticker] {
+			selected = append(selected, h)
+			delete(wanted, h.Security.Ticker)
+		}
+	}
+	if len(wanted) > 0 {
+		return nil, ErrTickerNotInPortfolio
+	}
+	return selected, nil
+}
+
+// refresh обновляет цены бумаг позиций и возвращает итог по каждой. ошибки провайдеров не прерывают
+// обновление: бумага без котировки остается с прежней ценой (stale) или без цены (failed)
+func (s *portfolioService) refresh(ctx context.Context, portfolioID uuid.UUID, holdings []models.Holding) *models.PortfolioRefreshReport {
 	// группируем позиции в портфеле по биржам
-	exchangeHoldings := make(map[models.Exchange][]*models.Holding)
+	tickersByExchange := make(map[models.Exchange][]string)
 	for i := range holdings {
-		if holdings[i].Security != nil {
+		// бумаги, заведенные вручную, не котируются
+		if holdings[i].Security != nil && !holdings[i].Security.IsManual() {
 			exchange := holdings[i].Security.Exchange
-			exchangeHoldings[exchange] = append(exchangeHoldings[exchange], &holdings[i])
+			tickersByExchange[exchange] = append(tickersByExchange[exchange], holdings[i].Security.Ticker)
 		}
 	}
 
-	for exchange, hs := range exchangeHoldings {
-		var tickers []string
-		tickerToHolding := make(map[string]*models.Holding)
+	// котировки по всем биржам запрашиваем параллельно; биржи с ошибкой пропускаем
+	quotesByExchange, _ := s.marketProvider.GetQuotesByExchange(ctx, tickersByExchange)
 
-		for _, h := range hs {
-			tickers = append(tickers, h.Security.Ticker)
-			tickerToHolding[h.Security.Ticker] = h
+	// апдейтим цены бумаг
+	report := &models.PortfolioRefreshReport{PortfolioID: portfolioID, Results: []models.PriceRefreshResult{}}
+	prices := make(map[uuid.UUID]decimal.Decimal)
+	for i := range holdings {
+		security := holdings[i].Security
+		if security == nil {
+			continue
+		}
+		result := models.PriceRefreshResult{
+			SecurityID: security.ID,
+			Ticker:     security.Ticker,
+			Exchange:   security.Exchange,
+			Price:      security.LastPrice,
 		}
 
-		quotes, err := s.marketProvider.GetQuotes(ctx, tickers, exchange)
-		if err != nil {
+		quote, ok := quotesByExchange[security.Exchange][security.Ticker]
+		switch {
+		case security.IsManual():
+			result.Status = models.PriceRefreshSkipped
+		case ok:
+			if err := s.securityRepo.UpdatePrice(ctx, security.ID, quote); err != nil {
+				result.Status = models.PriceRefreshFailed
+				result.Error = err.Error()
+				break
+			}
+			result.Status = models.PriceRefreshUpdated
+			result.Price = quote.LastPrice
+			prices[security.ID] = quote.LastPrice
+		case security.LastPrice.IsPositive():
+			result.Status = models.PriceRefreshStale
+			updatedAt := security.UpdatedAt
+			result.PriceUpdatedAt = &updatedAt
+			result.Error = "no quote from provider"
+		default:
+			result.Status = models.PriceRefreshFailed
+			result.Error = "no quote from provider"
+		}
+
+		switch result.Status {
+		case models.PriceRefreshUpdated:
+			report.Updated++
+		case models.PriceRefreshStale:
+			report.Stale++
+		case models.PriceRefreshFailed:
+			report.Failed++
+		case models.PriceRefreshSkipped:
+			report.Skipped++
+		}
+		report.Results = append(report.Results, result)
+	}
+	s.checkTargetPrices(ctx, portfolioID, holdings, prices)
+
+	// уведомление о ребалансировке не должно ломать обновление цен
+	if _, err := s.rebalance.CheckDrift(ctx, portfolioID); err != nil {
+		log.Printf("не удалось проверить целевые доли портфеля %s: %v", portfolioID, err)
+	}
+	if err := s.drawdown.Check(ctx, portfolioID); err != nil {
+		log.Printf("не удалось проверить просадку портфеля %s: %v", portfolioID, err)
+	}
+
+	report.RefreshedAt = time.Now()
+	return report
+}
+
+// checkTargetPrices уведомляет, когда цена дошла до целевой из заметок к позиции. цель выше средней
+// цены покупки считается ценой продажи (ждем роста), ниже - ценой докупки (ждем падения)
+func (s *portfolioService) checkTargetPrices(ctx context.Context, portfolioID uuid.UUID, holdings []models.Holding, prices map[uuid.UUID]decimal.Decimal) {
+	metadata, err := s.metadataRepo.GetByPortfolioID(ctx, portfolioID)
+	if err != nil || len(metadata) == 0 {
+		return
+	}
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil {
+		return
+	}
+
+	targets := make(map[uuid.UUID]decimal.Decimal, len(metadata))
+	for _, m := range metadata {
+		if m.TargetPrice != nil {
+			targets[m.SecurityID] = *m.TargetPrice
+		}
+	}
+
+	for _, h := range holdings {
+		target, ok := targets[h.SecurityID]
+		price, fresh := prices[h.SecurityID]
+		if !ok || !fresh || h.Security == nil {
+			continue
+		}
+		rising := target.GreaterThanOrEqual(h.AveragePrice)
+		if (rising && price.LessThan(target)) || (!rising && price.GreaterThan(target)) {
 			continue
 		}
 
-		// апдейтим цены бумаг
-		for ticker, quote := range quotes {
-			h := tickerToHolding[ticker]
-			if h != nil && h.Security != nil {
-				s.securityRepo.UpdatePrice(ctx, h.Security.ID, quote.LastPrice, quote.Change, quote.ChangePercent, quote.Volume)
-			}
+		s.notifications.Notify(ctx, &models.Notification{
+			UserID:   portfolio.UserID,
+			Type:     models.NotificationPriceAlert,
+			Title:    fmt.Sprintf("%s достиг целевой цены %s", h.Security.Ticker, target.String()),
+			Body:     fmt.Sprintf("Портфель «%s»: текущая цена %s", portfolio.Name, price.String()),
+			EntityID: &portfolio.ID,
+			// новая целевая цена - новое уведомление
+			DedupKey: fmt.Sprintf("target_price:%s:%s:%s", portfolioID, h.SecurityID, target.String()),
+		})
+	}
+}
+
+func (s *portfolioService) RefreshWatched(ctx context.Context) (int, error) {
+	ids, err := s.rebalance.GetWatchedPortfolios(ctx)
+	if err != nil {
+		return 0, err
+	}
+	drawdownIDs, err := s.drawdown.GetWatchedPortfolios(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// портфель с обоими видами уведомлений обновляем один раз
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, id := range drawdownIDs {
+		if !seen[id] {
+			ids = append(ids, id)
 		}
 	}
+	return s.refreshPortfolios(ctx, ids), nil
+}
 
-	return nil
+func (s *portfolioService) RefreshAll(ctx context.Context) (int, error) {
+	ids, err := s.portfolioRepo.GetActiveIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return s.refreshPortfolios(ctx, ids), nil
+}
+
+// refreshPortfolios обновляет цены по очереди; ошибка одного портфеля не останавливает остальные
+func (s *portfolioService) refreshPortfolios(ctx context.Context, ids []uuid.UUID) int {
+	refreshed := 0
+	for _, id := range ids {
+		if err := s.RefreshPrices(ctx, id); err != nil {
+			log.Printf("не удалось обновить цены портфеля %s: %v", id, err)
+			continue
+		}
+		refreshed++
+	}
+	return refreshed
 }
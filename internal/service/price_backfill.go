@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidBackfillYears = errors.New("years must be between 1 and 20")
+	ErrBackfillManual       = errors.New("manual securities have no provider history to backfill")
+	ErrBackfillUnavailable  = errors.New("price history provider is unavailable")
+)
+
+const (
+	defaultBackfillYears = 5
+	maxBackfillYears     = 20
+)
+
+func (s *investmentService) BackfillHistory(ctx context.Context, userID, securityID uuid.UUID, years int) (*models.PriceBackfillResult, error) {
+	if years == 0 {
+		years = defaultBackfillYears
+	}
+	if years < 1 || years > maxBackfillYears {
+		return nil, ErrInvalidBackfillYears
+	}
+	security, err := s.securityRepo.GetByID(ctx, securityID)
+	if err != nil || (security.OwnerID != nil && *security.OwnerID != userID) {
+		return nil, ErrSecurityNotFound
+	}
+	if security.IsManual() {
+		return nil, ErrBackfillManual
+	}
+
+	from := truncateDay(time.Now()).AddDate(-years, 0, 0)
+	bars, err := s.priceHistory.Backfill(ctx, security, from)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBackfillUnavailable, err)
+	}
+
+	result := &models.PriceBackfillResult{SecurityID: security.ID, Ticker: security.Ticker, Bars: len(bars)}
+	if len(bars) > 0 {
+		result.From, result.To = &bars[0].Date, &bars[len(bars)-1].Date
+	}
+
+	portfolioIDs, err := s.investmentRepo.GetPortfolioIDsBySecurity(ctx, security.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, portfolioID := range portfolioIDs {
+		n, err := s.rebuildValueSnapshots(ctx, portfolioID, from)
+		if err != nil {
+			log.Printf("Не удалось пересчитать снимки стоимости портфеля %s после загрузки истории %s: %v", portfolioID, security.Ticker, err)
+			continue
+		}
+		result.Portfolios++
+		result.Snapshots += n
+	}
+	return result, nil
+}
+
+// rebuildValueSnapshots пересчитывает дневные снимки стоимости портфеля с даты from до вчерашнего дня:
+// позиции восстанавливаются по операциям, цены - по сохраненным свечам (в дни без торгов - последняя цена).
+// стоимость переводится в валюту портфеля по текущему курсу, как в сравнении с бенчмарком.
+// сегодняшний снимок не трогаем - его ведет обновление цен
+func (s *investmentService) rebuildValueSnapshots(ctx context.Context, portfolioID uuid.UUID, from time.Time) (int, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil {
+		return 0, err
+	}
+	today := truncateDay(time.Now())
+	transactions, err := s.investmentRepo.GetByDateRange(ctx, portfolioID, time.Time{}, today)
+	if err != nil || len(transactions) == 0 {
+		return 0, err
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if !transactions[i].Date.Equal(transactions[j].Date) {
+			return transactions[i].Date.Before(transactions[j].Date)
+		}
+		return transactions[i].CreatedAt.Before(transactions[j].CreatedAt)
+	})
+	if first := truncateDay(transactions[0].Date); first.After(from) {
+		from = first
+	}
+
+	conv := newCurrencyConverter(s.marketProvider, portfolio.Currency)
+	positions := make(map[uuid.UUID]*benchmarkPosition)
+	tradingDays := make(map[time.Time]bool)
+	for _, tx := range transactions {
+		if _, ok := positions[tx.SecurityID]; ok {
+			continue
+		}
+		security, err := s.securityRepo.GetByID(ctx, tx.SecurityID)
+		if err != nil {
+			return 0, err
+		}
+		rate, err := conv.rate(ctx, securityCurrency(security, portfolio.Currency))
+		if err != nil {
+			return 0, err
+		}
+		pos := &benchmarkPosition{security: security, rate: rate, closes: make(map[time.Time]decimal.Decimal)}
+		// недостающая история остальных бумаг портфеля догружается здесь же
+		history, err := s.priceHistory.GetHistory(ctx, security.ID, from, today.AddDate(0, 0, -1))
+		if err != nil {
+			return 0, err
+		}
+		for _, bar := range history {
+			if bar.Close.IsPositive() {
+				pos.closes[bar.Date] = bar.Close
+				tradingDays[bar.Date] = true
+			}
+		}
+		positions[tx.SecurityID] = pos
+	}
+
+	days := make([]time.Time, 0, len(tradingDays))
+	for day := range tradingDays {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	snapshots := make([]models.PortfolioValueSnapshot, 0, len(days))
+	next := 0
+	for _, day := range days {
+		// сначала операции дня, потом закрытие: в день сплита свеча уже в новых ценах
+		for ; next < len(transactions) && !truncateDay(transactions[next].Date).After(day); next++ {
+			replayQuantity(positions[transactions[next].SecurityID], &transactions[next])
+		}
+		for _, pos := range positions {
+			if c, ok := pos.closes[day]; ok {
+				pos.last = c
+			}
+		}
+
+		var value decimal.Decimal
+		for _, pos := range positions {
+			value = value.Add(pos.value())
+		}
+		// пустой портфель не снимаем, как и при ежедневном обновлении
+		if value.IsPositive() {
+			snapshots = append(snapshots, models.PortfolioValueSnapshot{
+				PortfolioID: portfolioID,
+				Date:        day,
+				Value:       value.Round(2),
+				Currency:    portfolio.Currency,
+			})
+		}
+	}
+
+	if err := s.drawdownRepo.ReplaceSnapshots(ctx, portfolioID, snapshots); err != nil {
+		return 0, err
+	}
+	return len(snapshots), nil
+}
+
+// replayQuantity меняет количество бумаги в позиции по операции
+func replayQuantity(pos *benchmarkPosition, tx *models.InvestmentTransaction) {
+	if pos.last.IsZero() && tx.Price.IsPositive() {
+		pos.last = tx.Price
+	}
+	switch tx.Type {
+	case models.InvestmentTransactionTypeBuy, models.InvestmentTransactionTypeTransferIn,
+		models.InvestmentTransactionTypeStakingReward, models.InvestmentTransactionTypeAirdrop:
+		pos.quantity = pos.quantity.Add(tx.Quantity)
+	case models.InvestmentTransactionTypeSell, models.InvestmentTransactionTypeTransferOut,
+		models.InvestmentTransactionTypeExpiration, models.InvestmentTransactionTypeRedemption:
+		pos.quantity = pos.quantity.Sub(tx.Quantity)
+	case models.InvestmentTransactionTypeSplit:
+		if tx.Quantity.IsPositive() {
+			pos.quantity = pos.quantity.Mul(tx.Quantity)
+			pos.last = pos.last.Div(tx.Quantity)
+		}
+	}
+}
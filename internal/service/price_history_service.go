@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidDateRange = errors.New("invalid date range")
+
+type PriceHistoryService interface {
+	// GetHistory дневные свечи за период из бд; даты, которых еще нет, догружаются из провайдера.
+	// если провайдер недоступен, отдается то, что уже сохранено
+	GetHistory(ctx context.Context, securityID uuid.UUID, from, to time.Time) ([]models.PriceHistory, error)
+	// RecordPrice сохраняет цену закрытия на дату, введенную вручную; если это самая свежая дата,
+	// она же становится текущей ценой бумаги
+	RecordPrice(ctx context.Context, security *models.Security, date time.Time, price decimal.Decimal) error
+	// Backfill догружает из провайдера свечи с даты from по сегодня; в отличие от GetHistory
+	// ошибка провайдера возвращается. отдает все сохраненные свечи за период
+	Backfill(ctx context.Context, security *models.Security, from time.Time) ([]models.PriceHistory, error)
+}
+
+type priceHistoryService struct {
+	historyRepo    repository.PriceHistoryRepository
+	securityRepo   repository.SecurityRepository
+	marketProvider *market.MultiProvider
+	txManager      repository.TxManager
+}
+
+func NewPriceHistoryService(historyRepo repository.PriceHistoryRepository, securityRepo repository.SecurityRepository, marketProvider *market.MultiProvider, txManager repository.TxManager) PriceHistoryService {
+	return &priceHistoryService{
+		historyRepo:    historyRepo,
+		securityRepo:   securityRepo,
+		marketProvider: marketProvider,
+		txManager:      txManager,
+	}
+}
+
+func (s *priceHistoryService) GetHistory(ctx context.Context, securityID uuid.UUID, from, to time.Time) ([]models.PriceHistory, error) {
+	from, to = truncateDay(from), truncateDay(to)
+	if to.Before(from) {
+		return nil, ErrInvalidDateRange
+	}
+
+	security, err := s.securityRepo.GetByID(ctx, securityID)
+	if err != nil {
+		return nil, ErrSecurityNotFound
+	}
+
+	// у бумаг, заведенных вручную, провайдера нет - история только из введенных цен
+	if security.IsManual() {
+		return s.historyRepo.GetRange(ctx, securityID, from, to)
+	}
+
+	if err := s.sync(ctx, security, from, to); err != nil {
+		log.Printf("История цен %s: провайдер недоступен, используем сохраненные данные: %v", security.Ticker, err)
+	}
+
+	return s.historyRepo.GetRange(ctx, securityID, from, to)
+}
+
+func (s *priceHistoryService) RecordPrice(ctx context.Context, security *models.Security, date time.Time, price decimal.Decimal) error {
+	date = truncateDay(date)
+
+	return s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		bar := models.PriceHistory{SecurityID: security.ID, Date: date, Open: price, High: price, Low: price, Close: price}
+		if err := s.historyRepo.Upsert(ctx, []models.PriceHistory{bar}); err != nil {
+			return err
+		}
+
+		// текущая цена и изменение - по двум последним введенным датам
+		bars, err := s.historyRepo.GetRange(ctx, security.ID, time.Time{}, truncateDay(time.Now()).AddDate(1, 0, 0))
+		if err != nil {
+			return err
+		}
+		last := bars[len(bars)-1]
+		if last.Date.After(date) {
+			return nil
+		}
+		change, changePercent := decimal.Zero, decimal.Zero
+		if len(bars) > 1 {
+			prev := bars[len(bars)-2].Close
+			change = last.Close.Sub(prev)
+			if prev.IsPositive() {
+				changePercent = change.Div(prev).Mul(decimal.NewFromInt(100)).Round(2)
+			}
+		}
+		return s.securityRepo.UpdatePrice(ctx, security.ID, &models.MarketQuote{LastPrice: last.Close, Change: change, ChangePercent: changePercent})
+	})
+}
+
+func (s *priceHistoryService) Backfill(ctx context.Context, security *models.Security, from time.Time) ([]models.PriceHistory, error) {
+	from, to := truncateDay(from), truncateDay(time.Now())
+	if err := s.sync(ctx, security, from, to); err != nil {
+		return nil, err
+	}
+	return s.historyRepo.GetRange(ctx, security.ID, from, to)
+}
+
+// sync догружает из провайдера только даты за пределами уже загруженного диапазона
+func (s *priceHistoryService) sync(ctx context.Context, security *models.Security, from, to time.Time) error {
+	today := truncateDay(time.Now())
+	if to.After(today) {
+		to = today
+	}
+	if to.Before(from) {
+		return nil
+	}
+
+	type dateRange struct{ from, to time.Time }
+	var missing []dateRange
+
+	coveredFrom, coveredTo := from, to
+	coverage, err := s.historyRepo.GetCoverage(ctx, security.ID)
+	if err != nil {
+		missing = append(missing, dateRange{from, to})
+	} else {
+		if from.Before(coverage.FromDate) {
+			missing = append(missing, dateRange{from, coverage.FromDate.AddDate(0, 0, -1)})
+		} else {
+			coveredFrom = coverage.FromDate
+		}
+		if to.After(coverage.ToDate) {
+			missing = append(missing, dateRange{coverage.ToDate.AddDate(0, 0, 1), to})
+		} else {
+			coveredTo = coverage.ToDate
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var bars []models.PriceHistory
+	for _, r := range missing {
+		fetched, err := s.marketProvider.GetPriceHistory(ctx, security.Ticker, security.Exchange, r.from, r.to)
+		if err != nil {
+			return err
+		}
+		for _, bar := range fetched {
+			bars = append(bars, models.PriceHistory{
+				SecurityID: security.ID,
+				Date:       truncateDay(bar.Date),
+				Open:       bar.Open,
+				High:       bar.High,
+				Low:        bar.Low,
+				Close:      bar.Close,
+				Volume:     bar.Volume,
+			})
+		}
+	}
+
+	// сегодняшняя свеча еще формируется - день не считаем загруженным, в следующий раз перезапросим
+	if !coveredTo.Before(today) {
+		coveredTo = today.AddDate(0, 0, -1)
+	}
+
+	return s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.historyRepo.Upsert(ctx, bars); err != nil {
+			return err
+		}
+		if coveredTo.Before(coveredFrom) {
+			return nil
+		}
+		return s.historyRepo.SetCoverage(ctx, &models.PriceHistoryCoverage{
+			SecurityID: security.ID,
+			FromDate:   coveredFrom,
+			ToDate:     coveredTo,
+		})
+	})
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrProductNotFound  = errors.New("product not found")
+	ErrProductNameTaken = errors.New("product with this name already exists, merge them instead")
+	ErrInvalidProduct   = errors.New("product name is empty")
+)
+
+// без дат отчеты по товарам строятся за последний год
+const productDefaultMonths = 12
+
+type ProductService interface {
+	// Resolve находит товар по названию позиции чека или создает новый; для пустого названия возвращает nil
+	Resolve(ctx context.Context, userID uuid.UUID, name string) (*uuid.UUID, error)
+	GetByID(ctx context.Context, userID, id uuid.UUID) (*models.Product, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, query string) ([]models.Product, error)
+	Rename(ctx context.Context, userID, id uuid.UUID, input *models.ProductRename) (*models.Product, error)
+	Merge(ctx context.Context, userID, targetID uuid.UUID, input *models.ProductMerge) (*models.Product, error)
+	// GetSpending сколько потрачено на каждый товар за период; currency - валюта отчета, пустая = валюта пользователя
+	GetSpending(ctx context.Context, userID uuid.UUID, startDate, endDate *time.Time, currency string) (*models.ProductSpendingReport, error)
+	// GetPriceHistory цены товара по покупкам и продавцам
+	GetPriceHistory(ctx context.Context, userID, id uuid.UUID, startDate, endDate *time.Time) (*models.ProductPriceHistory, error)
+}
+
+type productService struct {
+	productRepo    repository.ProductRepository
+	userRepo       repository.UserRepository
+	txManager      repository.TxManager
+	marketProvider *market.MultiProvider
+	config         *config.Config
+}
+
+func NewProductService(productRepo repository.ProductRepository, userRepo repository.UserRepository, txManager repository.TxManager, marketProvider *market.MultiProvider, cfg *config.Config) ProductService {
+	return &productService{
+		productRepo:    productRepo,
+		userRepo:       userRepo,
+		txManager:      txManager,
+		marketProvider: marketProvider,
+		config:         cfg,
+	}
+}
+
+func (s *productService) Resolve(ctx context.Context, userID uuid.UUID, name string) (*uuid.UUID, error) {
+	normalized := normalizeProductName(name)
+	if normalized == "" {
+		return nil, nil
+	}
+
+	if product, err := s.productRepo.GetByNormalizedName(ctx, userID, normalized); err == nil {
+		return &product.ID, nil
+	}
+
+	product := &models.Product{
+		UserID:         userID,
+		Name:           productDisplayName(name),
+		NormalizedName: normalized,
+	}
+	if err := s.productRepo.Create(ctx, product); err != nil {
+		return nil, err
+	}
+	return &product.ID, nil
+}
+
+func (s *productService) GetByID(ctx context.Context, userID, id uuid.UUID) (*models.Product, error) {
+	product, err := s.productRepo.GetByID(ctx, id)
+	if err != nil || product.UserID != userID {
+		return nil, ErrProductNotFound
+	}
+	return product, nil
+}
+
+func (s *productService) GetByUserID(ctx context.Context, userID uuid.UUID, query string) ([]models.Product, error) {
+	return s.productRepo.GetByUserID(ctx, userID, strings.TrimSpace(query))
+}
+
+func (s *productService) Rename(ctx context.Context, userID, id uuid.UUID, input *models.ProductRename) (*models.Product, error) {
+	product, err := s.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	name := productDisplayName(input.Name)
+	normalized := normalizeProductName(name)
+	if normalized == "" {
+		return nil, ErrInvalidProduct
+	}
+	if existing, err := s.productRepo.GetByNormalizedName(ctx, userID, normalized); err == nil && existing.ID != id {
+		return nil, ErrProductNameTaken
+	}
+
+	if err := s.productRepo.Rename(ctx, id, name, normalized); err != nil {
+		return nil, err
+	}
+	product.Name = name
+	product.NormalizedName = normalized
+	return product, nil
+}
+
+func (s *productService) Merge(ctx context.Context, userID, targetID uuid.UUID, input *models.ProductMerge) (*models.Product, error) {
+	target, err := s.GetByID(ctx, userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceIDs []uuid.UUID
+	for _, id := range input.SourceIDs {
+		if id == targetID {
+			continue
+		}
+		if _, err := s.GetByID(ctx, userID, id); err != nil {
+			return nil, err
+		}
+		sourceIDs = append(sourceIDs, id)
+	}
+	if len(sourceIDs) == 0 {
+		return target, nil
+	}
+
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		return s.productRepo.Merge(txCtx, targetID, sourceIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+func (s *productService) GetSpending(ctx context.Context, userID uuid.UUID, startDate, endDate *time.Time, currency string) (*models.ProductSpendingReport, error) {
+	start, end := productPeriod(startDate, endDate)
+
+	rows, err := s.productRepo.GetSpending(ctx, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	userCurrency := ""
+	if user, _ := s.userRepo.GetByID(ctx, userID); user != nil {
+		userCurrency = user.DefaultCurrency
+	}
+	currency = reportCurrency(currency, userCurrency, s.config.DefaultCurrency)
+	conv := newCurrencyConverter(s.marketProvider, currency)
+
+	// одна строка на товар, валюты сведены к валюте отчета
+	byProduct := make(map[uuid.UUID]*models.ProductSpending)
+	for _, row := range rows {
+		amount, err := conv.convert(ctx, row.Amount, row.Currency)
+		if err != nil {
+			return nil, err
+		}
+		item, ok := byProduct[row.ProductID]
+		if !ok {
+			item = &models.ProductSpending{ProductID: row.ProductID, Name: row.Name}
+			byProduct[row.ProductID] = item
+		}
+		item.PurchaseCount += row.PurchaseCount
+		item.Quantity = item.Quantity.Add(row.Quantity)
+		item.TotalSpent = item.TotalSpent.Add(amount)
+	}
+
+	report := &models.ProductSpendingReport{
+		Currency:  currency,
+		StartDate: start,
+		EndDate:   end,
+		Products:  make([]models.ProductSpending, 0, len(byProduct)),
+	}
+	for _, item := range byProduct {
+		report.TotalSpent = report.TotalSpent.Add(item.TotalSpent)
+	}
+	for _, item := range byProduct {
+		if item.Quantity.IsPositive() {
+			item.AveragePrice = item.TotalSpent.Div(item.Quantity).Round(2)
+		}
+		if report.TotalSpent.IsPositive() {
+			item.Share = item.TotalSpent.Div(report.TotalSpent).Mul(decimal.NewFromInt(100)).Round(2)
+		}
+		report.Products = append(report.Products, *item)
+	}
+	sort.Slice(report.Products, func(i, j int) bool {
+		return report.Products[i].TotalSpent.GreaterThan(report.Products[j].TotalSpent)
+	})
+	return report, nil
+}
+
+func (s *productService) GetPriceHistory(ctx context.Context, userID, id uuid.UUID, startDate, endDate *time.Time) (*models.ProductPriceHistory, error) {
+	product, err := s.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := productPeriod(startDate, endDate)
+	points, err := s.productRepo.GetPurchases(ctx, id, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &models.ProductPriceHistory{
+		ProductID: product.ID,
+		Name:      product.Name,
+		Points:    points,
+		Merchants: []models.MerchantPrice{},
+	}
+	if len(points) == 0 {
+		history.Points = []models.ProductPricePoint{}
+		return history, nil
+	}
+
+	// min/max и изменение - только по покупкам в валюте последней, цены разных валют не сравниваем
+	last := points[len(points)-1]
+	history.Currency = last.Currency
+	history.LastPrice = last.Price
+	first := true
+	for _, p := range points {
+		if p.Currency != history.Currency {
+			continue
+		}
+		if first {
+			history.FirstPrice, history.MinPrice, history.MaxPrice = p.Price, p.Price, p.Price
+			first = false
+		}
+		history.MinPrice = decimal.Min(history.MinPrice, p.Price)
+		history.MaxPrice = decimal.Max(history.MaxPrice, p.Price)
+	}
+	if history.FirstPrice.IsPositive() {
+		history.ChangePercent = history.LastPrice.Sub(history.FirstPrice).Div(history.FirstPrice).Mul(decimal.NewFromInt(100)).Round(2)
+	}
+
+	history.Merchants = merchantPrices(points)
+	return history, nil
+}
+
+// merchantPrices сводка цен по продавцам: дешевле в среднем - выше
+func merchantPrices(points []models.ProductPricePoint) []models.MerchantPrice {
+	type key struct {
+		merchant string
+		currency string
+	}
+	sums := make(map[key]decimal.Decimal)
+	byMerchant := make(map[key]*models.MerchantPrice)
+	var order []key
+	for _, p := range points {
+		k := key{merchant: strings.ToLower(p.Merchant), currency: p.Currency}
+		m, ok := byMerchant[k]
+		if !ok {
+			m = &models.MerchantPrice{PayeeID: p.PayeeID, Merchant: p.Merchant, Currency: p.Currency, MinPrice: p.Price}
+			byMerchant[k] = m
+			order = append(order, k)
+		}
+		m.PurchaseCount++
+		m.MinPrice = decimal.Min(m.MinPrice, p.Price)
+		// покупки идут по возрастанию даты - последняя перезаписывает
+		m.LastPrice = p.Price
+		m.LastDate = p.Date
+		sums[k] = sums[k].Add(p.Price)
+	}
+
+	result := make([]models.MerchantPrice, 0, len(order))
+	for _, k := range order {
+		m := byMerchant[k]
+		m.AveragePrice = sums[k].Div(decimal.NewFromInt(int64(m.PurchaseCount))).Round(2)
+		result = append(result, *m)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Currency != result[j].Currency {
+			return result[i].Currency < result[j].Currency
+		}
+		return result[i].AveragePrice.LessThan(result[j].AveragePrice)
+	})
+	return result
+}
+
+// productPeriod период отчета: указанные даты или последние 12 месяцев
+func productPeriod(startDate, endDate *time.Time) (time.Time, time.Time) {
+	end := time.Now()
+	if endDate != nil {
+		end = *endDate
+	}
+	start := end.AddDate(0, -productDefaultMonths, 0)
+	if startDate != nil {
+		start = *startDate
+	}
+	return start, end
+}
+
+// normalizeProductName ключ товара: нижний регистр и одиночные пробелы. так же товары
+// сопоставляются в миграции, которая привязала уже сохраненные позиции
+func normalizeProductName(name string) string {
+	return strings.ToLower(productDisplayName(name))
+}
+
+// productDisplayName название товара для показа пользователю
+func productDisplayName(name string) string {
+	return truncateRunes(strings.Join(strings.Fields(name), " "), 500)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+)
+
+// квоты на количество (портфели, объем вложений) - 403, на частоту (транзакции за месяц, AI за день) - 429
+var (
+	ErrPortfolioQuotaExceeded   = errors.New("portfolio limit reached")
+	ErrTransactionQuotaExceeded = errors.New("monthly transaction limit reached")
+	ErrAttachmentQuotaExceeded  = errors.New("monthly attachment size limit reached")
+	ErrAIQuotaExceeded          = errors.New("daily AI request limit reached")
+)
+
+type QuotaService interface {
+	// CheckPortfolio можно ли создать еще один портфель
+	CheckPortfolio(ctx context.Context, userID uuid.UUID) error
+	// CheckTransaction можно ли создать еще одну транзакцию в этом месяце
+	CheckTransaction(ctx context.Context, userID uuid.UUID) error
+	// UseAttachment учитывает загруженный файл в месячном объеме
+	UseAttachment(ctx context.Context, userID uuid.UUID, size int64) error
+	// UseAICall учитывает обращение к AI в дневном лимите
+	UseAICall(ctx context.Context, userID uuid.UUID) error
+	GetUsage(ctx context.Context, userID uuid.UUID) (*models.UserUsage, error)
+}
+
+type quotaService struct {
+	usageRepo repository.UsageRepository
+	config    *config.Config
+}
+
+func NewQuotaService(usageRepo repository.UsageRepository, cfg *config.Config) QuotaService {
+	return &quotaService{
+		usageRepo: usageRepo,
+		config:    cfg,
+	}
+}
+
+func (s *quotaService) CheckPortfolio(ctx context.Context, userID uuid.UUID) error {
+	limit := int64(s.config.QuotaMaxPortfolios)
+	if limit <= 0 {
+		return nil
+	}
+	count, err := s.usageRepo.CountPortfolios(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if count >= limit {
+		return ErrPortfolioQuotaExceeded
+	}
+	return nil
+}
+
+func (s *quotaService) CheckTransaction(ctx context.Context, userID uuid.UUID) error {
+	limit := int64(s.config.QuotaTransactionsPerMonth)
+	if limit <= 0 {
+		return nil
+	}
+	month, _ := quotaPeriods(time.Now())
+	count, err := s.usageRepo.CountTransactionsSince(ctx, userID, month)
+	if err != nil {
+		return err
+	}
+	if count >= limit {
+		return ErrTransactionQuotaExceeded
+	}
+	return nil
+}
+
+func (s *quotaService) UseAttachment(ctx context.Context, userID uuid.UUID, size int64) error {
+	month, _ := quotaPeriods(time.Now())
+	ok, err := s.usageRepo.Add(ctx, userID, repository.UsageMetricAttachmentBytes, month, size, s.attachmentLimit())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAttachmentQuotaExceeded
+	}
+	return nil
+}
+
+func (s *quotaService) UseAICall(ctx context.Context, userID uuid.UUID) error {
+	_, day := quotaPeriods(time.Now())
+	ok, err := s.usageRepo.Add(ctx, userID, repository.UsageMetricAICalls, day, 1, int64(max(s.config.QuotaAICallsPerDay, 0)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAIQuotaExceeded
+	}
+	return nil
+}
+
+func (s *quotaService) GetUsage(ctx context.Context, userID uuid.UUID) (*models.UserUsage, error) {
+	month, day := quotaPeriods(time.Now())
+	usage := &models.UserUsage{
+		Portfolios:            models.QuotaUsage{Limit: int64(max(s.config.QuotaMaxPortfolios, 0))},
+		TransactionsThisMonth: models.QuotaUsage{Limit: int64(max(s.config.QuotaTransactionsPerMonth, 0))},
+		AttachmentBytesMonth:  models.QuotaUsage{Limit: s.attachmentLimit()},
+		AICallsToday:          models.QuotaUsage{Limit: int64(max(s.config.QuotaAICallsPerDay, 0))},
+		MonthResetsAt:         month.AddDate(0, 1, 0),
+		DayResetsAt:           day.AddDate(0, 0, 1),
+	}
+
+	var err error
+	if usage.Portfolios.Used, err = s.usageRepo.CountPortfolios(ctx, userID); err != nil {
+		return nil, err
+	}
+	if usage.TransactionsThisMonth.Used, err = s.usageRepo.CountTransactionsSince(ctx, userID, month); err != nil {
+		return nil, err
+	}
+	if usage.AttachmentBytesMonth.Used, err = s.usageRepo.Get(ctx, userID, repository.UsageMetricAttachmentBytes, month); err != nil {
+		return nil, err
+	}
+	if usage.AICallsToday.Used, err = s.usageRepo.Get(ctx, userID, repository.UsageMetricAICalls, day); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (s *quotaService) attachmentLimit() int64 {
+	return int64(max(s.config.QuotaAttachmentMBPerMonth, 0)) << 20
+}
+
+// quotaPeriods начало текущего месяца и дня (UTC), к которым привязаны счетчики
+func quotaPeriods(now time.Time) (month, day time.Time) {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), truncateDay(now)
+}
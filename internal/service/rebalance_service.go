@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/notify"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrPortfolioNotFound   = errors.New("portfolio not found")
+	ErrInvalidTargetWeight = errors.New("target weights must be between 0 and 100 and sum to at most 100")
+	ErrDuplicateTarget     = errors.New("security is listed more than once in targets")
+	ErrInvalidThreshold    = errors.New("threshold must be between 0 and 100")
+)
+
+var hundred = decimal.NewFromInt(100)
+
+type RebalanceService interface {
+	GetTargets(ctx context.Context, userID, portfolioID uuid.UUID) (*models.RebalanceTargets, error)
+	// SetTargets заменяет целевые доли и порог; пустой список targets удаляет цели
+	SetTargets(ctx context.Context, userID, portfolioID uuid.UUID, input *models.RebalanceTargetsInput) (*models.RebalanceTargets, error)
+	// GetReport текущие доли против целевых с предлагаемыми сделками по последним сохраненным ценам
+	GetReport(ctx context.Context, userID, portfolioID uuid.UUID) (*models.RebalanceReport, error)
+
+	// CheckDrift вызывается после обновления цен: если доля бумаги ушла от цели дальше порога,
+	// отправляет уведомление (один раз, пока доля не вернется в порог). Возвращает число новых отклонений
+	CheckDrift(ctx context.Context, portfolioID uuid.UUID) (int, error)
+	// GetWatchedPortfolios портфели, для которых включены уведомления о ребалансировке
+	GetWatchedPortfolios(ctx context.Context) ([]uuid.UUID, error)
+}
+
+type rebalanceService struct {
+	rebalanceRepo  repository.RebalanceRepository
+	portfolioRepo  repository.PortfolioRepository
+	holdingRepo    repository.HoldingRepository
+	securityRepo   repository.SecurityRepository
+	userRepo       repository.UserRepository
+	marketProvider *market.MultiProvider
+	txManager      repository.TxManager
+	mailer         notify.Mailer // nil - письма не отправляются, только в лог
+	notifications  NotificationService
+}
+
+func NewRebalanceService(
+	rebalanceRepo repository.RebalanceRepository,
+	portfolioRepo repository.PortfolioRepository,
+	holdingRepo repository.HoldingRepository,
+	securityRepo repository.SecurityRepository,
+	userRepo repository.UserRepository,
+	marketProvider *market.MultiProvider,
+	txManager repository.TxManager,
+	mailer notify.Mailer,
+	notifications NotificationService,
+) RebalanceService {
+	return &rebalanceService{
+		rebalanceRepo:  rebalanceRepo,
+		portfolioRepo:  portfolioRepo,
+		holdingRepo:    holdingRepo,
+		securityRepo:   securityRepo,
+		userRepo:       userRepo,
+		marketProvider: marketProvider,
+		txManager:      txManager,
+		mailer:         mailer,
+		notifications:  notifications,
+	}
+}
+
+func (s *rebalanceService) GetTargets(ctx context.Context, userID, portfolioID uuid.UUID) (*models.RebalanceTargets, error) {
+	if _, err := s.portfolio(ctx, userID, portfolioID); err != nil {
+		return nil, err
+	}
+	return s.targets(ctx, portfolioID)
+}
+
+func (s *rebalanceService) SetTargets(ctx context.Context, userID, portfolioID uuid.UUID, input *models.RebalanceTargetsInput) (*models.RebalanceTargets, error) {
+	if _, err := s.portfolio(ctx, userID, portfolioID); err != nil {
+		return nil, err
+	}
+	if input.Threshold != nil && (!input.Threshold.IsPositive() || input.Threshold.GreaterThan(hundred)) {
+		return nil, ErrInvalidThreshold
+	}
+
+	seen := make(map[uuid.UUID]bool, len(input.Targets))
+	targets := make([]models.PortfolioTarget, 0, len(input.Targets))
+	var total decimal.Decimal
+	for _, t := range input.Targets {
+		if t.Weight.IsNegative() || t.Weight.GreaterThan(hundred) {
+			return nil, ErrInvalidTargetWeight
+		}
+		if seen[t.SecurityID] {
+			return nil, ErrDuplicateTarget
+		}
+		seen[t.SecurityID] = true
+		if _, err := s.securityRepo.GetByID(ctx, t.SecurityID); err != nil {
+			return nil, ErrSecurityNotFound
+		}
+		total = total.Add(t.Weight)
+		targets = append(targets, models.PortfolioTarget{PortfolioID: portfolioID, SecurityID: t.SecurityID, Weight: t.Weight})
+	}
+	// остаток до 100% - бумаги без цели и деньги
+	if total.GreaterThan(hundred) {
+		return nil, ErrInvalidTargetWeight
+	}
+
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.rebalanceRepo.ReplaceTargets(ctx, portfolioID, targets); err != nil {
+			return err
+		}
+		return s.rebalanceRepo.SetThreshold(ctx, portfolioID, input.Threshold)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.targets(ctx, portfolioID)
+}
+
+func (s *rebalanceService) GetReport(ctx context.Context, userID, portfolioID uuid.UUID) (*models.RebalanceReport, error) {
+	portfolio, err := s.portfolio(ctx, userID, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	report, _, err := s.buildReport(ctx, portfolio)
+	return report, err
+}
+
+func (s *rebalanceService) CheckDrift(ctx context.Context, portfolioID uuid.UUID) (int, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil {
+		return 0, err
+	}
+
+	report, targets, err := s.buildReport(ctx, portfolio)
+	if err != nil || report.Threshold == nil || len(targets) == 0 {
+		return 0, err
+	}
+
+	alerted := make(map[uuid.UUID]bool, len(targets))
+	for _, t := range targets {
+		alerted[t.SecurityID] = t.AlertedAt != nil
+	}
+
+	now := time.Now()
+	fresh := 0
+	for _, item := range report.Items {
+		switch {
+		case item.OverThreshold && !alerted[item.SecurityID]:
+			fresh++
+		case !item.OverThreshold && alerted[item.SecurityID]:
+			// доля вернулась в порог - следующее отклонение снова даст уведомление
+			if err := s.rebalanceRepo.SetAlerted(ctx, portfolioID, item.SecurityID, nil); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if fresh == 0 {
+		return 0, nil
+	}
+
+	text := driftText(portfolio, report)
+	if err := s.notify(ctx, portfolio, text); err != nil {
+		// отметки не ставим - попробуем при следующем обновлении цен
+		return 0, err
+	}
+	for _, item := range report.Items {
+		if item.OverThreshold && !alerted[item.SecurityID] {
+			if err := s.rebalanceRepo.SetAlerted(ctx, portfolioID, item.SecurityID, &now); err != nil {
+				return fresh, err
+			}
+		}
+	}
+
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID:   portfolio.UserID,
+		Type:     models.NotificationPriceAlert,
+		Title:    rebalanceSubject(portfolio),
+		Body:     text,
+		EntityID: &portfolio.ID,
+	})
+	return fresh, nil
+}
+
+func (s *rebalanceService) GetWatchedPortfolios(ctx context.Context) ([]uuid.UUID, error) {
+	return s.rebalanceRepo.GetWatchedPortfolioIDs(ctx)
+}
+
+// buildReport считает доли по сохраненным ценам в валюте портфеля; доля берется от всего портфеля,
+// включая бумаги без цели, а проверяются только бумаги с целевой долей
+func (s *rebalanceService) buildReport(ctx context.Context, portfolio *models.Portfolio) (*models.RebalanceReport, []models.PortfolioTarget, error) {
+	targets, err := s.rebalanceRepo.GetTargets(ctx, portfolio.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	threshold, err := s.rebalanceRepo.GetThreshold(ctx, portfolio.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	holdings, err := s.holdingRepo.GetByPortfolioID(ctx, portfolio.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &models.RebalanceReport{
+		PortfolioID: portfolio.ID,
+		Currency:    portfolio.Currency,
+		Threshold:   threshold,
+		Items:       make([]models.RebalanceItem, 0, len(targets)),
+	}
+	conv := newCurrencyConverter(s.marketProvider, portfolio.Currency)
+
+	if report.TotalValue, _, err = convertHoldings(ctx, conv, holdings); err != nil {
+		return nil, nil, err
+	}
+	held := make(map[uuid.UUID]models.Holding, len(holdings))
+	for _, h := range holdings {
+		held[h.SecurityID] = h
+	}
+
+	for _, t := range targets {
+		item := models.RebalanceItem{SecurityID: t.SecurityID, TargetWeight: t.Weight}
+
+		var security *models.Security
+		if h, ok := held[t.SecurityID]; ok {
+			security = h.Security
+			item.CurrentValue = h.ConvertedValue
+		} else if security, err = s.securityRepo.GetByID(ctx, t.SecurityID); err != nil {
+			return nil, nil, err
+		}
+		item.Ticker, item.Name = security.Ticker, security.Name
+
+		// цена одного контракта (бумаги) в валюте портфеля
+		currency := security.Currency
+		if currency == "" {
+			currency = security.Exchange.QuoteCurrency()
+		}
+		price, err := conv.convert(ctx, security.LastPrice.Mul(security.ContractSize()), currency)
+		if err != nil {
+			return nil, nil, err
+		}
+		item.Price = price
+
+		if report.TotalValue.IsPositive() {
+			item.CurrentWeight = item.CurrentValue.Div(report.TotalValue).Mul(hundred).Round(2)
+			item.SuggestedAmount = t.Weight.Div(hundred).Mul(report.TotalValue).Sub(item.CurrentValue).Round(2)
+		}
+		item.Drift = item.CurrentWeight.Sub(t.Weight)
+		if price.IsPositive() {
+			item.SuggestedQuantity = item.SuggestedAmount.Div(price).Truncate(0)
+		}
+		item.OverThreshold = threshold != nil && item.Drift.Abs().GreaterThan(*threshold)
+		if item.OverThreshold {
+			report.NeedsRebalance = true
+		}
+
+		report.Items = append(report.Items, item)
+	}
+
+	sort.Slice(report.Items, func(i, j int) bool {
+		return report.Items[i].Drift.Abs().GreaterThan(report.Items[j].Drift.Abs())
+	})
+	return report, targets, nil
+}
+
+// driftText список отклонений от целевых долей и сделок для их исправления
+func driftText(portfolio *models.Portfolio, report *models.RebalanceReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Доли бумаг в портфеле «%s» отклонились от целевых больше чем на %s п.п.\n\n", portfolio.Name, report.Threshold.String())
+	for _, item := range report.Items {
+		if !item.OverThreshold {
+			continue
+		}
+		action := "докупить"
+		if item.SuggestedAmount.IsNegative() {
+			action = "продать"
+		}
+		fmt.Fprintf(&b, "%s: доля %s%% при цели %s%% — %s %s шт. на %s %s\n",
+			item.Ticker, item.CurrentWeight.StringFixed(2), item.TargetWeight.StringFixed(2),
+			action, item.SuggestedQuantity.Abs().String(), item.SuggestedAmount.Abs().StringFixed(2), report.Currency)
+	}
+	return b.String()
+}
+
+func rebalanceSubject(portfolio *models.Portfolio) string {
+	return "Портфель «" + portfolio.Name + "»: пора ребалансировать"
+}
+
+// notify письмо владельцу портфеля со списком отклонений и сделками
+func (s *rebalanceService) notify(ctx context.Context, portfolio *models.Portfolio, text string) error {
+	if s.mailer == nil {
+		log.Printf("Ребалансировка портфеля %s: %s", portfolio.ID, text)
+		return nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, portfolio.UserID)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, notify.Email{
+		To:      user.Email,
+		Subject: rebalanceSubject(portfolio),
+		Text:    text,
+	})
+}
+
+func (s *rebalanceService) targets(ctx context.Context, portfolioID uuid.UUID) (*models.RebalanceTargets, error) {
+	targets, err := s.rebalanceRepo.GetTargets(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := s.rebalanceRepo.GetThreshold(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	if targets == nil {
+		targets = []models.PortfolioTarget{}
+	}
+	return &models.RebalanceTargets{PortfolioID: portfolioID, Threshold: threshold, Targets: targets}, nil
+}
+
+// portfolio портфель пользователя; чужой не отличается от несуществующего
+func (s *rebalanceService) portfolio(ctx context.Context, userID, portfolioID uuid.UUID) (*models.Portfolio, error) {
+	portfolio, err := s.portfolioRepo.GetByID(ctx, portfolioID)
+	if err != nil || portfolio.UserID != userID {
+		return nil, ErrPortfolioNotFound
+	}
+	return portfolio, nil
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/receipt"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrReceiptDisabled        = errors.New("receipt import is disabled: RECEIPT_API_TOKEN is not configured")
+	ErrReceiptRequired        = errors.New("qr or image is required")
+	ErrInvalidReceiptQR       = errors.New("invalid receipt QR code, expected t=...&s=...&fn=...&i=...&fp=...")
+	ErrReceiptNotFound        = errors.New("receipt is not in the tax service yet, try again later")
+	ErrReceiptAlreadyImported = errors.New("this receipt has already been imported")
+	ErrReceiptRateLimited     = errors.New("receipt check service rate limit exceeded, try again later")
+	ErrReceiptUnavailable     = errors.New("receipt check service is unavailable")
+)
+
+// тег транзакций, сумма и позиции которых подтверждены чеком из ФНС
+const receiptVerifiedTag = "verified-by-receipt"
+
+type ReceiptService interface {
+	// Import получает чек по строке из QR-кода (или по фото QR-кода) и создает транзакцию
+	// с позициями чека и продавцом в качестве получателя
+	Import(ctx context.Context, userID uuid.UUID, input *models.ReceiptImport, image []byte, filename string) (*models.ReceiptImportResult, error)
+}
+
+type receiptService struct {
+	receiptRepo        repository.ReceiptRepository
+	accountRepo        repository.AccountRepository
+	transactionService TransactionService
+	txManager          repository.TxManager
+	provider           receipt.Provider // nil - импорт чеков выключен
+	quota              QuotaService
+}
+
+func NewReceiptService(receiptRepo repository.ReceiptRepository, accountRepo repository.AccountRepository, transactionService TransactionService, txManager repository.TxManager, provider receipt.Provider, quota QuotaService) ReceiptService {
+	return &receiptService{
+		receiptRepo:        receiptRepo,
+		accountRepo:        accountRepo,
+		transactionService: transactionService,
+		txManager:          txManager,
+		provider:           provider,
+		quota:              quota,
+	}
+}
+
+func (s *receiptService) Import(ctx context.Context, userID uuid.UUID, input *models.ReceiptImport, image []byte, filename string) (*models.ReceiptImportResult, error) {
+	if s.provider == nil {
+		return nil, ErrReceiptDisabled
+	}
+	if input.QR == "" && len(image) == 0 {
+		return nil, ErrReceiptRequired
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, input.AccountID)
+	if err != nil || account.UserID != userID {
+		return nil, ErrAccountNotFound
+	}
+
+	var check *receipt.Receipt
+	if input.QR != "" {
+		qr, err := receipt.ParseQR(input.QR)
+		if err != nil {
+			return nil, ErrInvalidReceiptQR
+		}
+		// повторный чек отсекаем до запроса к сервису проверки
+		if err := s.checkNotImported(ctx, userID, qr.FN, qr.FD, qr.FP); err != nil {
+			return nil, err
+		}
+		check, err = s.provider.FetchByQR(ctx, qr)
+		if err != nil {
+			return nil, providerError(err)
+		}
+	} else {
+		// фото учитывается в объеме вложений, даже если чек по нему не найдется
+		if err := s.quota.UseAttachment(ctx, userID, int64(len(image))); err != nil {
+			return nil, err
+		}
+		check, err = s.provider.FetchByImage(ctx, image, filename)
+		if err != nil {
+			return nil, providerError(err)
+		}
+		if err := s.checkNotImported(ctx, userID, check.FN, check.FD, check.FP); err != nil {
+			return nil, err
+		}
+	}
+
+	create := &models.TransactionCreate{
+		AccountID:   input.AccountID,
+		CategoryID:  input.CategoryID,
+		Type:        models.TransactionTypeExpense,
+		Amount:      check.Total,
+		Description: receiptMerchant(check),
+		Date:        check.Date,
+		Location:    check.Address,
+		Tags:        []string{receiptVerifiedTag},
+	}
+	// возврат покупки (и расход продавца, например скупка) - деньги приходят покупателю
+	if check.OperationType == receipt.OperationIncomeReturn || check.OperationType == receipt.OperationOutcome {
+		create.Type = models.TransactionTypeIncome
+	}
+	for _, item := range check.Items {
+		create.Items = append(create.Items, models.TransactionItem{
+			Name:     item.Name,
+			Quantity: item.Quantity,
+			Price:    item.Price,
+			Amount:   item.Sum,
+		})
+	}
+
+	result := &models.ReceiptImportResult{}
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		tx, err := s.transactionService.Create(txCtx, userID, create)
+		if err != nil {
+			return err
+		}
+		rc := &models.Receipt{
+			UserID:        userID,
+			TransactionID: tx.ID,
+			FN:            check.FN,
+			FD:            check.FD,
+			FP:            check.FP,
+			Seller:        check.Seller,
+			SellerINN:     check.SellerINN,
+			RetailPlace:   check.RetailPlace,
+			Address:       check.Address,
+			Total:         check.Total,
+			Date:          check.Date,
+		}
+		if err := s.receiptRepo.Create(txCtx, rc); err != nil {
+			return err
+		}
+		result.Transaction, result.Receipt = tx, rc
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *receiptService) checkNotImported(ctx context.Context, userID uuid.UUID, fn, fd, fp string) error {
+	exists, err := s.receiptRepo.Exists(ctx, userID, fn, fd, fp)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrReceiptAlreadyImported
+	}
+	return nil
+}
+
+// receiptMerchant название точки продаж понятнее юрлица ("Пятерочка" вместо ООО "Агроторг")
+func receiptMerchant(check *receipt.Receipt) string {
+	if check.RetailPlace != "" {
+		return check.RetailPlace
+	}
+	return check.Seller
+}
+
+func providerError(err error) error {
+	switch {
+	case errors.Is(err, receipt.ErrReceiptNotFound):
+		return ErrReceiptNotFound
+	case errors.Is(err, receipt.ErrRateLimited):
+		return ErrReceiptRateLimited
+	}
+	log.Printf("Ошибка сервиса проверки чеков: %v", err)
+	return ErrReceiptUnavailable
+}
@@ -0,0 +1,403 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/format"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/notify"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrReportEmailDisabled        = errors.New("report emails are disabled: SMTP is not configured")
+	ErrReportSubscriptionExists   = errors.New("subscription with this frequency already exists")
+	ErrReportSubscriptionNotFound = errors.New("report subscription not found")
+	ErrInvalidReportFrequency     = errors.New("frequency must be weekly or monthly")
+	ErrInvalidReportSchedule      = errors.New("weekday must be 1-7, day_of_month 1-28 and hour 0-23")
+	ErrReportEmpty                = errors.New("at least one report section must be enabled")
+)
+
+// сколько категорий расходов показывать в письме
+const reportTopCategories = 5
+
+type ReportSubscriptionService interface {
+	Create(ctx context.Context, userID uuid.UUID, input *models.ReportSubscriptionCreate) (*models.ReportSubscription, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ReportSubscription, error)
+	Update(ctx context.Context, userID, id uuid.UUID, update *models.ReportSubscriptionUpdate) (*models.ReportSubscription, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	// SendNow отправляет отчет за прошлый период сразу; расписание не сдвигается
+	SendNow(ctx context.Context, userID, id uuid.UUID) error
+	// SendDue рассылает отчеты, время которых наступило (фоновая задача)
+	SendDue(ctx context.Context) (*models.ReportSendResult, error)
+}
+
+type reportSubscriptionService struct {
+	subRepo          repository.ReportSubscriptionRepository
+	userRepo         repository.UserRepository
+	analyticsService AnalyticsService
+	budgetService    BudgetService
+	portfolioService PortfolioService
+	mailer           notify.Mailer // nil - рассылка выключена
+}
+
+func NewReportSubscriptionService(subRepo repository.ReportSubscriptionRepository, userRepo repository.UserRepository, analyticsService AnalyticsService, budgetService BudgetService, portfolioService PortfolioService, mailer notify.Mailer) ReportSubscriptionService {
+	return &reportSubscriptionService{
+		subRepo:          subRepo,
+		userRepo:         userRepo,
+		analyticsService: analyticsService,
+		budgetService:    budgetService,
+		portfolioService: portfolioService,
+		mailer:           mailer,
+	}
+}
+
+func (s *reportSubscriptionService) Create(ctx context.Context, userID uuid.UUID, input *models.ReportSubscriptionCreate) (*models.ReportSubscription, error) {
+	if s.mailer == nil {
+		return nil, ErrReportEmailDisabled
+	}
+	if input.Frequency != models.ReportWeekly && input.Frequency != models.ReportMonthly {
+		return nil, ErrInvalidReportFrequency
+	}
+
+	existing, err := s.subRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range existing {
+		if sub.Frequency == input.Frequency {
+			return nil, ErrReportSubscriptionExists
+		}
+	}
+
+	sub := &models.ReportSubscription{
+		UserID:           userID,
+		Frequency:        input.Frequency,
+		IncludeSummary:   input.IncludeSummary == nil || *input.IncludeSummary,
+		IncludeBudgets:   input.IncludeBudgets == nil || *input.IncludeBudgets,
+		IncludePortfolio: input.IncludePortfolio == nil || *input.IncludePortfolio,
+		Weekday:          input.Weekday,
+		DayOfMonth:       input.DayOfMonth,
+		Hour:             9,
+		IsActive:         true,
+	}
+	if sub.Weekday == 0 {
+		sub.Weekday = 1
+	}
+	if sub.DayOfMonth == 0 {
+		sub.DayOfMonth = 1
+	}
+	if input.Hour != nil {
+		sub.Hour = *input.Hour
+	}
+	if err := validateReportSubscription(sub); err != nil {
+		return nil, err
+	}
+
+	loc, err := s.userLocation(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sub.NextSendAt = nextReportSend(sub, loc, time.Now())
+
+	if err := s.subRepo.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *reportSubscriptionService) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ReportSubscription, error) {
+	return s.subRepo.GetByUserID(ctx, userID)
+}
+
+func (s *reportSubscriptionService) get(ctx context.Context, userID, id uuid.UUID) (*models.ReportSubscription, error) {
+	sub, err := s.subRepo.GetByID(ctx, id)
+	if err != nil || sub.UserID != userID {
+		return nil, ErrReportSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+func (s *reportSubscriptionService) Update(ctx context.Context, userID, id uuid.UUID, update *models.ReportSubscriptionUpdate) (*models.ReportSubscription, error) {
+	sub, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.IncludeSummary != nil {
+		sub.IncludeSummary = *update.IncludeSummary
+	}
+	if update.IncludeBudgets != nil {
+		sub.IncludeBudgets = *update.IncludeBudgets
+	}
+	if update.IncludePortfolio != nil {
+		sub.IncludePortfolio = *update.IncludePortfolio
+	}
+	if update.Weekday != nil {
+		sub.Weekday = *update.Weekday
+	}
+	if update.DayOfMonth != nil {
+		sub.DayOfMonth = *update.DayOfMonth
+	}
+	if update.Hour != nil {
+		sub.Hour = *update.Hour
+	}
+	if update.IsActive != nil {
+		sub.IsActive = *update.IsActive
+	}
+	if err := validateReportSubscription(sub); err != nil {
+		return nil, err
+	}
+
+	// расписание могло поменяться - считаем следующую отправку заново
+	loc, err := s.userLocation(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sub.NextSendAt = nextReportSend(sub, loc, time.Now())
+
+	if err := s.subRepo.Update(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *reportSubscriptionService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.get(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.subRepo.Delete(ctx, id)
+}
+
+func (s *reportSubscriptionService) SendNow(ctx context.Context, userID, id uuid.UUID) error {
+	if s.mailer == nil {
+		return ErrReportEmailDisabled
+	}
+	sub, err := s.get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	return s.send(ctx, sub, time.Now())
+}
+
+func (s *reportSubscriptionService) SendDue(ctx context.Context) (*models.ReportSendResult, error) {
+	result := &models.ReportSendResult{}
+	if s.mailer == nil {
+		return result, nil
+	}
+
+	now := time.Now()
+	subs, err := s.subRepo.GetDue(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range subs {
+		sub := &subs[i]
+		loc, err := s.userLocation(ctx, sub.UserID)
+		if err != nil {
+			continue
+		}
+
+		lastError := ""
+		if err := s.send(ctx, sub, sub.NextSendAt); err != nil {
+			log.Printf("Не удалось отправить отчет %s пользователю %s: %v", sub.Frequency, sub.UserID, err)
+			lastError = err.Error()
+			result.Failed++
+		} else {
+			result.Sent++
+		}
+
+		// неудачную отправку не повторяем до следующего периода, чтобы не слать письмо каждые несколько минут
+		if err := s.subRepo.SetSent(ctx, sub.ID, now, nextReportSend(sub, loc, now), lastError); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// send собирает отчет за период, закончившийся к моменту at, и отправляет его
+func (s *reportSubscriptionService) send(ctx context.Context, sub *models.ReportSubscription, at time.Time) error {
+	user, err := s.userRepo.GetByID(ctx, sub.UserID)
+	if err != nil {
+		return err
+	}
+	loc := userLocation(user)
+	start, end := reportPeriod(sub.Frequency, at.In(loc))
+	lf := format.Get(user.Locale)
+
+	var b strings.Builder
+	title, subject := "Недельный отчет", "FinTracker: отчет за неделю"
+	if sub.Frequency == models.ReportMonthly {
+		title, subject = "Месячный отчет", "FinTracker: отчет за месяц"
+	}
+	fmt.Fprintf(&b, "%s за %s - %s\n", title, lf.Date(start), lf.Date(end.AddDate(0, 0, -1)))
+
+	if sub.IncludeSummary {
+		period := models.PeriodWeek
+		if sub.Frequency == models.ReportMonthly {
+			period = models.PeriodMonth
+		}
+		periodEnd := end.Add(-time.Second)
+		summary, err := s.analyticsService.GetFinancialSummary(ctx, user.ID, period, &start, &periodEnd, "")
+		if err != nil {
+			return err
+		}
+		writeSummarySection(&b, lf, summary)
+	}
+	if sub.IncludeBudgets {
+		budgets, err := s.budgetService.GetSummary(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		writeBudgetSection(&b, lf, budgets)
+	}
+	if sub.IncludePortfolio {
+		portfolios, err := s.portfolioService.GetByUserID(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		writePortfolioSection(&b, lf, portfolios)
+	}
+
+	return s.mailer.Send(ctx, notify.Email{
+		To:      user.Email,
+		Subject: subject,
+		Text:    b.String(),
+	})
+}
+
+func (s *reportSubscriptionService) userLocation(ctx context.Context, userID uuid.UUID) (*time.Location, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return userLocation(user), nil
+}
+
+// userLocation часовой пояс из профиля; неизвестный - UTC
+func userLocation(user *models.User) *time.Location {
+	if user.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// localNow текущее время в часовом поясе пользователя: по нему считаются границы дня, месяца и года
+func localNow(user *models.User) time.Time {
+	return time.Now().In(userLocation(user))
+}
+
+func validateReportSubscription(sub *models.ReportSubscription) error {
+	if sub.Weekday < 1 || sub.Weekday > 7 || sub.DayOfMonth < 1 || sub.DayOfMonth > 28 || sub.Hour < 0 || sub.Hour > 23 {
+		return ErrInvalidReportSchedule
+	}
+	if !sub.IncludeSummary && !sub.IncludeBudgets && !sub.IncludePortfolio {
+		return ErrReportEmpty
+	}
+	return nil
+}
+
+// nextReportSend ближайший момент отправки строго после after в часовом поясе пользователя
+func nextReportSend(sub *models.ReportSubscription, loc *time.Location, after time.Time) time.Time {
+	local := after.In(loc)
+
+	if sub.Frequency == models.ReportMonthly {
+		next := time.Date(local.Year(), local.Month(), sub.DayOfMonth, sub.Hour, 0, 0, 0, loc)
+		if !next.After(local) {
+			next = time.Date(local.Year(), local.Month()+1, sub.DayOfMonth, sub.Hour, 0, 0, 0, loc)
+		}
+		return next
+	}
+
+	weekday := int(local.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	next := time.Date(local.Year(), local.Month(), local.Day()+sub.Weekday-weekday, sub.Hour, 0, 0, 0, loc)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
+
+// reportPeriod прошлая полная неделя (пн-вс) или прошлый календарный месяц относительно at; конец не включается
+func reportPeriod(frequency models.ReportFrequency, at time.Time) (time.Time, time.Time) {
+	if frequency == models.ReportMonthly {
+		end := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, at.Location())
+		return end.AddDate(0, -1, 0), end
+	}
+
+	weekday := int(at.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	end := time.Date(at.Year(), at.Month(), at.Day()-weekday+1, 0, 0, 0, 0, at.Location())
+	return end.AddDate(0, 0, -7), end
+}
+
+func writeSummarySection(b *strings.Builder, lf format.Locale, summary *models.FinancialSummary) {
+	cur := summary.Currency
+	b.WriteString("\nДоходы и расходы\n")
+	fmt.Fprintf(b, "  Доходы: %s\n", lf.Money(summary.TotalIncome, cur))
+	fmt.Fprintf(b, "  Расходы: %s\n", lf.Money(summary.TotalExpenses, cur))
+	fmt.Fprintf(b, "  Сбережения: %s (%s)\n", lf.Money(summary.NetSavings, cur), lf.Percent(summary.SavingsRate, 1))
+	fmt.Fprintf(b, "  Расходы к прошлому периоду: %s\n", lf.Percent(summary.ExpenseChangePct, 1))
+
+	if len(summary.ExpenseByCategory) > 0 {
+		b.WriteString("  Больше всего потрачено:\n")
+		for i, c := range summary.ExpenseByCategory {
+			if i == reportTopCategories {
+				break
+			}
+			fmt.Fprintf(b, "    %s: %s\n", c.CategoryName, lf.Money(c.Amount, cur))
+		}
+	}
+}
+
+func writeBudgetSection(b *strings.Builder, lf format.Locale, summary *models.BudgetSummary) {
+	b.WriteString("\nБюджеты\n")
+	if len(summary.Budgets) == 0 {
+		b.WriteString("  Активных бюджетов нет\n")
+		return
+	}
+	for _, budget := range summary.Budgets {
+		mark := ""
+		if budget.Spent.GreaterThan(budget.Amount) {
+			mark = " - превышен"
+		}
+		fmt.Fprintf(b, "  %s: %s из %s (%s)%s\n",
+			budget.Name, lf.Money(budget.Spent, budget.Currency), lf.Money(budget.Amount, budget.Currency),
+			lf.Percent(decimal.NewFromFloat(budget.SpentPercent), 0), mark)
+	}
+	if summary.OverBudgetCount > 0 {
+		fmt.Fprintf(b, "  Превышено бюджетов: %d\n", summary.OverBudgetCount)
+	}
+}
+
+func writePortfolioSection(b *strings.Builder, lf format.Locale, portfolios []models.Portfolio) {
+	b.WriteString("\nИнвестиции\n")
+	active := 0
+	for _, p := range portfolios {
+		if !p.IsActive {
+			continue
+		}
+		active++
+		fmt.Fprintf(b, "  %s: %s, прибыль %s (%s)\n",
+			p.Name, lf.Money(p.TotalValue, p.Currency), lf.Money(p.TotalProfit, p.Currency), lf.Percent(p.ProfitPercent, 2))
+	}
+	if active == 0 {
+		b.WriteString("  Портфелей нет\n")
+	}
+}
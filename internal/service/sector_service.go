@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSectorMappingNotFound        = errors.New("sector mapping not found")
+	ErrSecurityTypeOverrideNotFound = errors.New("security type override not found")
+)
+
+// сколько бумаг без сектора обрабатываем за один проход
+const sectorBackfillBatch = 500
+
+type SectorService interface {
+	// Enrich заполняет сектор и отрасль бумаги из справочников (без сетевых запросов) и применяет
+	// заданный администратором тип; возвращает true если поменялся сектор или отрасль
+	Enrich(ctx context.Context, security *models.Security) bool
+	Backfill(ctx context.Context) (*models.SectorBackfillResult, error)
+	ListMappings(ctx context.Context, exchange *models.Exchange) ([]models.SectorMapping, error)
+	UpsertMapping(ctx context.Context, input *models.SectorMappingUpsert) (*models.SectorMapping, error)
+	DeleteMapping(ctx context.Context, ticker string, exchange models.Exchange) error
+
+	ListTypeOverrides(ctx context.Context, exchange *models.Exchange) ([]models.SecurityTypeOverride, error)
+	// UpsertTypeOverride задает тип бумаги (и сектор, если указан) и сразу применяет их к сохраненным бумагам
+	UpsertTypeOverride(ctx context.Context, input *models.SecurityTypeOverrideUpsert) (*models.SecurityTypeOverride, error)
+	// DeleteTypeOverride убирает переопределение; сохраненные бумаги сохраняют тип до следующего ответа провайдера
+	DeleteTypeOverride(ctx context.Context, ticker string, exchange models.Exchange) error
+}
+
+type sectorService struct {
+	sectorRepo     repository.SectorMappingRepository
+	typeRepo       repository.SecurityTypeOverrideRepository
+	securityRepo   repository.SecurityRepository
+	marketProvider *market.MultiProvider
+}
+
+func NewSectorService(sectorRepo repository.SectorMappingRepository, typeRepo repository.SecurityTypeOverrideRepository, securityRepo repository.SecurityRepository, marketProvider *market.MultiProvider) SectorService {
+	return &sectorService{
+		sectorRepo:     sectorRepo,
+		typeRepo:       typeRepo,
+		securityRepo:   securityRepo,
+		marketProvider: marketProvider,
+	}
+}
+
+func (s *sectorService) Enrich(ctx context.Context, security *models.Security) bool {
+	// тип от администратора главнее классификации провайдера
+	if override, err := s.typeRepo.Get(ctx, security.Ticker, security.Exchange); err == nil {
+		security.Type = override.Type
+	}
+
+	mapping, err := s.sectorRepo.Get(ctx, security.Ticker, security.Exchange)
+	if err == nil {
+		// ручная привязка главнее того, что пришло от провайдера
+		if mapping.Source == models.SectorSourceManual || security.Sector == "" {
+			return applySector(security, mapping.Sector, mapping.Industry)
+		}
+		return false
+	}
+
+	if security.Sector != "" {
+		return false
+	}
+
+	if info, ok := market.BundledSector(security); ok {
+		return applySector(security, info.Sector, info.Industry)
+	}
+	return false
+}
+
+// enrichFromProvider спрашивает сектор у рыночного провайдера (медленно, только в фоне)
+func (s *sectorService) enrichFromProvider(ctx context.Context, security *models.Security) bool {
+	info, err := s.marketProvider.GetSectorInfo(ctx, security.Ticker, security.Exchange)
+	if err != nil || info == nil || info.Sector == "" {
+		return false
+	}
+
+	// запоминаем ответ провайдера, чтобы не ходить за ним повторно
+	if err := s.sectorRepo.Upsert(ctx, &models.SectorMapping{
+		Ticker:   security.Ticker,
+		Exchange: security.Exchange,
+		Sector:   info.Sector,
+		Industry: info.Industry,
+		Source:   models.SectorSourceProvider,
+	}); err != nil {
+		log.Printf("не удалось сохранить сектор %s: %v", security.Ticker, err)
+	}
+
+	return applySector(security, info.Sector, info.Industry)
+}
+
+func (s *sectorService) Backfill(ctx context.Context) (*models.SectorBackfillResult, error) {
+	securities, err := s.securityRepo.GetWithoutSector(ctx, sectorBackfillBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.SectorBackfillResult{Unresolved: []string{}}
+	var unresolved []uuid.UUID
+	for i := range securities {
+		sec := &securities[i]
+		result.Processed++
+
+		if !s.Enrich(ctx, sec) && !s.enrichFromProvider(ctx, sec) {
+			result.Unresolved = append(result.Unresolved, sec.Ticker)
+			unresolved = append(unresolved, sec.ID)
+			continue
+		}
+		if _, err := s.securityRepo.UpdateSector(ctx, sec.Ticker, sec.Exchange, sec.Sector, sec.Industry); err != nil {
+			return result, err
+		}
+		result.Updated++
+	}
+
+	// нерешенные уходят в конец очереди, иначе каждый проход упирается в одни и те же бумаги
+	if err := s.securityRepo.MarkSectorChecked(ctx, unresolved); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (s *sectorService) ListMappings(ctx context.Context, exchange *models.Exchange) ([]models.SectorMapping, error) {
+	return s.sectorRepo.List(ctx, exchange)
+}
+
+func (s *sectorService) UpsertMapping(ctx context.Context, input *models.SectorMappingUpsert) (*models.SectorMapping, error) {
+	mapping := &models.SectorMapping{
+		Ticker:   strings.ToUpper(strings.TrimSpace(input.Ticker)),
+		Exchange: input.Exchange,
+		Sector:   strings.TrimSpace(input.Sector),
+		Industry: strings.TrimSpace(input.Industry),
+		Source:   models.SectorSourceManual,
+	}
+
+	if err := s.sectorRepo.Upsert(ctx, mapping); err != nil {
+		return nil, err
+	}
+
+	// сразу применяем к уже сохраненным бумагам
+	if _, err := s.securityRepo.UpdateSector(ctx, mapping.Ticker, mapping.Exchange, mapping.Sector, mapping.Industry); err != nil {
+		return nil, err
+	}
+
+	return mapping, nil
+}
+
+func (s *sectorService) DeleteMapping(ctx context.Context, ticker string, exchange models.Exchange) error {
+	ticker = strings.ToUpper(ticker)
+	if _, err := s.sectorRepo.Get(ctx, ticker, exchange); err != nil {
+		return ErrSectorMappingNotFound
+	}
+	return s.sectorRepo.Delete(ctx, ticker, exchange)
+}
+
+func (s *sectorService) ListTypeOverrides(ctx context.Context, exchange *models.Exchange) ([]models.SecurityTypeOverride, error) {
+	overrides, err := s.typeRepo.List(ctx, exchange)
+	if err != nil {
+		return nil, err
+	}
+	if overrides == nil {
+		overrides = []models.SecurityTypeOverride{}
+	}
+	return overrides, nil
+}
+
+func (s *sectorService) UpsertTypeOverride(ctx context.Context, input *models.SecurityTypeOverrideUpsert) (*models.SecurityTypeOverride, error) {
+	override := &models.SecurityTypeOverride{
+		Ticker:   strings.ToUpper(strings.TrimSpace(input.Ticker)),
+		Exchange: input.Exchange,
+		Type:     input.Type,
+	}
+	if err := s.typeRepo.Upsert(ctx, override); err != nil {
+		return nil, err
+	}
+	if _, err := s.securityRepo.UpdateType(ctx, override.Ticker, override.Exchange, override.Type); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(input.Sector) != "" {
+		if _, err := s.UpsertMapping(ctx, &models.SectorMappingUpsert{
+			Ticker:   override.Ticker,
+			Exchange: override.Exchange,
+			Sector:   input.Sector,
+			Industry: input.Industry,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return override, nil
+}
+
+func (s *sectorService) DeleteTypeOverride(ctx context.Context, ticker string, exchange models.Exchange) error {
+	deleted, err := s.typeRepo.Delete(ctx, strings.ToUpper(ticker), exchange)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSecurityTypeOverrideNotFound
+	}
+	return nil
+}
+
+func applySector(security *models.Security, sector, industry string) bool {
+	if security.Sector == sector && security.Industry == industry {
+		return false
+	}
+	security.Sector = sector
+	security.Industry = industry
+	return true
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrSecurityMergeSame     = errors.New("source and target securities must differ")
+	ErrSecurityMergeManual   = errors.New("manual securities cannot be merged")
+	ErrSecurityMergeMismatch = errors.New("securities with different type or currency cannot be merged")
+)
+
+// securityKey ключ одной бумаги в выдаче провайдеров: ISIN и биржа, без ISIN - тикер и биржа
+func securityKey(security *models.Security) string {
+	if security.ISIN != "" {
+		return "isin:" + strings.ToUpper(security.ISIN) + ":" + string(security.Exchange)
+	}
+	return "ticker:" + strings.ToUpper(security.Ticker) + ":" + string(security.Exchange)
+}
+
+// dedupSecurities убирает повторы одной бумаги из выдачи провайдеров, первая запись остается
+func dedupSecurities(securities []models.Security) []models.Security {
+	seen := make(map[string]bool, len(securities))
+	unique := securities[:0]
+	for _, security := range securities {
+		key := securityKey(&security)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, security)
+	}
+	return unique
+}
+
+// saveSearchResult сохраняет бумагу из выдачи провайдера. если на бирже уже есть бумага с тем же ISIN
+// под другим тикером (тикер сменился), новая строка не заводится: обновляется название существующей
+func (s *investmentService) saveSearchResult(ctx context.Context, security *models.Security) {
+	if security.ISIN != "" {
+		existing, err := s.securityRepo.GetByISIN(ctx, security.ISIN, security.Exchange)
+		if err != nil {
+			log.Printf("не удалось проверить дубли бумаги %s: %v", security.ISIN, err)
+		}
+		if existing != nil && existing.Ticker != security.Ticker {
+			if security.Name != "" && security.Name != existing.Name {
+				existing.Name = security.Name
+				existing.ShortName = security.ShortName
+				if err := s.securityRepo.Update(ctx, existing.ID, existing); err != nil {
+					log.Printf("не удалось обновить название бумаги %s: %v", existing.ID, err)
+				}
+			}
+			*security = *existing
+			return
+		}
+	}
+	s.securityRepo.Create(ctx, security)
+}
+
+func (s *investmentService) FindDuplicateSecurities(ctx context.Context) ([]models.SecurityDuplicateGroup, error) {
+	duplicates, err := s.securityRepo.FindDuplicates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []models.SecurityDuplicateGroup{}
+	for _, d := range duplicates {
+		if n := len(groups); n == 0 || groups[n-1].Reason != d.Reason || groups[n-1].Key != d.Key {
+			groups = append(groups, models.SecurityDuplicateGroup{Reason: d.Reason, Key: d.Key})
+		}
+		group := &groups[len(groups)-1]
+		group.Securities = append(group.Securities, d)
+	}
+
+	for i := range groups {
+		// бумаги отсортированы по дате заведения, поэтому при равенстве остается более ранняя
+		best := groups[i].Securities[0]
+		for _, d := range groups[i].Securities[1:] {
+			if duplicateRank(d) > duplicateRank(best) {
+				best = d
+			}
+		}
+		groups[i].SuggestedTargetID = best.ID
+	}
+	return groups, nil
+}
+
+// duplicateRank чем больше, тем лучше бумага подходит на роль основной
+func duplicateRank(d models.SecurityDuplicate) int64 {
+	rank := (d.Transactions + d.Holdings) * 2
+	if d.IsActive {
+		rank++
+	}
+	return rank
+}
+
+func (s *investmentService) MergeSecurities(ctx context.Context, input *models.SecurityMergeRequest) (*models.SecurityMergeResult, error) {
+	if input.SourceID == input.TargetID {
+		return nil, ErrSecurityMergeSame
+	}
+
+	source, err := s.securityRepo.GetByID(ctx, input.SourceID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSecurityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.securityRepo.GetByID(ctx, input.TargetID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSecurityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if source.IsManual() || target.IsManual() {
+		return nil, ErrSecurityMergeManual
+	}
+	if source.Type != target.Type || source.Currency != target.Currency {
+		return nil, ErrSecurityMergeMismatch
+	}
+
+	var result *models.SecurityMergeResult
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		var err error
+		result, err = s.securityRepo.Merge(txCtx, source.ID, target.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("бумага %s %s (%s) объединена с %s %s (%s): позиций %d, операций %d",
+		source.Exchange, source.Ticker, source.ID, target.Exchange, target.Ticker, target.ID,
+		result.Holdings, result.Transactions)
+	return result, nil
+}
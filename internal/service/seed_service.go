@@ -0,0 +1,453 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidSeed = errors.New("invalid seed description")
+
+// ограничения описания: генератор для разработки и демо, а не нагрузочных тестов
+const (
+	defaultSeedMonths = 6
+	maxSeedMonths     = 36
+	maxSeedUsers      = 100
+)
+
+// SeedService наполнение базы тестовыми данными по декларативному описанию. в отличие от миграций
+// не запускается сам: только из ftctl seed, по SEED_FILE при старте сервера и для демо-режима
+type SeedService interface {
+	// Apply заводит пользователей из описания вместе с данными; существующие (по email) пропускаются целиком
+	Apply(ctx context.Context, spec *models.SeedSpec) (*models.SeedResult, error)
+}
+
+type seedService struct {
+	userRepo           repository.UserRepository
+	categoryRepo       repository.CategoryRepository
+	authService        AuthService
+	accountService     AccountService
+	transactionService TransactionService
+	portfolioService   PortfolioService
+	investmentService  InvestmentService
+}
+
+func NewSeedService(
+	userRepo repository.UserRepository,
+	categoryRepo repository.CategoryRepository,
+	authService AuthService,
+	accountService AccountService,
+	transactionService TransactionService,
+	portfolioService PortfolioService,
+	investmentService InvestmentService,
+) SeedService {
+	return &seedService{
+		userRepo:           userRepo,
+		categoryRepo:       categoryRepo,
+		authService:        authService,
+		accountService:     accountService,
+		transactionService: transactionService,
+		portfolioService:   portfolioService,
+		investmentService:  investmentService,
+	}
+}
+
+// LoadSeedSpec читает описание тестовых данных из JSON файла
+func LoadSeedSpec(path string) (*models.SeedSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec models.SeedSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSeed, err)
+	}
+	return &spec, nil
+}
+
+func (s *seedService) Apply(ctx context.Context, spec *models.SeedSpec) (*models.SeedResult, error) {
+	categories, err := s.categoryRepo.GetSystemCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]uuid.UUID, len(categories))
+	for _, c := range categories {
+		byName[c.Name] = c.ID
+	}
+	if err := validateSeedSpec(spec, byName); err != nil {
+		return nil, err
+	}
+
+	months := spec.Months
+	if months == 0 {
+		months = defaultSeedMonths
+	}
+	// одинаковые данные при каждом запуске с тем же описанием
+	rnd := mathrand.New(mathrand.NewSource(spec.RandomSeed))
+
+	result := &models.SeedResult{Users: make([]models.SeedUserResult, 0, len(spec.Users))}
+	for i := range spec.Users {
+		u := &spec.Users[i]
+		email := strings.ToLower(strings.TrimSpace(u.Email))
+		if existing, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+			result.Users = append(result.Users, models.SeedUserResult{ID: existing.ID, Email: email, Skipped: true})
+			continue
+		}
+
+		res, err := s.applyUser(ctx, u, email, months, byName, rnd)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", email, err)
+		}
+		result.Users = append(result.Users, *res)
+	}
+	return result, nil
+}
+
+func (s *seedService) applyUser(ctx context.Context, u *models.SeedUser, email string, months int, categories map[string]uuid.UUID, rnd *mathrand.Rand) (*models.SeedUserResult, error) {
+	password := u.Password
+	if password == "" {
+		// пароль никому не нужен: вход через /auth/demo или после сброса пароля
+		secret := make([]byte, 24)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		password = base64.RawURLEncoding.EncodeToString(secret)
+	}
+	currency := seedCurrency(u.Currency, "RUB")
+
+	user, err := s.authService.CreateUser(ctx, &models.UserRegistration{
+		Email:           email,
+		Password:        password,
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		DefaultCurrency: currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &models.SeedUserResult{ID: user.ID, Email: email, Password: u.Password}
+
+	accounts := make(map[string]uuid.UUID, len(u.Accounts))
+	for i, a := range u.Accounts {
+		account, err := s.accountService.Create(ctx, user.ID, &models.AccountCreate{
+			Name: a.Name, Type: a.Type, Currency: seedCurrency(a.Currency, currency),
+			InitialBalance: a.Balance, Institution: a.Institution,
+		})
+		if err != nil {
+			return nil, err
+		}
+		accounts[a.Name] = account.ID
+		// без явного счета операции идут на первый
+		if i == 0 {
+			accounts[""] = account.ID
+		}
+	}
+
+	today := truncateDay(time.Now())
+	create := func(accountID uuid.UUID, category string, txType models.TransactionType, amount decimal.Decimal, description string, date time.Time) error {
+		if date.After(today) {
+			return nil
+		}
+		_, err := s.transactionService.Create(ctx, user.ID, &models.TransactionCreate{
+			AccountID: accountID, CategoryID: categories[category], Type: txType,
+			Amount: amount, Description: description, Date: date,
+		})
+		if err == nil {
+			res.Transactions++
+		}
+		return err
+	}
+
+	for m := months - 1; m >= 0; m-- {
+		month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -m, 0)
+		days := month.AddDate(0, 1, -1).Day()
+
+		for _, e := range u.Monthly {
+			txType := e.Type
+			if txType == "" {
+				txType = models.TransactionTypeExpense
+			}
+			// 31-е в коротком месяце - последний день
+			day := min(e.Day, days)
+			if err := create(accounts[e.Account], e.Category, txType, e.Amount, e.Description, month.AddDate(0, 0, day-1)); err != nil {
+				return nil, err
+			}
+		}
+		for _, e := range u.Daily {
+			span := e.Max.Sub(e.Min).IntPart()
+			for i := 0; i < e.PerMonth; i++ {
+				account := accounts[""]
+				if len(e.Accounts) > 0 {
+					account = accounts[e.Accounts[i%len(e.Accounts)]]
+				}
+				amount := e.Min.Add(decimal.NewFromInt(rnd.Int63n(span + 1)))
+				if err := create(account, e.Category, models.TransactionTypeExpense, amount, e.Description, month.AddDate(0, 0, rnd.Intn(days))); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, p := range u.Portfolios {
+		portfolio, err := s.portfolioService.Create(ctx, user.ID, &models.PortfolioCreate{
+			Name: p.Name, Currency: seedCurrency(p.Currency, currency), BrokerName: p.Broker,
+		})
+		if err != nil {
+			return nil, err
+		}
+		res.Portfolios++
+
+		// без связи с биржей бумаги не найдутся: портфель остается с тем, что удалось провести
+		for _, t := range p.Trades {
+			exchange := t.Exchange
+			if exchange == "" {
+				exchange = models.ExchangeMOEX
+			}
+			_, err := s.investmentService.AddTransaction(ctx, &models.InvestmentTransactionCreate{
+				PortfolioID: portfolio.ID,
+				Ticker:      t.Ticker,
+				Exchange:    exchange,
+				Type:        models.InvestmentTransactionTypeBuy,
+				Date:        today.AddDate(0, -t.MonthsAgo, 0),
+				Quantity:    t.Quantity,
+				Price:       t.Price,
+				Commission:  t.Commission,
+			})
+			if err != nil {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s / %s: %v", p.Name, t.Ticker, err))
+				continue
+			}
+			res.Trades++
+		}
+	}
+	return res, nil
+}
+
+// validateSeedSpec проверяет описание целиком до записи, чтобы не оставлять наполовину заполненных пользователей
+func validateSeedSpec(spec *models.SeedSpec, categories map[string]uuid.UUID) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidSeed, fmt.Sprintf(format, args...))
+	}
+
+	if spec.Months < 0 || spec.Months > maxSeedMonths {
+		return invalid("months must be between 1 and %d", maxSeedMonths)
+	}
+	if len(spec.Users) == 0 || len(spec.Users) > maxSeedUsers {
+		return invalid("from 1 to %d users expected", maxSeedUsers)
+	}
+
+	emails := make(map[string]bool, len(spec.Users))
+	for _, u := range spec.Users {
+		email := strings.ToLower(strings.TrimSpace(u.Email))
+		if email == "" || emails[email] {
+			return invalid("user email is empty or repeated: %q", u.Email)
+		}
+		emails[email] = true
+		if u.Password != "" && len(u.Password) < minPasswordLength {
+			return invalid("%s: password is shorter than %d characters", email, minPasswordLength)
+		}
+
+		accounts := make(map[string]bool, len(u.Accounts))
+		for _, a := range u.Accounts {
+			if a.Name == "" || accounts[a.Name] {
+				return invalid("%s: account name is empty or repeated: %q", email, a.Name)
+			}
+			accounts[a.Name] = true
+		}
+		if len(u.Accounts) == 0 && (len(u.Monthly) > 0 || len(u.Daily) > 0) {
+			return invalid("%s: transactions require at least one account", email)
+		}
+
+		category := func(name string) error {
+			if _, ok := categories[name]; !ok {
+				return invalid("%s: unknown category %q", email, name)
+			}
+			return nil
+		}
+		account := func(name string) error {
+			if name != "" && !accounts[name] {
+				return invalid("%s: unknown account %q", email, name)
+			}
+			return nil
+		}
+
+		for _, e := range u.Monthly {
+			if err := category(e.Category); err != nil {
+				return err
+			}
+			if err := account(e.Account); err != nil {
+				return err
+			}
+			if e.Day < 1 || e.Day > 31 || !e.Amount.IsPositive() {
+				return invalid("%s: %q needs a day 1-31 and a positive amount", email, e.Description)
+			}
+			if e.Type != "" && e.Type != models.TransactionTypeIncome && e.Type != models.TransactionTypeExpense {
+				return invalid("%s: %q type must be income or expense", email, e.Description)
+			}
+		}
+		for _, e := range u.Daily {
+			if err := category(e.Category); err != nil {
+				return err
+			}
+			for _, name := range e.Accounts {
+				if err := account(name); err != nil {
+					return err
+				}
+			}
+			if e.PerMonth < 0 || !e.Min.IsPositive() || e.Max.LessThan(e.Min) {
+				return invalid("%s: %q needs per_month >= 0 and 0 < min <= max", email, e.Description)
+			}
+		}
+		for _, p := range u.Portfolios {
+			if p.Name == "" {
+				return invalid("%s: portfolio name is required", email)
+			}
+			for _, t := range p.Trades {
+				if t.Ticker == "" || !t.Quantity.IsPositive() || !t.Price.IsPositive() || t.MonthsAgo < 0 {
+					return invalid("%s: trade %q needs a ticker, positive quantity and price", email, t.Ticker)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func seedCurrency(currency, fallback string) string {
+	if currency == "" {
+		return fallback
+	}
+	return strings.ToUpper(currency)
+}
+
+// типовой бюджет, от которого генератор строит пользователей: суммы масштабируются под доход
+var (
+	seedAccounts = []models.SeedAccount{
+		{Name: "Дебетовая карта", Type: models.AccountTypeBank, Balance: decimal.NewFromInt(120000), Institution: "Демо-банк"},
+		{Name: "Наличные", Type: models.AccountTypeCash, Balance: decimal.NewFromInt(8000)},
+	}
+
+	seedMonthly = []models.SeedMonthly{
+		{Category: "Зарплата", Description: "Зарплата", Type: models.TransactionTypeIncome, Day: 5, Amount: decimal.NewFromInt(95000)},
+		{Category: "Зарплата", Description: "Аванс", Type: models.TransactionTypeIncome, Day: 20, Amount: decimal.NewFromInt(70000)},
+		{Category: "Жилье", Description: "Аренда квартиры", Day: 1, Amount: decimal.NewFromInt(45000)},
+		{Category: "Коммунальные услуги", Description: "ЖКУ", Day: 10, Amount: decimal.NewFromInt(6200)},
+		{Category: "Связь", Description: "Мобильная связь и интернет", Day: 12, Amount: decimal.NewFromInt(1100)},
+		{Category: "Подписки", Description: "Музыка и кино", Day: 15, Amount: decimal.NewFromInt(799)},
+		{Category: "Здоровье", Description: "Спортзал", Day: 3, Amount: decimal.NewFromInt(3500)},
+	}
+
+	seedDaily = []models.SeedDaily{
+		{Category: "Продукты", Description: "Супермаркет", PerMonth: 9, Min: decimal.NewFromInt(1200), Max: decimal.NewFromInt(5500)},
+		{Category: "Рестораны", Description: "Кафе", PerMonth: 4, Min: decimal.NewFromInt(600), Max: decimal.NewFromInt(3200)},
+		// каждая третья поездка за наличные
+		{Category: "Транспорт", Description: "Такси и метро", PerMonth: 8, Min: decimal.NewFromInt(150), Max: decimal.NewFromInt(900),
+			Accounts: []string{"Наличные", "Дебетовая карта", "Дебетовая карта"}},
+		{Category: "Покупки", Description: "Маркетплейс", PerMonth: 2, Min: decimal.NewFromInt(900), Max: decimal.NewFromInt(7000)},
+		{Category: "Развлечения", Description: "Кино, концерты", PerMonth: 1, Min: decimal.NewFromInt(800), Max: decimal.NewFromInt(4000)},
+	}
+
+	// портфели на MOEX: акции на брокерском счете и фонды на ИИС
+	seedPortfolios = []models.SeedPortfolio{
+		{Name: "Брокерский счет", Broker: "Демо-брокер", Trades: []models.SeedTrade{
+			{Ticker: "SBER", MonthsAgo: 5, Quantity: decimal.NewFromInt(100), Price: decimal.RequireFromString("268.40")},
+			{Ticker: "LKOH", MonthsAgo: 5, Quantity: decimal.NewFromInt(5), Price: decimal.RequireFromString("6950")},
+			{Ticker: "YDEX", MonthsAgo: 4, Quantity: decimal.NewFromInt(10), Price: decimal.RequireFromString("3900")},
+			{Ticker: "TMOS", MonthsAgo: 3, Quantity: decimal.NewFromInt(1000), Price: decimal.RequireFromString("6.45")},
+			{Ticker: "SBER", MonthsAgo: 2, Quantity: decimal.NewFromInt(50), Price: decimal.RequireFromString("281.10")},
+			{Ticker: "GAZP", MonthsAgo: 1, Quantity: decimal.NewFromInt(200), Price: decimal.RequireFromString("134.20")},
+		}},
+		{Name: "ИИС", Broker: "Демо-брокер", Trades: []models.SeedTrade{
+			{Ticker: "SBMX", MonthsAgo: 5, Quantity: decimal.NewFromInt(2000), Price: decimal.RequireFromString("15.10")},
+			{Ticker: "LQDT", MonthsAgo: 4, Quantity: decimal.NewFromInt(10000), Price: decimal.RequireFromString("1.62")},
+			{Ticker: "SBMX", MonthsAgo: 2, Quantity: decimal.NewFromInt(1500), Price: decimal.RequireFromString("15.70")},
+		}},
+	}
+
+	seedNames = [][2]string{
+		{"Анна", "Смирнова"}, {"Иван", "Петров"}, {"Мария", "Кузнецова"}, {"Алексей", "Соколов"},
+		{"Елена", "Попова"}, {"Дмитрий", "Лебедев"}, {"Ольга", "Козлова"}, {"Сергей", "Новиков"},
+	}
+)
+
+// seedUser пользователь из типового бюджета; scale - во сколько раз его доходы и траты больше типовых
+func seedUser(email, firstName, lastName string, scale decimal.Decimal, portfolios int) models.SeedUser {
+	// суммы округляются до десятков рублей, как в реальных чеках
+	round := func(d decimal.Decimal) decimal.Decimal {
+		return d.Mul(scale).Div(decimal.NewFromInt(10)).Round(0).Mul(decimal.NewFromInt(10))
+	}
+
+	u := models.SeedUser{
+		Email: email, FirstName: firstName, LastName: lastName, Currency: "RUB",
+		Accounts: append([]models.SeedAccount(nil), seedAccounts...),
+	}
+	for _, e := range seedMonthly {
+		e.Amount = round(e.Amount)
+		u.Monthly = append(u.Monthly, e)
+	}
+	for _, e := range seedDaily {
+		e.Min, e.Max = round(e.Min), round(e.Max)
+		u.Daily = append(u.Daily, e)
+	}
+	for _, p := range seedPortfolios[:min(portfolios, len(seedPortfolios))] {
+		trades := make([]models.SeedTrade, len(p.Trades))
+		for i, t := range p.Trades {
+			t.Quantity = t.Quantity.Mul(scale).Round(0)
+			if !t.Quantity.IsPositive() {
+				t.Quantity = decimal.NewFromInt(1)
+			}
+			t.Commission = decimal.NewFromInt(15)
+			trades[i] = t
+		}
+		p.Trades = trades
+		u.Portfolios = append(u.Portfolios, p)
+	}
+	return u
+}
+
+// GenerateSeedSpec описание для нескольких правдоподобных пользователей с разными доходами и тратами.
+// в базу ничего не пишет: результат можно сохранить в файл, поправить и применить через Apply
+func GenerateSeedSpec(opts models.SeedOptions) (*models.SeedSpec, error) {
+	if opts.Users < 1 || opts.Users > maxSeedUsers {
+		return nil, fmt.Errorf("%w: from 1 to %d users expected", ErrInvalidSeed, maxSeedUsers)
+	}
+	if opts.Portfolios < 0 || opts.Portfolios > len(seedPortfolios) {
+		return nil, fmt.Errorf("%w: from 0 to %d portfolios expected", ErrInvalidSeed, len(seedPortfolios))
+	}
+	prefix, domain := opts.EmailPrefix, opts.EmailDomain
+	if prefix == "" {
+		prefix = "demo"
+	}
+	if domain == "" {
+		domain = "fin-tracker.local"
+	}
+
+	rnd := mathrand.New(mathrand.NewSource(opts.RandomSeed))
+	spec := &models.SeedSpec{Months: opts.Months, RandomSeed: opts.RandomSeed}
+	for i := 0; i < opts.Users; i++ {
+		name := seedNames[i%len(seedNames)]
+		// доходы от 0.6 до 2 типовых
+		scale := decimal.NewFromFloat(0.6 + rnd.Float64()*1.4).Round(2)
+		u := seedUser(fmt.Sprintf("%s%d@%s", prefix, i+1, domain), name[0], name[1], scale, opts.Portfolios)
+
+		// у сгенерированных пользователей пароль есть: под ними входят при разработке
+		secret := make([]byte, 9)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		u.Password = base64.RawURLEncoding.EncodeToString(secret)
+		spec.Users = append(spec.Users, u)
+	}
+	return spec, nil
+}
@@ -1,42 +1,227 @@
 package service
 
 import (
+	"log"
+
 	"github.com/alligatorO15/fin-tracker/internal/ai"
 	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/mailimport"
 	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/notify"
+	"github.com/alligatorO15/fin-tracker/internal/password"
+	"github.com/alligatorO15/fin-tracker/internal/receipt"
 	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/alligatorO15/fin-tracker/internal/secrets"
+	"github.com/alligatorO15/fin-tracker/internal/storage"
+	"github.com/alligatorO15/fin-tracker/internal/telegram"
 )
 
 type Services struct {
-	Auth        AuthService
-	User        UserService
-	Account     AccountService
-	Category    CategoryService
-	Transaction TransactionService
-	Budget      BudgetService
-	Goal        GoalService
-	Portfolio   PortfolioService
-	Investment  InvestmentService
-	Analytics   AnalyticsService
+	Auth          AuthService
+	LoginThrottle LoginThrottleService
+	User          UserService
+	Account       AccountService
+	Category      CategoryService
+	Transaction   TransactionService
+	Budget        BudgetService
+	Goal          GoalService
+	Portfolio     PortfolioService
+	Investment    InvestmentService
+	Analytics     AnalyticsService
+	Sector        SectorService
+	Payee         PayeeService
+	Health        HealthService
+	Planned       PlannedTransactionService
+	AccountRule   AccountRuleService
+	Challenge     ChallengeService
+	Dashboard     DashboardService
+
+	PriceHistory  PriceHistoryService
+	Envelope      EnvelopeService
+	TransferMatch TransferMatchService
+	MailImport    MailImportService
+	Receipt       ReceiptService
+	CSVImport     CSVImportService
+	Webhook       WebhookService
+	Report        ReportSubscriptionService
+	CustomReport  CustomReportService
+	CustomAsset   CustomAssetService
+	Duplicate     DuplicateService
+	Rebalance     RebalanceService
+	Quota         QuotaService
+	HoldingMeta   HoldingMetadataService
+	Deposit       DepositService
+	Notification  NotificationService
+	Tag           TagService
+	Export        ExportService
+	IIS           IISService
+	Product       ProductService
+	Demo          DemoService
+	Seed          SeedService
+	Telegram      TelegramService
+	Currency      CurrencyService
+	Drawdown      DrawdownService
+	Fundamentals  FundamentalsService
 }
 
 func NewServices(repos *repository.Repositories, marketProvider *market.MultiProvider, cfg *config.Config) *Services {
-	// Создаём AI клиент (nil если URL пустой)
-	var aiClient *ai.OllamaClient
-	if cfg.OllamaURL != "" {
-		aiClient = ai.NewOllamaClient(cfg.OllamaURL, cfg.OllamaModel)
-	}
+	// Создаём AI клиент (nil если AI выключен)
+	aiClient := newAIClient(cfg)
+	mailer := newMailer(cfg)
+	bot := newTelegramBot(cfg)
+	quotaService := NewQuotaService(repos.Usage, cfg)
+	budgetService := NewBudgetService(repos.Budget, repos.Transaction, repos.Category, repos.Account, repos.Payee, repos.User, repos.TxManager)
+	notificationService := NewNotificationService(repos.TxManager, repos.Notification, budgetService, repos.Telegram, bot)
+
+	sectorService := NewSectorService(repos.Sector, repos.SecurityType, repos.Security, marketProvider)
+	payeeService := NewPayeeService(repos.Payee, repos.TxManager)
+	productService := NewProductService(repos.Product, repos.User, repos.TxManager, marketProvider, cfg)
+	transactionService := NewTransactionService(repos.TxManager, repos.Transaction, repos.Account, repos.User, marketProvider, payeeService, quotaService, notificationService, productService)
+	priceHistoryService := NewPriceHistoryService(repos.PriceHistory, repos.Security, marketProvider, repos.TxManager)
+	rebalanceService := NewRebalanceService(repos.Rebalance, repos.Portfolio, repos.Holding, repos.Security, repos.User, marketProvider, repos.TxManager, mailer, notificationService)
+	drawdownService := NewDrawdownService(repos.Drawdown, repos.Portfolio, repos.Holding, marketProvider, notificationService)
+	portfolioService := NewPortfolioService(repos.Portfolio, repos.Holding, repos.Security, marketProvider, rebalanceService, drawdownService, quotaService, repos.HoldingMetadata, notificationService, repos.Investment)
+	fundamentalsService := NewFundamentalsService(repos.Fundamentals, repos.TxManager, marketProvider)
+	investmentService := NewInvestmentService(repos.Portfolio, repos.Holding, repos.Security, repos.Investment, marketProvider, sectorService, fundamentalsService, priceHistoryService, repos.TxManager, repos.IIS, repos.Drawdown)
+	analyticsService := NewAnalyticsService(repos, marketProvider, cfg, aiClient, quotaService, notificationService) // передаем весь repos так как хз какие но там много repos будут использоваться
+	passwordHasher := newPasswordHasher(cfg)
+	loginThrottle := NewLoginThrottleService(repos.LoginAttempt, repos.User, repos.RefreshToken, notificationService, mailer, cfg)
+	authService := NewAuthService(repos.TxManager, repos.User, repos.RefreshToken, passwordHasher, loginThrottle, cfg)
+	accountService := NewAccountService(repos.TxManager, repos.Account, repos.User, marketProvider)
+	goalService := NewGoalService(repos.Goal, repos.Portfolio, repos.Holding, portfolioService, investmentService, marketProvider, notificationService)
+	seedService := NewSeedService(repos.User, repos.Category, authService, accountService, transactionService, portfolioService, investmentService)
 
 	return &Services{
-		Auth:        NewAuthService(repos.User, repos.RefreshToken, cfg),
-		User:        NewUserService(repos.User),
-		Account:     NewAccountService(repos.Account, repos.User, marketProvider),
-		Category:    NewCategoryService(repos.Category),
-		Transaction: NewTransactionService(repos.TxManager, repos.Transaction, repos.Account, marketProvider),
-		Budget:      NewBudgetService(repos.Budget, repos.Transaction, repos.Category),
-		Goal:        NewGoalService(repos.Goal),
-		Portfolio:   NewPortfolioService(repos.Portfolio, repos.Holding, repos.Security, marketProvider),
-		Investment:  NewInvestmentService(repos.Portfolio, repos.Holding, repos.Security, repos.Investment, marketProvider, repos.TxManager),
-		Analytics:   NewAnalyticsService(repos, cfg, aiClient), // передаем весь repos так как хз какие но там много repos будут использоваться
+		Auth:          authService,
+		LoginThrottle: loginThrottle,
+		User:          NewUserService(repos.User, repos.RefreshToken, repos.Deletion, repos.TxManager, newStorage(cfg), quotaService, passwordHasher, mailer, cfg),
+		Account:       accountService,
+		Category:      NewCategoryService(repos.Category),
+		Transaction:   transactionService,
+		Budget:        budgetService,
+		Goal:          goalService,
+		Portfolio:     portfolioService,
+		Investment:    investmentService,
+		Analytics:     analyticsService,
+		Sector:        sectorService,
+		Payee:         payeeService,
+		Health:        NewHealthService(repos, marketProvider),
+		Planned:       NewPlannedTransactionService(repos.TxManager, repos.Planned, repos.Account, transactionService, notificationService),
+		AccountRule:   NewAccountRuleService(repos.TxManager, repos.AccountRule, repos.Account, repos.Category, transactionService),
+		Challenge:     NewChallengeService(repos.TxManager, repos.Challenge, repos.Transaction, repos.Account, repos.Category, repos.Payee, repos.User, notificationService),
+		Dashboard:     NewDashboardService(accountService, analyticsService, budgetService, goalService, portfolioService, transactionService, cfg),
+
+		PriceHistory:  priceHistoryService,
+		Envelope:      NewEnvelopeService(repos.Envelope, repos.Category, repos.Transaction, repos.User, repos.TxManager),
+		TransferMatch: NewTransferMatchService(repos.Transaction, marketProvider, repos.TxManager),
+		MailImport:    NewMailImportService(repos.MailConnection, repos.TransactionDraft, repos.Account, transactionService, repos.TxManager, mailimport.DefaultRegistry(), newSecretBox(cfg), notificationService),
+		Receipt:       NewReceiptService(repos.Receipt, repos.Account, transactionService, repos.TxManager, newReceiptProvider(cfg), quotaService),
+		CSVImport:     NewCSVImportService(repos.TxManager, repos.CSVImport, repos.Account, repos.Category, repos.Transaction, transactionService),
+		Webhook:       NewWebhookService(repos.Webhook, repos.Account, repos.Category, transactionService, repos.TxManager),
+		Report:        NewReportSubscriptionService(repos.ReportSub, repos.User, analyticsService, budgetService, portfolioService, mailer),
+		CustomReport:  NewCustomReportService(repos.ReportDefinition, repos.User, marketProvider),
+		CustomAsset:   NewCustomAssetService(repos.CustomAsset, repos.TxManager),
+		Duplicate:     NewDuplicateService(repos.Transaction, transactionService, repos.TxManager),
+		Rebalance:     rebalanceService,
+		Quota:         quotaService,
+		HoldingMeta:   NewHoldingMetadataService(repos.HoldingMetadata, repos.Portfolio, repos.Holding),
+		Deposit:       NewDepositService(repos.Deposit, repos.Account),
+		Notification:  notificationService,
+		Tag:           NewTagService(repos.Tag, repos.User, repos.TxManager, marketProvider, cfg),
+		Export:        NewExportService(repos),
+		IIS:           NewIISService(repos.IIS, repos.Portfolio),
+		Product:       productService,
+		Demo:          NewDemoService(seedService, repos.User, cfg),
+		Seed:          seedService,
+		Telegram:      NewTelegramService(repos.Telegram, repos.User, repos.Account, repos.Category, repos.TxManager, transactionService, budgetService, analyticsService, bot, cfg),
+		Currency:      NewCurrencyService(repos.Redenomination),
+		Drawdown:      drawdownService,
+		Fundamentals:  fundamentalsService,
+	}
+}
+
+// newAIClient выбирает AI-провайдера по конфигу; nil - AI-функции отключены
+func newAIClient(cfg *config.Config) ai.Client {
+	switch cfg.AIProvider {
+	case ai.ProviderOllama:
+		if cfg.OllamaURL == "" {
+			return nil
+		}
+		return ai.NewOllamaClient(cfg.OllamaURL, cfg.OllamaModel)
+	case ai.ProviderOpenAI:
+		if cfg.OpenAIBaseURL == "" || cfg.OpenAIModel == "" {
+			return nil
+		}
+		return ai.NewOpenAIClient(cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.OpenAIModel)
+	case ai.ProviderNone, "":
+		return nil
+	}
+
+	log.Printf("Неизвестный AI_PROVIDER %q, AI-функции отключены", cfg.AIProvider)
+	return nil
+}
+
+// newPasswordHasher хэширование паролей по конфигу; неизвестный алгоритм - argon2id с теми же параметрами
+func newPasswordHasher(cfg *config.Config) *password.Hasher {
+	params := password.Params{
+		Algorithm:         cfg.PasswordHashAlgorithm,
+		BcryptCost:        cfg.BcryptCost,
+		Argon2Memory:      uint32(max(cfg.Argon2MemoryKB, 0)),
+		Argon2Iterations:  uint32(max(cfg.Argon2Iterations, 0)),
+		Argon2Parallelism: uint8(min(max(cfg.Argon2Parallelism, 0), 255)),
+	}
+	hasher, err := password.NewHasher(params)
+	if err != nil {
+		log.Printf("%v, пароли хэшируются argon2id", err)
+		params.Algorithm = password.AlgorithmArgon2id
+		hasher, _ = password.NewHasher(params)
+	}
+	return hasher
+}
+
+// newSecretBox шифрование секретов пользователей; nil - ключ не задан, функции с секретами выключены
+func newSecretBox(cfg *config.Config) *secrets.Box {
+	box, err := secrets.NewBox(cfg.EncryptionKey)
+	if err != nil {
+		log.Printf("Шифрование секретов недоступно (%v), импорт из почты выключен", err)
+		return nil
+	}
+	return box
+}
+
+// newReceiptProvider сервис проверки чеков; nil - токен не задан, импорт чеков выключен
+func newReceiptProvider(cfg *config.Config) receipt.Provider {
+	if cfg.ReceiptAPIToken == "" {
+		return nil
+	}
+	return receipt.NewProverkachekaClient(cfg.ReceiptAPIURL, cfg.ReceiptAPIToken)
+}
+
+// newMailer отправка писем пользователям; nil - SMTP не настроен, письма не отправляются
+func newMailer(cfg *config.Config) notify.Mailer {
+	if cfg.SMTPHost == "" {
+		return nil
+	}
+	return notify.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+}
+
+// newTelegramBot клиент Telegram-бота; nil - токен не задан, бот выключен
+func newTelegramBot(cfg *config.Config) telegram.Bot {
+	if cfg.TelegramBotToken == "" {
+		return nil
+	}
+	return telegram.NewClient(cfg.TelegramAPIURL, cfg.TelegramBotToken)
+}
+
+// newStorage хранилище вложений; nil - каталог не задан или недоступен, загрузка файлов выключена
+func newStorage(cfg *config.Config) storage.Storage {
+	if cfg.AttachmentsDir == "" {
+		return nil
+	}
+	local, err := storage.NewLocalStorage(cfg.AttachmentsDir)
+	if err != nil {
+		log.Printf("Каталог вложений недоступен (%v), загрузка файлов выключена", err)
+		return nil
 	}
+	return local
 }
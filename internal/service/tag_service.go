@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTagNotFound     = errors.New("tag not found")
+	ErrTagNameTaken    = errors.New("tag with this name already exists, merge them instead")
+	ErrInvalidTag      = errors.New("tag must be from 1 to 50 characters")
+	ErrInvalidTagGroup = errors.New("group_by must be one of: day, week, month, year")
+)
+
+const maxTagLength = 50 // transaction_tags.tag VARCHAR(50)
+
+type TagService interface {
+	List(ctx context.Context, userID uuid.UUID) ([]models.TagUsage, error)
+	Rename(ctx context.Context, userID uuid.UUID, input *models.TagRename) (*models.TagChange, error)
+	Merge(ctx context.Context, userID uuid.UUID, input *models.TagMerge) (*models.TagChange, error)
+	// GetSpending расходы по меткам с разбивкой по периодам; пустой tags - все метки,
+	// без дат - последние 12 месяцев, пустой currency - валюта пользователя
+	GetSpending(ctx context.Context, userID uuid.UUID, tags []string, groupBy string, startDate, endDate *time.Time, currency string) (*models.TagSpendingReport, error)
+}
+
+type tagService struct {
+	tagRepo        repository.TagRepository
+	userRepo       repository.UserRepository
+	txManager      repository.TxManager
+	marketProvider *market.MultiProvider
+	config         *config.Config
+}
+
+func NewTagService(tagRepo repository.TagRepository, userRepo repository.UserRepository, txManager repository.TxManager, marketProvider *market.MultiProvider, cfg *config.Config) TagService {
+	return &tagService{
+		tagRepo:        tagRepo,
+		userRepo:       userRepo,
+		txManager:      txManager,
+		marketProvider: marketProvider,
+		config:         cfg,
+	}
+}
+
+func (s *tagService) List(ctx context.Context, userID uuid.UUID) ([]models.TagUsage, error) {
+	return s.tagRepo.GetByUserID(ctx, userID)
+}
+
+func (s *tagService) Rename(ctx context.Context, userID uuid.UUID, input *models.TagRename) (*models.TagChange, error) {
+	// старое имя берем как есть: это ключ из списка меток
+	tag, name := input.Tag, normalizeTag(input.Name)
+	if !validTag(name) {
+		return nil, ErrInvalidTag
+	}
+	if err := s.checkExists(ctx, userID, tag); err != nil {
+		return nil, err
+	}
+	if name == tag {
+		return &models.TagChange{Tag: name}, nil
+	}
+
+	taken, err := s.tagRepo.Exists(ctx, userID, name)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrTagNameTaken
+	}
+
+	return s.replace(ctx, userID, []string{tag}, name)
+}
+
+func (s *tagService) Merge(ctx context.Context, userID uuid.UUID, input *models.TagMerge) (*models.TagChange, error) {
+	target := normalizeTag(input.Target)
+	if !validTag(target) {
+		return nil, ErrInvalidTag
+	}
+
+	var sources []string
+	for _, source := range input.Sources {
+		if source == target {
+			continue
+		}
+		if err := s.checkExists(ctx, userID, source); err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return &models.TagChange{Tag: target}, nil
+	}
+
+	return s.replace(ctx, userID, sources, target)
+}
+
+func (s *tagService) replace(ctx context.Context, userID uuid.UUID, sources []string, target string) (*models.TagChange, error) {
+	change := &models.TagChange{Tag: target}
+	err := s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		var err error
+		change.UpdatedTransactions, err = s.tagRepo.Replace(txCtx, userID, sources, target)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (s *tagService) checkExists(ctx context.Context, userID uuid.UUID, tag string) error {
+	exists, err := s.tagRepo.Exists(ctx, userID, tag)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+func (s *tagService) GetSpending(ctx context.Context, userID uuid.UUID, tags []string, groupBy string, startDate, endDate *time.Time, currency string) (*models.TagSpendingReport, error) {
+	if groupBy == "" {
+		groupBy = "month"
+	}
+	switch groupBy {
+	case "day", "week", "month", "year":
+	default:
+		return nil, ErrInvalidTagGroup
+	}
+
+	now := time.Now()
+	end := now
+	if endDate != nil {
+		end = *endDate
+	}
+	start := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, end.Location()).AddDate(0, -11, 0)
+	if startDate != nil {
+		start = *startDate
+	}
+
+	userCurrency := ""
+	if user, _ := s.userRepo.GetByID(ctx, userID); user != nil {
+		userCurrency = user.DefaultCurrency
+	}
+	report := &models.TagSpendingReport{
+		Currency:  reportCurrency(currency, userCurrency, s.config.DefaultCurrency),
+		GroupBy:   groupBy,
+		StartDate: start,
+		EndDate:   end,
+		Tags:      []models.TagSpending{},
+	}
+
+	byTag, err := s.tagRepo.GetExpensesByPeriodCurrency(ctx, userID, tags, start, end, groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	conv := newCurrencyConverter(s.marketProvider, report.Currency)
+	for tag, byCurrency := range byTag {
+		spending := models.TagSpending{Tag: tag}
+
+		// сводим валюты в одну строку на период
+		periods := make(map[string]*models.TagPeriodAmount)
+		for cur, amounts := range byCurrency {
+			for _, a := range amounts {
+				amount, err := conv.convert(ctx, a.Amount, cur)
+				if err != nil {
+					return nil, err
+				}
+				row, ok := periods[a.Period]
+				if !ok {
+					row = &models.TagPeriodAmount{Period: a.Period}
+					periods[a.Period] = row
+				}
+				row.Amount = row.Amount.Add(amount)
+				row.TransactionCount += a.TransactionCount
+			}
+		}
+
+		for _, row := range periods {
+			row.Amount = row.Amount.Round(2)
+			spending.Total = spending.Total.Add(row.Amount)
+			spending.TransactionCount += row.TransactionCount
+			spending.Periods = append(spending.Periods, *row)
+		}
+		sort.Slice(spending.Periods, func(i, j int) bool { return spending.Periods[i].Period < spending.Periods[j].Period })
+		report.Tags = append(report.Tags, spending)
+	}
+
+	sort.Slice(report.Tags, func(i, j int) bool {
+		if !report.Tags[i].Total.Equal(report.Tags[j].Total) {
+			return report.Tags[i].Total.GreaterThan(report.Tags[j].Total)
+		}
+		return report.Tags[i].Tag < report.Tags[j].Tag
+	})
+	return report, nil
+}
+
+// normalizeTag убирает лишние пробелы внутри и по краям метки
+func normalizeTag(tag string) string {
+	return strings.Join(strings.Fields(tag), " ")
+}
+
+func validTag(tag string) bool {
+	return tag != "" && utf8.RuneCountInString(tag) <= maxTagLength
+}
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/config"
+	"github.com/alligatorO15/fin-tracker/internal/format"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/alligatorO15/fin-tracker/internal/telegram"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrTelegramDisabled      = errors.New("telegram bot is not configured")
+	ErrTelegramNotLinked     = errors.New("telegram is not linked")
+	ErrTelegramInvalidSecret = errors.New("invalid telegram webhook secret")
+)
+
+// сколько действует ссылка для привязки
+const telegramCodeTTL = 15 * time.Minute
+
+// сколько помним обработанные обновления: недоставленные Telegram хранит сутки
+const telegramUpdateTTL = 48 * time.Hour
+
+const telegramHelp = `Пишите траты одним сообщением: «такси 450», «кофе 250 вчера».
+Доход - со знаком плюс: «+50000 зарплата».
+
+/balance - остатки на счетах
+/budgets - бюджеты текущего периода
+/unlink - отвязать чат`
+
+// ключевые слова для категорий по умолчанию, по порядку проверки; свои категории пользователя ищутся по названию
+var telegramCategoryKeywords = []struct {
+	category string
+	keywords []string
+}{
+	{"Продукты", []string{"продукт", "магазин", "супермаркет", "пятерочка", "пятёрочка", "перекресток", "перекрёсток", "магнит", "вкусвилл", "лента", "ашан"}},
+	{"Рестораны", []string{"кафе", "кофе", "ресторан", "обед", "ужин", "завтрак", "бар", "пицц", "суши", "бургер", "доставка еды"}},
+	{"Транспорт", []string{"такси", "метро", "автобус", "бензин", "заправк", "парковк", "каршеринг", "электричк", "проезд"}},
+	{"Жилье", []string{"аренд", "ипотек", "квартир"}},
+	{"Коммунальные услуги", []string{"жку", "коммуналк", "электричеств", "свет", "газ", "вода"}},
+	{"Здоровье", []string{"аптек", "врач", "лекарств", "стоматолог", "анализ", "спортзал", "фитнес"}},
+	{"Развлечения", []string{"кино", "театр", "концерт", "игр", "музей"}},
+	{"Покупки", []string{"одежд", "обув", "маркетплейс", "озон", "ozon", "wildberries", "вайлдберриз"}},
+	{"Подписки", []string{"подписк", "netflix", "spotify", "яндекс плюс"}},
+	{"Связь", []string{"связь", "интернет", "мобильн", "телефон"}},
+	{"Путешествия", []string{"отель", "билет", "авиа", "поезд"}},
+	{"Образование", []string{"курс", "книг", "учеб"}},
+	{"Домашние животные", []string{"корм", "ветеринар"}},
+}
+
+// категории, если ничего не подошло
+const (
+	telegramFallbackExpense = "Другие расходы"
+	telegramFallbackIncome  = "Другой доход"
+)
+
+type TelegramService interface {
+	// CreateLinkCode одноразовая ссылка на бота для привязки чата; прежняя ссылка перестает работать
+	CreateLinkCode(ctx context.Context, userID uuid.UUID, input *models.TelegramLinkRequest) (*models.TelegramLinkCode, error)
+	GetLink(ctx context.Context, userID uuid.UUID) (*models.TelegramLink, error)
+	Unlink(ctx context.Context, userID uuid.UUID) error
+	// VerifyWebhook сверяет секрет из заголовка X-Telegram-Bot-Api-Secret-Token
+	VerifyWebhook(secret string) error
+	// HandleUpdate отвечает на сообщение из чата; ошибка - не удалось ответить
+	HandleUpdate(ctx context.Context, update *telegram.Update) error
+	// PurgeUpdates забывает давно обработанные обновления
+	PurgeUpdates(ctx context.Context) (int64, error)
+}
+
+type telegramService struct {
+	telegramRepo       repository.TelegramRepository
+	userRepo           repository.UserRepository
+	accountRepo        repository.AccountRepository
+	categoryRepo       repository.CategoryRepository
+	txManager          repository.TxManager
+	transactionService TransactionService
+	budgetService      BudgetService
+	analyticsService   AnalyticsService
+	bot                telegram.Bot // nil - бот не настроен
+	config             *config.Config
+}
+
+func NewTelegramService(
+	telegramRepo repository.TelegramRepository,
+	userRepo repository.UserRepository,
+	accountRepo repository.AccountRepository,
+	categoryRepo repository.CategoryRepository,
+	txManager repository.TxManager,
+	transactionService TransactionService,
+	budgetService BudgetService,
+	analyticsService AnalyticsService,
+	bot telegram.Bot,
+	cfg *config.Config,
+) TelegramService {
+	return &telegramService{
+		telegramRepo:       telegramRepo,
+		userRepo:           userRepo,
+		accountRepo:        accountRepo,
+		categoryRepo:       categoryRepo,
+		txManager:          txManager,
+		transactionService: transactionService,
+		budgetService:      budgetService,
+		analyticsService:   analyticsService,
+		bot:                bot,
+		config:             cfg,
+	}
+}
+
+func (s *telegramService) CreateLinkCode(ctx context.Context, userID uuid.UUID, input *models.TelegramLinkRequest) (*models.TelegramLinkCode, error) {
+	if s.bot == nil || s.config.TelegramBotUsername == "" {
+		return nil, ErrTelegramDisabled
+	}
+	if input.AccountID != nil {
+		account, err := s.accountRepo.GetByID(ctx, *input.AccountID)
+		if err != nil || account.UserID != userID {
+			return nil, ErrAccountNotFound
+		}
+	}
+
+	// параметр start: до 64 символов A-Z, a-z, 0-9, _ и -
+	raw := make([]byte, 18)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	code := base64.RawURLEncoding.EncodeToString(raw)
+	expiresAt := time.Now().Add(telegramCodeTTL)
+	if err := s.telegramRepo.SetCode(ctx, userID, hashTelegramCode(code), expiresAt, input.AccountID); err != nil {
+		return nil, err
+	}
+
+	return &models.TelegramLinkCode{
+		Code:      code,
+		URL:       "https://t.me/" + s.config.TelegramBotUsername + "?start=" + code,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *telegramService) GetLink(ctx context.Context, userID uuid.UUID) (*models.TelegramLink, error) {
+	link, err := s.telegramRepo.GetByUserID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.TelegramLink{UserID: userID}, nil
+	}
+	return link, err
+}
+
+func (s *telegramService) Unlink(ctx context.Context, userID uuid.UUID) error {
+	deleted, err := s.telegramRepo.Delete(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrTelegramNotLinked
+	}
+	return nil
+}
+
+func (s *telegramService) VerifyWebhook(secret string) error {
+	if s.bot == nil {
+		return ErrTelegramDisabled
+	}
+	// без секрета вебхук мог бы вызвать кто угодно от имени любого чата
+	expected := s.config.TelegramWebhookSecret
+	if expected == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) != 1 {
+		return ErrTelegramInvalidSecret
+	}
+	return nil
+}
+
+func (s *telegramService) HandleUpdate(ctx context.Context, update *telegram.Update) error {
+	msg := update.Message
+	// группы и каналы не поддерживаем: траты личные
+	if msg == nil || msg.Chat.Type != "private" || strings.TrimSpace(msg.Text) == "" {
+		return nil
+	}
+	// пока подбирается категория, Telegram может не дождаться ответа на вебхук и прислать обновление
+	// снова, поэтому отмечаем его до обработки: повтор не запишет операцию второй раз
+	first, err := s.telegramRepo.MarkUpdate(ctx, update.UpdateID)
+	if err != nil {
+		return err
+	}
+	if !first {
+		return nil
+	}
+	return s.bot.SendMessage(ctx, msg.Chat.ID, s.reply(ctx, msg))
+}
+
+func (s *telegramService) PurgeUpdates(ctx context.Context) (int64, error) {
+	return s.telegramRepo.DeleteUpdatesBefore(ctx, time.Now().Add(-telegramUpdateTTL))
+}
+
+// reply ответ на сообщение; внутренние ошибки логируются, пользователю - короткий текст
+func (s *telegramService) reply(ctx context.Context, msg *telegram.Message) string {
+	text := strings.TrimSpace(msg.Text)
+	command, arg, _ := strings.Cut(text, " ")
+	// из меню команд может прийти /balance@bot_name
+	command, _, _ = strings.Cut(command, "@")
+
+	if command == "/start" {
+		return s.link(ctx, msg, strings.TrimSpace(arg))
+	}
+
+	link, err := s.telegramRepo.GetByChatID(ctx, msg.Chat.ID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Printf("Telegram: не удалось найти привязку чата %d: %v", msg.Chat.ID, err)
+			return "Что-то пошло не так, попробуйте позже"
+		}
+		return "Чат не привязан. Откройте ссылку для привязки в настройках FinTracker"
+	}
+
+	switch command {
+	case "/help":
+		return telegramHelp
+	case "/balance":
+		return s.balances(ctx, link.UserID)
+	case "/budgets":
+		return s.budgets(ctx, link.UserID)
+	case "/unlink":
+		if _, err := s.telegramRepo.Delete(ctx, link.UserID); err != nil {
+			log.Printf("Telegram: не удалось отвязать чат %d: %v", msg.Chat.ID, err)
+			return "Не удалось отвязать чат, попробуйте позже"
+		}
+		return "Чат отвязан. Уведомления сюда больше не придут"
+	}
+	if strings.HasPrefix(command, "/") {
+		return "Неизвестная команда\n\n" + telegramHelp
+	}
+	return s.addEntry(ctx, link, text)
+}
+
+func (s *telegramService) link(ctx context.Context, msg *telegram.Message, code string) string {
+	if code == "" {
+		return "Привет! Чтобы вести учет отсюда, откройте ссылку для привязки в настройках FinTracker\n\n" + telegramHelp
+	}
+
+	username := ""
+	if msg.From != nil {
+		username = msg.From.Username
+	}
+	err := s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		if err := s.telegramRepo.ReleaseChat(txCtx, msg.Chat.ID); err != nil {
+			return err
+		}
+		_, err := s.telegramRepo.LinkByCode(txCtx, hashTelegramCode(code), msg.Chat.ID, username)
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "Ссылка недействительна или устарела. Получите новую в настройках FinTracker"
+	}
+	if err != nil {
+		log.Printf("Telegram: не удалось привязать чат %d: %v", msg.Chat.ID, err)
+		return "Не удалось привязать чат, попробуйте позже"
+	}
+	return "Готово, чат привязан. Сюда же будут приходить уведомления\n\n" + telegramHelp
+}
+
+func (s *telegramService) balances(ctx context.Context, userID uuid.UUID) string {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "Не удалось получить счета, попробуйте позже"
+	}
+	accounts, err := s.accountRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		log.Printf("Telegram: не удалось получить счета %s: %v", userID, err)
+		return "Не удалось получить счета, попробуйте позже"
+	}
+
+	locale := format.Get(user.Locale)
+	var b strings.Builder
+	for _, a := range accounts {
+		if !a.IsActive {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\n", a.Name, locale.Money(a.Balance, a.Currency))
+	}
+	if b.Len() == 0 {
+		return "Счетов пока нет"
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func (s *telegramService) budgets(ctx context.Context, userID uuid.UUID) string {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "Не удалось получить бюджеты, попробуйте позже"
+	}
+	summary, err := s.budgetService.GetSummary(ctx, userID)
+	if err != nil {
+		log.Printf("Telegram: не удалось получить бюджеты %s: %v", userID, err)
+		return "Не удалось получить бюджеты, попробуйте позже"
+	}
+	if len(summary.Budgets) == 0 {
+		return "Бюджетов пока нет"
+	}
+
+	locale := format.Get(user.Locale)
+	var b strings.Builder
+	for _, budget := range summary.Budgets {
+		mark := ""
+		if budget.Spent.GreaterThan(budget.Amount) {
+			mark = " ⚠️"
+		}
+		fmt.Fprintf(&b, "%s: %s из %s (%.0f%%)%s\n", budget.Name,
+			locale.Money(budget.Spent, budget.Currency), locale.Money(budget.Amount, budget.Currency), budget.SpentPercent, mark)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func (s *telegramService) addEntry(ctx context.Context, link *models.TelegramLink, text string) string {
+	entry, ok := telegram.ParseEntry(text)
+	if !ok {
+		return "Не понял сообщение. Напишите сумму и на что: «такси 450»\n\n/help - что умеет бот"
+	}
+
+	user, err := s.userRepo.GetByID(ctx, link.UserID)
+	if err != nil {
+		return "Не удалось записать операцию, попробуйте позже"
+	}
+	account, err := s.entryAccount(ctx, link, user)
+	if err != nil {
+		return "Не найден счет для записи. Создайте счет в FinTracker"
+	}
+
+	txType, categoryType := models.TransactionTypeExpense, models.CategoryTypeExpense
+	if entry.Income {
+		txType, categoryType = models.TransactionTypeIncome, models.CategoryTypeIncome
+	}
+	category, err := s.entryCategory(ctx, link.UserID, entry.Description, categoryType)
+	if err != nil {
+		log.Printf("Telegram: не удалось подобрать категорию для %q: %v", entry.Description, err)
+		return "Не удалось записать операцию, попробуйте позже"
+	}
+
+	tx, err := s.transactionService.Create(ctx, link.UserID, &models.TransactionCreate{
+		AccountID:   account.ID,
+		CategoryID:  category.ID,
+		Type:        txType,
+		Amount:      entry.Amount,
+		Description: entry.Description,
+		Date:        entry.Date(time.Now(), userLocation(user)),
+		Notes:       "Из Telegram",
+	})
+	if err != nil {
+		if errors.Is(err, ErrTransactionQuotaExceeded) {
+			return "Достигнут лимит операций на этот месяц"
+		}
+		log.Printf("Telegram: не удалось создать операцию для %s: %v", link.UserID, err)
+		return "Не удалось записать операцию, попробуйте позже"
+	}
+
+	locale := format.Get(user.Locale)
+	kind := "Расход"
+	if entry.Income {
+		kind = "Доход"
+	}
+	return fmt.Sprintf("%s %s записан: %s, «%s», %s", kind, locale.Money(tx.Amount, tx.Currency),
+		category.Name, account.Name, locale.Date(tx.Date))
+}
+
+// entryAccount счет из настроек привязки или первый активный карточный/наличный в валюте пользователя
+func (s *telegramService) entryAccount(ctx context.Context, link *models.TelegramLink, user *models.User) (*models.Account, error) {
+	accounts, err := s.accountRepo.GetByUserID(ctx, link.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if link.AccountID != nil {
+		if a := findAccount(accounts, *link.AccountID); a != nil && a.IsActive {
+			return a, nil
+		}
+	}
+
+	var best *models.Account
+	score := func(a *models.Account) int {
+		n := 0
+		if a.Type == models.AccountTypeBank || a.Type == models.AccountTypeCash {
+			n += 2
+		}
+		if a.Currency == user.DefaultCurrency {
+			n++
+		}
+		return n
+	}
+	for i := range accounts {
+		a := &accounts[i]
+		if !a.IsActive || a.Type == models.AccountTypeInvestment || a.Type == models.AccountTypeDebt {
+			continue
+		}
+		if best == nil || score(a) > score(best) {
+			best = a
+		}
+	}
+	if best == nil {
+		return nil, ErrAccountNotFound
+	}
+	return best, nil
+}
+
+// entryCategory категория по названию в описании, по ключевым словам, через AI и, если ничего не подошло, "другие"
+func (s *telegramService) entryCategory(ctx context.Context, userID uuid.UUID, description string, categoryType models.CategoryType) (*models.Category, error) {
+	categories, err := s.categoryRepo.GetByType(ctx, userID, categoryType)
+	if err != nil {
+		return nil, err
+	}
+	byName := func(name string) *models.Category {
+		for i := range categories {
+			if strings.EqualFold(categories[i].Name, name) {
+				return &categories[i]
+			}
+		}
+		return nil
+	}
+
+	lower := strings.ToLower(description)
+	for i := range categories {
+		if strings.Contains(lower, strings.ToLower(categories[i].Name)) {
+			return &categories[i], nil
+		}
+	}
+	if categoryType == models.CategoryTypeExpense {
+		for _, group := range telegramCategoryKeywords {
+			for _, kw := range group.keywords {
+				if strings.Contains(lower, kw) {
+					if c := byName(group.category); c != nil {
+						return c, nil
+					}
+				}
+			}
+		}
+	}
+
+	// AI может быть выключен или недоступен - тогда просто без него
+	if c, err := s.analyticsService.SuggestCategory(ctx, userID, description, categoryType); err == nil && c != nil {
+		return c, nil
+	}
+
+	fallback := telegramFallbackExpense
+	if categoryType == models.CategoryTypeIncome {
+		fallback = telegramFallbackIncome
+	}
+	if c := byName(fallback); c != nil {
+		return c, nil
+	}
+	if len(categories) == 0 {
+		return nil, ErrCategoryNotFound
+	}
+	return &categories[0], nil
+}
+
+func hashTelegramCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
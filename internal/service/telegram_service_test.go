@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/alligatorO15/fin-tracker/internal/telegram"
+	"github.com/jackc/pgx/v5"
+)
+
+// updatesTelegramRepo помнит обработанные обновления, чаты не привязаны
+type updatesTelegramRepo struct {
+	repository.TelegramRepository
+	updates map[int64]bool
+}
+
+func (r *updatesTelegramRepo) MarkUpdate(ctx context.Context, updateID int64) (bool, error) {
+	if r.updates[updateID] {
+		return false, nil
+	}
+	r.updates[updateID] = true
+	return true, nil
+}
+
+func (r *updatesTelegramRepo) GetByChatID(ctx context.Context, chatID int64) (*models.TelegramLink, error) {
+	return nil, pgx.ErrNoRows
+}
+
+// countingBot считает отправленные сообщения
+type countingBot struct {
+	sent int
+}
+
+func (b *countingBot) SendMessage(ctx context.Context, chatID int64, text string) error {
+	b.sent++
+	return nil
+}
+
+func TestHandleUpdateIgnoresRedelivery(t *testing.T) {
+	bot := &countingBot{}
+	svc := &telegramService{telegramRepo: &updatesTelegramRepo{updates: make(map[int64]bool)}, bot: bot}
+	update := &telegram.Update{
+		UpdateID: 42,
+		Message:  &telegram.Message{Chat: telegram.Chat{ID: 1, Type: "private"}, Text: "такси 450"},
+	}
+
+	for range 2 {
+		if err := svc.HandleUpdate(context.Background(), update); err != nil {
+			t.Fatalf("HandleUpdate: %v", err)
+		}
+	}
+	if bot.sent != 1 {
+		t.Errorf("messages sent = %d, want 1", bot.sent)
+	}
+}
@@ -3,22 +3,29 @@ package service
 import (
 	"context"
 	"errors"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/alligatorO15/fin-tracker/internal/market"
 	"github.com/alligatorO15/fin-tracker/internal/models"
 	"github.com/alligatorO15/fin-tracker/internal/repository"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 var (
 	ErrInvalidTransactionType = errors.New("invalid transaction type")
 	ErrTransferMissingAccount = errors.New("transfer requires destination account")
+	ErrInvalidSortField       = errors.New("invalid sort_by field")
 )
 
 type TransactionService interface {
 	Create(ctx context.Context, userID uuid.UUID, input *models.TransactionCreate) (*models.Transaction, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Transaction, error)
 	GetByFilter(ctx context.Context, userID uuid.UUID, filter *models.TransactionFilter) (*models.TransactionList, error)
+	// GetPage страница по курсору для /api/v2; cursor - next_cursor предыдущей страницы, пустой - первая
+	GetPage(ctx context.Context, userID uuid.UUID, filter *models.TransactionFilter, cursor string, limit int) (*models.TransactionPage, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.TransactionUpdate) (*models.Transaction, error)
 	Delete(cxt context.Context, id uuid.UUID) error
 }
@@ -27,15 +34,25 @@ type transactionService struct {
 	txManager       repository.TxManager
 	transactionRepo repository.TransactionRepository
 	accountRepo     repository.AccountRepository
+	userRepo        repository.UserRepository
 	marketProvider  *market.MultiProvider
+	payeeService    PayeeService
+	quota           QuotaService
+	notifications   NotificationService
+	productService  ProductService
 }
 
-func NewTransactionService(txManager repository.TxManager, transactionRepo repository.TransactionRepository, accountRepo repository.AccountRepository, marketProvider *market.MultiProvider) TransactionService {
+func NewTransactionService(txManager repository.TxManager, transactionRepo repository.TransactionRepository, accountRepo repository.AccountRepository, userRepo repository.UserRepository, marketProvider *market.MultiProvider, payeeService PayeeService, quota QuotaService, notifications NotificationService, productService ProductService) TransactionService {
 	return &transactionService{
 		txManager:       txManager,
 		transactionRepo: transactionRepo,
 		accountRepo:     accountRepo,
+		userRepo:        userRepo,
 		marketProvider:  marketProvider,
+		payeeService:    payeeService,
+		quota:           quota,
+		notifications:   notifications,
+		productService:  productService,
 	}
 }
 
@@ -45,6 +62,13 @@ func (s *transactionService) Create(ctx context.Context, userID uuid.UUID, input
 			return nil, ErrTransferMissingAccount
 		}
 	}
+	if !validCoordinates(input.Latitude, input.Longitude) {
+		return nil, ErrInvalidCoordinates
+	}
+	// квота общая для ручного ввода и всех импортов
+	if err := s.quota.CheckTransaction(ctx, userID); err != nil {
+		return nil, err
+	}
 
 	// находим счет, чтобы узнать валюту счета
 	account, err := s.accountRepo.GetByID(ctx, input.AccountID)
@@ -60,14 +84,43 @@ func (s *transactionService) Create(ctx context.Context, userID uuid.UUID, input
 		Amount:         input.Amount,
 		Currency:       account.Currency,
 		Description:    input.Description,
-		Date:           input.Date,
+		Date:           s.localDate(ctx, userID, input.Date),
 		ToAccountID:    input.ToAccountID,
 		ToAmount:       input.ToAmount, // для переводов будет пересчитано ниже с учётом конвертации
 		IsRecurring:    input.IsRecurring,
 		RecurrenceRule: input.RecurrenceRule,
 		Tags:           input.Tags,
 		Location:       input.Location,
+		Latitude:       input.Latitude,
+		Longitude:      input.Longitude,
+		Geohash:        transactionGeohash(input.Latitude, input.Longitude),
+		City:           strings.TrimSpace(input.City),
 		Notes:          input.Notes,
+		Items:          input.Items,
+	}
+
+	// по умолчанию позиция - одна штука, ее сумма - количество × цена
+	for i := range tx.Items {
+		item := &tx.Items[i]
+		if item.Quantity.IsZero() {
+			item.Quantity = decimal.NewFromInt(1)
+		}
+		if item.Amount.IsZero() {
+			item.Amount = item.Quantity.Mul(item.Price)
+		}
+		// товар в каталоге только по названию: чужой product_id из запроса не принимаем
+		item.ProductID = nil
+		if input.Type == models.TransactionTypeExpense {
+			item.ProductID = s.resolveProduct(ctx, userID, item.Name)
+		}
+	}
+
+	// получатель: явно указанный или подобранный по описанию
+	if input.PayeeID != nil {
+		if _, err := s.payeeService.GetByID(ctx, userID, *input.PayeeID); err != nil {
+			return nil, err
+		}
+		tx.PayeeID = input.PayeeID
 	}
 
 	// вычисляем ToAmount для переводов(если нужна конвертация)
@@ -91,6 +144,10 @@ func (s *transactionService) Create(ctx context.Context, userID uuid.UUID, input
 	}
 	// выполняем транзакцию(все репо-методы атомарно)
 	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		// получателя создаем в той же транзакции, чтобы при ошибке не оставался лишний
+		if input.PayeeID == nil && input.Type != models.TransactionTypeTransfer {
+			tx.PayeeID = s.resolvePayee(txCtx, userID, input.Description)
+		}
 		if err := s.transactionRepo.Create(txCtx, tx); err != nil {
 			return err
 		}
@@ -116,6 +173,10 @@ func (s *transactionService) Create(ctx context.Context, userID uuid.UUID, input
 	if err != nil {
 		return nil, err
 	}
+
+	if tx.Type == models.TransactionTypeExpense {
+		s.notifications.CheckBudgets(ctx, userID)
+	}
 	return tx, nil
 }
 
@@ -124,7 +185,63 @@ func (s *transactionService) GetByID(ctx context.Context, id uuid.UUID) (*models
 }
 
 func (s *transactionService) GetByFilter(ctx context.Context, userID uuid.UUID, filter *models.TransactionFilter) (*models.TransactionList, error) {
-	return s.transactionRepo.GetByFilter(ctx, userID, filter)
+	list, err := s.transactionRepo.GetByFilter(ctx, userID, filter)
+	if errors.Is(err, repository.ErrInvalidSortField) {
+		return nil, ErrInvalidSortField
+	}
+	if err != nil {
+		return nil, err
+	}
+	list.Currency = s.convertTransactions(ctx, list.Transactions, filter.Currency)
+	return list, nil
+}
+
+func (s *transactionService) GetPage(ctx context.Context, userID uuid.UUID, filter *models.TransactionFilter, cursor string, limit int) (*models.TransactionPage, error) {
+	page, limit, err := cursorPage(cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.transactionRepo.GetPage(ctx, userID, filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.TransactionPage{Transactions: transactions, Limit: limit}
+	if len(transactions) > limit {
+		result.Transactions = transactions[:limit]
+		last := result.Transactions[limit-1]
+		result.NextCursor = encodeCursor(last.Date, last.ID)
+	}
+	if result.Transactions == nil {
+		result.Transactions = []models.Transaction{}
+	}
+	result.Currency = s.convertTransactions(ctx, result.Transactions, filter.Currency)
+	return result, nil
+}
+
+// convertTransactions заполняет converted_amount по курсу на дату каждой операции, чтобы суммы в прошлом
+// не плыли вместе с текущим курсом. операция без курса остается без пересчета, список отдается все равно.
+// возвращает валюту пересчета; пустая - пересчет не запрошен
+func (s *transactionService) convertTransactions(ctx context.Context, transactions []models.Transaction, currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return ""
+	}
+
+	conv := newCurrencyConverter(s.marketProvider, currency)
+	for i := range transactions {
+		t := &transactions[i]
+		rate, err := conv.rateOn(ctx, t.Currency, t.Date)
+		if err != nil {
+			log.Printf("не удалось пересчитать транзакцию %s в %s: %v", t.ID, currency, err)
+			continue
+		}
+		converted := t.Amount.Mul(rate)
+		t.ConvertedAmount = &converted
+		t.ConversionRate = &rate
+	}
+	return currency
 }
 
 func (s *transactionService) Update(ctx context.Context, id uuid.UUID, update *models.TransactionUpdate) (*models.Transaction, error) {
@@ -134,9 +251,35 @@ func (s *transactionService) Update(ctx context.Context, id uuid.UUID, update *m
 		return nil, err
 	}
 
+	// координаты меняются только парой
+	if !validCoordinates(update.Latitude, update.Longitude) {
+		return nil, ErrInvalidCoordinates
+	}
+	if hash := transactionGeohash(update.Latitude, update.Longitude); hash != "" {
+		update.Geohash = &hash
+	}
+	if update.City != nil {
+		city := strings.TrimSpace(*update.City)
+		update.City = &city
+	}
+	if update.Date != nil {
+		date := s.localDate(ctx, original.UserID, *update.Date)
+		update.Date = &date
+	}
+
+	if update.PayeeID != nil {
+		if _, err := s.payeeService.GetByID(ctx, original.UserID, *update.PayeeID); err != nil {
+			return nil, err
+		}
+	}
+	resolvePayee := update.PayeeID == nil && update.Description != nil && original.Type != models.TransactionTypeTransfer
+
 	var updated *models.Transaction
 
 	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		if resolvePayee {
+			update.PayeeID = s.resolvePayee(txCtx, original.UserID, *update.Description)
+		}
 		// отменяем изменения на счетах предыдущей старой транзакции
 		switch original.Type {
 		case models.TransactionTypeIncome:
@@ -202,6 +345,26 @@ func (s *transactionService) Update(ctx context.Context, id uuid.UUID, update *m
 	return updated, nil
 }
 
+// resolvePayee подбирает получателя по описанию; ошибка не должна мешать сохранить транзакцию
+func (s *transactionService) resolvePayee(ctx context.Context, userID uuid.UUID, description string) *uuid.UUID {
+	payeeID, err := s.payeeService.Resolve(ctx, userID, description)
+	if err != nil {
+		log.Printf("не удалось определить получателя для %q: %v", description, err)
+		return nil
+	}
+	return payeeID
+}
+
+// resolveProduct подбирает товар по названию позиции; как и с получателем, ошибка не мешает сохранить транзакцию
+func (s *transactionService) resolveProduct(ctx context.Context, userID uuid.UUID, name string) *uuid.UUID {
+	productID, err := s.productService.Resolve(ctx, userID, name)
+	if err != nil {
+		log.Printf("не удалось определить товар для %q: %v", name, err)
+		return nil
+	}
+	return productID
+}
+
 func (s *transactionService) Delete(ctx context.Context, id uuid.UUID) error {
 	tx, err := s.transactionRepo.GetByID(ctx, id)
 	if err != nil {
@@ -237,3 +400,16 @@ func (s *transactionService) Delete(ctx context.Context, id uuid.UUID) error {
 		return s.transactionRepo.Delete(txCtx, id)
 	})
 }
+
+// localDate дата операции в календаре пользователя, как полночь UTC - так она хранится в колонке DATE
+// и так же читается обратно. полночь UTC уже считается датой без времени (так присылают клиенты и импорт),
+// момент с временем переводится в часовой пояс пользователя: 02:00 по Владивостоку - это его 1 июня, а не 31 мая
+func (s *transactionService) localDate(ctx context.Context, userID uuid.UUID, date time.Time) time.Time {
+	if _, offset := date.Zone(); offset == 0 && date.Equal(truncateDay(date)) {
+		return date.UTC()
+	}
+	if user, err := s.userRepo.GetByID(ctx, userID); err == nil {
+		date = date.In(userLocation(user))
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/alligatorO15/fin-tracker/internal/market"
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrTransactionNotFound   = errors.New("transaction not found")
+	ErrInvalidTransferMatch  = errors.New("transfer match requires an expense and an income on different accounts")
+	ErrTransferWindowInvalid = errors.New("days must be between 0 and 10")
+)
+
+const (
+	defaultTransferMatchDays     = 3  // зачисление в другом банке обычно приходит в течение пары дней
+	maxTransferMatchDays         = 10 // дальше совпадения сумм уже случайные
+	defaultTransferMatchLookback = 90 // за сколько дней ищем пары, если since не задан
+)
+
+// допустимое расхождение сумм при переводе между валютами (курс банка отличается от биржевого)
+var transferRateTolerance = decimal.NewFromFloat(0.03)
+
+type TransferMatchService interface {
+	// Find ищет пары расход/доход между разными счетами пользователя с одинаковой суммой
+	// (для разных валют - по курсу с допуском), даты которых отличаются не больше чем на days
+	Find(ctx context.Context, userID uuid.UUID, maxDays *int, since *time.Time) ([]models.TransferMatch, error)
+	// Confirm объединяет подтвержденную пару в одну транзакцию-перевод
+	Confirm(ctx context.Context, userID uuid.UUID, input *models.TransferMatchConfirm) (*models.Transaction, error)
+}
+
+type transferMatchService struct {
+	transactionRepo repository.TransactionRepository
+	marketProvider  *market.MultiProvider
+	txManager       repository.TxManager
+}
+
+func NewTransferMatchService(transactionRepo repository.TransactionRepository, marketProvider *market.MultiProvider, txManager repository.TxManager) TransferMatchService {
+	return &transferMatchService{
+		transactionRepo: transactionRepo,
+		marketProvider:  marketProvider,
+		txManager:       txManager,
+	}
+}
+
+// Find maxDays - сколько дней может пройти между списанием и зачислением, nil - defaultTransferMatchDays
+func (s *transferMatchService) Find(ctx context.Context, userID uuid.UUID, maxDays *int, since *time.Time) ([]models.TransferMatch, error) {
+	days := defaultTransferMatchDays
+	if maxDays != nil {
+		days = *maxDays
+	}
+	if days < 0 || days > maxTransferMatchDays {
+		return nil, ErrTransferWindowInvalid
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, -defaultTransferMatchLookback)
+	if since != nil {
+		from = *since
+	}
+
+	expenseType, incomeType := models.TransactionTypeExpense, models.TransactionTypeIncome
+	expenses, err := s.transactionRepo.GetByDateRange(ctx, userID, from, now, &expenseType)
+	if err != nil {
+		return nil, err
+	}
+	// зачисление может быть отражено раньше списания - расширяем окно доходов в обе стороны
+	incomes, err := s.transactionRepo.GetByDateRange(ctx, userID, from.AddDate(0, 0, -days), now, &incomeType)
+	if err != nil {
+		return nil, err
+	}
+
+	// курсы кешируются по валюте зачисления
+	converters := make(map[string]*currencyConverter)
+	used := make(map[uuid.UUID]bool, len(incomes))
+	matches := make([]models.TransferMatch, 0)
+
+	for _, out := range expenses {
+		var best *models.TransferMatch
+		var bestDiff decimal.Decimal
+
+		for i := range incomes {
+			in := incomes[i]
+			if used[in.ID] || in.AccountID == out.AccountID {
+				continue
+			}
+			daysApart := transferDaysApart(out.Date, in.Date)
+			if daysApart > days {
+				continue
+			}
+
+			expected := out.Amount
+			var rate *decimal.Decimal
+			if in.Currency != out.Currency {
+				converter, ok := converters[in.Currency]
+				if !ok {
+					converter = newCurrencyConverter(s.marketProvider, in.Currency)
+					converters[in.Currency] = converter
+				}
+				// курса нет - такие пары не предлагаем
+				if expected, err = converter.convert(ctx, out.Amount, out.Currency); err != nil || expected.IsZero() {
+					continue
+				}
+				r := in.Amount.Div(out.Amount)
+				rate = &r
+			}
+
+			diff := in.Amount.Sub(expected).Abs()
+			if in.Currency == out.Currency && !diff.IsZero() {
+				continue
+			}
+			if in.Currency != out.Currency && diff.GreaterThan(expected.Mul(transferRateTolerance)) {
+				continue
+			}
+
+			// из нескольких кандидатов берем ближайший по дате, затем по сумме
+			if best == nil || daysApart < best.DaysApart || (daysApart == best.DaysApart && diff.LessThan(bestDiff)) {
+				best = &models.TransferMatch{Outgoing: out, Incoming: in, DaysApart: daysApart, Rate: rate}
+				bestDiff = diff
+			}
+		}
+
+		if best != nil {
+			used[best.Incoming.ID] = true
+			matches = append(matches, *best)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Outgoing.Date.After(matches[j].Outgoing.Date)
+	})
+	return matches, nil
+}
+
+func (s *transferMatchService) Confirm(ctx context.Context, userID uuid.UUID, input *models.TransferMatchConfirm) (*models.Transaction, error) {
+	outgoing, err := s.transactionRepo.GetByID(ctx, input.OutgoingID)
+	if err != nil || outgoing.UserID != userID {
+		return nil, ErrTransactionNotFound
+	}
+	incoming, err := s.transactionRepo.GetByID(ctx, input.IncomingID)
+	if err != nil || incoming.UserID != userID {
+		return nil, ErrTransactionNotFound
+	}
+
+	if outgoing.Type != models.TransactionTypeExpense || incoming.Type != models.TransactionTypeIncome ||
+		outgoing.AccountID == incoming.AccountID {
+		return nil, ErrInvalidTransferMatch
+	}
+
+	// расход уже списал сумму с первого счета, доход зачислил на второй - перевод с теми же
+	// суммами дает тот же итог, поэтому балансы счетов не пересчитываем
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		// расход мог уже стать переводом или быть удален параллельным запросом
+		converted, err := s.transactionRepo.ConvertToTransfer(txCtx, outgoing.ID, incoming.AccountID, incoming.Amount)
+		if err != nil {
+			return err
+		}
+		if !converted {
+			return ErrInvalidTransferMatch
+		}
+		return s.transactionRepo.Delete(txCtx, incoming.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transactionRepo.GetByID(ctx, outgoing.ID)
+}
+
+// transferDaysApart разница дат в днях без учета времени
+func transferDaysApart(a, b time.Time) int {
+	diff := truncateDay(a).Sub(truncateDay(b))
+	if diff < 0 {
+		diff = -diff
+	}
+	return int(diff.Hours() / 24)
+}
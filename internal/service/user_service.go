@@ -2,37 +2,379 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
+	"github.com/alligatorO15/fin-tracker/internal/config"
 	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/notify"
+	"github.com/alligatorO15/fin-tracker/internal/password"
 	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/alligatorO15/fin-tracker/internal/storage"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidPassword     = errors.New("invalid password")
+	ErrAttachmentsDisabled = errors.New("file uploads are disabled: ATTACHMENTS_DIR is not configured")
+	ErrInvalidImage        = errors.New("unsupported or corrupted image")
+	ErrAvatarNotFound      = errors.New("avatar not found")
+	ErrInvalidBirthDate    = errors.New("birth_date must be in the past")
+	ErrInvalidDeletionCode = errors.New("invalid or expired account deletion code")
+	ErrNothingToRestore    = errors.New("no deleted account with these credentials can be restored")
+)
+
+const (
+	// сторона квадратного аватара после уменьшения, пикселей
+	avatarSize = 256
+	// сколько действует код подтверждения удаления из письма
+	deletionCodeTTL = time.Hour
 )
 
 type UserService interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	Update(ctx context.Context, id uuid.UUID, update *models.UserUpdate) (*models.User, error)
-	Delete(ctx context.Context, id uuid.UUID) error
+	// UpdateProfile телефон, дата рождения и аватар (nil - аватар не меняется)
+	UpdateProfile(ctx context.Context, id uuid.UUID, update *models.UserProfileUpdate, avatar []byte) (*models.User, error)
+	GetAvatar(ctx context.Context, id uuid.UUID) ([]byte, error)
+	DeleteAvatar(ctx context.Context, id uuid.UUID) (*models.User, error)
+	// Delete с настроенной почтой отправляет код подтверждения, без нее проверяет пароль и сразу планирует удаление
+	Delete(ctx context.Context, id uuid.UUID, input *models.UserDeleteRequest, client models.ClientInfo) (*models.UserDeleteResult, error)
+	// ConfirmDelete планирует удаление по коду из письма
+	ConfirmDelete(ctx context.Context, token string, client models.ClientInfo) (*models.AccountDeletion, error)
+	// Restore отменяет удаление до конца льготного периода
+	Restore(ctx context.Context, input *models.UserRestoreRequest) error
+	PurgeDeleted(ctx context.Context) (int, error)
 }
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	deletionRepo     repository.AccountDeletionRepository
+	txManager        repository.TxManager
+	storage          storage.Storage // nil - загрузка файлов выключена
+	quota            QuotaService
+	hasher           *password.Hasher
+	mailer           notify.Mailer // nil - удаление подтверждается паролем
+	config           *config.Config
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(
+	userRepo repository.UserRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	deletionRepo repository.AccountDeletionRepository,
+	txManager repository.TxManager,
+	storage storage.Storage,
+	quota QuotaService,
+	hasher *password.Hasher,
+	mailer notify.Mailer,
+	cfg *config.Config,
+) UserService {
+	return &userService{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		deletionRepo:     deletionRepo,
+		txManager:        txManager,
+		storage:          storage,
+		quota:            quota,
+		hasher:           hasher,
+		mailer:           mailer,
+		config:           cfg,
+	}
 }
 
 func (s *userService) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
-	return s.userRepo.GetByID(ctx, id)
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return withAvatarURL(user), nil
+}
+
+func (s *userService) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
 }
 
 func (s *userService) Update(ctx context.Context, id uuid.UUID, update *models.UserUpdate) (*models.User, error) {
 	if err := s.userRepo.Update(ctx, id, update); err != nil {
 		return nil, err
 	}
-	return s.userRepo.GetByID(ctx, id)
+	return s.GetByID(ctx, id)
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, id uuid.UUID, update *models.UserProfileUpdate, avatar []byte) (*models.User, error) {
+	if update.BirthDate != nil {
+		if !update.BirthDate.Before(time.Now()) {
+			return nil, ErrInvalidBirthDate
+		}
+		date := time.Date(update.BirthDate.Year(), update.BirthDate.Month(), update.BirthDate.Day(), 0, 0, 0, 0, time.UTC)
+		update.BirthDate = &date
+	}
+
+	var key string
+	if avatar != nil {
+		if s.storage == nil {
+			return nil, ErrAttachmentsDisabled
+		}
+		resized, err := storage.SquareJPEG(avatar, avatarSize)
+		if err != nil {
+			if errors.Is(err, storage.ErrInvalidImage) {
+				return nil, ErrInvalidImage
+			}
+			return nil, err
+		}
+		// в квоту идет исходный файл: его и загружал пользователь
+		if err := s.quota.UseAttachment(ctx, id, int64(len(avatar))); err != nil {
+			return nil, err
+		}
+		key = avatarKey(id)
+		if err := s.storage.Put(ctx, key, resized); err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdateProfile(ctx, id, update); err != nil {
+			return err
+		}
+		if key != "" {
+			return s.userRepo.SetAvatarKey(ctx, id, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
+
+func (s *userService) GetAvatar(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.AvatarKey == "" || s.storage == nil {
+		return nil, ErrAvatarNotFound
+	}
+	data, err := s.storage.Get(ctx, user.AvatarKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrAvatarNotFound
+	}
+	return data, err
+}
+
+func (s *userService) DeleteAvatar(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.AvatarKey == "" {
+		return nil, ErrAvatarNotFound
+	}
+	if err := s.userRepo.SetAvatarKey(ctx, id, ""); err != nil {
+		return nil, err
+	}
+	if s.storage != nil {
+		if err := s.storage.Delete(ctx, user.AvatarKey); err != nil {
+			log.Printf("Не удалось удалить аватар %s: %v", user.AvatarKey, err)
+		}
+	}
+	return s.GetByID(ctx, id)
 }
 
-func (s *userService) Delete(ctx context.Context, id uuid.UUID) error {
-	return s.userRepo.Delete(ctx, id)
+// Delete запрос на удаление аккаунта. с настроенной почтой на email уходит код, и удаление планируется
+// только после ConfirmDelete; без почты подтверждение - повторный ввод пароля
+func (s *userService) Delete(ctx context.Context, id uuid.UUID, input *models.UserDeleteRequest, client models.ClientInfo) (*models.UserDeleteResult, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.mailer == nil {
+		if ok, _ := s.hasher.Verify(user.PasswordHash, password.Version(user.PasswordHashVersion), input.Password); !ok {
+			return nil, ErrInvalidPassword
+		}
+		deletion, err := s.scheduleDeletion(ctx, user, input.Reason, client)
+		if err != nil {
+			return nil, err
+		}
+		return &models.UserDeleteResult{Status: models.UserDeleteScheduled, PurgeAfter: &deletion.PurgeAfter}, nil
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	expiresAt := time.Now().Add(deletionCodeTTL)
+	if err := s.deletionRepo.CreateToken(ctx, id, token, input.Reason, expiresAt); err != nil {
+		return nil, err
+	}
+
+	err = s.mailer.Send(ctx, notify.Email{
+		To:      user.Email,
+		Subject: "Подтверждение удаления аккаунта FinTracker",
+		Text: fmt.Sprintf("С адреса %s запрошено удаление вашего аккаунта.\n\n"+
+			"Чтобы удалить аккаунт, подтвердите запрос кодом: %s\n"+
+			"Код действует до %s. Если это были не вы - просто проигнорируйте письмо и смените пароль.\n",
+			client.IPAddress, token, expiresAt.In(userLocation(user)).Format("02.01.2006 15:04")),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.UserDeleteResult{Status: models.UserDeleteConfirmationSent, ConfirmBy: &expiresAt}, nil
+}
+
+func (s *userService) ConfirmDelete(ctx context.Context, token string, client models.ClientInfo) (*models.AccountDeletion, error) {
+	userID, reason, err := s.deletionRepo.UseToken(ctx, strings.TrimSpace(token), time.Now())
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidDeletionCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidDeletionCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.scheduleDeletion(ctx, user, reason, client)
+}
+
+// scheduleDeletion скрывает аккаунт сразу (soft delete) и ставит его в очередь на физическое удаление
+// после льготного периода
+func (s *userService) scheduleDeletion(ctx context.Context, user *models.User, reason string, client models.ClientInfo) (*models.AccountDeletion, error) {
+	emailHash := sha256.Sum256([]byte(strings.ToLower(user.Email)))
+	deletion := &models.AccountDeletion{
+		UserID:     user.ID,
+		EmailHash:  hex.EncodeToString(emailHash[:]),
+		Reason:     reason,
+		IPAddress:  client.IPAddress,
+		PurgeAfter: time.Now().Add(s.config.UserPurgeGracePeriod),
+	}
+
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Delete(ctx, user.ID); err != nil {
+			return err
+		}
+		return s.deletionRepo.Create(ctx, deletion)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// выкидываем со всех устройств
+	if err := s.refreshTokenRepo.RevokeAllForUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	return deletion, nil
+}
+
+// Restore отменяет удаление: нужны email и пароль удаленного аккаунта, льготный период еще не истек.
+// если email за это время занял новый аккаунт - ErrUserExists
+func (s *userService) Restore(ctx context.Context, input *models.UserRestoreRequest) error {
+	user, err := s.userRepo.GetDeletedByEmail(ctx, strings.TrimSpace(input.Email))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNothingToRestore
+	}
+	if err != nil {
+		return err
+	}
+	if ok, _ := s.hasher.Verify(user.PasswordHash, password.Version(user.PasswordHashVersion), input.Password); !ok {
+		return ErrNothingToRestore
+	}
+
+	deletion, err := s.deletionRepo.GetPending(ctx, user.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNothingToRestore
+	}
+	if err != nil {
+		return err
+	}
+	if !deletion.PurgeAfter.After(time.Now()) {
+		return ErrNothingToRestore
+	}
+
+	err = s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		// отметка первой: если очистка уже началась, восстанавливать нечего
+		restored, err := s.deletionRepo.MarkRestored(ctx, deletion.ID)
+		if err != nil {
+			return err
+		}
+		if !restored {
+			return ErrNothingToRestore
+		}
+		return s.userRepo.Restore(ctx, user.ID)
+	})
+	if errors.Is(err, repository.ErrDuplicateEmail) {
+		return ErrUserExists
+	}
+	return err
+}
+
+// PurgeDeleted физически удаляет данные пользователей, у которых истек льготный период
+func (s *userService) PurgeDeleted(ctx context.Context) (int, error) {
+	due, err := s.deletionRepo.GetDue(ctx, time.Now(), 100)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, deletion := range due {
+		var marked bool
+		err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+			// отметка первой: аккаунт, восстановленный после выборки, не трогаем
+			var err error
+			if marked, err = s.deletionRepo.MarkPurged(ctx, deletion.ID); err != nil || !marked {
+				return err
+			}
+			return s.deletionRepo.PurgeUserData(ctx, deletion.UserID)
+		})
+		if err != nil {
+			log.Printf("Не удалось удалить данные пользователя %s: %v", deletion.UserID, err)
+			continue
+		}
+		if !marked {
+			continue
+		}
+		// файлы живут вне бд - удаляем после строк
+		if s.storage != nil {
+			if err := s.storage.Delete(ctx, avatarKey(deletion.UserID)); err != nil {
+				log.Printf("Не удалось удалить аватар пользователя %s: %v", deletion.UserID, err)
+			}
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// avatarKey у пользователя один аватар, новый перезаписывает старый
+func avatarKey(userID uuid.UUID) string {
+	return "avatars/" + userID.String() + ".jpg"
+}
+
+// withAvatarURL ссылка на аватар для клиента; версия по updated_at сбрасывает кэш браузера
+func withAvatarURL(user *models.User) *models.User {
+	if user.AvatarKey != "" {
+		user.AvatarURL = fmt.Sprintf("/api/v1/user/avatar?v=%d", user.UpdatedAt.Unix())
+	}
+	return user
 }
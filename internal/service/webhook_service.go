@@ -0,0 +1,484 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alligatorO15/fin-tracker/internal/models"
+	"github.com/alligatorO15/fin-tracker/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookInactive         = errors.New("webhook is disabled")
+	ErrWebhookInvalidPayload   = errors.New("payload must be a JSON object")
+	ErrWebhookInvalidAmount    = errors.New("amount is missing or is not a number")
+	ErrWebhookInvalidType      = errors.New("operation type is not income or expense; add it to template.type_values")
+	ErrWebhookInvalidDate      = errors.New("date must be RFC 3339, YYYY-MM-DD or unix time")
+	ErrWebhookCurrencyMismatch = errors.New("payload currency does not match the account currency")
+)
+
+// путь вебхука без токена; полный адрес - webhookPathPrefix + токен
+const webhookPathPrefix = "/api/v1/hooks/"
+
+// стандартная схема уведомления: {"id", "amount", "type", "description", "date", "currency", "card"}
+var defaultWebhookTemplate = models.WebhookTemplate{
+	Amount:      "amount",
+	Type:        "type",
+	Description: "description",
+	Date:        "date",
+	Currency:    "currency",
+	Card:        "card",
+	ExternalID:  "id",
+}
+
+var webhookDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+}
+
+type WebhookService interface {
+	// Create создает вебхук и возвращает секретный адрес; позже его можно только сменить
+	Create(ctx context.Context, userID uuid.UUID, input *models.WebhookEndpointCreate) (*models.WebhookEndpointSecret, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.WebhookEndpoint, error)
+	Update(ctx context.Context, userID, id uuid.UUID, update *models.WebhookEndpointUpdate) (*models.WebhookEndpoint, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	// RotateToken выдает новый адрес, старый сразу перестает работать
+	RotateToken(ctx context.Context, userID, id uuid.UUID) (*models.WebhookEndpointSecret, error)
+
+	// Ingest разбирает уведомление по шаблону вебхука и создает транзакцию.
+	// повтор с тем же id операции возвращает уже созданную транзакцию и created = false
+	Ingest(ctx context.Context, token string, payload []byte) (tx *models.Transaction, created bool, err error)
+}
+
+type webhookService struct {
+	webhookRepo        repository.WebhookRepository
+	accountRepo        repository.AccountRepository
+	categoryRepo       repository.CategoryRepository
+	transactionService TransactionService
+	txManager          repository.TxManager
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepository, accountRepo repository.AccountRepository, categoryRepo repository.CategoryRepository, transactionService TransactionService, txManager repository.TxManager) WebhookService {
+	return &webhookService{
+		webhookRepo:        webhookRepo,
+		accountRepo:        accountRepo,
+		categoryRepo:       categoryRepo,
+		transactionService: transactionService,
+		txManager:          txManager,
+	}
+}
+
+func (s *webhookService) Create(ctx context.Context, userID uuid.UUID, input *models.WebhookEndpointCreate) (*models.WebhookEndpointSecret, error) {
+	if err := s.checkAccount(ctx, userID, input.AccountID); err != nil {
+		return nil, err
+	}
+	// категории подставляются во все транзакции из вебхука, чужие недопустимы
+	if err := s.checkCategory(ctx, userID, input.CategoryID); err != nil {
+		return nil, err
+	}
+	if input.IncomeCategoryID != nil {
+		if err := s.checkCategory(ctx, userID, *input.IncomeCategoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	token, tokenHash, err := newWebhookToken()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		UserID:           userID,
+		Name:             strings.TrimSpace(input.Name),
+		TokenHash:        tokenHash,
+		AccountID:        input.AccountID,
+		CategoryID:       input.CategoryID,
+		IncomeCategoryID: input.IncomeCategoryID,
+		IsActive:         true,
+	}
+	if input.Template != nil {
+		endpoint.Template = *input.Template
+	}
+
+	if err := s.webhookRepo.Create(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return webhookSecret(endpoint, token), nil
+}
+
+func (s *webhookService) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.WebhookEndpoint, error) {
+	return s.webhookRepo.GetByUserID(ctx, userID)
+}
+
+func (s *webhookService) get(ctx context.Context, userID, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.webhookRepo.GetByID(ctx, id)
+	if err != nil || endpoint.UserID != userID {
+		return nil, ErrWebhookNotFound
+	}
+	return endpoint, nil
+}
+
+func (s *webhookService) Update(ctx context.Context, userID, id uuid.UUID, update *models.WebhookEndpointUpdate) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		endpoint.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.AccountID != nil {
+		if err := s.checkAccount(ctx, userID, *update.AccountID); err != nil {
+			return nil, err
+		}
+		endpoint.AccountID = *update.AccountID
+	}
+	if update.CategoryID != nil {
+		if err := s.checkCategory(ctx, userID, *update.CategoryID); err != nil {
+			return nil, err
+		}
+		endpoint.CategoryID = *update.CategoryID
+	}
+	if update.IncomeCategoryID != nil {
+		if err := s.checkCategory(ctx, userID, *update.IncomeCategoryID); err != nil {
+			return nil, err
+		}
+		endpoint.IncomeCategoryID = update.IncomeCategoryID
+	}
+	if update.Template != nil {
+		endpoint.Template = *update.Template
+	}
+	if update.IsActive != nil {
+		endpoint.IsActive = *update.IsActive
+	}
+
+	if err := s.webhookRepo.Update(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *webhookService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.get(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(ctx, id)
+}
+
+func (s *webhookService) RotateToken(ctx context.Context, userID, id uuid.UUID) (*models.WebhookEndpointSecret, error) {
+	endpoint, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	token, tokenHash, err := newWebhookToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.webhookRepo.SetTokenHash(ctx, id, tokenHash); err != nil {
+		return nil, err
+	}
+	endpoint.TokenHash = tokenHash
+	return webhookSecret(endpoint, token), nil
+}
+
+func (s *webhookService) Ingest(ctx context.Context, token string, payload []byte) (*models.Transaction, bool, error) {
+	endpoint, err := s.webhookRepo.GetByTokenHash(ctx, hashWebhookToken(token))
+	if err != nil {
+		return nil, false, ErrWebhookNotFound
+	}
+	if !endpoint.IsActive {
+		return nil, false, ErrWebhookInactive
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false, ErrWebhookInvalidPayload
+	}
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return nil, false, ErrWebhookInvalidPayload
+	}
+
+	tmpl := endpoint.Template
+	field := func(path, fallback string) string {
+		if path == "" {
+			path = fallback
+		}
+		return webhookString(lookupJSONPath(doc, path))
+	}
+
+	externalID := field(tmpl.ExternalID, defaultWebhookTemplate.ExternalID)
+	if externalID != "" {
+		if delivery, err := s.webhookRepo.GetDelivery(ctx, endpoint.ID, externalID); err == nil {
+			tx, err := s.transactionService.GetByID(ctx, delivery.TransactionID)
+			return tx, false, err
+		}
+	}
+
+	amount, err := parseWebhookAmount(field(tmpl.Amount, defaultWebhookTemplate.Amount))
+	if err != nil {
+		return nil, false, err
+	}
+	txType, err := webhookType(field(tmpl.Type, defaultWebhookTemplate.Type), tmpl.TypeValues, amount)
+	if err != nil {
+		return nil, false, err
+	}
+	date, err := parseWebhookDate(field(tmpl.Date, defaultWebhookTemplate.Date))
+	if err != nil {
+		return nil, false, err
+	}
+
+	accounts, err := s.accountRepo.GetByUserID(ctx, endpoint.UserID)
+	if err != nil {
+		return nil, false, err
+	}
+	accountID := matchDraftAccount(accounts, cardLast4(field(tmpl.Card, defaultWebhookTemplate.Card)), &endpoint.AccountID)
+	account := findAccount(accounts, *accountID)
+	if account == nil {
+		return nil, false, ErrAccountNotFound
+	}
+	if currency := strings.ToUpper(field(tmpl.Currency, defaultWebhookTemplate.Currency)); currency != "" && currency != account.Currency {
+		return nil, false, ErrWebhookCurrencyMismatch
+	}
+
+	create := &models.TransactionCreate{
+		AccountID:   account.ID,
+		CategoryID:  endpoint.CategoryID,
+		Type:        txType,
+		Amount:      amount.Abs(),
+		Description: field(tmpl.Description, defaultWebhookTemplate.Description),
+		Date:        date,
+		Notes:       "Из вебхука: " + endpoint.Name,
+	}
+	if txType == models.TransactionTypeIncome && endpoint.IncomeCategoryID != nil {
+		create.CategoryID = *endpoint.IncomeCategoryID
+	}
+	// эндпоинты, настроенные до проверки категорий, могли сохранить чужую
+	if err := s.checkCategory(ctx, endpoint.UserID, create.CategoryID); err != nil {
+		return nil, false, err
+	}
+
+	var tx *models.Transaction
+	duplicate := false
+	err = s.txManager.WithTx(ctx, func(txCtx context.Context) error {
+		var err error
+		tx, err = s.transactionService.Create(txCtx, endpoint.UserID, create)
+		if err != nil {
+			return err
+		}
+		if externalID == "" {
+			return nil
+		}
+		created, err := s.webhookRepo.CreateDelivery(txCtx, &models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			ExternalID:    externalID,
+			TransactionID: tx.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if !created {
+			// то же уведомление пришло параллельно и уже проведено - откатываем свою копию
+			duplicate = true
+			return errWebhookDuplicate
+		}
+		return nil
+	})
+	if duplicate {
+		delivery, err := s.webhookRepo.GetDelivery(ctx, endpoint.ID, externalID)
+		if err != nil {
+			return nil, false, err
+		}
+		tx, err := s.transactionService.GetByID(ctx, delivery.TransactionID)
+		return tx, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := s.webhookRepo.MarkUsed(ctx, endpoint.ID); err != nil {
+		log.Printf("Не удалось отметить использование вебхука %s: %v", endpoint.ID, err)
+	}
+	return tx, true, nil
+}
+
+// errWebhookDuplicate откатывает транзакцию при гонке двух одинаковых доставок, наружу не выходит
+var errWebhookDuplicate = errors.New("webhook delivery already processed")
+
+func (s *webhookService) checkAccount(ctx context.Context, userID, accountID uuid.UUID) error {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil || account.UserID != userID {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+func (s *webhookService) checkCategory(ctx context.Context, userID, categoryID uuid.UUID) error {
+	category, err := s.categoryRepo.GetByID(ctx, categoryID)
+	if err != nil || (!category.IsSystem && (category.UserID == nil || *category.UserID != userID)) {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+func findAccount(accounts []models.Account, id uuid.UUID) *models.Account {
+	for i := range accounts {
+		if accounts[i].ID == id {
+			return &accounts[i]
+		}
+	}
+	return nil
+}
+
+// newWebhookToken случайный токен для адреса и его хэш для хранения в бд
+func newWebhookToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashWebhookToken(token), nil
+}
+
+func hashWebhookToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func webhookSecret(endpoint *models.WebhookEndpoint, token string) *models.WebhookEndpointSecret {
+	return &models.WebhookEndpointSecret{
+		WebhookEndpoint: *endpoint,
+		Token:           token,
+		URL:             webhookPathPrefix + token,
+	}
+}
+
+// lookupJSONPath значение по пути "a.b.0.c"; nil, если чего-то на пути нет
+func lookupJSONPath(doc interface{}, path string) interface{} {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			current = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			current = node[i]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+func webhookString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// parseWebhookAmount сумма из числа или строки вида "-1 250,50 ₽"
+func parseWebhookAmount(raw string) (decimal.Decimal, error) {
+	var b strings.Builder
+	for _, r := range raw {
+		switch {
+		case unicode.IsDigit(r), r == '.', r == '-':
+			b.WriteRune(r)
+		case r == ',':
+			b.WriteRune('.')
+		case r == '−': // минус из типографики iOS
+			b.WriteRune('-')
+		}
+	}
+
+	amount, err := decimal.NewFromString(b.String())
+	if err != nil || amount.IsZero() {
+		return decimal.Zero, ErrWebhookInvalidAmount
+	}
+	return amount, nil
+}
+
+// webhookType тип операции по значению из уведомления или, если его нет, по знаку суммы
+func webhookType(raw string, values map[string]string, amount decimal.Decimal) (models.TransactionType, error) {
+	if raw == "" {
+		if amount.IsNegative() {
+			return models.TransactionTypeExpense, nil
+		}
+		return models.TransactionTypeIncome, nil
+	}
+
+	if mapped, ok := values[raw]; ok {
+		raw = mapped
+	}
+	switch models.TransactionType(strings.ToLower(raw)) {
+	case models.TransactionTypeIncome:
+		return models.TransactionTypeIncome, nil
+	case models.TransactionTypeExpense:
+		return models.TransactionTypeExpense, nil
+	}
+	return "", ErrWebhookInvalidType
+}
+
+// parseWebhookDate дата операции; без даты - момент получения уведомления
+func parseWebhookDate(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Now(), nil
+	}
+
+	if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		if unix > 1e12 { // миллисекунды
+			return time.UnixMilli(unix), nil
+		}
+		return time.Unix(unix, 0), nil
+	}
+
+	for _, layout := range webhookDateLayouts {
+		if t, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrWebhookInvalidDate
+}
+
+// cardLast4 последние четыре цифры из номера или маски карты ("*1234", "MIR •• 1234")
+func cardLast4(raw string) string {
+	var digits []rune
+	for _, r := range raw {
+		if unicode.IsDigit(r) {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) < 4 {
+		return ""
+	}
+	return string(digits[len(digits)-4:])
+}
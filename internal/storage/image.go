@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	// форматы, которые принимаем на вход
+	_ "image/gif"
+	_ "image/png"
+)
+
+var ErrInvalidImage = errors.New("неподдерживаемое или поврежденное изображение")
+
+// картинки больше этого не декодируем: маленький файл может распаковаться в гигабайты
+const maxImagePixels = 50_000_000
+
+// SquareJPEG обрезает изображение до квадрата по центру, уменьшает до size x size
+// и сохраняет в JPEG. меньшие картинки не растягиваются
+func SquareJPEG(data []byte, size int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrInvalidImage
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2))
+	size = min(size, side)
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, downscale(src, crop, size), &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// downscale уменьшает квадрат crop до size x size усреднением пикселей, попавших в каждую клетку
+func downscale(src image.Image, crop image.Rectangle, size int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	side := crop.Dx()
+	for y := 0; y < size; y++ {
+		y0, y1 := crop.Min.Y+y*side/size, crop.Min.Y+(y+1)*side/size
+		for x := 0; x < size; x++ {
+			x0, x1 := crop.Min.X+x*side/size, crop.Min.X+(x+1)*side/size
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			// прозрачные области - на белом фоне, в JPEG альфа-канала нет
+			white := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{R: uint16(r/n + white), G: uint16(g/n + white), B: uint16(bl/n + white), A: 0xffff})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,101 @@
+// Package telegram - тонкий клиент Bot API: входящие обновления приходят вебхуком,
+// ответы и уведомления уходят методом sendMessage
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrAPI = errors.New("telegram bot api вернул ошибку")
+
+// Update входящее обновление; бот обрабатывает только текстовые сообщения
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	From      *User  `json:"from"`
+	Chat      Chat   `json:"chat"`
+	Date      int64  `json:"date"` // unix time
+	Text      string `json:"text"`
+}
+
+type User struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+}
+
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"` // private, group...
+}
+
+// Bot отправка сообщений пользователям
+type Bot interface {
+	SendMessage(ctx context.Context, chatID int64, text string) error
+}
+
+var _ Bot = (*Client)(nil)
+
+// Client клиент Bot API по токену бота от @BotFather
+type Client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+type apiResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+func (c *Client) SendMessage(ctx context.Context, chatID int64, text string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/bot"+c.token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%w: HTTP %d", ErrAPI, resp.StatusCode)
+	}
+	if !result.OK {
+		return fmt.Errorf("%w: %s", ErrAPI, result.Description)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// Entry операция из сообщения вида "такси 450", "450 кофе вчера" или "+50000 зарплата"
+type Entry struct {
+	Amount      decimal.Decimal
+	Description string
+	Income      bool // сумма с плюсом
+	DaysAgo     int  // "вчера" - 1, "позавчера" - 2
+}
+
+// слова, сдвигающие дату операции
+var entryDays = map[string]int{
+	"сегодня":   0,
+	"вчера":     1,
+	"позавчера": 2,
+}
+
+// ParseEntry ищет в сообщении сумму и описание; false - суммы нет или описания не осталось.
+// сумма - первое слово из цифр: "1 500" не склеивается, "1500,50" и "1500.50" читаются одинаково
+func ParseEntry(text string) (*Entry, bool) {
+	entry := &Entry{}
+	var words []string
+	found := false
+
+	for _, word := range strings.Fields(text) {
+		if !found {
+			if amount, income, ok := parseAmount(word); ok {
+				entry.Amount, entry.Income, found = amount, income, true
+				continue
+			}
+		}
+		if days, ok := entryDays[strings.ToLower(word)]; ok {
+			entry.DaysAgo = days
+			continue
+		}
+		words = append(words, word)
+	}
+
+	entry.Description = strings.Join(words, " ")
+	if !found || entry.Description == "" {
+		return nil, false
+	}
+	return entry, true
+}
+
+// parseAmount сумма из слова: "450", "+50000", "99,90", "450р", "450₽"
+func parseAmount(word string) (decimal.Decimal, bool, bool) {
+	income := strings.HasPrefix(word, "+")
+	word = strings.TrimPrefix(word, "+")
+	word = strings.TrimRightFunc(strings.ToLower(word), func(r rune) bool {
+		return r == '₽' || r == 'р' || r == '.'
+	})
+	if word == "" || !unicode.IsDigit(rune(word[0])) {
+		return decimal.Zero, false, false
+	}
+
+	amount, err := decimal.NewFromString(strings.Replace(word, ",", ".", 1))
+	if err != nil || !amount.IsPositive() {
+		return decimal.Zero, false, false
+	}
+	return amount, income, true
+}
+
+// Date дата операции в часовом поясе loc
+func (e *Entry) Date(now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc).AddDate(0, 0, -e.DaysAgo)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}